@@ -40,6 +40,7 @@ var (
 	Version           = "dev"
 	Commit            = "none"
 	BuildDate         = "unknown"
+	ReleasePublicKey  = ""
 	DefaultConfigPath = ""
 )
 
@@ -49,6 +50,7 @@ func init() {
 	buildinfo.Version = Version
 	buildinfo.Commit = Commit
 	buildinfo.BuildDate = BuildDate
+	buildinfo.ReleasePublicKey = ReleasePublicKey
 }
 
 // setKiroIncognitoMode sets the incognito browser mode for Kiro authentication.
@@ -64,10 +66,45 @@ func setKiroIncognitoMode(cfg *config.Config, useIncognito, noIncognito bool) {
 	}
 }
 
+// runConfigValidation validates the config file at configPath (or
+// ./config.yaml when empty) and returns the process exit code.
+func runConfigValidation(configPath string) int {
+	if configPath == "" {
+		wd, errWd := os.Getwd()
+		if errWd != nil {
+			fmt.Fprintf(os.Stderr, "failed to get working directory: %v\n", errWd)
+			return 1
+		}
+		configPath = filepath.Join(wd, "config.yaml")
+	}
+	if errValidate := config.ValidateConfigFile(configPath); errValidate != nil {
+		fmt.Fprintln(os.Stderr, errValidate.Error())
+		return 1
+	}
+	fmt.Printf("%s: configuration is valid\n", configPath)
+	return 0
+}
+
 // main is the entry point of the application.
 // It parses command-line flags, loads configuration, and starts the appropriate
 // service based on the provided flags (login, codex-login, or server mode).
 func main() {
+	// Subcommands run before the banner so their stdout carries only output.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "translate":
+			os.Exit(cmd.RunTranslate(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "gen-golden":
+			os.Exit(cmd.RunGenGolden(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "install-service", "uninstall-service":
+			os.Exit(cmd.RunServiceCommand(os.Args[1], os.Args[2:], os.Stdout, os.Stderr))
+		case "init":
+			os.Exit(cmd.RunInit(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "update":
+			os.Exit(cmd.RunUpdate(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
 
 	// Command-line flags to control the application's behavior.
@@ -103,6 +140,7 @@ func main() {
 	var standalone bool
 	var noIncognito bool
 	var useIncognito bool
+	var validateConfig bool
 
 	// Define command-line flags for different operation modes.
 	flag.BoolVar(&login, "login", false, "Login Google Account")
@@ -133,10 +171,11 @@ func main() {
 	flag.BoolVar(&githubCopilotLogin, "github-copilot-login", false, "Login to GitHub Copilot using device flow")
 	flag.StringVar(&projectID, "project_id", "", "Project ID (Gemini only, not required)")
 	flag.StringVar(&configPath, "config", DefaultConfigPath, "Configure File Path")
-	flag.StringVar(&vertexImport, "vertex-import", "", "Import Vertex service account key JSON file")
+	flag.StringVar(&vertexImport, "vertex-import", "", "Import Vertex service account key or gcloud authorized_user JSON file")
 	flag.StringVar(&password, "password", "", "")
 	flag.BoolVar(&tuiMode, "tui", false, "Start with terminal management UI")
 	flag.BoolVar(&standalone, "standalone", false, "In TUI mode, start an embedded local server")
+	flag.BoolVar(&validateConfig, "validate-config", false, "Validate the config file against the schema and exit (non-zero on errors)")
 
 	flag.CommandLine.Usage = func() {
 		out := flag.CommandLine.Output()
@@ -168,6 +207,10 @@ func main() {
 	// Parse the command-line flags.
 	flag.Parse()
 
+	if validateConfig {
+		os.Exit(runConfigValidation(configPath))
+	}
+
 	// Core application variables.
 	var err error
 	var cfg *config.Config
@@ -470,7 +513,7 @@ func main() {
 	log.Infof("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
 
 	// Set the log level based on the configuration.
-	util.SetLogLevel(cfg)
+	logging.SetLogLevel(cfg)
 
 	if resolvedAuthDir, errResolveAuthDir := util.ResolveAuthDir(cfg.AuthDir); errResolveAuthDir != nil {
 		log.Errorf("failed to resolve auth directory: %v", errResolveAuthDir)
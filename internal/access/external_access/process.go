@@ -0,0 +1,70 @@
+package externalaccess
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	restartBackoffMin = time.Second
+	restartBackoffMax = time.Minute
+	// stableRunTime resets the backoff once a process has stayed up this long.
+	stableRunTime = 30 * time.Second
+)
+
+// process supervises the command behind an external provider, restarting it
+// with exponential backoff whenever it exits.
+type process struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+func startProcess(name string, command []string, env map[string]string) *process {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &process{cancel: cancel, done: make(chan struct{})}
+	environ := os.Environ()
+	for key, value := range env {
+		environ = append(environ, key+"="+value)
+	}
+	go p.supervise(ctx, name, command, environ)
+	return p
+}
+
+func (p *process) supervise(ctx context.Context, name string, command, environ []string) {
+	defer close(p.done)
+	backoff := restartBackoffMin
+	for {
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Env = environ
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		started := time.Now()
+		errRun := cmd.Run()
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) >= stableRunTime {
+			backoff = restartBackoffMin
+		}
+		log.Warnf("external access provider %s: process exited (%v), restarting in %s", name, errRun, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, restartBackoffMax)
+	}
+}
+
+func (p *process) stop() {
+	p.once.Do(func() {
+		p.cancel()
+		<-p.done
+	})
+}
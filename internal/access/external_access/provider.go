@@ -0,0 +1,340 @@
+// Package externalaccess implements the "external" access provider, which
+// hands each request's credentials to a separate process over HTTP.
+//
+// The proxy POSTs a JSON body to the configured URL:
+//
+//	{"provider": "...", "method": "POST", "path": "/v1/chat/completions",
+//	 "remote_addr": "...", "headers": {"Authorization": "Bearer ..."},
+//	 "query": {"key": "..."}, "config": {...}}
+//
+// and expects a 200 response of the form
+//
+//	{"decision": "allow" | "deny" | "skip", "principal": "...",
+//	 "metadata": {"...": "..."}, "message": "..."}
+//
+// "skip" passes the request on to the next provider. Any other status, or a
+// transport failure, fails the request with an internal error. With a cache
+// TTL, allow and deny decisions are cached per credentials, method, path and
+// client host.
+package externalaccess
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+	maxResponse    = 64 << 10
+
+	decisionAllow = "allow"
+	decisionDeny  = "deny"
+	decisionSkip  = "skip"
+)
+
+// credentialHeaders are always forwarded; they match what config-api-key reads.
+var credentialHeaders = []string{"Authorization", "X-Goog-Api-Key", "X-Api-Key"}
+
+// credentialQuery lists the query parameters forwarded to the process.
+var credentialQuery = []string{"key", "auth_token"}
+
+var (
+	registeredMu sync.Mutex
+	registered   = make(map[string]*provider)
+)
+
+// Register syncs the global access registry with the external providers in
+// cfg. Providers whose settings are unchanged keep their instance, and their
+// process, across reloads.
+func Register(cfg *sdkconfig.SDKConfig) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+
+	wanted := make(map[string]sdkconfig.AccessProvider)
+	if cfg != nil {
+		for _, entry := range cfg.Access.Providers {
+			if !strings.EqualFold(strings.TrimSpace(entry.Type), sdkaccess.AccessProviderTypeExternal) {
+				continue
+			}
+			name := strings.TrimSpace(entry.Name)
+			if name == "" || entry.External == nil || strings.TrimSpace(entry.External.URL) == "" {
+				log.Warnf("external access provider %q skipped: name and external.url are required", name)
+				continue
+			}
+			if _, dup := wanted[name]; dup {
+				log.Warnf("external access provider %q is configured more than once; keeping the first", name)
+				continue
+			}
+			wanted[name] = entry
+		}
+	}
+
+	for name, existing := range registered {
+		if entry, ok := wanted[name]; ok && reflect.DeepEqual(existing.entry, entry) {
+			delete(wanted, name)
+			continue
+		}
+		sdkaccess.UnregisterProvider(registryKey(name))
+		existing.close()
+		delete(registered, name)
+	}
+
+	names := make([]string, 0, len(wanted))
+	for name := range wanted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := newProvider(wanted[name])
+		registered[name] = p
+		sdkaccess.RegisterProvider(registryKey(name), p)
+	}
+}
+
+// Shutdown stops every process launched for an external provider.
+func Shutdown() {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	for name, p := range registered {
+		sdkaccess.UnregisterProvider(registryKey(name))
+		p.close()
+		delete(registered, name)
+	}
+}
+
+func registryKey(name string) string {
+	return sdkaccess.AccessProviderTypeExternal + ":" + name
+}
+
+type provider struct {
+	entry   sdkconfig.AccessProvider
+	name    string
+	url     string
+	headers []string
+	client  *http.Client
+	ttl     time.Duration
+	process *process
+
+	cacheMu sync.Mutex
+	cache   map[string]cachedDecision
+}
+
+type cachedDecision struct {
+	result  *sdkaccess.Result
+	denied  bool
+	expires time.Time
+}
+
+type validationRequest struct {
+	Provider   string            `json:"provider"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	RemoteAddr string            `json:"remote_addr,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Query      map[string]string `json:"query,omitempty"`
+	Config     map[string]any    `json:"config,omitempty"`
+}
+
+type validationResponse struct {
+	Decision  string            `json:"decision"`
+	Principal string            `json:"principal"`
+	Metadata  map[string]string `json:"metadata"`
+	Message   string            `json:"message"`
+}
+
+func newProvider(entry sdkconfig.AccessProvider) *provider {
+	ext := entry.External
+	timeout := defaultTimeout
+	if ext.Timeout > 0 {
+		timeout = time.Duration(ext.Timeout) * time.Second
+	}
+	headers := append([]string(nil), credentialHeaders...)
+	for _, header := range ext.ForwardHeaders {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, http.CanonicalHeaderKey(header))
+		}
+	}
+	p := &provider{
+		entry:   entry,
+		name:    strings.TrimSpace(entry.Name),
+		url:     strings.TrimSpace(ext.URL),
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+		ttl:     time.Duration(ext.CacheTTL) * time.Second,
+		cache:   make(map[string]cachedDecision),
+	}
+	if len(ext.Command) > 0 {
+		p.process = startProcess(p.name, ext.Command, ext.Env)
+	}
+	return p
+}
+
+func (p *provider) Identifier() string {
+	return p.name
+}
+
+func (p *provider) Authenticate(ctx context.Context, r *http.Request) (*sdkaccess.Result, *sdkaccess.AuthError) {
+	if p == nil || r == nil {
+		return nil, sdkaccess.NewNotHandledError()
+	}
+	payload := validationRequest{
+		Provider:   p.name,
+		Method:     r.Method,
+		RemoteAddr: r.RemoteAddr,
+		Config:     p.entry.Config,
+	}
+	if r.URL != nil {
+		payload.Path = r.URL.Path
+		query := r.URL.Query()
+		for _, key := range credentialQuery {
+			if value := query.Get(key); value != "" {
+				if payload.Query == nil {
+					payload.Query = make(map[string]string)
+				}
+				payload.Query[key] = value
+			}
+		}
+	}
+	for _, header := range p.headers {
+		if value := r.Header.Get(header); value != "" {
+			if payload.Headers == nil {
+				payload.Headers = make(map[string]string)
+			}
+			payload.Headers[header] = value
+		}
+	}
+	if len(payload.Headers) == 0 && len(payload.Query) == 0 {
+		return nil, sdkaccess.NewNoCredentialsError()
+	}
+
+	cacheKey := ""
+	if p.ttl > 0 {
+		cacheKey = credentialCacheKey(payload)
+		if result, denied, ok := p.cached(cacheKey); ok {
+			if denied {
+				return nil, sdkaccess.NewInvalidCredentialError()
+			}
+			return result, nil
+		}
+	}
+
+	verdict, errCall := p.call(ctx, payload)
+	if errCall != nil {
+		log.Errorf("external access provider %s: %v", p.name, errCall)
+		return nil, sdkaccess.NewInternalAuthError("external access provider unavailable", errCall)
+	}
+	switch strings.ToLower(strings.TrimSpace(verdict.Decision)) {
+	case decisionAllow:
+		result := &sdkaccess.Result{Provider: p.name, Principal: verdict.Principal, Metadata: verdict.Metadata}
+		p.remember(cacheKey, result, false)
+		return result, nil
+	case decisionDeny:
+		p.remember(cacheKey, nil, true)
+		return nil, sdkaccess.NewInvalidCredentialError()
+	case decisionSkip:
+		return nil, sdkaccess.NewNotHandledError()
+	default:
+		return nil, sdkaccess.NewInternalAuthError("external access provider returned an unknown decision", fmt.Errorf("decision %q", verdict.Decision))
+	}
+}
+
+func (p *provider) call(ctx context.Context, payload validationRequest) (*validationResponse, error) {
+	body, errMarshal := json.Marshal(payload)
+	if errMarshal != nil {
+		return nil, errMarshal
+	}
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if errReq != nil {
+		return nil, errReq
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, errDo := p.client.Do(req)
+	if errDo != nil {
+		return nil, errDo
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, errRead := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if errRead != nil {
+		return nil, errRead
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var verdict validationResponse
+	if errDecode := json.Unmarshal(data, &verdict); errDecode != nil {
+		return nil, fmt.Errorf("decode response: %w", errDecode)
+	}
+	return &verdict, nil
+}
+
+func (p *provider) cached(key string) (*sdkaccess.Result, bool, bool) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	entry, ok := p.cache[key]
+	if !ok {
+		return nil, false, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(p.cache, key)
+		return nil, false, false
+	}
+	return entry.result, entry.denied, true
+}
+
+func (p *provider) remember(key string, result *sdkaccess.Result, denied bool) {
+	if key == "" {
+		return
+	}
+	now := time.Now()
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	for k, entry := range p.cache {
+		if now.After(entry.expires) {
+			delete(p.cache, k)
+		}
+	}
+	p.cache[key] = cachedDecision{result: result, denied: denied, expires: now.Add(p.ttl)}
+}
+
+func (p *provider) close() {
+	if p != nil && p.process != nil {
+		p.process.stop()
+	}
+}
+
+// credentialCacheKey hashes everything a decision may depend on, so raw keys
+// are never held as map keys. The remote address contributes its host only,
+// so a client reconnecting from a new port still hits the cache.
+func credentialCacheKey(payload validationRequest) string {
+	host := payload.RemoteAddr
+	if h, _, errSplit := net.SplitHostPort(host); errSplit == nil {
+		host = h
+	}
+	parts := make([]string, 0, len(payload.Headers)+len(payload.Query)+3)
+	parts = append(parts, "m:"+payload.Method, "p:"+payload.Path, "r:"+host)
+	for k, v := range payload.Headers {
+		parts = append(parts, "h:"+k+"="+v)
+	}
+	for k, v := range payload.Query {
+		parts = append(parts, "q:"+k+"="+v)
+	}
+	sort.Strings(parts)
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:])
+}
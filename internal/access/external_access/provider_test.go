@@ -0,0 +1,131 @@
+package externalaccess
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func externalConfig(url string, cacheTTL int) *sdkconfig.SDKConfig {
+	return &sdkconfig.SDKConfig{Access: sdkconfig.AccessConfig{Providers: []sdkconfig.AccessProvider{{
+		Name:     "corp",
+		Type:     sdkaccess.AccessProviderTypeExternal,
+		Config:   map[string]any{"tenant": "acme"},
+		External: &sdkconfig.ExternalAccessProvider{URL: url, CacheTTL: cacheTTL, ForwardHeaders: []string{"x-team"}},
+	}}}}
+}
+
+func registeredProvider(t *testing.T, name string) sdkaccess.Provider {
+	t.Helper()
+	for _, p := range sdkaccess.RegisteredProviders() {
+		if p.Identifier() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func TestProvider_FollowsProcessDecisions(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var req validationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := validationResponse{Decision: decisionDeny}
+		switch req.Headers["Authorization"] {
+		case "Bearer good":
+			if req.Config["tenant"] != "acme" || req.Headers["X-Team"] != "red" || req.Path != "/v1/models" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			resp = validationResponse{Decision: decisionAllow, Principal: "alice", Metadata: map[string]string{"team": "red"}}
+		case "Bearer other":
+			resp.Decision = decisionSkip
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	Register(externalConfig(server.URL, 60))
+	t.Cleanup(Shutdown)
+	p := registeredProvider(t, "corp")
+	if p == nil {
+		t.Fatal("external provider not registered")
+	}
+
+	authenticate := func(token string) (*sdkaccess.Result, *sdkaccess.AuthError) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("X-Team", "red")
+		return p.Authenticate(context.Background(), req)
+	}
+
+	result, authErr := authenticate("good")
+	if authErr != nil || result.Principal != "alice" || result.Metadata["team"] != "red" {
+		t.Fatalf("allow: result=%+v err=%v", result, authErr)
+	}
+	if _, authErr = authenticate("good"); authErr != nil || calls != 1 {
+		t.Fatalf("cached allow: err=%v calls=%d", authErr, calls)
+	}
+	other := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	other.Header.Set("Authorization", "Bearer good")
+	other.Header.Set("X-Team", "red")
+	if _, authErr = p.Authenticate(context.Background(), other); authErr == nil || calls != 2 {
+		t.Fatalf("allow for another path was reused: err=%v calls=%d", authErr, calls)
+	}
+	if _, authErr = authenticate("bad"); !sdkaccess.IsAuthErrorCode(authErr, sdkaccess.AuthErrorCodeInvalidCredential) {
+		t.Fatalf("deny: err=%v", authErr)
+	}
+	if _, authErr = authenticate("other"); !sdkaccess.IsAuthErrorCode(authErr, sdkaccess.AuthErrorCodeNotHandled) {
+		t.Fatalf("skip: err=%v", authErr)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	if _, authErr = p.Authenticate(context.Background(), req); !sdkaccess.IsAuthErrorCode(authErr, sdkaccess.AuthErrorCodeNoCredentials) {
+		t.Fatalf("no credentials: err=%v", authErr)
+	}
+}
+
+func TestProvider_FailsClosedWhenProcessErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	Register(externalConfig(server.URL, 0))
+	t.Cleanup(Shutdown)
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("X-Api-Key", "k")
+	if _, authErr := registeredProvider(t, "corp").Authenticate(context.Background(), req); !sdkaccess.IsAuthErrorCode(authErr, sdkaccess.AuthErrorCodeInternal) {
+		t.Fatalf("expected an internal error, got %v", authErr)
+	}
+}
+
+func TestRegister_KeepsUnchangedProvidersAcrossReloads(t *testing.T) {
+	Register(externalConfig("http://127.0.0.1:1/validate", 0))
+	t.Cleanup(Shutdown)
+	first := registeredProvider(t, "corp")
+
+	Register(externalConfig("http://127.0.0.1:1/validate", 0))
+	if registeredProvider(t, "corp") != first {
+		t.Fatal("unchanged provider should keep its instance")
+	}
+	Register(externalConfig("http://127.0.0.1:2/validate", 0))
+	if second := registeredProvider(t, "corp"); second == nil || second == first {
+		t.Fatal("changed provider should be rebuilt")
+	}
+	Register(&sdkconfig.SDKConfig{})
+	if registeredProvider(t, "corp") != nil {
+		t.Fatal("removed provider should be unregistered")
+	}
+}
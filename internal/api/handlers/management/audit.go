@@ -0,0 +1,490 @@
+package management
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	auditLogFileName = "management-audit.jsonl"
+
+	auditCredentialContextKey  = "management_audit_credential"
+	auditFingerprintContextKey = "management_audit_fingerprint"
+
+	// auditActorHeader lets admins sharing one management key identify themselves.
+	auditActorHeader = "X-Management-Actor"
+)
+
+// auditRedactedQueryParams lists query parameters whose values carry secrets.
+var auditRedactedQueryParams = map[string]struct{}{
+	"api-key":       {},
+	"value":         {},
+	"client_secret": {},
+	"key":           {},
+}
+
+// auditEntry is one line of the management audit log.
+type auditEntry struct {
+	Timestamp       int64             `json:"timestamp"`
+	Time            string            `json:"time"`
+	Method          string            `json:"method"`
+	Path            string            `json:"path"`
+	Query           string            `json:"query,omitempty"`
+	Status          int               `json:"status"`
+	ClientIP        string            `json:"client_ip"`
+	UserAgent       string            `json:"user_agent,omitempty"`
+	Actor           string            `json:"actor,omitempty"`
+	Credential      string            `json:"credential,omitempty"`
+	KeyFingerprint  string            `json:"key_fingerprint,omitempty"`
+	ConfigChanges   []string          `json:"config_changes,omitempty"`
+	AuthFileChanges []auditFileChange `json:"auth_file_changes,omitempty"`
+}
+
+// auditFileChange describes how one auth file changed. Only field names are
+// recorded because auth files hold tokens.
+type auditFileChange struct {
+	Name          string   `json:"name"`
+	Action        string   `json:"action"`
+	ChangedFields []string `json:"changed_fields,omitempty"`
+}
+
+// auditFileState fingerprints an auth file and its top-level JSON fields.
+type auditFileState struct {
+	sum    string
+	fields map[string]string
+}
+
+// setAuditCredential records which management credential authorized the
+// request, plus a short fingerprint of the presented key.
+func setAuditCredential(c *gin.Context, kind, provided string) {
+	sum := sha256.Sum256([]byte(provided))
+	c.Set(auditCredentialContextKey, kind)
+	c.Set(auditFingerprintContextKey, hex.EncodeToString(sum[:])[:12])
+}
+
+// AuditMiddleware appends every mutating management call to the audit log,
+// including a redacted diff of config.yaml and the auth directory.
+func (h *Handler) AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		configBefore := h.readConfigSnapshot()
+		authBefore := h.snapshotAuthDir()
+
+		c.Next()
+
+		entry := auditEntry{
+			Method:          c.Request.Method,
+			Path:            c.Request.URL.Path,
+			Query:           redactAuditQuery(c.Request.URL.Query()),
+			Status:          c.Writer.Status(),
+			ClientIP:        c.ClientIP(),
+			UserAgent:       c.Request.UserAgent(),
+			Actor:           strings.TrimSpace(c.GetHeader(auditActorHeader)),
+			Credential:      c.GetString(auditCredentialContextKey),
+			KeyFingerprint:  c.GetString(auditFingerprintContextKey),
+			ConfigChanges:   auditConfigChanges(configBefore, h.readConfigSnapshot()),
+			AuthFileChanges: auditAuthFileChanges(authBefore, h.snapshotAuthDir()),
+		}
+		h.appendAuditEntry(entry)
+	}
+}
+
+// GetAuditLog returns audit entries, newest first. Supports limit, after
+// (unix seconds), method and path (prefix) filters.
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	limit, errLimit := parseLimit(c.Query("limit"))
+	if errLimit != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit: %v", errLimit)})
+		return
+	}
+	if limit == 0 {
+		limit = 100
+	}
+	cutoff := parseCutoff(c.Query("after"))
+	method := strings.ToUpper(strings.TrimSpace(c.Query("method")))
+	pathPrefix := strings.TrimSpace(c.Query("path"))
+
+	path := h.auditLogPath()
+	if path == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "audit log directory not configured"})
+		return
+	}
+	h.auditMu.Lock()
+	entries, errRead := readAuditLog(path)
+	h.auditMu.Unlock()
+	if errRead != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read audit log: %v", errRead)})
+		return
+	}
+
+	out := make([]auditEntry, 0, limit)
+	for i := len(entries) - 1; i >= 0 && len(out) < limit; i-- {
+		entry := entries[i]
+		if cutoff > 0 && entry.Timestamp <= cutoff {
+			continue
+		}
+		if method != "" && entry.Method != method {
+			continue
+		}
+		if pathPrefix != "" && !strings.HasPrefix(entry.Path, pathPrefix) {
+			continue
+		}
+		out = append(out, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": out, "count": len(out)})
+}
+
+// auditLogPath places the audit log next to the other logs, falling back to
+// the config directory. The .jsonl suffix keeps it out of log rotation;
+// log-retention.audit-log rotates it on its own, and GetAuditLog serves the
+// rotated segments that retention has kept.
+func (h *Handler) auditLogPath() string {
+	dir := h.logDirectory()
+	if dir == "" && h.configFilePath != "" {
+		dir = filepath.Dir(h.configFilePath)
+	}
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, auditLogFileName)
+}
+
+func (h *Handler) appendAuditEntry(entry auditEntry) {
+	path := h.auditLogPath()
+	if path == "" {
+		return
+	}
+	now := time.Now()
+	entry.Timestamp = now.Unix()
+	entry.Time = now.UTC().Format(time.RFC3339)
+	line, errMarshal := json.Marshal(entry)
+	if errMarshal != nil {
+		log.WithError(errMarshal).Warn("management audit: failed to encode entry")
+		return
+	}
+
+	h.auditMu.Lock()
+	defer h.auditMu.Unlock()
+	if errMkdir := os.MkdirAll(filepath.Dir(path), 0o755); errMkdir != nil {
+		log.WithError(errMkdir).Warn("management audit: failed to create log directory")
+		return
+	}
+	var policy config.LogRetentionPolicy
+	if h.cfg != nil {
+		policy = h.cfg.LogRetention.AuditLog
+	}
+	if writer := h.audit.writer(path, policy, now); writer != nil {
+		if _, errWrite := writer.Write(append(line, '\n')); errWrite != nil {
+			log.WithError(errWrite).Warn("management audit: failed to write entry")
+		}
+		return
+	}
+	file, errOpen := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if errOpen != nil {
+		log.WithError(errOpen).Warn("management audit: failed to open log")
+		return
+	}
+	defer func() { _ = file.Close() }()
+	if _, errWrite := file.Write(append(line, '\n')); errWrite != nil {
+		log.WithError(errWrite).Warn("management audit: failed to write entry")
+	}
+}
+
+// auditRotation holds the rotating writer used while log-retention.audit-log
+// enables rotation. Callers hold Handler.auditMu.
+type auditRotation struct {
+	logger   *lumberjack.Logger
+	path     string
+	policy   config.LogRetentionPolicy
+	openedAt time.Time
+}
+
+// writer returns the rotating writer for path under policy, or nil when the
+// policy does not rotate the audit log.
+func (r *auditRotation) writer(path string, policy config.LogRetentionPolicy, now time.Time) io.Writer {
+	if policy.MaxSizeMB <= 0 && policy.RotateHours <= 0 {
+		r.close()
+		return nil
+	}
+	if r.logger == nil || r.path != path || r.policy != policy {
+		r.close()
+		maxSize := policy.MaxSizeMB
+		if maxSize <= 0 {
+			// Rotate by time only.
+			maxSize = math.MaxInt32
+		}
+		r.logger = &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    maxSize,
+			MaxAge:     policy.MaxAgeDays,
+			MaxBackups: policy.MaxFiles,
+			Compress:   policy.Compress,
+		}
+		r.path, r.policy, r.openedAt = path, policy, now
+		if started, ok := auditLogStartedAt(path); ok {
+			r.openedAt = started
+		}
+	}
+	if policy.RotateHours > 0 && now.Sub(r.openedAt) >= time.Duration(policy.RotateHours)*time.Hour {
+		if errRotate := r.logger.Rotate(); errRotate != nil {
+			log.WithError(errRotate).Warn("management audit: failed to rotate log")
+		}
+		r.openedAt = now
+	}
+	return r.logger
+}
+
+// auditLogStartedAt returns the time of the first entry in the audit log at
+// path, which dates the active file for time-based rotation.
+func auditLogStartedAt(path string) (time.Time, bool) {
+	file, errOpen := os.Open(path)
+	if errOpen != nil {
+		return time.Time{}, false
+	}
+	defer func() { _ = file.Close() }()
+	line, _ := bufio.NewReader(file).ReadBytes('\n')
+	var first auditEntry
+	if json.Unmarshal(line, &first) != nil || first.Timestamp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(first.Timestamp, 0), true
+}
+
+func (r *auditRotation) close() {
+	if r.logger == nil {
+		return
+	}
+	_ = r.logger.Close()
+	r.logger = nil
+}
+
+// readAuditLog returns the entries of the audit log at path and its rotated
+// segments, oldest first. Missing files are skipped.
+func readAuditLog(path string) ([]auditEntry, error) {
+	var entries []auditEntry
+	for _, segment := range append(auditLogSegments(path), path) {
+		segmentEntries, errRead := readAuditEntries(segment)
+		if errRead != nil {
+			if os.IsNotExist(errRead) {
+				continue
+			}
+			return nil, errRead
+		}
+		entries = append(entries, segmentEntries...)
+	}
+	return entries, nil
+}
+
+// auditLogSegments lists the rotated segments of the audit log at path,
+// oldest first. Rotation names them <name>-<UTC timestamp>.jsonl, gzipped
+// when compression is enabled; while a segment is being compressed both
+// forms exist and the plain one is used.
+func auditLogSegments(path string) []string {
+	dir := filepath.Dir(path)
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(filepath.Base(path), ext) + "-"
+	dirEntries, errRead := os.ReadDir(dir)
+	if errRead != nil {
+		return nil
+	}
+	plain := make(map[string]bool)
+	var names []string
+	for _, entry := range dirEntries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasSuffix(name, ext) {
+			plain[name] = true
+			names = append(names, name)
+		} else if strings.HasSuffix(name, ext+".gz") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	segments := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.HasSuffix(name, ".gz") && plain[strings.TrimSuffix(name, ".gz")] {
+			continue
+		}
+		segments = append(segments, filepath.Join(dir, name))
+	}
+	return segments
+}
+
+func readAuditEntries(path string) ([]auditEntry, error) {
+	file, errOpen := os.Open(path)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+	defer func() { _ = file.Close() }()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, errGzip := gzip.NewReader(file)
+		if errGzip != nil {
+			return nil, errGzip
+		}
+		defer func() { _ = gz.Close() }()
+		reader = gz
+	}
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry auditEntry
+		if json.Unmarshal(line, &entry) != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func redactAuditQuery(values url.Values) string {
+	if len(values) == 0 {
+		return ""
+	}
+	redacted := make(url.Values, len(values))
+	for key, vals := range values {
+		if _, secret := auditRedactedQueryParams[strings.ToLower(key)]; secret {
+			redacted[key] = []string{"[redacted]"}
+			continue
+		}
+		redacted[key] = vals
+	}
+	return redacted.Encode()
+}
+
+func (h *Handler) readConfigSnapshot() []byte {
+	if h.configFilePath == "" {
+		return nil
+	}
+	data, errRead := os.ReadFile(h.configFilePath)
+	if errRead != nil {
+		return nil
+	}
+	return data
+}
+
+// auditConfigChanges reuses the watcher's redacted config diff so secrets are
+// never written to the audit log.
+func auditConfigChanges(before, after []byte) []string {
+	if bytes.Equal(before, after) {
+		return nil
+	}
+	var oldCfg, newCfg config.Config
+	if errOld := yaml.Unmarshal(before, &oldCfg); errOld != nil {
+		return []string{"config.yaml: updated (previous version unparsable)"}
+	}
+	if errNew := yaml.Unmarshal(after, &newCfg); errNew != nil {
+		return []string{"config.yaml: updated (new version unparsable)"}
+	}
+	changes := diff.BuildConfigChangeDetails(&oldCfg, &newCfg)
+	if len(changes) == 0 {
+		return []string{"config.yaml: updated (formatting or untracked fields)"}
+	}
+	return changes
+}
+
+func (h *Handler) snapshotAuthDir() map[string]auditFileState {
+	if h.cfg == nil || strings.TrimSpace(h.cfg.AuthDir) == "" {
+		return nil
+	}
+	entries, errRead := os.ReadDir(h.cfg.AuthDir)
+	if errRead != nil {
+		return nil
+	}
+	states := make(map[string]auditFileState, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(name), ".json") {
+			continue
+		}
+		data, errFile := os.ReadFile(filepath.Join(h.cfg.AuthDir, name))
+		if errFile != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		state := auditFileState{sum: hex.EncodeToString(sum[:])}
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(data, &fields) == nil {
+			state.fields = make(map[string]string, len(fields))
+			for key, raw := range fields {
+				fieldSum := sha256.Sum256(raw)
+				state.fields[key] = hex.EncodeToString(fieldSum[:])
+			}
+		}
+		states[name] = state
+	}
+	return states
+}
+
+func auditAuthFileChanges(before, after map[string]auditFileState) []auditFileChange {
+	var changes []auditFileChange
+	for name, prev := range before {
+		next, ok := after[name]
+		if !ok {
+			changes = append(changes, auditFileChange{Name: name, Action: "removed"})
+			continue
+		}
+		if prev.sum == next.sum {
+			continue
+		}
+		changes = append(changes, auditFileChange{Name: name, Action: "modified", ChangedFields: auditChangedFields(prev.fields, next.fields)})
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			changes = append(changes, auditFileChange{Name: name, Action: "added"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+func auditChangedFields(before, after map[string]string) []string {
+	var fields []string
+	for key, sum := range before {
+		if after[key] != sum {
+			fields = append(fields, key)
+		}
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,217 @@
+package management
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"time"
+)
+
+func TestAuditMiddleware_RecordsRedactedConfigAndAuthFileDiffs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	authDir := filepath.Join(dir, "auths")
+	if err := os.MkdirAll(authDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("debug: false\napi-keys:\n  - old-secret\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(authDir, "codex.json"), []byte(`{"type":"codex","access_token":"a"}`), 0o600); err != nil {
+		t.Fatalf("write auth: %v", err)
+	}
+
+	h := &Handler{cfg: &config.Config{AuthDir: authDir}, configFilePath: configPath, logDir: filepath.Join(dir, "logs")}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		setAuditCredential(c, "secret-key", "management-key")
+		c.Next()
+	}, h.AuditMiddleware())
+	router.PUT("/v0/management/debug", func(c *gin.Context) {
+		_ = os.WriteFile(configPath, []byte("debug: true\napi-keys:\n  - new-secret\n"), 0o600)
+		_ = os.WriteFile(filepath.Join(authDir, "codex.json"), []byte(`{"type":"codex","access_token":"b"}`), 0o600)
+		_ = os.WriteFile(filepath.Join(authDir, "claude.json"), []byte(`{"type":"claude"}`), 0o600)
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	router.GET("/v0/management/debug", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/v0/management/audit-log", h.GetAuditLog)
+
+	req := httptest.NewRequest(http.MethodPut, "/v0/management/debug?api-key=sk-live&index=2", strings.NewReader(`{"value":true}`))
+	req.Header.Set(auditActorHeader, "alice")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v0/management/debug", nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/management/audit-log", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("audit-log status = %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "old-secret") || strings.Contains(w.Body.String(), "new-secret") {
+		t.Fatalf("audit log leaked a secret: %s", w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "sk-live") {
+		t.Fatalf("audit log leaked a query secret: %s", w.Body.String())
+	}
+
+	var resp struct {
+		Entries []auditEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(resp.Entries) != 1 {
+		t.Fatalf("expected only the mutating call to be audited, got %d entries", len(resp.Entries))
+	}
+	entry := resp.Entries[0]
+	if entry.Method != http.MethodPut || entry.Path != "/v0/management/debug" || entry.Status != http.StatusOK {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if entry.Actor != "alice" || entry.Credential != "secret-key" || len(entry.KeyFingerprint) != 12 {
+		t.Fatalf("unexpected identity: %+v", entry)
+	}
+	if !strings.Contains(entry.Query, "api-key=%5Bredacted%5D") || !strings.Contains(entry.Query, "index=2") {
+		t.Fatalf("unexpected query: %q", entry.Query)
+	}
+	if !containsString(entry.ConfigChanges, "debug: false -> true") {
+		t.Fatalf("expected debug change, got %v", entry.ConfigChanges)
+	}
+
+	if len(entry.AuthFileChanges) != 2 {
+		t.Fatalf("expected 2 auth file changes, got %+v", entry.AuthFileChanges)
+	}
+	added, modified := entry.AuthFileChanges[0], entry.AuthFileChanges[1]
+	if added.Name != "claude.json" || added.Action != "added" {
+		t.Fatalf("unexpected added change: %+v", added)
+	}
+	if modified.Name != "codex.json" || modified.Action != "modified" || len(modified.ChangedFields) != 1 || modified.ChangedFields[0] != "access_token" {
+		t.Fatalf("unexpected modified change: %+v", modified)
+	}
+}
+
+func TestGetAuditLog_FiltersAndLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	h := &Handler{logDir: t.TempDir()}
+	h.appendAuditEntry(auditEntry{Method: http.MethodPut, Path: "/v0/management/debug"})
+	h.appendAuditEntry(auditEntry{Method: http.MethodDelete, Path: "/v0/management/auth-files"})
+	h.appendAuditEntry(auditEntry{Method: http.MethodPost, Path: "/v0/management/auth-files"})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v0/management/audit-log?path=/v0/management/auth-files&limit=1", nil)
+	h.GetAuditLog(c)
+
+	var resp struct {
+		Entries []auditEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0].Method != http.MethodPost {
+		t.Fatalf("expected newest auth-files entry, got %+v", resp.Entries)
+	}
+}
+
+func TestGetAuditLog_ServesRotatedSegments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	writeSegment := func(name string, compress bool, paths ...string) {
+		t.Helper()
+		var buf bytes.Buffer
+		for i, path := range paths {
+			line, _ := json.Marshal(auditEntry{Timestamp: int64(i + 1), Method: http.MethodPut, Path: path})
+			buf.Write(append(line, '\n'))
+		}
+		data := buf.Bytes()
+		if compress {
+			var gz bytes.Buffer
+			zw := gzip.NewWriter(&gz)
+			_, _ = zw.Write(data)
+			_ = zw.Close()
+			data = gz.Bytes()
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	writeSegment("management-audit-2026-01-01T00-00-00.000.jsonl.gz", true, "/first")
+	writeSegment("management-audit-2026-01-02T00-00-00.000.jsonl", false, "/second")
+	// A half-written archive of the segment above must not be read twice.
+	if err := os.WriteFile(filepath.Join(dir, "management-audit-2026-01-02T00-00-00.000.jsonl.gz"), []byte{0x1f}, 0o600); err != nil {
+		t.Fatalf("write partial archive: %v", err)
+	}
+	writeSegment(auditLogFileName, false, "/third")
+
+	h := &Handler{logDir: dir}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/v0/management/audit-log", nil)
+	h.GetAuditLog(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Entries []auditEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var paths []string
+	for _, entry := range resp.Entries {
+		paths = append(paths, entry.Path)
+	}
+	if strings.Join(paths, ",") != "/third,/second,/first" {
+		t.Fatalf("entries = %v, want newest first across segments", paths)
+	}
+}
+
+func containsString(values []string, want string) bool {
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAppendAuditEntry_RotatesByTime(t *testing.T) {
+	dir := t.TempDir()
+	policy := config.LogRetentionPolicy{RotateHours: 24, MaxFiles: 2}
+	h := &Handler{cfg: &config.Config{}, logDir: dir}
+	h.cfg.LogRetention.AuditLog = policy
+	path := filepath.Join(dir, auditLogFileName)
+
+	stale := auditEntry{Timestamp: time.Now().Add(-48 * time.Hour).Unix(), Method: http.MethodPut, Path: "/v0/management/debug"}
+	line, _ := json.Marshal(stale)
+	if err := os.WriteFile(path, append(line, '\n'), 0o600); err != nil {
+		t.Fatalf("write audit log: %v", err)
+	}
+
+	h.appendAuditEntry(auditEntry{Method: http.MethodPut, Path: "/v0/management/request-log"})
+	h.audit.close()
+
+	entries, err := readAuditEntries(path)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != "/v0/management/request-log" {
+		t.Fatalf("active audit log entries = %+v, want only the new entry", entries)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "management-audit-*.jsonl"))
+	if len(matches) != 1 {
+		t.Fatalf("rotated audit logs = %v, want 1", matches)
+	}
+}
@@ -0,0 +1,244 @@
+package management
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/scrypt"
+)
+
+// An auth bundle is a JSON envelope holding every auth file of an instance,
+// encrypted with AES-256-GCM under a key derived from a passphrase with scrypt.
+const (
+	authBundleFormat  = "cliproxy-auth-bundle"
+	authBundleVersion = 1
+
+	authBundleMinPassphrase = 8
+	authBundleMaxBytes      = 64 << 20
+
+	authBundleScryptN = 1 << 15
+	authBundleScryptR = 8
+	authBundleScryptP = 1
+	// authBundleScryptMaxMemory caps the 128·N·r bytes scrypt allocates to
+	// open a bundle, which is what export uses.
+	authBundleScryptMaxMemory = 128 * authBundleScryptN * authBundleScryptR
+)
+
+type authBundleEnvelope struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type authBundleFile struct {
+	Name    string `json:"name"`
+	Content []byte `json:"content"`
+}
+
+type authBundlePayload struct {
+	CreatedAt time.Time        `json:"created_at"`
+	Files     []authBundleFile `json:"files"`
+}
+
+func authBundleAEAD(passphrase string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealAuthBundle(payload authBundlePayload, passphrase string) ([]byte, error) {
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	env := authBundleEnvelope{
+		Format:  authBundleFormat,
+		Version: authBundleVersion,
+		KDF:     "scrypt",
+		N:       authBundleScryptN,
+		R:       authBundleScryptR,
+		P:       authBundleScryptP,
+		Salt:    make([]byte, 16),
+	}
+	if _, err = rand.Read(env.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := authBundleAEAD(passphrase, env.Salt, env.N, env.R, env.P)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(env.Nonce); err != nil {
+		return nil, err
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plaintext, []byte(authBundleFormat))
+	return json.MarshalIndent(env, "", "  ")
+}
+
+func openAuthBundle(data []byte, passphrase string) (authBundlePayload, error) {
+	var env authBundleEnvelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Format != authBundleFormat {
+		return authBundlePayload{}, fmt.Errorf("not an auth bundle")
+	}
+	if env.Version != authBundleVersion || env.KDF != "scrypt" {
+		return authBundlePayload{}, fmt.Errorf("unsupported auth bundle version %d (%s)", env.Version, env.KDF)
+	}
+	// Bound the work factor so a crafted bundle cannot exhaust memory or CPU.
+	if env.N <= 1 || env.R <= 0 || env.P != authBundleScryptP ||
+		env.R > authBundleScryptMaxMemory/128 || env.N > authBundleScryptMaxMemory/(128*env.R) {
+		return authBundlePayload{}, fmt.Errorf("invalid auth bundle key parameters")
+	}
+	aead, err := authBundleAEAD(passphrase, env.Salt, env.N, env.R, env.P)
+	if err != nil {
+		return authBundlePayload{}, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return authBundlePayload{}, fmt.Errorf("invalid auth bundle nonce")
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, []byte(authBundleFormat))
+	if err != nil {
+		return authBundlePayload{}, fmt.Errorf("wrong passphrase or corrupted bundle")
+	}
+	var payload authBundlePayload
+	if err = json.Unmarshal(plaintext, &payload); err != nil {
+		return authBundlePayload{}, fmt.Errorf("invalid auth bundle contents: %w", err)
+	}
+	return payload, nil
+}
+
+// ExportAuthFiles returns every auth file in the auth directory as one
+// passphrase-encrypted bundle. The body is {"passphrase": "..."}.
+func (h *Handler) ExportAuthFiles(c *gin.Context) {
+	var req struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if len(req.Passphrase) < authBundleMinPassphrase {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("passphrase must be at least %d characters", authBundleMinPassphrase)})
+		return
+	}
+	entries, err := os.ReadDir(h.cfg.AuthDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read auth dir: %v", err)})
+		return
+	}
+	now := time.Now().UTC()
+	payload := authBundlePayload{CreatedAt: now, Files: make([]authBundleFile, 0, len(entries))}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(strings.ToLower(name), ".json") {
+			continue
+		}
+		data, errRead := os.ReadFile(filepath.Join(h.cfg.AuthDir, name))
+		if errRead != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read %s: %v", name, errRead)})
+			return
+		}
+		payload.Files = append(payload.Files, authBundleFile{Name: name, Content: data})
+	}
+	bundle, err := sealAuthBundle(payload, req.Passphrase)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to encrypt bundle: %v", err)})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"auth-files-%s.bundle\"", now.Format("20060102-150405")))
+	c.Header("X-Auth-Files-Count", fmt.Sprint(len(payload.Files)))
+	c.Data(http.StatusOK, "application/json", bundle)
+}
+
+// ImportAuthFiles writes the auth files of a bundle made by ExportAuthFiles
+// into the auth directory and registers them. It takes a multipart form with
+// "file" and "passphrase". Existing files are kept unless ?overwrite=true.
+func (h *Handler) ImportAuthFiles(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bundle file is required"})
+		return
+	}
+	passphrase := c.PostForm("passphrase")
+	if passphrase == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "passphrase is required"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to open bundle: %v", err)})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(src, authBundleMaxBytes+1))
+	_ = src.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read bundle: %v", err)})
+		return
+	}
+	if len(data) > authBundleMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "bundle too large"})
+		return
+	}
+	payload, err := openAuthBundle(data, passphrase)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	overwrite := c.Query("overwrite") == "true" || c.Query("overwrite") == "1"
+	ctx := c.Request.Context()
+	imported := make([]string, 0, len(payload.Files))
+	skipped := make([]string, 0)
+	for _, f := range payload.Files {
+		name := filepath.Base(f.Name)
+		if name != f.Name || !strings.HasSuffix(strings.ToLower(name), ".json") || !json.Valid(f.Content) {
+			skipped = append(skipped, f.Name)
+			continue
+		}
+		dst := filepath.Join(h.cfg.AuthDir, name)
+		if !filepath.IsAbs(dst) {
+			if abs, errAbs := filepath.Abs(dst); errAbs == nil {
+				dst = abs
+			}
+		}
+		if _, errStat := os.Stat(dst); errStat == nil && !overwrite {
+			skipped = append(skipped, name)
+			continue
+		}
+		if errWrite := os.WriteFile(dst, f.Content, 0o600); errWrite != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to write %s: %v", name, errWrite), "imported": imported})
+			return
+		}
+		if errReg := h.registerAuthFromFile(ctx, dst, f.Content); errReg != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": errReg.Error(), "imported": imported})
+			return
+		}
+		imported = append(imported, name)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "imported": imported, "skipped": skipped})
+}
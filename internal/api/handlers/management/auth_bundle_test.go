@@ -0,0 +1,137 @@
+package management
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func exportAuthBundle(t *testing.T, h *Handler, passphrase string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v0/management/auth-files/export", strings.NewReader(`{"passphrase":"`+passphrase+`"}`))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	h.ExportAuthFiles(ctx)
+	return rec
+}
+
+func importAuthBundle(t *testing.T, h *Handler, bundle []byte, passphrase, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "auth.bundle")
+	_, _ = part.Write(bundle)
+	_ = mw.WriteField("passphrase", passphrase)
+	_ = mw.Close()
+
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v0/management/auth-files/import"+query, &body)
+	ctx.Request.Header.Set("Content-Type", mw.FormDataContentType())
+	h.ImportAuthFiles(ctx)
+	return rec
+}
+
+func TestAuthBundleExportImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sourceDir := t.TempDir()
+	files := map[string]string{
+		"claude-a@example.com.json": `{"type":"claude","email":"a@example.com"}`,
+		"codex-b@example.com.json":  `{"type":"codex","email":"b@example.com"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "notes.txt"), []byte("ignored"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	source := NewHandlerWithoutConfigFilePath(&config.Config{AuthDir: sourceDir}, nil)
+
+	if rec := exportAuthBundle(t, source, "short"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected short passphrase to be rejected, got %d", rec.Code)
+	}
+	exportRec := exportAuthBundle(t, source, "correct horse battery")
+	if exportRec.Code != http.StatusOK {
+		t.Fatalf("export status %d: %s", exportRec.Code, exportRec.Body.String())
+	}
+	bundle := exportRec.Body.Bytes()
+	if bytes.Contains(bundle, []byte("a@example.com")) {
+		t.Fatal("bundle contains plaintext auth data")
+	}
+
+	targetDir := t.TempDir()
+	existing := filepath.Join(targetDir, "codex-b@example.com.json")
+	if err := os.WriteFile(existing, []byte(`{"type":"codex","email":"local@example.com"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	manager := coreauth.NewManager(nil, nil, nil)
+	target := NewHandlerWithoutConfigFilePath(&config.Config{AuthDir: targetDir}, manager)
+
+	if rec := importAuthBundle(t, target, bundle, "wrong passphrase", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected wrong passphrase to be rejected, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := importAuthBundle(t, target, bundle, "correct horse battery", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import status %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"skipped":["codex-b@example.com.json"]`) {
+		t.Fatalf("expected the existing file to be skipped: %s", rec.Body.String())
+	}
+	imported, _ := os.ReadFile(filepath.Join(targetDir, "claude-a@example.com.json"))
+	if string(imported) != files["claude-a@example.com.json"] {
+		t.Fatalf("imported file = %q", imported)
+	}
+	if kept, _ := os.ReadFile(existing); !strings.Contains(string(kept), "local@example.com") {
+		t.Fatalf("existing file overwritten without ?overwrite: %q", kept)
+	}
+	if _, ok := manager.GetByID(target.authIDForPath(filepath.Join(targetDir, "claude-a@example.com.json"))); !ok {
+		t.Fatal("imported auth not registered")
+	}
+
+	if rec = importAuthBundle(t, target, bundle, "correct horse battery", "?overwrite=true"); rec.Code != http.StatusOK {
+		t.Fatalf("overwrite import status %d: %s", rec.Code, rec.Body.String())
+	}
+	if replaced, _ := os.ReadFile(existing); string(replaced) != files["codex-b@example.com.json"] {
+		t.Fatalf("existing file not overwritten: %q", replaced)
+	}
+}
+
+func TestOpenAuthBundleRejectsTampering(t *testing.T) {
+	bundle, err := sealAuthBundle(authBundlePayload{Files: []authBundleFile{{Name: "x.json", Content: []byte(`{}`)}}}, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = openAuthBundle(bundle, "passphrase"); err != nil {
+		t.Fatalf("openAuthBundle: %v", err)
+	}
+	tampered := bytes.Replace(bundle, []byte(`"n": 32768`), []byte(`"n": 1073741824`), 1)
+	if _, err = openAuthBundle(tampered, "passphrase"); err == nil {
+		t.Fatal("expected an oversized work factor to be rejected")
+	}
+	for _, params := range []struct{ from, to string }{
+		{`"r": 8`, `"r": 32`},
+		{`"p": 1`, `"p": 16`},
+		{`"n": 32768`, `"n": 1048576`},
+	} {
+		oversized := bytes.Replace(bundle, []byte(params.from), []byte(params.to), 1)
+		if _, err = openAuthBundle(oversized, "passphrase"); err == nil || !strings.Contains(err.Error(), "key parameters") {
+			t.Fatalf("%s: expected oversized scrypt parameters to be rejected, got %v", params.to, err)
+		}
+	}
+	if _, err = openAuthBundle([]byte(`{"files":[]}`), "passphrase"); err == nil {
+		t.Fatal("expected a non-bundle to be rejected")
+	}
+}
@@ -74,6 +74,7 @@ var (
 	callbackForwardersMu    sync.Mutex
 	callbackForwarders      = make(map[int]*callbackForwarder)
 	performGeminiCLISetupFn = performGeminiCLISetup
+	fetchGCPProjectsFn      = fetchGCPProjects
 )
 
 func extractLastRefreshTimestamp(meta map[string]any) (time.Time, bool) {
@@ -473,9 +474,57 @@ func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth) gin.H {
 	if claims := extractCodexIDTokenClaims(auth); claims != nil {
 		entry["id_token"] = claims
 	}
+	if headroom, ok := coreauth.QuotaHeadroomFor(auth.ID); ok {
+		entry["quota_headroom"] = headroom
+	}
+	if auth.LastError != nil {
+		entry["last_error"] = auth.LastError
+	}
+	if h.usageStats != nil {
+		if stats, ok := h.usageStats.AuthUsage(auth.Index); ok {
+			entry["usage"] = stats
+		}
+	}
+	entry["cooldown"] = authCooldown(auth, time.Now())
 	return entry
 }
 
+// authCooldown reports whether auth, or any of its models, is waiting out a
+// retry or quota cooldown, and until when.
+func authCooldown(auth *coreauth.Auth, now time.Time) gin.H {
+	until := time.Time{}
+	if auth.Unavailable && auth.NextRetryAfter.After(now) {
+		until = auth.NextRetryAfter
+	}
+	if auth.Quota.Exceeded && auth.Quota.NextRecoverAt.After(until) {
+		until = auth.Quota.NextRecoverAt
+	}
+	out := gin.H{"active": until.After(now)}
+	if until.After(now) {
+		out["until"] = until
+		if auth.Quota.Exceeded && auth.Quota.Reason != "" {
+			out["reason"] = auth.Quota.Reason
+		}
+	}
+	models := make(map[string]time.Time)
+	for model, state := range auth.ModelStates {
+		if state == nil || !state.Unavailable {
+			continue
+		}
+		modelUntil := state.NextRetryAfter
+		if state.Quota.Exceeded && state.Quota.NextRecoverAt.After(modelUntil) {
+			modelUntil = state.Quota.NextRecoverAt
+		}
+		if modelUntil.After(now) {
+			models[model] = modelUntil
+		}
+	}
+	if len(models) > 0 {
+		out["models"] = models
+	}
+	return out
+}
+
 func extractCodexIDTokenClaims(auth *coreauth.Auth) gin.H {
 	if auth == nil || auth.Metadata == nil {
 		return nil
@@ -641,13 +690,18 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok"})
 }
 
-// Delete auth files: single by name or all
+// Delete auth files: single by name or all. Files are moved to the auth trash
+// (see RestoreAuthFile) unless remote-management.auth-trash-ttl-hours is negative.
 func (h *Handler) DeleteAuthFile(c *gin.Context) {
 	if h.authManager == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
 		return
 	}
 	ctx := c.Request.Context()
+	now := time.Now()
+	if _, errTrash := h.authTrashEntries(now); errTrash != nil {
+		log.Warnf("management: failed to purge auth trash: %v", errTrash)
+	}
 	if all := c.Query("all"); all == "true" || all == "1" || all == "*" {
 		entries, err := os.ReadDir(h.cfg.AuthDir)
 		if err != nil {
@@ -669,7 +723,7 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 					full = abs
 				}
 			}
-			if err = os.Remove(full); err == nil {
+			if _, err = h.removeAuthFile(full, now); err == nil {
 				if errDel := h.deleteTokenRecord(ctx, full); errDel != nil {
 					c.JSON(500, gin.H{"error": errDel.Error()})
 					return
@@ -700,7 +754,8 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 			targetPath = abs
 		}
 	}
-	if errRemove := os.Remove(targetPath); errRemove != nil {
+	trashID, errRemove := h.removeAuthFile(targetPath, now)
+	if errRemove != nil {
 		if os.IsNotExist(errRemove) {
 			c.JSON(404, gin.H{"error": "file not found"})
 		} else {
@@ -717,6 +772,10 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 	} else {
 		h.disableAuth(ctx, targetPath)
 	}
+	if trashID != "" {
+		c.JSON(200, gin.H{"status": "ok", "trash_id": trashID})
+		return
+	}
 	c.JSON(200, gin.H{"status": "ok"})
 }
 
@@ -1281,7 +1340,7 @@ func (h *Handler) RequestAnthropicToken(c *gin.Context) {
 					SetOAuthSessionError(state, "Timeout waiting for OAuth callback")
 					return nil, fmt.Errorf("timeout waiting for OAuth callback")
 				}
-				data, errRead := os.ReadFile(path)
+				data, errRead := readOAuthCallbackFile(path)
 				if errRead == nil {
 					var m map[string]string
 					_ = json.Unmarshal(data, &m)
@@ -1420,7 +1479,7 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 				SetOAuthSessionError(state, "OAuth flow timed out")
 				return
 			}
-			if data, errR := os.ReadFile(waitFile); errR == nil {
+			if data, errR := readOAuthCallbackFile(waitFile); errR == nil {
 				var m map[string]string
 				_ = json.Unmarshal(data, &m)
 				_ = os.Remove(waitFile)
@@ -1561,7 +1620,19 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 				return
 			}
 		} else {
-			if errEnsure := ensureGeminiProjectAndOnboard(ctx, gemClient, &ts, requestedProjectID); errEnsure != nil {
+			errEnsure := ensureGeminiProjectAndOnboard(ctx, gemClient, &ts, requestedProjectID)
+			if _, needsSelection := errors.AsType[*projectSelectionRequiredError](errEnsure); needsSelection && requestedProjectID == "" {
+				selectedProjectID, autoSelected, errSelect := awaitGeminiProjectSelection(ctx, gemClient, state)
+				if errSelect != nil {
+					log.Errorf("Gemini CLI project selection failed: %v", errSelect)
+					SetOAuthSessionError(state, "Failed to select Google Cloud project")
+					return
+				}
+				requestedProjectID = selectedProjectID
+				errEnsure = ensureGeminiProjectAndOnboard(ctx, gemClient, &ts, requestedProjectID)
+				ts.Auto = autoSelected
+			}
+			if errEnsure != nil {
 				log.Errorf("Failed to complete Gemini CLI onboarding: %v", errEnsure)
 				SetOAuthSessionError(state, geminiOnboardingFailureMessage(requestedProjectID, errEnsure))
 				return
@@ -1598,6 +1669,9 @@ func (h *Handler) RequestGeminiCLIToken(c *gin.Context) {
 			"auto":       ts.Auto,
 			"checked":    ts.Checked,
 		}
+		if ts.Tier != "" {
+			recordMetadata["tier"] = ts.Tier
+		}
 
 		fileName := geminiAuth.CredentialFileName(ts.Email, ts.ProjectID, true)
 		record := &coreauth.Auth{
@@ -1695,7 +1769,7 @@ func (h *Handler) RequestCodexToken(c *gin.Context) {
 				SetOAuthSessionError(state, "Timeout waiting for OAuth callback")
 				return
 			}
-			if data, errR := os.ReadFile(waitFile); errR == nil {
+			if data, errR := readOAuthCallbackFile(waitFile); errR == nil {
 				var m map[string]string
 				_ = json.Unmarshal(data, &m)
 				_ = os.Remove(waitFile)
@@ -1849,7 +1923,7 @@ func (h *Handler) RequestGitLabToken(c *gin.Context) {
 				SetOAuthSessionError(state, "Timeout waiting for OAuth callback")
 				return
 			}
-			if data, errRead := os.ReadFile(waitFile); errRead == nil {
+			if data, errRead := readOAuthCallbackFile(waitFile); errRead == nil {
 				var payload map[string]string
 				_ = json.Unmarshal(data, &payload)
 				_ = os.Remove(waitFile)
@@ -2063,7 +2137,7 @@ func (h *Handler) RequestAntigravityToken(c *gin.Context) {
 				SetOAuthSessionError(state, "OAuth flow timed out")
 				return
 			}
-			if data, errReadFile := os.ReadFile(waitFile); errReadFile == nil {
+			if data, errReadFile := readOAuthCallbackFile(waitFile); errReadFile == nil {
 				var payload map[string]string
 				_ = json.Unmarshal(data, &payload)
 				_ = os.Remove(waitFile)
@@ -2355,7 +2429,7 @@ func (h *Handler) RequestIFlowToken(c *gin.Context) {
 				fmt.Println("Authentication failed: timeout waiting for callback")
 				return
 			}
-			if data, errR := os.ReadFile(waitFile); errR == nil {
+			if data, errR := readOAuthCallbackFile(waitFile); errR == nil {
 				_ = os.Remove(waitFile)
 				_ = json.Unmarshal(data, &resultMap)
 				break
@@ -2650,6 +2724,104 @@ func ensureGeminiProjectAndOnboard(ctx context.Context, httpClient *http.Client,
 	return nil
 }
 
+// geminiProjectChoice is a Cloud project offered to the management client when
+// the account cannot auto-provision a Gemini CLI project.
+type geminiProjectChoice struct {
+	ProjectID string `json:"project_id"`
+	Name      string `json:"name,omitempty"`
+}
+
+// awaitGeminiProjectSelection resolves the project for an account whose tier
+// needs a user-defined Cloud project. A single active project is used directly
+// (reported as auto-selected); otherwise the choices are published on the OAuth
+// session and the call blocks until the client submits one via
+// PostGeminiCLIProjectSelection or the session times out.
+func awaitGeminiProjectSelection(ctx context.Context, httpClient *http.Client, state string) (string, bool, error) {
+	projects, errProjects := fetchGCPProjectsFn(ctx, httpClient)
+	if errProjects != nil {
+		return "", false, fmt.Errorf("fetch project list: %w", errProjects)
+	}
+	choices := make([]geminiProjectChoice, 0, len(projects))
+	for _, project := range projects {
+		projectID := strings.TrimSpace(project.ProjectID)
+		if projectID == "" {
+			continue
+		}
+		if lifecycle := strings.TrimSpace(project.LifecycleState); lifecycle != "" && !strings.EqualFold(lifecycle, "ACTIVE") {
+			continue
+		}
+		choices = append(choices, geminiProjectChoice{ProjectID: projectID, Name: strings.TrimSpace(project.Name)})
+	}
+	switch len(choices) {
+	case 0:
+		return "", false, fmt.Errorf("no active Google Cloud projects available for this account")
+	case 1:
+		log.Infof("Gemini CLI onboarding: using the only available project %s", choices[0].ProjectID)
+		return choices[0].ProjectID, true, nil
+	}
+
+	encoded, errMarshal := json.Marshal(choices)
+	if errMarshal != nil {
+		return "", false, fmt.Errorf("encode project choices: %w", errMarshal)
+	}
+	SetOAuthSessionProjectSelection(state, encoded)
+	fmt.Println("Waiting for Google Cloud project selection...")
+	deadline := time.Now().Add(oauthWaitTimeout)
+	for {
+		if !IsOAuthSessionPending(state, "gemini") {
+			return "", false, fmt.Errorf("oauth session is no longer pending")
+		}
+		if selected := TakeOAuthSessionSelection(state); selected != "" {
+			for _, choice := range choices {
+				if strings.EqualFold(choice.ProjectID, selected) {
+					return choice.ProjectID, false, nil
+				}
+			}
+			return "", false, fmt.Errorf("project %s is not available for this account", selected)
+		}
+		if time.Now().After(deadline) {
+			return "", false, fmt.Errorf("project selection timed out")
+		}
+		select {
+		case <-ctx.Done():
+			return "", false, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// PostGeminiCLIProjectSelection submits the Cloud project chosen for a Gemini
+// CLI OAuth session that is waiting in the project selection step.
+func (h *Handler) PostGeminiCLIProjectSelection(c *gin.Context) {
+	var body struct {
+		State     string `json:"state"`
+		ProjectID string `json:"project_id"`
+	}
+	if errBind := c.ShouldBindJSON(&body); errBind != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	state := strings.TrimSpace(body.State)
+	if errState := ValidateOAuthState(state); errState != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid state"})
+		return
+	}
+	projectID := strings.TrimSpace(body.ProjectID)
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "project_id is required"})
+		return
+	}
+	if provider, _, ok := GetOAuthSession(state); !ok || provider != "gemini" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "oauth session not found"})
+		return
+	}
+	if !SetOAuthSessionSelection(state, projectID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "oauth session is not waiting for project selection"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
 func onboardAllGeminiProjects(ctx context.Context, httpClient *http.Client, storage *geminiAuth.GeminiTokenStorage) ([]string, error) {
 	projects, errProjects := fetchGCPProjects(ctx, httpClient)
 	if errProjects != nil {
@@ -2723,21 +2895,8 @@ func performGeminiCLISetup(ctx context.Context, httpClient *http.Client, storage
 		return fmt.Errorf("load code assist: %w", errLoad)
 	}
 
-	tierID := "legacy-tier"
-	if tiers, okTiers := loadResp["allowedTiers"].([]any); okTiers {
-		for _, rawTier := range tiers {
-			tier, okTier := rawTier.(map[string]any)
-			if !okTier {
-				continue
-			}
-			if isDefault, okDefault := tier["isDefault"].(bool); okDefault && isDefault {
-				if id, okID := tier["id"].(string); okID && strings.TrimSpace(id) != "" {
-					tierID = strings.TrimSpace(id)
-					break
-				}
-			}
-		}
-	}
+	tierID, requiresUserProject := geminiOnboardTier(loadResp)
+	storage.Tier = tierID
 
 	projectID := trimmedRequest
 	if projectID == "" {
@@ -2752,6 +2911,12 @@ func performGeminiCLISetup(ctx context.Context, httpClient *http.Client, storage
 			}
 		}
 	}
+	if projectID == "" && requiresUserProject {
+		// Paid tiers cannot auto-provision a managed project; the caller has to
+		// pick one of the user's Cloud projects.
+		log.Infof("Gemini tier %s requires a user-selected Cloud project", tierID)
+		return &projectSelectionRequiredError{}
+	}
 	if projectID == "" {
 		// Auto-discovery: try onboardUser without specifying a project
 		// to let Google auto-provision one (matches Gemini CLI headless behavior
@@ -2862,6 +3027,37 @@ func performGeminiCLISetup(ctx context.Context, httpClient *http.Client, storage
 	}
 }
 
+// geminiOnboardTier picks the tier used for onboarding from a loadCodeAssist
+// response: the account's current tier when present, otherwise the default
+// allowed tier. The second value reports whether that tier requires a
+// user-defined Cloud project instead of a Google-managed one.
+func geminiOnboardTier(loadResp map[string]any) (string, bool) {
+	tierFields := func(tier map[string]any) (string, bool) {
+		id, _ := tier["id"].(string)
+		userDefined, _ := tier["userDefinedCloudaicompanionProject"].(bool)
+		return strings.TrimSpace(id), userDefined
+	}
+	if current, okCurrent := loadResp["currentTier"].(map[string]any); okCurrent {
+		if id, userDefined := tierFields(current); id != "" {
+			return id, userDefined
+		}
+	}
+	if tiers, okTiers := loadResp["allowedTiers"].([]any); okTiers {
+		for _, rawTier := range tiers {
+			tier, okTier := rawTier.(map[string]any)
+			if !okTier {
+				continue
+			}
+			if isDefault, okDefault := tier["isDefault"].(bool); okDefault && isDefault {
+				if id, userDefined := tierFields(tier); id != "" {
+					return id, userDefined
+				}
+			}
+		}
+	}
+	return "legacy-tier", false
+}
+
 func callGeminiCLI(ctx context.Context, httpClient *http.Client, endpoint string, body any, result any) error {
 	endPointURL := fmt.Sprintf("%s/%s:%s", geminiCLIEndpoint, geminiCLIVersion, endpoint)
 	if strings.HasPrefix(endpoint, "operations/") {
@@ -3028,6 +3224,15 @@ func (h *Handler) GetAuthStatus(c *gin.Context) {
 				return
 			}
 		}
+		if strings.HasPrefix(status, oauthSessionProjectSelectionPrefix) {
+			var choices []geminiProjectChoice
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(status, oauthSessionProjectSelectionPrefix)), &choices)
+			c.JSON(http.StatusOK, gin.H{
+				"status":   "project_selection",
+				"projects": choices,
+			})
+			return
+		}
 		if strings.HasPrefix(status, "auth_url|") {
 			authURL := strings.TrimPrefix(status, "auth_url|")
 			c.JSON(http.StatusOK, gin.H{
@@ -3241,7 +3446,7 @@ func (h *Handler) RequestKiroToken(c *gin.Context) {
 					SetOAuthSessionError(state, "OAuth flow timed out")
 					return
 				}
-				if data, errRead := os.ReadFile(waitFile); errRead == nil {
+				if data, errRead := readOAuthCallbackFile(waitFile); errRead == nil {
 					var m map[string]string
 					_ = json.Unmarshal(data, &m)
 					_ = os.Remove(waitFile)
@@ -4,8 +4,10 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	geminiAuth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 )
 
 func TestShouldVerifyCloudAPIForProjectSelection(t *testing.T) {
@@ -55,3 +57,80 @@ func TestGeminiOnboardingFailureMessage(t *testing.T) {
 		t.Fatalf("unexpected message for explicit project: %q", got)
 	}
 }
+
+func TestGeminiOnboardTier(t *testing.T) {
+	tierID, userDefined := geminiOnboardTier(map[string]any{
+		"currentTier": map[string]any{"id": "standard-tier", "userDefinedCloudaicompanionProject": true},
+		"allowedTiers": []any{
+			map[string]any{"id": "free-tier", "isDefault": true},
+		},
+	})
+	if tierID != "standard-tier" || !userDefined {
+		t.Fatalf("current tier = (%q, %v), want (standard-tier, true)", tierID, userDefined)
+	}
+
+	tierID, userDefined = geminiOnboardTier(map[string]any{
+		"allowedTiers": []any{
+			map[string]any{"id": "standard-tier"},
+			map[string]any{"id": "free-tier", "isDefault": true},
+		},
+	})
+	if tierID != "free-tier" || userDefined {
+		t.Fatalf("default tier = (%q, %v), want (free-tier, false)", tierID, userDefined)
+	}
+
+	if tierID, _ = geminiOnboardTier(map[string]any{}); tierID != "legacy-tier" {
+		t.Fatalf("fallback tier = %q, want legacy-tier", tierID)
+	}
+}
+
+func stubGCPProjects(t *testing.T, projects []interfaces.GCPProjectProjects) {
+	t.Helper()
+	original := fetchGCPProjectsFn
+	fetchGCPProjectsFn = func(context.Context, *http.Client) ([]interfaces.GCPProjectProjects, error) {
+		return projects, nil
+	}
+	t.Cleanup(func() { fetchGCPProjectsFn = original })
+}
+
+func TestAwaitGeminiProjectSelection_SingleProjectIsAutoSelected(t *testing.T) {
+	stubGCPProjects(t, []interfaces.GCPProjectProjects{
+		{ProjectID: "deleted-project", LifecycleState: "DELETE_REQUESTED"},
+		{ProjectID: "only-project", LifecycleState: "ACTIVE"},
+	})
+
+	projectID, auto, err := awaitGeminiProjectSelection(context.Background(), &http.Client{}, "gem-single")
+	if err != nil {
+		t.Fatalf("awaitGeminiProjectSelection returned error: %v", err)
+	}
+	if projectID != "only-project" || !auto {
+		t.Fatalf("selection = (%q, %v), want (only-project, true)", projectID, auto)
+	}
+}
+
+func TestAwaitGeminiProjectSelection_WaitsForClientChoice(t *testing.T) {
+	stubGCPProjects(t, []interfaces.GCPProjectProjects{
+		{ProjectID: "project-a", Name: "A"},
+		{ProjectID: "project-b", Name: "B"},
+	})
+	state := "gem-select-test"
+	RegisterOAuthSession(state, "gemini")
+	t.Cleanup(func() { CompleteOAuthSession(state) })
+
+	go func() {
+		for !SetOAuthSessionSelection(state, "project-b") {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	projectID, auto, err := awaitGeminiProjectSelection(context.Background(), &http.Client{}, state)
+	if err != nil {
+		t.Fatalf("awaitGeminiProjectSelection returned error: %v", err)
+	}
+	if projectID != "project-b" || auto {
+		t.Fatalf("selection = (%q, %v), want (project-b, false)", projectID, auto)
+	}
+	if _, status, _ := GetOAuthSession(state); status != "" {
+		t.Fatalf("session status after selection = %q, want pending", status)
+	}
+}
@@ -0,0 +1,59 @@
+package management
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestBuildAuthFileEntryIncludesUsageAndCooldown(t *testing.T) {
+	now := time.Now()
+	auth := &coreauth.Auth{
+		ID:             "codex-a.json",
+		FileName:       "codex-a.json",
+		Provider:       "codex",
+		Attributes:     map[string]string{"runtime_only": "true"},
+		Status:         coreauth.StatusError,
+		Unavailable:    true,
+		NextRetryAfter: now.Add(time.Minute),
+		LastError:      &coreauth.Error{Code: "rate_limited", Message: "429", HTTPStatus: 429},
+		ModelStates: map[string]*coreauth.ModelState{
+			"gpt-5":      {Unavailable: true, NextRetryAfter: now.Add(2 * time.Minute)},
+			"gpt-5-mini": {Unavailable: true, NextRetryAfter: now.Add(-time.Minute)},
+		},
+	}
+	auth.EnsureIndex()
+
+	stats := usage.NewRequestStatistics()
+	stats.Record(context.Background(), coreusage.Record{AuthIndex: auth.Index, Model: "gpt-5", RequestedAt: now, Detail: coreusage.Detail{TotalTokens: 42}})
+	h := NewHandlerWithoutConfigFilePath(&config.Config{}, nil)
+	h.SetUsageStatistics(stats)
+
+	entry := h.buildAuthFileEntry(auth)
+	if entry == nil {
+		t.Fatal("expected an entry for a runtime-only auth")
+	}
+	if got, ok := entry["usage"].(usage.AuthUsage); !ok || got.TokensToday != 42 || got.SuccessRate != 1 {
+		t.Fatalf("usage = %#v", entry["usage"])
+	}
+	if entry["last_error"] != auth.LastError {
+		t.Fatalf("last_error = %#v", entry["last_error"])
+	}
+	cooldown, _ := entry["cooldown"].(gin.H)
+	if cooldown == nil {
+		t.Fatalf("cooldown = %#v", entry["cooldown"])
+	}
+	if cooldown["active"] != true || !cooldown["until"].(time.Time).Equal(auth.NextRetryAfter) {
+		t.Fatalf("cooldown = %#v", cooldown)
+	}
+	models, _ := cooldown["models"].(map[string]time.Time)
+	if len(models) != 1 || models["gpt-5"].IsZero() {
+		t.Fatalf("cooling models = %#v, want only gpt-5", models)
+	}
+}
@@ -0,0 +1,205 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// Deleted auth files are moved to <auth-dir>/.trash as
+// "<unix-nanos>_<original name>.trash". The suffix keeps them out of every
+// auth loader, which only pick up *.json files.
+const (
+	authTrashDirName = ".trash"
+	authTrashSuffix  = ".trash"
+)
+
+// authTrashTTL returns how long trashed auth files are kept, or 0 when
+// management deletions should remove files outright.
+func (h *Handler) authTrashTTL() time.Duration {
+	hours := config.DefaultAuthTrashTTLHours
+	if h.cfg != nil && h.cfg.RemoteManagement.AuthTrashTTLHours != 0 {
+		hours = h.cfg.RemoteManagement.AuthTrashTTLHours
+	}
+	if hours < 0 {
+		return 0
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func (h *Handler) authTrashDir() string {
+	return filepath.Join(h.cfg.AuthDir, authTrashDirName)
+}
+
+// removeAuthFile deletes path, moving it to the trash when the trash is
+// enabled. It returns the trash ID, empty when the file was removed outright.
+func (h *Handler) removeAuthFile(path string, now time.Time) (string, error) {
+	if h.authTrashTTL() <= 0 {
+		return "", os.Remove(path)
+	}
+	if _, errStat := os.Stat(path); errStat != nil {
+		return "", errStat
+	}
+	dir := h.authTrashDir()
+	if errMkdir := os.MkdirAll(dir, 0o700); errMkdir != nil {
+		return "", fmt.Errorf("failed to create auth trash: %w", errMkdir)
+	}
+	id := strconv.FormatInt(now.UnixNano(), 10) + "_" + filepath.Base(path) + authTrashSuffix
+	dst := filepath.Join(dir, id)
+	if errRename := os.Rename(path, dst); errRename == nil {
+		return id, nil
+	}
+	// The auth's path may sit on another filesystem than the auth directory.
+	data, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return "", errRead
+	}
+	if errWrite := os.WriteFile(dst, data, 0o600); errWrite != nil {
+		return "", fmt.Errorf("failed to write auth trash: %w", errWrite)
+	}
+	if errRemove := os.Remove(path); errRemove != nil {
+		_ = os.Remove(dst)
+		return "", errRemove
+	}
+	return id, nil
+}
+
+type authTrashEntry struct {
+	id        string
+	name      string
+	deletedAt time.Time
+}
+
+func parseAuthTrashID(id string) (authTrashEntry, bool) {
+	if id != filepath.Base(id) || !strings.HasSuffix(id, authTrashSuffix) {
+		return authTrashEntry{}, false
+	}
+	stamp, name, ok := strings.Cut(strings.TrimSuffix(id, authTrashSuffix), "_")
+	if !ok || name == "" || name == "." || name == ".." {
+		return authTrashEntry{}, false
+	}
+	nanos, errParse := strconv.ParseInt(stamp, 10, 64)
+	if errParse != nil {
+		return authTrashEntry{}, false
+	}
+	return authTrashEntry{id: id, name: name, deletedAt: time.Unix(0, nanos)}, true
+}
+
+// authTrashEntries lists the trash, newest first, removing entries older
+// than the TTL along the way.
+func (h *Handler) authTrashEntries(now time.Time) ([]authTrashEntry, error) {
+	dir := h.authTrashDir()
+	files, errRead := os.ReadDir(dir)
+	if errRead != nil {
+		if os.IsNotExist(errRead) {
+			return nil, nil
+		}
+		return nil, errRead
+	}
+	ttl := h.authTrashTTL()
+	entries := make([]authTrashEntry, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		entry, ok := parseAuthTrashID(file.Name())
+		if !ok {
+			continue
+		}
+		if ttl > 0 && now.Sub(entry.deletedAt) >= ttl {
+			if errRemove := os.Remove(filepath.Join(dir, entry.id)); errRemove != nil && !os.IsNotExist(errRemove) {
+				log.Warnf("management: failed to purge trashed auth file %s: %v", entry.id, errRemove)
+			}
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].deletedAt.After(entries[j].deletedAt) })
+	return entries, nil
+}
+
+// ListAuthTrash lists auth files deleted through the management API that can
+// still be restored.
+func (h *Handler) ListAuthTrash(c *gin.Context) {
+	now := time.Now()
+	entries, err := h.authTrashEntries(now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read auth trash: %v", err)})
+		return
+	}
+	ttl := h.authTrashTTL()
+	files := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		item := gin.H{"id": entry.id, "name": entry.name, "deleted_at": entry.deletedAt}
+		if ttl > 0 {
+			item["expires_at"] = entry.deletedAt.Add(ttl)
+		}
+		if data, errRead := os.ReadFile(filepath.Join(h.authTrashDir(), entry.id)); errRead == nil {
+			item["size"] = len(data)
+			item["type"] = gjson.GetBytes(data, "type").String()
+			item["email"] = gjson.GetBytes(data, "email").String()
+		}
+		files = append(files, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"files": files})
+}
+
+// RestoreAuthFile moves a trashed auth file back into the auth directory and
+// registers it again. The body is {"id": "<trash id>"}.
+func (h *Handler) RestoreAuthFile(c *gin.Context) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	entry, ok := parseAuthTrashID(strings.TrimSpace(req.ID))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if _, err := h.authTrashEntries(time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read auth trash: %v", err)})
+		return
+	}
+	src := filepath.Join(h.authTrashDir(), entry.id)
+	data, errRead := os.ReadFile(src)
+	if errRead != nil {
+		if os.IsNotExist(errRead) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "trashed file not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read trashed file: %v", errRead)})
+		}
+		return
+	}
+	dst := filepath.Join(h.cfg.AuthDir, entry.name)
+	if !filepath.IsAbs(dst) {
+		if abs, errAbs := filepath.Abs(dst); errAbs == nil {
+			dst = abs
+		}
+	}
+	if _, errStat := os.Stat(dst); errStat == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "an auth file with this name already exists"})
+		return
+	}
+	if errRename := os.Rename(src, dst); errRename != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to restore file: %v", errRename)})
+		return
+	}
+	if errReg := h.registerAuthFromFile(c.Request.Context(), dst, data); errReg != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errReg.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "name": entry.name})
+}
@@ -0,0 +1,147 @@
+package management
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func listAuthTrash(t *testing.T, h *Handler) []map[string]any {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/v0/management/auth-files/trash", nil)
+	h.ListAuthTrash(ctx)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected trash list status %d, got %d with body %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var payload struct {
+		Files []map[string]any `json:"files"`
+	}
+	if errUnmarshal := json.Unmarshal(rec.Body.Bytes(), &payload); errUnmarshal != nil {
+		t.Fatalf("failed to decode trash payload: %v", errUnmarshal)
+	}
+	return payload.Files
+}
+
+func restoreAuthFile(h *Handler, id string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rec)
+	body, _ := json.Marshal(map[string]string{"id": id})
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/v0/management/auth-files/restore", bytes.NewReader(body))
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	h.RestoreAuthFile(ctx)
+	return rec
+}
+
+func TestDeleteAuthFile_TrashAndRestore(t *testing.T) {
+	t.Setenv("MANAGEMENT_PASSWORD", "")
+	gin.SetMode(gin.TestMode)
+
+	authDir := t.TempDir()
+	fileName := "claude-user@example.com.json"
+	filePath := filepath.Join(authDir, fileName)
+	content := []byte(`{"type":"claude","email":"user@example.com","refresh_token":"rt"}`)
+	if errWrite := os.WriteFile(filePath, content, 0o600); errWrite != nil {
+		t.Fatalf("failed to write auth file: %v", errWrite)
+	}
+
+	manager := coreauth.NewManager(nil, nil, nil)
+	h := NewHandlerWithoutConfigFilePath(&config.Config{AuthDir: authDir}, manager)
+	h.tokenStore = &memoryAuthStore{}
+
+	deleteRec := httptest.NewRecorder()
+	deleteCtx, _ := gin.CreateTestContext(deleteRec)
+	deleteCtx.Request = httptest.NewRequest(http.MethodDelete, "/v0/management/auth-files?name="+url.QueryEscape(fileName), nil)
+	h.DeleteAuthFile(deleteCtx)
+	if deleteRec.Code != http.StatusOK {
+		t.Fatalf("expected delete status %d, got %d with body %s", http.StatusOK, deleteRec.Code, deleteRec.Body.String())
+	}
+	if _, errStat := os.Stat(filePath); !os.IsNotExist(errStat) {
+		t.Fatalf("expected auth file to leave the auth dir, stat err: %v", errStat)
+	}
+
+	files := listAuthTrash(t, h)
+	if len(files) != 1 || files[0]["name"] != fileName || files[0]["email"] != "user@example.com" {
+		t.Fatalf("unexpected trash listing: %#v", files)
+	}
+	if _, ok := files[0]["expires_at"]; !ok {
+		t.Fatalf("expected expires_at in trash listing: %#v", files[0])
+	}
+	id, _ := files[0]["id"].(string)
+
+	if rec := restoreAuthFile(h, "../"+id); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected path traversal to be rejected, got %d", rec.Code)
+	}
+	if rec := restoreAuthFile(h, id); rec.Code != http.StatusOK {
+		t.Fatalf("expected restore status %d, got %d with body %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	restored, errRead := os.ReadFile(filePath)
+	if errRead != nil || !bytes.Equal(restored, content) {
+		t.Fatalf("restored file = %q, err %v", restored, errRead)
+	}
+	if len(listAuthTrash(t, h)) != 0 {
+		t.Fatal("expected trash to be empty after restore")
+	}
+	if auth, ok := manager.GetByID(h.authIDForPath(filePath)); !ok || auth.Disabled {
+		t.Fatalf("expected restored auth to be registered and enabled, got %#v", auth)
+	}
+	if rec := restoreAuthFile(h, id); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected second restore to return 404, got %d", rec.Code)
+	}
+}
+
+func TestAuthTrashPurgesExpiredEntries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	authDir := t.TempDir()
+	h := NewHandlerWithoutConfigFilePath(&config.Config{AuthDir: authDir}, nil)
+	h.cfg.RemoteManagement.AuthTrashTTLHours = 1
+	trashDir := filepath.Join(authDir, authTrashDirName)
+	if errMkdir := os.MkdirAll(trashDir, 0o700); errMkdir != nil {
+		t.Fatal(errMkdir)
+	}
+	expired := strconv.FormatInt(time.Now().Add(-2*time.Hour).UnixNano(), 10) + "_old.json" + authTrashSuffix
+	fresh := strconv.FormatInt(time.Now().UnixNano(), 10) + "_new.json" + authTrashSuffix
+	for _, name := range []string{expired, fresh} {
+		if errWrite := os.WriteFile(filepath.Join(trashDir, name), []byte(`{"type":"codex"}`), 0o600); errWrite != nil {
+			t.Fatal(errWrite)
+		}
+	}
+
+	files := listAuthTrash(t, h)
+	if len(files) != 1 || files[0]["id"] != fresh {
+		t.Fatalf("unexpected trash listing: %#v", files)
+	}
+	if _, errStat := os.Stat(filepath.Join(trashDir, expired)); !os.IsNotExist(errStat) {
+		t.Fatalf("expected expired entry to be purged, stat err: %v", errStat)
+	}
+}
+
+func TestRemoveAuthFile_NegativeTTLDeletesOutright(t *testing.T) {
+	authDir := t.TempDir()
+	h := NewHandlerWithoutConfigFilePath(&config.Config{AuthDir: authDir}, nil)
+	h.cfg.RemoteManagement.AuthTrashTTLHours = -1
+	path := filepath.Join(authDir, "gone.json")
+	if errWrite := os.WriteFile(path, []byte(`{}`), 0o600); errWrite != nil {
+		t.Fatal(errWrite)
+	}
+	id, errRemove := h.removeAuthFile(path, time.Now())
+	if errRemove != nil || id != "" {
+		t.Fatalf("removeAuthFile = %q, %v", id, errRemove)
+	}
+	if _, errStat := os.Stat(filepath.Join(authDir, authTrashDirName)); !os.IsNotExist(errStat) {
+		t.Fatalf("expected no trash dir, stat err: %v", errStat)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -39,3 +42,68 @@ func TestGetConfigReturnsCurrentConfigSnapshot(t *testing.T) {
 		t.Fatalf("expected logging-to-file=true in response")
 	}
 }
+
+func TestPutConfigYAMLReturnsSchemaIssues(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	h := &Handler{cfg: &config.Config{}, configFilePath: configPath}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/v0/management/config.yaml", strings.NewReader("port: 8317\nroutng:\n  strategy: fill-first\n"))
+
+	h.PutConfigYAML(c)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("unexpected status code: got %d want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	var resp struct {
+		Issues []config.ValidationIssue `json:"issues"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if len(resp.Issues) != 1 || resp.Issues[0].Path != "routng" || resp.Issues[0].Line != 2 {
+		t.Fatalf("unexpected issues: %+v", resp.Issues)
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Fatalf("invalid config must not be written, stat err = %v", err)
+	}
+}
+
+func TestPutLogLevelsMergesAndPersists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8317\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	h := &Handler{cfg: &config.Config{Logging: config.LoggingConfig{Levels: config.LogLevelsConfig{Thinking: "warn"}}}, configFilePath: configPath}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/v0/management/log-levels", strings.NewReader(`{"executor":"DEBUG"}`))
+	h.PutLogLevels(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if got := h.cfg.Logging.Levels; got.Executor != "debug" || got.Thinking != "warn" {
+		t.Fatalf("levels = %+v", got)
+	}
+	saved, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(saved), "executor: debug") {
+		t.Fatalf("saved config missing executor level:\n%s", saved)
+	}
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/v0/management/log-levels", strings.NewReader(`{"translator":"loud"}`))
+	h.PutLogLevels(c)
+	if w.Code != http.StatusBadRequest || h.cfg.Logging.Levels.Translator != "" {
+		t.Fatalf("invalid level: status %d, levels %+v", w.Code, h.cfg.Logging.Levels)
+	}
+}
@@ -0,0 +1,22 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+)
+
+// GetConnectionStats reports, per provider, how many upstream requests
+// reused a pooled connection and how long DNS, dialing and TLS took for the
+// new ones, with the number of cached HTTP clients those requests share.
+func (h *Handler) GetConnectionStats(c *gin.Context) {
+	providers := executor.UpstreamConnectionStats()
+	if providers == nil {
+		providers = []executor.ConnectionStats{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"http-clients": executor.CachedHTTPClients(),
+		"providers":    providers,
+	})
+}
@@ -0,0 +1,50 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// ExportConversation exports the conversation ending with the response named
+// by the response-id query parameter, as recorded for the client key api-key
+// (authenticated by the access provider in provider, the inline config keys
+// by default). format is json (the default) or markdown.
+func (h *Handler) ExportConversation(c *gin.Context) {
+	responseID := strings.TrimSpace(c.Query("response-id"))
+	apiKey := strings.TrimSpace(c.Query("api-key"))
+	if responseID == "" || apiKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "response-id and api-key are required"})
+		return
+	}
+	provider := strings.TrimSpace(c.Query("provider"))
+	if provider == "" {
+		provider = sdkaccess.DefaultAccessProviderName
+	}
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "json")))
+	if format != "json" && format != "markdown" && format != "md" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or markdown"})
+		return
+	}
+
+	transcript, found, err := coreauth.LoadCodexTranscript(c.Request.Context(), cliproxyexecutor.ClientScope(provider, apiKey), responseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to load conversation: %v", err)})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		return
+	}
+	if format == "json" {
+		c.JSON(http.StatusOK, transcript)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"conversation-%s.md\"", responseID))
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(transcript.Markdown()))
+}
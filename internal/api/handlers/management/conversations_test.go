@@ -0,0 +1,54 @@
+package management
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+func TestExportConversation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	scope := cliproxyexecutor.ClientScope(sdkaccess.DefaultAccessProviderName, "export-key")
+	transcript := `[{"type":"message","role":"user","content":"hi"},{"type":"message","role":"assistant","content":[{"type":"output_text","text":"hello"}]}]`
+	if err := sharedstate.Current().Set(context.Background(), "codex-rollout:"+scope+":resp_export", []byte(transcript), time.Minute); err != nil {
+		t.Fatalf("seed transcript: %v", err)
+	}
+
+	h := &Handler{}
+	router := gin.New()
+	router.GET("/conversations/export", h.ExportConversation)
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/conversations/export?"+query, nil))
+		return rec
+	}
+
+	if rec := get("response-id=resp_export"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing api-key: status %d", rec.Code)
+	}
+	if rec := get("response-id=resp_export&api-key=export-key&format=html"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown format: status %d", rec.Code)
+	}
+	if rec := get("response-id=resp_export&api-key=other-key"); rec.Code != http.StatusNotFound {
+		t.Fatalf("other key: status %d", rec.Code)
+	}
+
+	rec := get("response-id=resp_export&api-key=export-key")
+	if rec.Code != http.StatusOK || gjson.Get(rec.Body.String(), "turns.0.input.#").Int() != 2 {
+		t.Fatalf("json export: status %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = get("response-id=resp_export&api-key=export-key&format=markdown")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/markdown") ||
+		!strings.Contains(rec.Body.String(), "**Assistant**\n\nhello") {
+		t.Fatalf("markdown export: status %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,144 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/eventstream"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	eventsPingInterval = 30 * time.Second
+	eventsWriteTimeout = 10 * time.Second
+)
+
+var eventsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	// The management key is checked before the upgrade.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// GetEventsWebSocket streams live activity as JSON WebSocket messages:
+// request_started, request_finished, usage and log events. Query parameters
+// narrow the stream:
+//
+//	types        comma-separated event types (default: all)
+//	provider     usage from this provider only
+//	model        usage for this model only
+//	api-key      requests and usage of this client API key only
+//	request-id   events of one request
+//	level        least severe log level (default: info)
+//	errors-only  failed requests, failed usage and error logs only
+//
+// Browsers cannot set headers on the handshake, so the management key may
+// also be passed as the "key" query parameter. When the client reads too
+// slowly events are dropped and a {"type":"dropped","count":N} message
+// reports the running total.
+func (h *Handler) GetEventsWebSocket(c *gin.Context) {
+	filter, err := parseEventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "websocket upgrade required"})
+		return
+	}
+	conn, err := eventsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Debugf("management events: upgrade failed: %v", err)
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	sub := eventstream.Subscribe(filter, 0)
+	defer sub.Close()
+
+	// Drain client messages so control frames are handled and a close is seen.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, errRead := conn.ReadMessage(); errRead != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(eventsPingInterval)
+	defer ticker.Stop()
+	var reportedDrops int64
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(eventsWriteTimeout))
+			if errWrite := conn.WriteJSON(event); errWrite != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(eventsWriteTimeout))
+			if dropped := sub.Dropped(); dropped != reportedDrops {
+				reportedDrops = dropped
+				if errWrite := conn.WriteJSON(gin.H{"type": "dropped", "count": dropped}); errWrite != nil {
+					return
+				}
+			}
+			if errPing := conn.WriteMessage(websocket.PingMessage, nil); errPing != nil {
+				return
+			}
+		}
+	}
+}
+
+func parseEventFilter(c *gin.Context) (eventstream.Filter, error) {
+	filter := eventstream.Filter{
+		Provider:  strings.TrimSpace(c.Query("provider")),
+		Model:     strings.TrimSpace(c.Query("model")),
+		APIKey:    strings.TrimSpace(c.Query("api-key")),
+		RequestID: strings.TrimSpace(c.Query("request-id")),
+		MinLevel:  log.InfoLevel,
+	}
+	if raw := strings.TrimSpace(c.Query("types")); raw != "" {
+		filter.Types = make(map[string]struct{})
+		for _, part := range strings.Split(raw, ",") {
+			eventType := strings.ToLower(strings.TrimSpace(part))
+			switch eventType {
+			case "":
+				continue
+			case eventstream.TypeRequestStarted, eventstream.TypeRequestFinished, eventstream.TypeUsage, eventstream.TypeLog:
+				filter.Types[eventType] = struct{}{}
+			default:
+				return filter, fmt.Errorf("unknown event type %q", eventType)
+			}
+		}
+	}
+	if raw := strings.TrimSpace(c.Query("level")); raw != "" {
+		level, err := log.ParseLevel(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid level %q", raw)
+		}
+		filter.MinLevel = level
+	}
+	if raw := strings.TrimSpace(c.Query("errors-only")); raw != "" {
+		errorsOnly, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid errors-only %q", raw)
+		}
+		filter.ErrorsOnly = errorsOnly
+	}
+	return filter, nil
+}
@@ -0,0 +1,67 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/eventstream"
+)
+
+func TestGetEventsWebSocketStreamsFilteredEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	router := gin.New()
+	router.GET("/v0/management/ws", h.GetEventsWebSocket)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v0/management/ws?types=usage&model=gpt-4o"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !eventstream.Active() {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription not registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	eventstream.Publish(eventstream.Event{Type: eventstream.TypeUsage, Model: "claude-sonnet-4-5"})
+	eventstream.Publish(eventstream.Event{Type: eventstream.TypeRequestStarted, Model: "gpt-4o"})
+	eventstream.Publish(eventstream.Event{Type: eventstream.TypeUsage, Model: "gpt-4o", RequestID: "req-ws"})
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var event eventstream.Event
+	if err = conn.ReadJSON(&event); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if event.Type != eventstream.TypeUsage || event.RequestID != "req-ws" {
+		t.Fatalf("event = %+v", event)
+	}
+}
+
+func TestGetEventsWebSocketRejectsBadFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	router := gin.New()
+	router.GET("/v0/management/ws", h.GetEventsWebSocket)
+
+	for _, query := range []string{"types=bogus", "level=loud", "errors-only=maybe", ""} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v0/management/ws?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%q: status = %d, want 400", query, w.Code)
+		}
+	}
+}
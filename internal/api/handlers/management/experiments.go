@@ -0,0 +1,26 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage/experiment"
+)
+
+// GetExperiments compares latency, token use and error rates per arm of the
+// configured A/B experiments.
+func (h *Handler) GetExperiments(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"experiments": experiment.Default().Reports()})
+}
+
+// DeleteExperiments resets the report of the experiment named by the name
+// query parameter, or of every experiment when it is omitted.
+func (h *Handler) DeleteExperiments(c *gin.Context) {
+	name := strings.TrimSpace(c.Query("name"))
+	if !experiment.Default().Reset(name) && name != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "experiment has no data"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
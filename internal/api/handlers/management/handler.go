@@ -5,6 +5,7 @@ package management
 import (
 	"crypto/subtle"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -13,12 +14,14 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
 )
 
 type attemptInfo struct {
@@ -48,6 +51,8 @@ type Handler struct {
 	envSecret           string
 	logDir              string
 	postAuthHook        coreauth.PostAuthHook
+	auditMu             sync.Mutex
+	audit               auditRotation
 }
 
 // NewHandler creates a new management handler instance.
@@ -134,6 +139,17 @@ func (h *Handler) SetPostAuthHook(hook coreauth.PostAuthHook) {
 	h.postAuthHook = hook
 }
 
+// isUnixSocketRequest reports whether r arrived over a Unix domain socket
+// straight from a local client. Requests carrying forwarding headers came
+// through a reverse proxy and may originate anywhere.
+func isUnixSocketRequest(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok || addr.Network() != "unix" {
+		return false
+	}
+	return r.Header.Get("X-Forwarded-For") == "" && r.Header.Get("X-Real-IP") == ""
+}
+
 // Middleware enforces access control for management endpoints.
 // All requests (local and remote) require a valid management key.
 // Additionally, remote access requires allow-remote-management=true.
@@ -147,7 +163,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		c.Header("X-CPA-BUILD-DATE", buildinfo.BuildDate)
 
 		clientIP := c.ClientIP()
-		localClient := clientIP == "127.0.0.1" || clientIP == "::1"
+		localClient := clientIP == "127.0.0.1" || clientIP == "::1" || isUnixSocketRequest(c.Request)
 		cfg := h.cfg
 		var (
 			allowRemote bool
@@ -220,6 +236,10 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		if provided == "" {
 			provided = c.GetHeader("X-Management-Key")
 		}
+		if provided == "" && websocket.IsWebSocketUpgrade(c.Request) {
+			// Browsers cannot set headers on a WebSocket handshake.
+			provided = c.Query("key")
+		}
 
 		if provided == "" {
 			if !localClient {
@@ -232,6 +252,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		if localClient {
 			if lp := h.localPassword; lp != "" {
 				if subtle.ConstantTimeCompare([]byte(provided), []byte(lp)) == 1 {
+					setAuditCredential(c, "local-password", provided)
 					c.Next()
 					return
 				}
@@ -247,6 +268,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 				}
 				h.attemptsMu.Unlock()
 			}
+			setAuditCredential(c, "env-secret", provided)
 			c.Next()
 			return
 		}
@@ -268,6 +290,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 			h.attemptsMu.Unlock()
 		}
 
+		setAuditCredential(c, "secret-key", provided)
 		c.Next()
 	}
 }
@@ -276,6 +299,13 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 func (h *Handler) persist(c *gin.Context) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	// Reject values the loader would refuse, such as unknown enum values.
+	if data, errMarshal := yaml.Marshal(h.cfg); errMarshal == nil {
+		if errValidate := config.ValidateConfigYAML("", data); errValidate != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid_config", "message": errValidate.Error(), "issues": config.ValidationIssues(errValidate)})
+			return false
+		}
+	}
 	// Preserve comments when writing
 	if err := config.SaveConfigPreserveComments(h.configFilePath, h.cfg); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save config: %v", err)})
@@ -0,0 +1,22 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+)
+
+// GetKiroHTTPPoolHealth reports the effective Kiro HTTP pool settings and
+// connection counters for every pooled transport created so far.
+func (h *Handler) GetKiroHTTPPoolHealth(c *gin.Context) {
+	var configured config.KiroHTTPPoolConfig
+	if h != nil && h.cfg != nil {
+		configured = h.cfg.KiroHTTPPool
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"config": configured.Effective(),
+		"pools":  executor.KiroHTTPPoolHealthSnapshot(),
+	})
+}
@@ -0,0 +1,58 @@
+package management
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+// GetLogLevels returns the configured component levels together with the
+// levels currently in effect.
+func (h *Handler) GetLogLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"log-levels": h.cfg.Logging.Levels,
+		"default":    logging.BaseLevel(),
+		"effective":  logging.ComponentLevels(),
+	})
+}
+
+// PutLogLevels updates the levels of the components present in the body.
+// An empty level makes the component follow the global level again.
+func (h *Handler) PutLogLevels(c *gin.Context) {
+	var body struct {
+		Executor   *string `json:"executor"`
+		Translator *string `json:"translator"`
+		Thinking   *string `json:"thinking"`
+		Management *string `json:"management"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	levels := h.cfg.Logging.Levels
+	for _, field := range []struct {
+		value  *string
+		target *string
+	}{
+		{body.Executor, &levels.Executor},
+		{body.Translator, &levels.Translator},
+		{body.Thinking, &levels.Thinking},
+		{body.Management, &levels.Management},
+	} {
+		if field.value == nil {
+			continue
+		}
+		level := strings.ToLower(strings.TrimSpace(*field.value))
+		if level != "" && !slices.Contains(config.LogLevelNames, level) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid level", "level": *field.value})
+			return
+		}
+		*field.target = level
+	}
+	h.cfg.Logging.Levels = levels
+	h.persist(c)
+}
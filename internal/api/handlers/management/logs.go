@@ -2,6 +2,7 @@ package management
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/forensics"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 )
 
@@ -189,7 +191,7 @@ func (h *Handler) GetRequestErrorLogs(c *gin.Context) {
 			continue
 		}
 		name := entry.Name()
-		if !strings.HasPrefix(name, "error-") || !strings.HasSuffix(name, ".log") {
+		if !strings.HasPrefix(name, "error-") || !hasRequestLogSuffix(name) {
 			continue
 		}
 		info, errInfo := entry.Info()
@@ -250,6 +252,7 @@ func (h *Handler) GetRequestLogByID(c *gin.Context) {
 		return
 	}
 
+	// Logs compressed by log-retention keep their name with a .gz suffix.
 	suffix := "-" + requestID + ".log"
 	var matchedFile string
 	for _, entry := range entries {
@@ -257,7 +260,7 @@ func (h *Handler) GetRequestLogByID(c *gin.Context) {
 			continue
 		}
 		name := entry.Name()
-		if strings.HasSuffix(name, suffix) {
+		if strings.HasSuffix(name, suffix) || strings.HasSuffix(name, suffix+".gz") {
 			matchedFile = name
 			break
 		}
@@ -297,6 +300,23 @@ func (h *Handler) GetRequestLogByID(c *gin.Context) {
 	c.FileAttachment(fullPath, matchedFile)
 }
 
+// GetRequestForensics returns the forensic bundle persisted for a failed request.
+func (h *Handler) GetRequestForensics(c *gin.Context) {
+	data, err := forensics.Load(c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, forensics.ErrInvalidRequestID):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request ID"})
+		case errors.Is(err, os.ErrNotExist):
+			c.JSON(http.StatusNotFound, gin.H{"error": "forensic bundle not found for the given request ID"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read forensic bundle: %v", err)})
+		}
+		return
+	}
+	c.Data(http.StatusOK, "application/json", data)
+}
+
 // DownloadRequestErrorLog downloads a specific error request log file by name.
 func (h *Handler) DownloadRequestErrorLog(c *gin.Context) {
 	if h == nil {
@@ -319,7 +339,7 @@ func (h *Handler) DownloadRequestErrorLog(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid log file name"})
 		return
 	}
-	if !strings.HasPrefix(name, "error-") || !strings.HasSuffix(name, ".log") {
+	if !strings.HasPrefix(name, "error-") || !hasRequestLogSuffix(name) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "log file not found"})
 		return
 	}
@@ -353,6 +373,11 @@ func (h *Handler) DownloadRequestErrorLog(c *gin.Context) {
 	c.FileAttachment(fullPath, name)
 }
 
+// hasRequestLogSuffix accepts request logs in plain and compressed form.
+func hasRequestLogSuffix(name string) bool {
+	return strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")
+}
+
 func (h *Handler) logDirectory() string {
 	if h == nil {
 		return ""
@@ -0,0 +1,32 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetModelUnavailable lists the (auth, model) pairs the router currently
+// skips because the upstream reported the model unavailable for the auth.
+func (h *Handler) GetModelUnavailable(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": h.authManager.UnavailableModels()})
+}
+
+// DeleteModelUnavailable clears negative cache entries so the affected auths
+// are tried for their models again. ?auth-id= and ?model= narrow the match;
+// without either, every entry is cleared.
+func (h *Handler) DeleteModelUnavailable(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	authID := strings.TrimSpace(c.Query("auth-id"))
+	model := strings.TrimSpace(c.Query("model"))
+	cleared := h.authManager.ClearUnavailableModels(c.Request.Context(), authID, model)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "cleared": cleared})
+}
@@ -0,0 +1,101 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyTogglePath stays writable in read-only mode so the mode can be
+// turned off again.
+const readOnlyTogglePath = "/v0/management/read-only"
+
+// readOnlyAllowedPaths are non-GET routes read-only mode lets through: the
+// toggle itself, and routes that use POST only to keep secrets out of URLs.
+var readOnlyAllowedPaths = map[string]bool{
+	readOnlyTogglePath:                 true,
+	"/v0/management/auth-files/export": true,
+}
+
+// readOnlyBlockedPaths are GET routes read-only mode rejects anyway: each
+// starts an OAuth flow whose callback saves a new auth file.
+var readOnlyBlockedPaths = map[string]bool{
+	"/v0/management/anthropic-auth-url":   true,
+	"/v0/management/codex-auth-url":       true,
+	"/v0/management/gitlab-auth-url":      true,
+	"/v0/management/gemini-cli-auth-url":  true,
+	"/v0/management/antigravity-auth-url": true,
+	"/v0/management/qwen-auth-url":        true,
+	"/v0/management/kilo-auth-url":        true,
+	"/v0/management/kimi-auth-url":        true,
+	"/v0/management/iflow-auth-url":       true,
+	"/v0/management/kiro-auth-url":        true,
+	"/v0/management/github-auth-url":      true,
+}
+
+// ReadOnlyMiddleware rejects requests that could change state while
+// remote-management.read-only is set.
+func (h *Handler) ReadOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.cfg == nil || !h.cfg.RemoteManagement.ReadOnly || readOnlyAllowedPaths[c.FullPath()] {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if !readOnlyBlockedPaths[c.FullPath()] {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":   "read_only",
+			"message": "management API is in read-only mode; PUT " + readOnlyTogglePath + " with {\"value\": false} to leave it",
+		})
+	}
+}
+
+// GetReadOnly reports whether read-only mode is on.
+func (h *Handler) GetReadOnly(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"read-only": h.cfg.RemoteManagement.ReadOnly})
+}
+
+// PutReadOnly turns read-only mode on or off.
+func (h *Handler) PutReadOnly(c *gin.Context) {
+	h.updateBoolField(c, func(v bool) { h.cfg.RemoteManagement.ReadOnly = v })
+}
+
+// GetMaintenance returns the maintenance mode settings.
+func (h *Handler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"maintenance": h.cfg.Maintenance})
+}
+
+// PutMaintenance updates the maintenance settings present in the body.
+func (h *Handler) PutMaintenance(c *gin.Context) {
+	var body struct {
+		Enable            *bool   `json:"enable"`
+		Message           *string `json:"message"`
+		RetryAfterSeconds *int    `json:"retry-after-seconds"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	maintenance := h.cfg.Maintenance
+	if body.Enable != nil {
+		maintenance.Enable = *body.Enable
+	}
+	if body.Message != nil {
+		maintenance.Message = strings.TrimSpace(*body.Message)
+	}
+	if body.RetryAfterSeconds != nil {
+		if *body.RetryAfterSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "retry-after-seconds must not be negative"})
+			return
+		}
+		maintenance.RetryAfterSeconds = *body.RetryAfterSeconds
+	}
+	h.cfg.Maintenance = maintenance
+	h.persist(c)
+}
@@ -0,0 +1,89 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestReadOnlyMiddlewareBlocksMutations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8317\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	h := &Handler{cfg: &config.Config{}, configFilePath: configPath}
+	router := gin.New()
+	mgmt := router.Group("/v0/management", h.ReadOnlyMiddleware())
+	mgmt.GET("/debug", h.GetDebug)
+	mgmt.PUT("/debug", h.PutDebug)
+	mgmt.PUT("/read-only", h.PutReadOnly)
+	oauthStarted := false
+	mgmt.GET("/anthropic-auth-url", func(c *gin.Context) { oauthStarted = true })
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := serve(http.MethodPut, "/v0/management/read-only", `{"value":true}`); rec.Code != http.StatusOK || !h.cfg.RemoteManagement.ReadOnly {
+		t.Fatalf("enable read-only: status %d, read-only %v", rec.Code, h.cfg.RemoteManagement.ReadOnly)
+	}
+	if rec := serve(http.MethodPut, "/v0/management/debug", `{"value":true}`); rec.Code != http.StatusForbidden || h.cfg.Debug {
+		t.Fatalf("mutation in read-only mode: status %d, debug %v", rec.Code, h.cfg.Debug)
+	}
+	if rec := serve(http.MethodGet, "/v0/management/debug", ""); rec.Code != http.StatusOK {
+		t.Fatalf("read in read-only mode: status %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/v0/management/anthropic-auth-url", ""); rec.Code != http.StatusForbidden || oauthStarted {
+		t.Fatalf("OAuth start in read-only mode: status %d, started %v", rec.Code, oauthStarted)
+	}
+	if rec := serve(http.MethodPut, "/v0/management/read-only", `{"value":false}`); rec.Code != http.StatusOK || h.cfg.RemoteManagement.ReadOnly {
+		t.Fatalf("disable read-only: status %d, read-only %v", rec.Code, h.cfg.RemoteManagement.ReadOnly)
+	}
+	if rec := serve(http.MethodPut, "/v0/management/debug", `{"value":true}`); rec.Code != http.StatusOK || !h.cfg.Debug {
+		t.Fatalf("mutation after read-only: status %d, debug %v", rec.Code, h.cfg.Debug)
+	}
+}
+
+func TestPutMaintenanceMergesAndPersists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8317\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	h := &Handler{cfg: &config.Config{}, configFilePath: configPath}
+	h.cfg.Maintenance.Message = "back soon"
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/v0/management/maintenance", strings.NewReader(`{"enable":true,"retry-after-seconds":60}`))
+	h.PutMaintenance(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if got := h.cfg.Maintenance; !got.Enable || got.Message != "back soon" || got.RetryAfterSeconds != 60 {
+		t.Fatalf("maintenance = %+v", got)
+	}
+	saved, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(saved), "maintenance:") || !strings.Contains(string(saved), "retry-after-seconds: 60") {
+		t.Fatalf("saved config missing maintenance:\n%s", saved)
+	}
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/v0/management/maintenance", strings.NewReader(`{"retry-after-seconds":-1}`))
+	h.PutMaintenance(c)
+	if w.Code != http.StatusBadRequest || h.cfg.Maintenance.RetryAfterSeconds != 60 {
+		t.Fatalf("negative retry-after: status %d, maintenance %+v", w.Code, h.cfg.Maintenance)
+	}
+}
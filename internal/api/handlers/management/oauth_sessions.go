@@ -1,6 +1,7 @@
 package management
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,11 +10,20 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
 	oauthSessionTTL     = 30 * time.Minute
 	maxOAuthStateLength = 128
+
+	// Shared-state keys used when replicas coordinate OAuth logins: the
+	// replica that starts a login may not be the one receiving its callback.
+	oauthSessionSharedKeyPrefix  = "oauth-session:"
+	oauthCallbackSharedKeyPrefix = "oauth-callback:"
+	oauthSharedStateTimeout      = 3 * time.Second
 )
 
 var (
@@ -22,9 +32,14 @@ var (
 	errOAuthSessionNotPending = errors.New("oauth session is not pending")
 )
 
+// oauthSessionProjectSelectionPrefix marks a session that finished OAuth and is
+// waiting for the management client to choose a Cloud project.
+const oauthSessionProjectSelectionPrefix = "project_selection|"
+
 type oauthSession struct {
 	Provider  string
 	Status    string
+	Selection string
 	CreatedAt time.Time
 	ExpiresAt time.Time
 }
@@ -65,12 +80,12 @@ func (s *oauthSessionStore) Register(state, provider string) {
 	defer s.mu.Unlock()
 
 	s.purgeExpiredLocked(now)
-	s.sessions[state] = oauthSession{
+	s.storeLocked(state, oauthSession{
 		Provider:  provider,
 		Status:    "",
 		CreatedAt: now,
 		ExpiresAt: now.Add(s.ttl),
-	}
+	})
 }
 
 func (s *oauthSessionStore) SetError(state, message string) {
@@ -88,13 +103,56 @@ func (s *oauthSessionStore) SetError(state, message string) {
 	defer s.mu.Unlock()
 
 	s.purgeExpiredLocked(now)
-	session, ok := s.sessions[state]
+	session, ok := s.loadLocked(state)
 	if !ok {
 		return
 	}
 	session.Status = message
 	session.ExpiresAt = now.Add(s.ttl)
-	s.sessions[state] = session
+	s.storeLocked(state, session)
+}
+
+// SetSelection records the value chosen for a session that is waiting in the
+// project selection step. It returns false when the session is not waiting.
+func (s *oauthSessionStore) SetSelection(state, value string) bool {
+	state = strings.TrimSpace(state)
+	value = strings.TrimSpace(value)
+	if state == "" || value == "" {
+		return false
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpiredLocked(now)
+	session, ok := s.loadLocked(state)
+	if !ok || !strings.HasPrefix(session.Status, oauthSessionProjectSelectionPrefix) {
+		return false
+	}
+	session.Selection = value
+	s.storeLocked(state, session)
+	return true
+}
+
+// TakeSelection returns and clears the value recorded by SetSelection.
+func (s *oauthSessionStore) TakeSelection(state string) string {
+	state = strings.TrimSpace(state)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpiredLocked(now)
+	session, ok := s.loadLocked(state)
+	if !ok || session.Selection == "" {
+		return ""
+	}
+	value := session.Selection
+	session.Selection = ""
+	session.Status = ""
+	s.storeLocked(state, session)
+	return value
 }
 
 func (s *oauthSessionStore) Complete(state string) {
@@ -108,7 +166,7 @@ func (s *oauthSessionStore) Complete(state string) {
 	defer s.mu.Unlock()
 
 	s.purgeExpiredLocked(now)
-	delete(s.sessions, state)
+	s.deleteLocked(state)
 }
 
 func (s *oauthSessionStore) CompleteProvider(provider string) int {
@@ -125,7 +183,7 @@ func (s *oauthSessionStore) CompleteProvider(provider string) int {
 	removed := 0
 	for state, session := range s.sessions {
 		if strings.EqualFold(session.Provider, provider) {
-			delete(s.sessions, state)
+			s.deleteLocked(state)
 			removed++
 		}
 	}
@@ -140,8 +198,7 @@ func (s *oauthSessionStore) Get(state string) (oauthSession, bool) {
 	defer s.mu.Unlock()
 
 	s.purgeExpiredLocked(now)
-	session, ok := s.sessions[state]
-	return session, ok
+	return s.loadLocked(state)
 }
 
 func (s *oauthSessionStore) IsPending(state, provider string) bool {
@@ -153,11 +210,11 @@ func (s *oauthSessionStore) IsPending(state, provider string) bool {
 	defer s.mu.Unlock()
 
 	s.purgeExpiredLocked(now)
-	session, ok := s.sessions[state]
+	session, ok := s.loadLocked(state)
 	if !ok {
 		return false
 	}
-	if session.Status != "" {
+	if session.Status != "" && !strings.HasPrefix(session.Status, oauthSessionProjectSelectionPrefix) {
 		if !strings.EqualFold(session.Provider, "kiro") {
 			return false
 		}
@@ -171,6 +228,53 @@ func (s *oauthSessionStore) IsPending(state, provider string) bool {
 	return strings.EqualFold(session.Provider, provider)
 }
 
+// loadLocked returns the session for state. With a distributed shared state
+// the shared copy wins, since another replica may have updated it; the local
+// map is only consulted when the shared store is unreachable.
+func (s *oauthSessionStore) loadLocked(state string) (oauthSession, bool) {
+	if sharedstate.Distributed() {
+		ctx, cancel := context.WithTimeout(context.Background(), oauthSharedStateTimeout)
+		defer cancel()
+		var session oauthSession
+		ok, errGet := sharedstate.GetJSON(ctx, oauthSessionSharedKeyPrefix+state, &session)
+		if errGet == nil {
+			if ok {
+				s.sessions[state] = session
+			} else {
+				delete(s.sessions, state)
+			}
+			return session, ok
+		}
+		log.Warnf("oauth session: shared state read failed, using local copy: %v", errGet)
+	}
+	session, ok := s.sessions[state]
+	return session, ok
+}
+
+func (s *oauthSessionStore) storeLocked(state string, session oauthSession) {
+	s.sessions[state] = session
+	if !sharedstate.Distributed() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), oauthSharedStateTimeout)
+	defer cancel()
+	if errSet := sharedstate.SetJSON(ctx, oauthSessionSharedKeyPrefix+state, session, time.Until(session.ExpiresAt)); errSet != nil {
+		log.Warnf("oauth session: shared state write failed: %v", errSet)
+	}
+}
+
+func (s *oauthSessionStore) deleteLocked(state string) {
+	delete(s.sessions, state)
+	if !sharedstate.Distributed() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), oauthSharedStateTimeout)
+	defer cancel()
+	if errDelete := sharedstate.Current().Delete(ctx, oauthSessionSharedKeyPrefix+state); errDelete != nil {
+		log.Warnf("oauth session: shared state delete failed: %v", errDelete)
+	}
+}
+
 var oauthSessions = newOAuthSessionStore(oauthSessionTTL)
 
 func RegisterOAuthSession(state, provider string) { oauthSessions.Register(state, provider) }
@@ -183,6 +287,18 @@ func CompleteOAuthSessionsByProvider(provider string) int {
 	return oauthSessions.CompleteProvider(provider)
 }
 
+// SetOAuthSessionProjectSelection moves a session into the project selection
+// step, publishing the JSON-encoded choices through the auth status endpoint.
+func SetOAuthSessionProjectSelection(state string, choices []byte) {
+	oauthSessions.SetError(state, oauthSessionProjectSelectionPrefix+string(choices))
+}
+
+func SetOAuthSessionSelection(state, value string) bool {
+	return oauthSessions.SetSelection(state, value)
+}
+
+func TakeOAuthSessionSelection(state string) string { return oauthSessions.TakeSelection(state) }
+
 func GetOAuthSession(state string) (provider string, status string, ok bool) {
 	session, ok := oauthSessions.Get(state)
 	if !ok {
@@ -279,9 +395,39 @@ func WriteOAuthCallbackFile(authDir, provider, state, code, errorMessage string)
 	if err := os.WriteFile(filePath, data, 0o600); err != nil {
 		return "", fmt.Errorf("write oauth callback file: %w", err)
 	}
+	if sharedstate.Distributed() {
+		// The replica waiting for this callback may not share our auth dir.
+		ctx, cancel := context.WithTimeout(context.Background(), oauthSharedStateTimeout)
+		defer cancel()
+		if errSet := sharedstate.Current().Set(ctx, oauthCallbackSharedKeyPrefix+fileName, data, oauthSessionTTL); errSet != nil {
+			log.Warnf("oauth callback: shared state write failed: %v", errSet)
+		}
+	}
 	return filePath, nil
 }
 
+// readOAuthCallbackFile returns the callback payload written by
+// WriteOAuthCallbackFile, from the local auth dir or, when replicas share
+// state, from whichever replica received the callback.
+func readOAuthCallbackFile(path string) ([]byte, error) {
+	data, errRead := os.ReadFile(path)
+	if !sharedstate.Distributed() {
+		return data, errRead
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), oauthSharedStateTimeout)
+	defer cancel()
+	key := oauthCallbackSharedKeyPrefix + filepath.Base(path)
+	if errRead == nil {
+		_ = sharedstate.Current().Delete(ctx, key)
+		return data, nil
+	}
+	shared, ok, errTake := sharedstate.Current().Take(ctx, key)
+	if errTake != nil || !ok {
+		return nil, errRead
+	}
+	return shared, nil
+}
+
 func WriteOAuthCallbackFileForPendingSession(authDir, provider, state, code, errorMessage string) (string, error) {
 	canonicalProvider, err := NormalizeOAuthProvider(provider)
 	if err != nil {
@@ -0,0 +1,100 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// GetPromptTemplates lists the prompt templates, or the one named by the
+// name query parameter.
+func (h *Handler) GetPromptTemplates(c *gin.Context) {
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		c.JSON(http.StatusOK, gin.H{"prompt-templates": h.cfg.PromptTemplates})
+		return
+	}
+	for _, tmpl := range h.cfg.PromptTemplates {
+		if strings.EqualFold(tmpl.Name, name) {
+			c.JSON(http.StatusOK, tmpl)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "prompt template not found"})
+}
+
+// PutPromptTemplates replaces every prompt template. The body is an array of
+// templates or {"items": [...]}.
+func (h *Handler) PutPromptTemplates(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	var arr []config.PromptTemplate
+	if err = json.Unmarshal(data, &arr); err != nil {
+		var obj struct {
+			Items []config.PromptTemplate `json:"items"`
+		}
+		if err2 := json.Unmarshal(data, &obj); err2 != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+		arr = obj.Items
+	}
+	h.cfg.PromptTemplates = arr
+	h.cfg.SanitizePromptTemplates()
+	h.persist(c)
+}
+
+// PatchPromptTemplate creates the template in the body, or replaces the one
+// with the same name.
+func (h *Handler) PatchPromptTemplate(c *gin.Context) {
+	var tmpl config.PromptTemplate
+	if err := c.ShouldBindJSON(&tmpl); err != nil || strings.TrimSpace(tmpl.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	tmpl.Name = strings.TrimSpace(tmpl.Name)
+	if strings.TrimSpace(tmpl.System) == "" && len(tmpl.Messages) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template needs a system prompt or messages"})
+		return
+	}
+	replaced := false
+	for i := range h.cfg.PromptTemplates {
+		if strings.EqualFold(h.cfg.PromptTemplates[i].Name, tmpl.Name) {
+			h.cfg.PromptTemplates[i] = tmpl
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		h.cfg.PromptTemplates = append(h.cfg.PromptTemplates, tmpl)
+	}
+	h.cfg.SanitizePromptTemplates()
+	h.persist(c)
+}
+
+// DeletePromptTemplate removes the template named by the name query parameter.
+func (h *Handler) DeletePromptTemplate(c *gin.Context) {
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing name"})
+		return
+	}
+	out := make([]config.PromptTemplate, 0, len(h.cfg.PromptTemplates))
+	for _, tmpl := range h.cfg.PromptTemplates {
+		if !strings.EqualFold(tmpl.Name, name) {
+			out = append(out, tmpl)
+		}
+	}
+	if len(out) == len(h.cfg.PromptTemplates) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "prompt template not found"})
+		return
+	}
+	h.cfg.PromptTemplates = out
+	h.persist(c)
+}
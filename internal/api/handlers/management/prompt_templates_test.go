@@ -0,0 +1,63 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestPromptTemplatesCRUD(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8317\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	h := &Handler{cfg: &config.Config{}, configFilePath: configPath}
+	router := gin.New()
+	router.GET("/prompt-templates", h.GetPromptTemplates)
+	router.PUT("/prompt-templates", h.PutPromptTemplates)
+	router.PATCH("/prompt-templates", h.PatchPromptTemplate)
+	router.DELETE("/prompt-templates", h.DeletePromptTemplate)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := serve(http.MethodPatch, "/prompt-templates", `{"name":"review","system":"Review {{lang}} code.","messages":[{"role":"User","content":"Be strict."}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(http.MethodPatch, "/prompt-templates", `{"name":"REVIEW","system":"Review {{lang}} code carefully."}`); rec.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(h.cfg.PromptTemplates) != 1 || h.cfg.PromptTemplates[0].System != "Review {{lang}} code carefully." {
+		t.Fatalf("templates after update = %+v", h.cfg.PromptTemplates)
+	}
+	if rec := serve(http.MethodPatch, "/prompt-templates", `{"name":"empty"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("template without prompt: status %d", rec.Code)
+	}
+	if rec := serve(http.MethodGet, "/prompt-templates?name=review", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "carefully") {
+		t.Fatalf("get: status %d: %s", rec.Code, rec.Body.String())
+	}
+	saved, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(saved), "prompt-templates:") {
+		t.Fatalf("templates not persisted:\n%s", saved)
+	}
+
+	if rec := serve(http.MethodDelete, "/prompt-templates?name=missing", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("delete missing: status %d", rec.Code)
+	}
+	if rec := serve(http.MethodDelete, "/prompt-templates?name=review", ""); rec.Code != http.StatusOK || len(h.cfg.PromptTemplates) != 0 {
+		t.Fatalf("delete: status %d, templates %+v", rec.Code, h.cfg.PromptTemplates)
+	}
+	if rec := serve(http.MethodGet, "/prompt-templates?name=review", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("get deleted: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,24 @@
+package management
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetQuotaHeadroom returns the latest quota each credential's upstream
+// reported, keyed by auth ID, along with the share left of the tightest window.
+func (h *Handler) GetQuotaHeadroom(c *gin.Context) {
+	now := time.Now()
+	type entry struct {
+		coreauth.QuotaHeadroom
+		RemainingFraction float64 `json:"remaining_fraction"`
+	}
+	out := make(map[string]entry)
+	for id, headroom := range coreauth.QuotaHeadrooms() {
+		out[id] = entry{QuotaHeadroom: headroom, RemainingFraction: headroom.RemainingFraction(now)}
+	}
+	c.JSON(http.StatusOK, gin.H{"quota_headroom": out})
+}
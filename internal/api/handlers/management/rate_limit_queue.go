@@ -0,0 +1,18 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// GetRateLimitQueue returns, per client API key, the requests waiting in the
+// rate-limit queue and how long queued requests have waited.
+func (h *Handler) GetRateLimitQueue(c *gin.Context) {
+	stats := h.authManager.RateLimitQueueStats()
+	if stats == nil {
+		stats = []coreauth.RateLimitQueueClientStats{}
+	}
+	c.JSON(http.StatusOK, gin.H{"rate_limit_queue": stats})
+}
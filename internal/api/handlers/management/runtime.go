@@ -0,0 +1,31 @@
+package management
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/memguard"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/proxyutil"
+)
+
+// GetRuntimeStats reports the goroutine count, the client and upstream
+// streams in flight and the outbound connection counters, so goroutine or
+// connection leaks show up as values that keep growing while idle. It also
+// reports memory use against the memory limit and whether requests are being
+// shed under memory pressure.
+func (h *Handler) GetRuntimeStats(c *gin.Context) {
+	limit, _ := memguard.Limit()
+	c.JSON(http.StatusOK, gin.H{
+		"goroutines":       runtime.NumGoroutine(),
+		"gomaxprocs":       runtime.GOMAXPROCS(0),
+		"client-streams":   len(handlers.ActiveStreamStats()),
+		"upstream-streams": executor.ActiveStreams(),
+		"connections":      proxyutil.Connections(),
+		"memory-bytes":     memguard.Usage(),
+		"memory-limit":     limit,
+		"memory-pressure":  memguard.UnderPressure(),
+	})
+}
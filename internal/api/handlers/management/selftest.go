@@ -0,0 +1,313 @@
+package management
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	selftestPass = "pass"
+	selftestFail = "fail"
+	selftestSkip = "skip"
+
+	// selftestCheckTimeout bounds each live check so one hung upstream does
+	// not stall the whole report.
+	selftestCheckTimeout = 90 * time.Second
+)
+
+// selftestImage is a 1x1 red PNG, the smallest image every vision model accepts.
+const selftestImage = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8DwHwAFBQIAX8jx0gAAAABJRU5ErkJggg=="
+
+type selftestCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// PostProviderSelftest runs live conformance checks against one credential of
+// a provider: basic chat, streaming, tool calling, vision when the model takes
+// images, and every thinking level the model advertises. The credential is
+// chosen by ?auth= (ID or file name) and the model by ?model=; both default to
+// the first available match. Failed checks are reported, not returned as
+// errors, so the response is 200 whenever the checks could run.
+func (h *Handler) PostProviderSelftest(c *gin.Context) {
+	provider := strings.ToLower(strings.TrimSpace(c.Param("provider")))
+	if provider == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provider is required"})
+		return
+	}
+	if h == nil || h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	auth := h.selftestAuth(provider, strings.TrimSpace(c.Query("auth")))
+	if auth == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no enabled %s credential found", provider)})
+		return
+	}
+	model := strings.TrimSpace(c.Query("model"))
+	if model == "" {
+		model = selftestModel(auth.ID)
+	}
+	if model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "credential has no registered models; pass ?model="})
+		return
+	}
+	info := registry.GetGlobalRegistry().ClientModelInfo(auth.ID, model)
+	if info == nil {
+		info = registry.LookupModelInfo(model, provider)
+	}
+
+	started := time.Now()
+	runner := selftestRunner{manager: h.authManager, provider: provider, authID: auth.ID, model: model}
+	checks := []selftestCheck{
+		runner.run(c.Request.Context(), "chat", runner.chat),
+		runner.run(c.Request.Context(), "stream", runner.stream),
+		runner.run(c.Request.Context(), "tool_call", runner.toolCall),
+	}
+	if vision, reason := selftestVisionSupport(info); vision {
+		checks = append(checks, runner.run(c.Request.Context(), "vision", runner.vision))
+	} else {
+		checks = append(checks, selftestCheck{Name: "vision", Status: selftestSkip, Detail: reason})
+	}
+	levels := selftestThinkingLevels(info)
+	if len(levels) == 0 {
+		checks = append(checks, selftestCheck{Name: "thinking", Status: selftestSkip, Detail: "model does not advertise thinking support"})
+	}
+	for _, level := range levels {
+		checks = append(checks, runner.run(c.Request.Context(), "thinking:"+level, func(ctx context.Context) (string, error) {
+			return runner.thinking(ctx, level)
+		}))
+	}
+
+	summary := map[string]int{selftestPass: 0, selftestFail: 0, selftestSkip: 0}
+	for _, check := range checks {
+		summary[check.Status]++
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"provider":    provider,
+		"auth_id":     auth.ID,
+		"model":       model,
+		"started_at":  started.UTC().Format(time.RFC3339),
+		"duration_ms": time.Since(started).Milliseconds(),
+		"passed":      summary[selftestFail] == 0,
+		"summary":     summary,
+		"checks":      checks,
+	})
+}
+
+// selftestAuth returns the requested credential of provider, or the first
+// enabled one by ID when selector is empty.
+func (h *Handler) selftestAuth(provider, selector string) *coreauth.Auth {
+	auths := h.authManager.List()
+	sort.Slice(auths, func(i, j int) bool { return auths[i].ID < auths[j].ID })
+	for _, auth := range auths {
+		if auth == nil || auth.Disabled || !strings.EqualFold(auth.Provider, provider) {
+			continue
+		}
+		if selector == "" || auth.ID == selector || auth.FileName == selector {
+			return auth
+		}
+	}
+	return nil
+}
+
+// selftestModel picks the first registered chat model of a credential,
+// passing over models that only produce audio or images or that cannot
+// generate content at all, such as embedding models.
+func selftestModel(authID string) string {
+	models := registry.GetGlobalRegistry().GetModelsForClient(authID)
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	for _, model := range models {
+		if model == nil || model.ID == "" {
+			continue
+		}
+		if len(model.SupportedOutputModalities) > 0 && !containsFold(model.SupportedOutputModalities, "TEXT") {
+			continue
+		}
+		if len(model.SupportedGenerationMethods) > 0 && !containsFold(model.SupportedGenerationMethods, "generateContent") {
+			continue
+		}
+		return model.ID
+	}
+	return ""
+}
+
+func selftestVisionSupport(info *registry.ModelInfo) (bool, string) {
+	if info == nil || len(info.SupportedInputModalities) == 0 {
+		return true, ""
+	}
+	if containsFold(info.SupportedInputModalities, "IMAGE") {
+		return true, ""
+	}
+	return false, "model does not accept image input"
+}
+
+// selftestThinkingLevels lists the reasoning efforts to exercise: the model's
+// discrete levels, or low and high for budget-based thinking.
+func selftestThinkingLevels(info *registry.ModelInfo) []string {
+	if info == nil || info.Thinking == nil {
+		return nil
+	}
+	if len(info.Thinking.Levels) > 0 {
+		return append([]string(nil), info.Thinking.Levels...)
+	}
+	if info.Thinking.Max > 0 {
+		return []string{"low", "high"}
+	}
+	return nil
+}
+
+func containsFold(values []string, want string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, want) {
+			return true
+		}
+	}
+	return false
+}
+
+type selftestRunner struct {
+	manager  *coreauth.Manager
+	provider string
+	authID   string
+	model    string
+}
+
+func (r selftestRunner) run(parent context.Context, name string, check func(context.Context) (string, error)) selftestCheck {
+	ctx, cancel := context.WithTimeout(parent, selftestCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	detail, err := check(ctx)
+	result := selftestCheck{Name: name, Status: selftestPass, DurationMS: time.Since(start).Milliseconds(), Detail: detail}
+	if err != nil {
+		result.Status = selftestFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (r selftestRunner) request(payload string, stream bool) (cliproxyexecutor.Request, cliproxyexecutor.Options) {
+	body, _ := sjson.SetBytes([]byte(payload), "model", r.model)
+	if stream {
+		body, _ = sjson.SetBytes(body, "stream", true)
+	}
+	opts := cliproxyexecutor.Options{
+		Stream:          stream,
+		OriginalRequest: body,
+		SourceFormat:    sdktranslator.FromString("openai"),
+		Metadata: map[string]any{
+			cliproxyexecutor.PinnedAuthMetadataKey:     r.authID,
+			cliproxyexecutor.RequestedModelMetadataKey: r.model,
+		},
+	}
+	return cliproxyexecutor.Request{Model: r.model, Payload: body}, opts
+}
+
+func (r selftestRunner) execute(ctx context.Context, payload string) (gjson.Result, error) {
+	req, opts := r.request(payload, false)
+	resp, err := r.manager.Execute(ctx, []string{r.provider}, req, opts)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	if !gjson.ValidBytes(resp.Payload) {
+		return gjson.Result{}, fmt.Errorf("response is not valid JSON")
+	}
+	return gjson.ParseBytes(resp.Payload), nil
+}
+
+func (r selftestRunner) chat(ctx context.Context) (string, error) {
+	out, err := r.execute(ctx, `{"messages":[{"role":"user","content":"Reply with the single word OK."}],"max_tokens":64}`)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(out.Get("choices.0.message.content").String())
+	if text == "" {
+		return "", fmt.Errorf("response has no message content")
+	}
+	return fmt.Sprintf("%d completion tokens", out.Get("usage.completion_tokens").Int()), nil
+}
+
+func (r selftestRunner) stream(ctx context.Context) (string, error) {
+	req, opts := r.request(`{"messages":[{"role":"user","content":"Count from 1 to 5."}],"max_tokens":64}`, true)
+	result, err := r.manager.ExecuteStream(ctx, []string{r.provider}, req, opts)
+	if err != nil {
+		return "", err
+	}
+	chunks, deltas := 0, 0
+	var first time.Duration
+	start := time.Now()
+	for chunk := range result.Chunks {
+		if chunk.Err != nil {
+			return "", chunk.Err
+		}
+		chunks++
+		for _, line := range strings.Split(string(chunk.Payload), "\n") {
+			line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "data:"))
+			if gjson.Get(line, "choices.0.delta.content").String() != "" {
+				if deltas == 0 {
+					first = time.Since(start)
+				}
+				deltas++
+			}
+		}
+	}
+	if deltas == 0 {
+		return "", fmt.Errorf("stream produced %d chunks without content deltas", chunks)
+	}
+	return fmt.Sprintf("%d content deltas, first after %dms", deltas, first.Milliseconds()), nil
+}
+
+func (r selftestRunner) toolCall(ctx context.Context) (string, error) {
+	out, err := r.execute(ctx, `{"messages":[{"role":"user","content":"What is the weather in Paris? Use the get_weather tool."}],"max_tokens":256,"tools":[{"type":"function","function":{"name":"get_weather","description":"Get the current weather for a city","parameters":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}}]}`)
+	if err != nil {
+		return "", err
+	}
+	call := out.Get("choices.0.message.tool_calls.0")
+	if call.Get("function.name").String() != "get_weather" {
+		return "", fmt.Errorf("response has no get_weather tool call (finish_reason %q)", out.Get("choices.0.finish_reason").String())
+	}
+	if args := call.Get("function.arguments").String(); !gjson.Valid(args) {
+		return "", fmt.Errorf("tool call arguments are not valid JSON: %s", args)
+	}
+	return "arguments " + call.Get("function.arguments").String(), nil
+}
+
+func (r selftestRunner) vision(ctx context.Context) (string, error) {
+	payload, _ := sjson.Set(`{"messages":[{"role":"user","content":[{"type":"text","text":"What color is this image? Answer in one word."},{"type":"image_url","image_url":{"url":""}}]}],"max_tokens":64}`, "messages.0.content.1.image_url.url", selftestImage)
+	out, err := r.execute(ctx, payload)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(out.Get("choices.0.message.content").String())
+	if text == "" {
+		return "", fmt.Errorf("response has no message content")
+	}
+	return "answered " + text, nil
+}
+
+func (r selftestRunner) thinking(ctx context.Context, level string) (string, error) {
+	payload, _ := sjson.Set(`{"messages":[{"role":"user","content":"What is 17 * 23?"}],"max_tokens":2048}`, "reasoning_effort", level)
+	out, err := r.execute(ctx, payload)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(out.Get("choices.0.message.content").String()) == "" {
+		return "", fmt.Errorf("response has no message content")
+	}
+	return fmt.Sprintf("%d reasoning tokens", out.Get("usage.completion_tokens_details.reasoning_tokens").Int()), nil
+}
@@ -0,0 +1,124 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// selftestExecutor answers like an OpenAI-compatible upstream, except that it
+// rejects high reasoning effort.
+type selftestExecutor struct{}
+
+func (selftestExecutor) Identifier() string { return "selftest-provider" }
+
+func (selftestExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	if gjson.GetBytes(req.Payload, "reasoning_effort").String() == "high" {
+		return coreexecutor.Response{}, &coreauth.Error{Code: "invalid_request", Message: "reasoning effort high is not supported", HTTPStatus: http.StatusBadRequest}
+	}
+	if gjson.GetBytes(req.Payload, "tools").Exists() {
+		return coreexecutor.Response{Payload: []byte(`{"choices":[{"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}`)}, nil
+	}
+	return coreexecutor.Response{Payload: []byte(`{"choices":[{"message":{"role":"assistant","content":"OK"},"finish_reason":"stop"}],"usage":{"completion_tokens":1}}`)}, nil
+}
+
+func (selftestExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (*coreexecutor.StreamResult, error) {
+	ch := make(chan coreexecutor.StreamChunk, 2)
+	ch <- coreexecutor.StreamChunk{Payload: []byte(`{"choices":[{"delta":{"content":"1 2"}}]}`)}
+	ch <- coreexecutor.StreamChunk{Payload: []byte(`{"choices":[{"delta":{"content":" 3 4 5"},"finish_reason":"stop"}]}`)}
+	close(ch)
+	return &coreexecutor.StreamResult{Chunks: ch}, nil
+}
+
+func (selftestExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (selftestExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (selftestExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func TestPostProviderSelftestReportsEachCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(selftestExecutor{})
+	auth := &coreauth.Auth{ID: "selftest-auth", Provider: "selftest-provider", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{
+		ID:                       "selftest-model",
+		SupportedInputModalities: []string{"TEXT"},
+		Thinking:                 &registry.ThinkingSupport{Levels: []string{"low", "high"}},
+	}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	h := NewHandlerWithoutConfigFilePath(&config.Config{}, manager)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v0/management/selftest/selftest-provider", nil)
+	c.Params = gin.Params{{Key: "provider", Value: "selftest-provider"}}
+	h.PostProviderSelftest(c)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	var report struct {
+		AuthID  string          `json:"auth_id"`
+		Model   string          `json:"model"`
+		Passed  bool            `json:"passed"`
+		Summary map[string]int  `json:"summary"`
+		Checks  []selftestCheck `json:"checks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.AuthID != auth.ID || report.Model != "selftest-model" || report.Passed {
+		t.Fatalf("report = %+v", report)
+	}
+	want := map[string]string{
+		"chat":          selftestPass,
+		"stream":        selftestPass,
+		"tool_call":     selftestPass,
+		"vision":        selftestSkip,
+		"thinking:low":  selftestPass,
+		"thinking:high": selftestFail,
+	}
+	if len(report.Checks) != len(want) {
+		t.Fatalf("checks = %+v", report.Checks)
+	}
+	for _, check := range report.Checks {
+		if want[check.Name] != check.Status {
+			t.Errorf("check %s = %s (%s), want %s", check.Name, check.Status, check.Error, want[check.Name])
+		}
+	}
+	if report.Summary[selftestPass] != 4 || report.Summary[selftestFail] != 1 || report.Summary[selftestSkip] != 1 {
+		t.Fatalf("summary = %v", report.Summary)
+	}
+}
+
+func TestPostProviderSelftestUnknownProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandlerWithoutConfigFilePath(&config.Config{}, coreauth.NewManager(nil, nil, nil))
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v0/management/selftest/nobody", nil)
+	c.Params = gin.Params{{Key: "provider", Value: "nobody"}}
+	h.PostProviderSelftest(c)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
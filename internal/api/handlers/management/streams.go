@@ -0,0 +1,19 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// GetActiveStreams lists the client streams currently being forwarded with
+// their write queue metrics: queue depth, frames and bytes written, dropped
+// keep-alives and time spent waiting on slow clients.
+func (h *Handler) GetActiveStreams(c *gin.Context) {
+	streams := handlers.ActiveStreamStats()
+	if streams == nil {
+		streams = []handlers.StreamStats{}
+	}
+	c.JSON(http.StatusOK, gin.H{"streams": streams})
+}
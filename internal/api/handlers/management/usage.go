@@ -3,10 +3,13 @@ package management
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage/anomaly"
 )
 
 type usageExportPayload struct {
@@ -77,3 +80,33 @@ func (h *Handler) ImportUsageStatistics(c *gin.Context) {
 		"failed_requests": snapshot.FailureCount,
 	})
 }
+
+// GetUsageAnomalies lists the API keys currently flagged by the usage anomaly detector.
+func (h *Handler) GetUsageAnomalies(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"anomalies": anomaly.Default().Flags()})
+}
+
+// DeleteUsageAnomaly clears the flag on an API key. The optional
+// exempt-minutes query parameter also suppresses new flags for that long.
+func (h *Handler) DeleteUsageAnomaly(c *gin.Context) {
+	apiKey := strings.TrimSpace(c.Query("api-key"))
+	if apiKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "api-key is required"})
+		return
+	}
+	var exempt time.Duration
+	if raw := strings.TrimSpace(c.Query("exempt-minutes")); raw != "" {
+		minutes, errParse := strconv.Atoi(raw)
+		if errParse != nil || minutes < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid exempt-minutes"})
+			return
+		}
+		exempt = time.Duration(minutes) * time.Minute
+	}
+	cleared := anomaly.Default().Clear(apiKey, exempt)
+	if !cleared && exempt == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key is not flagged"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "cleared": cleared})
+}
@@ -13,7 +13,9 @@ import (
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
-// ImportVertexCredential handles uploading a Vertex service account JSON and saving it as an auth record.
+// ImportVertexCredential handles uploading a Vertex service account or gcloud authorized_user
+// JSON and saving it as an auth record. Optional project_id and location form fields
+// override the values derived from the uploaded file.
 func (h *Handler) ImportVertexCredential(c *gin.Context) {
 	if h == nil || h.cfg == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "config unavailable"})
@@ -49,6 +51,16 @@ func (h *Handler) ImportVertexCredential(c *gin.Context) {
 		return
 	}
 
+	location := vertexFormValue(c, "location")
+	if location == "" {
+		location = "us-central1"
+	}
+
+	if strings.EqualFold(strings.TrimSpace(valueAsString(serviceAccount["type"])), vertex.CredentialSourceAuthorizedUser) {
+		h.saveVertexAuthorizedUser(c, serviceAccount, location)
+		return
+	}
+
 	normalizedSA, err := vertex.NormalizeServiceAccountMap(serviceAccount)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service account", "message": err.Error()})
@@ -56,23 +68,16 @@ func (h *Handler) ImportVertexCredential(c *gin.Context) {
 	}
 	serviceAccount = normalizedSA
 
-	projectID := strings.TrimSpace(valueAsString(serviceAccount["project_id"]))
+	projectID := vertexFormValue(c, "project_id")
+	if projectID == "" {
+		projectID = strings.TrimSpace(valueAsString(serviceAccount["project_id"]))
+	}
 	if projectID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "project_id missing"})
 		return
 	}
 	email := strings.TrimSpace(valueAsString(serviceAccount["client_email"]))
 
-	location := strings.TrimSpace(c.PostForm("location"))
-	if location == "" {
-		location = strings.TrimSpace(c.Query("location"))
-	}
-	if location == "" {
-		location = "us-central1"
-	}
-
-	fileName := fmt.Sprintf("vertex-%s.json", sanitizeVertexFilePart(projectID))
-	label := labelForVertex(projectID, email)
 	storage := &vertex.VertexCredentialStorage{
 		ServiceAccount: serviceAccount,
 		ProjectID:      projectID,
@@ -86,8 +91,99 @@ func (h *Handler) ImportVertexCredential(c *gin.Context) {
 		"email":           email,
 		"location":        location,
 		"type":            "vertex",
-		"label":           label,
 	}
+	h.saveVertexRecord(c, fmt.Sprintf("vertex-%s.json", sanitizeVertexFilePart(projectID)), storage, metadata)
+}
+
+// saveVertexAuthorizedUser stores gcloud authorized_user (user OAuth) credentials.
+// The refresh token is exchanged for access tokens on demand by the executor.
+func (h *Handler) saveVertexAuthorizedUser(c *gin.Context, user map[string]any, location string) {
+	if strings.TrimSpace(valueAsString(user["refresh_token"])) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid authorized user", "message": "refresh_token missing"})
+		return
+	}
+	projectID := vertexFormValue(c, "project_id")
+	if projectID == "" {
+		projectID = strings.TrimSpace(valueAsString(user["quota_project_id"]))
+	}
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "project_id missing"})
+		return
+	}
+	storage := &vertex.VertexCredentialStorage{
+		AuthorizedUser:   user,
+		CredentialSource: vertex.CredentialSourceAuthorizedUser,
+		ProjectID:        projectID,
+		Location:         location,
+		Type:             "vertex",
+	}
+	metadata := map[string]any{
+		"authorized_user":   user,
+		"credential_source": vertex.CredentialSourceAuthorizedUser,
+		"project_id":        projectID,
+		"location":          location,
+		"type":              "vertex",
+	}
+	h.saveVertexRecord(c, fmt.Sprintf("vertex-user-%s.json", sanitizeVertexFilePart(projectID)), storage, metadata)
+}
+
+// ImportVertexWorkloadIdentity registers a Vertex credential that obtains tokens
+// from the GCE/GKE metadata server. project_id is optional and is discovered from
+// the metadata server at request time when omitted.
+func (h *Handler) ImportVertexWorkloadIdentity(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "config unavailable"})
+		return
+	}
+	if h.cfg.AuthDir == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "auth directory not configured"})
+		return
+	}
+
+	var body struct {
+		ProjectID string `json:"project_id"`
+		Location  string `json:"location"`
+		Name      string `json:"name"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+	}
+	projectID := strings.TrimSpace(body.ProjectID)
+	location := strings.TrimSpace(body.Location)
+	if location == "" {
+		location = "us-central1"
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		name = projectID
+	}
+	if name == "" {
+		name = "default"
+	}
+
+	storage := &vertex.VertexCredentialStorage{
+		CredentialSource: vertex.CredentialSourceWorkloadIdentity,
+		ProjectID:        projectID,
+		Location:         location,
+		Type:             "vertex",
+	}
+	metadata := map[string]any{
+		"credential_source": vertex.CredentialSourceWorkloadIdentity,
+		"location":          location,
+		"type":              "vertex",
+	}
+	if projectID != "" {
+		metadata["project_id"] = projectID
+	}
+	h.saveVertexRecord(c, fmt.Sprintf("vertex-wi-%s.json", sanitizeVertexFilePart(name)), storage, metadata)
+}
+
+func (h *Handler) saveVertexRecord(c *gin.Context, fileName string, storage *vertex.VertexCredentialStorage, metadata map[string]any) {
+	label := labelForVertex(storage.ProjectID, storage.Email)
+	metadata["label"] = label
 	record := &coreauth.Auth{
 		ID:       fileName,
 		Provider: "vertex",
@@ -107,15 +203,27 @@ func (h *Handler) ImportVertexCredential(c *gin.Context) {
 		return
 	}
 
+	credentialSource := storage.CredentialSource
+	if credentialSource == "" {
+		credentialSource = vertex.CredentialSourceServiceAccount
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"status":     "ok",
-		"auth-file":  savedPath,
-		"project_id": projectID,
-		"email":      email,
-		"location":   location,
+		"status":            "ok",
+		"auth-file":         savedPath,
+		"project_id":        storage.ProjectID,
+		"email":             storage.Email,
+		"location":          storage.Location,
+		"credential_source": credentialSource,
 	})
 }
 
+func vertexFormValue(c *gin.Context, key string) string {
+	if value := strings.TrimSpace(c.PostForm(key)); value != "" {
+		return value
+	}
+	return strings.TrimSpace(c.Query(key))
+}
+
 func valueAsString(v any) string {
 	if v == nil {
 		return ""
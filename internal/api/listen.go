@@ -0,0 +1,193 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/proxyproto"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// systemdListenFDsStart is the first descriptor passed by systemd
+	// (SD_LISTEN_FDS_START).
+	systemdListenFDsStart = 3
+
+	defaultUnixSocketMode os.FileMode = 0o660
+)
+
+// openListeners opens every listener the server serves: the WithListener
+// listener, the systemd-activated sockets or host:port, plus the configured
+// Unix socket. TCP listeners expect a PROXY protocol header when
+// client-ip.proxy-protocol is set.
+func (s *Server) openListeners() ([]net.Listener, error) {
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+	}
+	var listenCfg config.ListenConfig
+	var proxyProtocol bool
+	if s.cfg != nil {
+		listenCfg = s.cfg.Listen
+		proxyProtocol = s.cfg.ClientIP.ProxyProtocol
+	}
+
+	if s.listener != nil {
+		listeners = append(listeners, s.listener)
+	} else {
+		if listenCfg.SystemdActivation {
+			activated, err := systemdListeners()
+			if err != nil {
+				return nil, err
+			}
+			if len(activated) == 0 {
+				log.Infof("systemd socket activation enabled but no sockets were passed, binding %s", s.server.Addr)
+			}
+			listeners = append(listeners, activated...)
+		}
+		if len(listeners) == 0 && !listenCfg.DisableTCP {
+			listener, err := net.Listen("tcp", s.server.Addr)
+			if err != nil {
+				return nil, err
+			}
+			listeners = append(listeners, listener)
+		}
+	}
+
+	if listenCfg.UnixSocket != "" {
+		listener, err := listenUnix(listenCfg.UnixSocket, listenCfg.UnixSocketMode)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	if proxyProtocol {
+		for i, listener := range listeners {
+			if listener.Addr().Network() != "unix" {
+				listeners[i] = proxyproto.NewListener(listener)
+			}
+		}
+	}
+
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("listen.disable-tcp is set but no unix socket or systemd socket is available")
+	}
+	return listeners, nil
+}
+
+// serveListeners serves every listener until shutdown. Unix sockets are
+// served without TLS. The first failure closes the server and is returned.
+func (s *Server) serveListeners(listeners []net.Listener, useTLS bool, certFile, keyFile string) error {
+	errCh := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			if useTLS && listener.Addr().Network() != "unix" {
+				log.Debugf("Starting API server on %s with TLS", listener.Addr())
+				if err := s.server.ServeTLS(listener, certFile, keyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					errCh <- fmt.Errorf("failed to start HTTPS server: %v", err)
+					return
+				}
+				errCh <- nil
+				return
+			}
+			log.Debugf("Starting API server on %s", listener.Addr())
+			if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- fmt.Errorf("failed to start HTTP server: %v", err)
+				return
+			}
+			errCh <- nil
+		}(listener)
+	}
+
+	var firstErr error
+	for range listeners {
+		if err := <-errCh; err != nil && firstErr == nil {
+			firstErr = err
+			_ = s.server.Close()
+		}
+	}
+	return firstErr
+}
+
+// listenUnix listens on a Unix socket at path with the octal permission mode
+// (0660 when empty). A stale socket left by an earlier run is removed; one
+// that still accepts connections is reported as in use.
+func listenUnix(path, mode string) (net.Listener, error) {
+	perm := defaultUnixSocketMode
+	if mode != "" {
+		parsed, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || parsed > 0o777 {
+			return nil, fmt.Errorf("invalid listen.unix-socket-mode %q", mode)
+		}
+		perm = os.FileMode(parsed)
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, errDial := net.DialTimeout("unix", path, time.Second); errDial == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("unix socket %s is in use", path)
+		}
+		if errRemove := os.Remove(path); errRemove != nil {
+			return nil, fmt.Errorf("remove stale unix socket: %w", errRemove)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if errChmod := os.Chmod(path, perm); errChmod != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("set unix socket mode: %w", errChmod)
+	}
+	return listener, nil
+}
+
+// systemdListeners returns the sockets passed through systemd socket
+// activation, or none when the process was not socket-activated. The
+// activation variables are cleared so child processes do not inherit them.
+func systemdListeners() ([]net.Listener, error) {
+	pid, errPID := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	count, errCount := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if errPID != nil || errCount != nil || pid != os.Getpid() || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, count)
+	for i := 0; i < count; i++ {
+		fd := systemdListenFDsStart + i
+		name := fmt.Sprintf("LISTEN_FD_%d", fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(fd), name)
+		listener, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("systemd socket %s: %w", name, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
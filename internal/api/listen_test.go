@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// shortSocketDir returns a directory short enough for Unix socket paths,
+// which are limited to about 100 bytes.
+func shortSocketDir(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "cpa")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func TestListenUnix(t *testing.T) {
+	dir := shortSocketDir(t)
+	path := filepath.Join(dir, "api.sock")
+
+	listener, err := listenUnix(path, "0600")
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("socket mode = %o, want 600", perm)
+	}
+	if _, err = listenUnix(path, ""); err == nil {
+		t.Fatal("expected error for a socket in use")
+	}
+
+	// Leave a stale socket file behind, as after a crash.
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = listener.Close()
+	listener, err = listenUnix(path, "")
+	if err != nil {
+		t.Fatalf("listenUnix over stale socket: %v", err)
+	}
+	_ = listener.Close()
+
+	regular := filepath.Join(dir, "regular")
+	if err = os.WriteFile(regular, nil, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err = listenUnix(regular, ""); err == nil {
+		t.Fatal("expected error for a regular file")
+	}
+	if _, err = listenUnix(filepath.Join(dir, "bad.sock"), "999"); err == nil {
+		t.Fatal("expected error for an invalid mode")
+	}
+}
+
+func TestSystemdListeners_NotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	listeners, err := systemdListeners()
+	if err != nil || len(listeners) != 0 {
+		t.Fatalf("systemdListeners for another pid = %v, %v; want none", listeners, err)
+	}
+}
+
+func TestServerStart_UnixSocketOnly(t *testing.T) {
+	server := newTestServer(t)
+	path := filepath.Join(shortSocketDir(t), "api.sock")
+	server.cfg.Listen.UnixSocket = path
+	server.cfg.Listen.DisableTCP = true
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Start() }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}}
+	var resp *http.Response
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if resp, err = client.Get("http://unix/"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("request over unix socket: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = server.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err = <-errCh; err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err = os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket file left behind: %v", err)
+	}
+}
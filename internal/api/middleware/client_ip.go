@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// ClientIPMiddleware resolves the client address from X-Forwarded-For and
+// X-Real-IP according to cfg and stores it in Request.RemoteAddr, so
+// c.ClientIP(), the access logs and RemoteAddr-based checks all see the
+// client rather than the proxy. Headers are only honored when the peer is a
+// trusted proxy; Unix socket peers are local and always trusted. The server
+// disables Gin's own forwarded-header handling when this is installed.
+func ClientIPMiddleware(cfg config.ClientIPConfig) gin.HandlerFunc {
+	resolver := clientIPResolver{
+		trusted: parseTrustedProxies(cfg.TrustedProxies),
+		depth:   cfg.ForwardedForDepth,
+	}
+	return func(c *gin.Context) {
+		if client, ok := resolver.resolve(c.Request); ok {
+			c.Request.RemoteAddr = net.JoinHostPort(client.String(), "0")
+		}
+		c.Next()
+	}
+}
+
+type clientIPResolver struct {
+	trusted []netip.Prefix
+	depth   int
+}
+
+func parseTrustedProxies(entries []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		log.Warnf("client-ip: ignoring invalid trusted proxy %q", entry)
+	}
+	return prefixes
+}
+
+func (r clientIPResolver) isTrusted(addr netip.Addr) bool {
+	if len(r.trusted) == 0 {
+		return r.depth > 0
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the client address of req when its peer is trusted and it
+// carries a usable forwarded address.
+func (r clientIPResolver) resolve(req *http.Request) (netip.Addr, bool) {
+	if !r.peerTrusted(req) {
+		return netip.Addr{}, false
+	}
+
+	chain := forwardedChain(req.Header.Values("X-Forwarded-For"))
+	if len(chain) == 0 {
+		addr, err := netip.ParseAddr(strings.TrimSpace(req.Header.Get("X-Real-IP")))
+		return addr.Unmap(), err == nil
+	}
+	if r.depth > 0 {
+		return chain[max(len(chain)-r.depth, 0)], true
+	}
+	for i := len(chain) - 1; i > 0; i-- {
+		if !r.isTrusted(chain[i]) {
+			return chain[i], true
+		}
+	}
+	return chain[0], true
+}
+
+func (r clientIPResolver) peerTrusted(req *http.Request) bool {
+	if local, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && local.Network() == "unix" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	return err == nil && r.isTrusted(peer)
+}
+
+// forwardedChain parses the X-Forwarded-For addresses, left to right. Parsing
+// stops at the right-most malformed entry since nothing left of it can be
+// attributed to a known hop.
+func forwardedChain(values []string) []netip.Addr {
+	var parts []string
+	for _, value := range values {
+		parts = append(parts, strings.Split(value, ",")...)
+	}
+	chain := make([]netip.Addr, 0, len(parts))
+	for i := len(parts) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(parts[i]))
+		if err != nil {
+			break
+		}
+		chain = append(chain, addr.Unmap())
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
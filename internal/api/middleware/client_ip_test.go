@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func clientIPFor(t *testing.T, cfg config.ClientIPConfig, remoteAddr string, headers map[string]string) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.ForwardedByClientIP = false
+	engine.Use(ClientIPMiddleware(cfg))
+	var got string
+	engine.GET("/", func(c *gin.Context) { got = c.ClientIP() })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	return got
+}
+
+func TestClientIPMiddleware(t *testing.T) {
+	trusted := config.ClientIPConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}}
+	tests := []struct {
+		name    string
+		cfg     config.ClientIPConfig
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"untrusted peer ignores headers", trusted, "198.51.100.9:1234", map[string]string{"X-Forwarded-For": "203.0.113.5"}, "198.51.100.9"},
+		{"trusted peer", trusted, "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "203.0.113.5"}, "203.0.113.5"},
+		{"skips trusted hops", trusted, "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, 203.0.113.5, 192.0.2.1, 10.9.9.9"}, "203.0.113.5"},
+		{"spoofed prefix stops at first untrusted", trusted, "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "127.0.0.1, 203.0.113.5"}, "203.0.113.5"},
+		{"x-real-ip fallback", trusted, "192.0.2.1:80", map[string]string{"X-Real-IP": "203.0.113.8"}, "203.0.113.8"},
+		{"no headers keeps peer", trusted, "10.1.2.3:1234", nil, "10.1.2.3"},
+		{"depth without trusted list", config.ClientIPConfig{ForwardedForDepth: 2}, "198.51.100.9:1234", map[string]string{"X-Forwarded-For": "127.0.0.1, 203.0.113.5, 10.0.0.7"}, "203.0.113.5"},
+		{"depth beyond chain", config.ClientIPConfig{ForwardedForDepth: 5}, "198.51.100.9:1234", map[string]string{"X-Forwarded-For": "203.0.113.5, 10.0.0.7"}, "203.0.113.5"},
+		{"malformed entry ends chain", trusted, "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "203.0.113.5, garbage, 10.2.2.2"}, "10.2.2.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clientIPFor(t, tt.cfg, tt.remote, tt.headers); got != tt.want {
+				t.Fatalf("client ip = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
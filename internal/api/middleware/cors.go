@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const managementPathPrefix = "/v0/management"
+
+var defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// corsPolicy is a config.CORSPolicy prepared for matching.
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     map[string]struct{}
+	wildcards   [][2]string // scheme+"://" prefix and ".domain" suffix
+	anyHeader   bool
+	headers     string
+	methods     string
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+type corsPolicies struct {
+	api        *corsPolicy
+	management *corsPolicy
+}
+
+var currentCORS atomic.Pointer[corsPolicies]
+
+func init() {
+	ConfigureCORS(config.CORSConfig{})
+}
+
+// ConfigureCORS replaces the policies applied by CORSMiddleware.
+func ConfigureCORS(cfg config.CORSConfig) {
+	policies := &corsPolicies{api: newCORSPolicy(cfg.CORSPolicy)}
+	policies.management = policies.api
+	if cfg.Management != nil {
+		policies.management = newCORSPolicy(*cfg.Management)
+	}
+	currentCORS.Store(policies)
+}
+
+func newCORSPolicy(cfg config.CORSPolicy) *corsPolicy {
+	p := &corsPolicy{origins: make(map[string]struct{}), credentials: cfg.AllowCredentials}
+	if len(cfg.AllowedOrigins) == 0 {
+		p.anyOrigin = true
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimRight(strings.TrimSpace(origin), "/"))
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, domain, _ := strings.Cut(origin, "://*")
+			p.wildcards = append(p.wildcards, [2]string{scheme + "://", domain})
+		case origin != "":
+			p.origins[origin] = struct{}{}
+		}
+	}
+	p.anyHeader = len(cfg.AllowedHeaders) == 0
+	for _, header := range cfg.AllowedHeaders {
+		if strings.TrimSpace(header) == "*" {
+			p.anyHeader = true
+		}
+	}
+	if !p.anyHeader {
+		p.headers = strings.Join(cfg.AllowedHeaders, ", ")
+	}
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	p.methods = strings.Join(methods, ", ")
+	p.exposed = strings.Join(cfg.ExposedHeaders, ", ")
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+	return p
+}
+
+func (p *corsPolicy) allows(origin string) bool {
+	return p.anyOrigin || p.lists(origin)
+}
+
+// lists reports whether origin is named by allowed-origins, explicitly or by
+// a subdomain wildcard, rather than only admitted by "*".
+func (p *corsPolicy) lists(origin string) bool {
+	origin = strings.ToLower(origin)
+	if _, ok := p.origins[origin]; ok {
+		return true
+	}
+	for _, wildcard := range p.wildcards {
+		host := strings.TrimPrefix(origin, wildcard[0])
+		if host != origin && strings.HasSuffix(host, wildcard[1]) && len(host) > len(wildcard[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets the CORS headers for origin and reports whether it is allowed.
+// Credentials are only allowed for listed origins: origins admitted by "*"
+// get the anonymous "*" policy, so an arbitrary site cannot make
+// authenticated reads.
+func (p *corsPolicy) apply(c *gin.Context, origin string, preflight bool) bool {
+	credentials := p.credentials && origin != "" && p.lists(origin)
+	switch {
+	case origin == "" && !p.anyOrigin:
+		return false
+	case origin != "" && !p.allows(origin):
+		return false
+	case !credentials && p.anyOrigin:
+		c.Header("Access-Control-Allow-Origin", "*")
+		if p.credentials {
+			// Listed origins get a different answer.
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+	default:
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Writer.Header().Add("Vary", "Origin")
+	}
+	if credentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+	c.Header("Access-Control-Allow-Methods", p.methods)
+	switch {
+	case !p.anyHeader:
+		c.Header("Access-Control-Allow-Headers", p.headers)
+	case credentials:
+		// "*" is a literal header name on credentialed requests.
+		if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			c.Header("Access-Control-Allow-Headers", requested)
+		}
+	default:
+		c.Header("Access-Control-Allow-Headers", "*")
+	}
+	if preflight {
+		if p.maxAge != "" {
+			c.Header("Access-Control-Max-Age", p.maxAge)
+		}
+	} else if p.exposed != "" {
+		c.Header("Access-Control-Expose-Headers", p.exposed)
+	}
+	return true
+}
+
+// CORSMiddleware adds the configured CORS headers and answers preflight
+// requests. Management API routes use the management policy. Preflights from
+// origins the policy does not allow are rejected with 403; other requests
+// from them proceed without CORS headers, so browsers block the response.
+func CORSMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policies := currentCORS.Load()
+		policy := policies.api
+		if strings.HasPrefix(c.Request.URL.Path, managementPathPrefix) {
+			policy = policies.management
+		}
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions
+		allowed := policy.apply(c, origin, preflight)
+
+		if preflight {
+			if origin != "" && !allowed {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func newCORSTestEngine(t *testing.T, cfg config.CORSConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ConfigureCORS(cfg)
+	t.Cleanup(func() { ConfigureCORS(config.CORSConfig{}) })
+
+	engine := gin.New()
+	engine.Use(CORSMiddleware())
+	engine.POST("/v1/chat/completions", func(c *gin.Context) { c.Status(http.StatusOK) })
+	engine.GET("/v0/management/config", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return engine
+}
+
+func corsRequest(engine *gin.Engine, method, path, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+	}
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSMiddleware_DefaultAllowsAnyOrigin(t *testing.T) {
+	engine := newCORSTestEngine(t, config.CORSConfig{})
+
+	rec := corsRequest(engine, http.MethodPost, "/v1/chat/completions", "")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("allow-origin = %q, want *", got)
+	}
+	rec = corsRequest(engine, http.MethodOptions, "/v1/chat/completions", "https://any.example")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "*" {
+		t.Fatalf("allow-headers = %q, want *", got)
+	}
+}
+
+func TestCORSMiddleware_AllowList(t *testing.T) {
+	engine := newCORSTestEngine(t, config.CORSConfig{CORSPolicy: config.CORSPolicy{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		ExposedHeaders: []string{"X-Request-ID"},
+		MaxAge:         600,
+	}})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"http://app.example.com", false},
+		{"https://evil.example", false},
+	}
+	for _, tt := range tests {
+		rec := corsRequest(engine, http.MethodOptions, "/v1/chat/completions", tt.origin)
+		if tt.allowed {
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("%s: preflight status = %d, want %d", tt.origin, rec.Code, http.StatusNoContent)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.origin {
+				t.Errorf("%s: allow-origin = %q", tt.origin, got)
+			}
+			if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("%s: max-age = %q, want 600", tt.origin, got)
+			}
+			continue
+		}
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: preflight status = %d, want %d", tt.origin, rec.Code, http.StatusForbidden)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%s: allow-origin = %q, want none", tt.origin, got)
+		}
+	}
+
+	rec := corsRequest(engine, http.MethodPost, "/v1/chat/completions", "https://app.example.com")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID" {
+		t.Fatalf("expose-headers = %q", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Fatalf("vary = %q, want Origin", got)
+	}
+}
+
+func TestCORSMiddleware_ManagementPolicyWithCredentials(t *testing.T) {
+	engine := newCORSTestEngine(t, config.CORSConfig{
+		CORSPolicy: config.CORSPolicy{AllowedOrigins: []string{"https://playground.example.com"}},
+		Management: &config.CORSPolicy{
+			AllowedOrigins:   []string{"https://admin.example.com"},
+			AllowCredentials: true,
+		},
+	})
+
+	rec := corsRequest(engine, http.MethodOptions, "/v0/management/config", "https://admin.example.com")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Fatalf("allow-credentials = %q, want true", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "authorization, content-type" {
+		t.Fatalf("allow-headers = %q, want the requested headers", got)
+	}
+
+	rec = corsRequest(engine, http.MethodOptions, "/v0/management/config", "https://playground.example.com")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("inference origin on management: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	rec = corsRequest(engine, http.MethodOptions, "/v1/chat/completions", "https://admin.example.com")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("management origin on inference: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestCORSMiddleware_CredentialsOnlyForListedOrigins(t *testing.T) {
+	engine := newCORSTestEngine(t, config.CORSConfig{CORSPolicy: config.CORSPolicy{AllowCredentials: true}})
+
+	rec := corsRequest(engine, http.MethodPost, "/v1/chat/completions", "https://evil.example")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("unlisted origin: allow-origin = %q, want *", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Fatalf("unlisted origin: allow-credentials = %q, want none", got)
+	}
+
+	engine = newCORSTestEngine(t, config.CORSConfig{CORSPolicy: config.CORSPolicy{
+		AllowedOrigins:   []string{"*", "https://app.example.com"},
+		AllowCredentials: true,
+	}})
+	rec = corsRequest(engine, http.MethodPost, "/v1/chat/completions", "https://app.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("listed origin: allow-origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Fatalf("listed origin: allow-credentials = %q, want true", got)
+	}
+	rec = corsRequest(engine, http.MethodPost, "/v1/chat/completions", "https://evil.example")
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("origin admitted by *: allow-origin = %q, allow-credentials = %q", rec.Header().Get("Access-Control-Allow-Origin"), got)
+	}
+}
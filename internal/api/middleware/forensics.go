@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/forensics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// ForensicsMiddleware records a forensic bundle for each AI API request and
+// persists it when the request fails, either with an error status or with
+// upstream errors reported during streaming. It must run after the request ID
+// has been assigned.
+func ForensicsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !forensics.Enabled() || shouldSkipMethodForRequestLogging(c.Request) || !shouldLogRequest(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		url := c.Request.URL.Path
+		if maskedQuery := util.MaskSensitiveQuery(c.Request.URL.RawQuery); maskedQuery != "" {
+			url += "?" + maskedQuery
+		}
+		rec := forensics.Begin(logging.GetGinRequestID(c), c.Request.Method, url, c.Request.Header)
+		if rec == nil {
+			c.Next()
+			return
+		}
+		if c.Request.Body != nil {
+			body, errRead := io.ReadAll(c.Request.Body)
+			if errRead != nil {
+				c.Next()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+			rec.AddStage("original", "", "", body)
+		}
+		forensics.Attach(c, rec)
+
+		c.Next()
+
+		var errs []string
+		if value, exists := c.Get("API_RESPONSE_ERROR"); exists {
+			if apiErrors, ok := value.([]*interfaces.ErrorMessage); ok {
+				for _, apiErr := range apiErrors {
+					if apiErr != nil && apiErr.Error != nil {
+						errs = append(errs, apiErr.Error.Error())
+					}
+				}
+			}
+		}
+		status := c.Writer.Status()
+		if status < http.StatusBadRequest && len(errs) == 0 {
+			return
+		}
+		if errSave := forensics.Save(rec, status, errs); errSave != nil {
+			log.Warnf("failed to persist forensic bundle: %v", errSave)
+		}
+	}
+}
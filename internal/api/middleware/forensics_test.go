@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/forensics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+)
+
+func TestForensicsMiddleware_PersistsFailedRequestsOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	forensics.Configure(config.ForensicsConfig{Enable: true}, dir)
+	t.Cleanup(func() { forensics.Configure(config.ForensicsConfig{}, dir) })
+
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		logging.SetGinRequestID(c, c.GetHeader("X-Test-ID"))
+		c.Next()
+	})
+	engine.Use(ForensicsMiddleware())
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		if forensics.FromGin(c) == nil {
+			t.Error("recorder not attached")
+		}
+		if c.GetHeader("X-Test-ID") == "failed01" {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "upstream failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	for _, id := range []string{"failed01", "success1"} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-5"}`))
+		req.Header.Set("X-Test-ID", id)
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	data, errLoad := forensics.Load("failed01")
+	if errLoad != nil {
+		t.Fatalf("load failed bundle: %v", errLoad)
+	}
+	var bundle forensics.Bundle
+	if errUnmarshal := json.Unmarshal(data, &bundle); errUnmarshal != nil {
+		t.Fatalf("unmarshal: %v", errUnmarshal)
+	}
+	if bundle.Status != http.StatusBadGateway || len(bundle.Stages) != 1 || bundle.Stages[0].Payload != `{"model":"gpt-5"}` {
+		t.Fatalf("bundle = %+v", bundle)
+	}
+	if _, errLoad = forensics.Load("success1"); !errors.Is(errLoad, os.ErrNotExist) {
+		t.Fatalf("successful request persisted: %v", errLoad)
+	}
+}
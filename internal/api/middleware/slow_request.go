@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+)
+
+// SlowRequestTraceMiddleware attaches a stage trace to each AI API request and
+// logs it when the request is slower than slow-request-threshold-ms. It must
+// run after the request ID has been assigned.
+func SlowRequestTraceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := logging.GetGinRequestID(c)
+		if tracing.Threshold() <= 0 || requestID == "" {
+			c.Next()
+			return
+		}
+		trace := tracing.New()
+		tracing.Attach(c, trace)
+
+		c.Next()
+
+		trace.LogIfSlow(requestID, c.Request.Method, c.Request.URL.Path, c.Writer.Status())
+	}
+}
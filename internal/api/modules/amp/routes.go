@@ -278,6 +278,7 @@ func (m *AmpModule) registerProviderAliases(engine *gin.Engine, baseHandler *han
 
 	// Provider-specific routes under /api/provider/:provider
 	ampProviders := engine.Group("/api/provider")
+	ampProviders.Use(handlers.MaintenanceMiddleware(baseHandler))
 	if auth != nil {
 		ampProviders.Use(auth)
 	}
@@ -7,8 +7,8 @@ package api
 import (
 	"context"
 	"crypto/subtle"
-	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -26,9 +26,15 @@ import (
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/eventstream"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/forensics"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/memguard"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage/anomaly"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
@@ -53,11 +59,18 @@ type serverOptionConfig struct {
 	keepAliveTimeout     time.Duration
 	keepAliveOnTimeout   func()
 	postAuthHook         auth.PostAuthHook
+	listener             net.Listener
 }
 
 // ServerOption customises HTTP server construction.
 type ServerOption func(*serverOptionConfig)
 
+// requestLogRetentionSetter is implemented by request loggers that prune
+// their files under log-retention.request-logs.
+type requestLogRetentionSetter interface {
+	SetRetention(config.LogRetentionPolicy)
+}
+
 func defaultRequestLoggerFactory(cfg *config.Config, configPath string) logging.RequestLogger {
 	configDir := filepath.Dir(configPath)
 	logsDir := logging.ResolveLogDirectory(cfg)
@@ -118,6 +131,13 @@ func WithPostAuthHook(hook auth.PostAuthHook) ServerOption {
 	}
 }
 
+// WithListener serves on an existing listener instead of binding host:port from the config.
+func WithListener(listener net.Listener) ServerOption {
+	return func(cfg *serverOptionConfig) {
+		cfg.listener = listener
+	}
+}
+
 // Server represents the main API server.
 // It encapsulates the Gin engine, HTTP server, handlers, and configuration.
 type Server struct {
@@ -127,9 +147,20 @@ type Server struct {
 	// server is the underlying HTTP server.
 	server *http.Server
 
+	// listener, when set, is served instead of binding server.Addr.
+	listener net.Listener
+
+	// redirectServer redirects plain HTTP to HTTPS and answers ACME HTTP-01
+	// challenges when tls.redirect-http is set.
+	redirectMu     sync.Mutex
+	redirectServer *http.Server
+
 	// handlers contains the API handlers for processing requests.
 	handlers *handlers.BaseAPIHandler
 
+	// jobs runs background generations submitted through /v1/jobs.
+	jobs *handlers.JobManager
+
 	// cfg holds the current server configuration.
 	cfg *config.Config
 
@@ -207,9 +238,16 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		optionState.engineConfigurator(engine)
 	}
 
+	// Resolve client addresses behind trusted proxies before anything logs them.
+	if cfg.ClientIP.ResolvesForwarded() {
+		engine.ForwardedByClientIP = false
+		engine.Use(middleware.ClientIPMiddleware(cfg.ClientIP))
+	}
+
 	// Add middleware
 	engine.Use(logging.GinLogrusLogger())
 	engine.Use(logging.GinLogrusRecovery())
+	engine.Use(eventstream.Middleware())
 	for _, mw := range optionState.extraMiddleware {
 		engine.Use(mw)
 	}
@@ -227,10 +265,17 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 			if setter, ok := requestLogger.(interface{ SetEnabled(bool) }); ok {
 				toggle = setter.SetEnabled
 			}
+			if setter, ok := requestLogger.(requestLogRetentionSetter); ok {
+				setter.SetRetention(cfg.LogRetention.RequestLogs)
+			}
 		}
 	}
 
-	engine.Use(corsMiddleware())
+	engine.Use(middleware.ForensicsMiddleware())
+	engine.Use(middleware.SlowRequestTraceMiddleware())
+
+	middleware.ConfigureCORS(cfg.CORS)
+	engine.Use(middleware.CORSMiddleware())
 	wd, err := os.Getwd()
 	if err != nil {
 		wd = configFilePath
@@ -259,9 +304,14 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	s.applyAccessConfig(nil, cfg)
 	if authManager != nil {
 		authManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second, cfg.MaxRetryCredentials)
+		authManager.SetRateLimitQueue(time.Duration(cfg.RateLimitQueue.MaxWaitSeconds) * time.Second)
+		authManager.SetQualityGuard(cfg.QualityGuard.MaxRetries, cfg.QualityGuard.SameCredentialRetries)
 	}
 	managementasset.SetCurrentConfig(cfg)
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
+	memguard.Configure(cfg.ResourceLimits)
+	anomaly.Configure(cfg.UsageAnomaly)
+	tracing.SetThreshold(time.Duration(cfg.SlowRequestThresholdMs) * time.Millisecond)
 	// Initialize management handler
 	s.mgmt = managementHandlers.NewHandler(cfg, configFilePath, authManager)
 	if optionState.localPassword != "" {
@@ -269,6 +319,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 	logDir := logging.ResolveLogDirectory(cfg)
 	s.mgmt.SetLogDirectory(logDir)
+	forensics.Configure(cfg.Forensics, filepath.Join(logDir, "forensics"))
 	if optionState.postAuthHook != nil {
 		s.mgmt.SetPostAuthHook(optionState.postAuthHook)
 	}
@@ -316,6 +367,10 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		Addr:    fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 		Handler: engine,
 	}
+	if optionState.listener != nil {
+		s.listener = optionState.listener
+		s.server.Addr = optionState.listener.Addr().String()
+	}
 
 	return s
 }
@@ -329,24 +384,31 @@ func (s *Server) setupRoutes() {
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
 	claudeCodeHandlers := claude.NewClaudeCodeAPIHandler(s.handlers)
 	openaiResponsesHandlers := openai.NewOpenAIResponsesAPIHandler(s.handlers)
+	s.jobs = handlers.NewJobManager(s.handlers, s.engine)
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(handlers.MaintenanceMiddleware(s.handlers), handlers.MemoryPressureMiddleware(), handlers.CompressionMiddleware(s.handlers), AuthMiddleware(s.accessManager), handlers.RequestDedupMiddleware(s.handlers), handlers.ArtifactMiddleware(s.handlers), handlers.StreamResumeMiddleware(s.handlers), handlers.DisconnectPolicyMiddleware(s.handlers))
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/audio/speech", openaiHandlers.AudioSpeech)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.GET("/responses", openaiResponsesHandlers.ResponsesWebsocket)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
 		v1.POST("/responses/compact", openaiResponsesHandlers.Compact)
+		v1.GET("/requests/:id", s.handlers.GetDetachedResult)
+		v1.GET("/artifacts/:id", s.handlers.GetArtifact)
+		v1.POST("/jobs", s.jobs.Submit)
+		v1.GET("/jobs/:id", s.jobs.Get)
+		v1.GET("/jobs/:id/result", s.jobs.Result)
 	}
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(handlers.MaintenanceMiddleware(s.handlers), handlers.MemoryPressureMiddleware(), handlers.CompressionMiddleware(s.handlers), AuthMiddleware(s.accessManager), handlers.RequestDedupMiddleware(s.handlers), handlers.ArtifactMiddleware(s.handlers), handlers.StreamResumeMiddleware(s.handlers), handlers.DisconnectPolicyMiddleware(s.handlers))
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/*action", geminiHandlers.GeminiHandler)
@@ -527,11 +589,18 @@ func (s *Server) registerManagementRoutes() {
 	log.Info("management routes registered after secret key configuration")
 
 	mgmt := s.engine.Group("/v0/management")
-	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
+	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware(), s.mgmt.ReadOnlyMiddleware(), s.mgmt.AuditMiddleware())
 	{
+		mgmt.GET("/audit-log", s.mgmt.GetAuditLog)
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
 		mgmt.GET("/usage/export", s.mgmt.ExportUsageStatistics)
 		mgmt.POST("/usage/import", s.mgmt.ImportUsageStatistics)
+		mgmt.GET("/usage-anomalies", s.mgmt.GetUsageAnomalies)
+		mgmt.DELETE("/usage-anomalies", s.mgmt.DeleteUsageAnomaly)
+		mgmt.GET("/ws", s.mgmt.GetEventsWebSocket)
+		mgmt.GET("/experiments", s.mgmt.GetExperiments)
+		mgmt.DELETE("/experiments", s.mgmt.DeleteExperiments)
+		mgmt.GET("/conversations/export", s.mgmt.ExportConversation)
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
@@ -541,6 +610,17 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PUT("/debug", s.mgmt.PutDebug)
 		mgmt.PATCH("/debug", s.mgmt.PutDebug)
 
+		mgmt.GET("/log-levels", s.mgmt.GetLogLevels)
+		mgmt.PUT("/log-levels", s.mgmt.PutLogLevels)
+		mgmt.PATCH("/log-levels", s.mgmt.PutLogLevels)
+
+		mgmt.GET("/read-only", s.mgmt.GetReadOnly)
+		mgmt.PUT("/read-only", s.mgmt.PutReadOnly)
+		mgmt.PATCH("/read-only", s.mgmt.PutReadOnly)
+		mgmt.GET("/maintenance", s.mgmt.GetMaintenance)
+		mgmt.PUT("/maintenance", s.mgmt.PutMaintenance)
+		mgmt.PATCH("/maintenance", s.mgmt.PutMaintenance)
+
 		mgmt.GET("/logging-to-file", s.mgmt.GetLoggingToFile)
 		mgmt.PUT("/logging-to-file", s.mgmt.PutLoggingToFile)
 		mgmt.PATCH("/logging-to-file", s.mgmt.PutLoggingToFile)
@@ -563,6 +643,12 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.DELETE("/proxy-url", s.mgmt.DeleteProxyURL)
 
 		mgmt.POST("/api-call", s.mgmt.APICall)
+		mgmt.POST("/selftest/:provider", s.mgmt.PostProviderSelftest)
+		mgmt.GET("/streams", s.mgmt.GetActiveStreams)
+		mgmt.GET("/runtime", s.mgmt.GetRuntimeStats)
+		mgmt.GET("/connections", s.mgmt.GetConnectionStats)
+		mgmt.GET("/quota-headroom", s.mgmt.GetQuotaHeadroom)
+		mgmt.GET("/rate-limit-queue", s.mgmt.GetRateLimitQueue)
 
 		mgmt.GET("/quota-exceeded/switch-project", s.mgmt.GetSwitchProject)
 		mgmt.PUT("/quota-exceeded/switch-project", s.mgmt.PutSwitchProject)
@@ -587,6 +673,7 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/request-error-logs", s.mgmt.GetRequestErrorLogs)
 		mgmt.GET("/request-error-logs/:name", s.mgmt.DownloadRequestErrorLog)
 		mgmt.GET("/request-log-by-id/:id", s.mgmt.GetRequestLogByID)
+		mgmt.GET("/request-forensics/:id", s.mgmt.GetRequestForensics)
 		mgmt.GET("/request-log", s.mgmt.GetRequestLog)
 		mgmt.PUT("/request-log", s.mgmt.PutRequestLog)
 		mgmt.PATCH("/request-log", s.mgmt.PutRequestLog)
@@ -663,21 +750,34 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PATCH("/oauth-model-alias", s.mgmt.PatchOAuthModelAlias)
 		mgmt.DELETE("/oauth-model-alias", s.mgmt.DeleteOAuthModelAlias)
 
+		mgmt.GET("/prompt-templates", s.mgmt.GetPromptTemplates)
+		mgmt.PUT("/prompt-templates", s.mgmt.PutPromptTemplates)
+		mgmt.PATCH("/prompt-templates", s.mgmt.PatchPromptTemplate)
+		mgmt.DELETE("/prompt-templates", s.mgmt.DeletePromptTemplate)
+
 		mgmt.GET("/auth-files", s.mgmt.ListAuthFiles)
 		mgmt.GET("/auth-files/models", s.mgmt.GetAuthFileModels)
 		mgmt.GET("/model-definitions/:channel", s.mgmt.GetStaticModelDefinitions)
 		mgmt.GET("/auth-files/download", s.mgmt.DownloadAuthFile)
 		mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
 		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
+		mgmt.GET("/auth-files/trash", s.mgmt.ListAuthTrash)
+		mgmt.POST("/auth-files/restore", s.mgmt.RestoreAuthFile)
+		mgmt.POST("/auth-files/export", s.mgmt.ExportAuthFiles)
+		mgmt.POST("/auth-files/import", s.mgmt.ImportAuthFiles)
 		mgmt.PATCH("/auth-files/status", s.mgmt.PatchAuthFileStatus)
 		mgmt.PATCH("/auth-files/fields", s.mgmt.PatchAuthFileFields)
+		mgmt.GET("/model-unavailable", s.mgmt.GetModelUnavailable)
+		mgmt.DELETE("/model-unavailable", s.mgmt.DeleteModelUnavailable)
 		mgmt.POST("/vertex/import", s.mgmt.ImportVertexCredential)
+		mgmt.POST("/vertex/workload-identity", s.mgmt.ImportVertexWorkloadIdentity)
 
 		mgmt.GET("/anthropic-auth-url", s.mgmt.RequestAnthropicToken)
 		mgmt.GET("/codex-auth-url", s.mgmt.RequestCodexToken)
 		mgmt.GET("/gitlab-auth-url", s.mgmt.RequestGitLabToken)
 		mgmt.POST("/gitlab-auth-url", s.mgmt.RequestGitLabPATToken)
 		mgmt.GET("/gemini-cli-auth-url", s.mgmt.RequestGeminiCLIToken)
+		mgmt.POST("/gemini-cli-project", s.mgmt.PostGeminiCLIProjectSelection)
 		mgmt.GET("/antigravity-auth-url", s.mgmt.RequestAntigravityToken)
 		mgmt.GET("/qwen-auth-url", s.mgmt.RequestQwenToken)
 		mgmt.GET("/kilo-auth-url", s.mgmt.RequestKiloToken)
@@ -685,6 +785,7 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/iflow-auth-url", s.mgmt.RequestIFlowToken)
 		mgmt.POST("/iflow-auth-url", s.mgmt.RequestIFlowCookieToken)
 		mgmt.GET("/kiro-auth-url", s.mgmt.RequestKiroToken)
+		mgmt.GET("/kiro/http-pool", s.mgmt.GetKiroHTTPPoolHealth)
 		mgmt.GET("/github-auth-url", s.mgmt.RequestGitHubToken)
 		mgmt.POST("/oauth-callback", s.mgmt.PostOAuthCallback)
 		mgmt.GET("/get-auth-status", s.mgmt.GetAuthStatus)
@@ -838,26 +939,33 @@ func (s *Server) Start() error {
 		return fmt.Errorf("failed to start HTTP server: server not initialized")
 	}
 
+	listeners, err := s.openListeners()
+	if err != nil {
+		return fmt.Errorf("failed to start HTTP server: %v", err)
+	}
+
+	s.jobs.Resume()
+
 	useTLS := s.cfg != nil && s.cfg.TLS.Enable
+	var certFile, keyFile string
 	if useTLS {
-		cert := strings.TrimSpace(s.cfg.TLS.Cert)
-		key := strings.TrimSpace(s.cfg.TLS.Key)
-		if cert == "" || key == "" {
-			return fmt.Errorf("failed to start HTTPS server: tls.cert or tls.key is empty")
-		}
-		log.Debugf("Starting API server on %s with TLS", s.server.Addr)
-		if errServeTLS := s.server.ListenAndServeTLS(cert, key); errServeTLS != nil && !errors.Is(errServeTLS, http.ErrServerClosed) {
-			return fmt.Errorf("failed to start HTTPS server: %v", errServeTLS)
+		if certFile, keyFile, err = s.prepareTLS(); err != nil {
+			for _, listener := range listeners {
+				_ = listener.Close()
+			}
+			return fmt.Errorf("failed to start HTTPS server: %v", err)
 		}
-		return nil
 	}
 
-	log.Debugf("Starting API server on %s", s.server.Addr)
-	if errServe := s.server.ListenAndServe(); errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
-		return fmt.Errorf("failed to start HTTP server: %v", errServe)
-	}
+	return s.serveListeners(listeners, useTLS, certFile, keyFile)
+}
 
-	return nil
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	if s == nil || s.server == nil {
+		return ""
+	}
+	return s.server.Addr
 }
 
 // Stop gracefully shuts down the API server without interrupting any
@@ -878,6 +986,15 @@ func (s *Server) Stop(ctx context.Context) error {
 		}
 	}
 
+	s.redirectMu.Lock()
+	redirectServer := s.redirectServer
+	s.redirectMu.Unlock()
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(ctx); err != nil {
+			log.Warnf("failed to shutdown HTTPS redirect server: %v", err)
+		}
+	}
+
 	// Shutdown the HTTP server.
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
@@ -887,26 +1004,6 @@ func (s *Server) Stop(ctx context.Context) error {
 	return nil
 }
 
-// corsMiddleware returns a Gin middleware handler that adds CORS headers
-// to every response, allowing cross-origin requests.
-//
-// Returns:
-//   - gin.HandlerFunc: The CORS middleware handler
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "*")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-
-		c.Next()
-	}
-}
-
 func (s *Server) applyAccessConfig(oldCfg, newCfg *config.Config) {
 	if s == nil || s.accessManager == nil || newCfg == nil {
 		return
@@ -952,23 +1049,51 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 	}
 
+	if oldCfg == nil || oldCfg.UsageAnomaly != cfg.UsageAnomaly {
+		anomaly.Configure(cfg.UsageAnomaly)
+	}
+
+	if oldCfg == nil || oldCfg.SlowRequestThresholdMs != cfg.SlowRequestThresholdMs {
+		tracing.SetThreshold(time.Duration(cfg.SlowRequestThresholdMs) * time.Millisecond)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.CORS, cfg.CORS) {
+		middleware.ConfigureCORS(cfg.CORS)
+	}
+
+	if oldCfg == nil || oldCfg.Forensics != cfg.Forensics {
+		forensics.Configure(cfg.Forensics, filepath.Join(logging.ResolveLogDirectory(cfg), "forensics"))
+	}
+
+	if oldCfg != nil && oldCfg.ResourceLimits != cfg.ResourceLimits {
+		memguard.Configure(cfg.ResourceLimits)
+	}
+
 	if s.requestLogger != nil && (oldCfg == nil || oldCfg.ErrorLogsMaxFiles != cfg.ErrorLogsMaxFiles) {
 		if setter, ok := s.requestLogger.(interface{ SetErrorLogsMaxFiles(int) }); ok {
 			setter.SetErrorLogsMaxFiles(cfg.ErrorLogsMaxFiles)
 		}
 	}
 
+	if s.requestLogger != nil && oldCfg != nil && oldCfg.LogRetention.RequestLogs != cfg.LogRetention.RequestLogs {
+		if setter, ok := s.requestLogger.(requestLogRetentionSetter); ok {
+			setter.SetRetention(cfg.LogRetention.RequestLogs)
+		}
+	}
+
 	if oldCfg == nil || oldCfg.DisableCooling != cfg.DisableCooling {
 		auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 	}
 
 	if s.handlers != nil && s.handlers.AuthManager != nil {
 		s.handlers.AuthManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second, cfg.MaxRetryCredentials)
+		s.handlers.AuthManager.SetRateLimitQueue(time.Duration(cfg.RateLimitQueue.MaxWaitSeconds) * time.Second)
+		s.handlers.AuthManager.SetQualityGuard(cfg.QualityGuard.MaxRetries, cfg.QualityGuard.SameCredentialRetries)
 	}
 
-	// Update log level dynamically when debug flag changes
-	if oldCfg == nil || oldCfg.Debug != cfg.Debug {
-		util.SetLogLevel(cfg)
+	// Update log levels and format dynamically when debug or logging changes
+	if oldCfg == nil || oldCfg.Debug != cfg.Debug || oldCfg.Logging != cfg.Logging {
+		logging.SetLogLevel(cfg)
 	}
 
 	prevSecretEmpty := true
@@ -1083,6 +1208,14 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 		result, err := manager.Authenticate(c.Request.Context(), c.Request)
 		if err == nil {
 			if result != nil {
+				allowed, status, message := anomaly.Check(result.Principal)
+				if limit, remaining, resetAt, throttled := anomaly.Throttle(result.Principal); throttled {
+					ratelimit.Set(c.Writer.Header(), float64(limit), float64(remaining), resetAt, time.Now())
+				}
+				if !allowed {
+					c.AbortWithStatusJSON(status, gin.H{"error": message})
+					return
+				}
 				c.Set("apiKey", result.Principal)
 				c.Set("accessProvider", result.Provider)
 				if len(result.Metadata) > 0 {
@@ -12,8 +12,11 @@ import (
 
 	gin "github.com/gin-gonic/gin"
 	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/forensics"
 	internallogging "github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage/anomaly"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
@@ -21,6 +24,13 @@ import (
 
 func newTestServer(t *testing.T) *Server {
 	t.Helper()
+	return newTestServerWithConfig(t, nil)
+}
+
+// newTestServerWithConfig builds a test server from a config that configure,
+// when set, amends before the server is created.
+func newTestServerWithConfig(t *testing.T, configure func(*proxyconfig.Config)) *Server {
+	t.Helper()
 
 	gin.SetMode(gin.TestMode)
 
@@ -41,6 +51,10 @@ func newTestServer(t *testing.T) *Server {
 		UsageStatisticsEnabled: false,
 	}
 
+	if configure != nil {
+		configure(cfg)
+	}
+
 	authManager := auth.NewManager(nil, nil, nil)
 	accessManager := sdkaccess.NewManager()
 
@@ -333,3 +347,33 @@ func TestDebugModelRouteMemoryEndpoint_NoAuthAndContainsModelDiagnostics(t *test
 		t.Fatalf("custom_models.provider_summaries = %v, want claude entry", payload.Config.CustomModels["provider_summaries"])
 	}
 }
+
+func TestNewServer_AppliesStartupUsageAnomalyConfig(t *testing.T) {
+	t.Cleanup(func() { anomaly.Configure(proxyconfig.UsageAnomalyConfig{}) })
+	newTestServerWithConfig(t, func(cfg *proxyconfig.Config) {
+		cfg.UsageAnomaly = proxyconfig.UsageAnomalyConfig{Enable: true}
+	})
+	if !anomaly.Default().Enabled() {
+		t.Fatal("usage-anomaly from the startup config was not applied")
+	}
+}
+
+func TestNewServer_AppliesStartupForensicsConfig(t *testing.T) {
+	t.Cleanup(func() { forensics.Configure(proxyconfig.ForensicsConfig{}, "") })
+	newTestServerWithConfig(t, func(cfg *proxyconfig.Config) {
+		cfg.Forensics = proxyconfig.ForensicsConfig{Enable: true}
+	})
+	if !forensics.Enabled() {
+		t.Fatal("forensics from the startup config was not applied")
+	}
+}
+
+func TestNewServer_AppliesStartupSlowRequestThreshold(t *testing.T) {
+	t.Cleanup(func() { tracing.SetThreshold(0) })
+	newTestServerWithConfig(t, func(cfg *proxyconfig.Config) {
+		cfg.SlowRequestThresholdMs = 1500
+	})
+	if got := tracing.Threshold(); got != 1500*time.Millisecond {
+		t.Fatalf("slow request threshold = %v, want 1.5s", got)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// defaultACMEHTTPAddr is where HTTP-01 challenges are answered when
+// tls.redirect-http is not set; the CA always connects to port 80.
+const defaultACMEHTTPAddr = ":80"
+
+// prepareTLS configures HTTPS with the certificate files or, when ACME is
+// enabled, with certificates issued and renewed on demand, and starts the
+// redirect server. It returns the files to pass to ServeTLS, empty for ACME.
+func (s *Server) prepareTLS() (certFile, keyFile string, err error) {
+	tlsCfg := s.cfg.TLS
+	var challenge func(http.Handler) http.Handler
+	if tlsCfg.ACME.Enable {
+		manager, errManager := newACMEManager(s.cfg)
+		if errManager != nil {
+			return "", "", errManager
+		}
+		// The manager's config also negotiates acme-tls/1 for TLS-ALPN-01.
+		s.server.TLSConfig = manager.TLSConfig()
+		if tlsCfg.ACME.Challenge == config.ACMEChallengeHTTP {
+			challenge = manager.HTTPHandler
+		}
+		log.Infof("ACME certificates enabled for %s", strings.Join(tlsCfg.ACME.Domains, ", "))
+	} else {
+		certFile = strings.TrimSpace(tlsCfg.Cert)
+		keyFile = strings.TrimSpace(tlsCfg.Key)
+		if certFile == "" || keyFile == "" {
+			return "", "", fmt.Errorf("tls.cert or tls.key is empty")
+		}
+	}
+
+	redirectAddr := strings.TrimSpace(tlsCfg.RedirectHTTP)
+	if redirectAddr == "" && challenge != nil {
+		redirectAddr = defaultACMEHTTPAddr
+	}
+	if redirectAddr != "" {
+		handler := httpsRedirectHandler(s.server.Addr)
+		if challenge != nil {
+			handler = challenge(handler)
+		}
+		s.startRedirectServer(redirectAddr, handler)
+	}
+	return certFile, keyFile, nil
+}
+
+// newACMEManager builds the certificate manager for cfg.TLS.ACME. The account
+// key and certificates are cached under <auth-dir>/acme unless
+// tls.acme.cache-dir is set.
+func newACMEManager(cfg *config.Config) (*autocert.Manager, error) {
+	acmeCfg := cfg.TLS.ACME
+	if len(acmeCfg.Domains) == 0 {
+		return nil, fmt.Errorf("tls.acme.domains is empty")
+	}
+	cacheDir := acmeCfg.CacheDir
+	if cacheDir == "" {
+		authDir, err := util.ResolveAuthDir(cfg.AuthDir)
+		if err != nil {
+			return nil, err
+		}
+		if authDir == "" {
+			return nil, fmt.Errorf("tls.acme.cache-dir is empty and auth-dir is not set")
+		}
+		cacheDir = filepath.Join(authDir, "acme")
+	} else {
+		resolved, err := util.ResolveAuthDir(cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		cacheDir = resolved
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(acmeCfg.Domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      acmeCfg.Email,
+	}
+	if acmeCfg.DirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: acmeCfg.DirectoryURL}
+	}
+	return manager, nil
+}
+
+// startRedirectServer serves handler on addr in the background until Stop.
+func (s *Server) startRedirectServer(addr string, handler http.Handler) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.redirectMu.Lock()
+	s.redirectServer = server
+	s.redirectMu.Unlock()
+
+	go func() {
+		log.Debugf("Starting HTTPS redirect server on %s", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("HTTPS redirect server on %s failed: %v", addr, err)
+		}
+	}()
+}
+
+// httpsRedirectHandler redirects every request to the same host and URI over
+// HTTPS on the port of httpsAddr. Methods other than GET and HEAD get a 308
+// so clients repeat them with the original body.
+func httpsRedirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		method    string
+		target    string
+		wantCode  int
+		wantURL   string
+	}{
+		{"default port", ":443", http.MethodGet, "http://proxy.example.com/v1/models?x=1", http.StatusMovedPermanently, "https://proxy.example.com/v1/models?x=1"},
+		{"custom port", ":8317", http.MethodGet, "http://proxy.example.com:80/v1/models", http.StatusMovedPermanently, "https://proxy.example.com:8317/v1/models"},
+		{"post keeps method", ":443", http.MethodPost, "http://proxy.example.com/v1/chat/completions", http.StatusPermanentRedirect, "https://proxy.example.com/v1/chat/completions"},
+		{"ipv6 host", "[::]:8443", http.MethodGet, "http://[::1]:80/", http.StatusMovedPermanently, "https://[::1]:8443/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			httpsRedirectHandler(tt.httpsAddr).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantURL {
+				t.Fatalf("location = %q, want %q", got, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestNewACMEManager(t *testing.T) {
+	cfg := &proxyconfig.Config{}
+	cfg.TLS.ACME.Enable = true
+	if _, err := newACMEManager(cfg); err == nil {
+		t.Fatal("expected error without domains")
+	}
+
+	cfg.TLS.ACME.Domains = []string{"proxy.example.com"}
+	if _, err := newACMEManager(cfg); err == nil {
+		t.Fatal("expected error without cache-dir or auth-dir")
+	}
+
+	cfg.AuthDir = t.TempDir()
+	cfg.TLS.ACME.DirectoryURL = "https://acme-staging-v02.api.letsencrypt.org/directory"
+	manager, err := newACMEManager(cfg)
+	if err != nil {
+		t.Fatalf("newACMEManager: %v", err)
+	}
+	if manager.Client == nil || manager.Client.DirectoryURL != cfg.TLS.ACME.DirectoryURL {
+		t.Fatalf("directory url not applied")
+	}
+	if got, want := manager.Cache, autocert.DirCache(filepath.Join(cfg.AuthDir, "acme")); got != want {
+		t.Fatalf("cache = %v, want %v", got, want)
+	}
+	if err := manager.HostPolicy(t.Context(), "proxy.example.com"); err != nil {
+		t.Fatalf("configured domain rejected: %v", err)
+	}
+	if err := manager.HostPolicy(t.Context(), "other.example.com"); err == nil {
+		t.Fatal("unconfigured domain accepted")
+	}
+}
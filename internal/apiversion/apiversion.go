@@ -0,0 +1,152 @@
+// Package apiversion holds the upstream API version pinned for each provider
+// by the api-versions config, and the version each provider uses by default.
+// Executors build their URLs and version headers from it, and translators
+// whose output depends on the upstream API shape branch on For or AtLeast
+// instead of assuming the latest version.
+package apiversion
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Versions the executors use when nothing is pinned.
+const (
+	// ClaudeDefault is the Anthropic-Version header value.
+	ClaudeDefault = "2023-06-01"
+	// GeminiDefault is the Generative Language API path version.
+	GeminiDefault = "v1beta"
+	// VertexDefault is the Vertex AI path version.
+	VertexDefault = "v1"
+	// CodeAssistDefault is the Gemini Code Assist path version.
+	CodeAssistDefault = "v1internal"
+)
+
+var defaults = map[string]string{
+	"claude":     ClaudeDefault,
+	"gemini":     GeminiDefault,
+	"aistudio":   GeminiDefault,
+	"vertex":     VertexDefault,
+	"gemini-cli": CodeAssistDefault,
+}
+
+var pins atomic.Pointer[map[string]string]
+
+// Apply replaces the pinned versions with those of the api-versions config,
+// keyed by provider ID or openai-compatibility name.
+func Apply(versions map[string]string) {
+	next := make(map[string]string, len(versions))
+	for provider, version := range versions {
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		version = strings.TrimSpace(version)
+		if provider != "" && version != "" {
+			next[provider] = version
+		}
+	}
+	pins.Store(&next)
+}
+
+// Pinned returns the version pinned for provider, or "" when none is.
+func Pinned(provider string) string {
+	current := pins.Load()
+	if current == nil {
+		return ""
+	}
+	return (*current)[strings.ToLower(strings.TrimSpace(provider))]
+}
+
+// Default returns the version provider uses when nothing is pinned, or ""
+// for providers whose API is not versioned by the proxy.
+func Default(provider string) string {
+	return defaults[strings.ToLower(strings.TrimSpace(provider))]
+}
+
+// For returns the version requests to provider are sent with: the pinned
+// version, or the default. Credentials with their own api-version override
+// it in the executors; translators, which do not see credentials, go by the
+// provider's version.
+func For(provider string) string {
+	if version := Pinned(provider); version != "" {
+		return version
+	}
+	return Default(provider)
+}
+
+// AtLeast reports whether provider's version is version or a later one. It
+// is false when provider has no version.
+func AtLeast(provider, version string) bool {
+	current := For(provider)
+	return current != "" && Compare(current, version) >= 0
+}
+
+// stage ranks the pre-release suffix of a path version below the stable
+// release: v1alpha < v1beta < v1. Other suffixes, such as "internal", rank
+// with the stable release and are then ordered by name.
+func stage(suffix string) int {
+	switch {
+	case strings.HasPrefix(suffix, "alpha"):
+		return 0
+	case strings.HasPrefix(suffix, "beta"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// pathVersion splits a version such as "v1beta1" or "v2.1" into its numbers
+// and suffix.
+func pathVersion(version string) (major, minor int, suffix string, ok bool) {
+	rest, found := strings.CutPrefix(strings.ToLower(version), "v")
+	if !found {
+		return 0, 0, "", false
+	}
+	end := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+	if end == 0 {
+		return 0, 0, "", false
+	}
+	if end < 0 {
+		end = len(rest)
+	}
+	major, _ = strconv.Atoi(rest[:end])
+	rest = rest[end:]
+	if after, isMinor := strings.CutPrefix(rest, "."); isMinor {
+		end = strings.IndexFunc(after, func(r rune) bool { return r < '0' || r > '9' })
+		if end < 0 {
+			end = len(after)
+		}
+		minor, _ = strconv.Atoi(after[:end])
+		rest = after[end:]
+	}
+	return major, minor, rest, true
+}
+
+// Compare orders two versions of the same provider, returning -1, 0 or 1.
+// Path versions ("v1beta", "v1", "v2.1") compare by number and then stage;
+// date versions ("2023-06-01") and anything else compare as strings.
+func Compare(a, b string) int {
+	aMajor, aMinor, aSuffix, aOK := pathVersion(a)
+	bMajor, bMinor, bSuffix, bOK := pathVersion(b)
+	if aOK && bOK {
+		switch {
+		case aMajor != bMajor:
+			return sign(aMajor - bMajor)
+		case aMinor != bMinor:
+			return sign(aMinor - bMinor)
+		case stage(aSuffix) != stage(bSuffix):
+			return sign(stage(aSuffix) - stage(bSuffix))
+		}
+		return strings.Compare(aSuffix, bSuffix)
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
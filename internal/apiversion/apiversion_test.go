@@ -0,0 +1,48 @@
+package apiversion
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"v1beta", "v1", -1},
+		{"v1alpha", "v1beta", -1},
+		{"v1beta1", "v1beta", 1},
+		{"v1", "v1", 0},
+		{"v2", "v1", 1},
+		{"v1.1", "v1", 1},
+		{"v1internal", "v1", 1},
+		{"2023-06-01", "2024-10-22", -1},
+		{"2024-10-22", "2024-10-22", 0},
+	}
+	for _, tc := range cases {
+		if got := Compare(tc.a, tc.b); got != tc.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestApplyPinsOverDefaults(t *testing.T) {
+	t.Cleanup(func() { Apply(nil) })
+	Apply(map[string]string{" Gemini ": "v1", "my-gateway": "2024-10-21", "vertex": " "})
+
+	if got := For("gemini"); got != "v1" {
+		t.Fatalf("For(gemini) = %q, want the pinned v1", got)
+	}
+	if got := For("vertex"); got != VertexDefault {
+		t.Fatalf("For(vertex) = %q, want the default for a blank pin", got)
+	}
+	if got := Pinned("MY-GATEWAY"); got != "2024-10-21" {
+		t.Fatalf("Pinned(my-gateway) = %q", got)
+	}
+	if !AtLeast("gemini", "v1beta") || AtLeast("claude", "2024-01-01") || AtLeast("codex", "v1") {
+		t.Fatal("AtLeast does not follow the pinned and default versions")
+	}
+
+	Apply(nil)
+	if got := For("gemini"); got != GeminiDefault {
+		t.Fatalf("For(gemini) after clearing = %q", got)
+	}
+}
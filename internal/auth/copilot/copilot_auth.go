@@ -117,9 +117,7 @@ func (c *CopilotAuth) GetCopilotAPIToken(ctx context.Context, githubAccessToken
 
 	req.Header.Set("Authorization", "token "+githubAccessToken)
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", copilotUserAgent)
-	req.Header.Set("Editor-Version", copilotEditorVersion)
-	req.Header.Set("Editor-Plugin-Version", copilotPluginVersion)
+	c.setEditorHeaders(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -216,15 +214,30 @@ func (c *CopilotAuth) MakeAuthenticatedRequest(ctx context.Context, method, requ
 	req.Header.Set("Authorization", "Bearer "+apiToken.Token)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", copilotUserAgent)
-	req.Header.Set("Editor-Version", copilotEditorVersion)
-	req.Header.Set("Editor-Plugin-Version", copilotPluginVersion)
+	c.setEditorHeaders(req)
 	req.Header.Set("Openai-Intent", copilotIntentForRequestURL(requestURL))
-	req.Header.Set("Copilot-Integration-Id", copilotIntegrationID)
+	req.Header.Set("Copilot-Integration-Id", c.headerPolicyValue(func(p config.GitHubCopilotHeaderPolicyConfig) string { return p.IntegrationID }, copilotIntegrationID))
 
 	return req, nil
 }
 
+// setEditorHeaders applies the editor identity headers, preferring the values
+// configured under github-copilot.header-policy over the built-in defaults.
+func (c *CopilotAuth) setEditorHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", c.headerPolicyValue(func(p config.GitHubCopilotHeaderPolicyConfig) string { return p.UserAgent }, copilotUserAgent))
+	req.Header.Set("Editor-Version", c.headerPolicyValue(func(p config.GitHubCopilotHeaderPolicyConfig) string { return p.EditorVersion }, copilotEditorVersion))
+	req.Header.Set("Editor-Plugin-Version", c.headerPolicyValue(func(p config.GitHubCopilotHeaderPolicyConfig) string { return p.EditorPluginVersion }, copilotPluginVersion))
+}
+
+func (c *CopilotAuth) headerPolicyValue(field func(config.GitHubCopilotHeaderPolicyConfig) string, fallback string) string {
+	if c != nil && c.cfg != nil {
+		if value := strings.TrimSpace(field(c.cfg.GitHubCopilot.HeaderPolicy)); value != "" {
+			return value
+		}
+	}
+	return fallback
+}
+
 func copilotIntentForRequestURL(requestURL string) string {
 	parsed, err := url.Parse(requestURL)
 	if err == nil {
@@ -33,6 +33,10 @@ type GeminiTokenStorage struct {
 	// Checked indicates if the associated Cloud AI API has been verified as enabled.
 	Checked bool `json:"checked"`
 
+	// Tier is the Code Assist user tier (e.g. "free-tier", "standard-tier") the
+	// project was onboarded with.
+	Tier string `json:"tier,omitempty"`
+
 	// Type indicates the authentication provider type, always "gemini" for this storage.
 	Type string `json:"type"`
 
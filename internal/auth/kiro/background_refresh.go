@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
 	"golang.org/x/sync/semaphore"
 )
 
@@ -126,6 +127,10 @@ func (r *BackgroundRefresher) Stop() {
 }
 
 func (r *BackgroundRefresher) refreshBatch(ctx context.Context) {
+	// 集群模式下仅由 leader 副本刷新，避免多个副本争用同一 refresh token
+	if !sharedstate.IsLeader() {
+		return
+	}
 	tokens := r.tokenRepo.FindOldestUnverified(r.batchSize)
 	if len(tokens) == 0 {
 		return
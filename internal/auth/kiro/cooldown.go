@@ -31,14 +31,33 @@ func NewCooldownManager() *CooldownManager {
 func (cm *CooldownManager) SetCooldown(tokenKey string, duration time.Duration, reason string) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
-	cm.cooldowns[tokenKey] = time.Now().Add(duration)
+	endTime := time.Now().Add(duration)
+	cm.cooldowns[tokenKey] = endTime
 	cm.reasons[tokenKey] = reason
+	storeSharedJSON(sharedCooldownKeyPrefix+tokenKey, sharedCooldown{End: endTime, Reason: reason}, duration)
 }
 
-func (cm *CooldownManager) IsInCooldown(tokenKey string) bool {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
+// lookup returns the cooldown for tokenKey. When replicas share state the
+// shared record wins, so cooldowns set or cleared elsewhere apply here too.
+func (cm *CooldownManager) lookup(tokenKey string) (time.Time, string, bool) {
+	var shared sharedCooldown
+	found, authoritative := loadSharedJSON(sharedCooldownKeyPrefix+tokenKey, &shared)
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	switch {
+	case found:
+		cm.cooldowns[tokenKey] = shared.End
+		cm.reasons[tokenKey] = shared.Reason
+	case authoritative:
+		delete(cm.cooldowns, tokenKey)
+		delete(cm.reasons, tokenKey)
+	}
 	endTime, exists := cm.cooldowns[tokenKey]
+	return endTime, cm.reasons[tokenKey], exists
+}
+
+func (cm *CooldownManager) IsInCooldown(tokenKey string) bool {
+	endTime, _, exists := cm.lookup(tokenKey)
 	if !exists {
 		return false
 	}
@@ -46,9 +65,7 @@ func (cm *CooldownManager) IsInCooldown(tokenKey string) bool {
 }
 
 func (cm *CooldownManager) GetRemainingCooldown(tokenKey string) time.Duration {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-	endTime, exists := cm.cooldowns[tokenKey]
+	endTime, _, exists := cm.lookup(tokenKey)
 	if !exists {
 		return 0
 	}
@@ -60,9 +77,8 @@ func (cm *CooldownManager) GetRemainingCooldown(tokenKey string) time.Duration {
 }
 
 func (cm *CooldownManager) GetCooldownReason(tokenKey string) string {
-	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-	return cm.reasons[tokenKey]
+	_, reason, _ := cm.lookup(tokenKey)
+	return reason
 }
 
 func (cm *CooldownManager) ClearCooldown(tokenKey string) {
@@ -70,6 +86,7 @@ func (cm *CooldownManager) ClearCooldown(tokenKey string) {
 	defer cm.mu.Unlock()
 	delete(cm.cooldowns, tokenKey)
 	delete(cm.reasons, tokenKey)
+	deleteShared(sharedCooldownKeyPrefix + tokenKey)
 }
 
 func (cm *CooldownManager) CleanupExpired() {
@@ -117,6 +117,23 @@ func (rl *RateLimiter) getOrCreateState(tokenKey string) *TokenState {
 	return state
 }
 
+// syncFromSharedLocked 多副本部署时以共享存储中的状态为准
+func (rl *RateLimiter) syncFromSharedLocked(tokenKey string) {
+	var shared TokenState
+	found, authoritative := loadSharedJSON(sharedRateStateKeyPrefix+tokenKey, &shared)
+	switch {
+	case found:
+		rl.states[tokenKey] = &shared
+	case authoritative:
+		delete(rl.states, tokenKey)
+	}
+}
+
+// publishState 将 Token 状态写入共享存储（单实例时无操作）
+func (rl *RateLimiter) publishState(tokenKey string, state TokenState) {
+	storeSharedJSON(sharedRateStateKeyPrefix+tokenKey, state, sharedRateStateTTL)
+}
+
 // resetDailyIfNeeded 如果需要则重置每日计数
 func (rl *RateLimiter) resetDailyIfNeeded(state *TokenState) {
 	now := time.Now()
@@ -136,6 +153,7 @@ func (rl *RateLimiter) calculateInterval() time.Duration {
 // WaitForToken 等待 Token 可用（带抖动的随机间隔）
 func (rl *RateLimiter) WaitForToken(tokenKey string) {
 	rl.mu.Lock()
+	rl.syncFromSharedLocked(tokenKey)
 	state := rl.getOrCreateState(tokenKey)
 	rl.resetDailyIfNeeded(state)
 
@@ -166,7 +184,9 @@ func (rl *RateLimiter) WaitForToken(tokenKey string) {
 	state.LastRequest = time.Now()
 	state.RequestCount++
 	state.DailyRequests++
+	snapshot := *state
 	rl.mu.Unlock()
+	rl.publishState(tokenKey, snapshot)
 }
 
 // MarkTokenFailed 标记 Token 失败
@@ -174,9 +194,11 @@ func (rl *RateLimiter) MarkTokenFailed(tokenKey string) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
+	rl.syncFromSharedLocked(tokenKey)
 	state := rl.getOrCreateState(tokenKey)
 	state.FailCount++
 	state.CooldownEnd = time.Now().Add(rl.calculateBackoff(state.FailCount))
+	rl.publishState(tokenKey, *state)
 }
 
 // MarkTokenSuccess 标记 Token 成功
@@ -184,9 +206,11 @@ func (rl *RateLimiter) MarkTokenSuccess(tokenKey string) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
+	rl.syncFromSharedLocked(tokenKey)
 	state := rl.getOrCreateState(tokenKey)
 	state.FailCount = 0
 	state.CooldownEnd = time.Time{}
+	rl.publishState(tokenKey, *state)
 }
 
 // CheckAndMarkSuspended 检测暂停错误并标记
@@ -213,6 +237,7 @@ func (rl *RateLimiter) CheckAndMarkSuspended(tokenKey string, errorMsg string) b
 			state.SuspendedAt = time.Now()
 			state.SuspendReason = errorMsg
 			state.CooldownEnd = time.Now().Add(rl.suspendCooldown)
+			rl.publishState(tokenKey, *state)
 			return true
 		}
 	}
@@ -221,6 +246,10 @@ func (rl *RateLimiter) CheckAndMarkSuspended(tokenKey string, errorMsg string) b
 
 // IsTokenAvailable 检查 Token 是否可用
 func (rl *RateLimiter) IsTokenAvailable(tokenKey string) bool {
+	rl.mu.Lock()
+	rl.syncFromSharedLocked(tokenKey)
+	rl.mu.Unlock()
+
 	rl.mu.RLock()
 	defer rl.mu.RUnlock()
 
@@ -298,6 +327,7 @@ func (rl *RateLimiter) ClearTokenState(tokenKey string) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 	delete(rl.states, tokenKey)
+	deleteShared(sharedRateStateKeyPrefix + tokenKey)
 }
 
 // ResetSuspension 重置暂停状态
@@ -312,5 +342,6 @@ func (rl *RateLimiter) ResetSuspension(tokenKey string) {
 		state.SuspendReason = ""
 		state.CooldownEnd = time.Time{}
 		state.FailCount = 0
+		rl.publishState(tokenKey, *state)
 	}
 }
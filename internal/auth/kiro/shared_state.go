@@ -0,0 +1,62 @@
+package kiro
+
+import (
+	"context"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+	log "github.com/sirupsen/logrus"
+)
+
+// 多副本部署时，频率限制与冷却状态通过共享存储同步，
+// 使同一 Token 在所有副本上遵守同一套间隔、退避和暂停规则。
+const (
+	sharedRateStateKeyPrefix = "kiro-rate:"
+	sharedCooldownKeyPrefix  = "kiro-cooldown:"
+	sharedRateStateTTL       = 48 * time.Hour
+	sharedStateTimeout       = 2 * time.Second
+)
+
+// sharedCooldown 共享存储中的冷却记录
+type sharedCooldown struct {
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason"`
+}
+
+// loadSharedJSON 读取共享状态。authoritative 表示共享存储可用，
+// 此时 found 为 false 即代表其他副本已清除该状态。
+func loadSharedJSON(key string, out any) (found, authoritative bool) {
+	if !sharedstate.Distributed() {
+		return false, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	ok, errGet := sharedstate.GetJSON(ctx, key, out)
+	if errGet != nil {
+		log.Debugf("kiro: shared state read %s failed: %v", key, errGet)
+		return false, false
+	}
+	return ok, true
+}
+
+func storeSharedJSON(key string, value any, ttl time.Duration) {
+	if !sharedstate.Distributed() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	if errSet := sharedstate.SetJSON(ctx, key, value, ttl); errSet != nil {
+		log.Debugf("kiro: shared state write %s failed: %v", key, errSet)
+	}
+}
+
+func deleteShared(key string) {
+	if !sharedstate.Distributed() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	if errDelete := sharedstate.Current().Delete(ctx, key); errDelete != nil {
+		log.Debugf("kiro: shared state delete %s failed: %v", key, errDelete)
+	}
+}
@@ -0,0 +1,41 @@
+package kiro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+)
+
+func TestSharedState_CooldownsAndSuspensionsSpanReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	if err := sharedstate.Apply(config.SharedStateConfig{Backend: sharedstate.BackendRedis, RedisURL: "redis://" + server.Addr()}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	defer func() { _ = sharedstate.Close() }()
+
+	// Two managers stand in for two replicas serving the same token.
+	cmA, cmB := NewCooldownManager(), NewCooldownManager()
+	cmA.SetCooldown("token1", time.Minute, CooldownReason429)
+	if !cmB.IsInCooldown("token1") || cmB.GetCooldownReason("token1") != CooldownReason429 {
+		t.Fatal("cooldown set on one replica should apply to the other")
+	}
+	cmA.ClearCooldown("token1")
+	if cmB.IsInCooldown("token1") {
+		t.Fatal("cleared cooldown should clear on the other replica too")
+	}
+
+	rlA, rlB := NewRateLimiter(), NewRateLimiter()
+	if !rlA.CheckAndMarkSuspended("token1", "Account has been suspended") {
+		t.Fatal("expected suspension to be detected")
+	}
+	if rlB.IsTokenAvailable("token1") {
+		t.Fatal("suspension on one replica should apply to the other")
+	}
+	rlA.ResetSuspension("token1")
+	if !rlB.IsTokenAvailable("token1") {
+		t.Fatal("reset suspension should apply to the other replica")
+	}
+}
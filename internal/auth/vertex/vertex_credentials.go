@@ -1,5 +1,6 @@
-// Package vertex provides token storage for Google Vertex AI Gemini via service account credentials.
-// It serialises service account JSON into an auth file that is consumed by the runtime executor.
+// Package vertex provides token storage for Google Vertex AI Gemini credentials.
+// It serialises service account JSON, authorized user JSON or a workload identity
+// marker into an auth file that is consumed by the runtime executor.
 package vertex
 
 import (
@@ -7,17 +8,61 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
 	log "github.com/sirupsen/logrus"
 )
 
-// VertexCredentialStorage stores the service account JSON for Vertex AI access.
+// Supported values for the "credential_source" auth metadata field.
+const (
+	// CredentialSourceServiceAccount mints tokens from an embedded service account key.
+	CredentialSourceServiceAccount = "service_account"
+	// CredentialSourceAuthorizedUser refreshes tokens from user OAuth credentials
+	// (gcloud "authorized_user" JSON).
+	CredentialSourceAuthorizedUser = "authorized_user"
+	// CredentialSourceWorkloadIdentity obtains tokens from the GCE/GKE metadata server.
+	CredentialSourceWorkloadIdentity = "workload_identity"
+)
+
+// CredentialSourceFromMetadata resolves the credential source for a Vertex auth.
+// An explicit "credential_source" wins; otherwise the source is inferred from the
+// embedded credential JSON, defaulting to service account.
+func CredentialSourceFromMetadata(metadata map[string]any) string {
+	if raw, ok := metadata["credential_source"].(string); ok {
+		switch strings.ToLower(strings.TrimSpace(raw)) {
+		case CredentialSourceWorkloadIdentity, "workload-identity", "metadata", "gce", "gke":
+			return CredentialSourceWorkloadIdentity
+		case CredentialSourceAuthorizedUser, "authorized-user", "user":
+			return CredentialSourceAuthorizedUser
+		case CredentialSourceServiceAccount, "service-account":
+			return CredentialSourceServiceAccount
+		}
+	}
+	if _, ok := metadata["authorized_user"].(map[string]any); ok {
+		return CredentialSourceAuthorizedUser
+	}
+	if sa, ok := metadata["service_account"].(map[string]any); ok {
+		if kind, _ := sa["type"].(string); strings.EqualFold(strings.TrimSpace(kind), CredentialSourceAuthorizedUser) {
+			return CredentialSourceAuthorizedUser
+		}
+	}
+	return CredentialSourceServiceAccount
+}
+
+// VertexCredentialStorage stores the credentials for Vertex AI access.
 // The content is persisted verbatim under the "service_account" key, together with
 // helper fields for project, location and email to improve logging and discovery.
 type VertexCredentialStorage struct {
 	// ServiceAccount holds the parsed service account JSON content.
-	ServiceAccount map[string]any `json:"service_account"`
+	ServiceAccount map[string]any `json:"service_account,omitempty"`
+
+	// AuthorizedUser holds gcloud authorized_user JSON content for user OAuth credentials.
+	AuthorizedUser map[string]any `json:"authorized_user,omitempty"`
+
+	// CredentialSource selects how access tokens are obtained (see CredentialSource*).
+	// Empty means service account.
+	CredentialSource string `json:"credential_source,omitempty"`
 
 	// ProjectID is derived from the service account JSON (project_id).
 	ProjectID string `json:"project_id"`
@@ -39,8 +84,17 @@ func (s *VertexCredentialStorage) SaveTokenToFile(authFilePath string) error {
 	if s == nil {
 		return fmt.Errorf("vertex credential: storage is nil")
 	}
-	if s.ServiceAccount == nil {
-		return fmt.Errorf("vertex credential: service account content is empty")
+	switch s.CredentialSource {
+	case CredentialSourceWorkloadIdentity:
+		// Tokens come from the metadata server; nothing is embedded.
+	case CredentialSourceAuthorizedUser:
+		if s.AuthorizedUser == nil {
+			return fmt.Errorf("vertex credential: authorized user content is empty")
+		}
+	default:
+		if s.ServiceAccount == nil {
+			return fmt.Errorf("vertex credential: service account content is empty")
+		}
 	}
 	// Ensure we tag the file with the provider type.
 	s.Type = "vertex"
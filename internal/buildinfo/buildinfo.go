@@ -12,4 +12,8 @@ var (
 
 	// BuildDate records when the binary was built in UTC.
 	BuildDate = "unknown"
+
+	// ReleasePublicKey is the base64 Ed25519 key release checksums are signed
+	// with. The update subcommand refuses to install builds it cannot verify.
+	ReleasePublicKey = ""
 )
@@ -67,6 +67,7 @@ func startCacheCleanup() {
 		defer ticker.Stop()
 		for range ticker.C {
 			purgeExpiredCaches()
+			purgeExpiredThinkingTexts(time.Now())
 		}
 	}()
 }
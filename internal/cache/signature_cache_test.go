@@ -208,3 +208,20 @@ func TestCacheSignature_ExpirationLogic(t *testing.T) {
 	// but the logic is verified by the implementation
 	_ = time.Now() // Acknowledge we're not testing time passage
 }
+
+func TestRememberThinkingText(t *testing.T) {
+	signature := "thinkingTextSignature_12345678901234567890123456789012345678"
+
+	RememberThinkingText(signature, "original reasoning")
+	if got := RecallThinkingText(signature); got != "original reasoning" {
+		t.Fatalf("Expected the remembered text, got '%s'", got)
+	}
+
+	RememberThinkingText("short", "ignored")
+	if got := RecallThinkingText("short"); got != "" {
+		t.Errorf("Expected no text for an invalid signature, got '%s'", got)
+	}
+	if got := RecallThinkingText("unknownSignature_123456789012345678901234567890123456789"); got != "" {
+		t.Errorf("Expected no text for an unknown signature, got '%s'", got)
+	}
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// thinkingTextEntry holds the original text of a signed thinking block.
+type thinkingTextEntry struct {
+	Text      string
+	Timestamp time.Time
+}
+
+// thinkingTexts maps a signature hash to the text it was issued for.
+var (
+	thinkingTextsMu sync.Mutex
+	thinkingTexts   = make(map[string]thinkingTextEntry)
+)
+
+// RememberThinkingText records the text a thinking signature was issued for.
+// It is used when the proxy rewrites signed thinking before handing it to a
+// client, so the block can be restored when the client sends it back.
+func RememberThinkingText(signature, text string) {
+	if text == "" || len(signature) < MinValidSignatureLen {
+		return
+	}
+	cacheCleanupOnce.Do(startCacheCleanup)
+	thinkingTextsMu.Lock()
+	defer thinkingTextsMu.Unlock()
+	thinkingTexts[hashText(signature)] = thinkingTextEntry{Text: text, Timestamp: time.Now()}
+}
+
+// RecallThinkingText returns the text recorded for signature, or an empty
+// string if none was recorded or it expired.
+func RecallThinkingText(signature string) string {
+	if signature == "" {
+		return ""
+	}
+	key := hashText(signature)
+	now := time.Now()
+	thinkingTextsMu.Lock()
+	defer thinkingTextsMu.Unlock()
+	entry, ok := thinkingTexts[key]
+	if !ok {
+		return ""
+	}
+	if now.Sub(entry.Timestamp) > SignatureCacheTTL {
+		delete(thinkingTexts, key)
+		return ""
+	}
+	entry.Timestamp = now
+	thinkingTexts[key] = entry
+	return entry.Text
+}
+
+// purgeExpiredThinkingTexts removes recorded texts past SignatureCacheTTL.
+func purgeExpiredThinkingTexts(now time.Time) {
+	thinkingTextsMu.Lock()
+	defer thinkingTextsMu.Unlock()
+	for key, entry := range thinkingTexts {
+		if now.Sub(entry.Timestamp) > SignatureCacheTTL {
+			delete(thinkingTexts, key)
+		}
+	}
+}
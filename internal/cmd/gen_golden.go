@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/golden"
+	"github.com/tidwall/gjson"
+)
+
+// headerFlags collects repeated -header "Name: value" flags.
+type headerFlags []string
+
+func (h *headerFlags) String() string { return strings.Join(*h, ", ") }
+
+func (h *headerFlags) Set(value string) error {
+	if _, _, ok := strings.Cut(value, ":"); !ok {
+		return fmt.Errorf("header %q must be in Name: value form", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// RunGenGolden implements the gen-golden subcommand. Each JSONL client
+// request is translated, sent to -url with the given headers, and written to
+// -dir as a golden file holding the sanitized request, the upstream body and
+// the client response the translators produce for it. Headers are never
+// recorded. It returns the process exit code.
+func RunGenGolden(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gen-golden", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	from := fs.String("from", "openai", "Source request format (openai, openai-response, claude, gemini, ...)")
+	to := fs.String("to", "", "Target provider format (gemini, gemini-cli, antigravity, claude, codex, openai, ...)")
+	model := fs.String("model", "", "Model for every request (defaults to each request's model field)")
+	stream := fs.Bool("stream", false, "Record streaming exchanges")
+	url := fs.String("url", "", "Upstream endpoint the translated requests are posted to")
+	name := fs.String("name", "", "Case name; line numbers are appended when the input has several requests")
+	dir := fs.String("dir", filepath.Join("internal", "translator", "golden", "testdata"), "Directory golden files are written to")
+	inPath := fs.String("in", "-", "Input JSONL file, or - for stdin")
+	timeout := fs.Duration("timeout", 5*time.Minute, "Timeout for each upstream request")
+	var headers headerFlags
+	fs.Var(&headers, "header", "Upstream request header as \"Name: value\" (repeatable)")
+	if errParse := fs.Parse(args); errParse != nil {
+		return 2
+	}
+	if strings.TrimSpace(*to) == "" || strings.TrimSpace(*url) == "" || strings.TrimSpace(*name) == "" {
+		_, _ = fmt.Fprintln(stderr, "gen-golden: -to, -url and -name are required")
+		return 2
+	}
+
+	in := stdin
+	if *inPath != "-" {
+		file, errOpen := os.Open(*inPath)
+		if errOpen != nil {
+			_, _ = fmt.Fprintf(stderr, "gen-golden: %v\n", errOpen)
+			return 1
+		}
+		defer func() { _ = file.Close() }()
+		in = file
+	}
+	data, errRead := io.ReadAll(in)
+	if errRead != nil {
+		_, _ = fmt.Fprintf(stderr, "gen-golden: read input: %v\n", errRead)
+		return 1
+	}
+	var requests [][]byte
+	var lineNos []int
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			requests = append(requests, line)
+			lineNos = append(lineNos, i+1)
+		}
+	}
+	if errMkdir := os.MkdirAll(*dir, 0o755); errMkdir != nil {
+		_, _ = fmt.Fprintf(stderr, "gen-golden: %v\n", errMkdir)
+		return 1
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	failed := 0
+	for i, request := range requests {
+		caseName := *name
+		if len(requests) > 1 {
+			caseName = fmt.Sprintf("%s-%d", *name, lineNos[i])
+		}
+		path := filepath.Join(*dir, caseName+".json")
+		if errRecord := recordGolden(client, headers, *url, path, caseName, *from, *to, *model, *stream, request); errRecord != nil {
+			failed++
+			_, _ = fmt.Fprintf(stderr, "line %d: %v\n", lineNos[i], errRecord)
+			continue
+		}
+		_, _ = fmt.Fprintln(stdout, path)
+	}
+	if failed > 0 {
+		_, _ = fmt.Fprintf(stderr, "gen-golden: %d of %d requests failed\n", failed, len(requests))
+		return 1
+	}
+	return 0
+}
+
+func recordGolden(client *http.Client, headers headerFlags, url, path, name, from, to, model string, stream bool, request []byte) error {
+	if !json.Valid(request) {
+		return fmt.Errorf("invalid JSON")
+	}
+	if model == "" {
+		model = gjson.GetBytes(request, "model").String()
+	}
+	c, err := golden.NewCase(name, from, to, model, stream, request)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(c.UpstreamRequest))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for _, header := range headers {
+		key, value, _ := strings.Cut(header, ":")
+		httpReq.Header.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("read upstream response: %w", err)
+	}
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return fmt.Errorf("upstream returned %d: %s", httpResp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	c.SetUpstreamResponse(body)
+	return golden.Write(path, c)
+}
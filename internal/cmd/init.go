@@ -0,0 +1,373 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// initProvider is a provider the init wizard can set up, either through its
+// login flow or by storing an API key in the config.
+type initProvider struct {
+	label string
+	// login runs the provider's login flow; nil for API-key providers.
+	login func(cfg *config.Config, options *LoginOptions)
+	// keyField is the config list an API key is added to.
+	keyField string
+}
+
+var initProviders = []initProvider{
+	{label: "Gemini CLI (Google OAuth)", login: func(cfg *config.Config, options *LoginOptions) { DoLogin(cfg, "", options) }},
+	{label: "Claude (Anthropic OAuth)", login: DoClaudeLogin},
+	{label: "Codex (OpenAI OAuth)", login: DoCodexLogin},
+	{label: "Antigravity (Google OAuth)", login: DoAntigravityLogin},
+	{label: "GitHub Copilot (device code)", login: DoGitHubCopilotLogin},
+	{label: "Qwen (OAuth)", login: DoQwenLogin},
+	{label: "iFlow (OAuth)", login: DoIFlowLogin},
+	{label: "Kimi (OAuth)", login: DoKimiLogin},
+	{label: "Kiro (Google OAuth)", login: DoKiroLogin},
+	{label: "Kilo AI (device code)", login: DoKiloLogin},
+	{label: "GitLab Duo (OAuth)", login: DoGitLabLogin},
+	{label: "Gemini API key", keyField: "gemini-api-key"},
+	{label: "Claude API key", keyField: "claude-api-key"},
+	{label: "OpenAI API key for Codex", keyField: "codex-api-key"},
+}
+
+// initConfigFile is the config the wizard writes. It holds only the answered
+// settings; everything else keeps its default.
+type initConfigFile struct {
+	Host             string               `yaml:"host"`
+	Port             int                  `yaml:"port"`
+	RemoteManagement initRemoteManagement `yaml:"remote-management"`
+	AuthDir          string               `yaml:"auth-dir"`
+	APIKeys          []string             `yaml:"api-keys"`
+	ProxyURL         string               `yaml:"proxy-url,omitempty"`
+	GeminiKey        []initAPIKey         `yaml:"gemini-api-key,omitempty"`
+	ClaudeKey        []initAPIKey         `yaml:"claude-api-key,omitempty"`
+	CodexKey         []initAPIKey         `yaml:"codex-api-key,omitempty"`
+}
+
+type initRemoteManagement struct {
+	AllowRemote bool   `yaml:"allow-remote"`
+	SecretKey   string `yaml:"secret-key"`
+}
+
+type initAPIKey struct {
+	APIKey string `yaml:"api-key"`
+}
+
+const initConfigHeader = "# Written by the init command. config.example.yaml documents every option.\n\n"
+
+// RunInit implements the init subcommand, an interactive first-run setup. It
+// asks for the listen address, providers and keys, writes a validated config
+// and then runs the login flow of each chosen OAuth provider. It returns the
+// process exit code.
+func RunInit(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "config.yaml", "Config file to write")
+	noBrowser := fs.Bool("no-browser", false, "Print login URLs instead of opening a browser")
+	if errParse := fs.Parse(args); errParse != nil {
+		return 2
+	}
+	path, errAbs := filepath.Abs(*configPath)
+	if errAbs != nil {
+		_, _ = fmt.Fprintf(stderr, "init: %v\n", errAbs)
+		return 1
+	}
+	w := &initWizard{in: bufio.NewReader(stdin), out: stdout}
+	if errRun := w.run(path, *noBrowser); errRun != nil {
+		_, _ = fmt.Fprintf(stderr, "init: %v\n", errRun)
+		return 1
+	}
+	return 0
+}
+
+type initWizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (w *initWizard) run(path string, noBrowser bool) error {
+	_, _ = fmt.Fprintf(w.out, "This sets up %s. Press Enter to accept the value in brackets.\n\n", path)
+	if _, errStat := os.Stat(path); errStat == nil {
+		overwrite, errAsk := w.confirm(path+" already exists. Overwrite it?", false)
+		if errAsk != nil {
+			return errAsk
+		}
+		if !overwrite {
+			return errors.New("left the existing config unchanged")
+		}
+	}
+
+	file := initConfigFile{}
+	localOnly, errLocal := w.confirm("Accept connections from this machine only?", true)
+	if errLocal != nil {
+		return errLocal
+	}
+	if localOnly {
+		file.Host = "127.0.0.1"
+	}
+	port, errPort := w.askInt("Port to listen on", 8317, 1, 65535)
+	if errPort != nil {
+		return errPort
+	}
+	file.Port = port
+	authDir, errAuthDir := w.ask("Directory for provider credentials", "~/.cli-proxy-api")
+	if errAuthDir != nil {
+		return errAuthDir
+	}
+	file.AuthDir = authDir
+	proxyURL, errProxy := w.ask("Upstream proxy URL (socks5://, http://; empty for none)", "")
+	if errProxy != nil {
+		return errProxy
+	}
+	file.ProxyURL = proxyURL
+
+	keyCount, errCount := w.askInt("How many client API keys to generate", 1, 1, 20)
+	if errCount != nil {
+		return errCount
+	}
+	for range keyCount {
+		key, errKey := randomInitKey("sk-")
+		if errKey != nil {
+			return errKey
+		}
+		file.APIKeys = append(file.APIKeys, key)
+	}
+	enableManagement, errManagement := w.confirm("Enable the management API and control panel?", false)
+	if errManagement != nil {
+		return errManagement
+	}
+	if enableManagement {
+		secret, errSecret := randomInitKey("")
+		if errSecret != nil {
+			return errSecret
+		}
+		file.RemoteManagement.SecretKey = secret
+		if !localOnly {
+			if file.RemoteManagement.AllowRemote, errManagement = w.confirm("Allow management from other machines?", false); errManagement != nil {
+				return errManagement
+			}
+		}
+	}
+
+	selected, errProviders := w.chooseProviders()
+	if errProviders != nil {
+		return errProviders
+	}
+	var logins []initProvider
+	for _, provider := range selected {
+		if provider.login != nil {
+			logins = append(logins, provider)
+			continue
+		}
+		key, errKey := w.ask(provider.label, "")
+		if errKey != nil {
+			return errKey
+		}
+		if key == "" {
+			continue
+		}
+		switch provider.keyField {
+		case "gemini-api-key":
+			file.GeminiKey = append(file.GeminiKey, initAPIKey{APIKey: key})
+		case "claude-api-key":
+			file.ClaudeKey = append(file.ClaudeKey, initAPIKey{APIKey: key})
+		case "codex-api-key":
+			file.CodexKey = append(file.CodexKey, initAPIKey{APIKey: key})
+		}
+	}
+
+	if errWrite := writeInitConfig(path, file); errWrite != nil {
+		return errWrite
+	}
+	_, _ = fmt.Fprintf(w.out, "\nWrote %s.\n", path)
+
+	if len(logins) > 0 {
+		cfg, errLoad := config.LoadConfig(path)
+		if errLoad != nil {
+			return fmt.Errorf("load written config: %w", errLoad)
+		}
+		resolvedAuthDir, errResolve := util.ResolveAuthDir(cfg.AuthDir)
+		if errResolve != nil {
+			return fmt.Errorf("resolve auth directory: %w", errResolve)
+		}
+		if errMkdir := os.MkdirAll(resolvedAuthDir, 0o700); errMkdir != nil {
+			return fmt.Errorf("create auth directory: %w", errMkdir)
+		}
+		cfg.AuthDir = resolvedAuthDir
+		options := &LoginOptions{NoBrowser: noBrowser, Prompt: w.prompt}
+		for _, provider := range logins {
+			_, _ = fmt.Fprintf(w.out, "\n== %s ==\n", provider.label)
+			provider.login(cfg, options)
+		}
+	}
+
+	_, _ = fmt.Fprintln(w.out, "\nClient API keys (send as Authorization: Bearer <key>):")
+	for _, key := range file.APIKeys {
+		_, _ = fmt.Fprintf(w.out, "  %s\n", key)
+	}
+	if file.RemoteManagement.SecretKey != "" {
+		_, _ = fmt.Fprintf(w.out, "Management key (stored hashed after the first start, so note it now):\n  %s\n", file.RemoteManagement.SecretKey)
+	}
+	_, _ = fmt.Fprintf(w.out, "\nStart the server with:\n  %s -config %s\n", executableName(), path)
+	_, _ = fmt.Fprintln(w.out, "Run the -login flags later to add more accounts, or install-service to run it in the background.")
+	return nil
+}
+
+// prompt reads one answer for the login flows, sharing the wizard's reader
+// so no buffered input is lost between them.
+func (w *initWizard) prompt(question string) (string, error) {
+	_, _ = fmt.Fprint(w.out, question)
+	line, errRead := w.in.ReadString('\n')
+	if errRead != nil && !(errors.Is(errRead, io.EOF) && line != "") {
+		return "", errRead
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// ask returns the answer to question, or def for an empty answer or once the
+// input is exhausted.
+func (w *initWizard) ask(question, def string) (string, error) {
+	label := question + ": "
+	if def != "" {
+		label = fmt.Sprintf("%s [%s]: ", question, def)
+	}
+	answer, errRead := w.prompt(label)
+	if errors.Is(errRead, io.EOF) {
+		_, _ = fmt.Fprintln(w.out)
+		return def, nil
+	}
+	if errRead != nil {
+		return "", errRead
+	}
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+func (w *initWizard) confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		answer, errAsk := w.ask(question+" ("+hint+")", "")
+		if errAsk != nil {
+			return false, errAsk
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		_, _ = fmt.Fprintln(w.out, "Please answer y or n.")
+	}
+}
+
+func (w *initWizard) askInt(question string, def, minValue, maxValue int) (int, error) {
+	for {
+		answer, errAsk := w.ask(question, strconv.Itoa(def))
+		if errAsk != nil {
+			return 0, errAsk
+		}
+		value, errAtoi := strconv.Atoi(answer)
+		if errAtoi == nil && value >= minValue && value <= maxValue {
+			return value, nil
+		}
+		_, _ = fmt.Fprintf(w.out, "Please enter a number from %d to %d.\n", minValue, maxValue)
+	}
+}
+
+// chooseProviders lists initProviders and returns those picked by number.
+func (w *initWizard) chooseProviders() ([]initProvider, error) {
+	_, _ = fmt.Fprintln(w.out, "\nProviders:")
+	for i, provider := range initProviders {
+		_, _ = fmt.Fprintf(w.out, "  %2d) %s\n", i+1, provider.label)
+	}
+	for {
+		answer, errAsk := w.ask("Providers to set up, comma separated (empty to skip)", "")
+		if errAsk != nil {
+			return nil, errAsk
+		}
+		if answer == "" {
+			return nil, nil
+		}
+		var selected []initProvider
+		seen := make(map[int]bool)
+		valid := true
+		for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' }) {
+			index, errAtoi := strconv.Atoi(field)
+			if errAtoi != nil || index < 1 || index > len(initProviders) {
+				valid = false
+				break
+			}
+			if !seen[index] {
+				seen[index] = true
+				selected = append(selected, initProviders[index-1])
+			}
+		}
+		if valid {
+			return selected, nil
+		}
+		_, _ = fmt.Fprintf(w.out, "Please enter numbers from 1 to %d.\n", len(initProviders))
+	}
+}
+
+// writeInitConfig validates file against the config schema before writing
+// it, readable by the owner only since it holds keys.
+func writeInitConfig(path string, file initConfigFile) error {
+	var buf bytes.Buffer
+	buf.WriteString(initConfigHeader)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if errEncode := enc.Encode(file); errEncode != nil {
+		return fmt.Errorf("encode config: %w", errEncode)
+	}
+	_ = enc.Close()
+	data := buf.Bytes()
+	if errValidate := config.ValidateConfigYAML(filepath.Base(path), data); errValidate != nil {
+		return errValidate
+	}
+	if errMkdir := os.MkdirAll(filepath.Dir(path), 0o755); errMkdir != nil {
+		return fmt.Errorf("create config directory: %w", errMkdir)
+	}
+	if errWrite := os.WriteFile(path, data, 0o600); errWrite != nil {
+		return fmt.Errorf("write config: %w", errWrite)
+	}
+	return nil
+}
+
+// randomInitKey returns prefix followed by 48 random hex characters.
+func randomInitKey(prefix string) (string, error) {
+	buf := make([]byte, 24)
+	if _, errRead := rand.Read(buf); errRead != nil {
+		return "", fmt.Errorf("generate key: %w", errRead)
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+func executableName() string {
+	if executable, errExe := os.Executable(); errExe == nil {
+		return executable
+	}
+	return filepath.Base(os.Args[0])
+}
@@ -30,7 +30,9 @@ func StartService(cfg *config.Config, configPath string, localPassword string) {
 		WithConfigPath(configPath).
 		WithLocalManagementPassword(localPassword)
 
-	ctxSignal, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	// Signals, or a Windows service stop, cancel the run context; Run then
+	// drains in-flight requests before returning.
+	ctxSignal, cancel := shutdownContext()
 	defer cancel()
 
 	runCtx := ctxSignal
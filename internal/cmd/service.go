@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultServiceName names the installed Windows service or systemd unit.
+const defaultServiceName = "cli-proxy-api"
+
+// serviceNamePattern restricts names to those valid as both Windows service
+// names and systemd unit names.
+var serviceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// serviceStopTimeoutSeconds is how long the service manager waits for a
+// stop. It exceeds the 30 seconds Service.Run gives in-flight requests to
+// drain, so the manager does not kill the process mid-drain.
+const serviceStopTimeoutSeconds = 45
+
+// serviceOptions describes the service to install or remove.
+type serviceOptions struct {
+	name       string
+	executable string
+	configPath string
+	workDir    string
+	// user installs a systemd user unit instead of a system unit.
+	user bool
+	// print writes the generated unit to stdout instead of installing it.
+	print bool
+}
+
+// RunServiceCommand implements the install-service and uninstall-service
+// subcommands: it registers the server with the Windows service manager, or
+// as a systemd unit on Linux, started as "<executable> -config <config>". It
+// returns the process exit code.
+func RunServiceCommand(command string, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	name := fs.String("name", defaultServiceName, "Service name")
+	configPath := fs.String("config", "", "Config file the service runs with (default: config.yaml next to the executable)")
+	user := fs.Bool("user", false, "Install a systemd user unit instead of a system unit (Linux only)")
+	printUnit := fs.Bool("print", false, "Print the systemd unit instead of installing it (Linux only)")
+	if errParse := fs.Parse(args); errParse != nil {
+		return 2
+	}
+
+	opts, errOpts := newServiceOptions(*name, *configPath)
+	if errOpts != nil {
+		_, _ = fmt.Fprintf(stderr, "%s: %v\n", command, errOpts)
+		return 1
+	}
+	opts.user = *user
+	opts.print = *printUnit
+
+	var errRun error
+	switch command {
+	case "install-service":
+		errRun = installService(opts, stdout)
+	case "uninstall-service":
+		errRun = uninstallService(opts, stdout)
+	default:
+		errRun = fmt.Errorf("unknown command")
+	}
+	if errRun != nil {
+		_, _ = fmt.Fprintf(stderr, "%s: %v\n", command, errRun)
+		return 1
+	}
+	return 0
+}
+
+// newServiceOptions resolves the executable and config paths to absolute
+// ones, since service managers start processes outside the current
+// directory.
+func newServiceOptions(name, configPath string) (serviceOptions, error) {
+	name = strings.TrimSpace(name)
+	if !serviceNamePattern.MatchString(name) {
+		return serviceOptions{}, fmt.Errorf("invalid service name %q", name)
+	}
+	executable, errExe := os.Executable()
+	if errExe != nil {
+		return serviceOptions{}, fmt.Errorf("locate executable: %w", errExe)
+	}
+	if resolved, errEval := filepath.EvalSymlinks(executable); errEval == nil {
+		executable = resolved
+	}
+	if strings.TrimSpace(configPath) == "" {
+		configPath = filepath.Join(filepath.Dir(executable), "config.yaml")
+	}
+	configPath, errAbs := filepath.Abs(configPath)
+	if errAbs != nil {
+		return serviceOptions{}, fmt.Errorf("resolve config path: %w", errAbs)
+	}
+	return serviceOptions{
+		name:       name,
+		executable: executable,
+		configPath: configPath,
+		workDir:    filepath.Dir(configPath),
+	}, nil
+}
+
+// systemdUnit renders the unit for opts. SIGTERM cancels the run context, so
+// systemctl stop drains in-flight requests before the process exits.
+func systemdUnit(opts serviceOptions) string {
+	wantedBy := "multi-user.target"
+	if opts.user {
+		wantedBy = "default.target"
+	}
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=CLI Proxy API\n")
+	b.WriteString("After=network-online.target\n")
+	b.WriteString("Wants=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s -config %s\n", systemdQuote(opts.executable), systemdQuote(opts.configPath))
+	// WorkingDirectory takes the rest of the line as the path, unquoted.
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", strings.ReplaceAll(opts.workDir, "%", "%%"))
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=5\n")
+	b.WriteString("KillSignal=SIGTERM\n")
+	fmt.Fprintf(&b, "TimeoutStopSec=%d\n", serviceStopTimeoutSeconds)
+	b.WriteString("LimitNOFILE=65536\n\n")
+	b.WriteString("[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=%s\n", wantedBy)
+	return b.String()
+}
+
+// systemdQuote quotes a path for a unit file when it contains spaces or
+// characters systemd treats specially.
+func systemdQuote(value string) string {
+	if !strings.ContainsAny(value, " \t\"'\\$%;") {
+		return value
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$", "%", "%%")
+	return `"` + replacer.Replace(value) + `"`
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// systemdUnitPath returns where the unit for opts is installed.
+func systemdUnitPath(opts serviceOptions) (string, error) {
+	if !opts.user {
+		return filepath.Join("/etc/systemd/system", opts.name+".service"), nil
+	}
+	configDir, errDir := os.UserConfigDir()
+	if errDir != nil {
+		return "", fmt.Errorf("locate user config directory: %w", errDir)
+	}
+	return filepath.Join(configDir, "systemd", "user", opts.name+".service"), nil
+}
+
+func systemctl(opts serviceOptions, args ...string) error {
+	if opts.user {
+		args = append([]string{"--user"}, args...)
+	}
+	out, errRun := exec.Command("systemctl", args...).CombinedOutput()
+	if errRun != nil {
+		return fmt.Errorf("systemctl %v: %w: %s", args, errRun, out)
+	}
+	return nil
+}
+
+// installService writes the systemd unit, then enables and starts it.
+func installService(opts serviceOptions, stdout io.Writer) error {
+	unit := systemdUnit(opts)
+	if opts.print {
+		_, errWrite := io.WriteString(stdout, unit)
+		return errWrite
+	}
+	path, errPath := systemdUnitPath(opts)
+	if errPath != nil {
+		return errPath
+	}
+	if errMkdir := os.MkdirAll(filepath.Dir(path), 0o755); errMkdir != nil {
+		return fmt.Errorf("create unit directory: %w", errMkdir)
+	}
+	if errWrite := os.WriteFile(path, []byte(unit), 0o644); errWrite != nil {
+		return fmt.Errorf("write unit: %w", errWrite)
+	}
+	if errReload := systemctl(opts, "daemon-reload"); errReload != nil {
+		return errReload
+	}
+	if errEnable := systemctl(opts, "enable", "--now", opts.name+".service"); errEnable != nil {
+		return errEnable
+	}
+	_, _ = fmt.Fprintf(stdout, "installed and started %s (%s)\n", opts.name, path)
+	return nil
+}
+
+// uninstallService stops and disables the unit and removes its file.
+func uninstallService(opts serviceOptions, stdout io.Writer) error {
+	path, errPath := systemdUnitPath(opts)
+	if errPath != nil {
+		return errPath
+	}
+	if _, errStat := os.Stat(path); errStat != nil {
+		return fmt.Errorf("unit %s not installed: %w", path, errStat)
+	}
+	// systemctl stop waits for the drain, bounded by TimeoutStopSec.
+	if errDisable := systemctl(opts, "disable", "--now", opts.name+".service"); errDisable != nil {
+		return errDisable
+	}
+	if errRemove := os.Remove(path); errRemove != nil {
+		return fmt.Errorf("remove unit: %w", errRemove)
+	}
+	if errReload := systemctl(opts, "daemon-reload"); errReload != nil {
+		return errReload
+	}
+	_, _ = fmt.Fprintf(stdout, "removed %s (%s)\n", opts.name, path)
+	return nil
+}
+
+// restartService restarts the unit. systemctl waits for the old process to
+// drain before starting the new one.
+func restartService(opts serviceOptions) error {
+	return systemctl(opts, "restart", opts.name+".service")
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !windows
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+func installService(serviceOptions, io.Writer) error {
+	return fmt.Errorf("service installation is not supported on %s", runtime.GOOS)
+}
+
+func uninstallService(serviceOptions, io.Writer) error {
+	return fmt.Errorf("service installation is not supported on %s", runtime.GOOS)
+}
+
+func restartService(serviceOptions) error {
+	return fmt.Errorf("service restarts are not supported on %s", runtime.GOOS)
+}
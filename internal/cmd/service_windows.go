@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// installService registers the executable with the service manager as an
+// automatically started service and starts it.
+func installService(opts serviceOptions, stdout io.Writer) error {
+	if opts.user || opts.print {
+		return errors.New("-user and -print apply to systemd units only")
+	}
+	m, errConnect := mgr.Connect()
+	if errConnect != nil {
+		return fmt.Errorf("connect to service manager: %w", errConnect)
+	}
+	defer func() { _ = m.Disconnect() }()
+
+	if existing, errOpen := m.OpenService(opts.name); errOpen == nil {
+		_ = existing.Close()
+		return fmt.Errorf("service %s already exists", opts.name)
+	}
+	s, errCreate := m.CreateService(opts.name, opts.executable, mgr.Config{
+		DisplayName: "CLI Proxy API",
+		Description: "OpenAI, Gemini and Claude compatible API proxy",
+		StartType:   mgr.StartAutomatic,
+	}, "-config", opts.configPath)
+	if errCreate != nil {
+		return fmt.Errorf("create service: %w", errCreate)
+	}
+	defer func() { _ = s.Close() }()
+
+	restart := []mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 5 * time.Second}}
+	if errRecovery := s.SetRecoveryActions(restart, uint32((24 * time.Hour).Seconds())); errRecovery != nil {
+		log.Warnf("install-service: failed to set recovery actions: %v", errRecovery)
+	}
+	if errStart := s.Start(); errStart != nil {
+		return fmt.Errorf("start service: %w", errStart)
+	}
+	_, _ = fmt.Fprintf(stdout, "installed and started %s; set logging-to-file in %s to keep its logs\n", opts.name, opts.configPath)
+	return nil
+}
+
+// uninstallService stops the service, waiting for it to drain, and removes
+// it from the service manager.
+func uninstallService(opts serviceOptions, stdout io.Writer) error {
+	m, errConnect := mgr.Connect()
+	if errConnect != nil {
+		return fmt.Errorf("connect to service manager: %w", errConnect)
+	}
+	defer func() { _ = m.Disconnect() }()
+
+	s, errOpen := m.OpenService(opts.name)
+	if errOpen != nil {
+		return fmt.Errorf("service %s not installed: %w", opts.name, errOpen)
+	}
+	defer func() { _ = s.Close() }()
+
+	if !stopService(s) {
+		log.Warnf("uninstall-service: %s did not stop within %ds", opts.name, serviceStopTimeoutSeconds)
+	}
+	if errDelete := s.Delete(); errDelete != nil {
+		return fmt.Errorf("delete service: %w", errDelete)
+	}
+	_, _ = fmt.Fprintf(stdout, "removed %s\n", opts.name)
+	return nil
+}
+
+// restartService stops the service, waiting for it to drain, and starts it
+// again so it runs the current executable.
+func restartService(opts serviceOptions) error {
+	m, errConnect := mgr.Connect()
+	if errConnect != nil {
+		return fmt.Errorf("connect to service manager: %w", errConnect)
+	}
+	defer func() { _ = m.Disconnect() }()
+
+	s, errOpen := m.OpenService(opts.name)
+	if errOpen != nil {
+		return fmt.Errorf("service %s not installed: %w", opts.name, errOpen)
+	}
+	defer func() { _ = s.Close() }()
+
+	if !stopService(s) {
+		return fmt.Errorf("%s did not stop within %ds", opts.name, serviceStopTimeoutSeconds)
+	}
+	if errStart := s.Start(); errStart != nil {
+		return fmt.Errorf("start service: %w", errStart)
+	}
+	return nil
+}
+
+// stopService requests a stop and reports whether the service stopped within
+// serviceStopTimeoutSeconds. A service that is not running counts as stopped.
+func stopService(s *mgr.Service) bool {
+	status, errControl := s.Control(svc.Stop)
+	if errControl != nil {
+		current, errQuery := s.Query()
+		return errQuery == nil && current.State == svc.Stopped
+	}
+	deadline := time.Now().Add(serviceStopTimeoutSeconds * time.Second)
+	for status.State != svc.Stopped && time.Now().Before(deadline) {
+		time.Sleep(500 * time.Millisecond)
+		if status, errControl = s.Query(); errControl != nil {
+			return false
+		}
+	}
+	return status.State == svc.Stopped
+}
+
+// windowsService reports the service state to the service manager and turns
+// stop and shutdown requests into a canceled run context.
+type windowsService struct {
+	stop     context.CancelFunc
+	finished chan struct{}
+}
+
+func (s *windowsService) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+	status <- svc.Status{State: svc.StartPending}
+	status <- svc.Status{State: svc.Running, Accepts: accepted}
+	for {
+		select {
+		case <-s.finished:
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending, WaitHint: serviceStopTimeoutSeconds * 1000}
+				s.stop()
+				// Report stopped only once in-flight requests drained.
+				<-s.finished
+				return false, 0
+			}
+		}
+	}
+}
+
+// shutdownContext returns a context canceled by Ctrl+C or, when running
+// under the service manager, by a service stop or system shutdown. The
+// returned function reports the service stopped and must be called once the
+// server has shut down.
+func shutdownContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	isService, errDetect := svc.IsWindowsService()
+	if errDetect != nil || !isService {
+		return ctx, stop
+	}
+
+	handler := &windowsService{stop: stop, finished: make(chan struct{})}
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		if errRun := svc.Run(defaultServiceName, handler); errRun != nil {
+			log.Errorf("windows service: %v", errRun)
+			stop()
+		}
+	}()
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			stop()
+			close(handler.finished)
+			<-runDone
+		})
+	}
+}
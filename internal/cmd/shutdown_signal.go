@@ -0,0 +1,15 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+)
+
+// shutdownContext returns a context canceled by SIGINT or SIGTERM, which is
+// how systemd stops the service.
+func shutdownContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/tidwall/gjson"
+)
+
+// translateMaxLine bounds a single JSONL request read by RunTranslate.
+const translateMaxLine = 64 << 20
+
+// RunTranslate implements the translate subcommand. It reads one request per
+// line in the -from format and writes the payload the server would send for
+// the -to format, one per line. Lines that fail are reported on stderr with
+// their line number and skipped. It returns the process exit code.
+func RunTranslate(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("translate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	from := fs.String("from", "openai", "Source request format (openai, openai-response, claude, gemini, ...)")
+	to := fs.String("to", "", "Target provider format (gemini, gemini-cli, antigravity, claude, codex, openai, ...)")
+	provider := fs.String("provider", "", "Provider key for thinking adaptation and model lookups (defaults to -to)")
+	model := fs.String("model", "", "Model for every request (defaults to each request's model field)")
+	stream := fs.Bool("stream", false, "Translate as streaming requests")
+	configPath := fs.String("config", "", "Config file whose payload rules are applied")
+	inPath := fs.String("in", "-", "Input JSONL file, or - for stdin")
+	outPath := fs.String("out", "-", "Output JSONL file, or - for stdout")
+	if errParse := fs.Parse(args); errParse != nil {
+		return 2
+	}
+	if strings.TrimSpace(*to) == "" {
+		_, _ = fmt.Fprintln(stderr, "translate: -to is required")
+		return 2
+	}
+
+	var cfg *config.Config
+	if *configPath != "" {
+		loaded, errLoad := config.LoadConfig(*configPath)
+		if errLoad != nil {
+			_, _ = fmt.Fprintf(stderr, "translate: load config: %v\n", errLoad)
+			return 1
+		}
+		cfg = loaded
+	}
+
+	in := stdin
+	if *inPath != "-" {
+		file, errOpen := os.Open(*inPath)
+		if errOpen != nil {
+			_, _ = fmt.Fprintf(stderr, "translate: %v\n", errOpen)
+			return 1
+		}
+		defer func() { _ = file.Close() }()
+		in = file
+	}
+	out := stdout
+	if *outPath != "-" {
+		file, errCreate := os.Create(*outPath)
+		if errCreate != nil {
+			_, _ = fmt.Fprintf(stderr, "translate: %v\n", errCreate)
+			return 1
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+	writer := bufio.NewWriter(out)
+	defer func() { _ = writer.Flush() }()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, translateMaxLine)
+	failed, total := 0, 0
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		total++
+		if !json.Valid(line) {
+			failed++
+			_, _ = fmt.Fprintf(stderr, "line %d: invalid JSON\n", lineNo)
+			continue
+		}
+		requestModel := *model
+		if requestModel == "" {
+			requestModel = gjson.GetBytes(line, "model").String()
+		}
+		payload, errTranslate := executor.TranslatePayload(cfg, *from, *to, *provider, requestModel, bytes.Clone(line), *stream)
+		if errTranslate != nil {
+			failed++
+			_, _ = fmt.Fprintf(stderr, "line %d: %v\n", lineNo, errTranslate)
+			continue
+		}
+		var compact bytes.Buffer
+		if errCompact := json.Compact(&compact, payload); errCompact != nil {
+			failed++
+			_, _ = fmt.Fprintf(stderr, "line %d: translated payload is not valid JSON: %v\n", lineNo, errCompact)
+			continue
+		}
+		compact.WriteByte('\n')
+		if _, errWrite := writer.Write(compact.Bytes()); errWrite != nil {
+			_, _ = fmt.Fprintf(stderr, "translate: write output: %v\n", errWrite)
+			return 1
+		}
+	}
+	if errScan := scanner.Err(); errScan != nil {
+		_, _ = fmt.Fprintf(stderr, "translate: read input: %v\n", errScan)
+		return 1
+	}
+	if failed > 0 {
+		_, _ = fmt.Fprintf(stderr, "translate: %d of %d requests failed\n", failed, total)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/selfupdate"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// RunUpdate implements the update subcommand. It installs the latest release
+// in place of the running executable once its signed checksums verify and,
+// with -service, restarts the service and rolls back to the previous binary
+// when the restarted server does not become healthy. It returns the process
+// exit code.
+func RunUpdate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	check := fs.Bool("check", false, "Only report whether an update is available")
+	force := fs.Bool("force", false, "Reinstall even when the latest release is already running or older than this build")
+	configPath := fs.String("config", "", "Config file whose proxy-url, host and port are used (default: config.yaml next to the executable)")
+	publicKey := fs.String("public-key", "", "Ed25519 release key, base64 or a file holding it (default: the key built into this binary)")
+	feedURL := fs.String("release-url", selfupdate.LatestReleaseURL, "Release feed to query")
+	service := fs.String("service", "", "Service to restart after installing, as registered by install-service")
+	user := fs.Bool("user", false, "The -service is a systemd user unit (Linux only)")
+	healthURL := fs.String("health-url", "", "URL that must answer 200 after the restart (default: the configured server root)")
+	healthTimeout := fs.Duration("health-timeout", 60*time.Second, "How long the restarted server has to become healthy")
+	if errParse := fs.Parse(args); errParse != nil {
+		return 2
+	}
+	if errRun := runUpdate(updateOptions{
+		check:         *check,
+		force:         *force,
+		configPath:    *configPath,
+		publicKey:     *publicKey,
+		feedURL:       *feedURL,
+		service:       strings.TrimSpace(*service),
+		user:          *user,
+		healthURL:     strings.TrimSpace(*healthURL),
+		healthTimeout: *healthTimeout,
+	}, stdout); errRun != nil {
+		_, _ = fmt.Fprintf(stderr, "update: %v\n", errRun)
+		return 1
+	}
+	return 0
+}
+
+type updateOptions struct {
+	check         bool
+	force         bool
+	configPath    string
+	publicKey     string
+	feedURL       string
+	service       string
+	user          bool
+	healthURL     string
+	healthTimeout time.Duration
+}
+
+func runUpdate(opts updateOptions, stdout io.Writer) error {
+	// The service name is validated even without a restart so a typo fails
+	// before anything is replaced.
+	serviceName := opts.service
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	svcOpts, errOpts := newServiceOptions(serviceName, opts.configPath)
+	if errOpts != nil {
+		return errOpts
+	}
+	svcOpts.user = opts.user
+
+	cfg, errConfig := config.LoadConfigOptional(svcOpts.configPath, true)
+	if errConfig != nil {
+		return fmt.Errorf("load config: %w", errConfig)
+	}
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	client := &http.Client{Timeout: 5 * time.Minute}
+	util.SetProxy(&sdkconfig.SDKConfig{ProxyURL: strings.TrimSpace(cfg.ProxyURL)}, client)
+
+	ctx := context.Background()
+	release, errFetch := selfupdate.FetchLatest(ctx, client, opts.feedURL)
+	if errFetch != nil {
+		return errFetch
+	}
+	latest := release.Version()
+	upToDate := selfupdate.SameVersion(buildinfo.Version, latest)
+	older := selfupdate.OlderVersion(buildinfo.Version, latest)
+	if opts.check {
+		switch {
+		case upToDate:
+			_, _ = fmt.Fprintf(stdout, "%s is the latest release\n", buildinfo.Version)
+		case older:
+			_, _ = fmt.Fprintf(stdout, "%s is newer than the latest release %s\n", buildinfo.Version, latest)
+		default:
+			_, _ = fmt.Fprintf(stdout, "update available: %s -> %s\n", buildinfo.Version, latest)
+		}
+		return nil
+	}
+	if upToDate && !opts.force {
+		_, _ = fmt.Fprintf(stdout, "%s is the latest release\n", buildinfo.Version)
+		return nil
+	}
+	// A validly signed older release must not silently roll a newer build
+	// back.
+	if older && !opts.force {
+		return fmt.Errorf("latest release %s is older than the running %s; pass -force to downgrade", latest, buildinfo.Version)
+	}
+
+	key, errKey := releaseKey(opts.publicKey)
+	if errKey != nil {
+		return errKey
+	}
+	archive, errArchive := release.ArchiveFor(runtime.GOOS, runtime.GOARCH)
+	if errArchive != nil {
+		return errArchive
+	}
+	data, errVerified := selfupdate.Verified(ctx, client, release, archive, key)
+	if errVerified != nil {
+		return errVerified
+	}
+	binaryName := selfupdate.BinaryName
+	if runtime.GOOS == "windows" {
+		binaryName += ".exe"
+	}
+	binary, errExtract := selfupdate.ExtractBinary(data, archive.Name, binaryName)
+	if errExtract != nil {
+		return errExtract
+	}
+	backup, errReplace := selfupdate.Replace(svcOpts.executable, binary)
+	if errReplace != nil {
+		return errReplace
+	}
+	_, _ = fmt.Fprintf(stdout, "installed %s as %s (previous build kept at %s)\n", latest, svcOpts.executable, backup)
+
+	if opts.service == "" {
+		_, _ = fmt.Fprintln(stdout, "restart the server to run the new build")
+		return nil
+	}
+	if errRestart := restartService(svcOpts); errRestart != nil {
+		return rollbackUpdate(svcOpts, backup, fmt.Errorf("restart %s: %w", svcOpts.name, errRestart), stdout)
+	}
+	target := opts.healthURL
+	if target == "" {
+		target = defaultHealthURL(cfg)
+	}
+	if errHealth := selfupdate.WaitHealthy(ctx, healthClient(), target, opts.healthTimeout); errHealth != nil {
+		return rollbackUpdate(svcOpts, backup, errHealth, stdout)
+	}
+	_, _ = fmt.Fprintf(stdout, "%s restarted on %s and is healthy\n", svcOpts.name, latest)
+	return nil
+}
+
+// rollbackUpdate restores the previous binary after cause and restarts the
+// service on it. It always returns an error describing cause.
+func rollbackUpdate(opts serviceOptions, backup string, cause error, stdout io.Writer) error {
+	if errRollback := selfupdate.Rollback(opts.executable, backup); errRollback != nil {
+		return fmt.Errorf("%w; rollback failed: %v", cause, errRollback)
+	}
+	if errRestart := restartService(opts); errRestart != nil {
+		return fmt.Errorf("%w; restored the previous binary but restarting %s failed: %v", cause, opts.name, errRestart)
+	}
+	_, _ = fmt.Fprintf(stdout, "rolled back to the previous build and restarted %s\n", opts.name)
+	return fmt.Errorf("update rolled back: %w", cause)
+}
+
+// releaseKey resolves the key release checksums must be signed with: the
+// -public-key value, read from a file when it names one, or the key built
+// into the binary.
+func releaseKey(value string) (ed25519.PublicKey, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		value = buildinfo.ReleasePublicKey
+	} else if data, errRead := os.ReadFile(filepath.Clean(value)); errRead == nil {
+		value = string(data)
+	}
+	if strings.TrimSpace(value) == "" {
+		return nil, fmt.Errorf("this build has no release public key; pass -public-key")
+	}
+	return selfupdate.ParsePublicKey(value)
+}
+
+// defaultHealthURL returns the server root on the configured address,
+// reached over loopback when the server listens on all interfaces.
+func defaultHealthURL(cfg *config.Config) string {
+	host := strings.TrimSpace(cfg.Host)
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	port := cfg.Port
+	if port <= 0 {
+		port = 8317
+	}
+	scheme := "http"
+	if cfg.TLS.Enable {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/"
+}
+
+// healthClient only checks that the restarted server answers, so it accepts
+// the self-signed certificates TLS deployments commonly use locally.
+func healthClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return &http.Client{Timeout: 5 * time.Second, Transport: transport}
+}
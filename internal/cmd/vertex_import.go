@@ -42,6 +42,10 @@ func DoVertexImport(cfg *config.Config, keyPath string) {
 		log.Errorf("vertex-import: invalid service account json: %v", errUnmarshal)
 		return
 	}
+	if kind, _ := sa["type"].(string); strings.EqualFold(strings.TrimSpace(kind), vertex.CredentialSourceAuthorizedUser) {
+		doVertexAuthorizedUserImport(cfg, sa)
+		return
+	}
 	// Validate and normalize private_key before saving
 	normalizedSA, errFix := vertex.NormalizeServiceAccountMap(sa)
 	if errFix != nil {
@@ -98,6 +102,49 @@ func DoVertexImport(cfg *config.Config, keyPath string) {
 	fmt.Printf("Vertex credentials imported: %s\n", path)
 }
 
+// doVertexAuthorizedUserImport persists gcloud authorized_user credentials
+// (user OAuth) using quota_project_id as the Vertex project.
+func doVertexAuthorizedUserImport(cfg *config.Config, user map[string]any) {
+	projectID, _ := user["quota_project_id"].(string)
+	projectID = strings.TrimSpace(projectID)
+	if projectID == "" {
+		log.Errorf("vertex-import: quota_project_id missing in authorized_user json")
+		return
+	}
+	location := "us-central1"
+	fileName := fmt.Sprintf("vertex-user-%s.json", sanitizeFilePart(projectID))
+	record := &coreauth.Auth{
+		ID:       fileName,
+		Provider: "vertex",
+		FileName: fileName,
+		Storage: &vertex.VertexCredentialStorage{
+			AuthorizedUser:   user,
+			CredentialSource: vertex.CredentialSourceAuthorizedUser,
+			ProjectID:        projectID,
+			Location:         location,
+		},
+		Metadata: map[string]any{
+			"authorized_user":   user,
+			"credential_source": vertex.CredentialSourceAuthorizedUser,
+			"project_id":        projectID,
+			"location":          location,
+			"type":              "vertex",
+			"label":             labelForVertex(projectID, ""),
+		},
+	}
+
+	store := sdkAuth.GetTokenStore()
+	if setter, ok := store.(interface{ SetBaseDir(string) }); ok {
+		setter.SetBaseDir(cfg.AuthDir)
+	}
+	path, errSave := store.Save(context.Background(), record)
+	if errSave != nil {
+		log.Errorf("vertex-import: save credential failed: %v", errSave)
+		return
+	}
+	fmt.Printf("Vertex credentials imported: %s\n", path)
+}
+
 func sanitizeFilePart(s string) string {
 	out := strings.TrimSpace(s)
 	replacers := []string{"/", "_", "\\", "_", ":", "_", " ", "-"}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"syscall"
 
@@ -21,6 +22,9 @@ import (
 const (
 	DefaultPanelGitHubRepository = "https://github.com/router-for-me/Cli-Proxy-API-Management-Center"
 	DefaultPprofAddr             = "127.0.0.1:8316"
+	DefaultAuthTrashTTLHours     = 168
+
+	DefaultModelUnavailableCacheSeconds = 3600
 
 	GitHubCopilotHeaderPolicyModeLegacy  = "legacy"
 	GitHubCopilotHeaderPolicyModeDualRun = "dual-run"
@@ -36,9 +40,19 @@ type Config struct {
 	// Port is the network port on which the API server will listen.
 	Port int `yaml:"port" json:"-"`
 
+	// Listen configures Unix socket and systemd socket-activation listeners.
+	Listen ListenConfig `yaml:"listen" json:"-"`
+
+	// ClientIP controls how client addresses are resolved behind load
+	// balancers and reverse proxies.
+	ClientIP ClientIPConfig `yaml:"client-ip" json:"-"`
+
 	// TLS config controls HTTPS server settings.
 	TLS TLSConfig `yaml:"tls" json:"tls"`
 
+	// CORS controls the cross-origin headers sent to browser clients.
+	CORS CORSConfig `yaml:"cors" json:"cors"`
+
 	// RemoteManagement nests management-related options under 'remote-management'.
 	RemoteManagement RemoteManagement `yaml:"remote-management" json:"-"`
 
@@ -65,9 +79,25 @@ type Config struct {
 	// When exceeded, the oldest error log files are deleted. Default is 10. Set to 0 to disable cleanup.
 	ErrorLogsMaxFiles int `yaml:"error-logs-max-files" json:"error-logs-max-files"`
 
+	// Logging selects the application log format and per-component log levels.
+	Logging LoggingConfig `yaml:"logging,omitempty" json:"logging,omitempty"`
+
+	// Forensics persists a debugging bundle for every failed request.
+	Forensics ForensicsConfig `yaml:"forensics" json:"forensics"`
+
+	// SlowRequestThresholdMs logs per-stage timings for requests slower than
+	// this many milliseconds. 0 disables.
+	SlowRequestThresholdMs int `yaml:"slow-request-threshold-ms" json:"slow-request-threshold-ms"`
+
 	// UsageStatisticsEnabled toggles in-memory usage aggregation; when false, usage data is discarded.
 	UsageStatisticsEnabled bool `yaml:"usage-statistics-enabled" json:"usage-statistics-enabled"`
 
+	// UsageStreaming enables partial usage records during long streams.
+	UsageStreaming UsageStreamingConfig `yaml:"usage-streaming" json:"usage-streaming"`
+
+	// UsageAnomaly configures detection of abnormal consumption per API key.
+	UsageAnomaly UsageAnomalyConfig `yaml:"usage-anomaly" json:"usage-anomaly"`
+
 	// DisableCooling disables quota cooldown scheduling when true.
 	DisableCooling bool `yaml:"disable-cooling" json:"disable-cooling"`
 
@@ -102,6 +132,9 @@ type Config struct {
 	// Values: "ide" (default, CodeWhisperer) or "cli" (Amazon Q).
 	KiroPreferredEndpoint string `yaml:"kiro-preferred-endpoint" json:"kiro-preferred-endpoint"`
 
+	// KiroHTTPPool tunes the shared connection pool used for Kiro API requests.
+	KiroHTTPPool KiroHTTPPoolConfig `yaml:"kiro-http-pool" json:"kiro-http-pool"`
+
 	// Codex defines a list of Codex API key configurations as specified in the YAML configuration file.
 	CodexKey []CodexKey `yaml:"codex-api-key" json:"codex-api-key"`
 
@@ -141,6 +174,45 @@ type Config struct {
 	// gemini-api-key, codex-api-key, claude-api-key, openai-compatibility, vertex-api-key, and ampcode.
 	OAuthModelAlias map[string][]OAuthModelAlias `yaml:"oauth-model-alias,omitempty" json:"oauth-model-alias,omitempty"`
 
+	// ProviderHeaders injects extra headers into every upstream request sent for a
+	// provider, keyed by provider ID (e.g. "claude", "gemini-cli") or
+	// openai-compatibility name. Values may reference {{request_id}}, {{model}},
+	// {{provider}} and {{auth_id}}. Per-credential headers take precedence.
+	ProviderHeaders map[string]map[string]string `yaml:"provider-headers,omitempty" json:"provider-headers,omitempty"`
+
+	// APIVersions pins the upstream API version of a provider, keyed by
+	// provider ID (e.g. "claude", "gemini", "vertex") or openai-compatibility
+	// name, so an upstream shape change does not reach clients unannounced.
+	// Path-versioned providers use it as the version segment of the URL,
+	// Claude as the Anthropic-Version header and the others as the
+	// api-version query parameter. A credential's own api-version wins.
+	APIVersions map[string]string `yaml:"api-versions,omitempty" json:"api-versions,omitempty"`
+
+	// ClientProfiles defines named bundles of client-identifying headers, such
+	// as the User-Agent and fingerprint headers of an official CLI, that are
+	// sent upstream as a set. A credential selects one by name with
+	// client-profile; otherwise the profile listing its provider applies.
+	// Profile headers override provider-headers and the executors' built-in
+	// values; per-credential headers override the profile.
+	ClientProfiles map[string]ClientProfile `yaml:"client-profiles,omitempty" json:"client-profiles,omitempty"`
+
+	// AuthSchedules defines named active-hours and rest windows. A credential
+	// that selects one with schedule is only routed to while it is active.
+	AuthSchedules map[string]AuthSchedule `yaml:"auth-schedules,omitempty" json:"auth-schedules,omitempty"`
+
+	// TTSVoices maps the voice names of /v1/audio/speech requests to upstream
+	// voice names, keyed by provider ID (e.g. "gemini") or openai-compatibility
+	// name. Entries override the built-in OpenAI-to-Gemini voice table.
+	TTSVoices map[string]map[string]string `yaml:"tts-voices,omitempty" json:"tts-voices,omitempty"`
+
+	// RequestSigning lists signers applied to upstream requests right before they
+	// are sent, e.g. for corporate egress gateways that verify signatures.
+	RequestSigning []RequestSigner `yaml:"request-signing,omitempty" json:"request-signing,omitempty"`
+
+	// ExecutorPlugins lists Go plugin files (.so) that register out-of-tree
+	// provider executors when opened.
+	ExecutorPlugins []string `yaml:"executor-plugins,omitempty" json:"executor-plugins,omitempty"`
+
 	// Payload defines default and override rules for provider payload parameters.
 	Payload PayloadConfig `yaml:"payload" json:"payload"`
 
@@ -150,6 +222,10 @@ type Config struct {
 	IncognitoBrowser bool `yaml:"incognito-browser" json:"incognito-browser"`
 
 	legacyMigrationPending bool `yaml:"-" json:"-"`
+
+	// environmentProviders tracks provider entries taken from
+	// CLIPROXY_PROVIDER_* variables, which saves leave out.
+	environmentProviders *environmentProviders
 }
 
 // ClaudeHeaderDefaults configures default header values injected into Claude API requests
@@ -177,6 +253,101 @@ type TLSConfig struct {
 	Cert string `yaml:"cert" json:"cert"`
 	// Key is the path to the TLS private key file.
 	Key string `yaml:"key" json:"key"`
+	// ACME obtains and renews certificates automatically instead of reading
+	// Cert and Key.
+	ACME ACMEConfig `yaml:"acme" json:"acme"`
+	// RedirectHTTP is an address (e.g. ":80") on which plain HTTP requests are
+	// redirected to HTTPS. ACME HTTP-01 challenges are answered there as well.
+	RedirectHTTP string `yaml:"redirect-http,omitempty" json:"redirect-http,omitempty"`
+}
+
+// ListenConfig adds listeners beside the TCP host:port one.
+type ListenConfig struct {
+	// UnixSocket is the path of a Unix domain socket to serve plain HTTP on.
+	// A stale socket file at the path is replaced.
+	UnixSocket string `yaml:"unix-socket,omitempty" json:"unix-socket,omitempty"`
+	// UnixSocketMode is the octal permission of the socket file. Defaults to 0660.
+	UnixSocketMode string `yaml:"unix-socket-mode,omitempty" json:"unix-socket-mode,omitempty"`
+	// SystemdActivation serves the sockets passed by systemd (LISTEN_FDS)
+	// instead of binding host:port. Without passed sockets host:port is used.
+	SystemdActivation bool `yaml:"systemd-activation,omitempty" json:"systemd-activation,omitempty"`
+	// DisableTCP skips binding host:port, e.g. to serve only the Unix socket.
+	DisableTCP bool `yaml:"disable-tcp,omitempty" json:"disable-tcp,omitempty"`
+}
+
+// ClientIPConfig controls client address resolution. When neither
+// TrustedProxies nor ForwardedForDepth is set, X-Forwarded-For and X-Real-IP
+// are honored from any peer, as before.
+type ClientIPConfig struct {
+	// ProxyProtocol expects a PROXY protocol v1 or v2 header on every TCP
+	// connection and takes the client address from it.
+	ProxyProtocol bool `yaml:"proxy-protocol,omitempty" json:"proxy-protocol,omitempty"`
+	// TrustedProxies lists the proxy IPs and CIDRs whose X-Forwarded-For and
+	// X-Real-IP headers are honored. The client is the right-most forwarded
+	// address that is not itself a trusted proxy.
+	TrustedProxies []string `yaml:"trusted-proxies,omitempty" json:"trusted-proxies,omitempty"`
+	// ForwardedForDepth takes the client as the Nth X-Forwarded-For address
+	// from the right (1 = the one appended by the nearest proxy), for proxies
+	// without fixed addresses. Without TrustedProxies every peer is trusted.
+	ForwardedForDepth int `yaml:"forwarded-for-depth,omitempty" json:"forwarded-for-depth,omitempty"`
+}
+
+// ResolvesForwarded reports whether forwarded headers are resolved by the
+// configured rules instead of the permissive default.
+func (c ClientIPConfig) ResolvesForwarded() bool {
+	return len(c.TrustedProxies) > 0 || c.ForwardedForDepth > 0
+}
+
+// ACME challenge types.
+const (
+	ACMEChallengeTLSALPN = "tls-alpn-01"
+	ACMEChallengeHTTP    = "http-01"
+)
+
+// ACMEConfig configures automatic certificate issuance, e.g. from Let's Encrypt.
+type ACMEConfig struct {
+	// Enable toggles ACME issuance.
+	Enable bool `yaml:"enable" json:"enable"`
+	// Domains are the host names certificates are requested for. Requests for
+	// other names are refused during the handshake.
+	Domains []string `yaml:"domains,omitempty" json:"domains,omitempty"`
+	// Email is the contact address registered with the CA.
+	Email string `yaml:"email,omitempty" json:"email,omitempty"`
+	// CacheDir stores the account key and certificates. Defaults to
+	// <auth-dir>/acme.
+	CacheDir string `yaml:"cache-dir,omitempty" json:"cache-dir,omitempty"`
+	// DirectoryURL selects the CA. Defaults to Let's Encrypt production.
+	DirectoryURL string `yaml:"directory-url,omitempty" json:"directory-url,omitempty"`
+	// Challenge is "tls-alpn-01" (default), answered on the HTTPS port, or
+	// "http-01", answered on RedirectHTTP (":80" when unset).
+	Challenge string `yaml:"challenge,omitempty" json:"challenge,omitempty"`
+}
+
+// Application log formats.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LogLevelNames lists the accepted component log levels.
+var LogLevelNames = []string{"trace", "debug", "info", "warn", "warning", "error"}
+
+// LoggingConfig configures application logs.
+type LoggingConfig struct {
+	// Format is "text" (default) or "json", one object per line.
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+
+	// Levels overrides the log level of individual components.
+	Levels LogLevelsConfig `yaml:"levels,omitempty" json:"levels,omitempty"`
+}
+
+// LogLevelsConfig sets the log level (trace, debug, info, warn or error) of
+// each component. Empty follows the global level chosen by debug.
+type LogLevelsConfig struct {
+	Executor   string `yaml:"executor,omitempty" json:"executor,omitempty"`
+	Translator string `yaml:"translator,omitempty" json:"translator,omitempty"`
+	Thinking   string `yaml:"thinking,omitempty" json:"thinking,omitempty"`
+	Management string `yaml:"management,omitempty" json:"management,omitempty"`
 }
 
 // PprofConfig holds pprof HTTP server settings.
@@ -187,6 +358,94 @@ type PprofConfig struct {
 	Addr string `yaml:"addr" json:"addr"`
 }
 
+// CORSConfig controls cross-origin access for browser clients. The top-level
+// policy applies to the whole server; Management, when set, replaces it for
+// the /v0/management API. Empty lists keep the permissive defaults.
+type CORSConfig struct {
+	CORSPolicy `yaml:",inline"`
+	// Management overrides the policy for management API routes.
+	Management *CORSPolicy `yaml:"management,omitempty" json:"management,omitempty"`
+}
+
+// CORSPolicy is one set of CORS rules.
+type CORSPolicy struct {
+	// AllowedOrigins lists the origins allowed to call the API. "*" allows any
+	// origin and "https://*.example.com" any subdomain. Defaults to "*".
+	AllowedOrigins []string `yaml:"allowed-origins,omitempty" json:"allowed-origins,omitempty"`
+	// AllowedHeaders lists the request headers browsers may send. Defaults to any.
+	AllowedHeaders []string `yaml:"allowed-headers,omitempty" json:"allowed-headers,omitempty"`
+	// AllowedMethods defaults to GET, POST, PUT, PATCH, DELETE and OPTIONS.
+	AllowedMethods []string `yaml:"allowed-methods,omitempty" json:"allowed-methods,omitempty"`
+	// ExposedHeaders lists the response headers scripts may read.
+	ExposedHeaders []string `yaml:"exposed-headers,omitempty" json:"exposed-headers,omitempty"`
+	// AllowCredentials lets browsers send cookies and authorization headers
+	// from the origins listed in AllowedOrigins, explicitly or by subdomain
+	// wildcard; origins only admitted by "*" never get credentials. Wildcard
+	// headers are echoed back, since "*" is not honored on credentialed
+	// requests.
+	AllowCredentials bool `yaml:"allow-credentials,omitempty" json:"allow-credentials,omitempty"`
+	// MaxAge caches preflight responses for this many seconds. 0 leaves it to
+	// the browser.
+	MaxAge int `yaml:"max-age,omitempty" json:"max-age,omitempty"`
+}
+
+// ForensicsConfig controls the bundles kept for failed requests. Each bundle
+// holds the original payload, every translation stage, the upstream request
+// and response headers, timing and the thinking adaptation.
+type ForensicsConfig struct {
+	// Enable turns bundle capture on.
+	Enable bool `yaml:"enable" json:"enable"`
+	// TTL is how long, in seconds, a bundle is kept. Defaults to 86400.
+	TTL int `yaml:"ttl" json:"ttl"`
+	// MaxBodyBytes truncates each captured payload. Defaults to 1 MiB.
+	MaxBodyBytes int `yaml:"max-body-bytes" json:"max-body-bytes"`
+}
+
+// UsageStreamingConfig controls the partial usage records published while a
+// stream is in progress. Both thresholds are disabled when zero.
+type UsageStreamingConfig struct {
+	// Interval publishes a partial record at most every Interval seconds.
+	Interval int `yaml:"interval" json:"interval"`
+	// Tokens publishes a partial record each time the estimated output grows
+	// by this many tokens.
+	Tokens int `yaml:"tokens" json:"tokens"`
+}
+
+// Actions taken when a usage anomaly is detected for an API key.
+const (
+	UsageAnomalyActionNotify   = "notify"
+	UsageAnomalyActionThrottle = "throttle"
+	UsageAnomalyActionDisable  = "disable"
+)
+
+// UsageAnomalyConfig configures the usage spike detector. Consumption is
+// bucketed per API key into fixed windows; a window is anomalous when it
+// exceeds a fixed threshold or lies ZScore standard deviations above the
+// mean of the preceding windows.
+type UsageAnomalyConfig struct {
+	// Enable turns the detector on.
+	Enable bool `yaml:"enable" json:"enable"`
+	// Window is the bucket length in seconds. Defaults to 300.
+	Window int `yaml:"window" json:"window"`
+	// History is the number of past windows forming the baseline. Defaults to 12.
+	History int `yaml:"history" json:"history"`
+	// ZScore flags a window this many standard deviations above the baseline. 0 disables.
+	ZScore float64 `yaml:"z-score" json:"z-score"`
+	// MaxTokens flags a key once it uses this many tokens within one window. 0 disables.
+	MaxTokens int64 `yaml:"max-tokens" json:"max-tokens"`
+	// MaxRequests flags a key once it sends this many requests within one window. 0 disables.
+	MaxRequests int64 `yaml:"max-requests" json:"max-requests"`
+	// Action is one of "notify" (default), "throttle" or "disable".
+	Action string `yaml:"action" json:"action"`
+	// ThrottleRPM caps requests per minute for a throttled key. Defaults to 10.
+	ThrottleRPM int `yaml:"throttle-rpm" json:"throttle-rpm"`
+	// BlockDuration is how long, in seconds, a throttle or disable lasts.
+	// 0 keeps it until cleared through the management API.
+	BlockDuration int `yaml:"block-duration" json:"block-duration"`
+	// WebhookURL receives a JSON POST for every anomaly.
+	WebhookURL string `yaml:"webhook-url" json:"webhook-url"`
+}
+
 // RemoteManagement holds management API configuration under 'remote-management'.
 type RemoteManagement struct {
 	// AllowRemote toggles remote (non-localhost) access to management API.
@@ -195,6 +454,13 @@ type RemoteManagement struct {
 	SecretKey string `yaml:"secret-key"`
 	// DisableControlPanel skips serving and syncing the bundled management UI when true.
 	DisableControlPanel bool `yaml:"disable-control-panel"`
+	// ReadOnly rejects management API requests that change state, except the
+	// one that turns read-only mode off again.
+	ReadOnly bool `yaml:"read-only,omitempty"`
+	// AuthTrashTTLHours is how long auth files deleted through the management
+	// API stay restorable. 0 uses DefaultAuthTrashTTLHours; negative deletes
+	// them outright.
+	AuthTrashTTLHours int `yaml:"auth-trash-ttl-hours,omitempty"`
 	// PanelGitHubRepository overrides the GitHub repository used to fetch the management panel asset.
 	// Accepts either a repository URL (https://github.com/org/repo) or an API releases endpoint.
 	PanelGitHubRepository string `yaml:"panel-github-repository"`
@@ -215,6 +481,16 @@ type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
 	// Supported values: "round-robin" (default), "fill-first".
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// AntigravityAutoTier exposes tierless Antigravity model IDs (e.g. "gemini-3.1-pro")
+	// for models published as separate "-high"/"-low" tiers, and picks the tier per
+	// request from the requested thinking level and each tier's remaining quota.
+	AntigravityAutoTier bool `yaml:"antigravity-auto-tier,omitempty" json:"antigravity-auto-tier,omitempty"`
+
+	// ModelUnavailableCacheSeconds is how long a credential is skipped for a
+	// model after the upstream reported that model unavailable for it.
+	// 0 uses DefaultModelUnavailableCacheSeconds; negative disables the cache.
+	ModelUnavailableCacheSeconds int `yaml:"model-unavailable-cache-seconds,omitempty" json:"model-unavailable-cache-seconds,omitempty"`
 }
 
 // OAuthModelAlias defines a model ID alias for a specific channel.
@@ -273,6 +549,42 @@ type AmpCode struct {
 	ForceModelMappings bool `yaml:"force-model-mappings" json:"force-model-mappings"`
 }
 
+// RequestSigner configures one outbound request signer.
+type RequestSigner struct {
+	// Type is "hmac", "sigv4", or the name of a signer registered through the
+	// sdk/cliproxy/signing package.
+	Type string `yaml:"type" json:"type"`
+
+	// Providers limits the signer to these provider IDs or openai-compatibility
+	// names. Empty applies the signer to every provider.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// HMAC configures the built-in "hmac" signer.
+	HMAC RequestSignerHMAC `yaml:"hmac,omitempty" json:"hmac,omitempty"`
+
+	// SigV4 configures the built-in "sigv4" signer.
+	SigV4 RequestSignerSigV4 `yaml:"sigv4,omitempty" json:"sigv4,omitempty"`
+}
+
+// RequestSignerHMAC holds settings for the built-in HMAC signer.
+type RequestSignerHMAC struct {
+	Secret          string `yaml:"secret" json:"secret"`
+	KeyID           string `yaml:"key-id,omitempty" json:"key-id,omitempty"`
+	Algorithm       string `yaml:"algorithm,omitempty" json:"algorithm,omitempty"`
+	SignatureHeader string `yaml:"signature-header,omitempty" json:"signature-header,omitempty"`
+	TimestampHeader string `yaml:"timestamp-header,omitempty" json:"timestamp-header,omitempty"`
+	KeyIDHeader     string `yaml:"key-id-header,omitempty" json:"key-id-header,omitempty"`
+}
+
+// RequestSignerSigV4 holds settings for the built-in AWS SigV4 signer.
+type RequestSignerSigV4 struct {
+	AccessKeyID     string `yaml:"access-key-id" json:"access-key-id"`
+	SecretAccessKey string `yaml:"secret-access-key" json:"secret-access-key"`
+	SessionToken    string `yaml:"session-token,omitempty" json:"session-token,omitempty"`
+	Region          string `yaml:"region" json:"region"`
+	Service         string `yaml:"service" json:"service"`
+}
+
 // GitHubCopilotConfig holds behavioral overrides for the GitHub Copilot executor.
 type GitHubCopilotConfig struct {
 	// HeaderPolicy configures deterministic header behavior for GitHub Copilot requests.
@@ -295,11 +607,14 @@ type GitHubCopilotConfig struct {
 }
 
 // GitHubCopilotHeaderPolicyConfig configures default header values and mode.
+// Empty header values fall back to the built-in editor identity; all modes,
+// including legacy, honor the configured values.
 type GitHubCopilotHeaderPolicyConfig struct {
 	Mode                string `yaml:"mode" json:"mode"`
 	UserAgent           string `yaml:"user-agent" json:"user-agent"`
 	EditorVersion       string `yaml:"editor-version" json:"editor-version"`
 	EditorPluginVersion string `yaml:"editor-plugin-version" json:"editor-plugin-version"`
+	IntegrationID       string `yaml:"integration-id,omitempty" json:"integration-id,omitempty"`
 	AnthropicBeta       string `yaml:"anthropic-beta" json:"anthropic-beta"`
 	SessionStateFile    string `yaml:"session-state-file" json:"session-state-file"`
 	ShadowStateFile     string `yaml:"shadow-state-file" json:"shadow-state-file"`
@@ -341,6 +656,53 @@ type PayloadConfig struct {
 	OverrideRaw []PayloadRule `yaml:"override-raw" json:"override-raw"`
 	// Filter defines rules that remove parameters from the payload by JSON path.
 	Filter []PayloadFilterRule `yaml:"filter" json:"filter"`
+	// Transform defines template rules applied after all other rules.
+	Transform []PayloadTransformRule `yaml:"transform,omitempty" json:"transform,omitempty"`
+	// Calibration re-maps client sampling values before any other rule runs.
+	Calibration []PayloadCalibrationRule `yaml:"calibration,omitempty" json:"calibration,omitempty"`
+}
+
+// PayloadCalibrationRule re-maps the temperature and top_p a client sent
+// along piecewise-linear curves, since the same value samples very
+// differently across vendors. Values outside a curve take its nearest end.
+// The first matching rule with a curve for a parameter wins.
+type PayloadCalibrationRule struct {
+	// Providers restricts the rule to credentials of these providers; empty
+	// matches every provider.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+	// Models restricts the rule to matching models; empty matches every model.
+	Models []PayloadModelRule `yaml:"models,omitempty" json:"models,omitempty"`
+	// Temperature maps client temperatures to upstream ones.
+	Temperature []CalibrationPoint `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	// TopP maps client top_p values to upstream ones.
+	TopP []CalibrationPoint `yaml:"top-p,omitempty" json:"top-p,omitempty"`
+}
+
+// CalibrationPoint is one point of a calibration curve.
+type CalibrationPoint struct {
+	From float64 `yaml:"from" json:"from"`
+	To   float64 `yaml:"to" json:"to"`
+}
+
+// PayloadTransformRule conditionally rewrites the translated payload with Go
+// templates (text/template). Templates see .Model, .RequestedModel,
+// .Protocol, .Provider, .APIKey, .Labels (the credential's labels) and .Body
+// (the payload decoded from JSON), the .Get and .Has path lookups and helper
+// functions such as json, default, hasPrefix, contains and match.
+type PayloadTransformRule struct {
+	// Name identifies the rule in logs.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Models restricts the rule to matching models; empty matches every model.
+	Models []PayloadModelRule `yaml:"models,omitempty" json:"models,omitempty"`
+	// When is a template; the rule applies only when it renders "true".
+	// Empty always applies.
+	When string `yaml:"when,omitempty" json:"when,omitempty"`
+	// Set maps JSON paths to templates. Output that is valid JSON is written
+	// as raw JSON, anything else as a string; empty output leaves the path
+	// untouched.
+	Set map[string]string `yaml:"set,omitempty" json:"set,omitempty"`
+	// Delete lists JSON paths removed after Set is applied.
+	Delete []string `yaml:"delete,omitempty" json:"delete,omitempty"`
 }
 
 // PayloadFilterRule describes a rule to remove specific JSON paths from matching model payloads.
@@ -391,6 +753,35 @@ type CloakConfig struct {
 	CacheUserID *bool `yaml:"cache-user-id,omitempty" json:"cache-user-id,omitempty"`
 }
 
+// ClientProfile is a named set of upstream headers identifying a client.
+type ClientProfile struct {
+	// Providers lists the provider IDs or openai-compatibility names whose
+	// credentials use this profile unless they select another one.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// Headers are set on every upstream request of a credential using the
+	// profile. Values may use the same placeholders as provider-headers.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// AuthSchedule limits when the credentials using it are selected.
+type AuthSchedule struct {
+	// ActiveHours lists the windows a credential may be used in, each an
+	// optional day list and an optional time range, e.g. "mon-fri 18:00-09:00"
+	// or "sat,sun". A range ending before it starts runs past midnight. Empty
+	// means always.
+	ActiveHours []string `yaml:"active-hours,omitempty" json:"active-hours,omitempty"`
+
+	// Timezone is the IANA zone ActiveHours are read in. Empty uses the
+	// server's local time.
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// RestMinutesPerHour takes the credential out of rotation for this many
+	// minutes every hour, at an offset derived from its ID so that credentials
+	// sharing a schedule rest at different times. Valid values are 1-59.
+	RestMinutesPerHour int `yaml:"rest-minutes-per-hour,omitempty" json:"rest-minutes-per-hour,omitempty"`
+}
+
 // ClaudeKey represents the configuration for a Claude API key,
 // including the API key itself and an optional base URL for the API endpoint.
 type ClaudeKey struct {
@@ -408,6 +799,10 @@ type ClaudeKey struct {
 	// If empty, the default Claude API URL will be used.
 	BaseURL string `yaml:"base-url" json:"base-url"`
 
+	// APIVersion overrides the Anthropic-Version header, e.g. for gateways that
+	// front Claude with their own versioning.
+	APIVersion string `yaml:"api-version,omitempty" json:"api-version,omitempty"`
+
 	// ProxyURL overrides the global proxy setting for this API key if provided.
 	ProxyURL string `yaml:"proxy-url" json:"proxy-url"`
 
@@ -417,6 +812,15 @@ type ClaudeKey struct {
 	// Headers optionally adds extra HTTP headers for requests sent with this key.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
 
+	// ClientProfile selects a client-profiles entry for requests sent with this key.
+	ClientProfile string `yaml:"client-profile,omitempty" json:"client-profile,omitempty"`
+
+	// Schedule selects an auth-schedules entry limiting when this key is used.
+	Schedule string `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// Labels tag the key for model-namespaces selectors.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
 	// ExcludedModels lists model IDs that should be excluded for this provider.
 	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
 
@@ -456,6 +860,10 @@ type CodexKey struct {
 	// If empty, the default Codex API URL will be used.
 	BaseURL string `yaml:"base-url" json:"base-url"`
 
+	// APIVersion, when set, is sent as the "api-version" query parameter
+	// (Azure OpenAI style).
+	APIVersion string `yaml:"api-version,omitempty" json:"api-version,omitempty"`
+
 	// Websockets enables the Responses API websocket transport for this credential.
 	Websockets bool `yaml:"websockets,omitempty" json:"websockets,omitempty"`
 
@@ -468,6 +876,15 @@ type CodexKey struct {
 	// Headers optionally adds extra HTTP headers for requests sent with this key.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
 
+	// ClientProfile selects a client-profiles entry for requests sent with this key.
+	ClientProfile string `yaml:"client-profile,omitempty" json:"client-profile,omitempty"`
+
+	// Schedule selects an auth-schedules entry limiting when this key is used.
+	Schedule string `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// Labels tag the key for model-namespaces selectors.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
 	// ExcludedModels lists model IDs that should be excluded for this provider.
 	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
 }
@@ -503,6 +920,9 @@ type GeminiKey struct {
 	// BaseURL optionally overrides the Gemini API endpoint.
 	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
 
+	// APIVersion optionally replaces the "v1beta" path segment.
+	APIVersion string `yaml:"api-version,omitempty" json:"api-version,omitempty"`
+
 	// ProxyURL optionally overrides the global proxy for this API key.
 	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
 
@@ -512,6 +932,15 @@ type GeminiKey struct {
 	// Headers optionally adds extra HTTP headers for requests sent with this key.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
 
+	// ClientProfile selects a client-profiles entry for requests sent with this key.
+	ClientProfile string `yaml:"client-profile,omitempty" json:"client-profile,omitempty"`
+
+	// Schedule selects an auth-schedules entry limiting when this key is used.
+	Schedule string `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// Labels tag the key for model-namespaces selectors.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
 	// ExcludedModels lists model IDs that should be excluded for this provider.
 	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
 }
@@ -577,6 +1006,44 @@ type KiroFingerprintConfig struct {
 	KiroHash            string `yaml:"kiro-hash,omitempty" json:"kiro-hash,omitempty"`
 }
 
+// KiroHTTPPoolConfig tunes the shared HTTP connection pool used for Kiro API requests.
+// Zero or negative values fall back to the built-in defaults.
+type KiroHTTPPoolConfig struct {
+	// MaxIdleConns limits idle connections kept across all hosts.
+	MaxIdleConns int `yaml:"max-idle-conns,omitempty" json:"max-idle-conns,omitempty"`
+	// MaxIdleConnsPerHost limits idle connections kept per upstream host.
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host,omitempty" json:"max-idle-conns-per-host,omitempty"`
+	// MaxConnsPerHost limits total (active + idle) connections per upstream host.
+	MaxConnsPerHost int `yaml:"max-conns-per-host,omitempty" json:"max-conns-per-host,omitempty"`
+	// IdleConnTimeoutSeconds controls how long idle connections stay in the pool.
+	IdleConnTimeoutSeconds int `yaml:"idle-conn-timeout-seconds,omitempty" json:"idle-conn-timeout-seconds,omitempty"`
+}
+
+// Default Kiro HTTP pool values.
+const (
+	DefaultKiroMaxIdleConns           = 100
+	DefaultKiroMaxIdleConnsPerHost    = 20
+	DefaultKiroMaxConnsPerHost        = 50
+	DefaultKiroIdleConnTimeoutSeconds = 90
+)
+
+// Effective returns the pool configuration with defaults applied to unset fields.
+func (p KiroHTTPPoolConfig) Effective() KiroHTTPPoolConfig {
+	if p.MaxIdleConns <= 0 {
+		p.MaxIdleConns = DefaultKiroMaxIdleConns
+	}
+	if p.MaxIdleConnsPerHost <= 0 {
+		p.MaxIdleConnsPerHost = DefaultKiroMaxIdleConnsPerHost
+	}
+	if p.MaxConnsPerHost <= 0 {
+		p.MaxConnsPerHost = DefaultKiroMaxConnsPerHost
+	}
+	if p.IdleConnTimeoutSeconds <= 0 {
+		p.IdleConnTimeoutSeconds = DefaultKiroIdleConnTimeoutSeconds
+	}
+	return p
+}
+
 // OpenAICompatibility represents the configuration for OpenAI API compatibility
 // with external providers, allowing model aliases to be routed through OpenAI API format.
 type OpenAICompatibility struct {
@@ -593,6 +1060,10 @@ type OpenAICompatibility struct {
 	// BaseURL is the base URL for the external OpenAI-compatible API endpoint.
 	BaseURL string `yaml:"base-url" json:"base-url"`
 
+	// APIVersion, when set, is sent as the "api-version" query parameter
+	// required by Azure OpenAI and similar gateways.
+	APIVersion string `yaml:"api-version,omitempty" json:"api-version,omitempty"`
+
 	// APIKeyEntries defines API keys with optional per-key proxy configuration.
 	APIKeyEntries []OpenAICompatibilityAPIKey `yaml:"api-key-entries,omitempty" json:"api-key-entries,omitempty"`
 
@@ -601,6 +1072,15 @@ type OpenAICompatibility struct {
 
 	// Headers optionally adds extra HTTP headers for requests sent to this provider.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// ClientProfile selects a client-profiles entry for requests sent to this provider.
+	ClientProfile string `yaml:"client-profile,omitempty" json:"client-profile,omitempty"`
+
+	// Schedule selects an auth-schedules entry limiting when this provider is used.
+	Schedule string `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// Labels tag the provider for model-namespaces selectors.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
 }
 
 // OpenAICompatibilityAPIKey represents an API key configuration with optional proxy setting.
@@ -620,6 +1100,10 @@ type OpenAICompatibilityModel struct {
 
 	// Alias is the model name alias that clients will use to reference this model.
 	Alias string `yaml:"alias" json:"alias"`
+
+	// StreamMode declares that the provider only supports "stream" or
+	// "non-stream" requests for this model; other requests are adapted.
+	StreamMode string `yaml:"stream-mode,omitempty" json:"stream-mode,omitempty"`
 }
 
 func (m OpenAICompatibilityModel) GetName() string  { return m.Name }
@@ -643,6 +1127,12 @@ func LoadConfig(configFile string) (*Config, error) {
 // If optional is true and the file is missing, it returns an empty Config.
 // If optional is true and the file is empty or invalid, it returns an empty Config.
 func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
+	return loadConfigOptional(configFile, optional, true)
+}
+
+// loadConfigOptional implements LoadConfigOptional. With persist false it
+// never writes to disk, which lets saves compute the layered baseline.
+func loadConfigOptional(configFile string, optional, persist bool) (*Config, error) {
 	// Read the entire configuration file into memory.
 	data, err := os.ReadFile(configFile)
 	if err != nil {
@@ -660,6 +1150,32 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		return &Config{}, nil
 	}
 
+	// Merge includes and overlays into one document.
+	layers, err := loadConfigLayers(configFile, data)
+	if err != nil {
+		if optional {
+			// In cloud deploy mode, if YAML parsing fails, return empty config instead of error.
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+	root := &layers.root
+
+	// Expand ${ENV}, file: and vault: references, then validate against the
+	// schema so every issue is reported with its line and key path, not just
+	// the first decode error.
+	refIssues, refs := resolveConfigReferences(root)
+	if persist {
+		rememberConfigReferences(configFile, refs)
+	}
+	if errValidate := layers.validationError(configFile, append(refIssues, validateConfigNode(root)...)); errValidate != nil {
+		if !optional {
+			return nil, errValidate
+		}
+		// Cloud deploy tolerates invalid files; surface the issues and carry on.
+		log.Warn(errValidate.Error())
+	}
+
 	// Unmarshal the YAML data into the Config struct.
 	var cfg Config
 	// Set defaults before unmarshal so that absent keys keep defaults.
@@ -675,12 +1191,14 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	cfg.AmpCode.RestrictManagementToLocalhost = false // Default to false: API key auth is sufficient
 	cfg.RemoteManagement.PanelGitHubRepository = DefaultPanelGitHubRepository
 	cfg.IncognitoBrowser = false // Default to normal browser (AWS uses incognito by force)
-	if err = yaml.Unmarshal(data, &cfg); err != nil {
-		if optional {
-			// In cloud deploy mode, if YAML parsing fails, return empty config instead of error.
-			return &Config{}, nil
+	if root.Kind != 0 {
+		if err = root.Decode(&cfg); err != nil {
+			if optional {
+				// In cloud deploy mode, if YAML parsing fails, return empty config instead of error.
+				return &Config{}, nil
+			}
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
 		}
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
 	// NOTE: Startup legacy key migration is intentionally disabled.
@@ -710,7 +1228,10 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 
 		// Persist the hashed value back to the config file to avoid re-hashing on next startup.
 		// Preserve YAML comments and ordering; update only the nested key.
-		_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		// A referenced key stays a reference and is simply re-hashed on each load.
+		if persist && !refs.hasReference("remote-management.secret-key") && layers.ownsSecretKey(configFile) {
+			_ = SaveConfigPreserveCommentsUpdateNestedScalar(configFile, []string{"remote-management", "secret-key"}, hashed)
+		}
 	}
 
 	cfg.RemoteManagement.PanelGitHubRepository = strings.TrimSpace(cfg.RemoteManagement.PanelGitHubRepository)
@@ -735,6 +1256,9 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 		cfg.MaxRetryCredentials = 0
 	}
 
+	// Add API-key providers declared through CLIPROXY_PROVIDER_* variables.
+	cfg.applyEnvironmentProviders(os.Environ())
+
 	// Sanitize Gemini API key configuration and migrate legacy entries.
 	cfg.SanitizeGeminiKeys()
 
@@ -768,6 +1292,51 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Sanitize GitHub Copilot config defaults.
 	cfg.SanitizeGitHubCopilotConfig()
 
+	// Normalize request signer entries and drop those without a type.
+	cfg.SanitizeRequestSigning()
+
+	// Normalize shared-state backend settings.
+	cfg.SanitizeSharedState()
+
+	// Drop blank and duplicate executor plugin paths.
+	cfg.SanitizeExecutorPlugins()
+
+	// Normalize model namespace prefixes and drop unusable entries.
+	cfg.SanitizeModelNamespaces()
+
+	// Trim per-key model lists and drop entries without a key.
+	cfg.SanitizeAPIKeyModels()
+	cfg.SanitizeAPIKeyProfiles()
+	cfg.SanitizeAPIKeyLimits()
+	cfg.SanitizeRateLimitQueueKeyWeights()
+	cfg.SanitizeModelPrices()
+	cfg.SanitizeAPIKeyReasoning()
+	cfg.SanitizeLoopDetection()
+
+	// Drop incomplete shadow-traffic rules and clamp their percentages.
+	cfg.SanitizeShadowTraffic()
+
+	// Normalize experiment arms and drop experiments that cannot split traffic.
+	cfg.SanitizeExperiments()
+
+	// Normalize smart router rules and drop routers without a fallback.
+	cfg.SanitizeSmartRouters()
+
+	// Normalize prompt template messages and drop empty or duplicate templates.
+	cfg.SanitizePromptTemplates()
+
+	// Trim CORS lists and upper-case methods.
+	cfg.SanitizeCORS()
+
+	// Normalize ACME domains and challenge type.
+	cfg.SanitizeTLS()
+
+	// Trim the Unix socket settings.
+	cfg.SanitizeListen()
+
+	// Drop empty trusted proxies and clamp the forwarded-for depth.
+	cfg.SanitizeClientIP()
+
 	// NOTE: Legacy migration persistence is intentionally disabled together with
 	// startup legacy migration to keep startup read-only for config.yaml.
 	// Re-enable the block below if automatic startup migration is needed again.
@@ -819,6 +1388,28 @@ func (cfg *Config) SanitizePayloadRules() {
 	}
 	cfg.Payload.DefaultRaw = sanitizePayloadRawRules(cfg.Payload.DefaultRaw, "default-raw")
 	cfg.Payload.OverrideRaw = sanitizePayloadRawRules(cfg.Payload.OverrideRaw, "override-raw")
+	cfg.Payload.Calibration = sanitizePayloadCalibrationRules(cfg.Payload.Calibration)
+}
+
+// sanitizePayloadCalibrationRules sorts each curve by client value and drops
+// rules without any curve.
+func sanitizePayloadCalibrationRules(rules []PayloadCalibrationRule) []PayloadCalibrationRule {
+	if len(rules) == 0 {
+		return rules
+	}
+	out := make([]PayloadCalibrationRule, 0, len(rules))
+	for i := range rules {
+		rule := rules[i]
+		if len(rule.Temperature) == 0 && len(rule.TopP) == 0 {
+			log.WithField("rule_index", i+1).Warn("payload calibration rule dropped: no curve")
+			continue
+		}
+		for _, curve := range [][]CalibrationPoint{rule.Temperature, rule.TopP} {
+			sort.SliceStable(curve, func(a, b int) bool { return curve[a].From < curve[b].From })
+		}
+		out = append(out, rule)
+	}
+	return out
 }
 
 func sanitizePayloadRawRules(rules []PayloadRule, section string) []PayloadRule {
@@ -877,6 +1468,550 @@ func (cfg *Config) SanitizeCodexHeaderDefaults() {
 	cfg.CodexHeaderDefaults.BetaFeatures = strings.TrimSpace(cfg.CodexHeaderDefaults.BetaFeatures)
 }
 
+// SanitizeRequestSigning trims request signer fields, lower-cases signer
+// types and drops entries without a type.
+func (cfg *Config) SanitizeRequestSigning() {
+	if cfg == nil || len(cfg.RequestSigning) == 0 {
+		return
+	}
+	out := make([]RequestSigner, 0, len(cfg.RequestSigning))
+	for _, entry := range cfg.RequestSigning {
+		entry.Type = strings.ToLower(strings.TrimSpace(entry.Type))
+		if entry.Type == "" {
+			continue
+		}
+		providers := make([]string, 0, len(entry.Providers))
+		for _, provider := range entry.Providers {
+			if provider = strings.TrimSpace(provider); provider != "" {
+				providers = append(providers, provider)
+			}
+		}
+		entry.Providers = providers
+		entry.HMAC.KeyID = strings.TrimSpace(entry.HMAC.KeyID)
+		entry.HMAC.Algorithm = strings.ToLower(strings.TrimSpace(entry.HMAC.Algorithm))
+		entry.HMAC.SignatureHeader = strings.TrimSpace(entry.HMAC.SignatureHeader)
+		entry.HMAC.TimestampHeader = strings.TrimSpace(entry.HMAC.TimestampHeader)
+		entry.HMAC.KeyIDHeader = strings.TrimSpace(entry.HMAC.KeyIDHeader)
+		entry.SigV4.AccessKeyID = strings.TrimSpace(entry.SigV4.AccessKeyID)
+		entry.SigV4.SecretAccessKey = strings.TrimSpace(entry.SigV4.SecretAccessKey)
+		entry.SigV4.SessionToken = strings.TrimSpace(entry.SigV4.SessionToken)
+		entry.SigV4.Region = strings.TrimSpace(entry.SigV4.Region)
+		entry.SigV4.Service = strings.TrimSpace(entry.SigV4.Service)
+		out = append(out, entry)
+	}
+	cfg.RequestSigning = out
+}
+
+// SanitizeSharedState trims shared-state settings, lower-cases the backend
+// and clamps a negative sticky-session TTL to zero.
+func (cfg *Config) SanitizeSharedState() {
+	if cfg == nil {
+		return
+	}
+	cfg.SharedState.Backend = strings.ToLower(strings.TrimSpace(cfg.SharedState.Backend))
+	cfg.SharedState.RedisURL = strings.TrimSpace(cfg.SharedState.RedisURL)
+	cfg.SharedState.KeyPrefix = strings.TrimSpace(cfg.SharedState.KeyPrefix)
+	if cfg.SharedState.StickySessionTTL < 0 {
+		cfg.SharedState.StickySessionTTL = 0
+	}
+}
+
+// SanitizeClientIP drops empty trusted proxy entries and clamps a negative
+// forwarded-for depth to zero.
+func (cfg *Config) SanitizeClientIP() {
+	if cfg == nil {
+		return
+	}
+	proxies := cfg.ClientIP.TrustedProxies[:0]
+	for _, proxy := range cfg.ClientIP.TrustedProxies {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if len(proxies) == 0 {
+		proxies = nil
+	}
+	cfg.ClientIP.TrustedProxies = proxies
+	if cfg.ClientIP.ForwardedForDepth < 0 {
+		cfg.ClientIP.ForwardedForDepth = 0
+	}
+}
+
+// SanitizeListen trims the Unix socket path and permission mode.
+func (cfg *Config) SanitizeListen() {
+	if cfg == nil {
+		return
+	}
+	cfg.Listen.UnixSocket = strings.TrimSpace(cfg.Listen.UnixSocket)
+	cfg.Listen.UnixSocketMode = strings.TrimSpace(cfg.Listen.UnixSocketMode)
+}
+
+// SanitizeTLS trims the TLS settings, lower-cases and de-duplicates ACME
+// domains and normalizes the challenge type.
+func (cfg *Config) SanitizeTLS() {
+	if cfg == nil {
+		return
+	}
+	cfg.TLS.Cert = strings.TrimSpace(cfg.TLS.Cert)
+	cfg.TLS.Key = strings.TrimSpace(cfg.TLS.Key)
+	cfg.TLS.RedirectHTTP = strings.TrimSpace(cfg.TLS.RedirectHTTP)
+	acme := &cfg.TLS.ACME
+	acme.Email = strings.TrimSpace(acme.Email)
+	acme.CacheDir = strings.TrimSpace(acme.CacheDir)
+	acme.DirectoryURL = strings.TrimSpace(acme.DirectoryURL)
+	acme.Challenge = strings.ToLower(strings.TrimSpace(acme.Challenge))
+	seen := make(map[string]struct{}, len(acme.Domains))
+	domains := acme.Domains[:0]
+	for _, domain := range acme.Domains {
+		domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+		if domain == "" {
+			continue
+		}
+		if _, ok := seen[domain]; ok {
+			continue
+		}
+		seen[domain] = struct{}{}
+		domains = append(domains, domain)
+	}
+	if len(domains) == 0 {
+		domains = nil
+	}
+	acme.Domains = domains
+}
+
+// SanitizeCORS trims the CORS lists, drops empty entries and trailing
+// slashes from origins, upper-cases methods and clamps a negative max age.
+func (cfg *Config) SanitizeCORS() {
+	if cfg == nil {
+		return
+	}
+	sanitizeCORSPolicy(&cfg.CORS.CORSPolicy)
+	if cfg.CORS.Management != nil {
+		sanitizeCORSPolicy(cfg.CORS.Management)
+	}
+}
+
+func sanitizeCORSPolicy(policy *CORSPolicy) {
+	clean := func(values []string, normalize func(string) string) []string {
+		out := values[:0]
+		for _, value := range values {
+			if value = normalize(strings.TrimSpace(value)); value != "" {
+				out = append(out, value)
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return out
+	}
+	keep := func(value string) string { return value }
+	policy.AllowedOrigins = clean(policy.AllowedOrigins, func(value string) string {
+		return strings.ToLower(strings.TrimRight(value, "/"))
+	})
+	policy.AllowedHeaders = clean(policy.AllowedHeaders, keep)
+	policy.AllowedMethods = clean(policy.AllowedMethods, strings.ToUpper)
+	policy.ExposedHeaders = clean(policy.ExposedHeaders, keep)
+	if policy.MaxAge < 0 {
+		policy.MaxAge = 0
+	}
+}
+
+// SanitizeModelNamespaces normalizes namespace prefixes to "/"-separated
+// segments and drops entries without a prefix or selector, and later entries
+// repeating a prefix.
+func (cfg *Config) SanitizeModelNamespaces() {
+	if cfg == nil || len(cfg.ModelNamespaces) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.ModelNamespaces))
+	out := cfg.ModelNamespaces[:0]
+	for _, ns := range cfg.ModelNamespaces {
+		var segments []string
+		for _, segment := range strings.Split(ns.Prefix, "/") {
+			if segment = strings.TrimSpace(segment); segment != "" {
+				segments = append(segments, segment)
+			}
+		}
+		ns.Prefix = strings.Join(segments, "/")
+		selector := make(map[string]string, len(ns.Selector))
+		for key, value := range ns.Selector {
+			if key = strings.TrimSpace(key); key != "" {
+				selector[key] = strings.TrimSpace(value)
+			}
+		}
+		ns.Selector = selector
+		if ns.Prefix == "" || len(ns.Selector) == 0 {
+			continue
+		}
+		if _, dup := seen[ns.Prefix]; dup {
+			continue
+		}
+		seen[ns.Prefix] = struct{}{}
+		keys := ns.APIKeys[:0]
+		for _, key := range ns.APIKeys {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
+			}
+		}
+		ns.APIKeys = keys
+		out = append(out, ns)
+	}
+	cfg.ModelNamespaces = out
+}
+
+// SanitizeAPIKeyModels trims api-key-models entries and their patterns,
+// dropping entries without an API key and blank patterns.
+func (cfg *Config) SanitizeAPIKeyModels() {
+	if cfg == nil || len(cfg.APIKeyModels) == 0 {
+		return
+	}
+	trimPatterns := func(patterns []string) []string {
+		out := patterns[:0]
+		for _, pattern := range patterns {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				out = append(out, pattern)
+			}
+		}
+		return out
+	}
+	out := cfg.APIKeyModels[:0]
+	for _, entry := range cfg.APIKeyModels {
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		if entry.APIKey == "" {
+			continue
+		}
+		entry.Provider = strings.TrimSpace(entry.Provider)
+		entry.Allow = trimPatterns(entry.Allow)
+		entry.Deny = trimPatterns(entry.Deny)
+		out = append(out, entry)
+	}
+	cfg.APIKeyModels = out
+}
+
+// SanitizeAPIKeyProfiles trims api-key-profiles entries, lowercases their
+// profile names and drops entries without an API key or a profile.
+func (cfg *Config) SanitizeAPIKeyProfiles() {
+	if cfg == nil || len(cfg.APIKeyProfiles) == 0 {
+		return
+	}
+	out := cfg.APIKeyProfiles[:0]
+	for _, entry := range cfg.APIKeyProfiles {
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		entry.Provider = strings.TrimSpace(entry.Provider)
+		entry.Profile = strings.ToLower(strings.TrimSpace(entry.Profile))
+		if entry.APIKey == "" || entry.Profile == "" {
+			continue
+		}
+		out = append(out, entry)
+	}
+	cfg.APIKeyProfiles = out
+}
+
+// SanitizeAPIKeyLimits trims api-key-limits entries, treats negative limits
+// as unset and drops entries without an API key or any limit.
+func (cfg *Config) SanitizeAPIKeyLimits() {
+	if cfg == nil || len(cfg.APIKeyLimits) == 0 {
+		return
+	}
+	out := cfg.APIKeyLimits[:0]
+	for _, entry := range cfg.APIKeyLimits {
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		entry.Provider = strings.TrimSpace(entry.Provider)
+		entry.MaxOutputTokens = max(entry.MaxOutputTokens, 0)
+		entry.MaxCostUSD = max(entry.MaxCostUSD, 0)
+		if entry.APIKey == "" || (entry.MaxOutputTokens == 0 && entry.MaxCostUSD == 0) {
+			continue
+		}
+		out = append(out, entry)
+	}
+	cfg.APIKeyLimits = out
+}
+
+// SanitizeRateLimitQueueKeyWeights trims rate-limit-queue key-weights entries
+// and drops entries without an API key or a positive weight.
+func (cfg *Config) SanitizeRateLimitQueueKeyWeights() {
+	if cfg == nil || len(cfg.RateLimitQueue.KeyWeights) == 0 {
+		return
+	}
+	out := cfg.RateLimitQueue.KeyWeights[:0]
+	for _, entry := range cfg.RateLimitQueue.KeyWeights {
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		entry.Provider = strings.TrimSpace(entry.Provider)
+		if entry.APIKey == "" || entry.Weight <= 0 {
+			continue
+		}
+		out = append(out, entry)
+	}
+	cfg.RateLimitQueue.KeyWeights = out
+}
+
+// SanitizeModelPrices trims model-prices patterns and drops entries without
+// a model or with a negative price.
+func (cfg *Config) SanitizeModelPrices() {
+	if cfg == nil || len(cfg.ModelPrices) == 0 {
+		return
+	}
+	out := cfg.ModelPrices[:0]
+	for _, price := range cfg.ModelPrices {
+		price.Model = strings.TrimSpace(price.Model)
+		if price.Model == "" || price.InputPerMillion < 0 || price.OutputPerMillion < 0 {
+			continue
+		}
+		out = append(out, price)
+	}
+	cfg.ModelPrices = out
+}
+
+// SanitizeAPIKeyReasoning trims api-key-reasoning entries, drops those
+// without an API key and lower-cases modes, turning unknown ones into
+// "stripped".
+func (cfg *Config) SanitizeAPIKeyReasoning() {
+	if cfg == nil || len(cfg.APIKeyReasoning) == 0 {
+		return
+	}
+	out := cfg.APIKeyReasoning[:0]
+	for _, entry := range cfg.APIKeyReasoning {
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		entry.Provider = strings.TrimSpace(entry.Provider)
+		if entry.APIKey == "" {
+			continue
+		}
+		switch entry.Mode = strings.ToLower(strings.TrimSpace(entry.Mode)); entry.Mode {
+		case ReasoningVerbatim, ReasoningSummarized, ReasoningStripped:
+		default:
+			entry.Mode = ReasoningStripped
+		}
+		out = append(out, entry)
+	}
+	cfg.APIKeyReasoning = out
+}
+
+// SanitizeLoopDetection trims loop-detection entries, drops those without an
+// API key, lower-cases actions and resets unknown actions and non-positive
+// thresholds and windows to their defaults.
+func (cfg *Config) SanitizeLoopDetection() {
+	if cfg == nil || len(cfg.LoopDetection) == 0 {
+		return
+	}
+	out := cfg.LoopDetection[:0]
+	for _, entry := range cfg.LoopDetection {
+		entry.APIKey = strings.TrimSpace(entry.APIKey)
+		entry.Provider = strings.TrimSpace(entry.Provider)
+		if entry.APIKey == "" {
+			continue
+		}
+		if entry.Threshold <= 0 {
+			entry.Threshold = DefaultLoopThreshold
+		}
+		if entry.WindowSeconds <= 0 {
+			entry.WindowSeconds = DefaultLoopWindowSeconds
+		}
+		entry.Action = strings.ToLower(strings.TrimSpace(entry.Action))
+		if entry.Action != LoopActionInject {
+			entry.Action = LoopActionError
+		}
+		entry.Message = strings.TrimSpace(entry.Message)
+		out = append(out, entry)
+	}
+	cfg.LoopDetection = out
+}
+
+// SanitizeShadowTraffic trims shadow-traffic rules, drops those without a
+// model, a target model or a positive percentage, and caps percentages at 100.
+func (cfg *Config) SanitizeShadowTraffic() {
+	if cfg == nil {
+		return
+	}
+	cfg.ShadowTraffic.StoreDir = strings.TrimSpace(cfg.ShadowTraffic.StoreDir)
+	out := cfg.ShadowTraffic.Rules[:0]
+	for _, rule := range cfg.ShadowTraffic.Rules {
+		rule.Model = strings.TrimSpace(rule.Model)
+		rule.TargetModel = strings.TrimSpace(rule.TargetModel)
+		if rule.Model == "" || rule.TargetModel == "" || rule.Percent <= 0 {
+			continue
+		}
+		if rule.Percent > 100 {
+			rule.Percent = 100
+		}
+		out = append(out, rule)
+	}
+	cfg.ShadowTraffic.Rules = out
+}
+
+// SanitizeExperiments trims experiments and their arms, drops arms without a
+// model, defaults arm names and weights, and drops experiments without a name,
+// a model, or at least two arms.
+func (cfg *Config) SanitizeExperiments() {
+	if cfg == nil || len(cfg.Experiments) == 0 {
+		return
+	}
+	out := cfg.Experiments[:0]
+	for _, exp := range cfg.Experiments {
+		exp.Name = strings.TrimSpace(exp.Name)
+		exp.Model = strings.TrimSpace(exp.Model)
+		keys := exp.APIKeys[:0]
+		for _, key := range exp.APIKeys {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
+			}
+		}
+		exp.APIKeys = keys
+		arms := make([]ExperimentArm, 0, len(exp.Arms))
+		for _, arm := range exp.Arms {
+			arm.Model = strings.TrimSpace(arm.Model)
+			if arm.Model == "" {
+				continue
+			}
+			if arm.Name = strings.TrimSpace(arm.Name); arm.Name == "" {
+				arm.Name = arm.Model
+			}
+			if arm.Weight <= 0 {
+				arm.Weight = 1
+			}
+			arms = append(arms, arm)
+		}
+		exp.Arms = arms
+		if exp.Name == "" || exp.Model == "" || len(exp.Arms) < 2 {
+			continue
+		}
+		out = append(out, exp)
+	}
+	cfg.Experiments = out
+}
+
+// SanitizeSmartRouters trims smart routers, their rules and classifiers,
+// lower-cases thinking levels and keywords, drops rules without a model, and
+// drops routers without a name or a default model.
+func (cfg *Config) SanitizeSmartRouters() {
+	if cfg == nil || len(cfg.SmartRouters) == 0 {
+		return
+	}
+	out := cfg.SmartRouters[:0]
+	for _, router := range cfg.SmartRouters {
+		router.Name = strings.TrimSpace(router.Name)
+		router.DefaultModel = strings.TrimSpace(router.DefaultModel)
+		if router.Name == "" || router.DefaultModel == "" {
+			continue
+		}
+		rules := make([]SmartRouterRule, 0, len(router.Rules))
+		for _, rule := range router.Rules {
+			if rule.Model = strings.TrimSpace(rule.Model); rule.Model == "" {
+				continue
+			}
+			rule.Thinking = lowerNonEmpty(rule.Thinking)
+			rule.Keywords = lowerNonEmpty(rule.Keywords)
+			rules = append(rules, rule)
+		}
+		router.Rules = rules
+		router.Classifier = sanitizeSmartRouterClassifier(router.Classifier)
+		out = append(out, router)
+	}
+	cfg.SmartRouters = out
+}
+
+// SanitizePromptTemplates trims prompt templates, lower-cases message roles
+// and defaults them to "user", drops messages without content or with a role
+// other than user or assistant, and drops templates without a name, without
+// a prompt, or named like an earlier template.
+func (cfg *Config) SanitizePromptTemplates() {
+	if cfg == nil || len(cfg.PromptTemplates) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.PromptTemplates))
+	out := cfg.PromptTemplates[:0]
+	for _, tmpl := range cfg.PromptTemplates {
+		tmpl.Name = strings.TrimSpace(tmpl.Name)
+		key := strings.ToLower(tmpl.Name)
+		if key == "" {
+			continue
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		tmpl.Description = strings.TrimSpace(tmpl.Description)
+		messages := make([]PromptTemplateMessage, 0, len(tmpl.Messages))
+		for _, msg := range tmpl.Messages {
+			msg.Role = strings.ToLower(strings.TrimSpace(msg.Role))
+			if msg.Role == "" {
+				msg.Role = "user"
+			}
+			if strings.TrimSpace(msg.Content) == "" || (msg.Role != "user" && msg.Role != "assistant") {
+				continue
+			}
+			messages = append(messages, msg)
+		}
+		tmpl.Messages = messages
+		if strings.TrimSpace(tmpl.System) == "" && len(tmpl.Messages) == 0 {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, tmpl)
+	}
+	cfg.PromptTemplates = out
+}
+
+// sanitizeSmartRouterClassifier trims a classifier, lower-cases class names,
+// drops classes without a name or model and keeps the first of duplicates.
+// A classifier left without classes is removed.
+func sanitizeSmartRouterClassifier(classifier *SmartRouterClassifier) *SmartRouterClassifier {
+	if classifier == nil {
+		return nil
+	}
+	out := *classifier
+	out.Model = strings.TrimSpace(out.Model)
+	out.Classes = make([]SmartRouterClass, 0, len(classifier.Classes))
+	seen := make(map[string]struct{}, len(classifier.Classes))
+	for _, class := range classifier.Classes {
+		class.Name = strings.ToLower(strings.TrimSpace(class.Name))
+		class.Model = strings.TrimSpace(class.Model)
+		class.Description = strings.TrimSpace(class.Description)
+		if class.Name == "" || class.Model == "" {
+			continue
+		}
+		if _, dup := seen[class.Name]; dup {
+			continue
+		}
+		seen[class.Name] = struct{}{}
+		out.Classes = append(out.Classes, class)
+	}
+	if len(out.Classes) == 0 {
+		return nil
+	}
+	return &out
+}
+
+func lowerNonEmpty(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+// SanitizeExecutorPlugins trims executor plugin paths and drops blank or
+// duplicate entries, keeping the first occurrence.
+func (cfg *Config) SanitizeExecutorPlugins() {
+	if cfg == nil || len(cfg.ExecutorPlugins) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.ExecutorPlugins))
+	out := make([]string, 0, len(cfg.ExecutorPlugins))
+	for _, path := range cfg.ExecutorPlugins {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if _, dup := seen[path]; dup {
+			continue
+		}
+		seen[path] = struct{}{}
+		out = append(out, path)
+	}
+	cfg.ExecutorPlugins = out
+}
+
 // SanitizeOAuthModelAlias normalizes and deduplicates global OAuth model name aliases.
 // It trims whitespace, normalizes channel keys to lower-case, drops empty entries,
 // allows multiple aliases per upstream name, and ensures aliases are unique within each channel.
@@ -1145,7 +2280,8 @@ func hashSecret(secret string) (string, error) {
 // SaveConfigPreserveComments writes the config back to YAML while preserving existing comments
 // and key ordering by loading the original file into a yaml.Node tree and updating values in-place.
 func SaveConfigPreserveComments(configFile string, cfg *Config) error {
-	persistCfg := cfg
+	// Providers taken from the environment stay out of the file.
+	persistCfg := cfg.withoutEnvironmentProviders()
 	// Load original YAML as a node tree to preserve comments and ordering.
 	data, err := os.ReadFile(configFile)
 	if err != nil {
@@ -1179,6 +2315,15 @@ func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 		return fmt.Errorf("expected generated root mapping node")
 	}
 
+	// Leave values that come from includes and overlays where they are.
+	if err = keepLayeredValues(configFile, data, original.Content[0], generated.Content[0]); err != nil {
+		return err
+	}
+
+	// Put ${...}, file: and vault: references back in place of the secrets
+	// they resolved to.
+	configFileReferences(configFile, data).restore(generated.Content[0], "")
+
 	// Remove deprecated sections before merging back the sanitized config.
 	removeLegacyAuthBlock(original.Content[0])
 	removeLegacyOpenAICompatAPIKeys(original.Content[0])
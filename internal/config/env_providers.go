@@ -0,0 +1,259 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// API-key providers can be configured entirely from the environment, for
+// container deployments that should not write config or auth files:
+//
+//	CLIPROXY_PROVIDER_<NAME>_KEYS       API keys, comma or newline separated (required)
+//	CLIPROXY_PROVIDER_<NAME>_BASE_URL   upstream endpoint
+//	CLIPROXY_PROVIDER_<NAME>_PREFIX     model prefix clients must use
+//	CLIPROXY_PROVIDER_<NAME>_PROXY_URL  proxy for this provider's requests
+//	CLIPROXY_PROVIDER_<NAME>_MODELS     models, "name" or "name=alias" (OpenAI-compatible only)
+//
+// GEMINI, CLAUDE and CODEX add gemini-api-key, claude-api-key and
+// codex-api-key entries. Any other NAME adds its keys to the
+// openai-compatibility provider of that name (lower case, "_" read as "-"),
+// creating it when the config has none. Entries added this way are never
+// written back to the config file.
+const (
+	envProviderPrefix = "CLIPROXY_PROVIDER_"
+	envProviderKeys   = "_KEYS"
+)
+
+// envProviderBaseURLs are the endpoints used for well-known
+// OpenAI-compatible providers when no _BASE_URL is given.
+var envProviderBaseURLs = map[string]string{
+	"codex":      "https://api.openai.com/v1",
+	"deepseek":   "https://api.deepseek.com/v1",
+	"groq":       "https://api.groq.com/openai/v1",
+	"mistral":    "https://api.mistral.ai/v1",
+	"openrouter": "https://openrouter.ai/api/v1",
+	"xai":        "https://api.x.ai/v1",
+}
+
+// environmentProviders records what applyEnvironmentProviders added, so
+// saves can leave it out.
+type environmentProviders struct {
+	gemini map[string]bool
+	claude map[string]bool
+	codex  map[string]bool
+	// compatKeys maps openai-compatibility provider names to the keys added.
+	compatKeys map[string]map[string]bool
+	// compatCreated lists openai-compatibility providers created outright.
+	compatCreated map[string]bool
+}
+
+func (e *environmentProviders) empty() bool {
+	return e == nil || len(e.gemini)+len(e.claude)+len(e.codex)+len(e.compatKeys) == 0
+}
+
+// applyEnvironmentProviders adds the providers described by environ, a list
+// of KEY=value pairs, to cfg. Keys already in the config are not added twice.
+func (cfg *Config) applyEnvironmentProviders(environ []string) {
+	values := make(map[string]string)
+	var names []string
+	for _, pair := range environ {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || !strings.HasPrefix(key, envProviderPrefix) {
+			continue
+		}
+		values[key] = strings.TrimSpace(value)
+		if name, isKeys := strings.CutSuffix(strings.TrimPrefix(key, envProviderPrefix), envProviderKeys); isKeys && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	added := &environmentProviders{
+		gemini:        make(map[string]bool),
+		claude:        make(map[string]bool),
+		codex:         make(map[string]bool),
+		compatKeys:    make(map[string]map[string]bool),
+		compatCreated: make(map[string]bool),
+	}
+	for _, envName := range names {
+		setting := func(suffix string) string { return values[envProviderPrefix+envName+suffix] }
+		keys := splitEnvList(setting(envProviderKeys))
+		if len(keys) == 0 {
+			continue
+		}
+		name := strings.ReplaceAll(strings.ToLower(envName), "_", "-")
+		baseURL := setting("_BASE_URL")
+		prefix := setting("_PREFIX")
+		proxyURL := setting("_PROXY_URL")
+
+		switch name {
+		case "gemini":
+			for _, key := range keys {
+				if !added.gemini[key] && !hasGeminiKey(cfg.GeminiKey, key) {
+					cfg.GeminiKey = append(cfg.GeminiKey, GeminiKey{APIKey: key, BaseURL: baseURL, Prefix: prefix, ProxyURL: proxyURL})
+					added.gemini[key] = true
+				}
+			}
+		case "claude":
+			for _, key := range keys {
+				if !added.claude[key] && !hasClaudeKey(cfg.ClaudeKey, key) {
+					cfg.ClaudeKey = append(cfg.ClaudeKey, ClaudeKey{APIKey: key, BaseURL: baseURL, Prefix: prefix, ProxyURL: proxyURL})
+					added.claude[key] = true
+				}
+			}
+		case "codex":
+			if baseURL == "" {
+				baseURL = envProviderBaseURLs[name]
+			}
+			for _, key := range keys {
+				if !added.codex[key] && !hasCodexKey(cfg.CodexKey, key) {
+					cfg.CodexKey = append(cfg.CodexKey, CodexKey{APIKey: key, BaseURL: baseURL, Prefix: prefix, ProxyURL: proxyURL})
+					added.codex[key] = true
+				}
+			}
+		default:
+			cfg.addEnvironmentCompatProvider(added, envName, name, keys, baseURL, prefix, proxyURL, setting("_MODELS"))
+		}
+	}
+	if !added.empty() {
+		cfg.environmentProviders = added
+	}
+}
+
+func (cfg *Config) addEnvironmentCompatProvider(added *environmentProviders, envName, name string, keys []string, baseURL, prefix, proxyURL, models string) {
+	idx := -1
+	for i := range cfg.OpenAICompatibility {
+		if strings.EqualFold(strings.TrimSpace(cfg.OpenAICompatibility[i].Name), name) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		if baseURL == "" {
+			baseURL = envProviderBaseURLs[name]
+		}
+		if baseURL == "" {
+			log.Warnf("config: %s%s%s ignored: set %s%s_BASE_URL", envProviderPrefix, envName, envProviderKeys, envProviderPrefix, envName)
+			return
+		}
+		provider := OpenAICompatibility{Name: name, BaseURL: baseURL, Prefix: prefix}
+		for _, model := range splitEnvList(models) {
+			modelName, alias, _ := strings.Cut(model, "=")
+			modelName = strings.TrimSpace(modelName)
+			alias = strings.TrimSpace(alias)
+			if alias == "" {
+				alias = modelName
+			}
+			provider.Models = append(provider.Models, OpenAICompatibilityModel{Name: modelName, Alias: alias})
+		}
+		if len(provider.Models) == 0 {
+			log.Warnf("config: openai-compatibility provider %s from the environment has no models; set %s%s_MODELS", name, envProviderPrefix, envName)
+		}
+		cfg.OpenAICompatibility = append(cfg.OpenAICompatibility, provider)
+		idx = len(cfg.OpenAICompatibility) - 1
+		added.compatCreated[name] = true
+	}
+
+	provider := &cfg.OpenAICompatibility[idx]
+	existing := make(map[string]bool, len(provider.APIKeyEntries))
+	for _, entry := range provider.APIKeyEntries {
+		existing[strings.TrimSpace(entry.APIKey)] = true
+	}
+	for _, key := range keys {
+		if existing[key] {
+			continue
+		}
+		existing[key] = true
+		provider.APIKeyEntries = append(provider.APIKeyEntries, OpenAICompatibilityAPIKey{APIKey: key, ProxyURL: proxyURL})
+		if added.compatKeys[name] == nil {
+			added.compatKeys[name] = make(map[string]bool)
+		}
+		added.compatKeys[name][key] = true
+	}
+}
+
+// withoutEnvironmentProviders returns cfg, or a shallow copy of it without
+// the entries applyEnvironmentProviders added, for writing to disk.
+func (cfg *Config) withoutEnvironmentProviders() *Config {
+	added := cfg.environmentProviders
+	if added.empty() {
+		return cfg
+	}
+	out := *cfg
+	out.environmentProviders = nil
+	out.GeminiKey = nil
+	for _, entry := range cfg.GeminiKey {
+		if !added.gemini[entry.APIKey] {
+			out.GeminiKey = append(out.GeminiKey, entry)
+		}
+	}
+	out.ClaudeKey = nil
+	for _, entry := range cfg.ClaudeKey {
+		if !added.claude[entry.APIKey] {
+			out.ClaudeKey = append(out.ClaudeKey, entry)
+		}
+	}
+	out.CodexKey = nil
+	for _, entry := range cfg.CodexKey {
+		if !added.codex[entry.APIKey] {
+			out.CodexKey = append(out.CodexKey, entry)
+		}
+	}
+	out.OpenAICompatibility = nil
+	for _, provider := range cfg.OpenAICompatibility {
+		name := strings.ToLower(strings.TrimSpace(provider.Name))
+		if added.compatCreated[name] {
+			continue
+		}
+		if keys := added.compatKeys[name]; len(keys) > 0 {
+			entries := make([]OpenAICompatibilityAPIKey, 0, len(provider.APIKeyEntries))
+			for _, entry := range provider.APIKeyEntries {
+				if !keys[entry.APIKey] {
+					entries = append(entries, entry)
+				}
+			}
+			provider.APIKeyEntries = entries
+		}
+		out.OpenAICompatibility = append(out.OpenAICompatibility, provider)
+	}
+	return &out
+}
+
+func splitEnvList(value string) []string {
+	var out []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func hasGeminiKey(entries []GeminiKey, key string) bool {
+	for _, entry := range entries {
+		if strings.TrimSpace(entry.APIKey) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func hasClaudeKey(entries []ClaudeKey, key string) bool {
+	for _, entry := range entries {
+		if strings.TrimSpace(entry.APIKey) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func hasCodexKey(entries []CodexKey, key string) bool {
+	for _, entry := range entries {
+		if strings.TrimSpace(entry.APIKey) == key {
+			return true
+		}
+	}
+	return false
+}
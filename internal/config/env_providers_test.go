@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyEnvironmentProviders(t *testing.T) {
+	cfg := &Config{
+		GeminiKey: []GeminiKey{{APIKey: "g-file"}},
+		OpenAICompatibility: []OpenAICompatibility{{
+			Name:          "Groq",
+			BaseURL:       "https://groq.example/v1",
+			APIKeyEntries: []OpenAICompatibilityAPIKey{{APIKey: "groq-file"}},
+		}},
+	}
+	cfg.applyEnvironmentProviders([]string{
+		"CLIPROXY_PROVIDER_GEMINI_KEYS=g-file, g-env",
+		"CLIPROXY_PROVIDER_CODEX_KEYS=sk-codex",
+		"CLIPROXY_PROVIDER_OPENROUTER_KEYS=or-1\nor-2",
+		"CLIPROXY_PROVIDER_OPENROUTER_MODELS=openai/gpt-4o=gpt-4o,anthropic/claude-sonnet-4",
+		"CLIPROXY_PROVIDER_OPENROUTER_PREFIX=or",
+		"CLIPROXY_PROVIDER_GROQ_KEYS=groq-env",
+		"CLIPROXY_PROVIDER_MY_GATEWAY_KEYS=gw",
+		"CLIPROXY_PROVIDER_EMPTY_KEYS= ",
+		"UNRELATED_KEYS=x",
+	})
+
+	if len(cfg.GeminiKey) != 2 || cfg.GeminiKey[1].APIKey != "g-env" {
+		t.Fatalf("gemini keys = %+v, want the file key plus g-env", cfg.GeminiKey)
+	}
+	if len(cfg.CodexKey) != 1 || cfg.CodexKey[0].BaseURL != "https://api.openai.com/v1" {
+		t.Fatalf("codex keys = %+v", cfg.CodexKey)
+	}
+	if len(cfg.OpenAICompatibility) != 2 {
+		t.Fatalf("openai-compatibility = %+v, want groq and openrouter (my-gateway has no base URL)", cfg.OpenAICompatibility)
+	}
+	groq := cfg.OpenAICompatibility[0]
+	if len(groq.APIKeyEntries) != 2 || groq.APIKeyEntries[1].APIKey != "groq-env" || groq.BaseURL != "https://groq.example/v1" {
+		t.Fatalf("groq = %+v, want the env key added to the file provider", groq)
+	}
+	openrouter := cfg.OpenAICompatibility[1]
+	if openrouter.Name != "openrouter" || openrouter.BaseURL != "https://openrouter.ai/api/v1" || openrouter.Prefix != "or" {
+		t.Fatalf("openrouter = %+v", openrouter)
+	}
+	if len(openrouter.APIKeyEntries) != 2 || len(openrouter.Models) != 2 {
+		t.Fatalf("openrouter keys/models = %+v / %+v", openrouter.APIKeyEntries, openrouter.Models)
+	}
+	if openrouter.Models[0].Alias != "gpt-4o" || openrouter.Models[1].Alias != "anthropic/claude-sonnet-4" {
+		t.Fatalf("openrouter models = %+v", openrouter.Models)
+	}
+
+	persisted := cfg.withoutEnvironmentProviders()
+	if len(persisted.GeminiKey) != 1 || persisted.GeminiKey[0].APIKey != "g-file" {
+		t.Fatalf("persisted gemini keys = %+v", persisted.GeminiKey)
+	}
+	if len(persisted.CodexKey) != 0 {
+		t.Fatalf("persisted codex keys = %+v", persisted.CodexKey)
+	}
+	if len(persisted.OpenAICompatibility) != 1 || len(persisted.OpenAICompatibility[0].APIKeyEntries) != 1 {
+		t.Fatalf("persisted openai-compatibility = %+v", persisted.OpenAICompatibility)
+	}
+	if len(cfg.OpenAICompatibility[0].APIKeyEntries) != 2 {
+		t.Fatal("withoutEnvironmentProviders modified the live config")
+	}
+}
+
+func TestEnvironmentProvidersAreNotSaved(t *testing.T) {
+	t.Setenv("CLIPROXY_PROVIDER_CLAUDE_KEYS", "sk-ant-env")
+	t.Setenv("CLIPROXY_PROVIDER_OPENROUTER_KEYS", "sk-or-env")
+	t.Setenv("CLIPROXY_PROVIDER_OPENROUTER_MODELS", "openai/gpt-4o")
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("port: 8317\nclaude-api-key:\n  - api-key: sk-ant-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.ClaudeKey) != 2 || len(cfg.OpenAICompatibility) != 1 {
+		t.Fatalf("environment providers not loaded: claude=%+v compat=%+v", cfg.ClaudeKey, cfg.OpenAICompatibility)
+	}
+
+	cfg.Port = 9000
+	if err = SaveConfigPreserveComments(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	data, _ := os.ReadFile(configPath)
+	saved := string(data)
+	if !strings.Contains(saved, "9000") || !strings.Contains(saved, "sk-ant-file") {
+		t.Fatalf("saved config lost file values:\n%s", saved)
+	}
+	if strings.Contains(saved, "sk-ant-env") || strings.Contains(saved, "sk-or-env") || strings.Contains(saved, "openrouter") {
+		t.Fatalf("saved config contains environment providers:\n%s", saved)
+	}
+}
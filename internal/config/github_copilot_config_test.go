@@ -39,9 +39,13 @@ func TestGitHubCopilotHeaderPolicy_InvalidModeFallsBackToLegacy(t *testing.T) {
 		t.Fatalf("write config file: %v", err)
 	}
 
-	cfg, err := LoadConfig(configPath)
+	// Strict loading rejects the unknown mode; tolerant loading still falls back.
+	if _, err := LoadConfig(configPath); len(ValidationIssues(err)) == 0 {
+		t.Fatalf("LoadConfig() error = %v, want validation error", err)
+	}
+	cfg, err := LoadConfigOptional(configPath, true)
 	if err != nil {
-		t.Fatalf("LoadConfig() error = %v", err)
+		t.Fatalf("LoadConfigOptional() error = %v", err)
 	}
 	if got := cfg.GitHubCopilot.HeaderPolicy.Mode; got != GitHubCopilotHeaderPolicyModeLegacy {
 		t.Fatalf("GitHubCopilot.HeaderPolicy.Mode = %q, want %q", got, GitHubCopilotHeaderPolicyModeLegacy)
@@ -0,0 +1,321 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// Config values may reference secrets instead of holding them:
+//
+//	${NAME}               environment variable (error when unset)
+//	${NAME:-fallback}     environment variable with a default
+//	${file:/path}         file contents, trailing newlines trimmed
+//	${vault:path#field}   HashiCorp Vault KV secret (v1 or v2)
+//	file:/path            whole-value shorthand for ${file:/path}
+//	vault:path#field      whole-value shorthand for ${vault:path#field}
+//
+// "$${" escapes a literal "${". Vault is reached through VAULT_ADDR and
+// VAULT_TOKEN (plus VAULT_NAMESPACE when set).
+const (
+	fileReferencePrefix  = "file:"
+	vaultReferencePrefix = "vault:"
+
+	vaultRequestTimeout = 10 * time.Second
+)
+
+var (
+	// loadedConfigReferences keeps the references seen by the last load of
+	// each config file, covering saves made while a secret source is down.
+	loadedConfigReferences sync.Map
+
+	configReferencePattern = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+	envVarNamePattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// configReference remembers the raw text a resolved value came from so the
+// reference, not the secret, is written back on save.
+type configReference struct {
+	value string
+	raw   string
+}
+
+// configReferences indexes references by schema path ("[]" for any index).
+type configReferences map[string][]configReference
+
+// configReferenceResolver expands references in a YAML tree. Vault responses
+// are cached so each secret path is fetched once per load.
+type configReferenceResolver struct {
+	issues     []ValidationIssue
+	references configReferences
+	vault      map[string]map[string]any
+	client     *http.Client
+}
+
+// resolveConfigReferences expands references in every scalar value of root,
+// in place. Values that fail to resolve become null and are reported as issues.
+func resolveConfigReferences(root *yaml.Node) ([]ValidationIssue, configReferences) {
+	r := &configReferenceResolver{
+		references: make(configReferences),
+		vault:      make(map[string]map[string]any),
+		client:     &http.Client{Timeout: vaultRequestTimeout},
+	}
+	if root != nil && root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		r.walk(root.Content[0], "")
+	}
+	return r.issues, r.references
+}
+
+func (r *configReferenceResolver) walk(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			r.walk(node.Content[i+1], joinConfigPath(path, node.Content[i].Value))
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			r.walk(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case yaml.ScalarNode:
+		r.resolveScalar(node, path)
+	}
+}
+
+func (r *configReferenceResolver) resolveScalar(node *yaml.Node, path string) {
+	if node.Tag == "!!null" || !hasConfigReference(node.Value) {
+		return
+	}
+	raw := node.Value
+	value, errExpand := r.expand(raw)
+	if errExpand != nil {
+		r.issues = append(r.issues, ValidationIssue{Line: node.Line, Column: node.Column, Path: path, Message: errExpand.Error(), node: node})
+		node.Value, node.Tag = "", "!!null"
+		return
+	}
+	node.Value = value
+	// Plain scalars re-resolve so "port: ${PORT}" still decodes as an int;
+	// quoted ones stay strings.
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+		node.Tag = ""
+	} else {
+		node.Tag = "!!str"
+	}
+	key := schemaPath(path)
+	r.references[key] = append(r.references[key], configReference{value: value, raw: raw})
+}
+
+func hasConfigReference(value string) bool {
+	return strings.Contains(value, "${") || bareConfigReference(value) != ""
+}
+
+// bareConfigReference returns the expression for whole-value file:/vault:
+// shorthands, or "" when value is not one.
+func bareConfigReference(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if path, ok := strings.CutPrefix(trimmed, fileReferencePrefix); ok && strings.TrimSpace(path) != "" {
+		return trimmed
+	}
+	if ref, ok := strings.CutPrefix(trimmed, vaultReferencePrefix); ok && strings.Contains(ref, "#") {
+		return trimmed
+	}
+	return ""
+}
+
+func (r *configReferenceResolver) expand(value string) (string, error) {
+	if expr := bareConfigReference(value); expr != "" {
+		return r.lookup(expr)
+	}
+	var firstErr error
+	out := configReferencePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		resolved, errLookup := r.lookup(match[2 : len(match)-1])
+		if errLookup != nil && firstErr == nil {
+			firstErr = errLookup
+		}
+		return resolved
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return out, nil
+}
+
+func (r *configReferenceResolver) lookup(expr string) (string, error) {
+	expr = strings.TrimSpace(expr)
+	if path, ok := strings.CutPrefix(expr, fileReferencePrefix); ok {
+		return readFileReference(strings.TrimSpace(path))
+	}
+	if ref, ok := strings.CutPrefix(expr, vaultReferencePrefix); ok {
+		return r.readVaultReference(strings.TrimSpace(ref))
+	}
+	name, fallback, hasFallback := strings.Cut(expr, ":-")
+	if !envVarNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid reference ${%s}", expr)
+	}
+	if value, ok := os.LookupEnv(name); ok && (value != "" || !hasFallback) {
+		return value, nil
+	}
+	if hasFallback {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("environment variable %q is not set", name)
+}
+
+func readFileReference(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("file reference has no path")
+	}
+	data, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", errRead)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func (r *configReferenceResolver) readVaultReference(ref string) (string, error) {
+	secretPath, field, _ := strings.Cut(ref, "#")
+	secretPath = strings.Trim(strings.TrimSpace(secretPath), "/")
+	field = strings.TrimSpace(field)
+	if secretPath == "" || field == "" {
+		return "", fmt.Errorf("vault reference %q must look like path#field", ref)
+	}
+	data, ok := r.vault[secretPath]
+	if !ok {
+		var errFetch error
+		if data, errFetch = r.fetchVaultSecret(secretPath); errFetch != nil {
+			return "", errFetch
+		}
+		r.vault[secretPath] = data
+	}
+	// KV v2 nests the secret under data.data; KV v1 returns it under data.
+	if nested, isMap := data["data"].(map[string]any); isMap {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+	value, ok := data[field]
+	if !ok || value == nil {
+		return "", fmt.Errorf("vault secret %q has no field %q", secretPath, field)
+	}
+	if s, isString := value.(string); isString {
+		return s, nil
+	}
+	encoded, errMarshal := json.Marshal(value)
+	if errMarshal != nil {
+		return "", fmt.Errorf("vault secret %q field %q: %w", secretPath, field, errMarshal)
+	}
+	return string(encoded), nil
+}
+
+func (r *configReferenceResolver) fetchVaultSecret(secretPath string) (map[string]any, error) {
+	addr := strings.TrimRight(strings.TrimSpace(os.Getenv("VAULT_ADDR")), "/")
+	token := strings.TrimSpace(os.Getenv("VAULT_TOKEN"))
+	if addr == "" || token == "" {
+		return nil, fmt.Errorf("vault reference requires VAULT_ADDR and VAULT_TOKEN")
+	}
+	req, errReq := http.NewRequest(http.MethodGet, addr+"/v1/"+secretPath, nil)
+	if errReq != nil {
+		return nil, fmt.Errorf("vault request: %w", errReq)
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := strings.TrimSpace(os.Getenv("VAULT_NAMESPACE")); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, errDo := r.client.Do(req)
+	if errDo != nil {
+		return nil, fmt.Errorf("vault request: %w", errDo)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, errBody := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if errBody != nil {
+		return nil, fmt.Errorf("vault response: %w", errBody)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %d for %q", resp.StatusCode, secretPath)
+	}
+	var payload struct {
+		Data map[string]any `json:"data"`
+	}
+	if errDecode := json.Unmarshal(body, &payload); errDecode != nil {
+		return nil, fmt.Errorf("vault response: %w", errDecode)
+	}
+	return payload.Data, nil
+}
+
+// hasReference reports whether the value at path came from a reference.
+func (refs configReferences) hasReference(path string) bool {
+	return len(refs[schemaPath(path)]) > 0
+}
+
+// restore swaps resolved secrets in a generated YAML tree back to the
+// references they came from, so saving never writes a secret to disk.
+func (refs configReferences) restore(node *yaml.Node, path string) {
+	if len(refs) == 0 || node == nil {
+		return
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			refs.restore(node.Content[i+1], joinConfigPath(path, node.Content[i].Value))
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			refs.restore(item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case yaml.ScalarNode:
+		for _, ref := range refs[schemaPath(path)] {
+			// The management secret-key is hashed in memory after resolution.
+			matches := node.Value == ref.value ||
+				(looksLikeBcrypt(node.Value) && bcrypt.CompareHashAndPassword([]byte(node.Value), []byte(ref.value)) == nil)
+			if matches {
+				node.Value, node.Tag, node.Style = ref.raw, "!!str", 0
+				return
+			}
+		}
+	}
+}
+
+// configFileReferences resolves the references in a config file's raw bytes,
+// ignoring failures, and adds those remembered from the last load so a save
+// can map resolved values back to them.
+func configFileReferences(configFile string, data []byte) configReferences {
+	refs := make(configReferences)
+	var root yaml.Node
+	if yaml.Unmarshal(data, &root) == nil {
+		_, refs = resolveConfigReferences(&root)
+	}
+	if cached, ok := loadedConfigReferences.Load(configReferenceKey(configFile)); ok {
+		for path, entries := range cached.(configReferences) {
+			refs[path] = append(refs[path], entries...)
+		}
+	}
+	return refs
+}
+
+func rememberConfigReferences(configFile string, refs configReferences) {
+	key := configReferenceKey(configFile)
+	if len(refs) == 0 {
+		loadedConfigReferences.Delete(key)
+		return
+	}
+	loadedConfigReferences.Store(key, refs)
+}
+
+func configReferenceKey(configFile string) string {
+	if abs, errAbs := filepath.Abs(configFile); errAbs == nil {
+		return abs
+	}
+	return configFile
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig_ResolvesEnvAndFileReferences(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "claude.key")
+	if err := os.WriteFile(secretFile, []byte("sk-ant-file\n"), 0o600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	t.Setenv("CLIPROXY_TEST_PORT", "9000")
+	t.Setenv("CLIPROXY_TEST_KEY", "sk-env")
+	path := writeTestConfig(t, dir, `port: ${CLIPROXY_TEST_PORT}
+proxy-url: "socks5://${CLIPROXY_TEST_PROXY_HOST:-127.0.0.1}:1080"
+api-keys:
+  - ${CLIPROXY_TEST_KEY}
+  - "literal-$${NOT_EXPANDED}"
+claude-api-key:
+  - api-key: file:`+secretFile+`
+  - api-key: "prefix-${file:`+secretFile+`}"
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != 9000 {
+		t.Fatalf("port = %d, want 9000", cfg.Port)
+	}
+	if cfg.ProxyURL != "socks5://127.0.0.1:1080" {
+		t.Fatalf("proxy-url = %q", cfg.ProxyURL)
+	}
+	if len(cfg.APIKeys) != 2 || cfg.APIKeys[0] != "sk-env" || cfg.APIKeys[1] != "literal-${NOT_EXPANDED}" {
+		t.Fatalf("api-keys = %v", cfg.APIKeys)
+	}
+	if len(cfg.ClaudeKey) != 2 || cfg.ClaudeKey[0].APIKey != "sk-ant-file" || cfg.ClaudeKey[1].APIKey != "prefix-sk-ant-file" {
+		t.Fatalf("claude-api-key = %+v", cfg.ClaudeKey)
+	}
+}
+
+func TestLoadConfig_ResolvesVaultReferences(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/cliproxy":
+			_, _ = w.Write([]byte(`{"data":{"data":{"codex":"sk-codex","gemini":"g-key"},"metadata":{"version":3}}}`))
+		case "/v1/kv/legacy":
+			_, _ = w.Write([]byte(`{"data":{"token":"v1-token"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+
+	path := writeTestConfig(t, t.TempDir(), `codex-api-key:
+  - api-key: vault:secret/data/cliproxy#codex
+    base-url: https://codex.example.com
+gemini-api-key:
+  - api-key: ${vault:secret/data/cliproxy#gemini}
+api-keys:
+  - vault:kv/legacy#token
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.CodexKey) != 1 || cfg.CodexKey[0].APIKey != "sk-codex" {
+		t.Fatalf("codex-api-key = %+v", cfg.CodexKey)
+	}
+	if len(cfg.GeminiKey) != 1 || cfg.GeminiKey[0].APIKey != "g-key" {
+		t.Fatalf("gemini-api-key = %+v", cfg.GeminiKey)
+	}
+	if len(cfg.APIKeys) != 1 || cfg.APIKeys[0] != "v1-token" {
+		t.Fatalf("api-keys = %v", cfg.APIKeys)
+	}
+	if requests != 2 {
+		t.Fatalf("expected one vault request per secret path, got %d", requests)
+	}
+}
+
+func TestLoadConfig_ReportsUnresolvedReferences(t *testing.T) {
+	path := writeTestConfig(t, t.TempDir(), "port: 8317\napi-keys:\n  - ${CLIPROXY_TEST_UNSET_KEY}\n")
+
+	_, err := LoadConfig(path)
+	issues := ValidationIssues(err)
+	if len(issues) != 1 || issues[0].Line != 3 || issues[0].Path != "api-keys[0]" {
+		t.Fatalf("expected one issue at api-keys[0] line 3, got %v", err)
+	}
+	if !strings.Contains(issues[0].Message, `"CLIPROXY_TEST_UNSET_KEY" is not set`) {
+		t.Fatalf("unexpected message: %q", issues[0].Message)
+	}
+
+	cfg, err := LoadConfigOptional(path, true)
+	if err != nil || cfg.Port != 8317 || len(cfg.APIKeys) != 0 {
+		t.Fatalf("optional load should drop the unresolved value, got cfg=%+v err=%v", cfg, err)
+	}
+}
+
+func TestSaveConfigPreserveComments_KeepsReferences(t *testing.T) {
+	t.Setenv("CLIPROXY_TEST_KEY", "sk-env")
+	t.Setenv("CLIPROXY_TEST_MGMT", "mgmt-secret")
+	path := writeTestConfig(t, t.TempDir(), `debug: false
+remote-management:
+  secret-key: ${CLIPROXY_TEST_MGMT}
+api-keys:
+  - ${CLIPROXY_TEST_KEY}
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !looksLikeBcrypt(cfg.RemoteManagement.SecretKey) {
+		t.Fatalf("secret-key should be hashed in memory, got %q", cfg.RemoteManagement.SecretKey)
+	}
+	cfg.Debug = true
+	cfg.APIKeys = append(cfg.APIKeys, "added-key")
+	if err = SaveConfigPreserveComments(path, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	saved := string(data)
+	for _, want := range []string{"debug: true", "secret-key: ${CLIPROXY_TEST_MGMT}", "- ${CLIPROXY_TEST_KEY}", "- added-key"} {
+		if !strings.Contains(saved, want) {
+			t.Fatalf("saved config missing %q:\n%s", want, saved)
+		}
+	}
+	if strings.Contains(saved, "sk-env") || strings.Contains(saved, "$2a$") {
+		t.Fatalf("saved config leaked a resolved secret:\n%s", saved)
+	}
+}
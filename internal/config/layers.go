@@ -0,0 +1,323 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// A config is assembled from layers, lowest precedence first:
+//
+//  1. files listed under the top-level "include:" key (in order, globs sorted),
+//     each of which may include further files;
+//  2. the config file itself;
+//  3. config.<CLIPROXY_ENV>.yaml next to it, when CLIPROXY_ENV is set;
+//  4. config.local.yaml next to it.
+//
+// Later layers win. Mappings merge key by key; sequences and scalars are
+// replaced as a whole, so a provider list lives in exactly one layer.
+const (
+	configIncludeKey     = "include"
+	configEnvironmentVar = "CLIPROXY_ENV"
+	configLocalOverlay   = "local"
+)
+
+// configLayers is the merged view of a config file and everything it pulls in.
+type configLayers struct {
+	root yaml.Node
+	// files lists every file read, in the order it was read.
+	files []string
+	// origins maps each node to the file it was parsed from.
+	origins map[*yaml.Node]string
+	// overlayKeys maps top-level keys to the highest overlay that sets them.
+	overlayKeys map[string]string
+}
+
+// loadConfigLayers parses configFile (whose contents are data) together with
+// its includes and overlays and merges them into one document.
+func loadConfigLayers(configFile string, data []byte) (*configLayers, error) {
+	l := &configLayers{
+		origins:     make(map[*yaml.Node]string),
+		overlayKeys: make(map[string]string),
+	}
+	merged, err := l.loadFile(configFile, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, overlay := range configOverlayFiles(configFile) {
+		overlayData, errRead := os.ReadFile(overlay)
+		if errRead != nil {
+			if os.IsNotExist(errRead) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read config overlay %s: %w", overlay, errRead)
+		}
+		node, errLoad := l.loadFile(overlay, overlayData, nil)
+		if errLoad != nil {
+			return nil, errLoad
+		}
+		if node != nil && node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				l.overlayKeys[node.Content[i].Value] = overlay
+			}
+		}
+		merged = mergeConfigNodes(merged, node)
+	}
+	if merged != nil {
+		l.root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{merged}}
+	}
+	return l, nil
+}
+
+// layered reports whether anything besides the config file itself was read.
+func (l *configLayers) layered() bool {
+	return len(l.files) > 1
+}
+
+func (l *configLayers) loadFile(path string, data []byte, stack []string) (*yaml.Node, error) {
+	for _, seen := range stack {
+		if seen == path {
+			return nil, fmt.Errorf("config include cycle: %s", strings.Join(append(stack, path), " -> "))
+		}
+	}
+	var doc yaml.Node
+	if errParse := yaml.Unmarshal(data, &doc); errParse != nil {
+		if len(stack) == 0 && len(l.files) == 0 {
+			return nil, fmt.Errorf("failed to parse config file: %w", errParse)
+		}
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, errParse)
+	}
+	l.files = append(l.files, path)
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	node := doc.Content[0]
+	l.recordOrigin(node, path)
+
+	includes, errIncludes := takeConfigIncludes(node, path)
+	if errIncludes != nil {
+		return nil, errIncludes
+	}
+	var merged *yaml.Node
+	for _, include := range includes {
+		includeData, errRead := os.ReadFile(include)
+		if errRead != nil {
+			return nil, fmt.Errorf("failed to read included config %s: %w", include, errRead)
+		}
+		includeNode, errLoad := l.loadFile(include, includeData, append(stack, path))
+		if errLoad != nil {
+			return nil, errLoad
+		}
+		merged = mergeConfigNodes(merged, includeNode)
+	}
+	return mergeConfigNodes(merged, node), nil
+}
+
+func (l *configLayers) recordOrigin(node *yaml.Node, path string) {
+	if node == nil {
+		return
+	}
+	l.origins[node] = path
+	for _, child := range node.Content {
+		l.recordOrigin(child, path)
+	}
+}
+
+// ownsSecretKey reports whether remote-management.secret-key is set in
+// configFile itself; only then may its hash be written back there.
+func (l *configLayers) ownsSecretKey(configFile string) bool {
+	if len(l.root.Content) == 0 {
+		return false
+	}
+	section := l.root.Content[0]
+	idx := findMapKeyIndex(section, "remote-management")
+	if idx < 0 {
+		return false
+	}
+	section = section.Content[idx+1]
+	if idx = findMapKeyIndex(section, "secret-key"); idx < 0 {
+		return false
+	}
+	return l.origins[section.Content[idx+1]] == configFile
+}
+
+// validationError is newValidationError with each issue attributed to the
+// layer it came from.
+func (l *configLayers) validationError(file string, issues []ValidationIssue) error {
+	for i := range issues {
+		if origin, ok := l.origins[issues[i].node]; ok {
+			issues[i].File = origin
+		}
+	}
+	return newValidationError(file, issues)
+}
+
+// takeConfigIncludes removes the include directive from a document root and
+// returns the files it names, relative paths taken from path's directory.
+func takeConfigIncludes(root *yaml.Node, path string) ([]string, error) {
+	idx := findMapKeyIndex(root, configIncludeKey)
+	if idx < 0 {
+		return nil, nil
+	}
+	value := root.Content[idx+1]
+	removeMapKey(root, configIncludeKey)
+
+	var patterns []string
+	switch value.Kind {
+	case yaml.ScalarNode:
+		if value.Tag != "!!null" {
+			patterns = append(patterns, value.Value)
+		}
+	case yaml.SequenceNode:
+		for _, item := range value.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s:%d:%d: include: entries must be paths", path, item.Line, item.Column)
+			}
+			patterns = append(patterns, item.Value)
+		}
+	default:
+		return nil, fmt.Errorf("%s:%d:%d: include: expected a path or a list of paths", path, value.Line, value.Column)
+	}
+
+	dir := filepath.Dir(path)
+	var files []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			files = append(files, pattern)
+			continue
+		}
+		matches, errGlob := filepath.Glob(pattern)
+		if errGlob != nil {
+			return nil, fmt.Errorf("%s:%d:%d: include: %w", path, value.Line, value.Column, errGlob)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// configOverlayFiles returns the candidate overlays for configFile in
+// precedence order; missing files are skipped by the caller.
+func configOverlayFiles(configFile string) []string {
+	dir, name := filepath.Split(configFile)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	var files []string
+	if env := strings.TrimSpace(os.Getenv(configEnvironmentVar)); env != "" && env != configLocalOverlay {
+		files = append(files, filepath.Join(dir, stem+"."+env+ext))
+	}
+	return append(files, filepath.Join(dir, stem+"."+configLocalOverlay+ext))
+}
+
+// mergeConfigNodes lays src over dst. Mappings are merged recursively in
+// place; any other src value replaces dst.
+func mergeConfigNodes(dst, src *yaml.Node) *yaml.Node {
+	if dst == nil {
+		return src
+	}
+	if src == nil {
+		return dst
+	}
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return src
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if idx := findMapKeyIndex(dst, key.Value); idx >= 0 {
+			dst.Content[idx+1] = mergeConfigNodes(dst.Content[idx+1], value)
+			continue
+		}
+		dst.Content = append(dst.Content, key, value)
+	}
+	return dst
+}
+
+// ConfigSourceFiles lists every existing file that contributes to
+// configFile: the file itself, its includes and its overlays.
+func ConfigSourceFiles(configFile string) []string {
+	data, errRead := os.ReadFile(configFile)
+	if errRead != nil {
+		return []string{configFile}
+	}
+	layers, errLayers := loadConfigLayers(configFile, data)
+	if errLayers != nil {
+		return []string{configFile}
+	}
+	return layers.files
+}
+
+// ValidateConfigFile validates configFile after applying its includes,
+// overlays and references, which is what LoadConfig would see.
+func ValidateConfigFile(configFile string) error {
+	data, errRead := os.ReadFile(configFile)
+	if errRead != nil {
+		return fmt.Errorf("failed to read config file: %w", errRead)
+	}
+	layers, errLayers := loadConfigLayers(configFile, data)
+	if errLayers != nil {
+		return errLayers
+	}
+	issues, _ := resolveConfigReferences(&layers.root)
+	return layers.validationError(configFile, append(issues, validateConfigNode(&layers.root)...))
+}
+
+// keepLayeredValues stops a save from copying values owned by includes or
+// overlays into configFile. Top-level keys whose value matches what the
+// layers already produce are reset to the file's own value (or dropped).
+func keepLayeredValues(configFile string, data []byte, original, generated *yaml.Node) error {
+	layers, errLayers := loadConfigLayers(configFile, data)
+	if errLayers != nil || !layers.layered() {
+		return nil
+	}
+	baseline, errBaseline := loadConfigOptional(configFile, false, false)
+	if errBaseline != nil {
+		return fmt.Errorf("cannot save layered config: %w", errBaseline)
+	}
+	rendered, errMarshal := yaml.Marshal(baseline)
+	if errMarshal != nil {
+		return errMarshal
+	}
+	var baselineDoc yaml.Node
+	if errParse := yaml.Unmarshal(rendered, &baselineDoc); errParse != nil {
+		return errParse
+	}
+	if len(baselineDoc.Content) == 0 {
+		return nil
+	}
+	baselineRoot := baselineDoc.Content[0]
+
+	kept := generated.Content[:0]
+	for i := 0; i+1 < len(generated.Content); i += 2 {
+		key, value := generated.Content[i], generated.Content[i+1]
+		if idx := findMapKeyIndex(baselineRoot, key.Value); idx >= 0 && yamlNodesEqual(value, baselineRoot.Content[idx+1]) {
+			ownIdx := findMapKeyIndex(original, key.Value)
+			if ownIdx < 0 {
+				continue
+			}
+			value = deepCopyNode(original.Content[ownIdx+1])
+		} else if overlay, ok := layers.overlayKeys[key.Value]; ok {
+			log.Warnf("config: %s saved to %s is overridden by %s", key.Value, configFile, overlay)
+		}
+		kept = append(kept, key, value)
+	}
+	generated.Content = kept
+	return nil
+}
+
+func yamlNodesEqual(a, b *yaml.Node) bool {
+	encodedA, errA := yaml.Marshal(a)
+	encodedB, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && string(encodedA) == string(encodedB)
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeLayerFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestLoadConfig_MergesIncludesAndOverlays(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(configEnvironmentVar, "staging")
+	writeLayerFile(t, filepath.Join(dir, "providers", "claude.yaml"), "claude-api-key:\n  - api-key: sk-claude\nrouting:\n  strategy: fill-first\n")
+	writeLayerFile(t, filepath.Join(dir, "providers", "gemini.yaml"), "gemini-api-key:\n  - api-key: g-key\n")
+	writeLayerFile(t, filepath.Join(dir, "routing.yaml"), "request-retry: 2\nrouting:\n  strategy: round-robin\n")
+	configPath := filepath.Join(dir, "config.yaml")
+	writeLayerFile(t, configPath, "include:\n  - providers/*.yaml\n  - routing.yaml\nport: 8317\ndebug: false\nrequest-retry: 5\n")
+	writeLayerFile(t, filepath.Join(dir, "config.staging.yaml"), "port: 9000\n")
+	writeLayerFile(t, filepath.Join(dir, "config.local.yaml"), "debug: true\n")
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != 9000 || !cfg.Debug {
+		t.Fatalf("overlays not applied: port=%d debug=%v", cfg.Port, cfg.Debug)
+	}
+	if cfg.RequestRetry != 5 {
+		t.Fatalf("config file should win over includes, request-retry = %d", cfg.RequestRetry)
+	}
+	if cfg.Routing.Strategy != "round-robin" {
+		t.Fatalf("later include should win, routing.strategy = %q", cfg.Routing.Strategy)
+	}
+	if len(cfg.ClaudeKey) != 1 || len(cfg.GeminiKey) != 1 {
+		t.Fatalf("provider includes not merged: claude=%d gemini=%d", len(cfg.ClaudeKey), len(cfg.GeminiKey))
+	}
+
+	files := ConfigSourceFiles(configPath)
+	if len(files) != 6 || files[0] != configPath {
+		t.Fatalf("unexpected source files: %v", files)
+	}
+}
+
+func TestLoadConfig_ReportsIssuesInIncludedFile(t *testing.T) {
+	dir := t.TempDir()
+	included := filepath.Join(dir, "routing.yaml")
+	writeLayerFile(t, included, "routing:\n  strategy: sideways\n")
+	configPath := filepath.Join(dir, "config.yaml")
+	writeLayerFile(t, configPath, "include: routing.yaml\nport: 8317\n")
+
+	_, err := LoadConfig(configPath)
+	issues := ValidationIssues(err)
+	if len(issues) != 1 || issues[0].File != included || issues[0].Line != 2 {
+		t.Fatalf("expected one issue in %s line 2, got %v", included, err)
+	}
+	if !strings.Contains(err.Error(), included+":2:13: routing.strategy") {
+		t.Fatalf("unexpected error text: %v", err)
+	}
+}
+
+func TestLoadConfig_RejectsIncludeCycles(t *testing.T) {
+	dir := t.TempDir()
+	writeLayerFile(t, filepath.Join(dir, "a.yaml"), "include: b.yaml\n")
+	writeLayerFile(t, filepath.Join(dir, "b.yaml"), "include: a.yaml\n")
+	configPath := filepath.Join(dir, "config.yaml")
+	writeLayerFile(t, configPath, "include: a.yaml\n")
+
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "config include cycle") {
+		t.Fatalf("expected include cycle error, got %v", err)
+	}
+}
+
+func TestSaveConfigPreserveComments_LeavesLayeredValuesInPlace(t *testing.T) {
+	dir := t.TempDir()
+	writeLayerFile(t, filepath.Join(dir, "providers.yaml"), "claude-api-key:\n  - api-key: sk-claude\n")
+	configPath := filepath.Join(dir, "config.yaml")
+	writeLayerFile(t, configPath, "include: providers.yaml\n# keep me\ndebug: false\n")
+	writeLayerFile(t, filepath.Join(dir, "config.local.yaml"), "port: 9000\n")
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	cfg.Debug = true
+	if err = SaveConfigPreserveComments(configPath, cfg); err != nil {
+		t.Fatalf("SaveConfigPreserveComments: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	saved := string(data)
+	for _, want := range []string{"include: providers.yaml", "# keep me", "debug: true"} {
+		if !strings.Contains(saved, want) {
+			t.Fatalf("saved config missing %q:\n%s", want, saved)
+		}
+	}
+	if strings.Contains(saved, "sk-claude") || strings.Contains(saved, "9000") {
+		t.Fatalf("layered values leaked into the config file:\n%s", saved)
+	}
+
+	reloaded, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !reloaded.Debug || reloaded.Port != 9000 || len(reloaded.ClaudeKey) != 1 {
+		t.Fatalf("unexpected reloaded config: debug=%v port=%d claude=%d", reloaded.Debug, reloaded.Port, len(reloaded.ClaudeKey))
+	}
+}
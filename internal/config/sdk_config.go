@@ -18,29 +18,793 @@ type SDKConfig struct {
 	// credentials as well.
 	ForceModelPrefix bool `yaml:"force-model-prefix" json:"force-model-prefix"`
 
+	// ModelNamespaces publish the models of credentials whose labels match a
+	// selector under a shared, possibly nested, prefix such as "acme/research".
+	ModelNamespaces []ModelNamespace `yaml:"model-namespaces,omitempty" json:"model-namespaces,omitempty"`
+
 	// RequestLog enables or disables detailed request logging functionality.
 	RequestLog bool `yaml:"request-log" json:"request-log"`
 
 	// APIKeys is a list of keys for authenticating clients to this proxy server.
 	APIKeys []string `yaml:"api-keys" json:"api-keys"`
 
+	// APIKeyModels limits the models individual client API keys may list and
+	// use, for inline api-keys as well as keys of access providers.
+	APIKeyModels []APIKeyModels `yaml:"api-key-models,omitempty" json:"api-key-models,omitempty"`
+
+	// APIKeyProfiles assign client compatibility profiles to API keys, such
+	// as "claude-code" for keys used by Claude Code or "codex-cli" for Codex
+	// CLI.
+	APIKeyProfiles []APIKeyProfile `yaml:"api-key-profiles,omitempty" json:"api-key-profiles,omitempty"`
+
+	// APIKeyLimits cap the output tokens or estimated cost of any single
+	// request made with an API key, so a runaway agent loop cannot run up
+	// an unbounded bill in one call.
+	APIKeyLimits []APIKeyLimit `yaml:"api-key-limits,omitempty" json:"api-key-limits,omitempty"`
+
+	// ModelPrices are the token prices request costs are estimated with.
+	ModelPrices []ModelPrice `yaml:"model-prices,omitempty" json:"model-prices,omitempty"`
+
+	// APIKeyReasoning decides per API key whether the upstream's reasoning
+	// content reaches the client as sent, summarized or not at all.
+	APIKeyReasoning []APIKeyReasoning `yaml:"api-key-reasoning,omitempty" json:"api-key-reasoning,omitempty"`
+
+	// LoopDetection breaks agent loops of API keys: conversations that keep
+	// repeating the same tool call or nearly the same prompt.
+	LoopDetection []LoopDetection `yaml:"loop-detection,omitempty" json:"loop-detection,omitempty"`
+
 	// PassthroughHeaders controls whether upstream response headers are forwarded to downstream clients.
 	// Default is false (disabled).
 	PassthroughHeaders bool `yaml:"passthrough-headers" json:"passthrough-headers"`
 
+	// RateLimitHeaders reports the quota the serving credential has left,
+	// as last reported by its upstream, in X-RateLimit-* response headers.
+	RateLimitHeaders bool `yaml:"rate-limit-headers,omitempty" json:"rate-limit-headers,omitempty"`
+
 	// Streaming configures server-side streaming behavior (keep-alives and safe bootstrap retries).
 	Streaming StreamingConfig `yaml:"streaming" json:"streaming"`
 
+	// Disconnect controls upstream work when a client disconnects before its
+	// response is complete.
+	Disconnect DisconnectConfig `yaml:"disconnect,omitempty" json:"disconnect,omitempty"`
+
+	// RequestDedup collapses identical requests sent with the same API key
+	// into one upstream call.
+	RequestDedup RequestDedupConfig `yaml:"request-dedup,omitempty" json:"request-dedup,omitempty"`
+
+	// Artifacts persists large completed responses to disk so clients that
+	// timed out can fetch them later.
+	Artifacts ArtifactsConfig `yaml:"artifacts,omitempty" json:"artifacts,omitempty"`
+
+	// Jobs configures background generations submitted through /v1/jobs,
+	// whose results are kept in the artifact store.
+	Jobs JobsConfig `yaml:"jobs,omitempty" json:"jobs,omitempty"`
+
+	// Compression negotiates compressed response bodies with upstreams and
+	// clients to save bandwidth on slow links.
+	Compression CompressionConfig `yaml:"compression,omitempty" json:"compression,omitempty"`
+
+	// ResponseSpill moves large responses that are kept while the request
+	// runs, for deduplication or disconnect recovery, to temporary files.
+	ResponseSpill ResponseSpillConfig `yaml:"response-spill,omitempty" json:"response-spill,omitempty"`
+
+	// ResourceLimits fits the process to its container and sheds load under
+	// memory pressure instead of running out of memory.
+	ResourceLimits ResourceLimitsConfig `yaml:"resource-limits,omitempty" json:"resource-limits,omitempty"`
+
+	// LogRetention compresses and prunes request logs and rotates the
+	// management audit log so long-running deployments do not fill disks.
+	LogRetention LogRetentionConfig `yaml:"log-retention,omitempty" json:"log-retention,omitempty"`
+
+	// Maintenance answers inference requests with 503 while the management
+	// API stays up, e.g. during credential migrations.
+	Maintenance MaintenanceConfig `yaml:"maintenance,omitempty" json:"maintenance,omitempty"`
+
+	// ShadowTraffic mirrors a share of requests to another model in the
+	// background to compare providers on real traffic.
+	ShadowTraffic ShadowTrafficConfig `yaml:"shadow-traffic,omitempty" json:"shadow-traffic,omitempty"`
+
+	// Experiments split the traffic for a model between arms served by other
+	// models or variants, tagging usage with the arm that served it.
+	Experiments []Experiment `yaml:"experiments,omitempty" json:"experiments,omitempty"`
+
+	// SmartRouters are pseudo-models that pick a backend model per request
+	// from rules over the prompt, such as its length or whether it has images.
+	SmartRouters []SmartRouter `yaml:"smart-routers,omitempty" json:"smart-routers,omitempty"`
+
+	// PromptTemplates are named prompts a request expands by setting
+	// "template", so teams can change shared prompts in one place.
+	PromptTemplates []PromptTemplate `yaml:"prompt-templates,omitempty" json:"prompt-templates,omitempty"`
+
+	// RateLimitQueue holds requests while every credential is rate-limited
+	// instead of failing them, dispatching once one cools down.
+	RateLimitQueue RateLimitQueueConfig `yaml:"rate-limit-queue,omitempty" json:"rate-limit-queue,omitempty"`
+
+	// ToolCallJSONRepair fixes malformed JSON in tool-call arguments, such as
+	// trailing commas or truncated objects, before responses reach clients.
+	ToolCallJSONRepair bool `yaml:"tool-call-json-repair,omitempty" json:"tool-call-json-repair,omitempty"`
+
+	// QualityGuard retries non-streaming responses that came back empty,
+	// stopped immediately, or carry tool calls with malformed JSON arguments.
+	QualityGuard QualityGuardConfig `yaml:"quality-guard,omitempty" json:"quality-guard,omitempty"`
+
+	// ImagePreprocess downscales and re-encodes request images that are too
+	// large or in formats providers reject, before translation.
+	ImagePreprocess ImagePreprocessConfig `yaml:"image-preprocess,omitempty" json:"image-preprocess,omitempty"`
+
+	// EmbeddingCache serves repeated Gemini embedding inputs from a persistent
+	// cache instead of the upstream.
+	EmbeddingCache EmbeddingCacheConfig `yaml:"embedding-cache,omitempty" json:"embedding-cache,omitempty"`
+
 	// NonStreamKeepAliveInterval controls how often blank lines are emitted for non-streaming responses.
 	// <= 0 disables keep-alives. Value is in seconds.
 	NonStreamKeepAliveInterval int `yaml:"nonstream-keepalive-interval,omitempty" json:"nonstream-keepalive-interval,omitempty"`
 
 	// UpstreamTimeouts configures timeouts for upstream HTTP requests to provider APIs.
 	UpstreamTimeouts UpstreamTimeouts `yaml:"upstream-timeouts" json:"upstream-timeouts"`
+
+	// SharedState configures the store that lets several proxy replicas share
+	// rate limits, cooldowns, sticky sessions and OAuth sessions.
+	SharedState SharedStateConfig `yaml:"shared-state,omitempty" json:"shared-state,omitempty"`
+
+	// Access lists request authentication providers consulted after api-keys.
+	Access AccessConfig `yaml:"access,omitempty" json:"access,omitempty"`
+}
+
+// DefaultEmbeddingCacheMaxEntries bounds the embedding cache when
+// max-entries is unset.
+const DefaultEmbeddingCacheMaxEntries = 50000
+
+// EmbeddingCacheConfig configures the embedding vector cache.
+type EmbeddingCacheConfig struct {
+	// Dir is the directory the cache is persisted in. Empty disables the cache.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// MaxEntries caps the cached vectors; the oldest are evicted first.
+	// <= 0 uses the default of 50000.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// Similarity, between 0 and 1, also serves an input from the cached
+	// vector of a near-duplicate text whose character trigram cosine
+	// similarity reaches it. 0 only serves exact matches.
+	Similarity float64 `yaml:"similarity,omitempty" json:"similarity,omitempty"`
+
+	// Shared serves one client API key's cached vectors to every key. Off by
+	// default, since hits would tell a client what other keys embedded; when
+	// on, the X-Embedding-Cache header is omitted for the same reason.
+	Shared bool `yaml:"shared,omitempty" json:"shared,omitempty"`
+}
+
+// ImagePreprocessConfig configures the request image pre-processor.
+type ImagePreprocessConfig struct {
+	// Enable turns the pre-processor on. Default is false.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// MaxDimension is the longest side, in pixels, images are downscaled to.
+	// <= 0 keeps their dimensions.
+	MaxDimension int `yaml:"max-dimension,omitempty" json:"max-dimension,omitempty"`
+
+	// MaxBytes is the largest encoded image size; larger images are
+	// re-encoded, and downscaled further if needed. <= 0 does not limit it.
+	MaxBytes int `yaml:"max-bytes,omitempty" json:"max-bytes,omitempty"`
+
+	// JPEGQuality is the quality images are re-encoded at as JPEG. Default
+	// is 85.
+	JPEGQuality int `yaml:"jpeg-quality,omitempty" json:"jpeg-quality,omitempty"`
+
+	// ConvertCommand converts images in formats that cannot be decoded
+	// natively, such as HEIC: it receives the image on stdin and must write
+	// a JPEG to stdout, e.g. ["magick", "-", "jpeg:-"]. Empty leaves those
+	// images unchanged.
+	ConvertCommand []string `yaml:"convert-command,omitempty" json:"convert-command,omitempty"`
+}
+
+// Disconnect policies.
+const (
+	DisconnectPolicyCancel   = "cancel"
+	DisconnectPolicyComplete = "complete"
+)
+
+// DisconnectConfig selects what happens when a client goes away mid-request.
+type DisconnectConfig struct {
+	// Policy is "cancel" (default) to stop the upstream request immediately, or
+	// "complete" to let it finish and keep the response for retrieval via
+	// GET /v1/requests/{request_id}.
+	Policy string `yaml:"policy,omitempty" json:"policy,omitempty"`
+
+	// ResultTTL is how long, in seconds, completed responses of disconnected
+	// clients are kept. Default is 3600.
+	ResultTTL int `yaml:"result-ttl,omitempty" json:"result-ttl,omitempty"`
+}
+
+// RequestDedupConfig configures the request deduplication window.
+type RequestDedupConfig struct {
+	// WindowSeconds is how long after an API request arrives an identical one
+	// (same API key, path and body) is served from it instead of going
+	// upstream: live while the original is still running, from the recorded
+	// copy once it has finished. Error responses are never reused. <= 0
+	// disables deduplication. Default is 0.
+	WindowSeconds int `yaml:"window-seconds,omitempty" json:"window-seconds,omitempty"`
+}
+
+// Default artifact store limits.
+const (
+	DefaultArtifactMinBytes    = 64 * 1024
+	DefaultArtifactMaxAgeHours = 24
+)
+
+// ArtifactsConfig configures the local store for long generations.
+type ArtifactsConfig struct {
+	// Dir is the directory responses are written to. Empty disables the store.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// MinBytes is the response size from which a successful response is kept.
+	// <= 0 uses the default of 65536.
+	MinBytes int `yaml:"min-bytes,omitempty" json:"min-bytes,omitempty"`
+
+	// MaxAgeHours is how long artifacts are kept. <= 0 uses the default of 24.
+	MaxAgeHours int `yaml:"max-age-hours,omitempty" json:"max-age-hours,omitempty"`
+}
+
+// DefaultJobsMaxConcurrent bounds the background jobs running at once.
+const DefaultJobsMaxConcurrent = 4
+
+// JobsConfig configures the background job API.
+type JobsConfig struct {
+	// MaxConcurrent caps the jobs generating at once; further jobs wait in
+	// the queue. <= 0 uses the default of 4.
+	MaxConcurrent int `yaml:"max-concurrent,omitempty" json:"max-concurrent,omitempty"`
+
+	// WebhookHosts, when set, lists the only hosts job webhooks may be sent
+	// to; they may resolve to private addresses. Empty allows any host that
+	// resolves to public addresses only.
+	WebhookHosts []string `yaml:"webhook-hosts,omitempty" json:"webhook-hosts,omitempty"`
+}
+
+// DefaultCompressionMinBytes is the smallest response compressed for clients.
+const DefaultCompressionMinBytes = 1024
+
+// CompressionConfig configures response compression.
+type CompressionConfig struct {
+	// Upstream asks upstreams for gzip, br or zstd encoded non-streaming
+	// responses and decodes them before translation.
+	Upstream bool `yaml:"upstream,omitempty" json:"upstream,omitempty"`
+
+	// Client compresses non-streaming responses for clients whose
+	// Accept-Encoding allows gzip, br or zstd.
+	Client bool `yaml:"client,omitempty" json:"client,omitempty"`
+
+	// MinBytes is the smallest response compressed for clients. <= 0 uses
+	// the default of 1024.
+	MinBytes int `yaml:"min-bytes,omitempty" json:"min-bytes,omitempty"`
+}
+
+// ResponseSpillConfig configures the temporary files of large responses.
+type ResponseSpillConfig struct {
+	// ThresholdBytes is the size past which a kept response moves to a file.
+	// <= 0 keeps responses in memory.
+	ThresholdBytes int `yaml:"threshold-bytes,omitempty" json:"threshold-bytes,omitempty"`
+
+	// Dir holds the files. Empty uses the system temporary directory.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+}
+
+// Defaults for resource-limits.
+const (
+	DefaultMemoryLimitRatio         = 0.9
+	DefaultMemoryPressureRetryAfter = 5
+)
+
+// ResourceLimitsConfig configures CPU and memory limits.
+type ResourceLimitsConfig struct {
+	// MaxProcs sets GOMAXPROCS. <= 0 keeps the Go runtime default, which
+	// follows the container CPU limit.
+	MaxProcs int `yaml:"max-procs,omitempty" json:"max-procs,omitempty"`
+
+	// MemoryLimitRatio sets the Go memory limit to this share of the cgroup
+	// memory limit, unless GOMEMLIMIT is set. 0 uses the default of 0.9; a
+	// negative value leaves the memory limit alone.
+	MemoryLimitRatio float64 `yaml:"memory-limit-ratio,omitempty" json:"memory-limit-ratio,omitempty"`
+
+	// PressureRatio is the share of the memory limit in use past which new
+	// requests are rejected with 503 and stream buffers shrink. <= 0
+	// disables load shedding.
+	PressureRatio float64 `yaml:"pressure-ratio,omitempty" json:"pressure-ratio,omitempty"`
+
+	// RetryAfterSeconds is sent in Retry-After with rejected requests. <= 0
+	// uses the default of 5.
+	RetryAfterSeconds int `yaml:"retry-after-seconds,omitempty" json:"retry-after-seconds,omitempty"`
+}
+
+// LogRetentionConfig holds the retention policies of each kind of log.
+type LogRetentionConfig struct {
+	// RequestLogs applies to the per-request log files, including forced
+	// error logs.
+	RequestLogs LogRetentionPolicy `yaml:"request-logs,omitempty" json:"request-logs,omitempty"`
+
+	// AuditLog applies to the management audit log, which records config and
+	// auth file changes.
+	AuditLog LogRetentionPolicy `yaml:"audit-log,omitempty" json:"audit-log,omitempty"`
+}
+
+// LogRetentionPolicy bounds the disk use of one kind of log. Zero values
+// disable the corresponding limit.
+type LogRetentionPolicy struct {
+	// MaxSizeMB rotates the audit log once it grows past this size; for
+	// request logs it caps their total size, removing the oldest first.
+	MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+
+	// RotateHours rotates the audit log after this many hours. Request logs
+	// are one file per request and are not rotated.
+	RotateHours int `yaml:"rotate-hours,omitempty" json:"rotate-hours,omitempty"`
+
+	// MaxAgeDays removes request logs, or rotated audit logs, older than
+	// this many days.
+	MaxAgeDays int `yaml:"max-age-days,omitempty" json:"max-age-days,omitempty"`
+
+	// MaxFiles keeps at most this many request logs, or rotated audit logs.
+	MaxFiles int `yaml:"max-files,omitempty" json:"max-files,omitempty"`
+
+	// Compress gzips request logs once they are finished, and rotated audit
+	// logs.
+	Compress bool `yaml:"compress,omitempty" json:"compress,omitempty"`
+}
+
+// DefaultMaintenanceMessage is the error message inference requests get in
+// maintenance mode when none is configured.
+const DefaultMaintenanceMessage = "service is under maintenance, retry later"
+
+// MaintenanceConfig configures maintenance mode.
+type MaintenanceConfig struct {
+	// Enable rejects inference requests with 503.
+	Enable bool `yaml:"enable,omitempty" json:"enable,omitempty"`
+
+	// Message replaces DefaultMaintenanceMessage in the error body.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+
+	// RetryAfterSeconds, when positive, is sent as Retry-After.
+	RetryAfterSeconds int `yaml:"retry-after-seconds,omitempty" json:"retry-after-seconds,omitempty"`
+}
+
+// DefaultShadowTrafficMaxConcurrent bounds the mirrored requests in flight.
+const DefaultShadowTrafficMaxConcurrent = 8
+
+// ShadowTrafficConfig configures request mirroring.
+type ShadowTrafficConfig struct {
+	// Rules lists which requests are mirrored and where to. The first rule
+	// matching the requested model applies.
+	Rules []ShadowTrafficRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// StoreDir, when set, receives one JSON file per mirrored request holding
+	// the request and both responses. Empty discards mirrored responses.
+	StoreDir string `yaml:"store-dir,omitempty" json:"store-dir,omitempty"`
+
+	// MaxConcurrent caps mirrored requests in flight; requests sampled while
+	// the cap is reached are not mirrored. <= 0 uses the default of 8.
+	MaxConcurrent int `yaml:"max-concurrent,omitempty" json:"max-concurrent,omitempty"`
+}
+
+// ShadowTrafficRule mirrors a percentage of the requests for Model to
+// TargetModel. Clients always receive the response of the requested model.
+type ShadowTrafficRule struct {
+	// Model matches the requested model case-insensitively; '*' matches any
+	// run of characters.
+	Model string `yaml:"model" json:"model"`
+
+	// TargetModel is the model, optionally with a prefix, that receives the
+	// mirrored copy.
+	TargetModel string `yaml:"target-model" json:"target-model"`
+
+	// Percent is the share of matching requests mirrored, from 0 to 100.
+	Percent float64 `yaml:"percent" json:"percent"`
+}
+
+// Experiment assigns each client API key to one of its arms, so a key keeps
+// seeing the same arm for the lifetime of the experiment.
+type Experiment struct {
+	// Name identifies the experiment in usage records and reports.
+	Name string `yaml:"name" json:"name"`
+
+	// Model matches the requested model case-insensitively; '*' matches any
+	// run of characters.
+	Model string `yaml:"model" json:"model"`
+
+	// APIKeys limits the experiment to these client keys. Empty enrolls every
+	// key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Arms lists the models requests are routed to, at least two.
+	Arms []ExperimentArm `yaml:"arms" json:"arms"`
+}
+
+// ExperimentArm is one side of an experiment.
+type ExperimentArm struct {
+	// Name labels the arm; it defaults to Model.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Model serves the arm's requests. A thinking suffix such as
+	// "gemini-2.5-pro(high)" selects a variant of the same model.
+	Model string `yaml:"model" json:"model"`
+
+	// Weight is the arm's relative share of API keys. <= 0 counts as 1.
+	Weight int `yaml:"weight,omitempty" json:"weight,omitempty"`
+}
+
+// SmartRouter is a model name clients can request that resolves to the model
+// of the first matching rule, or to DefaultModel when none matches.
+type SmartRouter struct {
+	// Name is the pseudo-model clients request, matched case-insensitively.
+	Name string `yaml:"name" json:"name"`
+
+	// DefaultModel serves requests no rule matches.
+	DefaultModel string `yaml:"default-model" json:"default-model"`
+
+	// Rules are evaluated in order.
+	Rules []SmartRouterRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// Classifier, when set, picks the model for requests no rule matches.
+	Classifier *SmartRouterClassifier `yaml:"classifier,omitempty" json:"classifier,omitempty"`
+}
+
+// Defaults of a smart router classifier.
+const (
+	DefaultSmartRouterLongContextChars = 32000
+	DefaultSmartRouterCacheTTLSeconds  = 600
+)
+
+// SmartRouterClassifier sorts a prompt into one of Classes and routes it to
+// that class's model. Prompts it cannot classify go to DefaultModel.
+type SmartRouterClassifier struct {
+	// Model is a cheap model asked to name the class. When empty a local
+	// heuristic is used instead, which knows the classes "code",
+	// "long-context" and "chat".
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	Classes []SmartRouterClass `yaml:"classes" json:"classes"`
+
+	// LongContextChars is the prompt length from which the heuristic answers
+	// "long-context". <= 0 uses DefaultSmartRouterLongContextChars.
+	LongContextChars int `yaml:"long-context-chars,omitempty" json:"long-context-chars,omitempty"`
+
+	// CacheTTLSeconds is how long a classifier model's answer is reused for
+	// the same prompt. 0 uses DefaultSmartRouterCacheTTLSeconds; negative
+	// disables the cache.
+	CacheTTLSeconds int `yaml:"cache-ttl-seconds,omitempty" json:"cache-ttl-seconds,omitempty"`
+}
+
+// SmartRouterClass is one answer of a smart router classifier.
+type SmartRouterClass struct {
+	// Name is the class label, matched case-insensitively.
+	Name string `yaml:"name" json:"name"`
+
+	// Description tells the classifier model which prompts belong here.
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+
+	Model string `yaml:"model" json:"model"`
+}
+
+// SmartRouterRule routes a request to Model when every condition it sets
+// holds. Prompt length is the number of characters of text in the messages,
+// system prompt included and tool definitions excluded.
+type SmartRouterRule struct {
+	Model string `yaml:"model" json:"model"`
+
+	MinPromptChars int `yaml:"min-prompt-chars,omitempty" json:"min-prompt-chars,omitempty"`
+	MaxPromptChars int `yaml:"max-prompt-chars,omitempty" json:"max-prompt-chars,omitempty"`
+
+	// Images and Tools require the request to have (true) or lack (false)
+	// image inputs and tool definitions.
+	Images *bool `yaml:"images,omitempty" json:"images,omitempty"`
+	Tools  *bool `yaml:"tools,omitempty" json:"tools,omitempty"`
+
+	// Thinking lists the requested thinking levels that match, e.g. "high";
+	// "none" matches requests that ask for no thinking or leave it unset.
+	Thinking []string `yaml:"thinking,omitempty" json:"thinking,omitempty"`
+
+	// Keywords match when any of them appears in the prompt text,
+	// case-insensitively.
+	Keywords []string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+}
+
+// PromptTemplate is a prompt kept on the server. A request naming it in its
+// "template" field gets System added to its system prompt and Messages put
+// before its own messages, with every {{variable}} replaced by the value in
+// the request's "template_variables" or, failing that, in Variables.
+type PromptTemplate struct {
+	// Name is what requests put in "template", matched case-insensitively.
+	Name string `yaml:"name" json:"name"`
+
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+
+	System string `yaml:"system,omitempty" json:"system,omitempty"`
+
+	Messages []PromptTemplateMessage `yaml:"messages,omitempty" json:"messages,omitempty"`
+
+	// Variables are the default values of the template's variables.
+	Variables map[string]string `yaml:"variables,omitempty" json:"variables,omitempty"`
+}
+
+// PromptTemplateMessage is one conversation turn of a prompt template.
+type PromptTemplateMessage struct {
+	// Role is "user" or "assistant".
+	Role    string `yaml:"role" json:"role"`
+	Content string `yaml:"content" json:"content"`
+}
+
+// APIKeyModels is the model allowlist and denylist of one client API key.
+// Patterns are matched case-insensitively against the requested model name,
+// with '*' matching any run of characters.
+type APIKeyModels struct {
+	// APIKey is the client key the lists apply to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// Provider optionally restricts the entry to keys authenticated by the
+	// named access provider; empty matches the key from any provider.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Allow lists the models the key may use. Empty allows every model not
+	// denied.
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+
+	// Deny lists models the key may not use, even when allowed.
+	Deny []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+}
+
+// Client compatibility profiles.
+const (
+	// ClientProfileClaudeCode adapts Claude Code requests for providers other
+	// than Anthropic.
+	ClientProfileClaudeCode = "claude-code"
+	// ClientProfileCodexCLI adapts Codex CLI's Responses sessions for
+	// providers without a native Responses API.
+	ClientProfileCodexCLI = "codex-cli"
+)
+
+// APIKeyProfile selects the compatibility profile of one client API key.
+type APIKeyProfile struct {
+	// APIKey is the client key the profile applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// Provider optionally restricts the entry to keys authenticated by the
+	// named access provider; empty matches the key from any provider.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Profile names the client profile: "claude-code" or "codex-cli".
+	Profile string `yaml:"profile" json:"profile"`
+}
+
+// APIKeyLimit caps single requests of one client API key. Requests are sent
+// upstream with their output limit lowered to fit, and streams that still
+// run past it are ended as if the model had hit its length limit.
+type APIKeyLimit struct {
+	// APIKey is the client key the limits apply to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// Provider optionally restricts the entry to keys authenticated by the
+	// named access provider; empty matches the key from any provider.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// MaxOutputTokens caps the tokens one request may generate.
+	MaxOutputTokens int `yaml:"max-output-tokens,omitempty" json:"max-output-tokens,omitempty"`
+
+	// MaxCostUSD caps the estimated cost of one request, priced with
+	// model-prices. Models without a price are not limited by cost.
+	MaxCostUSD float64 `yaml:"max-cost-usd,omitempty" json:"max-cost-usd,omitempty"`
+}
+
+// Reasoning exposure modes of APIKeyReasoning entries.
+const (
+	ReasoningVerbatim   = "verbatim"
+	ReasoningSummarized = "summarized"
+	ReasoningStripped   = "stripped"
+)
+
+// APIKeyReasoning sets how the reasoning (thinking) content of responses is
+// exposed to one client API key, for deployments that must not show chain of
+// thought. Usage, including reasoning token counts, is reported unchanged.
+type APIKeyReasoning struct {
+	// APIKey is the client key the mode applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// Provider optionally restricts the entry to keys authenticated by the
+	// named access provider; empty matches the key from any provider.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Mode is "verbatim" to forward reasoning as received, "summarized" to
+	// replace it with its opening sentences, or "stripped" to remove it.
+	// Any other value strips, so a misspelled mode never exposes reasoning.
+	Mode string `yaml:"mode" json:"mode"`
+}
+
+// Actions a LoopDetection entry takes on a detected loop.
+const (
+	LoopActionError  = "error"
+	LoopActionInject = "inject"
+)
+
+// Defaults of LoopDetection entries.
+const (
+	DefaultLoopThreshold     = 5
+	DefaultLoopWindowSeconds = 600
+)
+
+// LoopDetection configures agent loop detection for one client API key. A
+// conversation is the requests sharing an X-Session-Affinity header, or
+// without one, the same opening user message.
+type LoopDetection struct {
+	// APIKey is the client key whose conversations are watched.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// Provider optionally restricts the entry to keys authenticated by the
+	// named access provider; empty matches the key from any provider.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Threshold is how many times in a row the latest tool call or user
+	// prompt of a conversation may repeat before it counts as a loop.
+	// Defaults to 5.
+	Threshold int `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+
+	// WindowSeconds is how long the repeats may take. A repeat arriving
+	// later starts the count again. Defaults to 600.
+	WindowSeconds int `yaml:"window-seconds,omitempty" json:"window-seconds,omitempty"`
+
+	// Action is "error" (default) to reject looping requests with an
+	// agent_loop_detected error, or "inject" to forward them with a system
+	// message telling the model it is repeating itself.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// Message replaces the built-in system message of the inject action.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// ModelPrice is the price of a model's tokens in USD per million.
+type ModelPrice struct {
+	// Model is matched case-insensitively, with '*' matching any run of
+	// characters. The first matching entry applies.
+	Model string `yaml:"model" json:"model"`
+
+	InputPerMillion  float64 `yaml:"input-per-million" json:"input-per-million"`
+	OutputPerMillion float64 `yaml:"output-per-million" json:"output-per-million"`
+}
+
+// ModelNamespace exposes the models of a group of credentials under Prefix,
+// so "acme/research/gemini-2.5-pro" targets only the group's credentials.
+type ModelNamespace struct {
+	// Prefix is the namespace path; segments are separated by "/" and a
+	// namespace may sit inside another, e.g. "acme" and "acme/research".
+	Prefix string `yaml:"prefix" json:"prefix"`
+
+	// Selector lists the labels a credential must carry, all with the given
+	// values, to serve the namespace.
+	Selector map[string]string `yaml:"selector" json:"selector"`
+
+	// APIKeys limits which client API keys may list and use the namespace.
+	// Empty allows every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+}
+
+// DefaultRateLimitQueueKeepAliveSeconds is the keep-alive interval for
+// streaming requests waiting in the rate-limit queue.
+const DefaultRateLimitQueueKeepAliveSeconds = 15
+
+// RateLimitQueueConfig configures queue-and-wait handling of rate limits.
+type RateLimitQueueConfig struct {
+	// MaxWaitSeconds is how long a request may wait for a credential to cool
+	// down after every candidate returned 429 or was overloaded. <= 0 disables
+	// the queue, so such requests fail right away.
+	MaxWaitSeconds int `yaml:"max-wait-seconds,omitempty" json:"max-wait-seconds,omitempty"`
+
+	// KeepAliveSeconds is how often queued streaming requests receive an SSE
+	// comment so clients do not time out. <= 0 uses the default of 15.
+	KeepAliveSeconds int `yaml:"keepalive-seconds,omitempty" json:"keepalive-seconds,omitempty"`
+
+	// KeyWeights share the credentials that free up between the client API
+	// keys waiting for them. Keys without an entry weigh 1.
+	KeyWeights []RateLimitQueueKeyWeight `yaml:"key-weights,omitempty" json:"key-weights,omitempty"`
+}
+
+// RateLimitQueueKeyWeight sets the share of queued capacity one client API
+// key receives relative to the other waiting keys.
+type RateLimitQueueKeyWeight struct {
+	// APIKey is the client key the weight applies to.
+	APIKey string `yaml:"api-key" json:"api-key"`
+
+	// Provider optionally restricts the entry to keys authenticated by the
+	// named access provider; empty matches the key from any provider.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Weight is the key's share; a key of weight 2 is served twice as often
+	// as a key of weight 1 while both wait.
+	Weight float64 `yaml:"weight" json:"weight"`
+}
+
+// QualityGuardConfig limits the retries of pathological responses.
+type QualityGuardConfig struct {
+	// MaxRetries is how many times a request is retried after a pathological
+	// response. <= 0 disables the guard.
+	MaxRetries int `yaml:"max-retries,omitempty" json:"max-retries,omitempty"`
+
+	// SameCredentialRetries is how many of those retries stay on the
+	// credential that produced the response before moving to the next one.
+	SameCredentialRetries int `yaml:"same-credential-retries,omitempty" json:"same-credential-retries,omitempty"`
+}
+
+// AccessConfig groups request authentication providers.
+type AccessConfig struct {
+	// Providers lists configured authentication providers.
+	Providers []AccessProvider `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// AccessProvider describes a request authentication provider entry.
+type AccessProvider struct {
+	// Name is the instance identifier for the provider.
+	Name string `yaml:"name" json:"name"`
+
+	// Type selects the provider implementation registered via the SDK.
+	Type string `yaml:"type" json:"type"`
+
+	// SDK optionally names a third-party SDK module providing this provider.
+	SDK string `yaml:"sdk,omitempty" json:"sdk,omitempty"`
+
+	// APIKeys lists inline keys for providers that require them.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Config passes provider-specific options to the implementation.
+	Config map[string]any `yaml:"config,omitempty" json:"config,omitempty"`
+
+	// External configures a provider of type "external", which delegates key
+	// validation to a separate process over HTTP.
+	External *ExternalAccessProvider `yaml:"external,omitempty" json:"external,omitempty"`
+}
+
+// ExternalAccessProvider points an external access provider at the process
+// that validates credentials.
+type ExternalAccessProvider struct {
+	// URL receives a POST per request to authenticate.
+	URL string `yaml:"url" json:"url"`
+
+	// Command, when set, is launched by the proxy and restarted if it exits.
+	// The process must serve URL.
+	Command []string `yaml:"command,omitempty" json:"command,omitempty"`
+
+	// Env adds environment variables to Command.
+	Env map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+
+	// Timeout bounds each validation call in seconds. Default is 5.
+	Timeout int `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// CacheTTL caches allow and deny decisions per credential for this many
+	// seconds. <= 0 asks the process on every request.
+	CacheTTL int `yaml:"cache-ttl,omitempty" json:"cache-ttl,omitempty"`
+
+	// ForwardHeaders lists extra request headers sent to the process besides
+	// Authorization, X-Goog-Api-Key and X-Api-Key.
+	ForwardHeaders []string `yaml:"forward-headers,omitempty" json:"forward-headers,omitempty"`
+}
+
+// SharedStateConfig selects the coordination backend used across replicas.
+type SharedStateConfig struct {
+	// Backend is "memory" (default, single instance) or "redis".
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// RedisURL is the redis:// or rediss:// URL used by the redis backend.
+	RedisURL string `yaml:"redis-url,omitempty" json:"redis-url,omitempty"`
+
+	// KeyPrefix namespaces every key and channel. Default is "cliproxy:".
+	KeyPrefix string `yaml:"key-prefix,omitempty" json:"key-prefix,omitempty"`
+
+	// StickySessionTTL is how long, in seconds, a client session stays bound
+	// to the credential that served it. <= 0 disables sticky sessions.
+	StickySessionTTL int `yaml:"sticky-session-ttl,omitempty" json:"sticky-session-ttl,omitempty"`
+
+	// LeaderElection lets only one replica refresh OAuth tokens and run other
+	// singleton background work. Requires the redis backend.
+	LeaderElection bool `yaml:"leader-election,omitempty" json:"leader-election,omitempty"`
 }
 
 // UpstreamTimeouts holds upstream HTTP request timeout configuration.
 // These timeouts apply to all provider executors using newProxyAwareHTTPClient() or getKiroPooledHTTPClient().
+// Kiro connection pool sizing is configured separately via Config.KiroHTTPPool.
 type UpstreamTimeouts struct {
 	// ConnectTimeoutSeconds is the timeout for establishing TCP connection and TLS handshake.
 	// 0 means use Go default (no explicit timeout). Negative values are invalid.
@@ -67,8 +831,40 @@ type StreamingConfig struct {
 	// normalize Anthropic SSE content_block lifecycle ordering.
 	// nil means enabled by default.
 	AnthropicSSELifecycleEnable *bool `yaml:"anthropic-sse-lifecycle-enable,omitempty" json:"anthropic-sse-lifecycle-enable,omitempty"`
+
+	// ResumeWindowSeconds enables Last-Event-ID resumption: SSE events carry IDs
+	// and a stream keeps generating this long after its client disconnects,
+	// waiting for a reconnect. <= 0 disables resumption. Default is 0.
+	ResumeWindowSeconds int `yaml:"resume-window-seconds,omitempty" json:"resume-window-seconds,omitempty"`
+
+	// ResumeBufferEvents is how many recent events each stream keeps for replay.
+	// <= 0 uses the default of 256.
+	ResumeBufferEvents int `yaml:"resume-buffer-events,omitempty" json:"resume-buffer-events,omitempty"`
+
+	// Sanitize validates outbound OpenAI, Claude and Responses stream events
+	// against their format: "repair" fixes or drops invalid frames, "strict"
+	// aborts the stream with an error. Empty disables validation.
+	Sanitize string `yaml:"sanitize,omitempty" json:"sanitize,omitempty"`
+
+	// ToolCallDeltas aggregates streamed tool-call argument fragments for
+	// clients that mishandle partial JSON: "coalesce" merges them into fewer,
+	// larger deltas and "final" sends each call's arguments in one delta once
+	// the call is complete. Empty forwards fragments as received.
+	ToolCallDeltas string `yaml:"tool-call-deltas,omitempty" json:"tool-call-deltas,omitempty"`
 }
 
+// Stream sanitizer modes.
+const (
+	StreamSanitizeRepair = "repair"
+	StreamSanitizeStrict = "strict"
+)
+
+// Tool-call delta aggregation modes.
+const (
+	ToolCallDeltasCoalesce = "coalesce"
+	ToolCallDeltasFinal    = "final"
+)
+
 // AnthropicSSELifecycleEnabled reports whether the Anthropic SSE lifecycle
 // normalizer should run for Claude direct streams. The default is enabled.
 func (s StreamingConfig) AnthropicSSELifecycleEnabled() bool {
@@ -0,0 +1,360 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationIssue describes one schema violation found in a YAML config.
+type ValidationIssue struct {
+	// File is set when the issue comes from an included or overlay file.
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+
+	node *yaml.Node
+}
+
+// ValidationError collects every schema violation found in a config file.
+type ValidationError struct {
+	File   string
+	Issues []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		location := fmt.Sprintf("%d:%d", issue.Line, issue.Column)
+		if file := issue.File; file != "" {
+			location = file + ":" + location
+		} else if e.File != "" {
+			location = e.File + ":" + location
+		}
+		if issue.Path != "" {
+			lines = append(lines, fmt.Sprintf("%s: %s: %s", location, issue.Path, issue.Message))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: %s", location, issue.Message))
+		}
+	}
+	return "invalid config:\n  " + strings.Join(lines, "\n  ")
+}
+
+// configEnumValues lists the accepted values for enum-like string fields,
+// keyed by schema path ("[]" stands for any sequence index). Values are
+// matched case-insensitively after trimming; empty strings mean "use default".
+var configEnumValues = map[string][]string{
+	"routing.strategy":                            {"round-robin", "roundrobin", "rr", "fill-first", "fillfirst", "ff"},
+	"github-copilot.header-policy.mode":           {GitHubCopilotHeaderPolicyModeLegacy, GitHubCopilotHeaderPolicyModeDualRun, GitHubCopilotHeaderPolicyModeStrict},
+	"claude-api-key[].cloak.mode":                 {"auto", "always", "never"},
+	"request-signing[].hmac.algorithm":            {"sha256", "sha512"},
+	"shared-state.backend":                        {"memory", "redis"},
+	"openai-compatibility[].models[].stream-mode": {"stream", "non-stream"},
+	"disconnect.policy":                           {DisconnectPolicyCancel, DisconnectPolicyComplete},
+	"streaming.sanitize":                          {StreamSanitizeRepair, StreamSanitizeStrict},
+	"streaming.tool-call-deltas":                  {ToolCallDeltasCoalesce, ToolCallDeltasFinal},
+	"usage-anomaly.action":                        {UsageAnomalyActionNotify, UsageAnomalyActionThrottle, UsageAnomalyActionDisable},
+	"tls.acme.challenge":                          {ACMEChallengeTLSALPN, ACMEChallengeHTTP},
+	"api-key-profiles[].profile":                  {ClientProfileClaudeCode, ClientProfileCodexCLI},
+	"logging.format":                              {LogFormatText, LogFormatJSON},
+	"logging.levels.executor":                     LogLevelNames,
+	"logging.levels.translator":                   LogLevelNames,
+	"logging.levels.thinking":                     LogLevelNames,
+	"logging.levels.management":                   LogLevelNames,
+}
+
+// legacyConfigPaths are keys no longer in the schema that are still accepted
+// so that configs migrated by older tooling keep loading.
+var legacyConfigPaths = buildLegacyConfigPaths()
+
+var sequenceIndexPattern = regexp.MustCompile(`\[\d+\]`)
+
+func buildLegacyConfigPaths() map[string]struct{} {
+	paths := make(map[string]struct{})
+	for _, name := range yamlFieldNames(reflect.TypeOf(legacyConfigData{})) {
+		paths[name] = struct{}{}
+	}
+	for _, name := range yamlFieldNames(reflect.TypeOf(legacyOpenAICompatibility{})) {
+		paths["openai-compatibility[]."+name] = struct{}{}
+	}
+	return paths
+}
+
+// ValidateConfigYAML checks data against the Config schema. It reports
+// unknown fields, type mismatches, invalid enum values and unresolvable
+// ${...}/file:/vault: references with their line, column and key path.
+// file is only used to prefix error messages.
+// A nil error means the document is valid.
+func ValidateConfigYAML(file string, data []byte) error {
+	var root yaml.Node
+	if errParse := yaml.Unmarshal(data, &root); errParse != nil {
+		return fmt.Errorf("failed to parse config file: %w", errParse)
+	}
+	issues, _ := resolveConfigReferences(&root)
+	return newValidationError(file, append(issues, validateConfigNode(&root)...))
+}
+
+// validateConfigNode checks an already parsed (and resolved) document.
+func validateConfigNode(root *yaml.Node) []ValidationIssue {
+	if root.Kind == 0 || len(root.Content) == 0 {
+		return nil
+	}
+	v := &configValidator{}
+	v.walk(root.Content[0], reflect.TypeOf(Config{}), "")
+	return v.issues
+}
+
+// newValidationError returns nil when there are no issues, otherwise a
+// *ValidationError with the issues in document order.
+func newValidationError(file string, issues []ValidationIssue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+	return &ValidationError{File: file, Issues: issues}
+}
+
+// ValidationIssues returns the issues carried by err, if it is a *ValidationError.
+func ValidationIssues(err error) []ValidationIssue {
+	if validationErr, ok := errors.AsType[*ValidationError](err); ok {
+		return validationErr.Issues
+	}
+	return nil
+}
+
+type configValidator struct {
+	issues []ValidationIssue
+}
+
+func (v *configValidator) report(node *yaml.Node, path, format string, args ...any) {
+	v.issues = append(v.issues, ValidationIssue{
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+		node:    node,
+	})
+}
+
+func (v *configValidator) walk(node *yaml.Node, typ reflect.Type, path string) {
+	if node == nil {
+		return
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if reflect.PointerTo(typ).Implements(reflect.TypeFor[yaml.Unmarshaler]()) {
+		v.decodeLeaf(node, typ, path)
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			v.report(node, path, "expected a mapping, got %s", yamlNodeKind(node))
+			return
+		}
+		fields := yamlStructFields(typ)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			if keyNode.Value == "<<" {
+				continue
+			}
+			if path == "" && keyNode.Value == configIncludeKey {
+				v.checkInclude(valueNode)
+				continue
+			}
+			childPath := joinConfigPath(path, keyNode.Value)
+			field, ok := fields[keyNode.Value]
+			if !ok {
+				if _, legacy := legacyConfigPaths[schemaPath(childPath)]; !legacy {
+					v.report(keyNode, childPath, "unknown field")
+				}
+				continue
+			}
+			v.walk(valueNode, field, childPath)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			v.report(node, path, "expected a mapping, got %s", yamlNodeKind(node))
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			childPath := joinConfigPath(path, keyNode.Value)
+			v.decodeLeaf(keyNode, typ.Key(), childPath)
+			v.walk(valueNode, typ.Elem(), childPath)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			if typ.Elem().Kind() == reflect.Uint8 {
+				v.decodeLeaf(node, typ, path)
+				return
+			}
+			v.report(node, path, "expected a sequence, got %s", yamlNodeKind(node))
+			return
+		}
+		for i, item := range node.Content {
+			v.walk(item, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Interface:
+		// Free-form values (e.g. payload params) accept any YAML.
+	default:
+		if node.Kind != yaml.ScalarNode {
+			v.report(node, path, "expected a %s value, got %s", typ.Kind(), yamlNodeKind(node))
+			return
+		}
+		if !v.decodeLeaf(node, typ, path) {
+			return
+		}
+		if allowed, ok := configEnumValues[schemaPath(path)]; ok && typ.Kind() == reflect.String {
+			v.checkEnum(node, path, allowed)
+		}
+	}
+}
+
+// decodeLeaf decodes node with yaml.v3 itself so type checks match what the
+// real unmarshal accepts.
+func (v *configValidator) decodeLeaf(node *yaml.Node, typ reflect.Type, path string) bool {
+	target := reflect.New(typ)
+	if errDecode := node.Decode(target.Interface()); errDecode != nil {
+		message := errDecode.Error()
+		if typeErr, ok := errors.AsType[*yaml.TypeError](errDecode); ok && len(typeErr.Errors) > 0 {
+			message = typeErr.Errors[0]
+			if idx := strings.Index(message, ": "); idx >= 0 && strings.HasPrefix(message, "line ") {
+				message = message[idx+2:]
+			}
+		}
+		v.report(node, path, "%s", message)
+		return false
+	}
+	return true
+}
+
+// checkInclude accepts a path or a list of paths for the include directive.
+func (v *configValidator) checkInclude(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				v.report(item, fmt.Sprintf("%s[%d]", configIncludeKey, i), "expected a path, got %s", yamlNodeKind(item))
+			}
+		}
+	default:
+		v.report(node, configIncludeKey, "expected a path or a list of paths, got %s", yamlNodeKind(node))
+	}
+}
+
+func (v *configValidator) checkEnum(node *yaml.Node, path string, allowed []string) {
+	value := strings.ToLower(strings.TrimSpace(node.Value))
+	if value == "" {
+		return
+	}
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	v.report(node, path, "invalid value %q (allowed: %s)", node.Value, strings.Join(allowed, ", "))
+}
+
+// yamlStructFields maps YAML keys to field types, flattening inline structs.
+func yamlStructFields(typ reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, inline, skip := yamlFieldTag(field)
+		if skip {
+			continue
+		}
+		if inline {
+			inlineType := field.Type
+			for inlineType.Kind() == reflect.Pointer {
+				inlineType = inlineType.Elem()
+			}
+			if inlineType.Kind() == reflect.Struct {
+				for key, fieldType := range yamlStructFields(inlineType) {
+					fields[key] = fieldType
+				}
+			}
+			continue
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func yamlFieldNames(typ reflect.Type) []string {
+	fields := yamlStructFields(typ)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	return names
+}
+
+func yamlFieldTag(field reflect.StructField) (name string, inline, skip bool) {
+	tag := field.Tag.Get("yaml")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	for _, opt := range parts[1:] {
+		if opt == "inline" {
+			inline = true
+		}
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, inline, false
+}
+
+func joinConfigPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// schemaPath replaces concrete sequence indexes with "[]".
+func schemaPath(path string) string {
+	return sequenceIndexPattern.ReplaceAllString(path, "[]")
+}
+
+func yamlNodeKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a sequence"
+	case yaml.ScalarNode:
+		return fmt.Sprintf("scalar %q", node.Value)
+	default:
+		return "an unsupported node"
+	}
+}
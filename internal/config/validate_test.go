@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateConfigYAML_ReportsIssuesWithLocation(t *testing.T) {
+	data := []byte(`port: abc
+debugg: true
+routing:
+  strategy: random
+claude-api-key:
+  - api-key: x
+    cloak:
+      mode: Sometimes
+    models: nope
+`)
+	err := ValidateConfigYAML("config.yaml", data)
+	issues := ValidationIssues(err)
+	want := []ValidationIssue{
+		{Line: 1, Column: 7, Path: "port"},
+		{Line: 2, Column: 1, Path: "debugg", Message: "unknown field"},
+		{Line: 4, Column: 13, Path: "routing.strategy"},
+		{Line: 8, Column: 13, Path: "claude-api-key[0].cloak.mode"},
+		{Line: 9, Column: 13, Path: "claude-api-key[0].models"},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %d: %v", len(want), len(issues), err)
+	}
+	for i, w := range want {
+		got := issues[i]
+		if got.Line != w.Line || got.Column != w.Column || got.Path != w.Path {
+			t.Fatalf("issue %d = %+v, want line %d col %d path %q", i, got, w.Line, w.Column, w.Path)
+		}
+		if w.Message != "" && got.Message != w.Message {
+			t.Fatalf("issue %d message = %q, want %q", i, got.Message, w.Message)
+		}
+	}
+	if !strings.Contains(err.Error(), `config.yaml:8:13: claude-api-key[0].cloak.mode: invalid value "Sometimes"`) {
+		t.Fatalf("unexpected error text: %v", err)
+	}
+}
+
+func TestValidateConfigYAML_AcceptsLegacyKeysAndEnumCase(t *testing.T) {
+	data := []byte(`generative-language-api-key: ["k"]
+amp-upstream-url: https://example.com
+openai-compatibility:
+  - name: legacy
+    base-url: https://example.com/v1
+    api-keys: ["k"]
+routing:
+  strategy: " Fill-First "
+payload:
+  default:
+    - models: [{name: "gpt-*"}]
+      params: {"reasoning.effort": high, "nested": {"a": [1, 2]}}
+`)
+	if err := ValidateConfigYAML("", data); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+}
+
+func TestValidateConfigYAML_ExampleConfigIsValid(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "config.example.yaml"))
+	if err != nil {
+		t.Fatalf("read example config: %v", err)
+	}
+	if errValidate := ValidateConfigYAML("config.example.yaml", data); errValidate != nil {
+		t.Fatalf("example config should validate: %v", errValidate)
+	}
+}
+
+func TestValidateConfigYAML_AcceptsMarshaledConfig(t *testing.T) {
+	cfg := &Config{
+		Port:            8317,
+		ClaudeKey:       []ClaudeKey{{APIKey: "k", Cloak: &CloakConfig{Mode: "always"}}},
+		ProviderHeaders: map[string]map[string]string{"codex": {"X-Team": "a"}},
+		RequestSigning:  []RequestSigner{{Type: "hmac", HMAC: RequestSignerHMAC{Secret: "s"}}},
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if errValidate := ValidateConfigYAML("", data); errValidate != nil {
+		t.Fatalf("marshaled config should validate: %v", errValidate)
+	}
+}
+
+func TestLoadConfig_RejectsSchemaViolations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("port: 8317\nunknown-key: 1\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadConfig(path); len(ValidationIssues(err)) != 1 {
+		t.Fatalf("expected one validation issue, got %v", err)
+	}
+	cfg, err := LoadConfigOptional(path, true)
+	if err != nil || cfg == nil || cfg.Port != 8317 {
+		t.Fatalf("optional load should tolerate schema issues, got cfg=%v err=%v", cfg, err)
+	}
+}
@@ -25,6 +25,9 @@ type VertexCompatKey struct {
 	// When empty, requests fall back to the default Vertex API base URL.
 	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
 
+	// APIVersion optionally replaces the "v1" path segment.
+	APIVersion string `yaml:"api-version,omitempty" json:"api-version,omitempty"`
+
 	// ProxyURL optionally overrides the global proxy for this API key.
 	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
 
@@ -32,6 +35,15 @@ type VertexCompatKey struct {
 	// Commonly used for cookies, user-agent, and other authentication headers.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
 
+	// ClientProfile selects a client-profiles entry for requests sent with this key.
+	ClientProfile string `yaml:"client-profile,omitempty" json:"client-profile,omitempty"`
+
+	// Schedule selects an auth-schedules entry limiting when this key is used.
+	Schedule string `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// Labels tag the key for model-namespaces selectors.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
 	// Models defines the model configurations including aliases for routing.
 	Models []VertexCompatModel `yaml:"models,omitempty" json:"models,omitempty"`
 
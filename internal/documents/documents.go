@@ -0,0 +1,280 @@
+// Package documents converts document attachments (PDFs and plain-text files)
+// between the Claude, Gemini and OpenAI request schemas, and falls back to
+// extracted text for models that cannot read PDFs.
+package documents
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// PDFMimeType is the media type of PDF documents.
+const PDFMimeType = "application/pdf"
+
+// Document is an attachment in provider-neutral form. Exactly one of Data,
+// URL and Text carries its content.
+type Document struct {
+	MimeType string
+	Filename string
+	// Data is the base64-encoded file content.
+	Data string
+	// URL points at a file the upstream fetches itself.
+	URL string
+	// Text is the content of a plain-text document.
+	Text string
+}
+
+// IsPDF reports whether d is a PDF document.
+func (d Document) IsPDF() bool {
+	return d.MimeType == PDFMimeType
+}
+
+// IsDocumentMimeType reports whether mimeType is carried as a document rather
+// than as image, audio or video media.
+func IsDocumentMimeType(mimeType string) bool {
+	return mimeType == PDFMimeType || strings.HasPrefix(mimeType, "text/")
+}
+
+// ParseDataURL splits a base64 data URL into its media type and payload.
+func ParseDataURL(value string) (mimeType, data string, ok bool) {
+	rest, ok := strings.CutPrefix(value, "data:")
+	if !ok {
+		return "", "", false
+	}
+	mimeType, data, ok = strings.Cut(rest, ";base64,")
+	if !ok {
+		return "", "", false
+	}
+	return mimeType, data, true
+}
+
+// FromClaude reads a Claude document block. Blocks that reference uploaded
+// files by id cannot be converted.
+func FromClaude(block gjson.Result) (Document, bool) {
+	if block.Get("type").String() != "document" {
+		return Document{}, false
+	}
+	doc := Document{Filename: block.Get("title").String()}
+	source := block.Get("source")
+	switch source.Get("type").String() {
+	case "base64":
+		doc.MimeType, doc.Data = source.Get("media_type").String(), source.Get("data").String()
+		if doc.MimeType == "" {
+			doc.MimeType = PDFMimeType
+		}
+	case "url":
+		doc.MimeType, doc.URL = PDFMimeType, source.Get("url").String()
+	case "text":
+		doc.MimeType, doc.Text = "text/plain", source.Get("data").String()
+	case "content":
+		var texts []string
+		source.Get("content").ForEach(func(_, item gjson.Result) bool {
+			if item.Get("type").String() == "text" {
+				texts = append(texts, item.Get("text").String())
+			}
+			return true
+		})
+		if source.Get("content").Type == gjson.String {
+			texts = append(texts, source.Get("content").String())
+		}
+		doc.MimeType, doc.Text = "text/plain", strings.Join(texts, "\n")
+	default:
+		return Document{}, false
+	}
+	return doc, doc.Data != "" || doc.URL != "" || doc.Text != ""
+}
+
+// FromGemini reads a Gemini inline or file data part holding a document.
+func FromGemini(part gjson.Result) (Document, bool) {
+	if inline := firstOf(part, "inlineData", "inline_data"); inline.Exists() {
+		doc := Document{MimeType: firstOf(inline, "mimeType", "mime_type").String(), Data: inline.Get("data").String()}
+		return doc, IsDocumentMimeType(doc.MimeType) && doc.Data != ""
+	}
+	if file := firstOf(part, "fileData", "file_data"); file.Exists() {
+		doc := Document{MimeType: firstOf(file, "mimeType", "mime_type").String(), URL: firstOf(file, "fileUri", "file_uri").String()}
+		return doc, IsDocumentMimeType(doc.MimeType) && doc.URL != ""
+	}
+	return Document{}, false
+}
+
+// FromOpenAI reads a chat completions "file" part or a Responses
+// "input_file" part. Parts that reference uploaded files by id cannot be
+// converted.
+func FromOpenAI(part gjson.Result) (Document, bool) {
+	var fields gjson.Result
+	switch part.Get("type").String() {
+	case "file":
+		fields = part.Get("file")
+	case "input_file":
+		fields = part
+	default:
+		return Document{}, false
+	}
+	doc := Document{Filename: fields.Get("filename").String()}
+	if data := fields.Get("file_data").String(); data != "" {
+		if mimeType, payload, ok := ParseDataURL(data); ok {
+			doc.MimeType, doc.Data = mimeType, payload
+		} else {
+			doc.Data = data
+		}
+	} else if url := fields.Get("file_url").String(); url != "" {
+		doc.URL = url
+	} else {
+		return Document{}, false
+	}
+	if doc.MimeType == "" {
+		doc.MimeType = mimeTypeFromFilename(doc.Filename)
+	}
+	return doc, true
+}
+
+// ClaudeBlock returns d as a Claude content block. Plain-text documents use
+// a text source; files Claude cannot fetch become a text reference.
+func (d Document) ClaudeBlock() string {
+	block := `{"type":"document","source":{}}`
+	switch {
+	case d.Text != "" || d.Data != "" && strings.HasPrefix(d.MimeType, "text/"):
+		text := d.Text
+		if text == "" {
+			text = decodeText(d.Data)
+		}
+		block, _ = sjson.SetRaw(block, "source", `{"type":"text","media_type":"text/plain","data":""}`)
+		block, _ = sjson.Set(block, "source.data", text)
+	case d.Data != "":
+		block, _ = sjson.SetRaw(block, "source", `{"type":"base64","media_type":"","data":""}`)
+		block, _ = sjson.Set(block, "source.media_type", d.MimeType)
+		block, _ = sjson.Set(block, "source.data", d.Data)
+	case d.IsPDF() && isHTTPURL(d.URL):
+		block, _ = sjson.SetRaw(block, "source", `{"type":"url","url":""}`)
+		block, _ = sjson.Set(block, "source.url", d.URL)
+	default:
+		block = `{"type":"text","text":""}`
+		block, _ = sjson.Set(block, "text", d.reference())
+		return block
+	}
+	if d.Filename != "" {
+		block, _ = sjson.Set(block, "title", d.Filename)
+	}
+	return block
+}
+
+// GeminiPart returns d as a Gemini content part.
+func (d Document) GeminiPart() string {
+	switch {
+	case d.Data != "":
+		part := `{"inlineData":{"mimeType":"","data":""}}`
+		part, _ = sjson.Set(part, "inlineData.mimeType", d.MimeType)
+		part, _ = sjson.Set(part, "inlineData.data", d.Data)
+		return part
+	case d.URL != "":
+		part := `{"fileData":{"mimeType":"","fileUri":""}}`
+		part, _ = sjson.Set(part, "fileData.mimeType", d.MimeType)
+		part, _ = sjson.Set(part, "fileData.fileUri", d.URL)
+		return part
+	default:
+		part := `{"text":""}`
+		part, _ = sjson.Set(part, "text", d.Text)
+		return part
+	}
+}
+
+// OpenAIPart returns d as a chat completions content part. Chat completions
+// cannot reference files by URL, so those become a text reference.
+func (d Document) OpenAIPart() string {
+	switch {
+	case d.Data != "":
+		part := `{"type":"file","file":{"filename":"","file_data":""}}`
+		part, _ = sjson.Set(part, "file.filename", d.filename())
+		part, _ = sjson.Set(part, "file.file_data", "data:"+d.MimeType+";base64,"+d.Data)
+		return part
+	case d.URL != "":
+		part := `{"type":"text","text":""}`
+		part, _ = sjson.Set(part, "text", d.reference())
+		return part
+	default:
+		part := `{"type":"text","text":""}`
+		part, _ = sjson.Set(part, "text", d.Text)
+		return part
+	}
+}
+
+// ResponsesPart returns d as a Responses API input content part.
+func (d Document) ResponsesPart() string {
+	switch {
+	case d.Data != "":
+		part := `{"type":"input_file","filename":"","file_data":""}`
+		part, _ = sjson.Set(part, "filename", d.filename())
+		part, _ = sjson.Set(part, "file_data", "data:"+d.MimeType+";base64,"+d.Data)
+		return part
+	case isHTTPURL(d.URL):
+		part := `{"type":"input_file","file_url":""}`
+		part, _ = sjson.Set(part, "file_url", d.URL)
+		return part
+	case d.URL != "":
+		part := `{"type":"input_text","text":""}`
+		part, _ = sjson.Set(part, "text", d.reference())
+		return part
+	default:
+		part := `{"type":"input_text","text":""}`
+		part, _ = sjson.Set(part, "text", d.Text)
+		return part
+	}
+}
+
+// reference describes a document the target cannot receive as a file. It
+// keeps the wording the Gemini to Claude translation has always used.
+func (d Document) reference() string {
+	text := "File: " + d.URL
+	if d.MimeType != "" {
+		text += " (Type: " + d.MimeType + ")"
+	}
+	return text
+}
+
+func (d Document) filename() string {
+	if d.Filename != "" {
+		return d.Filename
+	}
+	switch d.MimeType {
+	case PDFMimeType:
+		return "document.pdf"
+	case "text/plain":
+		return "document.txt"
+	default:
+		return "document"
+	}
+}
+
+func mimeTypeFromFilename(filename string) string {
+	if idx := strings.LastIndex(filename, "."); idx >= 0 {
+		if mimeType, ok := misc.MimeTypes[strings.ToLower(filename[idx+1:])]; ok {
+			return mimeType
+		}
+	}
+	return PDFMimeType
+}
+
+func decodeText(data string) string {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return ""
+	}
+	return string(decoded)
+}
+
+func isHTTPURL(value string) bool {
+	return strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "http://")
+}
+
+func firstOf(value gjson.Result, keys ...string) gjson.Result {
+	for _, key := range keys {
+		if v := value.Get(key); v.Exists() {
+			return v
+		}
+	}
+	return gjson.Result{}
+}
@@ -0,0 +1,115 @@
+package documents
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+)
+
+// buildPDF returns a one-page PDF that draws text in Helvetica.
+func buildPDF(text string) []byte {
+	content := fmt.Sprintf("BT /F1 12 Tf 20 100 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return []byte(b.String())
+}
+
+func registerDocumentModel(t *testing.T, provider string, info *registry.ModelInfo) {
+	t.Helper()
+	clientID := uuid.NewString()
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(clientID, provider, []*registry.ModelInfo{info})
+	t.Cleanup(func() { reg.UnregisterClient(clientID) })
+}
+
+func TestExtractText(t *testing.T) {
+	text, err := ExtractText(buildPDF("Quarterly report"))
+	if err != nil {
+		t.Fatalf("ExtractText error: %v", err)
+	}
+	if !strings.Contains(text, "Quarterly report") {
+		t.Fatalf("text = %q", text)
+	}
+	if _, err = ExtractText([]byte("not a pdf")); err == nil {
+		t.Fatal("expected an error for malformed input")
+	}
+}
+
+func TestConversions(t *testing.T) {
+	doc, ok := FromOpenAI(gjson.Parse(`{"type":"file","file":{"filename":"a.pdf","file_data":"data:application/pdf;base64,JVBERi0="}}`))
+	if !ok || doc.MimeType != PDFMimeType || doc.Data != "JVBERi0=" {
+		t.Fatalf("FromOpenAI = %+v, %v", doc, ok)
+	}
+	if got := gjson.Get(doc.ClaudeBlock(), "source.media_type").String(); got != PDFMimeType {
+		t.Fatalf("claude block = %s", doc.ClaudeBlock())
+	}
+	if got := gjson.Get(doc.ResponsesPart(), "file_data").String(); got != "data:application/pdf;base64,JVBERi0=" {
+		t.Fatalf("responses part = %s", doc.ResponsesPart())
+	}
+
+	doc, ok = FromGemini(gjson.Parse(`{"file_data":{"mime_type":"application/pdf","file_uri":"gs://bucket/a.pdf"}}`))
+	if !ok || doc.URL != "gs://bucket/a.pdf" {
+		t.Fatalf("FromGemini = %+v, %v", doc, ok)
+	}
+	if block := gjson.Parse(doc.ClaudeBlock()); block.Get("type").String() != "text" || !strings.Contains(block.Get("text").String(), "gs://bucket/a.pdf") {
+		t.Fatalf("non-HTTP file should become a reference: %s", block.Raw)
+	}
+
+	text := base64.StdEncoding.EncodeToString([]byte("plain notes"))
+	doc, _ = FromGemini(gjson.Parse(`{"inlineData":{"mimeType":"text/plain","data":"` + text + `"}}`))
+	if block := gjson.Parse(doc.ClaudeBlock()); block.Get("source.type").String() != "text" || block.Get("source.data").String() != "plain notes" {
+		t.Fatalf("text document block = %s", block.Raw)
+	}
+	if _, ok = FromGemini(gjson.Parse(`{"inlineData":{"mimeType":"image/png","data":"AA=="}}`)); ok {
+		t.Fatal("images are not documents")
+	}
+}
+
+func TestNormalizeExtractsTextForModelsWithoutPDFSupport(t *testing.T) {
+	registerDocumentModel(t, "kimi", &registry.ModelInfo{ID: "documents-test-text"})
+	registerDocumentModel(t, "claude", &registry.ModelInfo{ID: "documents-test-pdf", SupportsPDF: true})
+
+	data := base64.StdEncoding.EncodeToString(buildPDF("Hello PDF"))
+	body := []byte(`{"messages":[{"role":"user","content":[{"type":"text","text":"summarise"},{"type":"file","file":{"filename":"hello.pdf","file_data":"data:application/pdf;base64,` + data + `"}}]}]}`)
+	out := Normalize(body, "documents-test-text", "kimi", "kimi")
+	part := gjson.GetBytes(out, "messages.0.content.1")
+	if part.Get("type").String() != "text" || !strings.Contains(part.Get("text").String(), "[hello.pdf]\nHello PDF") {
+		t.Fatalf("part = %s", part.Raw)
+	}
+
+	claude := []byte(`{"messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"document","source":{"type":"base64","media_type":"application/pdf","data":"` + data + `"}}]}]}]}`)
+	if got := Normalize(claude, "documents-test-pdf", "claude", "claude"); string(got) != string(claude) {
+		t.Fatalf("PDF-capable model rewritten: %s", got)
+	}
+	registerDocumentModel(t, "claude", &registry.ModelInfo{ID: "documents-test-claude-text"})
+	out = Normalize(claude, "documents-test-claude-text", "claude", "claude")
+	if got := gjson.GetBytes(out, "messages.0.content.0.content.0.text").String(); !strings.Contains(got, "Hello PDF") {
+		t.Fatalf("nested tool_result document not converted: %s", out)
+	}
+	if got := Normalize(body, "unknown-document-model", "openai", "openai"); string(got) != string(body) {
+		t.Fatalf("unknown model rewritten: %s", got)
+	}
+}
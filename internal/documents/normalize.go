@@ -0,0 +1,154 @@
+package documents
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// schema locates the content arrays of a provider format and converts its
+// parts to and from documents.
+type schema struct {
+	// containers is the path of the message array and content the key of
+	// each message's part array.
+	containers string
+	content    string
+	parse      func(gjson.Result) (Document, bool)
+	text       func(string) string
+}
+
+func textPart(template, key string) func(string) string {
+	return func(text string) string {
+		part, _ := sjson.Set(template, key, text)
+		return part
+	}
+}
+
+func schemaFor(format string) (schema, bool) {
+	switch format {
+	case "claude":
+		return schema{containers: "messages", content: "content", parse: FromClaude, text: textPart(`{"type":"text","text":""}`, "text")}, true
+	case "openai", "iflow", "kimi":
+		return schema{containers: "messages", content: "content", parse: FromOpenAI, text: textPart(`{"type":"text","text":""}`, "text")}, true
+	case "openai-response", "codex":
+		return schema{containers: "input", content: "content", parse: FromOpenAI, text: textPart(`{"type":"input_text","text":""}`, "text")}, true
+	case "gemini":
+		return schema{containers: "contents", content: "parts", parse: FromGemini, text: textPart(`{"text":""}`, "text")}, true
+	case "gemini-cli", "antigravity":
+		return schema{containers: "request.contents", content: "parts", parse: FromGemini, text: textPart(`{"text":""}`, "text")}, true
+	default:
+		return schema{}, false
+	}
+}
+
+// Readable reports whether info describes a model that reads PDFs. Models the
+// registry does not know and user-defined models are trusted to.
+func Readable(info *registry.ModelInfo) bool {
+	return info == nil || info.UserDefined || info.SupportsPDF
+}
+
+// Supported resolves model for provider and reports whether it reads PDFs.
+func Supported(model, provider string) bool {
+	return Readable(registry.LookupModelInfo(baseModel(model), provider))
+}
+
+// Normalize replaces the PDF documents of body, already in the provider
+// format, with their extracted text when the target model cannot read PDFs.
+func Normalize(body []byte, model, format, provider string) []byte {
+	s, ok := schemaFor(format)
+	if !ok || !bytes.Contains(body, []byte("pdf")) || Supported(model, provider) {
+		return body
+	}
+	type replacement struct {
+		path string
+		part string
+	}
+	var replacements []replacement
+	var visit func(path string, parts gjson.Result)
+	visit = func(path string, parts gjson.Result) {
+		parts.ForEach(func(key, part gjson.Result) bool {
+			partPath := path + "." + key.String()
+			if doc, ok := s.parse(part); ok && doc.IsPDF() {
+				replacements = append(replacements, replacement{path: partPath, part: s.text(doc.fallbackText())})
+			} else if nested := part.Get("content"); format == "claude" && part.Get("type").String() == "tool_result" && nested.IsArray() {
+				visit(partPath+".content", nested)
+			}
+			return true
+		})
+	}
+	gjson.GetBytes(body, s.containers).ForEach(func(key, message gjson.Result) bool {
+		if parts := message.Get(s.content); parts.IsArray() {
+			visit(s.containers+"."+key.String()+"."+s.content, parts)
+		}
+		return true
+	})
+	for _, r := range replacements {
+		if updated, err := sjson.SetRawBytes(body, r.path, []byte(r.part)); err == nil {
+			body = updated
+		}
+	}
+	if len(replacements) > 0 {
+		log.Debugf("documents: replaced %d PDF(s) with extracted text for model %s", len(replacements), model)
+	}
+	return body
+}
+
+// fallbackText renders a PDF document as text for models that cannot read
+// the file itself.
+func (d Document) fallbackText() string {
+	name := d.Filename
+	if name == "" {
+		name = "document"
+	}
+	if d.Data == "" {
+		return d.reference()
+	}
+	raw, err := base64.StdEncoding.DecodeString(d.Data)
+	if err != nil {
+		return fmt.Sprintf("[%s: invalid base64 PDF data]", name)
+	}
+	text, err := ExtractText(raw)
+	if err != nil {
+		log.Warnf("documents: extract text from %s: %v", name, err)
+		return fmt.Sprintf("[%s: the PDF could not be converted to text]", name)
+	}
+	return fmt.Sprintf("[%s]\n%s", name, text)
+}
+
+// ExtractText returns the plain text of a PDF file.
+func ExtractText(data []byte) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	out, err := io.ReadAll(plain)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func baseModel(model string) string {
+	if name := strings.TrimSpace(thinking.ParseSuffix(model).ModelName); name != "" {
+		return name
+	}
+	return strings.TrimSpace(model)
+}
@@ -0,0 +1,346 @@
+package embedding
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// CacheFile is the name of the cache file inside the cache directory.
+const CacheFile = "embeddings.jsonl"
+
+// sketchSize is the number of buckets character trigrams are hashed into
+// for near-duplicate detection.
+const sketchSize = 256
+
+// maxCacheLine bounds one persisted entry; 3072 float dimensions take about
+// 60 KiB.
+const maxCacheLine = 16 << 20
+
+// Key identifies a vector: the same text embedded by the same model with the
+// same options yields the same vector.
+type Key struct {
+	// Scope partitions the cache, e.g. per client API key, so entries are
+	// only served within the scope that stored them. Empty is shared.
+	Scope      string
+	Model      string
+	TaskType   string
+	Dimensions int64
+	// Text is the input as returned by NormalizeText.
+	Text string
+}
+
+// NormalizeText trims text and collapses its whitespace runs, so inputs that
+// differ only in layout share a cache entry.
+func NormalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func (k Key) group() string {
+	group := k.Model + "\x00" + k.TaskType + "\x00" + strconv.FormatInt(k.Dimensions, 10)
+	if k.Scope != "" {
+		group = k.Scope + "\x00" + group
+	}
+	return group
+}
+
+func (k Key) hash() string {
+	sum := sha256.Sum256([]byte(k.group() + "\x00" + k.Text))
+	return hex.EncodeToString(sum[:])
+}
+
+type cacheEntry struct {
+	Hash   string          `json:"hash"`
+	Group  string          `json:"group"`
+	Length int             `json:"length"`
+	Sketch string          `json:"sketch"`
+	Vector json.RawMessage `json:"vector"`
+
+	sketch []float32
+}
+
+// Cache stores embedding vectors in memory and appends them to a JSON lines
+// file so they survive restarts. Once full, the oldest entries are evicted.
+type Cache struct {
+	mu         sync.Mutex
+	path       string
+	file       *os.File
+	maxEntries int
+	entries    map[string]*cacheEntry
+	order      []string
+	lines      int
+}
+
+// OpenCache loads the cache persisted in dir, creating the directory when
+// needed, and keeps at most maxEntries vectors.
+func OpenCache(dir string, maxEntries int) (*Cache, error) {
+	if maxEntries <= 0 {
+		return nil, errors.New("embedding cache: max entries must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	c := &Cache{path: filepath.Join(dir, CacheFile), maxEntries: maxEntries, entries: make(map[string]*cacheEntry)}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	if c.lines > len(c.entries) {
+		if err := c.compact(); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	c.file = file
+	return c, nil
+}
+
+func (c *Cache) load() error {
+	file, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxCacheLine)
+	for scanner.Scan() {
+		c.lines++
+		var entry cacheEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.Hash == "" || len(entry.Vector) == 0 {
+			continue
+		}
+		entry.sketch = decodeSketch(entry.Sketch)
+		c.add(&entry)
+	}
+	return scanner.Err()
+}
+
+// Close releases the cache file.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// Get returns the vector cached for key. When similarity is between 0 and 1
+// and no exact entry exists, it returns the vector of the most similar text
+// embedded with the same options, provided the cosine similarity of their
+// character trigram sketches reaches similarity.
+func (c *Cache) Get(key Key, similarity float64) (json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key.hash()]; ok {
+		return entry.Vector, true
+	}
+	if similarity <= 0 || similarity >= 1 {
+		return nil, false
+	}
+	group, length := key.group(), utf8.RuneCountInString(key.Text)
+	_, sketch := quantizedSketch(key.Text)
+	var best *cacheEntry
+	bestScore := similarity
+	for _, entry := range c.entries {
+		// Texts of very different lengths are never near-duplicates.
+		if entry.Group != group || entry.sketch == nil || entry.Length*5 < length*4 || length*5 < entry.Length*4 {
+			continue
+		}
+		if score := cosine(sketch, entry.sketch); score >= bestScore {
+			best, bestScore = entry, score
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	return best.Vector, true
+}
+
+// Put caches vector, a JSON array of floats, for key.
+func (c *Cache) Put(key Key, vector json.RawMessage) error {
+	encoded, sketch := quantizedSketch(key.Text)
+	entry := &cacheEntry{
+		Hash:   key.hash(),
+		Group:  key.group(),
+		Length: utf8.RuneCountInString(key.Text),
+		Sketch: encoded,
+		Vector: append(json.RawMessage(nil), vector...),
+		sketch: sketch,
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[entry.Hash]; ok {
+		return nil
+	}
+	c.add(entry)
+	if c.file == nil {
+		return errors.New("embedding cache: closed")
+	}
+	if _, err = c.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	c.lines++
+	if c.lines > 2*c.maxEntries {
+		return c.reopenCompacted()
+	}
+	return nil
+}
+
+// Len returns the number of cached vectors.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *Cache) add(entry *cacheEntry) {
+	if _, ok := c.entries[entry.Hash]; !ok {
+		c.order = append(c.order, entry.Hash)
+	}
+	c.entries[entry.Hash] = entry
+	for len(c.entries) > c.maxEntries {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// reopenCompacted rewrites the file without evicted entries and reopens it
+// for appending.
+func (c *Cache) reopenCompacted() error {
+	if err := c.file.Close(); err != nil {
+		return err
+	}
+	c.file = nil
+	if err := c.compact(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	c.file = file
+	return nil
+}
+
+func (c *Cache) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".embeddings-*.tmp")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, hash := range c.order {
+		line, errMarshal := json.Marshal(c.entries[hash])
+		if errMarshal != nil {
+			continue
+		}
+		_, _ = writer.Write(append(line, '\n'))
+	}
+	if err = writer.Flush(); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	c.lines = len(c.order)
+	return nil
+}
+
+// quantizedSketch returns the persisted form of the sketch of text and the
+// sketch it decodes to, so fresh and loaded entries compare alike.
+func quantizedSketch(text string) (string, []float32) {
+	encoded := encodeSketch(textSketch(text))
+	return encoded, decodeSketch(encoded)
+}
+
+// textSketch hashes the lower-cased character trigrams of text into an
+// L2-normalised count vector.
+func textSketch(text string) []float32 {
+	runes := []rune(strings.ToLower(text))
+	sketch := make([]float32, sketchSize)
+	if len(runes) == 0 {
+		return sketch
+	}
+	n := 3
+	if len(runes) < n {
+		n = len(runes)
+	}
+	for i := 0; i+n <= len(runes); i++ {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(string(runes[i : i+n])))
+		sketch[h.Sum32()%sketchSize]++
+	}
+	return normalizeSketch(sketch)
+}
+
+func normalizeSketch(sketch []float32) []float32 {
+	var sum float64
+	for _, v := range sketch {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return sketch
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range sketch {
+		sketch[i] /= norm
+	}
+	return sketch
+}
+
+// encodeSketch quantises a normalised sketch to one byte per bucket.
+func encodeSketch(sketch []float32) string {
+	raw := make([]byte, len(sketch))
+	for i, v := range sketch {
+		raw[i] = byte(math.Round(float64(v) * 255))
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func decodeSketch(encoded string) []float32 {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != sketchSize {
+		return nil
+	}
+	sketch := make([]float32, sketchSize)
+	for i, b := range raw {
+		sketch[i] = float32(b) / 255
+	}
+	return normalizeSketch(sketch)
+}
+
+func cosine(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
@@ -0,0 +1,66 @@
+package embedding
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCachePersistsAndEvicts(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := OpenCache(dir, 2)
+	if err != nil {
+		t.Fatalf("OpenCache: %v", err)
+	}
+	key := func(text string) Key { return Key{Model: "m", Dimensions: 8, Text: NormalizeText(text)} }
+	for _, text := range []string{"one", "two", "three"} {
+		if err = cache.Put(key(text), json.RawMessage(`[1,2]`)); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	if err = cache.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := OpenCache(dir, 2)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer func() { _ = reopened.Close() }()
+	if reopened.Len() != 2 {
+		t.Fatalf("Len = %d", reopened.Len())
+	}
+	if _, ok := reopened.Get(key("one"), 0); ok {
+		t.Fatal("oldest entry should have been evicted")
+	}
+	if vector, ok := reopened.Get(key("  three "), 0); !ok || string(vector) != `[1,2]` {
+		t.Fatalf("three = %s, %v", vector, ok)
+	}
+	if _, ok := reopened.Get(Key{Model: "m", Dimensions: 16, Text: "three"}, 0); ok {
+		t.Fatal("entries must not be shared across dimensions")
+	}
+}
+
+func TestCacheNearDuplicates(t *testing.T) {
+	cache, err := OpenCache(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("OpenCache: %v", err)
+	}
+	defer func() { _ = cache.Close() }()
+	text := "The quick brown fox jumps over the lazy dog near the riverbank every morning."
+	if err = cache.Put(Key{Model: "m", Text: text}, json.RawMessage(`[0.5]`)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	near := Key{Model: "m", Text: "The quick brown fox jumps over the lazy dog near the riverbank every morning!"}
+	if _, ok := cache.Get(near, 0); ok {
+		t.Fatal("exact lookup matched a different text")
+	}
+	if vector, ok := cache.Get(near, 0.9); !ok || string(vector) != `[0.5]` {
+		t.Fatalf("near duplicate = %s, %v", vector, ok)
+	}
+	if _, ok := cache.Get(Key{Model: "m", Text: "Completely unrelated sentence about quarterly revenue figures."}, 0.9); ok {
+		t.Fatal("unrelated text matched")
+	}
+	if _, ok := cache.Get(Key{Model: "other", Text: near.Text}, 0.9); ok {
+		t.Fatal("near duplicates must not cross models")
+	}
+}
@@ -0,0 +1,113 @@
+// Package embedding maps Gemini embedContent and batchEmbedContents requests
+// onto embedding upstreams and converts their vectors back.
+package embedding
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Gemini embedding methods, also used as the execution Alt of embedding
+// requests.
+const (
+	MethodEmbed      = "embedContent"
+	MethodBatchEmbed = "batchEmbedContents"
+)
+
+// IsMethod reports whether alt names a Gemini embedding method.
+func IsMethod(alt string) bool {
+	return alt == MethodEmbed || alt == MethodBatchEmbed
+}
+
+// GeminiRequest returns a Gemini embedding request for model, naming it in
+// the request and in each batched request as the API expects.
+func GeminiRequest(model, method string, payload []byte) []byte {
+	name := "models/" + model
+	out, _ := sjson.SetBytes(payload, "model", name)
+	if method != MethodBatchEmbed {
+		return out
+	}
+	out, _ = sjson.DeleteBytes(out, "model")
+	for i := range gjson.GetBytes(out, "requests").Array() {
+		out, _ = sjson.SetBytes(out, fmt.Sprintf("requests.%d.model", i), name)
+	}
+	return out
+}
+
+// OpenAIRequest converts a Gemini embedding request into an OpenAI
+// /embeddings request: each content becomes one input, its text parts joined
+// by newlines.
+func OpenAIRequest(model, method string, payload []byte) ([]byte, error) {
+	contents := []gjson.Result{gjson.GetBytes(payload, "content")}
+	dimensions := gjson.GetBytes(payload, "outputDimensionality")
+	if method == MethodBatchEmbed {
+		contents = contents[:0]
+		for _, request := range gjson.GetBytes(payload, "requests").Array() {
+			contents = append(contents, request.Get("content"))
+			if !dimensions.Exists() {
+				dimensions = request.Get("outputDimensionality")
+			}
+		}
+	}
+	inputs := make([]string, 0, len(contents))
+	for _, content := range contents {
+		text, ok := ContentText(content)
+		if !ok {
+			return nil, errors.New("embedding content has no text parts")
+		}
+		inputs = append(inputs, text)
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("embedding request has no content")
+	}
+	out, _ := sjson.SetBytes([]byte(`{}`), "model", model)
+	out, _ = sjson.SetBytes(out, "input", inputs)
+	if dimensions.Exists() && dimensions.Int() > 0 {
+		out, _ = sjson.SetBytes(out, "dimensions", dimensions.Int())
+	}
+	return out, nil
+}
+
+// ContentText joins the text parts of a Gemini embedding content by
+// newlines, reporting false when it has none.
+func ContentText(content gjson.Result) (string, bool) {
+	var texts []string
+	for _, part := range content.Get("parts").Array() {
+		if text := part.Get("text"); text.Exists() {
+			texts = append(texts, text.String())
+		}
+	}
+	return strings.Join(texts, "\n"), len(texts) > 0
+}
+
+// FromOpenAI converts an OpenAI /embeddings response into the Gemini response
+// of method.
+func FromOpenAI(method string, data []byte) ([]byte, error) {
+	items := gjson.GetBytes(data, "data").Array()
+	if len(items) == 0 {
+		return nil, errors.New("embedding response has no data")
+	}
+	vectors := make([]string, len(items))
+	for i, item := range items {
+		index := i
+		if idx := item.Get("index"); idx.Exists() && idx.Int() >= 0 && int(idx.Int()) < len(items) {
+			index = int(idx.Int())
+		}
+		embedding := item.Get("embedding")
+		if !embedding.IsArray() {
+			return nil, errors.New("embedding response has no float vectors")
+		}
+		if vectors[index] != "" {
+			return nil, errors.New("embedding response repeats an index")
+		}
+		vectors[index] = `{"values":` + embedding.Raw + `}`
+	}
+	if method != MethodBatchEmbed {
+		return []byte(`{"embedding":` + vectors[0] + `}`), nil
+	}
+	return []byte(`{"embeddings":[` + strings.Join(vectors, ",") + `]}`), nil
+}
@@ -0,0 +1,65 @@
+package embedding
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestGeminiRequestNamesModel(t *testing.T) {
+	single := GeminiRequest("gemini-embedding-001", MethodEmbed, []byte(`{"content":{"parts":[{"text":"hi"}]}}`))
+	if got := gjson.GetBytes(single, "model").String(); got != "models/gemini-embedding-001" {
+		t.Fatalf("model = %q", got)
+	}
+
+	batch := GeminiRequest("gemini-embedding-001", MethodBatchEmbed, []byte(`{"model":"x","requests":[{"model":"alias","content":{}},{"content":{}}]}`))
+	if gjson.GetBytes(batch, "model").Exists() {
+		t.Fatalf("batch request should not carry a top-level model: %s", batch)
+	}
+	if got := gjson.GetBytes(batch, "requests.#.model").String(); got != `["models/gemini-embedding-001","models/gemini-embedding-001"]` {
+		t.Fatalf("batch models = %s", got)
+	}
+}
+
+func TestOpenAIRequest(t *testing.T) {
+	out, err := OpenAIRequest("text-embedding-3-small", MethodBatchEmbed, []byte(`{"requests":[
+		{"content":{"parts":[{"text":"a"},{"text":"b"}]},"outputDimensionality":256},
+		{"content":{"parts":[{"text":"c"}]}}
+	]}`))
+	if err != nil {
+		t.Fatalf("OpenAIRequest error: %v", err)
+	}
+	if got := gjson.GetBytes(out, "input").Raw; got != `["a\nb","c"]` {
+		t.Fatalf("input = %s", got)
+	}
+	if gjson.GetBytes(out, "model").String() != "text-embedding-3-small" || gjson.GetBytes(out, "dimensions").Int() != 256 {
+		t.Fatalf("request = %s", out)
+	}
+
+	if _, err = OpenAIRequest("m", MethodEmbed, []byte(`{"content":{"parts":[{"inlineData":{}}]}}`)); err == nil {
+		t.Fatal("content without text should be rejected")
+	}
+}
+
+func TestFromOpenAI(t *testing.T) {
+	data := []byte(`{"data":[{"index":1,"embedding":[0.3]},{"index":0,"embedding":[0.1,0.2]}]}`)
+	out, err := FromOpenAI(MethodBatchEmbed, data)
+	if err != nil {
+		t.Fatalf("FromOpenAI error: %v", err)
+	}
+	if string(out) != `{"embeddings":[{"values":[0.1,0.2]},{"values":[0.3]}]}` {
+		t.Fatalf("batch = %s", out)
+	}
+
+	out, err = FromOpenAI(MethodEmbed, []byte(`{"data":[{"embedding":[1,2]}]}`))
+	if err != nil || string(out) != `{"embedding":{"values":[1,2]}}` {
+		t.Fatalf("single = %s, %v", out, err)
+	}
+
+	if _, err = FromOpenAI(MethodBatchEmbed, []byte(`{"data":[{"index":0,"embedding":[1]},{"index":0,"embedding":[2]}]}`)); err == nil {
+		t.Fatal("repeated indexes should be rejected")
+	}
+	if _, err = FromOpenAI(MethodEmbed, []byte(`{"data":[{"embedding":"AAAA"}]}`)); err == nil {
+		t.Fatal("base64 vectors should be rejected")
+	}
+}
@@ -0,0 +1,202 @@
+// Package eventstream fans live proxy activity out to management subscribers:
+// request lifecycle events from the API middleware, terminal usage records
+// and log entries. Publishing is a no-op while nobody is subscribed, and slow
+// subscribers lose events instead of blocking the proxy.
+package eventstream
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Event types.
+const (
+	TypeRequestStarted  = "request_started"
+	TypeRequestFinished = "request_finished"
+	TypeUsage           = "usage"
+	TypeLog             = "log"
+)
+
+// DefaultBuffer is the number of events queued per subscriber before new
+// events are dropped.
+const DefaultBuffer = 256
+
+// Event is one streamed activity record. Fields that do not apply to the
+// event type are omitted.
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+
+	// Request lifecycle.
+	Method    string `json:"method,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	LatencyMs int64  `json:"latency_ms,omitempty"`
+	Error     string `json:"error,omitempty"`
+
+	// Usage.
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	APIKey   string `json:"api_key,omitempty"`
+	Usage    *Usage `json:"usage,omitempty"`
+
+	// Log entries.
+	Level   string         `json:"level,omitempty"`
+	Message string         `json:"message,omitempty"`
+	Fields  map[string]any `json:"fields,omitempty"`
+
+	// apiKey is the unmasked key, used only for filtering.
+	apiKey string
+}
+
+// Usage is the token breakdown of a usage event.
+type Usage struct {
+	AuthIndex       string `json:"auth_index,omitempty"`
+	InputTokens     int64  `json:"input_tokens"`
+	OutputTokens    int64  `json:"output_tokens"`
+	ReasoningTokens int64  `json:"reasoning_tokens,omitempty"`
+	CachedTokens    int64  `json:"cached_tokens,omitempty"`
+	TotalTokens     int64  `json:"total_tokens"`
+	Failed          bool   `json:"failed,omitempty"`
+	Estimated       bool   `json:"estimated,omitempty"`
+}
+
+// Filter selects the events a subscriber receives. Zero fields match
+// everything; a set Provider, Model or APIKey only matches events carrying
+// that value, so request lifecycle and log events are excluded by them.
+type Filter struct {
+	Types     map[string]struct{}
+	Provider  string
+	Model     string
+	APIKey    string
+	RequestID string
+	// MinLevel is the least severe log level delivered.
+	MinLevel log.Level
+	// ErrorsOnly keeps failed requests, failed usage and error logs only.
+	ErrorsOnly bool
+}
+
+// Match reports whether e passes the filter.
+func (f Filter) Match(e Event) bool {
+	if len(f.Types) > 0 {
+		if _, ok := f.Types[e.Type]; !ok {
+			return false
+		}
+	}
+	if f.Provider != "" && !strings.EqualFold(f.Provider, e.Provider) {
+		return false
+	}
+	if f.Model != "" && !strings.EqualFold(f.Model, e.Model) {
+		return false
+	}
+	if f.APIKey != "" && f.APIKey != e.apiKey {
+		return false
+	}
+	if f.RequestID != "" && f.RequestID != e.RequestID {
+		return false
+	}
+	if e.Type == TypeLog {
+		level, err := log.ParseLevel(e.Level)
+		if err == nil && level > f.MinLevel {
+			return false
+		}
+	}
+	if f.ErrorsOnly && !e.isError() {
+		return false
+	}
+	return true
+}
+
+func (e Event) isError() bool {
+	switch e.Type {
+	case TypeRequestFinished:
+		return e.Status >= 400 || e.Error != ""
+	case TypeUsage:
+		return e.Usage != nil && e.Usage.Failed
+	case TypeLog:
+		level, err := log.ParseLevel(e.Level)
+		return err == nil && level <= log.ErrorLevel
+	default:
+		return false
+	}
+}
+
+// Subscription receives the events matching its filter on C until Close.
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	filter  Filter
+	dropped atomic.Int64
+	once    sync.Once
+}
+
+// Dropped returns the number of events lost because C was full.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes C.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		mu.Lock()
+		delete(subscribers, s)
+		active.Store(int32(len(subscribers)))
+		mu.Unlock()
+		close(s.ch)
+	})
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = make(map[*Subscription]struct{})
+	active      atomic.Int32
+)
+
+// Subscribe registers a subscriber with the given filter. buffer <= 0 uses
+// DefaultBuffer.
+func Subscribe(filter Filter, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	ch := make(chan Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	mu.Lock()
+	subscribers[sub] = struct{}{}
+	active.Store(int32(len(subscribers)))
+	mu.Unlock()
+	return sub
+}
+
+// Active reports whether anyone is subscribed, so publishers can skip
+// building events.
+func Active() bool {
+	return active.Load() > 0
+}
+
+// Publish delivers e to every matching subscriber without blocking.
+func Publish(e Event) {
+	if !Active() {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for sub := range subscribers {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package eventstream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+func nextEvent(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case event := <-sub.C:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return Event{}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	usage := Event{Type: TypeUsage, Provider: "claude", Model: "claude-sonnet-4-5", apiKey: "sk-a", Usage: &Usage{Failed: true}}
+	request := Event{Type: TypeRequestFinished, Status: 200}
+	debug := Event{Type: TypeLog, Level: "debug"}
+	errLog := Event{Type: TypeLog, Level: "error"}
+
+	cases := []struct {
+		name   string
+		filter Filter
+		event  Event
+		want   bool
+	}{
+		{"empty filter", Filter{MinLevel: log.InfoLevel}, usage, true},
+		{"type excluded", Filter{Types: map[string]struct{}{TypeLog: {}}}, usage, false},
+		{"provider case", Filter{Provider: "CLAUDE"}, usage, true},
+		{"model excludes request", Filter{Model: "claude-sonnet-4-5"}, request, false},
+		{"api key", Filter{APIKey: "sk-b"}, usage, false},
+		{"debug below level", Filter{MinLevel: log.InfoLevel}, debug, false},
+		{"debug at level", Filter{MinLevel: log.DebugLevel}, debug, true},
+		{"errors only keeps failed usage", Filter{ErrorsOnly: true}, usage, true},
+		{"errors only drops ok request", Filter{ErrorsOnly: true}, request, false},
+		{"errors only keeps error log", Filter{ErrorsOnly: true, MinLevel: log.InfoLevel}, errLog, true},
+	}
+	for _, tc := range cases {
+		if got := tc.filter.Match(tc.event); got != tc.want {
+			t.Errorf("%s: Match = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPublishDropsWhenSubscriberIsFull(t *testing.T) {
+	sub := Subscribe(Filter{Types: map[string]struct{}{TypeRequestStarted: {}}}, 1)
+	defer sub.Close()
+
+	Publish(Event{Type: TypeRequestStarted, RequestID: "a"})
+	Publish(Event{Type: TypeRequestStarted, RequestID: "b"})
+	if event := nextEvent(t, sub); event.RequestID != "a" {
+		t.Fatalf("first event = %+v", event)
+	}
+	if sub.Dropped() != 1 {
+		t.Fatalf("dropped = %d, want 1", sub.Dropped())
+	}
+
+	sub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Fatal("channel should be closed after Close")
+	}
+	Publish(Event{Type: TypeRequestStarted})
+}
+
+func TestSourcesPublishRequestUsageAndLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sub := Subscribe(Filter{RequestID: "req-events", MinLevel: log.InfoLevel}, 0)
+	defer sub.Close()
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		logging.SetGinRequestID(c, "req-events")
+		c.Set("apiKey", "sk-test-1234567890")
+		c.Next()
+	}, Middleware())
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		usagePlugin{}.HandleUsage(context.Background(), coreusage.Record{
+			RequestID: "req-events",
+			Provider:  "openai",
+			Model:     "gpt-4o",
+			APIKey:    "sk-test-1234567890",
+			Estimated: true,
+			Detail:    coreusage.Detail{InputTokens: 3, OutputTokens: 4, TotalTokens: 7},
+		})
+		log.WithField("request_id", "req-events").Warn("upstream slow")
+		c.Status(http.StatusBadGateway)
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+
+	started := nextEvent(t, sub)
+	if started.Type != TypeRequestStarted || started.Path != "/v1/chat/completions" {
+		t.Fatalf("started = %+v", started)
+	}
+	usage := nextEvent(t, sub)
+	if usage.Type != TypeUsage || usage.Usage.TotalTokens != 7 || !usage.Usage.Estimated || usage.APIKey == "sk-test-1234567890" {
+		t.Fatalf("usage = %+v", usage)
+	}
+	logged := nextEvent(t, sub)
+	if logged.Type != TypeLog || logged.Level != "warning" || logged.Message != "upstream slow" {
+		t.Fatalf("log = %+v", logged)
+	}
+	finished := nextEvent(t, sub)
+	if finished.Type != TypeRequestFinished || finished.Status != http.StatusBadGateway {
+		t.Fatalf("finished = %+v", finished)
+	}
+}
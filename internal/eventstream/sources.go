@@ -0,0 +1,126 @@
+package eventstream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	coreusage.RegisterPlugin(usagePlugin{})
+	log.AddHook(logHook{})
+}
+
+// Middleware publishes the start and finish of API requests. It must run
+// after logging.GinLogrusLogger, which assigns the request IDs; requests
+// without one, such as management calls, are not published.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := logging.GetGinRequestID(c)
+		if requestID == "" || !Active() {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		Publish(Event{
+			Type:      TypeRequestStarted,
+			Time:      start,
+			RequestID: requestID,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+		})
+
+		c.Next()
+
+		apiKey := c.GetString("apiKey")
+		Publish(Event{
+			Type:      TypeRequestFinished,
+			RequestID: requestID,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			LatencyMs: time.Since(start).Milliseconds(),
+			Error:     c.Errors.ByType(gin.ErrorTypePrivate).String(),
+			APIKey:    util.HideAPIKey(apiKey),
+			apiKey:    apiKey,
+		})
+	}
+}
+
+// usagePlugin publishes terminal usage records.
+type usagePlugin struct{}
+
+func (usagePlugin) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if !Active() {
+		return
+	}
+	requestID := record.RequestID
+	if requestID == "" {
+		requestID = logging.GetRequestID(ctx)
+	}
+	detail := record.Detail
+	Publish(Event{
+		Type:      TypeUsage,
+		RequestID: requestID,
+		Provider:  record.Provider,
+		Model:     record.Model,
+		APIKey:    util.HideAPIKey(record.APIKey),
+		apiKey:    record.APIKey,
+		Usage: &Usage{
+			AuthIndex:       record.AuthIndex,
+			InputTokens:     detail.InputTokens,
+			OutputTokens:    detail.OutputTokens,
+			ReasoningTokens: detail.ReasoningTokens,
+			CachedTokens:    detail.CachedTokens,
+			TotalTokens:     detail.TotalTokens,
+			Failed:          record.Failed,
+			Estimated:       record.Estimated,
+		},
+	})
+}
+
+// logHook publishes log entries.
+type logHook struct{}
+
+func (logHook) Levels() []log.Level { return log.AllLevels }
+
+func (logHook) Fire(entry *log.Entry) error {
+	if !Active() {
+		return nil
+	}
+	var fields map[string]any
+	var requestID string
+	if len(entry.Data) > 0 {
+		fields = make(map[string]any, len(entry.Data))
+		for key, value := range entry.Data {
+			if key == "request_id" {
+				requestID = fmt.Sprint(value)
+				continue
+			}
+			switch v := value.(type) {
+			case string, bool, int, int64, float64:
+				fields[key] = v
+			default:
+				fields[key] = fmt.Sprint(v)
+			}
+		}
+		if len(fields) == 0 {
+			fields = nil
+		}
+	}
+	Publish(Event{
+		Type:      TypeLog,
+		Time:      entry.Time,
+		RequestID: requestID,
+		Level:     entry.Level.String(),
+		Message:   entry.Message,
+		Fields:    fields,
+	})
+	return nil
+}
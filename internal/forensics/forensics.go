@@ -0,0 +1,236 @@
+// Package forensics captures a structured bundle for each API request and
+// persists it when the request fails, so an operator can later inspect the
+// original payload, every translation stage, the upstream exchanges and the
+// thinking adaptation by request ID.
+package forensics
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// ginRecorderKey is the Gin context key holding the request's Recorder.
+const ginRecorderKey = "__forensics_recorder__"
+
+// Stage is one snapshot of the request payload on its way upstream.
+type Stage struct {
+	Name      string    `json:"name"`
+	Format    string    `json:"format,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Payload   string    `json:"payload"`
+	Truncated bool      `json:"truncated,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// Exchange is one upstream HTTP attempt.
+type Exchange struct {
+	Method          string              `json:"method"`
+	URL             string              `json:"url"`
+	RequestHeaders  map[string][]string `json:"request_headers,omitempty"`
+	Status          int                 `json:"status,omitempty"`
+	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
+	Error           string              `json:"error,omitempty"`
+	StartedAt       time.Time           `json:"started_at"`
+	// HeadersMs is the time until the upstream response headers arrived.
+	HeadersMs int64 `json:"headers_ms"`
+}
+
+// Adaptation records how the requested thinking strength was adapted for one
+// upstream model.
+type Adaptation struct {
+	Provider      string `json:"provider,omitempty"`
+	Model         string `json:"model,omitempty"`
+	VariantOrigin string `json:"variant_origin,omitempty"`
+	Variant       string `json:"variant,omitempty"`
+	Decision      string `json:"decision"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// Calibration records a sampling value re-mapped by a payload calibration
+// rule for one upstream model.
+type Calibration struct {
+	Provider  string  `json:"provider,omitempty"`
+	Model     string  `json:"model,omitempty"`
+	Param     string  `json:"param"`
+	Requested float64 `json:"requested"`
+	Applied   float64 `json:"applied"`
+}
+
+// Bundle is the persisted forensic record of a failed request.
+type Bundle struct {
+	RequestID    string              `json:"request_id"`
+	Method       string              `json:"method"`
+	URL          string              `json:"url"`
+	Headers      map[string][]string `json:"headers,omitempty"`
+	Status       int                 `json:"status"`
+	Errors       []string            `json:"errors,omitempty"`
+	StartedAt    time.Time           `json:"started_at"`
+	DurationMs   int64               `json:"duration_ms"`
+	Stages       []Stage             `json:"stages"`
+	Upstream     []Exchange          `json:"upstream,omitempty"`
+	Adaptations  []Adaptation        `json:"adaptations,omitempty"`
+	Calibrations []Calibration       `json:"calibrations,omitempty"`
+}
+
+// Recorder accumulates the bundle for a single request. All methods are safe
+// on a nil Recorder, so call sites need not check whether capture is enabled.
+type Recorder struct {
+	mu           sync.Mutex
+	bundle       Bundle
+	maxBodyBytes int
+}
+
+// Attach stores rec in the Gin context so executors running the request can
+// find it with FromContext.
+func Attach(c *gin.Context, rec *Recorder) {
+	if c != nil && rec != nil {
+		c.Set(ginRecorderKey, rec)
+	}
+}
+
+// FromGin returns the Recorder attached to c, or nil.
+func FromGin(c *gin.Context) *Recorder {
+	if c == nil {
+		return nil
+	}
+	if v, ok := c.Get(ginRecorderKey); ok {
+		if rec, okRec := v.(*Recorder); okRec {
+			return rec
+		}
+	}
+	return nil
+}
+
+// FromContext returns the Recorder of the API request that ctx belongs to, or nil.
+func FromContext(ctx context.Context) *Recorder {
+	if ctx == nil {
+		return nil
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	return FromGin(ginCtx)
+}
+
+// AddStage records payload under name. Payloads above the configured limit
+// are truncated.
+func (r *Recorder) AddStage(name, format, model string, payload []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stage := Stage{Name: name, Format: format, Model: model, At: time.Now()}
+	if r.maxBodyBytes > 0 && len(payload) > r.maxBodyBytes {
+		payload = payload[:r.maxBodyBytes]
+		stage.Truncated = true
+	}
+	stage.Payload = string(payload)
+	r.bundle.Stages = append(r.bundle.Stages, stage)
+}
+
+// AddAdaptation records the thinking adaptation applied for model.
+func (r *Recorder) AddAdaptation(provider, model string, meta thinking.AdaptationMeta) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bundle.Adaptations = append(r.bundle.Adaptations, Adaptation{
+		Provider:      provider,
+		Model:         model,
+		VariantOrigin: meta.VariantOrigin,
+		Variant:       meta.Variant,
+		Decision:      meta.Decision,
+		Reason:        meta.Reason,
+	})
+}
+
+// AddCalibration records that param was sent to model as applied instead of
+// the requested value.
+func (r *Recorder) AddCalibration(provider, model, param string, requested, applied float64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bundle.Calibrations = append(r.bundle.Calibrations, Calibration{
+		Provider:  provider,
+		Model:     model,
+		Param:     param,
+		Requested: requested,
+		Applied:   applied,
+	})
+}
+
+// BeginExchange records an upstream request and returns its index for
+// FinishExchange.
+func (r *Recorder) BeginExchange(req *http.Request) int {
+	if r == nil || req == nil {
+		return -1
+	}
+	exchange := Exchange{
+		Method:         req.Method,
+		RequestHeaders: maskHeaders(req.Header),
+		StartedAt:      time.Now(),
+	}
+	if req.URL != nil {
+		u := *req.URL
+		u.RawQuery = util.MaskSensitiveQuery(u.RawQuery)
+		exchange.URL = u.String()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bundle.Upstream = append(r.bundle.Upstream, exchange)
+	return len(r.bundle.Upstream) - 1
+}
+
+// FinishExchange records the outcome of the upstream request at index.
+func (r *Recorder) FinishExchange(index int, resp *http.Response, err error) {
+	if r == nil || index < 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if index >= len(r.bundle.Upstream) {
+		return
+	}
+	exchange := &r.bundle.Upstream[index]
+	exchange.HeadersMs = time.Since(exchange.StartedAt).Milliseconds()
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+	if resp != nil {
+		exchange.Status = resp.StatusCode
+		exchange.ResponseHeaders = maskHeaders(resp.Header)
+	}
+}
+
+// finish closes the bundle with the downstream outcome and returns a copy.
+func (r *Recorder) finish(status int, errs []string) Bundle {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bundle.Status = status
+	r.bundle.Errors = errs
+	r.bundle.DurationMs = time.Since(r.bundle.StartedAt).Milliseconds()
+	return r.bundle
+}
+
+func maskHeaders(headers http.Header) map[string][]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		masked := make([]string, len(values))
+		for i, value := range values {
+			masked[i] = util.MaskSensitiveHeaderValue(key, value)
+		}
+		out[key] = masked
+	}
+	return out
+}
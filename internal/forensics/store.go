@@ -0,0 +1,233 @@
+package forensics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTTL          = 24 * time.Hour
+	defaultMaxBodyBytes = 1 << 20 // 1 MiB
+	sweepInterval       = 10 * time.Minute
+	bundleSuffix        = ".json"
+)
+
+// ErrInvalidRequestID is returned by Load for IDs that cannot name a bundle.
+var ErrInvalidRequestID = errors.New("forensics: invalid request ID")
+
+// Store persists bundles as one JSON file per request ID and removes them
+// once they outlive the configured TTL.
+type Store struct {
+	mu           sync.Mutex
+	enabled      bool
+	dir          string
+	ttl          time.Duration
+	maxBodyBytes int
+	stop         chan struct{}
+	done         chan struct{}
+	now          func() time.Time
+}
+
+// NewStore returns a disabled store; call Configure to enable it.
+func NewStore() *Store {
+	return &Store{now: time.Now}
+}
+
+var defaultStore = NewStore()
+
+// Default returns the store used by the server.
+func Default() *Store { return defaultStore }
+
+// Configure applies cfg to the default store, keeping bundles under dir.
+func Configure(cfg config.ForensicsConfig, dir string) { defaultStore.Configure(cfg, dir) }
+
+// Configure applies cfg, starting or stopping the TTL sweeper as needed.
+// Bundles already on disk are kept when capture is disabled.
+func (s *Store) Configure(cfg config.ForensicsConfig, dir string) {
+	ttl := time.Duration(cfg.TTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	maxBodyBytes := cfg.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+
+	s.mu.Lock()
+	wasEnabled := s.enabled
+	s.enabled, s.dir, s.ttl, s.maxBodyBytes = cfg.Enable, dir, ttl, maxBodyBytes
+	stop, done := s.stop, s.done
+	if wasEnabled != cfg.Enable {
+		s.stop, s.done = nil, nil
+	}
+	s.mu.Unlock()
+
+	if wasEnabled == cfg.Enable {
+		return
+	}
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	if cfg.Enable {
+		s.Sweep()
+		s.mu.Lock()
+		s.stop, s.done = make(chan struct{}), make(chan struct{})
+		go s.run(s.stop, s.done)
+		s.mu.Unlock()
+	}
+}
+
+func (s *Store) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
+
+// Enabled reports whether failed requests are being captured.
+func (s *Store) Enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled && s.dir != ""
+}
+
+// Enabled reports whether the default store captures failed requests.
+func Enabled() bool { return defaultStore.Enabled() }
+
+// Begin starts a Recorder for a request. It returns nil when capture is
+// disabled or the request has no ID.
+func (s *Store) Begin(requestID, method, url string, headers http.Header) *Recorder {
+	if requestID == "" || !s.Enabled() {
+		return nil
+	}
+	s.mu.Lock()
+	maxBodyBytes := s.maxBodyBytes
+	s.mu.Unlock()
+	return &Recorder{
+		maxBodyBytes: maxBodyBytes,
+		bundle: Bundle{
+			RequestID: requestID,
+			Method:    method,
+			URL:       url,
+			Headers:   maskHeaders(headers),
+			StartedAt: s.now(),
+		},
+	}
+}
+
+// Begin starts a Recorder on the default store.
+func Begin(requestID, method, url string, headers http.Header) *Recorder {
+	return defaultStore.Begin(requestID, method, url, headers)
+}
+
+// Save closes rec with the downstream status and errors and writes it to disk.
+func (s *Store) Save(rec *Recorder, status int, errs []string) error {
+	if rec == nil {
+		return nil
+	}
+	s.mu.Lock()
+	dir := s.dir
+	s.mu.Unlock()
+	if dir == "" {
+		return nil
+	}
+	bundle := rec.finish(status, errs)
+	data, errMarshal := json.MarshalIndent(bundle, "", "  ")
+	if errMarshal != nil {
+		return fmt.Errorf("forensics: marshal bundle: %w", errMarshal)
+	}
+	if errMkdir := os.MkdirAll(dir, 0o755); errMkdir != nil {
+		return fmt.Errorf("forensics: create directory: %w", errMkdir)
+	}
+	path := filepath.Join(dir, bundle.RequestID+bundleSuffix)
+	tmp := path + ".tmp"
+	if errWrite := os.WriteFile(tmp, data, 0o600); errWrite != nil {
+		return fmt.Errorf("forensics: write bundle: %w", errWrite)
+	}
+	if errRename := os.Rename(tmp, path); errRename != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("forensics: write bundle: %w", errRename)
+	}
+	return nil
+}
+
+// Save writes rec through the default store.
+func Save(rec *Recorder, status int, errs []string) error {
+	return defaultStore.Save(rec, status, errs)
+}
+
+// Load returns the raw JSON bundle stored for requestID. A missing or
+// expired bundle yields an error satisfying errors.Is(err, os.ErrNotExist).
+func (s *Store) Load(requestID string) ([]byte, error) {
+	requestID = strings.TrimSpace(requestID)
+	if requestID == "" || strings.ContainsAny(requestID, `/\.`) {
+		return nil, ErrInvalidRequestID
+	}
+	s.mu.Lock()
+	dir, ttl := s.dir, s.ttl
+	s.mu.Unlock()
+	if dir == "" {
+		return nil, os.ErrNotExist
+	}
+	path := filepath.Join(dir, requestID+bundleSuffix)
+	info, errStat := os.Stat(path)
+	if errStat != nil {
+		return nil, errStat
+	}
+	if s.now().Sub(info.ModTime()) > ttl {
+		_ = os.Remove(path)
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(path)
+}
+
+// Load reads a bundle from the default store.
+func Load(requestID string) ([]byte, error) { return defaultStore.Load(requestID) }
+
+// Sweep removes bundles older than the TTL.
+func (s *Store) Sweep() {
+	s.mu.Lock()
+	dir, ttl := s.dir, s.ttl
+	s.mu.Unlock()
+	if dir == "" {
+		return
+	}
+	entries, errRead := os.ReadDir(dir)
+	if errRead != nil {
+		if !os.IsNotExist(errRead) {
+			log.Warnf("forensics: failed to list %s: %v", dir, errRead)
+		}
+		return
+	}
+	cutoff := s.now().Add(-ttl)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), bundleSuffix) {
+			continue
+		}
+		info, errInfo := entry.Info()
+		if errInfo != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if errRemove := os.Remove(filepath.Join(dir, entry.Name())); errRemove != nil && !os.IsNotExist(errRemove) {
+			log.Warnf("forensics: failed to remove expired bundle %s: %v", entry.Name(), errRemove)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package imageprep downscales and re-encodes the base64 images embedded in
+// API requests before they are translated, because providers reject images
+// above their size limits and formats other than JPEG, PNG, GIF and WebP.
+package imageprep
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register GIF decoding
+	"image/jpeg"
+	"image/png"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	_ "golang.org/x/image/bmp" // register BMP decoding
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff" // register TIFF decoding
+	_ "golang.org/x/image/webp" // register WebP decoding
+)
+
+const (
+	defaultJPEGQuality = 85
+	// minDimension stops the size search from shrinking images further.
+	minDimension = 64
+	// convertTimeout bounds one run of the external convert command.
+	convertTimeout = 30 * time.Second
+	// maxDecodePixels caps the declared size of images that are decoded: a
+	// small file can declare dimensions whose pixels would take gigabytes.
+	maxDecodePixels = 64 << 20
+)
+
+// passthroughFormats are accepted by every provider and kept as they are
+// when the image is within the limits.
+var passthroughFormats = map[string]bool{"jpeg": true, "png": true, "gif": true, "webp": true}
+
+// Process rewrites every base64 image of payload that exceeds cfg's limits or
+// is in a format providers reject. It recognises data URLs (OpenAI chat and
+// Responses), Claude base64 sources and Gemini inline data, in any request
+// format. Images that cannot be processed are left unchanged.
+func Process(payload []byte, cfg config.ImagePreprocessConfig) []byte {
+	if !cfg.Enable || !gjson.ValidBytes(payload) || !bytes.Contains(payload, []byte("base64")) && !bytes.Contains(payload, []byte(`"data"`)) {
+		return payload
+	}
+	p := processor{cfg: cfg}
+	var edits []edit
+	p.walk("", gjson.ParseBytes(payload), &edits)
+	for _, e := range edits {
+		if updated, err := sjson.SetBytes(payload, e.path, e.value); err == nil {
+			payload = updated
+		}
+	}
+	return payload
+}
+
+type edit struct {
+	path  string
+	value string
+}
+
+type processor struct {
+	cfg config.ImagePreprocessConfig
+}
+
+func joinPath(prefix, key string) string {
+	key = strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`).Replace(key)
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func (p processor) walk(path string, value gjson.Result, edits *[]edit) {
+	switch {
+	case value.IsObject():
+		if p.object(path, value, edits) {
+			return
+		}
+		value.ForEach(func(key, child gjson.Result) bool {
+			p.walk(joinPath(path, key.String()), child, edits)
+			return true
+		})
+	case value.IsArray():
+		value.ForEach(func(key, child gjson.Result) bool {
+			p.walk(joinPath(path, key.String()), child, edits)
+			return true
+		})
+	case value.Type == gjson.String:
+		if url, ok := p.dataURL(value.String()); ok {
+			*edits = append(*edits, edit{path: path, value: url})
+		}
+	}
+}
+
+// object handles Claude base64 sources and Gemini inline data, reporting
+// whether value was one.
+func (p processor) object(path string, value gjson.Result, edits *[]edit) bool {
+	data := value.Get("data")
+	if data.Type != gjson.String {
+		return false
+	}
+	mimeKey := ""
+	for _, key := range []string{"media_type", "mimeType", "mime_type"} {
+		if strings.HasPrefix(value.Get(key).String(), "image/") {
+			mimeKey = key
+			break
+		}
+	}
+	if mimeKey == "" || mimeKey == "media_type" && value.Get("type").String() != "base64" {
+		return false
+	}
+	raw, err := base64.StdEncoding.DecodeString(data.String())
+	if err != nil {
+		return true
+	}
+	out, mimeType, changed := p.convert(raw, value.Get(mimeKey).String())
+	if changed {
+		*edits = append(*edits,
+			edit{path: joinPath(path, "data"), value: base64.StdEncoding.EncodeToString(out)},
+			edit{path: joinPath(path, mimeKey), value: mimeType},
+		)
+	}
+	return true
+}
+
+// dataURL returns the processed form of a base64 image data URL.
+func (p processor) dataURL(value string) (string, bool) {
+	rest, ok := strings.CutPrefix(value, "data:image/")
+	if !ok {
+		return "", false
+	}
+	header, encoded, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return "", false
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	out, mimeType, changed := p.convert(raw, "image/"+strings.TrimSuffix(header, ";base64"))
+	if !changed {
+		return "", false
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(out), true
+}
+
+// convert returns data re-encoded to fit the configured limits, its MIME
+// type, and whether anything changed.
+func (p processor) convert(data []byte, mimeType string) ([]byte, string, bool) {
+	changed := false
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if len(p.cfg.ConvertCommand) == 0 {
+			return data, mimeType, false
+		}
+		converted, errConvert := p.runConvert(data)
+		if errConvert != nil {
+			log.Warnf("image preprocess: convert %s: %v", mimeType, errConvert)
+			return data, mimeType, false
+		}
+		if cfg, format, err = image.DecodeConfig(bytes.NewReader(converted)); err != nil {
+			log.Warnf("image preprocess: convert %s: output is not an image: %v", mimeType, err)
+			return data, mimeType, false
+		}
+		data, mimeType, changed = converted, "image/"+format, true
+	}
+	if passthroughFormats[format] && p.fits(cfg.Width, cfg.Height, len(data)) {
+		return data, mimeType, changed
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxDecodePixels {
+		log.Warnf("image preprocess: %s of %dx%d is too large to decode, left unchanged", mimeType, cfg.Width, cfg.Height)
+		return data, mimeType, changed
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data, mimeType, changed
+	}
+	out, outType, err := p.shrink(img, format)
+	if err != nil {
+		log.Warnf("image preprocess: re-encode %s: %v", mimeType, err)
+		return data, mimeType, changed
+	}
+	return out, outType, true
+}
+
+func (p processor) fits(width, height, size int) bool {
+	if p.cfg.MaxDimension > 0 && max(width, height) > p.cfg.MaxDimension {
+		return false
+	}
+	return p.cfg.MaxBytes <= 0 || size <= p.cfg.MaxBytes
+}
+
+// shrink scales img to the configured dimension and re-encodes it, lowering
+// the JPEG quality and then the dimensions until it fits the byte limit.
+// Images with transparency stay PNG while they fit.
+func (p processor) shrink(img image.Image, format string) ([]byte, string, error) {
+	longest := max(img.Bounds().Dx(), img.Bounds().Dy())
+	if p.cfg.MaxDimension > 0 && longest > p.cfg.MaxDimension {
+		longest = p.cfg.MaxDimension
+	}
+	quality := p.cfg.JPEGQuality
+	if quality <= 0 || quality > 100 {
+		quality = defaultJPEGQuality
+	}
+	var out []byte
+	var outType string
+	for {
+		scaled := scale(img, longest)
+		var err error
+		if (format == "png" || format == "gif") && !scaled.Opaque() {
+			out, outType, err = encodePNG(scaled)
+			if err == nil && (p.cfg.MaxBytes <= 0 || len(out) <= p.cfg.MaxBytes) {
+				return out, outType, nil
+			}
+		}
+		for q := quality; ; q -= 15 {
+			out, outType, err = encodeJPEG(scaled, q)
+			if err != nil {
+				return nil, "", err
+			}
+			if p.cfg.MaxBytes <= 0 || len(out) <= p.cfg.MaxBytes || q <= 40 {
+				break
+			}
+		}
+		if p.cfg.MaxBytes <= 0 || len(out) <= p.cfg.MaxBytes || longest <= minDimension {
+			return out, outType, nil
+		}
+		longest = max(longest*3/4, minDimension)
+	}
+}
+
+// scale returns img resized so its longest side is longest pixels.
+func scale(img image.Image, longest int) *image.RGBA {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if current := max(width, height); current > longest {
+		width = max(width*longest/current, 1)
+		height = max(height*longest/current, 1)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst
+}
+
+func encodePNG(img image.Image) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// encodeJPEG encodes img on a white background, since JPEG has no alpha.
+func encodeJPEG(img *image.RGBA, quality int) ([]byte, string, error) {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
+
+// runConvert pipes data through the configured convert command.
+func (p processor) runConvert(data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), convertTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.cfg.ConvertCommand[0], p.cfg.ConvertCommand[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
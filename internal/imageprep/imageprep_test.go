@@ -0,0 +1,132 @@
+package imageprep
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"golang.org/x/image/bmp"
+)
+
+// testImage returns an opaque noise image, which compresses poorly.
+func testImage(width, height int) image.Image {
+	rng := rand.New(rand.NewPCG(1, 2))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(rng.Uint32()), G: uint8(rng.Uint32()), B: uint8(rng.Uint32()), A: 255})
+		}
+	}
+	return img
+}
+
+func encodeTest(t *testing.T, encode func(*bytes.Buffer, image.Image) error, img image.Image) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func decodeConfig(t *testing.T, data string) (image.Config, string) {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		t.Fatalf("decode base64: %v", err)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("decode image: %v", err)
+	}
+	return cfg, format
+}
+
+func TestProcessDownscalesDataURL(t *testing.T) {
+	data := encodeTest(t, func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) }, testImage(400, 200))
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,` + data + `"}}]}]}`)
+
+	out := Process(payload, config.ImagePreprocessConfig{Enable: true, MaxDimension: 100})
+	url := gjson.GetBytes(out, "messages.0.content.0.image_url.url").String()
+	encoded, ok := strings.CutPrefix(url, "data:image/jpeg;base64,")
+	if !ok {
+		t.Fatalf("url = %.40s", url)
+	}
+	if cfg, _ := decodeConfig(t, encoded); cfg.Width != 100 || cfg.Height != 50 {
+		t.Fatalf("size = %dx%d", cfg.Width, cfg.Height)
+	}
+}
+
+func TestProcessConvertsClaudeBMP(t *testing.T) {
+	data := encodeTest(t, func(b *bytes.Buffer, img image.Image) error { return bmp.Encode(b, img) }, testImage(32, 32))
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/bmp","data":"` + data + `"}}]}]}`)
+
+	out := Process(payload, config.ImagePreprocessConfig{Enable: true})
+	source := gjson.GetBytes(out, "messages.0.content.0.source")
+	if source.Get("media_type").String() != "image/jpeg" {
+		t.Fatalf("media_type = %q", source.Get("media_type").String())
+	}
+	if _, format := decodeConfig(t, source.Get("data").String()); format != "jpeg" {
+		t.Fatalf("format = %q", format)
+	}
+}
+
+func TestProcessShrinksToByteLimit(t *testing.T) {
+	data := encodeTest(t, func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) }, testImage(256, 256))
+	payload := []byte(`{"contents":[{"parts":[{"inlineData":{"mimeType":"image/png","data":"` + data + `"}}]}]}`)
+
+	out := Process(payload, config.ImagePreprocessConfig{Enable: true, MaxBytes: 4000})
+	inline := gjson.GetBytes(out, "contents.0.parts.0.inlineData")
+	raw, _ := base64.StdEncoding.DecodeString(inline.Get("data").String())
+	if inline.Get("mimeType").String() != "image/jpeg" || len(raw) > 4000 {
+		t.Fatalf("mimeType = %q, %d bytes", inline.Get("mimeType").String(), len(raw))
+	}
+}
+
+func TestProcessKeepsImagesWithinLimits(t *testing.T) {
+	data := encodeTest(t, func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) }, testImage(16, 16))
+	payload := []byte(`{"contents":[{"parts":[{"inline_data":{"mime_type":"image/png","data":"` + data + `"}},{"text":"data:image/png;base64,not-an-image"}]}]}`)
+
+	out := Process(payload, config.ImagePreprocessConfig{Enable: true, MaxDimension: 64, MaxBytes: 1 << 20})
+	if !bytes.Equal(out, payload) {
+		t.Fatalf("payload changed: %s", out)
+	}
+	if got := Process(payload, config.ImagePreprocessConfig{MaxDimension: 1}); !bytes.Equal(got, payload) {
+		t.Fatal("disabled pre-processor changed the payload")
+	}
+}
+
+// headerOnlyPNG returns a PNG signature and IHDR chunk declaring width by
+// height pixels, with no image data.
+func headerOnlyPNG(width, height uint32) []byte {
+	ihdr := make([]byte, 0, 17)
+	ihdr = append(ihdr, "IHDR"...)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8-bit RGBA, no interlace
+	out := []byte("\x89PNG\r\n\x1a\n")
+	out = binary.BigEndian.AppendUint32(out, 13)
+	out = append(out, ihdr...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestProcessSkipsImagesTooLargeToDecode(t *testing.T) {
+	data := base64.StdEncoding.EncodeToString(headerOnlyPNG(60000, 60000))
+	if cfg, _ := decodeConfig(t, data); cfg.Width != 60000 {
+		t.Fatalf("declared width = %d", cfg.Width)
+	}
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,` + data + `"}}]}]}`)
+
+	if out := Process(payload, config.ImagePreprocessConfig{Enable: true, MaxDimension: 1024}); !bytes.Equal(out, payload) {
+		t.Fatalf("oversized image was rewritten: %.80s", out)
+	}
+}
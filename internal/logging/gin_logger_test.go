@@ -58,3 +58,41 @@ func TestGinLogrusRecoveryHandlesRegularPanic(t *testing.T) {
 		t.Fatalf("expected 500, got %d", recorder.Code)
 	}
 }
+
+func TestGinLogrusLoggerHonorsClientRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(GinLogrusLogger())
+	var seen string
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		seen = GetRequestID(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		clientID string
+		keep     bool
+	}{
+		{name: "valid client id is kept", clientID: "client-trace_01", keep: true},
+		{name: "unsafe client id is replaced", clientID: "../etc/passwd", keep: false},
+		{name: "missing client id is generated", clientID: "", keep: false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if tt.clientID != "" {
+			req.Header.Set(RequestIDHeader, tt.clientID)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, req)
+
+		got := recorder.Header().Get(RequestIDHeader)
+		if got == "" || got != seen {
+			t.Fatalf("%s: response id %q, context id %q", tt.name, got, seen)
+		}
+		if (got == tt.clientID) != tt.keep {
+			t.Fatalf("%s: request id = %q", tt.name, got)
+		}
+	}
+}
@@ -87,7 +87,7 @@ func SetupBaseLogger() {
 		log.SetOutput(os.Stdout)
 		log.SetLevel(log.InfoLevel)
 		log.SetReportCaller(true)
-		log.SetFormatter(&LogFormatter{})
+		log.SetFormatter(&componentFormatter{})
 
 		ginInfoWriter = log.StandardLogger().Writer()
 		gin.DefaultWriter = ginInfoWriter
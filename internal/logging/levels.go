@@ -0,0 +1,192 @@
+package logging
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Components whose log level can be set on its own.
+const (
+	ComponentExecutor   = "executor"
+	ComponentTranslator = "translator"
+	ComponentThinking   = "thinking"
+	ComponentManagement = "management"
+)
+
+// componentField lets an entry name its component explicitly.
+const componentField = "component"
+
+const modulePath = "github.com/router-for-me/CLIProxyAPI/v6/"
+
+// componentPackages maps package paths, and the packages below them, to
+// their component.
+var componentPackages = []struct {
+	pkg       string
+	component string
+}{
+	{modulePath + "internal/runtime/executor", ComponentExecutor},
+	{modulePath + "internal/translator", ComponentTranslator},
+	{modulePath + "internal/thinking", ComponentThinking},
+	{modulePath + "internal/api/handlers/management", ComponentManagement},
+}
+
+// logSettings is the level and format state read by every log entry.
+type logSettings struct {
+	base       log.Level
+	components map[string]log.Level
+	json       bool
+}
+
+var currentSettings atomic.Pointer[logSettings]
+
+func init() {
+	currentSettings.Store(&logSettings{base: log.InfoLevel})
+}
+
+var jsonFormatter = &log.JSONFormatter{
+	TimestampFormat: time.RFC3339Nano,
+	CallerPrettyfier: func(frame *runtime.Frame) (string, string) {
+		return "", fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+	},
+	FieldMap: log.FieldMap{log.FieldKeyFile: "caller"},
+}
+
+// componentFormatter drops entries above the level of their component and
+// renders the rest as text or JSON.
+type componentFormatter struct {
+	text LogFormatter
+}
+
+func (f *componentFormatter) Format(entry *log.Entry) ([]byte, error) {
+	settings := currentSettings.Load()
+	component := entryComponent(entry)
+	level, ok := settings.components[component]
+	if !ok {
+		level = settings.base
+	}
+	if entry.Level > level {
+		return nil, nil
+	}
+	if !settings.json {
+		return f.text.Format(entry)
+	}
+	if component != "" {
+		if _, set := entry.Data[componentField]; !set {
+			data := make(log.Fields, len(entry.Data)+1)
+			for key, value := range entry.Data {
+				data[key] = value
+			}
+			data[componentField] = component
+			tagged := *entry
+			tagged.Data = data
+			entry = &tagged
+		}
+	}
+	return jsonFormatter.Format(entry)
+}
+
+// entryComponent returns the component named by the entry's component field
+// or, failing that, the one owning the calling package.
+func entryComponent(entry *log.Entry) string {
+	if component, ok := entry.Data[componentField].(string); ok {
+		return component
+	}
+	if entry.Caller == nil {
+		return ""
+	}
+	return packageComponent(entry.Caller.Function)
+}
+
+// packageComponent maps a fully qualified function name to its component.
+func packageComponent(function string) string {
+	// Type arguments of generic functions may contain package paths.
+	pkg, _, _ := strings.Cut(function, "[")
+	if slash := strings.LastIndex(pkg, "/"); slash >= 0 {
+		if dot := strings.Index(pkg[slash:], "."); dot >= 0 {
+			pkg = pkg[:slash+dot]
+		}
+	}
+	for _, entry := range componentPackages {
+		if pkg == entry.pkg || strings.HasPrefix(pkg, entry.pkg+"/") {
+			return entry.component
+		}
+	}
+	return ""
+}
+
+// parseComponentLevels returns the component levels set in cfg; invalid
+// names are reported and ignored.
+func parseComponentLevels(cfg config.LogLevelsConfig) map[string]log.Level {
+	levels := make(map[string]log.Level)
+	for component, value := range map[string]string{
+		ComponentExecutor:   cfg.Executor,
+		ComponentTranslator: cfg.Translator,
+		ComponentThinking:   cfg.Thinking,
+		ComponentManagement: cfg.Management,
+	} {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		level, err := log.ParseLevel(value)
+		if err != nil {
+			log.Warnf("logging: ignoring %s level %q: %v", component, value, err)
+			continue
+		}
+		levels[component] = level
+	}
+	return levels
+}
+
+// SetLogLevel applies the global level chosen by debug, the component levels
+// and the log format of cfg. The logrus level is raised to the most verbose
+// of them so component entries reach the formatter, which filters them.
+func SetLogLevel(cfg *config.Config) {
+	settings := &logSettings{
+		base:       log.InfoLevel,
+		components: parseComponentLevels(cfg.Logging.Levels),
+		json:       strings.EqualFold(strings.TrimSpace(cfg.Logging.Format), config.LogFormatJSON),
+	}
+	if cfg.Debug {
+		settings.base = log.DebugLevel
+	}
+	effective := settings.base
+	for _, level := range settings.components {
+		if level > effective {
+			effective = level
+		}
+	}
+	previous := currentSettings.Swap(settings)
+	if previous.base != settings.base {
+		log.Infof("log level changed from %s to %s (debug=%t)", previous.base, settings.base, cfg.Debug)
+	}
+	if log.GetLevel() != effective {
+		log.SetLevel(effective)
+	}
+}
+
+// ComponentLevels returns the effective level of every component.
+func ComponentLevels() map[string]string {
+	settings := currentSettings.Load()
+	levels := make(map[string]string, len(componentPackages))
+	for _, entry := range componentPackages {
+		level, ok := settings.components[entry.component]
+		if !ok {
+			level = settings.base
+		}
+		levels[entry.component] = level.String()
+	}
+	return levels
+}
+
+// BaseLevel returns the level of logs outside the components.
+func BaseLevel() string {
+	return currentSettings.Load().base.String()
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestPackageComponent(t *testing.T) {
+	cases := map[string]string{
+		modulePath + "internal/runtime/executor.(*ClaudeExecutor).Execute":            ComponentExecutor,
+		modulePath + "internal/translator/claude/gemini.ConvertGeminiRequestToClaude": ComponentTranslator,
+		modulePath + "internal/thinking.ApplyThinking.func1":                          ComponentThinking,
+		modulePath + "internal/api/handlers/management.(*Handler).PutDebug":           ComponentManagement,
+		modulePath + "internal/api.(*Server).UpdateClients":                           "",
+		modulePath + "internal/runtime/executorx.Run":                                 "",
+		modulePath + "internal/api.run[" + modulePath + "internal/thinking.Config]":   "",
+	}
+	for function, want := range cases {
+		if got := packageComponent(function); got != want {
+			t.Errorf("packageComponent(%q) = %q, want %q", function, got, want)
+		}
+	}
+}
+
+func newTestLogger(out *bytes.Buffer) *log.Logger {
+	logger := log.New()
+	logger.SetOutput(out)
+	logger.SetFormatter(&componentFormatter{})
+	logger.SetReportCaller(true)
+	logger.SetLevel(log.GetLevel())
+	return logger
+}
+
+func TestComponentLevelsFilterEntries(t *testing.T) {
+	saved, savedLevel := currentSettings.Load(), log.GetLevel()
+	t.Cleanup(func() {
+		currentSettings.Store(saved)
+		log.SetLevel(savedLevel)
+	})
+
+	SetLogLevel(&config.Config{Logging: config.LoggingConfig{Levels: config.LogLevelsConfig{Executor: "debug", Thinking: "error"}}})
+	if log.GetLevel() != log.DebugLevel {
+		t.Fatalf("logrus level = %s, want debug so executor entries pass", log.GetLevel())
+	}
+
+	var out bytes.Buffer
+	logger := newTestLogger(&out)
+	logger.WithField(componentField, ComponentExecutor).Debug("executor detail")
+	logger.WithField(componentField, ComponentThinking).Warn("thinking warning")
+	logger.Debug("other detail")
+	logger.Info("other info")
+
+	got := out.String()
+	for _, want := range []string{"executor detail", "other info"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"thinking warning", "other detail"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("unexpected %q in:\n%s", unwanted, got)
+		}
+	}
+	if levels := ComponentLevels(); levels[ComponentExecutor] != "debug" || levels[ComponentTranslator] != "info" || levels[ComponentThinking] != "error" {
+		t.Fatalf("ComponentLevels() = %v", levels)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	saved, savedLevel := currentSettings.Load(), log.GetLevel()
+	t.Cleanup(func() {
+		currentSettings.Store(saved)
+		log.SetLevel(savedLevel)
+	})
+	SetLogLevel(&config.Config{Logging: config.LoggingConfig{Format: "json"}})
+
+	var out bytes.Buffer
+	logger := newTestLogger(&out)
+	logger.WithFields(log.Fields{"request_id": "abc123", componentField: ComponentTranslator}).Warn("converted")
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if entry["msg"] != "converted" || entry["level"] != "warning" || entry["request_id"] != "abc123" || entry["component"] != ComponentTranslator {
+		t.Fatalf("entry = %v", entry)
+	}
+	if caller, _ := entry["caller"].(string); !strings.HasPrefix(caller, "levels_test.go:") {
+		t.Fatalf("caller = %v", entry["caller"])
+	}
+}
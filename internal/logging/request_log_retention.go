@@ -0,0 +1,168 @@
+package logging
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// requestLogCompressAfter is how long a request log must stay unmodified
+// before it is compressed, so logs still being written or just fetched
+// through the management API stay plain.
+const requestLogCompressAfter = 10 * time.Minute
+
+// SetRetention applies the request-log retention policy, pruning the logs
+// directory in the background until the policy is cleared.
+func (l *FileRequestLogger) SetRetention(policy config.LogRetentionPolicy) {
+	l.retentionMu.Lock()
+	defer l.retentionMu.Unlock()
+	if l.retentionCancel != nil {
+		l.retentionCancel()
+		l.retentionCancel = nil
+	}
+	if policy == (config.LogRetentionPolicy{}) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l.retentionCancel = cancel
+	go runRequestLogRetention(ctx, l.logsDir, policy)
+}
+
+func runRequestLogRetention(ctx context.Context, dir string, policy config.LogRetentionPolicy) {
+	ticker := time.NewTicker(logDirCleanerInterval)
+	defer ticker.Stop()
+	for {
+		compressed, removed, errPrune := pruneRequestLogs(dir, policy, time.Now())
+		if errPrune != nil {
+			log.WithError(errPrune).Warn("logging: failed to apply request log retention")
+		} else if compressed > 0 || removed > 0 {
+			log.Debugf("logging: request log retention compressed %d and removed %d file(s)", compressed, removed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// isRequestLogFile reports whether name is a per-request log, plain or
+// compressed, rather than the application log or one of its backups.
+func isRequestLogFile(name string) bool {
+	if name == "main.log" || strings.HasPrefix(name, "main-") {
+		return false
+	}
+	return strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")
+}
+
+// pruneRequestLogs compresses finished request logs in dir and removes those
+// past the age, count and total size limits of policy.
+func pruneRequestLogs(dir string, policy config.LogRetentionPolicy, now time.Time) (compressed, removed int, err error) {
+	entries, errRead := os.ReadDir(dir)
+	if errRead != nil {
+		if os.IsNotExist(errRead) {
+			return 0, 0, nil
+		}
+		return 0, 0, errRead
+	}
+
+	type logFile struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var files []logFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isRequestLogFile(entry.Name()) {
+			continue
+		}
+		info, errInfo := entry.Info()
+		if errInfo != nil {
+			continue
+		}
+		file := logFile{name: entry.Name(), size: info.Size(), modTime: info.ModTime()}
+		if policy.MaxAgeDays > 0 && now.Sub(file.modTime) > time.Duration(policy.MaxAgeDays)*24*time.Hour {
+			if errRemove := os.Remove(filepath.Join(dir, file.name)); errRemove == nil {
+				removed++
+			}
+			continue
+		}
+		if policy.Compress && strings.HasSuffix(file.name, ".log") && now.Sub(file.modTime) > requestLogCompressAfter {
+			size, errCompress := compressLogFile(filepath.Join(dir, file.name), file.modTime)
+			if errCompress != nil {
+				log.WithError(errCompress).Warnf("logging: failed to compress request log %s", file.name)
+			} else {
+				file.name += ".gz"
+				file.size = size
+				compressed++
+			}
+		}
+		files = append(files, file)
+	}
+
+	// Newest first, so the limits below keep the most recent logs.
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	var total int64
+	maxBytes := int64(policy.MaxSizeMB) * 1024 * 1024
+	for i, file := range files {
+		total += file.size
+		overCount := policy.MaxFiles > 0 && i >= policy.MaxFiles
+		overSize := maxBytes > 0 && total > maxBytes
+		if !overCount && !overSize {
+			continue
+		}
+		if errRemove := os.Remove(filepath.Join(dir, file.name)); errRemove != nil {
+			log.WithError(errRemove).Warnf("logging: failed to remove request log %s", file.name)
+			continue
+		}
+		total -= file.size
+		removed++
+	}
+	return compressed, removed, nil
+}
+
+// compressLogFile replaces path with path.gz, keeping its modification time
+// so age-based retention still sees when the request ran.
+func compressLogFile(path string, modTime time.Time) (int64, error) {
+	src, errOpen := os.Open(path)
+	if errOpen != nil {
+		return 0, errOpen
+	}
+	defer func() { _ = src.Close() }()
+
+	target := path + ".gz"
+	dst, errCreate := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if errCreate != nil {
+		return 0, errCreate
+	}
+	zw := gzip.NewWriter(dst)
+	_, errCopy := io.Copy(zw, src)
+	if errClose := zw.Close(); errCopy == nil {
+		errCopy = errClose
+	}
+	if errClose := dst.Close(); errCopy == nil {
+		errCopy = errClose
+	}
+	if errCopy != nil {
+		_ = os.Remove(target)
+		return 0, errCopy
+	}
+	_ = os.Chtimes(target, modTime, modTime)
+	info, errStat := os.Stat(target)
+	if errStat != nil {
+		return 0, errStat
+	}
+	if errRemove := os.Remove(path); errRemove != nil {
+		_ = os.Remove(target)
+		return 0, errRemove
+	}
+	return info.Size(), nil
+}
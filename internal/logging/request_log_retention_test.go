@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestPruneRequestLogsCompressesFinishedLogs(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	finished := filepath.Join(dir, "v1-chat-completions-2026-01-01T000000-a.log")
+	recent := filepath.Join(dir, "v1-chat-completions-2026-01-01T000000-b.log")
+	writeLogFile(t, finished, 4096, now.Add(-time.Hour))
+	writeLogFile(t, recent, 4096, now.Add(-time.Minute))
+	writeLogFile(t, filepath.Join(dir, "main.log"), 4096, now.Add(-time.Hour))
+
+	compressed, removed, err := pruneRequestLogs(dir, config.LogRetentionPolicy{Compress: true}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if compressed != 1 || removed != 0 {
+		t.Fatalf("compressed %d, removed %d; want 1, 0", compressed, removed)
+	}
+	if _, err := os.Stat(finished); !os.IsNotExist(err) {
+		t.Fatalf("expected the finished log to be replaced, stat error: %v", err)
+	}
+	for _, path := range []string{recent, filepath.Join(dir, "main.log")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %s to stay plain: %v", filepath.Base(path), err)
+		}
+	}
+
+	file, err := os.Open(finished + ".gz")
+	if err != nil {
+		t.Fatalf("open compressed log: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil || len(data) != 4096 {
+		t.Fatalf("decompressed %d bytes, err %v", len(data), err)
+	}
+	info, _ := file.Stat()
+	if info.ModTime().After(now.Add(-50 * time.Minute)) {
+		t.Fatalf("compressed log lost its modification time: %v", info.ModTime())
+	}
+}
+
+func TestPruneRequestLogsAppliesLimits(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeLogFile(t, filepath.Join(dir, "expired.log"), 10, now.Add(-72*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "oldest.log.gz"), 10, now.Add(-4*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "older.log"), 10, now.Add(-3*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "newer.log"), 10, now.Add(-2*time.Hour))
+	writeLogFile(t, filepath.Join(dir, "newest.log"), 10, now.Add(-time.Hour))
+	writeLogFile(t, filepath.Join(dir, "main-2026-01-01T00-00-00.000.log"), 10, now.Add(-96*time.Hour))
+
+	_, removed, err := pruneRequestLogs(dir, config.LogRetentionPolicy{MaxAgeDays: 2, MaxFiles: 3}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("removed %d files, want 2", removed)
+	}
+	for _, name := range []string{"expired.log", "oldest.log.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, stat error: %v", name, err)
+		}
+	}
+	for _, name := range []string{"older.log", "newer.log", "newest.log", "main-2026-01-01T00-00-00.000.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s to remain: %v", name, err)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"compress/flate"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -135,6 +137,11 @@ type FileRequestLogger struct {
 
 	// errorLogsMaxFiles limits the number of error log files retained.
 	errorLogsMaxFiles int
+
+	// retentionMu guards retentionCancel, which stops the background pruning
+	// started by SetRetention.
+	retentionMu     sync.Mutex
+	retentionCancel context.CancelFunc
 }
 
 // NewFileRequestLogger creates a new file-based request logger.
@@ -465,7 +472,7 @@ func (l *FileRequestLogger) cleanupOldErrorLogs() error {
 			continue
 		}
 		name := entry.Name()
-		if !strings.HasPrefix(name, "error-") || !strings.HasSuffix(name, ".log") {
+		if !strings.HasPrefix(name, "error-") || !isRequestLogFile(name) {
 			continue
 		}
 		info, errInfo := entry.Info()
@@ -14,6 +14,13 @@ type requestIDKey struct{}
 // ginRequestIDKey is the Gin context key for request IDs.
 const ginRequestIDKey = "__request_id__"
 
+// RequestIDHeader carries the request ID from clients and back in responses.
+const RequestIDHeader = "X-Request-ID"
+
+// maxClientRequestIDLength bounds client-supplied IDs, which end up in log
+// file names.
+const maxClientRequestIDLength = 64
+
 // GenerateRequestID creates a new 8-character hex request ID.
 func GenerateRequestID() string {
 	b := make([]byte, 4)
@@ -23,6 +30,24 @@ func GenerateRequestID() string {
 	return hex.EncodeToString(b)
 }
 
+// ClientRequestID returns the client-supplied request ID when it is safe to
+// use as-is: at most 64 ASCII letters, digits, '-' or '_'. Any other value
+// yields an empty string so a fresh ID is generated instead.
+func ClientRequestID(raw string) string {
+	if raw == "" || len(raw) > maxClientRequestIDLength {
+		return ""
+	}
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9', ch == '-', ch == '_':
+		default:
+			return ""
+		}
+	}
+	return raw
+}
+
 // WithRequestID returns a new context with the request ID attached.
 func WithRequestID(ctx context.Context, requestID string) context.Context {
 	return context.WithValue(ctx, requestIDKey{}, requestID)
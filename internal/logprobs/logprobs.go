@@ -0,0 +1,184 @@
+// Package logprobs translates token log probability options and results
+// between the OpenAI Chat Completions, OpenAI Responses and Gemini schemas,
+// and strips the options for models that cannot return them.
+package logprobs
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ResponsesInclude is the Responses include entry that requests logprobs.
+const ResponsesInclude = "message.output_text.logprobs"
+
+// FromOpenAIChat reports whether an OpenAI Chat Completions request asks for
+// logprobs, and how many alternatives per token it wants.
+func FromOpenAIChat(root gjson.Result) (enabled bool, top int64) {
+	top = root.Get("top_logprobs").Int()
+	return root.Get("logprobs").Bool() || top > 0, top
+}
+
+// FromResponses reports whether an OpenAI Responses request asks for logprobs,
+// either through top_logprobs or the include list.
+func FromResponses(root gjson.Result) (enabled bool, top int64) {
+	top = root.Get("top_logprobs").Int()
+	if top > 0 {
+		return true, top
+	}
+	for _, item := range root.Get("include").Array() {
+		if item.String() == ResponsesInclude {
+			return true, 0
+		}
+	}
+	return false, 0
+}
+
+// SetGemini writes the logprobs options under a Gemini generationConfig path.
+func SetGemini(out []byte, generationConfigPath string, top int64) []byte {
+	out, _ = sjson.SetBytes(out, generationConfigPath+".responseLogprobs", true)
+	if top > 0 {
+		out, _ = sjson.SetBytes(out, generationConfigPath+".logprobs", top)
+	}
+	return out
+}
+
+// OpenAIFromGemini converts a Gemini logprobsResult into an OpenAI Chat
+// Completions logprobs object. It returns "" when there is nothing to report.
+func OpenAIFromGemini(result gjson.Result) string {
+	chosen := result.Get("chosenCandidates").Array()
+	if len(chosen) == 0 {
+		return ""
+	}
+	topCandidates := result.Get("topCandidates").Array()
+	out := `{"content":[]}`
+	for i, candidate := range chosen {
+		entry := openAIEntry(candidate.Get("token").String(), candidate.Get("logProbability").Float())
+		entry, _ = sjson.SetRaw(entry, "top_logprobs", "[]")
+		if i < len(topCandidates) {
+			for _, alt := range topCandidates[i].Get("candidates").Array() {
+				altEntry := openAIEntry(alt.Get("token").String(), alt.Get("logProbability").Float())
+				entry, _ = sjson.SetRaw(entry, "top_logprobs.-1", altEntry)
+			}
+		}
+		out, _ = sjson.SetRaw(out, "content.-1", entry)
+	}
+	return out
+}
+
+// GeminiFromOpenAI converts the content array of an OpenAI logprobs object
+// into a Gemini logprobsResult. It returns "" when there is nothing to report.
+func GeminiFromOpenAI(content gjson.Result) string {
+	entries := content.Array()
+	if len(entries) == 0 {
+		return ""
+	}
+	out := `{"topCandidates":[],"chosenCandidates":[]}`
+	for _, entry := range entries {
+		out, _ = sjson.SetRaw(out, "chosenCandidates.-1", geminiCandidate(entry))
+		alternatives := `{"candidates":[]}`
+		for _, alt := range entry.Get("top_logprobs").Array() {
+			alternatives, _ = sjson.SetRaw(alternatives, "candidates.-1", geminiCandidate(alt))
+		}
+		out, _ = sjson.SetRaw(out, "topCandidates.-1", alternatives)
+	}
+	return out
+}
+
+// ResponsesFromOpenAI returns the Responses output_text logprobs array for the
+// content array of an OpenAI logprobs object. The entry shapes are identical.
+func ResponsesFromOpenAI(content gjson.Result) string {
+	if !content.IsArray() {
+		return "[]"
+	}
+	return content.Raw
+}
+
+func openAIEntry(token string, logprob float64) string {
+	entry := `{"token":"","logprob":0,"bytes":null}`
+	entry, _ = sjson.Set(entry, "token", token)
+	entry, _ = sjson.Set(entry, "logprob", logprob)
+	raw := []byte(token)
+	tokenBytes := make([]int, len(raw))
+	for i, b := range raw {
+		tokenBytes[i] = int(b)
+	}
+	entry, _ = sjson.Set(entry, "bytes", tokenBytes)
+	return entry
+}
+
+func geminiCandidate(entry gjson.Result) string {
+	candidate := `{"token":"","logProbability":0}`
+	candidate, _ = sjson.Set(candidate, "token", entry.Get("token").String())
+	candidate, _ = sjson.Set(candidate, "logProbability", entry.Get("logprob").Float())
+	return candidate
+}
+
+// fieldPaths lists the logprobs option fields of a provider schema.
+func fieldPaths(format string) []string {
+	switch format {
+	case "openai":
+		return []string{"logprobs", "top_logprobs"}
+	case "openai-response", "codex":
+		return []string{"top_logprobs"}
+	case "gemini":
+		return []string{"generationConfig.responseLogprobs", "generationConfig.logprobs"}
+	case "gemini-cli", "antigravity":
+		return []string{"request.generationConfig.responseLogprobs", "request.generationConfig.logprobs"}
+	default:
+		return nil
+	}
+}
+
+// Supported reports whether model may receive logprobs options. Models the
+// registry does not know and user-defined models are left to the upstream.
+func Supported(model, provider string) bool {
+	baseModel := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	if baseModel == "" {
+		baseModel = strings.TrimSpace(model)
+	}
+	info := registry.LookupModelInfo(baseModel, provider)
+	return info == nil || info.UserDefined || info.SupportsLogprobs
+}
+
+// Normalize removes logprobs options from body, already in the provider
+// format, when the target model cannot return them.
+func Normalize(body []byte, model, format, provider string) []byte {
+	paths := fieldPaths(format)
+	if len(paths) == 0 || !gjson.ValidBytes(body) || Supported(model, provider) {
+		return body
+	}
+	for _, path := range paths {
+		if gjson.GetBytes(body, path).Exists() {
+			body, _ = sjson.DeleteBytes(body, path)
+		}
+	}
+	if format == "openai-response" || format == "codex" {
+		body = dropResponsesInclude(body)
+	}
+	return body
+}
+
+func dropResponsesInclude(body []byte) []byte {
+	include := gjson.GetBytes(body, "include")
+	if !include.IsArray() {
+		return body
+	}
+	kept := make([]string, 0, len(include.Array()))
+	dropped := false
+	for _, item := range include.Array() {
+		if item.String() == ResponsesInclude {
+			dropped = true
+			continue
+		}
+		kept = append(kept, item.String())
+	}
+	if !dropped {
+		return body
+	}
+	body, _ = sjson.SetBytes(body, "include", kept)
+	return body
+}
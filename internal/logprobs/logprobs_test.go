@@ -0,0 +1,99 @@
+package logprobs
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+)
+
+func registerLogprobsModel(t *testing.T, provider string, info *registry.ModelInfo) {
+	t.Helper()
+	clientID := uuid.NewString()
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(clientID, provider, []*registry.ModelInfo{info})
+	t.Cleanup(func() { reg.UnregisterClient(clientID) })
+}
+
+func TestNormalizeStripsUnsupportedTargets(t *testing.T) {
+	registerLogprobsModel(t, "gemini-cli", &registry.ModelInfo{ID: "logprobs-test-cli"})
+
+	body := []byte(`{"request":{"generationConfig":{"responseLogprobs":true,"logprobs":3,"temperature":1}}}`)
+	out := Normalize(body, "logprobs-test-cli", "gemini-cli", "gemini-cli")
+	if gjson.GetBytes(out, "request.generationConfig.responseLogprobs").Exists() || gjson.GetBytes(out, "request.generationConfig.logprobs").Exists() {
+		t.Fatalf("logprobs options not stripped: %s", out)
+	}
+	if gjson.GetBytes(out, "request.generationConfig.temperature").Int() != 1 {
+		t.Fatalf("unrelated options lost: %s", out)
+	}
+
+	registerLogprobsModel(t, "codex", &registry.ModelInfo{ID: "logprobs-test-codex"})
+	out = Normalize([]byte(`{"top_logprobs":2,"include":["reasoning.encrypted_content","message.output_text.logprobs"]}`), "logprobs-test-codex", "codex", "codex")
+	if gjson.GetBytes(out, "top_logprobs").Exists() {
+		t.Fatalf("top_logprobs not stripped: %s", out)
+	}
+	if include := gjson.GetBytes(out, "include").Raw; include != `["reasoning.encrypted_content"]` {
+		t.Fatalf("include = %s", include)
+	}
+}
+
+func TestNormalizeKeepsSupportedAndUnknownTargets(t *testing.T) {
+	registerLogprobsModel(t, "gemini", &registry.ModelInfo{ID: "logprobs-test-gemini", SupportsLogprobs: true})
+	registerLogprobsModel(t, "openai-compatibility", &registry.ModelInfo{ID: "logprobs-test-compat", UserDefined: true})
+
+	body := []byte(`{"generationConfig":{"responseLogprobs":true}}`)
+	if out := Normalize(body, "logprobs-test-gemini", "gemini", "gemini"); string(out) != string(body) {
+		t.Fatalf("supported model rewritten: %s", out)
+	}
+	body = []byte(`{"logprobs":true,"top_logprobs":2}`)
+	if out := Normalize(body, "logprobs-test-compat", "openai", "openai-compatibility"); string(out) != string(body) {
+		t.Fatalf("user-defined model rewritten: %s", out)
+	}
+	if out := Normalize(body, "unknown-logprobs-model", "openai", "openai"); string(out) != string(body) {
+		t.Fatalf("unknown model rewritten: %s", out)
+	}
+}
+
+func TestRequestOptions(t *testing.T) {
+	if enabled, top := FromOpenAIChat(gjson.Parse(`{"logprobs":true,"top_logprobs":4}`)); !enabled || top != 4 {
+		t.Fatalf("chat = %t/%d", enabled, top)
+	}
+	if enabled, _ := FromOpenAIChat(gjson.Parse(`{"logprobs":false}`)); enabled {
+		t.Fatal("logprobs=false must not enable")
+	}
+	if enabled, top := FromResponses(gjson.Parse(`{"include":["message.output_text.logprobs"]}`)); !enabled || top != 0 {
+		t.Fatalf("responses include = %t/%d", enabled, top)
+	}
+
+	out := SetGemini([]byte(`{}`), "generationConfig", 2)
+	if !gjson.GetBytes(out, "generationConfig.responseLogprobs").Bool() || gjson.GetBytes(out, "generationConfig.logprobs").Int() != 2 {
+		t.Fatalf("gemini options = %s", out)
+	}
+}
+
+func TestGeminiOpenAIRoundTrip(t *testing.T) {
+	gemini := gjson.Parse(`{"chosenCandidates":[{"token":"Hi","logProbability":-0.5}],"topCandidates":[{"candidates":[{"token":"Hi","logProbability":-0.5},{"token":"Hello","logProbability":-1.25}]}]}`)
+
+	openai := OpenAIFromGemini(gemini)
+	for path, want := range map[string]string{
+		"content.0.token":                  "Hi",
+		"content.0.logprob":                "-0.5",
+		"content.0.bytes":                  "[72,105]",
+		"content.0.top_logprobs.1.token":   "Hello",
+		"content.0.top_logprobs.1.logprob": "-1.25",
+	} {
+		if got := gjson.Get(openai, path).String(); got != want {
+			t.Fatalf("%s = %s, want %s", path, got, want)
+		}
+	}
+
+	back := gjson.Parse(GeminiFromOpenAI(gjson.Get(openai, "content")))
+	if back.Get("chosenCandidates.0.token").String() != "Hi" || back.Get("topCandidates.0.candidates.1.logProbability").Float() != -1.25 {
+		t.Fatalf("round trip = %s", back.Raw)
+	}
+
+	if OpenAIFromGemini(gjson.Parse(`{}`)) != "" || GeminiFromOpenAI(gjson.Parse(`[]`)) != "" {
+		t.Fatal("expected empty conversions for missing logprobs")
+	}
+}
@@ -0,0 +1,191 @@
+// Package memguard fits the process to its container: it sets GOMAXPROCS and
+// the Go memory limit from configuration and the cgroup memory limit, and
+// watches memory use so the server can shed load instead of running out of
+// memory.
+package memguard
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// pressureHysteresis is how far below the pressure threshold memory use must
+// fall before pressure ends, so the state does not flap around it.
+const pressureHysteresis = 0.05
+
+// checkInterval is how often memory use is sampled.
+const checkInterval = time.Second
+
+// cgroupMemoryFiles are the cgroup v2 and v1 memory limit files.
+var cgroupMemoryFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+var (
+	mu           sync.Mutex
+	stop         chan struct{}
+	appliedLimit bool
+	defaultProcs = runtime.GOMAXPROCS(0)
+
+	pressure          atomic.Bool
+	retryAfterSeconds atomic.Int64
+)
+
+func init() {
+	retryAfterSeconds.Store(config.DefaultMemoryPressureRetryAfter)
+}
+
+// CgroupMemoryLimit returns the memory limit of the process's cgroup, or
+// false when it has none.
+func CgroupMemoryLimit() (int64, bool) {
+	for _, path := range cgroupMemoryFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "max" {
+			return 0, false
+		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		// cgroup v1 reports an unlimited group as a huge page-aligned value.
+		if err != nil || limit <= 0 || limit >= math.MaxInt64/2 {
+			return 0, false
+		}
+		return limit, true
+	}
+	return 0, false
+}
+
+// Configure applies resource-limits: GOMAXPROCS, the Go memory limit derived
+// from the cgroup limit unless GOMEMLIMIT is set, and the memory pressure
+// watcher.
+func Configure(cfg config.ResourceLimitsConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	procs := defaultProcs
+	if cfg.MaxProcs > 0 {
+		procs = cfg.MaxProcs
+	}
+	if runtime.GOMAXPROCS(0) != procs {
+		runtime.GOMAXPROCS(procs)
+		log.Infof("resource limits: GOMAXPROCS %d", procs)
+	}
+
+	if _, set := os.LookupEnv("GOMEMLIMIT"); !set {
+		ratio := cfg.MemoryLimitRatio
+		if ratio == 0 {
+			ratio = config.DefaultMemoryLimitRatio
+		}
+		if limit, ok := CgroupMemoryLimit(); ok && ratio > 0 && ratio <= 1 {
+			soft := int64(float64(limit) * ratio)
+			if debug.SetMemoryLimit(soft) != soft {
+				log.Infof("resource limits: memory limit %d MiB (%.0f%% of the cgroup limit)", soft>>20, ratio*100)
+			}
+			appliedLimit = true
+		} else if appliedLimit {
+			debug.SetMemoryLimit(math.MaxInt64)
+			appliedLimit = false
+		}
+	}
+
+	retryAfter := cfg.RetryAfterSeconds
+	if retryAfter <= 0 {
+		retryAfter = config.DefaultMemoryPressureRetryAfter
+	}
+	retryAfterSeconds.Store(int64(retryAfter))
+
+	if stop != nil {
+		close(stop)
+		stop = nil
+	}
+	pressure.Store(false)
+	if cfg.PressureRatio > 0 && cfg.PressureRatio < 1 {
+		stop = make(chan struct{})
+		go watch(cfg.PressureRatio, stop)
+	}
+}
+
+// UnderPressure reports whether memory use is past resource-limits
+// pressure-ratio of the memory limit.
+func UnderPressure() bool {
+	return pressure.Load()
+}
+
+// RetryAfterSeconds is the delay suggested to clients turned away under
+// memory pressure.
+func RetryAfterSeconds() int {
+	return int(retryAfterSeconds.Load())
+}
+
+// Usage returns the memory the Go runtime holds from the system, excluding
+// heap memory already returned to it.
+func Usage() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	for _, sample := range samples {
+		if sample.Value.Kind() != metrics.KindUint64 {
+			return 0
+		}
+	}
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+// Limit returns the memory limit pressure is measured against: the Go memory
+// limit, or the cgroup limit when no Go limit is set. ok is false without
+// either.
+func Limit() (limit int64, ok bool) {
+	if soft := debug.SetMemoryLimit(-1); soft < math.MaxInt64 {
+		return soft, true
+	}
+	return CgroupMemoryLimit()
+}
+
+func watch(ratio float64, stop <-chan struct{}) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			limit, ok := Limit()
+			if !ok {
+				pressure.Store(false)
+				continue
+			}
+			evaluate(Usage(), limit, ratio)
+		}
+	}
+}
+
+// evaluate updates the pressure state for usage out of limit.
+func evaluate(usage uint64, limit int64, ratio float64) {
+	share := float64(usage) / float64(limit)
+	switch {
+	case !pressure.Load() && share >= ratio:
+		pressure.Store(true)
+		log.Warnf("resource limits: memory pressure, %d of %d MiB in use; rejecting new requests", usage>>20, limit>>20)
+		// Give memory held by finished requests back before the next sample.
+		debug.FreeOSMemory()
+	case pressure.Load() && share < ratio-pressureHysteresis:
+		pressure.Store(false)
+		log.Infof("resource limits: memory pressure ended, %d of %d MiB in use", usage>>20, limit>>20)
+	}
+}
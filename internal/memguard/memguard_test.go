@@ -0,0 +1,71 @@
+package memguard
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func withCgroupFiles(t *testing.T, contents ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	saved := cgroupMemoryFiles
+	cgroupMemoryFiles = nil
+	for i, content := range contents {
+		path := filepath.Join(dir, "limit"+string(rune('0'+i)))
+		if content != "" {
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatalf("write %s: %v", path, err)
+			}
+		}
+		cgroupMemoryFiles = append(cgroupMemoryFiles, path)
+	}
+	t.Cleanup(func() { cgroupMemoryFiles = saved })
+}
+
+func TestCgroupMemoryLimit(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents []string
+		want     int64
+		ok       bool
+	}{
+		{"v2 limit", []string{"536870912\n", ""}, 536870912, true},
+		{"v2 unlimited", []string{"max\n", "1024"}, 0, false},
+		{"v1 limit", []string{"", "268435456\n"}, 268435456, true},
+		{"v1 unlimited", []string{"", "9223372036854771712\n"}, 0, false},
+		{"no cgroup", []string{"", ""}, 0, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			withCgroupFiles(t, tc.contents...)
+			got, ok := CgroupMemoryLimit()
+			if got != tc.want || ok != tc.ok {
+				t.Fatalf("CgroupMemoryLimit() = %d, %t; want %d, %t", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestEvaluateHysteresis(t *testing.T) {
+	pressure.Store(false)
+	t.Cleanup(func() { pressure.Store(false) })
+
+	const limit = 1000
+	steps := []struct {
+		usage uint64
+		want  bool
+	}{
+		{700, false},
+		{850, true},
+		{820, true}, // within the hysteresis band
+		{790, false},
+		{840, false},
+	}
+	for _, step := range steps {
+		evaluate(step.usage, limit, 0.85)
+		if UnderPressure() != step.want {
+			t.Fatalf("usage %d: UnderPressure() = %t, want %t", step.usage, UnderPressure(), step.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package proxyproto accepts connections that start with a PROXY protocol
+// (v1 text or v2 binary) header, as sent by load balancers such as HAProxy,
+// AWS NLB and nginx stream, and reports the original client address as the
+// connection's remote address.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHeaderTimeout bounds how long a new connection may take to send its
+// header.
+const DefaultHeaderTimeout = 10 * time.Second
+
+// v1MaxLength is the longest valid v1 header, CRLF included.
+const v1MaxLength = 107
+
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ErrNoHeader is returned by reads on a connection that did not start with a
+// PROXY protocol header.
+var ErrNoHeader = errors.New("proxyproto: missing PROXY protocol header")
+
+// Listener wraps a listener whose connections carry a PROXY protocol header.
+// The header is parsed on the first Read or RemoteAddr call, in the goroutine
+// serving the connection, so slow clients do not stall Accept.
+type Listener struct {
+	net.Listener
+	// HeaderTimeout bounds reading the header; zero uses DefaultHeaderTimeout.
+	HeaderTimeout time.Duration
+}
+
+// NewListener wraps inner.
+func NewListener(inner net.Listener) *Listener {
+	return &Listener{Listener: inner}
+}
+
+// Accept waits for the next connection.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	timeout := l.HeaderTimeout
+	if timeout <= 0 {
+		timeout = DefaultHeaderTimeout
+	}
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+// Conn is a connection whose remote address comes from its PROXY header.
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+// Read reads past the header. It fails when the header is missing or invalid.
+func (c *Conn) Read(p []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(p)
+}
+
+// RemoteAddr returns the client address from the header, or the peer address
+// for LOCAL and UNKNOWN headers.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) readHeader() {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	c.remote, c.err = parseHeader(c.reader)
+	_ = c.Conn.SetReadDeadline(time.Time{})
+	if c.err != nil {
+		_ = c.Conn.Close()
+	}
+}
+
+// parseHeader consumes a v1 or v2 header from r and returns the source
+// address it carries, nil when it carries none.
+func parseHeader(r *bufio.Reader) (net.Addr, error) {
+	peek, err := r.Peek(len(v2Signature))
+	if err != nil {
+		return nil, ErrNoHeader
+	}
+	switch {
+	case bytes.Equal(peek, v2Signature):
+		return parseV2(r)
+	case bytes.HasPrefix(peek, []byte("PROXY ")):
+		return parseV1(r)
+	default:
+		return nil, ErrNoHeader
+	}
+}
+
+func parseV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("proxyproto: read v1 header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("proxyproto: v1 header too long or not CRLF terminated")
+	}
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("proxyproto: malformed v1 header %q", string(line))
+	}
+	ip := net.ParseIP(fields[2])
+	port, errPort := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || errPort != nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("proxyproto: malformed v1 source %s:%s", fields[2], fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func parseV2(r *bufio.Reader) (net.Addr, error) {
+	var fixed [16]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, fmt.Errorf("proxyproto: read v2 header: %w", err)
+	}
+	verCmd, family := fixed[12], fixed[13]
+	length := int(binary.BigEndian.Uint16(fixed[14:16]))
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("proxyproto: unsupported v2 version %d", verCmd>>4)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("proxyproto: read v2 addresses: %w", err)
+	}
+	switch verCmd & 0x0f {
+	case 0x0: // LOCAL: health checks from the proxy itself.
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("proxyproto: unsupported v2 command %d", verCmd&0x0f)
+	}
+	switch family >> 4 {
+	case 0x1: // AF_INET
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("proxyproto: short v2 IPv4 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 0x2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("proxyproto: short v2 IPv6 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default: // AF_UNSPEC or AF_UNIX carry no usable client IP.
+		return nil, nil
+	}
+}
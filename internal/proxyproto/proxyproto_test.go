@@ -0,0 +1,91 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// acceptWith sends header and body through a Listener and returns the
+// accepted connection.
+func acceptWith(t *testing.T, payload []byte) net.Conn {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = inner.Close() })
+	listener := NewListener(inner)
+	listener.HeaderTimeout = time.Second
+
+	client, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	if _, err = client.Write(payload); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestConn_V1(t *testing.T) {
+	conn := acceptWith(t, []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\r\nGET / HTTP/1.1\r\n"))
+	if got := conn.RemoteAddr().String(); got != "203.0.113.7:51234" {
+		t.Fatalf("remote = %s", got)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "GET /" {
+		t.Fatalf("body = %q, %v", buf, err)
+	}
+}
+
+func TestConn_V1Unknown(t *testing.T) {
+	conn := acceptWith(t, []byte("PROXY UNKNOWN\r\nping"))
+	if got := conn.RemoteAddr().String(); got == "" || got[:9] != "127.0.0.1" {
+		t.Fatalf("remote = %s, want the peer address", got)
+	}
+}
+
+func TestConn_V2(t *testing.T) {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, 0x21, 0x21) // v2 PROXY, AF_INET6 STREAM
+	header = binary.BigEndian.AppendUint16(header, 36)
+	src := net.ParseIP("2001:db8::1")
+	dst := net.ParseIP("2001:db8::2")
+	header = append(header, src...)
+	header = append(header, dst...)
+	header = binary.BigEndian.AppendUint16(header, 40000)
+	header = binary.BigEndian.AppendUint16(header, 443)
+
+	conn := acceptWith(t, append(header, []byte("data")...))
+	if got := conn.RemoteAddr().String(); got != "[2001:db8::1]:40000" {
+		t.Fatalf("remote = %s", got)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "data" {
+		t.Fatalf("body = %q, %v", buf, err)
+	}
+}
+
+func TestConn_MissingHeader(t *testing.T) {
+	conn := acceptWith(t, []byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	if _, err := conn.Read(make([]byte, 4)); err != ErrNoHeader {
+		t.Fatalf("read error = %v, want ErrNoHeader", err)
+	}
+}
+
+func TestConn_MalformedV1(t *testing.T) {
+	conn := acceptWith(t, []byte("PROXY TCP4 not-an-ip 10.0.0.1 1 2\r\n"))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected error for a malformed header")
+	}
+}
@@ -0,0 +1,43 @@
+// Package ratelimit writes the X-RateLimit response headers that let clients
+// throttle themselves before the proxy or an upstream turns them away.
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Response headers describing the limit a request counted against.
+const (
+	LimitHeader     = "X-RateLimit-Limit"
+	RemainingHeader = "X-RateLimit-Remaining"
+	// ResetHeader is the number of seconds until the limit resets.
+	ResetHeader = "X-RateLimit-Reset"
+)
+
+// Headers lists the rate-limit response headers.
+var Headers = []string{LimitHeader, RemainingHeader, ResetHeader}
+
+// Set records a limit on headers, allocating them when nil. Remaining is
+// clamped to [0, limit]; a zero resetAt omits ResetHeader. Limits that are
+// not positive leave headers unchanged.
+func Set(headers http.Header, limit, remaining float64, resetAt, now time.Time) http.Header {
+	if limit <= 0 {
+		return headers
+	}
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	remaining = math.Max(0, math.Min(remaining, limit))
+	headers.Set(LimitHeader, strconv.FormatInt(int64(math.Round(limit)), 10))
+	headers.Set(RemainingHeader, strconv.FormatInt(int64(math.Floor(remaining)), 10))
+	if resetAt.IsZero() {
+		headers.Del(ResetHeader)
+		return headers
+	}
+	seconds := int64(math.Ceil(resetAt.Sub(now).Seconds()))
+	headers.Set(ResetHeader, strconv.FormatInt(max(seconds, 0), 10))
+	return headers
+}
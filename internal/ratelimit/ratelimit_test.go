@@ -0,0 +1,26 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSet(t *testing.T) {
+	now := time.Unix(1000, 0)
+	headers := Set(nil, 60, 12.7, now.Add(1500*time.Millisecond), now)
+	if headers.Get(LimitHeader) != "60" || headers.Get(RemainingHeader) != "12" || headers.Get(ResetHeader) != "2" {
+		t.Fatalf("headers = %v", headers)
+	}
+
+	headers = Set(headers, 10, 20, time.Time{}, now)
+	if headers.Get(RemainingHeader) != "10" || headers.Get(ResetHeader) != "" {
+		t.Fatalf("clamped headers = %v", headers)
+	}
+	if got := Set(http.Header{}, 10, -1, now.Add(-time.Second), now); got.Get(RemainingHeader) != "0" || got.Get(ResetHeader) != "0" {
+		t.Fatalf("exhausted headers = %v", got)
+	}
+	if got := Set(nil, 0, 5, now, now); got != nil {
+		t.Fatalf("zero limit set %v", got)
+	}
+}
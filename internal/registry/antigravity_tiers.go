@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"strings"
+)
+
+// Antigravity publishes some models as separate thinking tiers that share a
+// family prefix, e.g. "gemini-3.1-pro-high" and "gemini-3.1-pro-low".
+const (
+	AntigravityTierHigh = "high"
+	AntigravityTierLow  = "low"
+)
+
+// AntigravityTierModelID returns the tier-specific model ID for a tier family.
+func AntigravityTierModelID(family, tier string) string {
+	return family + "-" + tier
+}
+
+// AntigravityTierFamilyModels returns one synthetic tierless model for every
+// family that has both a high and a low tier in models. Families whose ID is
+// already present in models are skipped. Metadata is copied from the high tier.
+func AntigravityTierFamilyModels(models []*ModelInfo) []*ModelInfo {
+	if len(models) == 0 {
+		return nil
+	}
+	byID := make(map[string]*ModelInfo, len(models))
+	for _, model := range models {
+		if model == nil {
+			continue
+		}
+		id := strings.ToLower(strings.TrimSpace(model.ID))
+		if id != "" {
+			byID[id] = model
+		}
+	}
+
+	var out []*ModelInfo
+	for _, model := range models {
+		if model == nil {
+			continue
+		}
+		id := strings.TrimSpace(model.ID)
+		family, ok := strings.CutSuffix(strings.ToLower(id), "-"+AntigravityTierHigh)
+		if !ok || family == "" {
+			continue
+		}
+		if _, hasLow := byID[AntigravityTierModelID(family, AntigravityTierLow)]; !hasLow {
+			continue
+		}
+		if _, exists := byID[family]; exists {
+			continue
+		}
+		familyModel := cloneModelInfo(model)
+		familyModel.ID = id[:len(family)]
+		if familyModel.Name == id {
+			familyModel.Name = familyModel.ID
+		}
+		familyModel.DisplayName = strings.TrimSpace(strings.TrimSuffix(familyModel.DisplayName, "(High)"))
+		familyModel.Description = strings.TrimSpace(strings.TrimSuffix(familyModel.Description, "(High)"))
+		byID[family] = familyModel
+		out = append(out, familyModel)
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package registry
+
+import "testing"
+
+func TestAntigravityTierFamilyModels(t *testing.T) {
+	models := []*ModelInfo{
+		{ID: "gemini-3.1-pro-high", Name: "gemini-3.1-pro-high", DisplayName: "Gemini 3.1 Pro (High)", ContextLength: 1048576},
+		{ID: "gemini-3.1-pro-low", Name: "gemini-3.1-pro-low", DisplayName: "Gemini 3.1 Pro (Low)"},
+		{ID: "gpt-oss-120b-high"},
+		{ID: "gemini-3-pro-high"},
+		{ID: "gemini-3-pro-low"},
+		{ID: "gemini-3-pro"},
+	}
+
+	got := AntigravityTierFamilyModels(models)
+	if len(got) != 1 {
+		t.Fatalf("families = %d, want 1 (%v)", len(got), got)
+	}
+	family := got[0]
+	if family.ID != "gemini-3.1-pro" || family.Name != "gemini-3.1-pro" {
+		t.Fatalf("family id/name = %q/%q", family.ID, family.Name)
+	}
+	if family.DisplayName != "Gemini 3.1 Pro" {
+		t.Fatalf("display name = %q, want Gemini 3.1 Pro", family.DisplayName)
+	}
+	if family.ContextLength != 1048576 {
+		t.Fatalf("context length = %d, want copied from high tier", family.ContextLength)
+	}
+	if models[0].ID != "gemini-3.1-pro-high" {
+		t.Fatal("source model must not be mutated")
+	}
+}
@@ -33,6 +33,8 @@ type ModelInfo struct {
 	Name string `json:"name,omitempty"`
 	// Version is the model version
 	Version string `json:"version,omitempty"`
+	// Family groups related model revisions (e.g., "gpt-4o"), as reported by upstreams that expose it
+	Family string `json:"family,omitempty"`
 	// Description provides detailed information about the model
 	Description string `json:"description,omitempty"`
 	// InputTokenLimit is the maximum input token limit
@@ -54,6 +56,27 @@ type ModelInfo struct {
 	// SupportedOutputModalities lists supported output modalities (e.g., TEXT, IMAGE)
 	SupportedOutputModalities []string `json:"supportedOutputModalities,omitempty"`
 
+	// SupportsLogprobs reports that the upstream returns token log probabilities.
+	// Requests for other registered models have logprobs options stripped.
+	SupportsLogprobs bool `json:"supports_logprobs,omitempty"`
+
+	// SupportsSeed reports that the upstream samples deterministically for a
+	// fixed seed. Seeds sent to other registered models are dropped.
+	SupportsSeed bool `json:"supports_seed,omitempty"`
+
+	// SupportsPDF reports that the upstream reads PDF documents. PDFs sent to
+	// other registered models are replaced by their extracted text.
+	SupportsPDF bool `json:"supports_pdf,omitempty"`
+
+	// StreamMode restricts the upstream call style: StreamModeNonStream or
+	// StreamModeStream. Requests in the other style are adapted by the proxy.
+	// Empty means the upstream supports both.
+	StreamMode string `json:"stream_mode,omitempty"`
+
+	// Tokenizer names the tokenizer used to count this model's tokens, overriding
+	// the one its family maps to (e.g. "o200k_base", "gemini-sentencepiece").
+	Tokenizer string `json:"tokenizer,omitempty"`
+
 	// Thinking holds provider-specific reasoning/thinking budget capabilities.
 	// This is optional and currently used for Gemini thinking budget normalization.
 	Thinking *ThinkingSupport `json:"thinking,omitempty"`
@@ -64,6 +87,14 @@ type ModelInfo struct {
 	UserDefined bool `json:"-"`
 }
 
+// Upstream stream modes for ModelInfo.StreamMode.
+const (
+	// StreamModeNonStream marks models whose upstream only answers non-streaming requests.
+	StreamModeNonStream = "non-stream"
+	// StreamModeStream marks models whose upstream only answers streaming requests.
+	StreamModeStream = "stream"
+)
+
 type availableModelsCacheEntry struct {
 	models    []map[string]any
 	expiresAt time.Time
@@ -759,6 +790,34 @@ func (r *ModelRegistry) ClientSupportsModel(clientID, modelID string) bool {
 	return false
 }
 
+// ClientModelInfo returns the model info registered by clientID for modelID,
+// falling back to the global definition. It returns nil when the client does
+// not serve the model.
+func (r *ModelRegistry) ClientModelInfo(clientID, modelID string) *ModelInfo {
+	clientID = strings.TrimSpace(clientID)
+	modelID = strings.TrimSpace(modelID)
+	if clientID == "" || modelID == "" {
+		return nil
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, id := range r.clientModels[clientID] {
+		if !strings.EqualFold(strings.TrimSpace(id), modelID) {
+			continue
+		}
+		if info, ok := r.clientModelInfos[clientID][id]; ok && info != nil {
+			return cloneModelInfo(info)
+		}
+		if reg, ok := r.models[id]; ok && reg != nil && reg.Info != nil {
+			return cloneModelInfo(reg.Info)
+		}
+		return nil
+	}
+	return nil
+}
+
 // GetAvailableModels returns all models that have at least one available client
 // Parameters:
 //   - handlerType: The handler type to filter models for (e.g., "openai", "claude", "gemini")
@@ -1170,6 +1229,9 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 		if model.Version != "" {
 			result["version"] = model.Version
 		}
+		if model.Family != "" {
+			result["family"] = model.Family
+		}
 		if model.Description != "" {
 			result["description"] = model.Description
 		}
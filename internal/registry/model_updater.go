@@ -142,6 +142,7 @@ func tryRefreshModels(ctx context.Context, label string) {
 
 	parsed, url := fetchModelsFromRemote(ctx)
 	if parsed != nil {
+		mergeEmbeddedCapabilities(parsed)
 		modelsCatalogStore.mu.Lock()
 		modelsCatalogStore.base = cloneStaticModels(parsed)
 		modelsCatalogStore.mu.Unlock()
@@ -173,6 +174,48 @@ func tryRefreshModels(ctx context.Context, label string) {
 	notifyModelRefresh(changed)
 }
 
+// embeddedCapabilities indexes the embedded catalog by section and model ID.
+var embeddedCapabilities = sync.OnceValue(func() map[string]map[string]*ModelInfo {
+	var parsed staticModelsJSON
+	if err := json.Unmarshal(embeddedModelsJSON, &parsed); err != nil {
+		return nil
+	}
+	index := make(map[string]map[string]*ModelInfo, len(staticModelSectionSpecs))
+	for _, spec := range staticModelSectionSpecs {
+		models := make(map[string]*ModelInfo)
+		for _, model := range spec.get(&parsed) {
+			if model != nil {
+				models[model.ID] = model
+			}
+		}
+		index[spec.key] = models
+	}
+	return index
+})
+
+// mergeEmbeddedCapabilities carries the capability flags of the embedded
+// catalog over to the same models of a fetched one. The remote feed does not
+// publish them, so without this every refreshed model would read as lacking
+// the capability.
+func mergeEmbeddedCapabilities(data *staticModelsJSON) {
+	index := embeddedCapabilities()
+	for _, spec := range staticModelSectionSpecs {
+		known := index[spec.key]
+		for _, model := range spec.get(data) {
+			if model == nil {
+				continue
+			}
+			embedded := known[model.ID]
+			if embedded == nil {
+				continue
+			}
+			model.SupportsPDF = model.SupportsPDF || embedded.SupportsPDF
+			model.SupportsLogprobs = model.SupportsLogprobs || embedded.SupportsLogprobs
+			model.SupportsSeed = model.SupportsSeed || embedded.SupportsSeed
+		}
+	}
+}
+
 func reloadFinalCatalogFromCurrentBase(reason string) ([]string, error) {
 	catalogRebuildMu.Lock()
 	defer catalogRebuildMu.Unlock()
@@ -203,6 +203,58 @@ func TestConcurrentCustomPathUpdateAndRefresh_KeepStateConsistent(t *testing.T)
 	}
 }
 
+func TestTryRefreshModels_KeepsEmbeddedCapabilityFlags(t *testing.T) {
+	resetModelUpdaterStateForTest(t)
+	var embedded staticModelsJSON
+	if err := json.Unmarshal(embeddedModelsJSON, &embedded); err != nil {
+		t.Fatalf("decode embedded catalog: %v", err)
+	}
+	claude := embedded.Claude[0]
+	if !claude.SupportsPDF {
+		t.Fatalf("embedded %s is not flagged supports_pdf", claude.ID)
+	}
+	var gemini *ModelInfo
+	for _, model := range embedded.Gemini {
+		if model.SupportsLogprobs {
+			gemini = model
+			break
+		}
+	}
+	if gemini == nil {
+		t.Fatal("no embedded gemini model is flagged supports_logprobs")
+	}
+	geminiCLI := embedded.GeminiCLI[0]
+	if !geminiCLI.SupportsSeed {
+		t.Fatalf("embedded %s is not flagged supports_seed", geminiCLI.ID)
+	}
+
+	// The remote feed lists the same model without any capability flags.
+	remote := buildValidCatalog("remote")
+	remote.Claude = []*ModelInfo{{ID: claude.ID, OwnedBy: "remote-owner"}}
+	remote.Gemini = []*ModelInfo{{ID: gemini.ID, OwnedBy: "remote-owner"}}
+	remote.GeminiCLI = []*ModelInfo{{ID: geminiCLI.ID, OwnedBy: "remote-owner"}}
+	remoteSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(remote)
+	}))
+	defer remoteSrv.Close()
+	oldURLs := modelsURLs
+	modelsURLs = []string{remoteSrv.URL}
+	t.Cleanup(func() { modelsURLs = oldURLs })
+
+	tryRefreshModels(context.Background(), "test-refresh")
+
+	got := getModels().Claude[0]
+	if got.OwnedBy != "remote-owner" || !got.SupportsPDF {
+		t.Fatalf("refreshed %s = %+v, want the remote entry with supports_pdf kept", claude.ID, got)
+	}
+	if got := getModels().Gemini[0]; !got.SupportsLogprobs {
+		t.Fatalf("refreshed %s = %+v, want supports_logprobs kept", gemini.ID, got)
+	}
+	if got := getModels().GeminiCLI[0]; !got.SupportsSeed {
+		t.Fatalf("refreshed %s = %+v, want supports_seed kept", geminiCLI.ID, got)
+	}
+}
+
 func resetModelUpdaterStateForTest(t *testing.T) {
 	t.Helper()
 	modelsCatalogStore.mu.Lock()
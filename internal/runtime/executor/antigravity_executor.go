@@ -24,6 +24,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embedding"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
@@ -188,6 +189,12 @@ func (e *AntigravityExecutor) Execute(ctx context.Context, auth *cliproxyauth.Au
 	if opts.Alt == "responses/compact" {
 		return resp, statusErr{code: http.StatusNotImplemented, msg: "/responses/compact not supported"}
 	}
+	if opts.Alt == "audio/speech" {
+		return resp, statusErr{code: http.StatusNotImplemented, msg: "/audio/speech not supported"}
+	}
+	if embedding.IsMethod(opts.Alt) {
+		return resp, embeddingNotSupported(opts.Alt)
+	}
 	baseModel := thinking.ParseSuffix(req.Model).ModelName
 	isClaude := strings.Contains(strings.ToLower(baseModel), "claude")
 
@@ -223,7 +230,7 @@ func (e *AntigravityExecutor) Execute(ctx context.Context, auth *cliproxyauth.Au
 	}
 
 	requestedModel := payloadRequestedModel(opts, req.Model)
-	translated = applyPayloadConfigWithRoot(e.cfg, baseModel, "antigravity", "request", translated, originalTranslated, requestedModel)
+	translated = applyPayloadConfigWithRoot(ctx, e.cfg, auth, baseModel, "antigravity", "request", translated, originalTranslated, requestedModel)
 
 	baseURLs := antigravityBaseURLFallbackOrder(auth)
 	httpClient := newAntigravityHTTPClient(ctx, e.cfg, auth, 0)
@@ -365,7 +372,7 @@ func (e *AntigravityExecutor) executeClaudeNonStream(ctx context.Context, auth *
 	}
 
 	requestedModel := payloadRequestedModel(opts, req.Model)
-	translated = applyPayloadConfigWithRoot(e.cfg, baseModel, "antigravity", "request", translated, originalTranslated, requestedModel)
+	translated = applyPayloadConfigWithRoot(ctx, e.cfg, auth, baseModel, "antigravity", "request", translated, originalTranslated, requestedModel)
 
 	baseURLs := antigravityBaseURLFallbackOrder(auth)
 	httpClient := newAntigravityHTTPClient(ctx, e.cfg, auth, 0)
@@ -725,6 +732,9 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 	if opts.Alt == "responses/compact" {
 		return nil, statusErr{code: http.StatusNotImplemented, msg: "/responses/compact not supported"}
 	}
+	if opts.Alt == "audio/speech" {
+		return nil, statusErr{code: http.StatusNotImplemented, msg: "/audio/speech not supported"}
+	}
 	baseModel := thinking.ParseSuffix(req.Model).ModelName
 
 	ctx = context.WithValue(ctx, "alt", "")
@@ -757,7 +767,7 @@ func (e *AntigravityExecutor) ExecuteStream(ctx context.Context, auth *cliproxya
 	}
 
 	requestedModel := payloadRequestedModel(opts, req.Model)
-	translated = applyPayloadConfigWithRoot(e.cfg, baseModel, "antigravity", "request", translated, originalTranslated, requestedModel)
+	translated = applyPayloadConfigWithRoot(ctx, e.cfg, auth, baseModel, "antigravity", "request", translated, originalTranslated, requestedModel)
 
 	baseURLs := antigravityBaseURLFallbackOrder(auth)
 	httpClient := newAntigravityHTTPClient(ctx, e.cfg, auth, 0)
@@ -895,7 +905,7 @@ attemptLoop:
 					reporter.ensurePublished(ctx)
 				}
 			}(httpResp)
-			return &cliproxyexecutor.StreamResult{Headers: httpResp.Header.Clone(), Chunks: out}, nil
+			return &cliproxyexecutor.StreamResult{Headers: httpResp.Header.Clone(), Chunks: reporter.trackStream(ctx, e.cfg, opts, out)}, nil
 		}
 
 		switch {
@@ -983,7 +993,7 @@ func (e *AntigravityExecutor) CountTokens(ctx context.Context, auth *cliproxyaut
 
 		var requestURL strings.Builder
 		requestURL.WriteString(base)
-		requestURL.WriteString(antigravityCountTokensPath)
+		requestURL.WriteString(antigravityVersionedPath(auth, antigravityCountTokensPath))
 		if opts.Alt != "" {
 			requestURL.WriteString("?$alt=")
 			requestURL.WriteString(url.QueryEscape(opts.Alt))
@@ -1226,7 +1236,7 @@ func (e *AntigravityExecutor) buildRequest(ctx context.Context, auth *cliproxyau
 	}
 	var requestURL strings.Builder
 	requestURL.WriteString(base)
-	requestURL.WriteString(path)
+	requestURL.WriteString(antigravityVersionedPath(auth, path))
 	if stream {
 		if alt != "" {
 			requestURL.WriteString("?$alt=")
@@ -1479,23 +1489,17 @@ func antigravityBaseURLFallbackOrder(auth *cliproxyauth.Auth) []string {
 }
 
 func resolveCustomAntigravityBaseURL(auth *cliproxyauth.Auth) string {
-	if auth == nil {
-		return ""
-	}
-	if auth.Attributes != nil {
-		if v := strings.TrimSpace(auth.Attributes["base_url"]); v != "" {
-			return strings.TrimSuffix(v, "/")
-		}
-	}
-	if auth.Metadata != nil {
-		if v, ok := auth.Metadata["base_url"].(string); ok {
-			v = strings.TrimSpace(v)
-			if v != "" {
-				return strings.TrimSuffix(v, "/")
-			}
-		}
+	return resolveAuthBaseURL(auth, "")
+}
+
+// antigravityVersionedPath swaps the Code Assist version prefix of path for the
+// auth's API version override, if any.
+func antigravityVersionedPath(auth *cliproxyauth.Auth, path string) string {
+	version := authEndpointOverride(auth, authAPIVersionKey)
+	if version == "" {
+		return path
 	}
-	return ""
+	return "/" + version + strings.TrimPrefix(path, "/"+codeAssistVersion)
 }
 
 func geminiToAntigravity(modelName string, payload []byte, projectID string) []byte {
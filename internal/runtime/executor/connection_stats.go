@@ -0,0 +1,195 @@
+package executor
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// ConnectionStats summarizes how the upstream requests of one provider got
+// their connections. A high reused-connections share means the cached
+// clients keep connections alive across requests and across auths that
+// share a proxy.
+type ConnectionStats struct {
+	Provider string `json:"provider"`
+	// Requests is the number of requests that obtained a connection.
+	Requests int64 `json:"requests"`
+	// NewConnections and ReusedConnections split Requests by whether the
+	// connection was dialed for the request or taken from the idle pool.
+	NewConnections    int64 `json:"new-connections"`
+	ReusedConnections int64 `json:"reused-connections"`
+	// ReuseRatio is ReusedConnections divided by Requests.
+	ReuseRatio float64 `json:"reuse-ratio"`
+	// AvgIdleMs is the mean time reused connections spent idle.
+	AvgIdleMs float64 `json:"avg-idle-ms"`
+
+	DNSLookups       int64   `json:"dns-lookups"`
+	AvgDNSMs         float64 `json:"avg-dns-ms"`
+	Dials            int64   `json:"dials"`
+	AvgDialMs        float64 `json:"avg-dial-ms"`
+	TLSHandshakes    int64   `json:"tls-handshakes"`
+	AvgTLSMs         float64 `json:"avg-tls-ms"`
+	FailedHandshakes int64   `json:"failed-tls-handshakes"`
+}
+
+// connectionCounters accumulates the httptrace events of one provider.
+type connectionCounters struct {
+	requests, reused, idleNanos           atomic.Int64
+	dns, dnsNanos                         atomic.Int64
+	dials, dialNanos                      atomic.Int64
+	handshakes, handshakeNanos, failedTLS atomic.Int64
+}
+
+var connectionStatsByProvider sync.Map // provider -> *connectionCounters
+
+func connectionCountersFor(provider string) *connectionCounters {
+	if counters, ok := connectionStatsByProvider.Load(provider); ok {
+		return counters.(*connectionCounters)
+	}
+	counters, _ := connectionStatsByProvider.LoadOrStore(provider, &connectionCounters{})
+	return counters.(*connectionCounters)
+}
+
+func averageMs(total, count int64) float64 {
+	if count == 0 {
+		return 0
+	}
+	return float64(total) / float64(count) / float64(time.Millisecond)
+}
+
+// UpstreamConnectionStats returns the connection statistics of every
+// provider that made upstream requests, sorted by provider.
+func UpstreamConnectionStats() []ConnectionStats {
+	var stats []ConnectionStats
+	connectionStatsByProvider.Range(func(key, value any) bool {
+		c := value.(*connectionCounters)
+		requests, reused := c.requests.Load(), c.reused.Load()
+		s := ConnectionStats{
+			Provider:          key.(string),
+			Requests:          requests,
+			NewConnections:    requests - reused,
+			ReusedConnections: reused,
+			AvgIdleMs:         averageMs(c.idleNanos.Load(), reused),
+			DNSLookups:        c.dns.Load(),
+			AvgDNSMs:          averageMs(c.dnsNanos.Load(), c.dns.Load()),
+			Dials:             c.dials.Load(),
+			AvgDialMs:         averageMs(c.dialNanos.Load(), c.dials.Load()),
+			TLSHandshakes:     c.handshakes.Load(),
+			AvgTLSMs:          averageMs(c.handshakeNanos.Load(), c.handshakes.Load()),
+			FailedHandshakes:  c.failedTLS.Load(),
+		}
+		if requests > 0 {
+			s.ReuseRatio = float64(reused) / float64(requests)
+		}
+		stats = append(stats, s)
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Provider < stats[j].Provider })
+	return stats
+}
+
+// CachedHTTPClients returns the number of HTTP clients, one per proxy and
+// timeout combination, that upstream requests share.
+func CachedHTTPClients() int {
+	httpClientCacheMutex.RLock()
+	defer httpClientCacheMutex.RUnlock()
+	return len(httpClientCache)
+}
+
+// connectionStatsTransport counts, per provider, whether requests reuse
+// pooled connections and how long DNS, dialing and TLS take for new ones.
+type connectionStatsTransport struct {
+	base     http.RoundTripper
+	counters *connectionCounters
+}
+
+func (t *connectionStatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.counters
+	// httptrace hooks may fire on transport goroutines, and a dial started
+	// for this request may finish after it took another idle connection.
+	var mu sync.Mutex
+	var dnsStart, handshakeStart time.Time
+	dialStarts := make(map[string]time.Time)
+	clientTrace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.requests.Add(1)
+			if info.Reused {
+				c.reused.Add(1)
+				c.idleNanos.Add(int64(info.IdleTime))
+			}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			start := dnsStart
+			mu.Unlock()
+			c.dns.Add(1)
+			c.dnsNanos.Add(int64(time.Since(start)))
+		},
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			dialStarts[network+" "+addr] = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			start, ok := dialStarts[network+" "+addr]
+			delete(dialStarts, network+" "+addr)
+			mu.Unlock()
+			if ok && err == nil {
+				c.dials.Add(1)
+				c.dialNanos.Add(int64(time.Since(start)))
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			handshakeStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			start := handshakeStart
+			mu.Unlock()
+			if err != nil {
+				c.failedTLS.Add(1)
+				return
+			}
+			c.handshakes.Add(1)
+			c.handshakeNanos.Add(int64(time.Since(start)))
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), clientTrace)))
+}
+
+// withConnectionStats wraps client so its requests count towards the
+// connection statistics of the auth's provider.
+func withConnectionStats(client *http.Client, auth *cliproxyauth.Auth) *http.Client {
+	if client == nil || auth == nil {
+		return client
+	}
+	provider := strings.ToLower(strings.TrimSpace(auth.Provider))
+	if provider == "" {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport:     &connectionStatsTransport{base: base, counters: connectionCountersFor(provider)},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
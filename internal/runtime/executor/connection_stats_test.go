@@ -0,0 +1,51 @@
+package executor
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func connectionStatsOf(provider string) ConnectionStats {
+	for _, stats := range UpstreamConnectionStats() {
+		if stats.Provider == provider {
+			return stats
+		}
+	}
+	return ConnectionStats{}
+}
+
+func TestWithConnectionStats_CountsReusedConnections(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	// Two auths of one provider share the client, as auths behind the same
+	// proxy share a cached client.
+	shared := server.Client()
+	before := connectionStatsOf("test-reuse")
+	for _, id := range []string{"a1", "a2", "a1"} {
+		client := withConnectionStats(shared, &cliproxyauth.Auth{ID: id, Provider: "Test-Reuse"})
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	stats := connectionStatsOf("test-reuse")
+	if stats.Requests-before.Requests != 3 || stats.NewConnections-before.NewConnections != 1 || stats.ReusedConnections-before.ReusedConnections != 2 {
+		t.Fatalf("stats = %+v, before %+v, want 1 new and 2 reused connections", stats, before)
+	}
+	if stats.Dials-before.Dials != 1 || stats.TLSHandshakes-before.TLSHandshakes != 1 || stats.FailedHandshakes != 0 {
+		t.Fatalf("stats = %+v, before %+v, want one dial and one TLS handshake", stats, before)
+	}
+	if stats.AvgTLSMs <= 0 {
+		t.Fatalf("TLS handshake time not recorded: %+v", stats)
+	}
+}
@@ -0,0 +1,149 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embedding"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+// embeddingNotSupported is returned by executors whose upstream has no
+// embedding API.
+func embeddingNotSupported(method string) error {
+	return statusErr{code: http.StatusNotImplemented, msg: fmt.Sprintf("%s not supported", method)}
+}
+
+// postEmbedding sends an embedding request prepared by the caller and returns
+// the upstream body and headers.
+func postEmbedding(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, provider string, httpReq *http.Request, body []byte) ([]byte, http.Header, error) {
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, cfg, upstreamRequestLog{
+		URL:       httpReq.URL.String(),
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  provider,
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, cfg, err)
+		return nil, nil, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("%s executor: close response body error: %v", provider, errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, cfg, err)
+		return nil, nil, err
+	}
+	appendAPIResponseChunk(ctx, cfg, data)
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		logWithRequestID(ctx).Debugf("request error, error status: %d, error message: %s", httpResp.StatusCode, summarizeErrorBody(httpResp.Header.Get("Content-Type"), data))
+		return nil, nil, statusErr{code: httpResp.StatusCode, msg: string(data)}
+	}
+	return data, httpResp.Header.Clone(), nil
+}
+
+// executeEmbed forwards a Gemini embedding request to the Gemini API.
+func (e *GeminiExecutor) executeEmbed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, method string) (resp cliproxyexecutor.Response, err error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+
+	reporter := newUsageReporter(ctx, e.Identifier(), baseModel, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	apiKey, bearer := geminiCreds(auth)
+	body := embedding.GeminiRequest(baseModel, method, req.Payload)
+	url := fmt.Sprintf("%s/%s/models/%s:%s", resolveGeminiBaseURL(auth), resolveAuthAPIVersion(auth, glAPIVersion), baseModel, method)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("x-goog-api-key", apiKey)
+	} else if bearer != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	applyGeminiHeaders(httpReq, auth)
+
+	data, headers, err := postEmbedding(ctx, e.cfg, auth, e.Identifier(), httpReq, body)
+	if err != nil {
+		return resp, err
+	}
+	reporter.ensurePublished(ctx)
+	return cliproxyexecutor.Response{Payload: data, Headers: headers}, nil
+}
+
+// executeEmbed serves a Gemini embedding request from the compatible
+// upstream's /embeddings endpoint.
+func (e *OpenAICompatExecutor) executeEmbed(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, method string) (resp cliproxyexecutor.Response, err error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+
+	reporter := newUsageReporter(ctx, e.Identifier(), baseModel, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	baseURL, apiKey := e.resolveCredentials(auth)
+	if baseURL == "" {
+		err = statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL"}
+		return resp, err
+	}
+	body, errConvert := embedding.OpenAIRequest(baseModel, method, req.Payload)
+	if errConvert != nil {
+		err = statusErr{code: http.StatusBadRequest, msg: errConvert.Error()}
+		return resp, err
+	}
+
+	url := applyAPIVersionQuery(strings.TrimSuffix(baseURL, "/")+"/embeddings", auth)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	httpReq.Header.Set("User-Agent", "cli-proxy-openai-compat")
+	var attrs map[string]string
+	if auth != nil {
+		attrs = auth.Attributes
+	}
+	util.ApplyCustomHeadersFromAttrs(httpReq, attrs)
+
+	data, headers, err := postEmbedding(ctx, e.cfg, auth, e.Identifier(), httpReq, body)
+	if err != nil {
+		return resp, err
+	}
+	reporter.publish(ctx, parseOpenAIUsage(data))
+	out, errConvert := embedding.FromOpenAI(method, data)
+	if errConvert != nil {
+		err = statusErr{code: http.StatusBadGateway, msg: errConvert.Error()}
+		return resp, err
+	}
+	headers.Del("Content-Length")
+	return cliproxyexecutor.Response{Payload: out, Headers: headers}, nil
+}
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embedding"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAICompatExecutorEmbedContent(t *testing.T) {
+	var gotPath string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.5,-0.5]}],"usage":{"prompt_tokens":2,"total_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	executor := NewOpenAICompatExecutor("openai-compatibility", &config.Config{})
+	auth := &cliproxyauth.Auth{Provider: "openai-compatibility", Attributes: map[string]string{
+		"base_url": server.URL + "/v1",
+		"api_key":  "test",
+	}}
+	resp, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "nomic-embed-text",
+		Payload: []byte(`{"content":{"parts":[{"text":"hello"}]}}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini"), Alt: embedding.MethodEmbed})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if gotPath != "/v1/embeddings" {
+		t.Fatalf("path = %q", gotPath)
+	}
+	if gjson.GetBytes(gotBody, "model").String() != "nomic-embed-text" || gjson.GetBytes(gotBody, "input.0").String() != "hello" {
+		t.Fatalf("upstream body = %s", gotBody)
+	}
+	if string(resp.Payload) != `{"embedding":{"values":[0.5,-0.5]}}` {
+		t.Fatalf("payload = %s", resp.Payload)
+	}
+}
+
+func TestGeminiExecutorEmbedContentUsesModelPath(t *testing.T) {
+	var gotPath string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"embeddings":[{"values":[1]}]}`))
+	}))
+	defer server.Close()
+
+	executor := NewGeminiExecutor(&config.Config{})
+	auth := &cliproxyauth.Auth{Provider: "gemini", Attributes: map[string]string{"base_url": server.URL, "api_key": "test"}}
+	resp, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "gemini-embedding-001",
+		Payload: []byte(`{"requests":[{"content":{"parts":[{"text":"hello"}]}}]}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini"), Alt: embedding.MethodBatchEmbed})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if gotPath != "/v1beta/models/gemini-embedding-001:batchEmbedContents" {
+		t.Fatalf("path = %q", gotPath)
+	}
+	if got := gjson.GetBytes(gotBody, "requests.0.model").String(); got != "models/gemini-embedding-001" {
+		t.Fatalf("request model = %q", got)
+	}
+	if string(resp.Payload) != `{"embeddings":[{"values":[1]}]}` {
+		t.Fatalf("payload = %s", resp.Payload)
+	}
+}
+
+func TestEmbeddingNotSupported(t *testing.T) {
+	executor := NewCodexExecutor(&config.Config{})
+	_, err := executor.Execute(context.Background(), &cliproxyauth.Auth{Provider: "codex"}, cliproxyexecutor.Request{
+		Model:   "gpt-5",
+		Payload: []byte(`{"content":{"parts":[{"text":"hello"}]}}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini"), Alt: embedding.MethodEmbed})
+	var status statusErr
+	if !errors.As(err, &status) || status.StatusCode() != http.StatusNotImplemented {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/apiversion"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// Per-auth endpoint override keys. Config-backed credentials expose them as
+// attributes (from base-url / api-version); auth files may set them as
+// top-level JSON fields, which surface in metadata.
+const (
+	authBaseURLKey    = "base_url"
+	authAPIVersionKey = "api_version"
+)
+
+// authEndpointOverride returns the trimmed override stored under key,
+// preferring attributes over metadata.
+func authEndpointOverride(auth *cliproxyauth.Auth, key string) string {
+	if auth == nil {
+		return ""
+	}
+	if auth.Attributes != nil {
+		if v := strings.TrimSpace(auth.Attributes[key]); v != "" {
+			return v
+		}
+	}
+	if auth.Metadata != nil {
+		if v, ok := auth.Metadata[key].(string); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// resolveAuthBaseURL returns the auth's upstream base URL override without a
+// trailing slash, or fallback when none is configured.
+func resolveAuthBaseURL(auth *cliproxyauth.Auth, fallback string) string {
+	if base := strings.TrimRight(authEndpointOverride(auth, authBaseURLKey), "/"); base != "" {
+		return base
+	}
+	return fallback
+}
+
+// pinnedAPIVersion returns the api-versions pin for the auth's
+// openai-compatibility name or, failing that, its provider ID.
+func pinnedAPIVersion(auth *cliproxyauth.Auth) string {
+	keys := authProviderKeys(auth)
+	for i := len(keys) - 1; i >= 0; i-- {
+		if version := apiversion.Pinned(keys[i]); version != "" {
+			return version
+		}
+	}
+	return ""
+}
+
+// resolveAuthAPIVersion returns the auth's API version override, the version
+// pinned for its provider, or fallback. Path-versioned providers (Gemini,
+// Vertex, Code Assist) use it as the version segment; header-versioned
+// providers send it in their version header.
+func resolveAuthAPIVersion(auth *cliproxyauth.Auth, fallback string) string {
+	if version := authEndpointOverride(auth, authAPIVersionKey); version != "" {
+		return version
+	}
+	if version := pinnedAPIVersion(auth); version != "" {
+		return version
+	}
+	return fallback
+}
+
+// applyAPIVersionQuery adds the auth's API version, or the one pinned for its
+// provider, as the "api-version" query parameter expected by Azure-style
+// OpenAI gateways. rawURL is returned as-is when neither is set or the
+// parameter is already present.
+func applyAPIVersionQuery(rawURL string, auth *cliproxyauth.Auth) string {
+	version := resolveAuthAPIVersion(auth, "")
+	if version == "" {
+		return rawURL
+	}
+	parsed, errParse := url.Parse(rawURL)
+	if errParse != nil {
+		return rawURL
+	}
+	query := parsed.Query()
+	if query.Get("api-version") != "" {
+		return rawURL
+	}
+	query.Set("api-version", version)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
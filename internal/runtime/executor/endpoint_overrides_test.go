@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/apiversion"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestResolveAuthEndpointOverrides(t *testing.T) {
+	fileAuth := &cliproxyauth.Auth{Metadata: map[string]any{
+		"base_url":    " https://gateway.example.com/anthropic/ ",
+		"api_version": "2024-10-22",
+	}}
+	if got := resolveAuthBaseURL(fileAuth, "https://api.anthropic.com"); got != "https://gateway.example.com/anthropic" {
+		t.Fatalf("metadata base url = %q", got)
+	}
+	if got := resolveAuthAPIVersion(fileAuth, "2023-06-01"); got != "2024-10-22" {
+		t.Fatalf("metadata api version = %q", got)
+	}
+
+	configAuth := &cliproxyauth.Auth{
+		Attributes: map[string]string{"base_url": "https://eu.example.com"},
+		Metadata:   map[string]any{"base_url": "https://ignored.example.com"},
+	}
+	if got := resolveAuthBaseURL(configAuth, ""); got != "https://eu.example.com" {
+		t.Fatalf("attributes should win over metadata, got %q", got)
+	}
+	if got := resolveAuthAPIVersion(configAuth, "v1beta"); got != "v1beta" {
+		t.Fatalf("fallback api version = %q", got)
+	}
+	if got := resolveAuthBaseURL(nil, "https://default.example.com"); got != "https://default.example.com" {
+		t.Fatalf("nil auth base url = %q", got)
+	}
+}
+
+func TestApplyAPIVersionQuery(t *testing.T) {
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{"api_version": "2024-10-21"}}
+	if got := applyAPIVersionQuery("https://x.openai.azure.com/openai/chat/completions", auth); got != "https://x.openai.azure.com/openai/chat/completions?api-version=2024-10-21" {
+		t.Fatalf("applied url = %q", got)
+	}
+	keep := "https://x.openai.azure.com/openai/chat/completions?api-version=preview"
+	if got := applyAPIVersionQuery(keep, auth); got != keep {
+		t.Fatalf("existing api-version should be kept, got %q", got)
+	}
+	if got := applyAPIVersionQuery("https://api.openai.com/v1/responses", &cliproxyauth.Auth{}); got != "https://api.openai.com/v1/responses" {
+		t.Fatalf("url without override changed: %q", got)
+	}
+}
+
+func TestProviderAPIVersionPins(t *testing.T) {
+	t.Cleanup(func() { apiversion.Apply(nil) })
+	apiversion.Apply(map[string]string{"gemini": "v1", "azure-gw": "2024-10-21", "openai-compatibility": "2024-06-01"})
+
+	if got := resolveAuthAPIVersion(&cliproxyauth.Auth{Provider: "gemini"}, glAPIVersion); got != "v1" {
+		t.Fatalf("pinned gemini version = %q, want v1", got)
+	}
+	own := &cliproxyauth.Auth{Provider: "gemini", Attributes: map[string]string{"api_version": "v1alpha"}}
+	if got := resolveAuthAPIVersion(own, glAPIVersion); got != "v1alpha" {
+		t.Fatalf("credential api-version should win over the pin, got %q", got)
+	}
+	compat := &cliproxyauth.Auth{Provider: "openai-compatibility", Attributes: map[string]string{"compat_name": "azure-gw"}}
+	if got := applyAPIVersionQuery("https://gw.example.com/chat/completions", compat); got != "https://gw.example.com/chat/completions?api-version=2024-10-21" {
+		t.Fatalf("compat name pin should win over the provider pin, got %q", got)
+	}
+	if got := resolveAuthAPIVersion(&cliproxyauth.Auth{Provider: "vertex"}, vertexAPIVersion); got != apiversion.VertexDefault {
+		t.Fatalf("unpinned vertex version = %q", got)
+	}
+}
+
+func TestGeminiExecutorUsesAuthAPIVersion(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"totalTokens":3}`))
+	}))
+	defer server.Close()
+
+	executor := NewGeminiExecutor(&config.Config{})
+	auth := &cliproxyauth.Auth{Attributes: map[string]string{
+		"api_key":     "test",
+		"base_url":    server.URL + "/",
+		"api_version": "v1",
+	}}
+	_, err := executor.CountTokens(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "gemini-2.5-flash",
+		Payload: []byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini")})
+	if err != nil {
+		t.Fatalf("CountTokens error: %v", err)
+	}
+	if gotPath != "/v1/models/gemini-2.5-flash:countTokens" {
+		t.Fatalf("path = %q, want /v1/models/gemini-2.5-flash:countTokens", gotPath)
+	}
+}
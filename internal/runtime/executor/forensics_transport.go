@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/forensics"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/signing"
+)
+
+// forensicsTransport records the final upstream payload and each exchange in
+// the request's forensic bundle.
+type forensicsTransport struct {
+	base     http.RoundTripper
+	recorder *forensics.Recorder
+}
+
+func (t *forensicsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, errBody := signing.RequestBody(req)
+	if errBody != nil {
+		return nil, errBody
+	}
+	model, _ := cliproxyexecutor.UpstreamModel(req.Context())
+	t.recorder.AddStage("upstream", "", model, body)
+	index := t.recorder.BeginExchange(req)
+	resp, err := t.base.RoundTrip(req)
+	t.recorder.FinishExchange(index, resp, err)
+	return resp, err
+}
+
+// withForensics wraps client so upstream calls made for a captured API request
+// are added to its forensic bundle. It should wrap the innermost client so the
+// recorded request carries every header added by the outer transports.
+func withForensics(ctx context.Context, client *http.Client) *http.Client {
+	if client == nil {
+		return client
+	}
+	recorder := forensics.FromContext(ctx)
+	if recorder == nil {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport:     &forensicsTransport{base: base, recorder: recorder},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/compute/metadata"
+	vertexauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/vertex"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const vertexCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+var (
+	vertexTokenSourcesMu sync.Mutex
+	// vertexTokenSources caches one reusable token source per auth credential so
+	// access tokens are minted once and refreshed only when they expire.
+	vertexTokenSources = map[string]oauth2.TokenSource{}
+
+	// vertexMetadataProjectID discovers the project from the metadata server.
+	// Overridable in tests.
+	vertexMetadataProjectID = func() string {
+		if !metadata.OnGCE() {
+			return ""
+		}
+		projectID, err := metadata.ProjectID()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(projectID)
+	}
+
+	// vertexComputeTokenSource returns the metadata-server token source used
+	// for workload identity. Overridable in tests.
+	vertexComputeTokenSource = func() oauth2.TokenSource {
+		return google.ComputeTokenSource("", vertexCloudPlatformScope)
+	}
+)
+
+func vertexCredentialSource(meta map[string]any) string {
+	return vertexauth.CredentialSourceFromMetadata(meta)
+}
+
+// vertexTokenSource returns a cached, auto-refreshing token source for auth.
+// A nil credentialJSON selects workload identity.
+func vertexTokenSource(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, credentialJSON []byte) (oauth2.TokenSource, error) {
+	key := vertexTokenSourceKey(auth, credentialJSON)
+
+	vertexTokenSourcesMu.Lock()
+	defer vertexTokenSourcesMu.Unlock()
+	if source, ok := vertexTokenSources[key]; ok {
+		return source, nil
+	}
+
+	var base oauth2.TokenSource
+	if len(credentialJSON) == 0 {
+		base = vertexComputeTokenSource()
+	} else {
+		// The token source outlives this request; keep proxy settings but drop
+		// cancellation so later refreshes are not tied to the first caller.
+		tokenCtx := context.WithoutCancel(ctx)
+		if httpClient := newProxyAwareHTTPClient(ctx, cfg, auth, 0); httpClient != nil {
+			tokenCtx = context.WithValue(tokenCtx, oauth2.HTTPClient, httpClient)
+		}
+		creds, errCreds := google.CredentialsFromJSON(tokenCtx, credentialJSON, vertexCloudPlatformScope)
+		if errCreds != nil {
+			return nil, fmt.Errorf("vertex executor: parse credential json failed: %w", errCreds)
+		}
+		base = creds.TokenSource
+	}
+	source := oauth2.ReuseTokenSource(nil, base)
+	vertexTokenSources[key] = source
+	return source, nil
+}
+
+func vertexTokenSourceKey(auth *cliproxyauth.Auth, credentialJSON []byte) string {
+	authID := ""
+	if auth != nil {
+		authID = auth.ID
+	}
+	if len(credentialJSON) == 0 {
+		return authID + "|" + vertexauth.CredentialSourceWorkloadIdentity
+	}
+	sum := sha256.Sum256(credentialJSON)
+	return authID + "|" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,76 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	vertexauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/vertex"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"golang.org/x/oauth2"
+)
+
+type countingTokenSource struct {
+	calls int
+}
+
+func (s *countingTokenSource) Token() (*oauth2.Token, error) {
+	s.calls++
+	return &oauth2.Token{AccessToken: "wi-token", Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+func TestVertexCreds_WorkloadIdentityUsesMetadataServer(t *testing.T) {
+	prevProject, prevSource := vertexMetadataProjectID, vertexComputeTokenSource
+	source := &countingTokenSource{}
+	vertexMetadataProjectID = func() string { return "gke-project" }
+	vertexComputeTokenSource = func() oauth2.TokenSource { return source }
+	t.Cleanup(func() {
+		vertexMetadataProjectID, vertexComputeTokenSource = prevProject, prevSource
+		vertexTokenSourcesMu.Lock()
+		vertexTokenSources = map[string]oauth2.TokenSource{}
+		vertexTokenSourcesMu.Unlock()
+	})
+
+	auth := &cliproxyauth.Auth{ID: "vertex-wi-test", Metadata: map[string]any{
+		"credential_source": "workload-identity",
+		"region":            "europe-west4",
+	}}
+	projectID, location, credJSON, err := vertexCreds(auth)
+	if err != nil {
+		t.Fatalf("vertexCreds error: %v", err)
+	}
+	if projectID != "gke-project" || location != "europe-west4" || credJSON != nil {
+		t.Fatalf("vertexCreds = (%q, %q, %v), want discovered project, region and no credential json", projectID, location, credJSON)
+	}
+
+	for i := 0; i < 2; i++ {
+		token, errToken := vertexAccessToken(context.Background(), nil, auth, credJSON)
+		if errToken != nil {
+			t.Fatalf("vertexAccessToken error: %v", errToken)
+		}
+		if token != "wi-token" {
+			t.Fatalf("token = %q, want wi-token", token)
+		}
+	}
+	if source.calls != 1 {
+		t.Fatalf("metadata token calls = %d, want 1 (token should be reused until expiry)", source.calls)
+	}
+}
+
+func TestVertexCredentialSourceFromMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		meta map[string]any
+		want string
+	}{
+		{name: "default", meta: map[string]any{"service_account": map[string]any{"type": "service_account"}}, want: vertexauth.CredentialSourceServiceAccount},
+		{name: "authorized user json", meta: map[string]any{"service_account": map[string]any{"type": "authorized_user"}}, want: vertexauth.CredentialSourceAuthorizedUser},
+		{name: "authorized user field", meta: map[string]any{"authorized_user": map[string]any{}}, want: vertexauth.CredentialSourceAuthorizedUser},
+		{name: "explicit metadata", meta: map[string]any{"credential_source": "gke"}, want: vertexauth.CredentialSourceWorkloadIdentity},
+	}
+	for _, tt := range tests {
+		if got := vertexCredentialSource(tt.meta); got != tt.want {
+			t.Fatalf("%s: source = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+)
+
+// githubCopilotModelCapabilities is the subset of the Copilot /models
+// "capabilities" object that affects how requests are shaped.
+type githubCopilotModelCapabilities struct {
+	Family            string
+	Vision            bool
+	ToolCalls         bool
+	ParallelToolCalls bool
+	Streaming         bool
+	StructuredOutputs bool
+	MaxContextTokens  int
+	MaxOutputTokens   int
+}
+
+// githubCopilotCapabilityStore remembers the capabilities negotiated during the
+// last model listing so request paths can consult them without a round trip.
+type githubCopilotCapabilityStore struct {
+	mu     sync.RWMutex
+	models map[string]githubCopilotModelCapabilities
+}
+
+var githubCopilotCapabilities = &githubCopilotCapabilityStore{models: map[string]githubCopilotModelCapabilities{}}
+
+func (s *githubCopilotCapabilityStore) set(modelID string, caps githubCopilotModelCapabilities) {
+	modelID = strings.ToLower(strings.TrimSpace(modelID))
+	if modelID == "" {
+		return
+	}
+	s.mu.Lock()
+	s.models[modelID] = caps
+	s.mu.Unlock()
+}
+
+func (s *githubCopilotCapabilityStore) get(model string) (githubCopilotModelCapabilities, bool) {
+	modelID := strings.ToLower(strings.TrimSpace(thinking.ParseSuffix(model).ModelName))
+	if modelID == "" {
+		return githubCopilotModelCapabilities{}, false
+	}
+	s.mu.RLock()
+	caps, ok := s.models[modelID]
+	s.mu.RUnlock()
+	return caps, ok
+}
+
+// supportsVision reports whether vision requests may be sent for model.
+// Models that were never negotiated are assumed to support it so the
+// executor keeps working when the model listing is unavailable.
+func (s *githubCopilotCapabilityStore) supportsVision(model string) bool {
+	caps, ok := s.get(model)
+	return !ok || caps.Vision
+}
+
+// supportsAgentMode reports whether model accepts agent-style (tool calling)
+// conversations. Unknown models default to true.
+func (s *githubCopilotCapabilityStore) supportsAgentMode(model string) bool {
+	caps, ok := s.get(model)
+	return !ok || caps.ToolCalls
+}
+
+// parseGitHubCopilotModelCapabilities extracts capabilities from a raw
+// Copilot /models entry. The second return value is false when the entry
+// has neither a "supports" nor a "limits" object.
+func parseGitHubCopilotModelCapabilities(raw map[string]any) (githubCopilotModelCapabilities, bool) {
+	caps := githubCopilotModelCapabilities{}
+	supports, hasSupports := raw["supports"].(map[string]any)
+	limits, hasLimits := raw["limits"].(map[string]any)
+	if !hasSupports && !hasLimits {
+		return caps, false
+	}
+	caps.Family = strings.TrimSpace(copilotCapabilityString(raw["family"]))
+
+	if hasSupports {
+		caps.Vision = copilotCapabilityBool(supports["vision"])
+		caps.ToolCalls = copilotCapabilityBool(supports["tool_calls"])
+		caps.ParallelToolCalls = copilotCapabilityBool(supports["parallel_tool_calls"])
+		caps.Streaming = copilotCapabilityBool(supports["streaming"])
+		caps.StructuredOutputs = copilotCapabilityBool(supports["structured_outputs"])
+	}
+	if hasLimits {
+		caps.MaxContextTokens = copilotCapabilityInt(limits["max_context_window_tokens"])
+		caps.MaxOutputTokens = copilotCapabilityInt(limits["max_output_tokens"])
+		// Some entries only advertise vision through limits.vision.
+		if _, hasVision := limits["vision"].(map[string]any); hasVision {
+			caps.Vision = true
+		}
+	}
+	return caps, true
+}
+
+// applyToModelInfo exposes negotiated capabilities through the registry model.
+// Upstream-reported limits take precedence over static defaults.
+func (c githubCopilotModelCapabilities) applyToModelInfo(m *registry.ModelInfo) {
+	if m == nil {
+		return
+	}
+	if c.Family != "" {
+		m.Family = c.Family
+	}
+	if c.MaxContextTokens > 0 {
+		m.ContextLength = c.MaxContextTokens
+	}
+	if c.MaxOutputTokens > 0 {
+		m.MaxCompletionTokens = c.MaxOutputTokens
+	}
+
+	m.SupportedInputModalities = []string{"TEXT"}
+	if c.Vision {
+		m.SupportedInputModalities = append(m.SupportedInputModalities, "IMAGE")
+	}
+
+	var params []string
+	if c.ToolCalls {
+		params = append(params, "tools", "tool_choice")
+	}
+	if c.ParallelToolCalls {
+		params = append(params, "parallel_tool_calls")
+	}
+	if c.Streaming {
+		params = append(params, "stream")
+	}
+	if c.StructuredOutputs {
+		params = append(params, "response_format")
+	}
+	m.SupportedParameters = params
+}
+
+func copilotCapabilityString(value any) string {
+	s, _ := value.(string)
+	return s
+}
+
+func copilotCapabilityBool(value any) bool {
+	b, _ := value.(bool)
+	return b
+}
+
+func copilotCapabilityInt(value any) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	default:
+		return 0
+	}
+}
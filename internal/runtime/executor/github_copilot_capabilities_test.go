@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+func TestParseGitHubCopilotModelCapabilities(t *testing.T) {
+	t.Parallel()
+
+	var raw map[string]any
+	payload := `{"family":"gpt-4o","limits":{"max_context_window_tokens":128000,"max_output_tokens":4096},"supports":{"tool_calls":true,"parallel_tool_calls":true,"streaming":true,"vision":true}}`
+	if err := json.Unmarshal([]byte(payload), &raw); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	caps, ok := parseGitHubCopilotModelCapabilities(raw)
+	if !ok {
+		t.Fatal("expected capabilities to be parsed")
+	}
+	want := githubCopilotModelCapabilities{
+		Family:            "gpt-4o",
+		Vision:            true,
+		ToolCalls:         true,
+		ParallelToolCalls: true,
+		Streaming:         true,
+		MaxContextTokens:  128000,
+		MaxOutputTokens:   4096,
+	}
+	if caps != want {
+		t.Fatalf("caps = %+v, want %+v", caps, want)
+	}
+
+	m := &registry.ModelInfo{ID: "gpt-4o", ContextLength: 1}
+	caps.applyToModelInfo(m)
+	if m.Family != "gpt-4o" || m.ContextLength != 128000 || m.MaxCompletionTokens != 4096 {
+		t.Fatalf("model info = %+v", m)
+	}
+	if !reflect.DeepEqual(m.SupportedInputModalities, []string{"TEXT", "IMAGE"}) {
+		t.Fatalf("SupportedInputModalities = %v", m.SupportedInputModalities)
+	}
+	if !reflect.DeepEqual(m.SupportedParameters, []string{"tools", "tool_choice", "parallel_tool_calls", "stream"}) {
+		t.Fatalf("SupportedParameters = %v", m.SupportedParameters)
+	}
+
+	if _, ok := parseGitHubCopilotModelCapabilities(nil); ok {
+		t.Fatal("expected nil capabilities to be reported as absent")
+	}
+}
+
+func TestParseGitHubCopilotModelCapabilities_FamilyOnly(t *testing.T) {
+	t.Parallel()
+
+	for _, payload := range []string{`{"family":"x"}`, `{"family":"x","supports":null,"limits":"none"}`} {
+		var raw map[string]any
+		if err := json.Unmarshal([]byte(payload), &raw); err != nil {
+			t.Fatalf("unmarshal %s: %v", payload, err)
+		}
+		if caps, ok := parseGitHubCopilotModelCapabilities(raw); ok {
+			t.Fatalf("%s: expected capabilities to be reported as absent, got %+v", payload, caps)
+		}
+	}
+}
+
+func TestGitHubCopilotCapabilityStore_GatesKnownModelsOnly(t *testing.T) {
+	t.Parallel()
+
+	store := &githubCopilotCapabilityStore{models: map[string]githubCopilotModelCapabilities{}}
+	store.set("Text-Only-Model", githubCopilotModelCapabilities{Family: "text-only"})
+
+	if store.supportsVision("text-only-model(high)") {
+		t.Fatal("expected negotiated text-only model to reject vision")
+	}
+	if store.supportsAgentMode("text-only-model") {
+		t.Fatal("expected negotiated model without tool calls to reject agent mode")
+	}
+	if !store.supportsVision("unknown-model") || !store.supportsAgentMode("unknown-model") {
+		t.Fatal("expected unknown models to keep default behavior")
+	}
+}
@@ -17,6 +17,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	copilotauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/copilot"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
@@ -113,7 +114,7 @@ func (e *GitHubCopilotExecutor) PrepareRequest(req *http.Request, auth *cliproxy
 		if strings.TrimSpace(req.Header.Get("Accept")) == "" {
 			req.Header.Set("Accept", "application/json")
 		}
-		req.Header.Set("User-Agent", copilotUserAgent)
+		req.Header.Set("User-Agent", resolveHeaderValue("", "", e.githubCopilotHeaderPolicy().UserAgent, copilotUserAgent, ""))
 		return nil
 	}
 	ctx := req.Context()
@@ -241,19 +242,20 @@ func (e *GitHubCopilotExecutor) Execute(ctx context.Context, auth *cliproxyauth.
 		body = normalizeGitHubCopilotChatTools(body)
 	}
 	requestedModel := payloadRequestedModel(opts, req.Model)
-	body = applyPayloadConfigWithRoot(e.cfg, req.Model, to.String(), "", body, originalTranslated, requestedModel)
+	body = applyPayloadConfigWithRoot(ctx, e.cfg, auth, req.Model, to.String(), "", body, originalTranslated, requestedModel)
 	// For Claude /v1/messages: extract betas from body into header, and enforce thinking constraints.
 	var extraBetas []string
 	if useMessages {
 		extraBetas, body = extractAndRemoveBetas(body)
 		body = disableThinkingIfToolChoiceForced(body)
+		body = thinking.NormalizeClaudeHistory(body, cache.RecallThinkingText)
 	}
 	body, _ = sjson.SetBytes(body, "stream", false)
 
 	// Inject a fake assistant message when force-agent-initiator is enabled and
 	// the request body has no agent role (would otherwise produce X-Initiator: user).
 	hasAgentRole := containsAgentConversationRole(body)
-	if e.cfg.GitHubCopilot.ForceAgentInitiator && !hasAgentRole {
+	if e.cfg.GitHubCopilot.ForceAgentInitiator && !hasAgentRole && githubCopilotCapabilities.supportsAgentMode(req.Model) {
 		bypassIdentity := e.initiatorBypassIdentity(auth, apiToken)
 		if e.initiatorBypass == nil || !e.initiatorBypass.ShouldBypass(req.Model, bypassIdentity, false) {
 			body = injectFakeAssistantMessage(body, e.cfg.GitHubCopilot.FakeAssistantContent, useResponses)
@@ -269,7 +271,8 @@ func (e *GitHubCopilotExecutor) Execute(ctx context.Context, auth *cliproxyauth.
 	e.applyHeaders(httpReq, apiToken, auth, body, false, useMessages, extraBetas)
 
 	// Add Copilot-Vision-Request header if the request contains vision content
-	if hasVision {
+	// and the negotiated model capabilities do not rule it out.
+	if hasVision && githubCopilotCapabilities.supportsVision(req.Model) {
 		httpReq.Header.Set("Copilot-Vision-Request", "true")
 	}
 
@@ -395,19 +398,20 @@ func (e *GitHubCopilotExecutor) ExecuteStream(ctx context.Context, auth *cliprox
 		body = normalizeGitHubCopilotChatTools(body)
 	}
 	requestedModel := payloadRequestedModel(opts, req.Model)
-	body = applyPayloadConfigWithRoot(e.cfg, req.Model, to.String(), "", body, originalTranslated, requestedModel)
+	body = applyPayloadConfigWithRoot(ctx, e.cfg, auth, req.Model, to.String(), "", body, originalTranslated, requestedModel)
 	// For Claude /v1/messages: extract betas from body into header, and enforce thinking constraints.
 	var extraBetas []string
 	if useMessages {
 		extraBetas, body = extractAndRemoveBetas(body)
 		body = disableThinkingIfToolChoiceForced(body)
+		body = thinking.NormalizeClaudeHistory(body, cache.RecallThinkingText)
 	}
 	body, _ = sjson.SetBytes(body, "stream", true)
 
 	// Inject a fake assistant message when force-agent-initiator is enabled and
 	// the request body has no agent role (would otherwise produce X-Initiator: user).
 	hasAgentRole := containsAgentConversationRole(body)
-	if e.cfg.GitHubCopilot.ForceAgentInitiator && !hasAgentRole {
+	if e.cfg.GitHubCopilot.ForceAgentInitiator && !hasAgentRole && githubCopilotCapabilities.supportsAgentMode(req.Model) {
 		bypassIdentity := e.initiatorBypassIdentity(auth, apiToken)
 		if e.initiatorBypass == nil || !e.initiatorBypass.ShouldBypass(req.Model, bypassIdentity, false) {
 			body = injectFakeAssistantMessage(body, e.cfg.GitHubCopilot.FakeAssistantContent, useResponses)
@@ -423,7 +427,8 @@ func (e *GitHubCopilotExecutor) ExecuteStream(ctx context.Context, auth *cliprox
 	e.applyHeaders(httpReq, apiToken, auth, body, true, useMessages, extraBetas)
 
 	// Add Copilot-Vision-Request header if the request contains vision content
-	if hasVision {
+	// and the negotiated model capabilities do not rule it out.
+	if hasVision && githubCopilotCapabilities.supportsVision(req.Model) {
 		httpReq.Header.Set("Copilot-Vision-Request", "true")
 	}
 
@@ -578,7 +583,7 @@ func (e *GitHubCopilotExecutor) ExecuteStream(ctx context.Context, auth *cliprox
 
 	return &cliproxyexecutor.StreamResult{
 		Headers: httpResp.Header.Clone(),
-		Chunks:  out,
+		Chunks:  reporter.trackStream(ctx, e.cfg, opts, out),
 	}, nil
 }
 
@@ -626,7 +631,7 @@ func (e *GitHubCopilotExecutor) ensureAPIToken(ctx context.Context, auth *clipro
 	e.mu.RLock()
 	if cached, ok := e.cache[accessToken]; ok && cached.expiresAt.After(time.Now().Add(tokenExpiryBuffer)) {
 		e.mu.RUnlock()
-		return cached.token, cached.apiEndpoint, nil
+		return cached.token, resolveAuthBaseURL(auth, cached.apiEndpoint), nil
 	}
 	e.mu.RUnlock()
 
@@ -656,7 +661,7 @@ func (e *GitHubCopilotExecutor) ensureAPIToken(ctx context.Context, auth *clipro
 	}
 	e.mu.Unlock()
 
-	return apiToken.Token, apiEndpoint, nil
+	return apiToken.Token, resolveAuthBaseURL(auth, apiEndpoint), nil
 }
 
 func (e *GitHubCopilotExecutor) initiatorBypassIdentity(auth *cliproxyauth.Auth, apiToken string) string {
@@ -684,19 +689,22 @@ func (e *GitHubCopilotExecutor) applyHeaders(r *http.Request, apiToken string, a
 		r.Header = make(http.Header)
 	}
 
-	setGitHubCopilotBaseHeaders(r.Header, apiToken, stream)
+	setGitHubCopilotBaseHeaders(r.Header, apiToken, stream, policy)
 
 	switch mode {
 	case config.GitHubCopilotHeaderPolicyModeStrict:
 		compiled, _ := e.compileGitHubCopilotPolicyHeaders(ginHeaders, auth, body, apiToken, policy, policy.SessionStateFile, true, useMessages, extraBetas, false)
 		applyHeadersFromMap(r.Header, compiled)
 	case config.GitHubCopilotHeaderPolicyModeDualRun:
-		e.applyGitHubCopilotLegacyHeaders(r, ginHeaders, auth, body, useMessages, extraBetas)
+		e.applyGitHubCopilotLegacyHeaders(r, ginHeaders, auth, body, policy, useMessages, extraBetas)
 		legacy := r.Header.Clone()
 		candidate, candidateAudit := e.compileGitHubCopilotPolicyHeaders(ginHeaders, auth, body, apiToken, policy, policy.ShadowStateFile, true, useMessages, extraBetas, false)
 		e.emitGitHubCopilotDualRunDiffs(legacy, candidate, candidateAudit)
 	default:
-		e.applyGitHubCopilotLegacyHeaders(r, ginHeaders, auth, body, useMessages, extraBetas)
+		e.applyGitHubCopilotLegacyHeaders(r, ginHeaders, auth, body, policy, useMessages, extraBetas)
+	}
+	if version := authEndpointOverride(auth, authAPIVersionKey); version != "" {
+		r.Header.Set("X-Github-Api-Version", version)
 	}
 }
 
@@ -728,7 +736,7 @@ func (e *GitHubCopilotExecutor) githubCopilotHeaderPolicy() config.GitHubCopilot
 	return policy
 }
 
-func setGitHubCopilotBaseHeaders(headers http.Header, apiToken string, stream bool) {
+func setGitHubCopilotBaseHeaders(headers http.Header, apiToken string, stream bool, policy config.GitHubCopilotHeaderPolicyConfig) {
 	headers.Set("Content-Type", "application/json")
 	headers.Set("Authorization", "Bearer "+apiToken)
 	if stream {
@@ -737,14 +745,16 @@ func setGitHubCopilotBaseHeaders(headers http.Header, apiToken string, stream bo
 		headers.Set("Accept", "application/json")
 	}
 	headers.Set("Openai-Intent", copilotOpenAIIntent)
-	headers.Set("Copilot-Integration-Id", copilotIntegrationID)
+	headers.Set("Copilot-Integration-Id", resolveHeaderValue("", "", policy.IntegrationID, copilotIntegrationID, ""))
 	headers.Set("X-Github-Api-Version", copilotGitHubAPIVer)
 }
 
-func (e *GitHubCopilotExecutor) applyGitHubCopilotLegacyHeaders(r *http.Request, incoming http.Header, auth *cliproxyauth.Auth, body []byte, useMessages bool, extraBetas []string) {
-	r.Header.Set("User-Agent", copilotUserAgent)
-	r.Header.Set("Editor-Version", copilotEditorVersion)
-	r.Header.Set("Editor-Plugin-Version", copilotPluginVersion)
+func (e *GitHubCopilotExecutor) applyGitHubCopilotLegacyHeaders(r *http.Request, incoming http.Header, auth *cliproxyauth.Auth, body []byte, policy config.GitHubCopilotHeaderPolicyConfig, useMessages bool, extraBetas []string) {
+	// Copilot rejects stale editor identities, so configured values take
+	// precedence over the built-in constants even in legacy mode.
+	r.Header.Set("User-Agent", resolveHeaderValue("", "", policy.UserAgent, copilotUserAgent, ""))
+	r.Header.Set("Editor-Version", resolveHeaderValue("", "", policy.EditorVersion, copilotEditorVersion, ""))
+	r.Header.Set("Editor-Plugin-Version", resolveHeaderValue("", "", policy.EditorPluginVersion, copilotPluginVersion, ""))
 	r.Header.Set("X-Request-Id", generateGitHubCopilotLegacyRequestID())
 
 	if useMessages {
@@ -2046,6 +2056,9 @@ func FetchGitHubCopilotModels(ctx context.Context, auth *cliproxyauth.Auth, cfg
 		if entry.Created > 0 {
 			m.Created = entry.Created
 		}
+		if entry.Version != "" {
+			m.Version = entry.Version
+		}
 		if entry.Name != "" {
 			m.DisplayName = entry.Name
 		} else {
@@ -2069,6 +2082,11 @@ func FetchGitHubCopilotModels(ctx context.Context, auth *cliproxyauth.Auth, cfg
 			m.MaxCompletionTokens = defaultCopilotMaxCompletionTokens
 		}
 
+		if caps, ok := parseGitHubCopilotModelCapabilities(entry.Capabilities); ok {
+			caps.applyToModelInfo(m)
+			githubCopilotCapabilities.set(entry.ID, caps)
+		}
+
 		models = append(models, m)
 	}
 
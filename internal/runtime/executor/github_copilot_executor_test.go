@@ -512,6 +512,32 @@ func TestApplyHeaders_CopilotClientVersionHeaders(t *testing.T) {
 	}
 }
 
+func TestApplyHeaders_LegacyHonorsConfiguredClientVersionHeaders(t *testing.T) {
+	t.Parallel()
+	cfg := &config.Config{}
+	cfg.GitHubCopilot.HeaderPolicy = config.GitHubCopilotHeaderPolicyConfig{
+		UserAgent:           "GitHubCopilotChat/9.9.9",
+		EditorVersion:       " vscode/9.9.9 ",
+		EditorPluginVersion: "copilot-chat/9.9.9",
+		IntegrationID:       "vscode-chat-next",
+	}
+	e := NewGitHubCopilotExecutor(cfg)
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com", nil)
+	e.applyHeaders(req, "token", nil, nil, false, false, nil)
+	if got := req.Header.Get("User-Agent"); got != "GitHubCopilotChat/9.9.9" {
+		t.Fatalf("User-Agent = %q, want GitHubCopilotChat/9.9.9", got)
+	}
+	if got := req.Header.Get("Editor-Version"); got != "vscode/9.9.9" {
+		t.Fatalf("Editor-Version = %q, want vscode/9.9.9", got)
+	}
+	if got := req.Header.Get("Editor-Plugin-Version"); got != "copilot-chat/9.9.9" {
+		t.Fatalf("Editor-Plugin-Version = %q, want copilot-chat/9.9.9", got)
+	}
+	if got := req.Header.Get("Copilot-Integration-Id"); got != "vscode-chat-next" {
+		t.Fatalf("Copilot-Integration-Id = %q, want vscode-chat-next", got)
+	}
+}
+
 func TestApplyHeaders_StreamAcceptSSE(t *testing.T) {
 	t.Parallel()
 	e := &GitHubCopilotExecutor{}
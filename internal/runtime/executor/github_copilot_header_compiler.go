@@ -253,6 +253,7 @@ func sanitizeGitHubCopilotHeaderPolicy(policy config.GitHubCopilotHeaderPolicyCo
 	policy.UserAgent = strings.TrimSpace(policy.UserAgent)
 	policy.EditorVersion = strings.TrimSpace(policy.EditorVersion)
 	policy.EditorPluginVersion = strings.TrimSpace(policy.EditorPluginVersion)
+	policy.IntegrationID = strings.TrimSpace(policy.IntegrationID)
 	policy.AnthropicBeta = strings.TrimSpace(policy.AnthropicBeta)
 	return policy
 }
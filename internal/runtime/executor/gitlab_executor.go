@@ -16,6 +16,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenizer"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
@@ -156,7 +157,7 @@ func (e *GitLabExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.A
 			}
 		}
 	}()
-	return &cliproxyexecutor.StreamResult{Headers: make(http.Header), Chunks: out}, nil
+	return &cliproxyexecutor.StreamResult{Headers: make(http.Header), Chunks: reporter.trackStream(ctx, e.cfg, opts, out)}, nil
 }
 
 func (e *GitLabExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
@@ -200,7 +201,7 @@ func (e *GitLabExecutor) Refresh(ctx context.Context, auth *cliproxyauth.Auth) (
 	auth.Metadata["type"] = gitLabProviderKey
 	auth.Metadata["auth_method"] = method
 	auth.Metadata["auth_kind"] = gitLabAuthKind(method)
-	auth.Metadata["base_url"] = gitlab.NormalizeBaseURL(baseURL)
+	auth.Metadata["base_url"] = gitlab.NormalizeBaseURL(gitLabMetadataString(auth.Metadata, "base_url"))
 	auth.Metadata["last_refresh"] = time.Now().UTC().Format(time.RFC3339)
 	mergeGitLabDirectAccessMetadata(auth.Metadata, direct)
 	return auth, nil
@@ -212,7 +213,7 @@ func (e *GitLabExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Aut
 	}
 	baseModel := thinking.ParseSuffix(req.Model).ModelName
 	translated := sdktranslator.TranslateRequest(opts.SourceFormat, sdktranslator.FromString("openai"), baseModel, req.Payload, false)
-	enc, err := tokenizerForModel(baseModel)
+	enc, err := tokenizer.ForModel(baseModel)
 	if err != nil {
 		return cliproxyexecutor.Response{}, fmt.Errorf("gitlab duo executor: tokenizer init failed: %w", err)
 	}
@@ -435,7 +436,7 @@ func (e *GitLabExecutor) requestCodeSuggestionsStream(
 
 	return &cliproxyexecutor.StreamResult{
 		Headers: cloneGitLabStreamHeaders(httpResp.Header, bodyRaw),
-		Chunks:  out,
+		Chunks:  reporter.trackStream(ctx, e.cfg, opts, out),
 	}, nil
 }
 
@@ -482,7 +483,7 @@ func (e *GitLabExecutor) doJSONRequest(
 		return nil, nil, fmt.Errorf("gitlab duo executor: marshal request failed: %w", err)
 	}
 
-	url := strings.TrimRight(baseURL, "/") + endpoint
+	url := applyAPIVersionQuery(strings.TrimRight(baseURL, "/")+endpoint, auth)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, nil, err
@@ -974,7 +975,7 @@ func buildGitLabOpenAIStream(model, text string) []string {
 }
 
 func gitLabUsage(model string, translatedReq []byte, text string) (int64, int64) {
-	enc, err := tokenizerForModel(model)
+	enc, err := tokenizer.ForModel(model)
 	if err != nil {
 		return 0, 0
 	}
@@ -1141,11 +1142,13 @@ func gitLabPrimaryToken(auth *cliproxyauth.Auth) string {
 	return gitLabMetadataString(auth.Metadata, "personal_access_token")
 }
 
+// gitLabBaseURL returns the GitLab instance URL, honouring a per-auth
+// base_url attribute over the one stored by login.
 func gitLabBaseURL(auth *cliproxyauth.Auth) string {
-	if auth == nil || auth.Metadata == nil {
+	if auth == nil || auth.Metadata == nil && auth.Attributes == nil {
 		return ""
 	}
-	return gitlab.NormalizeBaseURL(gitLabMetadataString(auth.Metadata, "base_url"))
+	return gitlab.NormalizeBaseURL(resolveAuthBaseURL(auth, gitLabMetadataString(auth.Metadata, "base_url")))
 }
 
 func gitLabResolvedModel(auth *cliproxyauth.Auth, requested string) string {
@@ -54,6 +54,43 @@ func TestGitLabExecutorExecuteUsesChatEndpoint(t *testing.T) {
 	}
 }
 
+func TestGitLabExecutorExecuteUsesEndpointOverrides(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != gitLabChatEndpoint {
+			t.Fatalf("unexpected path %q", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("api-version"); got != "2025-01-01" {
+			t.Fatalf("api-version = %q", got)
+		}
+		_, _ = w.Write([]byte(`"chat response"`))
+	}))
+	defer srv.Close()
+
+	exec := NewGitLabExecutor(&config.Config{})
+	auth := &cliproxyauth.Auth{
+		Provider: "gitlab",
+		Attributes: map[string]string{
+			"base_url":    srv.URL,
+			"api_version": "2025-01-01",
+		},
+		Metadata: map[string]any{
+			"base_url":     "https://gitlab.invalid",
+			"access_token": "oauth-access",
+			"model_name":   "claude-sonnet-4-5",
+		},
+	}
+	req := cliproxyexecutor.Request{
+		Model:   "gitlab-duo",
+		Payload: []byte(`{"model":"gitlab-duo","messages":[{"role":"user","content":"hello"}]}`),
+	}
+
+	if _, err := exec.Execute(context.Background(), auth, req, cliproxyexecutor.Options{
+		SourceFormat: sdktranslator.FromString("openai"),
+	}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+}
+
 func TestGitLabExecutorExecuteFallsBackToCodeSuggestions(t *testing.T) {
 	chatCalls := 0
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
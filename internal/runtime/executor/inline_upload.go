@@ -0,0 +1,325 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/apiversion"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Inline attachment limits past which upstreams reject a request. They are
+// variables so tests can lower them.
+var (
+	// geminiInlineRequestLimit is the largest generateContent request Gemini
+	// accepts with inline data.
+	geminiInlineRequestLimit = 20 << 20
+	// claudeInlineBlockLimit is the largest base64 block, in decoded bytes,
+	// the Messages API accepts.
+	claudeInlineBlockLimit = 5 << 20
+	// claudeInlineRequestLimit is the largest Messages API request.
+	claudeInlineRequestLimit = 32 << 20
+)
+
+// claudeFilesBeta is the beta a Messages request needs to reference files.
+const claudeFilesBeta = "files-api-2025-04-14"
+
+// inlineAttachment is a base64 attachment embedded in a provider payload.
+type inlineAttachment struct {
+	// path locates the part or content block holding the attachment.
+	path     string
+	mimeType string
+	data     string
+}
+
+// oversizeAttachments returns the attachments to move out of a payload of
+// bodyLen bytes: every one whose decoded size exceeds blockLimit, then the
+// largest remaining ones until the payload fits requestLimit. A limit of 0 is
+// not enforced.
+func oversizeAttachments(attachments []inlineAttachment, bodyLen, blockLimit, requestLimit int) []inlineAttachment {
+	var selected, rest []inlineAttachment
+	for _, a := range attachments {
+		if blockLimit > 0 && base64.StdEncoding.DecodedLen(len(a.data)) > blockLimit {
+			selected = append(selected, a)
+			bodyLen -= len(a.data)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	if requestLimit <= 0 {
+		return selected
+	}
+	sort.SliceStable(rest, func(i, j int) bool { return len(rest[i].data) > len(rest[j].data) })
+	for _, a := range rest {
+		if bodyLen <= requestLimit {
+			break
+		}
+		selected = append(selected, a)
+		bodyLen -= len(a.data)
+	}
+	return selected
+}
+
+func inlineUploadError(a inlineAttachment, err error) error {
+	return statusErr{code: http.StatusRequestEntityTooLarge, msg: fmt.Sprintf("inline %s attachment of %d bytes exceeds the provider's inline limit and could not be uploaded: %v", a.mimeType, base64.StdEncoding.DecodedLen(len(a.data)), err)}
+}
+
+// geminiInlineAttachments lists the inline data parts of a Gemini payload.
+func geminiInlineAttachments(body []byte) []inlineAttachment {
+	var out []inlineAttachment
+	gjson.GetBytes(body, "contents").ForEach(func(ci, content gjson.Result) bool {
+		content.Get("parts").ForEach(func(pi, part gjson.Result) bool {
+			for _, key := range []string{"inlineData", "inline_data"} {
+				inline := part.Get(key)
+				if !inline.IsObject() {
+					continue
+				}
+				mimeType := inline.Get("mimeType").String()
+				if mimeType == "" {
+					mimeType = inline.Get("mime_type").String()
+				}
+				out = append(out, inlineAttachment{
+					path:     "contents." + ci.String() + ".parts." + pi.String(),
+					mimeType: mimeType,
+					data:     inline.Get("data").String(),
+				})
+			}
+			return true
+		})
+		return true
+	})
+	return out
+}
+
+// uploadOversizeGeminiInline moves inline data that would push body past
+// Gemini's request limit to the Files API and references the uploaded files
+// instead. Gemini deletes uploaded files on its own after 48 hours.
+func uploadOversizeGeminiInline(ctx context.Context, client *http.Client, auth *cliproxyauth.Auth, baseURL, apiKey, bearer string, body []byte) ([]byte, error) {
+	if len(body) <= geminiInlineRequestLimit {
+		return body, nil
+	}
+	for _, a := range oversizeAttachments(geminiInlineAttachments(body), len(body), 0, geminiInlineRequestLimit) {
+		uri, err := uploadGeminiFile(ctx, client, auth, baseURL, apiKey, bearer, a)
+		if err != nil {
+			return nil, inlineUploadError(a, err)
+		}
+		part := `{"fileData":{}}`
+		part, _ = sjson.Set(part, "fileData.mimeType", a.mimeType)
+		part, _ = sjson.Set(part, "fileData.fileUri", uri)
+		if updated, errSet := sjson.SetRawBytes(body, a.path, []byte(part)); errSet == nil {
+			body = updated
+		}
+	}
+	return body, nil
+}
+
+// uploadGeminiFile stores a with the resumable upload protocol of the Gemini
+// Files API and returns the file URI.
+func uploadGeminiFile(ctx context.Context, client *http.Client, auth *cliproxyauth.Auth, baseURL, apiKey, bearer string, a inlineAttachment) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(a.data)
+	if err != nil {
+		return "", fmt.Errorf("decode inline data: %w", err)
+	}
+	authorize := func(req *http.Request) {
+		if apiKey != "" {
+			req.Header.Set("x-goog-api-key", apiKey)
+		} else if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		applyGeminiHeaders(req, auth)
+	}
+
+	start, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/upload/"+glAPIVersion+"/files", strings.NewReader(`{"file":{}}`))
+	if err != nil {
+		return "", err
+	}
+	start.Header.Set("Content-Type", "application/json")
+	start.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	start.Header.Set("X-Goog-Upload-Command", "start")
+	start.Header.Set("X-Goog-Upload-Header-Content-Length", strconv.Itoa(len(data)))
+	start.Header.Set("X-Goog-Upload-Header-Content-Type", a.mimeType)
+	authorize(start)
+	startResp, err := client.Do(start)
+	if err != nil {
+		return "", err
+	}
+	startBody, _ := io.ReadAll(startResp.Body)
+	_ = startResp.Body.Close()
+	uploadURL := startResp.Header.Get("X-Goog-Upload-URL")
+	if startResp.StatusCode < 200 || startResp.StatusCode >= 300 || uploadURL == "" {
+		return "", fmt.Errorf("start upload: status %d: %s", startResp.StatusCode, strings.TrimSpace(string(startBody)))
+	}
+
+	upload, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	upload.Header.Set("X-Goog-Upload-Offset", "0")
+	upload.Header.Set("X-Goog-Upload-Command", "upload, finalize")
+	authorize(upload)
+	uploadResp, err := client.Do(upload)
+	if err != nil {
+		return "", err
+	}
+	uploadBody, _ := io.ReadAll(uploadResp.Body)
+	_ = uploadResp.Body.Close()
+	uri := gjson.GetBytes(uploadBody, "file.uri").String()
+	if uploadResp.StatusCode < 200 || uploadResp.StatusCode >= 300 || uri == "" {
+		return "", fmt.Errorf("upload: status %d: %s", uploadResp.StatusCode, strings.TrimSpace(string(uploadBody)))
+	}
+	return uri, nil
+}
+
+// claudeInlineAttachments lists the base64 image and document blocks of a
+// Claude payload, including those nested in tool results.
+func claudeInlineAttachments(body []byte) []inlineAttachment {
+	var out []inlineAttachment
+	var walk func(path string, blocks gjson.Result)
+	walk = func(path string, blocks gjson.Result) {
+		blocks.ForEach(func(i, block gjson.Result) bool {
+			blockPath := path + "." + i.String()
+			switch block.Get("type").String() {
+			case "image", "document":
+				if block.Get("source.type").String() == "base64" {
+					out = append(out, inlineAttachment{
+						path:     blockPath,
+						mimeType: block.Get("source.media_type").String(),
+						data:     block.Get("source.data").String(),
+					})
+				}
+			case "tool_result":
+				if content := block.Get("content"); content.IsArray() {
+					walk(blockPath+".content", content)
+				}
+			}
+			return true
+		})
+	}
+	gjson.GetBytes(body, "messages").ForEach(func(mi, message gjson.Result) bool {
+		if content := message.Get("content"); content.IsArray() {
+			walk("messages."+mi.String()+".content", content)
+		}
+		return true
+	})
+	return out
+}
+
+// uploadOversizeClaudeInline moves base64 blocks past the Messages API
+// limits to the Files API and references the uploaded files instead. It
+// returns the uploaded file IDs, which the caller deletes once the
+// request is done, and whether the files beta must be sent. Only API key
+// credentials are used; other credentials keep the payload unchanged.
+func uploadOversizeClaudeInline(ctx context.Context, client *http.Client, auth *cliproxyauth.Auth, baseURL, apiKey string, body []byte) ([]byte, []string, error) {
+	if auth == nil || strings.TrimSpace(auth.Attributes["api_key"]) == "" {
+		return body, nil, nil
+	}
+	selected := oversizeAttachments(claudeInlineAttachments(body), len(body), claudeInlineBlockLimit, claudeInlineRequestLimit)
+	var ids []string
+	for _, a := range selected {
+		id, err := uploadClaudeFile(ctx, client, auth, baseURL, apiKey, a)
+		if err != nil {
+			deleteClaudeFiles(ctx, client, auth, baseURL, apiKey, ids)
+			return nil, nil, inlineUploadError(a, err)
+		}
+		ids = append(ids, id)
+		source, _ := sjson.Set(`{"type":"file"}`, "file_id", id)
+		if updated, errSet := sjson.SetRawBytes(body, a.path+".source", []byte(source)); errSet == nil {
+			body = updated
+		}
+	}
+	return body, ids, nil
+}
+
+func newClaudeFilesRequest(ctx context.Context, auth *cliproxyauth.Auth, method, url, apiKey string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(req.URL.Host, "api.anthropic.com") {
+		req.Header.Set("x-api-key", apiKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	req.Header.Set("Anthropic-Version", resolveAuthAPIVersion(auth, apiversion.ClaudeDefault))
+	req.Header.Set("Anthropic-Beta", claudeFilesBeta)
+	util.ApplyCustomHeadersFromAttrs(req, auth.Attributes)
+	return req, nil
+}
+
+// uploadClaudeFile stores a with the Files API and returns its file ID.
+func uploadClaudeFile(ctx context.Context, client *http.Client, auth *cliproxyauth.Auth, baseURL, apiKey string, a inlineAttachment) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(a.data)
+	if err != nil {
+		return "", fmt.Errorf("decode inline data: %w", err)
+	}
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="file"; filename="attachment"`)
+	header.Set("Content-Type", a.mimeType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err = part.Write(data); err != nil {
+		return "", err
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := newClaudeFilesRequest(ctx, auth, http.MethodPost, baseURL+"/v1/files", apiKey, &form)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	id := gjson.GetBytes(respBody, "id").String()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || id == "" {
+		return "", fmt.Errorf("upload: status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return id, nil
+}
+
+// deleteClaudeFiles removes files uploaded for a request, which Anthropic
+// would otherwise keep indefinitely. Failures are only logged.
+func deleteClaudeFiles(ctx context.Context, client *http.Client, auth *cliproxyauth.Auth, baseURL, apiKey string, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	for _, id := range ids {
+		req, err := newClaudeFilesRequest(ctx, auth, http.MethodDelete, baseURL+"/v1/files/"+id, apiKey, nil)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			logWithRequestID(ctx).Warnf("claude executor: delete uploaded file %s: %v", id, err)
+			continue
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			logWithRequestID(ctx).Warnf("claude executor: delete uploaded file %s: status %d", id, resp.StatusCode)
+		}
+	}
+}
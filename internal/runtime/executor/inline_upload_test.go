@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func lowerInlineLimits(t *testing.T, gemini, claudeBlock, claudeRequest int) {
+	t.Helper()
+	oldGemini, oldBlock, oldRequest := geminiInlineRequestLimit, claudeInlineBlockLimit, claudeInlineRequestLimit
+	geminiInlineRequestLimit, claudeInlineBlockLimit, claudeInlineRequestLimit = gemini, claudeBlock, claudeRequest
+	t.Cleanup(func() {
+		geminiInlineRequestLimit, claudeInlineBlockLimit, claudeInlineRequestLimit = oldGemini, oldBlock, oldRequest
+	})
+}
+
+func TestOversizeAttachments(t *testing.T) {
+	attachments := []inlineAttachment{
+		{path: "a", data: strings.Repeat("A", 40)},
+		{path: "b", data: strings.Repeat("B", 400)},
+		{path: "c", data: strings.Repeat("C", 200)},
+	}
+	selected := oversizeAttachments(attachments, 720, 0, 300)
+	if len(selected) != 2 || selected[0].path != "b" || selected[1].path != "c" {
+		t.Fatalf("selected = %+v", selected)
+	}
+	if got := oversizeAttachments(attachments, 700, 60, 0); len(got) != 2 {
+		t.Fatalf("block limit selected %d attachments", len(got))
+	}
+}
+
+func TestGeminiExecutorUploadsOversizeInlineData(t *testing.T) {
+	lowerInlineLimits(t, 100, 0, 0)
+	file := []byte(strings.Repeat("x", 300))
+	var uploaded, generated []byte
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/upload/v1beta/files":
+			if r.Header.Get("X-Goog-Upload-Command") != "start" || r.Header.Get("X-Goog-Upload-Header-Content-Type") != "application/pdf" {
+				t.Errorf("start headers = %v", r.Header)
+			}
+			w.Header().Set("X-Goog-Upload-URL", server.URL+"/upload-session")
+		case r.URL.Path == "/upload-session":
+			uploaded = body
+			_, _ = w.Write([]byte(`{"file":{"name":"files/abc","uri":"https://files.example/abc","state":"ACTIVE"}}`))
+		default:
+			generated = body
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]}}]}`))
+		}
+	}))
+	defer server.Close()
+
+	executor := NewGeminiExecutor(&config.Config{})
+	auth := &cliproxyauth.Auth{Provider: "gemini", Attributes: map[string]string{"base_url": server.URL, "api_key": "test"}}
+	payload := []byte(`{"contents":[{"role":"user","parts":[{"text":"read"},{"inlineData":{"mimeType":"application/pdf","data":"` + base64.StdEncoding.EncodeToString(file) + `"}}]}]}`)
+	if _, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{Model: "gemini-2.5-flash", Payload: payload}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini")}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if string(uploaded) != string(file) {
+		t.Fatalf("uploaded %d bytes", len(uploaded))
+	}
+	part := gjson.GetBytes(generated, "contents.0.parts.1")
+	if part.Get("fileData.fileUri").String() != "https://files.example/abc" || part.Get("fileData.mimeType").String() != "application/pdf" || part.Get("inlineData").Exists() {
+		t.Fatalf("rewritten part = %s", part.Raw)
+	}
+}
+
+func TestClaudeExecutorUploadsOversizeBlocksAndDeletesThem(t *testing.T) {
+	lowerInlineLimits(t, 0, 10, 1<<20)
+	var betas string
+	var sent []byte
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/files":
+			if !strings.Contains(string(body), "0123456789abcdef") {
+				t.Errorf("upload body = %q", body)
+			}
+			_, _ = w.Write([]byte(`{"id":"file_1","type":"file"}`))
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/v1/files/"))
+		default:
+			sent, betas = body, r.Header.Get("Anthropic-Beta")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","model":"claude-sonnet-4","role":"assistant","content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":1,"output_tokens":1}}`))
+		}
+	}))
+	defer server.Close()
+
+	executor := NewClaudeExecutor(&config.Config{})
+	auth := &cliproxyauth.Auth{Provider: "claude", Attributes: map[string]string{"api_key": "key-123", "base_url": server.URL}}
+	data := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	payload := []byte(`{"messages":[{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + data + `"}},{"type":"text","text":"describe"}]}]}`)
+	if _, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{Model: "claude-sonnet-4", Payload: payload}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("claude")}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if source := gjson.GetBytes(sent, "messages.0.content.0.source"); source.Get("type").String() != "file" || source.Get("file_id").String() != "file_1" {
+		t.Fatalf("source = %s", source.Raw)
+	}
+	if !strings.Contains(betas, claudeFilesBeta) {
+		t.Fatalf("betas = %q", betas)
+	}
+	if len(deleted) != 1 || deleted[0] != "file_1" {
+		t.Fatalf("deleted = %v", deleted)
+	}
+}
+
+func TestClaudeInlineUploadFailureIsReported(t *testing.T) {
+	lowerInlineLimits(t, 0, 10, 1<<20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":{"message":"files api disabled"}}`))
+	}))
+	defer server.Close()
+
+	auth := &cliproxyauth.Auth{Provider: "claude", Attributes: map[string]string{"api_key": "key-123"}}
+	data := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	body := []byte(`{"messages":[{"role":"user","content":[{"type":"document","source":{"type":"base64","media_type":"application/pdf","data":"` + data + `"}}]}]}`)
+	_, _, err := uploadOversizeClaudeInline(context.Background(), server.Client(), auth, server.URL, "key-123", body)
+	var status statusErr
+	if !errors.As(err, &status) || status.StatusCode() != http.StatusRequestEntityTooLarge || !strings.Contains(status.Error(), "files api disabled") {
+		t.Fatalf("err = %v", err)
+	}
+}
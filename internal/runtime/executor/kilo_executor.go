@@ -22,6 +22,9 @@ import (
 	"github.com/tidwall/gjson"
 )
 
+// kiloAPIBaseURL is the default Kilo API origin.
+const kiloAPIBaseURL = "https://api.kilo.ai"
+
 // KiloExecutor handles requests to Kilo API.
 type KiloExecutor struct {
 	cfg *config.Config
@@ -94,14 +97,14 @@ func (e *KiloExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req
 	originalTranslated := sdktranslator.TranslateRequest(from, to, baseModel, originalPayload, opts.Stream)
 	translated := sdktranslator.TranslateRequest(from, to, baseModel, req.Payload, opts.Stream)
 	requestedModel := payloadRequestedModel(opts, req.Model)
-	translated = applyPayloadConfigWithRoot(e.cfg, baseModel, to.String(), "", translated, originalTranslated, requestedModel)
+	translated = applyPayloadConfigWithRoot(ctx, e.cfg, auth, baseModel, to.String(), "", translated, originalTranslated, requestedModel)
 
 	translated, err = applyThinkingWithUsageMeta(translated, req.Model, from.String(), to.String(), e.Identifier(), reporter)
 	if err != nil {
 		return resp, err
 	}
 
-	url := "https://api.kilo.ai" + endpoint
+	url := applyAPIVersionQuery(resolveAuthBaseURL(auth, kiloAPIBaseURL)+endpoint, auth)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(translated))
 	if err != nil {
 		return resp, err
@@ -159,6 +162,7 @@ func (e *KiloExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req
 	}
 	appendAPIResponseChunk(ctx, e.cfg, body)
 	reporter.publish(ctx, parseOpenAIUsage(body))
+	reporter.publishEstimated(ctx, translated, body)
 	reporter.ensurePublished(ctx)
 
 	var param any
@@ -191,14 +195,14 @@ func (e *KiloExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Aut
 	originalTranslated := sdktranslator.TranslateRequest(from, to, baseModel, originalPayload, true)
 	translated := sdktranslator.TranslateRequest(from, to, baseModel, req.Payload, true)
 	requestedModel := payloadRequestedModel(opts, req.Model)
-	translated = applyPayloadConfigWithRoot(e.cfg, baseModel, to.String(), "", translated, originalTranslated, requestedModel)
+	translated = applyPayloadConfigWithRoot(ctx, e.cfg, auth, baseModel, to.String(), "", translated, originalTranslated, requestedModel)
 
 	translated, err = applyThinkingWithUsageMeta(translated, req.Model, from.String(), to.String(), e.Identifier(), reporter)
 	if err != nil {
 		return nil, err
 	}
 
-	url := "https://api.kilo.ai" + endpoint
+	url := applyAPIVersionQuery(resolveAuthBaseURL(auth, kiloAPIBaseURL)+endpoint, auth)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(translated))
 	if err != nil {
 		return nil, err
@@ -287,7 +291,7 @@ func (e *KiloExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Aut
 
 	return &cliproxyexecutor.StreamResult{
 		Headers: httpResp.Header.Clone(),
-		Chunks:  out,
+		Chunks:  reporter.trackStream(ctx, e.cfg, opts, out),
 	}, nil
 }
 
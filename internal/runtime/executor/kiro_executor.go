@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -23,6 +24,7 @@ import (
 	"github.com/google/uuid"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenizer"
 	kiroclaude "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/claude"
 	kirocommon "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/common"
 	kiroopenai "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/kiro/openai"
@@ -227,24 +229,134 @@ func logRetryAttempt(attempt, maxRetries int, reason string, delay time.Duration
 var (
 	kiroHTTPClientPoolMu sync.RWMutex
 	kiroHTTPClientPools  = make(map[string]*http.Client)
+	kiroHTTPPoolStats    = make(map[string]*kiroPoolStats)
 )
 
+// kiroPoolStats tracks connection-level health counters for one pooled Kiro transport.
+type kiroPoolStats struct {
+	settings              config.KiroHTTPPoolConfig
+	connectTimeout        time.Duration
+	responseHeaderTimeout time.Duration
+	createdAt             time.Time
+
+	dials       atomic.Int64
+	dialErrors  atomic.Int64
+	openConns   atomic.Int64
+	lastErrorMu sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (s *kiroPoolStats) recordDialError(err error) {
+	s.dialErrors.Add(1)
+	s.lastErrorMu.Lock()
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now()
+	s.lastErrorMu.Unlock()
+}
+
+// kiroPoolConn decrements the open connection gauge exactly once when closed.
+type kiroPoolConn struct {
+	net.Conn
+	stats  *kiroPoolStats
+	closed atomic.Bool
+}
+
+func (c *kiroPoolConn) Close() error {
+	if c.closed.CompareAndSwap(false, true) {
+		c.stats.openConns.Add(-1)
+	}
+	return c.Conn.Close()
+}
+
+// instrumentKiroDialer wraps a DialContext func so that dials, dial failures
+// and currently open connections are reported in the pool health snapshot.
+func instrumentKiroDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error), stats *kiroPoolStats) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{KeepAlive: 30 * time.Second}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		stats.dials.Add(1)
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			stats.recordDialError(err)
+			return nil, err
+		}
+		stats.openConns.Add(1)
+		return &kiroPoolConn{Conn: conn, stats: stats}, nil
+	}
+}
+
+// KiroHTTPPoolHealth describes the configuration and connection counters of one pooled Kiro transport.
+type KiroHTTPPoolHealth struct {
+	MaxIdleConns                 int       `json:"max_idle_conns"`
+	MaxIdleConnsPerHost          int       `json:"max_idle_conns_per_host"`
+	MaxConnsPerHost              int       `json:"max_conns_per_host"`
+	IdleConnTimeoutSeconds       int       `json:"idle_conn_timeout_seconds"`
+	ConnectTimeoutSeconds        int       `json:"connect_timeout_seconds"`
+	ResponseHeaderTimeoutSeconds int       `json:"response_header_timeout_seconds"`
+	CreatedAt                    time.Time `json:"created_at"`
+	Dials                        int64     `json:"dials"`
+	DialErrors                   int64     `json:"dial_errors"`
+	OpenConns                    int64     `json:"open_conns"`
+	LastError                    string    `json:"last_error,omitempty"`
+	LastErrorAt                  time.Time `json:"last_error_at,omitempty"`
+}
+
+// KiroHTTPPoolHealthSnapshot returns health counters for every Kiro connection pool created so far.
+// Pools are keyed by their timeout, TLS and pool settings, so a config change produces a new entry.
+func KiroHTTPPoolHealthSnapshot() []KiroHTTPPoolHealth {
+	kiroHTTPClientPoolMu.RLock()
+	stats := make([]*kiroPoolStats, 0, len(kiroHTTPPoolStats))
+	for _, s := range kiroHTTPPoolStats {
+		stats = append(stats, s)
+	}
+	kiroHTTPClientPoolMu.RUnlock()
+
+	out := make([]KiroHTTPPoolHealth, 0, len(stats))
+	for _, s := range stats {
+		s.lastErrorMu.Lock()
+		lastError, lastErrorAt := s.lastError, s.lastErrorAt
+		s.lastErrorMu.Unlock()
+		out = append(out, KiroHTTPPoolHealth{
+			MaxIdleConns:                 s.settings.MaxIdleConns,
+			MaxIdleConnsPerHost:          s.settings.MaxIdleConnsPerHost,
+			MaxConnsPerHost:              s.settings.MaxConnsPerHost,
+			IdleConnTimeoutSeconds:       s.settings.IdleConnTimeoutSeconds,
+			ConnectTimeoutSeconds:        int(s.connectTimeout / time.Second),
+			ResponseHeaderTimeoutSeconds: int(s.responseHeaderTimeout / time.Second),
+			CreatedAt:                    s.createdAt,
+			Dials:                        s.dials.Load(),
+			DialErrors:                   s.dialErrors.Load(),
+			OpenConns:                    s.openConns.Load(),
+			LastError:                    lastError,
+			LastErrorAt:                  lastErrorAt,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
 // getKiroPooledHTTPClient returns a shared HTTP client with optimized connection pooling.
 // The client is lazily initialized on first use and reused across requests.
-// It reads timeout configuration from the provided config.
+// Timeouts come from GetUpstreamTimeouts (same as newProxyAwareHTTPClient) and pool
+// sizing comes from cfg.KiroHTTPPool.
 // This is especially beneficial for:
 // - Reducing TCP handshake overhead
 // - Enabling HTTP/2 multiplexing
 // - Better handling of keep-alive connections
 //
 // Parameters:
-//   - cfg: The application configuration (includes upstream timeout settings)
+//   - cfg: The application configuration (includes upstream timeout and pool settings)
 func getKiroPooledHTTPClient(cfg *config.Config) *http.Client {
 	// Get upstream timeout configuration from global config
 	var sdkCfg *config.SDKConfig
+	var poolCfg config.KiroHTTPPoolConfig
 	if cfg != nil {
 		sdkCfg = &cfg.SDKConfig
+		poolCfg = cfg.KiroHTTPPool
 	}
+	poolCfg = poolCfg.Effective()
 	connectTimeoutSec, responseHeaderTimeoutSec, err := config.GetUpstreamTimeouts(sdkCfg)
 	if err != nil {
 		// Negative timeout values are invalid - log error and use defaults
@@ -253,7 +365,8 @@ func getKiroPooledHTTPClient(cfg *config.Config) *http.Client {
 		responseHeaderTimeoutSec = config.DefaultResponseHeaderTimeoutSeconds
 	}
 	insecureSkipVerify := cfg != nil && cfg.TLSInsecureSkipVerify
-	poolKey := fmt.Sprintf("%d|%d|%t", connectTimeoutSec, responseHeaderTimeoutSec, insecureSkipVerify)
+	poolKey := fmt.Sprintf("%d|%d|%t|%d|%d|%d|%d", connectTimeoutSec, responseHeaderTimeoutSec, insecureSkipVerify,
+		poolCfg.MaxIdleConns, poolCfg.MaxIdleConnsPerHost, poolCfg.MaxConnsPerHost, poolCfg.IdleConnTimeoutSeconds)
 
 	kiroHTTPClientPoolMu.RLock()
 	if pooled, ok := kiroHTTPClientPools[poolKey]; ok {
@@ -262,27 +375,14 @@ func getKiroPooledHTTPClient(cfg *config.Config) *http.Client {
 	}
 	kiroHTTPClientPoolMu.RUnlock()
 
-	connectTimeout := time.Duration(connectTimeoutSec) * time.Second
-	responseHeaderTimeout := time.Duration(responseHeaderTimeoutSec) * time.Second
-
-	// Clone DefaultTransport to preserve reasonable defaults
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	applyTransportTLSInsecureSkipVerify(transport, insecureSkipVerify)
+	// Share timeout handling with the generic upstream transport builder.
+	transport := buildDefaultTransportWithTimeouts(connectTimeoutSec, responseHeaderTimeoutSec, insecureSkipVerify)
 
 	// Override connection pool settings for Kiro
-	transport.MaxIdleConns = 100                 // Max idle connections across all hosts
-	transport.MaxIdleConnsPerHost = 20           // Max idle connections per host
-	transport.MaxConnsPerHost = 50               // Max total connections per host
-	transport.IdleConnTimeout = 90 * time.Second // How long idle connections stay in pool
-
-	// Apply connect timeout from config
-	transport.DialContext = (&net.Dialer{
-		Timeout:   connectTimeout,
-		KeepAlive: 30 * time.Second, // TCP keep-alive interval
-	}).DialContext
-
-	// Apply response header timeout from config
-	transport.ResponseHeaderTimeout = responseHeaderTimeout
+	transport.MaxIdleConns = poolCfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = poolCfg.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = poolCfg.MaxConnsPerHost
+	transport.IdleConnTimeout = time.Duration(poolCfg.IdleConnTimeoutSeconds) * time.Second
 
 	// Expect 100-continue timeout
 	transport.ExpectContinueTimeout = 1 * time.Second
@@ -290,6 +390,14 @@ func getKiroPooledHTTPClient(cfg *config.Config) *http.Client {
 	// Enable HTTP/2 when available
 	transport.ForceAttemptHTTP2 = true
 
+	stats := &kiroPoolStats{
+		settings:              poolCfg,
+		connectTimeout:        time.Duration(connectTimeoutSec) * time.Second,
+		responseHeaderTimeout: transport.ResponseHeaderTimeout,
+		createdAt:             time.Now(),
+	}
+	transport.DialContext = instrumentKiroDialer(transport.DialContext, stats)
+
 	pooledClient := &http.Client{
 		Transport: transport,
 		// No global timeout - let individual requests set their own timeouts via context
@@ -301,10 +409,11 @@ func getKiroPooledHTTPClient(cfg *config.Config) *http.Client {
 		return pooled
 	}
 	kiroHTTPClientPools[poolKey] = pooledClient
+	kiroHTTPPoolStats[poolKey] = stats
 	kiroHTTPClientPoolMu.Unlock()
 
-	log.Debugf("kiro: initialized pooled HTTP client (connect=%v, response-header=%v, insecureSkipVerify=%v, MaxIdleConns=%d, MaxIdleConnsPerHost=%d, MaxConnsPerHost=%d)",
-		connectTimeout, responseHeaderTimeout, insecureSkipVerify, transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	log.Debugf("kiro: initialized pooled HTTP client (connect=%ds, response-header=%ds, insecureSkipVerify=%v, MaxIdleConns=%d, MaxIdleConnsPerHost=%d, MaxConnsPerHost=%d, IdleConnTimeout=%v)",
+		connectTimeoutSec, responseHeaderTimeoutSec, insecureSkipVerify, transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost, transport.IdleConnTimeout)
 
 	return pooledClient
 }
@@ -333,13 +442,13 @@ func newKiroHTTPClientWithPooling(ctx context.Context, cfg *config.Config, auth
 
 	// If timeout is specified, we need to wrap the pooled transport with timeout
 	if timeout > 0 {
-		return &http.Client{
+		pooledClient = &http.Client{
 			Transport: pooledClient.Transport,
 			Timeout:   timeout,
 		}
 	}
 
-	return pooledClient
+	return withUpstreamHeaders(ctx, withRequestMiddleware(ctx, withRequestSigning(withStageTrace(ctx, withForensics(ctx, pooledClient)), cfg, auth), auth), cfg, auth)
 }
 
 // kiroEndpointConfig bundles endpoint URL with its compatible Origin and AmzTarget values.
@@ -460,12 +569,12 @@ func getKiroEndpointConfigs(auth *cliproxyauth.Auth) []kiroEndpointConfig {
 
 	preference := getAuthValue(auth, "preferred_endpoint")
 	if preference == "" {
-		return configs
+		return applyKiroEndpointOverrides(auth, configs)
 	}
 
 	targetName, ok := endpointAliases[preference]
 	if !ok {
-		return configs
+		return applyKiroEndpointOverrides(auth, configs)
 	}
 
 	var preferred, others []kiroEndpointConfig
@@ -478,9 +587,27 @@ func getKiroEndpointConfigs(auth *cliproxyauth.Auth) []kiroEndpointConfig {
 	}
 
 	if len(preferred) == 0 {
+		return applyKiroEndpointOverrides(auth, configs)
+	}
+	return applyKiroEndpointOverrides(auth, append(preferred, others...))
+}
+
+// applyKiroEndpointOverrides applies the auth's base_url and api_version
+// overrides. A base URL override replaces the regional hosts, so only the
+// first (preferred) endpoint is kept, pointed at the override.
+func applyKiroEndpointOverrides(auth *cliproxyauth.Auth, configs []kiroEndpointConfig) []kiroEndpointConfig {
+	if len(configs) == 0 {
 		return configs
 	}
-	return append(preferred, others...)
+	if base := resolveAuthBaseURL(auth, ""); base != "" {
+		primary := configs[0]
+		primary.URL = base + "/generateAssistantResponse"
+		configs = []kiroEndpointConfig{primary}
+	}
+	for i := range configs {
+		configs[i].URL = applyAPIVersionQuery(configs[i].URL, auth)
+	}
+	return configs
 }
 
 // KiroExecutor handles requests to AWS CodeWhisperer (Kiro) API.
@@ -1022,7 +1149,7 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 
 			// 1. Estimate InputTokens if missing
 			if usageInfo.InputTokens == 0 {
-				if enc, encErr := getTokenizer(req.Model); encErr == nil {
+				if enc, encErr := tokenizer.ForModel(req.Model); encErr == nil {
 					if inp, countErr := countOpenAIChatTokens(enc, opts.OriginalRequest); countErr == nil {
 						usageInfo.InputTokens = inp
 					}
@@ -1032,7 +1159,7 @@ func (e *KiroExecutor) executeWithRetry(ctx context.Context, auth *cliproxyauth.
 			// 2. Estimate OutputTokens if missing and content is available
 			if usageInfo.OutputTokens == 0 && len(content) > 0 {
 				// Use tiktoken for more accurate output token calculation
-				if enc, encErr := getTokenizer(req.Model); encErr == nil {
+				if enc, encErr := tokenizer.ForModel(req.Model); encErr == nil {
 					if tokenCount, countErr := enc.Count(content); countErr == nil {
 						usageInfo.OutputTokens = int64(tokenCount)
 					}
@@ -1170,7 +1297,7 @@ func (e *KiroExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Aut
 	if errStreamKiro != nil {
 		return nil, errStreamKiro
 	}
-	return &cliproxyexecutor.StreamResult{Chunks: streamKiro}, nil
+	return &cliproxyexecutor.StreamResult{Chunks: reporter.trackStream(ctx, e.cfg, opts, streamKiro)}, nil
 }
 
 // executeStreamWithRetry performs the streaming HTTP request with automatic retry on auth errors.
@@ -2552,7 +2679,7 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 
 	// Pre-calculate input tokens from request if possible
 	// Kiro uses Claude format, so try Claude format first, then OpenAI format, then fallback
-	if enc, err := getTokenizer(model); err == nil {
+	if enc, err := tokenizer.ForModel(model); err == nil {
 		var inputTokens int64
 		var countMethod string
 
@@ -2947,7 +3074,7 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 				if shouldSendUsageUpdate {
 					// Calculate current output tokens using tiktoken
 					var currentOutputTokens int64
-					if enc, encErr := getTokenizer(model); encErr == nil {
+					if enc, encErr := tokenizer.ForModel(model); encErr == nil {
 						if tokenCount, countErr := enc.Count(accumulatedContent.String()); countErr == nil {
 							currentOutputTokens = int64(tokenCount)
 						}
@@ -3583,7 +3710,7 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 	// Only use local estimation if server didn't provide usage (server-side usage takes priority)
 	if totalUsage.OutputTokens == 0 && accumulatedContent.Len() > 0 {
 		// Try to use tiktoken for accurate counting
-		if enc, err := getTokenizer(model); err == nil {
+		if enc, err := tokenizer.ForModel(model); err == nil {
 			if tokenCount, countErr := enc.Count(accumulatedContent.String()); countErr == nil {
 				totalUsage.OutputTokens = int64(tokenCount)
 				log.Debugf("kiro: streamToChannel calculated output tokens using tiktoken: %d", totalUsage.OutputTokens)
@@ -3683,7 +3810,7 @@ func (e *KiroExecutor) streamToChannel(ctx context.Context, body io.Reader, out
 // This provides approximate token counts for client requests.
 func (e *KiroExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	// Use tiktoken for local token counting
-	enc, err := getTokenizer(req.Model)
+	enc, err := tokenizer.ForModel(req.Model)
 	if err != nil {
 		log.Warnf("kiro: CountTokens failed to get tokenizer: %v, falling back to estimate", err)
 		// Fallback: estimate from payload size (roughly 4 chars per token)
@@ -4436,7 +4563,7 @@ func (e *KiroExecutor) handleWebSearchStream(
 
 		// Estimate input tokens using tokenizer (matching streamToChannel pattern)
 		var totalUsage usage.Detail
-		if enc, tokErr := getTokenizer(req.Model); tokErr == nil {
+		if enc, tokErr := tokenizer.ForModel(req.Model); tokErr == nil {
 			if inp, e := countClaudeChatTokens(enc, req.Payload); e == nil && inp > 0 {
 				totalUsage.InputTokens = inp
 			} else {
@@ -4541,7 +4668,8 @@ func (e *KiroExecutor) handleWebSearchStream(
 			// Call GAR with modified Claude payload (full translation pipeline)
 			modifiedReq := req
 			modifiedReq.Payload = currentClaudePayload
-			kiroChunks, kiroErr := e.callKiroAndBuffer(ctx, auth, modifiedReq, opts, accessToken, profileArn, nil)
+			followupReporter := reporter.child(fmt.Sprintf("web_search_followup_%d", iteration+1))
+			kiroChunks, kiroErr := e.callKiroAndBuffer(ctx, auth, modifiedReq, opts, accessToken, profileArn, followupReporter)
 			if kiroErr != nil {
 				log.Warnf("kiro/websearch: Kiro API failed at iteration %d: %v", iteration+1, kiroErr)
 				wsErr = fmt.Errorf("Kiro API failed at iteration %d: %w", iteration+1, kiroErr)
@@ -4698,7 +4826,9 @@ func (e *KiroExecutor) handleWebSearch(
 
 // callKiroAndBuffer calls the Kiro API and buffers all response chunks.
 // Returns the buffered chunks for analysis before forwarding to client.
-// Usage reporting is NOT done here — the caller (handleWebSearchStream) manages its own reporter.
+// reporter should be a child of the caller's reporter (see usageReporter.child):
+// it records this follow-up call separately so the parent record keeps its own
+// thinking variant and totals.
 func (e *KiroExecutor) callKiroAndBuffer(
 	ctx context.Context,
 	auth *cliproxyauth.Auth,
@@ -4706,7 +4836,9 @@ func (e *KiroExecutor) callKiroAndBuffer(
 	opts cliproxyexecutor.Options,
 	accessToken, profileArn string,
 	reporter *usageReporter,
-) ([][]byte, error) {
+) (chunks [][]byte, err error) {
+	defer reporter.trackFailure(ctx, &err)
+
 	from := opts.SourceFormat
 	body, err := translateKiroRequestWithThinkingMeta(req.Payload, req.Model, from, reporter)
 	if err != nil {
@@ -4722,14 +4854,13 @@ func (e *KiroExecutor) callKiroAndBuffer(
 
 	kiroStream, err := e.executeStreamWithRetry(
 		ctx, auth, req, opts, accessToken, effectiveProfileArn,
-		nil, body, from, nil, "", kiroModelID, isAgentic, isChatOnly, tokenKey,
+		nil, body, from, reporter, "", kiroModelID, isAgentic, isChatOnly, tokenKey,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	// Buffer all chunks
-	var chunks [][]byte
 	for chunk := range kiroStream {
 		if chunk.Err != nil {
 			return chunks, chunk.Err
@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestGetKiroPooledHTTPClient_AppliesPoolConfig(t *testing.T) {
+	resetKiroHTTPClientPoolsForTest()
+
+	cfg := &config.Config{
+		SDKConfig: config.SDKConfig{UpstreamTimeouts: config.UpstreamTimeouts{ResponseHeaderTimeoutSeconds: 7}},
+		KiroHTTPPool: config.KiroHTTPPoolConfig{
+			MaxIdleConns:           12,
+			MaxIdleConnsPerHost:    3,
+			MaxConnsPerHost:        4,
+			IdleConnTimeoutSeconds: 15,
+		},
+	}
+	client := getKiroPooledHTTPClient(cfg)
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok || transport == nil {
+		t.Fatalf("expected *http.Transport, got %T", client.Transport)
+	}
+	if transport.MaxIdleConns != 12 || transport.MaxIdleConnsPerHost != 3 || transport.MaxConnsPerHost != 4 {
+		t.Fatalf("unexpected pool sizes: idle=%d idlePerHost=%d perHost=%d", transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 15*time.Second {
+		t.Fatalf("IdleConnTimeout = %v, want 15s", transport.IdleConnTimeout)
+	}
+	if transport.ResponseHeaderTimeout != 7*time.Second {
+		t.Fatalf("ResponseHeaderTimeout = %v, want 7s", transport.ResponseHeaderTimeout)
+	}
+
+	defaults := getKiroPooledHTTPClient(&config.Config{})
+	if defaults == client {
+		t.Fatal("expected distinct pool for different pool settings")
+	}
+	defaultTransport := defaults.Transport.(*http.Transport)
+	if defaultTransport.MaxConnsPerHost != config.DefaultKiroMaxConnsPerHost {
+		t.Fatalf("MaxConnsPerHost = %d, want default %d", defaultTransport.MaxConnsPerHost, config.DefaultKiroMaxConnsPerHost)
+	}
+}
+
+func TestKiroHTTPPoolHealthSnapshot_TracksDials(t *testing.T) {
+	resetKiroHTTPClientPoolsForTest()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client := getKiroPooledHTTPClient(&config.Config{})
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	snapshot := KiroHTTPPoolHealthSnapshot()
+	if len(snapshot) != 1 {
+		t.Fatalf("expected 1 pool, got %d", len(snapshot))
+	}
+	health := snapshot[0]
+	if health.Dials != 1 {
+		t.Fatalf("Dials = %d, want 1 (second request should reuse the connection)", health.Dials)
+	}
+	if health.OpenConns != 1 {
+		t.Fatalf("OpenConns = %d, want 1", health.OpenConns)
+	}
+
+	client.Transport.(*http.Transport).CloseIdleConnections()
+	if got := KiroHTTPPoolHealthSnapshot()[0].OpenConns; got != 0 {
+		t.Fatalf("OpenConns after close = %d, want 0", got)
+	}
+}
@@ -2,6 +2,7 @@ package executor
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
@@ -231,6 +232,35 @@ func TestGetKiroEndpointConfigs_MetadataTakesPrecedenceOverAttributes(t *testing
 	}
 }
 
+func TestGetKiroEndpointConfigs_EndpointOverrides(t *testing.T) {
+	auth := &cliproxyauth.Auth{
+		Metadata:   map[string]any{"preferred_endpoint": "codewhisperer", "api_version": "2025-01-01"},
+		Attributes: map[string]string{"base_url": "https://kiro-gateway.example.com/"},
+	}
+
+	configs := getKiroEndpointConfigs(auth)
+
+	if len(configs) != 1 {
+		t.Fatalf("expected 1 endpoint with a base_url override, got %d", len(configs))
+	}
+	if configs[0].Name != "CodeWhisperer" {
+		t.Errorf("Name = %q, want %q", configs[0].Name, "CodeWhisperer")
+	}
+	if want := "https://kiro-gateway.example.com/generateAssistantResponse?api-version=2025-01-01"; configs[0].URL != want {
+		t.Errorf("URL = %q, want %q", configs[0].URL, want)
+	}
+
+	versionOnly := getKiroEndpointConfigs(&cliproxyauth.Auth{Attributes: map[string]string{"api_version": "v2"}})
+	if len(versionOnly) != 2 {
+		t.Fatalf("expected 2 endpoints without a base_url override, got %d", len(versionOnly))
+	}
+	for _, cfg := range versionOnly {
+		if !strings.HasSuffix(cfg.URL, "/generateAssistantResponse?api-version=v2") {
+			t.Errorf("%s URL = %q", cfg.Name, cfg.URL)
+		}
+	}
+}
+
 func TestGetAuthValue(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -11,6 +11,7 @@ func resetKiroHTTPClientPoolsForTest() {
 	kiroHTTPClientPoolMu.Lock()
 	defer kiroHTTPClientPoolMu.Unlock()
 	kiroHTTPClientPools = make(map[string]*http.Client)
+	kiroHTTPPoolStats = make(map[string]*kiroPoolStats)
 }
 
 func TestGetKiroPooledHTTPClient_AppliesInsecureSkipVerify(t *testing.T) {
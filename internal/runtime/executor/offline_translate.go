@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// TranslatePayload prepares payload for an upstream the way executors do,
+// without sending it: schema translation, the output-limit, logprobs and seed
+// normalizers, thinking adaptation and the payload rules of cfg. provider is
+// the executor key the thinking and model lookups use; empty means to.
+func TranslatePayload(cfg *config.Config, from, to, provider, model string, payload []byte, stream bool) ([]byte, error) {
+	if provider == "" {
+		provider = to
+	}
+	fromFormat := sdktranslator.FromString(from)
+	toFormat := sdktranslator.FromString(to)
+	baseModel := thinking.ParseSuffix(model).ModelName
+
+	translated := sdktranslator.TranslateRequest(fromFormat, toFormat, baseModel, payload, stream)
+	original := translated
+	translated, err := applyThinkingWithUsageMeta(translated, model, fromFormat.String(), toFormat.String(), provider, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The CLI-style envelopes keep their generation options under "request".
+	protocol, root := toFormat.String(), ""
+	switch protocol {
+	case "gemini-cli":
+		protocol, root = "gemini", "request"
+	case "antigravity":
+		root = "request"
+	}
+	return applyPayloadConfigWithRoot(context.Background(), cfg, nil, baseModel, protocol, root, translated, original, model), nil
+}
+
+// StreamPayloads splits a raw upstream stream body into the payloads the
+// executor for to feeds the stream translator, including the synthetic
+// "[DONE]" the Gemini-family executors send once the body ends.
+func StreamPayloads(to string, body []byte) [][]byte {
+	var payloads [][]byte
+	for _, line := range bytes.Split(bytes.TrimSuffix(body, []byte("\n")), []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		switch to {
+		case "gemini", "antigravity":
+			if payload := jsonPayload(FilterSSEUsageMetadata(line)); payload != nil {
+				payloads = append(payloads, bytes.Clone(payload))
+			}
+		case "gemini-cli", "openai":
+			if bytes.HasPrefix(line, dataTag) {
+				payloads = append(payloads, bytes.Clone(line))
+			}
+		default:
+			payloads = append(payloads, bytes.Clone(line))
+		}
+	}
+	switch to {
+	case "gemini", "gemini-cli", "antigravity":
+		payloads = append(payloads, []byte("[DONE]"))
+	}
+	return payloads
+}
@@ -0,0 +1,31 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestTranslatePayloadAppliesServerPipeline(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Payload.Override = []config.PayloadRule{{
+		Models: []config.PayloadModelRule{{Name: "gemini-2.5-pro", Protocol: "gemini"}},
+		Params: map[string]any{"generationConfig.temperature": 0.25},
+	}}
+	payload := []byte(`{"model":"gemini-2.5-pro","messages":[{"role":"user","content":"hi"}],"reasoning_effort":"low"}`)
+
+	out, err := TranslatePayload(cfg, "openai", "gemini-cli", "", "gemini-2.5-pro", payload, false)
+	if err != nil {
+		t.Fatalf("TranslatePayload error: %v", err)
+	}
+	if got := gjson.GetBytes(out, "request.contents.0.parts.0.text").String(); got != "hi" {
+		t.Fatalf("contents not translated: %s", out)
+	}
+	if !gjson.GetBytes(out, "request.generationConfig.thinkingConfig").Exists() {
+		t.Fatalf("thinking not adapted: %s", out)
+	}
+	if got := gjson.GetBytes(out, "request.generationConfig.temperature").Float(); got != 0.25 {
+		t.Fatalf("payload rules not applied: %s", out)
+	}
+}
@@ -0,0 +1,110 @@
+package executor
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/forensics"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// calibrationPaths returns the temperature and top_p paths of a provider
+// schema.
+func calibrationPaths(protocol string) (temperature, topP string) {
+	switch protocol {
+	case "gemini", "gemini-cli", "antigravity":
+		return "generationConfig.temperature", "generationConfig.topP"
+	default:
+		return "temperature", "top_p"
+	}
+}
+
+// applyPayloadCalibration re-maps the sampling values of a translated payload
+// with the first matching calibration curve for each parameter and records
+// every change in the request's forensic bundle.
+func applyPayloadCalibration(ctx context.Context, rules []config.PayloadCalibrationRule, auth *cliproxyauth.Auth, candidates []string, model, protocol, root string, payload []byte) []byte {
+	if len(rules) == 0 {
+		return payload
+	}
+	provider := ""
+	if auth != nil {
+		provider = auth.Provider
+	}
+	temperaturePath, topPPath := calibrationPaths(protocol)
+	params := []struct {
+		name  string
+		path  string
+		curve func(*config.PayloadCalibrationRule) []config.CalibrationPoint
+	}{
+		{"temperature", temperaturePath, func(r *config.PayloadCalibrationRule) []config.CalibrationPoint { return r.Temperature }},
+		{"top_p", topPPath, func(r *config.PayloadCalibrationRule) []config.CalibrationPoint { return r.TopP }},
+	}
+	out := payload
+	for _, param := range params {
+		fullPath := buildPayloadPath(root, param.path)
+		value := gjson.GetBytes(out, fullPath)
+		if value.Type != gjson.Number {
+			continue
+		}
+		for i := range rules {
+			rule := &rules[i]
+			curve := param.curve(rule)
+			if len(curve) == 0 || !calibrationRuleMatches(rule, provider, protocol, candidates) {
+				continue
+			}
+			requested := value.Float()
+			applied := calibrate(curve, requested)
+			if applied != requested {
+				if updated, errSet := sjson.SetBytes(out, fullPath, applied); errSet == nil {
+					out = updated
+					forensics.FromContext(ctx).AddCalibration(provider, model, param.name, requested, applied)
+				}
+			}
+			break
+		}
+	}
+	return out
+}
+
+func calibrationRuleMatches(rule *config.PayloadCalibrationRule, provider, protocol string, candidates []string) bool {
+	if len(rule.Providers) > 0 {
+		matched := false
+		for _, p := range rule.Providers {
+			if strings.EqualFold(strings.TrimSpace(p), provider) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return len(rule.Models) == 0 || payloadModelRulesMatch(rule.Models, protocol, candidates)
+}
+
+// calibrate interpolates value along curve, whose points are sorted by From,
+// rounded to four decimals so float noise does not reach the upstream.
+func calibrate(curve []config.CalibrationPoint, value float64) float64 {
+	return math.Round(interpolate(curve, value)*1e4) / 1e4
+}
+
+func interpolate(curve []config.CalibrationPoint, value float64) float64 {
+	if value <= curve[0].From {
+		return curve[0].To
+	}
+	for i := 1; i < len(curve); i++ {
+		lo, hi := curve[i-1], curve[i]
+		if value > hi.From {
+			continue
+		}
+		if hi.From == lo.From {
+			return hi.To
+		}
+		return lo.To + (value-lo.From)*(hi.To-lo.To)/(hi.From-lo.From)
+	}
+	return curve[len(curve)-1].To
+}
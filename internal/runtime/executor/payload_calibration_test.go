@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyPayloadConfigWithRoot_Calibration(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Payload.Calibration = []config.PayloadCalibrationRule{
+		{
+			Providers:   []string{"claude"},
+			Temperature: []config.CalibrationPoint{{From: 0, To: 0}, {From: 2, To: 1}},
+		},
+		{
+			Providers:   []string{"gemini-cli"},
+			Models:      []config.PayloadModelRule{{Name: "gemini-2.5-*"}},
+			Temperature: []config.CalibrationPoint{{From: 0, To: 0}, {From: 1, To: 0.7}, {From: 2, To: 1.2}},
+			TopP:        []config.CalibrationPoint{{From: 0.5, To: 0.6}, {From: 1, To: 0.95}},
+		},
+		{
+			Temperature: []config.CalibrationPoint{{From: 0, To: 1}, {From: 2, To: 1}},
+		},
+	}
+	cfg.Payload.Override = []config.PayloadRule{{
+		Models: []config.PayloadModelRule{{Name: "gemini-2.5-flash"}},
+		Params: map[string]any{"generationConfig.maxOutputTokens": 10},
+	}}
+
+	auth := &cliproxyauth.Auth{Provider: "gemini-cli"}
+	payload := []byte(`{"request":{"generationConfig":{"temperature":1.5,"topP":0.2}}}`)
+	out := applyPayloadConfigWithRoot(context.Background(), cfg, auth, "gemini-2.5-flash", "gemini", "request", payload, payload, "gemini-2.5-flash")
+	if got := gjson.GetBytes(out, "request.generationConfig.temperature").Float(); got != 0.95 {
+		t.Fatalf("temperature = %v", got)
+	}
+	if got := gjson.GetBytes(out, "request.generationConfig.topP").Float(); got != 0.6 {
+		t.Fatalf("top_p below the curve = %v", got)
+	}
+	if gjson.GetBytes(out, "request.generationConfig.maxOutputTokens").Int() != 10 {
+		t.Fatalf("override rules should still apply: %s", out)
+	}
+
+	claude := applyPayloadConfigWithRoot(context.Background(), cfg, &cliproxyauth.Auth{Provider: "claude"}, "claude-sonnet-4", "claude", "", []byte(`{"temperature":1,"top_p":0.9}`), nil, "claude-sonnet-4")
+	if got := gjson.GetBytes(claude, "temperature").Float(); got != 0.5 {
+		t.Fatalf("claude temperature = %v", got)
+	}
+	if got := gjson.GetBytes(claude, "top_p").Float(); got != 0.9 {
+		t.Fatalf("top_p without a curve changed to %v", got)
+	}
+
+	other := applyPayloadConfigWithRoot(context.Background(), cfg, &cliproxyauth.Auth{Provider: "codex"}, "gpt-5", "codex", "", []byte(`{"temperature":0.3}`), nil, "gpt-5")
+	if got := gjson.GetBytes(other, "temperature").Float(); got != 1 {
+		t.Fatalf("catch-all temperature = %v", got)
+	}
+}
@@ -1,11 +1,14 @@
 package executor
 
 import (
+	"context"
 	"encoding/json"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/servicetier"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -16,12 +19,14 @@ import (
 // and restricts matches to the given protocol when supplied. Defaults are checked
 // against the original payload when provided. requestedModel carries the client-visible
 // model name before alias resolution so payload rules can target aliases precisely.
-func applyPayloadConfigWithRoot(cfg *config.Config, model, protocol, root string, payload, original []byte, requestedModel string) []byte {
+// Calibration rules run first; transform rules run last and may branch on the
+// client key and the credential in ctx and auth.
+func applyPayloadConfigWithRoot(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, model, protocol, root string, payload, original []byte, requestedModel string) []byte {
 	if cfg == nil || len(payload) == 0 {
 		return payload
 	}
 	rules := cfg.Payload
-	if len(rules.Default) == 0 && len(rules.DefaultRaw) == 0 && len(rules.Override) == 0 && len(rules.OverrideRaw) == 0 && len(rules.Filter) == 0 {
+	if len(rules.Default) == 0 && len(rules.DefaultRaw) == 0 && len(rules.Override) == 0 && len(rules.OverrideRaw) == 0 && len(rules.Filter) == 0 && len(rules.Transform) == 0 && len(rules.Calibration) == 0 {
 		return payload
 	}
 	model = strings.TrimSpace(model)
@@ -30,7 +35,9 @@ func applyPayloadConfigWithRoot(cfg *config.Config, model, protocol, root string
 		return payload
 	}
 	candidates := payloadModelCandidates(model, requestedModel)
-	out := payload
+	// Calibrate the client's sampling values first so the rules below can
+	// still set upstream values outright.
+	out := applyPayloadCalibration(ctx, rules.Calibration, auth, candidates, model, protocol, root, payload)
 	source := original
 	if len(source) == 0 {
 		source = payload
@@ -148,7 +155,7 @@ func applyPayloadConfigWithRoot(cfg *config.Config, model, protocol, root string
 			out = updated
 		}
 	}
-	return out
+	return applyPayloadTransforms(ctx, rules.Transform, auth, candidates, protocol, root, out, model, requestedModel)
 }
 
 func payloadModelRulesMatch(rules []config.PayloadModelRule, protocol string, models []string) bool {
@@ -317,3 +324,10 @@ func matchModelPattern(pattern, model string) bool {
 	}
 	return pi == len(pattern)
 }
+
+// applyServiceTier sets the provider-native tier option for the service tier
+// the client asked for.
+func applyServiceTier(body []byte, provider string, opts cliproxyexecutor.Options) []byte {
+	tier, _ := opts.Metadata[cliproxyexecutor.ServiceTierMetadataKey].(string)
+	return servicetier.Apply(body, provider, tier)
+}
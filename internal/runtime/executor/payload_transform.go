@@ -0,0 +1,199 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// payloadTransformData is the dot value of payload transform templates.
+type payloadTransformData struct {
+	Model          string
+	RequestedModel string
+	Protocol       string
+	Provider       string
+	APIKey         string
+	Labels         map[string]string
+	Body           any
+
+	raw []byte
+}
+
+// payloadTemplates caches parsed templates by source text; a nil entry marks
+// a template that failed to parse.
+var payloadTemplates sync.Map
+
+func payloadTemplate(text string) *template.Template {
+	if cached, ok := payloadTemplates.Load(text); ok {
+		tmpl, _ := cached.(*template.Template)
+		return tmpl
+	}
+	tmpl, err := template.New("payload").Option("missingkey=zero").Funcs(payloadTemplateFuncs).Parse(text)
+	if err != nil {
+		log.Warnf("payload transform: invalid template %q: %v", text, err)
+		tmpl = nil
+	}
+	payloadTemplates.Store(text, tmpl)
+	return tmpl
+}
+
+var payloadTemplateFuncs = template.FuncMap{
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"contains":  strings.Contains,
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	// match reports whether value matches a model pattern such as "gpt-*".
+	"match": func(pattern, value string) bool { return matchModelPattern(pattern, value) },
+	// default returns fallback when value is nil, false, zero or empty.
+	"default": func(fallback, value any) any {
+		if truth, _ := template.IsTrue(value); truth {
+			return value
+		}
+		return fallback
+	},
+	// json renders value as JSON, e.g. to copy an object from .Body.
+	"json": func(value any) (string, error) {
+		raw, err := json.Marshal(value)
+		return string(raw), err
+	},
+}
+
+// applyPayloadTransforms runs the transform rules matching the model
+// candidates on payload. A rule whose template fails is skipped.
+func applyPayloadTransforms(ctx context.Context, rules []config.PayloadTransformRule, auth *cliproxyauth.Auth, candidates []string, protocol, root string, payload []byte, model, requestedModel string) []byte {
+	if len(rules) == 0 {
+		return payload
+	}
+	out := payload
+	var data *payloadTransformData
+	for i := range rules {
+		rule := &rules[i]
+		if len(rule.Models) > 0 && !payloadModelRulesMatch(rule.Models, protocol, candidates) {
+			continue
+		}
+		if data == nil {
+			data = newPayloadTransformData(ctx, auth, protocol, root, payload, model, requestedModel)
+		}
+		updated, err := applyPayloadTransformRule(rule, data, root, out)
+		if err != nil {
+			log.Debugf("payload transform %q skipped: %v", rule.Name, err)
+			continue
+		}
+		out = updated
+	}
+	return out
+}
+
+func newPayloadTransformData(ctx context.Context, auth *cliproxyauth.Auth, protocol, root string, payload []byte, model, requestedModel string) *payloadTransformData {
+	raw := payload
+	if root != "" {
+		raw = []byte(gjson.GetBytes(payload, root).Raw)
+	}
+	data := &payloadTransformData{
+		Model:          model,
+		RequestedModel: requestedModel,
+		Protocol:       protocol,
+		APIKey:         apiKeyFromContext(ctx),
+		Labels:         map[string]string{},
+		raw:            raw,
+	}
+	_ = json.Unmarshal(raw, &data.Body)
+	if auth != nil {
+		data.Provider = auth.Provider
+		for key, value := range auth.Attributes {
+			if label, ok := strings.CutPrefix(key, "label:"); ok {
+				data.Labels[label] = value
+			}
+		}
+	}
+	return data
+}
+
+// Get returns the value at a gjson path of the body, e.g. "messages.0.role".
+func (d *payloadTransformData) Get(path string) any {
+	return gjson.GetBytes(d.raw, path).Value()
+}
+
+// Has reports whether a gjson path exists in the body.
+func (d *payloadTransformData) Has(path string) bool {
+	return gjson.GetBytes(d.raw, path).Exists()
+}
+
+func renderPayloadTemplate(text string, data *payloadTransformData) (string, error) {
+	tmpl := payloadTemplate(text)
+	if tmpl == nil {
+		return "", errInvalidPayloadTemplate
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	out := strings.TrimSpace(buf.String())
+	if out == "<no value>" {
+		// A bare missing .Body field renders like this; treat it as empty.
+		return "", nil
+	}
+	return out, nil
+}
+
+var errInvalidPayloadTemplate = errors.New("invalid template")
+
+func applyPayloadTransformRule(rule *config.PayloadTransformRule, data *payloadTransformData, root string, payload []byte) ([]byte, error) {
+	if strings.TrimSpace(rule.When) != "" {
+		cond, err := renderPayloadTemplate(rule.When, data)
+		if err != nil {
+			return payload, err
+		}
+		if cond != "true" {
+			return payload, nil
+		}
+	}
+	out := payload
+	for path, text := range rule.Set {
+		fullPath := buildPayloadPath(root, path)
+		if fullPath == "" {
+			continue
+		}
+		value, err := renderPayloadTemplate(text, data)
+		if err != nil {
+			return payload, err
+		}
+		if value == "" {
+			continue
+		}
+		var updated []byte
+		if json.Valid([]byte(value)) {
+			updated, err = sjson.SetRawBytes(out, fullPath, []byte(value))
+		} else {
+			updated, err = sjson.SetBytes(out, fullPath, value)
+		}
+		if err != nil {
+			return payload, err
+		}
+		out = updated
+	}
+	for _, path := range rule.Delete {
+		fullPath := buildPayloadPath(root, path)
+		if fullPath == "" {
+			continue
+		}
+		updated, err := sjson.DeleteBytes(out, fullPath)
+		if err != nil {
+			return payload, err
+		}
+		out = updated
+	}
+	return out, nil
+}
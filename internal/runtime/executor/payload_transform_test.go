@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyPayloadConfigWithRoot_Transform(t *testing.T) {
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Set("apiKey", "team-a-key")
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+	auth := &cliproxyauth.Auth{Provider: "openai-compatibility", Attributes: map[string]string{"label:team": "research"}}
+
+	cfg := &config.Config{}
+	cfg.Payload.Transform = []config.PayloadTransformRule{
+		{
+			Name:   "clamp-temperature",
+			Models: []config.PayloadModelRule{{Name: "gpt-*"}},
+			When:   `{{ gt (default 0.0 .Body.temperature) 1.0 }}`,
+			Set:    map[string]string{"temperature": "1"},
+		},
+		{
+			Name: "rename-max-tokens",
+			When: `{{ .Has "max_tokens" }}`,
+			Set:  map[string]string{"max_completion_tokens": `{{ json .Body.max_tokens }}`},
+			Delete: []string{
+				"max_tokens",
+			},
+		},
+		{
+			Name: "tag-by-key-and-label",
+			When: `{{ and (eq .APIKey "team-a-key") (eq (index .Labels "team") "research") }}`,
+			Set: map[string]string{
+				"metadata.tenant":   `{{ index .Labels "team" }}`,
+				"metadata.provider": `{{ .Provider }}`,
+				"metadata.first":    `{{ .Get "messages.0.role" }}`,
+				"metadata.missing":  `{{ .Body.nope }}`,
+			},
+		},
+		{
+			Name:   "other-model",
+			Models: []config.PayloadModelRule{{Name: "claude-*"}},
+			Delete: []string{"messages"},
+		},
+		{
+			Name: "broken",
+			When: `{{ .Body.temperature | nosuchfunc }}`,
+			Set:  map[string]string{"broken": "true"},
+		},
+	}
+
+	payload := []byte(`{"model":"gpt-5","temperature":1.7,"max_tokens":4096,"messages":[{"role":"user","content":"hi"}]}`)
+	out := applyPayloadConfigWithRoot(ctx, cfg, auth, "gpt-5", "openai", "", payload, payload, "gpt-5")
+
+	checks := map[string]string{
+		"temperature":           "1",
+		"max_completion_tokens": "4096",
+		"metadata.tenant":       "research",
+		"metadata.provider":     "openai-compatibility",
+		"metadata.first":        "user",
+	}
+	for path, want := range checks {
+		if got := gjson.GetBytes(out, path).String(); got != want {
+			t.Errorf("%s = %q, want %q (payload %s)", path, got, want, out)
+		}
+	}
+	for _, path := range []string{"max_tokens", "metadata.missing", "broken"} {
+		if gjson.GetBytes(out, path).Exists() {
+			t.Errorf("%s should not be set: %s", path, out)
+		}
+	}
+	if !gjson.GetBytes(out, "messages").Exists() {
+		t.Errorf("rule for another model was applied: %s", out)
+	}
+}
+
+func TestApplyPayloadConfigWithRoot_TransformRoot(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Payload.Transform = []config.PayloadTransformRule{{
+		When: `{{ match "gemini-*" .Model }}`,
+		Set:  map[string]string{"generationConfig.candidateCount": "1"},
+	}}
+	payload := []byte(`{"project":"p","request":{"contents":[]}}`)
+	out := applyPayloadConfigWithRoot(context.Background(), cfg, nil, "gemini-2.5-pro", "gemini", "request", payload, payload, "")
+	if got := gjson.GetBytes(out, "request.generationConfig.candidateCount").Int(); got != 1 {
+		t.Fatalf("candidateCount = %d, payload %s", got, out)
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/proxyutil"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/proxy"
 )
@@ -36,8 +37,14 @@ var (
 // 2. Use cfg.ProxyURL if auth proxy is not configured
 // 3. Use RoundTripper from context if neither are configured
 //
-// This function caches HTTP clients by proxy URL to enable TCP/TLS connection reuse.
-// It also applies upstream timeout configuration from cfg.UpstreamTimeouts.
+// This function caches HTTP clients by proxy URL to enable TCP/TLS connection reuse;
+// whether requests actually reuse connections is counted per provider. With
+// compression.upstream enabled, non-streaming responses arrive compressed and
+// are decoded before executors read them.
+// It also applies upstream timeout configuration from cfg.UpstreamTimeouts, and
+// during model executions injects headers configured for the provider or auth.
+// Registered upstream middleware runs next, and configured request signers
+// run last, just before the transport.
 //
 // Parameters:
 //   - ctx: The context containing optional RoundTripper
@@ -48,6 +55,14 @@ var (
 // Returns:
 //   - *http.Client: An HTTP client with configured proxy or transport
 func newProxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, timeout time.Duration) *http.Client {
+	client := withRequestSigning(withStageTrace(ctx, withForensics(ctx, withQuotaTracking(ctx, withConnectionStats(withResponseDecompression(proxyAwareHTTPClient(ctx, cfg, auth, timeout), cfg), auth), auth))), cfg, auth)
+	client = withRequestMiddleware(ctx, client, auth)
+	return withUpstreamHeaders(ctx, client, cfg, auth)
+}
+
+// proxyAwareHTTPClient resolves the proxy, timeout and cached transport for
+// newProxyAwareHTTPClient.
+func proxyAwareHTTPClient(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, timeout time.Duration) *http.Client {
 	// Priority 1: Use auth.ProxyURL if configured
 	var proxyURL string
 	if auth != nil {
@@ -167,12 +182,13 @@ func buildDefaultTransportWithTimeouts(connectTimeoutSec, responseHeaderTimeoutS
 	// Apply connect timeout via custom Dialer
 	if connectTimeoutSec > 0 {
 		connectTimeout := time.Duration(connectTimeoutSec) * time.Second
-		transport.DialContext = (&net.Dialer{
+		transport.DialContext = proxyutil.Track((&net.Dialer{
 			Timeout:   connectTimeout,
 			KeepAlive: 30 * time.Second,
-		}).DialContext
+		}).DialContext)
 		log.Debugf("applied connect timeout: %v", connectTimeout)
 	} else if connectTimeoutSec == 0 {
+		transport.DialContext = proxyutil.Track(transport.DialContext)
 		log.Warnf("connect-timeout-seconds is 0, using Go default (no explicit timeout)")
 	}
 
@@ -244,31 +260,15 @@ func buildProxyTransport(proxyURL string, connectTimeoutSec, responseHeaderTimeo
 			log.Warnf("proxy transport (SOCKS5): connect-timeout-seconds is 0, using fallback 30s")
 		}
 
-		// Set up a custom DialContext using the SOCKS5 dialer with timeout
-		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
-			// Create a context with timeout for the dial operation
+		// Set up a custom DialContext using the SOCKS5 dialer with timeout. The
+		// dial is canceled with its context, so an abandoned dial leaves no
+		// goroutine or connection behind.
+		dial := proxyutil.ContextDialFunc(dialer)
+		transport.DialContext = proxyutil.Track(func(ctx context.Context, network, addr string) (net.Conn, error) {
 			dialCtx, cancel := context.WithTimeout(ctx, connectTimeout)
 			defer cancel()
-
-			// Use a channel to handle the dial result
-			type dialResult struct {
-				conn net.Conn
-				err  error
-			}
-			resultCh := make(chan dialResult, 1)
-
-			go func() {
-				conn, err := dialer.Dial(network, addr)
-				resultCh <- dialResult{conn: conn, err: err}
-			}()
-
-			select {
-			case <-dialCtx.Done():
-				return nil, dialCtx.Err()
-			case result := <-resultCh:
-				return result.conn, result.err
-			}
-		}
+			return dial(dialCtx, network, addr)
+		})
 		log.Debugf("proxy transport (SOCKS5): applied connect timeout: %v", connectTimeout)
 
 	} else if parsedURL.Scheme == "http" || parsedURL.Scheme == "https" {
@@ -278,12 +278,13 @@ func buildProxyTransport(proxyURL string, connectTimeoutSec, responseHeaderTimeo
 		// Apply connect timeout via custom Dialer for HTTP/HTTPS proxy
 		if connectTimeoutSec > 0 {
 			connectTimeout := time.Duration(connectTimeoutSec) * time.Second
-			transport.DialContext = (&net.Dialer{
+			transport.DialContext = proxyutil.Track((&net.Dialer{
 				Timeout:   connectTimeout,
 				KeepAlive: 30 * time.Second,
-			}).DialContext
+			}).DialContext)
 			log.Debugf("proxy transport (HTTP/HTTPS): applied connect timeout: %v", connectTimeout)
 		} else if connectTimeoutSec == 0 {
+			transport.DialContext = proxyutil.Track(transport.DialContext)
 			log.Warnf("proxy transport (HTTP/HTTPS): connect-timeout-seconds is 0, using Go default")
 		}
 	} else {
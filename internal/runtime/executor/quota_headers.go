@@ -0,0 +1,256 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// quotaErrorBodyLimit bounds how much of a 429 body is inspected for quota
+// details; the body is handed on unchanged.
+const quotaErrorBodyLimit = 64 << 10
+
+// defaultGeminiQuotaReset is assumed when a Gemini quota error carries no
+// retry delay.
+const defaultGeminiQuotaReset = time.Minute
+
+// quotaTransport records the quota an upstream reports in its response headers
+// or quota errors against the auth that made the request.
+type quotaTransport struct {
+	base   http.RoundTripper
+	authID string
+}
+
+func (t *quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	now := time.Now()
+	headroom := parseQuotaHeaders(resp.Header, now)
+	if resp.StatusCode == http.StatusTooManyRequests && resp.Body != nil {
+		body, errRead := io.ReadAll(io.LimitReader(resp.Body, quotaErrorBodyLimit))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		if errRead == nil {
+			if gemini := parseGeminiQuotaError(body, now); gemini != nil {
+				headroom = gemini
+			}
+		}
+	}
+	if headroom != nil {
+		headroom.UpdatedAt = now
+		cliproxyauth.RecordQuotaHeadroom(t.authID, *headroom)
+	}
+	return resp, nil
+}
+
+// withQuotaTracking wraps client so quota reported on model executions is
+// kept for the auth and taken into account when picking credentials.
+func withQuotaTracking(ctx context.Context, client *http.Client, auth *cliproxyauth.Auth) *http.Client {
+	if client == nil || auth == nil || auth.ID == "" {
+		return client
+	}
+	if _, ok := cliproxyexecutor.UpstreamModel(ctx); !ok {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport:     &quotaTransport{base: base, authID: auth.ID},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
+
+// parseQuotaHeaders reads the quota headers of Anthropic, GitHub Copilot,
+// Codex and OpenAI-style upstreams. It returns nil when none are present.
+func parseQuotaHeaders(h http.Header, now time.Time) *cliproxyauth.QuotaHeadroom {
+	for _, parse := range []func(http.Header, time.Time) *cliproxyauth.QuotaHeadroom{
+		parseAnthropicRateLimits,
+		parseCopilotQuotaSnapshots,
+		parseCodexUsageHeaders,
+		parseOpenAIRateLimits,
+	} {
+		if headroom := parse(h, now); headroom != nil {
+			return headroom
+		}
+	}
+	return nil
+}
+
+// quotaWindow builds a window from a limit and remaining count, skipping
+// windows whose limit is unknown.
+func quotaWindow(name, limit, remaining string, resetAt time.Time) (cliproxyauth.QuotaWindow, bool) {
+	l, errLimit := strconv.ParseFloat(strings.TrimSpace(limit), 64)
+	r, errRemaining := strconv.ParseFloat(strings.TrimSpace(remaining), 64)
+	if errLimit != nil || errRemaining != nil || l <= 0 {
+		return cliproxyauth.QuotaWindow{}, false
+	}
+	return cliproxyauth.QuotaWindow{
+		Name:              name,
+		Limit:             l,
+		Remaining:         r,
+		RemainingFraction: clampFraction(r / l),
+		ResetAt:           resetAt,
+	}, true
+}
+
+func clampFraction(f float64) float64 {
+	switch {
+	case f < 0:
+		return 0
+	case f > 1:
+		return 1
+	default:
+		return f
+	}
+}
+
+func quotaHeadroom(source string, windows []cliproxyauth.QuotaWindow) *cliproxyauth.QuotaHeadroom {
+	if len(windows) == 0 {
+		return nil
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Name < windows[j].Name })
+	return &cliproxyauth.QuotaHeadroom{Source: source, Windows: windows}
+}
+
+// parseAnthropicRateLimits reads the anthropic-ratelimit-{requests,tokens,
+// input-tokens,output-tokens}-* triplets of API keys and the unified
+// utilization windows reported for subscription accounts.
+func parseAnthropicRateLimits(h http.Header, _ time.Time) *cliproxyauth.QuotaHeadroom {
+	var windows []cliproxyauth.QuotaWindow
+	for _, name := range []string{"requests", "tokens", "input-tokens", "output-tokens"} {
+		prefix := "Anthropic-Ratelimit-" + name
+		resetAt, _ := time.Parse(time.RFC3339, strings.TrimSpace(h.Get(prefix+"-reset")))
+		if window, ok := quotaWindow(name, h.Get(prefix+"-limit"), h.Get(prefix+"-remaining"), resetAt); ok {
+			windows = append(windows, window)
+		}
+	}
+	const unified = "anthropic-ratelimit-unified-"
+	for key, values := range h {
+		lower := strings.ToLower(key)
+		if !strings.HasPrefix(lower, unified) || !strings.HasSuffix(lower, "-utilization") || len(values) == 0 {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(lower, unified), "-utilization")
+		utilization, err := strconv.ParseFloat(strings.TrimSpace(values[0]), 64)
+		if err != nil {
+			continue
+		}
+		window := cliproxyauth.QuotaWindow{Name: "unified-" + name, RemainingFraction: clampFraction(1 - utilization)}
+		if reset, errReset := strconv.ParseInt(strings.TrimSpace(h.Get(unified+name+"-reset")), 10, 64); errReset == nil && reset > 0 {
+			window.ResetAt = time.Unix(reset, 0)
+		}
+		windows = append(windows, window)
+	}
+	return quotaHeadroom("anthropic", windows)
+}
+
+// parseCopilotQuotaSnapshots reads x-quota-snapshot-<quota> headers such as
+// "ent=300&ov=0.0&ovPerm=false&rem=85.3&rst=2026-11-01T00:00:00Z", where rem is
+// the percentage left. Unlimited quotas (ent=-1) are skipped.
+func parseCopilotQuotaSnapshots(h http.Header, _ time.Time) *cliproxyauth.QuotaHeadroom {
+	const prefix = "x-quota-snapshot-"
+	var windows []cliproxyauth.QuotaWindow
+	for key, values := range h {
+		lower := strings.ToLower(key)
+		if !strings.HasPrefix(lower, prefix) || len(values) == 0 {
+			continue
+		}
+		fields, err := url.ParseQuery(values[0])
+		if err != nil {
+			continue
+		}
+		entitlement, errEnt := strconv.ParseFloat(fields.Get("ent"), 64)
+		percent, errRem := strconv.ParseFloat(fields.Get("rem"), 64)
+		if errEnt != nil || errRem != nil || entitlement < 0 {
+			continue
+		}
+		window := cliproxyauth.QuotaWindow{
+			Name:              strings.TrimPrefix(lower, prefix),
+			Limit:             entitlement,
+			Remaining:         entitlement * percent / 100,
+			RemainingFraction: clampFraction(percent / 100),
+		}
+		window.ResetAt, _ = time.Parse(time.RFC3339, fields.Get("rst"))
+		windows = append(windows, window)
+	}
+	return quotaHeadroom("github-copilot", windows)
+}
+
+// parseCodexUsageHeaders reads the x-codex-{primary,secondary}-used-percent
+// windows of ChatGPT subscription accounts.
+func parseCodexUsageHeaders(h http.Header, now time.Time) *cliproxyauth.QuotaHeadroom {
+	var windows []cliproxyauth.QuotaWindow
+	for _, name := range []string{"primary", "secondary"} {
+		used, err := strconv.ParseFloat(strings.TrimSpace(h.Get("X-Codex-"+name+"-Used-Percent")), 64)
+		if err != nil {
+			continue
+		}
+		window := cliproxyauth.QuotaWindow{Name: name, RemainingFraction: clampFraction(1 - used/100)}
+		if after, errAfter := strconv.ParseInt(strings.TrimSpace(h.Get("X-Codex-"+name+"-Reset-After-Seconds")), 10, 64); errAfter == nil && after >= 0 {
+			window.ResetAt = now.Add(time.Duration(after) * time.Second)
+		}
+		windows = append(windows, window)
+	}
+	return quotaHeadroom("codex", windows)
+}
+
+// parseOpenAIRateLimits reads x-ratelimit-{limit,remaining,reset}-{requests,
+// tokens}, whose reset is a duration such as "6m0s".
+func parseOpenAIRateLimits(h http.Header, now time.Time) *cliproxyauth.QuotaHeadroom {
+	var windows []cliproxyauth.QuotaWindow
+	for _, name := range []string{"requests", "tokens"} {
+		var resetAt time.Time
+		if reset, err := time.ParseDuration(strings.TrimSpace(h.Get("X-Ratelimit-Reset-" + name))); err == nil {
+			resetAt = now.Add(reset)
+		}
+		if window, ok := quotaWindow(name, h.Get("X-Ratelimit-Limit-"+name), h.Get("X-Ratelimit-Remaining-"+name), resetAt); ok {
+			windows = append(windows, window)
+		}
+	}
+	return quotaHeadroom("ratelimit", windows)
+}
+
+// parseGeminiQuotaError turns a Google RESOURCE_EXHAUSTED error into an
+// exhausted window named after the violated quota, resetting after the
+// error's retry delay.
+func parseGeminiQuotaError(body []byte, now time.Time) *cliproxyauth.QuotaHeadroom {
+	if gjson.GetBytes(body, "error.status").String() != "RESOURCE_EXHAUSTED" {
+		return nil
+	}
+	name := "quota"
+	for _, detail := range gjson.GetBytes(body, "error.details").Array() {
+		if detail.Get("@type").String() != "type.googleapis.com/google.rpc.QuotaFailure" {
+			continue
+		}
+		violation := detail.Get("violations.0")
+		if id := violation.Get("quotaId").String(); id != "" {
+			name = id
+		} else if metric := violation.Get("quotaMetric").String(); metric != "" {
+			name = metric
+		}
+		break
+	}
+	reset := defaultGeminiQuotaReset
+	if delay, err := parseRetryDelay(body); err == nil && delay != nil {
+		reset = *delay
+	}
+	return quotaHeadroom("gemini", []cliproxyauth.QuotaWindow{{Name: name, RemainingFraction: 0, ResetAt: now.Add(reset)}})
+}
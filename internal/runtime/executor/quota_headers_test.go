@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func quotaWindowByName(t *testing.T, h *cliproxyauth.QuotaHeadroom, name string) cliproxyauth.QuotaWindow {
+	t.Helper()
+	if h == nil {
+		t.Fatalf("no headroom parsed, want window %q", name)
+	}
+	for _, window := range h.Windows {
+		if window.Name == name {
+			return window
+		}
+	}
+	t.Fatalf("window %q missing from %+v", name, h.Windows)
+	return cliproxyauth.QuotaWindow{}
+}
+
+func TestParseQuotaHeaders_Anthropic(t *testing.T) {
+	h := http.Header{}
+	h.Set("anthropic-ratelimit-requests-limit", "50")
+	h.Set("anthropic-ratelimit-requests-remaining", "5")
+	h.Set("anthropic-ratelimit-requests-reset", "2026-10-14T12:00:30Z")
+	h.Set("anthropic-ratelimit-unified-5h-utilization", "0.25")
+	h.Set("anthropic-ratelimit-unified-5h-reset", "1792000000")
+
+	got := parseQuotaHeaders(h, time.Now())
+	requests := quotaWindowByName(t, got, "requests")
+	if requests.RemainingFraction != 0.1 || !requests.ResetAt.Equal(time.Date(2026, 10, 14, 12, 0, 30, 0, time.UTC)) {
+		t.Fatalf("requests window = %+v", requests)
+	}
+	unified := quotaWindowByName(t, got, "unified-5h")
+	if unified.RemainingFraction != 0.75 || unified.ResetAt.Unix() != 1792000000 {
+		t.Fatalf("unified window = %+v", unified)
+	}
+	if got.Source != "anthropic" {
+		t.Fatalf("Source = %q", got.Source)
+	}
+}
+
+func TestParseQuotaHeaders_CopilotSnapshots(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Quota-Snapshot-Premium_Interactions", "ent=300&ov=0.0&ovPerm=false&rem=20&rst=2026-11-01T00:00:00Z")
+	h.Set("X-Quota-Snapshot-Chat", "ent=-1&ov=0.0&ovPerm=false&rem=100&rst=2026-11-01T00:00:00Z")
+
+	got := parseQuotaHeaders(h, time.Now())
+	if got == nil || len(got.Windows) != 1 {
+		t.Fatalf("parseQuotaHeaders() = %+v, want only the limited quota", got)
+	}
+	premium := quotaWindowByName(t, got, "premium_interactions")
+	if premium.RemainingFraction != 0.2 || premium.Remaining != 60 || premium.ResetAt.IsZero() {
+		t.Fatalf("premium window = %+v", premium)
+	}
+}
+
+func TestParseQuotaHeaders_OpenAIResetDurations(t *testing.T) {
+	now := time.Now()
+	h := http.Header{}
+	h.Set("x-ratelimit-limit-tokens", "1000")
+	h.Set("x-ratelimit-remaining-tokens", "250")
+	h.Set("x-ratelimit-reset-tokens", "6m0s")
+
+	tokens := quotaWindowByName(t, parseQuotaHeaders(h, now), "tokens")
+	if tokens.RemainingFraction != 0.25 || !tokens.ResetAt.Equal(now.Add(6*time.Minute)) {
+		t.Fatalf("tokens window = %+v", tokens)
+	}
+	if got := parseQuotaHeaders(http.Header{"Content-Type": {"application/json"}}, now); got != nil {
+		t.Fatalf("parseQuotaHeaders() without quota headers = %+v", got)
+	}
+}
+
+func TestParseGeminiQuotaError(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[
+		{"@type":"type.googleapis.com/google.rpc.QuotaFailure","violations":[{"quotaId":"GenerateRequestsPerMinutePerProjectPerModel"}]},
+		{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"30s"}]}}`)
+
+	window := quotaWindowByName(t, parseGeminiQuotaError(body, now), "GenerateRequestsPerMinutePerProjectPerModel")
+	if window.RemainingFraction != 0 || !window.ResetAt.Equal(now.Add(30*time.Second)) {
+		t.Fatalf("quota window = %+v", window)
+	}
+	if got := parseGeminiQuotaError([]byte(`{"error":{"status":"UNAVAILABLE"}}`), now); got != nil {
+		t.Fatalf("parseGeminiQuotaError(non-quota) = %+v", got)
+	}
+}
+
+func TestWithQuotaTracking_RecordsAndPreservesBody(t *testing.T) {
+	const body = `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	auth := &cliproxyauth.Auth{ID: "quota-tracking-test"}
+	ctx := cliproxyexecutor.WithUpstreamModel(context.Background(), "gemini-2.5-pro")
+	client := withQuotaTracking(ctx, &http.Client{}, auth)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if strings.TrimSpace(string(got)) != body {
+		t.Fatalf("body = %q, want it unchanged", got)
+	}
+	headroom, ok := cliproxyauth.QuotaHeadroomFor(auth.ID)
+	if !ok || headroom.Source != "gemini" || headroom.RemainingFraction(time.Now()) != 0 {
+		t.Fatalf("QuotaHeadroomFor() = %+v, %v", headroom, ok)
+	}
+
+	if plain := withQuotaTracking(context.Background(), &http.Client{}, auth); plain.Transport != nil {
+		t.Fatalf("client outside model executions was wrapped")
+	}
+}
@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/middleware"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/signing"
+)
+
+// requestMiddlewareTransport runs the post-translation and pre-upstream
+// middleware stages on the final upstream payload.
+type requestMiddlewareTransport struct {
+	base    http.RoundTripper
+	request *middleware.Request
+	auth    *cliproxyauth.Auth
+}
+
+func (t *requestMiddlewareTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	out := req.Clone(ctx)
+	model, _ := cliproxyexecutor.UpstreamModel(ctx)
+	body, errBody := signing.RequestBody(out)
+	if errBody != nil {
+		return nil, errBody
+	}
+	if body != nil {
+		translated := &middleware.TranslatedRequest{Request: t.request, Auth: t.auth, Model: model, Payload: body}
+		if errRun := middleware.RunPostTranslation(ctx, translated); errRun != nil {
+			return nil, errRun
+		}
+		if !bytes.Equal(translated.Payload, body) {
+			payload := translated.Payload
+			out.Body = io.NopCloser(bytes.NewReader(payload))
+			out.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(payload)), nil
+			}
+			out.ContentLength = int64(len(payload))
+		}
+	}
+	upstream := &middleware.UpstreamRequest{Request: t.request, Auth: t.auth, Model: model, HTTP: out}
+	if errRun := middleware.RunPreUpstream(ctx, upstream); errRun != nil {
+		return nil, errRun
+	}
+	return t.base.RoundTrip(upstream.HTTP)
+}
+
+// withRequestMiddleware wraps client so model executions started by an API
+// handler pass through the registered upstream middleware.
+func withRequestMiddleware(ctx context.Context, client *http.Client, auth *cliproxyauth.Auth) *http.Client {
+	if client == nil || !middleware.HasUpstreamMiddleware() {
+		return client
+	}
+	request := middleware.RequestFromContext(ctx)
+	if request == nil {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport:     &requestMiddlewareTransport{base: base, request: request, auth: auth},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
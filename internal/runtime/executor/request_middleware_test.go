@@ -0,0 +1,64 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/middleware"
+)
+
+func TestNewProxyAwareHTTPClient_RunsUpstreamMiddleware(t *testing.T) {
+	var gotBody, gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody, gotHeader = string(body), r.Header.Get("X-Rewritten-By")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	middleware.UsePostTranslation("test-rewrite", func(_ context.Context, req *middleware.TranslatedRequest) error {
+		req.Payload = []byte(strings.ReplaceAll(string(req.Payload), "secret", "[redacted]"))
+		req.Request.Values["model"] = req.Model
+		return nil
+	})
+	middleware.UsePreUpstream("test-rewrite", func(_ context.Context, req *middleware.UpstreamRequest) error {
+		req.HTTP.Header.Set("X-Rewritten-By", req.Auth.ID+":"+req.Request.Values["model"].(string))
+		return nil
+	})
+	defer middleware.Remove("test-rewrite")
+
+	mwReq := &middleware.Request{Values: map[string]any{}}
+	ctx := middleware.WithRequest(cliproxyexecutor.WithUpstreamModel(context.Background(), "gpt-5"), mwReq)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader(`{"input":"secret"}`))
+	resp, errDo := newProxyAwareHTTPClient(ctx, &config.Config{}, &cliproxyauth.Auth{ID: "codex-1", Provider: "codex"}, 0).Do(req)
+	if errDo != nil {
+		t.Fatalf("do: %v", errDo)
+	}
+	_ = resp.Body.Close()
+
+	if gotBody != `{"input":"[redacted]"}` {
+		t.Fatalf("body = %q", gotBody)
+	}
+	if gotHeader != "codex-1:gpt-5" {
+		t.Fatalf("X-Rewritten-By = %q", gotHeader)
+	}
+}
+
+func TestNewProxyAwareHTTPClient_SkipsMiddlewareOutsideHandlerRequests(t *testing.T) {
+	middleware.UsePreUpstream("test-fail", func(context.Context, *middleware.UpstreamRequest) error {
+		return middleware.Reject(http.StatusForbidden, "blocked")
+	})
+	defer middleware.Remove("test-fail")
+
+	client := &http.Client{}
+	if got := withRequestMiddleware(context.Background(), client, nil); got != client {
+		t.Fatal("requests without a middleware request should keep the original client")
+	}
+}
@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/signing"
+)
+
+// requestSigningTransport runs the configured signers on every outgoing
+// request immediately before handing it to the base transport.
+type requestSigningTransport struct {
+	base    http.RoundTripper
+	entries []config.RequestSigner
+	auth    *cliproxyauth.Auth
+}
+
+func (t *requestSigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Signers mutate headers and may buffer the body, so work on a clone.
+	out := req.Clone(req.Context())
+	if errSign := signUpstreamRequest(out.Context(), out, t.entries, t.auth); errSign != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, errSign
+	}
+	return t.base.RoundTrip(out)
+}
+
+// withRequestSigning wraps client so that requests sent for auth are signed by
+// the request-signing entries that apply to its provider.
+func withRequestSigning(client *http.Client, cfg *config.Config, auth *cliproxyauth.Auth) *http.Client {
+	if client == nil {
+		return nil
+	}
+	entries := requestSignersFor(cfg, auth)
+	if len(entries) == 0 {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport:     &requestSigningTransport{base: base, entries: entries, auth: auth},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
+
+// signWebsocketHandshake signs the handshake of a websocket dial, which does
+// not go through http.Client. The returned headers include the signature.
+func signWebsocketHandshake(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth, wsURL string, headers http.Header) (http.Header, error) {
+	entries := requestSignersFor(cfg, auth)
+	if len(entries) == 0 {
+		return headers, nil
+	}
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, wsURL, nil)
+	if errReq != nil {
+		return nil, errReq
+	}
+	req.Header = headers.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if errSign := signUpstreamRequest(ctx, req, entries, auth); errSign != nil {
+		return nil, errSign
+	}
+	return req.Header, nil
+}
+
+func requestSignersFor(cfg *config.Config, auth *cliproxyauth.Auth) []config.RequestSigner {
+	if cfg == nil || len(cfg.RequestSigning) == 0 {
+		return nil
+	}
+	keys := authProviderKeys(auth)
+	var out []config.RequestSigner
+	for _, entry := range cfg.RequestSigning {
+		if requestSignerApplies(entry, keys) {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+func requestSignerApplies(entry config.RequestSigner, keys []string) bool {
+	if len(entry.Providers) == 0 {
+		return true
+	}
+	for _, provider := range entry.Providers {
+		for _, key := range keys {
+			if strings.EqualFold(strings.TrimSpace(provider), key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func signUpstreamRequest(ctx context.Context, req *http.Request, entries []config.RequestSigner, auth *cliproxyauth.Auth) error {
+	for _, entry := range entries {
+		signer, errSigner := buildRequestSigner(entry)
+		if errSigner != nil {
+			return errSigner
+		}
+		if errSign := signer.SignRequest(ctx, req, auth); errSign != nil {
+			return fmt.Errorf("request signing (%s): %w", entry.Type, errSign)
+		}
+	}
+	return nil
+}
+
+// buildRequestSigner resolves entry to a built-in or registered signer.
+// Unknown types fail the request rather than sending it unsigned.
+func buildRequestSigner(entry config.RequestSigner) (signing.Signer, error) {
+	switch strings.ToLower(strings.TrimSpace(entry.Type)) {
+	case "hmac":
+		return signing.NewHMACSigner(signing.HMACOptions{
+			Secret:          entry.HMAC.Secret,
+			KeyID:           entry.HMAC.KeyID,
+			Algorithm:       entry.HMAC.Algorithm,
+			SignatureHeader: entry.HMAC.SignatureHeader,
+			TimestampHeader: entry.HMAC.TimestampHeader,
+			KeyIDHeader:     entry.HMAC.KeyIDHeader,
+		})
+	case "sigv4":
+		return signing.NewSigV4Signer(signing.SigV4Options{
+			AccessKeyID:     entry.SigV4.AccessKeyID,
+			SecretAccessKey: entry.SigV4.SecretAccessKey,
+			SessionToken:    entry.SigV4.SessionToken,
+			Region:          entry.SigV4.Region,
+			Service:         entry.SigV4.Service,
+		})
+	}
+	if signer, ok := signing.LookupSigner(entry.Type); ok {
+		return signer, nil
+	}
+	return nil, fmt.Errorf("request signing: signer %q is not registered", entry.Type)
+}
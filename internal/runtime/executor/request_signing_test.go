@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/signing"
+)
+
+func TestNewProxyAwareHTTPClient_SignsAfterHeaderInjection(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	signing.RegisterSigner("test-egress", signing.SignerFunc(func(_ context.Context, req *http.Request, auth *cliproxyauth.Auth) error {
+		// The signature covers headers injected earlier in the chain.
+		req.Header.Set("X-Egress-Signature", auth.ID+":"+req.Header.Get("X-Team"))
+		return nil
+	}))
+	defer signing.UnregisterSigner("test-egress")
+
+	cfg := &config.Config{
+		ProviderHeaders: map[string]map[string]string{"codex": {"X-Team": "platform"}},
+		RequestSigning: []config.RequestSigner{
+			{Type: "test-egress", Providers: []string{"codex"}},
+			{Type: "hmac", Providers: []string{"claude"}, HMAC: config.RequestSignerHMAC{Secret: "unused"}},
+		},
+	}
+	auth := &cliproxyauth.Auth{ID: "codex-1", Provider: "codex"}
+	ctx := cliproxyexecutor.WithUpstreamModel(context.Background(), "gpt-5")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader("{}"))
+	resp, errDo := newProxyAwareHTTPClient(ctx, cfg, auth, 0).Do(req)
+	if errDo != nil {
+		t.Fatalf("do: %v", errDo)
+	}
+	_ = resp.Body.Close()
+
+	if v := got.Get("X-Egress-Signature"); v != "codex-1:platform" {
+		t.Fatalf("X-Egress-Signature = %q", v)
+	}
+	if v := got.Get(signing.DefaultHMACSignatureHeader); v != "" {
+		t.Fatalf("signer scoped to claude should not run for codex, got %q", v)
+	}
+}
+
+func TestNewProxyAwareHTTPClient_UnknownSignerFailsClosed(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{RequestSigning: []config.RequestSigner{{Type: "missing"}}}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, errDo := newProxyAwareHTTPClient(context.Background(), cfg, &cliproxyauth.Auth{Provider: "claude"}, 0).Do(req)
+	if resp != nil {
+		_ = resp.Body.Close()
+	}
+	if errDo == nil || !strings.Contains(errDo.Error(), `signer "missing" is not registered`) {
+		t.Fatalf("expected unregistered signer error, got %v", errDo)
+	}
+	if hits != 0 {
+		t.Fatalf("request must not reach upstream unsigned, hits = %d", hits)
+	}
+}
+
+func TestSignWebsocketHandshake_AddsSignatureHeaders(t *testing.T) {
+	cfg := &config.Config{RequestSigning: []config.RequestSigner{{
+		Type: "hmac",
+		HMAC: config.RequestSignerHMAC{Secret: "s3cret", SignatureHeader: "X-Gw-Sig"},
+	}}}
+	headers := http.Header{"Authorization": {"Bearer token"}}
+	signed, errSign := signWebsocketHandshake(context.Background(), cfg, &cliproxyauth.Auth{Provider: "codex"}, "wss://chatgpt.com/backend-api/codex/responses", headers)
+	if errSign != nil {
+		t.Fatalf("signWebsocketHandshake: %v", errSign)
+	}
+	if signed.Get("X-Gw-Sig") == "" || signed.Get("Authorization") != "Bearer token" {
+		t.Fatalf("unexpected signed headers: %v", signed)
+	}
+	if headers.Get("X-Gw-Sig") != "" {
+		t.Fatal("input headers must not be mutated")
+	}
+}
@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// upstreamAcceptEncoding lists the encodings requested from upstreams when
+// compression.upstream is enabled, in order of preference.
+const upstreamAcceptEncoding = "zstd, br, gzip"
+
+// decompressingTransport asks for compressed non-streaming responses and
+// hands executors decoded bodies, as the standard transport does for gzip.
+type decompressingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *decompressingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Executors that negotiate the encoding themselves, and streams, which a
+	// compressor would hold back while filling its window, are left alone.
+	if req.Header.Get("Accept-Encoding") != "" || isStreamingRequest(req) {
+		return t.base.RoundTrip(req)
+	}
+	// RoundTrippers must not mutate the caller's request.
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", upstreamAcceptEncoding)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp == nil || resp.Body == nil {
+		return resp, err
+	}
+	encoding := strings.TrimSpace(resp.Header.Get("Content-Encoding"))
+	if encoding == "" || strings.EqualFold(encoding, "identity") {
+		return resp, nil
+	}
+	body, errDecode := decodeResponseBody(resp.Body, encoding)
+	if errDecode != nil {
+		return nil, errDecode
+	}
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// isStreamingRequest reports whether req asks for a server-sent event stream.
+func isStreamingRequest(req *http.Request) bool {
+	if strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		return true
+	}
+	return req.URL != nil && req.URL.Query().Get("alt") == "sse"
+}
+
+// withResponseDecompression wraps client so that, with compression.upstream
+// enabled, non-streaming responses travel compressed from the upstream.
+func withResponseDecompression(client *http.Client, cfg *config.Config) *http.Client {
+	if client == nil || cfg == nil || !cfg.Compression.Upstream {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport:     &decompressingTransport{base: base},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
@@ -0,0 +1,64 @@
+package executor
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestWithResponseDecompression_DecodesNonStreamingResponses(t *testing.T) {
+	var acceptEncodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncodings = append(acceptEncodings, r.Header.Get("Accept-Encoding"))
+		if r.Header.Get("Accept-Encoding") != upstreamAcceptEncoding {
+			_, _ = io.WriteString(w, `{"plain":true}`)
+			return
+		}
+		w.Header().Set("Content-Encoding", "zstd")
+		encoder, _ := zstd.NewWriter(w)
+		_, _ = io.WriteString(encoder, `{"compressed":true}`)
+		_ = encoder.Close()
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{SDKConfig: config.SDKConfig{Compression: config.CompressionConfig{Upstream: true}}}
+	client := withResponseDecompression(&http.Client{}, cfg)
+	read := func(req *http.Request) (string, *http.Response) {
+		t.Helper()
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		return string(body), resp
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, nil)
+	if body, resp := read(req); body != `{"compressed":true}` || resp.Header.Get("Content-Encoding") != "" || !resp.Uncompressed {
+		t.Fatalf("body = %s, headers = %v", body, resp.Header)
+	}
+	if req.Header.Get("Accept-Encoding") != "" {
+		t.Fatal("caller's request was modified")
+	}
+
+	stream, _ := http.NewRequest(http.MethodPost, server.URL, nil)
+	stream.Header.Set("Accept", "text/event-stream")
+	if body, _ := read(stream); body != `{"plain":true}` {
+		t.Fatalf("streaming body = %s", body)
+	}
+	if acceptEncodings[1] == upstreamAcceptEncoding {
+		t.Fatalf("streaming request asked for %q", acceptEncodings[1])
+	}
+
+	if disabled := withResponseDecompression(&http.Client{}, &config.Config{}); disabled.Transport != nil {
+		t.Fatalf("disabled compression wrapped the transport: %T", disabled.Transport)
+	}
+}
@@ -0,0 +1,243 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/speech"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// speechChunkSize bounds the audio chunks relayed from a speech upstream.
+const speechChunkSize = 32 * 1024
+
+// speechVoice returns the voice configured under tts-voices for the voice of
+// a speech request sent through auth.
+func speechVoice(cfg *config.Config, auth *cliproxyauth.Auth, payload []byte) (string, bool) {
+	var table map[string]map[string]string
+	if cfg != nil {
+		table = cfg.TTSVoices
+	}
+	return speech.LookupVoice(table, authProviderKeys(auth), gjson.GetBytes(payload, "voice").String())
+}
+
+// speechStreamResult replays a complete speech response as a single chunk.
+func speechStreamResult(resp cliproxyexecutor.Response) *cliproxyexecutor.StreamResult {
+	out := make(chan cliproxyexecutor.StreamChunk, 1)
+	out <- cliproxyexecutor.StreamChunk{Payload: resp.Payload}
+	close(out)
+	return &cliproxyexecutor.StreamResult{Headers: resp.Headers, Chunks: out}
+}
+
+// collectSpeech reads a relayed speech stream into one response.
+func collectSpeech(result *cliproxyexecutor.StreamResult) (cliproxyexecutor.Response, error) {
+	var audio bytes.Buffer
+	for chunk := range result.Chunks {
+		if chunk.Err != nil {
+			for range result.Chunks {
+			}
+			return cliproxyexecutor.Response{}, chunk.Err
+		}
+		audio.Write(chunk.Payload)
+	}
+	return cliproxyexecutor.Response{Payload: audio.Bytes(), Headers: result.Headers}, nil
+}
+
+// executeSpeech sends a Gemini TTS generateContent request for an OpenAI speech
+// request and returns the audio in the requested response_format.
+func (e *GeminiExecutor) executeSpeech(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request) (resp cliproxyexecutor.Response, err error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+	format := speech.Format(req.Payload)
+	if !speech.GeminiFormatSupported(format) {
+		return resp, statusErr{code: http.StatusBadRequest, msg: fmt.Sprintf("response_format %q is not supported for gemini speech; use wav or pcm", format)}
+	}
+
+	apiKey, bearer := geminiCreds(auth)
+
+	reporter := newUsageReporter(ctx, e.Identifier(), baseModel, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	voice, ok := speechVoice(e.cfg, auth, req.Payload)
+	if !ok {
+		voice = speech.GeminiVoice(gjson.GetBytes(req.Payload, "voice").String())
+	}
+	body := speech.GeminiRequest(req.Payload, voice)
+
+	url := fmt.Sprintf("%s/%s/models/%s:generateContent", resolveGeminiBaseURL(auth), resolveAuthAPIVersion(auth, glAPIVersion), baseModel)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("x-goog-api-key", apiKey)
+	} else if bearer != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	applyGeminiHeaders(httpReq, auth)
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	defer func() {
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("gemini executor: close response body error: %v", errClose)
+		}
+	}()
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		logWithRequestID(ctx).Debugf("request error, error status: %d, error message: %s", httpResp.StatusCode, summarizeErrorBody(httpResp.Header.Get("Content-Type"), b))
+		err = statusErr{code: httpResp.StatusCode, msg: string(b)}
+		return resp, err
+	}
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return resp, err
+	}
+	appendAPIResponseChunk(ctx, e.cfg, data)
+	reporter.publish(ctx, parseGeminiUsage(data))
+	audio, errAudio := speech.FromGemini(data, format)
+	if errAudio != nil {
+		err = statusErr{code: http.StatusBadGateway, msg: errAudio.Error()}
+		return resp, err
+	}
+	headers := httpResp.Header.Clone()
+	headers.Del("Content-Type")
+	return cliproxyexecutor.Response{Payload: audio, Headers: headers}, nil
+}
+
+// executeSpeech forwards an OpenAI speech request to the compatible upstream
+// and relays the audio bytes as they arrive.
+func (e *OpenAICompatExecutor) executeSpeech(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request) (_ *cliproxyexecutor.StreamResult, err error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+
+	reporter := newUsageReporter(ctx, e.Identifier(), baseModel, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	baseURL, apiKey := e.resolveCredentials(auth)
+	if baseURL == "" {
+		err = statusErr{code: http.StatusUnauthorized, msg: "missing provider baseURL"}
+		return nil, err
+	}
+
+	body, _ := sjson.SetBytes(req.Payload, "model", baseModel)
+	if voice, ok := speechVoice(e.cfg, auth, req.Payload); ok {
+		body, _ = sjson.SetBytes(body, "voice", voice)
+	}
+
+	url := applyAPIVersionQuery(strings.TrimSuffix(baseURL, "/")+"/audio/speech", auth)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	httpReq.Header.Set("User-Agent", "cli-proxy-openai-compat")
+	var attrs map[string]string
+	if auth != nil {
+		attrs = auth.Attributes
+	}
+	util.ApplyCustomHeadersFromAttrs(httpReq, attrs)
+	var authID, authLabel, authType, authValue string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+		authType, authValue = auth.AccountInfo()
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       url,
+		Method:    http.MethodPost,
+		Headers:   httpReq.Header.Clone(),
+		Body:      body,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+		AuthType:  authType,
+		AuthValue: authValue,
+	})
+
+	httpClient := newProxyAwareHTTPClient(ctx, e.cfg, auth, 0)
+	httpResp, err := httpClient.Do(httpReq)
+	if err != nil {
+		recordAPIResponseError(ctx, e.cfg, err)
+		return nil, err
+	}
+	recordAPIResponseMetadata(ctx, e.cfg, httpResp.StatusCode, httpResp.Header.Clone())
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		b, _ := io.ReadAll(httpResp.Body)
+		appendAPIResponseChunk(ctx, e.cfg, b)
+		logWithRequestID(ctx).Debugf("request error, error status: %d, error message: %s", httpResp.StatusCode, summarizeErrorBody(httpResp.Header.Get("Content-Type"), b))
+		if errClose := httpResp.Body.Close(); errClose != nil {
+			log.Errorf("openai compat executor: close response body error: %v", errClose)
+		}
+		err = statusErr{code: httpResp.StatusCode, msg: string(b)}
+		return nil, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer func() {
+			if errClose := httpResp.Body.Close(); errClose != nil {
+				log.Errorf("openai compat executor: close response body error: %v", errClose)
+			}
+		}()
+		// Audio is relayed unlogged; request logs only keep the upstream metadata.
+		buf := make([]byte, speechChunkSize)
+		for {
+			n, errRead := httpResp.Body.Read(buf)
+			if n > 0 {
+				out <- cliproxyexecutor.StreamChunk{Payload: bytes.Clone(buf[:n])}
+			}
+			if errors.Is(errRead, io.EOF) {
+				break
+			}
+			if errRead != nil {
+				recordAPIResponseError(ctx, e.cfg, errRead)
+				reporter.publishFailure(ctx)
+				out <- cliproxyexecutor.StreamChunk{Err: errRead}
+				return
+			}
+		}
+		reporter.ensurePublished(ctx)
+	}()
+	headers := httpResp.Header.Clone()
+	headers.Del("Content-Type")
+	return &cliproxyexecutor.StreamResult{Headers: headers, Chunks: out}, nil
+}
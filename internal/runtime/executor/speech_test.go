@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAICompatExecutorSpeechRelaysAudio(t *testing.T) {
+	var gotPath string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "audio/mpeg")
+		_, _ = w.Write([]byte("ID3-audio-bytes"))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{TTSVoices: map[string]map[string]string{"my-tts": {"Alloy": "af_alloy"}}}
+	executor := NewOpenAICompatExecutor("openai-compatibility", cfg)
+	auth := &cliproxyauth.Auth{Provider: "openai-compatibility", Attributes: map[string]string{
+		"base_url":    server.URL + "/v1",
+		"api_key":     "test",
+		"compat_name": "my-tts",
+	}}
+	result, err := executor.ExecuteStream(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "kokoro",
+		Payload: []byte(`{"model":"tts-alias","input":"hello","voice":"alloy"}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), Alt: "audio/speech", Stream: true})
+	if err != nil {
+		t.Fatalf("ExecuteStream error: %v", err)
+	}
+	resp, err := collectSpeech(result)
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	if gotPath != "/v1/audio/speech" {
+		t.Fatalf("path = %q", gotPath)
+	}
+	if gjson.GetBytes(gotBody, "model").String() != "kokoro" || gjson.GetBytes(gotBody, "voice").String() != "af_alloy" {
+		t.Fatalf("upstream body = %s", gotBody)
+	}
+	if string(resp.Payload) != "ID3-audio-bytes" {
+		t.Fatalf("audio = %q", resp.Payload)
+	}
+}
+
+func TestGeminiExecutorSpeechReturnsWAV(t *testing.T) {
+	pcm := []byte{1, 0, 2, 0}
+	var gotPath string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"inlineData":{"mimeType":"audio/L16;codec=pcm;rate=16000","data":"` + base64.StdEncoding.EncodeToString(pcm) + `"}}]}}]}`))
+	}))
+	defer server.Close()
+
+	executor := NewGeminiExecutor(&config.Config{})
+	auth := &cliproxyauth.Auth{Provider: "gemini", Attributes: map[string]string{"base_url": server.URL, "api_key": "test"}}
+	resp, err := executor.Execute(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "gemini-2.5-flash-preview-tts",
+		Payload: []byte(`{"model":"gemini-2.5-flash-preview-tts","input":"hello","voice":"onyx","response_format":"wav"}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), Alt: "audio/speech"})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if gotPath != "/v1beta/models/gemini-2.5-flash-preview-tts:generateContent" {
+		t.Fatalf("path = %q", gotPath)
+	}
+	if voice := gjson.GetBytes(gotBody, "generationConfig.speechConfig.voiceConfig.prebuiltVoiceConfig.voiceName").String(); voice != "Charon" {
+		t.Fatalf("voice = %q", voice)
+	}
+	if len(resp.Payload) != 44+len(pcm) || string(resp.Payload[:4]) != "RIFF" || string(resp.Payload[44:]) != string(pcm) {
+		t.Fatalf("wav = %v", resp.Payload)
+	}
+
+	_, err = executor.Execute(context.Background(), auth, cliproxyexecutor.Request{
+		Model:   "gemini-2.5-flash-preview-tts",
+		Payload: []byte(`{"input":"hello"}`),
+	}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("openai"), Alt: "audio/speech"})
+	if se, ok := err.(statusErr); !ok || se.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("mp3 error = %v", err)
+	}
+}
@@ -5,106 +5,13 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenizer"
 	"github.com/tidwall/gjson"
-	"github.com/tiktoken-go/tokenizer"
 )
 
-// tokenizerCache stores tokenizer instances to avoid repeated creation
-var tokenizerCache sync.Map
-
-// TokenizerWrapper wraps a tokenizer codec with an adjustment factor for models
-// where tiktoken may not accurately estimate token counts (e.g., Claude models)
-type TokenizerWrapper struct {
-	Codec            tokenizer.Codec
-	AdjustmentFactor float64 // 1.0 means no adjustment, >1.0 means tiktoken underestimates
-}
-
-// Count returns the token count with adjustment factor applied
-func (tw *TokenizerWrapper) Count(text string) (int, error) {
-	count, err := tw.Codec.Count(text)
-	if err != nil {
-		return 0, err
-	}
-	if tw.AdjustmentFactor != 1.0 && tw.AdjustmentFactor > 0 {
-		return int(float64(count) * tw.AdjustmentFactor), nil
-	}
-	return count, nil
-}
-
-// getTokenizer returns a cached tokenizer for the given model.
-// This improves performance by avoiding repeated tokenizer creation.
-func getTokenizer(model string) (*TokenizerWrapper, error) {
-	// Check cache first
-	if cached, ok := tokenizerCache.Load(model); ok {
-		return cached.(*TokenizerWrapper), nil
-	}
-
-	// Cache miss, create new tokenizer
-	wrapper, err := tokenizerForModel(model)
-	if err != nil {
-		return nil, err
-	}
-
-	// Store in cache (use LoadOrStore to handle race conditions)
-	actual, _ := tokenizerCache.LoadOrStore(model, wrapper)
-	return actual.(*TokenizerWrapper), nil
-}
-
-// tokenizerForModel returns a tokenizer codec suitable for an OpenAI-style model id.
-// For Claude models, applies a 1.1 adjustment factor since tiktoken may underestimate.
-func tokenizerForModel(model string) (*TokenizerWrapper, error) {
-	sanitized := strings.ToLower(strings.TrimSpace(model))
-
-	// Claude models use cl100k_base with 1.1 adjustment factor
-	// because tiktoken may underestimate Claude's actual token count
-	if strings.Contains(sanitized, "claude") || strings.HasPrefix(sanitized, "kiro-") || strings.HasPrefix(sanitized, "amazonq-") {
-		enc, err := tokenizer.Get(tokenizer.Cl100kBase)
-		if err != nil {
-			return nil, err
-		}
-		return &TokenizerWrapper{Codec: enc, AdjustmentFactor: 1.1}, nil
-	}
-
-	var enc tokenizer.Codec
-	var err error
-
-	switch {
-	case sanitized == "":
-		enc, err = tokenizer.Get(tokenizer.Cl100kBase)
-	case strings.HasPrefix(sanitized, "gpt-5.2"):
-		enc, err = tokenizer.ForModel(tokenizer.GPT5)
-	case strings.HasPrefix(sanitized, "gpt-5.1"):
-		enc, err = tokenizer.ForModel(tokenizer.GPT5)
-	case strings.HasPrefix(sanitized, "gpt-5"):
-		enc, err = tokenizer.ForModel(tokenizer.GPT5)
-	case strings.HasPrefix(sanitized, "gpt-4.1"):
-		enc, err = tokenizer.ForModel(tokenizer.GPT41)
-	case strings.HasPrefix(sanitized, "gpt-4o"):
-		enc, err = tokenizer.ForModel(tokenizer.GPT4o)
-	case strings.HasPrefix(sanitized, "gpt-4"):
-		enc, err = tokenizer.ForModel(tokenizer.GPT4)
-	case strings.HasPrefix(sanitized, "gpt-3.5"), strings.HasPrefix(sanitized, "gpt-3"):
-		enc, err = tokenizer.ForModel(tokenizer.GPT35Turbo)
-	case strings.HasPrefix(sanitized, "o1"):
-		enc, err = tokenizer.ForModel(tokenizer.O1)
-	case strings.HasPrefix(sanitized, "o3"):
-		enc, err = tokenizer.ForModel(tokenizer.O3)
-	case strings.HasPrefix(sanitized, "o4"):
-		enc, err = tokenizer.ForModel(tokenizer.O4Mini)
-	default:
-		enc, err = tokenizer.Get(tokenizer.O200kBase)
-	}
-
-	if err != nil {
-		return nil, err
-	}
-	return &TokenizerWrapper{Codec: enc, AdjustmentFactor: 1.0}, nil
-}
-
 // countOpenAIChatTokens approximates prompt tokens for OpenAI chat completions payloads.
-func countOpenAIChatTokens(enc *TokenizerWrapper, payload []byte) (int64, error) {
+func countOpenAIChatTokens(enc tokenizer.Tokenizer, payload []byte) (int64, error) {
 	if enc == nil {
 		return 0, fmt.Errorf("encoder is nil")
 	}
@@ -143,7 +50,7 @@ func countOpenAIChatTokens(enc *TokenizerWrapper, payload []byte) (int64, error)
 // countClaudeChatTokens approximates prompt tokens for Claude API chat completions payloads.
 // This handles Claude's message format with system, messages, and tools.
 // Image tokens are estimated based on image dimensions when available.
-func countClaudeChatTokens(enc *TokenizerWrapper, payload []byte) (int64, error) {
+func countClaudeChatTokens(enc tokenizer.Tokenizer, payload []byte) (int64, error) {
 	if enc == nil {
 		return 0, fmt.Errorf("encoder is nil")
 	}
@@ -180,6 +87,66 @@ func countClaudeChatTokens(enc *TokenizerWrapper, payload []byte) (int64, error)
 	return int64(count) + int64(imageTokens), nil
 }
 
+// countGeminiRequestTokens approximates prompt tokens for Gemini
+// generateContent payloads, unwrapping the gemini-cli request envelope.
+func countGeminiRequestTokens(enc tokenizer.Tokenizer, payload []byte) (int64, error) {
+	if enc == nil {
+		return 0, fmt.Errorf("encoder is nil")
+	}
+	root := gjson.ParseBytes(payload)
+	if inner := root.Get("request"); inner.IsObject() {
+		root = inner
+	}
+	segments := make([]string, 0, 32)
+	for _, part := range root.Get("systemInstruction.parts").Array() {
+		addIfNotEmpty(&segments, part.Get("text").String())
+	}
+	for _, content := range root.Get("contents").Array() {
+		for _, part := range content.Get("parts").Array() {
+			addIfNotEmpty(&segments, part.Get("text").String())
+			addIfNotEmpty(&segments, part.Get("functionCall").Raw)
+			addIfNotEmpty(&segments, part.Get("functionResponse").Raw)
+		}
+	}
+	for _, tool := range root.Get("tools").Array() {
+		addIfNotEmpty(&segments, tool.Raw)
+	}
+
+	joined := strings.TrimSpace(strings.Join(segments, "\n"))
+	if joined == "" {
+		return 0, nil
+	}
+	count, err := enc.Count(joined)
+	if err != nil {
+		return 0, err
+	}
+	return int64(count), nil
+}
+
+// countRequestTokens approximates the prompt tokens of a request in the given
+// client format. Unknown formats and empty payloads count as zero.
+func countRequestTokens(enc tokenizer.Tokenizer, format string, payload []byte) int64 {
+	if len(payload) == 0 || !gjson.ValidBytes(payload) {
+		return 0
+	}
+	var count int64
+	var err error
+	switch format {
+	case "openai":
+		count, err = countOpenAIChatTokens(enc, payload)
+	case "claude":
+		count, err = countClaudeChatTokens(enc, payload)
+	case "openai-response", "codex":
+		count, err = countCodexInputTokens(enc, payload)
+	case "gemini", "gemini-cli", "antigravity":
+		count, err = countGeminiRequestTokens(enc, payload)
+	}
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
 // imageTokenPattern matches [IMAGE:xxx tokens] format for extracting estimated image tokens
 var imageTokenPattern = regexp.MustCompile(`\[IMAGE:(\d+) tokens\]`)
 
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+)
+
+// stageTraceTransport records connection setup, time to first byte and the
+// response body read in the request's stage trace.
+type stageTraceTransport struct {
+	base  http.RoundTripper
+	trace *tracing.Trace
+}
+
+func (t *stageTraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// httptrace hooks may fire on transport goroutines.
+	var mu sync.Mutex
+	var getConn, wroteRequest time.Time
+	clientTrace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			mu.Lock()
+			getConn = time.Now()
+			mu.Unlock()
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			mu.Lock()
+			start := getConn
+			mu.Unlock()
+			t.trace.Record(tracing.StageConnect, start, time.Now())
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			wroteRequest = time.Now()
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			start := wroteRequest
+			mu.Unlock()
+			t.trace.Record(tracing.StageTTFB, start, time.Now())
+		},
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), clientTrace)))
+	if err != nil || resp == nil || resp.Body == nil {
+		return resp, err
+	}
+	resp.Body = &tracedBody{ReadCloser: resp.Body, trace: t.trace, start: time.Now()}
+	return resp, nil
+}
+
+// tracedBody records the stream stage once the body is drained or closed.
+type tracedBody struct {
+	io.ReadCloser
+	trace *tracing.Trace
+	start time.Time
+	once  sync.Once
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *tracedBody) finish() {
+	b.once.Do(func() { b.trace.Record(tracing.StageStream, b.start, time.Now()) })
+}
+
+// withStageTrace wraps client so upstream calls made for a traced API request
+// record their connect, TTFB and stream timings.
+func withStageTrace(ctx context.Context, client *http.Client) *http.Client {
+	if client == nil {
+		return client
+	}
+	trace := tracing.FromContext(ctx)
+	if trace == nil {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport:     &stageTraceTransport{base: base, trace: trace},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
+)
+
+func TestWithStageTrace_RecordsUpstreamStages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "data: {}\n\n")
+	}))
+	defer server.Close()
+
+	gin.SetMode(gin.TestMode)
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	trace := tracing.New()
+	tracing.Attach(ginCtx, trace)
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+	resp, errDo := withStageTrace(ctx, &http.Client{}).Do(req)
+	if errDo != nil {
+		t.Fatalf("do: %v", errDo)
+	}
+	_, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	seen := make(map[string]int)
+	for _, span := range trace.Report("", "", "", 0).Stages {
+		seen[span.Stage]++
+	}
+	for _, stage := range []string{tracing.StageConnect, tracing.StageTTFB, tracing.StageStream} {
+		if seen[stage] != 1 {
+			t.Fatalf("stage %s recorded %d times: %v", stage, seen[stage], seen)
+		}
+	}
+}
+
+func TestWithStageTrace_LeavesUntracedClientsAlone(t *testing.T) {
+	client := &http.Client{}
+	if got := withStageTrace(context.Background(), client); got != client {
+		t.Fatal("client without a trace should be returned unchanged")
+	}
+}
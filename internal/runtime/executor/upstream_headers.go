@@ -0,0 +1,225 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+// upstreamHeaderProtected lists headers that injected values never override,
+// because executors depend on them for framing and response decoding.
+var upstreamHeaderProtected = map[string]struct{}{
+	"Host":              {},
+	"Content-Length":    {},
+	"Transfer-Encoding": {},
+	"Connection":        {},
+	"Accept-Encoding":   {},
+}
+
+var upstreamHeaderTemplatePattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// upstreamHeaderTransport sets configured provider and per-auth headers on each
+// outgoing request, expanding template variables per request.
+type upstreamHeaderTransport struct {
+	base     http.RoundTripper
+	headers  map[string]string
+	provider string
+	authID   string
+}
+
+func (t *upstreamHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not mutate the caller's request.
+	out := req.Clone(req.Context())
+	setUpstreamHeaders(req.Context(), out.Header, t.headers, t.provider, t.authID)
+	return t.base.RoundTrip(out)
+}
+
+// applyUpstreamHeaders sets injected headers directly on h. It serves
+// connections that bypass http.Client, such as websocket handshakes.
+func applyUpstreamHeaders(ctx context.Context, h http.Header, cfg *config.Config, auth *cliproxyauth.Auth) {
+	if h == nil || auth == nil {
+		return
+	}
+	if _, ok := cliproxyexecutor.UpstreamModel(ctx); !ok {
+		return
+	}
+	setUpstreamHeaders(ctx, h, collectUpstreamHeaders(cfg, auth), auth.Provider, auth.ID)
+}
+
+func setUpstreamHeaders(ctx context.Context, h http.Header, headers map[string]string, provider, authID string) {
+	if len(headers) == 0 {
+		return
+	}
+	model, _ := cliproxyexecutor.UpstreamModel(ctx)
+	vars := map[string]string{
+		"request_id": logging.GetRequestID(ctx),
+		"model":      thinking.ParseSuffix(model).ModelName,
+		"provider":   provider,
+		"auth_id":    authID,
+	}
+	for name, value := range headers {
+		if expanded := expandUpstreamHeaderTemplate(value, vars); expanded != "" {
+			h.Set(name, expanded)
+		}
+	}
+}
+
+// withUpstreamHeaders wraps client so model executions carry the headers
+// configured under provider-headers and on the auth itself. Requests made
+// outside a model execution (token refreshes, management calls) are left alone.
+func withUpstreamHeaders(ctx context.Context, client *http.Client, cfg *config.Config, auth *cliproxyauth.Auth) *http.Client {
+	if client == nil {
+		return nil
+	}
+	if _, ok := cliproxyexecutor.UpstreamModel(ctx); !ok {
+		return client
+	}
+	headers := collectUpstreamHeaders(cfg, auth)
+	if len(headers) == 0 {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	transport := &upstreamHeaderTransport{base: base, headers: headers}
+	if auth != nil {
+		transport.provider = auth.Provider
+		transport.authID = auth.ID
+	}
+	return &http.Client{
+		Transport:     transport,
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
+
+// collectUpstreamHeaders merges provider-level headers from cfg, the auth's
+// client profile and the auth's own "header:" attributes, in increasing order
+// of precedence.
+func collectUpstreamHeaders(cfg *config.Config, auth *cliproxyauth.Auth) map[string]string {
+	headers := make(map[string]string)
+	add := func(name, value string) {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if name == "" || value == "" {
+			return
+		}
+		if _, protected := upstreamHeaderProtected[name]; protected {
+			return
+		}
+		headers[name] = value
+	}
+
+	// openai-compatibility upstreams are generic gateways that accept a
+	// correlation header. First-party providers only receive the request ID
+	// when it is configured under provider-headers.
+	if auth != nil && strings.TrimSpace(auth.Attributes["compat_name"]) != "" {
+		add(logging.RequestIDHeader, "{{request_id}}")
+	}
+
+	if cfg != nil && len(cfg.ProviderHeaders) > 0 {
+		for _, key := range authProviderKeys(auth) {
+			for provider, providerHeaders := range cfg.ProviderHeaders {
+				if !strings.EqualFold(strings.TrimSpace(provider), key) {
+					continue
+				}
+				for name, value := range providerHeaders {
+					add(name, value)
+				}
+			}
+		}
+	}
+	if profile := resolveClientProfile(cfg, auth); profile != nil {
+		for name, value := range profile.Headers {
+			add(name, value)
+		}
+	}
+	if auth != nil {
+		for key, value := range auth.Attributes {
+			if name, ok := strings.CutPrefix(key, "header:"); ok {
+				add(name, value)
+			}
+		}
+	}
+	return headers
+}
+
+// resolveClientProfile returns the client-profiles entry auth selects through
+// its "client_profile" attribute or, failing that, the first profile by name
+// that lists one of its provider keys. The openai-compatibility name is
+// matched before the provider ID.
+func resolveClientProfile(cfg *config.Config, auth *cliproxyauth.Auth) *config.ClientProfile {
+	if cfg == nil || len(cfg.ClientProfiles) == 0 || auth == nil {
+		return nil
+	}
+	names := make([]string, 0, len(cfg.ClientProfiles))
+	for name := range cfg.ClientProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if selected := strings.TrimSpace(auth.Attributes["client_profile"]); selected != "" {
+		for _, name := range names {
+			if strings.EqualFold(strings.TrimSpace(name), selected) {
+				profile := cfg.ClientProfiles[name]
+				return &profile
+			}
+		}
+		log.Debugf("client profile %q selected by auth %s is not configured", selected, auth.ID)
+	}
+	keys := authProviderKeys(auth)
+	for i := len(keys) - 1; i >= 0; i-- {
+		for _, name := range names {
+			profile := cfg.ClientProfiles[name]
+			for _, provider := range profile.Providers {
+				if strings.EqualFold(strings.TrimSpace(provider), keys[i]) {
+					return &profile
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// authProviderKeys returns the names config sections may use to target auth:
+// its provider ID followed by its openai-compatibility name, if any.
+func authProviderKeys(auth *cliproxyauth.Auth) []string {
+	if auth == nil {
+		return nil
+	}
+	var keys []string
+	if provider := strings.TrimSpace(auth.Provider); provider != "" {
+		keys = append(keys, provider)
+	}
+	if auth.Attributes != nil {
+		if compat := strings.TrimSpace(auth.Attributes["compat_name"]); compat != "" {
+			keys = append(keys, compat)
+		}
+	}
+	return keys
+}
+
+// expandUpstreamHeaderTemplate substitutes {{name}} placeholders in value.
+// Unknown placeholders are left untouched.
+func expandUpstreamHeaderTemplate(value string, vars map[string]string) string {
+	if !strings.Contains(value, "{{") {
+		return value
+	}
+	return upstreamHeaderTemplatePattern.ReplaceAllStringFunc(value, func(match string) string {
+		name := upstreamHeaderTemplatePattern.FindStringSubmatch(match)[1]
+		if replacement, ok := vars[name]; ok {
+			return replacement
+		}
+		return match
+	})
+}
@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestNewProxyAwareHTTPClient_InjectsUpstreamHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ProviderHeaders: map[string]map[string]string{
+		"Claude": {
+			"X-Trace":         "{{request_id}}/{{model}}",
+			"X-Team":          "platform",
+			"Accept-Encoding": "br",
+		},
+	}}
+	auth := &cliproxyauth.Auth{
+		ID:         "claude-1",
+		Provider:   "claude",
+		Attributes: map[string]string{"header:X-Team": "research", "header:X-Auth": "{{auth_id}}"},
+	}
+
+	ctx := logging.WithRequestID(context.Background(), "req-42")
+	ctx = cliproxyexecutor.WithUpstreamModel(ctx, "claude-sonnet-4-5(high)")
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if errReq != nil {
+		t.Fatalf("new request: %v", errReq)
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, errDo := newProxyAwareHTTPClient(ctx, cfg, auth, 0).Do(req)
+	if errDo != nil {
+		t.Fatalf("do: %v", errDo)
+	}
+	_ = resp.Body.Close()
+
+	if v := got.Get("X-Trace"); v != "req-42/claude-sonnet-4-5" {
+		t.Fatalf("X-Trace = %q", v)
+	}
+	if v := got.Get("X-Team"); v != "research" {
+		t.Fatalf("per-auth header should win, X-Team = %q", v)
+	}
+	if v := got.Get("X-Auth"); v != "claude-1" {
+		t.Fatalf("X-Auth = %q", v)
+	}
+	if v := got.Get("Accept-Encoding"); v != "identity" {
+		t.Fatalf("Accept-Encoding must not be overridden, got %q", v)
+	}
+	if v := req.Header.Get("X-Trace"); v != "" {
+		t.Fatalf("caller request was mutated: X-Trace = %q", v)
+	}
+}
+
+func TestWithUpstreamHeaders_SkipsRequestsOutsideModelExecution(t *testing.T) {
+	cfg := &config.Config{ProviderHeaders: map[string]map[string]string{"codex": {"X-Team": "platform"}}}
+	auth := &cliproxyauth.Auth{Provider: "codex"}
+	client := &http.Client{}
+	if got := withUpstreamHeaders(context.Background(), client, cfg, auth); got != client {
+		t.Fatal("expected client to be returned unchanged without an upstream model")
+	}
+
+	headers := http.Header{}
+	applyUpstreamHeaders(context.Background(), headers, cfg, auth)
+	if len(headers) != 0 {
+		t.Fatalf("expected no headers, got %v", headers)
+	}
+	applyUpstreamHeaders(cliproxyexecutor.WithUpstreamModel(context.Background(), "gpt-5"), headers, cfg, auth)
+	if v := headers.Get("X-Team"); v != "platform" {
+		t.Fatalf("X-Team = %q", v)
+	}
+}
+
+func TestCollectUpstreamHeaders_MatchesCompatName(t *testing.T) {
+	cfg := &config.Config{ProviderHeaders: map[string]map[string]string{
+		"openrouter": {"X-Title": "{{provider}} {{unknown}}"},
+	}}
+	auth := &cliproxyauth.Auth{Provider: "openai-compatibility", Attributes: map[string]string{"compat_name": "OpenRouter"}}
+	headers := collectUpstreamHeaders(cfg, auth)
+	value := expandUpstreamHeaderTemplate(headers["X-Title"], map[string]string{"provider": auth.Provider})
+	if value != "openai-compatibility {{unknown}}" {
+		t.Fatalf("X-Title = %q", value)
+	}
+}
+
+func TestCollectUpstreamHeaders_PropagatesRequestIDToCompat(t *testing.T) {
+	compat := &cliproxyauth.Auth{Provider: "openrouter", Attributes: map[string]string{"compat_name": "OpenRouter"}}
+	if got := collectUpstreamHeaders(nil, compat)["X-Request-Id"]; got != "{{request_id}}" {
+		t.Fatalf("compat X-Request-Id = %q", got)
+	}
+	claude := &cliproxyauth.Auth{Provider: "claude"}
+	if got, ok := collectUpstreamHeaders(nil, claude)["X-Request-Id"]; ok {
+		t.Fatalf("first-party provider received X-Request-Id = %q", got)
+	}
+}
+
+func TestCollectUpstreamHeaders_AppliesClientProfile(t *testing.T) {
+	cfg := &config.Config{
+		ProviderHeaders: map[string]map[string]string{"claude": {"User-Agent": "provider-ua", "X-Team": "platform"}},
+		ClientProfiles: map[string]config.ClientProfile{
+			"claude-cli": {Providers: []string{"Claude"}, Headers: map[string]string{"User-Agent": "claude-cli/9.9.9", "X-App": "cli"}},
+			"desktop":    {Headers: map[string]string{"User-Agent": "claude-desktop/1.0"}},
+		},
+	}
+
+	byProvider := collectUpstreamHeaders(cfg, &cliproxyauth.Auth{Provider: "claude"})
+	if byProvider["User-Agent"] != "claude-cli/9.9.9" || byProvider["X-App"] != "cli" || byProvider["X-Team"] != "platform" {
+		t.Fatalf("provider default profile headers = %v", byProvider)
+	}
+
+	selected := collectUpstreamHeaders(cfg, &cliproxyauth.Auth{Provider: "claude", Attributes: map[string]string{
+		"client_profile": "Desktop",
+		"header:X-Team":  "research",
+	}})
+	if selected["User-Agent"] != "claude-desktop/1.0" || selected["X-App"] != "" || selected["X-Team"] != "research" {
+		t.Fatalf("selected profile headers = %v", selected)
+	}
+
+	if got := collectUpstreamHeaders(cfg, &cliproxyauth.Auth{Provider: "codex"}); len(got) != 0 {
+		t.Fatalf("unrelated provider received %v", got)
+	}
+}
+
+func TestNewProxyAwareHTTPClient_ClientProfileOverridesBuiltInUserAgent(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{ClientProfiles: map[string]config.ClientProfile{
+		"kimi": {Headers: map[string]string{"User-Agent": "KimiCLI/9.0.0"}},
+	}}
+	auth := &cliproxyauth.Auth{ID: "kimi-1", Provider: "kimi", Attributes: map[string]string{"client_profile": "kimi"}}
+	ctx := cliproxyexecutor.WithUpstreamModel(context.Background(), "kimi-k2")
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if errReq != nil {
+		t.Fatalf("new request: %v", errReq)
+	}
+	req.Header.Set("User-Agent", "KimiCLI/1.10.6")
+	resp, errDo := newProxyAwareHTTPClient(ctx, cfg, auth, 0).Do(req)
+	if errDo != nil {
+		t.Fatalf("do: %v", errDo)
+	}
+	_ = resp.Body.Close()
+	if got != "KimiCLI/9.0.0" {
+		t.Fatalf("User-Agent = %q", got)
+	}
+}
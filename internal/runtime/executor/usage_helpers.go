@@ -6,11 +6,21 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/documents"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/forensics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logprobs"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/seed"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenizer"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenlimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -25,8 +35,26 @@ type usageReporter struct {
 	authIndex     string
 	apiKey        string
 	source        string
+	requestID     string
+	parentID      string
+	experiment    string
+	arm           string
+	recovery      string
 	requestedAt   time.Time
+	retries       int
 	once          sync.Once
+	// firstByteAt holds the UnixNano time of the first stream chunk.
+	firstByteAt atomic.Int64
+	// finished is set once the terminal record has been published, so stream
+	// progress stops reporting after it.
+	finished atomic.Bool
+	// tracked is set when trackStream wraps the stream; it then publishes the
+	// terminal record itself if the upstream reported no usage.
+	tracked atomic.Bool
+	// forensics collects translation stages when bundle capture is enabled.
+	forensics *forensics.Recorder
+	// trace receives the translation and thinking stage timings.
+	trace *tracing.Trace
 }
 
 func (r *usageReporter) setThinkingVariant(origin, variant string) {
@@ -45,7 +73,14 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 		requestedAt: time.Now(),
 		apiKey:      apiKey,
 		source:      resolveUsageSource(auth, apiKey),
-	}
+		requestID:   logging.GetRequestID(ctx),
+		parentID:    cliproxyexecutor.UsageParent(ctx),
+		retries:     max(cliproxyexecutor.Attempt(ctx)-1, 0),
+		forensics:   forensics.FromContext(ctx),
+		trace:       tracing.FromContext(ctx),
+	}
+	reporter.experiment, reporter.arm = cliproxyexecutor.ExperimentArm(ctx)
+	reporter.recovery = cliproxyexecutor.RecoveryAction(ctx)
 	if auth != nil {
 		reporter.authID = auth.ID
 		reporter.authIndex = auth.EnsureIndex()
@@ -53,6 +88,36 @@ func newUsageReporter(ctx context.Context, provider, model string, auth *cliprox
 	return reporter
 }
 
+// child returns a reporter for a secondary upstream call made on behalf of r.
+// The child publishes its own record whose ParentRequestID points at r, so
+// follow-up calls are visible in usage without clobbering the parent's fields.
+func (r *usageReporter) child(label string) *usageReporter {
+	if r == nil {
+		return nil
+	}
+	c := &usageReporter{
+		provider:    r.provider,
+		model:       r.model,
+		authID:      r.authID,
+		authIndex:   r.authIndex,
+		apiKey:      r.apiKey,
+		source:      r.source,
+		parentID:    r.requestID,
+		experiment:  r.experiment,
+		arm:         r.arm,
+		recovery:    r.recovery,
+		requestID:   label,
+		requestedAt: time.Now(),
+		retries:     r.retries,
+		forensics:   r.forensics,
+		trace:       r.trace,
+	}
+	if r.requestID != "" {
+		c.requestID = r.requestID + ":" + label
+	}
+	return c
+}
+
 func (r *usageReporter) publish(ctx context.Context, detail usage.Detail) {
 	r.publishWithOutcome(ctx, detail, false)
 }
@@ -84,19 +149,8 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 		return
 	}
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
-			Provider:      r.provider,
-			Model:         r.model,
-			VariantOrigin: r.variantOrigin,
-			Variant:       r.variant,
-			Source:        r.source,
-			APIKey:        r.apiKey,
-			AuthID:        r.authID,
-			AuthIndex:     r.authIndex,
-			RequestedAt:   r.requestedAt,
-			Failed:        failed,
-			Detail:        detail,
-		})
+		r.finished.Store(true)
+		usage.PublishRecord(ctx, r.record(detail, failed))
 	})
 }
 
@@ -105,30 +159,137 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 // This is used to ensure request counting even when upstream responses do not
 // include any usage fields (tokens), especially for streaming paths.
 func (r *usageReporter) ensurePublished(ctx context.Context) {
-	if r == nil {
+	if r == nil || r.tracked.Load() {
 		return
 	}
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
-			Provider:      r.provider,
-			Model:         r.model,
-			VariantOrigin: r.variantOrigin,
-			Variant:       r.variant,
-			Source:        r.source,
-			APIKey:        r.apiKey,
-			AuthID:        r.authID,
-			AuthIndex:     r.authIndex,
-			RequestedAt:   r.requestedAt,
-			Failed:        false,
-			Detail:        usage.Detail{},
-		})
+		r.finished.Store(true)
+		usage.PublishRecord(ctx, r.record(usage.Detail{}, false))
 	})
 }
 
+// openAIChatOutputPaths locate the generated text of a non-streaming OpenAI
+// chat completion.
+var openAIChatOutputPaths = []string{
+	"choices.#.message.content",
+	"choices.#.message.reasoning_content",
+	"choices.#.message.tool_calls.#.function.arguments",
+}
+
+// publishEstimated publishes usage counted with the model's tokenizer for an
+// OpenAI chat request and response whose upstream reported no usage. It does
+// nothing once a record has been published.
+func (r *usageReporter) publishEstimated(ctx context.Context, request, response []byte) {
+	if r == nil || r.finished.Load() {
+		return
+	}
+	enc, err := tokenizer.ForModel(r.model)
+	if err != nil {
+		return
+	}
+	var detail usage.Detail
+	if input, errCount := countOpenAIChatTokens(enc, request); errCount == nil {
+		detail.InputTokens = input
+	}
+	if len(response) > 0 && gjson.ValidBytes(response) {
+		root := gjson.ParseBytes(response)
+		var text []byte
+		for _, path := range openAIChatOutputPaths {
+			text = appendStreamText(text, root.Get(path))
+		}
+		if len(text) > 0 {
+			if output, errCount := enc.Count(string(text)); errCount == nil {
+				detail.OutputTokens = int64(output)
+			}
+		}
+	}
+	r.publishEstimatedDetail(ctx, detail)
+}
+
+// publishEstimatedDetail publishes detail as the terminal record, marked as
+// estimated. Without any estimate it publishes a plain record instead so the
+// request is still counted.
+func (r *usageReporter) publishEstimatedDetail(ctx context.Context, detail usage.Detail) {
+	if detail.TotalTokens == 0 {
+		detail.TotalTokens = detail.InputTokens + detail.OutputTokens
+	}
+	r.once.Do(func() {
+		r.finished.Store(true)
+		record := r.record(detail, false)
+		record.Estimated = detail.TotalTokens > 0
+		usage.PublishRecord(ctx, record)
+	})
+}
+
+// markFirstByte records the arrival of the first stream chunk.
+func (r *usageReporter) markFirstByte() {
+	if r != nil {
+		r.firstByteAt.CompareAndSwap(0, time.Now().UnixNano())
+	}
+}
+
+// applyTiming fills the latency, throughput and retry fields of detail.
+// Throughput excludes the time spent waiting for the first byte.
+func (r *usageReporter) applyTiming(detail usage.Detail) usage.Detail {
+	detail.Retries = r.retries
+	if r.requestedAt.IsZero() {
+		return detail
+	}
+	detail.Duration = time.Since(r.requestedAt)
+	generation := detail.Duration
+	if first := r.firstByteAt.Load(); first > 0 {
+		detail.TimeToFirstByte = time.Unix(0, first).Sub(r.requestedAt)
+		generation -= detail.TimeToFirstByte
+	}
+	if detail.OutputTokens > 0 && generation > 0 {
+		detail.OutputTokensPerSecond = float64(detail.OutputTokens) / generation.Seconds()
+	}
+	return detail
+}
+
+// record builds the terminal usage record for r.
+func (r *usageReporter) record(detail usage.Detail, failed bool) usage.Record {
+	detail = r.applyTiming(detail)
+	return usage.Record{
+		Provider:        r.provider,
+		Model:           r.model,
+		VariantOrigin:   r.variantOrigin,
+		Variant:         r.variant,
+		Source:          r.source,
+		APIKey:          r.apiKey,
+		AuthID:          r.authID,
+		AuthIndex:       r.authIndex,
+		RequestID:       r.requestID,
+		ParentRequestID: r.parentID,
+		Experiment:      r.experiment,
+		Arm:             r.arm,
+		Recovery:        r.recovery,
+		RequestedAt:     r.requestedAt,
+		Failed:          failed,
+		Terminal:        true,
+		Detail:          detail,
+	}
+}
+
 func applyThinkingWithUsageMeta(body []byte, model, fromFormat, toFormat, providerKey string, reporter *usageReporter) ([]byte, error) {
+	var thinkingStart time.Time
+	if reporter != nil {
+		// Executors translate the payload between creating the reporter and
+		// adapting thinking.
+		thinkingStart = time.Now()
+		reporter.trace.Record(tracing.StageTranslation, reporter.requestedAt, thinkingStart)
+	}
+	// Clamp the output limit first so thinking budgets are fitted below it.
+	body = tokenlimit.Normalize(body, model, toFormat, providerKey)
+	body = logprobs.Normalize(body, model, toFormat, providerKey)
+	body = seed.Normalize(body, model, toFormat, providerKey)
+	body = documents.Normalize(body, model, toFormat, providerKey)
 	out, meta, err := thinking.ApplyThinkingWithMeta(body, model, fromFormat, toFormat, providerKey)
 	if reporter != nil {
+		reporter.trace.Record(tracing.StageThinking, thinkingStart, time.Now())
 		reporter.setThinkingVariant(meta.VariantOrigin, meta.Variant)
+		reporter.forensics.AddStage("translated", toFormat, model, body)
+		reporter.forensics.AddAdaptation(providerKey, model, meta)
 	}
 	return out, err
 }
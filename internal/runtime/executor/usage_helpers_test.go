@@ -6,6 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 )
 
@@ -436,3 +438,85 @@ func TestParseOpenAIStreamUsage_ResponsesFieldNames(t *testing.T) {
 		t.Fatalf("reasoning tokens = %d, want %d", detail.ReasoningTokens, 5)
 	}
 }
+
+func TestUsageReporterChildLinksToParentRequest(t *testing.T) {
+	plugin := newTestUsagePlugin()
+	usage.RegisterPlugin(plugin)
+
+	ctx := logging.WithRequestID(context.Background(), "req12345")
+	parent := newUsageReporter(ctx, "child-link-provider", "child-link-model", nil)
+	parent.setThinkingVariant("high", "high")
+
+	child := parent.child("web_search_followup_1")
+	child.setThinkingVariant("low", "low")
+	child.publish(ctx, usage.Detail{InputTokens: 3, OutputTokens: 4})
+
+	for {
+		rec := plugin.waitOne(t)
+		if rec.Provider != "child-link-provider" {
+			continue
+		}
+		if rec.RequestID != "req12345:web_search_followup_1" {
+			t.Fatalf("child RequestID = %q", rec.RequestID)
+		}
+		if rec.ParentRequestID != "req12345" {
+			t.Fatalf("child ParentRequestID = %q, want req12345", rec.ParentRequestID)
+		}
+		if rec.Variant != "low" {
+			t.Fatalf("child Variant = %q, want low", rec.Variant)
+		}
+		break
+	}
+	if parent.variant != "high" {
+		t.Fatalf("parent variant clobbered: %q", parent.variant)
+	}
+}
+
+func TestNewUsageReporter_CountsEarlierAttemptsAsRetries(t *testing.T) {
+	ctx := cliproxyexecutor.WithAttemptCounter(context.Background())
+	cliproxyexecutor.NextAttempt(ctx)
+	cliproxyexecutor.NextAttempt(ctx)
+	if reporter := newUsageReporter(ctx, "codex", "gpt-5", nil); reporter.retries != 1 {
+		t.Fatalf("retries = %d, want 1", reporter.retries)
+	}
+	if reporter := newUsageReporter(context.Background(), "codex", "gpt-5", nil); reporter.retries != 0 {
+		t.Fatalf("retries without a counter = %d, want 0", reporter.retries)
+	}
+}
+
+func TestNewUsageReporter_TakesParentFromContext(t *testing.T) {
+	ctx := logging.WithRequestID(context.Background(), "req1:router-classifier")
+	ctx = cliproxyexecutor.WithUsageParent(ctx, "req1")
+	if reporter := newUsageReporter(ctx, "codex", "gpt-5-mini", nil); reporter.parentID != "req1" {
+		t.Fatalf("parentID = %q, want req1", reporter.parentID)
+	}
+}
+
+func TestPublishEstimatedCountsWhenUsageMissing(t *testing.T) {
+	plugin := newTestUsagePlugin()
+	usage.RegisterPlugin(plugin)
+
+	reporter := newUsageReporter(context.Background(), "estimate-provider", "gpt-4o", nil)
+	request := []byte(`{"messages":[{"role":"user","content":"How many tokens is this?"}]}`)
+	response := []byte(`{"choices":[{"message":{"content":"About seven."}}]}`)
+	reporter.publish(context.Background(), parseOpenAIUsage(response))
+	reporter.publishEstimated(context.Background(), request, response)
+
+	for {
+		rec := plugin.waitOne(t)
+		if rec.Provider != "estimate-provider" {
+			continue
+		}
+		if !rec.Estimated || rec.Detail.InputTokens == 0 || rec.Detail.OutputTokens == 0 {
+			t.Fatalf("estimated record = %+v, want estimated input and output tokens", rec)
+		}
+		break
+	}
+
+	reported := newUsageReporter(context.Background(), "estimate-provider", "gpt-4o", nil)
+	reported.publish(context.Background(), usage.Detail{InputTokens: 1})
+	reported.publishEstimated(context.Background(), request, response)
+	if !reported.finished.Load() {
+		t.Fatal("reporter should be finished after publishing upstream usage")
+	}
+}
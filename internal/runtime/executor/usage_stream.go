@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenizer"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+)
+
+// streamTextPaths locate generated text in the chunk formats the translators
+// emit: OpenAI chat, OpenAI responses, Claude and Gemini (plain or wrapped by
+// gemini-cli).
+var streamTextPaths = []string{
+	"choices.#.delta.content",
+	"choices.#.delta.reasoning_content",
+	"choices.#.delta.tool_calls.#.function.arguments",
+	"delta.text",
+	"delta.thinking",
+	"delta.partial_json",
+	"candidates.#.content.parts.#.text",
+	"response.candidates.#.content.parts.#.text",
+}
+
+// activeStreams counts the stream forwarding goroutines started by
+// trackStream that have not finished.
+var activeStreams atomic.Int64
+
+// ActiveStreams returns the number of upstream streams still being forwarded.
+func ActiveStreams() int64 {
+	return activeStreams.Load()
+}
+
+// trackStream forwards chunks unchanged, recording when the first one arrives.
+// When usage-streaming is configured and a streaming usage plugin is
+// registered, it also publishes partial records with the running
+// output-token estimate. Progress stops once the terminal record for r has
+// been published. Once ctx ends, chunks are drained without being forwarded.
+//
+// trackStream owns the terminal record of a stream that ends without upstream
+// usage: once chunks closes it publishes input and output counts estimated
+// from opts.OriginalRequest and the forwarded text, marked as estimated.
+func (r *usageReporter) trackStream(ctx context.Context, cfg *config.Config, opts cliproxyexecutor.Options, chunks <-chan cliproxyexecutor.StreamChunk) <-chan cliproxyexecutor.StreamChunk {
+	if r == nil {
+		return chunks
+	}
+	var interval time.Duration
+	var step int64
+	if cfg != nil {
+		interval = time.Duration(cfg.UsageStreaming.Interval) * time.Second
+		step = int64(cfg.UsageStreaming.Tokens)
+	}
+	partials := (interval > 0 || step > 0) && usage.HasStreamingPlugins()
+	r.tracked.Store(true)
+	request, requestFormat := opts.OriginalRequest, opts.SourceFormat.String()
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	activeStreams.Add(1)
+	go func() {
+		defer activeStreams.Add(-1)
+		defer close(out)
+		forward := true
+		var tokens, reported int64
+		var output []byte
+		lastReport := time.Now()
+		for chunk := range chunks {
+			if chunk.Err == nil {
+				r.markFirstByte()
+			}
+			if chunk.Err == nil && !r.finished.Load() {
+				text := streamChunkText(chunk.Payload)
+				output = append(output, text...)
+				if partials {
+					tokens += r.countTokens(text)
+					due := step > 0 && tokens-reported >= step
+					if !due && interval > 0 && tokens > reported && time.Since(lastReport) >= interval {
+						due = true
+					}
+					if due {
+						record := r.record(usage.Detail{OutputTokens: tokens, TotalTokens: tokens}, false)
+						usage.PublishPartialRecord(ctx, record)
+						reported, lastReport = tokens, time.Now()
+					}
+				}
+			}
+			if !forward {
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-done:
+				// Nobody reads out anymore. Keep draining chunks so the
+				// executor goroutine feeding it can finish and close the
+				// upstream body instead of blocking forever.
+				forward = false
+			}
+		}
+		r.reconcileStream(ctx, requestFormat, request, output)
+	}()
+	return out
+}
+
+// reconcileStream publishes the terminal record of a stream whose upstream
+// never reported usage, estimating both sides with the model's tokenizer.
+func (r *usageReporter) reconcileStream(ctx context.Context, requestFormat string, request, output []byte) {
+	if r.finished.Load() {
+		return
+	}
+	var detail usage.Detail
+	if enc, errTok := tokenizer.ForModel(r.model); errTok == nil {
+		detail.InputTokens = countRequestTokens(enc, requestFormat, request)
+	}
+	detail.OutputTokens = r.countTokens(output)
+	r.publishEstimatedDetail(ctx, detail)
+}
+
+// streamChunkText extracts the generated text carried by one translated
+// stream chunk, which may hold several SSE lines.
+func streamChunkText(payload []byte) []byte {
+	var text []byte
+	for _, line := range bytes.Split(payload, []byte("\n")) {
+		data := jsonPayload(line)
+		if len(data) == 0 || !gjson.ValidBytes(data) {
+			continue
+		}
+		root := gjson.ParseBytes(data)
+		for _, path := range streamTextPaths {
+			text = appendStreamText(text, root.Get(path))
+		}
+		// OpenAI responses deltas carry the text directly under "delta".
+		if delta := root.Get("delta"); delta.Type == gjson.String {
+			text = append(text, delta.Str...)
+		}
+	}
+	return text
+}
+
+// countTokens counts text with the model's tokenizer, falling back to four
+// bytes per token.
+func (r *usageReporter) countTokens(text []byte) int64 {
+	if len(text) == 0 {
+		return 0
+	}
+	if enc, errTok := tokenizer.ForModel(r.model); errTok == nil {
+		if count, errCount := enc.Count(string(text)); errCount == nil {
+			return int64(count)
+		}
+	}
+	return int64((len(text) + 3) / 4)
+}
+
+func appendStreamText(dst []byte, value gjson.Result) []byte {
+	if value.IsArray() {
+		for _, item := range value.Array() {
+			dst = appendStreamText(dst, item)
+		}
+		return dst
+	}
+	if value.Type == gjson.String {
+		dst = append(dst, value.Str...)
+	}
+	return dst
+}
@@ -0,0 +1,184 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// streamingTestPlugin captures partial and terminal records for one provider.
+type streamingTestPlugin struct {
+	provider string
+	mu       sync.Mutex
+	partials []usage.Record
+	terminal []usage.Record
+}
+
+func (p *streamingTestPlugin) HandleUsage(_ context.Context, record usage.Record) {
+	if record.Provider != p.provider {
+		return
+	}
+	p.mu.Lock()
+	p.terminal = append(p.terminal, record)
+	p.mu.Unlock()
+}
+
+func (p *streamingTestPlugin) HandleStreamingUsage(_ context.Context, record usage.Record) {
+	if record.Provider != p.provider {
+		return
+	}
+	p.mu.Lock()
+	p.partials = append(p.partials, record)
+	p.mu.Unlock()
+}
+
+func TestUsageReporterTrackStream_PublishesPartialRecords(t *testing.T) {
+	plugin := &streamingTestPlugin{provider: "test-stream"}
+	usage.RegisterPlugin(plugin)
+
+	cfg := &config.Config{UsageStreaming: config.UsageStreamingConfig{Tokens: 20}}
+	reporter := &usageReporter{provider: "test-stream", model: "gpt-4o"}
+	in := make(chan cliproxyexecutor.StreamChunk)
+	out := reporter.trackStream(context.Background(), cfg, cliproxyexecutor.Options{}, in)
+	go func() {
+		defer close(in)
+		chunk := `data: {"choices":[{"delta":{"content":"` + strings.Repeat("hello ", 30) + `"}}]}`
+		for range 3 {
+			in <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk)}
+		}
+		reporter.publish(context.Background(), usage.Detail{InputTokens: 5, OutputTokens: 90})
+		in <- cliproxyexecutor.StreamChunk{Payload: []byte(chunk)}
+	}()
+	forwarded := 0
+	for range out {
+		forwarded++
+	}
+	if forwarded != 4 {
+		t.Fatalf("forwarded %d chunks, want 4", forwarded)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		plugin.mu.Lock()
+		partials, terminal := append([]usage.Record(nil), plugin.partials...), len(plugin.terminal)
+		plugin.mu.Unlock()
+		if terminal == 1 && len(partials) == 3 {
+			for i, record := range partials {
+				if record.Terminal || record.Detail.OutputTokens <= 0 {
+					t.Fatalf("partial %d = %+v", i, record)
+				}
+				if i > 0 && record.Detail.OutputTokens <= partials[i-1].Detail.OutputTokens {
+					t.Fatalf("partial output tokens should grow: %+v", partials)
+				}
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d partial and %d terminal records, want 3 and 1", len(partials), terminal)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUsageReporterTrackStream_RecordsTiming(t *testing.T) {
+	reporter := &usageReporter{provider: "test-stream-timing", requestedAt: time.Now().Add(-time.Second), retries: 2}
+	in := make(chan cliproxyexecutor.StreamChunk, 1)
+	in <- cliproxyexecutor.StreamChunk{Payload: []byte(`data: {}`)}
+	close(in)
+	for range reporter.trackStream(context.Background(), &config.Config{}, cliproxyexecutor.Options{}, in) {
+	}
+
+	detail := reporter.record(usage.Detail{OutputTokens: 100}, false).Detail
+	if detail.TimeToFirstByte < time.Second || detail.Duration < detail.TimeToFirstByte {
+		t.Fatalf("timing = ttfb %s, duration %s", detail.TimeToFirstByte, detail.Duration)
+	}
+	if detail.OutputTokensPerSecond <= 0 || detail.Retries != 2 {
+		t.Fatalf("detail = %+v", detail)
+	}
+}
+
+func TestUsageReporterTrackStream_EstimatesMissingUsage(t *testing.T) {
+	plugin := &streamingTestPlugin{provider: "test-stream-estimate"}
+	usage.RegisterPlugin(plugin)
+
+	reporter := &usageReporter{provider: "test-stream-estimate", model: "claude-sonnet-4-5"}
+	opts := cliproxyexecutor.Options{
+		SourceFormat:    sdktranslator.FromString("claude"),
+		OriginalRequest: []byte(`{"system":"Be brief.","messages":[{"role":"user","content":"Write a haiku about rivers."}]}`),
+	}
+	in := make(chan cliproxyexecutor.StreamChunk)
+	out := reporter.trackStream(context.Background(), &config.Config{}, opts, in)
+	go func() {
+		defer close(in)
+		in <- cliproxyexecutor.StreamChunk{Payload: []byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Water finds its way\"}}\n\n")}
+		// The executor's fallback must leave the terminal record to trackStream.
+		reporter.ensurePublished(context.Background())
+	}()
+	for range out {
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		plugin.mu.Lock()
+		terminal := append([]usage.Record(nil), plugin.terminal...)
+		plugin.mu.Unlock()
+		if len(terminal) == 1 {
+			record := terminal[0]
+			if !record.Estimated || record.Detail.InputTokens <= 0 || record.Detail.OutputTokens <= 0 {
+				t.Fatalf("record = %+v, want estimated input and output tokens", record)
+			}
+			if record.Detail.TotalTokens != record.Detail.InputTokens+record.Detail.OutputTokens {
+				t.Fatalf("total tokens = %d", record.Detail.TotalTokens)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d terminal records, want 1", len(terminal))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUsageReporterTrackStream_DrainsAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reporter := &usageReporter{provider: "test-drain", model: "gpt-4o"}
+	in := make(chan cliproxyexecutor.StreamChunk)
+	before := ActiveStreams()
+	out := reporter.trackStream(ctx, nil, cliproxyexecutor.Options{}, in)
+	if ActiveStreams() != before+1 {
+		t.Fatalf("active streams = %d, want %d", ActiveStreams(), before+1)
+	}
+
+	// The consumer goes away without reading; the producer must still be
+	// able to send every chunk and finish.
+	cancel()
+	produced := make(chan struct{})
+	go func() {
+		defer close(produced)
+		defer close(in)
+		for range 5 {
+			in <- cliproxyexecutor.StreamChunk{Payload: []byte(`data: {"choices":[{"delta":{"content":"hi"}}]}`)}
+		}
+	}()
+	select {
+	case <-produced:
+	case <-time.After(2 * time.Second):
+		t.Fatal("producer blocked after the context was canceled")
+	}
+	for range out {
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for ActiveStreams() != before {
+		if time.Now().After(deadline) {
+			t.Fatalf("active streams = %d, want %d", ActiveStreams(), before)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
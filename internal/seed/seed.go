@@ -0,0 +1,100 @@
+// Package seed maps the OpenAI sampling seed onto providers that sample
+// deterministically, strips it for models that cannot honour it and reports
+// the outcome to clients.
+package seed
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// HonoredHeader is the response header that tells clients whether the seed
+// they sent reached an upstream that samples deterministically.
+const HonoredHeader = "X-Seed-Honored"
+
+// fieldPath returns the seed field of a provider schema, or "" when the
+// schema has none.
+func fieldPath(format string) string {
+	switch format {
+	case "openai":
+		return "seed"
+	case "gemini":
+		return "generationConfig.seed"
+	case "gemini-cli", "antigravity":
+		return "request.generationConfig.seed"
+	default:
+		return ""
+	}
+}
+
+// FromRequest returns the seed of a request in the given source format.
+func FromRequest(payload []byte, format string) (int64, bool) {
+	path := fieldPath(format)
+	if path == "" {
+		return 0, false
+	}
+	value := gjson.GetBytes(payload, path)
+	if value.Type != gjson.Number {
+		return 0, false
+	}
+	return value.Int(), true
+}
+
+// SetGemini writes seed under a Gemini generationConfig path.
+func SetGemini(out []byte, generationConfigPath string, seed int64) []byte {
+	out, _ = sjson.SetBytes(out, generationConfigPath+".seed", seed)
+	return out
+}
+
+// Fingerprint returns the OpenAI system_fingerprint for a response served by
+// modelVersion. Backends that only expose their model version report it when
+// the original OpenAI request carried a seed; otherwise it returns "".
+func Fingerprint(originalRequest []byte, modelVersion string) string {
+	modelVersion = strings.TrimSpace(modelVersion)
+	if modelVersion == "" {
+		return ""
+	}
+	if _, ok := FromRequest(originalRequest, "openai"); !ok {
+		return ""
+	}
+	return "fp_" + modelVersion
+}
+
+// Honored reports whether a seed sent for model is forwarded upstream. Models
+// the registry does not know and user-defined models keep the seed.
+func Honored(info *registry.ModelInfo) bool {
+	return info == nil || info.UserDefined || info.SupportsSeed
+}
+
+// Supported resolves model for provider and reports whether it keeps a seed.
+func Supported(model, provider string) bool {
+	return Honored(registry.LookupModelInfo(baseModel(model), provider))
+}
+
+// ClientSupported resolves model as registered for clientID and reports
+// whether it keeps a seed.
+func ClientSupported(clientID, model string) bool {
+	return Honored(registry.GetGlobalRegistry().ClientModelInfo(clientID, baseModel(model)))
+}
+
+// Normalize removes the seed from body, already in the provider format, when
+// the target model cannot honour it.
+func Normalize(body []byte, model, format, provider string) []byte {
+	path := fieldPath(format)
+	if path == "" || !gjson.GetBytes(body, path).Exists() || Supported(model, provider) {
+		return body
+	}
+	body, _ = sjson.DeleteBytes(body, path)
+	return body
+}
+
+func baseModel(model string) string {
+	if name := strings.TrimSpace(thinking.ParseSuffix(model).ModelName); name != "" {
+		return name
+	}
+	return strings.TrimSpace(model)
+}
@@ -0,0 +1,87 @@
+package seed
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+)
+
+func registerSeedModel(t *testing.T, provider string, info *registry.ModelInfo) string {
+	t.Helper()
+	clientID := uuid.NewString()
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(clientID, provider, []*registry.ModelInfo{info})
+	t.Cleanup(func() { reg.UnregisterClient(clientID) })
+	return clientID
+}
+
+func TestNormalizeStripsUnsupportedTargets(t *testing.T) {
+	registerSeedModel(t, "gemini-cli", &registry.ModelInfo{ID: "seed-test-cli"})
+
+	body := []byte(`{"request":{"generationConfig":{"seed":42,"temperature":1}}}`)
+	out := Normalize(body, "seed-test-cli", "gemini-cli", "gemini-cli")
+	if gjson.GetBytes(out, "request.generationConfig.seed").Exists() {
+		t.Fatalf("seed not stripped: %s", out)
+	}
+	if gjson.GetBytes(out, "request.generationConfig.temperature").Int() != 1 {
+		t.Fatalf("unrelated options lost: %s", out)
+	}
+}
+
+func TestNormalizeKeepsSupportedAndUnknownTargets(t *testing.T) {
+	registerSeedModel(t, "gemini", &registry.ModelInfo{ID: "seed-test-gemini", SupportsSeed: true})
+	registerSeedModel(t, "openai-compatibility", &registry.ModelInfo{ID: "seed-test-compat", UserDefined: true})
+
+	body := []byte(`{"generationConfig":{"seed":7}}`)
+	if out := Normalize(body, "seed-test-gemini(high)", "gemini", "gemini"); string(out) != string(body) {
+		t.Fatalf("supported model rewritten: %s", out)
+	}
+	body = []byte(`{"seed":7}`)
+	if out := Normalize(body, "seed-test-compat", "openai", "openai-compatibility"); string(out) != string(body) {
+		t.Fatalf("user-defined model rewritten: %s", out)
+	}
+	if out := Normalize(body, "unknown-seed-model", "openai", "openai"); string(out) != string(body) {
+		t.Fatalf("unknown model rewritten: %s", out)
+	}
+}
+
+func TestClientSupported(t *testing.T) {
+	supported := registerSeedModel(t, "gemini", &registry.ModelInfo{ID: "seed-test-client", SupportsSeed: true})
+	unsupported := registerSeedModel(t, "claude", &registry.ModelInfo{ID: "seed-test-client"})
+
+	if !ClientSupported(supported, "seed-test-client") {
+		t.Fatal("expected seed to be honoured by the seed-capable client")
+	}
+	if ClientSupported(unsupported, "seed-test-client") {
+		t.Fatal("expected seed to be dropped by the client without seed support")
+	}
+}
+
+func TestRequestAndFingerprint(t *testing.T) {
+	if value, ok := FromRequest([]byte(`{"seed":12}`), "openai"); !ok || value != 12 {
+		t.Fatalf("openai seed = %d/%t", value, ok)
+	}
+	if value, ok := FromRequest([]byte(`{"generationConfig":{"seed":3}}`), "gemini"); !ok || value != 3 {
+		t.Fatalf("gemini seed = %d/%t", value, ok)
+	}
+	if _, ok := FromRequest([]byte(`{"seed":null}`), "openai"); ok {
+		t.Fatal("null seed must be ignored")
+	}
+	if _, ok := FromRequest([]byte(`{"seed":1}`), "claude"); ok {
+		t.Fatal("claude has no seed field")
+	}
+
+	out := SetGemini([]byte(`{}`), "request.generationConfig", 5)
+	if gjson.GetBytes(out, "request.generationConfig.seed").Int() != 5 {
+		t.Fatalf("gemini seed = %s", out)
+	}
+
+	if got := Fingerprint([]byte(`{"seed":1}`), "gemini-2.5-pro-001"); got != "fp_gemini-2.5-pro-001" {
+		t.Fatalf("fingerprint = %q", got)
+	}
+	if got := Fingerprint([]byte(`{}`), "gemini-2.5-pro-001"); got != "" {
+		t.Fatalf("unseeded fingerprint = %q", got)
+	}
+}
@@ -0,0 +1,428 @@
+// Package selfupdate downloads signed release builds from the GitHub release
+// feed and swaps them in for the running executable.
+//
+// Releases publish checksums.txt and checksums.txt.sig, an Ed25519 signature
+// over checksums.txt. An archive is trusted only when the signature verifies
+// against the release public key and the archive's SHA-256 matches its line
+// in checksums.txt.
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// LatestReleaseURL is the release feed queried for updates.
+	LatestReleaseURL = "https://api.github.com/repos/router-for-me/CLIProxyAPIPlus/releases/latest"
+	// BinaryName is the executable name inside release archives.
+	BinaryName = "cli-proxy-api-plus"
+
+	checksumsAssetName = "checksums.txt"
+	signatureAssetName = "checksums.txt.sig"
+	userAgent          = "CLIProxyAPIPlus"
+
+	// maxDownloadBytes bounds any single release asset.
+	maxDownloadBytes = 256 << 20
+)
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// Release is the subset of the GitHub release payload the updater uses.
+type Release struct {
+	TagName string  `json:"tag_name"`
+	Name    string  `json:"name"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Version returns the release version without the leading "v".
+func (r *Release) Version() string {
+	version := strings.TrimSpace(r.TagName)
+	if version == "" {
+		version = strings.TrimSpace(r.Name)
+	}
+	return strings.TrimPrefix(version, "v")
+}
+
+func (r *Release) asset(name string) (Asset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return Asset{}, false
+}
+
+// ArchiveFor returns the archive built for goos/goarch, named
+// "<project>_<version>_<goos>_<goarch>.tar.gz" (".zip" on Windows).
+func (r *Release) ArchiveFor(goos, goarch string) (Asset, error) {
+	ext := ".tar.gz"
+	if goos == "windows" {
+		ext = ".zip"
+	}
+	suffix := "_" + goos + "_" + goarch + ext
+	for _, asset := range r.Assets {
+		if strings.HasSuffix(asset.Name, suffix) {
+			return asset, nil
+		}
+	}
+	return Asset{}, fmt.Errorf("release %s has no archive for %s/%s", r.Version(), goos, goarch)
+}
+
+// SameVersion reports whether the running build already is release version.
+// Builds are stamped "<version>-plus", so that suffix is ignored.
+func SameVersion(current, release string) bool {
+	return normalizeVersion(current) == normalizeVersion(release)
+}
+
+// OlderVersion reports whether release predates the running build current.
+// Versions that are not dotted numbers, such as "dev", are never older.
+func OlderVersion(current, release string) bool {
+	cur, okCur := versionNumbers(current)
+	rel, okRel := versionNumbers(release)
+	if !okCur || !okRel {
+		return false
+	}
+	for i := 0; i < len(cur) || i < len(rel); i++ {
+		var c, r int
+		if i < len(cur) {
+			c = cur[i]
+		}
+		if i < len(rel) {
+			r = rel[i]
+		}
+		if c != r {
+			return r < c
+		}
+	}
+	return false
+}
+
+func normalizeVersion(v string) string {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	return strings.TrimSuffix(v, "-plus")
+}
+
+// versionNumbers parses the dotted numeric part of v, ignoring any
+// "-suffix".
+func versionNumbers(v string) ([]int, bool) {
+	v = normalizeVersion(v)
+	if idx := strings.IndexByte(v, '-'); idx >= 0 {
+		v = v[:idx]
+	}
+	fields := strings.Split(v, ".")
+	numbers := make([]int, 0, len(fields))
+	for _, field := range fields {
+		n, errAtoi := strconv.Atoi(field)
+		if errAtoi != nil || n < 0 {
+			return nil, false
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, true
+}
+
+// FetchLatest reads the latest release from feedURL.
+func FetchLatest(ctx context.Context, client *http.Client, feedURL string) (*Release, error) {
+	data, errFetch := Download(ctx, client, feedURL)
+	if errFetch != nil {
+		return nil, fmt.Errorf("fetch release feed: %w", errFetch)
+	}
+	var release Release
+	if errDecode := json.Unmarshal(data, &release); errDecode != nil {
+		return nil, fmt.Errorf("decode release feed: %w", errDecode)
+	}
+	if release.Version() == "" {
+		return nil, errors.New("release feed is missing a version")
+	}
+	return &release, nil
+}
+
+// Download fetches url, failing on non-200 responses and oversized bodies.
+func Download(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if errReq != nil {
+		return nil, errReq
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if strings.HasPrefix(url, "https://api.github.com/") {
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+	resp, errDo := client.Do(req)
+	if errDo != nil {
+		return nil, errDo
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	data, errRead := io.ReadAll(io.LimitReader(resp.Body, maxDownloadBytes+1))
+	if errRead != nil {
+		return nil, errRead
+	}
+	if len(data) > maxDownloadBytes {
+		return nil, fmt.Errorf("%s exceeds %d bytes", url, maxDownloadBytes)
+	}
+	return data, nil
+}
+
+// ParsePublicKey decodes an Ed25519 public key given as PEM, or as base64 of
+// either the raw 32-byte key or its PKIX DER encoding.
+func ParsePublicKey(value string) (ed25519.PublicKey, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, errors.New("empty public key")
+	}
+	var der []byte
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, errDecode := base64.StdEncoding.DecodeString(value)
+		if errDecode != nil {
+			return nil, fmt.Errorf("decode public key: %w", errDecode)
+		}
+		if len(decoded) == ed25519.PublicKeySize {
+			return ed25519.PublicKey(decoded), nil
+		}
+		der = decoded
+	}
+	parsed, errParse := x509.ParsePKIXPublicKey(der)
+	if errParse != nil {
+		return nil, fmt.Errorf("parse public key: %w", errParse)
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is %T, want Ed25519", parsed)
+	}
+	return key, nil
+}
+
+// VerifyChecksums checks signature over checksums. The signature may be the
+// raw 64 bytes or their base64 encoding.
+func VerifyChecksums(checksums, signature []byte, key ed25519.PublicKey) error {
+	if len(signature) != ed25519.SignatureSize {
+		decoded, errDecode := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if errDecode != nil || len(decoded) != ed25519.SignatureSize {
+			return errors.New("malformed checksums signature")
+		}
+		signature = decoded
+	}
+	if !ed25519.Verify(key, checksums, signature) {
+		return errors.New("checksums signature does not match the release public key")
+	}
+	return nil
+}
+
+// ChecksumFor returns the SHA-256 listed for name in a "<hex>  <name>"
+// checksums file.
+func ChecksumFor(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s is not listed in %s", name, checksumsAssetName)
+}
+
+// Verified downloads the checksums and their signature from release,
+// verifies them with key, then downloads archive and checks its digest.
+func Verified(ctx context.Context, client *http.Client, release *Release, archive Asset, key ed25519.PublicKey) ([]byte, error) {
+	checksumsAsset, okChecksums := release.asset(checksumsAssetName)
+	signatureAsset, okSignature := release.asset(signatureAssetName)
+	if !okChecksums || !okSignature {
+		return nil, fmt.Errorf("release %s is not signed: %s or %s missing", release.Version(), checksumsAssetName, signatureAssetName)
+	}
+	checksums, errChecksums := Download(ctx, client, checksumsAsset.BrowserDownloadURL)
+	if errChecksums != nil {
+		return nil, fmt.Errorf("download %s: %w", checksumsAssetName, errChecksums)
+	}
+	signature, errSignature := Download(ctx, client, signatureAsset.BrowserDownloadURL)
+	if errSignature != nil {
+		return nil, fmt.Errorf("download %s: %w", signatureAssetName, errSignature)
+	}
+	if errVerify := VerifyChecksums(checksums, signature, key); errVerify != nil {
+		return nil, errVerify
+	}
+	expected, errExpected := ChecksumFor(checksums, archive.Name)
+	if errExpected != nil {
+		return nil, errExpected
+	}
+	data, errArchive := Download(ctx, client, archive.BrowserDownloadURL)
+	if errArchive != nil {
+		return nil, fmt.Errorf("download %s: %w", archive.Name, errArchive)
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return nil, fmt.Errorf("%s checksum mismatch: got %s, want %s", archive.Name, actual, expected)
+	}
+	return data, nil
+}
+
+// ExtractBinary returns the file called binaryName from a .tar.gz or .zip
+// archive, wherever it sits in the tree.
+func ExtractBinary(archive []byte, archiveName, binaryName string) ([]byte, error) {
+	if strings.HasSuffix(archiveName, ".zip") {
+		zr, errZip := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if errZip != nil {
+			return nil, fmt.Errorf("open %s: %w", archiveName, errZip)
+		}
+		for _, file := range zr.File {
+			if file.FileInfo().IsDir() || filepath.Base(file.Name) != binaryName {
+				continue
+			}
+			rc, errOpen := file.Open()
+			if errOpen != nil {
+				return nil, errOpen
+			}
+			defer func() { _ = rc.Close() }()
+			return readBinary(rc)
+		}
+		return nil, fmt.Errorf("%s not found in %s", binaryName, archiveName)
+	}
+
+	gz, errGzip := gzip.NewReader(bytes.NewReader(archive))
+	if errGzip != nil {
+		return nil, fmt.Errorf("open %s: %w", archiveName, errGzip)
+	}
+	defer func() { _ = gz.Close() }()
+	tr := tar.NewReader(gz)
+	for {
+		header, errNext := tr.Next()
+		if errors.Is(errNext, io.EOF) {
+			return nil, fmt.Errorf("%s not found in %s", binaryName, archiveName)
+		}
+		if errNext != nil {
+			return nil, fmt.Errorf("read %s: %w", archiveName, errNext)
+		}
+		if header.Typeflag == tar.TypeReg && filepath.Base(header.Name) == binaryName {
+			return readBinary(tr)
+		}
+	}
+}
+
+func readBinary(r io.Reader) ([]byte, error) {
+	data, errRead := io.ReadAll(io.LimitReader(r, maxDownloadBytes+1))
+	if errRead != nil {
+		return nil, errRead
+	}
+	if len(data) > maxDownloadBytes {
+		return nil, fmt.Errorf("binary exceeds %d bytes", maxDownloadBytes)
+	}
+	if len(data) == 0 {
+		return nil, errors.New("binary is empty")
+	}
+	return data, nil
+}
+
+// Replace installs binary as executable and keeps the previous build as
+// executable+".old", which it returns. Both steps are renames within the
+// executable's directory, so a running process, even on Windows, keeps its
+// image and the path is never left without a binary.
+func Replace(executable string, binary []byte) (string, error) {
+	info, errStat := os.Stat(executable)
+	if errStat != nil {
+		return "", errStat
+	}
+	tmp, errCreate := os.CreateTemp(filepath.Dir(executable), ".update-*")
+	if errCreate != nil {
+		return "", errCreate
+	}
+	tmpName := tmp.Name()
+	defer func() { _ = os.Remove(tmpName) }()
+	_, errWrite := tmp.Write(binary)
+	if errSync := tmp.Sync(); errWrite == nil {
+		errWrite = errSync
+	}
+	if errClose := tmp.Close(); errWrite == nil {
+		errWrite = errClose
+	}
+	if errWrite != nil {
+		return "", errWrite
+	}
+	if errChmod := os.Chmod(tmpName, info.Mode().Perm()|0o111); errChmod != nil {
+		return "", errChmod
+	}
+
+	backup := executable + ".old"
+	_ = os.Remove(backup)
+	if errBackup := os.Rename(executable, backup); errBackup != nil {
+		return "", fmt.Errorf("move current binary aside: %w", errBackup)
+	}
+	if errInstall := os.Rename(tmpName, executable); errInstall != nil {
+		_ = os.Rename(backup, executable)
+		return "", fmt.Errorf("install new binary: %w", errInstall)
+	}
+	return backup, nil
+}
+
+// Rollback restores the build Replace moved to backup. The rejected binary
+// is renamed rather than deleted, since it may still be running.
+func Rollback(executable, backup string) error {
+	rejected := executable + ".rejected"
+	_ = os.Remove(rejected)
+	if errAside := os.Rename(executable, rejected); errAside != nil && !os.IsNotExist(errAside) {
+		return fmt.Errorf("move rejected binary aside: %w", errAside)
+	}
+	if errRestore := os.Rename(backup, executable); errRestore != nil {
+		return fmt.Errorf("restore previous binary: %w", errRestore)
+	}
+	return nil
+}
+
+// WaitHealthy polls url until it answers 200 or timeout passes.
+func WaitHealthy(ctx context.Context, client *http.Client, url string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if errReq != nil {
+			return errReq
+		}
+		resp, errDo := client.Do(req)
+		if errDo == nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			lastErr = fmt.Errorf("status %d", resp.StatusCode)
+		} else {
+			lastErr = errDo
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s not healthy after %s: %w", url, timeout, lastErr)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tarGz(t *testing.T, name string, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "LICENSE", Mode: 0o644, Size: 3, Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = tw.Write([]byte("MIT"))
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = tw.Write(content)
+	_ = tw.Close()
+	_ = gz.Close()
+	return buf.Bytes()
+}
+
+type fakeRelease struct {
+	server    *httptest.Server
+	release   *Release
+	key       ed25519.PublicKey
+	archive   []byte
+	checksums []byte
+	signature []byte
+}
+
+func newFakeRelease(t *testing.T, binary []byte) *fakeRelease {
+	t.Helper()
+	pub, priv, errKey := ed25519.GenerateKey(rand.Reader)
+	if errKey != nil {
+		t.Fatal(errKey)
+	}
+	f := &fakeRelease{key: pub, archive: tarGz(t, BinaryName, binary)}
+	const archiveName = "CLIProxyAPIPlus_6.9.0_linux_amd64.tar.gz"
+	sum := sha256.Sum256(f.archive)
+	f.checksums = []byte(hex.EncodeToString(sum[:]) + "  " + archiveName + "\n" + strings.Repeat("0", 64) + "  other.zip\n")
+	f.signature = ed25519.Sign(priv, f.checksums)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, _ *http.Request) { _ = json.NewEncoder(w).Encode(f.release) })
+	mux.HandleFunc("/"+archiveName, func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(f.archive) })
+	mux.HandleFunc("/checksums.txt", func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(f.checksums) })
+	mux.HandleFunc("/checksums.txt.sig", func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(f.signature) })
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+
+	f.release = &Release{TagName: "v6.9.0", Assets: []Asset{
+		{Name: archiveName, BrowserDownloadURL: f.server.URL + "/" + archiveName},
+		{Name: "checksums.txt", BrowserDownloadURL: f.server.URL + "/checksums.txt"},
+		{Name: "checksums.txt.sig", BrowserDownloadURL: f.server.URL + "/checksums.txt.sig"},
+	}}
+	return f
+}
+
+func TestVerifiedDownloadsSignedArchive(t *testing.T) {
+	f := newFakeRelease(t, []byte("new-binary"))
+	ctx := context.Background()
+
+	release, errFetch := FetchLatest(ctx, f.server.Client(), f.server.URL+"/latest")
+	if errFetch != nil {
+		t.Fatalf("FetchLatest: %v", errFetch)
+	}
+	if release.Version() != "6.9.0" {
+		t.Fatalf("Version() = %q, want 6.9.0", release.Version())
+	}
+	archive, errArchive := release.ArchiveFor("linux", "amd64")
+	if errArchive != nil {
+		t.Fatal(errArchive)
+	}
+	data, errVerified := Verified(ctx, f.server.Client(), release, archive, f.key)
+	if errVerified != nil {
+		t.Fatalf("Verified: %v", errVerified)
+	}
+	binary, errExtract := ExtractBinary(data, archive.Name, BinaryName)
+	if errExtract != nil {
+		t.Fatal(errExtract)
+	}
+	if string(binary) != "new-binary" {
+		t.Fatalf("binary = %q", binary)
+	}
+	if _, errWindows := release.ArchiveFor("windows", "amd64"); errWindows == nil {
+		t.Fatal("expected no windows archive")
+	}
+}
+
+func TestVerifiedRejectsTampering(t *testing.T) {
+	ctx := context.Background()
+
+	f := newFakeRelease(t, []byte("new-binary"))
+	archive, _ := f.release.ArchiveFor("linux", "amd64")
+	otherKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := Verified(ctx, f.server.Client(), f.release, archive, otherKey); err == nil {
+		t.Fatal("expected a signature from another key to be rejected")
+	}
+
+	f.archive = tarGz(t, BinaryName, []byte("evil-binary"))
+	if _, err := Verified(ctx, f.server.Client(), f.release, archive, f.key); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	f.release.Assets = f.release.Assets[:2]
+	if _, err := Verified(ctx, f.server.Client(), f.release, archive, f.key); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Fatalf("expected unsigned release to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyChecksumsAcceptsBase64Signature(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	checksums := []byte("abc  file.tar.gz\n")
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, checksums))
+	if err := VerifyChecksums(checksums, []byte(signature+"\n"), pub); err != nil {
+		t.Fatalf("VerifyChecksums: %v", err)
+	}
+	if err := VerifyChecksums(append(checksums, 'x'), []byte(signature), pub); err == nil {
+		t.Fatal("expected modified checksums to fail verification")
+	}
+}
+
+func TestParsePublicKeyFormats(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	der, errMarshal := x509.MarshalPKIXPublicKey(pub)
+	if errMarshal != nil {
+		t.Fatal(errMarshal)
+	}
+	for name, value := range map[string]string{
+		"raw":  base64.StdEncoding.EncodeToString(pub),
+		"der":  base64.StdEncoding.EncodeToString(der),
+		"pem":  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		"trim": "  " + base64.StdEncoding.EncodeToString(pub) + "\n",
+	} {
+		key, errParse := ParsePublicKey(value)
+		if errParse != nil {
+			t.Fatalf("%s: %v", name, errParse)
+		}
+		if !key.Equal(pub) {
+			t.Fatalf("%s: key mismatch", name)
+		}
+	}
+	if _, err := ParsePublicKey("not-a-key"); err == nil {
+		t.Fatal("expected invalid key to fail")
+	}
+}
+
+func TestExtractBinaryFromZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("dist/" + BinaryName + ".exe")
+	_, _ = w.Write([]byte("windows-binary"))
+	_ = zw.Close()
+
+	binary, errExtract := ExtractBinary(buf.Bytes(), "CLIProxyAPIPlus_6.9.0_windows_amd64.zip", BinaryName+".exe")
+	if errExtract != nil {
+		t.Fatal(errExtract)
+	}
+	if string(binary) != "windows-binary" {
+		t.Fatalf("binary = %q", binary)
+	}
+	if _, err := ExtractBinary(buf.Bytes(), "x.zip", BinaryName); err == nil {
+		t.Fatal("expected missing binary to fail")
+	}
+}
+
+func TestReplaceAndRollback(t *testing.T) {
+	executable := filepath.Join(t.TempDir(), BinaryName)
+	if err := os.WriteFile(executable, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, errReplace := Replace(executable, []byte("new"))
+	if errReplace != nil {
+		t.Fatalf("Replace: %v", errReplace)
+	}
+	if got, _ := os.ReadFile(executable); string(got) != "new" {
+		t.Fatalf("executable = %q, want new", got)
+	}
+	if got, _ := os.ReadFile(backup); string(got) != "old" {
+		t.Fatalf("backup = %q, want old", got)
+	}
+	info, _ := os.Stat(executable)
+	if info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("new binary not executable: %v", info.Mode())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(executable))
+	if len(entries) != 2 {
+		t.Fatalf("leftover files after replace: %v", entries)
+	}
+
+	if err := Rollback(executable, backup); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if got, _ := os.ReadFile(executable); string(got) != "old" {
+		t.Fatalf("executable after rollback = %q, want old", got)
+	}
+	if _, err := os.Stat(executable + ".rejected"); err != nil {
+		t.Fatalf("rejected binary not kept: %v", err)
+	}
+}
+
+func TestSameVersion(t *testing.T) {
+	cases := []struct {
+		current, release string
+		want             bool
+	}{
+		{"6.9.0-plus", "6.9.0", true},
+		{"v6.9.0", "6.9.0", true},
+		{"6.8.9-plus", "6.9.0", false},
+		{"dev", "6.9.0", false},
+	}
+	for _, tc := range cases {
+		if got := SameVersion(tc.current, tc.release); got != tc.want {
+			t.Errorf("SameVersion(%q, %q) = %v, want %v", tc.current, tc.release, got, tc.want)
+		}
+	}
+}
+
+func TestOlderVersion(t *testing.T) {
+	cases := []struct {
+		current, release string
+		want             bool
+	}{
+		{"6.9.1-plus", "6.9.0", true},
+		{"v6.10.0", "6.9.9", true},
+		{"6.9.0-plus", "6.9.0", false},
+		{"6.9.0", "6.9.0.1", false},
+		{"6.8.9-plus", "6.9.0", false},
+		{"dev", "6.9.0", false},
+	}
+	for _, tc := range cases {
+		if got := OlderVersion(tc.current, tc.release); got != tc.want {
+			t.Errorf("OlderVersion(%q, %q) = %v, want %v", tc.current, tc.release, got, tc.want)
+		}
+	}
+}
+
+func TestWaitHealthy(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		if calls < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	if err := WaitHealthy(context.Background(), srv.Client(), srv.URL, 5*time.Second); err != nil {
+		t.Fatalf("WaitHealthy: %v", err)
+	}
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	if err := WaitHealthy(context.Background(), down.Client(), down.URL, time.Second); err == nil {
+		t.Fatal("expected an unhealthy server to time out")
+	}
+}
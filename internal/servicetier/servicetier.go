@@ -0,0 +1,132 @@
+// Package servicetier maps the OpenAI service_tier and the X-Request-Priority
+// header onto rate-limit queue priorities and provider-native tier options,
+// and reports the tier that served a request.
+package servicetier
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// OpenAI service tiers.
+const (
+	Auto     = "auto"
+	Default  = "default"
+	Flex     = "flex"
+	Priority = "priority"
+	Scale    = "scale"
+)
+
+// RequestHeader lets clients that cannot set service_tier ask for a priority:
+// "high", "normal" or "low", or a service tier name.
+const RequestHeader = "X-Request-Priority"
+
+// ResponseHeader reports the tier that served a request.
+const ResponseHeader = "X-Service-Tier"
+
+// Normalize returns the OpenAI tier named by value, accepting the Claude
+// service_tier values and the X-Request-Priority levels, or "" when value
+// names no tier.
+func Normalize(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case Priority, "high":
+		return Priority
+	case Default, "normal", "standard", "standard_only":
+		return Default
+	case Flex, "low":
+		return Flex
+	case Auto:
+		return Auto
+	case Scale:
+		return Scale
+	default:
+		return ""
+	}
+}
+
+// FromRequest returns the tier requested by a payload in the given source
+// format.
+func FromRequest(payload []byte, format string) string {
+	switch format {
+	case "openai", "openai-response", "claude":
+		return Normalize(gjson.GetBytes(payload, "service_tier").String())
+	default:
+		return ""
+	}
+}
+
+// Rank orders tiers for the rate-limit queue: requests of a higher rank get
+// a freed credential first.
+func Rank(tier string) int {
+	switch tier {
+	case Priority:
+		return 2
+	case Flex:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// nativeProvider reports whether provider has a tier option Apply sets.
+func nativeProvider(provider string) bool {
+	return provider == "claude" || provider == "codex"
+}
+
+// Apply sets the tier option of body, already in the provider format, when
+// the provider has one and the body does not choose itself. Claude only
+// distinguishes whether priority capacity may be used; Codex accepts the
+// priority tier alone.
+func Apply(body []byte, provider, tier string) []byte {
+	if tier == "" || !nativeProvider(provider) || gjson.GetBytes(body, "service_tier").Exists() {
+		return body
+	}
+	var value string
+	switch provider {
+	case "claude":
+		value = "standard_only"
+		if tier == Priority || tier == Auto || tier == Scale {
+			value = Auto
+		}
+	case "codex":
+		if tier != Priority {
+			return body
+		}
+		value = Priority
+	}
+	out, err := sjson.SetBytes(body, "service_tier", value)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// FromResponse returns the tier an upstream reported in a response in the
+// given client format, or "".
+func FromResponse(payload []byte, format string) string {
+	switch format {
+	case "openai":
+		return Normalize(gjson.GetBytes(payload, "service_tier").String())
+	case "openai-response":
+		if tier := Normalize(gjson.GetBytes(payload, "service_tier").String()); tier != "" {
+			return tier
+		}
+		return Normalize(gjson.GetBytes(payload, "response.service_tier").String())
+	case "claude":
+		return Normalize(gjson.GetBytes(payload, "usage.service_tier").String())
+	default:
+		return ""
+	}
+}
+
+// Effective returns the tier that serves a request for tier on provider when
+// the upstream does not report one: providers without tiers serve every
+// request at the default tier.
+func Effective(provider, tier string) string {
+	if tier == Priority && nativeProvider(provider) {
+		return Priority
+	}
+	return Default
+}
@@ -0,0 +1,57 @@
+package servicetier
+
+import "testing"
+
+func TestRequestTiers(t *testing.T) {
+	cases := []struct {
+		payload, format, want string
+	}{
+		{`{"service_tier":"priority"}`, "openai", Priority},
+		{`{"service_tier":"flex"}`, "openai-response", Flex},
+		{`{"service_tier":"standard_only"}`, "claude", Default},
+		{`{"service_tier":"priority"}`, "gemini", ""},
+		{`{"service_tier":"turbo"}`, "openai", ""},
+	}
+	for _, tc := range cases {
+		if got := FromRequest([]byte(tc.payload), tc.format); got != tc.want {
+			t.Errorf("FromRequest(%s, %s) = %q, want %q", tc.payload, tc.format, got, tc.want)
+		}
+	}
+	if Normalize(" High ") != Priority || Normalize("low") != Flex || Normalize("normal") != Default {
+		t.Fatal("priority header levels must map onto tiers")
+	}
+	if !(Rank(Priority) > Rank(Default) && Rank(Default) > Rank(Flex) && Rank("") == Rank(Auto)) {
+		t.Fatal("unexpected rank order")
+	}
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		provider, tier, body, want string
+	}{
+		{"claude", Priority, `{}`, `{"service_tier":"auto"}`},
+		{"claude", Flex, `{}`, `{"service_tier":"standard_only"}`},
+		{"claude", Priority, `{"service_tier":"standard_only"}`, `{"service_tier":"standard_only"}`},
+		{"codex", Priority, `{}`, `{"service_tier":"priority"}`},
+		{"codex", Flex, `{}`, `{}`},
+		{"gemini", Priority, `{}`, `{}`},
+		{"claude", "", `{}`, `{}`},
+	}
+	for _, tc := range cases {
+		if got := string(Apply([]byte(tc.body), tc.provider, tc.tier)); got != tc.want {
+			t.Errorf("Apply(%s, %s, %s) = %s, want %s", tc.body, tc.provider, tc.tier, got, tc.want)
+		}
+	}
+}
+
+func TestResponseTiers(t *testing.T) {
+	if got := FromResponse([]byte(`{"usage":{"service_tier":"standard"}}`), "claude"); got != Default {
+		t.Fatalf("claude tier = %q", got)
+	}
+	if got := FromResponse([]byte(`{"response":{"service_tier":"flex"}}`), "openai-response"); got != Flex {
+		t.Fatalf("responses tier = %q", got)
+	}
+	if Effective("codex", Priority) != Priority || Effective("gemini", Priority) != Default || Effective("claude", Flex) != Default {
+		t.Fatal("unexpected effective tiers")
+	}
+}
@@ -0,0 +1,139 @@
+package sharedstate
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Leader election picks one replica to run work that must not race across
+// instances, chiefly OAuth token refresh: two replicas refreshing the same
+// credential can invalidate each other's refresh tokens.
+const (
+	leaderKey           = "leader"
+	leaderLease         = 15 * time.Second
+	leaderRenewInterval = 5 * time.Second
+)
+
+type leaderElection struct {
+	store     Store
+	cancel    context.CancelFunc
+	done      chan struct{}
+	renewedAt time.Time
+}
+
+var (
+	election *leaderElection
+	electing atomic.Bool
+	leading  atomic.Bool
+
+	leadershipMu        sync.RWMutex
+	leadershipListeners = make(map[int]func(bool))
+	nextListenerID      int
+)
+
+// IsLeader reports whether this replica should run singleton background work.
+// Every replica is a leader unless leader election is enabled.
+func IsLeader() bool {
+	return !electing.Load() || leading.Load()
+}
+
+// OnLeadershipChange registers fn to be called when this replica gains or
+// loses leadership. The returned function removes the listener.
+func OnLeadershipChange(fn func(leading bool)) func() {
+	leadershipMu.Lock()
+	id := nextListenerID
+	nextListenerID++
+	leadershipListeners[id] = fn
+	leadershipMu.Unlock()
+	return func() {
+		leadershipMu.Lock()
+		delete(leadershipListeners, id)
+		leadershipMu.Unlock()
+	}
+}
+
+// restartElectionLocked stops any running election and, when enabled,
+// starts campaigning on store. Callers hold mu.
+func restartElectionLocked(store Store, enabled bool) {
+	if election != nil {
+		election.cancel()
+		<-election.done
+		election = nil
+	}
+	if !enabled {
+		electing.Store(false)
+		setLeading(false)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	election = &leaderElection{store: store, cancel: cancel, done: make(chan struct{})}
+	electing.Store(true)
+	election.campaign(ctx)
+	go election.run(ctx)
+}
+
+func (e *leaderElection) run(ctx context.Context) {
+	defer close(e.done)
+	ticker := time.NewTicker(leaderRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if leading.Load() {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), leaderRenewInterval)
+				if errRelease := e.store.Release(releaseCtx, leaderKey, instanceID); errRelease != nil {
+					log.Debugf("shared state: failed to release leadership: %v", errRelease)
+				}
+				cancel()
+				setLeading(false)
+			}
+			return
+		case <-ticker.C:
+			e.campaign(ctx)
+		}
+	}
+}
+
+func (e *leaderElection) campaign(ctx context.Context) {
+	attemptCtx, cancel := context.WithTimeout(ctx, leaderRenewInterval)
+	acquired, errAcquire := e.store.Acquire(attemptCtx, leaderKey, instanceID, leaderLease)
+	cancel()
+	now := time.Now()
+	switch {
+	case errAcquire != nil:
+		log.Warnf("shared state: leader lease renewal failed: %v", errAcquire)
+		// Step down before the lease we last renewed can expire elsewhere.
+		if leading.Load() && now.Sub(e.renewedAt) >= leaderLease-leaderRenewInterval {
+			setLeading(false)
+		}
+	case acquired:
+		e.renewedAt = now
+		setLeading(true)
+	default:
+		setLeading(false)
+	}
+}
+
+func setLeading(value bool) {
+	if leading.Swap(value) == value {
+		return
+	}
+	if value {
+		log.Info("shared state: this replica is now the leader")
+	} else if electing.Load() {
+		log.Info("shared state: this replica is no longer the leader")
+	}
+	leadershipMu.RLock()
+	listeners := make([]func(bool), 0, len(leadershipListeners))
+	for _, fn := range leadershipListeners {
+		listeners = append(listeners, fn)
+	}
+	leadershipMu.RUnlock()
+	for _, fn := range listeners {
+		fn(value)
+	}
+}
@@ -0,0 +1,109 @@
+package sharedstate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval bounds how often expired entries are purged on write.
+const memorySweepInterval = time.Minute
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// memoryStore is the single-process backend. Publish has no other replica
+// to reach, so messages are dropped.
+type memoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.lookupLocked(key, time.Now())
+	return value, ok, nil
+}
+
+func (s *memoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+	entry := memoryEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = entry
+	if now.Sub(s.lastSweep) >= memorySweepInterval {
+		s.lastSweep = now
+		for k, e := range s.entries {
+			if !e.expires.IsZero() && !now.Before(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Take(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.lookupLocked(key, time.Now())
+	delete(s.entries, key)
+	return value, ok, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStore) Acquire(_ context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value, ok := s.lookupLocked(key, now); ok && string(value) != owner {
+		return false, nil
+	}
+	s.entries[key] = memoryEntry{value: []byte(owner), expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *memoryStore) Release(_ context.Context, key, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value, ok := s.lookupLocked(key, time.Now()); ok && string(value) == owner {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+func (s *memoryStore) Publish(context.Context, []byte) error { return nil }
+
+func (s *memoryStore) Subscribe(context.Context, func([]byte)) error { return nil }
+
+func (s *memoryStore) Distributed() bool { return false }
+
+func (s *memoryStore) Close() error { return nil }
+
+func (s *memoryStore) lookupLocked(key string, now time.Time) ([]byte, bool) {
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expires.IsZero() && !now.Before(entry.expires) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return append([]byte(nil), entry.value...), true
+}
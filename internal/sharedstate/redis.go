@@ -0,0 +1,135 @@
+package sharedstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// redisConnectTimeout bounds the ping made when the backend is configured.
+const redisConnectTimeout = 5 * time.Second
+
+var (
+	// acquireScript sets the lease when free and renews it when already ours.
+	acquireScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current == false then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+if current == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+return 0`)
+	// releaseScript deletes the lease only if it is still ours.
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// redisStore shares keys through Redis and broadcasts messages on a single
+// prefixed pub/sub channel.
+type redisStore struct {
+	client *redis.Client
+	prefix string
+	pubsub *redis.PubSub
+}
+
+func newRedisStore(redisURL, prefix string) (*redisStore, error) {
+	if redisURL == "" {
+		return nil, fmt.Errorf("shared state: redis backend requires redis-url")
+	}
+	opts, errParse := redis.ParseURL(redisURL)
+	if errParse != nil {
+		return nil, fmt.Errorf("shared state: invalid redis-url: %w", errParse)
+	}
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), redisConnectTimeout)
+	defer cancel()
+	if errPing := client.Ping(ctx).Err(); errPing != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("shared state: redis ping: %w", errPing)
+	}
+	return &redisStore{client: client, prefix: prefix}, nil
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, errGet := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(errGet, redis.Nil) {
+		return nil, false, nil
+	}
+	if errGet != nil {
+		return nil, false, errGet
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
+
+func (s *redisStore) Take(ctx context.Context, key string) ([]byte, bool, error) {
+	value, errGet := s.client.GetDel(ctx, s.prefix+key).Bytes()
+	if errors.Is(errGet, redis.Nil) {
+		return nil, false, nil
+	}
+	if errGet != nil {
+		return nil, false, errGet
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+func (s *redisStore) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	acquired, errRun := acquireScript.Run(ctx, s.client, []string{s.prefix + key}, owner, ttl.Milliseconds()).Int()
+	if errRun != nil {
+		return false, errRun
+	}
+	return acquired == 1, nil
+}
+
+func (s *redisStore) Release(ctx context.Context, key, owner string) error {
+	return releaseScript.Run(ctx, s.client, []string{s.prefix + key}, owner).Err()
+}
+
+func (s *redisStore) Publish(ctx context.Context, message []byte) error {
+	return s.client.Publish(ctx, s.prefix+eventsChannel, message).Err()
+}
+
+func (s *redisStore) Subscribe(ctx context.Context, handler func([]byte)) error {
+	pubsub := s.client.Subscribe(ctx, s.prefix+eventsChannel)
+	if _, errReceive := pubsub.Receive(ctx); errReceive != nil {
+		_ = pubsub.Close()
+		return fmt.Errorf("shared state: redis subscribe: %w", errReceive)
+	}
+	s.pubsub = pubsub
+	go func() {
+		for msg := range pubsub.Channel() {
+			handler([]byte(msg.Payload))
+		}
+		log.Debug("shared state: redis subscription closed")
+	}()
+	return nil
+}
+
+func (s *redisStore) Distributed() bool { return true }
+
+func (s *redisStore) Close() error {
+	if s.pubsub != nil {
+		_ = s.pubsub.Close()
+	}
+	return s.client.Close()
+}
@@ -0,0 +1,180 @@
+package sharedstate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// envelope wraps a published payload so replicas can route it by topic and
+// ignore their own messages.
+type envelope struct {
+	Origin  string          `json:"origin"`
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type subscription struct {
+	topic   string
+	handler func(payload json.RawMessage)
+}
+
+var (
+	mu         sync.RWMutex
+	current    Store = newMemoryStore()
+	currentCfg config.SharedStateConfig
+
+	subscriptionsMu sync.RWMutex
+	subscriptions   = make(map[int]subscription)
+	nextID          int
+
+	// instanceID identifies this process in published envelopes.
+	instanceID = uuid.NewString()
+)
+
+// Current returns the active store. It is never nil.
+func Current() Store {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Distributed reports whether the active store is shared with other replicas.
+func Distributed() bool {
+	return Current().Distributed()
+}
+
+// Apply switches the active store to match cfg. Reapplying an unchanged
+// config is a no-op; on error the previous store stays active.
+func Apply(cfg config.SharedStateConfig) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if cfg == currentCfg {
+		return nil
+	}
+	prefix := cfg.KeyPrefix
+	if prefix == "" {
+		prefix = DefaultKeyPrefix
+	}
+
+	var next Store
+	switch cfg.Backend {
+	case "", BackendMemory:
+		next = newMemoryStore()
+	case BackendRedis:
+		store, errStore := newRedisStore(cfg.RedisURL, prefix)
+		if errStore != nil {
+			return errStore
+		}
+		if errSubscribe := store.Subscribe(context.Background(), dispatch); errSubscribe != nil {
+			_ = store.Close()
+			return errSubscribe
+		}
+		next = store
+	default:
+		return fmt.Errorf("shared state: unknown backend %q", cfg.Backend)
+	}
+
+	restartElectionLocked(next, false)
+	if errClose := current.Close(); errClose != nil {
+		log.Warnf("shared state: failed to close previous store: %v", errClose)
+	}
+	current, currentCfg = next, cfg
+	if next.Distributed() {
+		log.Infof("shared state: using %s backend (prefix %q)", cfg.Backend, prefix)
+	}
+	restartElectionLocked(next, cfg.LeaderElection && next.Distributed())
+	return nil
+}
+
+// Close closes the active store and falls back to the memory backend.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	restartElectionLocked(current, false)
+	errClose := current.Close()
+	current, currentCfg = newMemoryStore(), config.SharedStateConfig{}
+	return errClose
+}
+
+// Publish sends payload, JSON-encoded, to the other replicas subscribed to
+// topic. It does nothing when the store is not distributed.
+func Publish(ctx context.Context, topic string, payload any) error {
+	store := Current()
+	if !store.Distributed() {
+		return nil
+	}
+	raw, errMarshal := json.Marshal(payload)
+	if errMarshal != nil {
+		return fmt.Errorf("shared state: encode %s: %w", topic, errMarshal)
+	}
+	message, errMarshal := json.Marshal(envelope{Origin: instanceID, Topic: topic, Payload: raw})
+	if errMarshal != nil {
+		return fmt.Errorf("shared state: encode %s: %w", topic, errMarshal)
+	}
+	return store.Publish(ctx, message)
+}
+
+// Subscribe registers handler for payloads published to topic by other
+// replicas. The returned function removes the subscription.
+func Subscribe(topic string, handler func(payload json.RawMessage)) func() {
+	subscriptionsMu.Lock()
+	id := nextID
+	nextID++
+	subscriptions[id] = subscription{topic: topic, handler: handler}
+	subscriptionsMu.Unlock()
+	return func() {
+		subscriptionsMu.Lock()
+		delete(subscriptions, id)
+		subscriptionsMu.Unlock()
+	}
+}
+
+func dispatch(message []byte) {
+	var env envelope
+	if errDecode := json.Unmarshal(message, &env); errDecode != nil {
+		log.Debugf("shared state: dropping malformed event: %v", errDecode)
+		return
+	}
+	if env.Origin == instanceID {
+		return
+	}
+	subscriptionsMu.RLock()
+	var handlers []func(json.RawMessage)
+	for _, sub := range subscriptions {
+		if sub.topic == env.Topic {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	subscriptionsMu.RUnlock()
+	for _, handler := range handlers {
+		handler(env.Payload)
+	}
+}
+
+// GetJSON decodes the value stored under key into out, reporting whether it existed.
+func GetJSON(ctx context.Context, key string, out any) (bool, error) {
+	data, ok, errGet := Current().Get(ctx, key)
+	if errGet != nil || !ok {
+		return false, errGet
+	}
+	if errDecode := json.Unmarshal(data, out); errDecode != nil {
+		return false, fmt.Errorf("shared state: decode %s: %w", key, errDecode)
+	}
+	return true, nil
+}
+
+// SetJSON stores value, JSON-encoded, under key.
+func SetJSON(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, errMarshal := json.Marshal(value)
+	if errMarshal != nil {
+		return fmt.Errorf("shared state: encode %s: %w", key, errMarshal)
+	}
+	return Current().Set(ctx, key, data, ttl)
+}
@@ -0,0 +1,168 @@
+package sharedstate
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestMemoryStore_ExpiresAndTakes(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	if err := store.Set(ctx, "short", []byte("v"), time.Millisecond); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set(ctx, "kept", []byte("v"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := store.Get(ctx, "short"); ok {
+		t.Fatal("expired key should be gone")
+	}
+	if value, ok, _ := store.Take(ctx, "kept"); !ok || string(value) != "v" {
+		t.Fatalf("Take = %q, %v", value, ok)
+	}
+	if _, ok, _ := store.Get(ctx, "kept"); ok {
+		t.Fatal("taken key should be deleted")
+	}
+}
+
+func TestRedisStore_SharesKeysUnderPrefix(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+	store, err := newRedisStore("redis://"+server.Addr(), "test:")
+	if err != nil {
+		t.Fatalf("newRedisStore: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	if err = store.Set(ctx, "oauth-session:abc", []byte("pending"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, _ := server.Get("test:oauth-session:abc"); got != "pending" {
+		t.Fatalf("raw key = %q", got)
+	}
+	if ttl := server.TTL("test:oauth-session:abc"); ttl != time.Minute {
+		t.Fatalf("ttl = %v", ttl)
+	}
+	value, ok, err := store.Take(ctx, "oauth-session:abc")
+	if err != nil || !ok || string(value) != "pending" {
+		t.Fatalf("Take = %q, %v, %v", value, ok, err)
+	}
+	if _, ok, _ = store.Get(ctx, "oauth-session:abc"); ok {
+		t.Fatal("taken key should be deleted")
+	}
+}
+
+func TestApply_RoutesEventsFromOtherReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	if err := Apply(config.SharedStateConfig{Backend: BackendRedis, RedisURL: "redis://" + server.Addr()}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	defer func() { _ = Close() }()
+	if !Distributed() {
+		t.Fatal("redis backend should be distributed")
+	}
+
+	received := make(chan string, 2)
+	unsubscribe := Subscribe("auth-result", func(payload json.RawMessage) {
+		var value string
+		_ = json.Unmarshal(payload, &value)
+		received <- value
+	})
+	defer unsubscribe()
+
+	// Our own publications are not echoed back.
+	if err := Publish(context.Background(), "auth-result", "local"); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	remote, _ := json.Marshal(envelope{Origin: "other-replica", Topic: "auth-result", Payload: json.RawMessage(`"remote"`)})
+	server.Publish(DefaultKeyPrefix+eventsChannel, string(remote))
+
+	select {
+	case got := <-received:
+		if got != "remote" {
+			t.Fatalf("received %q, want only the remote event", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("remote event was not delivered")
+	}
+}
+
+func TestApply_KeepsPreviousStoreOnError(t *testing.T) {
+	defer func() { _ = Close() }()
+	if err := Apply(config.SharedStateConfig{Backend: BackendRedis, RedisURL: "redis://127.0.0.1:1"}); err == nil {
+		t.Fatal("expected connection error")
+	}
+	if Distributed() {
+		t.Fatal("failed apply should keep the memory store")
+	}
+}
+
+func TestAcquire_HonoursExistingLease(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisStore, err := newRedisStore("redis://"+server.Addr(), "test:")
+	if err != nil {
+		t.Fatalf("newRedisStore: %v", err)
+	}
+	defer func() { _ = redisStore.Close() }()
+	ctx := context.Background()
+
+	for name, store := range map[string]Store{"memory": newMemoryStore(), "redis": redisStore} {
+		if ok, errAcquire := store.Acquire(ctx, "lease", "a", time.Minute); errAcquire != nil || !ok {
+			t.Fatalf("%s: first acquire = %v, %v", name, ok, errAcquire)
+		}
+		if ok, _ := store.Acquire(ctx, "lease", "b", time.Minute); ok {
+			t.Fatalf("%s: lease held by a must not go to b", name)
+		}
+		if ok, _ := store.Acquire(ctx, "lease", "a", time.Minute); !ok {
+			t.Fatalf("%s: owner should be able to renew", name)
+		}
+		_ = store.Release(ctx, "lease", "b")
+		if ok, _ := store.Acquire(ctx, "lease", "b", time.Minute); ok {
+			t.Fatalf("%s: release by a non-owner must not free the lease", name)
+		}
+		_ = store.Release(ctx, "lease", "a")
+		if ok, _ := store.Acquire(ctx, "lease", "b", time.Minute); !ok {
+			t.Fatalf("%s: released lease should be free", name)
+		}
+	}
+}
+
+func TestLeaderElection_FollowsLease(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := config.SharedStateConfig{Backend: BackendRedis, RedisURL: "redis://" + server.Addr(), LeaderElection: true}
+
+	if err := server.Set(DefaultKeyPrefix+leaderKey, "other-replica"); err != nil {
+		t.Fatalf("seed lease: %v", err)
+	}
+	if err := Apply(cfg); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if IsLeader() {
+		t.Fatal("replica should follow while another holds the lease")
+	}
+	_ = Close()
+	if !IsLeader() {
+		t.Fatal("without election every replica leads")
+	}
+
+	server.Del(DefaultKeyPrefix + leaderKey)
+	if err := Apply(cfg); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if !IsLeader() {
+		t.Fatal("replica should lead when the lease is free")
+	}
+	if holder, _ := server.Get(DefaultKeyPrefix + leaderKey); holder != instanceID {
+		t.Fatalf("lease holder = %q", holder)
+	}
+	_ = Close()
+	if server.Exists(DefaultKeyPrefix + leaderKey) {
+		t.Fatal("closing should release the lease")
+	}
+}
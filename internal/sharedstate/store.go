@@ -0,0 +1,50 @@
+// Package sharedstate provides the coordination store that lets several proxy
+// replicas behind a load balancer share rate limits, cooldowns, credential
+// health, sticky sessions and pending OAuth logins.
+//
+// The default memory backend keeps everything in process; the redis backend
+// shares keys between replicas and fans events out over a pub/sub channel.
+package sharedstate
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// BackendMemory keeps state inside the current process.
+	BackendMemory = "memory"
+	// BackendRedis shares state through a Redis server.
+	BackendRedis = "redis"
+
+	// DefaultKeyPrefix namespaces keys and the events channel when no prefix is configured.
+	DefaultKeyPrefix = "cliproxy:"
+
+	eventsChannel = "events"
+)
+
+// Store is a small key/value and messaging surface shared between replicas.
+// Keys are relative; the store applies its configured prefix.
+type Store interface {
+	// Get returns the value stored under key and whether it exists.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key. A ttl <= 0 keeps the value until deleted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Take atomically returns and deletes the value stored under key.
+	Take(ctx context.Context, key string) ([]byte, bool, error)
+	// Delete removes key.
+	Delete(ctx context.Context, key string) error
+	// Acquire takes or renews a lease on key for owner. It returns false when
+	// another owner holds the lease.
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Release drops the lease on key if owner still holds it.
+	Release(ctx context.Context, key, owner string) error
+	// Publish broadcasts message to every subscribed replica, including this one.
+	Publish(ctx context.Context, message []byte) error
+	// Subscribe delivers published messages to handler until the store is closed.
+	Subscribe(ctx context.Context, handler func(message []byte)) error
+	// Distributed reports whether state is visible to other replicas.
+	Distributed() bool
+	// Close releases the store's connections.
+	Close() error
+}
@@ -0,0 +1,180 @@
+// Package speech maps OpenAI /v1/audio/speech requests onto text-to-speech
+// upstreams and converts their audio into the response format clients asked
+// for.
+package speech
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// DefaultFormat is the response_format OpenAI uses when a request omits it.
+const DefaultFormat = "mp3"
+
+// geminiSampleRate is the PCM sample rate Gemini TTS models return when the
+// inline data MIME type does not state one.
+const geminiSampleRate = 24000
+
+// geminiDefaultVoice is used when a request names no voice.
+const geminiDefaultVoice = "Kore"
+
+// geminiVoices maps OpenAI voice names to the closest Gemini prebuilt voices.
+var geminiVoices = map[string]string{
+	"alloy":   "Kore",
+	"ash":     "Orus",
+	"ballad":  "Algieba",
+	"coral":   "Aoede",
+	"echo":    "Puck",
+	"fable":   "Leda",
+	"onyx":    "Charon",
+	"nova":    "Zephyr",
+	"sage":    "Sulafat",
+	"shimmer": "Despina",
+	"verse":   "Fenrir",
+}
+
+var contentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/opus",
+	"aac":  "audio/aac",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"pcm":  "audio/pcm",
+}
+
+// Format returns the lower-cased response_format of a speech request.
+func Format(payload []byte) string {
+	format := strings.ToLower(strings.TrimSpace(gjson.GetBytes(payload, "response_format").String()))
+	if format == "" {
+		return DefaultFormat
+	}
+	return format
+}
+
+// ContentType returns the Content-Type of audio in format, or "" when the
+// format is not an OpenAI speech format.
+func ContentType(format string) string {
+	return contentTypes[strings.ToLower(strings.TrimSpace(format))]
+}
+
+// LookupVoice returns the voice configured for voice under the first of
+// providerKeys present in table. Provider keys and voice names match case
+// insensitively.
+func LookupVoice(table map[string]map[string]string, providerKeys []string, voice string) (string, bool) {
+	voice = strings.TrimSpace(voice)
+	for _, key := range providerKeys {
+		for provider, voices := range table {
+			if !strings.EqualFold(strings.TrimSpace(provider), key) {
+				continue
+			}
+			for from, to := range voices {
+				if strings.EqualFold(strings.TrimSpace(from), voice) && strings.TrimSpace(to) != "" {
+					return strings.TrimSpace(to), true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// GeminiVoice returns the Gemini prebuilt voice for an OpenAI voice name.
+// Other names are assumed to be Gemini voices already and returned as sent.
+func GeminiVoice(voice string) string {
+	voice = strings.TrimSpace(voice)
+	if voice == "" {
+		return geminiDefaultVoice
+	}
+	if mapped, ok := geminiVoices[strings.ToLower(voice)]; ok {
+		return mapped
+	}
+	return voice
+}
+
+// GeminiFormatSupported reports whether Gemini PCM output can be served in
+// format without transcoding.
+func GeminiFormatSupported(format string) bool {
+	return format == "wav" || format == "pcm"
+}
+
+// GeminiRequest builds a Gemini generateContent request that speaks the input
+// of an OpenAI speech request with voice. Instructions become a style prefix,
+// which is how Gemini TTS models are steered.
+func GeminiRequest(payload []byte, voice string) []byte {
+	root := gjson.ParseBytes(payload)
+	text := root.Get("input").String()
+	if instructions := strings.TrimSpace(root.Get("instructions").String()); instructions != "" {
+		text = instructions + ": " + text
+	}
+	out := []byte(`{"contents":[{"role":"user","parts":[{"text":""}]}],"generationConfig":{"responseModalities":["AUDIO"],"speechConfig":{"voiceConfig":{"prebuiltVoiceConfig":{"voiceName":""}}}}}`)
+	out, _ = sjson.SetBytes(out, "contents.0.parts.0.text", text)
+	out, _ = sjson.SetBytes(out, "generationConfig.speechConfig.voiceConfig.prebuiltVoiceConfig.voiceName", voice)
+	return out
+}
+
+// FromGemini extracts the audio of a Gemini generateContent response and
+// returns it in format, which must satisfy GeminiFormatSupported.
+func FromGemini(response []byte, format string) ([]byte, error) {
+	var inline gjson.Result
+	gjson.GetBytes(response, "candidates.0.content.parts").ForEach(func(_, part gjson.Result) bool {
+		if data := part.Get("inlineData"); data.Exists() {
+			inline = data
+			return false
+		}
+		return true
+	})
+	if !inline.Exists() {
+		return nil, fmt.Errorf("speech: gemini response carries no audio")
+	}
+	pcm, err := base64.StdEncoding.DecodeString(inline.Get("data").String())
+	if err != nil {
+		return nil, fmt.Errorf("speech: decode gemini audio: %w", err)
+	}
+	switch format {
+	case "pcm":
+		return pcm, nil
+	case "wav":
+		return WAV(pcm, pcmSampleRate(inline.Get("mimeType").String()), 1, 16), nil
+	default:
+		return nil, fmt.Errorf("speech: response_format %q is not supported for gemini", format)
+	}
+}
+
+// WAV wraps little-endian PCM samples in a RIFF/WAVE container.
+func WAV(pcm []byte, sampleRate, channels, bitsPerSample int) []byte {
+	blockAlign := channels * bitsPerSample / 8
+	out := make([]byte, 44, 44+len(pcm))
+	copy(out[0:], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(36+len(pcm)))
+	copy(out[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(out[16:], 16)
+	binary.LittleEndian.PutUint16(out[20:], 1)
+	binary.LittleEndian.PutUint16(out[22:], uint16(channels))
+	binary.LittleEndian.PutUint32(out[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(out[28:], uint32(sampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(out[32:], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(out[34:], uint16(bitsPerSample))
+	copy(out[36:], "data")
+	binary.LittleEndian.PutUint32(out[40:], uint32(len(pcm)))
+	return append(out, pcm...)
+}
+
+// pcmSampleRate reads the rate parameter of a MIME type such as
+// "audio/L16;codec=pcm;rate=24000".
+func pcmSampleRate(mimeType string) int {
+	for _, param := range strings.Split(mimeType, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(key, "rate") {
+			continue
+		}
+		if rate, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && rate > 0 {
+			return rate
+		}
+	}
+	return geminiSampleRate
+}
@@ -0,0 +1,68 @@
+package speech
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestVoices(t *testing.T) {
+	table := map[string]map[string]string{"Gemini": {"ALLOY": "Achird"}}
+	if voice, ok := LookupVoice(table, []string{"openai-compatibility", "gemini"}, "alloy"); !ok || voice != "Achird" {
+		t.Fatalf("configured voice = %q/%t", voice, ok)
+	}
+	if _, ok := LookupVoice(table, []string{"gemini"}, "nova"); ok {
+		t.Fatal("unexpected configured voice for nova")
+	}
+	for voice, want := range map[string]string{"": "Kore", "Onyx": "Charon", "Puck": "Puck"} {
+		if got := GeminiVoice(voice); got != want {
+			t.Errorf("GeminiVoice(%q) = %q, want %q", voice, got, want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := Format([]byte(`{}`)); got != "mp3" {
+		t.Fatalf("default format = %q", got)
+	}
+	if got := Format([]byte(`{"response_format":"WAV"}`)); got != "wav" || ContentType(got) != "audio/wav" {
+		t.Fatalf("wav format = %q", got)
+	}
+	if ContentType("ogg") != "" {
+		t.Fatal("expected no content type for ogg")
+	}
+}
+
+func TestGeminiRequest(t *testing.T) {
+	out := gjson.ParseBytes(GeminiRequest([]byte(`{"input":"Have a nice day","instructions":"Say cheerfully"}`), "Kore"))
+	if got := out.Get("contents.0.parts.0.text").String(); got != "Say cheerfully: Have a nice day" {
+		t.Fatalf("text = %q", got)
+	}
+	if out.Get("generationConfig.responseModalities.0").String() != "AUDIO" || out.Get("generationConfig.speechConfig.voiceConfig.prebuiltVoiceConfig.voiceName").String() != "Kore" {
+		t.Fatalf("generationConfig = %s", out.Get("generationConfig").Raw)
+	}
+}
+
+func TestFromGemini(t *testing.T) {
+	// "AQACAA==" is the PCM sample pair {1, 0, 2, 0}.
+	response := []byte(`{"candidates":[{"content":{"parts":[{"text":"ignored"},{"inlineData":{"mimeType":"audio/L16;codec=pcm;rate=16000","data":"AQACAA=="}}]}}]}`)
+
+	pcm, err := FromGemini(response, "pcm")
+	if err != nil || string(pcm) != "\x01\x00\x02\x00" {
+		t.Fatalf("pcm = %v, %v", pcm, err)
+	}
+	wav, err := FromGemini(response, "wav")
+	if err != nil {
+		t.Fatalf("wav error: %v", err)
+	}
+	if string(wav[:4]) != "RIFF" || string(wav[8:12]) != "WAVE" || binary.LittleEndian.Uint32(wav[24:]) != 16000 || binary.LittleEndian.Uint32(wav[40:]) != 4 {
+		t.Fatalf("wav header = %v", wav[:44])
+	}
+	if _, err = FromGemini(response, "mp3"); err == nil {
+		t.Fatal("expected mp3 to be rejected")
+	}
+	if _, err = FromGemini([]byte(`{"candidates":[]}`), "wav"); err == nil {
+		t.Fatal("expected missing audio to fail")
+	}
+}
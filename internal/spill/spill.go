@@ -0,0 +1,157 @@
+// Package spill provides a byte buffer that moves its contents to a temporary
+// file once they grow past a threshold, bounding the memory held by large
+// responses that must be kept while other requests run.
+package spill
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Buffer accumulates bytes in memory up to its threshold and in a temporary
+// file beyond it. Appends and reads may run concurrently. A Buffer whose
+// file cannot be created keeps growing in memory.
+type Buffer struct {
+	mu        sync.Mutex
+	dir       string
+	threshold int
+	mem       []byte
+	file      *os.File
+	size      int64
+	closed    bool
+}
+
+// New returns a Buffer that spills to a file in dir, or the system temporary
+// directory when dir is empty, once it holds more than threshold bytes. A
+// threshold <= 0 keeps everything in memory.
+func New(dir string, threshold int) *Buffer {
+	return &Buffer{dir: dir, threshold: threshold}
+}
+
+// Write appends p.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, os.ErrClosed
+	}
+	if b.file == nil && b.threshold > 0 && len(b.mem)+len(p) > b.threshold {
+		b.spillLocked()
+	}
+	if b.file == nil {
+		b.mem = append(b.mem, p...)
+		b.size += int64(len(p))
+		return len(p), nil
+	}
+	n, err := b.file.WriteAt(p, b.size)
+	b.size += int64(n)
+	return n, err
+}
+
+// spillLocked moves the buffered bytes to a new temporary file.
+func (b *Buffer) spillLocked() {
+	file, err := os.CreateTemp(b.dir, ".spill-*.tmp")
+	if err != nil {
+		log.Warnf("spill: create temporary file: %v", err)
+		b.threshold = 0
+		return
+	}
+	if _, err = file.WriteAt(b.mem, 0); err != nil {
+		log.Warnf("spill: write %s: %v", file.Name(), err)
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		b.threshold = 0
+		return
+	}
+	b.file = file
+	b.mem = nil
+}
+
+// Len returns the number of bytes written.
+func (b *Buffer) Len() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// Spilled reports whether the contents moved to a file.
+func (b *Buffer) Spilled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.file != nil
+}
+
+// ReadAt reads the bytes written at offset off, implementing io.ReaderAt
+// over the bytes written so far.
+func (b *Buffer) ReadAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return 0, os.ErrClosed
+	}
+	size, file := b.size, b.file
+	if file == nil {
+		defer b.mu.Unlock()
+		if off >= size {
+			return 0, io.EOF
+		}
+		n := copy(p, b.mem[off:])
+		if n < len(p) {
+			return n, io.EOF
+		}
+		return n, nil
+	}
+	b.mu.Unlock()
+	if off >= size {
+		return 0, io.EOF
+	}
+	// Bytes appended after size was read are not visible yet.
+	if remaining := size - off; int64(len(p)) > remaining {
+		n, err := file.ReadAt(p[:remaining], off)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return file.ReadAt(p, off)
+}
+
+// WriteTo copies the bytes written so far to w without loading a spilled
+// buffer into memory.
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, io.NewSectionReader(b, 0, b.Len()))
+}
+
+// Bytes returns a copy of the bytes written so far.
+func (b *Buffer) Bytes() ([]byte, error) {
+	out := make([]byte, b.Len())
+	n, err := b.ReadAt(out, 0)
+	if err == io.EOF {
+		err = nil
+	}
+	return out[:n], err
+}
+
+// Close releases the buffer and deletes its file.
+func (b *Buffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.mem = nil
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	err := b.file.Close()
+	if errRemove := os.Remove(name); err == nil {
+		err = errRemove
+	}
+	b.file = nil
+	return err
+}
@@ -0,0 +1,72 @@
+package spill
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func tempFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	return len(entries)
+}
+
+func TestBufferSpillsPastThreshold(t *testing.T) {
+	dir := t.TempDir()
+	buf := New(dir, 8)
+	_, _ = buf.Write([]byte("hello"))
+	if buf.Spilled() || tempFiles(t, dir) != 0 {
+		t.Fatal("buffer below the threshold must stay in memory")
+	}
+	_, _ = buf.Write([]byte(" world"))
+	_, _ = io.WriteString(buf, "!")
+	if !buf.Spilled() || tempFiles(t, dir) != 1 || buf.Len() != 12 {
+		t.Fatalf("spilled = %t, files = %d, len = %d", buf.Spilled(), tempFiles(t, dir), buf.Len())
+	}
+
+	data, err := buf.Bytes()
+	if err != nil || string(data) != "hello world!" {
+		t.Fatalf("Bytes = %q, %v", data, err)
+	}
+	part := make([]byte, 10)
+	if n, errRead := buf.ReadAt(part, 6); n != 6 || errRead != io.EOF || string(part[:n]) != "world!" {
+		t.Fatalf("ReadAt = %d %q, %v", n, part[:n], errRead)
+	}
+	var out bytes.Buffer
+	if n, errCopy := buf.WriteTo(&out); n != 12 || errCopy != nil || out.String() != "hello world!" {
+		t.Fatalf("WriteTo = %d %q, %v", n, out.String(), errCopy)
+	}
+
+	if err = buf.Close(); err != nil || tempFiles(t, dir) != 0 {
+		t.Fatalf("Close = %v, files left = %d", err, tempFiles(t, dir))
+	}
+	if _, err = buf.Write([]byte("x")); err == nil {
+		t.Fatal("write after close must fail")
+	}
+}
+
+func TestBufferWithoutThresholdStaysInMemory(t *testing.T) {
+	buf := New(t.TempDir(), 0)
+	_, _ = buf.Write([]byte(strings.Repeat("x", 1<<16)))
+	if buf.Spilled() || buf.Len() != 1<<16 {
+		t.Fatalf("spilled = %t, len = %d", buf.Spilled(), buf.Len())
+	}
+	_ = buf.Close()
+}
+
+func TestBufferFallsBackToMemory(t *testing.T) {
+	buf := New(t.TempDir()+"/missing", 4)
+	_, _ = buf.Write([]byte("more than four"))
+	if buf.Spilled() {
+		t.Fatal("a buffer that cannot create its file must stay in memory")
+	}
+	if data, _ := buf.Bytes(); string(data) != "more than four" {
+		t.Fatalf("Bytes = %q", data)
+	}
+}
@@ -0,0 +1,546 @@
+// Package streamadapt converts complete responses into stream chunks and
+// stream chunks back into complete responses, in the client-facing schemas.
+// It lets a request be served in the style the client asked for when the
+// upstream model only supports the other one.
+package streamadapt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ErrUnsupportedFormat is returned for schemas without an adapter.
+var ErrUnsupportedFormat = errors.New("streamadapt: unsupported format")
+
+// Supported reports whether format can be adapted in both directions.
+func Supported(format sdktranslator.Format) bool {
+	switch format {
+	case sdktranslator.FormatOpenAI, sdktranslator.FormatOpenAIResponse, sdktranslator.FormatClaude,
+		sdktranslator.FormatGemini, sdktranslator.FormatGeminiCLI:
+		return true
+	default:
+		return false
+	}
+}
+
+// Synthesize splits a complete response into the stream chunks a streaming
+// upstream would have produced, framed the way executors emit them.
+func Synthesize(format sdktranslator.Format, payload []byte) ([][]byte, error) {
+	if !gjson.ValidBytes(payload) {
+		return nil, fmt.Errorf("streamadapt: response is not valid JSON")
+	}
+	switch format {
+	case sdktranslator.FormatOpenAI:
+		return synthesizeOpenAI(payload), nil
+	case sdktranslator.FormatClaude:
+		return synthesizeClaude(payload), nil
+	case sdktranslator.FormatOpenAIResponse:
+		return synthesizeResponses(payload), nil
+	case sdktranslator.FormatGemini, sdktranslator.FormatGeminiCLI:
+		// A generateContent response is already a valid stream chunk.
+		return [][]byte{bytes.Clone(payload)}, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// Aggregate merges stream chunks into the complete response a non-streaming
+// upstream would have returned.
+func Aggregate(format sdktranslator.Format, chunks [][]byte) ([]byte, error) {
+	events := parseEvents(chunks)
+	switch format {
+	case sdktranslator.FormatOpenAI:
+		return aggregateOpenAI(events)
+	case sdktranslator.FormatClaude:
+		return aggregateClaude(events)
+	case sdktranslator.FormatOpenAIResponse:
+		return aggregateResponses(events)
+	case sdktranslator.FormatGemini:
+		return aggregateGemini(events, false)
+	case sdktranslator.FormatGeminiCLI:
+		return aggregateGemini(events, true)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+type sseEvent struct {
+	name string
+	data []byte
+}
+
+// parseEvents accepts chunks as bare JSON or as SSE text with event/data
+// lines, in any grouping.
+func parseEvents(chunks [][]byte) []sseEvent {
+	var events []sseEvent
+	for _, chunk := range chunks {
+		trimmed := bytes.TrimSpace(chunk)
+		if len(trimmed) == 0 {
+			continue
+		}
+		if trimmed[0] == '{' && gjson.ValidBytes(trimmed) {
+			events = append(events, sseEvent{data: trimmed})
+			continue
+		}
+		var name string
+		for _, line := range bytes.Split(trimmed, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			switch {
+			case bytes.HasPrefix(line, []byte("event:")):
+				name = string(bytes.TrimSpace(line[len("event:"):]))
+			case bytes.HasPrefix(line, []byte("data:")):
+				data := bytes.TrimSpace(line[len("data:"):])
+				if len(data) == 0 || bytes.Equal(data, []byte("[DONE]")) {
+					continue
+				}
+				events = append(events, sseEvent{name: name, data: data})
+				name = ""
+			}
+		}
+	}
+	return events
+}
+
+func sseFrame(event string, data string) []byte {
+	return []byte("event: " + event + "\ndata: " + data + "\n\n")
+}
+
+func synthesizeOpenAI(payload []byte) [][]byte {
+	chunk := `{"object":"chat.completion.chunk","choices":[]}`
+	for _, key := range []string{"id", "created", "model", "system_fingerprint", "service_tier"} {
+		if value := gjson.GetBytes(payload, key); value.Exists() {
+			chunk, _ = sjson.SetRaw(chunk, key, value.Raw)
+		}
+	}
+	gjson.GetBytes(payload, "choices").ForEach(func(_, choice gjson.Result) bool {
+		out := `{"delta":{"role":"assistant"}}`
+		out, _ = sjson.Set(out, "index", choice.Get("index").Int())
+		message := choice.Get("message")
+		for _, key := range []string{"content", "reasoning_content", "refusal"} {
+			if value := message.Get(key); value.Exists() && value.Type != gjson.Null {
+				out, _ = sjson.SetRaw(out, "delta."+key, value.Raw)
+			}
+		}
+		message.Get("tool_calls").ForEach(func(idx, call gjson.Result) bool {
+			raw, _ := sjson.Set(call.Raw, "index", idx.Int())
+			out, _ = sjson.SetRaw(out, "delta.tool_calls.-1", raw)
+			return true
+		})
+		if finish := choice.Get("finish_reason"); finish.Exists() {
+			out, _ = sjson.SetRaw(out, "finish_reason", finish.Raw)
+		}
+		chunk, _ = sjson.SetRaw(chunk, "choices.-1", out)
+		return true
+	})
+	if usage := gjson.GetBytes(payload, "usage"); usage.Exists() {
+		chunk, _ = sjson.SetRaw(chunk, "usage", usage.Raw)
+	}
+	return [][]byte{[]byte(chunk)}
+}
+
+func aggregateOpenAI(events []sseEvent) ([]byte, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("streamadapt: empty stream")
+	}
+	type toolCall struct {
+		raw       string
+		arguments strings.Builder
+	}
+	type choiceState struct {
+		content, reasoning, refusal strings.Builder
+		hasContent                  bool
+		finish                      string
+		calls                       map[int64]*toolCall
+		callOrder                   []int64
+	}
+	out := `{"object":"chat.completion","choices":[]}`
+	choices := make(map[int64]*choiceState)
+	var order []int64
+	for _, event := range events {
+		if errMsg := gjson.GetBytes(event.data, "error"); errMsg.Exists() {
+			return nil, fmt.Errorf("streamadapt: upstream error: %s", errMsg.Raw)
+		}
+		for _, key := range []string{"id", "created", "model", "system_fingerprint", "service_tier"} {
+			if value := gjson.GetBytes(event.data, key); value.Exists() && !gjson.Get(out, key).Exists() {
+				out, _ = sjson.SetRaw(out, key, value.Raw)
+			}
+		}
+		if usage := gjson.GetBytes(event.data, "usage"); usage.Exists() && usage.Type != gjson.Null {
+			out, _ = sjson.SetRaw(out, "usage", usage.Raw)
+		}
+		gjson.GetBytes(event.data, "choices").ForEach(func(_, choice gjson.Result) bool {
+			index := choice.Get("index").Int()
+			state, ok := choices[index]
+			if !ok {
+				state = &choiceState{calls: make(map[int64]*toolCall)}
+				choices[index] = state
+				order = append(order, index)
+			}
+			delta := choice.Get("delta")
+			if content := delta.Get("content"); content.Type == gjson.String {
+				state.content.WriteString(content.String())
+				state.hasContent = true
+			}
+			if reasoning := delta.Get("reasoning_content"); reasoning.Type == gjson.String {
+				state.reasoning.WriteString(reasoning.String())
+			}
+			if refusal := delta.Get("refusal"); refusal.Type == gjson.String {
+				state.refusal.WriteString(refusal.String())
+			}
+			delta.Get("tool_calls").ForEach(func(_, call gjson.Result) bool {
+				callIndex := call.Get("index").Int()
+				tc, exists := state.calls[callIndex]
+				if !exists {
+					tc = &toolCall{raw: `{"type":"function","function":{}}`}
+					state.calls[callIndex] = tc
+					state.callOrder = append(state.callOrder, callIndex)
+				}
+				if id := call.Get("id"); id.String() != "" {
+					tc.raw, _ = sjson.Set(tc.raw, "id", id.String())
+				}
+				if name := call.Get("function.name"); name.String() != "" {
+					tc.raw, _ = sjson.Set(tc.raw, "function.name", name.String())
+				}
+				tc.arguments.WriteString(call.Get("function.arguments").String())
+				return true
+			})
+			if finish := choice.Get("finish_reason"); finish.String() != "" {
+				state.finish = finish.String()
+			}
+			return true
+		})
+	}
+	for _, index := range order {
+		state := choices[index]
+		choice := `{"message":{"role":"assistant","content":null}}`
+		choice, _ = sjson.Set(choice, "index", index)
+		if state.hasContent {
+			choice, _ = sjson.Set(choice, "message.content", state.content.String())
+		}
+		if state.reasoning.Len() > 0 {
+			choice, _ = sjson.Set(choice, "message.reasoning_content", state.reasoning.String())
+		}
+		if state.refusal.Len() > 0 {
+			choice, _ = sjson.Set(choice, "message.refusal", state.refusal.String())
+		}
+		for _, callIndex := range state.callOrder {
+			tc := state.calls[callIndex]
+			raw, _ := sjson.Set(tc.raw, "function.arguments", tc.arguments.String())
+			choice, _ = sjson.SetRaw(choice, "message.tool_calls.-1", raw)
+		}
+		if state.finish != "" {
+			choice, _ = sjson.Set(choice, "finish_reason", state.finish)
+		} else {
+			choice, _ = sjson.SetRaw(choice, "finish_reason", "null")
+		}
+		out, _ = sjson.SetRaw(out, "choices.-1", choice)
+	}
+	return []byte(out), nil
+}
+
+func synthesizeClaude(payload []byte) [][]byte {
+	var chunks [][]byte
+	start := string(payload)
+	start, _ = sjson.SetRaw(start, "content", "[]")
+	start, _ = sjson.SetRaw(start, "stop_reason", "null")
+	start, _ = sjson.SetRaw(start, "stop_sequence", "null")
+	start, _ = sjson.Set(start, "usage.output_tokens", 0)
+	startEvent, _ := sjson.SetRaw(`{"type":"message_start"}`, "message", start)
+	chunks = append(chunks, sseFrame("message_start", startEvent))
+
+	gjson.GetBytes(payload, "content").ForEach(func(idx, block gjson.Result) bool {
+		index := idx.Int()
+		var head, delta string
+		switch block.Get("type").String() {
+		case "text":
+			head = `{"type":"text","text":""}`
+			delta, _ = sjson.Set(`{"type":"text_delta"}`, "text", block.Get("text").String())
+		case "thinking":
+			head = `{"type":"thinking","thinking":""}`
+			delta, _ = sjson.Set(`{"type":"thinking_delta"}`, "thinking", block.Get("thinking").String())
+		case "tool_use":
+			head, _ = sjson.SetRaw(block.Raw, "input", "{}")
+			input := block.Get("input").Raw
+			if input == "" {
+				input = "{}"
+			}
+			delta, _ = sjson.Set(`{"type":"input_json_delta"}`, "partial_json", input)
+		default:
+			head = block.Raw
+		}
+		event, _ := sjson.Set(`{"type":"content_block_start"}`, "index", index)
+		event, _ = sjson.SetRaw(event, "content_block", head)
+		chunks = append(chunks, sseFrame("content_block_start", event))
+		if delta != "" {
+			event, _ = sjson.Set(`{"type":"content_block_delta"}`, "index", index)
+			event, _ = sjson.SetRaw(event, "delta", delta)
+			chunks = append(chunks, sseFrame("content_block_delta", event))
+		}
+		if signature := block.Get("signature"); block.Get("type").String() == "thinking" && signature.String() != "" {
+			event, _ = sjson.Set(`{"type":"content_block_delta","delta":{"type":"signature_delta"}}`, "index", index)
+			event, _ = sjson.Set(event, "delta.signature", signature.String())
+			chunks = append(chunks, sseFrame("content_block_delta", event))
+		}
+		event, _ = sjson.Set(`{"type":"content_block_stop"}`, "index", index)
+		chunks = append(chunks, sseFrame("content_block_stop", event))
+		return true
+	})
+
+	messageDelta := `{"type":"message_delta","delta":{}}`
+	messageDelta, _ = sjson.SetRaw(messageDelta, "delta.stop_reason", rawOrNull(gjson.GetBytes(payload, "stop_reason")))
+	messageDelta, _ = sjson.SetRaw(messageDelta, "delta.stop_sequence", rawOrNull(gjson.GetBytes(payload, "stop_sequence")))
+	if usage := gjson.GetBytes(payload, "usage"); usage.Exists() {
+		messageDelta, _ = sjson.SetRaw(messageDelta, "usage", usage.Raw)
+	}
+	chunks = append(chunks, sseFrame("message_delta", messageDelta))
+	chunks = append(chunks, sseFrame("message_stop", `{"type":"message_stop"}`))
+	return chunks
+}
+
+func aggregateClaude(events []sseEvent) ([]byte, error) {
+	var message string
+	type blockState struct {
+		raw     string
+		text    strings.Builder
+		partial strings.Builder
+	}
+	blocks := make(map[int64]*blockState)
+	var order []int64
+	for _, event := range events {
+		kind := gjson.GetBytes(event.data, "type").String()
+		switch kind {
+		case "error":
+			return nil, fmt.Errorf("streamadapt: upstream error: %s", gjson.GetBytes(event.data, "error").Raw)
+		case "message_start":
+			message = gjson.GetBytes(event.data, "message").Raw
+		case "content_block_start":
+			index := gjson.GetBytes(event.data, "index").Int()
+			if _, exists := blocks[index]; !exists {
+				order = append(order, index)
+			}
+			blocks[index] = &blockState{raw: gjson.GetBytes(event.data, "content_block").Raw}
+		case "content_block_delta":
+			block := blocks[gjson.GetBytes(event.data, "index").Int()]
+			if block == nil {
+				continue
+			}
+			delta := gjson.GetBytes(event.data, "delta")
+			switch delta.Get("type").String() {
+			case "text_delta":
+				block.text.WriteString(delta.Get("text").String())
+			case "thinking_delta":
+				block.text.WriteString(delta.Get("thinking").String())
+			case "signature_delta":
+				block.raw, _ = sjson.Set(block.raw, "signature", delta.Get("signature").String())
+			case "input_json_delta":
+				block.partial.WriteString(delta.Get("partial_json").String())
+			}
+		case "message_delta":
+			if message == "" {
+				message = `{"type":"message","role":"assistant"}`
+			}
+			gjson.GetBytes(event.data, "delta").ForEach(func(key, value gjson.Result) bool {
+				message, _ = sjson.SetRaw(message, key.String(), value.Raw)
+				return true
+			})
+			gjson.GetBytes(event.data, "usage").ForEach(func(key, value gjson.Result) bool {
+				message, _ = sjson.SetRaw(message, "usage."+key.String(), value.Raw)
+				return true
+			})
+		}
+	}
+	if message == "" {
+		return nil, fmt.Errorf("streamadapt: stream ended without message_start")
+	}
+	message, _ = sjson.SetRaw(message, "content", "[]")
+	for _, index := range order {
+		block := blocks[index]
+		raw := block.raw
+		switch gjson.Get(raw, "type").String() {
+		case "text":
+			raw, _ = sjson.Set(raw, "text", block.text.String())
+		case "thinking":
+			raw, _ = sjson.Set(raw, "thinking", block.text.String())
+		}
+		if block.partial.Len() > 0 {
+			input := block.partial.String()
+			if !gjson.Valid(input) {
+				return nil, fmt.Errorf("streamadapt: invalid tool input for content block %d", index)
+			}
+			raw, _ = sjson.SetRaw(raw, "input", input)
+		}
+		message, _ = sjson.SetRaw(message, "content.-1", raw)
+	}
+	return []byte(message), nil
+}
+
+func synthesizeResponses(payload []byte) [][]byte {
+	var chunks [][]byte
+	var sequence int64
+	emit := func(event, data string) {
+		data, _ = sjson.Set(data, "type", event)
+		data, _ = sjson.Set(data, "sequence_number", sequence)
+		sequence++
+		chunks = append(chunks, []byte("event: "+event+"\ndata: "+data))
+	}
+	pending := string(payload)
+	pending, _ = sjson.Set(pending, "status", "in_progress")
+	pending, _ = sjson.SetRaw(pending, "output", "[]")
+	created, _ := sjson.SetRaw(`{}`, "response", pending)
+	emit("response.created", created)
+	emit("response.in_progress", created)
+
+	gjson.GetBytes(payload, "output").ForEach(func(idx, item gjson.Result) bool {
+		outputIndex := idx.Int()
+		itemID := item.Get("id").String()
+		added := item.Raw
+		if item.Get("type").String() == "message" {
+			added, _ = sjson.Set(added, "status", "in_progress")
+			added, _ = sjson.SetRaw(added, "content", "[]")
+		}
+		event, _ := sjson.Set(`{}`, "output_index", outputIndex)
+		event, _ = sjson.SetRaw(event, "item", added)
+		emit("response.output_item.added", event)
+
+		if item.Get("type").String() == "message" {
+			item.Get("content").ForEach(func(partIdx, part gjson.Result) bool {
+				base, _ := sjson.Set(`{}`, "item_id", itemID)
+				base, _ = sjson.Set(base, "output_index", outputIndex)
+				base, _ = sjson.Set(base, "content_index", partIdx.Int())
+				emptyPart := part.Raw
+				if part.Get("type").String() == "output_text" {
+					emptyPart, _ = sjson.Set(emptyPart, "text", "")
+				}
+				event, _ := sjson.SetRaw(base, "part", emptyPart)
+				emit("response.content_part.added", event)
+				if part.Get("type").String() == "output_text" {
+					event, _ = sjson.Set(base, "delta", part.Get("text").String())
+					emit("response.output_text.delta", event)
+					event, _ = sjson.Set(base, "text", part.Get("text").String())
+					emit("response.output_text.done", event)
+				}
+				event, _ = sjson.SetRaw(base, "part", part.Raw)
+				emit("response.content_part.done", event)
+				return true
+			})
+		}
+
+		event, _ = sjson.Set(`{}`, "output_index", outputIndex)
+		event, _ = sjson.SetRaw(event, "item", item.Raw)
+		emit("response.output_item.done", event)
+		return true
+	})
+
+	final := "response.completed"
+	switch gjson.GetBytes(payload, "status").String() {
+	case "incomplete":
+		final = "response.incomplete"
+	case "failed":
+		final = "response.failed"
+	}
+	completed, _ := sjson.SetRaw(`{}`, "response", string(payload))
+	emit(final, completed)
+	return chunks
+}
+
+func aggregateResponses(events []sseEvent) ([]byte, error) {
+	for i := len(events) - 1; i >= 0; i-- {
+		kind := events[i].name
+		if kind == "" {
+			kind = gjson.GetBytes(events[i].data, "type").String()
+		}
+		switch kind {
+		case "response.completed", "response.incomplete", "response.failed":
+			if response := gjson.GetBytes(events[i].data, "response"); response.IsObject() {
+				return []byte(response.Raw), nil
+			}
+		case "error":
+			return nil, fmt.Errorf("streamadapt: upstream error: %s", events[i].data)
+		}
+	}
+	return nil, fmt.Errorf("streamadapt: stream ended without a final response")
+}
+
+func aggregateGemini(events []sseEvent, wrapped bool) ([]byte, error) {
+	var out string
+	var parts []string
+	var finish, role string
+	for _, event := range events {
+		data := gjson.ParseBytes(event.data)
+		if wrapped && data.Get("response").Exists() {
+			data = data.Get("response")
+		}
+		if errMsg := data.Get("error"); errMsg.Exists() {
+			return nil, fmt.Errorf("streamadapt: upstream error: %s", errMsg.Raw)
+		}
+		out = data.Raw
+		candidate := data.Get("candidates.0")
+		if value := candidate.Get("content.role").String(); value != "" {
+			role = value
+		}
+		if value := candidate.Get("finishReason").String(); value != "" {
+			finish = value
+		}
+		candidate.Get("content.parts").ForEach(func(_, part gjson.Result) bool {
+			parts = appendGeminiPart(parts, part)
+			return true
+		})
+	}
+	if out == "" {
+		return nil, fmt.Errorf("streamadapt: empty stream")
+	}
+	if gjson.Get(out, "candidates.0").Exists() || len(parts) > 0 {
+		out, _ = sjson.SetRaw(out, "candidates.0.content.parts", "["+strings.Join(parts, ",")+"]")
+		if role != "" {
+			out, _ = sjson.Set(out, "candidates.0.content.role", role)
+		}
+		if finish != "" {
+			out, _ = sjson.Set(out, "candidates.0.finishReason", finish)
+		}
+	}
+	if wrapped {
+		out, _ = sjson.SetRaw(`{}`, "response", out)
+	}
+	return []byte(out), nil
+}
+
+// appendGeminiPart concatenates consecutive plain text parts of the same kind
+// (thought or answer) and keeps every other part as is.
+func appendGeminiPart(parts []string, part gjson.Result) []string {
+	if len(parts) > 0 && isPlainGeminiText(part) {
+		last := gjson.Parse(parts[len(parts)-1])
+		if isPlainGeminiText(last) && last.Get("thought").Bool() == part.Get("thought").Bool() {
+			merged, _ := sjson.Set(last.Raw, "text", last.Get("text").String()+part.Get("text").String())
+			parts[len(parts)-1] = merged
+			return parts
+		}
+	}
+	return append(parts, part.Raw)
+}
+
+func isPlainGeminiText(part gjson.Result) bool {
+	if part.Get("text").Type != gjson.String {
+		return false
+	}
+	plain := true
+	part.ForEach(func(key, _ gjson.Result) bool {
+		if name := key.String(); name != "text" && name != "thought" {
+			plain = false
+		}
+		return plain
+	})
+	return plain
+}
+
+func rawOrNull(value gjson.Result) string {
+	if !value.Exists() {
+		return "null"
+	}
+	return value.Raw
+}
@@ -0,0 +1,145 @@
+package streamadapt
+
+import (
+	"strings"
+	"testing"
+
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func TestOpenAIRoundTrip(t *testing.T) {
+	full := `{"id":"c1","object":"chat.completion","created":1,"model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"hello","tool_calls":[{"id":"t1","type":"function","function":{"name":"f","arguments":"{\"a\":1}"}}]},"finish_reason":"tool_calls"}],"usage":{"total_tokens":3}}`
+
+	chunks, err := Synthesize(sdktranslator.FormatOpenAI, []byte(full))
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	if got := gjson.GetBytes(chunks[0], "object").String(); got != "chat.completion.chunk" {
+		t.Fatalf("object = %q", got)
+	}
+	if got := gjson.GetBytes(chunks[0], "choices.0.delta.tool_calls.0.index").Int(); got != 0 {
+		t.Fatalf("tool call index = %d", got)
+	}
+
+	out, err := Aggregate(sdktranslator.FormatOpenAI, chunks)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	for path, want := range map[string]string{
+		"object":                    "chat.completion",
+		"choices.0.message.content": "hello",
+		"choices.0.message.tool_calls.0.function.name":      "f",
+		"choices.0.message.tool_calls.0.function.arguments": `{"a":1}`,
+		"choices.0.finish_reason":                           "tool_calls",
+		"usage.total_tokens":                                "3",
+	} {
+		if got := gjson.GetBytes(out, path).String(); got != want {
+			t.Fatalf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAggregateOpenAIDeltas(t *testing.T) {
+	chunks := [][]byte{
+		[]byte(`{"id":"c1","model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"he"}}]}`),
+		[]byte(`data: {"id":"c1","choices":[{"index":0,"delta":{"content":"llo"},"finish_reason":"stop"}]}`),
+		[]byte(`data: [DONE]`),
+	}
+	out, err := Aggregate(sdktranslator.FormatOpenAI, chunks)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	if got := gjson.GetBytes(out, "choices.0.message.content").String(); got != "hello" {
+		t.Fatalf("content = %q", got)
+	}
+}
+
+func TestClaudeRoundTrip(t *testing.T) {
+	full := `{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[{"type":"thinking","thinking":"hmm","signature":"sig"},{"type":"text","text":"hi"},{"type":"tool_use","id":"tu","name":"f","input":{"a":1}}],"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":5,"output_tokens":7}}`
+
+	chunks, err := Synthesize(sdktranslator.FormatClaude, []byte(full))
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	if !strings.HasPrefix(string(chunks[0]), "event: message_start\n") {
+		t.Fatalf("first chunk = %q", chunks[0])
+	}
+	if !strings.HasPrefix(string(chunks[len(chunks)-1]), "event: message_stop\n") {
+		t.Fatalf("last chunk = %q", chunks[len(chunks)-1])
+	}
+
+	out, err := Aggregate(sdktranslator.FormatClaude, chunks)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	for path, want := range map[string]string{
+		"content.0.thinking":  "hmm",
+		"content.0.signature": "sig",
+		"content.1.text":      "hi",
+		"content.2.input.a":   "1",
+		"stop_reason":         "tool_use",
+		"usage.output_tokens": "7",
+		"usage.input_tokens":  "5",
+	} {
+		if got := gjson.GetBytes(out, path).String(); got != want {
+			t.Fatalf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestResponsesRoundTrip(t *testing.T) {
+	full := `{"id":"resp_1","object":"response","status":"completed","output":[{"id":"msg_1","type":"message","role":"assistant","status":"completed","content":[{"type":"output_text","text":"hi","annotations":[]}]}]}`
+
+	chunks, err := Synthesize(sdktranslator.FormatOpenAIResponse, []byte(full))
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	var sawDelta bool
+	for _, chunk := range chunks {
+		if strings.HasPrefix(string(chunk), "event: response.output_text.delta\n") {
+			sawDelta = true
+		}
+	}
+	if !sawDelta {
+		t.Fatal("expected an output_text delta event")
+	}
+
+	out, err := Aggregate(sdktranslator.FormatOpenAIResponse, chunks)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	if got := gjson.GetBytes(out, "output.0.content.0.text").String(); got != "hi" {
+		t.Fatalf("text = %q", got)
+	}
+	if got := gjson.GetBytes(out, "status").String(); got != "completed" {
+		t.Fatalf("status = %q", got)
+	}
+}
+
+func TestAggregateGeminiMergesText(t *testing.T) {
+	chunks := [][]byte{
+		[]byte(`{"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"he"}]}}]}}`),
+		[]byte(`{"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"llo"},{"functionCall":{"name":"f"}}]},"finishReason":"STOP"}],"usageMetadata":{"totalTokenCount":4}}}`),
+	}
+	out, err := Aggregate(sdktranslator.FormatGeminiCLI, chunks)
+	if err != nil {
+		t.Fatalf("Aggregate: %v", err)
+	}
+	for path, want := range map[string]string{
+		"response.candidates.0.content.parts.0.text":              "hello",
+		"response.candidates.0.content.parts.1.functionCall.name": "f",
+		"response.candidates.0.finishReason":                      "STOP",
+		"response.usageMetadata.totalTokenCount":                  "4",
+	} {
+		if got := gjson.GetBytes(out, path).String(); got != want {
+			t.Fatalf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	if _, err := Synthesize(sdktranslator.FormatCodex, []byte(`{}`)); err != ErrUnsupportedFormat {
+		t.Fatalf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package thinking
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// SplitSignature splits a thinking signature tagged with the model group that
+// issued it, such as "gemini#<signature>", into the group and the signature.
+// Untagged signatures are Claude's own and return an empty group.
+func SplitSignature(signature string) (group, raw string) {
+	// Base64 signatures never contain '#', so any '#' ends a tag.
+	if i := strings.IndexByte(signature, '#'); i > 0 {
+		return signature[:i], signature[i+1:]
+	}
+	return "", signature
+}
+
+// ClaudeSignature returns signature in the form the Claude API accepts, or an
+// empty string when it was not issued by Claude: signatures of other model
+// groups and the placeholders some translators make up cannot be verified.
+func ClaudeSignature(signature string) string {
+	group, raw := SplitSignature(signature)
+	if group != "" && group != "claude" {
+		return ""
+	}
+	if len(raw) < cache.MinValidSignatureLen {
+		return ""
+	}
+	return raw
+}
+
+// NormalizeClaudeHistory prepares the thinking blocks of prior turns in a
+// Claude Messages request for the Claude API.
+//
+// Signed blocks are re-serialized as the API minted them: object-shaped
+// thinking is unwrapped, extra fields such as cache_control are dropped and
+// group tags are removed from signatures. When recall knows the original text
+// of a signature, that text replaces a summarized or stripped copy the client
+// was sent. Blocks Claude cannot verify, produced by other providers or only
+// partly echoed back, are removed. If the last assistant turn then calls tools
+// without opening on a thinking block, thinking is disabled for the request,
+// since the API rejects that combination.
+func NormalizeClaudeHistory(body []byte, recall func(signature string) string) []byte {
+	messages := gjson.GetBytes(body, "messages")
+	if !messages.IsArray() {
+		return body
+	}
+	out := make([]string, 0, len(messages.Array()))
+	changed := false
+	messages.ForEach(func(_, message gjson.Result) bool {
+		content := message.Get("content")
+		if !content.IsArray() {
+			out = append(out, message.Raw)
+			return true
+		}
+		assistant := message.Get("role").String() == "assistant"
+		blocks := make([]string, 0, len(content.Array()))
+		modified := false
+		content.ForEach(func(_, block gjson.Result) bool {
+			normalized, keep, blockChanged := normalizeClaudeThinkingBlock(block, assistant, recall)
+			modified = modified || blockChanged
+			if keep {
+				blocks = append(blocks, normalized)
+			}
+			return true
+		})
+		if !modified {
+			out = append(out, message.Raw)
+			return true
+		}
+		changed = true
+		if len(blocks) == 0 {
+			return true
+		}
+		updated, _ := sjson.SetRaw(message.Raw, "content", "["+strings.Join(blocks, ",")+"]")
+		out = append(out, updated)
+		return true
+	})
+	if changed {
+		body, _ = sjson.SetRawBytes(body, "messages", []byte("["+strings.Join(out, ",")+"]"))
+	}
+
+	switch gjson.GetBytes(body, "thinking.type").String() {
+	case "enabled", "adaptive":
+		if lastAssistantToolUseUnsigned(gjson.GetBytes(body, "messages")) {
+			body = StripThinkingConfig(body, "claude")
+		}
+	}
+	return body
+}
+
+// normalizeClaudeThinkingBlock returns block as the Claude API accepts it,
+// whether to keep it and whether it differs from the block received.
+func normalizeClaudeThinkingBlock(block gjson.Result, assistant bool, recall func(string) string) (string, bool, bool) {
+	switch block.Get("type").String() {
+	case "thinking":
+		signature := ClaudeSignature(block.Get("signature").String())
+		if !assistant || signature == "" {
+			return "", false, true
+		}
+		text := GetThinkingText(block)
+		if recall != nil {
+			if original := recall(signature); original != "" {
+				text = original
+			}
+		}
+		thinkingField := block.Get("thinking")
+		if thinkingField.Type == gjson.String && thinkingField.String() == text &&
+			block.Get("signature").String() == signature && len(block.Map()) == 3 {
+			return block.Raw, true, false
+		}
+		normalized := `{"type":"thinking","thinking":"","signature":""}`
+		normalized, _ = sjson.Set(normalized, "thinking", text)
+		normalized, _ = sjson.Set(normalized, "signature", signature)
+		return normalized, true, true
+	case "redacted_thinking":
+		data := block.Get("data").String()
+		if !assistant || data == "" {
+			return "", false, true
+		}
+		if len(block.Map()) == 2 {
+			return block.Raw, true, false
+		}
+		normalized, _ := sjson.Set(`{"type":"redacted_thinking","data":""}`, "data", data)
+		return normalized, true, true
+	}
+	return block.Raw, true, false
+}
+
+// lastAssistantToolUseUnsigned reports whether the last assistant message
+// calls a tool but does not start with a thinking block.
+func lastAssistantToolUseUnsigned(messages gjson.Result) bool {
+	list := messages.Array()
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].Get("role").String() != "assistant" {
+			continue
+		}
+		blocks := list[i].Get("content").Array()
+		if len(blocks) == 0 {
+			return false
+		}
+		switch blocks[0].Get("type").String() {
+		case "thinking", "redacted_thinking":
+			return false
+		}
+		for _, block := range blocks {
+			if block.Get("type").String() == "tool_use" {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package thinking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+var claudeSig = "Er" + strings.Repeat("A", 60)
+
+func TestSplitAndClaudeSignature(t *testing.T) {
+	if group, raw := SplitSignature("gemini#abc"); group != "gemini" || raw != "abc" {
+		t.Fatalf("SplitSignature = %q, %q", group, raw)
+	}
+	if got := ClaudeSignature("claude#" + claudeSig); got != claudeSig {
+		t.Fatalf("claude-tagged signature = %q", got)
+	}
+	for _, sig := range []string{"gemini#" + claudeSig, "short", ""} {
+		if got := ClaudeSignature(sig); got != "" {
+			t.Errorf("ClaudeSignature(%q) = %q, want empty", sig, got)
+		}
+	}
+}
+
+func TestNormalizeClaudeHistory(t *testing.T) {
+	body := []byte(`{"model":"claude-sonnet-4-5","thinking":{"type":"enabled","budget_tokens":2048},"messages":[
+		{"role":"user","content":[{"type":"text","text":"hi"}]},
+		{"role":"assistant","content":[
+			{"type":"thinking","thinking":{"text":"summary","cache_control":{"type":"ephemeral"}},"signature":"claude#` + claudeSig + `"},
+			{"type":"thinking","thinking":"from gemini","signature":"gemini#xyz"},
+			{"type":"redacted_thinking","data":"opaque"},
+			{"type":"tool_use","id":"t1","name":"ls","input":{}}
+		]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}
+	]}`)
+	recall := func(signature string) string {
+		if signature == claudeSig {
+			return "the full original reasoning"
+		}
+		return ""
+	}
+	out := NormalizeClaudeHistory(body, recall)
+	blocks := gjson.GetBytes(out, "messages.1.content").Array()
+	if len(blocks) != 3 {
+		t.Fatalf("assistant blocks = %s", gjson.GetBytes(out, "messages.1.content").Raw)
+	}
+	if blocks[0].Raw != `{"type":"thinking","thinking":"the full original reasoning","signature":"`+claudeSig+`"}` {
+		t.Fatalf("signed block = %s", blocks[0].Raw)
+	}
+	if blocks[1].Get("type").String() != "redacted_thinking" || blocks[2].Get("type").String() != "tool_use" {
+		t.Fatalf("remaining blocks = %s", gjson.GetBytes(out, "messages.1.content").Raw)
+	}
+	if gjson.GetBytes(out, "thinking.type").String() != "enabled" {
+		t.Fatalf("thinking should stay enabled: %s", out)
+	}
+}
+
+func TestNormalizeClaudeHistory_DisablesThinkingForUnsignedToolTurn(t *testing.T) {
+	body := []byte(`{"thinking":{"type":"enabled","budget_tokens":2048},"messages":[
+		{"role":"user","content":"hi"},
+		{"role":"assistant","content":[
+			{"type":"thinking","thinking":"from another provider","signature":"gpt#abc"},
+			{"type":"tool_use","id":"t1","name":"ls","input":{}}
+		]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}
+	]}`)
+	out := NormalizeClaudeHistory(body, nil)
+	if gjson.GetBytes(out, "thinking").Exists() {
+		t.Fatalf("thinking should be disabled: %s", out)
+	}
+	if got := gjson.GetBytes(out, "messages.1.content.0.type").String(); got != "tool_use" {
+		t.Fatalf("unverifiable thinking should be dropped, first block = %q", got)
+	}
+	if got := gjson.GetBytes(out, "messages.0.content").String(); got != "hi" {
+		t.Fatalf("string content changed: %q", got)
+	}
+}
+
+func TestNormalizeClaudeHistory_LeavesVerifiedHistoryUntouched(t *testing.T) {
+	body := []byte(`{"thinking":{"type":"enabled"},"messages":[{"role":"assistant","content":[{"type":"thinking","thinking":"x","signature":"` + claudeSig + `"},{"type":"tool_use","id":"t1","name":"ls","input":{}}]}]}`)
+	if out := NormalizeClaudeHistory(body, func(string) string { return "" }); string(out) != string(body) {
+		t.Fatalf("body rewritten: %s", out)
+	}
+}
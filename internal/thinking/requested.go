@@ -0,0 +1,64 @@
+package thinking
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// requestedLevelPaths lists where the supported client formats carry a
+// discrete thinking level.
+var requestedLevelPaths = []string{
+	"reasoning_effort",
+	"reasoning.effort",
+	"output_config.effort",
+	"generationConfig.thinkingConfig.thinkingLevel",
+	"request.generationConfig.thinkingConfig.thinkingLevel",
+}
+
+// requestedBudgetPaths lists where the supported client formats carry a
+// numeric thinking budget.
+var requestedBudgetPaths = []string{
+	"thinking.budget_tokens",
+	"generationConfig.thinkingConfig.thinkingBudget",
+	"request.generationConfig.thinkingConfig.thinkingBudget",
+}
+
+// RequestedLevel returns the thinking level a client asked for, from the
+// model suffix or else the raw request payload in any supported format.
+// Budgets are mapped to the nearest level. Empty means unset or dynamic.
+func RequestedLevel(suffix SuffixResult, payload []byte) string {
+	if suffix.HasSuffix {
+		if level, ok := ParseLevelSuffix(suffix.RawSuffix); ok {
+			return string(level)
+		}
+		if mode, ok := ParseSpecialSuffix(suffix.RawSuffix); ok {
+			if mode == ModeNone {
+				return string(LevelNone)
+			}
+			return ""
+		}
+		if budget, ok := ParseNumericSuffix(suffix.RawSuffix); ok {
+			level, _ := ConvertBudgetToLevel(budget)
+			return level
+		}
+	}
+	if len(payload) == 0 {
+		return ""
+	}
+	for _, path := range requestedLevelPaths {
+		if level := strings.ToLower(strings.TrimSpace(gjson.GetBytes(payload, path).String())); level != "" {
+			return level
+		}
+	}
+	for _, path := range requestedBudgetPaths {
+		if value := gjson.GetBytes(payload, path); value.Exists() {
+			level, _ := ConvertBudgetToLevel(int(value.Int()))
+			return level
+		}
+	}
+	if gjson.GetBytes(payload, "thinking.type").String() == "disabled" {
+		return string(LevelNone)
+	}
+	return ""
+}
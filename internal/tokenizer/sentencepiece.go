@@ -0,0 +1,76 @@
+package tokenizer
+
+import (
+	"math"
+	"unicode"
+)
+
+// sentencePieceTokenizer approximates a SentencePiece-style vocabulary without
+// loading the model file. Words cost one token per charsPerToken letters, a
+// single leading space is folded into the word, digits are split one per
+// token, and CJK text costs one token per cjkPerToken characters.
+type sentencePieceTokenizer struct {
+	name          string
+	charsPerToken float64
+	cjkPerToken   float64
+}
+
+func newSentencePiece(name string, charsPerToken, cjkPerToken float64) Factory {
+	return func() (Tokenizer, error) {
+		return &sentencePieceTokenizer{name: name, charsPerToken: charsPerToken, cjkPerToken: cjkPerToken}, nil
+	}
+}
+
+func (t *sentencePieceTokenizer) Name() string { return t.name }
+
+func (t *sentencePieceTokenizer) Count(text string) (int, error) {
+	total := 0
+	word, cjk, spaces := 0, 0, 0
+	flush := func() {
+		if word > 0 {
+			total += int(math.Ceil(float64(word) / t.charsPerToken))
+		}
+		if cjk > 0 {
+			total += int(math.Ceil(float64(cjk) / t.cjkPerToken))
+		}
+		// Runs of spaces beyond the one a word absorbs are a token of their own.
+		if spaces > 1 {
+			total++
+		}
+		word, cjk, spaces = 0, 0, 0
+	}
+	for _, r := range text {
+		switch {
+		case r == '\n':
+			flush()
+			total++
+		case unicode.IsSpace(r):
+			if word > 0 || cjk > 0 {
+				flush()
+			}
+			spaces++
+		case isCJK(r):
+			if word > 0 {
+				flush()
+			}
+			cjk++
+		case unicode.IsDigit(r):
+			flush()
+			total++
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			if cjk > 0 {
+				flush()
+			}
+			word++
+		default:
+			flush()
+			total++
+		}
+	}
+	flush()
+	return total, nil
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
@@ -0,0 +1,41 @@
+package tokenizer
+
+import (
+	tiktoken "github.com/tiktoken-go/tokenizer"
+)
+
+const (
+	tiktokenCL100k = tiktoken.Cl100kBase
+	tiktokenO200k  = tiktoken.O200kBase
+)
+
+// tiktokenTokenizer counts with a tiktoken encoding, scaled by factor for
+// models whose own tokenizer is only approximated by it.
+type tiktokenTokenizer struct {
+	name   string
+	codec  tiktoken.Codec
+	factor float64
+}
+
+func newTiktoken(name string, encoding tiktoken.Encoding, factor float64) Factory {
+	return func() (Tokenizer, error) {
+		codec, err := tiktoken.Get(encoding)
+		if err != nil {
+			return nil, err
+		}
+		return &tiktokenTokenizer{name: name, codec: codec, factor: factor}, nil
+	}
+}
+
+func (t *tiktokenTokenizer) Name() string { return t.name }
+
+func (t *tiktokenTokenizer) Count(text string) (int, error) {
+	count, err := t.codec.Count(text)
+	if err != nil {
+		return 0, err
+	}
+	if t.factor > 0 && t.factor != 1 {
+		return int(float64(count) * t.factor), nil
+	}
+	return count, nil
+}
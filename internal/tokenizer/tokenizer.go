@@ -0,0 +1,183 @@
+// Package tokenizer counts tokens with the tokenizer of a model's family.
+// Tokenizers are registered by name and model families are mapped onto those
+// names; a registry model may also name its tokenizer explicitly. Counts are
+// local estimates used for token-count requests and for usage when an
+// upstream omits it.
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+)
+
+// Tokenizer counts the tokens a text encodes to.
+type Tokenizer interface {
+	// Name identifies the tokenizer, e.g. "o200k_base".
+	Name() string
+	// Count returns the number of tokens in text.
+	Count(text string) (int, error)
+}
+
+// Factory creates a tokenizer. It is called at most once per registration.
+type Factory func() (Tokenizer, error)
+
+// Built-in tokenizer names.
+const (
+	CL100kBase          = "cl100k_base"
+	O200kBase           = "o200k_base"
+	Claude              = "claude"
+	GeminiSentencePiece = "gemini-sentencepiece"
+	QwenSentencePiece   = "qwen-sentencepiece"
+)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+	instances = make(map[string]Tokenizer)
+	// families maps a model family, matched as a model ID prefix, to a
+	// tokenizer name.
+	families = make(map[string]string)
+)
+
+func init() {
+	Register(CL100kBase, newTiktoken(CL100kBase, tiktokenCL100k, 1))
+	Register(O200kBase, newTiktoken(O200kBase, tiktokenO200k, 1))
+	// tiktoken undercounts Claude's tokenizer by roughly a tenth.
+	Register(Claude, newTiktoken(Claude, tiktokenCL100k, 1.1))
+	Register(GeminiSentencePiece, newSentencePiece(GeminiSentencePiece, 6, 1.5))
+	Register(QwenSentencePiece, newSentencePiece(QwenSentencePiece, 5, 1.4))
+
+	for family, name := range map[string]string{
+		"gpt-5":          O200kBase,
+		"gpt-4.1":        O200kBase,
+		"gpt-4o":         O200kBase,
+		"gpt-oss":        O200kBase,
+		"chatgpt":        O200kBase,
+		"o1":             O200kBase,
+		"o3":             O200kBase,
+		"o4":             O200kBase,
+		"gpt-4":          CL100kBase,
+		"gpt-3":          CL100kBase,
+		"text-embedding": CL100kBase,
+		"claude":         Claude,
+		"kiro-":          Claude,
+		"amazonq-":       Claude,
+		"gemini":         GeminiSentencePiece,
+		"gemma":          GeminiSentencePiece,
+		"learnlm":        GeminiSentencePiece,
+		"qwen":           QwenSentencePiece,
+		"qwq":            QwenSentencePiece,
+	} {
+		RegisterFamily(family, name)
+	}
+}
+
+// Register adds or replaces the tokenizer factory for name.
+func Register(name string, factory Factory) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || factory == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+	delete(instances, name)
+}
+
+// RegisterFamily maps models whose ID starts with family to the named
+// tokenizer. The longest matching family wins, so "gpt-4o" can refine "gpt-4".
+func RegisterFamily(family, name string) {
+	family = strings.ToLower(strings.TrimSpace(family))
+	name = strings.ToLower(strings.TrimSpace(name))
+	if family == "" || name == "" {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	families[family] = name
+}
+
+// Get returns the tokenizer registered under name.
+func Get(name string) (Tokenizer, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	mu.RLock()
+	tok, ok := instances[name]
+	factory := factories[name]
+	mu.RUnlock()
+	if ok {
+		return tok, nil
+	}
+	if factory == nil {
+		return nil, fmt.Errorf("tokenizer: unknown tokenizer %q", name)
+	}
+	tok, err := factory()
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer: create %s: %w", name, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if existing, ok := instances[name]; ok {
+		return existing, nil
+	}
+	instances[name] = tok
+	return tok, nil
+}
+
+// ForModel returns the tokenizer for model. The registry entry's tokenizer
+// takes precedence, then its reported family, then the family matched from
+// the model ID. Unknown models use o200k_base.
+func ForModel(model string) (Tokenizer, error) {
+	return Get(NameForModel(model))
+}
+
+// NameForModel returns the name of the tokenizer ForModel would use.
+func NameForModel(model string) string {
+	baseModel := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	if baseModel == "" {
+		baseModel = strings.TrimSpace(model)
+	}
+	if baseModel == "" {
+		return CL100kBase
+	}
+	if info := registry.LookupModelInfo(baseModel); info != nil {
+		if name := strings.ToLower(strings.TrimSpace(info.Tokenizer)); name != "" {
+			return name
+		}
+		if name, ok := familyTokenizer(info.Family); ok {
+			return name
+		}
+	}
+	if name, ok := familyTokenizer(baseModel); ok {
+		return name
+	}
+	return O200kBase
+}
+
+// familyTokenizer matches id against the registered families. Vendor prefixes
+// such as "openai/" are ignored.
+func familyTokenizer(id string) (string, bool) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if idx := strings.LastIndex(id, "/"); idx >= 0 {
+		id = id[idx+1:]
+	}
+	if id == "" {
+		return "", false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	best, name := "", ""
+	for family, tok := range families {
+		if strings.HasPrefix(id, family) && len(family) > len(best) {
+			best, name = family, tok
+		}
+	}
+	if best == "" && strings.Contains(id, "claude") {
+		// Bedrock-style IDs such as "us.anthropic.claude-..." embed the family.
+		return Claude, true
+	}
+	return name, best != ""
+}
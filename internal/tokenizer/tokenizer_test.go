@@ -0,0 +1,109 @@
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+func TestNameForModelMatchesLongestFamily(t *testing.T) {
+	cases := map[string]string{
+		"gpt-4o-mini":                  O200kBase,
+		"gpt-4-turbo":                  CL100kBase,
+		"openai/gpt-5(high)":           O200kBase,
+		"claude-sonnet-4-5":            Claude,
+		"us.anthropic.claude-opus-4-1": Claude,
+		"gemini-2.5-pro":               GeminiSentencePiece,
+		"qwen3-coder-plus":             QwenSentencePiece,
+		"some-unknown-model":           O200kBase,
+		"":                             CL100kBase,
+	}
+	for model, want := range cases {
+		if got := NameForModel(model); got != want {
+			t.Errorf("NameForModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestNameForModelPrefersRegistry(t *testing.T) {
+	clientID := uuid.NewString()
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(clientID, "openai", []*registry.ModelInfo{
+		{ID: "tokenizer-test-explicit", Tokenizer: QwenSentencePiece},
+		{ID: "tokenizer-test-family", Family: "gemini-2.5"},
+	})
+	t.Cleanup(func() { reg.UnregisterClient(clientID) })
+
+	if got := NameForModel("tokenizer-test-explicit"); got != QwenSentencePiece {
+		t.Fatalf("explicit tokenizer = %q", got)
+	}
+	if got := NameForModel("tokenizer-test-family"); got != GeminiSentencePiece {
+		t.Fatalf("family tokenizer = %q", got)
+	}
+}
+
+type fixedTokenizer int
+
+func (f fixedTokenizer) Name() string              { return "fixed" }
+func (f fixedTokenizer) Count(string) (int, error) { return int(f), nil }
+
+func TestRegisterCustomTokenizer(t *testing.T) {
+	Register("fixed", func() (Tokenizer, error) { return fixedTokenizer(42), nil })
+	RegisterFamily("tokenizer-test-", "fixed")
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(factories, "fixed")
+		delete(instances, "fixed")
+		delete(families, "tokenizer-test-")
+		mu.Unlock()
+	})
+
+	tok, err := ForModel("tokenizer-test-custom")
+	if err != nil {
+		t.Fatalf("ForModel: %v", err)
+	}
+	if n, _ := tok.Count("anything"); n != 42 {
+		t.Fatalf("count = %d, want 42", n)
+	}
+	if _, err = Get("missing"); err == nil {
+		t.Fatal("expected error for an unknown tokenizer")
+	}
+}
+
+func TestSentencePieceApproximation(t *testing.T) {
+	tok, err := Get(GeminiSentencePiece)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	cases := map[string]int{
+		"":                     0,
+		"hello world":          2,
+		"internationalization": 4,
+		"2025":                 4,
+		"hi, there\n":          4,
+		"你好世界":                 3,
+	}
+	for text, want := range cases {
+		if got, _ := tok.Count(text); got != want {
+			t.Errorf("Count(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestTiktokenAdjustment(t *testing.T) {
+	base, err := Get(CL100kBase)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	claude, err := Get(Claude)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	text := "The quick brown fox jumps over the lazy dog, again and again and again."
+	n, _ := base.Count(text)
+	m, _ := claude.Count(text)
+	if m != int(float64(n)*1.1) {
+		t.Fatalf("claude count = %d, want %d", m, int(float64(n)*1.1))
+	}
+}
@@ -0,0 +1,94 @@
+// Package tokenlimit normalizes output token limits across provider schemas.
+// Translators map the client's limit into the provider field; Normalize then
+// clamps it to the model's registered cap and fills fields that a provider
+// requires.
+package tokenlimit
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// DefaultClaudeMaxTokens fills Claude's mandatory max_tokens when neither the
+// client nor the registry provides a value. It matches the translator default.
+const DefaultClaudeMaxTokens = 32000
+
+// FromOpenAIChat returns the output limit of an OpenAI Chat Completions
+// request. max_completion_tokens supersedes the deprecated max_tokens.
+func FromOpenAIChat(root gjson.Result) (int64, bool) {
+	for _, key := range []string{"max_completion_tokens", "max_tokens"} {
+		if value := root.Get(key); value.Type == gjson.Number && value.Int() > 0 {
+			return value.Int(), true
+		}
+	}
+	return 0, false
+}
+
+// fieldPaths lists the limit fields of a provider schema.
+func fieldPaths(format string) []string {
+	switch format {
+	case "claude":
+		return []string{"max_tokens"}
+	case "openai":
+		return []string{"max_completion_tokens", "max_tokens"}
+	case "openai-response", "codex":
+		return []string{"max_output_tokens"}
+	case "gemini":
+		return []string{"generationConfig.maxOutputTokens"}
+	case "gemini-cli", "antigravity":
+		return []string{"request.generationConfig.maxOutputTokens"}
+	default:
+		return nil
+	}
+}
+
+// ModelLimit returns the registered output cap of model, or 0 when unknown.
+func ModelLimit(model, provider string) int64 {
+	baseModel := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	if baseModel == "" {
+		baseModel = strings.TrimSpace(model)
+	}
+	info := registry.LookupModelInfo(baseModel, provider)
+	if info == nil {
+		return 0
+	}
+	if info.MaxCompletionTokens > 0 {
+		return int64(info.MaxCompletionTokens)
+	}
+	return int64(info.OutputTokenLimit)
+}
+
+// Normalize clamps the output limit of body, already in the provider format,
+// to the model cap and fills max_tokens for Claude when it is missing.
+// User-defined models without a cap are left as sent.
+func Normalize(body []byte, model, format, provider string) []byte {
+	paths := fieldPaths(format)
+	if len(paths) == 0 || !gjson.ValidBytes(body) {
+		return body
+	}
+	limit := ModelLimit(model, provider)
+	present := false
+	for _, path := range paths {
+		value := gjson.GetBytes(body, path)
+		if !value.Exists() {
+			continue
+		}
+		present = true
+		if limit > 0 && value.Type == gjson.Number && value.Int() > limit {
+			body, _ = sjson.SetBytes(body, path, limit)
+		}
+	}
+	// Kiro derives its own limit when the request carries none.
+	if !present && format == "claude" && provider != "kiro" {
+		fill := int64(DefaultClaudeMaxTokens)
+		if limit > 0 && limit < fill {
+			fill = limit
+		}
+		body, _ = sjson.SetBytes(body, "max_tokens", fill)
+	}
+	return body
+}
@@ -0,0 +1,71 @@
+package tokenlimit
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+)
+
+func registerLimitModel(t *testing.T, provider string, info *registry.ModelInfo) {
+	t.Helper()
+	clientID := uuid.NewString()
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(clientID, provider, []*registry.ModelInfo{info})
+	t.Cleanup(func() { reg.UnregisterClient(clientID) })
+}
+
+func TestNormalizeClampsToModelLimit(t *testing.T) {
+	registerLimitModel(t, "gemini", &registry.ModelInfo{ID: "limit-test-gemini", OutputTokenLimit: 1000})
+
+	out := Normalize([]byte(`{"generationConfig":{"maxOutputTokens":5000}}`), "limit-test-gemini(high)", "gemini", "gemini")
+	if got := gjson.GetBytes(out, "generationConfig.maxOutputTokens").Int(); got != 1000 {
+		t.Fatalf("maxOutputTokens = %d, want 1000", got)
+	}
+
+	out = Normalize([]byte(`{"generationConfig":{"maxOutputTokens":500}}`), "limit-test-gemini", "gemini", "gemini")
+	if got := gjson.GetBytes(out, "generationConfig.maxOutputTokens").Int(); got != 500 {
+		t.Fatalf("maxOutputTokens = %d, want 500", got)
+	}
+}
+
+func TestNormalizeFillsClaudeMaxTokens(t *testing.T) {
+	registerLimitModel(t, "claude", &registry.ModelInfo{ID: "limit-test-claude", MaxCompletionTokens: 8192})
+
+	out := Normalize([]byte(`{"messages":[]}`), "limit-test-claude", "claude", "claude")
+	if got := gjson.GetBytes(out, "max_tokens").Int(); got != 8192 {
+		t.Fatalf("max_tokens = %d, want 8192", got)
+	}
+
+	out = Normalize([]byte(`{"messages":[]}`), "unknown-claude-model", "claude", "claude")
+	if got := gjson.GetBytes(out, "max_tokens").Int(); got != DefaultClaudeMaxTokens {
+		t.Fatalf("max_tokens = %d, want default", got)
+	}
+
+	out = Normalize([]byte(`{"messages":[]}`), "unknown-claude-model", "claude", "kiro")
+	if gjson.GetBytes(out, "max_tokens").Exists() {
+		t.Fatal("kiro requests must not get a max_tokens fill")
+	}
+}
+
+func TestNormalizeLeavesUnknownModelsAlone(t *testing.T) {
+	body := []byte(`{"max_completion_tokens":100000}`)
+	if out := Normalize(body, "unknown-openai-model", "openai", "openai"); string(out) != string(body) {
+		t.Fatalf("unexpected rewrite %s", out)
+	}
+}
+
+func TestFromOpenAIChatPrefersMaxCompletionTokens(t *testing.T) {
+	got, ok := FromOpenAIChat(gjson.Parse(`{"max_tokens":10,"max_completion_tokens":20}`))
+	if !ok || got != 20 {
+		t.Fatalf("got %d, %t", got, ok)
+	}
+	got, ok = FromOpenAIChat(gjson.Parse(`{"max_tokens":10}`))
+	if !ok || got != 10 {
+		t.Fatalf("got %d, %t", got, ok)
+	}
+	if _, ok = FromOpenAIChat(gjson.Parse(`{}`)); ok {
+		t.Fatal("expected no limit")
+	}
+}
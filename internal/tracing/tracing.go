@@ -0,0 +1,160 @@
+// Package tracing records per-request stage timings and logs them for requests
+// slower than the configured threshold, so slowness can be attributed to the
+// proxy or to the upstream.
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// ginTraceKey is the Gin context key holding the request's Trace.
+const ginTraceKey = "__stage_trace__"
+
+// Stages recorded for a request. Connect, TTFB and stream are spent waiting on
+// the upstream; everything else is proxy-side.
+const (
+	StageAuthSelection = "auth-selection"
+	StageTranslation   = "translation"
+	StageThinking      = "thinking"
+	StageConnect       = "connect"
+	StageTTFB          = "ttfb"
+	StageStream        = "stream"
+)
+
+var upstreamStages = map[string]struct{}{
+	StageConnect: {},
+	StageTTFB:    {},
+	StageStream:  {},
+}
+
+var threshold atomic.Int64
+
+// SetThreshold sets the latency above which traces are logged. Zero or a
+// negative value disables tracing.
+func SetThreshold(d time.Duration) { threshold.Store(int64(d)) }
+
+// Threshold returns the current logging threshold.
+func Threshold() time.Duration { return time.Duration(threshold.Load()) }
+
+// Span is one timed stage, relative to the start of the request.
+type Span struct {
+	Stage      string  `json:"stage"`
+	StartMs    float64 `json:"start_ms"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Trace collects the spans of one request. Methods are safe on a nil Trace,
+// so call sites need not check whether tracing is enabled.
+type Trace struct {
+	mu    sync.Mutex
+	start time.Time
+	spans []Span
+}
+
+// New starts a trace at the current time.
+func New() *Trace { return &Trace{start: time.Now()} }
+
+// Attach stores t in the Gin context so the rest of the request can find it
+// with FromContext.
+func Attach(c *gin.Context, t *Trace) {
+	if c != nil && t != nil {
+		c.Set(ginTraceKey, t)
+	}
+}
+
+// FromContext returns the Trace of the API request that ctx belongs to, or nil.
+func FromContext(ctx context.Context) *Trace {
+	if ctx == nil {
+		return nil
+	}
+	c, _ := ctx.Value("gin").(*gin.Context)
+	if c == nil {
+		return nil
+	}
+	if v, ok := c.Get(ginTraceKey); ok {
+		if t, okTrace := v.(*Trace); okTrace {
+			return t
+		}
+	}
+	return nil
+}
+
+// Record adds a span for stage covering start to end. Retries and fallbacks
+// may record the same stage several times.
+func (t *Trace) Record(stage string, start, end time.Time) {
+	if t == nil || start.IsZero() || end.Before(start) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, Span{Stage: stage, StartMs: millis(start.Sub(t.start)), DurationMs: millis(end.Sub(start))})
+}
+
+// Start begins a span for stage and returns the function that ends it.
+func (t *Trace) Start(stage string) func() {
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { t.Record(stage, start, time.Now()) }
+}
+
+// Report is the structured summary logged for a slow request.
+type Report struct {
+	RequestID  string  `json:"request_id"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	TotalMs    float64 `json:"total_ms"`
+	ProxyMs    float64 `json:"proxy_ms"`
+	UpstreamMs float64 `json:"upstream_ms"`
+	Stages     []Span  `json:"stages"`
+}
+
+// Report summarises the trace as of now.
+func (t *Trace) Report(requestID, method, path string, status int) Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := Report{
+		RequestID: requestID,
+		Method:    method,
+		Path:      path,
+		Status:    status,
+		TotalMs:   millis(time.Since(t.start)),
+		Stages:    append([]Span(nil), t.spans...),
+	}
+	for _, span := range t.spans {
+		if _, upstream := upstreamStages[span.Stage]; upstream {
+			report.UpstreamMs += span.DurationMs
+		}
+	}
+	report.UpstreamMs = min(report.UpstreamMs, report.TotalMs)
+	report.ProxyMs = report.TotalMs - report.UpstreamMs
+	return report
+}
+
+// LogIfSlow logs the trace when the request took at least the threshold.
+func (t *Trace) LogIfSlow(requestID, method, path string, status int) {
+	limit := Threshold()
+	if t == nil || limit <= 0 || time.Since(t.start) < limit {
+		return
+	}
+	report := t.Report(requestID, method, path, status)
+	data, errMarshal := json.Marshal(report)
+	if errMarshal != nil {
+		return
+	}
+	log.WithField("request_id", requestID).Warnf("slow request %s %s: %.0fms total, %.0fms proxy, %.0fms upstream %s",
+		method, path, report.TotalMs, report.ProxyMs, report.UpstreamMs, data)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTraceReportSplitsProxyAndUpstreamTime(t *testing.T) {
+	trace := New()
+	trace.start = time.Now().Add(-time.Second)
+	base := trace.start
+	trace.Record(StageAuthSelection, base, base.Add(100*time.Millisecond))
+	trace.Record(StageConnect, base.Add(200*time.Millisecond), base.Add(300*time.Millisecond))
+	trace.Record(StageTTFB, base.Add(300*time.Millisecond), base.Add(700*time.Millisecond))
+	trace.Record(StageThinking, base, base.Add(-time.Millisecond)) // negative spans are dropped
+
+	report := trace.Report("a1b2c3d4", "POST", "/v1/messages", 200)
+	if len(report.Stages) != 3 {
+		t.Fatalf("stages = %+v", report.Stages)
+	}
+	if report.UpstreamMs != 500 {
+		t.Fatalf("upstream = %v, want 500", report.UpstreamMs)
+	}
+	if report.TotalMs < 1000 || report.ProxyMs != report.TotalMs-report.UpstreamMs {
+		t.Fatalf("total = %v proxy = %v", report.TotalMs, report.ProxyMs)
+	}
+	if report.Stages[1].StartMs != 200 {
+		t.Fatalf("connect starts at %v, want 200", report.Stages[1].StartMs)
+	}
+}
+
+func TestNilTraceIsNoOp(t *testing.T) {
+	var trace *Trace
+	trace.Start(StageTranslation)()
+	trace.Record(StageStream, time.Now(), time.Now())
+	trace.LogIfSlow("id", "POST", "/v1/messages", 200)
+	if FromContext(nil) != nil {
+		t.Fatal("nil context should have no trace")
+	}
+}
@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/documents"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logprobs"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/seed"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenlimit"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
@@ -66,8 +70,14 @@ func ConvertOpenAIRequestToAntigravity(modelName string, inputRawJSON []byte, _
 	if tkr := gjson.GetBytes(rawJSON, "top_k"); tkr.Exists() && tkr.Type == gjson.Number {
 		out, _ = sjson.SetBytes(out, "request.generationConfig.topK", tkr.Num)
 	}
-	if maxTok := gjson.GetBytes(rawJSON, "max_tokens"); maxTok.Exists() && maxTok.Type == gjson.Number {
-		out, _ = sjson.SetBytes(out, "request.generationConfig.maxOutputTokens", maxTok.Num)
+	if maxTok, ok := tokenlimit.FromOpenAIChat(gjson.ParseBytes(rawJSON)); ok {
+		out, _ = sjson.SetBytes(out, "request.generationConfig.maxOutputTokens", maxTok)
+	}
+	if enabled, top := logprobs.FromOpenAIChat(gjson.ParseBytes(rawJSON)); enabled {
+		out = logprobs.SetGemini(out, "request.generationConfig", top)
+	}
+	if value, ok := seed.FromRequest(rawJSON, "openai"); ok {
+		out = seed.SetGemini(out, "request.generationConfig", value)
 	}
 
 	// Candidate count (OpenAI 'n' parameter)
@@ -205,7 +215,15 @@ func ConvertOpenAIRequestToAntigravity(modelName string, inputRawJSON []byte, _
 							if sp := strings.Split(filename, "."); len(sp) > 1 {
 								ext = sp[len(sp)-1]
 							}
-							if mimeType, ok := misc.MimeTypes[ext]; ok {
+							mimeType, ok := misc.MimeTypes[ext]
+							// file_data is usually a data URL; Gemini takes the bare base64.
+							if dataMimeType, data, isDataURL := documents.ParseDataURL(fileData); isDataURL {
+								fileData = data
+								if !ok && dataMimeType != "" {
+									mimeType, ok = dataMimeType, true
+								}
+							}
+							if ok {
 								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.mimeType", mimeType)
 								node, _ = sjson.SetBytes(node, "parts."+itoa(p)+".inlineData.data", fileData)
 								p++
@@ -15,7 +15,10 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logprobs"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/seed"
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -63,6 +66,9 @@ func ConvertAntigravityResponseToOpenAI(_ context.Context, _ string, originalReq
 	// Extract and set the model version.
 	if modelVersionResult := gjson.GetBytes(rawJSON, "response.modelVersion"); modelVersionResult.Exists() {
 		template, _ = sjson.Set(template, "model", modelVersionResult.String())
+		if fingerprint := seed.Fingerprint(originalRequestRawJSON, modelVersionResult.String()); fingerprint != "" {
+			template, _ = sjson.Set(template, "system_fingerprint", fingerprint)
+		}
 	}
 
 	// Extract and set the creation timestamp.
@@ -111,6 +117,10 @@ func ConvertAntigravityResponseToOpenAI(_ context.Context, _ string, originalReq
 		}
 	}
 
+	if lp := logprobs.OpenAIFromGemini(gjson.GetBytes(rawJSON, "response.candidates.0.logprobsResult")); lp != "" {
+		template, _ = sjson.SetRaw(template, "choices.0.logprobs", lp)
+	}
+
 	// Process the main content part of the response.
 	partsResult := gjson.GetBytes(rawJSON, "response.candidates.0.content.parts")
 	if partsResult.IsArray() {
@@ -204,15 +214,11 @@ func ConvertAntigravityResponseToOpenAI(_ context.Context, _ string, originalReq
 	isFinalChunk := upstreamFinishReason != "" && usageExists
 
 	if isFinalChunk {
-		var finishReason string
+		finishReason := stopreason.Parse(upstreamFinishReason)
 		if sawToolCall {
-			finishReason = "tool_calls"
-		} else if upstreamFinishReason == "MAX_TOKENS" {
-			finishReason = "max_tokens"
-		} else {
-			finishReason = "stop"
+			finishReason = stopreason.ToolUse
 		}
-		template, _ = sjson.Set(template, "choices.0.finish_reason", finishReason)
+		template, _ = sjson.Set(template, "choices.0.finish_reason", stopreason.ToOpenAI(finishReason))
 		template, _ = sjson.Set(template, "choices.0.native_finish_reason", strings.ToLower(upstreamFinishReason))
 	}
 
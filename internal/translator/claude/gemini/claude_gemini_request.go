@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/documents"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
@@ -305,6 +306,12 @@ func ConvertGeminiRequestToClaude(modelName string, inputRawJSON []byte, stream
 						return true
 					}
 
+					// Document content (PDF and plain text) conversion to Claude document blocks
+					if doc, ok := documents.FromGemini(part); ok {
+						msg, _ = sjson.SetRaw(msg, "content.-1", doc.ClaudeBlock())
+						return true
+					}
+
 					// Image content (inline_data) conversion to Claude Code format
 					if inlineData := part.Get("inline_data"); inlineData.Exists() {
 						imageContent := `{"type":"image","source":{"type":"base64","media_type":"","data":""}}`
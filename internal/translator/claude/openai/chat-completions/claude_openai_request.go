@@ -14,8 +14,10 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenlimit"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -131,8 +133,8 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 	out, _ = sjson.Set(out, "model", modelName)
 
 	// Max tokens configuration with fallback to default value
-	if maxTokens := root.Get("max_tokens"); maxTokens.Exists() {
-		out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
+	if maxTokens, ok := tokenlimit.FromOpenAIChat(root); ok {
+		out, _ = sjson.Set(out, "max_tokens", maxTokens)
 	}
 
 	// Temperature setting for controlling response randomness
@@ -196,6 +198,19 @@ func ConvertOpenAIRequestToClaude(modelName string, inputRawJSON []byte, stream
 				msg := `{"role":"","content":[]}`
 				msg, _ = sjson.Set(msg, "role", role)
 
+				// Rebuild the signed thinking block behind echoed reasoning_content,
+				// which Claude needs to continue a tool loop with thinking enabled.
+				if role == "assistant" {
+					if reasoning := message.Get("reasoning_content").String(); reasoning != "" {
+						if signature := cache.GetCachedSignature(modelName, reasoning); signature != "" {
+							block := `{"type":"thinking","thinking":"","signature":""}`
+							block, _ = sjson.Set(block, "thinking", reasoning)
+							block, _ = sjson.Set(block, "signature", signature)
+							msg, _ = sjson.SetRaw(msg, "content.-1", block)
+						}
+					}
+				}
+
 				// Handle content based on its type (string or array)
 				if contentResult.Exists() && contentResult.Type == gjson.String && contentResult.String() != "" {
 					part := `{"type":"text","text":""}`
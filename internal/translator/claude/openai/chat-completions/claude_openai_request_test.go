@@ -1,6 +1,8 @@
 package chat_completions
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/tidwall/gjson"
@@ -135,3 +137,29 @@ func TestConvertOpenAIRequestToClaude_ToolResultURLImageOnly(t *testing.T) {
 		t.Fatalf("Unexpected image URL: %q", got)
 	}
 }
+
+func TestConvertOpenAIRequestToClaude_RebuildsSignedThinking(t *testing.T) {
+	signature := "Er" + strings.Repeat("B", 60)
+	var param any
+	for _, event := range []string{
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Listing files first."}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"` + signature + `"}}`,
+	} {
+		ConvertClaudeResponseToOpenAI(context.Background(), "claude-sonnet-4-5", nil, nil, []byte(event), &param)
+	}
+
+	inputJSON := `{"model":"claude-sonnet-4-5","messages":[
+		{"role":"user","content":"list files"},
+		{"role":"assistant","content":"","reasoning_content":"Listing files first.","tool_calls":[{"id":"call_1","type":"function","function":{"name":"ls","arguments":"{}"}}]},
+		{"role":"tool","tool_call_id":"call_1","content":"a.txt"}
+	]}`
+	result := gjson.ParseBytes(ConvertOpenAIRequestToClaude("claude-sonnet-4-5", []byte(inputJSON), false))
+	block := result.Get("messages.1.content.0")
+	if block.Get("type").String() != "thinking" || block.Get("thinking").String() != "Listing files first." || block.Get("signature").String() != signature {
+		t.Fatalf("assistant content = %s", result.Get("messages.1.content").Raw)
+	}
+	if got := result.Get("messages.1.content.1.type").String(); got != "tool_use" {
+		t.Fatalf("second block type = %q, want tool_use", got)
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -27,6 +29,9 @@ type ConvertAnthropicResponseToOpenAIParams struct {
 	FinishReason string
 	// Tool calls accumulator for streaming
 	ToolCallsAccumulator map[int]*ToolCallAccumulator
+	// ThinkingText accumulates the open thinking block, whose signature is
+	// cached by text so the block can be rebuilt from reasoning_content.
+	ThinkingText strings.Builder
 }
 
 // ToolCallAccumulator holds the state for accumulating tool call data
@@ -150,8 +155,13 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 				// Accumulate reasoning/thinking content
 				if thinking := delta.Get("thinking"); thinking.Exists() {
 					template, _ = sjson.Set(template, "choices.0.delta.reasoning_content", thinking.String())
+					(*param).(*ConvertAnthropicResponseToOpenAIParams).ThinkingText.WriteString(thinking.String())
 					hasContent = true
 				}
+			case "signature_delta":
+				thinkingText := &(*param).(*ConvertAnthropicResponseToOpenAIParams).ThinkingText
+				cache.CacheSignature(modelName, thinkingText.String(), delta.Get("signature").String())
+				thinkingText.Reset()
 			case "input_json_delta":
 				// Tool use input delta - accumulate arguments for tool calls
 				if partialJSON := delta.Get("partial_json"); partialJSON.Exists() {
@@ -244,18 +254,7 @@ func ConvertClaudeResponseToOpenAI(_ context.Context, modelName string, original
 
 // mapAnthropicStopReasonToOpenAI maps Anthropic stop reasons to OpenAI stop reasons
 func mapAnthropicStopReasonToOpenAI(anthropicReason string) string {
-	switch anthropicReason {
-	case "end_turn":
-		return "stop"
-	case "tool_use":
-		return "tool_calls"
-	case "max_tokens":
-		return "length"
-	case "stop_sequence":
-		return "stop"
-	default:
-		return "stop"
-	}
+	return stopreason.ToOpenAI(stopreason.Parse(anthropicReason))
 }
 
 // ConvertClaudeResponseToOpenAINonStream converts a non-streaming Claude Code response to a non-streaming OpenAI response.
@@ -291,6 +290,7 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 	var stopReason string
 	var contentParts []string
 	var reasoningParts []string
+	var thinkingText strings.Builder
 	toolCallsAccumulator := make(map[int]*ToolCallAccumulator)
 
 	for _, chunk := range chunks {
@@ -337,7 +337,11 @@ func ConvertClaudeResponseToOpenAINonStream(_ context.Context, _ string, origina
 					// Accumulate reasoning/thinking content
 					if thinking := delta.Get("thinking"); thinking.Exists() {
 						reasoningParts = append(reasoningParts, thinking.String())
+						thinkingText.WriteString(thinking.String())
 					}
+				case "signature_delta":
+					cache.CacheSignature(model, thinkingText.String(), delta.Get("signature").String())
+					thinkingText.Reset()
 				case "input_json_delta":
 					// Accumulate tool call arguments
 					if partialJSON := delta.Get("partial_json"); partialJSON.Exists() {
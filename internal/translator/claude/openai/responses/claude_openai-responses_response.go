@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -36,6 +37,8 @@ type claudeToResponsesState struct {
 	InputTokens  int64
 	OutputTokens int64
 	UsageSeen    bool
+	// StopReason is the Claude stop_reason reported by message_delta
+	StopReason string
 }
 
 var dataTag = []byte("data:")
@@ -272,6 +275,9 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 			st.ReasoningPartAdded = false
 		}
 	case "message_delta":
+		if v := root.Get("delta.stop_reason"); v.Exists() && v.String() != "" {
+			st.StopReason = v.String()
+		}
 		if usage := root.Get("usage"); usage.Exists() {
 			if v := usage.Get("output_tokens"); v.Exists() {
 				st.OutputTokens = v.Int()
@@ -288,6 +294,13 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 		completed, _ = sjson.Set(completed, "sequence_number", nextSeq())
 		completed, _ = sjson.Set(completed, "response.id", st.ResponseID)
 		completed, _ = sjson.Set(completed, "response.created_at", st.CreatedAt)
+		completedEvent := "response.completed"
+		if status, incompleteReason := stopreason.ToResponses(stopreason.Parse(st.StopReason)); status != "completed" {
+			completedEvent = "response." + status
+			completed, _ = sjson.Set(completed, "type", completedEvent)
+			completed, _ = sjson.Set(completed, "response.status", status)
+			completed, _ = sjson.Set(completed, "response.incomplete_details.reason", incompleteReason)
+		}
 		// Inject original request fields into response as per docs/response.completed.json
 
 		reqBytes := pickRequestJSON(originalRequestRawJSON, requestRawJSON)
@@ -425,7 +438,7 @@ func ConvertClaudeResponseToOpenAIResponses(ctx context.Context, modelName strin
 				completed, _ = sjson.Set(completed, "response.usage.total_tokens", total)
 			}
 		}
-		out = append(out, emitEvent("response.completed", completed))
+		out = append(out, emitEvent(completedEvent, completed))
 	}
 
 	return out
@@ -469,6 +482,7 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		reasoningItemID string
 		inputTokens     int64
 		outputTokens    int64
+		stopReason      string
 	)
 
 	// Per-index tool call aggregation
@@ -550,6 +564,9 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 			_ = root
 
 		case "message_delta":
+			if v := root.Get("delta.stop_reason"); v.Exists() && v.String() != "" {
+				stopReason = v.String()
+			}
 			if usage := root.Get("usage"); usage.Exists() {
 				outputTokens = usage.Get("output_tokens").Int()
 			}
@@ -559,6 +576,10 @@ func ConvertClaudeResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	// Populate base fields
 	out, _ = sjson.Set(out, "id", responseID)
 	out, _ = sjson.Set(out, "created_at", createdAt)
+	if status, incompleteReason := stopreason.ToResponses(stopreason.Parse(stopReason)); status != "completed" {
+		out, _ = sjson.Set(out, "status", status)
+		out, _ = sjson.Set(out, "incomplete_details.reason", incompleteReason)
+	}
 
 	// Inject request echo fields as top-level (similar to streaming variant)
 	reqBytes := pickRequestJSON(originalRequestRawJSON, requestRawJSON)
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/documents"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -149,6 +150,12 @@ func ConvertClaudeRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 								appendImageContent(dataURL)
 							}
 						}
+					case "document":
+						if doc, ok := documents.FromClaude(messageContentResult); ok {
+							message, _ = sjson.SetRaw(message, fmt.Sprintf("content.%d", contentIndex), doc.ResponsesPart())
+							contentIndex++
+							hasContent = true
+						}
 					case "tool_use":
 						flushMessage()
 						functionCallMessage := `{"type":"function_call"}`
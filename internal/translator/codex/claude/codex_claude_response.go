@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -111,17 +112,13 @@ func ConvertCodexResponseToClaude(_ context.Context, _ string, originalRequestRa
 
 		output = "event: content_block_stop\n"
 		output += fmt.Sprintf("data: %s\n\n", template)
-	} else if typeStr == "response.completed" {
+	} else if typeStr == "response.completed" || typeStr == "response.incomplete" {
 		template = `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`
-		p := (*param).(*ConvertCodexResponseToClaudeParams).HasToolCall
-		stopReason := rootResult.Get("response.stop_reason").String()
-		if p {
-			template, _ = sjson.Set(template, "delta.stop_reason", "tool_use")
-		} else if stopReason == "max_tokens" || stopReason == "stop" {
-			template, _ = sjson.Set(template, "delta.stop_reason", stopReason)
-		} else {
-			template, _ = sjson.Set(template, "delta.stop_reason", "end_turn")
+		stopReason := stopreason.FromResponsesObject(rootResult.Get("response"))
+		if stopReason == stopreason.Stop && (*param).(*ConvertCodexResponseToClaudeParams).HasToolCall {
+			stopReason = stopreason.ToolUse
 		}
+		template, _ = sjson.Set(template, "delta.stop_reason", stopreason.ToClaude(stopReason))
 		inputTokens, outputTokens, cachedTokens := extractResponsesUsage(rootResult.Get("response.usage"))
 		template, _ = sjson.Set(template, "usage.input_tokens", inputTokens)
 		template, _ = sjson.Set(template, "usage.output_tokens", outputTokens)
@@ -219,7 +216,7 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 	revNames := buildReverseMapFromClaudeOriginalShortToOriginal(originalRequestRawJSON)
 
 	rootResult := gjson.ParseBytes(rawJSON)
-	if rootResult.Get("type").String() != "response.completed" {
+	if typ := rootResult.Get("type").String(); typ != "response.completed" && typ != "response.incomplete" {
 		return ""
 	}
 
@@ -327,13 +324,11 @@ func ConvertCodexResponseToClaudeNonStream(_ context.Context, _ string, original
 		})
 	}
 
-	if stopReason := responseData.Get("stop_reason"); stopReason.Exists() && stopReason.String() != "" {
-		out, _ = sjson.Set(out, "stop_reason", stopReason.String())
-	} else if hasToolCall {
-		out, _ = sjson.Set(out, "stop_reason", "tool_use")
-	} else {
-		out, _ = sjson.Set(out, "stop_reason", "end_turn")
+	stopReason := stopreason.FromResponsesObject(responseData)
+	if stopReason == stopreason.Stop && hasToolCall {
+		stopReason = stopreason.ToolUse
 	}
+	out, _ = sjson.Set(out, "stop_reason", stopreason.ToClaude(stopReason))
 
 	if stopSequence := responseData.Get("stop_sequence"); stopSequence.Exists() && stopSequence.String() != "" {
 		out, _ = sjson.SetRaw(out, "stop_sequence", stopSequence.Raw)
@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/documents"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
@@ -137,6 +138,15 @@ func ConvertGeminiRequestToCodex(modelName string, inputRawJSON []byte, _ bool)
 					continue
 				}
 
+				// document part
+				if doc, ok := documents.FromGemini(p); ok {
+					msg := `{"type":"message","role":"","content":[]}`
+					msg, _ = sjson.Set(msg, "role", role)
+					msg, _ = sjson.SetRaw(msg, "content.-1", doc.ResponsesPart())
+					out, _ = sjson.SetRaw(out, "input.-1", msg)
+					continue
+				}
+
 				// function call from model
 				if fc := p.Get("functionCall"); fc.Exists() {
 					fn := `{"type":"function_call"}`
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -120,7 +121,8 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 		part := `{"text":""}`
 		part, _ = sjson.Set(part, "text", rootResult.Get("delta").String())
 		template, _ = sjson.SetRaw(template, "candidates.0.content.parts.-1", part)
-	} else if typeStr == "response.completed" { // Handle response completion with usage metadata
+	} else if typeStr == "response.completed" || typeStr == "response.incomplete" { // Handle response completion with usage metadata
+		template, _ = sjson.Set(template, "candidates.0.finishReason", stopreason.ToGemini(stopreason.FromResponsesObject(rootResult.Get("response"))))
 		template, _ = sjson.Set(template, "usageMetadata.promptTokenCount", rootResult.Get("response.usage.input_tokens").Int())
 		template, _ = sjson.Set(template, "usageMetadata.candidatesTokenCount", rootResult.Get("response.usage.output_tokens").Int())
 		totalTokens := rootResult.Get("response.usage.input_tokens").Int() + rootResult.Get("response.usage.output_tokens").Int()
@@ -153,8 +155,8 @@ func ConvertCodexResponseToGemini(_ context.Context, modelName string, originalR
 func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	rootResult := gjson.ParseBytes(rawJSON)
 
-	// Verify this is a response.completed event
-	if rootResult.Get("type").String() != "response.completed" {
+	// Verify this is a terminal response event
+	if typ := rootResult.Get("type").String(); typ != "response.completed" && typ != "response.incomplete" {
 		return ""
 	}
 
@@ -189,7 +191,6 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 		}
 
 		// Process output content to build parts array
-		hasToolCall := false
 		var pendingFunctionCalls []string
 
 		flushPendingFunctionCalls := func() {
@@ -240,7 +241,6 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 
 				case "function_call":
 					// Collect function call for potential merging with consecutive ones
-					hasToolCall = true
 					functionCall := `{"functionCall":{"args":{},"name":""}}`
 					{
 						n := value.Get("name").String()
@@ -268,12 +268,8 @@ func ConvertCodexResponseToGeminiNonStream(_ context.Context, modelName string,
 			flushPendingFunctionCalls()
 		}
 
-		// Set finish reason based on whether there were tool calls
-		if hasToolCall {
-			template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
-		} else {
-			template, _ = sjson.Set(template, "candidates.0.finishReason", "STOP")
-		}
+		// Gemini reports tool calls with STOP, so only the response outcome matters here
+		template, _ = sjson.Set(template, "candidates.0.finishReason", stopreason.ToGemini(stopreason.FromResponsesObject(responseData)))
 	}
 	return template
 }
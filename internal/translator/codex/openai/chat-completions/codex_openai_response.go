@@ -10,6 +10,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -120,13 +121,13 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 			template, _ = sjson.Set(template, "choices.0.delta.role", "assistant")
 			template, _ = sjson.Set(template, "choices.0.delta.content", deltaResult.String())
 		}
-	} else if dataType == "response.completed" {
-		finishReason := "stop"
-		if (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex != -1 {
-			finishReason = "tool_calls"
+	} else if dataType == "response.completed" || dataType == "response.incomplete" {
+		finishReason := stopreason.FromResponsesObject(rootResult.Get("response"))
+		if finishReason == stopreason.Stop && (*param).(*ConvertCliToOpenAIParams).FunctionCallIndex != -1 {
+			finishReason = stopreason.ToolUse
 		}
-		template, _ = sjson.Set(template, "choices.0.finish_reason", finishReason)
-		template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReason)
+		template, _ = sjson.Set(template, "choices.0.finish_reason", stopreason.ToOpenAI(finishReason))
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", stopreason.ToOpenAI(finishReason))
 	} else if dataType == "response.output_item.added" {
 		itemResult := rootResult.Get("item")
 		if !itemResult.Exists() || itemResult.Get("type").String() != "function_call" {
@@ -236,8 +237,8 @@ func ConvertCodexResponseToOpenAI(_ context.Context, modelName string, originalR
 //   - string: An OpenAI-compatible JSON response containing all message content and metadata
 func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
 	rootResult := gjson.ParseBytes(rawJSON)
-	// Verify this is a response.completed event
-	if rootResult.Get("type").String() != "response.completed" {
+	// Verify this is a terminal response event
+	if typ := rootResult.Get("type").String(); typ != "response.completed" && typ != "response.incomplete" {
 		return ""
 	}
 
@@ -365,11 +366,12 @@ func ConvertCodexResponseToOpenAINonStream(_ context.Context, _ string, original
 
 	// Extract and set the finish reason based on status
 	if statusResult := responseResult.Get("status"); statusResult.Exists() {
-		status := statusResult.String()
-		if status == "completed" {
-			template, _ = sjson.Set(template, "choices.0.finish_reason", "stop")
-			template, _ = sjson.Set(template, "choices.0.native_finish_reason", "stop")
+		finishReason := stopreason.FromResponsesObject(responseResult)
+		if finishReason == stopreason.Stop && gjson.Get(template, "choices.0.message.tool_calls").IsArray() {
+			finishReason = stopreason.ToolUse
 		}
+		template, _ = sjson.Set(template, "choices.0.finish_reason", stopreason.ToOpenAI(finishReason))
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", stopreason.ToOpenAI(finishReason))
 	}
 
 	return template
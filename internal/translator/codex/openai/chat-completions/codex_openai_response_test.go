@@ -45,3 +45,22 @@ func TestConvertCodexResponseToOpenAI_FirstChunkUsesRequestModelName(t *testing.
 		t.Fatalf("expected model %q, got %q", modelName, gotModel)
 	}
 }
+
+func TestConvertCodexResponseToOpenAI_IncompleteMapsToLength(t *testing.T) {
+	ctx := context.Background()
+	var param any
+
+	raw := []byte(`data: {"type":"response.incomplete","response":{"id":"resp_1","status":"incomplete","incomplete_details":{"reason":"max_output_tokens"}}}`)
+	out := ConvertCodexResponseToOpenAI(ctx, "gpt-5", nil, nil, raw, &param)
+	if len(out) != 1 {
+		t.Fatalf("expected 1 chunk, got %d", len(out))
+	}
+	if got := gjson.Get(out[0], "choices.0.finish_reason").String(); got != "length" {
+		t.Fatalf("finish_reason = %q, want length", got)
+	}
+
+	nonStream := ConvertCodexResponseToOpenAINonStream(ctx, "gpt-5", nil, nil, raw[len("data: "):], nil)
+	if got := gjson.Get(nonStream, "choices.0.finish_reason").String(); got != "length" {
+		t.Fatalf("non-stream finish_reason = %q, want length", got)
+	}
+}
@@ -8,6 +8,8 @@ package claude
 import (
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/documents"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
@@ -79,10 +81,18 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 			contentJSON := `{"role":"","parts":[]}`
 			contentJSON, _ = sjson.Set(contentJSON, "role", role)
 
+			// A thinking block signed by Gemini carries the thought signature
+			// of the function call that follows it.
+			pendingSignature := ""
 			contentsResult := messageResult.Get("content")
 			if contentsResult.IsArray() {
 				contentsResult.ForEach(func(_, contentResult gjson.Result) bool {
 					switch contentResult.Get("type").String() {
+					case "thinking":
+						if group, signature := thinking.SplitSignature(contentResult.Get("signature").String()); group == "gemini" && signature != "" {
+							pendingSignature = signature
+						}
+
 					case "text":
 						part := `{"text":""}`
 						part, _ = sjson.Set(part, "text", contentResult.Get("text").String())
@@ -94,7 +104,11 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 						argsResult := gjson.Parse(functionArgs)
 						if argsResult.IsObject() && gjson.Valid(functionArgs) {
 							part := `{"thoughtSignature":"","functionCall":{"name":"","args":{}}}`
-							part, _ = sjson.Set(part, "thoughtSignature", geminiCLIClaudeThoughtSignature)
+							signature := geminiCLIClaudeThoughtSignature
+							if pendingSignature != "" {
+								signature, pendingSignature = pendingSignature, ""
+							}
+							part, _ = sjson.Set(part, "thoughtSignature", signature)
 							part, _ = sjson.Set(part, "functionCall.name", functionName)
 							part, _ = sjson.SetRaw(part, "functionCall.args", functionArgs)
 							contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", part)
@@ -128,6 +142,11 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 								contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", part)
 							}
 						}
+
+					case "document":
+						if doc, ok := documents.FromClaude(contentResult); ok {
+							contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", doc.GeminiPart())
+						}
 					}
 					return true
 				})
@@ -235,6 +254,9 @@ func ConvertClaudeRequestToCLI(modelName string, inputRawJSON []byte, _ bool) []
 	if v := gjson.GetBytes(rawJSON, "top_k"); v.Exists() && v.Type == gjson.Number {
 		out, _ = sjson.Set(out, "request.generationConfig.topK", v.Num)
 	}
+	if v := gjson.GetBytes(rawJSON, "max_tokens"); v.Type == gjson.Number && v.Int() > 0 {
+		out, _ = sjson.Set(out, "request.generationConfig.maxOutputTokens", v.Int())
+	}
 
 	outBytes := []byte(out)
 	outBytes = common.AttachDefaultSafetySettings(outBytes, "request.safetySettings")
@@ -14,6 +14,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -189,11 +190,15 @@ func ConvertGeminiCLIResponseToClaude(_ context.Context, _ string, originalReque
 					(*param).(*Params).ResponseType = 0
 				}
 
-				// Special handling for thinking state transition
+				// Special handling for thinking state transition: seal the thinking
+				// block with the signature Gemini attached to the call, tagged so it
+				// is not mistaken for a Claude signature when sent back.
 				if (*param).(*Params).ResponseType == 2 {
-					// output = output + "event: content_block_delta\n"
-					// output = output + fmt.Sprintf(`data: {"type":"content_block_delta","index":%d,"delta":{"type":"signature_delta","signature":null}}`, (*param).(*Params).ResponseIndex)
-					// output = output + "\n\n\n"
+					if signature := partResult.Get("thoughtSignature").String(); signature != "" {
+						output = output + "event: content_block_delta\n"
+						data, _ := sjson.Set(fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"signature_delta","signature":""}}`, (*param).(*Params).ResponseIndex), "delta.signature", "gemini#"+signature)
+						output = output + fmt.Sprintf("data: %s\n\n\n", data)
+					}
 				}
 
 				// Close any other existing content block
@@ -242,12 +247,12 @@ func ConvertGeminiCLIResponseToClaude(_ context.Context, _ string, originalReque
 
 				// Create the message delta template with appropriate stop reason
 				template := `{"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`
+				stopReason := stopreason.Parse(gjson.GetBytes(rawJSON, "response.candidates.0.finishReason").String())
 				// Set tool_use stop reason if tools were used in this response
 				if usedTool {
-					template = `{"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`
-				} else if finish := gjson.GetBytes(rawJSON, "response.candidates.0.finishReason"); finish.Exists() && finish.String() == "MAX_TOKENS" {
-					template = `{"type":"message_delta","delta":{"stop_reason":"max_tokens","stop_sequence":null},"usage":{"input_tokens":0,"output_tokens":0}}`
+					stopReason = stopreason.ToolUse
 				}
+				template, _ = sjson.Set(template, "delta.stop_reason", stopreason.ToClaude(stopReason))
 
 				// Include thinking tokens in output token count if present
 				thoughtsTokenCount := usageResult.Get("thoughtsTokenCount").Int()
@@ -350,20 +355,9 @@ func ConvertGeminiCLIResponseToClaudeNonStream(_ context.Context, _ string, orig
 	flushThinking()
 	flushText()
 
-	stopReason := "end_turn"
+	stopReason := stopreason.ToClaude(stopreason.Parse(root.Get("response.candidates.0.finishReason").String()))
 	if hasToolCall {
-		stopReason = "tool_use"
-	} else {
-		if finish := root.Get("response.candidates.0.finishReason"); finish.Exists() {
-			switch finish.String() {
-			case "MAX_TOKENS":
-				stopReason = "max_tokens"
-			case "STOP", "FINISH_REASON_UNSPECIFIED", "UNKNOWN":
-				stopReason = "end_turn"
-			default:
-				stopReason = "end_turn"
-			}
-		}
+		stopReason = stopreason.ToClaude(stopreason.ToolUse)
 	}
 	out, _ = sjson.Set(out, "stop_reason", stopReason)
 
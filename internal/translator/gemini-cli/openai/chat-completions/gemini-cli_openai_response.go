@@ -13,7 +13,10 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logprobs"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/seed"
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/openai/chat-completions"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -60,6 +63,9 @@ func ConvertCliResponseToOpenAI(_ context.Context, _ string, originalRequestRawJ
 	// Extract and set the model version.
 	if modelVersionResult := gjson.GetBytes(rawJSON, "response.modelVersion"); modelVersionResult.Exists() {
 		template, _ = sjson.Set(template, "model", modelVersionResult.String())
+		if fingerprint := seed.Fingerprint(originalRequestRawJSON, modelVersionResult.String()); fingerprint != "" {
+			template, _ = sjson.Set(template, "system_fingerprint", fingerprint)
+		}
 	}
 
 	// Extract and set the creation timestamp.
@@ -114,6 +120,10 @@ func ConvertCliResponseToOpenAI(_ context.Context, _ string, originalRequestRawJ
 		}
 	}
 
+	if lp := logprobs.OpenAIFromGemini(gjson.GetBytes(rawJSON, "response.candidates.0.logprobsResult")); lp != "" {
+		template, _ = sjson.SetRaw(template, "choices.0.logprobs", lp)
+	}
+
 	// Process the main content part of the response.
 	partsResult := gjson.GetBytes(rawJSON, "response.candidates.0.content.parts")
 	hasFunctionCall := false
@@ -203,11 +213,8 @@ func ConvertCliResponseToOpenAI(_ context.Context, _ string, originalRequestRawJ
 		template, _ = sjson.Set(template, "choices.0.finish_reason", "tool_calls")
 		template, _ = sjson.Set(template, "choices.0.native_finish_reason", "tool_calls")
 	} else if finishReason != "" && (*param).(*convertCliResponseToOpenAIChatParams).FunctionIndex == 0 {
-		// Only pass through specific finish reasons
-		if finishReason == "max_tokens" || finishReason == "stop" {
-			template, _ = sjson.Set(template, "choices.0.finish_reason", finishReason)
-			template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReason)
-		}
+		template, _ = sjson.Set(template, "choices.0.finish_reason", stopreason.ToOpenAI(stopreason.Parse(finishReason)))
+		template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReason)
 	}
 
 	return []string{template}
@@ -9,7 +9,9 @@ import (
 	"bytes"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/documents"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -74,10 +76,18 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 			contentJSON := `{"role":"","parts":[]}`
 			contentJSON, _ = sjson.Set(contentJSON, "role", role)
 
+			// A thinking block signed by Gemini carries the thought signature
+			// of the function call that follows it.
+			pendingSignature := ""
 			contentsResult := messageResult.Get("content")
 			if contentsResult.IsArray() {
 				contentsResult.ForEach(func(_, contentResult gjson.Result) bool {
 					switch contentResult.Get("type").String() {
+					case "thinking":
+						if group, signature := thinking.SplitSignature(contentResult.Get("signature").String()); group == "gemini" && signature != "" {
+							pendingSignature = signature
+						}
+
 					case "text":
 						part := `{"text":""}`
 						part, _ = sjson.Set(part, "text", contentResult.Get("text").String())
@@ -94,7 +104,11 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						argsResult := gjson.Parse(functionArgs)
 						if argsResult.IsObject() && gjson.Valid(functionArgs) {
 							part := `{"thoughtSignature":"","functionCall":{"name":"","args":{}}}`
-							part, _ = sjson.Set(part, "thoughtSignature", geminiClaudeThoughtSignature)
+							signature := geminiClaudeThoughtSignature
+							if pendingSignature != "" {
+								signature, pendingSignature = pendingSignature, ""
+							}
+							part, _ = sjson.Set(part, "thoughtSignature", signature)
 							part, _ = sjson.Set(part, "functionCall.name", functionName)
 							part, _ = sjson.SetRaw(part, "functionCall.args", functionArgs)
 							contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", part)
@@ -129,6 +143,11 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 						part, _ = sjson.Set(part, "inline_data.mime_type", mimeType)
 						part, _ = sjson.Set(part, "inline_data.data", data)
 						contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", part)
+
+					case "document":
+						if doc, ok := documents.FromClaude(contentResult); ok {
+							contentJSON, _ = sjson.SetRaw(contentJSON, "parts.-1", doc.GeminiPart())
+						}
 					}
 					return true
 				})
@@ -243,6 +262,9 @@ func ConvertClaudeRequestToGemini(modelName string, inputRawJSON []byte, _ bool)
 	if v := gjson.GetBytes(rawJSON, "top_k"); v.Exists() && v.Type == gjson.Number {
 		out, _ = sjson.Set(out, "generationConfig.topK", v.Num)
 	}
+	if v := gjson.GetBytes(rawJSON, "max_tokens"); v.Type == gjson.Number && v.Int() > 0 {
+		out, _ = sjson.Set(out, "generationConfig.maxOutputTokens", v.Int())
+	}
 
 	result := []byte(out)
 	result = common.AttachDefaultSafetySettings(result, "safetySettings")
@@ -1,6 +1,8 @@
 package claude
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/tidwall/gjson"
@@ -78,3 +80,66 @@ func TestConvertClaudeRequestToGemini_ImageContent(t *testing.T) {
 		t.Fatalf("Expected image data 'aGVsbG8=', got '%s'", got)
 	}
 }
+
+func TestConvertClaudeRequestToGemini_DocumentContent(t *testing.T) {
+	inputJSON := []byte(`{
+		"model": "gemini-3-flash-preview",
+		"messages": [
+			{
+				"role": "user",
+				"content": [
+					{"type": "document", "source": {"type": "base64", "media_type": "application/pdf", "data": "JVBERi0="}},
+					{"type": "document", "source": {"type": "url", "url": "https://example.com/a.pdf"}},
+					{"type": "document", "source": {"type": "text", "media_type": "text/plain", "data": "notes"}}
+				]
+			}
+		]
+	}`)
+
+	output := ConvertClaudeRequestToGemini("gemini-3-flash-preview", inputJSON, false)
+
+	parts := gjson.GetBytes(output, "contents.0.parts").Array()
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 parts, got %d", len(parts))
+	}
+	if parts[0].Get("inlineData.mimeType").String() != "application/pdf" || parts[0].Get("inlineData.data").String() != "JVBERi0=" {
+		t.Fatalf("Unexpected base64 document part: %s", parts[0].Raw)
+	}
+	if parts[1].Get("fileData.fileUri").String() != "https://example.com/a.pdf" || parts[1].Get("fileData.mimeType").String() != "application/pdf" {
+		t.Fatalf("Unexpected URL document part: %s", parts[1].Raw)
+	}
+	if got := parts[2].Get("text").String(); got != "notes" {
+		t.Fatalf("Expected text document as text part, got %s", parts[2].Raw)
+	}
+}
+
+func TestGeminiThoughtSignatureRoundTrip(t *testing.T) {
+	var param any
+	chunk := []byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"Check the directory.","thought":true}]}}]}`)
+	ConvertGeminiResponseToClaude(context.Background(), "", nil, nil, chunk, &param)
+	chunk = []byte(`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"ls","args":{}},"thoughtSignature":"native-sig"}]}}]}`)
+	out := strings.Join(ConvertGeminiResponseToClaude(context.Background(), "", nil, nil, chunk, &param), "")
+	if !strings.Contains(out, `"signature":"gemini#native-sig"`) {
+		t.Fatalf("thinking block not sealed with the tagged signature: %s", out)
+	}
+
+	inputJSON := []byte(`{"model":"gemini-3-flash-preview","messages":[
+		{"role":"user","content":[{"type":"text","text":"list"}]},
+		{"role":"assistant","content":[
+			{"type":"thinking","thinking":"Check the directory.","signature":"gemini#native-sig"},
+			{"type":"tool_use","id":"ls-1","name":"ls","input":{}},
+			{"type":"tool_use","id":"ls-2","name":"ls","input":{}}
+		]}
+	]}`)
+	output := ConvertClaudeRequestToGemini("gemini-3-flash-preview", inputJSON, false)
+	parts := gjson.GetBytes(output, "contents.1.parts").Array()
+	if len(parts) != 2 {
+		t.Fatalf("model parts = %s", gjson.GetBytes(output, "contents.1.parts").Raw)
+	}
+	if got := parts[0].Get("thoughtSignature").String(); got != "native-sig" {
+		t.Fatalf("first call thoughtSignature = %q, want native-sig", got)
+	}
+	if got := parts[1].Get("thoughtSignature").String(); got != geminiClaudeThoughtSignature {
+		t.Fatalf("second call thoughtSignature = %q", got)
+	}
+}
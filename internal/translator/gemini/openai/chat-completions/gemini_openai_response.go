@@ -13,6 +13,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logprobs"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/seed"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -72,6 +75,9 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 	// Extract and set the model version.
 	if modelVersionResult := gjson.GetBytes(rawJSON, "modelVersion"); modelVersionResult.Exists() {
 		baseTemplate, _ = sjson.Set(baseTemplate, "model", modelVersionResult.String())
+		if fingerprint := seed.Fingerprint(originalRequestRawJSON, modelVersionResult.String()); fingerprint != "" {
+			baseTemplate, _ = sjson.Set(baseTemplate, "system_fingerprint", fingerprint)
+		}
 	}
 
 	// Extract and set the creation timestamp.
@@ -128,17 +134,11 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 			// Set the specific index for this candidate.
 			candidateIndex := int(candidate.Get("index").Int())
 			template, _ = sjson.Set(template, "choices.0.index", candidateIndex)
-
-			finishReason := ""
-			if stopReasonResult := gjson.GetBytes(rawJSON, "stop_reason"); stopReasonResult.Exists() {
-				finishReason = stopReasonResult.String()
-			}
-			if finishReason == "" {
-				if finishReasonResult := gjson.GetBytes(rawJSON, "candidates.0.finishReason"); finishReasonResult.Exists() {
-					finishReason = finishReasonResult.String()
-				}
+			if lp := logprobs.OpenAIFromGemini(candidate.Get("logprobsResult")); lp != "" {
+				template, _ = sjson.SetRaw(template, "choices.0.logprobs", lp)
 			}
-			finishReason = strings.ToLower(finishReason)
+
+			finishReason := nativeFinishReason(rawJSON, candidate)
 
 			partsResult := candidate.Get("content.parts")
 			hasFunctionCall := false
@@ -231,11 +231,8 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 				template, _ = sjson.Set(template, "choices.0.finish_reason", "tool_calls")
 				template, _ = sjson.Set(template, "choices.0.native_finish_reason", "tool_calls")
 			} else if finishReason != "" {
-				// Only pass through specific finish reasons
-				if finishReason == "max_tokens" || finishReason == "stop" {
-					template, _ = sjson.Set(template, "choices.0.finish_reason", finishReason)
-					template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReason)
-				}
+				template, _ = sjson.Set(template, "choices.0.finish_reason", stopreason.ToOpenAI(stopreason.Parse(finishReason)))
+				template, _ = sjson.Set(template, "choices.0.native_finish_reason", finishReason)
 			}
 
 			responseStrings = append(responseStrings, template)
@@ -251,6 +248,18 @@ func ConvertGeminiResponseToOpenAI(_ context.Context, _ string, originalRequestR
 	return responseStrings
 }
 
+// nativeFinishReason returns the upstream finish reason of candidate, as
+// reported in native_finish_reason by both the streaming and non-streaming
+// conversions: the response's stop_reason when set, otherwise the candidate's
+// finishReason, lower-cased.
+func nativeFinishReason(rawJSON []byte, candidate gjson.Result) string {
+	reason := gjson.GetBytes(rawJSON, "stop_reason").String()
+	if reason == "" {
+		reason = candidate.Get("finishReason").String()
+	}
+	return strings.ToLower(strings.TrimSpace(reason))
+}
+
 // ConvertGeminiResponseToOpenAINonStream converts a non-streaming Gemini response to a non-streaming OpenAI response.
 // This function processes the complete Gemini response and transforms it into a single OpenAI-compatible
 // JSON response. It handles message content, tool calls, reasoning content, and usage metadata, combining all
@@ -271,6 +280,9 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 
 	if modelVersionResult := gjson.GetBytes(rawJSON, "modelVersion"); modelVersionResult.Exists() {
 		template, _ = sjson.Set(template, "model", modelVersionResult.String())
+		if fingerprint := seed.Fingerprint(originalRequestRawJSON, modelVersionResult.String()); fingerprint != "" {
+			template, _ = sjson.Set(template, "system_fingerprint", fingerprint)
+		}
 	}
 
 	if createTimeResult := gjson.GetBytes(rawJSON, "createTime"); createTimeResult.Exists() {
@@ -320,11 +332,14 @@ func ConvertGeminiResponseToOpenAINonStream(_ context.Context, _ string, origina
 
 			// Set the index for this choice.
 			choiceTemplate, _ = sjson.Set(choiceTemplate, "index", candidate.Get("index").Int())
+			if lp := logprobs.OpenAIFromGemini(candidate.Get("logprobsResult")); lp != "" {
+				choiceTemplate, _ = sjson.SetRaw(choiceTemplate, "logprobs", lp)
+			}
 
 			// Set finish reason.
-			if finishReasonResult := candidate.Get("finishReason"); finishReasonResult.Exists() {
-				choiceTemplate, _ = sjson.Set(choiceTemplate, "finish_reason", strings.ToLower(finishReasonResult.String()))
-				choiceTemplate, _ = sjson.Set(choiceTemplate, "native_finish_reason", strings.ToLower(finishReasonResult.String()))
+			if finishReason := nativeFinishReason(rawJSON, candidate); finishReason != "" {
+				choiceTemplate, _ = sjson.Set(choiceTemplate, "finish_reason", stopreason.ToOpenAI(stopreason.Parse(finishReason)))
+				choiceTemplate, _ = sjson.Set(choiceTemplate, "native_finish_reason", finishReason)
 			}
 
 			partsResult := candidate.Get("content.parts")
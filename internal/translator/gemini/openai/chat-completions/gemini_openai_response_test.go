@@ -0,0 +1,28 @@
+package chat_completions
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestNativeFinishReasonMatchesAcrossStreamAndNonStream(t *testing.T) {
+	raw := []byte(`{"candidates":[{"index":0,"content":{"parts":[{"text":"a"}]},"finishReason":"STOP"},{"index":1,"content":{"parts":[{"text":"b"}]},"finishReason":"MAX_TOKENS"}]}`)
+
+	var param any
+	chunks := ConvertGeminiResponseToOpenAI(context.Background(), "model", nil, nil, raw, &param)
+	nonStream := ConvertGeminiResponseToOpenAINonStream(context.Background(), "model", nil, nil, raw, nil)
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	for i, want := range []string{"stop", "max_tokens"} {
+		if got := gjson.Get(chunks[i], "choices.0.native_finish_reason").String(); got != want {
+			t.Errorf("stream candidate %d native_finish_reason = %q, want %q", i, got, want)
+		}
+		if got := gjson.Get(nonStream, "choices."+strconv.Itoa(i)+".native_finish_reason").String(); got != want {
+			t.Errorf("non-stream candidate %d native_finish_reason = %q, want %q", i, got, want)
+		}
+	}
+}
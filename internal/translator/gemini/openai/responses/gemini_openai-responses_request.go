@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/documents"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logprobs"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/gemini/common"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -265,6 +267,10 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 								partJSON, _ = sjson.Set(partJSON, "inline_data.mime_type", mimeType)
 								partJSON, _ = sjson.Set(partJSON, "inline_data.data", audioData)
 							}
+						case "input_file":
+							if doc, ok := documents.FromOpenAI(contentItem); ok {
+								partJSON = doc.GeminiPart()
+							}
 						}
 
 						if partJSON != "" {
@@ -403,6 +409,10 @@ func ConvertOpenAIResponsesRequestToGemini(modelName string, inputRawJSON []byte
 		out, _ = sjson.SetRaw(out, "generationConfig", genConfig)
 	}
 
+	if enabled, top := logprobs.FromResponses(root); enabled {
+		out = string(logprobs.SetGemini([]byte(out), "generationConfig", top))
+	}
+
 	// Handle temperature if present
 	if temperature := root.Get("temperature"); temperature.Exists() {
 		if !gjson.Get(out, "generationConfig").Exists() {
@@ -8,6 +8,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logprobs"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -224,6 +226,9 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 		st.NextIndex = 0
 	}
 
+	// Token logprobs arrive per chunk; attach them to the chunk's first text delta.
+	chunkLogprobs := logprobs.OpenAIFromGemini(root.Get("candidates.0.logprobsResult"))
+
 	// Handle parts (text/thought/functionCall)
 	if parts := root.Get("candidates.0.content.parts"); parts.Exists() && parts.IsArray() {
 		parts.ForEach(func(_, part gjson.Result) bool {
@@ -295,6 +300,10 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 				msg, _ = sjson.Set(msg, "item_id", st.CurrentMsgID)
 				msg, _ = sjson.Set(msg, "output_index", st.MsgIndex)
 				msg, _ = sjson.Set(msg, "delta", t.String())
+				if chunkLogprobs != "" {
+					msg, _ = sjson.SetRaw(msg, "logprobs", gjson.Get(chunkLogprobs, "content").Raw)
+					chunkLogprobs = ""
+				}
 				out = append(out, emitEvent("response.output_text.delta", msg))
 				return true
 			}
@@ -428,6 +437,13 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 		completed, _ = sjson.Set(completed, "sequence_number", nextSeq())
 		completed, _ = sjson.Set(completed, "response.id", st.ResponseID)
 		completed, _ = sjson.Set(completed, "response.created_at", st.CreatedAt)
+		completedEvent := "response.completed"
+		if status, incompleteReason := stopreason.ToResponses(stopreason.Parse(fr.String())); status != "completed" {
+			completedEvent = "response." + status
+			completed, _ = sjson.Set(completed, "type", completedEvent)
+			completed, _ = sjson.Set(completed, "response.status", status)
+			completed, _ = sjson.Set(completed, "response.incomplete_details.reason", incompleteReason)
+		}
 
 		if reqJSON := pickRequestJSON(originalRequestRawJSON, requestRawJSON); len(reqJSON) > 0 {
 			req := unwrapRequestRoot(gjson.ParseBytes(reqJSON))
@@ -554,7 +570,7 @@ func ConvertGeminiResponseToOpenAIResponses(_ context.Context, modelName string,
 			}
 		}
 
-		out = append(out, emitEvent("response.completed", completed))
+		out = append(out, emitEvent(completedEvent, completed))
 	}
 
 	return out
@@ -588,6 +604,11 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 	}
 	resp, _ = sjson.Set(resp, "created_at", createdAt)
 
+	if status, incompleteReason := stopreason.ToResponses(stopreason.Parse(root.Get("candidates.0.finishReason").String())); status != "completed" {
+		resp, _ = sjson.Set(resp, "status", status)
+		resp, _ = sjson.Set(resp, "incomplete_details.reason", incompleteReason)
+	}
+
 	// Echo request fields when present; fallback model from response modelVersion
 	if reqJSON := pickRequestJSON(originalRequestRawJSON, requestRawJSON); len(reqJSON) > 0 {
 		req := unwrapRequestRoot(gjson.ParseBytes(reqJSON))
@@ -732,6 +753,9 @@ func ConvertGeminiResponseToOpenAIResponsesNonStream(_ context.Context, _ string
 		itemJSON := `{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`
 		itemJSON, _ = sjson.Set(itemJSON, "id", fmt.Sprintf("msg_%s_0", strings.TrimPrefix(id, "resp_")))
 		itemJSON, _ = sjson.Set(itemJSON, "content.0.text", messageText.String())
+		if lp := logprobs.OpenAIFromGemini(root.Get("candidates.0.logprobsResult")); lp != "" {
+			itemJSON, _ = sjson.SetRaw(itemJSON, "content.0.logprobs", gjson.Get(lp, "content").Raw)
+		}
 		appendOutput(itemJSON)
 	}
 
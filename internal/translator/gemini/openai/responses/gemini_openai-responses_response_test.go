@@ -351,3 +351,28 @@ func TestConvertGeminiResponseToOpenAIResponses_ResponseOutputOrdering(t *testin
 		t.Fatalf("expected response.completed after message added: msgAdded=%d completed=%d", posMsgAdded, posCompleted)
 	}
 }
+
+func TestConvertGeminiResponseToOpenAIResponses_MaxTokensIsIncomplete(t *testing.T) {
+	raw := []byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"truncated"}]},"finishReason":"MAX_TOKENS"}],"usageMetadata":{"promptTokenCount":1,"candidatesTokenCount":1,"totalTokenCount":2}}`)
+
+	var param any
+	var event string
+	var completed gjson.Result
+	for _, chunk := range ConvertGeminiResponseToOpenAIResponses(context.Background(), "gemini-2.5-pro", nil, nil, raw, &param) {
+		name, data := parseSSEEvent(t, chunk)
+		if name == "response.completed" || name == "response.incomplete" {
+			event, completed = name, data
+		}
+	}
+	if event != "response.incomplete" {
+		t.Fatalf("final event = %q, want response.incomplete", event)
+	}
+	if got := completed.Get("response.incomplete_details.reason").String(); got != "max_output_tokens" {
+		t.Fatalf("incomplete reason = %q", got)
+	}
+
+	nonStream := gjson.Parse(ConvertGeminiResponseToOpenAIResponsesNonStream(context.Background(), "gemini-2.5-pro", nil, nil, raw, nil))
+	if got := nonStream.Get("status").String(); got != "incomplete" {
+		t.Fatalf("non-stream status = %q", got)
+	}
+}
@@ -0,0 +1,216 @@
+// Package golden records upstream exchanges as golden files and replays them
+// through the translators, so the request and streaming converters can be
+// refactored against real traffic. Files are produced by the gen-golden
+// subcommand and checked by this package's tests; run
+// `go test ./internal/translator/golden -update` to accept intended changes.
+package golden
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/sjson"
+)
+
+// Case is one recorded exchange. Request is the sanitized client payload and
+// UpstreamResponse the raw upstream body; UpstreamRequest and Response are the
+// translator outputs expected for them. Stream selects both the request
+// translation and how the body is fed back, as one SSE payload at a time.
+type Case struct {
+	Name             string          `json:"name"`
+	From             string          `json:"from"`
+	To               string          `json:"to"`
+	Model            string          `json:"model"`
+	Stream           bool            `json:"stream"`
+	Request          json.RawMessage `json:"request"`
+	UpstreamRequest  json.RawMessage `json:"upstream_request"`
+	UpstreamResponse string          `json:"upstream_response"`
+	Response         []string        `json:"response"`
+}
+
+// sensitiveFields identify a user, account or cache partition and are dropped
+// from recorded requests. Paths are relative to the payload root.
+var sensitiveFields = []string{
+	"user",
+	"metadata.user_id",
+	"safety_identifier",
+	"prompt_cache_key",
+	"session_id",
+	"request.sessionId",
+	"user_prompt_id",
+}
+
+// volatileFields are generated per response, so their values are masked
+// before outputs are compared.
+var volatileFields = map[string]bool{
+	"id":         true,
+	"call_id":    true,
+	"item_id":    true,
+	"created":    true,
+	"created_at": true,
+}
+
+// Sanitize removes identifying fields from a client or upstream request.
+func Sanitize(payload []byte) []byte {
+	out := bytes.Clone(payload)
+	for _, path := range sensitiveFields {
+		out, _ = sjson.DeleteBytes(out, path)
+	}
+	return out
+}
+
+// NewCase sanitizes request and computes the upstream request the server
+// would send for it, which is what gen-golden replays against the upstream.
+func NewCase(name, from, to, model string, stream bool, request []byte) (*Case, error) {
+	c := &Case{Name: name, From: from, To: to, Model: model, Stream: stream, Request: Sanitize(request)}
+	upstream, err := c.translateRequest()
+	if err != nil {
+		return nil, err
+	}
+	c.UpstreamRequest = upstream
+	return c, nil
+}
+
+// SetUpstreamResponse records body and the client response the translators
+// currently produce for it.
+func (c *Case) SetUpstreamResponse(body []byte) {
+	c.UpstreamResponse = string(body)
+	c.Response = c.translateResponse()
+}
+
+// Replay runs the case through the current translators and returns the
+// upstream request and the normalized client response.
+func (c *Case) Replay() (json.RawMessage, []string, error) {
+	upstream, err := c.translateRequest()
+	if err != nil {
+		return nil, nil, err
+	}
+	return upstream, c.translateResponse(), nil
+}
+
+func (c *Case) translateRequest() (json.RawMessage, error) {
+	upstream, err := executor.TranslatePayload(nil, c.From, c.To, "", c.Model, bytes.Clone(c.Request), c.Stream)
+	if err != nil {
+		return nil, err
+	}
+	// Translators may stamp per-request identifiers such as Claude's
+	// metadata.user_id, which would never replay identically.
+	var compact bytes.Buffer
+	if errCompact := json.Compact(&compact, Sanitize(upstream)); errCompact != nil {
+		return nil, fmt.Errorf("translated request is not valid JSON: %w", errCompact)
+	}
+	return compact.Bytes(), nil
+}
+
+func (c *Case) translateResponse() []string {
+	ctx := context.Background()
+	from := sdktranslator.FromString(c.From)
+	to := sdktranslator.FromString(c.To)
+	var param any
+	if !c.Stream {
+		out := sdktranslator.TranslateNonStream(ctx, to, from, c.Model, c.Request, c.UpstreamRequest, []byte(c.UpstreamResponse), &param)
+		return []string{Normalize(out)}
+	}
+	response := []string{}
+	for _, payload := range executor.StreamPayloads(c.To, []byte(c.UpstreamResponse)) {
+		for _, chunk := range sdktranslator.TranslateStream(ctx, to, from, c.Model, c.Request, c.UpstreamRequest, payload, &param) {
+			response = append(response, Normalize(chunk))
+		}
+	}
+	return response
+}
+
+// Normalize masks volatile fields in every JSON line of a translated chunk,
+// with or without an SSE "data:" prefix; other lines are kept verbatim.
+func Normalize(chunk string) string {
+	lines := strings.Split(chunk, "\n")
+	for i, line := range lines {
+		prefix, body := "", line
+		if rest, ok := strings.CutPrefix(line, "data:"); ok {
+			prefix, body = "data: ", strings.TrimSpace(rest)
+		}
+		if !strings.HasPrefix(body, "{") {
+			continue
+		}
+		decoder := json.NewDecoder(strings.NewReader(body))
+		decoder.UseNumber()
+		var value any
+		if errDecode := decoder.Decode(&value); errDecode != nil {
+			continue
+		}
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if errEncode := encoder.Encode(mask(value)); errEncode != nil {
+			continue
+		}
+		lines[i] = prefix + strings.TrimSuffix(buf.String(), "\n")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func mask(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if !volatileFields[key] {
+				v[key] = mask(field)
+				continue
+			}
+			switch field.(type) {
+			case string:
+				v[key] = "<" + key + ">"
+			case json.Number:
+				v[key] = json.Number("0")
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = mask(v[i])
+		}
+	}
+	return value
+}
+
+// Load reads every golden file in dir, ordered by file name.
+func Load(dir string) ([]*Case, []string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(paths)
+	cases := make([]*Case, 0, len(paths))
+	for _, path := range paths {
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			return nil, nil, errRead
+		}
+		var c Case
+		if errUnmarshal := json.Unmarshal(data, &c); errUnmarshal != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, errUnmarshal)
+		}
+		cases = append(cases, &c)
+	}
+	return cases, paths, nil
+}
+
+// Write stores c at path as indented JSON.
+func Write(path string, c *Case) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
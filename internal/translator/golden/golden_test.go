@@ -0,0 +1,59 @@
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files from the current translators")
+
+func TestGoldenFiles(t *testing.T) {
+	cases, paths, err := Load("testdata")
+	if err != nil {
+		t.Fatalf("load golden files: %v", err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("no golden files in testdata")
+	}
+	for i, c := range cases {
+		path := paths[i]
+		t.Run(c.Name, func(t *testing.T) {
+			upstream, response, errReplay := c.Replay()
+			if errReplay != nil {
+				t.Fatalf("replay: %v", errReplay)
+			}
+			if *update {
+				c.UpstreamRequest, c.Response = upstream, response
+				if errWrite := Write(path, c); errWrite != nil {
+					t.Fatalf("write %s: %v", path, errWrite)
+				}
+				return
+			}
+			var want bytes.Buffer
+			if errCompact := json.Compact(&want, c.UpstreamRequest); errCompact != nil {
+				t.Fatalf("golden upstream_request: %v", errCompact)
+			}
+			if !bytes.Equal(upstream, want.Bytes()) {
+				t.Errorf("upstream request changed:\n got %s\nwant %s", upstream, want.Bytes())
+			}
+			if !reflect.DeepEqual(response, c.Response) {
+				t.Errorf("response changed:\n got %q\nwant %q", response, c.Response)
+			}
+		})
+	}
+}
+
+func TestSanitizeAndNormalize(t *testing.T) {
+	got := string(Sanitize([]byte(`{"model":"m","user":"alice","metadata":{"user_id":"u-1","tag":"x"}}`)))
+	if got != `{"model":"m","metadata":{"tag":"x"}}` {
+		t.Fatalf("Sanitize = %s", got)
+	}
+	chunk := "event: message_start\ndata: {\"id\":\"msg_123\",\"created\":1700000000,\"text\":\"<b>\",\"tool_calls\":[{\"id\":\"call_9\"}]}"
+	want := "event: message_start\ndata: {\"created\":0,\"id\":\"<id>\",\"text\":\"<b>\",\"tool_calls\":[{\"id\":\"<id>\"}]}"
+	if got = Normalize(chunk); got != want {
+		t.Fatalf("Normalize = %q", got)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	log "github.com/sirupsen/logrus"
 )
@@ -95,22 +96,13 @@ func BuildOpenAIResponseWithReasoning(content, reasoningContent string, toolUses
 	return result
 }
 
-// mapKiroStopReasonToOpenAI converts Kiro/Claude stop_reason to OpenAI finish_reason
+// mapKiroStopReasonToOpenAI converts Kiro/Claude stop_reason to OpenAI finish_reason.
+// An empty stop_reason stays empty so callers can apply their own fallback.
 func mapKiroStopReasonToOpenAI(stopReason string) string {
-	switch stopReason {
-	case "end_turn":
-		return "stop"
-	case "stop_sequence":
-		return "stop"
-	case "tool_use":
-		return "tool_calls"
-	case "max_tokens":
-		return "length"
-	case "content_filtered":
-		return "content_filter"
-	default:
-		return stopReason
+	if stopReason == "" {
+		return ""
 	}
+	return stopreason.ToOpenAI(stopreason.Parse(stopReason))
 }
 
 // BuildOpenAIStreamChunk constructs an OpenAI Chat Completions streaming chunk.
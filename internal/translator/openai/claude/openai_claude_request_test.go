@@ -696,3 +696,31 @@ func TestConvertClaudeRequestToOpenAI_AssistantThinkingToolUseThinkingSplit(t *t
 		t.Fatalf("Expected reasoning_content %q, got %q", "t1\n\nt2", got)
 	}
 }
+
+func TestConvertClaudeRequestToOpenAI_DocumentContent(t *testing.T) {
+	inputJSON := []byte(`{
+		"model": "gpt-4.1",
+		"messages": [
+			{
+				"role": "user",
+				"content": [
+					{"type": "text", "text": "summarise"},
+					{"type": "document", "title": "report.pdf", "source": {"type": "base64", "media_type": "application/pdf", "data": "JVBERi0="}}
+				]
+			}
+		]
+	}`)
+
+	result := ConvertClaudeRequestToOpenAI("gpt-4.1", inputJSON, false)
+
+	file := gjson.GetBytes(result, "messages.0.content.1")
+	if file.Get("type").String() != "file" {
+		t.Fatalf("Expected file part, got %s", file.Raw)
+	}
+	if got := file.Get("file.filename").String(); got != "report.pdf" {
+		t.Fatalf("Expected filename report.pdf, got %q", got)
+	}
+	if got := file.Get("file.file_data").String(); got != "data:application/pdf;base64,JVBERi0=" {
+		t.Fatalf("Unexpected file data %q", got)
+	}
+}
@@ -95,3 +95,15 @@ func TestConvertOpenAIResponseToClaude_NonStream_ReasoningText(t *testing.T) {
 		t.Fatalf("expected text content in non-stream output, got: %s", joined)
 	}
 }
+
+func TestConvertOpenAIResponseToClaude_NonStream_ContentFilterIsRefusal(t *testing.T) {
+	t.Parallel()
+
+	var param any
+	chunk := []byte(`{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":""},"finish_reason":"content_filter"}],"model":"gpt-4o"}`)
+
+	out := ConvertOpenAIResponseToClaudeNonStream(context.Background(), "gpt-4o", []byte(`{"stream":false}`), nil, chunk, &param)
+	if !strings.Contains(out, `"stop_reason":"refusal"`) {
+		t.Fatalf("expected refusal stop_reason, got: %s", out)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"math/big"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/documents"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -53,6 +54,19 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 			out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
 		}
 
+		// Logprobs
+		if genConfig.Get("responseLogprobs").Bool() {
+			out, _ = sjson.Set(out, "logprobs", true)
+			if top := genConfig.Get("logprobs").Int(); top > 0 {
+				out, _ = sjson.Set(out, "top_logprobs", top)
+			}
+		}
+
+		// Seed
+		if seed := genConfig.Get("seed"); seed.Type == gjson.Number {
+			out, _ = sjson.Set(out, "seed", seed.Int())
+		}
+
 		// Top P
 		if topP := genConfig.Get("topP"); topP.Exists() {
 			out, _ = sjson.Set(out, "top_p", topP.Float())
@@ -196,6 +210,14 @@ func ConvertGeminiRequestToOpenAI(modelName string, inputRawJSON []byte, stream
 						contentPartsCount++
 					}
 
+					// Handle documents (e.g., PDFs)
+					if doc, ok := documents.FromGemini(part); ok {
+						onlyTextContent = false
+						contentWrapper, _ = sjson.SetRaw(contentWrapper, "arr.-1", doc.OpenAIPart())
+						contentPartsCount++
+						return true
+					}
+
 					// Handle inline data (e.g., images)
 					if inlineData := part.Get("inlineData"); inlineData.Exists() {
 						onlyTextContent = false
@@ -12,6 +12,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logprobs"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -170,6 +172,9 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 				// Create text part for this delta
 				contentTemplate := baseTemplate
 				contentTemplate, _ = sjson.Set(contentTemplate, "candidates.0.content.parts.0.text", contentText)
+				if lp := logprobs.GeminiFromOpenAI(choice.Get("logprobs.content")); lp != "" {
+					contentTemplate, _ = sjson.SetRaw(contentTemplate, "candidates.0.logprobsResult", lp)
+				}
 				chunkOutputs = append(chunkOutputs, contentTemplate)
 			}
 
@@ -347,18 +352,7 @@ func ConvertOpenAIResponseToGemini(_ context.Context, _ string, originalRequestR
 
 // mapOpenAIFinishReasonToGemini maps OpenAI finish reasons to Gemini finish reasons
 func mapOpenAIFinishReasonToGemini(openAIReason string) string {
-	switch openAIReason {
-	case "stop":
-		return "STOP"
-	case "length":
-		return "MAX_TOKENS"
-	case "tool_calls":
-		return "STOP" // Gemini doesn't have a specific tool_calls finish reason
-	case "content_filter":
-		return "SAFETY"
-	default:
-		return "STOP"
-	}
+	return stopreason.ToGemini(stopreason.Parse(openAIReason))
 }
 
 // parseArgsToObjectRaw safely parses a JSON string of function arguments into an object JSON string.
@@ -698,6 +692,10 @@ func ConvertOpenAIResponseToGeminiNonStream(_ context.Context, _ string, origina
 				out, _ = sjson.Set(out, "candidates.0.finishReason", geminiFinishReason)
 			}
 
+			if lp := logprobs.GeminiFromOpenAI(choice.Get("logprobs.content")); lp != "" {
+				out, _ = sjson.SetRaw(out, "candidates.0.logprobsResult", lp)
+			}
+
 			// Set index
 			out, _ = sjson.Set(out, "candidates.0.index", choiceIdx)
 
@@ -222,3 +222,17 @@ func TestCollectReasoningTextsFromFields(t *testing.T) {
 		}
 	})
 }
+
+func TestConvertOpenAIResponseToGemini_NonStreamLogprobs(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`{"id":"c1","created":1,"model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"logprobs":{"content":[{"token":"Hi","logprob":-0.1,"bytes":[72,105],"top_logprobs":[{"token":"Hi","logprob":-0.1},{"token":"Hey","logprob":-2.3}]}]},"finish_reason":"stop"}]}`)
+
+	out := ConvertOpenAIResponseToGeminiNonStream(context.Background(), "m", nil, nil, raw, nil)
+	if got := gjson.Get(out, "candidates.0.logprobsResult.chosenCandidates.0.token").String(); got != "Hi" {
+		t.Fatalf("chosen token = %q, output: %s", got, out)
+	}
+	if got := gjson.Get(out, "candidates.0.logprobsResult.topCandidates.0.candidates.1.logProbability").Float(); got != -2.3 {
+		t.Fatalf("alternative logprob = %v", got)
+	}
+}
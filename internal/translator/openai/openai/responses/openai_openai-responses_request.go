@@ -3,6 +3,7 @@ package responses
 import (
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logprobs"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -44,6 +45,13 @@ func ConvertOpenAIResponsesRequestToOpenAIChatCompletions(modelName string, inpu
 		out, _ = sjson.Set(out, "max_tokens", maxTokens.Int())
 	}
 
+	if enabled, top := logprobs.FromResponses(root); enabled {
+		out, _ = sjson.Set(out, "logprobs", true)
+		if top > 0 {
+			out, _ = sjson.Set(out, "top_logprobs", top)
+		}
+	}
+
 	if parallelToolCalls := root.Get("parallel_tool_calls"); parallelToolCalls.Exists() {
 		out, _ = sjson.Set(out, "parallel_tool_calls", parallelToolCalls.Bool())
 	}
@@ -4,48 +4,56 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logprobs"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
-type oaiToResponsesStateReasoning struct {
-	ReasoningID   string
-	ReasoningData string
+// oaiToResponsesItem is one output item of the streamed response, assembled
+// from chat-completions deltas between its added and done events.
+type oaiToResponsesItem struct {
+	Type        string // "message", "reasoning" or "function_call"
+	ID          string
+	OutputIndex int
+	// Buf aggregates the message text, the reasoning summary or the call arguments.
+	Buf       strings.Builder
+	Encrypted string
+	CallID    string
+	Name      string
+	// SyntheticCallID is set when the upstream streamed the call without an ID.
+	SyntheticCallID bool
+	// Done holds the item as sent in response.output_item.done, once closed.
+	Done string
 }
-type oaiToResponsesCompletedFunc struct {
-	CallID string
-	Name   string
-	Args   string
+
+// oaiToResponsesChoice tracks the items a chat choice has open. A choice has
+// at most one open message and one open reasoning item at a time; opening one
+// kind closes the others, so the client sees each item's full lifecycle before
+// the next begins.
+type oaiToResponsesChoice struct {
+	Message   *oaiToResponsesItem
+	Reasoning *oaiToResponsesItem
+	Funcs     map[int]*oaiToResponsesItem // tool_call index -> item
+	Finished  bool
 }
+
 type oaiToResponsesState struct {
-	Seq            int
-	ResponseID     string
-	Created        int64
-	Started        bool
-	ReasoningID    string
-	ReasoningIndex int
-	// aggregation buffers for response.output
-	// Per-output message text buffers by index
-	MsgTextBuf   map[int]*strings.Builder
-	ReasoningBuf strings.Builder
-	Reasonings   []oaiToResponsesStateReasoning
-	FuncArgsBuf  map[int]*strings.Builder // index -> args
-	FuncNames    map[int]string           // index -> name
-	FuncCallIDs  map[int]string           // index -> call_id
-	// CompletedFuncs stores tool calls that were flushed when a new ID appeared at the same index.
-	// This handles Copilot Gemini models which send multiple complete tool calls all with index=0.
-	CompletedFuncs []oaiToResponsesCompletedFunc
-	// message item state per output index
-	MsgItemAdded    map[int]bool // whether response.output_item.added emitted for message
-	MsgContentAdded map[int]bool // whether response.content_part.added emitted for message
-	MsgItemDone     map[int]bool // whether message done events were emitted
-	// function item done state
-	FuncArgsDone map[int]bool
-	FuncItemDone map[int]bool
+	Seq        int
+	ResponseID string
+	Created    int64
+	Started    bool
+	Completed  bool
+	Scanner    chatChunkScanner
+	// Items lists every output item in output_index order.
+	Items   []*oaiToResponsesItem
+	Choices map[int]*oaiToResponsesChoice
 	// usage aggregation
 	PromptTokens     int64
 	CachedTokens     int64
@@ -62,22 +70,71 @@ func emitRespEvent(event string, payload string) string {
 	return fmt.Sprintf("event: %s\ndata: %s", event, payload)
 }
 
+// chatChunkScanner reassembles chat-completions chunk objects from SSE data
+// payloads. Some upstreams split one object across several data lines or put
+// several objects on one, so objects are cut at their closing brace rather
+// than at line boundaries.
+type chatChunkScanner struct {
+	pending []byte
+}
+
+// Feed appends data and returns the complete top-level JSON objects now
+// available, keeping an unfinished object for the next call. A pending object
+// is dropped when data is itself a complete object, since the upstream has
+// evidently moved on.
+func (s *chatChunkScanner) Feed(data []byte) [][]byte {
+	if len(s.pending) > 0 && bytes.HasPrefix(data, []byte("{")) && gjson.ValidBytes(data) {
+		s.pending = s.pending[:0]
+	}
+	s.pending = append(s.pending, data...)
+
+	var objects [][]byte
+	start, depth := -1, 0
+	inString, escaped := false, false
+	for i, b := range s.pending {
+		if start < 0 {
+			if b == '{' {
+				start, depth = i, 1
+			}
+			continue
+		}
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+		switch b {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				objects = append(objects, bytes.Clone(s.pending[start:i+1]))
+				start = -1
+			}
+		}
+	}
+	if start < 0 {
+		s.pending = s.pending[:0]
+	} else {
+		s.pending = append(s.pending[:0], s.pending[start:]...)
+	}
+	return objects
+}
+
 // ConvertOpenAIChatCompletionsResponseToOpenAIResponses converts OpenAI Chat Completions streaming chunks
 // to OpenAI Responses SSE events (response.*).
 func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context, modelName string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
 	if *param == nil {
-		*param = &oaiToResponsesState{
-			FuncArgsBuf:     make(map[int]*strings.Builder),
-			FuncNames:       make(map[int]string),
-			FuncCallIDs:     make(map[int]string),
-			MsgTextBuf:      make(map[int]*strings.Builder),
-			MsgItemAdded:    make(map[int]bool),
-			MsgContentAdded: make(map[int]bool),
-			MsgItemDone:     make(map[int]bool),
-			FuncArgsDone:    make(map[int]bool),
-			FuncItemDone:    make(map[int]bool),
-			Reasonings:      make([]oaiToResponsesStateReasoning, 0),
-		}
+		*param = &oaiToResponsesState{Choices: make(map[int]*oaiToResponsesChoice)}
 	}
 	st := (*param).(*oaiToResponsesState)
 
@@ -93,13 +150,21 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 		return []string{}
 	}
 
-	root := gjson.ParseBytes(rawJSON)
+	var out []string
+	for _, chunk := range st.Scanner.Feed(rawJSON) {
+		out = append(out, st.convertChunk(gjson.ParseBytes(chunk), requestRawJSON)...)
+	}
+	return out
+}
+
+// convertChunk converts one complete chat-completions chunk.
+func (st *oaiToResponsesState) convertChunk(root gjson.Result, requestRawJSON []byte) []string {
 	obj := root.Get("object")
 	if obj.Exists() && obj.String() != "" && obj.String() != "chat.completion.chunk" {
-		return []string{}
+		return nil
 	}
-	if !root.Get("choices").Exists() || !root.Get("choices").IsArray() {
-		return []string{}
+	if !root.Get("choices").IsArray() || st.Completed {
+		return nil
 	}
 
 	if usage := root.Get("usage"); usage.Exists() {
@@ -131,541 +196,415 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponses(ctx context.Context,
 		}
 	}
 
-	nextSeq := func() int { st.Seq++; return st.Seq }
 	var out []string
-
 	if !st.Started {
 		st.ResponseID = root.Get("id").String()
 		st.Created = root.Get("created").Int()
-		// reset aggregation state for a new streaming response
-		st.MsgTextBuf = make(map[int]*strings.Builder)
-		st.ReasoningBuf.Reset()
-		st.ReasoningID = ""
-		st.ReasoningIndex = 0
-		st.FuncArgsBuf = make(map[int]*strings.Builder)
-		st.FuncNames = make(map[int]string)
-		st.FuncCallIDs = make(map[int]string)
-		st.CompletedFuncs = nil
-		st.MsgItemAdded = make(map[int]bool)
-		st.MsgContentAdded = make(map[int]bool)
-		st.MsgItemDone = make(map[int]bool)
-		st.FuncArgsDone = make(map[int]bool)
-		st.FuncItemDone = make(map[int]bool)
-		st.PromptTokens = 0
-		st.CachedTokens = 0
-		st.CompletionTokens = 0
-		st.TotalTokens = 0
-		st.ReasoningTokens = 0
-		st.UsageSeen = false
 		// response.created
 		created := `{"type":"response.created","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"in_progress","background":false,"error":null,"output":[]}}`
-		created, _ = sjson.Set(created, "sequence_number", nextSeq())
 		created, _ = sjson.Set(created, "response.id", st.ResponseID)
 		created, _ = sjson.Set(created, "response.created_at", st.Created)
-		out = append(out, emitRespEvent("response.created", created))
+		out = append(out, st.event("response.created", created))
 
 		inprog := `{"type":"response.in_progress","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"in_progress"}}`
-		inprog, _ = sjson.Set(inprog, "sequence_number", nextSeq())
 		inprog, _ = sjson.Set(inprog, "response.id", st.ResponseID)
 		inprog, _ = sjson.Set(inprog, "response.created_at", st.Created)
-		out = append(out, emitRespEvent("response.in_progress", inprog))
+		out = append(out, st.event("response.in_progress", inprog))
 		st.Started = true
 	}
 
-	stopReasoning := func(text string) {
-		// Emit reasoning done events
-		textDone := `{"type":"response.reasoning_summary_text.done","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"text":""}`
-		textDone, _ = sjson.Set(textDone, "sequence_number", nextSeq())
-		textDone, _ = sjson.Set(textDone, "item_id", st.ReasoningID)
-		textDone, _ = sjson.Set(textDone, "output_index", st.ReasoningIndex)
-		textDone, _ = sjson.Set(textDone, "text", text)
-		out = append(out, emitRespEvent("response.reasoning_summary_text.done", textDone))
-		partDone := `{"type":"response.reasoning_summary_part.done","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`
-		partDone, _ = sjson.Set(partDone, "sequence_number", nextSeq())
-		partDone, _ = sjson.Set(partDone, "item_id", st.ReasoningID)
-		partDone, _ = sjson.Set(partDone, "output_index", st.ReasoningIndex)
-		partDone, _ = sjson.Set(partDone, "part.text", text)
-		out = append(out, emitRespEvent("response.reasoning_summary_part.done", partDone))
-		outputItemDone := `{"type":"response.output_item.done","item":{"id":"","type":"reasoning","encrypted_content":"","summary":[{"type":"summary_text","text":""}]},"output_index":0,"sequence_number":0}`
-		outputItemDone, _ = sjson.Set(outputItemDone, "sequence_number", nextSeq())
-		outputItemDone, _ = sjson.Set(outputItemDone, "item.id", st.ReasoningID)
-		outputItemDone, _ = sjson.Set(outputItemDone, "output_index", st.ReasoningIndex)
-		outputItemDone, _ = sjson.Set(outputItemDone, "item.summary.text", text)
-		out = append(out, emitRespEvent("response.output_item.done", outputItemDone))
-
-		st.Reasonings = append(st.Reasonings, oaiToResponsesStateReasoning{ReasoningID: st.ReasoningID, ReasoningData: text})
-		st.ReasoningID = ""
-	}
-
-	// choices[].delta content / tool_calls / reasoning_content
-	if choices := root.Get("choices"); choices.Exists() && choices.IsArray() {
-		choices.ForEach(func(_, choice gjson.Result) bool {
-			idx := int(choice.Get("index").Int())
-			delta := choice.Get("delta")
-			if delta.Exists() {
-				if c := delta.Get("content"); c.Exists() && c.String() != "" {
-					// Ensure the message item and its first content part are announced before any text deltas
-					if st.ReasoningID != "" {
-						stopReasoning(st.ReasoningBuf.String())
-						st.ReasoningBuf.Reset()
-					}
-					if !st.MsgItemAdded[idx] {
-						item := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"in_progress","content":[],"role":"assistant"}}`
-						item, _ = sjson.Set(item, "sequence_number", nextSeq())
-						item, _ = sjson.Set(item, "output_index", idx)
-						item, _ = sjson.Set(item, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-						out = append(out, emitRespEvent("response.output_item.added", item))
-						st.MsgItemAdded[idx] = true
-					}
-					if !st.MsgContentAdded[idx] {
-						part := `{"type":"response.content_part.added","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`
-						part, _ = sjson.Set(part, "sequence_number", nextSeq())
-						part, _ = sjson.Set(part, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-						part, _ = sjson.Set(part, "output_index", idx)
-						part, _ = sjson.Set(part, "content_index", 0)
-						out = append(out, emitRespEvent("response.content_part.added", part))
-						st.MsgContentAdded[idx] = true
-					}
+	root.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+		idx := int(choice.Get("index").Int())
+		ch := st.Choices[idx]
+		if ch == nil {
+			ch = &oaiToResponsesChoice{Funcs: make(map[int]*oaiToResponsesItem)}
+			st.Choices[idx] = ch
+		}
+		if ch.Finished {
+			return true
+		}
+		delta := choice.Get("delta")
+
+		if text, encrypted := chatReasoningDelta(delta); text != "" || encrypted != "" {
+			out = append(out, st.closeMessage(ch)...)
+			out = append(out, st.closeFuncs(ch)...)
+			if ch.Reasoning == nil {
+				var added []string
+				ch.Reasoning, added = st.openItem("reasoning")
+				out = append(out, added...)
+			}
+			ch.Reasoning.Encrypted += encrypted
+			if text != "" {
+				ch.Reasoning.Buf.WriteString(text)
+				msg := `{"type":"response.reasoning_summary_text.delta","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"delta":""}`
+				msg, _ = sjson.Set(msg, "item_id", ch.Reasoning.ID)
+				msg, _ = sjson.Set(msg, "output_index", ch.Reasoning.OutputIndex)
+				msg, _ = sjson.Set(msg, "delta", text)
+				out = append(out, st.event("response.reasoning_summary_text.delta", msg))
+			}
+		}
 
-					msg := `{"type":"response.output_text.delta","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"delta":"","logprobs":[]}`
-					msg, _ = sjson.Set(msg, "sequence_number", nextSeq())
-					msg, _ = sjson.Set(msg, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-					msg, _ = sjson.Set(msg, "output_index", idx)
-					msg, _ = sjson.Set(msg, "content_index", 0)
-					msg, _ = sjson.Set(msg, "delta", c.String())
-					out = append(out, emitRespEvent("response.output_text.delta", msg))
-					// aggregate for response.output
-					if st.MsgTextBuf[idx] == nil {
-						st.MsgTextBuf[idx] = &strings.Builder{}
-					}
-					st.MsgTextBuf[idx].WriteString(c.String())
-				}
+		if c := delta.Get("content"); c.Exists() && c.String() != "" {
+			out = append(out, st.closeReasoning(ch)...)
+			out = append(out, st.closeFuncs(ch)...)
+			if ch.Message == nil {
+				var added []string
+				ch.Message, added = st.openItem("message")
+				out = append(out, added...)
+			}
+			ch.Message.Buf.WriteString(c.String())
+			msg := `{"type":"response.output_text.delta","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"delta":"","logprobs":[]}`
+			msg, _ = sjson.Set(msg, "item_id", ch.Message.ID)
+			msg, _ = sjson.Set(msg, "output_index", ch.Message.OutputIndex)
+			msg, _ = sjson.Set(msg, "delta", c.String())
+			if lp := choice.Get("logprobs.content"); lp.IsArray() {
+				msg, _ = sjson.SetRaw(msg, "logprobs", logprobs.ResponsesFromOpenAI(lp))
+			}
+			out = append(out, st.event("response.output_text.delta", msg))
+		}
 
-				// reasoning_content / reasoning_text (OpenAI reasoning incremental text).
-				// Gemini on Copilot emits delta.reasoning_text, while standard OpenAI
-				// emits delta.reasoning_content. Support both.
-				for _, rcField := range []string{"reasoning_content", "reasoning_text"} {
-					rc := delta.Get(rcField)
-					if !rc.Exists() || rc.String() == "" {
-						continue
-					}
-					// On first appearance, add reasoning item and part
-					if st.ReasoningID == "" {
-						st.ReasoningID = fmt.Sprintf("rs_%s_%d", st.ResponseID, idx)
-						st.ReasoningIndex = idx
-						item := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"reasoning","status":"in_progress","summary":[]}}`
-						item, _ = sjson.Set(item, "sequence_number", nextSeq())
-						item, _ = sjson.Set(item, "output_index", idx)
-						item, _ = sjson.Set(item, "item.id", st.ReasoningID)
-						out = append(out, emitRespEvent("response.output_item.added", item))
-						part := `{"type":"response.reasoning_summary_part.added","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`
-						part, _ = sjson.Set(part, "sequence_number", nextSeq())
-						part, _ = sjson.Set(part, "item_id", st.ReasoningID)
-						part, _ = sjson.Set(part, "output_index", st.ReasoningIndex)
-						out = append(out, emitRespEvent("response.reasoning_summary_part.added", part))
-					}
-					// Append incremental text to reasoning buffer
-					st.ReasoningBuf.WriteString(rc.String())
-					msg := `{"type":"response.reasoning_summary_text.delta","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"delta":""}`
-					msg, _ = sjson.Set(msg, "sequence_number", nextSeq())
-					msg, _ = sjson.Set(msg, "item_id", st.ReasoningID)
-					msg, _ = sjson.Set(msg, "output_index", st.ReasoningIndex)
-					msg, _ = sjson.Set(msg, "delta", rc.String())
-					out = append(out, emitRespEvent("response.reasoning_summary_text.delta", msg))
-				}
+		if tcs := delta.Get("tool_calls"); tcs.IsArray() {
+			out = append(out, st.closeReasoning(ch)...)
+			out = append(out, st.closeMessage(ch)...)
+			tcs.ForEach(func(_, tc gjson.Result) bool {
+				out = append(out, st.appendToolCall(ch, tc)...)
+				return true
+			})
+		}
 
-				// tool calls
-				if tcs := delta.Get("tool_calls"); tcs.Exists() && tcs.IsArray() {
-					if st.ReasoningID != "" {
-						stopReasoning(st.ReasoningBuf.String())
-						st.ReasoningBuf.Reset()
-					}
-					// Before emitting any function events, if a message is open for this index,
-					// close its text/content to match Codex expected ordering.
-					if st.MsgItemAdded[idx] && !st.MsgItemDone[idx] {
-						fullText := ""
-						if b := st.MsgTextBuf[idx]; b != nil {
-							fullText = b.String()
-						}
-						done := `{"type":"response.output_text.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"text":"","logprobs":[]}`
-						done, _ = sjson.Set(done, "sequence_number", nextSeq())
-						done, _ = sjson.Set(done, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-						done, _ = sjson.Set(done, "output_index", idx)
-						done, _ = sjson.Set(done, "content_index", 0)
-						done, _ = sjson.Set(done, "text", fullText)
-						out = append(out, emitRespEvent("response.output_text.done", done))
-
-						partDone := `{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`
-						partDone, _ = sjson.Set(partDone, "sequence_number", nextSeq())
-						partDone, _ = sjson.Set(partDone, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-						partDone, _ = sjson.Set(partDone, "output_index", idx)
-						partDone, _ = sjson.Set(partDone, "content_index", 0)
-						partDone, _ = sjson.Set(partDone, "part.text", fullText)
-						out = append(out, emitRespEvent("response.content_part.done", partDone))
-
-						itemDone := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}}`
-						itemDone, _ = sjson.Set(itemDone, "sequence_number", nextSeq())
-						itemDone, _ = sjson.Set(itemDone, "output_index", idx)
-						itemDone, _ = sjson.Set(itemDone, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, idx))
-						itemDone, _ = sjson.Set(itemDone, "item.content.0.text", fullText)
-						out = append(out, emitRespEvent("response.output_item.done", itemDone))
-						st.MsgItemDone[idx] = true
-					}
+		fr := choice.Get("finish_reason")
+		if !fr.Exists() || fr.String() == "" {
+			return true
+		}
+		out = append(out, st.closeReasoning(ch)...)
+		out = append(out, st.closeMessage(ch)...)
+		out = append(out, st.closeFuncs(ch)...)
+		ch.Finished = true
+		for _, other := range st.Choices {
+			if !other.Finished {
+				return true
+			}
+		}
+		out = append(out, st.complete(fr.String(), requestRawJSON))
+		return false
+	})
 
-					// tool_calls may contain multiple calls. Track each by its tool_call.index.
-					tcs.ForEach(func(_, tc gjson.Result) bool {
-						tcIndex := int(tc.Get("index").Int())
-						// Allocate a deterministic output_index namespace for tool calls so they
-						// don't collide with message output_index (= choice index).
-						// NOTE: This assumes a single choice won't exceed 1000 tool call indices.
-						funcIdx := (idx + 1) * 1000
-						funcIdx += tcIndex
-
-						newCallID := tc.Get("id").String()
-						nameChunk := tc.Get("function.name").String()
-						argsChunk := tc.Get("function.arguments").String()
-
-						existingCallID := st.FuncCallIDs[funcIdx]
-
-						// Copilot Gemini models can reuse the same tcIndex (often 0) for multiple
-						// complete tool calls across chunks. Flush when a new id appears.
-						if existingCallID != "" && newCallID != "" && existingCallID != newCallID {
-							oldArgs := "{}"
-							if b := st.FuncArgsBuf[funcIdx]; b != nil && b.Len() > 0 {
-								oldArgs = b.String()
-							}
-							fcDone := `{"type":"response.function_call_arguments.done","sequence_number":0,"item_id":"","output_index":0,"arguments":""}`
-							fcDone, _ = sjson.Set(fcDone, "sequence_number", nextSeq())
-							fcDone, _ = sjson.Set(fcDone, "item_id", fmt.Sprintf("fc_%s", existingCallID))
-							fcDone, _ = sjson.Set(fcDone, "output_index", funcIdx)
-							fcDone, _ = sjson.Set(fcDone, "arguments", oldArgs)
-							out = append(out, emitRespEvent("response.function_call_arguments.done", fcDone))
-
-							itemDone := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}}`
-							itemDone, _ = sjson.Set(itemDone, "sequence_number", nextSeq())
-							itemDone, _ = sjson.Set(itemDone, "output_index", funcIdx)
-							itemDone, _ = sjson.Set(itemDone, "item.id", fmt.Sprintf("fc_%s", existingCallID))
-							itemDone, _ = sjson.Set(itemDone, "item.arguments", oldArgs)
-							itemDone, _ = sjson.Set(itemDone, "item.call_id", existingCallID)
-							itemDone, _ = sjson.Set(itemDone, "item.name", st.FuncNames[funcIdx])
-							out = append(out, emitRespEvent("response.output_item.done", itemDone))
-
-							st.CompletedFuncs = append(st.CompletedFuncs, oaiToResponsesCompletedFunc{CallID: existingCallID, Name: st.FuncNames[funcIdx], Args: oldArgs})
-
-							st.FuncCallIDs[funcIdx] = ""
-							st.FuncNames[funcIdx] = ""
-							st.FuncArgsBuf[funcIdx] = &strings.Builder{}
-							existingCallID = ""
-						}
-
-						if nameChunk != "" {
-							st.FuncNames[funcIdx] = nameChunk
-						}
-						effectiveCallID := existingCallID
-						shouldEmitItem := false
-						if existingCallID == "" && newCallID != "" {
-							effectiveCallID = newCallID
-							st.FuncCallIDs[funcIdx] = newCallID
-							shouldEmitItem = true
-						}
-
-						if shouldEmitItem && effectiveCallID != "" {
-							o := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"in_progress","arguments":"","call_id":"","name":""}}`
-							o, _ = sjson.Set(o, "sequence_number", nextSeq())
-							o, _ = sjson.Set(o, "output_index", funcIdx)
-							o, _ = sjson.Set(o, "item.id", fmt.Sprintf("fc_%s", effectiveCallID))
-							o, _ = sjson.Set(o, "item.call_id", effectiveCallID)
-							o, _ = sjson.Set(o, "item.name", st.FuncNames[funcIdx])
-							out = append(out, emitRespEvent("response.output_item.added", o))
-						}
-
-						if st.FuncArgsBuf[funcIdx] == nil {
-							st.FuncArgsBuf[funcIdx] = &strings.Builder{}
-						}
-
-						if argsChunk != "" {
-							refCallID := st.FuncCallIDs[funcIdx]
-							if refCallID == "" {
-								refCallID = newCallID
-							}
-							if refCallID != "" {
-								ad := `{"type":"response.function_call_arguments.delta","sequence_number":0,"item_id":"","output_index":0,"delta":""}`
-								ad, _ = sjson.Set(ad, "sequence_number", nextSeq())
-								ad, _ = sjson.Set(ad, "item_id", fmt.Sprintf("fc_%s", refCallID))
-								ad, _ = sjson.Set(ad, "output_index", funcIdx)
-								ad, _ = sjson.Set(ad, "delta", argsChunk)
-								out = append(out, emitRespEvent("response.function_call_arguments.delta", ad))
-							}
-							st.FuncArgsBuf[funcIdx].WriteString(argsChunk)
-						}
+	return out
+}
 
-						return true
-					})
-				}
+// chatReasoningDelta returns the reasoning text and encrypted reasoning of a
+// chat delta. Standard OpenAI-compatible servers emit reasoning_content,
+// Gemini on Copilot emits reasoning_text, and OpenRouter-style servers emit a
+// reasoning string alongside typed reasoning_details; the first field present
+// wins so the same text is not counted twice.
+func chatReasoningDelta(delta gjson.Result) (string, string) {
+	text := ""
+	for _, field := range []string{"reasoning_content", "reasoning_text", "reasoning"} {
+		if v := delta.Get(field); v.Type == gjson.String && v.String() != "" {
+			text = v.String()
+			break
+		}
+	}
+	var encrypted strings.Builder
+	for _, detail := range delta.Get("reasoning_details").Array() {
+		switch detail.Get("type").String() {
+		case "reasoning.text":
+			if text == "" {
+				text = detail.Get("text").String()
+			}
+		case "reasoning.summary":
+			if text == "" {
+				text = detail.Get("summary").String()
 			}
+		case "reasoning.encrypted":
+			encrypted.WriteString(detail.Get("data").String())
+		}
+	}
+	return text, encrypted.String()
+}
 
-			// finish_reason triggers finalization, including text done/content done/item done,
-			// reasoning done/part.done, function args done/item done, and completed
-			if fr := choice.Get("finish_reason"); fr.Exists() && fr.String() != "" {
-				// Emit message done events for all indices that started a message
-				if len(st.MsgItemAdded) > 0 {
-					// sort indices for deterministic order
-					idxs := make([]int, 0, len(st.MsgItemAdded))
-					for i := range st.MsgItemAdded {
-						idxs = append(idxs, i)
-					}
-					for i := 0; i < len(idxs); i++ {
-						for j := i + 1; j < len(idxs); j++ {
-							if idxs[j] < idxs[i] {
-								idxs[i], idxs[j] = idxs[j], idxs[i]
-							}
-						}
-					}
-					for _, i := range idxs {
-						if st.MsgItemAdded[i] && !st.MsgItemDone[i] {
-							fullText := ""
-							if b := st.MsgTextBuf[i]; b != nil {
-								fullText = b.String()
-							}
-							done := `{"type":"response.output_text.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"text":"","logprobs":[]}`
-							done, _ = sjson.Set(done, "sequence_number", nextSeq())
-							done, _ = sjson.Set(done, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
-							done, _ = sjson.Set(done, "output_index", i)
-							done, _ = sjson.Set(done, "content_index", 0)
-							done, _ = sjson.Set(done, "text", fullText)
-							out = append(out, emitRespEvent("response.output_text.done", done))
-
-							partDone := `{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`
-							partDone, _ = sjson.Set(partDone, "sequence_number", nextSeq())
-							partDone, _ = sjson.Set(partDone, "item_id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
-							partDone, _ = sjson.Set(partDone, "output_index", i)
-							partDone, _ = sjson.Set(partDone, "content_index", 0)
-							partDone, _ = sjson.Set(partDone, "part.text", fullText)
-							out = append(out, emitRespEvent("response.content_part.done", partDone))
-
-							itemDone := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}}`
-							itemDone, _ = sjson.Set(itemDone, "sequence_number", nextSeq())
-							itemDone, _ = sjson.Set(itemDone, "output_index", i)
-							itemDone, _ = sjson.Set(itemDone, "item.id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
-							itemDone, _ = sjson.Set(itemDone, "item.content.0.text", fullText)
-							out = append(out, emitRespEvent("response.output_item.done", itemDone))
-							st.MsgItemDone[i] = true
-						}
-					}
-				}
+// event stamps payload with the next sequence number and frames it as an SSE event.
+func (st *oaiToResponsesState) event(name, payload string) string {
+	st.Seq++
+	payload, _ = sjson.Set(payload, "sequence_number", st.Seq)
+	return emitRespEvent(name, payload)
+}
 
-				if st.ReasoningID != "" {
-					stopReasoning(st.ReasoningBuf.String())
-					st.ReasoningBuf.Reset()
-				}
+// openItem allocates the next output index for a message or reasoning item
+// and announces it together with its first content or summary part.
+func (st *oaiToResponsesState) openItem(itemType string) (*oaiToResponsesItem, []string) {
+	item := &oaiToResponsesItem{Type: itemType, OutputIndex: len(st.Items)}
+	st.Items = append(st.Items, item)
+	var out []string
+	switch itemType {
+	case "message":
+		item.ID = fmt.Sprintf("msg_%s_%d", st.ResponseID, item.OutputIndex)
+		added := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"message","status":"in_progress","content":[],"role":"assistant"}}`
+		added, _ = sjson.Set(added, "output_index", item.OutputIndex)
+		added, _ = sjson.Set(added, "item.id", item.ID)
+		out = append(out, st.event("response.output_item.added", added))
+		part := `{"type":"response.content_part.added","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`
+		part, _ = sjson.Set(part, "item_id", item.ID)
+		part, _ = sjson.Set(part, "output_index", item.OutputIndex)
+		out = append(out, st.event("response.content_part.added", part))
+	case "reasoning":
+		item.ID = fmt.Sprintf("rs_%s_%d", st.ResponseID, item.OutputIndex)
+		added := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"reasoning","status":"in_progress","summary":[]}}`
+		added, _ = sjson.Set(added, "output_index", item.OutputIndex)
+		added, _ = sjson.Set(added, "item.id", item.ID)
+		out = append(out, st.event("response.output_item.added", added))
+		part := `{"type":"response.reasoning_summary_part.added","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`
+		part, _ = sjson.Set(part, "item_id", item.ID)
+		part, _ = sjson.Set(part, "output_index", item.OutputIndex)
+		out = append(out, st.event("response.reasoning_summary_part.added", part))
+	}
+	return item, out
+}
 
-				// Emit function call done events for any active function calls
-				if len(st.FuncCallIDs) > 0 {
-					idxs := make([]int, 0, len(st.FuncCallIDs))
-					for i := range st.FuncCallIDs {
-						idxs = append(idxs, i)
-					}
-					for i := 0; i < len(idxs); i++ {
-						for j := i + 1; j < len(idxs); j++ {
-							if idxs[j] < idxs[i] {
-								idxs[i], idxs[j] = idxs[j], idxs[i]
-							}
-						}
-					}
-					for _, i := range idxs {
-						callID := st.FuncCallIDs[i]
-						if callID == "" || st.FuncItemDone[i] {
-							continue
-						}
-						args := "{}"
-						if b := st.FuncArgsBuf[i]; b != nil && b.Len() > 0 {
-							args = b.String()
-						}
-						fcDone := `{"type":"response.function_call_arguments.done","sequence_number":0,"item_id":"","output_index":0,"arguments":""}`
-						fcDone, _ = sjson.Set(fcDone, "sequence_number", nextSeq())
-						fcDone, _ = sjson.Set(fcDone, "item_id", fmt.Sprintf("fc_%s", callID))
-						fcDone, _ = sjson.Set(fcDone, "output_index", i)
-						fcDone, _ = sjson.Set(fcDone, "arguments", args)
-						out = append(out, emitRespEvent("response.function_call_arguments.done", fcDone))
-
-						itemDone := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}}`
-						itemDone, _ = sjson.Set(itemDone, "sequence_number", nextSeq())
-						itemDone, _ = sjson.Set(itemDone, "output_index", i)
-						itemDone, _ = sjson.Set(itemDone, "item.id", fmt.Sprintf("fc_%s", callID))
-						itemDone, _ = sjson.Set(itemDone, "item.arguments", args)
-						itemDone, _ = sjson.Set(itemDone, "item.call_id", callID)
-						itemDone, _ = sjson.Set(itemDone, "item.name", st.FuncNames[i])
-						out = append(out, emitRespEvent("response.output_item.done", itemDone))
-						st.FuncItemDone[i] = true
-						st.FuncArgsDone[i] = true
-					}
-				}
-				completed := `{"type":"response.completed","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"completed","background":false,"error":null}}`
-				completed, _ = sjson.Set(completed, "sequence_number", nextSeq())
-				completed, _ = sjson.Set(completed, "response.id", st.ResponseID)
-				completed, _ = sjson.Set(completed, "response.created_at", st.Created)
-				// Inject original request fields into response as per docs/response.completed.json
-				if requestRawJSON != nil {
-					req := gjson.ParseBytes(requestRawJSON)
-					if v := req.Get("instructions"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.instructions", v.String())
-					}
-					if v := req.Get("max_output_tokens"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.max_output_tokens", v.Int())
-					}
-					if v := req.Get("max_tool_calls"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.max_tool_calls", v.Int())
-					}
-					if v := req.Get("model"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.model", v.String())
-					}
-					if v := req.Get("parallel_tool_calls"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.parallel_tool_calls", v.Bool())
-					}
-					if v := req.Get("previous_response_id"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.previous_response_id", v.String())
-					}
-					if v := req.Get("prompt_cache_key"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.prompt_cache_key", v.String())
-					}
-					if v := req.Get("reasoning"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.reasoning", v.Value())
-					}
-					if v := req.Get("safety_identifier"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.safety_identifier", v.String())
-					}
-					if v := req.Get("service_tier"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.service_tier", v.String())
-					}
-					if v := req.Get("store"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.store", v.Bool())
-					}
-					if v := req.Get("temperature"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.temperature", v.Float())
-					}
-					if v := req.Get("text"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.text", v.Value())
-					}
-					if v := req.Get("tool_choice"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.tool_choice", v.Value())
-					}
-					if v := req.Get("tools"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.tools", v.Value())
-					}
-					if v := req.Get("top_logprobs"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.top_logprobs", v.Int())
-					}
-					if v := req.Get("top_p"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.top_p", v.Float())
-					}
-					if v := req.Get("truncation"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.truncation", v.String())
-					}
-					if v := req.Get("user"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.user", v.Value())
-					}
-					if v := req.Get("metadata"); v.Exists() {
-						completed, _ = sjson.Set(completed, "response.metadata", v.Value())
-					}
-				}
-				// Build response.output using aggregated buffers
-				outputsWrapper := `{"arr":[]}`
-				if len(st.Reasonings) > 0 {
-					for _, r := range st.Reasonings {
-						item := `{"id":"","type":"reasoning","summary":[{"type":"summary_text","text":""}]}`
-						item, _ = sjson.Set(item, "id", r.ReasoningID)
-						item, _ = sjson.Set(item, "summary.0.text", r.ReasoningData)
-						outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
-					}
-				}
-				// Append message items in ascending index order
-				if len(st.MsgItemAdded) > 0 {
-					midxs := make([]int, 0, len(st.MsgItemAdded))
-					for i := range st.MsgItemAdded {
-						midxs = append(midxs, i)
-					}
-					for i := 0; i < len(midxs); i++ {
-						for j := i + 1; j < len(midxs); j++ {
-							if midxs[j] < midxs[i] {
-								midxs[i], midxs[j] = midxs[j], midxs[i]
-							}
-						}
-					}
-					for _, i := range midxs {
-						txt := ""
-						if b := st.MsgTextBuf[i]; b != nil {
-							txt = b.String()
-						}
-						item := `{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`
-						item, _ = sjson.Set(item, "id", fmt.Sprintf("msg_%s_%d", st.ResponseID, i))
-						item, _ = sjson.Set(item, "content.0.text", txt)
-						outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
-					}
-				}
-				// Append previously completed function calls (flushed due to ID collision at same index)
-				for _, cf := range st.CompletedFuncs {
-					item := `{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`
-					item, _ = sjson.Set(item, "id", fmt.Sprintf("fc_%s", cf.CallID))
-					item, _ = sjson.Set(item, "arguments", cf.Args)
-					item, _ = sjson.Set(item, "call_id", cf.CallID)
-					item, _ = sjson.Set(item, "name", cf.Name)
-					outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
-				}
-				// Append still-active function call accumulators
-				if len(st.FuncArgsBuf) > 0 {
-					idxs := make([]int, 0, len(st.FuncArgsBuf))
-					for i := range st.FuncArgsBuf {
-						idxs = append(idxs, i)
-					}
-					// small-N sort without extra imports
-					for i := 0; i < len(idxs); i++ {
-						for j := i + 1; j < len(idxs); j++ {
-							if idxs[j] < idxs[i] {
-								idxs[i], idxs[j] = idxs[j], idxs[i]
-							}
-						}
-					}
-					for _, i := range idxs {
-						args := ""
-						if b := st.FuncArgsBuf[i]; b != nil {
-							args = b.String()
-						}
-						callID := st.FuncCallIDs[i]
-						name := st.FuncNames[i]
-						item := `{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`
-						item, _ = sjson.Set(item, "id", fmt.Sprintf("fc_%s", callID))
-						item, _ = sjson.Set(item, "arguments", args)
-						item, _ = sjson.Set(item, "call_id", callID)
-						item, _ = sjson.Set(item, "name", name)
-						outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
-					}
-				}
-				if gjson.Get(outputsWrapper, "arr.#").Int() > 0 {
-					completed, _ = sjson.SetRaw(completed, "response.output", gjson.Get(outputsWrapper, "arr").Raw)
-				}
-				if st.UsageSeen {
-					completed, _ = sjson.Set(completed, "response.usage.input_tokens", st.PromptTokens)
-					completed, _ = sjson.Set(completed, "response.usage.input_tokens_details.cached_tokens", st.CachedTokens)
-					completed, _ = sjson.Set(completed, "response.usage.output_tokens", st.CompletionTokens)
-					if st.ReasoningTokens > 0 {
-						completed, _ = sjson.Set(completed, "response.usage.output_tokens_details.reasoning_tokens", st.ReasoningTokens)
-					}
-					total := st.TotalTokens
-					if total == 0 {
-						total = st.PromptTokens + st.CompletionTokens
-					}
-					completed, _ = sjson.Set(completed, "response.usage.total_tokens", total)
-				}
-				out = append(out, emitRespEvent("response.completed", completed))
-			}
+// closeMessage finishes the choice's open message item, if any.
+func (st *oaiToResponsesState) closeMessage(ch *oaiToResponsesChoice) []string {
+	item := ch.Message
+	if item == nil {
+		return nil
+	}
+	ch.Message = nil
+	text := item.Buf.String()
+	var out []string
+	done := `{"type":"response.output_text.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"text":"","logprobs":[]}`
+	done, _ = sjson.Set(done, "item_id", item.ID)
+	done, _ = sjson.Set(done, "output_index", item.OutputIndex)
+	done, _ = sjson.Set(done, "text", text)
+	out = append(out, st.event("response.output_text.done", done))
+
+	partDone := `{"type":"response.content_part.done","sequence_number":0,"item_id":"","output_index":0,"content_index":0,"part":{"type":"output_text","annotations":[],"logprobs":[],"text":""}}`
+	partDone, _ = sjson.Set(partDone, "item_id", item.ID)
+	partDone, _ = sjson.Set(partDone, "output_index", item.OutputIndex)
+	partDone, _ = sjson.Set(partDone, "part.text", text)
+	out = append(out, st.event("response.content_part.done", partDone))
+
+	item.Done = `{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`
+	item.Done, _ = sjson.Set(item.Done, "id", item.ID)
+	item.Done, _ = sjson.Set(item.Done, "content.0.text", text)
+	return append(out, st.itemDone(item))
+}
 
-			return true
-		})
+// closeReasoning finishes the choice's open reasoning item, if any.
+func (st *oaiToResponsesState) closeReasoning(ch *oaiToResponsesChoice) []string {
+	item := ch.Reasoning
+	if item == nil {
+		return nil
 	}
+	ch.Reasoning = nil
+	text := item.Buf.String()
+	var out []string
+	textDone := `{"type":"response.reasoning_summary_text.done","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"text":""}`
+	textDone, _ = sjson.Set(textDone, "item_id", item.ID)
+	textDone, _ = sjson.Set(textDone, "output_index", item.OutputIndex)
+	textDone, _ = sjson.Set(textDone, "text", text)
+	out = append(out, st.event("response.reasoning_summary_text.done", textDone))
+
+	partDone := `{"type":"response.reasoning_summary_part.done","sequence_number":0,"item_id":"","output_index":0,"summary_index":0,"part":{"type":"summary_text","text":""}}`
+	partDone, _ = sjson.Set(partDone, "item_id", item.ID)
+	partDone, _ = sjson.Set(partDone, "output_index", item.OutputIndex)
+	partDone, _ = sjson.Set(partDone, "part.text", text)
+	out = append(out, st.event("response.reasoning_summary_part.done", partDone))
+
+	item.Done = `{"id":"","type":"reasoning","encrypted_content":"","summary":[{"type":"summary_text","text":""}]}`
+	item.Done, _ = sjson.Set(item.Done, "id", item.ID)
+	item.Done, _ = sjson.Set(item.Done, "encrypted_content", item.Encrypted)
+	item.Done, _ = sjson.Set(item.Done, "summary.0.text", text)
+	return append(out, st.itemDone(item))
+}
 
+// appendToolCall applies one tool_calls delta. Copilot Gemini models reuse
+// tool_call index 0 for several complete calls, so a new ID at an index closes
+// the call open there.
+func (st *oaiToResponsesState) appendToolCall(ch *oaiToResponsesChoice, tc gjson.Result) []string {
+	tcIndex := int(tc.Get("index").Int())
+	callID := tc.Get("id").String()
+	name := tc.Get("function.name").String()
+	args := tc.Get("function.arguments").String()
+
+	var out []string
+	item := ch.Funcs[tcIndex]
+	if item != nil && callID != "" && !item.SyntheticCallID && item.CallID != callID {
+		out = append(out, st.closeFunc(ch, tcIndex)...)
+		item = nil
+	}
+	if item == nil {
+		if callID == "" && name == "" && args == "" {
+			return out
+		}
+		item = &oaiToResponsesItem{Type: "function_call", OutputIndex: len(st.Items), CallID: callID, Name: name}
+		if item.CallID == "" {
+			item.CallID = fmt.Sprintf("call_%s_%d", st.ResponseID, item.OutputIndex)
+			item.SyntheticCallID = true
+		}
+		item.ID = "fc_" + item.CallID
+		st.Items = append(st.Items, item)
+		ch.Funcs[tcIndex] = item
+
+		added := `{"type":"response.output_item.added","sequence_number":0,"output_index":0,"item":{"id":"","type":"function_call","status":"in_progress","arguments":"","call_id":"","name":""}}`
+		added, _ = sjson.Set(added, "output_index", item.OutputIndex)
+		added, _ = sjson.Set(added, "item.id", item.ID)
+		added, _ = sjson.Set(added, "item.call_id", item.CallID)
+		added, _ = sjson.Set(added, "item.name", item.Name)
+		out = append(out, st.event("response.output_item.added", added))
+	} else if name != "" {
+		item.Name = name
+	}
+
+	if args != "" {
+		item.Buf.WriteString(args)
+		delta := `{"type":"response.function_call_arguments.delta","sequence_number":0,"item_id":"","output_index":0,"delta":""}`
+		delta, _ = sjson.Set(delta, "item_id", item.ID)
+		delta, _ = sjson.Set(delta, "output_index", item.OutputIndex)
+		delta, _ = sjson.Set(delta, "delta", args)
+		out = append(out, st.event("response.function_call_arguments.delta", delta))
+	}
 	return out
 }
 
+// closeFuncs finishes the choice's open function calls in tool_call order.
+func (st *oaiToResponsesState) closeFuncs(ch *oaiToResponsesChoice) []string {
+	var out []string
+	for _, tcIndex := range slices.Sorted(maps.Keys(ch.Funcs)) {
+		out = append(out, st.closeFunc(ch, tcIndex)...)
+	}
+	return out
+}
+
+func (st *oaiToResponsesState) closeFunc(ch *oaiToResponsesChoice, tcIndex int) []string {
+	item := ch.Funcs[tcIndex]
+	if item == nil {
+		return nil
+	}
+	delete(ch.Funcs, tcIndex)
+	args := item.Buf.String()
+	if args == "" {
+		args = "{}"
+	}
+	done := `{"type":"response.function_call_arguments.done","sequence_number":0,"item_id":"","output_index":0,"arguments":""}`
+	done, _ = sjson.Set(done, "item_id", item.ID)
+	done, _ = sjson.Set(done, "output_index", item.OutputIndex)
+	done, _ = sjson.Set(done, "arguments", args)
+
+	item.Done = `{"id":"","type":"function_call","status":"completed","arguments":"","call_id":"","name":""}`
+	item.Done, _ = sjson.Set(item.Done, "id", item.ID)
+	item.Done, _ = sjson.Set(item.Done, "arguments", args)
+	item.Done, _ = sjson.Set(item.Done, "call_id", item.CallID)
+	item.Done, _ = sjson.Set(item.Done, "name", item.Name)
+	return []string{st.event("response.function_call_arguments.done", done), st.itemDone(item)}
+}
+
+func (st *oaiToResponsesState) itemDone(item *oaiToResponsesItem) string {
+	done := `{"type":"response.output_item.done","sequence_number":0,"output_index":0,"item":{}}`
+	done, _ = sjson.Set(done, "output_index", item.OutputIndex)
+	done, _ = sjson.SetRaw(done, "item", item.Done)
+	return st.event("response.output_item.done", done)
+}
+
+// complete builds the terminal response event once every choice has
+// finished, listing the items in the order they were streamed.
+func (st *oaiToResponsesState) complete(finishReason string, requestRawJSON []byte) string {
+	st.Completed = true
+	completed := `{"type":"response.completed","sequence_number":0,"response":{"id":"","object":"response","created_at":0,"status":"completed","background":false,"error":null}}`
+	completed, _ = sjson.Set(completed, "response.id", st.ResponseID)
+	completed, _ = sjson.Set(completed, "response.created_at", st.Created)
+	completedEvent := "response.completed"
+	if status, incompleteReason := stopreason.ToResponses(stopreason.Parse(finishReason)); status != "completed" {
+		completedEvent = "response." + status
+		completed, _ = sjson.Set(completed, "type", completedEvent)
+		completed, _ = sjson.Set(completed, "response.status", status)
+		completed, _ = sjson.Set(completed, "response.incomplete_details.reason", incompleteReason)
+	}
+	// Inject original request fields into response as per docs/response.completed.json
+	if requestRawJSON != nil {
+		req := gjson.ParseBytes(requestRawJSON)
+		if v := req.Get("instructions"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.instructions", v.String())
+		}
+		if v := req.Get("max_output_tokens"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.max_output_tokens", v.Int())
+		}
+		if v := req.Get("max_tool_calls"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.max_tool_calls", v.Int())
+		}
+		if v := req.Get("model"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.model", v.String())
+		}
+		if v := req.Get("parallel_tool_calls"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.parallel_tool_calls", v.Bool())
+		}
+		if v := req.Get("previous_response_id"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.previous_response_id", v.String())
+		}
+		if v := req.Get("prompt_cache_key"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.prompt_cache_key", v.String())
+		}
+		if v := req.Get("reasoning"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.reasoning", v.Value())
+		}
+		if v := req.Get("safety_identifier"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.safety_identifier", v.String())
+		}
+		if v := req.Get("service_tier"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.service_tier", v.String())
+		}
+		if v := req.Get("store"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.store", v.Bool())
+		}
+		if v := req.Get("temperature"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.temperature", v.Float())
+		}
+		if v := req.Get("text"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.text", v.Value())
+		}
+		if v := req.Get("tool_choice"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.tool_choice", v.Value())
+		}
+		if v := req.Get("tools"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.tools", v.Value())
+		}
+		if v := req.Get("top_logprobs"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.top_logprobs", v.Int())
+		}
+		if v := req.Get("top_p"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.top_p", v.Float())
+		}
+		if v := req.Get("truncation"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.truncation", v.String())
+		}
+		if v := req.Get("user"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.user", v.Value())
+		}
+		if v := req.Get("metadata"); v.Exists() {
+			completed, _ = sjson.Set(completed, "response.metadata", v.Value())
+		}
+	}
+	if len(st.Items) > 0 {
+		items := make([]string, 0, len(st.Items))
+		for _, item := range st.Items {
+			items = append(items, item.Done)
+		}
+		completed, _ = sjson.SetRaw(completed, "response.output", "["+strings.Join(items, ",")+"]")
+	}
+	if st.UsageSeen {
+		completed, _ = sjson.Set(completed, "response.usage.input_tokens", st.PromptTokens)
+		completed, _ = sjson.Set(completed, "response.usage.input_tokens_details.cached_tokens", st.CachedTokens)
+		completed, _ = sjson.Set(completed, "response.usage.output_tokens", st.CompletionTokens)
+		if st.ReasoningTokens > 0 {
+			completed, _ = sjson.Set(completed, "response.usage.output_tokens_details.reasoning_tokens", st.ReasoningTokens)
+		}
+		total := st.TotalTokens
+		if total == 0 {
+			total = st.PromptTokens + st.CompletionTokens
+		}
+		completed, _ = sjson.Set(completed, "response.usage.total_tokens", total)
+	}
+	return st.event(completedEvent, completed)
+}
+
 // ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream builds a single Responses JSON
 // from a non-streaming OpenAI Chat Completions response.
 func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Context, _ string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
@@ -688,6 +627,11 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 	}
 	resp, _ = sjson.Set(resp, "created_at", created)
 
+	if status, incompleteReason := stopreason.ToResponses(stopreason.Parse(root.Get("choices.0.finish_reason").String())); status != "completed" {
+		resp, _ = sjson.Set(resp, "status", status)
+		resp, _ = sjson.Set(resp, "incomplete_details.reason", incompleteReason)
+	}
+
 	// Echo request fields when available (aligns with streaming path behavior)
 	if len(requestRawJSON) > 0 {
 		req := gjson.ParseBytes(requestRawJSON)
@@ -765,13 +709,9 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 
 	// Build output list from choices[...]
 	outputsWrapper := `{"arr":[]}`
-	// Detect and capture reasoning content if present.
-	// Gemini on Copilot emits reasoning_text, while standard OpenAI emits
-	// reasoning_content. Support both.
-	rcText := gjson.GetBytes(rawJSON, "choices.0.message.reasoning_content").String()
-	if rcText == "" {
-		rcText = gjson.GetBytes(rawJSON, "choices.0.message.reasoning_text").String()
-	}
+	// Detect and capture reasoning content if present, from the same fields
+	// the streaming converter reads.
+	rcText, _ := chatReasoningDelta(gjson.GetBytes(rawJSON, "choices.0.message"))
 	includeReasoning := rcText != ""
 	if !includeReasoning && len(requestRawJSON) > 0 {
 		includeReasoning = gjson.GetBytes(requestRawJSON, "reasoning").Exists()
@@ -800,6 +740,9 @@ func ConvertOpenAIChatCompletionsResponseToOpenAIResponsesNonStream(_ context.Co
 					item := `{"id":"","type":"message","status":"completed","content":[{"type":"output_text","annotations":[],"logprobs":[],"text":""}],"role":"assistant"}`
 					item, _ = sjson.Set(item, "id", fmt.Sprintf("msg_%s_%d", id, int(choice.Get("index").Int())))
 					item, _ = sjson.Set(item, "content.0.text", c.String())
+					if lp := choice.Get("logprobs.content"); lp.IsArray() {
+						item, _ = sjson.SetRaw(item, "content.0.logprobs", logprobs.ResponsesFromOpenAI(lp))
+					}
 					outputsWrapper, _ = sjson.SetRaw(outputsWrapper, "arr.-1", item)
 				}
 
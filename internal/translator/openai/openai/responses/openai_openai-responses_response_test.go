@@ -4,6 +4,8 @@ import (
 	"context"
 	"strings"
 	"testing"
+
+	"github.com/tidwall/gjson"
 )
 
 func TestConvertToResponses_StreamReasoningText(t *testing.T) {
@@ -148,3 +150,113 @@ func TestConvertToResponses_StreamMultipleToolCallsInSingleChunk(t *testing.T) {
 		t.Fatalf("expected at least 2 output_item.added events for tool calls, got %d\n%s", addedCount, combined)
 	}
 }
+
+func convertChatStream(t *testing.T, chunks ...string) []gjson.Result {
+	t.Helper()
+	var param any
+	var events []gjson.Result
+	for _, chunk := range chunks {
+		for _, out := range ConvertOpenAIChatCompletionsResponseToOpenAIResponses(context.Background(), "m", nil, nil, []byte(chunk), &param) {
+			_, data, _ := strings.Cut(out, "\ndata: ")
+			events = append(events, gjson.Parse(data))
+		}
+	}
+	return events
+}
+
+func TestConvertToResponses_StreamItemLifecycles(t *testing.T) {
+	t.Parallel()
+
+	events := convertChatStream(t,
+		`data: {"id":"r","choices":[{"index":0,"delta":{"reasoning_content":"plan"}}]}`,
+		`data: {"id":"r","choices":[{"index":0,"delta":{"content":"Let me look."}}]}`,
+		`data: {"id":"r","choices":[{"index":0,"delta":{"reasoning_content":"more"}}]}`,
+		`data: {"id":"r","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"ls","arguments":"{\"p\":"}}]}}]}`,
+		`data: {"id":"r","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\".\"}"}}]}}]}`,
+		`data: {"id":"r","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	)
+
+	open := map[int64]string{}
+	var lifecycle []string
+	for i, ev := range events {
+		if got := ev.Get("sequence_number").Int(); got != int64(i+1) {
+			t.Fatalf("event %d has sequence_number %d", i, got)
+		}
+		typ := ev.Get("type").String()
+		idx := ev.Get("output_index").Int()
+		switch typ {
+		case "response.output_item.added":
+			if len(open) > 0 {
+				t.Fatalf("item %d added while %v still open", idx, open)
+			}
+			if idx != int64(len(lifecycle)) {
+				t.Fatalf("item added at output_index %d, want %d", idx, len(lifecycle))
+			}
+			open[idx] = ev.Get("item.type").String()
+			lifecycle = append(lifecycle, open[idx])
+		case "response.output_item.done":
+			if open[idx] != ev.Get("item.type").String() {
+				t.Fatalf("done for item %d that is not open: %s", idx, ev.Raw)
+			}
+			delete(open, idx)
+		case "response.output_text.delta", "response.reasoning_summary_text.delta", "response.function_call_arguments.delta":
+			if _, ok := open[idx]; !ok {
+				t.Fatalf("delta for closed item %d: %s", idx, ev.Raw)
+			}
+		}
+	}
+	if got := strings.Join(lifecycle, ","); got != "reasoning,message,reasoning,function_call" {
+		t.Fatalf("items = %s", got)
+	}
+
+	completed := events[len(events)-1]
+	if completed.Get("type").String() != "response.completed" {
+		t.Fatalf("last event = %s", completed.Raw)
+	}
+	if got := completed.Get("response.output.#.type").String(); got != `["reasoning","message","reasoning","function_call"]` {
+		t.Fatalf("output types = %s", got)
+	}
+	if got := completed.Get("response.output.0.summary.0.text").String(); got != "plan" {
+		t.Fatalf("first reasoning summary = %q", got)
+	}
+	if got := completed.Get("response.output.3.arguments").String(); got != `{"p":"."}` {
+		t.Fatalf("arguments = %q", got)
+	}
+}
+
+func TestConvertToResponses_StreamReassemblesSplitChunks(t *testing.T) {
+	t.Parallel()
+
+	events := convertChatStream(t,
+		`data: {"id":"r","choices":[{"index":0,"delta":{"reasoning":"a {brace",`,
+		`data: "reasoning_details":[{"type":"reasoning.text","text":"a {brace"},{"type":"reasoning.encrypted","data":"sig"}]}}]}`,
+		`data: {"id":"r","choices":[{"index":0,"delta":{"content":"hi"}}]}{"id":"r","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+	)
+
+	completed := events[len(events)-1]
+	if completed.Get("type").String() != "response.completed" {
+		t.Fatalf("last event = %s", completed.Raw)
+	}
+	reasoning := completed.Get("response.output.0")
+	if reasoning.Get("summary.0.text").String() != "a {brace" || reasoning.Get("encrypted_content").String() != "sig" {
+		t.Fatalf("reasoning item = %s", reasoning.Raw)
+	}
+	if got := completed.Get("response.output.1.content.0.text").String(); got != "hi" {
+		t.Fatalf("message text = %q", got)
+	}
+}
+
+func TestConvertToResponses_StreamToolCallWithoutID(t *testing.T) {
+	t.Parallel()
+
+	events := convertChatStream(t,
+		`data: {"id":"r","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"name":"ls","arguments":"{}"}}]}}]}`,
+		`data: {"id":"r","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	)
+
+	call := events[len(events)-1].Get("response.output.0")
+	if call.Get("type").String() != "function_call" || call.Get("name").String() != "ls" || call.Get("call_id").String() == "" {
+		t.Fatalf("function call = %s", call.Raw)
+	}
+}
@@ -0,0 +1,134 @@
+// Package stopreason maps finish reasons between the OpenAI Chat Completions,
+// Claude Messages, Gemini and OpenAI Responses vocabularies so every translator
+// reports the same outcome the same way.
+package stopreason
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Reason is the provider-neutral outcome of a generation.
+type Reason string
+
+const (
+	// Stop is a natural end of turn.
+	Stop Reason = "stop"
+	// StopSequence means a client stop sequence was hit.
+	StopSequence Reason = "stop_sequence"
+	// Length means the output token limit was reached.
+	Length Reason = "length"
+	// ToolUse means the model stopped to call tools.
+	ToolUse Reason = "tool_use"
+	// ContentFilter means output was withheld by a safety system or refused.
+	ContentFilter Reason = "content_filter"
+	// Pause means a long-running turn was paused and can be continued.
+	Pause Reason = "pause"
+)
+
+// Parse reads a finish reason in any supported vocabulary. Matching is case
+// insensitive; unknown non-empty values are treated as Stop and an empty
+// value yields "".
+func Parse(raw string) Reason {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	switch value {
+	case "":
+		return ""
+	case "stop_sequence":
+		return StopSequence
+	case "length", "max_tokens", "max_output_tokens", "model_context_window_exceeded":
+		return Length
+	case "tool_calls", "function_call", "tool_use":
+		return ToolUse
+	case "content_filter", "content_filtered", "refusal", "safety", "recitation", "blocklist",
+		"prohibited_content", "spii", "image_safety", "image_prohibited_content", "language":
+		return ContentFilter
+	case "pause_turn":
+		return Pause
+	default:
+		return Stop
+	}
+}
+
+// FromResponses reads the outcome of an OpenAI Responses object from its
+// status and incomplete_details.reason.
+func FromResponses(status, incompleteReason string) Reason {
+	if strings.EqualFold(strings.TrimSpace(status), "incomplete") {
+		if reason := Parse(incompleteReason); reason != "" && reason != Stop {
+			return reason
+		}
+		return Length
+	}
+	return Stop
+}
+
+// FromResponsesObject reads the outcome of a Responses object. A legacy
+// stop_reason field is honoured when the status itself reports completion.
+func FromResponsesObject(response gjson.Result) Reason {
+	reason := FromResponses(response.Get("status").String(), response.Get("incomplete_details.reason").String())
+	if reason == Stop {
+		if legacy := Parse(response.Get("stop_reason").String()); legacy != "" {
+			return legacy
+		}
+	}
+	return reason
+}
+
+// ToOpenAI returns the Chat Completions finish_reason.
+func ToOpenAI(r Reason) string {
+	switch r {
+	case Length:
+		return "length"
+	case ToolUse:
+		return "tool_calls"
+	case ContentFilter:
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// ToClaude returns the Messages API stop_reason.
+func ToClaude(r Reason) string {
+	switch r {
+	case StopSequence:
+		return "stop_sequence"
+	case Length:
+		return "max_tokens"
+	case ToolUse:
+		return "tool_use"
+	case ContentFilter:
+		return "refusal"
+	case Pause:
+		return "pause_turn"
+	default:
+		return "end_turn"
+	}
+}
+
+// ToGemini returns the Gemini finishReason. Gemini has no tool-call reason;
+// function calls finish with STOP.
+func ToGemini(r Reason) string {
+	switch r {
+	case Length:
+		return "MAX_TOKENS"
+	case ContentFilter:
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+// ToResponses returns the Responses status and, for incomplete responses,
+// the incomplete_details.reason.
+func ToResponses(r Reason) (status, incompleteReason string) {
+	switch r {
+	case Length:
+		return "incomplete", "max_output_tokens"
+	case ContentFilter:
+		return "incomplete", "content_filter"
+	default:
+		return "completed", ""
+	}
+}
@@ -0,0 +1,104 @@
+package stopreason
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+// matrix lists each canonical reason with its spelling in every format.
+var matrix = []struct {
+	reason           Reason
+	openai           string
+	claude           string
+	gemini           string
+	responsesStatus  string
+	incompleteReason string
+}{
+	{Stop, "stop", "end_turn", "STOP", "completed", ""},
+	{Length, "length", "max_tokens", "MAX_TOKENS", "incomplete", "max_output_tokens"},
+	{ToolUse, "tool_calls", "tool_use", "STOP", "completed", ""},
+	{ContentFilter, "content_filter", "refusal", "SAFETY", "incomplete", "content_filter"},
+}
+
+func TestMatrixEveryPair(t *testing.T) {
+	for _, row := range matrix {
+		t.Run(string(row.reason), func(t *testing.T) {
+			sources := map[string]Reason{
+				"openai":    Parse(row.openai),
+				"claude":    Parse(row.claude),
+				"gemini":    Parse(row.gemini),
+				"responses": FromResponses(row.responsesStatus, row.incompleteReason),
+			}
+			for source, parsed := range sources {
+				// Gemini and Responses have no tool-call reason; tool calls surface as a stop.
+				want := row.reason
+				if want == ToolUse && (source == "gemini" || source == "responses") {
+					want = Stop
+				}
+				if parsed != want {
+					t.Fatalf("%s %q parsed as %q, want %q", source, row.reason, parsed, want)
+				}
+				if want != row.reason {
+					continue
+				}
+				if got := ToOpenAI(parsed); got != row.openai {
+					t.Errorf("%s -> openai = %q, want %q", source, got, row.openai)
+				}
+				if got := ToClaude(parsed); got != row.claude {
+					t.Errorf("%s -> claude = %q, want %q", source, got, row.claude)
+				}
+				if got := ToGemini(parsed); got != row.gemini {
+					t.Errorf("%s -> gemini = %q, want %q", source, got, row.gemini)
+				}
+				status, incomplete := ToResponses(parsed)
+				if status != row.responsesStatus || incomplete != row.incompleteReason {
+					t.Errorf("%s -> responses = %q/%q, want %q/%q", source, status, incomplete, row.responsesStatus, row.incompleteReason)
+				}
+			}
+		})
+	}
+}
+
+func TestParseVocabularies(t *testing.T) {
+	cases := map[string]Reason{
+		"":                          "",
+		"STOP":                      Stop,
+		"end_turn":                  Stop,
+		"FINISH_REASON_UNSPECIFIED": Stop,
+		"MALFORMED_FUNCTION_CALL":   Stop,
+		"stop_sequence":             StopSequence,
+		"function_call":             ToolUse,
+		"RECITATION":                ContentFilter,
+		"PROHIBITED_CONTENT":        ContentFilter,
+		"content_filtered":          ContentFilter,
+		"pause_turn":                Pause,
+		"max_output_tokens":         Length,
+	}
+	for raw, want := range cases {
+		if got := Parse(raw); got != want {
+			t.Errorf("Parse(%q) = %q, want %q", raw, got, want)
+		}
+	}
+	if got := ToClaude(StopSequence); got != "stop_sequence" {
+		t.Errorf("stop_sequence -> claude = %q", got)
+	}
+	if got := ToOpenAI(Pause); got != "stop" {
+		t.Errorf("pause -> openai = %q", got)
+	}
+}
+
+func TestFromResponsesObject(t *testing.T) {
+	cases := map[string]Reason{
+		`{"status":"completed"}`: Stop,
+		`{"status":"incomplete","incomplete_details":{"reason":"max_output_tokens"}}`: Length,
+		`{"status":"incomplete","incomplete_details":{"reason":"content_filter"}}`:    ContentFilter,
+		`{"status":"incomplete"}`:                           Length,
+		`{"status":"completed","stop_reason":"max_tokens"}`: Length,
+	}
+	for raw, want := range cases {
+		if got := FromResponsesObject(gjson.Parse(raw)); got != want {
+			t.Errorf("FromResponsesObject(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
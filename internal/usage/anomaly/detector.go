@@ -0,0 +1,450 @@
+// Package anomaly flags abnormal consumption per API key. It receives usage
+// records as a usage plugin, buckets them into fixed windows and compares each
+// window against fixed thresholds and against the statistical baseline of the
+// preceding windows. Flagged keys can be throttled or disabled until the flag
+// expires or is cleared through the management API.
+package anomaly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWindow      = 5 * time.Minute
+	defaultHistory     = 12
+	defaultThrottleRPM = 10
+	// minBaseline is the number of past windows needed before the z-score
+	// check is trusted.
+	minBaseline    = 3
+	webhookTimeout = 10 * time.Second
+)
+
+// Flag describes an API key whose consumption was found abnormal.
+type Flag struct {
+	APIKey    string    `json:"api_key"`
+	Reason    string    `json:"reason"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Action    string    `json:"action"`
+	FlaggedAt time.Time `json:"flagged_at"`
+	// Until is nil when the flag lasts until cleared.
+	Until *time.Time `json:"until,omitempty"`
+}
+
+type window struct {
+	tokens   int64
+	requests int64
+}
+
+type keyState struct {
+	current     window
+	history     []window
+	flag        *Flag
+	exemptUntil time.Time
+
+	throttleMinute int64
+	throttleCount  int
+}
+
+// Detector tracks per-key usage windows and the resulting flags.
+type Detector struct {
+	mu      sync.Mutex
+	cfg     config.UsageAnomalyConfig
+	window  time.Duration
+	history int
+	keys    map[string]*keyState
+	stop    chan struct{}
+	done    chan struct{}
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewDetector returns a disabled detector; call Configure to enable it.
+func NewDetector() *Detector {
+	return &Detector{
+		keys:   make(map[string]*keyState),
+		client: &http.Client{Timeout: webhookTimeout},
+		now:    time.Now,
+	}
+}
+
+var defaultDetector = NewDetector()
+
+func init() {
+	coreusage.RegisterPlugin(defaultDetector)
+}
+
+// Default returns the detector fed by the global usage manager.
+func Default() *Detector { return defaultDetector }
+
+// Configure applies cfg to the default detector.
+func Configure(cfg config.UsageAnomalyConfig) { defaultDetector.Configure(cfg) }
+
+// Check reports whether the default detector lets apiKey through.
+func Check(apiKey string) (bool, int, string) { return defaultDetector.Check(apiKey) }
+
+// Throttle reports the default detector's throttle of apiKey.
+func Throttle(apiKey string) (limit, remaining int, resetAt time.Time, ok bool) {
+	return defaultDetector.Throttle(apiKey)
+}
+
+// Configure applies cfg, starting or stopping the window roller as needed.
+// Disabling the detector drops all collected state and flags.
+func (d *Detector) Configure(cfg config.UsageAnomalyConfig) {
+	cfg.Action = strings.ToLower(strings.TrimSpace(cfg.Action))
+	if cfg.Action == "" {
+		cfg.Action = config.UsageAnomalyActionNotify
+	}
+	cfg.WebhookURL = strings.TrimSpace(cfg.WebhookURL)
+	windowLen := time.Duration(cfg.Window) * time.Second
+	if windowLen <= 0 {
+		windowLen = defaultWindow
+	}
+	history := cfg.History
+	if history <= 0 {
+		history = defaultHistory
+	}
+	if cfg.ThrottleRPM <= 0 {
+		cfg.ThrottleRPM = defaultThrottleRPM
+	}
+
+	d.mu.Lock()
+	restart := !cfg.Enable || !d.cfg.Enable || windowLen != d.window
+	d.cfg, d.window, d.history = cfg, windowLen, history
+	if !cfg.Enable {
+		d.keys = make(map[string]*keyState)
+	}
+	stop, done := d.stop, d.done
+	if restart {
+		d.stop, d.done = nil, nil
+	}
+	d.mu.Unlock()
+
+	if !restart {
+		return
+	}
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	if cfg.Enable {
+		d.mu.Lock()
+		d.stop, d.done = make(chan struct{}), make(chan struct{})
+		go d.run(windowLen, d.stop, d.done)
+		d.mu.Unlock()
+	}
+}
+
+func (d *Detector) run(windowLen time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(windowLen)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.Roll()
+		}
+	}
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (d *Detector) HandleUsage(ctx context.Context, record coreusage.Record) {
+	apiKey := strings.TrimSpace(record.APIKey)
+	if apiKey == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+
+	d.mu.Lock()
+	if !d.cfg.Enable {
+		d.mu.Unlock()
+		return
+	}
+	state := d.keys[apiKey]
+	if state == nil {
+		state = &keyState{}
+		d.keys[apiKey] = state
+	}
+	state.current.tokens += tokens
+	state.current.requests++
+	var flagged *Flag
+	switch {
+	case d.cfg.MaxTokens > 0 && state.current.tokens >= d.cfg.MaxTokens:
+		flagged = d.flagLocked(apiKey, state, "max-tokens", "tokens", float64(state.current.tokens), float64(d.cfg.MaxTokens))
+	case d.cfg.MaxRequests > 0 && state.current.requests >= d.cfg.MaxRequests:
+		flagged = d.flagLocked(apiKey, state, "max-requests", "requests", float64(state.current.requests), float64(d.cfg.MaxRequests))
+	}
+	webhook := d.cfg.WebhookURL
+	d.mu.Unlock()
+
+	if flagged != nil {
+		d.notify(webhook, *flagged)
+	}
+}
+
+// Roll closes the current window for every key, running the z-score check
+// against the preceding windows before adding it to the baseline.
+func (d *Detector) Roll() {
+	d.mu.Lock()
+	if !d.cfg.Enable {
+		d.mu.Unlock()
+		return
+	}
+	now := d.now()
+	var flagged []Flag
+	for apiKey, state := range d.keys {
+		d.expireLocked(state, now)
+		if d.cfg.ZScore > 0 && len(state.history) >= minBaseline {
+			tokens := make([]float64, len(state.history))
+			requests := make([]float64, len(state.history))
+			for i, w := range state.history {
+				tokens[i], requests[i] = float64(w.tokens), float64(w.requests)
+			}
+			if limit, exceeded := zScoreExceeded(tokens, float64(state.current.tokens), d.cfg.ZScore); exceeded {
+				if flag := d.flagLocked(apiKey, state, "z-score", "tokens", float64(state.current.tokens), limit); flag != nil {
+					flagged = append(flagged, *flag)
+				}
+			} else if limit, exceeded = zScoreExceeded(requests, float64(state.current.requests), d.cfg.ZScore); exceeded {
+				if flag := d.flagLocked(apiKey, state, "z-score", "requests", float64(state.current.requests), limit); flag != nil {
+					flagged = append(flagged, *flag)
+				}
+			}
+		}
+		state.history = append(state.history, state.current)
+		if len(state.history) > d.history {
+			state.history = state.history[len(state.history)-d.history:]
+		}
+		state.current = window{}
+		if state.flag == nil && idle(state.history) && !now.Before(state.exemptUntil) {
+			delete(d.keys, apiKey)
+		}
+	}
+	webhook := d.cfg.WebhookURL
+	d.mu.Unlock()
+
+	for _, flag := range flagged {
+		d.notify(webhook, flag)
+	}
+}
+
+// zScoreExceeded reports whether value lies more than z standard deviations
+// above the mean of baseline, returning the limit it was compared against.
+// The spread is floored at 10% of the mean (and at least 1) so a perfectly
+// steady baseline does not flag on the smallest increase.
+func zScoreExceeded(baseline []float64, value, z float64) (float64, bool) {
+	var sum float64
+	for _, v := range baseline {
+		sum += v
+	}
+	mean := sum / float64(len(baseline))
+	var variance float64
+	for _, v := range baseline {
+		variance += (v - mean) * (v - mean)
+	}
+	stddev := math.Max(math.Sqrt(variance/float64(len(baseline))), math.Max(mean/10, 1))
+	limit := mean + z*stddev
+	return limit, value > limit
+}
+
+func idle(history []window) bool {
+	for _, w := range history {
+		if w.requests > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// flagLocked records a new flag for apiKey unless it is already flagged or
+// exempted by a management override.
+func (d *Detector) flagLocked(apiKey string, state *keyState, reason, metric string, value, threshold float64) *Flag {
+	now := d.now()
+	d.expireLocked(state, now)
+	if state.flag != nil || now.Before(state.exemptUntil) {
+		return nil
+	}
+	flag := &Flag{
+		APIKey:    apiKey,
+		Reason:    reason,
+		Metric:    metric,
+		Value:     value,
+		Threshold: math.Round(threshold*100) / 100,
+		Action:    d.cfg.Action,
+		FlaggedAt: now,
+	}
+	if d.cfg.BlockDuration > 0 {
+		until := now.Add(time.Duration(d.cfg.BlockDuration) * time.Second)
+		flag.Until = &until
+	}
+	state.flag = flag
+	log.Warnf("usage anomaly: api key %s flagged (%s: %s %.0f > %.2f), action %s", util.HideAPIKey(apiKey), reason, metric, value, flag.Threshold, flag.Action)
+	return flag
+}
+
+func (d *Detector) expireLocked(state *keyState, now time.Time) {
+	if state.flag != nil && state.flag.Until != nil && !now.Before(*state.flag.Until) {
+		state.flag = nil
+	}
+}
+
+// Check reports whether a request authenticated as apiKey may proceed. When
+// it may not, the HTTP status and message to return are included.
+func (d *Detector) Check(apiKey string) (bool, int, string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.cfg.Enable {
+		return true, 0, ""
+	}
+	state := d.keys[apiKey]
+	if state == nil || state.flag == nil {
+		return true, 0, ""
+	}
+	now := d.now()
+	d.expireLocked(state, now)
+	if state.flag == nil {
+		return true, 0, ""
+	}
+	switch state.flag.Action {
+	case config.UsageAnomalyActionDisable:
+		return false, http.StatusForbidden, "api key disabled after abnormal usage"
+	case config.UsageAnomalyActionThrottle:
+		minute := now.Unix() / 60
+		if state.throttleMinute != minute {
+			state.throttleMinute, state.throttleCount = minute, 0
+		}
+		if state.throttleCount >= d.cfg.ThrottleRPM {
+			return false, http.StatusTooManyRequests, "api key throttled after abnormal usage"
+		}
+		state.throttleCount++
+	}
+	return true, 0, ""
+}
+
+// Throttle reports the per-minute budget of apiKey while it is throttled:
+// the limit, the requests left this minute and when the minute ends. ok is
+// false for keys that are not throttled.
+func (d *Detector) Throttle(apiKey string) (limit, remaining int, resetAt time.Time, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state := d.keys[apiKey]
+	if !d.cfg.Enable || state == nil || state.flag == nil {
+		return 0, 0, time.Time{}, false
+	}
+	now := d.now()
+	d.expireLocked(state, now)
+	if state.flag == nil || state.flag.Action != config.UsageAnomalyActionThrottle {
+		return 0, 0, time.Time{}, false
+	}
+	minute := now.Unix() / 60
+	used := 0
+	if state.throttleMinute == minute {
+		used = state.throttleCount
+	}
+	return d.cfg.ThrottleRPM, max(d.cfg.ThrottleRPM-used, 0), time.Unix((minute+1)*60, 0), true
+}
+
+// Enabled reports whether the detector is collecting usage.
+func (d *Detector) Enabled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cfg.Enable
+}
+
+// Flags lists the active flags ordered by the time they were raised.
+func (d *Detector) Flags() []Flag {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	out := make([]Flag, 0)
+	for _, state := range d.keys {
+		d.expireLocked(state, now)
+		if state.flag != nil {
+			out = append(out, *state.flag)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].FlaggedAt.Before(out[j].FlaggedAt) })
+	return out
+}
+
+// Clear removes the flag on apiKey and exempts it from new flags for exempt.
+// It reports whether a flag was removed.
+func (d *Detector) Clear(apiKey string, exempt time.Duration) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state := d.keys[apiKey]
+	if state == nil {
+		if exempt <= 0 {
+			return false
+		}
+		state = &keyState{}
+		d.keys[apiKey] = state
+	}
+	cleared := state.flag != nil
+	state.flag = nil
+	if exempt > 0 {
+		state.exemptUntil = d.now().Add(exempt)
+	}
+	return cleared
+}
+
+type webhookPayload struct {
+	Event string `json:"event"`
+	Flag  Flag   `json:"flag"`
+}
+
+// notify posts flag to the configured webhook in the background. The API key
+// is masked because webhook receivers are usually outside the trust boundary.
+func (d *Detector) notify(url string, flag Flag) {
+	if url == "" {
+		return
+	}
+	flag.APIKey = util.HideAPIKey(flag.APIKey)
+	body, errMarshal := json.Marshal(webhookPayload{Event: "usage_anomaly", Flag: flag})
+	if errMarshal != nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		defer cancel()
+		if errPost := d.post(ctx, url, body); errPost != nil {
+			log.Warnf("usage anomaly: webhook delivery failed: %v", errPost)
+		}
+	}()
+}
+
+func (d *Detector) post(ctx context.Context, url string, body []byte) error {
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if errReq != nil {
+		return errReq
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, errDo := d.client.Do(req)
+	if errDo != nil {
+		return errDo
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
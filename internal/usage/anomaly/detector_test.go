@@ -0,0 +1,118 @@
+package anomaly
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func newTestDetector(t *testing.T, cfg config.UsageAnomalyConfig) *Detector {
+	t.Helper()
+	d := NewDetector()
+	cfg.Enable = true
+	d.Configure(cfg)
+	t.Cleanup(func() { d.Configure(config.UsageAnomalyConfig{}) })
+	return d
+}
+
+func use(d *Detector, apiKey string, tokens int64) {
+	d.HandleUsage(context.Background(), coreusage.Record{APIKey: apiKey, Detail: coreusage.Detail{TotalTokens: tokens}})
+}
+
+func TestDetector_FixedThresholdDisablesKeyAndNotifies(t *testing.T) {
+	received := make(chan webhookPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+	}))
+	defer server.Close()
+
+	d := newTestDetector(t, config.UsageAnomalyConfig{MaxTokens: 1000, Action: "disable", BlockDuration: 60, WebhookURL: server.URL})
+	use(d, "sk-customer-0001", 600)
+	if allowed, _, _ := d.Check("sk-customer-0001"); !allowed {
+		t.Fatal("key below the threshold should be allowed")
+	}
+	use(d, "sk-customer-0001", 600)
+	allowed, status, _ := d.Check("sk-customer-0001")
+	if allowed || status != http.StatusForbidden {
+		t.Fatalf("check = %v %d, want a 403", allowed, status)
+	}
+
+	select {
+	case payload := <-received:
+		if payload.Flag.Reason != "max-tokens" || payload.Flag.APIKey == "sk-customer-0001" {
+			t.Fatalf("webhook payload = %+v, want a masked max-tokens flag", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+
+	// The block lifts once BlockDuration has passed.
+	d.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if allowed, _, _ := d.Check("sk-customer-0001"); !allowed {
+		t.Fatal("expired flag should no longer block")
+	}
+}
+
+func TestDetector_ThrottleCapsRequestsPerMinute(t *testing.T) {
+	d := newTestDetector(t, config.UsageAnomalyConfig{MaxRequests: 1, Action: "throttle", ThrottleRPM: 2})
+	use(d, "k", 1)
+	for i := range 2 {
+		if allowed, _, _ := d.Check("k"); !allowed {
+			t.Fatalf("request %d should fit in the throttle budget", i)
+		}
+	}
+	if allowed, status, _ := d.Check("k"); allowed || status != http.StatusTooManyRequests {
+		t.Fatalf("check = %v %d, want a 429", allowed, status)
+	}
+	limit, remaining, resetAt, ok := d.Throttle("k")
+	if !ok || limit != 2 || remaining != 0 || !resetAt.After(time.Now()) || resetAt.Unix()%60 != 0 {
+		t.Fatalf("throttle = %d %d %v %v", limit, remaining, resetAt, ok)
+	}
+	if _, _, _, ok = d.Throttle("other"); ok {
+		t.Fatal("unflagged key reported as throttled")
+	}
+}
+
+func TestDetector_ZScoreFlagsSpikeAfterBaseline(t *testing.T) {
+	d := newTestDetector(t, config.UsageAnomalyConfig{ZScore: 3})
+	for _, tokens := range []int64{100, 120, 90, 110} {
+		use(d, "k", tokens)
+		d.Roll()
+	}
+	if flags := d.Flags(); len(flags) != 0 {
+		t.Fatalf("steady usage should not be flagged: %+v", flags)
+	}
+	use(d, "k", 5000)
+	d.Roll()
+	flags := d.Flags()
+	if len(flags) != 1 || flags[0].Reason != "z-score" || flags[0].Metric != "tokens" || flags[0].Action != config.UsageAnomalyActionNotify {
+		t.Fatalf("flags = %+v", flags)
+	}
+	// notify never blocks.
+	if allowed, _, _ := d.Check("k"); !allowed {
+		t.Fatal("notify action should not block the key")
+	}
+}
+
+func TestDetector_ClearWithExemption(t *testing.T) {
+	d := newTestDetector(t, config.UsageAnomalyConfig{MaxRequests: 1, Action: "disable"})
+	use(d, "k", 1)
+	if !d.Clear("k", time.Hour) {
+		t.Fatal("expected the flag to be cleared")
+	}
+	use(d, "k", 1)
+	if allowed, _, _ := d.Check("k"); !allowed || len(d.Flags()) != 0 {
+		t.Fatal("exempted key should not be flagged again")
+	}
+	if d.Clear("other", 0) {
+		t.Fatal("clearing an unknown key should report false")
+	}
+}
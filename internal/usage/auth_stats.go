@@ -0,0 +1,125 @@
+package usage
+
+import "time"
+
+// authWindowSize is the number of recent requests the per-auth success rate
+// and average latency are computed over.
+const authWindowSize = 100
+
+// authOutcome is one request in an auth's rolling window.
+type authOutcome struct {
+	failed    bool
+	latencyMs int64
+	timed     bool
+}
+
+// authStats holds the rolling metrics for a single credential.
+type authStats struct {
+	window [authWindowSize]authOutcome
+	count  int
+	next   int
+
+	day           string
+	requestsToday int64
+	tokensToday   int64
+
+	lastRequestAt time.Time
+	lastFailureAt time.Time
+}
+
+// AuthUsage summarises the recent traffic served by one credential.
+type AuthUsage struct {
+	// WindowRequests is how many of the last authWindowSize requests were seen.
+	WindowRequests   int       `json:"window_requests"`
+	SuccessRate      float64   `json:"success_rate"`
+	AverageLatencyMs int64     `json:"average_latency_ms,omitempty"`
+	RequestsToday    int64     `json:"requests_today"`
+	TokensToday      int64     `json:"tokens_today"`
+	LastRequestAt    time.Time `json:"last_request_at"`
+	LastFailureAt    time.Time `json:"last_failure_at"`
+}
+
+func (a *authStats) add(timestamp time.Time, failed bool, tokens int64, latency *LatencyStats) {
+	outcome := authOutcome{failed: failed}
+	if latency != nil {
+		outcome.latencyMs = latency.DurationMs
+		outcome.timed = true
+	}
+	a.window[a.next] = outcome
+	a.next = (a.next + 1) % authWindowSize
+	if a.count < authWindowSize {
+		a.count++
+	}
+
+	if day := timestamp.Format("2006-01-02"); day != a.day {
+		a.day = day
+		a.requestsToday = 0
+		a.tokensToday = 0
+	}
+	a.requestsToday++
+	a.tokensToday += tokens
+
+	if timestamp.After(a.lastRequestAt) {
+		a.lastRequestAt = timestamp
+	}
+	if failed && timestamp.After(a.lastFailureAt) {
+		a.lastFailureAt = timestamp
+	}
+}
+
+func (a *authStats) snapshot(now time.Time) AuthUsage {
+	out := AuthUsage{
+		WindowRequests: a.count,
+		LastRequestAt:  a.lastRequestAt,
+		LastFailureAt:  a.lastFailureAt,
+	}
+	var successes, timed, latencyTotal int64
+	for i := 0; i < a.count; i++ {
+		outcome := a.window[i]
+		if !outcome.failed {
+			successes++
+		}
+		if outcome.timed {
+			timed++
+			latencyTotal += outcome.latencyMs
+		}
+	}
+	if a.count > 0 {
+		out.SuccessRate = float64(successes) / float64(a.count)
+	}
+	if timed > 0 {
+		out.AverageLatencyMs = latencyTotal / timed
+	}
+	if a.day == now.Format("2006-01-02") {
+		out.RequestsToday = a.requestsToday
+		out.TokensToday = a.tokensToday
+	}
+	return out
+}
+
+func (s *RequestStatistics) recordAuth(authIndex string, timestamp time.Time, failed bool, tokens int64, latency *LatencyStats) {
+	if authIndex == "" {
+		return
+	}
+	stats, ok := s.auths[authIndex]
+	if !ok {
+		stats = &authStats{}
+		s.auths[authIndex] = stats
+	}
+	stats.add(timestamp, failed, tokens, latency)
+}
+
+// AuthUsage returns the recent traffic of the credential with the given auth
+// index, and false when it has served no requests since startup.
+func (s *RequestStatistics) AuthUsage(authIndex string) (AuthUsage, bool) {
+	if s == nil || authIndex == "" {
+		return AuthUsage{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats, ok := s.auths[authIndex]
+	if !ok {
+		return AuthUsage{}, false
+	}
+	return stats.snapshot(time.Now()), true
+}
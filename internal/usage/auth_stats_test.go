@@ -0,0 +1,51 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestAuthUsageRollingWindow(t *testing.T) {
+	stats := NewRequestStatistics()
+	ctx := context.Background()
+	now := time.Now()
+
+	stats.Record(ctx, coreusage.Record{AuthIndex: "a1", Model: "m", RequestedAt: now.Add(-48 * time.Hour), Detail: coreusage.Detail{TotalTokens: 1000}})
+	for i := 0; i < authWindowSize; i++ {
+		stats.Record(ctx, coreusage.Record{
+			AuthIndex:   "a1",
+			Model:       "m",
+			RequestedAt: now,
+			Failed:      i%4 == 0,
+			Detail:      coreusage.Detail{TotalTokens: 10, Duration: 200 * time.Millisecond},
+		})
+	}
+	stats.Record(ctx, coreusage.Record{AuthIndex: "a2", Model: "m", RequestedAt: now, Failed: true})
+
+	got, ok := stats.AuthUsage("a1")
+	if !ok {
+		t.Fatal("expected usage for a1")
+	}
+	if got.WindowRequests != authWindowSize || got.SuccessRate != 0.75 {
+		t.Fatalf("window = %d, success rate = %v", got.WindowRequests, got.SuccessRate)
+	}
+	if got.AverageLatencyMs != 200 {
+		t.Fatalf("average latency = %d, want 200", got.AverageLatencyMs)
+	}
+	if got.RequestsToday != authWindowSize || got.TokensToday != 10*authWindowSize {
+		t.Fatalf("today = %d requests / %d tokens; yesterday's traffic must not count", got.RequestsToday, got.TokensToday)
+	}
+	if got.LastFailureAt.IsZero() {
+		t.Fatal("expected last failure time")
+	}
+
+	if other, _ := stats.AuthUsage("a2"); other.SuccessRate != 0 || other.WindowRequests != 1 {
+		t.Fatalf("a2 = %+v", other)
+	}
+	if _, ok = stats.AuthUsage("missing"); ok {
+		t.Fatal("expected no usage for an unknown auth")
+	}
+}
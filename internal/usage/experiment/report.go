@@ -0,0 +1,227 @@
+// Package experiment aggregates usage records of A/B experiment arms. It
+// receives records as a usage plugin and keeps, per experiment and arm, the
+// request, failure and token totals plus a window of recent latencies, so the
+// management API can compare the arms side by side.
+package experiment
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// latencySamples bounds the latencies kept per arm for percentiles.
+const latencySamples = 1000
+
+// ArmReport compares one arm of an experiment. Requests and Failures count
+// upstream attempts, so a request retried on another credential after an
+// error counts once as a failure and once more for its final outcome.
+type ArmReport struct {
+	Arm          string  `json:"arm"`
+	Requests     int64   `json:"requests"`
+	Failures     int64   `json:"failures"`
+	ErrorRate    float64 `json:"error_rate"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	TotalTokens  int64   `json:"total_tokens"`
+	// AvgTotalTokens is the mean token use of successful requests.
+	AvgTotalTokens float64 `json:"avg_total_tokens"`
+	AvgLatencyMs   float64 `json:"avg_latency_ms"`
+	P50LatencyMs   int64   `json:"p50_latency_ms"`
+	P95LatencyMs   int64   `json:"p95_latency_ms"`
+	AvgTTFBMs      float64 `json:"avg_ttfb_ms,omitempty"`
+	// Models lists the upstream models that served the arm.
+	Models []string `json:"models"`
+}
+
+// Report compares the arms of one experiment.
+type Report struct {
+	Experiment string      `json:"experiment"`
+	Since      time.Time   `json:"since"`
+	Arms       []ArmReport `json:"arms"`
+}
+
+type armStats struct {
+	requests     int64
+	failures     int64
+	successes    int64
+	inputTokens  int64
+	outputTokens int64
+	totalTokens  int64
+
+	latencyTotal time.Duration
+	latencyCount int64
+	ttfbTotal    time.Duration
+	ttfbCount    int64
+	// latencies is a ring of the most recent latencies; next is the slot
+	// written after it is full.
+	latencies []time.Duration
+	next      int
+
+	models map[string]struct{}
+}
+
+type experimentStats struct {
+	since time.Time
+	arms  map[string]*armStats
+}
+
+// Tracker collects experiment usage.
+type Tracker struct {
+	mu          sync.Mutex
+	experiments map[string]*experimentStats
+	now         func() time.Time
+}
+
+// NewTracker returns an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{experiments: make(map[string]*experimentStats), now: time.Now}
+}
+
+var defaultTracker = NewTracker()
+
+func init() {
+	coreusage.RegisterPlugin(defaultTracker)
+}
+
+// Default returns the tracker fed by the global usage manager.
+func Default() *Tracker { return defaultTracker }
+
+// HandleUsage implements coreusage.Plugin. Records outside experiments and
+// in-progress stream records are ignored; follow-up calls made on behalf of a
+// request add their tokens without counting as requests.
+func (t *Tracker) HandleUsage(_ context.Context, record coreusage.Record) {
+	if t == nil || record.Experiment == "" || record.Arm == "" || !record.Terminal {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	exp, ok := t.experiments[record.Experiment]
+	if !ok {
+		exp = &experimentStats{since: t.now(), arms: make(map[string]*armStats)}
+		t.experiments[record.Experiment] = exp
+	}
+	arm, ok := exp.arms[record.Arm]
+	if !ok {
+		arm = &armStats{models: make(map[string]struct{})}
+		exp.arms[record.Arm] = arm
+	}
+	detail := record.Detail
+	total := detail.TotalTokens
+	if total == 0 {
+		total = detail.InputTokens + detail.OutputTokens + detail.ReasoningTokens
+	}
+	arm.inputTokens += detail.InputTokens
+	arm.outputTokens += detail.OutputTokens
+	arm.totalTokens += total
+	if record.Model != "" {
+		arm.models[record.Model] = struct{}{}
+	}
+	if record.ParentRequestID != "" {
+		return
+	}
+	arm.requests++
+	if record.Failed {
+		arm.failures++
+		return
+	}
+	arm.successes++
+	if detail.Duration > 0 {
+		arm.latencyTotal += detail.Duration
+		arm.latencyCount++
+		if len(arm.latencies) < latencySamples {
+			arm.latencies = append(arm.latencies, detail.Duration)
+		} else {
+			arm.latencies[arm.next] = detail.Duration
+			arm.next = (arm.next + 1) % latencySamples
+		}
+	}
+	if detail.TimeToFirstByte > 0 {
+		arm.ttfbTotal += detail.TimeToFirstByte
+		arm.ttfbCount++
+	}
+}
+
+// Reports returns the comparison of every experiment seen, sorted by name.
+func (t *Tracker) Reports() []Report {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reports := make([]Report, 0, len(t.experiments))
+	for name, exp := range t.experiments {
+		report := Report{Experiment: name, Since: exp.since, Arms: make([]ArmReport, 0, len(exp.arms))}
+		for armName, arm := range exp.arms {
+			report.Arms = append(report.Arms, arm.report(armName))
+		}
+		sort.Slice(report.Arms, func(i, j int) bool { return report.Arms[i].Arm < report.Arms[j].Arm })
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Experiment < reports[j].Experiment })
+	return reports
+}
+
+// Reset drops the collected data of the named experiment, or of all
+// experiments when name is empty. It reports whether anything was dropped.
+func (t *Tracker) Reset(name string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if name == "" {
+		cleared := len(t.experiments) > 0
+		t.experiments = make(map[string]*experimentStats)
+		return cleared
+	}
+	if _, ok := t.experiments[name]; !ok {
+		return false
+	}
+	delete(t.experiments, name)
+	return true
+}
+
+func (a *armStats) report(name string) ArmReport {
+	out := ArmReport{
+		Arm:          name,
+		Requests:     a.requests,
+		Failures:     a.failures,
+		InputTokens:  a.inputTokens,
+		OutputTokens: a.outputTokens,
+		TotalTokens:  a.totalTokens,
+		Models:       make([]string, 0, len(a.models)),
+	}
+	if a.requests > 0 {
+		out.ErrorRate = float64(a.failures) / float64(a.requests)
+	}
+	if a.successes > 0 {
+		out.AvgTotalTokens = float64(a.totalTokens) / float64(a.successes)
+	}
+	if a.latencyCount > 0 {
+		out.AvgLatencyMs = float64(a.latencyTotal) / float64(a.latencyCount) / float64(time.Millisecond)
+	}
+	if a.ttfbCount > 0 {
+		out.AvgTTFBMs = float64(a.ttfbTotal) / float64(a.ttfbCount) / float64(time.Millisecond)
+	}
+	if len(a.latencies) > 0 {
+		sorted := append([]time.Duration(nil), a.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		out.P50LatencyMs = percentile(sorted, 0.50).Milliseconds()
+		out.P95LatencyMs = percentile(sorted, 0.95).Milliseconds()
+	}
+	for model := range a.models {
+		out.Models = append(out.Models, model)
+	}
+	sort.Strings(out.Models)
+	return out
+}
+
+// percentile returns the nearest-rank percentile of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[min(max(idx, 0), len(sorted)-1)]
+}
@@ -0,0 +1,55 @@
+package experiment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestTrackerComparesArms(t *testing.T) {
+	tracker := NewTracker()
+	record := func(arm string, failed bool, tokens int64, latency time.Duration) coreusage.Record {
+		return coreusage.Record{
+			Model:      arm + "-model",
+			Experiment: "exp",
+			Arm:        arm,
+			Failed:     failed,
+			Terminal:   true,
+			Detail:     coreusage.Detail{InputTokens: tokens, OutputTokens: tokens, Duration: latency},
+		}
+	}
+	for i := 1; i <= 10; i++ {
+		tracker.HandleUsage(context.Background(), record("control", false, 10, time.Duration(i)*100*time.Millisecond))
+	}
+	tracker.HandleUsage(context.Background(), record("treatment", false, 50, time.Second))
+	tracker.HandleUsage(context.Background(), record("treatment", true, 0, 0))
+	// Ignored: not terminal, and outside any experiment.
+	partial := record("treatment", false, 1000, time.Second)
+	partial.Terminal = false
+	tracker.HandleUsage(context.Background(), partial)
+	tracker.HandleUsage(context.Background(), coreusage.Record{Terminal: true, Detail: coreusage.Detail{InputTokens: 5}})
+
+	reports := tracker.Reports()
+	if len(reports) != 1 || len(reports[0].Arms) != 2 {
+		t.Fatalf("reports = %+v", reports)
+	}
+	control, treatment := reports[0].Arms[0], reports[0].Arms[1]
+	if control.Requests != 10 || control.Failures != 0 || control.TotalTokens != 200 || control.AvgTotalTokens != 20 {
+		t.Fatalf("control = %+v", control)
+	}
+	if control.AvgLatencyMs != 550 || control.P50LatencyMs != 500 || control.P95LatencyMs != 1000 {
+		t.Fatalf("control latency = %+v", control)
+	}
+	if treatment.Requests != 2 || treatment.Failures != 1 || treatment.ErrorRate != 0.5 || treatment.TotalTokens != 100 {
+		t.Fatalf("treatment = %+v", treatment)
+	}
+	if len(treatment.Models) != 1 || treatment.Models[0] != "treatment-model" {
+		t.Fatalf("treatment models = %v", treatment.Models)
+	}
+
+	if !tracker.Reset("exp") || tracker.Reset("exp") || len(tracker.Reports()) != 0 {
+		t.Fatal("Reset did not drop the experiment")
+	}
+}
@@ -66,6 +66,8 @@ type RequestStatistics struct {
 	totalTokens   int64
 
 	apis map[string]*apiStats
+	// auths holds rolling per-credential metrics keyed by auth index.
+	auths map[string]*authStats
 
 	requestsByDay  map[string]int64
 	requestsByHour map[int]int64
@@ -87,13 +89,29 @@ type modelStats struct {
 	Details       []RequestDetail
 }
 
-// RequestDetail stores the timestamp and token usage for a single request.
+// RequestDetail stores the timestamp, token usage and timing for a single request.
 type RequestDetail struct {
-	Timestamp time.Time  `json:"timestamp"`
-	Source    string     `json:"source"`
-	AuthIndex string     `json:"auth_index"`
-	Tokens    TokenStats `json:"tokens"`
-	Failed    bool       `json:"failed"`
+	Timestamp       time.Time     `json:"timestamp"`
+	Source          string        `json:"source"`
+	AuthIndex       string        `json:"auth_index"`
+	RequestID       string        `json:"request_id,omitempty"`
+	ParentRequestID string        `json:"parent_request_id,omitempty"`
+	Variant         string        `json:"variant,omitempty"`
+	Experiment      string        `json:"experiment,omitempty"`
+	Arm             string        `json:"arm,omitempty"`
+	Recovery        string        `json:"recovery,omitempty"`
+	Tokens          TokenStats    `json:"tokens"`
+	Latency         *LatencyStats `json:"latency,omitempty"`
+	Failed          bool          `json:"failed"`
+	Estimated       bool          `json:"estimated,omitempty"`
+}
+
+// LatencyStats captures the timing of the attempt that served a request.
+type LatencyStats struct {
+	TimeToFirstByteMs     int64   `json:"ttfb_ms,omitempty"`
+	DurationMs            int64   `json:"duration_ms"`
+	OutputTokensPerSecond float64 `json:"output_tokens_per_second,omitempty"`
+	Retries               int     `json:"retries,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -143,6 +161,7 @@ func GetRequestStatistics() *RequestStatistics { return defaultRequestStatistics
 func NewRequestStatistics() *RequestStatistics {
 	return &RequestStatistics{
 		apis:           make(map[string]*apiStats),
+		auths:          make(map[string]*authStats),
 		requestsByDay:  make(map[string]int64),
 		requestsByHour: make(map[int]int64),
 		tokensByDay:    make(map[string]int64),
@@ -196,13 +215,23 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		stats = &apiStats{Models: make(map[string]*modelStats)}
 		s.apis[statsKey] = stats
 	}
+	latency := latencyFromDetail(record.Detail)
 	s.updateAPIStats(stats, modelName, RequestDetail{
-		Timestamp: timestamp,
-		Source:    record.Source,
-		AuthIndex: record.AuthIndex,
-		Tokens:    detail,
-		Failed:    failed,
+		Timestamp:       timestamp,
+		Source:          record.Source,
+		AuthIndex:       record.AuthIndex,
+		RequestID:       record.RequestID,
+		ParentRequestID: record.ParentRequestID,
+		Variant:         record.Variant,
+		Experiment:      record.Experiment,
+		Arm:             record.Arm,
+		Recovery:        record.Recovery,
+		Tokens:          detail,
+		Latency:         latency,
+		Failed:          failed,
+		Estimated:       record.Estimated,
 	})
+	s.recordAuth(record.AuthIndex, timestamp, failed, totalTokens, latency)
 
 	s.requestsByDay[dayKey]++
 	s.requestsByHour[hourKey]++
@@ -379,12 +408,13 @@ func dedupKey(apiName, modelName string, detail RequestDetail) string {
 	timestamp := detail.Timestamp.UTC().Format(time.RFC3339Nano)
 	tokens := normaliseTokenStats(detail.Tokens)
 	return fmt.Sprintf(
-		"%s|%s|%s|%s|%s|%t|%d|%d|%d|%d|%d",
+		"%s|%s|%s|%s|%s|%s|%t|%d|%d|%d|%d|%d",
 		apiName,
 		modelName,
 		timestamp,
 		detail.Source,
 		detail.AuthIndex,
+		detail.RequestID,
 		detail.Failed,
 		tokens.InputTokens,
 		tokens.OutputTokens,
@@ -453,6 +483,20 @@ func normaliseDetail(detail coreusage.Detail) TokenStats {
 	return tokens
 }
 
+// latencyFromDetail returns nil for records that carry no timing, such as
+// those published by third-party executors.
+func latencyFromDetail(detail coreusage.Detail) *LatencyStats {
+	if detail.Duration <= 0 && detail.TimeToFirstByte <= 0 && detail.Retries == 0 {
+		return nil
+	}
+	return &LatencyStats{
+		TimeToFirstByteMs:     detail.TimeToFirstByte.Milliseconds(),
+		DurationMs:            detail.Duration.Milliseconds(),
+		OutputTokensPerSecond: detail.OutputTokensPerSecond,
+		Retries:               detail.Retries,
+	}
+}
+
 func normaliseTokenStats(tokens TokenStats) TokenStats {
 	if tokens.TotalTokens == 0 {
 		tokens.TotalTokens = tokens.InputTokens + tokens.OutputTokens + tokens.ReasoningTokens
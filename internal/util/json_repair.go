@@ -0,0 +1,154 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// RepairJSON fixes the malformed JSON upstreams commonly emit in tool-call
+// arguments: trailing commas, raw control characters and unescaped quotes
+// inside strings, and objects or arrays cut off before their end. Valid input
+// is returned unchanged. The second result reports whether the input was
+// repaired; when no valid JSON could be recovered the input is returned as-is.
+//
+// Examples:
+//
+//	{"a": 1, "b": [1, 2,],}        => {"a": 1, "b": [1, 2]}
+//	{"q": "say "hi" now"}          => {"q": "say \"hi\" now"}
+//	{"path": "/tmp/x", "items": [1 => {"path": "/tmp/x", "items": [1]}
+func RepairJSON(input string) (string, bool) {
+	if json.Valid([]byte(input)) {
+		return input, false
+	}
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return input, false
+	}
+
+	var (
+		out      bytes.Buffer
+		stack    []byte
+		inString bool
+		escaped  bool
+	)
+	// lastSignificant returns the last non-space byte written outside strings.
+	lastSignificant := func() (byte, int) {
+		b := out.Bytes()
+		for i := len(b) - 1; i >= 0; i-- {
+			switch b[i] {
+			case ' ', '\t', '\n', '\r':
+				continue
+			}
+			return b[i], i
+		}
+		return 0, -1
+	}
+	dropTrailingComma := func() {
+		if c, i := lastSignificant(); c == ',' {
+			out.Truncate(i)
+		}
+	}
+
+	for i := 0; i < len(trimmed); i++ {
+		c := trimmed[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+				out.WriteByte(c)
+			case c == '\\':
+				escaped = true
+				out.WriteByte(c)
+			case c == '"':
+				if closesString(trimmed[i+1:]) {
+					inString = false
+					out.WriteByte(c)
+				} else {
+					out.WriteString(`\"`)
+				}
+			case c == '\n':
+				out.WriteString(`\n`)
+			case c == '\r':
+				out.WriteString(`\r`)
+			case c == '\t':
+				out.WriteString(`\t`)
+			default:
+				out.WriteByte(c)
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			stack = append(stack, c)
+		case '}', ']':
+			dropTrailingComma()
+			if last, _ := lastSignificant(); last == ':' {
+				out.WriteString("null")
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		out.WriteByte(c)
+	}
+
+	// Close whatever the input left open.
+	if inString {
+		if escaped {
+			out.Truncate(out.Len() - 1)
+		}
+		out.WriteByte('"')
+	}
+	for len(stack) > 0 {
+		dropTrailingComma()
+		if c, _ := lastSignificant(); c == ':' {
+			out.WriteString("null")
+		} else if stack[len(stack)-1] == '{' && c == '"' && expectsColon(out.Bytes()) {
+			out.WriteString(":null")
+		}
+		if stack[len(stack)-1] == '{' {
+			out.WriteByte('}')
+		} else {
+			out.WriteByte(']')
+		}
+		stack = stack[:len(stack)-1]
+	}
+
+	repaired := out.String()
+	if !json.Valid([]byte(repaired)) {
+		return input, false
+	}
+	return repaired, true
+}
+
+// closesString reports whether a quote followed by rest ends the current
+// string rather than being an unescaped quote inside it.
+func closesString(rest string) bool {
+	rest = strings.TrimLeft(rest, " \t\r\n")
+	return rest == "" || strings.ContainsRune(",:}]", rune(rest[0]))
+}
+
+// expectsColon reports whether the string that ends b is an object key still
+// waiting for its value, i.e. it follows '{' or ','.
+func expectsColon(b []byte) bool {
+	// Skip back over the closing quote and the string itself.
+	i := len(b) - 2
+	for ; i >= 0; i-- {
+		if b[i] == '"' && (i == 0 || b[i-1] != '\\') {
+			break
+		}
+	}
+	for i--; i >= 0; i-- {
+		switch b[i] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case '{', ',':
+			return true
+		}
+		return false
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	cases := []struct {
+		name, input, want string
+		repaired          bool
+	}{
+		{"valid", `{"a": [1, 2]}`, `{"a": [1, 2]}`, false},
+		{"trailing commas", `{"a": 1, "b": [1, 2,],}`, `{"a": 1, "b": [1, 2]}`, true},
+		{"unescaped quotes", `{"q": "say "hi" now"}`, `{"q": "say \"hi\" now"}`, true},
+		{"raw newline", "{\"code\": \"a\nb\"}", `{"code": "a\nb"}`, true},
+		{"truncated array", `{"path": "/tmp/x", "items": [1`, `{"path": "/tmp/x", "items": [1]}`, true},
+		{"truncated string", `{"cmd": "ls -l`, `{"cmd": "ls -l"}`, true},
+		{"truncated after colon", `{"a": 1, "b":`, `{"a": 1, "b":null}`, true},
+		{"truncated after key", `{"a": 1, "b"`, `{"a": 1, "b":null}`, true},
+		{"truncated after comma", `[{"a": 1},`, `[{"a": 1}]`, true},
+		{"not json", `hello`, `hello`, false},
+		{"unrecoverable literal", `{"a": tru`, `{"a": tru`, false},
+	}
+	for _, tc := range cases {
+		got, repaired := RepairJSON(tc.input)
+		if got != tc.want || repaired != tc.repaired {
+			t.Errorf("%s: RepairJSON(%q) = %q, %t; want %q, %t", tc.name, tc.input, got, repaired, tc.want, tc.repaired)
+		}
+		if repaired && !json.Valid([]byte(got)) {
+			t.Errorf("%s: repaired output %q is not valid JSON", tc.name, got)
+		}
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -54,23 +53,6 @@ func SanitizeFunctionName(name string) string {
 	return sanitized
 }
 
-// SetLogLevel configures the logrus log level based on the configuration.
-// It sets the log level to DebugLevel if debug mode is enabled, otherwise to InfoLevel.
-func SetLogLevel(cfg *config.Config) {
-	currentLevel := log.GetLevel()
-	var newLevel log.Level
-	if cfg.Debug {
-		newLevel = log.DebugLevel
-	} else {
-		newLevel = log.InfoLevel
-	}
-
-	if currentLevel != newLevel {
-		log.SetLevel(newLevel)
-		log.Infof("log level changed from %s to %s (debug=%t)", currentLevel, newLevel, cfg.Debug)
-	}
-}
-
 // ResolveAuthDir normalizes the auth directory path for consistent reuse throughout the app.
 // It expands a leading tilde (~) to the user's home directory and returns a cleaned path.
 func ResolveAuthDir(authDir string) (string, error) {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
@@ -21,6 +22,14 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.Port != newCfg.Port {
 		changes = append(changes, fmt.Sprintf("port: %d -> %d", oldCfg.Port, newCfg.Port))
 	}
+	if !reflect.DeepEqual(oldCfg.ClientIP, newCfg.ClientIP) {
+		changes = append(changes, fmt.Sprintf("client-ip: proxy-protocol %t -> %t, trusted-proxies %d -> %d, forwarded-for-depth %d -> %d",
+			oldCfg.ClientIP.ProxyProtocol, newCfg.ClientIP.ProxyProtocol, len(oldCfg.ClientIP.TrustedProxies), len(newCfg.ClientIP.TrustedProxies), oldCfg.ClientIP.ForwardedForDepth, newCfg.ClientIP.ForwardedForDepth))
+	}
+	if oldCfg.Listen != newCfg.Listen {
+		changes = append(changes, fmt.Sprintf("listen: unix-socket %q -> %q, systemd-activation %t -> %t, disable-tcp %t -> %t",
+			oldCfg.Listen.UnixSocket, newCfg.Listen.UnixSocket, oldCfg.Listen.SystemdActivation, newCfg.Listen.SystemdActivation, oldCfg.Listen.DisableTCP, newCfg.Listen.DisableTCP))
+	}
 	if oldCfg.AuthDir != newCfg.AuthDir {
 		changes = append(changes, fmt.Sprintf("auth-dir: %s -> %s", oldCfg.AuthDir, newCfg.AuthDir))
 	}
@@ -33,12 +42,99 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if strings.TrimSpace(oldCfg.Pprof.Addr) != strings.TrimSpace(newCfg.Pprof.Addr) {
 		changes = append(changes, fmt.Sprintf("pprof.addr: %s -> %s", strings.TrimSpace(oldCfg.Pprof.Addr), strings.TrimSpace(newCfg.Pprof.Addr)))
 	}
+	if oldCfg.SharedState.Backend != newCfg.SharedState.Backend {
+		changes = append(changes, fmt.Sprintf("shared-state.backend: %s -> %s", oldCfg.SharedState.Backend, newCfg.SharedState.Backend))
+	}
+	if oldCfg.SharedState.RedisURL != newCfg.SharedState.RedisURL {
+		changes = append(changes, "shared-state.redis-url: updated")
+	}
+	if oldCfg.SharedState.KeyPrefix != newCfg.SharedState.KeyPrefix {
+		changes = append(changes, fmt.Sprintf("shared-state.key-prefix: %s -> %s", oldCfg.SharedState.KeyPrefix, newCfg.SharedState.KeyPrefix))
+	}
+	if oldCfg.SharedState.StickySessionTTL != newCfg.SharedState.StickySessionTTL {
+		changes = append(changes, fmt.Sprintf("shared-state.sticky-session-ttl: %d -> %d", oldCfg.SharedState.StickySessionTTL, newCfg.SharedState.StickySessionTTL))
+	}
+	if oldCfg.SharedState.LeaderElection != newCfg.SharedState.LeaderElection {
+		changes = append(changes, fmt.Sprintf("shared-state.leader-election: %t -> %t", oldCfg.SharedState.LeaderElection, newCfg.SharedState.LeaderElection))
+	}
 	if oldCfg.LoggingToFile != newCfg.LoggingToFile {
 		changes = append(changes, fmt.Sprintf("logging-to-file: %t -> %t", oldCfg.LoggingToFile, newCfg.LoggingToFile))
 	}
 	if oldCfg.UsageStatisticsEnabled != newCfg.UsageStatisticsEnabled {
 		changes = append(changes, fmt.Sprintf("usage-statistics-enabled: %t -> %t", oldCfg.UsageStatisticsEnabled, newCfg.UsageStatisticsEnabled))
 	}
+	if oldCfg.Disconnect != newCfg.Disconnect {
+		changes = append(changes, fmt.Sprintf("disconnect: policy %s -> %s, result-ttl %d -> %d", oldCfg.Disconnect.Policy, newCfg.Disconnect.Policy, oldCfg.Disconnect.ResultTTL, newCfg.Disconnect.ResultTTL))
+	}
+	if oldCfg.RequestDedup != newCfg.RequestDedup {
+		changes = append(changes, fmt.Sprintf("request-dedup.window-seconds: %d -> %d", oldCfg.RequestDedup.WindowSeconds, newCfg.RequestDedup.WindowSeconds))
+	}
+	if oldCfg.RateLimitQueue.MaxWaitSeconds != newCfg.RateLimitQueue.MaxWaitSeconds || oldCfg.RateLimitQueue.KeepAliveSeconds != newCfg.RateLimitQueue.KeepAliveSeconds {
+		changes = append(changes, fmt.Sprintf("rate-limit-queue: max-wait-seconds %d -> %d, keepalive-seconds %d -> %d", oldCfg.RateLimitQueue.MaxWaitSeconds, newCfg.RateLimitQueue.MaxWaitSeconds, oldCfg.RateLimitQueue.KeepAliveSeconds, newCfg.RateLimitQueue.KeepAliveSeconds))
+	}
+	if !reflect.DeepEqual(oldCfg.RateLimitQueue.KeyWeights, newCfg.RateLimitQueue.KeyWeights) {
+		changes = append(changes, fmt.Sprintf("rate-limit-queue.key-weights: updated (%d -> %d entries)", len(oldCfg.RateLimitQueue.KeyWeights), len(newCfg.RateLimitQueue.KeyWeights)))
+	}
+	if oldCfg.RateLimitHeaders != newCfg.RateLimitHeaders {
+		changes = append(changes, fmt.Sprintf("rate-limit-headers: %t -> %t", oldCfg.RateLimitHeaders, newCfg.RateLimitHeaders))
+	}
+	if oldCfg.ToolCallJSONRepair != newCfg.ToolCallJSONRepair {
+		changes = append(changes, fmt.Sprintf("tool-call-json-repair: %t -> %t", oldCfg.ToolCallJSONRepair, newCfg.ToolCallJSONRepair))
+	}
+	if oldCfg.QualityGuard != newCfg.QualityGuard {
+		changes = append(changes, fmt.Sprintf("quality-guard: max-retries %d -> %d, same-credential-retries %d -> %d", oldCfg.QualityGuard.MaxRetries, newCfg.QualityGuard.MaxRetries, oldCfg.QualityGuard.SameCredentialRetries, newCfg.QualityGuard.SameCredentialRetries))
+	}
+	if oldCfg.Artifacts != newCfg.Artifacts {
+		changes = append(changes, fmt.Sprintf("artifacts: dir %q -> %q, min-bytes %d -> %d, max-age-hours %d -> %d", oldCfg.Artifacts.Dir, newCfg.Artifacts.Dir, oldCfg.Artifacts.MinBytes, newCfg.Artifacts.MinBytes, oldCfg.Artifacts.MaxAgeHours, newCfg.Artifacts.MaxAgeHours))
+	}
+	if oldCfg.Jobs.MaxConcurrent != newCfg.Jobs.MaxConcurrent {
+		changes = append(changes, fmt.Sprintf("jobs.max-concurrent: %d -> %d", oldCfg.Jobs.MaxConcurrent, newCfg.Jobs.MaxConcurrent))
+	}
+	if !reflect.DeepEqual(oldCfg.Jobs.WebhookHosts, newCfg.Jobs.WebhookHosts) {
+		changes = append(changes, fmt.Sprintf("jobs.webhook-hosts: updated (%d -> %d entries)", len(oldCfg.Jobs.WebhookHosts), len(newCfg.Jobs.WebhookHosts)))
+	}
+	if oldCfg.Compression != newCfg.Compression {
+		changes = append(changes, fmt.Sprintf("compression: upstream %t -> %t, client %t -> %t, min-bytes %d -> %d", oldCfg.Compression.Upstream, newCfg.Compression.Upstream, oldCfg.Compression.Client, newCfg.Compression.Client, oldCfg.Compression.MinBytes, newCfg.Compression.MinBytes))
+	}
+	if oldCfg.ResponseSpill != newCfg.ResponseSpill {
+		changes = append(changes, fmt.Sprintf("response-spill: threshold-bytes %d -> %d, dir %q -> %q", oldCfg.ResponseSpill.ThresholdBytes, newCfg.ResponseSpill.ThresholdBytes, oldCfg.ResponseSpill.Dir, newCfg.ResponseSpill.Dir))
+	}
+	if oldCfg.ResourceLimits != newCfg.ResourceLimits {
+		changes = append(changes, fmt.Sprintf("resource-limits: max-procs %d -> %d, memory-limit-ratio %g -> %g, pressure-ratio %g -> %g, retry-after-seconds %d -> %d", oldCfg.ResourceLimits.MaxProcs, newCfg.ResourceLimits.MaxProcs, oldCfg.ResourceLimits.MemoryLimitRatio, newCfg.ResourceLimits.MemoryLimitRatio, oldCfg.ResourceLimits.PressureRatio, newCfg.ResourceLimits.PressureRatio, oldCfg.ResourceLimits.RetryAfterSeconds, newCfg.ResourceLimits.RetryAfterSeconds))
+	}
+	if oldCfg.LogRetention.RequestLogs != newCfg.LogRetention.RequestLogs {
+		changes = append(changes, fmt.Sprintf("log-retention.request-logs: %+v -> %+v", oldCfg.LogRetention.RequestLogs, newCfg.LogRetention.RequestLogs))
+	}
+	if oldCfg.LogRetention.AuditLog != newCfg.LogRetention.AuditLog {
+		changes = append(changes, fmt.Sprintf("log-retention.audit-log: %+v -> %+v", oldCfg.LogRetention.AuditLog, newCfg.LogRetention.AuditLog))
+	}
+	if oldCfg.Maintenance != newCfg.Maintenance {
+		changes = append(changes, fmt.Sprintf("maintenance: enable %t -> %t, message %q -> %q, retry-after-seconds %d -> %d", oldCfg.Maintenance.Enable, newCfg.Maintenance.Enable, oldCfg.Maintenance.Message, newCfg.Maintenance.Message, oldCfg.Maintenance.RetryAfterSeconds, newCfg.Maintenance.RetryAfterSeconds))
+	}
+	if !reflect.DeepEqual(oldCfg.ShadowTraffic, newCfg.ShadowTraffic) {
+		changes = append(changes, fmt.Sprintf("shadow-traffic: rules %d -> %d, store-dir %q -> %q, max-concurrent %d -> %d", len(oldCfg.ShadowTraffic.Rules), len(newCfg.ShadowTraffic.Rules), oldCfg.ShadowTraffic.StoreDir, newCfg.ShadowTraffic.StoreDir, oldCfg.ShadowTraffic.MaxConcurrent, newCfg.ShadowTraffic.MaxConcurrent))
+	}
+	if !reflect.DeepEqual(oldCfg.Experiments, newCfg.Experiments) {
+		changes = append(changes, fmt.Sprintf("experiments: updated (%d -> %d experiments)", len(oldCfg.Experiments), len(newCfg.Experiments)))
+	}
+	if !reflect.DeepEqual(oldCfg.SmartRouters, newCfg.SmartRouters) {
+		changes = append(changes, fmt.Sprintf("smart-routers: updated (%d -> %d routers)", len(oldCfg.SmartRouters), len(newCfg.SmartRouters)))
+	}
+	if !reflect.DeepEqual(oldCfg.PromptTemplates, newCfg.PromptTemplates) {
+		changes = append(changes, fmt.Sprintf("prompt-templates: updated (%d -> %d templates)", len(oldCfg.PromptTemplates), len(newCfg.PromptTemplates)))
+	}
+	if oldCfg.Streaming.Sanitize != newCfg.Streaming.Sanitize {
+		changes = append(changes, fmt.Sprintf("streaming.sanitize: %q -> %q", oldCfg.Streaming.Sanitize, newCfg.Streaming.Sanitize))
+	}
+	if oldCfg.Streaming.ToolCallDeltas != newCfg.Streaming.ToolCallDeltas {
+		changes = append(changes, fmt.Sprintf("streaming.tool-call-deltas: %q -> %q", oldCfg.Streaming.ToolCallDeltas, newCfg.Streaming.ToolCallDeltas))
+	}
+	if oldCfg.UsageStreaming != newCfg.UsageStreaming {
+		changes = append(changes, fmt.Sprintf("usage-streaming: interval %d -> %d, tokens %d -> %d", oldCfg.UsageStreaming.Interval, newCfg.UsageStreaming.Interval, oldCfg.UsageStreaming.Tokens, newCfg.UsageStreaming.Tokens))
+	}
+	if oldCfg.UsageAnomaly != newCfg.UsageAnomaly {
+		changes = append(changes, "usage-anomaly: updated")
+	}
 	if oldCfg.DisableCooling != newCfg.DisableCooling {
 		changes = append(changes, fmt.Sprintf("disable-cooling: %t -> %t", oldCfg.DisableCooling, newCfg.DisableCooling))
 	}
@@ -51,6 +147,23 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.ErrorLogsMaxFiles != newCfg.ErrorLogsMaxFiles {
 		changes = append(changes, fmt.Sprintf("error-logs-max-files: %d -> %d", oldCfg.ErrorLogsMaxFiles, newCfg.ErrorLogsMaxFiles))
 	}
+	if oldCfg.Logging.Format != newCfg.Logging.Format {
+		changes = append(changes, fmt.Sprintf("logging.format: %s -> %s", oldCfg.Logging.Format, newCfg.Logging.Format))
+	}
+	if oldCfg.Logging.Levels != newCfg.Logging.Levels {
+		changes = append(changes, fmt.Sprintf("logging.levels: %+v -> %+v", oldCfg.Logging.Levels, newCfg.Logging.Levels))
+	}
+	if oldCfg.SlowRequestThresholdMs != newCfg.SlowRequestThresholdMs {
+		changes = append(changes, fmt.Sprintf("slow-request-threshold-ms: %d -> %d", oldCfg.SlowRequestThresholdMs, newCfg.SlowRequestThresholdMs))
+	}
+	if !reflect.DeepEqual(oldCfg.CORS, newCfg.CORS) {
+		changes = append(changes, fmt.Sprintf("cors: allowed-origins %d -> %d, allow-credentials %t -> %t, management override %t -> %t",
+			len(oldCfg.CORS.AllowedOrigins), len(newCfg.CORS.AllowedOrigins), oldCfg.CORS.AllowCredentials, newCfg.CORS.AllowCredentials, oldCfg.CORS.Management != nil, newCfg.CORS.Management != nil))
+	}
+	if oldCfg.Forensics != newCfg.Forensics {
+		changes = append(changes, fmt.Sprintf("forensics: enable %t -> %t, ttl %d -> %d, max-body-bytes %d -> %d",
+			oldCfg.Forensics.Enable, newCfg.Forensics.Enable, oldCfg.Forensics.TTL, newCfg.Forensics.TTL, oldCfg.Forensics.MaxBodyBytes, newCfg.Forensics.MaxBodyBytes))
+	}
 	if oldCfg.RequestRetry != newCfg.RequestRetry {
 		changes = append(changes, fmt.Sprintf("request-retry: %d -> %d", oldCfg.RequestRetry, newCfg.RequestRetry))
 	}
@@ -69,6 +182,12 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.ForceModelPrefix != newCfg.ForceModelPrefix {
 		changes = append(changes, fmt.Sprintf("force-model-prefix: %t -> %t", oldCfg.ForceModelPrefix, newCfg.ForceModelPrefix))
 	}
+	if oldCfg.EmbeddingCache.Dir != newCfg.EmbeddingCache.Dir || oldCfg.EmbeddingCache.MaxEntries != newCfg.EmbeddingCache.MaxEntries || oldCfg.EmbeddingCache.Similarity != newCfg.EmbeddingCache.Similarity {
+		changes = append(changes, fmt.Sprintf("embedding-cache: dir %q -> %q, max-entries %d -> %d, similarity %.2f -> %.2f", oldCfg.EmbeddingCache.Dir, newCfg.EmbeddingCache.Dir, oldCfg.EmbeddingCache.MaxEntries, newCfg.EmbeddingCache.MaxEntries, oldCfg.EmbeddingCache.Similarity, newCfg.EmbeddingCache.Similarity))
+	}
+	if oldCfg.ImagePreprocess.Enable != newCfg.ImagePreprocess.Enable || oldCfg.ImagePreprocess.MaxDimension != newCfg.ImagePreprocess.MaxDimension || oldCfg.ImagePreprocess.MaxBytes != newCfg.ImagePreprocess.MaxBytes {
+		changes = append(changes, fmt.Sprintf("image-preprocess: enable %t -> %t, max-dimension %d -> %d, max-bytes %d -> %d", oldCfg.ImagePreprocess.Enable, newCfg.ImagePreprocess.Enable, oldCfg.ImagePreprocess.MaxDimension, newCfg.ImagePreprocess.MaxDimension, oldCfg.ImagePreprocess.MaxBytes, newCfg.ImagePreprocess.MaxBytes))
+	}
 	if oldCfg.NonStreamKeepAliveInterval != newCfg.NonStreamKeepAliveInterval {
 		changes = append(changes, fmt.Sprintf("nonstream-keepalive-interval: %d -> %d", oldCfg.NonStreamKeepAliveInterval, newCfg.NonStreamKeepAliveInterval))
 	}
@@ -84,6 +203,12 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if oldCfg.Routing.Strategy != newCfg.Routing.Strategy {
 		changes = append(changes, fmt.Sprintf("routing.strategy: %s -> %s", oldCfg.Routing.Strategy, newCfg.Routing.Strategy))
 	}
+	if oldCfg.Routing.AntigravityAutoTier != newCfg.Routing.AntigravityAutoTier {
+		changes = append(changes, fmt.Sprintf("routing.antigravity-auto-tier: %t -> %t", oldCfg.Routing.AntigravityAutoTier, newCfg.Routing.AntigravityAutoTier))
+	}
+	if oldCfg.Routing.ModelUnavailableCacheSeconds != newCfg.Routing.ModelUnavailableCacheSeconds {
+		changes = append(changes, fmt.Sprintf("routing.model-unavailable-cache-seconds: %d -> %d", oldCfg.Routing.ModelUnavailableCacheSeconds, newCfg.Routing.ModelUnavailableCacheSeconds))
+	}
 
 	// API keys (redacted) and counts
 	if len(oldCfg.APIKeys) != len(newCfg.APIKeys) {
@@ -100,6 +225,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.BaseURL) != strings.TrimSpace(n.BaseURL) {
 				changes = append(changes, fmt.Sprintf("gemini[%d].base-url: %s -> %s", i, strings.TrimSpace(o.BaseURL), strings.TrimSpace(n.BaseURL)))
 			}
+			if strings.TrimSpace(o.APIVersion) != strings.TrimSpace(n.APIVersion) {
+				changes = append(changes, fmt.Sprintf("gemini[%d].api-version: %s -> %s", i, strings.TrimSpace(o.APIVersion), strings.TrimSpace(n.APIVersion)))
+			}
 			if strings.TrimSpace(o.ProxyURL) != strings.TrimSpace(n.ProxyURL) {
 				changes = append(changes, fmt.Sprintf("gemini[%d].proxy-url: %s -> %s", i, formatProxyURL(o.ProxyURL), formatProxyURL(n.ProxyURL)))
 			}
@@ -112,6 +240,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if !equalStringMap(o.Headers, n.Headers) {
 				changes = append(changes, fmt.Sprintf("gemini[%d].headers: updated", i))
 			}
+			if strings.TrimSpace(o.ClientProfile) != strings.TrimSpace(n.ClientProfile) {
+				changes = append(changes, fmt.Sprintf("gemini[%d].client-profile: %s -> %s", i, strings.TrimSpace(o.ClientProfile), strings.TrimSpace(n.ClientProfile)))
+			}
+			if strings.TrimSpace(o.Schedule) != strings.TrimSpace(n.Schedule) {
+				changes = append(changes, fmt.Sprintf("gemini[%d].schedule: %s -> %s", i, strings.TrimSpace(o.Schedule), strings.TrimSpace(n.Schedule)))
+			}
+			if !reflect.DeepEqual(o.Labels, n.Labels) {
+				changes = append(changes, fmt.Sprintf("gemini[%d].labels: updated", i))
+			}
 			oldModels := SummarizeGeminiModels(o.Models)
 			newModels := SummarizeGeminiModels(n.Models)
 			if oldModels.hash != newModels.hash {
@@ -135,6 +272,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.BaseURL) != strings.TrimSpace(n.BaseURL) {
 				changes = append(changes, fmt.Sprintf("claude[%d].base-url: %s -> %s", i, strings.TrimSpace(o.BaseURL), strings.TrimSpace(n.BaseURL)))
 			}
+			if strings.TrimSpace(o.APIVersion) != strings.TrimSpace(n.APIVersion) {
+				changes = append(changes, fmt.Sprintf("claude[%d].api-version: %s -> %s", i, strings.TrimSpace(o.APIVersion), strings.TrimSpace(n.APIVersion)))
+			}
 			if strings.TrimSpace(o.ProxyURL) != strings.TrimSpace(n.ProxyURL) {
 				changes = append(changes, fmt.Sprintf("claude[%d].proxy-url: %s -> %s", i, formatProxyURL(o.ProxyURL), formatProxyURL(n.ProxyURL)))
 			}
@@ -147,6 +287,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if !equalStringMap(o.Headers, n.Headers) {
 				changes = append(changes, fmt.Sprintf("claude[%d].headers: updated", i))
 			}
+			if strings.TrimSpace(o.ClientProfile) != strings.TrimSpace(n.ClientProfile) {
+				changes = append(changes, fmt.Sprintf("claude[%d].client-profile: %s -> %s", i, strings.TrimSpace(o.ClientProfile), strings.TrimSpace(n.ClientProfile)))
+			}
+			if strings.TrimSpace(o.Schedule) != strings.TrimSpace(n.Schedule) {
+				changes = append(changes, fmt.Sprintf("claude[%d].schedule: %s -> %s", i, strings.TrimSpace(o.Schedule), strings.TrimSpace(n.Schedule)))
+			}
+			if !reflect.DeepEqual(o.Labels, n.Labels) {
+				changes = append(changes, fmt.Sprintf("claude[%d].labels: updated", i))
+			}
 			oldModels := SummarizeClaudeModels(o.Models)
 			newModels := SummarizeClaudeModels(n.Models)
 			if oldModels.hash != newModels.hash {
@@ -181,6 +330,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.BaseURL) != strings.TrimSpace(n.BaseURL) {
 				changes = append(changes, fmt.Sprintf("codex[%d].base-url: %s -> %s", i, strings.TrimSpace(o.BaseURL), strings.TrimSpace(n.BaseURL)))
 			}
+			if strings.TrimSpace(o.APIVersion) != strings.TrimSpace(n.APIVersion) {
+				changes = append(changes, fmt.Sprintf("codex[%d].api-version: %s -> %s", i, strings.TrimSpace(o.APIVersion), strings.TrimSpace(n.APIVersion)))
+			}
 			if strings.TrimSpace(o.ProxyURL) != strings.TrimSpace(n.ProxyURL) {
 				changes = append(changes, fmt.Sprintf("codex[%d].proxy-url: %s -> %s", i, formatProxyURL(o.ProxyURL), formatProxyURL(n.ProxyURL)))
 			}
@@ -196,6 +348,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if !equalStringMap(o.Headers, n.Headers) {
 				changes = append(changes, fmt.Sprintf("codex[%d].headers: updated", i))
 			}
+			if strings.TrimSpace(o.ClientProfile) != strings.TrimSpace(n.ClientProfile) {
+				changes = append(changes, fmt.Sprintf("codex[%d].client-profile: %s -> %s", i, strings.TrimSpace(o.ClientProfile), strings.TrimSpace(n.ClientProfile)))
+			}
+			if strings.TrimSpace(o.Schedule) != strings.TrimSpace(n.Schedule) {
+				changes = append(changes, fmt.Sprintf("codex[%d].schedule: %s -> %s", i, strings.TrimSpace(o.Schedule), strings.TrimSpace(n.Schedule)))
+			}
+			if !reflect.DeepEqual(o.Labels, n.Labels) {
+				changes = append(changes, fmt.Sprintf("codex[%d].labels: updated", i))
+			}
 			oldModels := SummarizeCodexModels(o.Models)
 			newModels := SummarizeCodexModels(n.Models)
 			if oldModels.hash != newModels.hash {
@@ -248,11 +409,77 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if entries, _ := DiffOAuthModelAliasChanges(oldCfg.OAuthModelAlias, newCfg.OAuthModelAlias); len(entries) > 0 {
 		changes = append(changes, entries...)
 	}
+	if !reflect.DeepEqual(oldCfg.ProviderHeaders, newCfg.ProviderHeaders) {
+		// Header values may carry gateway credentials, so only report the shape.
+		changes = append(changes, fmt.Sprintf("provider-headers: updated (%d -> %d providers)", len(oldCfg.ProviderHeaders), len(newCfg.ProviderHeaders)))
+	}
+	if !reflect.DeepEqual(oldCfg.APIVersions, newCfg.APIVersions) {
+		providers := make([]string, 0, len(oldCfg.APIVersions)+len(newCfg.APIVersions))
+		for provider := range oldCfg.APIVersions {
+			providers = append(providers, provider)
+		}
+		for provider := range newCfg.APIVersions {
+			if _, seen := oldCfg.APIVersions[provider]; !seen {
+				providers = append(providers, provider)
+			}
+		}
+		sort.Strings(providers)
+		for _, provider := range providers {
+			if oldVersion, newVersion := oldCfg.APIVersions[provider], newCfg.APIVersions[provider]; oldVersion != newVersion {
+				changes = append(changes, fmt.Sprintf("api-versions.%s: %q -> %q", provider, oldVersion, newVersion))
+			}
+		}
+	}
+	if !reflect.DeepEqual(oldCfg.ClientProfiles, newCfg.ClientProfiles) {
+		changes = append(changes, fmt.Sprintf("client-profiles: updated (%d -> %d profiles)", len(oldCfg.ClientProfiles), len(newCfg.ClientProfiles)))
+	}
+	if !reflect.DeepEqual(oldCfg.APIKeyModels, newCfg.APIKeyModels) {
+		changes = append(changes, fmt.Sprintf("api-key-models: updated (%d -> %d entries)", len(oldCfg.APIKeyModels), len(newCfg.APIKeyModels)))
+	}
+	if !reflect.DeepEqual(oldCfg.APIKeyProfiles, newCfg.APIKeyProfiles) {
+		changes = append(changes, fmt.Sprintf("api-key-profiles: updated (%d -> %d entries)", len(oldCfg.APIKeyProfiles), len(newCfg.APIKeyProfiles)))
+	}
+	if !reflect.DeepEqual(oldCfg.APIKeyLimits, newCfg.APIKeyLimits) {
+		changes = append(changes, fmt.Sprintf("api-key-limits: updated (%d -> %d entries)", len(oldCfg.APIKeyLimits), len(newCfg.APIKeyLimits)))
+	}
+	if !reflect.DeepEqual(oldCfg.ModelPrices, newCfg.ModelPrices) {
+		changes = append(changes, fmt.Sprintf("model-prices: updated (%d -> %d entries)", len(oldCfg.ModelPrices), len(newCfg.ModelPrices)))
+	}
+	if !reflect.DeepEqual(oldCfg.APIKeyReasoning, newCfg.APIKeyReasoning) {
+		changes = append(changes, fmt.Sprintf("api-key-reasoning: updated (%d -> %d entries)", len(oldCfg.APIKeyReasoning), len(newCfg.APIKeyReasoning)))
+	}
+	if !reflect.DeepEqual(oldCfg.LoopDetection, newCfg.LoopDetection) {
+		changes = append(changes, fmt.Sprintf("loop-detection: updated (%d -> %d entries)", len(oldCfg.LoopDetection), len(newCfg.LoopDetection)))
+	}
+	if !reflect.DeepEqual(oldCfg.ModelNamespaces, newCfg.ModelNamespaces) {
+		changes = append(changes, fmt.Sprintf("model-namespaces: updated (%d -> %d namespaces)", len(oldCfg.ModelNamespaces), len(newCfg.ModelNamespaces)))
+	}
+	if !reflect.DeepEqual(oldCfg.AuthSchedules, newCfg.AuthSchedules) {
+		changes = append(changes, fmt.Sprintf("auth-schedules: updated (%d -> %d schedules)", len(oldCfg.AuthSchedules), len(newCfg.AuthSchedules)))
+	}
+	if !reflect.DeepEqual(oldCfg.TTSVoices, newCfg.TTSVoices) {
+		changes = append(changes, fmt.Sprintf("tts-voices: updated (%d -> %d providers)", len(oldCfg.TTSVoices), len(newCfg.TTSVoices)))
+	}
+	if !reflect.DeepEqual(oldCfg.RequestSigning, newCfg.RequestSigning) {
+		changes = append(changes, fmt.Sprintf("request-signing: updated (%d -> %d signers)", len(oldCfg.RequestSigning), len(newCfg.RequestSigning)))
+	}
+	if !reflect.DeepEqual(oldCfg.Access.Providers, newCfg.Access.Providers) {
+		changes = append(changes, fmt.Sprintf("access.providers: updated (%d -> %d providers)", len(oldCfg.Access.Providers), len(newCfg.Access.Providers)))
+	}
+	if !reflect.DeepEqual(oldCfg.ExecutorPlugins, newCfg.ExecutorPlugins) {
+		changes = append(changes, fmt.Sprintf("executor-plugins: updated (%d -> %d plugins)", len(oldCfg.ExecutorPlugins), len(newCfg.ExecutorPlugins)))
+	}
 
 	// Remote management (never print the key)
 	if oldCfg.RemoteManagement.AllowRemote != newCfg.RemoteManagement.AllowRemote {
 		changes = append(changes, fmt.Sprintf("remote-management.allow-remote: %t -> %t", oldCfg.RemoteManagement.AllowRemote, newCfg.RemoteManagement.AllowRemote))
 	}
+	if oldCfg.RemoteManagement.ReadOnly != newCfg.RemoteManagement.ReadOnly {
+		changes = append(changes, fmt.Sprintf("remote-management.read-only: %t -> %t", oldCfg.RemoteManagement.ReadOnly, newCfg.RemoteManagement.ReadOnly))
+	}
+	if oldCfg.RemoteManagement.AuthTrashTTLHours != newCfg.RemoteManagement.AuthTrashTTLHours {
+		changes = append(changes, fmt.Sprintf("remote-management.auth-trash-ttl-hours: %d -> %d", oldCfg.RemoteManagement.AuthTrashTTLHours, newCfg.RemoteManagement.AuthTrashTTLHours))
+	}
 	if oldCfg.RemoteManagement.DisableControlPanel != newCfg.RemoteManagement.DisableControlPanel {
 		changes = append(changes, fmt.Sprintf("remote-management.disable-control-panel: %t -> %t", oldCfg.RemoteManagement.DisableControlPanel, newCfg.RemoteManagement.DisableControlPanel))
 	}
@@ -290,6 +517,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if strings.TrimSpace(o.BaseURL) != strings.TrimSpace(n.BaseURL) {
 				changes = append(changes, fmt.Sprintf("vertex[%d].base-url: %s -> %s", i, strings.TrimSpace(o.BaseURL), strings.TrimSpace(n.BaseURL)))
 			}
+			if strings.TrimSpace(o.APIVersion) != strings.TrimSpace(n.APIVersion) {
+				changes = append(changes, fmt.Sprintf("vertex[%d].api-version: %s -> %s", i, strings.TrimSpace(o.APIVersion), strings.TrimSpace(n.APIVersion)))
+			}
 			if strings.TrimSpace(o.ProxyURL) != strings.TrimSpace(n.ProxyURL) {
 				changes = append(changes, fmt.Sprintf("vertex[%d].proxy-url: %s -> %s", i, formatProxyURL(o.ProxyURL), formatProxyURL(n.ProxyURL)))
 			}
@@ -312,6 +542,15 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 			if !equalStringMap(o.Headers, n.Headers) {
 				changes = append(changes, fmt.Sprintf("vertex[%d].headers: updated", i))
 			}
+			if strings.TrimSpace(o.ClientProfile) != strings.TrimSpace(n.ClientProfile) {
+				changes = append(changes, fmt.Sprintf("vertex[%d].client-profile: %s -> %s", i, strings.TrimSpace(o.ClientProfile), strings.TrimSpace(n.ClientProfile)))
+			}
+			if strings.TrimSpace(o.Schedule) != strings.TrimSpace(n.Schedule) {
+				changes = append(changes, fmt.Sprintf("vertex[%d].schedule: %s -> %s", i, strings.TrimSpace(o.Schedule), strings.TrimSpace(n.Schedule)))
+			}
+			if !reflect.DeepEqual(o.Labels, n.Labels) {
+				changes = append(changes, fmt.Sprintf("vertex[%d].labels: updated", i))
+			}
 		}
 	}
 
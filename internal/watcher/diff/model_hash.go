@@ -20,7 +20,11 @@ func ComputeOpenAICompatModelsHash(models []config.OpenAICompatibilityModel) str
 			if name == "" && alias == "" {
 				continue
 			}
-			out(strings.ToLower(name) + "|" + strings.ToLower(alias))
+			key := strings.ToLower(name) + "|" + strings.ToLower(alias)
+			if mode := strings.TrimSpace(model.StreamMode); mode != "" {
+				key += "|" + strings.ToLower(mode)
+			}
+			out(key)
 		}
 	})
 	return hashJoined(keys)
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 
@@ -75,6 +76,15 @@ func describeOpenAICompatibilityUpdate(oldEntry, newEntry config.OpenAICompatibi
 	if !equalStringMap(oldEntry.Headers, newEntry.Headers) {
 		details = append(details, "headers updated")
 	}
+	if strings.TrimSpace(oldEntry.ClientProfile) != strings.TrimSpace(newEntry.ClientProfile) {
+		details = append(details, "client-profile updated")
+	}
+	if strings.TrimSpace(oldEntry.Schedule) != strings.TrimSpace(newEntry.Schedule) {
+		details = append(details, "schedule updated")
+	}
+	if !reflect.DeepEqual(oldEntry.Labels, newEntry.Labels) {
+		details = append(details, "labels updated")
+	}
 	if len(details) == 0 {
 		return ""
 	}
@@ -142,6 +152,9 @@ func openAICompatSignature(entry config.OpenAICompatibility) string {
 	if v := strings.TrimSpace(entry.BaseURL); v != "" {
 		parts = append(parts, "base="+v)
 	}
+	if v := strings.TrimSpace(entry.APIVersion); v != "" {
+		parts = append(parts, "api-version="+v)
+	}
 
 	models := make([]string, 0, len(entry.Models))
 	for _, model := range entry.Models {
@@ -169,6 +182,20 @@ func openAICompatSignature(entry config.OpenAICompatibility) string {
 			parts = append(parts, "headers="+strings.Join(keys, ","))
 		}
 	}
+	if profile := strings.TrimSpace(entry.ClientProfile); profile != "" {
+		parts = append(parts, "client_profile="+profile)
+	}
+	if schedule := strings.TrimSpace(entry.Schedule); schedule != "" {
+		parts = append(parts, "schedule="+schedule)
+	}
+	if len(entry.Labels) > 0 {
+		keys := make([]string, 0, len(entry.Labels))
+		for key, value := range entry.Labels {
+			keys = append(keys, key+"="+value)
+		}
+		sort.Strings(keys)
+		parts = append(parts, "labels="+strings.Join(keys, ","))
+	}
 
 	// Intentionally exclude API key material; only count non-empty entries.
 	if count := countAPIKeys(entry); count > 0 {
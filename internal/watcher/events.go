@@ -14,6 +14,7 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -28,11 +29,15 @@ func matchProvider(provider string, targets []string) (string, bool) {
 }
 
 func (w *Watcher) start(ctx context.Context) error {
-	if errAddConfig := w.watcher.Add(w.configPath); errAddConfig != nil {
-		log.Errorf("failed to watch config file %s: %v", w.configPath, errAddConfig)
-		return errAddConfig
+	// An embedded service may run from an in-memory config with no file behind it.
+	if w.configPath != "" {
+		if errAddConfig := w.watcher.Add(w.configPath); errAddConfig != nil {
+			log.Errorf("failed to watch config file %s: %v", w.configPath, errAddConfig)
+			return errAddConfig
+		}
+		log.Debugf("watching config file: %s", w.configPath)
+		w.watchConfigLayers()
 	}
-	log.Debugf("watching config file: %s", w.configPath)
 
 	if errAddAuthDir := w.watcher.Add(w.authDir); errAddAuthDir != nil {
 		log.Errorf("failed to watch auth directory %s: %v", w.authDir, errAddAuthDir)
@@ -48,6 +53,36 @@ func (w *Watcher) start(ctx context.Context) error {
 	return nil
 }
 
+// watchConfigLayers adds the files included by or overlaid on the config
+// file to the watch list, so editing any of them triggers a reload.
+func (w *Watcher) watchConfigLayers() {
+	normalizedConfigPath := w.normalizeAuthPath(w.configPath)
+	for _, path := range config.ConfigSourceFiles(w.configPath) {
+		normalized := w.normalizeAuthPath(path)
+		if normalized == normalizedConfigPath || w.isConfigLayer(normalized) {
+			continue
+		}
+		if errAdd := w.watcher.Add(path); errAdd != nil {
+			log.Debugf("failed to watch config layer %s: %v", path, errAdd)
+			continue
+		}
+		w.configReloadMu.Lock()
+		if w.configLayerPaths == nil {
+			w.configLayerPaths = make(map[string]struct{})
+		}
+		w.configLayerPaths[normalized] = struct{}{}
+		w.configReloadMu.Unlock()
+		log.Debugf("watching config layer: %s", path)
+	}
+}
+
+func (w *Watcher) isConfigLayer(normalizedPath string) bool {
+	w.configReloadMu.Lock()
+	defer w.configReloadMu.Unlock()
+	_, ok := w.configLayerPaths[normalizedPath]
+	return ok
+}
+
 func (w *Watcher) watchKiroIDETokenFile() {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -94,7 +129,7 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	normalizedName := w.normalizeAuthPath(event.Name)
 	normalizedConfigPath := w.normalizeAuthPath(w.configPath)
 	normalizedAuthDir := w.normalizeAuthPath(w.authDir)
-	isConfigEvent := normalizedName == normalizedConfigPath && event.Op&configOps != 0
+	isConfigEvent := (normalizedName == normalizedConfigPath || w.isConfigLayer(normalizedName)) && event.Op&configOps != 0
 	authOps := fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename
 	isAuthJSON := strings.HasPrefix(normalizedName, normalizedAuthDir) && strings.HasSuffix(normalizedName, ".json") && event.Op&authOps != 0
 	isKiroIDEToken := w.isKiroIDETokenFile(event.Name) && event.Op&authOps != 0
@@ -70,10 +70,16 @@ func (s *ConfigSynthesizer) synthesizeGeminiKeys(ctx *SynthesisContext) []*corea
 		if base != "" {
 			attrs["base_url"] = base
 		}
+		if version := strings.TrimSpace(entry.APIVersion); version != "" {
+			attrs["api_version"] = version
+		}
 		if hash := diff.ComputeGeminiModelsHash(entry.Models); hash != "" {
 			attrs["models_hash"] = hash
 		}
 		addConfigHeadersToAttrs(entry.Headers, attrs)
+		addClientProfileToAttrs(entry.ClientProfile, attrs)
+		addScheduleToAttrs(cfg, entry.Schedule, attrs)
+		addLabelsToAttrs(cfg, entry.Labels, attrs)
 		a := &coreauth.Auth{
 			ID:         id,
 			Provider:   "gemini",
@@ -117,10 +123,16 @@ func (s *ConfigSynthesizer) synthesizeClaudeKeys(ctx *SynthesisContext) []*corea
 		if base != "" {
 			attrs["base_url"] = base
 		}
+		if version := strings.TrimSpace(ck.APIVersion); version != "" {
+			attrs["api_version"] = version
+		}
 		if hash := diff.ComputeClaudeModelsHash(ck.Models); hash != "" {
 			attrs["models_hash"] = hash
 		}
 		addConfigHeadersToAttrs(ck.Headers, attrs)
+		addClientProfileToAttrs(ck.ClientProfile, attrs)
+		addScheduleToAttrs(cfg, ck.Schedule, attrs)
+		addLabelsToAttrs(cfg, ck.Labels, attrs)
 		proxyURL := strings.TrimSpace(ck.ProxyURL)
 		a := &coreauth.Auth{
 			ID:         id,
@@ -164,6 +176,9 @@ func (s *ConfigSynthesizer) synthesizeCodexKeys(ctx *SynthesisContext) []*coreau
 		if ck.BaseURL != "" {
 			attrs["base_url"] = ck.BaseURL
 		}
+		if version := strings.TrimSpace(ck.APIVersion); version != "" {
+			attrs["api_version"] = version
+		}
 		if ck.Websockets {
 			attrs["websockets"] = "true"
 		}
@@ -171,6 +186,9 @@ func (s *ConfigSynthesizer) synthesizeCodexKeys(ctx *SynthesisContext) []*coreau
 			attrs["models_hash"] = hash
 		}
 		addConfigHeadersToAttrs(ck.Headers, attrs)
+		addClientProfileToAttrs(ck.ClientProfile, attrs)
+		addScheduleToAttrs(cfg, ck.Schedule, attrs)
+		addLabelsToAttrs(cfg, ck.Labels, attrs)
 		proxyURL := strings.TrimSpace(ck.ProxyURL)
 		a := &coreauth.Auth{
 			ID:         id,
@@ -222,6 +240,9 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 			if compat.Priority != 0 {
 				attrs["priority"] = strconv.Itoa(compat.Priority)
 			}
+			if version := strings.TrimSpace(compat.APIVersion); version != "" {
+				attrs["api_version"] = version
+			}
 			if key != "" {
 				attrs["api_key"] = key
 			}
@@ -229,6 +250,9 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 				attrs["models_hash"] = hash
 			}
 			addConfigHeadersToAttrs(compat.Headers, attrs)
+			addClientProfileToAttrs(compat.ClientProfile, attrs)
+			addScheduleToAttrs(cfg, compat.Schedule, attrs)
+			addLabelsToAttrs(cfg, compat.Labels, attrs)
 			a := &coreauth.Auth{
 				ID:         id,
 				Provider:   providerName,
@@ -256,10 +280,16 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 			if compat.Priority != 0 {
 				attrs["priority"] = strconv.Itoa(compat.Priority)
 			}
+			if version := strings.TrimSpace(compat.APIVersion); version != "" {
+				attrs["api_version"] = version
+			}
 			if hash := diff.ComputeOpenAICompatModelsHash(compat.Models); hash != "" {
 				attrs["models_hash"] = hash
 			}
 			addConfigHeadersToAttrs(compat.Headers, attrs)
+			addClientProfileToAttrs(compat.ClientProfile, attrs)
+			addScheduleToAttrs(cfg, compat.Schedule, attrs)
+			addLabelsToAttrs(cfg, compat.Labels, attrs)
 			a := &coreauth.Auth{
 				ID:         id,
 				Provider:   providerName,
@@ -301,6 +331,9 @@ func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*cor
 		if compat.Priority != 0 {
 			attrs["priority"] = strconv.Itoa(compat.Priority)
 		}
+		if version := strings.TrimSpace(compat.APIVersion); version != "" {
+			attrs["api_version"] = version
+		}
 		if key != "" {
 			attrs["api_key"] = key
 		}
@@ -308,6 +341,9 @@ func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*cor
 			attrs["models_hash"] = hash
 		}
 		addConfigHeadersToAttrs(compat.Headers, attrs)
+		addClientProfileToAttrs(compat.ClientProfile, attrs)
+		addScheduleToAttrs(cfg, compat.Schedule, attrs)
+		addLabelsToAttrs(cfg, compat.Labels, attrs)
 		a := &coreauth.Auth{
 			ID:         id,
 			Provider:   providerName,
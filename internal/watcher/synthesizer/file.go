@@ -157,6 +157,15 @@ func synthesizeFileAuths(ctx *SynthesisContext, fullPath string, data []byte) []
 			}
 		}
 	}
+	// Read per-account upstream headers and client profile from auth file.
+	addConfigHeadersToAttrs(extractHeadersFromMetadata(metadata), a.Attributes)
+	if rawProfile, ok := metadata["client_profile"].(string); ok {
+		addClientProfileToAttrs(rawProfile, a.Attributes)
+	}
+	if rawSchedule, ok := metadata["schedule"].(string); ok {
+		addScheduleToAttrs(cfg, rawSchedule, a.Attributes)
+	}
+	addLabelsToAttrs(cfg, labelsFromMetadata(metadata), a.Attributes)
 	ApplyAuthExcludedModelsMeta(a, cfg, perAccountExcluded, "oauth")
 	// For codex auth files, extract plan_type from the JWT id_token.
 	if provider == "codex" {
@@ -233,6 +242,13 @@ func SynthesizeGeminiVirtualAuths(primary *coreauth.Auth, metadata map[string]an
 		if noteVal, hasNote := primary.Attributes["note"]; hasNote && noteVal != "" {
 			attrs["note"] = noteVal
 		}
+		// Propagate per-account upstream headers, client profile, schedule and labels to virtual auths
+		for key, value := range primary.Attributes {
+			if strings.HasPrefix(key, "header:") || strings.HasPrefix(key, "schedule") || strings.HasPrefix(key, "label:") ||
+				key == "client_profile" || key == coreauth.ModelNamespacesAttr {
+				attrs[key] = value
+			}
+		}
 		metadataCopy := map[string]any{
 			"email":             email,
 			"project_id":        projectID,
@@ -408,6 +408,106 @@ func TestFileSynthesizer_Synthesize_OAuthExcludedModelsMerged(t *testing.T) {
 	}
 }
 
+func TestFileSynthesizer_Synthesize_HeadersFromAuthFile(t *testing.T) {
+	tempDir := t.TempDir()
+	authData := map[string]any{
+		"type":    "codex",
+		"headers": map[string]any{"X-Team": " research ", "X-Empty": "", "X-Number": 7},
+	}
+	data, _ := json.Marshal(authData)
+	errWriteFile := os.WriteFile(filepath.Join(tempDir, "auth.json"), data, 0644)
+	if errWriteFile != nil {
+		t.Fatalf("failed to write auth file: %v", errWriteFile)
+	}
+
+	synth := NewFileSynthesizer()
+	ctx := &SynthesisContext{
+		Config:      &config.Config{},
+		AuthDir:     tempDir,
+		Now:         time.Now(),
+		IDGenerator: NewStableIDGenerator(),
+	}
+
+	auths, errSynthesize := synth.Synthesize(ctx)
+	if errSynthesize != nil {
+		t.Fatalf("unexpected error: %v", errSynthesize)
+	}
+	if len(auths) != 1 {
+		t.Fatalf("expected 1 auth, got %d", len(auths))
+	}
+	if got := auths[0].Attributes["header:X-Team"]; got != "research" {
+		t.Fatalf("expected header:X-Team %q, got %q", "research", got)
+	}
+	if _, ok := auths[0].Attributes["header:X-Empty"]; ok {
+		t.Fatal("expected empty header to be skipped")
+	}
+	if _, ok := auths[0].Attributes["header:X-Number"]; ok {
+		t.Fatal("expected non-string header to be skipped")
+	}
+}
+
+func TestFileSynthesizer_Synthesize_ScheduleFromAuthFile(t *testing.T) {
+	tempDir := t.TempDir()
+	authData := map[string]any{
+		"type":     "codex",
+		"schedule": "off-hours",
+	}
+	data, _ := json.Marshal(authData)
+	if errWriteFile := os.WriteFile(filepath.Join(tempDir, "auth.json"), data, 0644); errWriteFile != nil {
+		t.Fatalf("failed to write auth file: %v", errWriteFile)
+	}
+
+	ctx := &SynthesisContext{
+		Config: &config.Config{AuthSchedules: map[string]config.AuthSchedule{
+			"Off-Hours": {ActiveHours: []string{"mon-fri 18:00-09:00", " sat-sun "}, Timezone: "Europe/Berlin", RestMinutesPerHour: 10},
+		}},
+		AuthDir:     tempDir,
+		Now:         time.Now(),
+		IDGenerator: NewStableIDGenerator(),
+	}
+	auths, errSynthesize := NewFileSynthesizer().Synthesize(ctx)
+	if errSynthesize != nil || len(auths) != 1 {
+		t.Fatalf("Synthesize() = %d auths, %v", len(auths), errSynthesize)
+	}
+	wantSchedule := map[string]string{
+		"schedule":              "off-hours",
+		"schedule_active_hours": "mon-fri 18:00-09:00;sat-sun",
+		"schedule_timezone":     "Europe/Berlin",
+		"schedule_rest_minutes": "10",
+	}
+	for key, want := range wantSchedule {
+		if got := auths[0].Attributes[key]; got != want {
+			t.Fatalf("expected %s %q, got %q", key, want, got)
+		}
+	}
+}
+
+func TestFileSynthesizer_Synthesize_ClientProfileFromAuthFile(t *testing.T) {
+	tempDir := t.TempDir()
+	authData := map[string]any{
+		"type":           "codex",
+		"client_profile": " codex-cli ",
+	}
+	data, _ := json.Marshal(authData)
+	if errWriteFile := os.WriteFile(filepath.Join(tempDir, "auth.json"), data, 0644); errWriteFile != nil {
+		t.Fatalf("failed to write auth file: %v", errWriteFile)
+	}
+
+	ctx := &SynthesisContext{
+		Config:      &config.Config{},
+		AuthDir:     tempDir,
+		Now:         time.Now(),
+		IDGenerator: NewStableIDGenerator(),
+	}
+	auths, errSynthesize := NewFileSynthesizer().Synthesize(ctx)
+	if errSynthesize != nil || len(auths) != 1 {
+		t.Fatalf("Synthesize() = %d auths, %v", len(auths), errSynthesize)
+	}
+	if got := auths[0].Attributes["client_profile"]; got != "codex-cli" {
+		t.Fatalf("expected client_profile %q, got %q", "codex-cli", got)
+	}
+}
+
 func TestSynthesizeGeminiVirtualAuths_NilInputs(t *testing.T) {
 	now := time.Now()
 
@@ -941,3 +1041,38 @@ func TestFileSynthesizer_Synthesize_MultiProjectGeminiWithNote(t *testing.T) {
 		}
 	}
 }
+
+func TestFileSynthesizer_Synthesize_LabelsSelectModelNamespaces(t *testing.T) {
+	tempDir := t.TempDir()
+	authData := map[string]any{
+		"type":   "claude",
+		"labels": map[string]any{"team": " research ", "org": "acme"},
+	}
+	data, _ := json.Marshal(authData)
+	if errWriteFile := os.WriteFile(filepath.Join(tempDir, "auth.json"), data, 0644); errWriteFile != nil {
+		t.Fatalf("failed to write auth file: %v", errWriteFile)
+	}
+
+	cfg := &config.Config{}
+	cfg.ModelNamespaces = []config.ModelNamespace{
+		{Prefix: "acme", Selector: map[string]string{"org": "acme"}},
+		{Prefix: "acme/research", Selector: map[string]string{"org": "acme", "team": "research"}},
+		{Prefix: "acme/infra", Selector: map[string]string{"team": "infra"}},
+	}
+	ctx := &SynthesisContext{
+		Config:      cfg,
+		AuthDir:     tempDir,
+		Now:         time.Now(),
+		IDGenerator: NewStableIDGenerator(),
+	}
+	auths, errSynthesize := NewFileSynthesizer().Synthesize(ctx)
+	if errSynthesize != nil || len(auths) != 1 {
+		t.Fatalf("Synthesize() = %d auths, %v", len(auths), errSynthesize)
+	}
+	if got := auths[0].Attributes["label:team"]; got != "research" {
+		t.Fatalf("expected label:team %q, got %q", "research", got)
+	}
+	if got := auths[0].Attributes[coreauth.ModelNamespacesAttr]; got != "acme,acme/research" {
+		t.Fatalf("expected namespaces %q, got %q", "acme,acme/research", got)
+	}
+}
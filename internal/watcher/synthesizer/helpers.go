@@ -5,11 +5,13 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
 )
 
 // StableIDGenerator generates stable, deterministic IDs for auth entries.
@@ -118,3 +120,124 @@ func addConfigHeadersToAttrs(headers map[string]string, attrs map[string]string)
 		attrs["header:"+key] = val
 	}
 }
+
+// addClientProfileToAttrs records the client-profiles entry a credential
+// selects under the "client_profile" attribute.
+func addClientProfileToAttrs(profile string, attrs map[string]string) {
+	if attrs == nil {
+		return
+	}
+	if profile = strings.TrimSpace(profile); profile != "" {
+		attrs["client_profile"] = profile
+	}
+}
+
+// addScheduleToAttrs expands the auth-schedules entry a credential selects
+// into the "schedule_*" attributes the selector enforces. Unknown names are
+// logged and leave the credential unrestricted.
+func addScheduleToAttrs(cfg *config.Config, name string, attrs map[string]string) {
+	if attrs == nil {
+		return
+	}
+	if name = strings.TrimSpace(name); name == "" {
+		return
+	}
+	var schedule config.AuthSchedule
+	found := false
+	if cfg != nil {
+		for key, candidate := range cfg.AuthSchedules {
+			if strings.EqualFold(strings.TrimSpace(key), name) {
+				schedule, found = candidate, true
+				break
+			}
+		}
+	}
+	if !found {
+		log.Warnf("auth schedule %q is not defined in auth-schedules", name)
+		return
+	}
+	attrs["schedule"] = name
+	var windows []string
+	for _, window := range schedule.ActiveHours {
+		if window = strings.TrimSpace(window); window != "" {
+			windows = append(windows, window)
+		}
+	}
+	if len(windows) > 0 {
+		attrs["schedule_active_hours"] = strings.Join(windows, ";")
+	}
+	if tz := strings.TrimSpace(schedule.Timezone); tz != "" {
+		attrs["schedule_timezone"] = tz
+	}
+	if schedule.RestMinutesPerHour > 0 {
+		attrs["schedule_rest_minutes"] = strconv.Itoa(schedule.RestMinutesPerHour)
+	}
+}
+
+// extractHeadersFromMetadata reads the optional "headers" object of an auth
+// file. Non-string values are ignored.
+func extractHeadersFromMetadata(metadata map[string]any) map[string]string {
+	raw, ok := metadata["headers"].(map[string]any)
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+	headers := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if str, isStr := value.(string); isStr {
+			headers[key] = str
+		}
+	}
+	return headers
+}
+
+// addLabelsToAttrs records a credential's labels as "label:<key>" attributes
+// and lists the model-namespaces whose selectors they satisfy.
+func addLabelsToAttrs(cfg *config.Config, labels map[string]string, attrs map[string]string) {
+	if attrs == nil || len(labels) == 0 {
+		return
+	}
+	normalized := make(map[string]string, len(labels))
+	for key, value := range labels {
+		if key = strings.TrimSpace(key); key != "" {
+			normalized[key] = strings.TrimSpace(value)
+			attrs["label:"+key] = normalized[key]
+		}
+	}
+	if cfg == nil {
+		return
+	}
+	var namespaces []string
+	for _, ns := range cfg.ModelNamespaces {
+		if ns.Prefix == "" || len(ns.Selector) == 0 {
+			continue
+		}
+		matched := true
+		for key, want := range ns.Selector {
+			if got, ok := normalized[key]; !ok || got != want {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			namespaces = append(namespaces, ns.Prefix)
+		}
+	}
+	if len(namespaces) > 0 {
+		attrs[coreauth.ModelNamespacesAttr] = strings.Join(namespaces, ",")
+	}
+}
+
+// labelsFromMetadata reads the "labels" object of an auth file.
+func labelsFromMetadata(metadata map[string]any) map[string]string {
+	raw, ok := metadata["labels"].(map[string]any)
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if str, isStr := value.(string); isStr {
+			labels[key] = str
+		}
+	}
+	return labels
+}
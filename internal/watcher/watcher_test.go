@@ -271,7 +271,7 @@ func TestStartAndStopSuccess(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir), 0o644); err != nil {
 		t.Fatalf("failed to create config file: %v", err)
 	}
 
@@ -923,7 +923,7 @@ func TestHandleEventIgnoresUnrelatedFiles(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 
@@ -949,7 +949,7 @@ func TestHandleEventConfigChangeSchedulesReload(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 
@@ -977,7 +977,7 @@ func TestHandleEventAuthWriteTriggersUpdate(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "a.json")
@@ -1007,7 +1007,7 @@ func TestHandleEventRemoveDebounceSkips(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "remove.json")
@@ -1037,7 +1037,7 @@ func TestHandleEventAtomicReplaceUnchangedSkips(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "same.json")
@@ -1070,7 +1070,7 @@ func TestHandleEventAtomicReplaceChangedTriggersUpdate(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "change.json")
@@ -1104,7 +1104,7 @@ func TestHandleEventRemoveUnknownFileIgnored(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "unknown.json")
@@ -1131,7 +1131,7 @@ func TestHandleEventRemoveKnownFileDeletes(t *testing.T) {
 		t.Fatalf("failed to create auth dir: %v", err)
 	}
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authFile := filepath.Join(authDir, "known.json")
@@ -1292,7 +1292,7 @@ func TestReloadConfigUsesMirroredAuthDir(t *testing.T) {
 	}
 
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+filepath.Join(tmpDir, "other")+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+filepath.Join(tmpDir, "other")+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config: %v", err)
 	}
 
@@ -1445,7 +1445,7 @@ func TestReloadConfigTriggersCallbackForMaxRetryCredentialsChange(t *testing.T)
 func TestStartFailsWhenAuthDirMissing(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte("auth_dir: "+filepath.Join(tmpDir, "missing-auth")+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(configPath, []byte("auth-dir: "+filepath.Join(tmpDir, "missing-auth")+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config file: %v", err)
 	}
 	authDir := filepath.Join(tmpDir, "missing-auth")
@@ -1554,7 +1554,7 @@ func TestScheduleConfigReloadDebounces(t *testing.T) {
 	tmp := t.TempDir()
 	authDir := tmp
 	cfgPath := tmp + "/config.yaml"
-	if err := os.WriteFile(cfgPath, []byte("auth_dir: "+authDir+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(cfgPath, []byte("auth-dir: "+authDir+"\n"), 0o644); err != nil {
 		t.Fatalf("failed to write config: %v", err)
 	}
 
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// artifactMeta is stored next to each artifact body.
+type artifactMeta struct {
+	RequestID   string    `json:"request_id"`
+	Path        string    `json:"path"`
+	Status      int       `json:"status"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type artifactSettings struct {
+	dir      string
+	minBytes int64
+	maxAge   time.Duration
+}
+
+func artifactConfig(cfg *config.SDKConfig) (artifactSettings, bool) {
+	if cfg == nil || strings.TrimSpace(cfg.Artifacts.Dir) == "" {
+		return artifactSettings{}, false
+	}
+	dir, err := util.ResolveAuthDir(strings.TrimSpace(cfg.Artifacts.Dir))
+	if err != nil || dir == "" {
+		return artifactSettings{}, false
+	}
+	settings := artifactSettings{
+		dir:      dir,
+		minBytes: config.DefaultArtifactMinBytes,
+		maxAge:   config.DefaultArtifactMaxAgeHours * time.Hour,
+	}
+	if cfg.Artifacts.MinBytes > 0 {
+		settings.minBytes = int64(cfg.Artifacts.MinBytes)
+	}
+	if cfg.Artifacts.MaxAgeHours > 0 {
+		settings.maxAge = time.Duration(cfg.Artifacts.MaxAgeHours) * time.Hour
+	}
+	return settings, true
+}
+
+// artifactName keys artifacts by API key as well as request ID, since clients
+// may choose their own request IDs.
+func artifactName(apiKey, requestID string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8]) + "-" + requestID
+}
+
+// artifactWriter spools the response to a temporary file next to the store.
+// A failing spool only stops the copy; the client response is unaffected.
+type artifactWriter struct {
+	gin.ResponseWriter
+	dir  string
+	file *os.File
+	size int64
+	err  error
+}
+
+func (w *artifactWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	if w.err == nil && n > 0 {
+		if w.file == nil {
+			w.file, w.err = os.CreateTemp(w.dir, ".artifact-*.tmp")
+		}
+		if w.err == nil {
+			_, w.err = w.file.Write(p[:n])
+			w.size += int64(n)
+		}
+		if w.err != nil {
+			log.Warnf("artifacts: spool response: %v", w.err)
+			w.discard()
+		}
+	}
+	return n, err
+}
+
+func (w *artifactWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *artifactWriter) discard() {
+	if w.file != nil {
+		_ = w.file.Close()
+		_ = os.Remove(w.file.Name())
+		w.file = nil
+	}
+}
+
+// keep moves the spooled body into the store under name with its metadata.
+func (w *artifactWriter) keep(name string, meta artifactMeta) error {
+	file := w.file
+	w.file = nil
+	return storeArtifact(w.dir, file, name, meta)
+}
+
+// storeArtifact closes the spooled body file and moves it into the store in
+// dir under name with its metadata.
+func storeArtifact(dir string, file *os.File, name string, meta artifactMeta) error {
+	tmp := file.Name()
+	if errClose := file.Close(); errClose != nil {
+		_ = os.Remove(tmp)
+		return errClose
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, filepath.Join(dir, name+".body")); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+".json"), data, 0o600)
+}
+
+// ArtifactMiddleware keeps successful POST responses of at least
+// artifacts.min-bytes in artifacts.dir, retrievable through GetArtifact by
+// request ID. Responses cut short by a client disconnect are only kept under
+// disconnect.policy "complete", where they still run to the end. It must run
+// after authentication and before StreamResumeMiddleware.
+func ArtifactMiddleware(h *BaseAPIHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Jobs store their own result, whatever its size.
+		if h == nil || c.Request.Method != http.MethodPost || isJobRequest(c.Request) {
+			c.Next()
+			return
+		}
+		settings, ok := artifactConfig(h.Cfg)
+		if !ok {
+			c.Next()
+			return
+		}
+		if err := os.MkdirAll(settings.dir, 0o700); err != nil {
+			log.Warnf("artifacts: create %s: %v", settings.dir, err)
+			c.Next()
+			return
+		}
+		writer := &artifactWriter{ResponseWriter: c.Writer, dir: settings.dir}
+		c.Writer = writer
+		defer writer.discard()
+		c.Next()
+
+		requestID := logging.GetGinRequestID(c)
+		status := writer.ResponseWriter.Status()
+		if writer.file == nil || writer.size < settings.minBytes || requestID == "" || status < 200 || status >= 300 {
+			return
+		}
+		if c.Request.Context().Err() != nil && disconnectPolicy(h.Cfg) != config.DisconnectPolicyComplete {
+			return
+		}
+		meta := artifactMeta{
+			RequestID:   requestID,
+			Path:        c.Request.URL.Path,
+			Status:      status,
+			ContentType: writer.ResponseWriter.Header().Get("Content-Type"),
+			Size:        writer.size,
+			CreatedAt:   time.Now().UTC(),
+		}
+		if err := writer.keep(artifactName(c.GetString("apiKey"), requestID), meta); err != nil {
+			log.Warnf("artifacts: store %s: %v", requestID, err)
+		}
+		pruneArtifacts(settings.dir, settings.maxAge)
+	}
+}
+
+// pruneArtifacts deletes artifacts, job records and temporary files left by a
+// crash that are older than maxAge.
+func pruneArtifacts(dir string, maxAge time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".body") || strings.HasSuffix(name, ".json") || strings.HasSuffix(name, jobFileSuffix) || strings.HasSuffix(name, ".tmp")) {
+			continue
+		}
+		info, errInfo := entry.Info()
+		if errInfo == nil && info.ModTime().Before(cutoff) {
+			_ = os.Remove(filepath.Join(dir, name))
+		}
+	}
+}
+
+// GetArtifact returns the full stored response of a request by its request ID.
+// Only the API key that made the request can read it.
+func (h *BaseAPIHandler) GetArtifact(c *gin.Context) {
+	notFound := func() {
+		c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"message": "artifact not found", "type": "invalid_request_error"}})
+	}
+	settings, ok := artifactConfig(h.Cfg)
+	requestID := logging.ClientRequestID(c.Param("id"))
+	if !ok || requestID == "" {
+		notFound()
+		return
+	}
+	name := filepath.Join(settings.dir, artifactName(c.GetString("apiKey"), requestID))
+	data, err := os.ReadFile(name + ".json")
+	if err != nil {
+		notFound()
+		return
+	}
+	var meta artifactMeta
+	if err = json.Unmarshal(data, &meta); err != nil || time.Since(meta.CreatedAt) > settings.maxAge {
+		notFound()
+		return
+	}
+	contentType := meta.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.File(name + ".body")
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func newArtifactTestRouter(t *testing.T, dir string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{Artifacts: sdkconfig.ArtifactsConfig{Dir: dir, MinBytes: 16}}, nil)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("apiKey", c.GetHeader("X-Test-Key"))
+		logging.SetGinRequestID(c, c.GetHeader("X-Test-Request"))
+		c.Next()
+	})
+	router.Use(ArtifactMiddleware(h))
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.WriteString("data: " + c.Query("text") + "\n\n")
+	})
+	router.POST("/v1/fail", func(c *gin.Context) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": strings.Repeat("x", 32)})
+	})
+	router.GET("/v1/artifacts/:id", h.GetArtifact)
+	return router
+}
+
+func artifactRequest(router *gin.Engine, method, path, key, requestID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+	req.Header.Set("X-Test-Key", key)
+	req.Header.Set("X-Test-Request", requestID)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestArtifactMiddleware_StoresLargeResponsesForTheirKey(t *testing.T) {
+	dir := t.TempDir()
+	router := newArtifactTestRouter(t, dir)
+
+	long := strings.Repeat("a", 32)
+	artifactRequest(router, http.MethodPost, "/v1/chat/completions?text="+long, "k1", "req-long")
+	artifactRequest(router, http.MethodPost, "/v1/chat/completions?text=hi", "k1", "req-short")
+	artifactRequest(router, http.MethodPost, "/v1/fail", "k1", "req-fail")
+
+	rec := artifactRequest(router, http.MethodGet, "/v1/artifacts/req-long", "k1", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "data: "+long+"\n\n" {
+		t.Fatalf("artifact: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	for _, tc := range []struct{ key, id string }{{"k2", "req-long"}, {"k1", "req-short"}, {"k1", "req-fail"}, {"k1", "../req-long"}} {
+		if rec := artifactRequest(router, http.MethodGet, "/v1/artifacts/"+tc.id, tc.key, ""); rec.Code != http.StatusNotFound {
+			t.Fatalf("key %s, id %s: status %d, want 404", tc.key, tc.id, rec.Code)
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(matches) != 0 {
+		t.Fatalf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestPruneArtifacts_RemovesExpiredFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "owner-old.body")
+	fresh := filepath.Join(dir, "owner-new.body")
+	for _, path := range []string{old, fresh} {
+		if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+	pruneArtifacts(dir, time.Hour)
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("expired artifact kept: %v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatalf("fresh artifact removed: %v", err)
+	}
+}
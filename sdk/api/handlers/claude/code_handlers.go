@@ -129,7 +129,7 @@ func (h *ClaudeCodeAPIHandler) ClaudeCountTokens(c *gin.Context) {
 // Parameters:
 //   - c: The Gin context for the request.
 func (h *ClaudeCodeAPIHandler) ClaudeModels(c *gin.Context) {
-	models := h.Models()
+	models := h.FilterModelsForAPIKey(c, h.Models())
 	firstID := ""
 	lastID := ""
 	if len(models) > 0 {
@@ -232,13 +232,12 @@ func (h *ClaudeCodeAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON [
 		c.Header("Content-Type", "text/event-stream")
 		c.Header("Cache-Control", "no-cache")
 		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
 	}
 
 	// Peek at the first chunk to determine success or failure before setting headers
 	for {
 		select {
-		case <-c.Request.Context().Done():
+		case <-handlers.ClientDone(c):
 			cliCancel(c.Request.Context().Err())
 			return
 		case errMsg, ok := <-errChan:
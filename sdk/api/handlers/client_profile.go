@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// clientProfileHeaders are the client headers handed to provider request
+// builders for clients with a compatibility profile.
+var clientProfileHeaders = []string{"Anthropic-Beta", "Anthropic-Version"}
+
+// apiKeyProfileFor returns the api-key-profiles profile of apiKey as
+// authenticated by the named access provider.
+func apiKeyProfileFor(cfg *config.SDKConfig, provider, apiKey string) string {
+	if cfg == nil || apiKey == "" {
+		return ""
+	}
+	for _, entry := range cfg.APIKeyProfiles {
+		if entry.APIKey != apiKey {
+			continue
+		}
+		if entry.Provider != "" && !strings.EqualFold(entry.Provider, provider) {
+			continue
+		}
+		return entry.Profile
+	}
+	return ""
+}
+
+// applyClientProfile records the compatibility profile of the requesting API
+// key in opts and passes the client headers the profile relies on.
+func (h *BaseAPIHandler) applyClientProfile(ctx context.Context, opts *coreexecutor.Options) {
+	if h == nil || h.Cfg == nil || len(h.Cfg.APIKeyProfiles) == 0 || ctx == nil {
+		return
+	}
+	c, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || c == nil || c.Request == nil {
+		return
+	}
+	profile := apiKeyProfileFor(h.Cfg, c.GetString("accessProvider"), c.GetString("apiKey"))
+	if profile == "" {
+		return
+	}
+	if opts.Metadata == nil {
+		opts.Metadata = make(map[string]any)
+	}
+	opts.Metadata[coreexecutor.ClientProfileMetadataKey] = profile
+	opts.Metadata[coreexecutor.ClientScopeMetadataKey] = coreexecutor.ClientScope(c.GetString("accessProvider"), c.GetString("apiKey"))
+	for _, name := range clientProfileHeaders {
+		values := c.Request.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if opts.Headers == nil {
+			opts.Headers = make(http.Header)
+		}
+		opts.Headers[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+}
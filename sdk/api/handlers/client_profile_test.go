@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestApplyClientProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{APIKeyProfiles: []sdkconfig.APIKeyProfile{
+		{APIKey: "cc", Provider: "team-keys", Profile: sdkconfig.ClientProfileClaudeCode},
+	}}, nil)
+
+	for provider, want := range map[string]string{"team-keys": sdkconfig.ClientProfileClaudeCode, "config-inline": ""} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+		c.Request.Header.Set("Anthropic-Beta", "interleaved-thinking-2025-05-14")
+		c.Set("apiKey", "cc")
+		c.Set("accessProvider", provider)
+		ctx := context.WithValue(context.Background(), "gin", c)
+
+		var opts coreexecutor.Options
+		h.applyClientProfile(ctx, &opts)
+		got, _ := opts.Metadata[coreexecutor.ClientProfileMetadataKey].(string)
+		if got != want {
+			t.Fatalf("provider %s: profile = %q, want %q", provider, got, want)
+		}
+		if want == "" {
+			continue
+		}
+		if opts.Headers.Get("Anthropic-Beta") != "interleaved-thinking-2025-05-14" {
+			t.Fatalf("provider %s: headers = %v", provider, opts.Headers)
+		}
+		if scope, _ := opts.Metadata[coreexecutor.ClientScopeMetadataKey].(string); scope == "" || scope == "cc" {
+			t.Fatalf("provider %s: client scope = %q", provider, scope)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// clientEncodings lists the encodings offered to clients, in order of
+// preference.
+var clientEncodings = []string{"zstd", "br", "gzip"}
+
+// negotiateEncoding returns the preferred encoding an Accept-Encoding value
+// allows, or "" when it allows none of clientEncodings.
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value <= 0 {
+				accepted[name] = false
+				continue
+			}
+		}
+		accepted[name] = true
+	}
+	for _, encoding := range clientEncodings {
+		if allowed, ok := accepted[encoding]; ok {
+			if allowed {
+				return encoding
+			}
+			continue
+		}
+		if accepted["*"] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+func newEncoder(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case "zstd":
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	case "br":
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	default:
+		return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	}
+}
+
+// compressWriter holds back the first minBytes of a response to decide
+// whether to compress it. A flush before then marks a stream, which is sent
+// as is so events are not delayed.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minBytes int
+	buf      bytes.Buffer
+	decided  bool
+	encoder  io.WriteCloser
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf.Write(p)
+	if w.buf.Len() >= w.minBytes {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Written() bool {
+	return w.ResponseWriter.Written() || w.buf.Len() > 0
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide starts writing the response, compressed when compress is set and
+// nothing makes the response unsuitable, and sends what was held back.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	status := w.ResponseWriter.Status()
+	if compress && !w.ResponseWriter.Written() && status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" && !strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") {
+		encoder, err := newEncoder(w.encoding, w.ResponseWriter)
+		if err != nil {
+			log.Warnf("compression: create %s encoder: %v", w.encoding, err)
+		} else {
+			header.Del("Content-Length")
+			header.Set("Content-Encoding", w.encoding)
+			header.Add("Vary", "Accept-Encoding")
+			w.encoder = encoder
+		}
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	held := w.buf.Bytes()
+	w.buf = bytes.Buffer{}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(held)
+	} else {
+		_, err = w.ResponseWriter.Write(held)
+	}
+	return err
+}
+
+// finish sends a response that stayed below minBytes and ends compression.
+func (w *compressWriter) finish() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Close(); err != nil {
+			log.Debugf("compression: close %s encoder: %v", w.encoding, err)
+		}
+		w.encoder = nil
+	}
+}
+
+// CompressionMiddleware compresses non-streaming responses of at least
+// compression.min-bytes for clients whose Accept-Encoding allows zstd, br or
+// gzip. It must run before middlewares that wrap the response writer, so
+// they see the uncompressed body.
+func CompressionMiddleware(h *BaseAPIHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h == nil || h.Cfg == nil || !h.Cfg.Compression.Client {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+		minBytes := h.Cfg.Compression.MinBytes
+		if minBytes <= 0 {
+			minBytes = config.DefaultCompressionMinBytes
+		}
+		writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minBytes: minBytes}
+		c.Writer = writer
+		defer writer.finish()
+		c.Next()
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                      "",
+		"identity":              "",
+		"gzip, deflate":         "gzip",
+		"gzip, br":              "br",
+		"gzip, br, zstd":        "zstd",
+		"zstd;q=0, br;q=0.5":    "br",
+		"*":                     "zstd",
+		"*, zstd;q=0":           "br",
+		"GZIP;q=1.0":            "gzip",
+		"deflate, compress;q=1": "",
+	} {
+		if got := negotiateEncoding(accept); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func newCompressionTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{Compression: sdkconfig.CompressionConfig{Client: true, MinBytes: 64}}, nil)
+	router := gin.New()
+	router.Use(CompressionMiddleware(h))
+	router.GET("/json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"text": strings.Repeat("hello ", 100)})
+	})
+	router.GET("/small", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		for range 3 {
+			_, _ = c.Writer.WriteString("data: " + strings.Repeat("x", 40) + "\n\n")
+			c.Writer.Flush()
+		}
+	})
+	return router
+}
+
+func TestCompressionMiddleware_CompressesLargeResponses(t *testing.T) {
+	router := newCompressionTestRouter()
+	req := httptest.NewRequest(http.MethodGet, "/json", nil)
+	req.Header.Set("Accept-Encoding", "gzip, zstd")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "zstd" || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+	decoder, err := zstd.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("zstd reader: %v", err)
+	}
+	defer decoder.Close()
+	body, err := io.ReadAll(decoder)
+	if err != nil || !strings.HasPrefix(string(body), `{"text":"hello hello`) {
+		t.Fatalf("decoded body = %q, %v", body, err)
+	}
+	if rec.Body.Len() >= len(body) {
+		t.Fatalf("compressed %d bytes into %d", len(body), rec.Body.Len())
+	}
+}
+
+func TestCompressionMiddleware_LeavesSmallAndStreamingResponses(t *testing.T) {
+	router := newCompressionTestRouter()
+	for _, path := range []string{"/small", "/stream"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("%s: Content-Encoding = %q", path, rec.Header().Get("Content-Encoding"))
+		}
+		if path == "/stream" && strings.Count(rec.Body.String(), "data: ") != 3 {
+			t.Fatalf("stream body = %q", rec.Body.String())
+		}
+		if path == "/small" && rec.Body.String() != `{"ok":true}` {
+			t.Fatalf("small body = %q", rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spill"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+const defaultDisconnectResultTTL = time.Hour
+
+// detachedResult is the response of a request whose client disconnected
+// before it completed.
+type detachedResult struct {
+	principal   string
+	status      int
+	contentType string
+	body        *spill.Buffer
+	expiresAt   time.Time
+}
+
+type detachedResultStore struct {
+	mu      sync.Mutex
+	results map[string]detachedResult
+}
+
+var detachedResults = &detachedResultStore{results: make(map[string]detachedResult)}
+
+func (s *detachedResultStore) put(requestID string, result detachedResult) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, existing := range s.results {
+		if now.After(existing.expiresAt) {
+			delete(s.results, id)
+			_ = existing.body.Close()
+		}
+	}
+	if existing, ok := s.results[requestID]; ok {
+		_ = existing.body.Close()
+	}
+	s.results[requestID] = result
+}
+
+func (s *detachedResultStore) get(requestID string) (detachedResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[requestID]
+	if !ok {
+		return detachedResult{}, false
+	}
+	if time.Now().After(result.expiresAt) {
+		delete(s.results, requestID)
+		_ = result.body.Close()
+		return detachedResult{}, false
+	}
+	return result, true
+}
+
+// completingWriter copies the response so it can be kept once the client has
+// gone. Large copies spill to disk as configured by response-spill.
+type completingWriter struct {
+	gin.ResponseWriter
+	body *spill.Buffer
+}
+
+func (w *completingWriter) Write(p []byte) (int, error) {
+	_, _ = w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *completingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func disconnectPolicy(cfg *config.SDKConfig) string {
+	if cfg == nil {
+		return config.DisconnectPolicyCancel
+	}
+	if strings.EqualFold(strings.TrimSpace(cfg.Disconnect.Policy), config.DisconnectPolicyComplete) {
+		return config.DisconnectPolicyComplete
+	}
+	return config.DisconnectPolicyCancel
+}
+
+// DisconnectPolicyMiddleware applies disconnect.policy "complete": requests
+// keep running after their client disconnects and the finished response is
+// kept under the request ID. It must run after authentication and after
+// StreamResumeMiddleware.
+func DisconnectPolicyMiddleware(h *BaseAPIHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h == nil || c.Request.Method != http.MethodPost || disconnectPolicy(h.Cfg) != config.DisconnectPolicyComplete {
+			c.Next()
+			return
+		}
+		ttl := defaultDisconnectResultTTL
+		if h.Cfg.Disconnect.ResultTTL > 0 {
+			ttl = time.Duration(h.Cfg.Disconnect.ResultTTL) * time.Second
+		}
+		writer := &completingWriter{ResponseWriter: c.Writer, body: newResponseBuffer(h.Cfg)}
+		c.Writer = writer
+		c.Next()
+
+		requestID := logging.GetGinRequestID(c)
+		if c.Request.Context().Err() == nil || requestID == "" {
+			_ = writer.body.Close()
+			return
+		}
+		detachedResults.put(requestID, detachedResult{
+			principal:   c.GetString("apiKey"),
+			status:      writer.Status(),
+			contentType: writer.Header().Get("Content-Type"),
+			body:        writer.body,
+			expiresAt:   time.Now().Add(ttl),
+		})
+	}
+}
+
+// clientGone reports whether upstream work should continue after the client
+// disconnected. hold closes when the work should stop after all; a nil hold
+// means run to completion.
+func clientGone(c *gin.Context) (hold <-chan struct{}, keep bool) {
+	if c == nil {
+		return nil, false
+	}
+	writer := c.Writer
+	// The completing writer is only installed for the "complete" policy.
+	if completing, ok := writer.(*completingWriter); ok {
+		writer = completing.ResponseWriter
+		keep = true
+	}
+	if resumable, ok := writer.(*resumableWriter); ok {
+		if expired, detached := resumable.detach(); detached && !keep {
+			return expired, true
+		}
+	}
+	return nil, keep
+}
+
+// ClientDone returns the channel a handler waiting for the first upstream
+// chunk should watch for a departed client. It is nil under the "complete"
+// policy, where a disconnect must not stop the request.
+func ClientDone(c *gin.Context) <-chan struct{} {
+	if _, ok := c.Writer.(*completingWriter); ok {
+		return nil
+	}
+	return c.Request.Context().Done()
+}
+
+// GetDetachedResult returns the response of a request whose client disconnected
+// under disconnect.policy "complete". Only the API key that made the request
+// can read it.
+func (h *BaseAPIHandler) GetDetachedResult(c *gin.Context) {
+	result, ok := detachedResults.get(c.Param("id"))
+	if !ok || result.principal != c.GetString("apiKey") {
+		c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"message": "request result not found", "type": "invalid_request_error"}})
+		return
+	}
+	contentType := result.contentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	status := result.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	c.Header("Content-Type", contentType)
+	c.Status(status)
+	_, _ = result.body.WriteTo(c.Writer)
+}
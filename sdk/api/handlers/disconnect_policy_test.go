@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func newDisconnectTestRouter(h *BaseAPIHandler, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("apiKey", c.GetHeader("X-Test-Key"))
+		logging.SetGinRequestID(c, c.GetHeader("X-Test-Request"))
+		c.Next()
+	})
+	router.Use(DisconnectPolicyMiddleware(h))
+	router.POST("/v1/chat/completions", handler)
+	router.GET("/v1/requests/:id", h.GetDetachedResult)
+	return router
+}
+
+func TestDisconnectPolicyComplete_KeepsResultForRequestID(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{Disconnect: sdkconfig.DisconnectConfig{Policy: sdkconfig.DisconnectPolicyComplete}}
+	h := NewBaseAPIHandlers(cfg, nil)
+
+	clientCtx, disconnect := context.WithCancel(context.Background())
+	upstreamCanceled := false
+	router := newDisconnectTestRouter(h, func(c *gin.Context) {
+		flusher := c.Writer.(http.Flusher)
+		c.Header("Content-Type", "text/event-stream")
+		data := make(chan []byte, 2)
+		errs := make(chan *interfaces.ErrorMessage)
+		disconnect()
+		<-c.Request.Context().Done()
+		data <- []byte("data: late\n\n")
+		close(data)
+		h.ForwardStream(c, flusher, func(err error) { upstreamCanceled = err != nil }, data, errs, StreamForwardOptions{
+			WriteChunk: func(chunk []byte) { _, _ = c.Writer.Write(chunk) },
+			WriteDone:  func() { _, _ = c.Writer.Write([]byte("data: [DONE]\n\n")) },
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}")).WithContext(clientCtx)
+	req.Header.Set("X-Test-Key", "k1")
+	req.Header.Set("X-Test-Request", "req-complete")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if upstreamCanceled {
+		t.Fatal("upstream should run to completion after the client disconnects")
+	}
+
+	get := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/requests/req-complete", nil)
+		req.Header.Set("X-Test-Key", key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := get("k1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected stored result, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "data: late") || !strings.Contains(body, "[DONE]") {
+		t.Fatalf("unexpected stored body %q", body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("expected event-stream content type, got %q", got)
+	}
+	if rec := get("k2"); rec.Code != http.StatusNotFound {
+		t.Fatalf("another key must not read the result, got %d", rec.Code)
+	}
+}
+
+func TestDisconnectPolicyCancel_StopsUpstream(t *testing.T) {
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil)
+
+	clientCtx, disconnect := context.WithCancel(context.Background())
+	var cancelErr error
+	router := newDisconnectTestRouter(h, func(c *gin.Context) {
+		disconnect()
+		h.ForwardStream(c, c.Writer.(http.Flusher), func(err error) { cancelErr = err }, make(chan []byte), make(chan *interfaces.ErrorMessage), StreamForwardOptions{})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}")).WithContext(clientCtx)
+	req.Header.Set("X-Test-Request", "req-cancel")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if cancelErr == nil {
+		t.Fatal("expected upstream to be canceled on disconnect")
+	}
+	if _, ok := detachedResults.get("req-cancel"); ok {
+		t.Fatal("cancel policy must not keep results")
+	}
+}
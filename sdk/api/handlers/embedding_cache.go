@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embedding"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"golang.org/x/net/context"
+)
+
+// EmbeddingCacheHeader reports whether an embedding response came from the
+// cache ("hit"), partly from it ("partial") or from the upstream ("miss"). It
+// is only sent while the cache is partitioned per client API key.
+const EmbeddingCacheHeader = "X-Embedding-Cache"
+
+var embeddingCacheState struct {
+	sync.Mutex
+	dir        string
+	maxEntries int
+	cache      *embedding.Cache
+}
+
+// embeddingCache returns the cache configured by cfg, reopening it when the
+// directory or size changed, or nil when caching is off or unavailable.
+func embeddingCache(cfg *config.SDKConfig) *embedding.Cache {
+	if cfg == nil || strings.TrimSpace(cfg.EmbeddingCache.Dir) == "" {
+		return nil
+	}
+	dir, err := util.ResolveAuthDir(strings.TrimSpace(cfg.EmbeddingCache.Dir))
+	if err != nil || dir == "" {
+		return nil
+	}
+	maxEntries := cfg.EmbeddingCache.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = config.DefaultEmbeddingCacheMaxEntries
+	}
+	state := &embeddingCacheState
+	state.Lock()
+	defer state.Unlock()
+	if state.cache != nil && state.dir == dir && state.maxEntries == maxEntries {
+		return state.cache
+	}
+	if state.cache != nil {
+		_ = state.cache.Close()
+		state.cache = nil
+	}
+	cache, err := embedding.OpenCache(dir, maxEntries)
+	if err != nil {
+		log.Warnf("embedding cache: open %s: %v", dir, err)
+		return nil
+	}
+	state.dir, state.maxEntries, state.cache = dir, maxEntries, cache
+	return cache
+}
+
+// embeddingInput is one content of an embedding request and its cache key.
+type embeddingInput struct {
+	raw       string
+	key       embedding.Key
+	cacheable bool
+}
+
+// embeddingCacheScope returns the cache partition of the client API key
+// behind ctx, or "" when the cache is shared between keys.
+func embeddingCacheScope(ctx context.Context, cfg *config.SDKConfig) string {
+	if cfg.EmbeddingCache.Shared {
+		return ""
+	}
+	var accessProvider, apiKey string
+	if c, ok := ctx.Value("gin").(*gin.Context); ok && c != nil {
+		accessProvider, apiKey = c.GetString("accessProvider"), c.GetString("apiKey")
+	}
+	return coreexecutor.ClientScope(accessProvider, apiKey)
+}
+
+func embeddingInputs(scope, modelName, method string, rawJSON []byte) []embeddingInput {
+	requests := []gjson.Result{gjson.ParseBytes(rawJSON)}
+	if method == embedding.MethodBatchEmbed {
+		requests = gjson.GetBytes(rawJSON, "requests").Array()
+	}
+	inputs := make([]embeddingInput, len(requests))
+	for i, request := range requests {
+		text, ok := embedding.ContentText(request.Get("content"))
+		inputs[i] = embeddingInput{
+			raw:       request.Raw,
+			cacheable: ok,
+			key: embedding.Key{
+				Scope:      scope,
+				Model:      modelName,
+				TaskType:   request.Get("taskType").String(),
+				Dimensions: request.Get("outputDimensionality").Int(),
+				Text:       embedding.NormalizeText(text),
+			},
+		}
+	}
+	return inputs
+}
+
+// embeddingVectors returns the "values" arrays of a Gemini embedding
+// response.
+func embeddingVectors(method string, resp []byte) []json.RawMessage {
+	var items []gjson.Result
+	if method == embedding.MethodBatchEmbed {
+		items = gjson.GetBytes(resp, "embeddings").Array()
+	} else if single := gjson.GetBytes(resp, "embedding"); single.Exists() {
+		items = []gjson.Result{single}
+	}
+	vectors := make([]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		values := item.Get("values")
+		if !values.IsArray() {
+			return nil
+		}
+		vectors = append(vectors, json.RawMessage(values.Raw))
+	}
+	return vectors
+}
+
+func embeddingResponse(method string, vectors []json.RawMessage) []byte {
+	if method != embedding.MethodBatchEmbed {
+		return []byte(`{"embedding":{"values":` + string(vectors[0]) + `}}`)
+	}
+	items := make([]string, len(vectors))
+	for i, vector := range vectors {
+		items[i] = `{"values":` + string(vector) + `}`
+	}
+	return []byte(`{"embeddings":[` + strings.Join(items, ",") + `]}`)
+}
+
+// ExecuteEmbeddingWithAuthManager executes a Gemini embedContent or
+// batchEmbedContents request. With embedding-cache configured, inputs embedded
+// before are served from the cache and only the rest are sent upstream.
+func (h *BaseAPIHandler) ExecuteEmbeddingWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, method string) ([]byte, http.Header, *interfaces.ErrorMessage) {
+	cache := embeddingCache(h.Cfg)
+	if cache == nil {
+		return h.ExecuteWithAuthManager(ctx, handlerType, modelName, rawJSON, method)
+	}
+	similarity := h.Cfg.EmbeddingCache.Similarity
+	shared := h.Cfg.EmbeddingCache.Shared
+	inputs := embeddingInputs(embeddingCacheScope(ctx, h.Cfg), modelName, method, rawJSON)
+	vectors := make([]json.RawMessage, len(inputs))
+	var misses []int
+	for i, input := range inputs {
+		if input.cacheable {
+			if vector, ok := cache.Get(input.key, similarity); ok {
+				vectors[i] = vector
+				continue
+			}
+		}
+		misses = append(misses, i)
+	}
+	if len(inputs) > 0 && len(misses) == 0 {
+		headers := http.Header{}
+		if !shared {
+			headers.Set(EmbeddingCacheHeader, "hit")
+		}
+		return embeddingResponse(method, vectors), headers, nil
+	}
+
+	upstreamJSON := rawJSON
+	if method == embedding.MethodBatchEmbed && len(misses) < len(inputs) {
+		requests := make([]string, len(misses))
+		for j, i := range misses {
+			requests[j] = inputs[i].raw
+		}
+		upstreamJSON, _ = sjson.SetRawBytes(rawJSON, "requests", []byte("["+strings.Join(requests, ",")+"]"))
+	}
+	resp, upstreamHeaders, errMsg := h.ExecuteWithAuthManager(ctx, handlerType, modelName, upstreamJSON, method)
+	if errMsg != nil {
+		return resp, upstreamHeaders, errMsg
+	}
+	fetched := embeddingVectors(method, resp)
+	if len(fetched) != len(misses) {
+		if len(misses) == len(inputs) {
+			return resp, upstreamHeaders, nil
+		}
+		err := fmt.Errorf("upstream returned %d embeddings for %d inputs", len(fetched), len(misses))
+		return nil, upstreamHeaders, &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: err}
+	}
+	for j, i := range misses {
+		vectors[i] = fetched[j]
+		if inputs[i].cacheable {
+			if errPut := cache.Put(inputs[i].key, fetched[j]); errPut != nil {
+				log.Warnf("embedding cache: store vector: %v", errPut)
+			}
+		}
+	}
+	headers := upstreamHeaders.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	if len(misses) == len(inputs) {
+		if !shared {
+			headers.Set(EmbeddingCacheHeader, "miss")
+		}
+		return resp, headers, nil
+	}
+	if !shared {
+		headers.Set(EmbeddingCacheHeader, "partial")
+	}
+	return embeddingResponse(method, vectors), headers, nil
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embedding"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// embedLengthExecutor embeds each input as a one-value vector holding its
+// text length and records the inputs it was sent.
+type embedLengthExecutor struct {
+	modelEchoExecutor
+	mu     sync.Mutex
+	inputs []string
+}
+
+func (e *embedLengthExecutor) Identifier() string { return "embed-cache-test" }
+
+func (e *embedLengthExecutor) vector(content gjson.Result) string {
+	text, _ := embedding.ContentText(content)
+	e.mu.Lock()
+	e.inputs = append(e.inputs, text)
+	e.mu.Unlock()
+	return `{"values":[` + strconv.Itoa(len(text)) + `]}`
+}
+
+func (e *embedLengthExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, opts coreexecutor.Options) (coreexecutor.Response, error) {
+	if opts.Alt != embedding.MethodBatchEmbed {
+		return coreexecutor.Response{Payload: []byte(`{"embedding":` + e.vector(gjson.GetBytes(req.Payload, "content")) + `}`)}, nil
+	}
+	out := `{"embeddings":[`
+	for i, request := range gjson.GetBytes(req.Payload, "requests").Array() {
+		if i > 0 {
+			out += ","
+		}
+		out += e.vector(request.Get("content"))
+	}
+	return coreexecutor.Response{Payload: []byte(out + `]}`)}, nil
+}
+
+func newEmbeddingCacheTestHandler(t *testing.T, cfg sdkconfig.EmbeddingCacheConfig) (*BaseAPIHandler, *embedLengthExecutor) {
+	t.Helper()
+	executor := &embedLengthExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "embed-cache-auth", Provider: "embed-cache-test", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "embed-model"}})
+	t.Cleanup(func() {
+		registry.GetGlobalRegistry().UnregisterClient(auth.ID)
+		embeddingCacheState.Lock()
+		defer embeddingCacheState.Unlock()
+		if embeddingCacheState.cache != nil {
+			_ = embeddingCacheState.cache.Close()
+			embeddingCacheState.cache = nil
+		}
+	})
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{EmbeddingCache: cfg}, manager), executor
+}
+
+func TestExecuteEmbeddingWithAuthManager_ServesCachedInputs(t *testing.T) {
+	h, executor := newEmbeddingCacheTestHandler(t, sdkconfig.EmbeddingCacheConfig{Dir: t.TempDir()})
+	ctx := context.Background()
+
+	first := []byte(`{"requests":[{"content":{"parts":[{"text":"alpha"}]}},{"content":{"parts":[{"text":"beta  gamma"}]}}]}`)
+	out, headers, errMsg := h.ExecuteEmbeddingWithAuthManager(ctx, "gemini", "embed-model", first, embedding.MethodBatchEmbed)
+	if errMsg != nil {
+		t.Fatalf("first batch: %v", errMsg.Error)
+	}
+	if headers.Get(EmbeddingCacheHeader) != "miss" || gjson.GetBytes(out, "embeddings.#").Int() != 2 {
+		t.Fatalf("first batch headers = %v, body = %s", headers, out)
+	}
+
+	second := []byte(`{"requests":[{"content":{"parts":[{"text":"beta gamma"}]}},{"content":{"parts":[{"text":"delta"}]}},{"content":{"parts":[{"text":" alpha "}]}}]}`)
+	out, headers, errMsg = h.ExecuteEmbeddingWithAuthManager(ctx, "gemini", "embed-model", second, embedding.MethodBatchEmbed)
+	if errMsg != nil {
+		t.Fatalf("second batch: %v", errMsg.Error)
+	}
+	if headers.Get(EmbeddingCacheHeader) != "partial" {
+		t.Fatalf("second batch header = %q", headers.Get(EmbeddingCacheHeader))
+	}
+	if got := gjson.GetBytes(out, "embeddings.#.values.0").Raw; got != "[11,5,5]" {
+		t.Fatalf("second batch vectors = %s", got)
+	}
+	if len(executor.inputs) != 3 || executor.inputs[2] != "delta" {
+		t.Fatalf("upstream inputs = %q", executor.inputs)
+	}
+
+	out, headers, errMsg = h.ExecuteEmbeddingWithAuthManager(ctx, "gemini", "embed-model", []byte(`{"content":{"parts":[{"text":"delta"}]}}`), embedding.MethodEmbed)
+	if errMsg != nil || headers.Get(EmbeddingCacheHeader) != "hit" || gjson.GetBytes(out, "embedding.values.0").Int() != 5 {
+		t.Fatalf("single hit = %s, %v, %v", out, headers, errMsg)
+	}
+	if len(executor.inputs) != 3 {
+		t.Fatalf("cache hit reached the upstream: %q", executor.inputs)
+	}
+}
+
+func TestExecuteEmbeddingWithAuthManager_DisabledPassesThrough(t *testing.T) {
+	h, executor := newEmbeddingCacheTestHandler(t, sdkconfig.EmbeddingCacheConfig{})
+	payload := []byte(`{"content":{"parts":[{"text":"alpha"}]}}`)
+	for range 2 {
+		_, headers, errMsg := h.ExecuteEmbeddingWithAuthManager(context.Background(), "gemini", "embed-model", payload, embedding.MethodEmbed)
+		if errMsg != nil {
+			t.Fatalf("execute: %v", errMsg.Error)
+		}
+		if headers.Get(EmbeddingCacheHeader) != "" {
+			t.Fatalf("disabled cache set %s", headers.Get(EmbeddingCacheHeader))
+		}
+	}
+	if len(executor.inputs) != 2 {
+		t.Fatalf("upstream inputs = %q", executor.inputs)
+	}
+}
+
+func TestExecuteEmbeddingWithAuthManager_PartitionsCacheByAPIKey(t *testing.T) {
+	payload := []byte(`{"content":{"parts":[{"text":"alpha"}]}}`)
+	embed := func(h *BaseAPIHandler, apiKey string) string {
+		t.Helper()
+		_, headers, errMsg := h.ExecuteEmbeddingWithAuthManager(loopContext(apiKey, ""), "gemini", "embed-model", payload, embedding.MethodEmbed)
+		if errMsg != nil {
+			t.Fatalf("execute for %s: %v", apiKey, errMsg.Error)
+		}
+		return headers.Get(EmbeddingCacheHeader)
+	}
+
+	h, executor := newEmbeddingCacheTestHandler(t, sdkconfig.EmbeddingCacheConfig{Dir: t.TempDir()})
+	if got := embed(h, "key-a"); got != "miss" {
+		t.Fatalf("key-a first = %q", got)
+	}
+	if got := embed(h, "key-b"); got != "miss" || len(executor.inputs) != 2 {
+		t.Fatalf("key-b was served key-a's entry: header %q, upstream inputs %q", got, executor.inputs)
+	}
+	if got := embed(h, "key-a"); got != "hit" {
+		t.Fatalf("key-a repeat = %q", got)
+	}
+
+	shared, sharedExecutor := newEmbeddingCacheTestHandler(t, sdkconfig.EmbeddingCacheConfig{Dir: t.TempDir(), Shared: true})
+	embed(shared, "key-a")
+	if got := embed(shared, "key-b"); got != "" || len(sharedExecutor.inputs) != 1 {
+		t.Fatalf("shared cache: header %q, upstream inputs %q", got, sharedExecutor.inputs)
+	}
+}
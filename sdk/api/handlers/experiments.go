@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// experimentArmFor returns the first experiment enrolling apiKey for model and
+// the arm the key is assigned to.
+func experimentArmFor(cfg *config.SDKConfig, apiKey, model string) (*config.Experiment, *config.ExperimentArm) {
+	if cfg == nil {
+		return nil, nil
+	}
+	model = strings.ToLower(strings.TrimPrefix(model, "models/"))
+	for i := range cfg.Experiments {
+		exp := &cfg.Experiments[i]
+		if len(exp.Arms) == 0 || !matchModelGlob(strings.ToLower(exp.Model), model) {
+			continue
+		}
+		if len(exp.APIKeys) > 0 && !slices.Contains(exp.APIKeys, apiKey) {
+			continue
+		}
+		return exp, assignExperimentArm(exp, apiKey)
+	}
+	return nil, nil
+}
+
+// assignExperimentArm hashes the key with the experiment name so each key
+// keeps its arm while different experiments split keys independently.
+func assignExperimentArm(exp *config.Experiment, apiKey string) *config.ExperimentArm {
+	total := 0
+	for _, arm := range exp.Arms {
+		total += max(arm.Weight, 1)
+	}
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(exp.Name))
+	_, _ = hasher.Write([]byte{0})
+	_, _ = hasher.Write([]byte(apiKey))
+	point := int(hasher.Sum32() % uint32(total))
+	for i := range exp.Arms {
+		point -= max(exp.Arms[i].Weight, 1)
+		if point < 0 {
+			return &exp.Arms[i]
+		}
+	}
+	return &exp.Arms[len(exp.Arms)-1]
+}
+
+// applyExperiment routes a request enrolled in an experiment to its arm's
+// model and tags ctx with the arm for usage records.
+func (h *BaseAPIHandler) applyExperiment(ctx context.Context, modelName string, rawJSON []byte) (context.Context, string, []byte) {
+	if h == nil || h.Cfg == nil || len(h.Cfg.Experiments) == 0 || ctx == nil {
+		return ctx, modelName, rawJSON
+	}
+	var apiKey string
+	if c, ok := ctx.Value("gin").(*gin.Context); ok && c != nil {
+		apiKey = c.GetString("apiKey")
+	}
+	exp, arm := experimentArmFor(h.Cfg, apiKey, modelName)
+	if arm == nil {
+		return ctx, modelName, rawJSON
+	}
+	if gjson.GetBytes(rawJSON, "model").Exists() {
+		if updated, err := sjson.SetBytes(rawJSON, "model", arm.Model); err == nil {
+			rawJSON = updated
+		}
+	}
+	return coreexecutor.WithExperimentArm(ctx, exp.Name, arm.Name), arm.Model, rawJSON
+}
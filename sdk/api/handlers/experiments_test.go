@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyExperiment_AssignsStableArmPerAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{Experiments: []sdkconfig.Experiment{{
+		Name:  "sonnet-vs-gemini",
+		Model: "claude-sonnet-*",
+		Arms: []sdkconfig.ExperimentArm{
+			{Name: "control", Model: "claude-sonnet-4-5", Weight: 1},
+			{Name: "treatment", Model: "gemini-2.5-pro(high)", Weight: 1},
+		},
+	}}}, nil)
+
+	counts := map[string]int{}
+	for i := 0; i < 200; i++ {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("apiKey", fmt.Sprintf("key-%d", i))
+		ctx := context.WithValue(context.Background(), "gin", c)
+
+		ctx, model, body := h.applyExperiment(ctx, "claude-sonnet-4-5", []byte(`{"model":"claude-sonnet-4-5"}`))
+		experiment, arm := coreexecutor.ExperimentArm(ctx)
+		if experiment != "sonnet-vs-gemini" || arm == "" {
+			t.Fatalf("key-%d: ExperimentArm() = %q, %q", i, experiment, arm)
+		}
+		if got := gjson.GetBytes(body, "model").String(); got != model {
+			t.Fatalf("key-%d: payload model %q, routed model %q", i, got, model)
+		}
+		_, again, _ := h.applyExperiment(ctx, "claude-sonnet-4-5", nil)
+		if again != model {
+			t.Fatalf("key-%d: assigned %q then %q", i, model, again)
+		}
+		counts[arm]++
+	}
+	if counts["control"] < 60 || counts["treatment"] < 60 {
+		t.Fatalf("arms unevenly split: %v", counts)
+	}
+
+	ctx, model, _ := h.applyExperiment(context.Background(), "gpt-5", nil)
+	if experiment, _ := coreexecutor.ExperimentArm(ctx); model != "gpt-5" || experiment != "" {
+		t.Fatalf("unmatched model routed to %q in experiment %q", model, experiment)
+	}
+}
@@ -1,6 +1,6 @@
 // Package gemini provides HTTP handlers for Gemini CLI API functionality.
 // This package implements handlers that process CLI-specific requests for Gemini API operations,
-// including content generation and streaming content generation endpoints.
+// including content generation, streaming content generation, token counting and embedding endpoints.
 // The handlers restrict access to localhost only and manage communication with the backend service.
 package gemini
 
@@ -15,11 +15,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embedding"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 )
 
 // GeminiCLIAPIHandler contains the handlers for Gemini CLI API endpoints.
@@ -66,6 +68,10 @@ func (h *GeminiCLIAPIHandler) CLIHandler(c *gin.Context) {
 		h.handleInternalGenerateContent(c, rawJSON)
 	} else if requestRawURI == "/v1internal:streamGenerateContent" {
 		h.handleInternalStreamGenerateContent(c, rawJSON)
+	} else if requestRawURI == "/v1internal:countTokens" {
+		h.handleInternalCountTokens(c, rawJSON)
+	} else if method, ok := strings.CutPrefix(requestRawURI, "/v1internal:"); ok && embedding.IsMethod(method) {
+		h.handleInternalEmbedContent(c, method, rawJSON)
 	} else {
 		reqBody := bytes.NewBuffer(rawJSON)
 		req, err := http.NewRequest("POST", fmt.Sprintf("https://cloudcode-pa.googleapis.com%s", c.Request.URL.RequestURI()), reqBody)
@@ -140,7 +146,6 @@ func (h *GeminiCLIAPIHandler) handleInternalStreamGenerateContent(c *gin.Context
 		c.Header("Content-Type", "text/event-stream")
 		c.Header("Cache-Control", "no-cache")
 		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
 	}
 
 	// Get the http.Flusher interface to manually flush the response.
@@ -184,6 +189,61 @@ func (h *GeminiCLIAPIHandler) handleInternalGenerateContent(c *gin.Context, rawJ
 	cliCancel()
 }
 
+// handleInternalCountTokens handles token counting requests. Gemini CLI names
+// the model only inside the request envelope, so it is copied to the top level
+// where the routing and the translators expect it.
+func (h *GeminiCLIAPIHandler) handleInternalCountTokens(c *gin.Context, rawJSON []byte) {
+	c.Header("Content-Type", "application/json")
+	modelName := cliRequestModel(rawJSON)
+	rawJSON, _ = sjson.SetBytes(rawJSON, "model", modelName)
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, upstreamHeaders, errMsg := h.ExecuteCountWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	handlers.WriteUpstreamHeaders(c.Writer.Header(), upstreamHeaders)
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
+// handleInternalEmbedContent handles embedContent and batchEmbedContents
+// requests. The request envelope, if any, is unwrapped so the backend receives
+// a plain Gemini embedding request.
+func (h *GeminiCLIAPIHandler) handleInternalEmbedContent(c *gin.Context, method string, rawJSON []byte) {
+	c.Header("Content-Type", "application/json")
+	modelName := cliRequestModel(rawJSON)
+	if request := gjson.GetBytes(rawJSON, "request"); request.IsObject() {
+		rawJSON = []byte(request.Raw)
+	}
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, upstreamHeaders, errMsg := h.ExecuteEmbeddingWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, method)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	handlers.WriteUpstreamHeaders(c.Writer.Header(), upstreamHeaders)
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
+// cliRequestModel returns the model of a Gemini CLI request without its
+// "models/" prefix, preferring the top-level model over the enveloped one.
+func cliRequestModel(rawJSON []byte) string {
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	if modelName == "" {
+		modelName = gjson.GetBytes(rawJSON, "request.model").String()
+	}
+	if modelName == "" {
+		modelName = gjson.GetBytes(rawJSON, "request.requests.0.model").String()
+	}
+	return strings.TrimPrefix(modelName, "models/")
+}
+
 func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flusher, alt string, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage) {
 	var keepAliveInterval *time.Duration
 	if alt != "" {
@@ -220,7 +280,7 @@ func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flus
 			if errMsg.Error != nil && errMsg.Error.Error() != "" {
 				errText = errMsg.Error.Error()
 			}
-			body := handlers.BuildErrorResponseBody(status, errText)
+			body := handlers.BuildErrorResponseBodyForRequest(c, status, errText)
 			if alt == "" {
 				_, _ = fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", string(body))
 			} else {
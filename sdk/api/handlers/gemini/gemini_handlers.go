@@ -1,6 +1,6 @@
 // Package gemini provides HTTP handlers for Gemini API endpoints.
 // This package implements handlers for managing Gemini model operations including
-// model listing, content generation, streaming content generation, and token counting.
+// model listing, content generation, streaming content generation, token counting and embeddings.
 // It serves as a proxy layer between clients and the Gemini backend service,
 // handling request translation, client management, and response processing.
 package gemini
@@ -14,6 +14,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/embedding"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
@@ -48,35 +49,41 @@ func (h *GeminiAPIHandler) Models() []map[string]any {
 // GeminiModels handles the Gemini models listing endpoint.
 // It returns a JSON response containing available Gemini models and their specifications.
 func (h *GeminiAPIHandler) GeminiModels(c *gin.Context) {
-	rawModels := h.Models()
+	rawModels := h.FilterModelsForAPIKey(c, h.Models())
 	normalizedModels := make([]map[string]any, 0, len(rawModels))
-	defaultMethods := []string{"generateContent"}
 	for _, model := range rawModels {
-		normalizedModel := make(map[string]any, len(model))
-		for k, v := range model {
-			normalizedModel[k] = v
-		}
-		if name, ok := normalizedModel["name"].(string); ok && name != "" {
-			if !strings.HasPrefix(name, "models/") {
-				normalizedModel["name"] = "models/" + name
-			}
-			if displayName, _ := normalizedModel["displayName"].(string); displayName == "" {
-				normalizedModel["displayName"] = name
-			}
-			if description, _ := normalizedModel["description"].(string); description == "" {
-				normalizedModel["description"] = name
-			}
-		}
-		if _, ok := normalizedModel["supportedGenerationMethods"]; !ok {
-			normalizedModel["supportedGenerationMethods"] = defaultMethods
-		}
-		normalizedModels = append(normalizedModels, normalizedModel)
+		normalizedModels = append(normalizedModels, normalizeGeminiModel(model))
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"models": normalizedModels,
 	})
 }
 
+// normalizeGeminiModel returns a copy of model with the fields Gemini clients
+// rely on: a "models/" name, a display name, a description and the supported
+// methods. Every routed model can count tokens, whatever its backend.
+func normalizeGeminiModel(model map[string]any) map[string]any {
+	normalizedModel := make(map[string]any, len(model))
+	for k, v := range model {
+		normalizedModel[k] = v
+	}
+	if name, ok := normalizedModel["name"].(string); ok && name != "" {
+		if !strings.HasPrefix(name, "models/") {
+			normalizedModel["name"] = "models/" + name
+		}
+		if displayName, _ := normalizedModel["displayName"].(string); displayName == "" {
+			normalizedModel["displayName"] = name
+		}
+		if description, _ := normalizedModel["description"].(string); description == "" {
+			normalizedModel["description"] = name
+		}
+	}
+	if _, ok := normalizedModel["supportedGenerationMethods"]; !ok {
+		normalizedModel["supportedGenerationMethods"] = []string{"generateContent", "countTokens"}
+	}
+	return normalizedModel
+}
+
 // GeminiGetHandler handles GET requests for specific Gemini model information.
 // It returns detailed information about a specific Gemini model based on the action parameter.
 func (h *GeminiAPIHandler) GeminiGetHandler(c *gin.Context) {
@@ -95,7 +102,7 @@ func (h *GeminiAPIHandler) GeminiGetHandler(c *gin.Context) {
 	action := strings.TrimPrefix(request.Action, "/")
 
 	// Get dynamic models from the global registry and find the matching one
-	availableModels := h.Models()
+	availableModels := h.FilterModelsForAPIKey(c, h.Models())
 	var targetModel map[string]any
 
 	for _, model := range availableModels {
@@ -108,11 +115,7 @@ func (h *GeminiAPIHandler) GeminiGetHandler(c *gin.Context) {
 	}
 
 	if targetModel != nil {
-		// Ensure the name has 'models/' prefix in the output if it's a Gemini model
-		if name, ok := targetModel["name"].(string); ok && name != "" && !strings.HasPrefix(name, "models/") {
-			targetModel["name"] = "models/" + name
-		}
-		c.JSON(http.StatusOK, targetModel)
+		c.JSON(http.StatusOK, normalizeGeminiModel(targetModel))
 		return
 	}
 
@@ -160,6 +163,8 @@ func (h *GeminiAPIHandler) GeminiHandler(c *gin.Context) {
 		h.handleStreamGenerateContent(c, action[0], rawJSON)
 	case "countTokens":
 		h.handleCountTokens(c, action[0], rawJSON)
+	case embedding.MethodEmbed, embedding.MethodBatchEmbed:
+		h.handleEmbedContent(c, action[0], method, rawJSON)
 	}
 }
 
@@ -194,13 +199,12 @@ func (h *GeminiAPIHandler) handleStreamGenerateContent(c *gin.Context, modelName
 		c.Header("Content-Type", "text/event-stream")
 		c.Header("Cache-Control", "no-cache")
 		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
 	}
 
 	// Peek at the first chunk
 	for {
 		select {
-		case <-c.Request.Context().Done():
+		case <-handlers.ClientDone(c):
 			cliCancel(c.Request.Context().Err())
 			return
 		case errMsg, ok := <-errChan:
@@ -275,6 +279,23 @@ func (h *GeminiAPIHandler) handleCountTokens(c *gin.Context, modelName string, r
 	cliCancel()
 }
 
+// handleEmbedContent handles embedContent and batchEmbedContents requests.
+// The routed backend serves them from its own embedding API, so Gemini
+// clients can embed with any configured embedding model.
+func (h *GeminiAPIHandler) handleEmbedContent(c *gin.Context, modelName, method string, rawJSON []byte) {
+	c.Header("Content-Type", "application/json")
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, upstreamHeaders, errMsg := h.ExecuteEmbeddingWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, method)
+	if errMsg != nil {
+		h.WriteErrorResponse(c, errMsg)
+		cliCancel(errMsg.Error)
+		return
+	}
+	handlers.WriteUpstreamHeaders(c.Writer.Header(), upstreamHeaders)
+	_, _ = c.Writer.Write(resp)
+	cliCancel()
+}
+
 // handleGenerateContent handles non-streaming content generation requests for Gemini models.
 // This function processes the request synchronously and returns the complete generated
 // response in a single API call. It supports various generation parameters and
@@ -330,7 +351,7 @@ func (h *GeminiAPIHandler) forwardGeminiStream(c *gin.Context, flusher http.Flus
 			if errMsg.Error != nil && errMsg.Error.Error() != "" {
 				errText = errMsg.Error.Error()
 			}
-			body := handlers.BuildErrorResponseBody(status, errText)
+			body := handlers.BuildErrorResponseBodyForRequest(c, status, errText)
 			if alt == "" {
 				_, _ = fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", string(body))
 			} else {
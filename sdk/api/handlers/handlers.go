@@ -14,6 +14,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/imageprep"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
@@ -22,6 +23,9 @@ import (
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
 	"golang.org/x/net/context"
 )
 
@@ -141,6 +145,22 @@ func BuildErrorResponseBody(status int, errText string) []byte {
 	return payload
 }
 
+// BuildErrorResponseBodyForRequest builds the body like BuildErrorResponseBody
+// and tags JSON object bodies with the request ID of c, so clients can quote it
+// when reporting a failure.
+func BuildErrorResponseBodyForRequest(c *gin.Context, status int, errText string) []byte {
+	body := BuildErrorResponseBody(status, errText)
+	requestID := logging.GetGinRequestID(c)
+	if requestID == "" || !gjson.ParseBytes(body).IsObject() || gjson.GetBytes(body, "request_id").Exists() {
+		return body
+	}
+	tagged, errSet := sjson.SetBytes(body, "request_id", requestID)
+	if errSet != nil {
+		return body
+	}
+	return tagged
+}
+
 // StreamingKeepAliveInterval returns the SSE keep-alive interval for this server.
 // Returning 0 disables keep-alives (default when unset).
 func StreamingKeepAliveInterval(cfg *config.SDKConfig) time.Duration {
@@ -185,6 +205,11 @@ func PassthroughHeadersEnabled(cfg *config.SDKConfig) bool {
 	return cfg != nil && cfg.PassthroughHeaders
 }
 
+// RateLimitHeadersEnabled returns whether the quota left on serving credentials is reported to clients.
+func RateLimitHeadersEnabled(cfg *config.SDKConfig) bool {
+	return cfg != nil && cfg.RateLimitHeaders
+}
+
 func requestExecutionMetadata(ctx context.Context) map[string]any {
 	// Idempotency-Key is an optional client-supplied header used to correlate retries.
 	// It is forwarded as execution metadata; when absent we generate a UUID.
@@ -344,6 +369,12 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 		go func() {
 			select {
 			case <-requestCtx.Done():
+				if hold, keep := clientGone(c); keep {
+					select {
+					case <-hold:
+					case <-newCtx.Done():
+					}
+				}
 				cancel()
 			case <-newCtx.Done():
 			}
@@ -469,12 +500,38 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, http.Header, *interfaces.ErrorMessage) {
+	ctx, mwReq, errMsg := runPreTranslation(ctx, handlerType, modelName, rawJSON, false, false)
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
+	if mwReq.Reply != nil {
+		return mwReq.Reply, nil, nil
+	}
+	modelName, rawJSON = mwReq.Model, mwReq.Payload
+	if rawJSON, errMsg = h.applyPromptTemplate(handlerType, rawJSON); errMsg != nil {
+		return nil, nil, errMsg
+	}
+	modelName, rawJSON = h.applySmartRouter(ctx, modelName, rawJSON)
+	if errMsg = h.checkModelAccess(ctx, modelName); errMsg != nil {
+		return nil, nil, errMsg
+	}
+	ctx, modelName, rawJSON = h.applyExperiment(ctx, modelName, rawJSON)
+	rawJSON = h.preprocessImages(rawJSON)
+	if rawJSON, errMsg = h.detectAgentLoop(ctx, handlerType, rawJSON); errMsg != nil {
+		return nil, nil, errMsg
+	}
+	rawJSON, outputLimit, errMsg := h.applyRequestLimits(ctx, handlerType, modelName, rawJSON)
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
 		return nil, nil, errMsg
 	}
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
+	h.applyStickySession(ctx, reqMeta, normalizedModel)
+	h.applyRateLimitQueueClient(ctx, reqMeta)
 	payload := rawJSON
 	if len(payload) == 0 {
 		payload = nil
@@ -490,6 +547,12 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
+	h.applyClientProfile(ctx, &opts)
+	applyServiceTier(ctx, handlerType, rawJSON, reqMeta)
+	if RateLimitHeadersEnabled(h.Cfg) {
+		reqMeta[coreexecutor.RateLimitHeadersMetadataKey] = true
+	}
+	shadow := h.startShadow(ctx, handlerType, modelName, rawJSON, alt, false)
 	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
 	if err != nil {
 		status := http.StatusInternalServerError
@@ -504,23 +567,59 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 				addon = hdr.Clone()
 			}
 		}
-		return nil, nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
+		errMsg := &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
+		shadow.finishPrimary(nil, errMsg)
+		return nil, nil, errMsg
+	}
+	var headers http.Header
+	if PassthroughHeadersEnabled(h.Cfg) {
+		headers = FilterUpstreamHeaders(resp.Headers)
+	} else {
+		headers = proxyHeaders(resp.Headers)
 	}
-	if !PassthroughHeadersEnabled(h.Cfg) {
-		return resp.Payload, nil, nil
+	body := applyReasoningPolicy(handlerType, h.reasoningMode(ctx), h.repairToolCallArguments(handlerType, resp.Payload, false))
+	out, errMsg := runPostResponse(ctx, mwReq, body, headers, false)
+	shadow.finishPrimary(out, errMsg)
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
+	if outputLimit > 0 && stoppedForLength(handlerType, out) {
+		if headers == nil {
+			headers = make(http.Header)
+		}
+		headers.Set("Warning", outputLimitWarning(outputLimit))
 	}
-	return resp.Payload, FilterUpstreamHeaders(resp.Headers), nil
+	return out, headers, nil
 }
 
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, http.Header, *interfaces.ErrorMessage) {
+	ctx, mwReq, errMsg := runPreTranslation(ctx, handlerType, modelName, rawJSON, false, true)
+	if errMsg != nil {
+		return nil, nil, errMsg
+	}
+	if mwReq.Reply != nil {
+		return mwReq.Reply, nil, nil
+	}
+	modelName, rawJSON = mwReq.Model, mwReq.Payload
+	if rawJSON, errMsg = h.applyPromptTemplate(handlerType, rawJSON); errMsg != nil {
+		return nil, nil, errMsg
+	}
+	modelName, rawJSON = h.applySmartRouter(ctx, modelName, rawJSON)
+	if errMsg = h.checkModelAccess(ctx, modelName); errMsg != nil {
+		return nil, nil, errMsg
+	}
+	ctx, modelName, rawJSON = h.applyExperiment(ctx, modelName, rawJSON)
+	rawJSON = h.preprocessImages(rawJSON)
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
 		return nil, nil, errMsg
 	}
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
+	h.applyStickySession(ctx, reqMeta, normalizedModel)
+	h.applyRateLimitQueueClient(ctx, reqMeta)
 	payload := rawJSON
 	if len(payload) == 0 {
 		payload = nil
@@ -536,6 +635,7 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
+	h.applyClientProfile(ctx, &opts)
 	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
 	if err != nil {
 		status := http.StatusInternalServerError
@@ -552,16 +652,70 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 		}
 		return nil, nil, &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
 	}
-	if !PassthroughHeadersEnabled(h.Cfg) {
-		return resp.Payload, nil, nil
+	var headers http.Header
+	if PassthroughHeadersEnabled(h.Cfg) {
+		headers = FilterUpstreamHeaders(resp.Headers)
+	}
+	out, errMsg := runPostResponse(ctx, mwReq, resp.Payload, headers, false)
+	if errMsg != nil {
+		return nil, nil, errMsg
 	}
-	return resp.Payload, FilterUpstreamHeaders(resp.Headers), nil
+	return out, headers, nil
 }
 
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
 // This path is the only supported execution route.
 // The returned http.Header carries upstream response headers captured before streaming begins.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, http.Header, <-chan *interfaces.ErrorMessage) {
+	ctx, mwReq, errMsg := runPreTranslation(ctx, handlerType, modelName, rawJSON, true, false)
+	if errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, nil, errChan
+	}
+	if mwReq.Reply != nil {
+		dataChan := make(chan []byte, 1)
+		dataChan <- mwReq.Reply
+		close(dataChan)
+		errChan := make(chan *interfaces.ErrorMessage)
+		close(errChan)
+		return dataChan, nil, errChan
+	}
+	modelName, rawJSON = mwReq.Model, mwReq.Payload
+	if rawJSON, errMsg = h.applyPromptTemplate(handlerType, rawJSON); errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, nil, errChan
+	}
+	modelName, rawJSON = h.applySmartRouter(ctx, modelName, rawJSON)
+	// Share one attempt counter across bootstrap retries of this stream.
+	ctx = coreexecutor.WithAttemptCounter(ctx)
+	if errMsg = h.checkModelAccess(ctx, modelName); errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, nil, errChan
+	}
+	ctx, modelName, rawJSON = h.applyExperiment(ctx, modelName, rawJSON)
+	rawJSON = h.preprocessImages(rawJSON)
+	if rawJSON, errMsg = h.detectAgentLoop(ctx, handlerType, rawJSON); errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, nil, errChan
+	}
+	rawJSON, outputLimit, errMsg := h.applyRequestLimits(ctx, handlerType, modelName, rawJSON)
+	if errMsg != nil {
+		errChan := make(chan *interfaces.ErrorMessage, 1)
+		errChan <- errMsg
+		close(errChan)
+		return nil, nil, errChan
+	}
+	if outputLimit > 0 {
+		setStreamOutputLimitWarning(ctx, outputLimit)
+	}
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
@@ -571,6 +725,8 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 	}
 	reqMeta := requestExecutionMetadata(ctx)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
+	h.applyStickySession(ctx, reqMeta, normalizedModel)
+	h.applyRateLimitQueueClient(ctx, reqMeta)
 	payload := rawJSON
 	if len(payload) == 0 {
 		payload = nil
@@ -586,7 +742,22 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
+	h.applyClientProfile(ctx, &opts)
+	applyServiceTier(ctx, handlerType, rawJSON, reqMeta)
+	if RateLimitHeadersEnabled(h.Cfg) {
+		reqMeta[coreexecutor.RateLimitHeadersMetadataKey] = true
+	}
+	// Only SSE responses can carry keep-alives while the request is queued.
+	stopQueueKeepAlive := func() {}
+	if alt == "" {
+		var queueCallback func(time.Duration)
+		if queueCallback, stopQueueKeepAlive = h.rateLimitQueueKeepAlive(ctx); queueCallback != nil {
+			reqMeta[coreexecutor.QueueWaitCallbackMetadataKey] = queueCallback
+		}
+	}
+	shadow := h.startShadow(ctx, handlerType, modelName, rawJSON, alt, true)
 	streamResult, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	stopQueueKeepAlive()
 	if err != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
@@ -601,7 +772,9 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 				addon = hdr.Clone()
 			}
 		}
-		errChan <- &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
+		errMsg := &interfaces.ErrorMessage{StatusCode: status, Error: err, Addon: addon}
+		shadow.finishPrimary(nil, errMsg)
+		errChan <- errMsg
 		close(errChan)
 		return nil, nil, errChan
 	}
@@ -614,6 +787,8 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		if upstreamHeaders == nil {
 			upstreamHeaders = make(http.Header)
 		}
+	} else {
+		upstreamHeaders = proxyHeaders(streamResult.Headers)
 	}
 	chunks := streamResult.Chunks
 	dataChan := make(chan []byte)
@@ -624,6 +799,9 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		sentPayload := false
 		bootstrapRetries := 0
 		maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
+		sanitizeMode := streamSanitizeMode(h.Cfg)
+		toolCallMode := toolCallDeltasMode(h.Cfg)
+		aggregator := newToolCallDeltaAggregator(handlerType, toolCallMode)
 
 		sendErr := func(msg *interfaces.ErrorMessage) bool {
 			if ctx == nil {
@@ -651,6 +829,56 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 			}
 		}
 
+		// emitPayload validates and post-processes one outbound chunk and
+		// sends it, reporting whether the stream should continue.
+		emitPayload := func(payload []byte) bool {
+			if handlerType == "openai-response" {
+				if err := validateSSEDataJSON(payload); err != nil {
+					_ = sendErr(&interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: err})
+					return false
+				}
+			}
+			out, errMsg := runPostResponse(ctx, mwReq, h.repairToolCallArguments(handlerType, cloneBytes(payload), true), upstreamHeaders, true)
+			if errMsg != nil {
+				_ = sendErr(errMsg)
+				return false
+			}
+			sentPayload = true
+			return sendData(out)
+		}
+
+		// sendLimited emits a chunk unless it passes the API key's output
+		// limit, in which case the stream ends as if the model had stopped at
+		// its length limit.
+		limitGuard := newOutputLimitGuard(handlerType, modelName, outputLimit)
+		sendLimited := func(payload []byte) bool {
+			if limitGuard == nil || limitGuard.admit(payload) {
+				return emitPayload(payload)
+			}
+			log.Infof("stream for %s reached the output limit of %d tokens set by api-key-limits", modelName, outputLimit)
+			for _, closing := range limitGuard.closing() {
+				if !emitPayload(closing) {
+					break
+				}
+			}
+			return false
+		}
+
+		// sendPayload applies the API key's reasoning policy to a chunk
+		// before sending what is left of it.
+		reasoning := h.newReasoningFilter(ctx, handlerType)
+		sendPayload := func(payload []byte) bool {
+			if reasoning == nil {
+				return sendLimited(payload)
+			}
+			for _, filtered := range reasoning.Process(payload) {
+				if !sendLimited(filtered) {
+					return false
+				}
+			}
+			return true
+		}
+
 		bootstrapEligible := func(err error) bool {
 			status := statusFromError(err)
 			if status == 0 {
@@ -680,6 +908,20 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					chunk, ok = <-chunks
 				}
 				if !ok {
+					if aggregator != nil {
+						for _, payload := range aggregator.Flush() {
+							if !sendPayload(payload) {
+								return
+							}
+						}
+					}
+					if reasoning != nil {
+						for _, payload := range reasoning.Flush() {
+							if !sendLimited(payload) {
+								return
+							}
+						}
+					}
 					return
 				}
 				if chunk.Err != nil {
@@ -693,8 +935,12 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 							if retryErr == nil {
 								if passthroughHeadersEnabled {
 									replaceHeader(upstreamHeaders, FilterUpstreamHeaders(retryResult.Headers))
+								} else if upstreamHeaders != nil {
+									replaceHeader(upstreamHeaders, proxyHeaders(retryResult.Headers))
 								}
 								chunks = retryResult.Chunks
+								aggregator = newToolCallDeltaAggregator(handlerType, toolCallMode)
+								reasoning = h.newReasoningFilter(ctx, handlerType)
 								continue outer
 							}
 							streamErr = retryErr
@@ -716,22 +962,34 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					_ = sendErr(&interfaces.ErrorMessage{StatusCode: status, Error: streamErr, Addon: addon})
 					return
 				}
-				if len(chunk.Payload) > 0 {
-					if handlerType == "openai-response" {
-						if err := validateSSEDataJSON(chunk.Payload); err != nil {
-							_ = sendErr(&interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: err})
-							return
-						}
+				if len(chunk.Payload) > 0 && sanitizeMode != "" {
+					cleaned, errSanitize := sanitizeStreamChunk(handlerType, sanitizeMode, chunk.Payload)
+					if errSanitize != nil {
+						_ = sendErr(&interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: errSanitize})
+						return
 					}
-					sentPayload = true
-					if okSendData := sendData(cloneBytes(chunk.Payload)); !okSendData {
+					if len(bytes.TrimSpace(cleaned)) == 0 {
+						continue
+					}
+					chunk.Payload = cleaned
+				}
+				if len(chunk.Payload) == 0 {
+					continue
+				}
+				payloads := [][]byte{chunk.Payload}
+				if aggregator != nil {
+					payloads = aggregator.Process(chunk.Payload)
+				}
+				for _, payload := range payloads {
+					if !sendPayload(payload) {
 						return
 					}
 				}
 			}
 		}
 	}()
-	return dataChan, upstreamHeaders, errChan
+	teeData, teeErrs := shadow.teeStream(ctx, dataChan, errChan)
+	return teeData, upstreamHeaders, teeErrs
 }
 
 func validateSSEDataJSON(chunk []byte) error {
@@ -775,6 +1033,15 @@ func statusFromError(err error) int {
 	return 0
 }
 
+// preprocessImages downscales and re-encodes the request's embedded images
+// when image pre-processing is enabled.
+func (h *BaseAPIHandler) preprocessImages(rawJSON []byte) []byte {
+	if h.Cfg == nil || !h.Cfg.ImagePreprocess.Enable {
+		return rawJSON
+	}
+	return imageprep.Process(rawJSON, h.Cfg.ImagePreprocess)
+}
+
 func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string, normalizedModel string, err *interfaces.ErrorMessage) {
 	resolvedModelName := modelName
 	initialSuffix := thinking.ParseSuffix(modelName)
@@ -865,7 +1132,7 @@ func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.Erro
 		}
 	}
 
-	body := BuildErrorResponseBody(status, errText)
+	body := BuildErrorResponseBodyForRequest(c, status, errText)
 	// Append first to preserve upstream response logs, then drop duplicate payloads if already recorded.
 	var previous []byte
 	if existing, exists := c.Get("API_RESPONSE"); exists {
@@ -883,6 +1150,9 @@ func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.Erro
 		}
 	}
 
+	if writeQueuedErrorEvent(c, body) {
+		return
+	}
 	if !c.Writer.Written() {
 		c.Writer.Header().Set("Content-Type", "application/json")
 	}
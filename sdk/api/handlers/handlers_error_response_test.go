@@ -9,7 +9,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
 )
 
 func TestWriteErrorResponse_AddonHeadersDisabledByDefault(t *testing.T) {
@@ -66,3 +68,21 @@ func TestWriteErrorResponse_AddonHeadersEnabled(t *testing.T) {
 		t.Fatalf("X-Request-Id = %#v, want %#v", got, []string{"new-1", "new-2"})
 	}
 }
+
+func TestWriteErrorResponse_IncludesRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	logging.SetGinRequestID(c, "client-trace-01")
+
+	handler := NewBaseAPIHandlers(nil, nil)
+	handler.WriteErrorResponse(c, &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: errors.New(`{"error":{"message":"upstream down"}}`)})
+
+	if got := gjson.Get(recorder.Body.String(), "request_id").String(); got != "client-trace-01" {
+		t.Fatalf("request_id = %q, body %s", got, recorder.Body.String())
+	}
+	if got := gjson.Get(recorder.Body.String(), "error.message").String(); got != "upstream down" {
+		t.Fatalf("upstream error body not preserved: %s", recorder.Body.String())
+	}
+}
@@ -3,6 +3,10 @@ package handlers
 import (
 	"net/http"
 	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/seed"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/servicetier"
 )
 
 // hopByHopHeaders lists RFC 7230 Section 6.1 hop-by-hop headers that MUST NOT
@@ -48,6 +52,21 @@ func FilterUpstreamHeaders(src http.Header) http.Header {
 	return dst
 }
 
+// proxyHeaders returns the headers the proxy itself stamps on upstream results.
+// They reach clients even when upstream header passthrough is disabled.
+func proxyHeaders(src http.Header) http.Header {
+	var dst http.Header
+	for _, key := range append([]string{seed.HonoredHeader, servicetier.ResponseHeader}, ratelimit.Headers...) {
+		if value := src.Get(key); value != "" {
+			if dst == nil {
+				dst = make(http.Header)
+			}
+			dst.Set(key, value)
+		}
+	}
+	return dst
+}
+
 func connectionScopedHeaders(src http.Header) map[string]struct{} {
 	scoped := make(map[string]struct{})
 	for _, rawValue := range src.Values("Connection") {
@@ -53,3 +53,24 @@ func TestFilterUpstreamHeaders_ReturnsNilWhenAllHeadersBlocked(t *testing.T) {
 		t.Fatalf("expected nil when all headers are filtered, got %#v", filtered)
 	}
 }
+
+func TestProxyHeaders_KeepsSeedReportOnly(t *testing.T) {
+	src := http.Header{}
+	src.Set("X-Request-Id", "req-1")
+	src.Set("X-Seed-Honored", "false")
+	src.Set("X-RateLimit-Remaining", "7")
+
+	got := proxyHeaders(src)
+	if got.Get("X-Seed-Honored") != "false" {
+		t.Fatalf("expected seed report to be kept, got %v", got)
+	}
+	if got.Get("X-RateLimit-Remaining") != "7" {
+		t.Fatalf("expected rate-limit report to be kept, got %v", got)
+	}
+	if got.Get("X-Request-Id") != "" {
+		t.Fatalf("expected upstream headers to be dropped, got %v", got)
+	}
+	if proxyHeaders(http.Header{"X-Request-Id": {"req-1"}}) != nil {
+		t.Fatal("expected nil without proxy headers")
+	}
+}
@@ -0,0 +1,640 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// Job states.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+)
+
+// jobFileSuffix marks job records in the artifact directory.
+const jobFileSuffix = ".job"
+
+const (
+	jobWebhookTimeout = 10 * time.Second
+	// maxJobError bounds the upstream error body kept in a failed job.
+	maxJobError = 4 << 10
+)
+
+type jobContextKey struct{}
+
+// isJobRequest reports whether r is a job generation dispatched by a
+// JobManager rather than a client request.
+func isJobRequest(r *http.Request) bool {
+	return r != nil && r.Context().Value(jobContextKey{}) != nil
+}
+
+// jobRecord is the persisted state of a job. The request is kept only until
+// the job finishes, so credentials do not outlive it.
+type jobRecord struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Status      string          `json:"status"`
+	Endpoint    string          `json:"endpoint"`
+	Query       string          `json:"query,omitempty"`
+	Header      http.Header     `json:"header,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	WebhookURL  string          `json:"webhook_url,omitempty"`
+	RemoteAddr  string          `json:"remote_addr,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	StatusCode  int             `json:"status_code,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+func (r *jobRecord) finished() bool {
+	return r.Status == JobStatusCompleted || r.Status == JobStatusFailed
+}
+
+// jobView is the job object returned to clients and webhooks.
+type jobView struct {
+	ID          string    `json:"id"`
+	Object      string    `json:"object"`
+	Status      string    `json:"status"`
+	Endpoint    string    `json:"endpoint"`
+	CreatedAt   int64     `json:"created_at"`
+	StartedAt   int64     `json:"started_at,omitempty"`
+	CompletedAt int64     `json:"completed_at,omitempty"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       *jobError `json:"error,omitempty"`
+	ResultURL   string    `json:"result_url,omitempty"`
+}
+
+type jobError struct {
+	Message string `json:"message"`
+}
+
+func (r *jobRecord) view() jobView {
+	v := jobView{ID: r.ID, Object: "job", Status: r.Status, Endpoint: r.Endpoint, CreatedAt: r.CreatedAt.Unix(), StatusCode: r.StatusCode}
+	if r.StartedAt != nil {
+		v.StartedAt = r.StartedAt.Unix()
+	}
+	if r.CompletedAt != nil {
+		v.CompletedAt = r.CompletedAt.Unix()
+	}
+	if r.Error != "" {
+		v.Error = &jobError{Message: r.Error}
+	}
+	if r.Status == JobStatusCompleted {
+		v.ResultURL = "/v1/jobs/" + r.ID + "/result"
+	}
+	return v
+}
+
+// JobManager runs generations submitted through /v1/jobs in the background.
+// Jobs are replayed through dispatch, the server's router, with the headers
+// and client address of the submitting request, so they pass the same
+// authentication, routing and middleware as a direct call. Results go to the
+// artifact store.
+type JobManager struct {
+	h        *BaseAPIHandler
+	dispatch http.Handler
+	// client delivers webhooks to public addresses only; allowlisted sends
+	// to the hosts of jobs.webhook-hosts, which may be private.
+	client      *http.Client
+	allowlisted *http.Client
+
+	slotsMu sync.Mutex
+	slots   chan struct{}
+}
+
+// NewJobManager returns a job manager replaying jobs through dispatch.
+func NewJobManager(h *BaseAPIHandler, dispatch http.Handler) *JobManager {
+	return &JobManager{h: h, dispatch: dispatch, client: newWebhookClient(true), allowlisted: newWebhookClient(false)}
+}
+
+// newWebhookClient returns the client webhooks are posted with. Redirects
+// are not followed and proxies not used, so the address dialed is the one
+// checked; with publicOnly, dialing a non-public address fails.
+func newWebhookClient(publicOnly bool) *http.Client {
+	dialer := &net.Dialer{Timeout: jobWebhookTimeout}
+	if publicOnly {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			if addr, err := netip.ParseAddrPort(address); err != nil || !publicAddr(addr.Addr()) {
+				return fmt.Errorf("webhook address %s is not public", address)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:       jobWebhookTimeout,
+		Transport:     transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether addr is a globally routable unicast address,
+// which excludes loopback, link-local, private and shared ranges.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// webhookHosts returns the configured webhook host allowlist.
+func (m *JobManager) webhookHosts() []string {
+	if m.h.Cfg == nil {
+		return nil
+	}
+	return m.h.Cfg.Jobs.WebhookHosts
+}
+
+func webhookHostAllowed(hosts []string, host string) bool {
+	for _, allowed := range hosts {
+		if strings.EqualFold(strings.TrimSpace(allowed), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebhook validates a webhook URL against the allowlist or, without
+// one, rejects hosts given as non-public addresses; host names are checked
+// once resolved, when the webhook is delivered.
+func (m *JobManager) checkWebhook(webhook string) string {
+	parsed, err := url.Parse(webhook)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "webhook_url must be an http or https URL"
+	}
+	if hosts := m.webhookHosts(); len(hosts) > 0 {
+		if !webhookHostAllowed(hosts, parsed.Hostname()) {
+			return "webhook_url host is not allowed"
+		}
+		return ""
+	}
+	if addr, errAddr := netip.ParseAddr(parsed.Hostname()); errAddr == nil && !publicAddr(addr) {
+		return "webhook_url must not point to a private address"
+	}
+	return ""
+}
+
+func jobsUnavailable(c *gin.Context) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": gin.H{"message": "background jobs need artifacts.dir to be configured", "type": "invalid_request_error"}})
+}
+
+func jobNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"message": "job not found", "type": "invalid_request_error"}})
+}
+
+func jobBadRequest(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"message": message, "type": "invalid_request_error"}})
+}
+
+// submittableEndpoint reports whether endpoint is a generation route a job
+// may target.
+func submittableEndpoint(endpoint string) bool {
+	if !strings.HasPrefix(endpoint, "/v1/") && !strings.HasPrefix(endpoint, "/v1beta/") {
+		return false
+	}
+	return endpoint != "/v1/jobs" && !strings.HasPrefix(endpoint, "/v1/jobs/") && !strings.Contains(endpoint, "..")
+}
+
+// Submit handles POST /v1/jobs. The body names the endpoint to call, the
+// request body to send it and an optional webhook_url notified when the job
+// finishes. It answers 202 with the queued job.
+func (m *JobManager) Submit(c *gin.Context) {
+	settings, ok := artifactConfig(m.h.Cfg)
+	if !ok {
+		jobsUnavailable(c)
+		return
+	}
+	var req struct {
+		Endpoint   string          `json:"endpoint"`
+		Body       json.RawMessage `json:"body"`
+		WebhookURL string          `json:"webhook_url"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		jobBadRequest(c, "invalid job request: "+err.Error())
+		return
+	}
+	endpoint := strings.TrimSpace(req.Endpoint)
+	if !submittableEndpoint(endpoint) {
+		jobBadRequest(c, "endpoint must be a /v1 or /v1beta generation route")
+		return
+	}
+	if !gjson.ParseBytes(req.Body).IsObject() {
+		jobBadRequest(c, "body must be a JSON object")
+		return
+	}
+	webhook := strings.TrimSpace(req.WebhookURL)
+	if webhook != "" {
+		if message := m.checkWebhook(webhook); message != "" {
+			jobBadRequest(c, message)
+			return
+		}
+	}
+	if err := os.MkdirAll(settings.dir, 0o700); err != nil {
+		log.Warnf("jobs: create %s: %v", settings.dir, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"message": "failed to store job", "type": "server_error"}})
+		return
+	}
+
+	id := "job_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	header := c.Request.Header.Clone()
+	for _, key := range []string{"Content-Length", "Connection", "Accept-Encoding", logging.RequestIDHeader} {
+		header.Del(key)
+	}
+	header.Set("Content-Type", "application/json")
+	rec := &jobRecord{
+		ID:         id,
+		Name:       artifactName(c.GetString("apiKey"), id),
+		Status:     JobStatusQueued,
+		Endpoint:   endpoint,
+		Query:      c.Request.URL.RawQuery,
+		Header:     header,
+		Body:       bytes.Clone(req.Body),
+		WebhookURL: webhook,
+		RemoteAddr: c.Request.RemoteAddr,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := saveJob(settings.dir, rec); err != nil {
+		log.Warnf("jobs: store %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"message": "failed to store job", "type": "server_error"}})
+		return
+	}
+	view := rec.view()
+	go m.run(settings.dir, rec)
+	c.JSON(http.StatusAccepted, view)
+}
+
+// Get handles GET /v1/jobs/:id. Only the API key that submitted a job can
+// see it.
+func (m *JobManager) Get(c *gin.Context) {
+	rec, ok := m.lookup(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, rec.view())
+}
+
+// Result handles GET /v1/jobs/:id/result: the response of a completed job,
+// or the upstream error of a failed one.
+func (m *JobManager) Result(c *gin.Context) {
+	rec, ok := m.lookup(c)
+	if !ok {
+		return
+	}
+	switch rec.Status {
+	case JobStatusCompleted:
+		settings, _ := artifactConfig(m.h.Cfg)
+		name := filepath.Join(settings.dir, rec.Name)
+		data, err := os.ReadFile(name + ".json")
+		var meta artifactMeta
+		if err != nil || json.Unmarshal(data, &meta) != nil {
+			c.JSON(http.StatusGone, gin.H{"error": gin.H{"message": "job result expired", "type": "invalid_request_error"}})
+			return
+		}
+		contentType := meta.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.Header("Content-Type", contentType)
+		c.File(name + ".body")
+	case JobStatusFailed:
+		status := rec.StatusCode
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, gin.H{"error": gin.H{"message": rec.Error, "type": "job_failed"}})
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": gin.H{"message": "job is " + rec.Status, "type": "invalid_request_error"}})
+	}
+}
+
+func (m *JobManager) lookup(c *gin.Context) (*jobRecord, bool) {
+	settings, ok := artifactConfig(m.h.Cfg)
+	if !ok {
+		jobsUnavailable(c)
+		return nil, false
+	}
+	id := logging.ClientRequestID(c.Param("id"))
+	if id == "" {
+		jobNotFound(c)
+		return nil, false
+	}
+	rec, err := loadJob(filepath.Join(settings.dir, artifactName(c.GetString("apiKey"), id)+jobFileSuffix))
+	if err != nil || rec.ID != id {
+		jobNotFound(c)
+		return nil, false
+	}
+	return rec, true
+}
+
+// Resume restarts the jobs that were queued or running when the proxy last
+// stopped. A job interrupted mid-generation runs again from the start.
+func (m *JobManager) Resume() {
+	if m == nil {
+		return
+	}
+	settings, ok := artifactConfig(m.h.Cfg)
+	if !ok {
+		return
+	}
+	entries, err := os.ReadDir(settings.dir)
+	if err != nil {
+		return
+	}
+	resumed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), jobFileSuffix) {
+			continue
+		}
+		rec, errLoad := loadJob(filepath.Join(settings.dir, entry.Name()))
+		if errLoad != nil || rec.finished() || time.Since(rec.CreatedAt) > settings.maxAge {
+			continue
+		}
+		rec.Status, rec.StartedAt = JobStatusQueued, nil
+		go m.run(settings.dir, rec)
+		resumed++
+	}
+	if resumed > 0 {
+		log.Infof("jobs: resumed %d unfinished job(s)", resumed)
+	}
+}
+
+func (m *JobManager) acquire() (release func()) {
+	limit := config.DefaultJobsMaxConcurrent
+	if m.h.Cfg != nil && m.h.Cfg.Jobs.MaxConcurrent > 0 {
+		limit = m.h.Cfg.Jobs.MaxConcurrent
+	}
+	m.slotsMu.Lock()
+	if m.slots == nil || cap(m.slots) != limit {
+		m.slots = make(chan struct{}, limit)
+	}
+	slots := m.slots
+	m.slotsMu.Unlock()
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+func (m *JobManager) run(dir string, rec *jobRecord) {
+	release := m.acquire()
+	defer release()
+	started := time.Now().UTC()
+	rec.Status, rec.StartedAt = JobStatusRunning, &started
+	if err := saveJob(dir, rec); err != nil {
+		log.Warnf("jobs: store %s: %v", rec.ID, err)
+	}
+
+	m.execute(dir, rec)
+	completed := time.Now().UTC()
+	rec.CompletedAt = &completed
+	rec.Header, rec.Body, rec.Query, rec.RemoteAddr = nil, nil, "", ""
+	if err := saveJob(dir, rec); err != nil {
+		log.Warnf("jobs: store %s: %v", rec.ID, err)
+	}
+	log.Debugf("jobs: %s %s with status %d", rec.ID, rec.Status, rec.StatusCode)
+	m.notify(rec)
+}
+
+// execute replays the job request through the router and records the
+// outcome on rec.
+func (m *JobManager) execute(dir string, rec *jobRecord) {
+	fail := func(status int, message string) {
+		rec.Status, rec.StatusCode, rec.Error = JobStatusFailed, status, message
+	}
+	target := rec.Endpoint
+	if rec.Query != "" {
+		target += "?" + rec.Query
+	}
+	ctx := context.WithValue(context.Background(), jobContextKey{}, rec.ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(rec.Body))
+	if err != nil {
+		fail(http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Header = rec.Header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set(logging.RequestIDHeader, rec.ID)
+	req.RemoteAddr = rec.RemoteAddr
+
+	writer := &jobWriter{header: http.Header{}, dir: dir}
+	m.dispatch.ServeHTTP(writer, req)
+	defer writer.discard()
+	status := writer.statusCode()
+	rec.StatusCode = status
+	if status < 200 || status >= 300 {
+		fail(status, writer.errorMessage())
+		return
+	}
+	if writer.err != nil {
+		fail(http.StatusInternalServerError, "failed to store job result: "+writer.err.Error())
+		return
+	}
+	if writer.file == nil {
+		if writer.file, err = os.CreateTemp(dir, ".artifact-*.tmp"); err != nil {
+			fail(http.StatusInternalServerError, "failed to store job result: "+err.Error())
+			return
+		}
+	}
+	meta := artifactMeta{
+		RequestID:   rec.ID,
+		Path:        rec.Endpoint,
+		Status:      status,
+		ContentType: writer.header.Get("Content-Type"),
+		Size:        writer.size,
+		CreatedAt:   time.Now().UTC(),
+	}
+	file := writer.file
+	writer.file = nil
+	if err = storeArtifact(dir, file, rec.Name, meta); err != nil {
+		fail(http.StatusInternalServerError, "failed to store job result: "+err.Error())
+		return
+	}
+	rec.Status = JobStatusCompleted
+}
+
+type jobWebhookPayload struct {
+	Event string  `json:"event"`
+	Job   jobView `json:"job"`
+}
+
+// notify posts the finished job to its webhook in the background.
+func (m *JobManager) notify(rec *jobRecord) {
+	if rec.WebhookURL == "" {
+		return
+	}
+	body, errMarshal := json.Marshal(jobWebhookPayload{Event: "job." + rec.Status, Job: rec.view()})
+	if errMarshal != nil {
+		return
+	}
+	webhook := rec.WebhookURL
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), jobWebhookTimeout)
+		defer cancel()
+		if errPost := m.post(ctx, webhook, body); errPost != nil {
+			log.Warnf("jobs: webhook delivery for %s failed: %v", rec.ID, errPost)
+		}
+	}()
+}
+
+// post delivers body to url. With jobs.webhook-hosts set, only the listed
+// hosts are posted to, whatever they resolve to; otherwise only public
+// addresses are dialed.
+func (m *JobManager) post(ctx context.Context, url string, body []byte) error {
+	req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if errReq != nil {
+		return errReq
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := m.client
+	if hosts := m.webhookHosts(); len(hosts) > 0 {
+		if !webhookHostAllowed(hosts, req.URL.Hostname()) {
+			return fmt.Errorf("host %s is not in jobs.webhook-hosts", req.URL.Hostname())
+		}
+		client = m.allowlisted
+	}
+	resp, errDo := client.Do(req)
+	if errDo != nil {
+		return errDo
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func saveJob(dir string, rec *jobRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".job-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, rec.Name+jobFileSuffix))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func loadJob(path string) (*jobRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec jobRecord
+	if err = json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	if rec.ID == "" || rec.Name == "" {
+		return nil, errors.New("incomplete job record")
+	}
+	return &rec, nil
+}
+
+// jobWriter receives a job response: successful bodies are spooled to a
+// temporary file in the artifact directory, error bodies kept in memory.
+type jobWriter struct {
+	header http.Header
+	status int
+	dir    string
+	file   *os.File
+	size   int64
+	err    error
+	errBuf bytes.Buffer
+}
+
+func (w *jobWriter) Header() http.Header { return w.header }
+
+func (w *jobWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *jobWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *jobWriter) Write(p []byte) (int, error) {
+	status := w.statusCode()
+	w.WriteHeader(status)
+	if status < 200 || status >= 300 {
+		if remaining := maxJobError - w.errBuf.Len(); remaining > 0 {
+			w.errBuf.Write(p[:min(len(p), remaining)])
+		}
+		return len(p), nil
+	}
+	if w.err != nil {
+		return len(p), nil
+	}
+	if w.file == nil {
+		w.file, w.err = os.CreateTemp(w.dir, ".artifact-*.tmp")
+	}
+	if w.err == nil {
+		_, w.err = w.file.Write(p)
+		w.size += int64(len(p))
+	}
+	return len(p), nil
+}
+
+// Flush satisfies the http.Flusher streaming handlers require.
+func (w *jobWriter) Flush() {}
+
+func (w *jobWriter) discard() {
+	if w.file != nil {
+		_ = w.file.Close()
+		_ = os.Remove(w.file.Name())
+		w.file = nil
+	}
+}
+
+func (w *jobWriter) errorMessage() string {
+	body := bytes.TrimSpace(w.errBuf.Bytes())
+	for _, path := range []string{"error.message", "error", "message"} {
+		if value := gjson.GetBytes(body, path); value.Type == gjson.String && value.String() != "" {
+			return value.String()
+		}
+	}
+	if len(body) == 0 {
+		return http.StatusText(w.statusCode())
+	}
+	return string(body)
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func newJobTestRouter(t *testing.T, dir string) (*gin.Engine, *JobManager) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{Artifacts: sdkconfig.ArtifactsConfig{Dir: dir}}, nil)
+	router := gin.New()
+	jobs := NewJobManager(h, router)
+	router.Use(func(c *gin.Context) {
+		c.Set("apiKey", c.GetHeader("X-Test-Key"))
+		logging.SetGinRequestID(c, c.GetHeader(logging.RequestIDHeader))
+		c.Next()
+	})
+	router.Use(ArtifactMiddleware(h))
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		if gjson.GetBytes(body, "model").String() == "missing" {
+			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"message": "unknown model"}})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"request_id": logging.GetGinRequestID(c), "content": gjson.GetBytes(body, "messages.0.content").String()})
+	})
+	router.POST("/v1/remote-addr", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"remote_addr": c.Request.RemoteAddr})
+	})
+	router.POST("/v1/jobs", jobs.Submit)
+	router.GET("/v1/jobs/:id", jobs.Get)
+	router.GET("/v1/jobs/:id/result", jobs.Result)
+	router.GET("/v1/artifacts/:id", h.GetArtifact)
+	return router, jobs
+}
+
+func jobRequest(router *gin.Engine, method, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-Test-Key", key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func waitForJob(t *testing.T, router *gin.Engine, key, id string) gjson.Result {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := jobRequest(router, http.MethodGet, "/v1/jobs/"+id, key, "")
+		job := gjson.Parse(rec.Body.String())
+		if status := job.Get("status").String(); status == JobStatusCompleted || status == JobStatusFailed {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s did not finish: %s", id, rec.Body.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobs_RunInBackgroundAndKeepResult(t *testing.T) {
+	router, _ := newJobTestRouter(t, t.TempDir())
+
+	rec := jobRequest(router, http.MethodPost, "/v1/jobs", "k1", `{"endpoint":"/v1/chat/completions","body":{"model":"m","messages":[{"role":"user","content":"hello"}]}}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("submit: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	id := gjson.Get(rec.Body.String(), "id").String()
+	if id == "" || gjson.Get(rec.Body.String(), "status").String() != JobStatusQueued {
+		t.Fatalf("submit body = %s", rec.Body.String())
+	}
+
+	job := waitForJob(t, router, "k1", id)
+	if job.Get("status").String() != JobStatusCompleted || job.Get("result_url").String() != "/v1/jobs/"+id+"/result" {
+		t.Fatalf("job = %s", job.Raw)
+	}
+	result := jobRequest(router, http.MethodGet, "/v1/jobs/"+id+"/result", "k1", "")
+	if result.Code != http.StatusOK || gjson.Get(result.Body.String(), "content").String() != "hello" || gjson.Get(result.Body.String(), "request_id").String() != id {
+		t.Fatalf("result: status %d, body %s", result.Code, result.Body.String())
+	}
+	if artifact := jobRequest(router, http.MethodGet, "/v1/artifacts/"+id, "k1", ""); artifact.Body.String() != result.Body.String() {
+		t.Fatalf("artifact body = %s", artifact.Body.String())
+	}
+	if other := jobRequest(router, http.MethodGet, "/v1/jobs/"+id, "k2", ""); other.Code != http.StatusNotFound {
+		t.Fatalf("another key must not see the job, got %d", other.Code)
+	}
+}
+
+func TestJobs_RecordUpstreamFailures(t *testing.T) {
+	router, _ := newJobTestRouter(t, t.TempDir())
+
+	rec := jobRequest(router, http.MethodPost, "/v1/jobs", "k1", `{"endpoint":"/v1/chat/completions","body":{"model":"missing"}}`)
+	id := gjson.Get(rec.Body.String(), "id").String()
+	job := waitForJob(t, router, "k1", id)
+	if job.Get("status").String() != JobStatusFailed || job.Get("status_code").Int() != http.StatusNotFound || job.Get("error.message").String() != "unknown model" {
+		t.Fatalf("job = %s", job.Raw)
+	}
+	if result := jobRequest(router, http.MethodGet, "/v1/jobs/"+id+"/result", "k1", ""); result.Code != http.StatusNotFound {
+		t.Fatalf("result status = %d", result.Code)
+	}
+
+	for _, body := range []string{
+		`{"endpoint":"/v1/jobs","body":{}}`,
+		`{"endpoint":"/v1/chat/completions","body":"text"}`,
+		`{"endpoint":"/v1/chat/completions","body":{},"webhook_url":"file:///etc/passwd"}`,
+		`{"endpoint":"/v1/chat/completions","body":{},"webhook_url":"http://127.0.0.1:8080/hook"}`,
+		`{"endpoint":"/v1/chat/completions","body":{},"webhook_url":"http://169.254.169.254/latest/meta-data"}`,
+		`{"endpoint":"/v1/chat/completions","body":{},"webhook_url":"http://[::ffff:10.0.0.1]/hook"}`,
+	} {
+		if rec = jobRequest(router, http.MethodPost, "/v1/jobs", "k1", body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d", body, rec.Code)
+		}
+	}
+}
+
+func TestJobs_WebhooksReachOnlyPublicOrAllowedHosts(t *testing.T) {
+	router, jobs := newJobTestRouter(t, t.TempDir())
+	delivered := make(chan string, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		delivered <- gjson.GetBytes(body, "event").String()
+	}))
+	defer hook.Close()
+
+	if err := jobs.post(context.Background(), hook.URL, []byte(`{}`)); err == nil || strings.Contains(err.Error(), "status") {
+		t.Fatalf("post to loopback without allowlist: %v", err)
+	}
+
+	jobs.h.Cfg.Jobs.WebhookHosts = []string{"127.0.0.1"}
+	if rec := jobRequest(router, http.MethodPost, "/v1/jobs", "k1", `{"endpoint":"/v1/chat/completions","body":{},"webhook_url":"https://hooks.example.com/"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unlisted webhook host: status %d", rec.Code)
+	}
+	rec := jobRequest(router, http.MethodPost, "/v1/jobs", "k1", `{"endpoint":"/v1/chat/completions","body":{},"webhook_url":"`+hook.URL+`/hook"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("allowlisted webhook: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	select {
+	case event := <-delivered:
+		if event != "job."+JobStatusCompleted {
+			t.Fatalf("webhook event = %q", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered to the allowlisted host")
+	}
+}
+
+func TestJobs_ReplayWithSubmitterAddress(t *testing.T) {
+	router, _ := newJobTestRouter(t, t.TempDir())
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/jobs", strings.NewReader(`{"endpoint":"/v1/remote-addr","body":{}}`))
+	req.Header.Set("X-Test-Key", "k1")
+	req.RemoteAddr = "203.0.113.7:4321"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	id := gjson.Get(rec.Body.String(), "id").String()
+	if job := waitForJob(t, router, "k1", id); job.Get("status").String() != JobStatusCompleted {
+		t.Fatalf("job = %s", job.Raw)
+	}
+	result := jobRequest(router, http.MethodGet, "/v1/jobs/"+id+"/result", "k1", "")
+	if got := gjson.Get(result.Body.String(), "remote_addr").String(); got != req.RemoteAddr {
+		t.Fatalf("replayed remote addr = %q, want %q", got, req.RemoteAddr)
+	}
+}
+
+func TestJobs_ResumeUnfinishedJobs(t *testing.T) {
+	dir := t.TempDir()
+	router, jobs := newJobTestRouter(t, dir)
+	header := http.Header{}
+	header.Set("X-Test-Key", "k1")
+	queued := &jobRecord{
+		ID:        "job_resumed",
+		Name:      artifactName("k1", "job_resumed"),
+		Status:    JobStatusRunning,
+		Endpoint:  "/v1/chat/completions",
+		Header:    header,
+		Body:      json.RawMessage(`{"messages":[{"content":"again"}]}`),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := saveJob(dir, queued); err != nil {
+		t.Fatalf("saveJob: %v", err)
+	}
+
+	jobs.Resume()
+	job := waitForJob(t, router, "k1", "job_resumed")
+	if job.Get("status").String() != JobStatusCompleted {
+		t.Fatalf("job = %s", job.Raw)
+	}
+	stored, err := loadJob(filepath.Join(dir, queued.Name+jobFileSuffix))
+	if err != nil || stored.Header != nil || stored.Body != nil {
+		t.Fatalf("finished job should drop its request: %+v, %v", stored, err)
+	}
+}
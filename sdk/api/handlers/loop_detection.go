@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	loopStateKeyPrefix = "loop:"
+	loopStateTimeout   = 2 * time.Second
+
+	// loopDetectedCode is the error code of requests rejected as a loop.
+	loopDetectedCode = "agent_loop_detected"
+)
+
+// Kinds of repetition the loop detector counts.
+const (
+	loopKindToolCall = "tool_call"
+	loopKindPrompt   = "prompt"
+)
+
+// agentTurn is one message of a conversation, reduced to what loop detection
+// compares.
+type agentTurn struct {
+	user      bool
+	text      string
+	toolCalls []string
+}
+
+// loopCount is the repetition count of one conversation, kept in shared
+// state.
+type loopCount struct {
+	Fingerprint string `json:"fp"`
+	Count       int    `json:"count"`
+	Since       int64  `json:"since"`
+}
+
+func loopDetectionFor(cfg *config.SDKConfig, provider, apiKey string) *config.LoopDetection {
+	if cfg == nil || apiKey == "" {
+		return nil
+	}
+	for i := range cfg.LoopDetection {
+		entry := &cfg.LoopDetection[i]
+		if entry.APIKey != apiKey {
+			continue
+		}
+		if entry.Provider != "" && !strings.EqualFold(entry.Provider, provider) {
+			continue
+		}
+		return entry
+	}
+	return nil
+}
+
+// canonicalToolCall renders a tool call so that calls with the same name and
+// arguments compare equal however their JSON is formatted.
+func canonicalToolCall(name string, args gjson.Result) string {
+	raw := args.Raw
+	if args.Type == gjson.String {
+		raw = args.Str
+	}
+	var decoded any
+	if err := json.Unmarshal([]byte(raw), &decoded); err == nil {
+		if normalized, errMarshal := json.Marshal(decoded); errMarshal == nil {
+			raw = string(normalized)
+		}
+	}
+	return name + "(" + strings.TrimSpace(raw) + ")"
+}
+
+// turnText joins the text of a string content or of the text parts of an
+// array content.
+func turnText(content gjson.Result) string {
+	if content.Type == gjson.String {
+		return content.Str
+	}
+	var parts []string
+	for _, part := range content.Array() {
+		switch part.Get("type").String() {
+		case "", "text", "input_text":
+			if text := part.Get("text"); text.Type == gjson.String {
+				parts = append(parts, text.Str)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// agentTurns extracts the conversation of a request in the format of
+// handlerType.
+func agentTurns(handlerType string, rawJSON []byte) []agentTurn {
+	root := gjson.ParseBytes(rawJSON)
+	var turns []agentTurn
+	switch handlerType {
+	case "openai":
+		for _, msg := range root.Get("messages").Array() {
+			switch msg.Get("role").String() {
+			case "user":
+				turns = append(turns, agentTurn{user: true, text: turnText(msg.Get("content"))})
+			case "assistant":
+				turn := agentTurn{text: turnText(msg.Get("content"))}
+				for _, call := range msg.Get("tool_calls").Array() {
+					turn.toolCalls = append(turn.toolCalls, canonicalToolCall(call.Get("function.name").String(), call.Get("function.arguments")))
+				}
+				turns = append(turns, turn)
+			}
+		}
+	case "openai-response":
+		input := root.Get("input")
+		if input.Type == gjson.String {
+			return []agentTurn{{user: true, text: input.Str}}
+		}
+		for _, item := range input.Array() {
+			switch {
+			case item.Get("type").String() == "function_call":
+				call := canonicalToolCall(item.Get("name").String(), item.Get("arguments"))
+				if n := len(turns); n > 0 && !turns[n-1].user && turns[n-1].text == "" {
+					turns[n-1].toolCalls = append(turns[n-1].toolCalls, call)
+				} else {
+					turns = append(turns, agentTurn{toolCalls: []string{call}})
+				}
+			case item.Get("role").String() == "user":
+				turns = append(turns, agentTurn{user: true, text: turnText(item.Get("content"))})
+			case item.Get("role").String() == "assistant":
+				turns = append(turns, agentTurn{text: turnText(item.Get("content"))})
+			}
+		}
+	case "claude":
+		for _, msg := range root.Get("messages").Array() {
+			turn := agentTurn{user: msg.Get("role").String() == "user", text: turnText(msg.Get("content"))}
+			for _, part := range msg.Get("content").Array() {
+				if part.Get("type").String() == "tool_use" {
+					turn.toolCalls = append(turn.toolCalls, canonicalToolCall(part.Get("name").String(), part.Get("input")))
+				}
+			}
+			turns = append(turns, turn)
+		}
+	case "gemini", "gemini-cli":
+		contents := root.Get("contents")
+		if handlerType == "gemini-cli" {
+			contents = root.Get("request.contents")
+		}
+		for _, content := range contents.Array() {
+			turn := agentTurn{user: content.Get("role").String() != "model"}
+			var text []string
+			for _, part := range content.Get("parts").Array() {
+				if call := part.Get("functionCall"); call.Exists() {
+					turn.toolCalls = append(turn.toolCalls, canonicalToolCall(call.Get("name").String(), call.Get("args")))
+				} else if t := part.Get("text"); t.Type == gjson.String {
+					text = append(text, t.Str)
+				}
+			}
+			turn.text = strings.Join(text, "\n")
+			turns = append(turns, turn)
+		}
+	}
+	return turns
+}
+
+// normalizePrompt folds the differences between near-identical prompts:
+// case, whitespace and numbers such as timestamps or counters.
+func normalizePrompt(text string) string {
+	var out strings.Builder
+	space, digits := false, false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsSpace(r):
+			space, digits = true, false
+			continue
+		case unicode.IsDigit(r):
+			if digits {
+				continue
+			}
+			r, digits = '#', true
+		default:
+			digits = false
+		}
+		if space && out.Len() > 0 {
+			out.WriteByte(' ')
+		}
+		space = false
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+func loopFingerprint(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// loopSignals returns the fingerprints of the conversation's latest tool call
+// and latest prompt, keyed by kind. The tool call is the one the assistant's
+// last turn made, if it made one; the prompt counts only when the request
+// ends with it, so tool results answering an agent's calls do not make the
+// task they work on look repeated.
+func loopSignals(turns []agentTurn) map[string]string {
+	signals := make(map[string]string, 2)
+	for i := len(turns) - 1; i >= 0; i-- {
+		if !turns[i].user {
+			if len(turns[i].toolCalls) > 0 {
+				signals[loopKindToolCall] = loopFingerprint(turns[i].toolCalls...)
+			}
+			break
+		}
+	}
+	if n := len(turns); n > 0 && turns[n-1].user && strings.TrimSpace(turns[n-1].text) != "" {
+		signals[loopKindPrompt] = loopFingerprint(normalizePrompt(turns[n-1].text))
+	}
+	return signals
+}
+
+// loopConversation identifies the conversation of a request: the client's
+// session header when it sends one, otherwise its opening user message.
+func loopConversation(c *gin.Context, turns []agentTurn) string {
+	if c.Request != nil {
+		if session := strings.TrimSpace(c.GetHeader(StickySessionHeader)); session != "" {
+			return "session\x00" + session
+		}
+	}
+	for _, turn := range turns {
+		if turn.user && turn.text != "" {
+			return "opening\x00" + turn.text
+		}
+	}
+	return ""
+}
+
+// countLoopRepeat records fingerprint as the latest of its kind in the
+// conversation and returns how many times in a row it has now been seen
+// within the window.
+func countLoopRepeat(key, fingerprint string, window time.Duration, now time.Time) int {
+	store := sharedstate.Current()
+	ctx, cancel := context.WithTimeout(context.Background(), loopStateTimeout)
+	defer cancel()
+	var state loopCount
+	if raw, found, err := store.Get(ctx, key); err != nil {
+		log.Debugf("loop detection: lookup failed: %v", err)
+	} else if found {
+		_ = json.Unmarshal(raw, &state)
+	}
+	if state.Fingerprint == fingerprint && now.Sub(time.Unix(state.Since, 0)) <= window {
+		state.Count++
+	} else {
+		state = loopCount{Fingerprint: fingerprint, Count: 1, Since: now.Unix()}
+	}
+	if raw, err := json.Marshal(state); err == nil {
+		if errSet := store.Set(ctx, key, raw, window); errSet != nil {
+			log.Debugf("loop detection: store failed: %v", errSet)
+		}
+	}
+	return state.Count
+}
+
+func loopDetectedError(kind string, repeats int, window time.Duration) *interfaces.ErrorMessage {
+	what := "tool call"
+	if kind == loopKindPrompt {
+		what = "prompt"
+	}
+	body := []byte(`{"error":{"type":"invalid_request_error"}}`)
+	body, _ = sjson.SetBytes(body, "error.message", fmt.Sprintf("agent loop detected: the same %s was repeated %d times within %s; change approach before retrying", what, repeats, window))
+	body, _ = sjson.SetBytes(body, "error.code", loopDetectedCode)
+	body, _ = sjson.SetBytes(body, "error.loop.kind", kind)
+	body, _ = sjson.SetBytes(body, "error.loop.repeats", repeats)
+	body, _ = sjson.SetBytes(body, "error.loop.window_seconds", int(window.Seconds()))
+	return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New(string(body))}
+}
+
+func loopSystemMessage(entry *config.LoopDetection, kind string, repeats int) string {
+	if entry.Message != "" {
+		return entry.Message
+	}
+	what := "tool call with the same arguments"
+	if kind == loopKindPrompt {
+		what = "request"
+	}
+	return fmt.Sprintf("You have been sent the same %s %d times in a row without making progress. Do not repeat it again. Try a different approach, or stop and explain to the user what is blocking you.", what, repeats)
+}
+
+// detectAgentLoop counts how often the conversation of a request has
+// repeated its latest tool call or prompt, and once either reaches the API
+// key's loop-detection threshold, rejects the request or injects a system
+// message telling the model to change course, as the entry's action says.
+func (h *BaseAPIHandler) detectAgentLoop(ctx context.Context, handlerType string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	if h == nil || h.Cfg == nil || len(h.Cfg.LoopDetection) == 0 || ctx == nil {
+		return rawJSON, nil
+	}
+	c, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || c == nil {
+		return rawJSON, nil
+	}
+	apiKey := c.GetString("apiKey")
+	entry := loopDetectionFor(h.Cfg, c.GetString("accessProvider"), apiKey)
+	if entry == nil {
+		return rawJSON, nil
+	}
+	turns := agentTurns(handlerType, rawJSON)
+	conversation := loopConversation(c, turns)
+	if conversation == "" {
+		return rawJSON, nil
+	}
+	conversationKey := loopFingerprint(apiKey, conversation)
+	window := time.Duration(entry.WindowSeconds) * time.Second
+	now := time.Now()
+
+	signals := loopSignals(turns)
+	for _, kind := range []string{loopKindToolCall, loopKindPrompt} {
+		fingerprint, ok := signals[kind]
+		if !ok {
+			continue
+		}
+		repeats := countLoopRepeat(loopStateKeyPrefix+kind+":"+conversationKey, fingerprint, window, now)
+		if repeats < entry.Threshold {
+			continue
+		}
+		log.Warnf("loop detection: %s repeated %d times in a conversation of an API key, action %s", kind, repeats, entry.Action)
+		if entry.Action != config.LoopActionInject {
+			return nil, loopDetectedError(kind, repeats, window)
+		}
+		out, err := insertPromptTemplate(handlerType, rawJSON, loopSystemMessage(entry, kind, repeats), nil)
+		if err != nil {
+			return nil, loopDetectedError(kind, repeats, window)
+		}
+		return out, nil
+	}
+	return rawJSON, nil
+}
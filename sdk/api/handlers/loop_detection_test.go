@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func loopContext(apiKey, session string) context.Context {
+	gin.SetMode(gin.TestMode)
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if session != "" {
+		ginCtx.Request.Header.Set(StickySessionHeader, session)
+	}
+	ginCtx.Set("apiKey", apiKey)
+	return context.WithValue(context.Background(), "gin", ginCtx)
+}
+
+func loopHandler(action string) *BaseAPIHandler {
+	cfg := &sdkconfig.Config{SDKConfig: sdkconfig.SDKConfig{LoopDetection: []sdkconfig.LoopDetection{{APIKey: "agent", Threshold: 3, Action: action}}}}
+	cfg.SanitizeLoopDetection()
+	return NewBaseAPIHandlers(&cfg.SDKConfig, nil)
+}
+
+// toolLoopRequest is an agent turn whose latest assistant message calls the
+// same tool, with its arguments formatted differently each time.
+func toolLoopRequest(step int) []byte {
+	args := `{"path": "main.go"}`
+	if step%2 == 1 {
+		args = `{"path":"main.go"}`
+	}
+	return []byte(`{"messages":[{"role":"user","content":"fix the build"},` +
+		`{"role":"assistant","tool_calls":[{"id":"call_` + strconv.Itoa(step) + `","type":"function","function":{"name":"read_file","arguments":` + strconv.Quote(args) + `}}]},` +
+		`{"role":"tool","tool_call_id":"call_` + strconv.Itoa(step) + `","content":"package main"}]}`)
+}
+
+func TestDetectAgentLoop_RejectsRepeatedToolCalls(t *testing.T) {
+	h := loopHandler("")
+	ctx := loopContext("agent", "loop-tool-session")
+	for step := 1; step < 3; step++ {
+		if _, errMsg := h.detectAgentLoop(ctx, "openai", toolLoopRequest(step)); errMsg != nil {
+			t.Fatalf("step %d rejected early: %v", step, errMsg.Error)
+		}
+	}
+	_, errMsg := h.detectAgentLoop(ctx, "openai", toolLoopRequest(3))
+	if errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("third repeat = %+v, want a 400", errMsg)
+	}
+	body := BuildErrorResponseBody(errMsg.StatusCode, errMsg.Error.Error())
+	if gjson.GetBytes(body, "error.code").String() != loopDetectedCode || gjson.GetBytes(body, "error.loop.kind").String() != loopKindToolCall ||
+		gjson.GetBytes(body, "error.loop.repeats").Int() != 3 {
+		t.Fatalf("error body = %s", body)
+	}
+
+	if _, errMsg = h.detectAgentLoop(loopContext("other-key", "loop-tool-session"), "openai", toolLoopRequest(4)); errMsg != nil {
+		t.Fatalf("key without loop-detection rejected: %v", errMsg.Error)
+	}
+}
+
+func TestDetectAgentLoop_NewToolCallResetsCount(t *testing.T) {
+	h := loopHandler("")
+	ctx := loopContext("agent", "loop-reset-session")
+	for step := 1; step < 3; step++ {
+		_, _ = h.detectAgentLoop(ctx, "openai", toolLoopRequest(step))
+	}
+	different := []byte(`{"messages":[{"role":"user","content":"fix the build"},{"role":"assistant","tool_calls":[{"function":{"name":"run_tests","arguments":"{}"}}]}]}`)
+	if _, errMsg := h.detectAgentLoop(ctx, "openai", different); errMsg != nil {
+		t.Fatalf("different tool call rejected: %v", errMsg.Error)
+	}
+	if _, errMsg := h.detectAgentLoop(ctx, "openai", toolLoopRequest(3)); errMsg != nil {
+		t.Fatalf("count did not restart: %v", errMsg.Error)
+	}
+}
+
+func TestDetectAgentLoop_InjectsOnNearIdenticalPrompts(t *testing.T) {
+	h := loopHandler("inject")
+	ctx := loopContext("agent", "")
+	prompts := []string{"Continue with step 1", "continue  with step 2", "CONTINUE with step 3"}
+	var out []byte
+	for i, prompt := range prompts {
+		body := []byte(`{"system":"be brief","messages":[{"role":"user","content":"start the migration"},{"role":"assistant","content":"ok"},{"role":"user","content":` + strconv.Quote(prompt) + `}]}`)
+		next, errMsg := h.detectAgentLoop(ctx, "claude", body)
+		out = next
+		if errMsg != nil {
+			t.Fatalf("prompt %d rejected in inject mode: %v", i, errMsg.Error)
+		}
+		if i < 2 && string(out) != string(body) {
+			t.Fatalf("prompt %d modified before the threshold: %s", i, out)
+		}
+	}
+	if system := gjson.GetBytes(out, "system").String(); system == "be brief" || gjson.GetBytes(out, "messages.#").Int() != 3 {
+		t.Fatalf("loop message not injected: %s", out)
+	}
+}
+
+func TestAgentTurns_ToolCallsAcrossFormats(t *testing.T) {
+	cases := map[string]string{
+		"claude":          `{"messages":[{"role":"user","content":"go"},{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"ls","input":{"dir":"."}}]},{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"a"}]}]}`,
+		"openai-response": `{"input":[{"role":"user","content":"go"},{"type":"function_call","name":"ls","arguments":"{\"dir\":\".\"}"},{"type":"function_call_output","output":"a"}]}`,
+		"gemini":          `{"contents":[{"role":"user","parts":[{"text":"go"}]},{"role":"model","parts":[{"functionCall":{"name":"ls","args":{"dir":"."}}}]},{"role":"user","parts":[{"functionResponse":{"name":"ls"}}]}]}`,
+	}
+	want := loopFingerprint(`ls({"dir":"."})`)
+	for handlerType, body := range cases {
+		signals := loopSignals(agentTurns(handlerType, []byte(body)))
+		if signals[loopKindToolCall] != want {
+			t.Errorf("%s: tool call fingerprint %q, want %q", handlerType, signals[loopKindToolCall], want)
+		}
+		if _, ok := signals[loopKindPrompt]; ok {
+			t.Errorf("%s: a tool result should not count as a repeated prompt", handlerType)
+		}
+	}
+}
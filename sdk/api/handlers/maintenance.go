@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// MaintenanceMiddleware answers every request with 503 while maintenance
+// mode is enabled, using the configured message and Retry-After.
+func MaintenanceMiddleware(h *BaseAPIHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h == nil || h.Cfg == nil || !h.Cfg.Maintenance.Enable {
+			c.Next()
+			return
+		}
+		maintenance := h.Cfg.Maintenance
+		message := strings.TrimSpace(maintenance.Message)
+		if message == "" {
+			message = config.DefaultMaintenanceMessage
+		}
+		if maintenance.RetryAfterSeconds > 0 {
+			c.Header("Retry-After", strconv.Itoa(maintenance.RetryAfterSeconds))
+		}
+		c.Data(http.StatusServiceUnavailable, "application/json",
+			BuildErrorResponseBodyForRequest(c, http.StatusServiceUnavailable, message))
+		c.Abort()
+	}
+}
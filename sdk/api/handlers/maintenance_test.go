@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &sdkconfig.SDKConfig{}
+	h := NewBaseAPIHandlers(cfg, nil)
+	router := gin.New()
+	router.Use(MaintenanceMiddleware(h))
+	router.POST("/v1/chat/completions", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}")))
+		return rec
+	}
+	if rec := serve(); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d with maintenance off", rec.Code)
+	}
+
+	cfg.Maintenance = sdkconfig.MaintenanceConfig{Enable: true}
+	rec := serve()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "" {
+		t.Fatalf("status = %d, Retry-After = %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(rec.Body.String(), sdkconfig.DefaultMaintenanceMessage) {
+		t.Fatalf("body = %s", rec.Body.String())
+	}
+
+	cfg.Maintenance = sdkconfig.MaintenanceConfig{Enable: true, Message: "migrating credentials", RetryAfterSeconds: 120}
+	rec = serve()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "120" || !strings.Contains(rec.Body.String(), "migrating credentials") {
+		t.Fatalf("status = %d, Retry-After = %q, body = %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body.String())
+	}
+}
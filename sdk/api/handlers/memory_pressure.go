@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/memguard"
+)
+
+// pressureStreamQueueFrames bounds the write queue of streams opened under
+// memory pressure.
+const pressureStreamQueueFrames = 4
+
+// underMemoryPressure is replaced in tests.
+var underMemoryPressure = memguard.UnderPressure
+
+// MemoryPressureMiddleware rejects new requests with 503 and Retry-After
+// while memory use is past resource-limits.pressure-ratio, so requests
+// already running can finish instead of the process running out of memory.
+func MemoryPressureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || !underMemoryPressure() {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", strconv.Itoa(memguard.RetryAfterSeconds()))
+		c.Data(http.StatusServiceUnavailable, "application/json",
+			BuildErrorResponseBodyForRequest(c, http.StatusServiceUnavailable, "server is under memory pressure, retry later"))
+		c.Abort()
+	}
+}
+
+// streamQueueCapacity returns the write queue size for a new stream, smaller
+// under memory pressure so slow clients hold fewer buffered frames.
+func streamQueueCapacity() int {
+	if underMemoryPressure() {
+		return pressureStreamQueueFrames
+	}
+	return streamQueueFrames
+}
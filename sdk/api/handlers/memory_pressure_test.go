@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func forceMemoryPressure(t *testing.T, under bool) {
+	t.Helper()
+	saved := underMemoryPressure
+	underMemoryPressure = func() bool { return under }
+	t.Cleanup(func() { underMemoryPressure = saved })
+}
+
+func TestMemoryPressureMiddleware_RejectsNewRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(MemoryPressureMiddleware())
+	handled := 0
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		handled++
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/v1/models", func(c *gin.Context) { c.String(http.StatusOK, "models") })
+
+	forceMemoryPressure(t, true)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}")))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" || handled != 0 {
+		t.Fatalf("status = %d, Retry-After = %q, handled = %d", rec.Code, rec.Header().Get("Retry-After"), handled)
+	}
+	if !strings.Contains(rec.Body.String(), "memory pressure") {
+		t.Fatalf("body = %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, want 200", rec.Code)
+	}
+
+	forceMemoryPressure(t, false)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}")))
+	if rec.Code != http.StatusOK || handled != 1 {
+		t.Fatalf("status = %d, handled = %d after pressure ended", rec.Code, handled)
+	}
+}
+
+func TestStreamQueueShrinksUnderMemoryPressure(t *testing.T) {
+	forceMemoryPressure(t, true)
+	q := newStreamQueue("", "/v1/chat/completions", httptest.NewRecorder())
+	defer q.close(false)
+	if got := cap(q.frames); got != pressureStreamQueueFrames {
+		t.Fatalf("queue capacity = %d, want %d", got, pressureStreamQueueFrames)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/middleware"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// runPreTranslation runs the pre-translation middleware stage and attaches the
+// resulting request to ctx for the executor-side stages. The returned request
+// carries the model and payload to execute, which middleware may have rewritten.
+func runPreTranslation(ctx context.Context, handlerType, modelName string, rawJSON []byte, stream, count bool) (context.Context, *middleware.Request, *interfaces.ErrorMessage) {
+	req := &middleware.Request{
+		Format:      sdktranslator.FromString(handlerType),
+		Model:       modelName,
+		Stream:      stream,
+		CountTokens: count,
+		Payload:     rawJSON,
+		Values:      make(map[string]any),
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+		req.Headers = ginCtx.Request.Header
+	}
+	if errRun := middleware.RunPreTranslation(ctx, req); errRun != nil {
+		return ctx, nil, middlewareError(errRun)
+	}
+	return middleware.WithRequest(ctx, req), req, nil
+}
+
+// runPostResponse runs the post-response middleware stage over payload.
+func runPostResponse(ctx context.Context, req *middleware.Request, payload []byte, headers http.Header, chunk bool) ([]byte, *interfaces.ErrorMessage) {
+	resp := &middleware.Response{Request: req, Payload: payload, Chunk: chunk, Headers: headers}
+	if errRun := middleware.RunPostResponse(ctx, resp); errRun != nil {
+		return nil, middlewareError(errRun)
+	}
+	return resp.Payload, nil
+}
+
+func middlewareError(err error) *interfaces.ErrorMessage {
+	status := statusFromError(err)
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	return &interfaces.ErrorMessage{StatusCode: status, Error: err}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// apiKeyModelsFor returns the first api-key-models entry for apiKey as
+// authenticated by the named access provider.
+func apiKeyModelsFor(cfg *config.SDKConfig, provider, apiKey string) *config.APIKeyModels {
+	if cfg == nil || apiKey == "" {
+		return nil
+	}
+	for i := range cfg.APIKeyModels {
+		entry := &cfg.APIKeyModels[i]
+		if entry.APIKey != apiKey {
+			continue
+		}
+		if entry.Provider != "" && !strings.EqualFold(entry.Provider, provider) {
+			continue
+		}
+		return entry
+	}
+	return nil
+}
+
+// APIKeyModelAllowed reports whether the allowlist and denylist of entry let
+// its key use model. A nil entry allows everything.
+func APIKeyModelAllowed(entry *config.APIKeyModels, model string) bool {
+	if entry == nil {
+		return true
+	}
+	model = strings.ToLower(thinking.ParseSuffix(strings.TrimPrefix(model, "models/")).ModelName)
+	for _, pattern := range entry.Deny {
+		if matchModelGlob(strings.ToLower(pattern), model) {
+			return false
+		}
+	}
+	if len(entry.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range entry.Allow {
+		if matchModelGlob(strings.ToLower(pattern), model) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchModelGlob matches value against pattern, where '*' matches any run of
+// characters, including "/".
+func matchModelGlob(pattern, value string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == value
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, segment := range parts[1 : len(parts)-1] {
+		idx := strings.Index(value, segment)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(segment):]
+	}
+	return strings.HasSuffix(value, last)
+}
+
+// modelAllowedForClient applies model-namespaces and api-key-models to the
+// client authenticated on c.
+func (h *BaseAPIHandler) modelAllowedForClient(c *gin.Context, model string) bool {
+	apiKey := c.GetString("apiKey")
+	if !ModelAllowedForAPIKey(h.Cfg, apiKey, model) {
+		return false
+	}
+	return APIKeyModelAllowed(apiKeyModelsFor(h.Cfg, c.GetString("accessProvider"), apiKey), model)
+}
+
+func (h *BaseAPIHandler) restrictsModels() bool {
+	return h != nil && h.Cfg != nil && (len(h.Cfg.ModelNamespaces) > 0 || len(h.Cfg.APIKeyModels) > 0)
+}
+
+// FilterModelsForAPIKey drops the models the requesting API key may not use
+// from a model listing.
+func (h *BaseAPIHandler) FilterModelsForAPIKey(c *gin.Context, models []map[string]any) []map[string]any {
+	if !h.restrictsModels() || c == nil {
+		return models
+	}
+	out := make([]map[string]any, 0, len(models))
+	for _, model := range models {
+		id, _ := model["id"].(string)
+		if id == "" {
+			id, _ = model["name"].(string)
+		}
+		if h.modelAllowedForClient(c, id) {
+			out = append(out, model)
+		}
+	}
+	return out
+}
+
+// checkModelAccess rejects a request for a model the requesting API key may
+// not use.
+func (h *BaseAPIHandler) checkModelAccess(ctx context.Context, model string) *interfaces.ErrorMessage {
+	if !h.restrictsModels() || ctx == nil {
+		return nil
+	}
+	c, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || c == nil || h.modelAllowedForClient(c, model) {
+		return nil
+	}
+	return &interfaces.ErrorMessage{StatusCode: http.StatusForbidden, Error: fmt.Errorf("model %s is not available for this API key", model)}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestAPIKeyModelAllowed(t *testing.T) {
+	entry := &sdkconfig.APIKeyModels{
+		APIKey: "k",
+		Allow:  []string{"gemini-*", "claude-sonnet-*"},
+		Deny:   []string{"*-preview*"},
+	}
+	cases := map[string]bool{
+		"gemini-2.5-pro":          true,
+		"models/Gemini-2.5-Flash": true,
+		"gemini-2.5-pro(high)":    true,
+		"gemini-3-pro-preview":    false,
+		"claude-sonnet-4-5":       true,
+		"claude-opus-4-1":         false,
+		"gpt-5":                   false,
+	}
+	for model, want := range cases {
+		if got := APIKeyModelAllowed(entry, model); got != want {
+			t.Errorf("APIKeyModelAllowed(%q) = %v, want %v", model, got, want)
+		}
+	}
+	if !APIKeyModelAllowed(&sdkconfig.APIKeyModels{Deny: []string{"gpt-*"}}, "gemini-2.5-pro") {
+		t.Error("an empty allowlist should allow models that are not denied")
+	}
+}
+
+func TestAPIKeyModels_ScopedByAccessProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{APIKeyModels: []sdkconfig.APIKeyModels{
+		{APIKey: "shared", Provider: "team-keys", Allow: []string{"gemini-*"}},
+		{APIKey: "shared", Deny: []string{"gemini-*"}},
+	}}, nil)
+	models := []map[string]any{{"id": "gemini-2.5-pro"}, {"id": "gpt-5"}}
+
+	for provider, want := range map[string]string{"team-keys": "gemini-2.5-pro", "config-inline": "gpt-5"} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("apiKey", "shared")
+		c.Set("accessProvider", provider)
+		got := h.FilterModelsForAPIKey(c, models)
+		if len(got) != 1 || got[0]["id"] != want {
+			t.Fatalf("provider %s sees %v, want only %s", provider, got, want)
+		}
+		ctx := context.WithValue(context.Background(), "gin", c)
+		if errMsg := h.checkModelAccess(ctx, want); errMsg != nil {
+			t.Fatalf("provider %s: checkModelAccess(%s) = %v", provider, want, errMsg)
+		}
+	}
+}
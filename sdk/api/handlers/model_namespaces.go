@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// modelNamespace returns the innermost model-namespaces entry whose prefix the
+// model name starts with.
+func modelNamespace(cfg *config.SDKConfig, model string) *config.ModelNamespace {
+	if cfg == nil {
+		return nil
+	}
+	var best *config.ModelNamespace
+	for i := range cfg.ModelNamespaces {
+		ns := &cfg.ModelNamespaces[i]
+		if ns.Prefix == "" || !strings.HasPrefix(model, ns.Prefix+"/") {
+			continue
+		}
+		if best == nil || len(ns.Prefix) > len(best.Prefix) {
+			best = ns
+		}
+	}
+	return best
+}
+
+// ModelAllowedForAPIKey reports whether a client authenticated with apiKey may
+// list and use model. Models outside any namespace, and namespaces without an
+// api-keys list, are open to every key.
+func ModelAllowedForAPIKey(cfg *config.SDKConfig, apiKey, model string) bool {
+	ns := modelNamespace(cfg, strings.TrimPrefix(model, "models/"))
+	return ns == nil || len(ns.APIKeys) == 0 || slices.Contains(ns.APIKeys, apiKey)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestModelNamespaces_RestrictListingAndUseByAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{ModelNamespaces: []sdkconfig.ModelNamespace{
+		{Prefix: "acme", Selector: map[string]string{"org": "acme"}},
+		{Prefix: "acme/research", Selector: map[string]string{"team": "research"}, APIKeys: []string{"research-key"}},
+	}}, nil)
+	models := []map[string]any{
+		{"id": "gemini-2.5-pro"},
+		{"id": "acme/gemini-2.5-pro"},
+		{"id": "acme/research/gemini-2.5-pro"},
+		{"name": "models/acme/research/gemini-2.5-flash"},
+	}
+
+	for key, want := range map[string]int{"research-key": 4, "other-key": 2} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("apiKey", key)
+		if got := h.FilterModelsForAPIKey(c, models); len(got) != want {
+			t.Fatalf("key %s sees %d models, want %d: %v", key, len(got), want, got)
+		}
+
+		ctx := context.WithValue(context.Background(), "gin", c)
+		errMsg := h.checkModelAccess(ctx, "acme/research/gemini-2.5-pro")
+		if allowed := errMsg == nil; allowed != (key == "research-key") {
+			t.Fatalf("key %s: checkModelAccess() = %v", key, errMsg)
+		}
+		if errMsg != nil && errMsg.StatusCode != http.StatusForbidden {
+			t.Fatalf("key %s: status %d, want 403", key, errMsg.StatusCode)
+		}
+	}
+}
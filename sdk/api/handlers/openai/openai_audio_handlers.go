@@ -0,0 +1,110 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/speech"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// AudioSpeech handles the /v1/audio/speech endpoint. The request is routed to
+// the provider serving the model, and the audio is streamed back as it arrives
+// with the Content-Type of the requested response_format.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) AudioSpeech(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	modelName := gjson.GetBytes(rawJSON, "model").String()
+	if strings.TrimSpace(modelName) == "" || strings.TrimSpace(gjson.GetBytes(rawJSON, "input").String()) == "" {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Invalid request: model and input are required",
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	format := speech.Format(rawJSON)
+	contentType := speech.ContentType(format)
+	if contentType == "" {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: unsupported response_format %q", format),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: "Streaming not supported",
+				Type:    "server_error",
+			},
+		})
+		return
+	}
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	dataChan, upstreamHeaders, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "audio/speech")
+
+	// Hold the status until the upstream either fails or produces audio.
+	for {
+		select {
+		case <-handlers.ClientDone(c):
+			cliCancel(c.Request.Context().Err())
+			return
+		case errMsg, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			h.WriteErrorResponse(c, errMsg)
+			if errMsg != nil {
+				cliCancel(errMsg.Error)
+			} else {
+				cliCancel(nil)
+			}
+			return
+		case chunk, ok := <-dataChan:
+			c.Header("Content-Type", contentType)
+			handlers.WriteUpstreamHeaders(c.Writer.Header(), upstreamHeaders)
+			if !ok {
+				c.Status(http.StatusOK)
+				cliCancel(nil)
+				return
+			}
+			_, _ = c.Writer.Write(chunk)
+			flusher.Flush()
+
+			// Binary bodies cannot carry keep-alives or in-band errors; a late
+			// failure simply ends the stream.
+			noKeepAlive := time.Duration(0)
+			h.ForwardStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, handlers.StreamForwardOptions{
+				KeepAliveInterval: &noKeepAlive,
+				WriteChunk: func(chunk []byte) {
+					_, _ = c.Writer.Write(chunk)
+				},
+			})
+			return
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Request fields that select a prompt template and supply its variables.
+// Both are removed before the request is translated.
+const (
+	promptTemplateField          = "template"
+	promptTemplateVariablesField = "template_variables"
+)
+
+var promptTemplateVariable = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// expandPromptTemplateText replaces the {{variables}} of text, failing on the
+// first variable without a value.
+func expandPromptTemplateText(text string, vars map[string]string) (string, error) {
+	var missing string
+	out := promptTemplateVariable.ReplaceAllStringFunc(text, func(match string) string {
+		name := promptTemplateVariable.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("missing variable %q", missing)
+	}
+	return out, nil
+}
+
+func promptTemplateFor(cfg *config.SDKConfig, name string) *config.PromptTemplate {
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.PromptTemplates {
+		if strings.EqualFold(cfg.PromptTemplates[i].Name, name) {
+			return &cfg.PromptTemplates[i]
+		}
+	}
+	return nil
+}
+
+// prependRaw puts items before the elements of the array at path.
+func prependRaw(rawJSON []byte, path string, items []string) ([]byte, error) {
+	if len(items) == 0 {
+		return rawJSON, nil
+	}
+	for _, existing := range gjson.GetBytes(rawJSON, path).Array() {
+		items = append(items, existing.Raw)
+	}
+	return sjson.SetRawBytes(rawJSON, path, []byte("["+strings.Join(items, ",")+"]"))
+}
+
+// prependSystemText adds text before the string or array system prompt at
+// path, creating it as a string when absent.
+func prependSystemText(rawJSON []byte, path, text string, part func(string) string) ([]byte, error) {
+	switch existing := gjson.GetBytes(rawJSON, path); {
+	case existing.IsArray():
+		return prependRaw(rawJSON, path, []string{part(text)})
+	case existing.Type == gjson.String && existing.String() != "":
+		return sjson.SetBytes(rawJSON, path, text+"\n\n"+existing.String())
+	default:
+		return sjson.SetBytes(rawJSON, path, text)
+	}
+}
+
+func jsonText(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// insertPromptTemplate adds an expanded system prompt and messages to a
+// request in the format of handlerType.
+func insertPromptTemplate(handlerType string, rawJSON []byte, system string, messages []config.PromptTemplateMessage) ([]byte, error) {
+	chatItems := func(withSystem bool) []string {
+		items := make([]string, 0, len(messages)+1)
+		if withSystem && system != "" {
+			items = append(items, `{"role":"system","content":`+jsonText(system)+`}`)
+		}
+		for _, msg := range messages {
+			items = append(items, `{"role":`+jsonText(msg.Role)+`,"content":`+jsonText(msg.Content)+`}`)
+		}
+		return items
+	}
+	var err error
+	switch handlerType {
+	case "openai":
+		return prependRaw(rawJSON, "messages", chatItems(true))
+	case "openai-response":
+		if system != "" {
+			if rawJSON, err = prependSystemText(rawJSON, "instructions", system, jsonText); err != nil {
+				return nil, err
+			}
+		}
+		if input := gjson.GetBytes(rawJSON, "input"); input.Type == gjson.String {
+			if rawJSON, err = sjson.SetRawBytes(rawJSON, "input", []byte(`[{"role":"user","content":`+input.Raw+`}]`)); err != nil {
+				return nil, err
+			}
+		}
+		return prependRaw(rawJSON, "input", chatItems(false))
+	case "claude":
+		if system != "" {
+			textPart := func(text string) string { return `{"type":"text","text":` + jsonText(text) + `}` }
+			if rawJSON, err = prependSystemText(rawJSON, "system", system, textPart); err != nil {
+				return nil, err
+			}
+		}
+		return prependRaw(rawJSON, "messages", chatItems(false))
+	case "gemini", "gemini-cli":
+		prefix := ""
+		if handlerType == "gemini-cli" {
+			prefix = "request."
+		}
+		if system != "" {
+			systemPath := prefix + "systemInstruction"
+			if !gjson.GetBytes(rawJSON, systemPath).Exists() && gjson.GetBytes(rawJSON, prefix+"system_instruction").Exists() {
+				systemPath = prefix + "system_instruction"
+			}
+			if rawJSON, err = prependRaw(rawJSON, systemPath+".parts", []string{`{"text":` + jsonText(system) + `}`}); err != nil {
+				return nil, err
+			}
+		}
+		items := make([]string, 0, len(messages))
+		for _, msg := range messages {
+			role := "user"
+			if msg.Role == "assistant" {
+				role = "model"
+			}
+			items = append(items, `{"role":"`+role+`","parts":[{"text":`+jsonText(msg.Content)+`}]}`)
+		}
+		return prependRaw(rawJSON, prefix+"contents", items)
+	}
+	return nil, fmt.Errorf("prompt templates are not supported for %s requests", handlerType)
+}
+
+// applyPromptTemplate expands the prompt template a request names in its
+// "template" field into the request, before it is translated. Requests
+// without the field are returned unchanged.
+func (h *BaseAPIHandler) applyPromptTemplate(handlerType string, rawJSON []byte) ([]byte, *interfaces.ErrorMessage) {
+	field := gjson.GetBytes(rawJSON, promptTemplateField)
+	if !field.Exists() {
+		return rawJSON, nil
+	}
+	badRequest := func(err error) *interfaces.ErrorMessage {
+		return &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: err}
+	}
+	name := strings.TrimSpace(field.String())
+	if field.Type != gjson.String || name == "" {
+		return nil, badRequest(fmt.Errorf("%s must be the name of a prompt template", promptTemplateField))
+	}
+	var tmpl *config.PromptTemplate
+	if h != nil {
+		tmpl = promptTemplateFor(h.Cfg, name)
+	}
+	if tmpl == nil {
+		return nil, badRequest(fmt.Errorf("unknown prompt template %q", name))
+	}
+
+	vars := make(map[string]string, len(tmpl.Variables))
+	for key, value := range tmpl.Variables {
+		vars[key] = value
+	}
+	if supplied := gjson.GetBytes(rawJSON, promptTemplateVariablesField); supplied.Exists() {
+		if !supplied.IsObject() {
+			return nil, badRequest(fmt.Errorf("%s must be an object", promptTemplateVariablesField))
+		}
+		supplied.ForEach(func(key, value gjson.Result) bool {
+			if value.Type == gjson.String {
+				vars[key.String()] = value.String()
+			} else {
+				vars[key.String()] = value.Raw
+			}
+			return true
+		})
+	}
+
+	system, err := expandPromptTemplateText(tmpl.System, vars)
+	if err != nil {
+		return nil, badRequest(fmt.Errorf("prompt template %s: %w", tmpl.Name, err))
+	}
+	messages := make([]config.PromptTemplateMessage, len(tmpl.Messages))
+	for i, msg := range tmpl.Messages {
+		content, errExpand := expandPromptTemplateText(msg.Content, vars)
+		if errExpand != nil {
+			return nil, badRequest(fmt.Errorf("prompt template %s: %w", tmpl.Name, errExpand))
+		}
+		messages[i] = config.PromptTemplateMessage{Role: msg.Role, Content: content}
+	}
+
+	out, _ := sjson.DeleteBytes(rawJSON, promptTemplateField)
+	out, _ = sjson.DeleteBytes(out, promptTemplateVariablesField)
+	if out, err = insertPromptTemplate(handlerType, out, strings.TrimSpace(system), messages); err != nil {
+		return nil, badRequest(err)
+	}
+	return out, nil
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func newPromptTemplateHandler() *BaseAPIHandler {
+	cfg := &sdkconfig.Config{SDKConfig: sdkconfig.SDKConfig{PromptTemplates: []sdkconfig.PromptTemplate{{
+		Name:      "Review",
+		System:    "You review {{lang}} code for {{team}}.",
+		Messages:  []sdkconfig.PromptTemplateMessage{{Role: "user", Content: "Focus on {{focus}}."}, {Role: "assistant", Content: "Understood."}},
+		Variables: map[string]string{"team": "platform", "focus": "bugs"},
+	}}}}
+	cfg.SanitizePromptTemplates()
+	return NewBaseAPIHandlers(&cfg.SDKConfig, nil)
+}
+
+func TestApplyPromptTemplate_Formats(t *testing.T) {
+	h := newPromptTemplateHandler()
+	const system = "You review go code for platform."
+
+	out, errMsg := h.applyPromptTemplate("openai", []byte(`{"model":"gpt-5","template":"review","template_variables":{"lang":"go"},"messages":[{"role":"user","content":"diff"}]}`))
+	if errMsg != nil {
+		t.Fatalf("openai: %v", errMsg.Error)
+	}
+	if gjson.GetBytes(out, "template").Exists() || gjson.GetBytes(out, "template_variables").Exists() {
+		t.Fatalf("openai: template fields kept: %s", out)
+	}
+	messages := gjson.GetBytes(out, "messages").Array()
+	if len(messages) != 4 || messages[0].Get("content").String() != system || messages[1].Get("content").String() != "Focus on bugs." || messages[3].Get("content").String() != "diff" {
+		t.Fatalf("openai messages = %s", gjson.GetBytes(out, "messages").Raw)
+	}
+
+	out, errMsg = h.applyPromptTemplate("claude", []byte(`{"template":"review","template_variables":{"lang":"go","focus":"style"},"system":[{"type":"text","text":"client"}],"messages":[{"role":"user","content":"diff"}]}`))
+	if errMsg != nil {
+		t.Fatalf("claude: %v", errMsg.Error)
+	}
+	if gjson.GetBytes(out, "system.0.text").String() != system || gjson.GetBytes(out, "system.1.text").String() != "client" {
+		t.Fatalf("claude system = %s", gjson.GetBytes(out, "system").Raw)
+	}
+	if gjson.GetBytes(out, "messages.0.content").String() != "Focus on style." || gjson.GetBytes(out, "messages.#").Int() != 3 {
+		t.Fatalf("claude messages = %s", gjson.GetBytes(out, "messages").Raw)
+	}
+
+	out, errMsg = h.applyPromptTemplate("openai-response", []byte(`{"template":"review","template_variables":{"lang":"go"},"instructions":"client","input":"diff"}`))
+	if errMsg != nil {
+		t.Fatalf("responses: %v", errMsg.Error)
+	}
+	if gjson.GetBytes(out, "instructions").String() != system+"\n\nclient" || gjson.GetBytes(out, "input.2.content").String() != "diff" {
+		t.Fatalf("responses = %s", out)
+	}
+
+	out, errMsg = h.applyPromptTemplate("gemini", []byte(`{"template":"review","template_variables":{"lang":"go"},"contents":[{"role":"user","parts":[{"text":"diff"}]}]}`))
+	if errMsg != nil {
+		t.Fatalf("gemini: %v", errMsg.Error)
+	}
+	if gjson.GetBytes(out, "systemInstruction.parts.0.text").String() != system || gjson.GetBytes(out, "contents.1.role").String() != "model" || gjson.GetBytes(out, "contents.2.parts.0.text").String() != "diff" {
+		t.Fatalf("gemini = %s", out)
+	}
+}
+
+func TestApplyPromptTemplate_Errors(t *testing.T) {
+	h := newPromptTemplateHandler()
+	body := `{"messages":[]}`
+	if out, errMsg := h.applyPromptTemplate("openai", []byte(body)); errMsg != nil || string(out) != body {
+		t.Fatalf("request without template changed: %s, %v", out, errMsg)
+	}
+	for name, req := range map[string]string{
+		"unknown template": `{"template":"nope","messages":[]}`,
+		"missing variable": `{"template":"review","messages":[]}`,
+		"bad variables":    `{"template":"review","template_variables":["go"],"messages":[]}`,
+		"not a name":       `{"template":{"name":"review"},"messages":[]}`,
+	} {
+		if _, errMsg := h.applyPromptTemplate("openai", []byte(req)); errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected a 400, got %+v", name, errMsg)
+		}
+	}
+}
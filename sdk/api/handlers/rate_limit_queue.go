@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// rateLimitQueuedKey marks a gin context whose SSE response was committed
+// while the request waited in the rate-limit queue.
+const rateLimitQueuedKey = "RATE_LIMIT_QUEUED"
+
+// RateLimitQueueKeepAliveInterval returns how often streaming requests waiting
+// in the rate-limit queue receive an SSE keep-alive.
+func RateLimitQueueKeepAliveInterval(cfg *config.SDKConfig) time.Duration {
+	seconds := config.DefaultRateLimitQueueKeepAliveSeconds
+	if cfg != nil && cfg.RateLimitQueue.KeepAliveSeconds > 0 {
+		seconds = cfg.RateLimitQueue.KeepAliveSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// rateLimitQueueWeightFor returns the rate-limit queue weight of apiKey as
+// authenticated by the named access provider.
+func rateLimitQueueWeightFor(cfg *config.SDKConfig, provider, apiKey string) float64 {
+	if cfg == nil || apiKey == "" {
+		return 1
+	}
+	for _, entry := range cfg.RateLimitQueue.KeyWeights {
+		if entry.APIKey != apiKey {
+			continue
+		}
+		if entry.Provider != "" && !strings.EqualFold(entry.Provider, provider) {
+			continue
+		}
+		return entry.Weight
+	}
+	return 1
+}
+
+// applyRateLimitQueueClient records in meta the client API key the request
+// waits as, and its weight, should it be queued.
+func (h *BaseAPIHandler) applyRateLimitQueueClient(ctx context.Context, meta map[string]any) {
+	if h == nil || h.Cfg == nil || h.Cfg.RateLimitQueue.MaxWaitSeconds <= 0 || ctx == nil || meta == nil {
+		return
+	}
+	c, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || c == nil {
+		return
+	}
+	apiKey := c.GetString("apiKey")
+	if apiKey == "" {
+		return
+	}
+	meta[coreexecutor.QueueClientMetadataKey] = apiKey
+	meta[coreexecutor.QueueWeightMetadataKey] = rateLimitQueueWeightFor(h.Cfg, c.GetString("accessProvider"), apiKey)
+}
+
+// rateLimitQueueKeepAlive returns a queue-wait callback for the auth manager
+// that, the first time the request is queued, commits the SSE response and
+// keeps the client connection alive with comments. stop must be called before
+// the handler writes to the response; the callback does nothing afterwards.
+func (h *BaseAPIHandler) rateLimitQueueKeepAlive(ctx context.Context) (callback func(time.Duration), stop func()) {
+	c, _ := ctx.Value("gin").(*gin.Context)
+	if c == nil {
+		return nil, func() {}
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		return nil, func() {}
+	}
+	interval := RateLimitQueueKeepAliveInterval(h.Cfg)
+
+	var (
+		mu      sync.Mutex
+		started bool
+		stopped bool
+		done    = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	callback = func(wait time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		log.Debugf("all credentials rate-limited, request %s queued, retrying in %s", logging.GetRequestID(ctx), wait)
+		if started {
+			return
+		}
+		started = true
+		c.Set(rateLimitQueuedKey, true)
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		_, _ = c.Writer.Write([]byte(": keep-alive\n\n"))
+		flusher.Flush()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					_, _ = c.Writer.Write([]byte(": keep-alive\n\n"))
+					flusher.Flush()
+				}
+			}
+		}()
+	}
+	stop = func() {
+		mu.Lock()
+		if !stopped {
+			stopped = true
+			close(done)
+		}
+		mu.Unlock()
+		wg.Wait()
+	}
+	return callback, stop
+}
+
+// writeQueuedErrorEvent reports an error on a response the rate-limit queue
+// already committed as an SSE stream, where a status code can no longer be set.
+func writeQueuedErrorEvent(c *gin.Context, body []byte) bool {
+	if !c.GetBool(rateLimitQueuedKey) || !c.Writer.Written() {
+		return false
+	}
+	_, _ = fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", body)
+	if flusher, ok := c.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestRateLimitQueueKeepAlive_CommitsSSEAndReportsErrorsAsEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{RateLimitQueue: sdkconfig.RateLimitQueueConfig{MaxWaitSeconds: 30, KeepAliveSeconds: 1}}, nil)
+
+	ctx := context.WithValue(context.Background(), "gin", c)
+	callback, stop := h.rateLimitQueueKeepAlive(ctx)
+	if callback == nil {
+		t.Fatal("rateLimitQueueKeepAlive() returned no callback")
+	}
+	callback(time.Second)
+	callback(time.Second)
+	stop()
+	// Waits reported after stop, e.g. during bootstrap retries, must not write.
+	callback(time.Second)
+
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	if got := rec.Body.String(); got != ": keep-alive\n\n" {
+		t.Fatalf("body after queueing = %q", got)
+	}
+
+	h.WriteErrorResponse(c, &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: errors.New("all credentials rate-limited")})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the committed 200", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "event: error\ndata: {") || !strings.Contains(body, "all credentials rate-limited") {
+		t.Fatalf("error after queueing was not sent as an SSE event: %q", body)
+	}
+}
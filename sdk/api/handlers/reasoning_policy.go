@@ -0,0 +1,575 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// reasoningSummaryMaxRunes bounds what the summarized mode keeps of one
+// reasoning block.
+const reasoningSummaryMaxRunes = 600
+
+func apiKeyReasoningFor(cfg *config.SDKConfig, provider, apiKey string) *config.APIKeyReasoning {
+	if cfg == nil || apiKey == "" {
+		return nil
+	}
+	for i := range cfg.APIKeyReasoning {
+		entry := &cfg.APIKeyReasoning[i]
+		if entry.APIKey != apiKey {
+			continue
+		}
+		if entry.Provider != "" && !strings.EqualFold(entry.Provider, provider) {
+			continue
+		}
+		return entry
+	}
+	return nil
+}
+
+// reasoningMode returns the api-key-reasoning mode of the client of ctx, or
+// "" when its responses carry reasoning as received.
+func (h *BaseAPIHandler) reasoningMode(ctx context.Context) string {
+	if h == nil || h.Cfg == nil || len(h.Cfg.APIKeyReasoning) == 0 || ctx == nil {
+		return ""
+	}
+	c, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || c == nil {
+		return ""
+	}
+	entry := apiKeyReasoningFor(h.Cfg, c.GetString("accessProvider"), c.GetString("apiKey"))
+	if entry == nil || entry.Mode == config.ReasoningVerbatim {
+		return ""
+	}
+	return entry.Mode
+}
+
+// firstSentence returns the first line of para up to the end of its first
+// sentence. Reasoning often opens a paragraph with a heading line, which is
+// kept on its own.
+func firstSentence(para string) string {
+	if i := strings.IndexByte(para, '\n'); i >= 0 {
+		para = para[:i]
+	}
+	for i, r := range para {
+		switch r {
+		case '.', '!', '?':
+			if end := i + 1; end == len(para) || para[end] == ' ' {
+				return para[:end]
+			}
+		case '。', '！', '？':
+			return para[:i+utf8.RuneLen(r)]
+		}
+	}
+	return para
+}
+
+// summarizeReasoning keeps the opening sentence of each paragraph of text,
+// up to reasoningSummaryMaxRunes.
+func summarizeReasoning(text string) string {
+	var lines []string
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if para = strings.TrimSpace(para); para != "" {
+			lines = append(lines, firstSentence(para))
+		}
+	}
+	summary := strings.Join(lines, "\n")
+	if runes := []rune(summary); len(runes) > reasoningSummaryMaxRunes {
+		summary = strings.TrimSpace(string(runes[:reasoningSummaryMaxRunes])) + "…"
+	}
+	return summary
+}
+
+// redactReasoning returns what mode lets the client see of reasoning text.
+func redactReasoning(mode, text string) string {
+	if mode == config.ReasoningSummarized {
+		return summarizeReasoning(text)
+	}
+	return ""
+}
+
+// redactStringField replaces the reasoning string at path, removing the field
+// when nothing of it may be shown.
+func redactStringField(body []byte, path, mode string) []byte {
+	value := gjson.GetBytes(body, path)
+	if value.Type != gjson.String {
+		return body
+	}
+	var err error
+	var out []byte
+	if redacted := redactReasoning(mode, value.Str); redacted != "" {
+		out, err = sjson.SetBytes(body, path, redacted)
+	} else {
+		out, err = sjson.DeleteBytes(body, path)
+	}
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// redactResponsesItems rewrites the reasoning items of the Responses output
+// array at path. Their encrypted_content is opaque to the client and kept, so
+// the items can still be sent back.
+func redactResponsesItems(body []byte, path, mode string) []byte {
+	gjson.GetBytes(body, path).ForEach(func(i, item gjson.Result) bool {
+		if item.Get("type").String() == "reasoning" {
+			body = redactResponsesItem(body, path+"."+i.String(), mode)
+		}
+		return true
+	})
+	return body
+}
+
+func redactResponsesItem(body []byte, path, mode string) []byte {
+	for _, field := range []string{"summary", "content"} {
+		parts := gjson.GetBytes(body, path+"."+field)
+		if !parts.IsArray() {
+			continue
+		}
+		if mode != config.ReasoningSummarized {
+			body, _ = sjson.SetRawBytes(body, path+"."+field, []byte(`[]`))
+			continue
+		}
+		parts.ForEach(func(j, part gjson.Result) bool {
+			if text := part.Get("text"); text.Type == gjson.String {
+				body, _ = sjson.SetBytes(body, path+"."+field+"."+j.String()+".text", redactReasoning(mode, text.Str))
+			}
+			return true
+		})
+	}
+	return body
+}
+
+// takeGeminiThoughts removes the thought text of the Gemini candidate at
+// path and returns it, reporting whether the candidate had thoughts. Thought
+// parts carrying a signature keep it without their text.
+func takeGeminiThoughts(body []byte, path string) ([]byte, string, bool) {
+	parts := gjson.GetBytes(body, path+".content.parts")
+	if !parts.IsArray() {
+		return body, "", false
+	}
+	kept := make([]string, 0, len(parts.Array()))
+	var text strings.Builder
+	found := false
+	for _, part := range parts.Array() {
+		if !part.Get("thought").Bool() {
+			kept = append(kept, part.Raw)
+			continue
+		}
+		found = true
+		text.WriteString(part.Get("text").String())
+		if part.Get("thoughtSignature").Exists() || part.Get("thought_signature").Exists() {
+			raw, _ := sjson.Delete(part.Raw, "text")
+			kept = append(kept, raw)
+		}
+	}
+	if !found {
+		return body, "", false
+	}
+	out, err := sjson.SetRawBytes(body, path+".content.parts", []byte("["+strings.Join(kept, ",")+"]"))
+	if err != nil {
+		return body, "", false
+	}
+	return out, text.String(), true
+}
+
+func geminiPrefix(handlerType string) string {
+	if handlerType == "gemini-cli" {
+		return "response."
+	}
+	return ""
+}
+
+// applyReasoningPolicy rewrites the reasoning content of a non-streaming
+// response in the format of handlerType as mode says. Claude thinking blocks
+// keep their signatures, and their original text is remembered so the blocks
+// can be restored when clients send them back.
+func applyReasoningPolicy(handlerType, mode string, body []byte) []byte {
+	if mode == "" || len(body) == 0 || !gjson.ValidBytes(body) {
+		return body
+	}
+	switch handlerType {
+	case "openai":
+		gjson.GetBytes(body, "choices").ForEach(func(i, _ gjson.Result) bool {
+			base := "choices." + i.String() + ".message."
+			body = redactStringField(body, base+"reasoning_content", mode)
+			body = redactStringField(body, base+"reasoning", mode)
+			body, _ = sjson.DeleteBytes(body, base+"reasoning_details")
+			return true
+		})
+	case "claude":
+		gjson.GetBytes(body, "content").ForEach(func(i, block gjson.Result) bool {
+			if block.Get("type").String() == "thinking" {
+				text := block.Get("thinking").String()
+				cache.RememberThinkingText(block.Get("signature").String(), text)
+				body, _ = sjson.SetBytes(body, "content."+i.String()+".thinking", redactReasoning(mode, text))
+			}
+			return true
+		})
+	case "gemini", "gemini-cli":
+		prefix := geminiPrefix(handlerType)
+		gjson.GetBytes(body, prefix+"candidates").ForEach(func(i, _ gjson.Result) bool {
+			path := prefix + "candidates." + i.String()
+			var text string
+			var found bool
+			if body, text, found = takeGeminiThoughts(body, path); found {
+				if summary := redactReasoning(mode, text); summary != "" {
+					part := `{"text":` + strconv.Quote(summary) + `,"thought":true}`
+					body, _ = prependRaw(body, path+".content.parts", []string{part})
+				}
+			}
+			return true
+		})
+	case "openai-response":
+		body = redactResponsesItems(body, "output", mode)
+	}
+	return body
+}
+
+// reasoningFilter applies an api-key-reasoning mode to one outbound stream in
+// the client's format. Reasoning deltas are withheld; in summarized mode the
+// summary of what was withheld is sent as a single delta once the reasoning
+// ends. Usage is left untouched, so reasoning tokens are still reported.
+type reasoningFilter struct {
+	handlerType string
+	mode        string
+
+	// held is the withheld reasoning text by chat choice index; Gemini and
+	// Claude use a single entry.
+	held  map[int64]*strings.Builder
+	order []int64
+	// envelope is the last chunk that carried reasoning, the template of the
+	// chunk the summary is sent in.
+	envelope []byte
+
+	// thinkingBlock is the index of the open Claude thinking block, or -1.
+	thinkingBlock int64
+	// seqShift is added to Responses sequence numbers, which must stay
+	// consecutive as reasoning events are dropped or inserted.
+	seqShift int64
+}
+
+// newReasoningFilter returns the filter for a stream of the client of ctx,
+// or nil when its reasoning is forwarded as received.
+func (h *BaseAPIHandler) newReasoningFilter(ctx context.Context, handlerType string) *reasoningFilter {
+	mode := h.reasoningMode(ctx)
+	if mode == "" {
+		return nil
+	}
+	switch handlerType {
+	case "openai", "claude", "openai-response", "gemini", "gemini-cli":
+		return &reasoningFilter{handlerType: handlerType, mode: mode, thinkingBlock: -1}
+	default:
+		return nil
+	}
+}
+
+func (f *reasoningFilter) hold(index int64, text string) {
+	if f.held == nil {
+		f.held = make(map[int64]*strings.Builder)
+	}
+	buf, ok := f.held[index]
+	if !ok {
+		buf = &strings.Builder{}
+		f.held[index] = buf
+		f.order = append(f.order, index)
+	}
+	buf.WriteString(text)
+}
+
+// take returns the summaries of the withheld reasoning, in the order it
+// arrived, and forgets it.
+func (f *reasoningFilter) take() (indexes []int64, summaries []string) {
+	for _, index := range f.order {
+		if summary := redactReasoning(f.mode, f.held[index].String()); summary != "" {
+			indexes = append(indexes, index)
+			summaries = append(summaries, summary)
+		}
+	}
+	f.held, f.order = nil, nil
+	return indexes, summaries
+}
+
+// Process returns the chunks to send in place of chunk, possibly none.
+func (f *reasoningFilter) Process(chunk []byte) [][]byte {
+	switch f.handlerType {
+	case "openai":
+		return f.processChat(chunk)
+	case "gemini", "gemini-cli":
+		return f.processGemini(chunk)
+	default:
+		return f.processSSE(chunk)
+	}
+}
+
+// Flush returns the summary of reasoning still withheld when the stream ends.
+func (f *reasoningFilter) Flush() [][]byte {
+	switch f.handlerType {
+	case "openai":
+		return f.releaseChat()
+	case "gemini", "gemini-cli":
+		return f.releaseGemini()
+	default:
+		return nil
+	}
+}
+
+func (f *reasoningFilter) processChat(chunk []byte) [][]byte {
+	data := bytes.TrimSpace(chunk)
+	if !gjson.ValidBytes(data) {
+		return [][]byte{chunk}
+	}
+	out := data
+	hadReasoning, hasOutput := false, false
+	gjson.GetBytes(data, "choices").ForEach(func(i, choice gjson.Result) bool {
+		base := "choices." + i.String() + ".delta."
+		for _, field := range []string{"reasoning_content", "reasoning", "reasoning_details"} {
+			value := choice.Get("delta." + field)
+			if !value.Exists() {
+				continue
+			}
+			hadReasoning = true
+			if value.Type == gjson.String {
+				f.hold(choice.Get("index").Int(), value.Str)
+			}
+			out, _ = sjson.DeleteBytes(out, base+field)
+		}
+		if content := choice.Get("delta.content"); content.Type == gjson.String && content.Str != "" {
+			hasOutput = true
+		}
+		if choice.Get("delta.tool_calls").Exists() || (choice.Get("finish_reason").Exists() && choice.Get("finish_reason").Type != gjson.Null) {
+			hasOutput = true
+		}
+		return true
+	})
+	if !hadReasoning {
+		return append(f.releaseChat(), chunk)
+	}
+	f.envelope = data
+	if hasOutput {
+		return append(f.releaseChat(), out)
+	}
+	residual := gjson.GetBytes(out, "usage").IsObject()
+	gjson.GetBytes(out, "choices").ForEach(func(_, choice gjson.Result) bool {
+		if delta := choice.Get("delta"); delta.IsObject() && len(delta.Map()) > 0 {
+			residual = true
+		}
+		return !residual
+	})
+	if residual {
+		return [][]byte{out}
+	}
+	return nil
+}
+
+func (f *reasoningFilter) releaseChat() [][]byte {
+	indexes, summaries := f.take()
+	if len(summaries) == 0 {
+		return nil
+	}
+	chunk, _ := sjson.DeleteBytes(f.envelope, "usage")
+	chunk, _ = sjson.SetRawBytes(chunk, "choices", []byte(`[]`))
+	for i, summary := range summaries {
+		path := "choices." + strconv.Itoa(i)
+		chunk, _ = sjson.SetBytes(chunk, path+".index", indexes[i])
+		chunk, _ = sjson.SetBytes(chunk, path+".delta.reasoning_content", summary)
+		chunk, _ = sjson.SetRawBytes(chunk, path+".finish_reason", []byte(`null`))
+	}
+	return [][]byte{chunk}
+}
+
+func (f *reasoningFilter) processGemini(chunk []byte) [][]byte {
+	data := bytes.TrimSpace(chunk)
+	if !gjson.ValidBytes(data) {
+		return [][]byte{chunk}
+	}
+	prefix := geminiPrefix(f.handlerType)
+	out := data
+	hadThoughts, hasOutput := false, false
+	gjson.GetBytes(data, prefix+"candidates").ForEach(func(i, candidate gjson.Result) bool {
+		path := prefix + "candidates." + i.String()
+		var text string
+		var found bool
+		if out, text, found = takeGeminiThoughts(out, path); found {
+			hadThoughts = true
+			f.hold(0, text)
+		}
+		if len(gjson.GetBytes(out, path+".content.parts").Array()) > 0 || candidate.Get("finishReason").Exists() {
+			hasOutput = true
+		}
+		return true
+	})
+	if !hadThoughts {
+		return append(f.releaseGemini(), chunk)
+	}
+	f.envelope = data
+	if hasOutput {
+		return append(f.releaseGemini(), out)
+	}
+	if gjson.GetBytes(out, prefix+"usageMetadata").Exists() {
+		return [][]byte{out}
+	}
+	return nil
+}
+
+func (f *reasoningFilter) releaseGemini() [][]byte {
+	_, summaries := f.take()
+	if len(summaries) == 0 {
+		return nil
+	}
+	prefix := geminiPrefix(f.handlerType)
+	chunk := []byte(`{"candidates":[{"content":{"role":"model","parts":[{"thought":true}]},"index":0}]}`)
+	chunk, _ = sjson.SetBytes(chunk, "candidates.0.content.parts.0.text", summaries[0])
+	for _, field := range []string{"modelVersion", "responseId"} {
+		if value := gjson.GetBytes(f.envelope, prefix+field); value.Exists() {
+			chunk, _ = sjson.SetRawBytes(chunk, field, []byte(value.Raw))
+		}
+	}
+	if prefix != "" {
+		chunk, _ = sjson.SetRawBytes([]byte(`{}`), "response", chunk)
+	}
+	return [][]byte{chunk}
+}
+
+func (f *reasoningFilter) processSSE(chunk []byte) [][]byte {
+	frames := parseSSEFrames(chunk)
+	if len(frames) == 0 {
+		return [][]byte{chunk}
+	}
+	kept := make([]string, 0, len(frames))
+	changed := false
+	for _, frame := range frames {
+		data := frame.data()
+		if !gjson.Valid(data) {
+			kept = append(kept, frame.String())
+			continue
+		}
+		var out []string
+		var modified bool
+		if f.handlerType == "claude" {
+			out, modified = f.claudeFrame(frame, data)
+		} else {
+			out, modified = f.responsesFrame(frame, data)
+		}
+		changed = changed || modified
+		kept = append(kept, out...)
+	}
+	if !changed {
+		return [][]byte{chunk}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	trailer := chunk[len(bytes.TrimRight(chunk, "\r\n")):]
+	return [][]byte{append([]byte(strings.Join(kept, "\n\n")), trailer...)}
+}
+
+// claudeFrame returns the frames to send for one Claude stream event.
+func (f *reasoningFilter) claudeFrame(frame *sseFrame, data string) ([]string, bool) {
+	event := gjson.Parse(data)
+	index := event.Get("index").Int()
+	switch event.Get("type").String() {
+	case "content_block_start":
+		if event.Get("content_block.type").String() != "thinking" {
+			return []string{frame.String()}, false
+		}
+		f.thinkingBlock = index
+		if text := event.Get("content_block.thinking").String(); text != "" {
+			f.hold(0, text)
+			updated, _ := sjson.Set(data, "content_block.thinking", "")
+			frame.setData(updated)
+			return []string{frame.String()}, true
+		}
+	case "content_block_delta":
+		if index != f.thinkingBlock {
+			break
+		}
+		switch event.Get("delta.type").String() {
+		case "thinking_delta":
+			f.hold(0, event.Get("delta.thinking").String())
+			return nil, true
+		case "signature_delta":
+			// The signature covers the text as received; remember it so the
+			// block can be restored when the client sends it back.
+			if held := f.held[0]; held != nil {
+				cache.RememberThinkingText(event.Get("delta.signature").String(), held.String())
+			}
+		}
+	case "content_block_stop":
+		if index != f.thinkingBlock {
+			return []string{frame.String()}, false
+		}
+		f.thinkingBlock = -1
+		_, summaries := f.take()
+		if len(summaries) == 0 {
+			return []string{frame.String()}, false
+		}
+		delta := `{"type":"content_block_delta","delta":{"type":"thinking_delta"}}`
+		delta, _ = sjson.Set(delta, "index", index)
+		delta, _ = sjson.Set(delta, "delta.thinking", summaries[0])
+		return []string{"event: content_block_delta\ndata: " + delta, frame.String()}, true
+	}
+	return []string{frame.String()}, false
+}
+
+// responsesFrame returns the frames to send for one Responses stream event.
+func (f *reasoningFilter) responsesFrame(frame *sseFrame, data string) ([]string, bool) {
+	event := gjson.Parse(data)
+	kind := event.Get("type").String()
+	seq := event.Get("sequence_number")
+	modified := false
+	if seq.Exists() && f.seqShift != 0 {
+		data, _ = sjson.Set(data, "sequence_number", seq.Int()+f.seqShift)
+		modified = true
+	}
+	switch kind {
+	case "response.reasoning_summary_text.delta", "response.reasoning_text.delta":
+		f.seqShift--
+		return nil, true
+	case "response.reasoning_summary_text.done", "response.reasoning_text.done":
+		summary := redactReasoning(f.mode, event.Get("text").String())
+		data, _ = sjson.Set(data, "text", summary)
+		frame.setData(data)
+		if summary == "" {
+			return []string{frame.String()}, true
+		}
+		deltaKind := strings.TrimSuffix(kind, ".done") + ".delta"
+		delta, _ := sjson.Delete(data, "text")
+		delta, _ = sjson.Set(delta, "type", deltaKind)
+		delta, _ = sjson.Set(delta, "delta", summary)
+		if seq.Exists() {
+			data, _ = sjson.Set(data, "sequence_number", seq.Int()+f.seqShift+1)
+			frame.setData(data)
+			f.seqShift++
+		}
+		return []string{"event: " + deltaKind + "\ndata: " + delta, frame.String()}, true
+	case "response.reasoning_summary_part.added", "response.reasoning_summary_part.done":
+		if text := event.Get("part.text"); text.Type == gjson.String && text.Str != "" {
+			data, _ = sjson.Set(data, "part.text", redactReasoning(f.mode, text.Str))
+			modified = true
+		}
+	case "response.output_item.added", "response.output_item.done":
+		if event.Get("item.type").String() == "reasoning" {
+			data = string(redactResponsesItem([]byte(data), "item", f.mode))
+			modified = true
+		}
+	default:
+		if event.Get("response.output").IsArray() {
+			data = string(redactResponsesItems([]byte(data), "response.output", f.mode))
+			modified = true
+		}
+	}
+	if modified {
+		frame.setData(data)
+	}
+	return []string{frame.String()}, modified
+}
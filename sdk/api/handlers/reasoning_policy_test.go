@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/cache"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+const sampleReasoning = "**Planning the answer**\nThe user wants a sum. I will add the numbers.\n\nTwo plus two is four. That is the answer."
+
+func TestSummarizeReasoning(t *testing.T) {
+	if got := summarizeReasoning(sampleReasoning); got != "**Planning the answer**\nTwo plus two is four." {
+		t.Fatalf("summary = %q", got)
+	}
+	long := strings.Repeat("word ", 400)
+	if got := []rune(summarizeReasoning(long)); len(got) > reasoningSummaryMaxRunes+1 || got[len(got)-1] != '…' {
+		t.Fatalf("long summary has %d runes", len(got))
+	}
+}
+
+func TestReasoningMode_PerKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &sdkconfig.Config{SDKConfig: sdkconfig.SDKConfig{APIKeyReasoning: []sdkconfig.APIKeyReasoning{
+		{APIKey: "open", Mode: "verbatim"},
+		{APIKey: "summary", Mode: "Summarized"},
+		{APIKey: "typo", Mode: "strip"},
+	}}}
+	cfg.SanitizeAPIKeyReasoning()
+	h := NewBaseAPIHandlers(&cfg.SDKConfig, nil)
+	for key, want := range map[string]string{"open": "", "summary": sdkconfig.ReasoningSummarized, "typo": sdkconfig.ReasoningStripped, "other": ""} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("apiKey", key)
+		if got := h.reasoningMode(context.WithValue(context.Background(), "gin", c)); got != want {
+			t.Errorf("key %s: mode %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestApplyReasoningPolicy_NonStreaming(t *testing.T) {
+	openai := []byte(`{"choices":[{"message":{"role":"assistant","content":"4","reasoning_content":` + jsonText(sampleReasoning) + `}}],"usage":{"completion_tokens_details":{"reasoning_tokens":42}}}`)
+	stripped := applyReasoningPolicy("openai", sdkconfig.ReasoningStripped, openai)
+	if gjson.GetBytes(stripped, "choices.0.message.reasoning_content").Exists() || gjson.GetBytes(stripped, "usage.completion_tokens_details.reasoning_tokens").Int() != 42 {
+		t.Fatalf("stripped openai = %s", stripped)
+	}
+	summarized := applyReasoningPolicy("openai", sdkconfig.ReasoningSummarized, openai)
+	if got := gjson.GetBytes(summarized, "choices.0.message.reasoning_content").String(); got != summarizeReasoning(sampleReasoning) {
+		t.Fatalf("summarized openai reasoning = %q", got)
+	}
+
+	claude := []byte(`{"content":[{"type":"thinking","thinking":` + jsonText(sampleReasoning) + `,"signature":"sig"},{"type":"text","text":"4"}]}`)
+	out := applyReasoningPolicy("claude", sdkconfig.ReasoningStripped, claude)
+	if gjson.GetBytes(out, "content.0.thinking").String() != "" || gjson.GetBytes(out, "content.0.signature").String() != "sig" {
+		t.Fatalf("stripped claude = %s", out)
+	}
+
+	gemini := []byte(`{"candidates":[{"content":{"parts":[{"text":"thinking...","thought":true},{"text":"4"}]}}]}`)
+	out = applyReasoningPolicy("gemini", sdkconfig.ReasoningStripped, gemini)
+	if parts := gjson.GetBytes(out, "candidates.0.content.parts").Array(); len(parts) != 1 || parts[0].Get("text").String() != "4" {
+		t.Fatalf("stripped gemini = %s", out)
+	}
+
+	responses := []byte(`{"output":[{"type":"reasoning","summary":[{"type":"summary_text","text":` + jsonText(sampleReasoning) + `}],"encrypted_content":"enc"},{"type":"message"}]}`)
+	out = applyReasoningPolicy("openai-response", sdkconfig.ReasoningSummarized, responses)
+	if gjson.GetBytes(out, "output.0.summary.0.text").String() != summarizeReasoning(sampleReasoning) || gjson.GetBytes(out, "output.0.encrypted_content").String() != "enc" {
+		t.Fatalf("summarized responses = %s", out)
+	}
+}
+
+func collectFiltered(f *reasoningFilter, chunks ...string) []string {
+	var out []string
+	for _, chunk := range chunks {
+		for _, payload := range f.Process([]byte(chunk)) {
+			out = append(out, string(payload))
+		}
+	}
+	for _, payload := range f.Flush() {
+		out = append(out, string(payload))
+	}
+	return out
+}
+
+func TestReasoningFilter_ChatStreamSummarizesBeforeContent(t *testing.T) {
+	f := &reasoningFilter{handlerType: "openai", mode: sdkconfig.ReasoningSummarized, thinkingBlock: -1}
+	out := collectFiltered(f,
+		`{"id":"c1","model":"m","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"The user wants a sum. "}}]}`,
+		`{"id":"c1","model":"m","choices":[{"index":0,"delta":{"reasoning_content":"I will add."}}]}`,
+		`{"id":"c1","model":"m","choices":[{"index":0,"delta":{"content":"4"},"finish_reason":"stop"}]}`,
+		`{"id":"c1","model":"m","choices":[],"usage":{"completion_tokens_details":{"reasoning_tokens":9}}}`,
+	)
+	if len(out) != 4 {
+		t.Fatalf("chunks = %q", out)
+	}
+	if gjson.Get(out[0], "choices.0.delta.role").String() != "assistant" || gjson.Get(out[0], "choices.0.delta.reasoning_content").Exists() {
+		t.Fatalf("role chunk = %s", out[0])
+	}
+	if got := gjson.Get(out[1], "choices.0.delta.reasoning_content").String(); got != "The user wants a sum." || gjson.Get(out[1], "id").String() != "c1" {
+		t.Fatalf("summary chunk = %s", out[1])
+	}
+	if gjson.Get(out[2], "choices.0.delta.content").String() != "4" || gjson.Get(out[3], "usage.completion_tokens_details.reasoning_tokens").Int() != 9 {
+		t.Fatalf("content and usage chunks = %q", out[2:])
+	}
+}
+
+func TestReasoningFilter_ClaudeStreamStripsThinking(t *testing.T) {
+	streamSignature := "Er" + strings.Repeat("C", 60)
+	f := &reasoningFilter{handlerType: "claude", mode: sdkconfig.ReasoningStripped, thinkingBlock: -1}
+	out := strings.Join(collectFiltered(f,
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"thinking\",\"thinking\":\"\"}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"secret plan\"}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"signature_delta\",\"signature\":\""+streamSignature+"\"}}\n\n",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"text_delta\",\"text\":\"4\"}}\n\n",
+	), "")
+	if strings.Contains(out, "secret plan") || !strings.Contains(out, "signature_delta") || !strings.Contains(out, "text_delta") {
+		t.Fatalf("filtered claude stream = %q", out)
+	}
+	if got := cache.RecallThinkingText(streamSignature); got != "secret plan" {
+		t.Fatalf("remembered thinking = %q, want the text the signature covers", got)
+	}
+}
+
+func TestReasoningFilter_ClaudeStreamSummary(t *testing.T) {
+	f := &reasoningFilter{handlerType: "claude", mode: sdkconfig.ReasoningSummarized, thinkingBlock: -1}
+	out := collectFiltered(f,
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"thinking\",\"thinking\":\"\"}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"First idea. More detail.\"}}\n\n",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n",
+	)
+	if len(out) != 2 {
+		t.Fatalf("chunks = %q", out)
+	}
+	frames := parseSSEFrames([]byte(out[1]))
+	if len(frames) != 2 || gjson.Get(frames[0].data(), "delta.thinking").String() != "First idea." || frames[1].event() != "content_block_stop" {
+		t.Fatalf("closing chunk = %q", out[1])
+	}
+}
+
+func TestReasoningFilter_ResponsesStreamKeepsSequence(t *testing.T) {
+	f := &reasoningFilter{handlerType: "openai-response", mode: sdkconfig.ReasoningSummarized, thinkingBlock: -1}
+	out := collectFiltered(f,
+		"event: response.reasoning_summary_text.delta\ndata: {\"type\":\"response.reasoning_summary_text.delta\",\"sequence_number\":3,\"item_id\":\"rs_1\",\"summary_index\":0,\"delta\":\"First idea. \"}",
+		"event: response.reasoning_summary_text.delta\ndata: {\"type\":\"response.reasoning_summary_text.delta\",\"sequence_number\":4,\"item_id\":\"rs_1\",\"summary_index\":0,\"delta\":\"More detail.\"}",
+		"event: response.reasoning_summary_text.done\ndata: {\"type\":\"response.reasoning_summary_text.done\",\"sequence_number\":5,\"item_id\":\"rs_1\",\"summary_index\":0,\"text\":\"First idea. More detail.\"}",
+		"event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"sequence_number\":6,\"delta\":\"4\"}",
+	)
+	if len(out) != 2 {
+		t.Fatalf("chunks = %q", out)
+	}
+	frames := parseSSEFrames([]byte(out[0]))
+	if len(frames) != 2 || gjson.Get(frames[0].data(), "delta").String() != "First idea." || gjson.Get(frames[0].data(), "sequence_number").Int() != 3 ||
+		gjson.Get(frames[1].data(), "text").String() != "First idea." || gjson.Get(frames[1].data(), "sequence_number").Int() != 4 {
+		t.Fatalf("summary frames = %q", out[0])
+	}
+	if got := gjson.Get(parseSSEFrames([]byte(out[1]))[0].data(), "sequence_number").Int(); got != 5 {
+		t.Fatalf("next event renumbered to %d, want 5", got)
+	}
+}
+
+func TestReasoningFilter_GeminiStreamStripsThoughts(t *testing.T) {
+	f := &reasoningFilter{handlerType: "gemini-cli", mode: sdkconfig.ReasoningStripped, thinkingBlock: -1}
+	out := collectFiltered(f,
+		`{"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"pondering","thought":true}]}}]}}`,
+		`{"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"4"}]},"finishReason":"STOP"}],"usageMetadata":{"thoughtsTokenCount":7}}}`,
+	)
+	if len(out) != 1 || gjson.Get(out[0], "response.usageMetadata.thoughtsTokenCount").Int() != 7 {
+		t.Fatalf("chunks = %q", out)
+	}
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spill"
+)
+
+// dedupHeader marks responses served from another request's upstream call.
+const dedupHeader = "X-Deduplicated"
+
+// dedupReadChunk bounds how much of a recorded response a follower copies at
+// once.
+const dedupReadChunk = 256 << 10
+
+// dedupEntry records the response of one request so identical requests can
+// follow it.
+type dedupEntry struct {
+	key string
+
+	mu       sync.Mutex
+	started  bool
+	status   int
+	header   http.Header
+	body     *spill.Buffer
+	finished bool
+	// abandoned marks a response cut short because the leader's client left
+	// or its handler aborted; it is not replayed.
+	abandoned bool
+	// changed is closed and replaced whenever the response grows or finishes.
+	changed chan struct{}
+	// refs counts the registry, the leader and the followers still using
+	// body, which is released by the last of them.
+	refs int
+}
+
+type dedupRegistry struct {
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+var requestDedups = &dedupRegistry{entries: make(map[string]*dedupEntry)}
+
+// join returns the entry already recorded under key, or registers a new one
+// recording into newBody for the caller to fill; leader reports which of the
+// two happened. Callers release the entry once done with it.
+func (r *dedupRegistry) join(key string, newBody func() *spill.Buffer) (entry *dedupEntry, leader bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing := r.entries[key]; existing != nil {
+		existing.mu.Lock()
+		existing.refs++
+		existing.mu.Unlock()
+		return existing, false
+	}
+	entry = &dedupEntry{key: key, body: newBody(), changed: make(chan struct{}), refs: 2}
+	r.entries[key] = entry
+	return entry, true
+}
+
+// remove drops entry unless key was registered again since.
+func (r *dedupRegistry) remove(entry *dedupEntry) {
+	r.mu.Lock()
+	removed := r.entries[entry.key] == entry
+	if removed {
+		delete(r.entries, entry.key)
+	}
+	r.mu.Unlock()
+	if removed {
+		entry.release()
+	}
+}
+
+// release drops one reference to entry, freeing the recorded body with the
+// last one.
+func (e *dedupEntry) release() {
+	e.mu.Lock()
+	e.refs--
+	last := e.refs == 0
+	e.mu.Unlock()
+	if last {
+		_ = e.body.Close()
+	}
+}
+
+func (e *dedupEntry) broadcastLocked() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// start snapshots the status and headers the first time the leader writes.
+func (e *dedupEntry) start(status int, header http.Header) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.started {
+		return
+	}
+	e.started = true
+	e.status = status
+	e.header = header.Clone()
+	e.broadcastLocked()
+}
+
+func (e *dedupEntry) append(p []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.body.Write(p)
+	e.broadcastLocked()
+}
+
+func (e *dedupEntry) finish(status int, header http.Header, abandoned bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.started && !abandoned {
+		e.started = true
+		e.status = status
+		e.header = header.Clone()
+	}
+	e.finished = true
+	e.abandoned = abandoned
+	e.broadcastLocked()
+}
+
+// since returns up to dedupReadChunk bytes of the recorded body after offset
+// and the channel that signals the next change. finished is only reported
+// with the last bytes, along with whether the response was abandoned.
+func (e *dedupEntry) since(offset int64) (started bool, status int, header http.Header, body []byte, finished, abandoned bool, changed <-chan struct{}) {
+	e.mu.Lock()
+	started, status, header, finished, abandoned, changed = e.started, e.status, e.header, e.finished, e.abandoned, e.changed
+	size := e.body.Len()
+	e.mu.Unlock()
+	if offset < size {
+		body = make([]byte, min(size-offset, dedupReadChunk))
+		n, _ := e.body.ReadAt(body, offset)
+		body = body[:n]
+		finished = finished && offset+int64(n) >= size
+		if len(body) == 0 {
+			// The body cannot be read; end the copy rather than loop on it.
+			finished = true
+		}
+	}
+	return started, status, header, body, finished, abandoned, changed
+}
+
+// dedupWriter copies everything the leader's handler writes into its entry.
+type dedupWriter struct {
+	gin.ResponseWriter
+	entry *dedupEntry
+}
+
+func (w *dedupWriter) Write(p []byte) (int, error) {
+	w.entry.start(w.ResponseWriter.Status(), w.ResponseWriter.Header())
+	n, err := w.ResponseWriter.Write(p)
+	w.entry.append(p[:n])
+	return n, err
+}
+
+func (w *dedupWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func dedupWindow(h *BaseAPIHandler) time.Duration {
+	if h == nil || h.Cfg == nil || h.Cfg.RequestDedup.WindowSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(h.Cfg.RequestDedup.WindowSeconds) * time.Second
+}
+
+// RequestDedupMiddleware serves identical POST requests, keyed by API key,
+// path, query and body, from a single upstream call when request-dedup.window-seconds
+// is set. A duplicate of a request still in flight follows its live response;
+// one arriving later within the window replays the recorded copy. Responses
+// with an error status are forgotten as soon as they finish so retries go
+// upstream again, and so are responses the leader's client abandoned; a
+// follower that has not received any of such a response makes its own
+// upstream call. It must run after authentication.
+func RequestDedupMiddleware(h *BaseAPIHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		window := dedupWindow(h)
+		if window <= 0 || c.Request.Method != http.MethodPost || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		body, errRead := io.ReadAll(c.Request.Body)
+		_ = c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if errRead != nil || len(body) == 0 {
+			c.Next()
+			return
+		}
+
+		sum := sha256.New()
+		sum.Write([]byte(c.GetString("apiKey")))
+		sum.Write([]byte{0})
+		sum.Write([]byte(c.Request.URL.Path))
+		sum.Write([]byte{0})
+		sum.Write([]byte(c.Request.URL.RawQuery))
+		sum.Write([]byte{0})
+		sum.Write(body)
+		entry, leader := requestDedups.join(hex.EncodeToString(sum.Sum(nil)), func() *spill.Buffer { return newResponseBuffer(h.Cfg) })
+		if !leader {
+			served := followDedup(c, entry)
+			entry.release()
+			if served {
+				c.Abort()
+			} else {
+				c.Next()
+			}
+			return
+		}
+
+		arrived := time.Now()
+		writer := &dedupWriter{ResponseWriter: c.Writer, entry: entry}
+		c.Writer = writer
+		defer func() {
+			defer entry.release()
+			status := writer.ResponseWriter.Status()
+			abandoned := c.Request.Context().Err() != nil || c.IsAborted()
+			entry.finish(status, writer.ResponseWriter.Header(), abandoned)
+			remaining := window - time.Since(arrived)
+			if abandoned || status >= http.StatusBadRequest || remaining <= 0 {
+				requestDedups.remove(entry)
+				return
+			}
+			time.AfterFunc(remaining, func() { requestDedups.remove(entry) })
+		}()
+		c.Next()
+	}
+}
+
+// followDedup writes entry's response to c as it is produced, until it
+// finishes or the client leaves. It reports false, having written nothing,
+// when the response was abandoned before any of it reached c.
+func followDedup(c *gin.Context, entry *dedupEntry) bool {
+	var offset int64
+	wroteHeader := false
+	flusher, _ := c.Writer.(http.Flusher)
+	for {
+		started, status, header, body, finished, abandoned, changed := entry.since(offset)
+		if abandoned && !wroteHeader {
+			return false
+		}
+		if started && !wroteHeader {
+			// Headers this request already carries, such as its own
+			// X-Request-ID, are kept.
+			for name, values := range header {
+				if _, exists := c.Writer.Header()[name]; !exists {
+					c.Writer.Header()[name] = append([]string(nil), values...)
+				}
+			}
+			c.Writer.Header().Set(dedupHeader, "true")
+			c.Writer.WriteHeader(status)
+			c.Writer.WriteHeaderNow()
+			wroteHeader = true
+		}
+		if len(body) > 0 {
+			if _, errWrite := c.Writer.Write(body); errWrite != nil {
+				return true
+			}
+			offset += int64(len(body))
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if finished {
+			return true
+		}
+		if len(body) > 0 {
+			// More may be recorded already; only wait once caught up.
+			continue
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return true
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"os"
+)
+
+func newDedupTestRouter(t *testing.T, release <-chan struct{}, calls *atomic.Int32) *gin.Engine {
+	t.Helper()
+	t.Cleanup(func() {
+		requestDedups.mu.Lock()
+		requestDedups.entries = make(map[string]*dedupEntry)
+		requestDedups.mu.Unlock()
+	})
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{RequestDedup: sdkconfig.RequestDedupConfig{WindowSeconds: 30}}, nil)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("apiKey", c.GetHeader("X-Test-Key"))
+		c.Next()
+	})
+	router.Use(RequestDedupMiddleware(h))
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		n := calls.Add(1)
+		c.Header("Content-Type", "text/event-stream")
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.WriteString("data: first\n\n")
+		c.Writer.Flush()
+		<-release
+		_, _ = c.Writer.WriteString("data: call " + string(rune('0'+n)) + "\n\n")
+	})
+	router.POST("/v1/fail", func(c *gin.Context) {
+		calls.Add(1)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "upstream"})
+	})
+	return router
+}
+
+func postDedup(router *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("X-Test-Key", key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequestDedupMiddleware_SharesInFlightAndRecentResponses(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	router := newDedupTestRouter(t, release, &calls)
+	body := `{"model":"m","stream":true,"messages":[{"role":"user","content":"dedup-shared"}]}`
+
+	var wg sync.WaitGroup
+	var leader, follower *httptest.ResponseRecorder
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		leader = postDedup(router, "/v1/chat/completions", "k1", body)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		follower = postDedup(router, "/v1/chat/completions", "k1", body)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls.Load())
+	}
+	want := "data: first\n\ndata: call 1\n\n"
+	if leader.Body.String() != want || follower.Body.String() != want {
+		t.Fatalf("leader %q, follower %q", leader.Body.String(), follower.Body.String())
+	}
+	if leader.Header().Get(dedupHeader) != "" || follower.Header().Get(dedupHeader) != "true" {
+		t.Fatalf("dedup headers: leader %q, follower %q", leader.Header().Get(dedupHeader), follower.Header().Get(dedupHeader))
+	}
+	if follower.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("follower Content-Type = %q", follower.Header().Get("Content-Type"))
+	}
+
+	replay := postDedup(router, "/v1/chat/completions", "k1", body)
+	if calls.Load() != 1 || replay.Body.String() != want || replay.Header().Get(dedupHeader) != "true" {
+		t.Fatalf("completed replay: calls %d, body %q", calls.Load(), replay.Body.String())
+	}
+
+	if postDedup(router, "/v1/chat/completions", "k2", body); calls.Load() != 2 {
+		t.Fatalf("another API key must not share the response, calls = %d", calls.Load())
+	}
+}
+
+func TestRequestDedupMiddleware_ForgetsAbandonedResponses(t *testing.T) {
+	var calls atomic.Int32
+	router := newDedupTestRouter(t, nil, &calls)
+	router.POST("/v1/abandon", func(c *gin.Context) {
+		calls.Add(1)
+		c.Header("Content-Type", "text/event-stream")
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.WriteString("data: partial\n\n")
+		c.Writer.Flush()
+		if c.GetHeader("X-Test-Leave") != "" {
+			<-c.Request.Context().Done()
+			return
+		}
+		_, _ = c.Writer.WriteString("data: done\n\n")
+	})
+	body := `{"model":"m","stream":true}`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderReq := httptest.NewRequest(http.MethodPost, "/v1/abandon", strings.NewReader(body)).WithContext(ctx)
+	leaderReq.Header.Set("X-Test-Key", "k1")
+	leaderReq.Header.Set("X-Test-Leave", "1")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(httptest.NewRecorder(), leaderReq)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	rec := postDedup(router, "/v1/abandon", "k1", body)
+	if calls.Load() != 2 || rec.Header().Get(dedupHeader) != "" || rec.Body.String() != "data: partial\n\ndata: done\n\n" {
+		t.Fatalf("after an abandoned leader: calls %d, dedup %q, body %q", calls.Load(), rec.Header().Get(dedupHeader), rec.Body.String())
+	}
+}
+
+func TestRequestDedupMiddleware_KeysOnQuery(t *testing.T) {
+	var calls atomic.Int32
+	router := newDedupTestRouter(t, nil, &calls)
+	router.POST("/v1beta/models/m:streamGenerateContent", func(c *gin.Context) {
+		calls.Add(1)
+		c.String(http.StatusOK, "alt=%s", c.Query("alt"))
+	})
+	body := `{"contents":[]}`
+	postDedup(router, "/v1beta/models/m:streamGenerateContent", "k1", body)
+	rec := postDedup(router, "/v1beta/models/m:streamGenerateContent?alt=sse", "k1", body)
+	if calls.Load() != 2 || rec.Body.String() != "alt=sse" {
+		t.Fatalf("a different query must not share the response: calls %d, body %q", calls.Load(), rec.Body.String())
+	}
+}
+
+func TestRequestDedupMiddleware_DoesNotReuseErrors(t *testing.T) {
+	var calls atomic.Int32
+	router := newDedupTestRouter(t, nil, &calls)
+	for i := 0; i < 2; i++ {
+		if rec := postDedup(router, "/v1/fail", "k1", `{"model":"m"}`); rec.Code != http.StatusBadGateway || rec.Header().Get(dedupHeader) != "" {
+			t.Fatalf("attempt %d: status %d, dedup %q", i, rec.Code, rec.Header().Get(dedupHeader))
+		}
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("handler ran %d times, want 2", calls.Load())
+	}
+}
+
+func TestRequestDedupMiddleware_SpillsLargeResponses(t *testing.T) {
+	t.Cleanup(func() {
+		requestDedups.mu.Lock()
+		requestDedups.entries = make(map[string]*dedupEntry)
+		requestDedups.mu.Unlock()
+	})
+	dir := t.TempDir()
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		RequestDedup:  sdkconfig.RequestDedupConfig{WindowSeconds: 30},
+		ResponseSpill: sdkconfig.ResponseSpillConfig{ThresholdBytes: 1024, Dir: dir},
+	}, nil)
+	large := strings.Repeat("0123456789", 70_000)
+	var calls atomic.Int32
+	router := gin.New()
+	router.Use(RequestDedupMiddleware(h))
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		calls.Add(1)
+		c.String(http.StatusOK, large)
+	})
+
+	if first := postDedup(router, "/v1/chat/completions", "", `{"model":"m"}`); first.Body.String() != large {
+		t.Fatalf("leader body has %d bytes, want %d", first.Body.Len(), len(large))
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("recorded response should be spilled to one file, found %d", len(files))
+	}
+	replay := postDedup(router, "/v1/chat/completions", "", `{"model":"m"}`)
+	if calls.Load() != 1 || replay.Body.String() != large {
+		t.Fatalf("replay: calls %d, %d bytes", calls.Load(), replay.Body.Len())
+	}
+
+	requestDedups.mu.Lock()
+	var entries []*dedupEntry
+	for _, entry := range requestDedups.entries {
+		entries = append(entries, entry)
+	}
+	requestDedups.mu.Unlock()
+	for _, entry := range entries {
+		requestDedups.remove(entry)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expired response left %d spill files", len(files))
+	}
+}
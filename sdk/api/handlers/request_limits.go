@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tokenizer"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/stopreason"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// outputLimitWarning is the Warning header of responses an api-key-limits
+// entry capped. Non-streaming responses carry it when they were cut at the
+// cap; streams carry it from the start, since their headers precede the
+// point where the cap is reached.
+func outputLimitWarning(limit int) string {
+	return fmt.Sprintf(`299 - "output limited to %d tokens per request for this API key"`, limit)
+}
+
+// outputTextPaths locate generated text in the client stream formats.
+var outputTextPaths = []string{
+	"choices.#.delta.content",
+	"choices.#.delta.reasoning_content",
+	"choices.#.delta.tool_calls.#.function.arguments",
+	"delta.text",
+	"delta.thinking",
+	"delta.partial_json",
+	"candidates.#.content.parts.#.text",
+	"response.candidates.#.content.parts.#.text",
+}
+
+// apiKeyLimitFor returns the first api-key-limits entry for apiKey as
+// authenticated by the named access provider.
+func apiKeyLimitFor(cfg *config.SDKConfig, provider, apiKey string) *config.APIKeyLimit {
+	if cfg == nil || apiKey == "" {
+		return nil
+	}
+	for i := range cfg.APIKeyLimits {
+		entry := &cfg.APIKeyLimits[i]
+		if entry.APIKey != apiKey {
+			continue
+		}
+		if entry.Provider != "" && !strings.EqualFold(entry.Provider, provider) {
+			continue
+		}
+		return entry
+	}
+	return nil
+}
+
+// modelPriceFor returns the first model-prices entry matching model.
+func modelPriceFor(cfg *config.SDKConfig, model string) *config.ModelPrice {
+	if cfg == nil {
+		return nil
+	}
+	model = strings.ToLower(thinking.ParseSuffix(strings.TrimPrefix(model, "models/")).ModelName)
+	for i := range cfg.ModelPrices {
+		if matchModelGlob(strings.ToLower(cfg.ModelPrices[i].Model), model) {
+			return &cfg.ModelPrices[i]
+		}
+	}
+	return nil
+}
+
+// estimateTokens counts text with the model's tokenizer, falling back to four
+// bytes per token.
+func estimateTokens(enc tokenizer.Tokenizer, text string) int64 {
+	if text == "" {
+		return 0
+	}
+	if enc != nil {
+		if count, err := enc.Count(text); err == nil {
+			return int64(count)
+		}
+	}
+	return int64((len(text) + 3) / 4)
+}
+
+func modelTokenizer(model string) tokenizer.Tokenizer {
+	enc, err := tokenizer.ForModel(thinking.ParseSuffix(model).ModelName)
+	if err != nil {
+		return nil
+	}
+	return enc
+}
+
+// requestOutputLimit returns the output token cap the requesting API key's
+// limits allow for the request, or 0 when there is none. A cost limit gives
+// the request what is left of its budget once the prompt is paid for; a
+// prompt that alone costs more than the limit is rejected.
+func (h *BaseAPIHandler) requestOutputLimit(ctx context.Context, modelName string, rawJSON []byte) (int, *interfaces.ErrorMessage) {
+	if h == nil || h.Cfg == nil || len(h.Cfg.APIKeyLimits) == 0 || ctx == nil {
+		return 0, nil
+	}
+	c, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || c == nil {
+		return 0, nil
+	}
+	entry := apiKeyLimitFor(h.Cfg, c.GetString("accessProvider"), c.GetString("apiKey"))
+	if entry == nil {
+		return 0, nil
+	}
+	limit := entry.MaxOutputTokens
+	if entry.MaxCostUSD <= 0 {
+		return limit, nil
+	}
+	price := modelPriceFor(h.Cfg, modelName)
+	if price == nil {
+		log.Debugf("api-key-limits: no model-prices entry for %s, not limiting its cost", modelName)
+		return limit, nil
+	}
+	f := extractPromptFeatures(modelName, rawJSON)
+	inputCost := float64(estimateTokens(modelTokenizer(modelName), f.text)) * price.InputPerMillion / 1e6
+	if inputCost >= entry.MaxCostUSD {
+		return 0, &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("estimated prompt cost $%.4f exceeds the per-request limit of $%.4f for this API key", inputCost, entry.MaxCostUSD),
+		}
+	}
+	if price.OutputPerMillion > 0 {
+		budget := int((entry.MaxCostUSD - inputCost) / price.OutputPerMillion * 1e6)
+		if limit == 0 || budget < limit {
+			limit = max(budget, 1)
+		}
+	}
+	return limit, nil
+}
+
+// clampOutputTokens lowers the output token limit of a request in the format
+// of handlerType to limit, setting it when the request has none.
+func clampOutputTokens(handlerType string, rawJSON []byte, limit int) []byte {
+	clamp := func(path string, always bool) {
+		current := gjson.GetBytes(rawJSON, path)
+		if (!current.Exists() && !always) || (current.Exists() && current.Int() > 0 && current.Int() <= int64(limit)) {
+			return
+		}
+		if updated, err := sjson.SetBytes(rawJSON, path, limit); err == nil {
+			rawJSON = updated
+		}
+	}
+	switch handlerType {
+	case "openai":
+		hasCompletion := gjson.GetBytes(rawJSON, "max_completion_tokens").Exists()
+		clamp("max_completion_tokens", false)
+		clamp("max_tokens", !hasCompletion)
+	case "openai-response":
+		clamp("max_output_tokens", true)
+	case "claude":
+		clamp("max_tokens", true)
+		// Claude rejects thinking budgets that do not fit in max_tokens.
+		if budget := gjson.GetBytes(rawJSON, "thinking.budget_tokens"); budget.Exists() && budget.Int() >= int64(limit) {
+			if updated, err := sjson.SetBytes(rawJSON, "thinking.budget_tokens", max(limit-1, 0)); err == nil {
+				rawJSON = updated
+			}
+		}
+	case "gemini":
+		clamp("generationConfig.maxOutputTokens", true)
+	case "gemini-cli":
+		clamp("request.generationConfig.maxOutputTokens", true)
+	}
+	return rawJSON
+}
+
+// applyRequestLimits enforces the requesting API key's api-key-limits on the
+// request and returns its output token cap, or 0 when it has none.
+func (h *BaseAPIHandler) applyRequestLimits(ctx context.Context, handlerType, modelName string, rawJSON []byte) ([]byte, int, *interfaces.ErrorMessage) {
+	limit, errMsg := h.requestOutputLimit(ctx, modelName, rawJSON)
+	if errMsg != nil || limit <= 0 {
+		return rawJSON, 0, errMsg
+	}
+	return clampOutputTokens(handlerType, rawJSON, limit), limit, nil
+}
+
+// stoppedForLength reports whether a non-streaming response in the format of
+// handlerType ended at its output token limit.
+func stoppedForLength(handlerType string, body []byte) bool {
+	root := gjson.ParseBytes(body)
+	var reason stopreason.Reason
+	switch handlerType {
+	case "openai":
+		reason = stopreason.Parse(root.Get("choices.0.finish_reason").String())
+	case "openai-response":
+		reason = stopreason.FromResponsesObject(root)
+	case "claude":
+		reason = stopreason.Parse(root.Get("stop_reason").String())
+	case "gemini":
+		reason = stopreason.Parse(root.Get("candidates.0.finishReason").String())
+	case "gemini-cli":
+		reason = stopreason.Parse(root.Get("response.candidates.0.finishReason").String())
+	}
+	return reason == stopreason.Length
+}
+
+// setStreamOutputLimitWarning adds the output limit warning to the client
+// response before the stream starts.
+func setStreamOutputLimitWarning(ctx context.Context, limit int) {
+	if ctx == nil {
+		return
+	}
+	if c, ok := ctx.Value("gin").(*gin.Context); ok && c != nil {
+		c.Header("Warning", outputLimitWarning(limit))
+	}
+}
+
+// outputLimitGuard counts the output of a stream in the client's format and
+// ends the stream once it would pass the limit, with the closing events a
+// model hitting its length limit sends.
+type outputLimitGuard struct {
+	handlerType string
+	limit       int64
+	used        int64
+	enc         tokenizer.Tokenizer
+
+	// Stream identity repeated in the closing events.
+	id       string
+	model    string
+	created  int64
+	sequence int64
+	// openBlock is the index of the Claude content block being streamed, or -1.
+	openBlock int64
+}
+
+func newOutputLimitGuard(handlerType, model string, limit int) *outputLimitGuard {
+	if limit <= 0 {
+		return nil
+	}
+	return &outputLimitGuard{handlerType: handlerType, limit: int64(limit), enc: modelTokenizer(model), model: model, openBlock: -1}
+}
+
+// events returns the JSON payloads carried by one client stream chunk.
+func (g *outputLimitGuard) events(payload []byte) []gjson.Result {
+	switch g.handlerType {
+	case "claude", "openai-response":
+		frames := parseSSEFrames(payload)
+		out := make([]gjson.Result, 0, len(frames))
+		for _, frame := range frames {
+			if data := frame.data(); gjson.Valid(data) {
+				out = append(out, gjson.Parse(data))
+			}
+		}
+		return out
+	default:
+		if !gjson.ValidBytes(payload) {
+			return nil
+		}
+		return []gjson.Result{gjson.ParseBytes(payload)}
+	}
+}
+
+// admit counts the output of payload and reports whether it still fits in
+// the limit. Once it returns false the stream must end with closing().
+func (g *outputLimitGuard) admit(payload []byte) bool {
+	var text strings.Builder
+	for _, event := range g.events(payload) {
+		g.observe(event)
+		for _, path := range outputTextPaths {
+			appendOutputText(&text, event.Get(path))
+		}
+		if delta := event.Get("delta"); delta.Type == gjson.String {
+			text.WriteString(delta.Str)
+		}
+	}
+	tokens := estimateTokens(g.enc, text.String())
+	if g.used+tokens > g.limit {
+		return false
+	}
+	g.used += tokens
+	return true
+}
+
+// observe records the stream identity and state closing() needs.
+func (g *outputLimitGuard) observe(event gjson.Result) {
+	switch g.handlerType {
+	case "openai":
+		if id := event.Get("id").String(); id != "" {
+			g.id = id
+		}
+		if created := event.Get("created").Int(); created > 0 {
+			g.created = created
+		}
+		if model := event.Get("model").String(); model != "" {
+			g.model = model
+		}
+	case "claude":
+		switch event.Get("type").String() {
+		case "content_block_start":
+			g.openBlock = event.Get("index").Int()
+		case "content_block_stop":
+			g.openBlock = -1
+		}
+	case "openai-response":
+		if id := event.Get("response.id").String(); id != "" {
+			g.id = id
+		}
+		if model := event.Get("response.model").String(); model != "" {
+			g.model = model
+		}
+		if created := event.Get("response.created_at").Int(); created > 0 {
+			g.created = created
+		}
+		if seq := event.Get("sequence_number").Int(); seq > g.sequence {
+			g.sequence = seq
+		}
+	}
+}
+
+// closing returns the chunks that end the stream as cut at the limit.
+func (g *outputLimitGuard) closing() [][]byte {
+	switch g.handlerType {
+	case "openai":
+		created := g.created
+		if created == 0 {
+			created = time.Now().Unix()
+		}
+		chunk := []byte(`{"object":"chat.completion.chunk","choices":[{"index":0,"delta":{}}]}`)
+		chunk, _ = sjson.SetBytes(chunk, "id", g.id)
+		chunk, _ = sjson.SetBytes(chunk, "created", created)
+		chunk, _ = sjson.SetBytes(chunk, "model", g.model)
+		chunk, _ = sjson.SetBytes(chunk, "choices.0.finish_reason", stopreason.ToOpenAI(stopreason.Length))
+		return [][]byte{chunk}
+	case "claude":
+		var out strings.Builder
+		if g.openBlock >= 0 {
+			fmt.Fprintf(&out, "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":%d}\n\n", g.openBlock)
+		}
+		delta := []byte(`{"type":"message_delta","delta":{"stop_sequence":null}}`)
+		delta, _ = sjson.SetBytes(delta, "delta.stop_reason", stopreason.ToClaude(stopreason.Length))
+		delta, _ = sjson.SetBytes(delta, "usage.output_tokens", g.used)
+		fmt.Fprintf(&out, "event: message_delta\ndata: %s\n\n", delta)
+		out.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+		return [][]byte{[]byte(out.String())}
+	case "openai-response":
+		status, reason := stopreason.ToResponses(stopreason.Length)
+		event := []byte(`{"type":"response.incomplete","response":{"object":"response","output":[]}}`)
+		event, _ = sjson.SetBytes(event, "sequence_number", g.sequence+1)
+		event, _ = sjson.SetBytes(event, "response.id", g.id)
+		event, _ = sjson.SetBytes(event, "response.created_at", g.created)
+		event, _ = sjson.SetBytes(event, "response.model", g.model)
+		event, _ = sjson.SetBytes(event, "response.status", status)
+		event, _ = sjson.SetBytes(event, "response.incomplete_details.reason", reason)
+		return [][]byte{append([]byte("event: response.incomplete\ndata: "), event...)}
+	case "gemini", "gemini-cli":
+		chunk := []byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":""}]},"index":0}]}`)
+		chunk, _ = sjson.SetBytes(chunk, "candidates.0.finishReason", stopreason.ToGemini(stopreason.Length))
+		chunk, _ = sjson.SetBytes(chunk, "modelVersion", g.model)
+		if g.handlerType == "gemini-cli" {
+			chunk, _ = sjson.SetRawBytes([]byte(`{}`), "response", chunk)
+		}
+		return [][]byte{chunk}
+	}
+	return nil
+}
+
+func appendOutputText(dst *strings.Builder, value gjson.Result) {
+	if value.IsArray() {
+		for _, item := range value.Array() {
+			appendOutputText(dst, item)
+		}
+		return
+	}
+	if value.Type == gjson.String {
+		dst.WriteString(value.Str)
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func limitsContext(apiKey string) (context.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Set("apiKey", apiKey)
+	return context.WithValue(context.Background(), "gin", c), rec
+}
+
+func TestApplyRequestLimits_ClampsOutputTokens(t *testing.T) {
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{APIKeyLimits: []sdkconfig.APIKeyLimit{{APIKey: "agent", MaxOutputTokens: 1000}}}, nil)
+	ctx, _ := limitsContext("agent")
+
+	cases := []struct {
+		handlerType, body, path string
+		want                    int64
+	}{
+		{"openai", `{"messages":[]}`, "max_tokens", 1000},
+		{"openai", `{"max_completion_tokens":5000}`, "max_completion_tokens", 1000},
+		{"openai", `{"max_tokens":200}`, "max_tokens", 200},
+		{"openai-response", `{"input":"hi"}`, "max_output_tokens", 1000},
+		{"claude", `{"max_tokens":4096,"thinking":{"type":"enabled","budget_tokens":2048}}`, "thinking.budget_tokens", 999},
+		{"gemini", `{"contents":[]}`, "generationConfig.maxOutputTokens", 1000},
+		{"gemini-cli", `{"request":{"generationConfig":{"maxOutputTokens":8192}}}`, "request.generationConfig.maxOutputTokens", 1000},
+	}
+	for _, tc := range cases {
+		out, limit, errMsg := h.applyRequestLimits(ctx, tc.handlerType, "gpt-5", []byte(tc.body))
+		if errMsg != nil || limit != 1000 {
+			t.Fatalf("%s %s: limit %d, err %v", tc.handlerType, tc.body, limit, errMsg)
+		}
+		if got := gjson.GetBytes(out, tc.path).Int(); got != tc.want {
+			t.Errorf("%s %s: %s = %d, want %d", tc.handlerType, tc.body, tc.path, got, tc.want)
+		}
+	}
+	if gjson.GetBytes(mustClamp(t, h, ctx, `{"max_completion_tokens":10}`), "max_tokens").Exists() {
+		t.Error("max_tokens should not be added next to max_completion_tokens")
+	}
+
+	other, _ := limitsContext("someone-else")
+	if out, limit, _ := h.applyRequestLimits(other, "openai", "gpt-5", []byte(`{}`)); limit != 0 || string(out) != `{}` {
+		t.Fatalf("unlimited key got limit %d, body %s", limit, out)
+	}
+}
+
+func mustClamp(t *testing.T, h *BaseAPIHandler, ctx context.Context, body string) []byte {
+	t.Helper()
+	out, _, errMsg := h.applyRequestLimits(ctx, "openai", "gpt-5", []byte(body))
+	if errMsg != nil {
+		t.Fatalf("applyRequestLimits: %v", errMsg.Error)
+	}
+	return out
+}
+
+func TestApplyRequestLimits_CostBudget(t *testing.T) {
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		APIKeyLimits: []sdkconfig.APIKeyLimit{{APIKey: "agent", MaxCostUSD: 0.01, MaxOutputTokens: 100000}},
+		ModelPrices:  []sdkconfig.ModelPrice{{Model: "gpt-5*", InputPerMillion: 10, OutputPerMillion: 10}},
+	}, nil)
+	ctx, _ := limitsContext("agent")
+
+	_, limit, errMsg := h.applyRequestLimits(ctx, "openai", "gpt-5-mini", []byte(`{"messages":[{"role":"user","content":"hello"}]}`))
+	if errMsg != nil {
+		t.Fatalf("small prompt rejected: %v", errMsg.Error)
+	}
+	if limit <= 0 || limit >= 1000 {
+		t.Fatalf("cost budget limit = %d, want just under 1000 tokens", limit)
+	}
+
+	huge := `{"messages":[{"role":"user","content":"` + strings.Repeat("lorem ipsum dolor ", 2000) + `"}]}`
+	if _, _, errMsg = h.applyRequestLimits(ctx, "openai", "gpt-5", []byte(huge)); errMsg == nil || errMsg.StatusCode != http.StatusBadRequest {
+		t.Fatalf("prompt over the cost limit: %+v, want 400", errMsg)
+	}
+
+	if _, limit, _ = h.applyRequestLimits(ctx, "claude", "claude-sonnet-4", []byte(huge)); limit != 100000 {
+		t.Fatalf("unpriced model limit = %d, want the token limit", limit)
+	}
+}
+
+func TestOutputLimitGuard_OpenAIStream(t *testing.T) {
+	guard := newOutputLimitGuard("openai", "gpt-5", 3)
+	first := []byte(`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":42,"model":"gpt-5","choices":[{"index":0,"delta":{"content":"abcd"}}]}`)
+	if !guard.admit(first) {
+		t.Fatal("first chunk should fit")
+	}
+	if guard.admit([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"` + strings.Repeat("word ", 50) + `"}}]}`)) {
+		t.Fatal("chunk past the limit was admitted")
+	}
+	closing := guard.closing()
+	if len(closing) != 1 {
+		t.Fatalf("closing = %q", closing)
+	}
+	root := gjson.ParseBytes(closing[0])
+	if root.Get("id").String() != "chatcmpl-1" || root.Get("created").Int() != 42 || root.Get("choices.0.finish_reason").String() != "length" {
+		t.Fatalf("closing chunk = %s", closing[0])
+	}
+}
+
+func TestOutputLimitGuard_ClaudeStream(t *testing.T) {
+	guard := newOutputLimitGuard("claude", "claude-sonnet-4", 2)
+	start := []byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n")
+	if !guard.admit(start) {
+		t.Fatal("block start should fit")
+	}
+	if guard.admit([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"text_delta\",\"text\":\"" + strings.Repeat("word ", 20) + "\"}}\n\n")) {
+		t.Fatal("delta past the limit was admitted")
+	}
+	frames := parseSSEFrames(guard.closing()[0])
+	var types []string
+	for _, frame := range frames {
+		types = append(types, gjson.Get(frame.data(), "type").String())
+	}
+	if strings.Join(types, ",") != "content_block_stop,message_delta,message_stop" {
+		t.Fatalf("closing events = %v", types)
+	}
+	if got := gjson.Get(frames[0].data(), "index").Int(); got != 1 {
+		t.Fatalf("closed block %d, want 1", got)
+	}
+	if got := gjson.Get(frames[1].data(), "delta.stop_reason").String(); got != "max_tokens" {
+		t.Fatalf("stop_reason = %q", got)
+	}
+}
+
+func TestOutputLimitGuard_ResponsesAndGemini(t *testing.T) {
+	guard := newOutputLimitGuard("openai-response", "gpt-5", 1)
+	guard.admit([]byte("event: response.created\ndata: {\"type\":\"response.created\",\"sequence_number\":4,\"response\":{\"id\":\"resp_1\",\"model\":\"gpt-5\"}}"))
+	if guard.admit([]byte("event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"sequence_number\":5,\"delta\":\"a long sentence of words\"}")) {
+		t.Fatal("delta past the limit was admitted")
+	}
+	frames := parseSSEFrames(guard.closing()[0])
+	data := gjson.Parse(frames[0].data())
+	if data.Get("type").String() != "response.incomplete" || data.Get("response.id").String() != "resp_1" ||
+		data.Get("response.incomplete_details.reason").String() != "max_output_tokens" || data.Get("sequence_number").Int() != 6 {
+		t.Fatalf("closing event = %s", frames[0].data())
+	}
+
+	gemini := newOutputLimitGuard("gemini-cli", "gemini-2.5-pro", 1)
+	if gemini.admit([]byte(`{"response":{"candidates":[{"content":{"parts":[{"text":"a long sentence of words"}]}}]}}`)) {
+		t.Fatal("gemini chunk past the limit was admitted")
+	}
+	if got := gjson.GetBytes(gemini.closing()[0], "response.candidates.0.finishReason").String(); got != "MAX_TOKENS" {
+		t.Fatalf("gemini finishReason = %q", got)
+	}
+}
+
+func TestStoppedForLength(t *testing.T) {
+	for handlerType, body := range map[string]string{
+		"openai":          `{"choices":[{"finish_reason":"length"}]}`,
+		"claude":          `{"stop_reason":"max_tokens"}`,
+		"gemini":          `{"candidates":[{"finishReason":"MAX_TOKENS"}]}`,
+		"openai-response": `{"status":"incomplete","incomplete_details":{"reason":"max_output_tokens"}}`,
+	} {
+		if !stoppedForLength(handlerType, []byte(body)) {
+			t.Errorf("%s %s not detected as a length stop", handlerType, body)
+		}
+	}
+	if stoppedForLength("openai", []byte(`{"choices":[{"finish_reason":"stop"}]}`)) {
+		t.Error("finish_reason stop detected as a length stop")
+	}
+}
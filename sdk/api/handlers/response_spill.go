@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"os"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spill"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// newResponseBuffer returns the buffer a middleware keeps a copy of a
+// response in, spilling to response-spill.dir past
+// response-spill.threshold-bytes.
+func newResponseBuffer(cfg *config.SDKConfig) *spill.Buffer {
+	if cfg == nil || cfg.ResponseSpill.ThresholdBytes <= 0 {
+		return spill.New("", 0)
+	}
+	dir := strings.TrimSpace(cfg.ResponseSpill.Dir)
+	if dir != "" {
+		resolved, err := util.ResolveAuthDir(dir)
+		if err != nil {
+			log.Warnf("response spill: resolve %s: %v", dir, err)
+			resolved = ""
+		}
+		dir = resolved
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			log.Warnf("response spill: create %s: %v", dir, err)
+			dir = ""
+		}
+	}
+	return spill.New(dir, cfg.ResponseSpill.ThresholdBytes)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/servicetier"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// applyServiceTier records in meta the service tier the client asked for.
+// A service_tier in the request body wins over the X-Request-Priority header.
+func applyServiceTier(ctx context.Context, handlerType string, rawJSON []byte, meta map[string]any) {
+	tier := servicetier.FromRequest(rawJSON, handlerType)
+	if tier == "" && ctx != nil {
+		if c, ok := ctx.Value("gin").(*gin.Context); ok && c != nil && c.Request != nil {
+			tier = servicetier.Normalize(c.GetHeader(servicetier.RequestHeader))
+		}
+	}
+	if tier != "" && meta != nil {
+		meta[coreexecutor.ServiceTierMetadataKey] = tier
+	}
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// shadowTimeout bounds a mirrored request, and how long its record waits for
+// the primary response.
+const shadowTimeout = 10 * time.Minute
+
+var (
+	shadowSlotsMu sync.Mutex
+	shadowSlots   chan struct{}
+)
+
+// acquireShadowSlot reserves one of limit in-flight mirrored requests without
+// blocking. Changing the limit applies to slots acquired afterwards.
+func acquireShadowSlot(limit int) (release func(), ok bool) {
+	shadowSlotsMu.Lock()
+	if shadowSlots == nil || cap(shadowSlots) != limit {
+		shadowSlots = make(chan struct{}, limit)
+	}
+	slots := shadowSlots
+	shadowSlotsMu.Unlock()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+		return nil, false
+	}
+}
+
+// shadowRule returns the first shadow-traffic rule matching model.
+func shadowRule(cfg *config.SDKConfig, model string) *config.ShadowTrafficRule {
+	if cfg == nil {
+		return nil
+	}
+	model = strings.ToLower(strings.TrimPrefix(model, "models/"))
+	for i := range cfg.ShadowTraffic.Rules {
+		rule := &cfg.ShadowTraffic.Rules[i]
+		if matchModelGlob(strings.ToLower(rule.Model), model) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// shadowResult is one side of a mirrored request.
+type shadowResult struct {
+	Model     string `json:"model"`
+	Status    int    `json:"status"`
+	Body      string `json:"body,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// shadowRecord is what is written to the store for a mirrored request.
+type shadowRecord struct {
+	RequestID string          `json:"request_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Handler   string          `json:"handler"`
+	Stream    bool            `json:"stream"`
+	Request   json.RawMessage `json:"request,omitempty"`
+	Primary   *shadowResult   `json:"primary,omitempty"`
+	Shadow    shadowResult    `json:"shadow"`
+}
+
+// shadowRun tracks a request being mirrored. Its methods are nil-safe so
+// callers do not need to check whether the request was sampled.
+type shadowRun struct {
+	started time.Time
+	model   string
+
+	primaryOnce sync.Once
+	primaryDone chan struct{}
+	primary     shadowResult
+}
+
+// startShadow samples the request against the shadow-traffic rules and, when
+// it is picked, sends a copy to the rule's target model in the background. It
+// returns nil when the request is not mirrored; otherwise the caller reports
+// the primary response through finishPrimary or teeStream.
+func (h *BaseAPIHandler) startShadow(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string, stream bool) *shadowRun {
+	if h == nil || h.Cfg == nil || h.AuthManager == nil || len(h.Cfg.ShadowTraffic.Rules) == 0 {
+		return nil
+	}
+	rule := shadowRule(h.Cfg, modelName)
+	if rule == nil || rand.Float64()*100 >= rule.Percent {
+		return nil
+	}
+	limit := h.Cfg.ShadowTraffic.MaxConcurrent
+	if limit <= 0 {
+		limit = config.DefaultShadowTrafficMaxConcurrent
+	}
+	release, ok := acquireShadowSlot(limit)
+	if !ok {
+		log.Debugf("shadow traffic: %d mirrored requests in flight, not mirroring %s", limit, modelName)
+		return nil
+	}
+
+	var storeDir string
+	if dir := h.Cfg.ShadowTraffic.StoreDir; dir != "" {
+		resolved, err := util.ResolveAuthDir(dir)
+		if err != nil {
+			log.Warnf("shadow traffic: resolve store-dir: %v", err)
+		}
+		storeDir = resolved
+	}
+	run := &shadowRun{started: time.Now(), model: modelName, primaryDone: make(chan struct{})}
+	record := shadowRecord{
+		RequestID: logging.GetRequestID(ctx),
+		CreatedAt: run.started,
+		Handler:   handlerType,
+		Stream:    stream,
+	}
+	if storeDir != "" && json.Valid(rawJSON) {
+		record.Request = json.RawMessage(bytes.Clone(rawJSON))
+	}
+	payload := bytes.Clone(rawJSON)
+	if gjson.GetBytes(payload, "model").Exists() {
+		payload, _ = sjson.SetBytes(payload, "model", rule.TargetModel)
+	}
+	target := rule.TargetModel
+
+	// The mirrored copy must outlive the client request, so it gets its own
+	// context and metadata rather than the handler's.
+	shadowCtx, cancel := context.WithTimeout(logging.WithRequestID(context.Background(), record.RequestID), shadowTimeout)
+	go func() {
+		defer release()
+		defer cancel()
+		record.Shadow = h.executeShadow(shadowCtx, handlerType, target, payload, alt, stream)
+		log.Debugf("shadow traffic: %s mirrored to %s, status %d in %dms", modelName, target, record.Shadow.Status, record.Shadow.LatencyMS)
+		if storeDir == "" {
+			return
+		}
+		select {
+		case <-run.primaryDone:
+			record.Primary = &run.primary
+		case <-shadowCtx.Done():
+		}
+		if err := writeShadowRecord(storeDir, record); err != nil {
+			log.Warnf("shadow traffic: store record: %v", err)
+		}
+	}()
+	return run
+}
+
+// executeShadow runs the mirrored request to completion, draining streams.
+func (h *BaseAPIHandler) executeShadow(ctx context.Context, handlerType, model string, payload []byte, alt string, stream bool) shadowResult {
+	started := time.Now()
+	result := shadowResult{Model: model}
+	finish := func(status int, body []byte, err error) shadowResult {
+		result.Status = status
+		result.Body = string(body)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.LatencyMS = time.Since(started).Milliseconds()
+		return result
+	}
+
+	providers, normalizedModel, errMsg := h.getRequestDetails(model)
+	if errMsg != nil {
+		return finish(errMsg.StatusCode, nil, errMsg.Error)
+	}
+	req := coreexecutor.Request{Model: normalizedModel, Payload: payload}
+	opts := coreexecutor.Options{
+		Stream:          stream,
+		Alt:             alt,
+		OriginalRequest: payload,
+		SourceFormat:    sdktranslator.FromString(handlerType),
+		Metadata:        map[string]any{coreexecutor.RequestedModelMetadataKey: normalizedModel},
+	}
+	if !stream {
+		resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
+		if err != nil {
+			return finish(shadowStatus(err), nil, err)
+		}
+		return finish(http.StatusOK, resp.Payload, nil)
+	}
+	streamResult, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
+	if err != nil {
+		return finish(shadowStatus(err), nil, err)
+	}
+	var body bytes.Buffer
+	for chunk := range streamResult.Chunks {
+		if chunk.Err != nil {
+			return finish(shadowStatus(chunk.Err), body.Bytes(), chunk.Err)
+		}
+		body.Write(chunk.Payload)
+	}
+	return finish(http.StatusOK, body.Bytes(), nil)
+}
+
+func shadowStatus(err error) int {
+	if status := statusFromError(err); status > 0 {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// finishPrimary records the response the client received.
+func (r *shadowRun) finishPrimary(body []byte, errMsg *interfaces.ErrorMessage) {
+	if r == nil {
+		return
+	}
+	r.primaryOnce.Do(func() {
+		r.primary = shadowResult{Model: r.model, Status: http.StatusOK, Body: string(body)}
+		if errMsg != nil {
+			r.primary.Status = errMsg.StatusCode
+			if errMsg.Error != nil {
+				r.primary.Error = errMsg.Error.Error()
+			}
+		}
+		r.primary.LatencyMS = time.Since(r.started).Milliseconds()
+		close(r.primaryDone)
+	})
+}
+
+// teeStream passes a streaming response through to the client while
+// collecting it for finishPrimary.
+func (r *shadowRun) teeStream(ctx context.Context, data <-chan []byte, errs <-chan *interfaces.ErrorMessage) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	if r == nil {
+		return data, errs
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	dataOut := make(chan []byte)
+	errOut := make(chan *interfaces.ErrorMessage, 1)
+	var (
+		wg      sync.WaitGroup
+		body    bytes.Buffer
+		lastErr *interfaces.ErrorMessage
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(dataOut)
+		for chunk := range data {
+			body.Write(chunk)
+			select {
+			case dataOut <- chunk:
+			case <-ctx.Done():
+				for range data {
+				}
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		defer close(errOut)
+		for errMsg := range errs {
+			lastErr = errMsg
+			select {
+			case errOut <- errMsg:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		r.finishPrimary(body.Bytes(), lastErr)
+	}()
+	return dataOut, errOut
+}
+
+func writeShadowRecord(dir string, record shadowRecord) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := record.RequestID
+	if name == "" {
+		name = record.CreatedAt.UTC().Format("20060102T150405.000000000")
+	}
+	tmp, err := os.CreateTemp(dir, ".shadow-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, errWrite := tmp.Write(data)
+	errClose := tmp.Close()
+	if errWrite == nil {
+		errWrite = errClose
+	}
+	if errWrite == nil {
+		errWrite = os.Rename(tmp.Name(), filepath.Join(dir, filepath.Base(name)+".json"))
+	}
+	if errWrite != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return errWrite
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// modelEchoExecutor answers with the model and payload model it was called with.
+type modelEchoExecutor struct {
+	mu     sync.Mutex
+	models []string
+}
+
+func (e *modelEchoExecutor) Identifier() string { return "shadow-test" }
+
+func (e *modelEchoExecutor) reply(req coreexecutor.Request) []byte {
+	e.mu.Lock()
+	e.models = append(e.models, req.Model)
+	e.mu.Unlock()
+	return []byte(`{"served_by":"` + req.Model + `","payload_model":"` + gjson.GetBytes(req.Payload, "model").String() + `"}`)
+}
+
+func (e *modelEchoExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Payload: e.reply(req)}, nil
+}
+
+func (e *modelEchoExecutor) ExecuteStream(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (*coreexecutor.StreamResult, error) {
+	ch := make(chan coreexecutor.StreamChunk, 1)
+	ch <- coreexecutor.StreamChunk{Payload: e.reply(req)}
+	close(ch)
+	return &coreexecutor.StreamResult{Chunks: ch}, nil
+}
+
+func (e *modelEchoExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *modelEchoExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *modelEchoExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newShadowTestHandler(t *testing.T, storeDir string) *BaseAPIHandler {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(&modelEchoExecutor{})
+	auth := &coreauth.Auth{ID: "shadow-auth", Provider: "shadow-test", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "primary-model"}, {ID: "candidate-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{ShadowTraffic: sdkconfig.ShadowTrafficConfig{
+		StoreDir: storeDir,
+		Rules:    []sdkconfig.ShadowTrafficRule{{Model: "primary-*", TargetModel: "candidate-model", Percent: 100}},
+	}}, manager)
+}
+
+func waitShadowRecord(t *testing.T, path string) shadowRecord {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var record shadowRecord
+			if err = json.Unmarshal(data, &record); err != nil {
+				t.Fatalf("decode record: %v", err)
+			}
+			return record
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("shadow record %s not written: %v", path, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShadowTraffic_MirrorsAndStoresBothResponses(t *testing.T) {
+	dir := t.TempDir()
+	h := newShadowTestHandler(t, dir)
+	ctx := logging.WithRequestID(context.Background(), "shadow-req-1")
+
+	out, _, errMsg := h.ExecuteWithAuthManager(ctx, "openai", "primary-model", []byte(`{"model":"primary-model"}`), "")
+	if errMsg != nil {
+		t.Fatalf("ExecuteWithAuthManager: %v", errMsg.Error)
+	}
+	if got := gjson.GetBytes(out, "served_by").String(); got != "primary-model" {
+		t.Fatalf("client served by %q, want primary-model", got)
+	}
+
+	record := waitShadowRecord(t, filepath.Join(dir, "shadow-req-1.json"))
+	if record.Primary == nil || gjson.Get(record.Primary.Body, "served_by").String() != "primary-model" {
+		t.Fatalf("primary = %+v", record.Primary)
+	}
+	if record.Shadow.Status != http.StatusOK || gjson.Get(record.Shadow.Body, "payload_model").String() != "candidate-model" {
+		t.Fatalf("shadow = %+v", record.Shadow)
+	}
+	if gjson.GetBytes(record.Request, "model").String() != "primary-model" {
+		t.Fatalf("request = %s", record.Request)
+	}
+}
+
+func TestShadowTraffic_StreamCollectsPrimary(t *testing.T) {
+	dir := t.TempDir()
+	h := newShadowTestHandler(t, dir)
+	ctx := logging.WithRequestID(context.Background(), "shadow-req-2")
+
+	dataChan, _, errChan := h.ExecuteStreamWithAuthManager(ctx, "openai", "primary-model", []byte(`{"model":"primary-model"}`), "")
+	var got []byte
+	for chunk := range dataChan {
+		got = append(got, chunk...)
+	}
+	for errMsg := range errChan {
+		t.Fatalf("stream error: %v", errMsg.Error)
+	}
+	if gjson.GetBytes(got, "served_by").String() != "primary-model" {
+		t.Fatalf("client received %s", got)
+	}
+
+	record := waitShadowRecord(t, filepath.Join(dir, "shadow-req-2.json"))
+	if !record.Stream || record.Primary == nil || record.Primary.Body != string(got) {
+		t.Fatalf("record = %+v", record)
+	}
+	if gjson.Get(record.Shadow.Body, "served_by").String() != "candidate-model" {
+		t.Fatalf("shadow = %+v", record.Shadow)
+	}
+}
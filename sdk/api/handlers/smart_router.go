@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// promptRoots are the request fields, across the supported client formats,
+// that hold the conversation and system prompt.
+var promptRoots = []string{
+	"messages",
+	"input",
+	"contents",
+	"system",
+	"instructions",
+	"systemInstruction",
+	"system_instruction",
+	"request.contents",
+	"request.systemInstruction",
+}
+
+// promptTextKeys are object keys whose string values are prompt text.
+var promptTextKeys = map[string]bool{"text": true, "content": true, "input": true}
+
+// promptFeatures are the request characteristics smart router rules test.
+type promptFeatures struct {
+	chars    int
+	images   bool
+	tools    bool
+	thinking string
+	text     string
+}
+
+func extractPromptFeatures(modelName string, rawJSON []byte) promptFeatures {
+	var f promptFeatures
+	var text strings.Builder
+	addText := func(s string) {
+		f.chars += utf8.RuneCountInString(s)
+		text.WriteString(strings.ToLower(s))
+		text.WriteByte('\n')
+	}
+	var walk func(value gjson.Result)
+	walk = func(value gjson.Result) {
+		switch {
+		case value.IsArray():
+			value.ForEach(func(_, item gjson.Result) bool {
+				walk(item)
+				return true
+			})
+		case value.IsObject():
+			if isImagePart(value) {
+				f.images = true
+				return
+			}
+			value.ForEach(func(key, item gjson.Result) bool {
+				if item.Type == gjson.String {
+					if promptTextKeys[key.String()] {
+						addText(item.String())
+					}
+					return true
+				}
+				walk(item)
+				return true
+			})
+		}
+	}
+	for _, root := range promptRoots {
+		value := gjson.GetBytes(rawJSON, root)
+		if value.Type == gjson.String {
+			addText(value.String())
+			continue
+		}
+		walk(value)
+	}
+	f.text = text.String()
+	for _, path := range []string{"tools", "functions", "request.tools"} {
+		if len(gjson.GetBytes(rawJSON, path).Array()) > 0 {
+			f.tools = true
+			break
+		}
+	}
+	f.thinking = thinking.RequestedLevel(thinking.ParseSuffix(modelName), rawJSON)
+	return f
+}
+
+// isImagePart recognises image content parts of the OpenAI, Responses,
+// Claude and Gemini formats.
+func isImagePart(part gjson.Result) bool {
+	switch part.Get("type").String() {
+	case "image", "image_url", "input_image":
+		return true
+	}
+	if part.Get("image_url").Exists() {
+		return true
+	}
+	for _, key := range []string{"inlineData", "inline_data", "fileData", "file_data"} {
+		if blob := part.Get(key); blob.Exists() {
+			mime := blob.Get("mimeType").String()
+			if mime == "" {
+				mime = blob.Get("mime_type").String()
+			}
+			return mime == "" || strings.HasPrefix(strings.ToLower(mime), "image/")
+		}
+	}
+	return false
+}
+
+func smartRouterRuleMatches(rule *config.SmartRouterRule, f promptFeatures) bool {
+	if rule.MinPromptChars > 0 && f.chars < rule.MinPromptChars {
+		return false
+	}
+	if rule.MaxPromptChars > 0 && f.chars > rule.MaxPromptChars {
+		return false
+	}
+	if rule.Images != nil && *rule.Images != f.images {
+		return false
+	}
+	if rule.Tools != nil && *rule.Tools != f.tools {
+		return false
+	}
+	if len(rule.Thinking) > 0 {
+		level := f.thinking
+		if level == "" {
+			level = string(thinking.LevelNone)
+		}
+		if !slices.Contains(rule.Thinking, level) {
+			return false
+		}
+	}
+	if len(rule.Keywords) > 0 && !slices.ContainsFunc(rule.Keywords, func(keyword string) bool { return strings.Contains(f.text, keyword) }) {
+		return false
+	}
+	return true
+}
+
+// smartRouterFor returns the smart router modelName names, if any.
+func smartRouterFor(cfg *config.SDKConfig, modelName string) *config.SmartRouter {
+	if cfg == nil || len(cfg.SmartRouters) == 0 {
+		return nil
+	}
+	name := strings.TrimSpace(thinking.ParseSuffix(strings.TrimPrefix(modelName, "models/")).ModelName)
+	for i := range cfg.SmartRouters {
+		if strings.EqualFold(cfg.SmartRouters[i].Name, name) {
+			return &cfg.SmartRouters[i]
+		}
+	}
+	return nil
+}
+
+// resolveSmartRouter returns the model router picks for the request, keeping
+// a thinking suffix the client put on the router name. Rules are tried first,
+// then the classifier, then DefaultModel.
+func (h *BaseAPIHandler) resolveSmartRouter(ctx context.Context, router *config.SmartRouter, modelName string, rawJSON []byte) string {
+	f := extractPromptFeatures(modelName, rawJSON)
+	target := ""
+	for i := range router.Rules {
+		if smartRouterRuleMatches(&router.Rules[i], f) {
+			target = router.Rules[i].Model
+			break
+		}
+	}
+	if target == "" && router.Classifier != nil {
+		target = h.smartRouterClassModel(ctx, router, f)
+	}
+	if target == "" {
+		target = router.DefaultModel
+	}
+	if suffix := thinking.ParseSuffix(modelName); suffix.HasSuffix && !thinking.ParseSuffix(target).HasSuffix {
+		target += "(" + suffix.RawSuffix + ")"
+	}
+	return target
+}
+
+// applySmartRouter replaces a smart router name with the model it resolves
+// to for this request, in both the model name and the payload.
+func (h *BaseAPIHandler) applySmartRouter(ctx context.Context, modelName string, rawJSON []byte) (string, []byte) {
+	if h == nil {
+		return modelName, rawJSON
+	}
+	router := smartRouterFor(h.Cfg, modelName)
+	if router == nil {
+		return modelName, rawJSON
+	}
+	target := h.resolveSmartRouter(ctx, router, modelName, rawJSON)
+	log.Debugf("smart router %s: routing request to %s", router.Name, target)
+	if gjson.GetBytes(rawJSON, "model").Exists() {
+		if updated, err := sjson.SetBytes(rawJSON, "model", target); err == nil {
+			rawJSON = updated
+		}
+	}
+	return target, rawJSON
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// smartRouterClassifierTimeout bounds a classifier model call. A call
+	// that takes longer falls back to the heuristic.
+	smartRouterClassifierTimeout = 10 * time.Second
+
+	// smartRouterClassifierPromptChars is how much of the prompt text the
+	// classifier model is shown.
+	smartRouterClassifierPromptChars = 4000
+
+	// smartRouterDecisionCacheSize bounds the cached classifier answers.
+	smartRouterDecisionCacheSize = 4096
+)
+
+// Classes the local heuristic answers with.
+const (
+	smartRouterClassCode        = "code"
+	smartRouterClassLongContext = "long-context"
+	smartRouterClassChat        = "chat"
+)
+
+// codeMarkers are prompt fragments typical of programming requests. A code
+// fence alone is enough; otherwise the heuristic wants two of them, so a
+// stray word like "class" or "import" in prose does not count as code.
+var codeMarkers = []string{
+	"func ",
+	"def ",
+	"class ",
+	"import ",
+	"package ",
+	"#include",
+	"return ",
+	"const ",
+	"=> ",
+	"();",
+	"traceback (most recent call last)",
+	"exception in thread",
+	"stack trace",
+	"syntax error",
+	"compile error",
+}
+
+// heuristicSmartRouterClass classifies the prompt without a model call.
+func heuristicSmartRouterClass(classifier *config.SmartRouterClassifier, f promptFeatures) string {
+	longContext := classifier.LongContextChars
+	if longContext <= 0 {
+		longContext = config.DefaultSmartRouterLongContextChars
+	}
+	if f.chars >= longContext {
+		return smartRouterClassLongContext
+	}
+	if strings.Contains(f.text, "```") {
+		return smartRouterClassCode
+	}
+	hits := 0
+	for _, marker := range codeMarkers {
+		if strings.Contains(f.text, marker) {
+			if hits++; hits >= 2 {
+				return smartRouterClassCode
+			}
+		}
+	}
+	return smartRouterClassChat
+}
+
+type smartRouterDecision struct {
+	class   string
+	expires time.Time
+}
+
+// smartRouterDecisions caches classifier model answers by prompt, so a
+// client resending the same conversation does not pay for another call.
+var smartRouterDecisions struct {
+	sync.Mutex
+	entries map[[sha256.Size]byte]smartRouterDecision
+}
+
+func smartRouterDecisionKey(router *config.SmartRouter, text string) [sha256.Size]byte {
+	hash := sha256.New()
+	hash.Write([]byte(strings.ToLower(router.Name)))
+	hash.Write([]byte{0})
+	hash.Write([]byte(router.Classifier.Model))
+	for _, class := range router.Classifier.Classes {
+		hash.Write([]byte{0})
+		hash.Write([]byte(class.Name))
+	}
+	hash.Write([]byte{0})
+	hash.Write([]byte(text))
+	var key [sha256.Size]byte
+	hash.Sum(key[:0])
+	return key
+}
+
+func cachedSmartRouterClass(key [sha256.Size]byte, now time.Time) (string, bool) {
+	cache := &smartRouterDecisions
+	cache.Lock()
+	defer cache.Unlock()
+	decision, ok := cache.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !decision.expires.After(now) {
+		delete(cache.entries, key)
+		return "", false
+	}
+	return decision.class, true
+}
+
+func storeSmartRouterClass(key [sha256.Size]byte, class string, now time.Time, ttl time.Duration) {
+	cache := &smartRouterDecisions
+	cache.Lock()
+	defer cache.Unlock()
+	if cache.entries == nil {
+		cache.entries = make(map[[sha256.Size]byte]smartRouterDecision)
+	}
+	if len(cache.entries) >= smartRouterDecisionCacheSize {
+		for k, decision := range cache.entries {
+			if !decision.expires.After(now) {
+				delete(cache.entries, k)
+			}
+		}
+		for k := range cache.entries {
+			if len(cache.entries) < smartRouterDecisionCacheSize {
+				break
+			}
+			delete(cache.entries, k)
+		}
+	}
+	cache.entries[key] = smartRouterDecision{class: class, expires: now.Add(ttl)}
+}
+
+// smartRouterClassifierPayload builds the OpenAI chat request asking the
+// classifier model to name the class of the prompt.
+func smartRouterClassifierPayload(classifier *config.SmartRouterClassifier, f promptFeatures) []byte {
+	var system strings.Builder
+	system.WriteString("Classify the user's request into exactly one of the following classes. Reply with the class name only.\n")
+	for _, class := range classifier.Classes {
+		system.WriteString("- ")
+		system.WriteString(class.Name)
+		if class.Description != "" {
+			system.WriteString(": ")
+			system.WriteString(class.Description)
+		}
+		system.WriteByte('\n')
+	}
+	text := []rune(f.text)
+	if len(text) > smartRouterClassifierPromptChars {
+		text = text[:smartRouterClassifierPromptChars]
+	}
+	user := fmt.Sprintf("The request is %d characters long and begins:\n\n%s", f.chars, string(text))
+
+	payload := []byte(`{"stream":false,"temperature":0,"max_tokens":16}`)
+	payload, _ = sjson.SetBytes(payload, "model", classifier.Model)
+	payload, _ = sjson.SetBytes(payload, "messages.0.role", "system")
+	payload, _ = sjson.SetBytes(payload, "messages.0.content", system.String())
+	payload, _ = sjson.SetBytes(payload, "messages.1.role", "user")
+	payload, _ = sjson.SetBytes(payload, "messages.1.content", user)
+	return payload
+}
+
+// matchSmartRouterClass returns the class the classifier answer names: the
+// answer itself when it is a class name, otherwise the class named earliest
+// in it. It returns "" when the answer names no class.
+func matchSmartRouterClass(classifier *config.SmartRouterClassifier, answer string) string {
+	answer = strings.ToLower(strings.Trim(strings.TrimSpace(answer), "\"'`.*"))
+	best, bestAt := "", -1
+	for _, class := range classifier.Classes {
+		if class.Name == answer {
+			return class.Name
+		}
+		at := strings.Index(answer, class.Name)
+		if at < 0 {
+			continue
+		}
+		if bestAt < 0 || at < bestAt || (at == bestAt && len(class.Name) > len(best)) {
+			best, bestAt = class.Name, at
+		}
+	}
+	return best
+}
+
+// classifyWithModel asks the router's classifier model for the class of the
+// prompt, reusing a cached answer when there is one. The call is billed as a
+// child of the client request: its usage record has the request ID suffixed
+// with ":router-classifier" and the client request as its parent.
+func (h *BaseAPIHandler) classifyWithModel(ctx context.Context, router *config.SmartRouter, f promptFeatures) (string, error) {
+	classifier := router.Classifier
+	ttl := time.Duration(config.DefaultSmartRouterCacheTTLSeconds) * time.Second
+	if classifier.CacheTTLSeconds != 0 {
+		ttl = time.Duration(classifier.CacheTTLSeconds) * time.Second
+	}
+	key := smartRouterDecisionKey(router, f.text)
+	if ttl > 0 {
+		if class, ok := cachedSmartRouterClass(key, time.Now()); ok {
+			return class, nil
+		}
+	}
+	if h.AuthManager == nil {
+		return "", fmt.Errorf("auth manager unavailable")
+	}
+	providers, normalizedModel, errMsg := h.getRequestDetails(classifier.Model)
+	if errMsg != nil {
+		return "", errMsg.Error
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := logging.GetRequestID(ctx)
+	classifierCtx := coreexecutor.WithUsageParent(ctx, parent)
+	if parent != "" {
+		classifierCtx = logging.WithRequestID(classifierCtx, parent+":router-classifier")
+	}
+	classifierCtx, cancel := context.WithTimeout(classifierCtx, smartRouterClassifierTimeout)
+	defer cancel()
+
+	payload := smartRouterClassifierPayload(classifier, f)
+	resp, err := h.AuthManager.Execute(classifierCtx, providers, coreexecutor.Request{Model: normalizedModel, Payload: payload}, coreexecutor.Options{
+		OriginalRequest: payload,
+		SourceFormat:    sdktranslator.FromString("openai"),
+		Metadata:        map[string]any{coreexecutor.RequestedModelMetadataKey: normalizedModel},
+	})
+	if err != nil {
+		return "", err
+	}
+	answer := gjson.GetBytes(resp.Payload, "choices.0.message.content").String()
+	class := matchSmartRouterClass(classifier, answer)
+	if class == "" {
+		return "", fmt.Errorf("answer %q names no class", answer)
+	}
+	if ttl > 0 {
+		storeSmartRouterClass(key, class, time.Now(), ttl)
+	}
+	return class, nil
+}
+
+// smartRouterClassModel classifies the prompt with the router's classifier
+// and returns the model of the class, or "" when the class is not configured.
+// A failed classifier model call falls back to the heuristic.
+func (h *BaseAPIHandler) smartRouterClassModel(ctx context.Context, router *config.SmartRouter, f promptFeatures) string {
+	classifier := router.Classifier
+	var class string
+	if classifier.Model != "" {
+		var err error
+		if class, err = h.classifyWithModel(ctx, router, f); err != nil {
+			log.Debugf("smart router %s: classifier %s failed, using heuristic: %v", router.Name, classifier.Model, err)
+		}
+	}
+	if class == "" {
+		class = heuristicSmartRouterClass(classifier, f)
+	}
+	for _, c := range classifier.Classes {
+		if c.Name == class {
+			return c.Model
+		}
+	}
+	return ""
+}
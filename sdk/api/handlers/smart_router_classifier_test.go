@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// classifierExecutor answers every request with a fixed class and records
+// the request and parent IDs each call was made with.
+type classifierExecutor struct {
+	answer string
+
+	mu      sync.Mutex
+	calls   int
+	ids     []string
+	parents []string
+}
+
+func (e *classifierExecutor) Identifier() string { return "classifier-test" }
+
+func (e *classifierExecutor) Execute(ctx context.Context, _ *coreauth.Auth, _ coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	e.mu.Lock()
+	e.calls++
+	e.ids = append(e.ids, logging.GetRequestID(ctx))
+	e.parents = append(e.parents, coreexecutor.UsageParent(ctx))
+	e.mu.Unlock()
+	return coreexecutor.Response{Payload: []byte(`{"choices":[{"message":{"role":"assistant","content":"` + e.answer + `"}}]}`)}, nil
+}
+
+func (e *classifierExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (*coreexecutor.StreamResult, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "ExecuteStream not implemented"}
+}
+
+func (e *classifierExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *classifierExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *classifierExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func classifierRouterConfig(name, classifierModel string) *sdkconfig.Config {
+	cfg := &sdkconfig.Config{SDKConfig: sdkconfig.SDKConfig{SmartRouters: []sdkconfig.SmartRouter{{
+		Name:         name,
+		DefaultModel: "default-model",
+		Rules:        []sdkconfig.SmartRouterRule{{Model: "keyword-model", Keywords: []string{"translate"}}},
+		Classifier: &sdkconfig.SmartRouterClassifier{
+			Model:            classifierModel,
+			LongContextChars: 500,
+			Classes: []sdkconfig.SmartRouterClass{
+				{Name: "Code", Model: "code-model"},
+				{Name: "long-context", Model: "long-model"},
+				{Name: "chat", Model: "chat-model"},
+				{Name: "", Model: "dropped"},
+			},
+		},
+	}}}}
+	cfg.SanitizeSmartRouters()
+	return cfg
+}
+
+func TestSmartRouterClassifier_Heuristic(t *testing.T) {
+	cfg := classifierRouterConfig("auto-heuristic", "")
+	if classes := cfg.SmartRouters[0].Classifier.Classes; len(classes) != 3 || classes[0].Name != "code" {
+		t.Fatalf("sanitized classes = %+v", classes)
+	}
+	h := NewBaseAPIHandlers(&cfg.SDKConfig, nil)
+
+	cases := []struct {
+		name, prompt, want string
+	}{
+		{"rule wins", "please translate this function: func main() { return }", "keyword-model"},
+		{"code fence", "why does this fail?\n```go\nx := 1\n```", "code-model"},
+		{"code markers", "def handler(event):\n    return event", "code-model"},
+		{"one marker is prose", "what class of ship is this", "chat-model"},
+		{"long", strings.Repeat("lorem ipsum ", 60), "long-model"},
+		{"chat", "hello, how are you?", "chat-model"},
+	}
+	for _, tc := range cases {
+		body := `{"model":"auto-heuristic","messages":[{"role":"user","content":` + strconv.Quote(tc.prompt) + `}]}`
+		if model, _ := h.applySmartRouter(context.Background(), "auto-heuristic", []byte(body)); model != tc.want {
+			t.Errorf("%s: routed to %q, want %q", tc.name, model, tc.want)
+		}
+	}
+}
+
+func TestSmartRouterClassifier_ModelCallIsCachedAndAttributed(t *testing.T) {
+	manager := coreauth.NewManager(nil, nil, nil)
+	executor := &classifierExecutor{answer: "Class: CODE."}
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "classifier-auth", Provider: "classifier-test", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "tiny-classifier"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := classifierRouterConfig("auto-model", "tiny-classifier")
+	h := NewBaseAPIHandlers(&cfg.SDKConfig, manager)
+	body := []byte(`{"model":"auto-model","messages":[{"role":"user","content":"hello there"}]}`)
+
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	if model, _ := h.applySmartRouter(ctx, "auto-model", body); model != "code-model" {
+		t.Fatalf("routed to %q, want code-model", model)
+	}
+	if model, _ := h.applySmartRouter(logging.WithRequestID(context.Background(), "req-2"), "auto-model", body); model != "code-model" {
+		t.Fatalf("cached decision routed to %q, want code-model", model)
+	}
+
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+	if executor.calls != 1 {
+		t.Fatalf("classifier called %d times, want 1", executor.calls)
+	}
+	if executor.ids[0] != "req-1:router-classifier" || executor.parents[0] != "req-1" {
+		t.Fatalf("classifier call id %q parent %q", executor.ids[0], executor.parents[0])
+	}
+}
+
+func TestMatchSmartRouterClass(t *testing.T) {
+	classifier := &sdkconfig.SmartRouterClassifier{Classes: []sdkconfig.SmartRouterClass{{Name: "code"}, {Name: "long-context"}, {Name: "chat"}}}
+	for answer, want := range map[string]string{
+		"chat":                          "chat",
+		" \"Code\". ":                   "code",
+		"long-context, not code":        "long-context",
+		"This looks like chat, or code": "chat",
+		"unsure":                        "",
+	} {
+		if got := matchSmartRouterClass(classifier, answer); got != want {
+			t.Errorf("matchSmartRouterClass(%q) = %q, want %q", answer, got, want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestApplySmartRouter_PicksFirstMatchingRule(t *testing.T) {
+	yes, no := true, false
+	cfg := &sdkconfig.Config{SDKConfig: sdkconfig.SDKConfig{SmartRouters: []sdkconfig.SmartRouter{{
+		Name:         "auto",
+		DefaultModel: "claude-sonnet-4-5",
+		Rules: []sdkconfig.SmartRouterRule{
+			{Model: "gemini-2.5-pro", Images: &yes},
+			{Model: "claude-opus-4-1", Thinking: []string{"high"}},
+			{Model: "gpt-5-codex", Keywords: []string{"stack trace"}},
+			{Model: "gemini-2.5-flash", MaxPromptChars: 50, Tools: &no},
+		},
+	}}}}
+	cfg.SanitizeSmartRouters()
+	h := NewBaseAPIHandlers(&cfg.SDKConfig, nil)
+	long := strings.Repeat("x", 200)
+
+	cases := []struct {
+		name, model, body, want string
+	}{
+		{"openai image", "auto", `{"model":"auto","messages":[{"role":"user","content":[{"type":"text","text":"what is this"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}]}`, "gemini-2.5-pro"},
+		{"gemini inline image", "auto", `{"contents":[{"role":"user","parts":[{"inlineData":{"mimeType":"image/jpeg","data":"AAAA"}}]}]}`, "gemini-2.5-pro"},
+		{"claude effort", "auto", `{"model":"auto","output_config":{"effort":"high"},"messages":[{"role":"user","content":"` + long + `"}]}`, "claude-opus-4-1"},
+		{"suffix level", "auto(high)", `{"model":"auto(high)","messages":[{"role":"user","content":"` + long + `"}]}`, "claude-opus-4-1(high)"},
+		{"keyword", "auto", `{"model":"auto","input":[{"role":"user","content":[{"type":"input_text","text":"Here is the STACK TRACE: ` + long + `"}]}]}`, "gpt-5-codex"},
+		{"short without tools", "auto", `{"model":"auto","system":"be brief","messages":[{"role":"user","content":"hi"}]}`, "gemini-2.5-flash"},
+		{"short with tools", "auto", `{"model":"auto","messages":[{"role":"user","content":"hi"}],"tools":[{"name":"` + long + `"}]}`, "claude-sonnet-4-5"},
+		{"long", "AUTO", `{"model":"AUTO","messages":[{"role":"user","content":"` + long + `"}]}`, "claude-sonnet-4-5"},
+	}
+	for _, tc := range cases {
+		model, body := h.applySmartRouter(context.Background(), tc.model, []byte(tc.body))
+		if model != tc.want {
+			t.Errorf("%s: routed to %q, want %q", tc.name, model, tc.want)
+		}
+		if payloadModel := gjson.GetBytes(body, "model"); payloadModel.Exists() && payloadModel.String() != tc.want {
+			t.Errorf("%s: payload model %q, want %q", tc.name, payloadModel.String(), tc.want)
+		}
+	}
+
+	if model, body := h.applySmartRouter(context.Background(), "gpt-5", []byte(`{"model":"gpt-5"}`)); model != "gpt-5" || string(body) != `{"model":"gpt-5"}` {
+		t.Fatalf("non-router model rewritten to %q, %s", model, body)
+	}
+}
+
+func TestExtractPromptFeatures_CountsTextOnly(t *testing.T) {
+	f := extractPromptFeatures("auto", []byte(`{"system":[{"type":"text","text":"abc"}],"messages":[{"role":"user","content":[{"type":"text","text":"héllo"},{"type":"image","source":{"type":"base64","data":"`+strings.Repeat("A", 1000)+`"}}]}]}`))
+	if f.chars != 8 || !f.images || f.tools {
+		t.Fatalf("features = %+v, want 8 chars, images, no tools", f)
+	}
+	if f.thinking != "" {
+		t.Fatalf("thinking = %q, want unset", f.thinking)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+// StickySessionHeader names the client session whose requests should keep
+// using the credential that served the first one. Bindings live in shared
+// state, so they hold across replicas when the redis backend is enabled.
+const StickySessionHeader = "X-Session-Affinity"
+
+const (
+	stickySessionKeyPrefix = "sticky:"
+	stickySessionTimeout   = 2 * time.Second
+)
+
+// applyStickySession pins the request to the credential bound to its session
+// for model, or records the credential selected for it. Requests that are
+// already pinned, or carry no session header, are left alone.
+func (h *BaseAPIHandler) applyStickySession(ctx context.Context, meta map[string]any, model string) {
+	if h == nil || h.Cfg == nil || h.AuthManager == nil || h.Cfg.SharedState.StickySessionTTL <= 0 {
+		return
+	}
+	if _, pinned := meta[coreexecutor.PinnedAuthMetadataKey]; pinned {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return
+	}
+	session := strings.TrimSpace(ginCtx.GetHeader(StickySessionHeader))
+	if session == "" {
+		return
+	}
+	sum := sha256.Sum256([]byte(session + "\x00" + model))
+	key := stickySessionKeyPrefix + hex.EncodeToString(sum[:16])
+	ttl := time.Duration(h.Cfg.SharedState.StickySessionTTL) * time.Second
+	store := sharedstate.Current()
+
+	lookupCtx, cancel := context.WithTimeout(context.Background(), stickySessionTimeout)
+	defer cancel()
+	bound, found, errGet := store.Get(lookupCtx, key)
+	if errGet != nil {
+		log.Debugf("sticky session: lookup failed: %v", errGet)
+	}
+	if authID := string(bound); found && h.AuthManager.AuthAvailableForModel(authID, model) {
+		meta[coreexecutor.PinnedAuthMetadataKey] = authID
+		if errSet := store.Set(lookupCtx, key, bound, ttl); errSet != nil {
+			log.Debugf("sticky session: refresh failed: %v", errSet)
+		}
+		return
+	}
+
+	previous, _ := meta[coreexecutor.SelectedAuthCallbackMetadataKey].(func(string))
+	meta[coreexecutor.SelectedAuthCallbackMetadataKey] = func(authID string) {
+		if previous != nil {
+			previous(authID)
+		}
+		bindCtx, cancelBind := context.WithTimeout(context.Background(), stickySessionTimeout)
+		defer cancelBind()
+		if errSet := store.Set(bindCtx, key, []byte(authID), ttl); errSet != nil {
+			log.Debugf("sticky session: bind failed: %v", errSet)
+		}
+	}
+}
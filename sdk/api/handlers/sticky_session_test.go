@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func stickySessionContext(session string) context.Context {
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if session != "" {
+		ginCtx.Request.Header.Set(StickySessionHeader, session)
+	}
+	return context.WithValue(context.Background(), "gin", ginCtx)
+}
+
+func TestApplyStickySession_BindsAndPinsSelectedAuth(t *testing.T) {
+	manager := coreauth.NewManager(nil, nil, nil)
+	auth := &coreauth.Auth{ID: "auth-sticky", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	cfg := &sdkconfig.SDKConfig{SharedState: sdkconfig.SharedStateConfig{StickySessionTTL: 60}}
+	h := NewBaseAPIHandlers(cfg, manager)
+
+	first := map[string]any{}
+	h.applyStickySession(stickySessionContext("conversation-1"), first, "gpt-5")
+	if _, pinned := first[coreexecutor.PinnedAuthMetadataKey]; pinned {
+		t.Fatal("unbound session should not be pinned")
+	}
+	callback, ok := first[coreexecutor.SelectedAuthCallbackMetadataKey].(func(string))
+	if !ok {
+		t.Fatal("expected a selected-auth callback to record the binding")
+	}
+	callback(auth.ID)
+
+	second := map[string]any{}
+	h.applyStickySession(stickySessionContext("conversation-1"), second, "gpt-5")
+	if got := second[coreexecutor.PinnedAuthMetadataKey]; got != auth.ID {
+		t.Fatalf("pinned auth = %v, want %s", got, auth.ID)
+	}
+
+	other := map[string]any{}
+	h.applyStickySession(stickySessionContext("conversation-2"), other, "gpt-5")
+	if _, pinned := other[coreexecutor.PinnedAuthMetadataKey]; pinned {
+		t.Fatal("a different session should not inherit the binding")
+	}
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 )
 
 type StreamForwardOptions struct {
@@ -29,6 +31,11 @@ type StreamForwardOptions struct {
 	WriteKeepAlive func()
 }
 
+// ForwardStream copies data to the client until the upstream finishes, fails
+// or the client leaves. Chunks pass through a bounded queue; when the client
+// falls behind, the forwarder waits for room and keep-alives are dropped
+// rather than queued behind data. Queue metrics are exposed through
+// ActiveStreamStats while the stream runs.
 func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, cancel func(error), data <-chan []byte, errs <-chan *interfaces.ErrorMessage, opts StreamForwardOptions) {
 	if c == nil {
 		return
@@ -61,14 +68,32 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 		keepAliveC = keepAlive.C
 	}
 
+	// Chunks and heartbeats are written by the queue's goroutine; terminal
+	// frames are written here only after it has drained and stopped.
+	queue := newStreamQueue(logging.GetGinRequestID(c), c.Request.URL.Path, flusher)
+	defer queue.close(true)
+
 	var terminalErr *interfaces.ErrorMessage
+	clientDone := c.Request.Context().Done()
+	var holdDone <-chan struct{}
 	for {
 		select {
-		case <-c.Request.Context().Done():
+		case <-clientDone:
+			// Resumable or "complete" policy streams keep generating without a client.
+			if hold, keep := clientGone(c); keep {
+				clientDone, keepAliveC, holdDone = nil, nil, hold
+				continue
+			}
+			queue.close(true)
 			cancel(c.Request.Context().Err())
 			return
+		case <-holdDone:
+			queue.close(true)
+			cancel(context.Canceled)
+			return
 		case chunk, ok := <-data:
 			if !ok {
+				queue.close(false)
 				// Prefer surfacing a terminal error if one is pending.
 				if terminalErr == nil {
 					select {
@@ -94,12 +119,12 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 				cancel(nil)
 				return
 			}
-			writeChunk(chunk)
-			flusher.Flush()
+			queue.pushData(len(chunk), func() { writeChunk(chunk) })
 		case errMsg, ok := <-errs:
 			if !ok {
 				continue
 			}
+			queue.close(false)
 			if errMsg != nil {
 				terminalErr = errMsg
 				if opts.WriteTerminalError != nil {
@@ -114,8 +139,7 @@ func (h *BaseAPIHandler) ForwardStream(c *gin.Context, flusher http.Flusher, can
 			cancel(execErr)
 			return
 		case <-keepAliveC:
-			writeKeepAlive()
-			flusher.Flush()
+			queue.pushKeepAlive(writeKeepAlive)
 		}
 	}
 }
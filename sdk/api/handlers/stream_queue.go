@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// streamQueueFrames bounds the frames waiting to be written to one client.
+// A full queue blocks the forwarder, which in turn stops reading upstream.
+const streamQueueFrames = 32
+
+// StreamStats is a snapshot of one client stream's write queue.
+type StreamStats struct {
+	RequestID     string    `json:"request_id,omitempty"`
+	Path          string    `json:"path"`
+	StartedAt     time.Time `json:"started_at"`
+	QueueDepth    int       `json:"queue_depth"`
+	QueueCapacity int       `json:"queue_capacity"`
+	MaxQueueDepth int64     `json:"max_queue_depth"`
+	Frames        int64     `json:"frames"`
+	Bytes         int64     `json:"bytes"`
+	KeepAlives    int64     `json:"keep_alives"`
+	// DroppedKeepAlives counts heartbeats skipped because data was already
+	// queued or being written.
+	DroppedKeepAlives int64 `json:"dropped_keep_alives"`
+	// Backpressure counts data frames that found the queue full, and BlockedMS
+	// the total time the forwarder waited for room.
+	Backpressure int64 `json:"backpressure"`
+	BlockedMS    int64 `json:"blocked_ms"`
+	// SlowestWriteMS is the longest single write and flush to the client.
+	SlowestWriteMS int64 `json:"slowest_write_ms"`
+}
+
+type streamFrame struct {
+	write     func()
+	size      int
+	keepAlive bool
+}
+
+// streamQueue serialises data and keep-alive frames onto one writer
+// goroutine so a slow client delays neither the upstream reader nor, through
+// stale heartbeats, the data behind them.
+type streamQueue struct {
+	requestID string
+	path      string
+	startedAt time.Time
+
+	frames    chan streamFrame
+	done      chan struct{}
+	closeOnce sync.Once
+	// pending counts frames queued or being written.
+	pending   atomic.Int64
+	abandoned atomic.Bool
+
+	maxDepth          atomic.Int64
+	frameCount        atomic.Int64
+	byteCount         atomic.Int64
+	keepAlives        atomic.Int64
+	droppedKeepAlives atomic.Int64
+	backpressure      atomic.Int64
+	blocked           atomic.Int64
+	slowestWrite      atomic.Int64
+}
+
+var activeStreams sync.Map // *streamQueue -> struct{}
+
+func newStreamQueue(requestID, path string, flusher http.Flusher) *streamQueue {
+	q := &streamQueue{
+		requestID: requestID,
+		path:      path,
+		startedAt: time.Now(),
+		frames:    make(chan streamFrame, streamQueueCapacity()),
+		done:      make(chan struct{}),
+	}
+	activeStreams.Store(q, struct{}{})
+	go q.run(flusher)
+	return q
+}
+
+func (q *streamQueue) run(flusher http.Flusher) {
+	defer close(q.done)
+	for frame := range q.frames {
+		if q.abandoned.Load() {
+			q.pending.Add(-1)
+			continue
+		}
+		start := time.Now()
+		frame.write()
+		flusher.Flush()
+		elapsed := time.Since(start).Milliseconds()
+		q.pending.Add(-1)
+
+		if frame.keepAlive {
+			q.keepAlives.Add(1)
+		} else {
+			q.frameCount.Add(1)
+			q.byteCount.Add(int64(frame.size))
+		}
+		for {
+			slowest := q.slowestWrite.Load()
+			if elapsed <= slowest || q.slowestWrite.CompareAndSwap(slowest, elapsed) {
+				break
+			}
+		}
+	}
+}
+
+// pushData queues a data frame, waiting for room when the client is behind.
+func (q *streamQueue) pushData(size int, write func()) {
+	frame := streamFrame{write: write, size: size}
+	q.pending.Add(1)
+	select {
+	case q.frames <- frame:
+	default:
+		q.backpressure.Add(1)
+		start := time.Now()
+		q.frames <- frame
+		q.blocked.Add(time.Since(start).Milliseconds())
+	}
+	q.noteDepth()
+}
+
+// pushKeepAlive queues a heartbeat only when the writer is idle. Pending data
+// already proves the stream is alive, so the heartbeat is dropped instead of
+// being written ahead of, or behind, real frames.
+func (q *streamQueue) pushKeepAlive(write func()) {
+	if !q.pending.CompareAndSwap(0, 1) {
+		q.droppedKeepAlives.Add(1)
+		return
+	}
+	q.frames <- streamFrame{write: write, keepAlive: true}
+}
+
+func (q *streamQueue) noteDepth() {
+	depth := int64(len(q.frames))
+	for {
+		current := q.maxDepth.Load()
+		if depth <= current || q.maxDepth.CompareAndSwap(current, depth) {
+			return
+		}
+	}
+}
+
+// close waits for queued frames to be written; with abandon they are
+// discarded instead. Callers may write to the response again afterwards.
+// Only the first call has any effect.
+func (q *streamQueue) close(abandon bool) {
+	q.closeOnce.Do(func() {
+		if abandon {
+			q.abandoned.Store(true)
+		}
+		close(q.frames)
+		<-q.done
+		activeStreams.Delete(q)
+	})
+}
+
+func (q *streamQueue) stats() StreamStats {
+	return StreamStats{
+		RequestID:         q.requestID,
+		Path:              q.path,
+		StartedAt:         q.startedAt,
+		QueueDepth:        len(q.frames),
+		QueueCapacity:     cap(q.frames),
+		MaxQueueDepth:     q.maxDepth.Load(),
+		Frames:            q.frameCount.Load(),
+		Bytes:             q.byteCount.Load(),
+		KeepAlives:        q.keepAlives.Load(),
+		DroppedKeepAlives: q.droppedKeepAlives.Load(),
+		Backpressure:      q.backpressure.Load(),
+		BlockedMS:         q.blocked.Load(),
+		SlowestWriteMS:    q.slowestWrite.Load(),
+	}
+}
+
+// ActiveStreamStats returns the write queue metrics of every client stream
+// currently being forwarded, oldest first.
+func ActiveStreamStats() []StreamStats {
+	var out []StreamStats
+	activeStreams.Range(func(key, _ any) bool {
+		out = append(out, key.(*streamQueue).stats())
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestForwardStream_DropsKeepAlivesBehindSlowClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	data := make(chan []byte, 3)
+	data <- []byte("data: 1\n\n")
+	data <- []byte("data: 2\n\n")
+	data <- []byte("data: 3\n\n")
+	release := make(chan struct{})
+	interval := time.Millisecond
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		h.ForwardStream(c, c.Writer.(http.Flusher), func(error) {}, data, make(chan *interfaces.ErrorMessage), StreamForwardOptions{
+			KeepAliveInterval: &interval,
+			WriteChunk: func(chunk []byte) {
+				<-release
+				_, _ = c.Writer.Write(chunk)
+			},
+			WriteDone: func() { _, _ = c.Writer.Write([]byte("data: [DONE]\n\n")) },
+		})
+	}()
+
+	var stats StreamStats
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if active := ActiveStreamStats(); len(active) == 1 && active[0].DroppedKeepAlives >= 3 {
+			stats = active[0]
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if stats.Path != "/v1/chat/completions" || stats.QueueCapacity != streamQueueFrames || stats.DroppedKeepAlives < 3 {
+		t.Fatalf("stats while blocked = %+v", stats)
+	}
+	if stats.Frames != 0 || stats.MaxQueueDepth == 0 {
+		t.Fatalf("expected queued but unwritten frames, got %+v", stats)
+	}
+
+	close(release)
+	close(data)
+	<-finished
+	// A heartbeat may follow the drained chunks, but none may precede them.
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "data: 1\n\ndata: 2\n\ndata: 3\n\n") || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("body = %q", body)
+	}
+	if active := ActiveStreamStats(); len(active) != 0 {
+		t.Fatalf("finished stream still listed: %+v", active)
+	}
+}
+
+func TestStreamQueue_CountsBackpressure(t *testing.T) {
+	rec := httptest.NewRecorder()
+	release := make(chan struct{})
+	q := newStreamQueue("req-1", "/v1/messages", rec)
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	for i := 0; i < streamQueueFrames+2; i++ {
+		q.pushData(4, func() {
+			<-release
+			_, _ = rec.WriteString("data")
+		})
+	}
+	q.close(false)
+
+	stats := q.stats()
+	if stats.Frames != streamQueueFrames+2 || stats.Bytes != 4*(streamQueueFrames+2) {
+		t.Fatalf("frames %d, bytes %d", stats.Frames, stats.Bytes)
+	}
+	if stats.Backpressure == 0 || stats.BlockedMS == 0 || stats.MaxQueueDepth < streamQueueFrames/2 {
+		t.Fatalf("backpressure not recorded: %+v", stats)
+	}
+	if rec.Body.Len() != 4*(streamQueueFrames+2) {
+		t.Fatalf("body length = %d", rec.Body.Len())
+	}
+}
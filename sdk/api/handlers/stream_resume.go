@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+const (
+	lastEventIDHeader         = "Last-Event-ID"
+	defaultResumeBufferEvents = 256
+)
+
+// resumeEvent is one buffered SSE event, already carrying its id line.
+type resumeEvent struct {
+	seq  uint64
+	data []byte
+}
+
+// resumableStream buffers the most recent events of one SSE response so a
+// client reconnecting with Last-Event-ID can pick up where it left off.
+type resumableStream struct {
+	id        string
+	principal string
+	path      string
+	capacity  int
+	window    time.Duration
+
+	mu       sync.Mutex
+	events   []resumeEvent
+	nextSeq  uint64
+	finished bool
+	// changed is closed and replaced whenever an event is added or the stream
+	// finishes, waking resumed readers.
+	changed chan struct{}
+	// attached counts connected readers, the original client included.
+	attached  int
+	timer     *time.Timer
+	expired   chan struct{}
+	isExpired bool
+}
+
+type resumeRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*resumableStream
+}
+
+var streamResumes = &resumeRegistry{streams: make(map[string]*resumableStream)}
+
+func (r *resumeRegistry) create(principal, path string, capacity int, window time.Duration) *resumableStream {
+	buf := make([]byte, 12)
+	_, _ = rand.Read(buf)
+	s := &resumableStream{
+		id:        hex.EncodeToString(buf),
+		principal: principal,
+		path:      path,
+		capacity:  capacity,
+		window:    window,
+		changed:   make(chan struct{}),
+		attached:  1,
+		expired:   make(chan struct{}),
+	}
+	r.mu.Lock()
+	r.streams[s.id] = s
+	r.mu.Unlock()
+	return s
+}
+
+func (r *resumeRegistry) lookup(id string) *resumableStream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.streams[id]
+}
+
+func (r *resumeRegistry) remove(id string) {
+	r.mu.Lock()
+	delete(r.streams, id)
+	r.mu.Unlock()
+}
+
+// append assigns the next ID to event and buffers it, returning the bytes to
+// send.
+func (s *resumableStream) append(event []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextSeq++
+	data := make([]byte, 0, len(event)+len(s.id)+24)
+	data = fmt.Appendf(data, "id: %s:%d\n", s.id, s.nextSeq)
+	data = append(data, event...)
+	data = append(data, '\n', '\n')
+	s.events = append(s.events, resumeEvent{seq: s.nextSeq, data: data})
+	if len(s.events) > s.capacity {
+		s.events = s.events[len(s.events)-s.capacity:]
+	}
+	s.broadcastLocked()
+	return data
+}
+
+// finish marks the stream complete. It stays resumable for one window so late
+// reconnects still receive the tail.
+func (s *resumableStream) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return
+	}
+	s.finished = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.broadcastLocked()
+	time.AfterFunc(s.window, func() { streamResumes.remove(s.id) })
+}
+
+func (s *resumableStream) broadcastLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// since returns the buffered events after seq together with the channel that
+// signals the next change. ok is false when events after seq were evicted.
+func (s *resumableStream) since(seq uint64) (events []resumeEvent, changed <-chan struct{}, finished, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) > 0 && s.events[0].seq > seq+1 {
+		return nil, nil, false, false
+	}
+	for _, event := range s.events {
+		if event.seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events, s.changed, s.finished, true
+}
+
+// attach registers a resumed reader. It fails once the stream has expired.
+func (s *resumableStream) attach() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isExpired {
+		return false
+	}
+	s.attached++
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	return true
+}
+
+// detach unregisters a reader. When none is left and generation is still
+// running, the stream expires after one window unless a client reattaches.
+func (s *resumableStream) detach() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attached--
+	if s.attached <= 0 && !s.finished && !s.isExpired && s.timer == nil {
+		s.timer = time.AfterFunc(s.window, s.expire)
+	}
+	return s.expired
+}
+
+func (s *resumableStream) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isExpired || s.attached > 0 {
+		return
+	}
+	s.isExpired = true
+	close(s.expired)
+	streamResumes.remove(s.id)
+}
+
+// resumableWriter assigns IDs to the SSE events written by a handler and
+// records them in a resumableStream. Non-SSE responses pass through untouched.
+type resumableWriter struct {
+	gin.ResponseWriter
+	principal string
+	path      string
+	capacity  int
+	window    time.Duration
+
+	mu          sync.Mutex
+	stream      *resumableStream
+	passthrough bool
+	pending     []byte
+	detached    bool
+	detachOnce  sync.Once
+	expired     <-chan struct{}
+}
+
+func (w *resumableWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	if w.stream == nil {
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+			w.passthrough = true
+			return w.ResponseWriter.Write(p)
+		}
+		w.stream = streamResumes.create(w.principal, w.path, w.capacity, w.window)
+	}
+	w.pending = append(w.pending, p...)
+	for {
+		idx := bytes.Index(w.pending, []byte("\n\n"))
+		if idx < 0 {
+			break
+		}
+		block := w.pending[:idx]
+		w.pending = w.pending[idx+2:]
+		if errWrite := w.emitLocked(block); errWrite != nil {
+			return len(p), errWrite
+		}
+	}
+	return len(p), nil
+}
+
+func (w *resumableWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// emitLocked sends one event block. Comment-only blocks such as keep-alives
+// carry no data worth replaying and are sent without an ID.
+func (w *resumableWriter) emitLocked(block []byte) error {
+	block = bytes.TrimLeft(block, "\r\n")
+	if len(bytes.TrimSpace(block)) == 0 {
+		return nil
+	}
+	data := append(block[:len(block):len(block)], '\n', '\n')
+	if !isSSEComment(block) {
+		data = w.stream.append(block)
+	}
+	if w.detached {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(data)
+	return err
+}
+
+func (w *resumableWriter) Flush() {
+	w.mu.Lock()
+	detached := w.detached
+	w.mu.Unlock()
+	if !detached {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// finish sends any trailing partial event and closes the stream buffer.
+func (w *resumableWriter) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stream == nil {
+		return
+	}
+	if len(w.pending) > 0 {
+		_ = w.emitLocked(w.pending)
+		w.pending = nil
+		if !w.detached {
+			w.ResponseWriter.Flush()
+		}
+	}
+	w.stream.finish()
+}
+
+// detach stops writing to the departed client and reports whether the stream
+// keeps running for a resume. The returned channel closes when no client has
+// reattached within the window.
+func (w *resumableWriter) detach() (<-chan struct{}, bool) {
+	w.detachOnce.Do(func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.stream == nil {
+			return
+		}
+		w.detached = true
+		w.expired = w.stream.detach()
+	})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.expired, w.detached
+}
+
+func isSSEComment(block []byte) bool {
+	for _, line := range bytes.Split(block, []byte("\n")) {
+		if len(line) > 0 && line[0] != ':' {
+			return false
+		}
+	}
+	return true
+}
+
+func resumeSettings(cfg *config.SDKConfig) (time.Duration, int) {
+	if cfg == nil || cfg.Streaming.ResumeWindowSeconds <= 0 {
+		return 0, 0
+	}
+	capacity := cfg.Streaming.ResumeBufferEvents
+	if capacity <= 0 {
+		capacity = defaultResumeBufferEvents
+	}
+	return time.Duration(cfg.Streaming.ResumeWindowSeconds) * time.Second, capacity
+}
+
+// StreamResumeMiddleware makes SSE responses resumable when
+// streaming.resume-window-seconds is set. Every event gets an ID, and a POST
+// carrying Last-Event-ID for a stream of the same API key and path replays the
+// missed events and follows the stream while the upstream is still alive.
+// Otherwise the request is processed as a new one. It must run after
+// authentication.
+func StreamResumeMiddleware(h *BaseAPIHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h == nil || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		window, capacity := resumeSettings(h.Cfg)
+		if window <= 0 {
+			c.Next()
+			return
+		}
+		principal := c.GetString("apiKey")
+		if lastEventID := strings.TrimSpace(c.GetHeader(lastEventIDHeader)); lastEventID != "" {
+			if resumeStream(c, principal, lastEventID) {
+				c.Abort()
+				return
+			}
+		}
+
+		writer := &resumableWriter{
+			ResponseWriter: c.Writer,
+			principal:      principal,
+			path:           c.Request.URL.Path,
+			capacity:       capacity,
+			window:         window,
+		}
+		c.Writer = writer
+		c.Next()
+		writer.finish()
+	}
+}
+
+// resumeStream replays the events after lastEventID and follows the stream
+// until it finishes or the client leaves. It returns false when the stream
+// cannot be resumed.
+func resumeStream(c *gin.Context, principal, lastEventID string) bool {
+	streamID, seqText, found := strings.Cut(lastEventID, ":")
+	if !found {
+		return false
+	}
+	seq, errSeq := strconv.ParseUint(seqText, 10, 64)
+	if errSeq != nil {
+		return false
+	}
+	stream := streamResumes.lookup(streamID)
+	if stream == nil || stream.principal != principal || stream.path != c.Request.URL.Path {
+		return false
+	}
+	events, changed, finished, ok := stream.since(seq)
+	if !ok || !stream.attach() {
+		return false
+	}
+	defer stream.detach()
+
+	flusher, _ := c.Writer.(http.Flusher)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	for {
+		for _, event := range events {
+			if _, errWrite := c.Writer.Write(event.data); errWrite != nil {
+				return true
+			}
+			seq = event.seq
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if finished {
+			return true
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return true
+		case <-changed:
+		}
+		// Falling behind the buffer ends the response; the client can
+		// reconnect and will be served as a new request.
+		if events, changed, finished, ok = stream.since(seq); !ok {
+			return true
+		}
+	}
+}
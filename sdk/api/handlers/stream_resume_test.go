@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+var resumeEventIDPattern = regexp.MustCompile(`id: ([0-9a-f]+:\d+)`)
+
+func newResumeTestRouter(events ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := &sdkconfig.SDKConfig{Streaming: sdkconfig.StreamingConfig{ResumeWindowSeconds: 30}}
+	h := NewBaseAPIHandlers(cfg, nil)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("apiKey", c.GetHeader("X-Test-Key"))
+		c.Next()
+	})
+	router.Use(StreamResumeMiddleware(h))
+	router.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		for _, event := range events {
+			_, _ = c.Writer.WriteString(event)
+			c.Writer.Flush()
+		}
+	})
+	router.POST("/v1/models", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	return router
+}
+
+func postResume(router *gin.Engine, path, key, lastEventID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+	req.Header.Set("X-Test-Key", key)
+	if lastEventID != "" {
+		req.Header.Set(lastEventIDHeader, lastEventID)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStreamResumeMiddleware_AssignsEventIDs(t *testing.T) {
+	router := newResumeTestRouter("data: one\n\n", ": keep-alive\n\n", "data: two\n\n")
+
+	body := postResume(router, "/v1/chat/completions", "k1", "").Body.String()
+	ids := resumeEventIDPattern.FindAllStringSubmatch(body, -1)
+	if len(ids) != 2 {
+		t.Fatalf("expected 2 event ids, got %d in %q", len(ids), body)
+	}
+	if !strings.HasSuffix(ids[0][1], ":1") || !strings.HasSuffix(ids[1][1], ":2") {
+		t.Fatalf("unexpected ids %q, %q", ids[0][1], ids[1][1])
+	}
+	if !strings.Contains(body, ": keep-alive\n\n") {
+		t.Fatalf("keep-alive comment should pass through unchanged: %q", body)
+	}
+}
+
+func TestStreamResumeMiddleware_ReplaysAfterLastEventID(t *testing.T) {
+	router := newResumeTestRouter("data: one\n\n", "data: two\n\n", "data: three\n\n")
+
+	body := postResume(router, "/v1/chat/completions", "k1", "").Body.String()
+	first := resumeEventIDPattern.FindStringSubmatch(body)[1]
+
+	replay := postResume(router, "/v1/chat/completions", "k1", first).Body.String()
+	if strings.Contains(replay, "data: one") {
+		t.Fatalf("replay should skip acknowledged events: %q", replay)
+	}
+	if !strings.Contains(replay, "data: two") || !strings.Contains(replay, "data: three") {
+		t.Fatalf("replay missing events: %q", replay)
+	}
+}
+
+func TestStreamResumeMiddleware_RejectsOtherPrincipal(t *testing.T) {
+	router := newResumeTestRouter("data: one\n\n", "data: two\n\n")
+
+	body := postResume(router, "/v1/chat/completions", "k1", "").Body.String()
+	first := resumeEventIDPattern.FindStringSubmatch(body)[1]
+
+	// A different key starts a fresh stream instead of reading k1's events.
+	other := postResume(router, "/v1/chat/completions", "k2", first).Body.String()
+	if !strings.Contains(other, "data: one") {
+		t.Fatalf("expected a new stream for another key: %q", other)
+	}
+	if strings.Contains(other, "id: "+first+"\n") {
+		t.Fatalf("new stream reused the original stream id: %q", other)
+	}
+}
+
+func TestStreamResumeMiddleware_IgnoresNonSSEResponses(t *testing.T) {
+	router := newResumeTestRouter()
+
+	body := postResume(router, "/v1/models", "k1", "").Body.String()
+	if body != `{"ok":true}` {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
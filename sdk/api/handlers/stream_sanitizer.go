@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// claudeStreamEventFields lists the Claude stream event types and the fields
+// each must carry.
+var claudeStreamEventFields = map[string][]string{
+	"message_start":       {"message"},
+	"message_delta":       {"delta"},
+	"message_stop":        nil,
+	"content_block_start": {"index", "content_block"},
+	"content_block_delta": {"index", "delta"},
+	"content_block_stop":  {"index"},
+	"ping":                nil,
+	"error":               {"error"},
+}
+
+// streamSanitizeMode returns the configured stream sanitizer mode, or "" when
+// the sanitizer is off.
+func streamSanitizeMode(cfg *config.SDKConfig) string {
+	if cfg == nil {
+		return ""
+	}
+	switch mode := strings.ToLower(strings.TrimSpace(cfg.Streaming.Sanitize)); mode {
+	case config.StreamSanitizeRepair, config.StreamSanitizeStrict:
+		return mode
+	default:
+		return ""
+	}
+}
+
+// sseFrame is one event of an SSE chunk. Lines other than event and data,
+// such as id and comments, are kept in order.
+type sseFrame struct {
+	lines    []string
+	eventIdx int
+	dataIdx  []int
+}
+
+func (f *sseFrame) event() string {
+	if f.eventIdx < 0 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(f.lines[f.eventIdx], "event:"))
+}
+
+func (f *sseFrame) data() string {
+	parts := make([]string, 0, len(f.dataIdx))
+	for _, idx := range f.dataIdx {
+		parts = append(parts, strings.TrimPrefix(strings.TrimPrefix(f.lines[idx], "data:"), " "))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// setData replaces the data lines with a single one holding data.
+func (f *sseFrame) setData(data string) {
+	if len(f.dataIdx) == 0 {
+		return
+	}
+	f.lines[f.dataIdx[0]] = "data: " + data
+	for _, idx := range f.dataIdx[1:] {
+		f.lines[idx] = ""
+	}
+	f.dataIdx = f.dataIdx[:1]
+}
+
+func (f *sseFrame) String() string {
+	kept := f.lines[:0:0]
+	for _, line := range f.lines {
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+func parseSSEFrames(chunk []byte) []*sseFrame {
+	var frames []*sseFrame
+	for _, block := range strings.Split(strings.ReplaceAll(string(chunk), "\r\n", "\n"), "\n\n") {
+		if strings.TrimSpace(block) == "" {
+			continue
+		}
+		frame := &sseFrame{eventIdx: -1}
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				frame.eventIdx = len(frame.lines)
+			case strings.HasPrefix(line, "data:"):
+				frame.dataIdx = append(frame.dataIdx, len(frame.lines))
+			}
+			frame.lines = append(frame.lines, line)
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// sanitizeStreamChunk validates one outbound stream chunk in the client's
+// format. In repair mode it returns the chunk with invalid frames fixed or
+// dropped, possibly empty; in strict mode it returns an error describing the
+// first invalid frame. Formats without a validator pass through unchanged.
+func sanitizeStreamChunk(handlerType, mode string, chunk []byte) ([]byte, error) {
+	switch handlerType {
+	case "openai":
+		// Chat completion chunks are bare JSON; the handler adds the data prefix.
+		data := strings.TrimSpace(string(chunk))
+		fixed, err := sanitizeOpenAIChunk(mode, data)
+		if err != nil {
+			return nil, err
+		}
+		if fixed == data {
+			return chunk, nil
+		}
+		return []byte(fixed), nil
+	case "claude", "openai-response":
+		frames := parseSSEFrames(chunk)
+		kept := make([]string, 0, len(frames))
+		changed := false
+		for _, frame := range frames {
+			keep, modified, err := sanitizeSSEFrame(handlerType, mode, frame)
+			if err != nil {
+				return nil, err
+			}
+			changed = changed || modified || !keep
+			if keep {
+				kept = append(kept, frame.String())
+			}
+		}
+		if !changed {
+			return chunk, nil
+		}
+		if len(kept) == 0 {
+			return nil, nil
+		}
+		// Keep the chunk's own frame terminator so the handler's framing still applies.
+		trailer := chunk[len(bytes.TrimRight(chunk, "\r\n")):]
+		return append([]byte(strings.Join(kept, "\n\n")), trailer...), nil
+	default:
+		return chunk, nil
+	}
+}
+
+// repairStreamJSON parses data, repairing it in repair mode.
+func repairStreamJSON(mode, data string) (string, bool, error) {
+	if gjson.Valid(data) {
+		return data, false, nil
+	}
+	if mode == config.StreamSanitizeRepair {
+		if fixed, ok := util.RepairJSON(data); ok {
+			return fixed, true, nil
+		}
+	}
+	return "", false, fmt.Errorf("invalid JSON payload")
+}
+
+func sanitizeOpenAIChunk(mode, data string) (string, error) {
+	if data == "" || data == "[DONE]" {
+		return data, nil
+	}
+	fixed, _, err := repairStreamJSON(mode, data)
+	if err == nil && !gjson.Get(fixed, "choices").IsArray() && !gjson.Get(fixed, "error").Exists() {
+		err = fmt.Errorf("chat completion chunk without choices")
+	}
+	if err != nil {
+		return "", rejectStreamFrame("openai", mode, err)
+	}
+	return fixed, nil
+}
+
+// sanitizeSSEFrame validates a Claude or Responses event. It reports whether
+// to keep the frame and whether it was changed.
+func sanitizeSSEFrame(handlerType, mode string, frame *sseFrame) (keep, modified bool, err error) {
+	if len(frame.dataIdx) == 0 {
+		// Comments and id-only frames carry no payload to check.
+		return true, false, nil
+	}
+	data, repaired, errJSON := repairStreamJSON(mode, frame.data())
+	if errJSON != nil {
+		return false, false, rejectStreamFrame(handlerType, mode, errJSON)
+	}
+	eventType := gjson.Get(data, "type").String()
+	if errType := checkStreamEventType(handlerType, eventType, data); errType != nil {
+		return false, false, rejectStreamFrame(handlerType, mode, errType)
+	}
+	if repaired {
+		frame.setData(data)
+		modified = true
+	}
+	if event := frame.event(); event != eventType {
+		if mode == config.StreamSanitizeStrict {
+			return false, false, fmt.Errorf("invalid %s stream event: event %q does not match payload type %q", handlerType, event, eventType)
+		}
+		if frame.eventIdx >= 0 {
+			frame.lines[frame.eventIdx] = "event: " + eventType
+		} else {
+			frame.lines = append([]string{"event: " + eventType}, frame.lines...)
+			frame.eventIdx = 0
+			for i := range frame.dataIdx {
+				frame.dataIdx[i]++
+			}
+		}
+		modified = true
+	}
+	return true, modified, nil
+}
+
+func checkStreamEventType(handlerType, eventType, data string) error {
+	if eventType == "" {
+		return fmt.Errorf("event payload without type")
+	}
+	if handlerType == "openai-response" {
+		if eventType != "error" && !strings.HasPrefix(eventType, "response.") {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+		return nil
+	}
+	fields, ok := claudeStreamEventFields[eventType]
+	if !ok {
+		return fmt.Errorf("unknown event type %q", eventType)
+	}
+	for _, field := range fields {
+		if !gjson.Get(data, field).Exists() {
+			return fmt.Errorf("%s event without %s", eventType, field)
+		}
+	}
+	return nil
+}
+
+// rejectStreamFrame reports an invalid frame: strict mode fails the stream,
+// repair mode drops the frame.
+func rejectStreamFrame(handlerType, mode string, cause error) error {
+	if mode == config.StreamSanitizeStrict {
+		return fmt.Errorf("invalid %s stream event: %w", handlerType, cause)
+	}
+	log.Debugf("stream sanitizer: dropped invalid %s frame: %v", handlerType, cause)
+	return nil
+}
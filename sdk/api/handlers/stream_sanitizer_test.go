@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestSanitizeStreamChunkClaude(t *testing.T) {
+	valid := []byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n")
+	out, err := sanitizeStreamChunk("claude", sdkconfig.StreamSanitizeRepair, valid)
+	if err != nil || string(out) != string(valid) {
+		t.Fatalf("valid chunk changed: %q, %v", out, err)
+	}
+
+	mismatched := []byte("event: message_delta\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+	out, err = sanitizeStreamChunk("claude", sdkconfig.StreamSanitizeRepair, mismatched)
+	if err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if want := "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"; string(out) != want {
+		t.Fatalf("repaired = %q, want %q", out, want)
+	}
+
+	mixed := []byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{}}\n\nevent: ping\ndata: {\"type\":\"ping\"}\n\n")
+	out, err = sanitizeStreamChunk("claude", sdkconfig.StreamSanitizeRepair, mixed)
+	if err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if want := "event: ping\ndata: {\"type\":\"ping\"}\n\n"; string(out) != want {
+		t.Fatalf("dropped = %q, want %q", out, want)
+	}
+
+	broken := []byte("event: message_stop\ndata: {\"type\":\"message_stop\",}\n\n")
+	out, err = sanitizeStreamChunk("claude", sdkconfig.StreamSanitizeRepair, broken)
+	if err != nil || !strings.Contains(string(out), `data: {"type":"message_stop"}`) {
+		t.Fatalf("json repair = %q, %v", out, err)
+	}
+
+	if _, err = sanitizeStreamChunk("claude", sdkconfig.StreamSanitizeStrict, mismatched); err == nil {
+		t.Fatal("strict mode accepted mismatched event")
+	}
+	if _, err = sanitizeStreamChunk("claude", sdkconfig.StreamSanitizeStrict, broken); err == nil || !strings.Contains(err.Error(), "invalid claude stream event") {
+		t.Fatalf("strict error = %v", err)
+	}
+}
+
+func TestSanitizeStreamChunkOpenAI(t *testing.T) {
+	valid := []byte(`{"choices":[{"delta":{"content":"hi"}}]}`)
+	if out, err := sanitizeStreamChunk("openai", sdkconfig.StreamSanitizeStrict, valid); err != nil || string(out) != string(valid) {
+		t.Fatalf("valid chunk changed: %q, %v", out, err)
+	}
+	out, err := sanitizeStreamChunk("openai", sdkconfig.StreamSanitizeRepair, []byte(`{"object":"chat.completion.chunk"}`))
+	if err != nil || len(out) != 0 {
+		t.Fatalf("chunk without choices kept: %q, %v", out, err)
+	}
+	out, err = sanitizeStreamChunk("openai", sdkconfig.StreamSanitizeRepair, []byte(`{"choices":[{"delta":{"content":"hi"}}`))
+	if err != nil || string(out) != `{"choices":[{"delta":{"content":"hi"}}]}` {
+		t.Fatalf("truncated chunk = %q, %v", out, err)
+	}
+	if _, err = sanitizeStreamChunk("openai", sdkconfig.StreamSanitizeStrict, []byte(`{"choices":`)); err == nil {
+		t.Fatal("strict mode accepted invalid JSON")
+	}
+}
+
+func TestSanitizeStreamChunkResponses(t *testing.T) {
+	chunk := []byte("event: response.created\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"a\"}\n")
+	out, err := sanitizeStreamChunk("openai-response", sdkconfig.StreamSanitizeRepair, chunk)
+	if err != nil || !strings.HasPrefix(string(out), "event: response.output_text.delta\n") || !strings.HasSuffix(string(out), "}\n") {
+		t.Fatalf("repaired = %q, %v", out, err)
+	}
+	out, err = sanitizeStreamChunk("openai-response", sdkconfig.StreamSanitizeRepair, []byte("data: {\"type\":\"bogus\"}\n"))
+	if err != nil || len(out) != 0 {
+		t.Fatalf("unknown event kept: %q, %v", out, err)
+	}
+}
+
+func TestStreamSanitizeMode(t *testing.T) {
+	cfg := &sdkconfig.SDKConfig{}
+	cfg.Streaming.Sanitize = " Strict "
+	if got := streamSanitizeMode(cfg); got != sdkconfig.StreamSanitizeStrict {
+		t.Fatalf("mode = %q", got)
+	}
+	cfg.Streaming.Sanitize = "off"
+	if got := streamSanitizeMode(cfg); got != "" {
+		t.Fatalf("mode = %q", got)
+	}
+}
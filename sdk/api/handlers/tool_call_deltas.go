@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// toolCallCoalesceBytes is how many argument bytes "coalesce" mode holds per
+// call before sending them.
+const toolCallCoalesceBytes = 512
+
+// toolCallDeltasMode returns the configured tool-call delta aggregation mode,
+// or "" when fragments are forwarded as received.
+func toolCallDeltasMode(cfg *config.SDKConfig) string {
+	if cfg == nil {
+		return ""
+	}
+	switch mode := strings.ToLower(strings.TrimSpace(cfg.Streaming.ToolCallDeltas)); mode {
+	case config.ToolCallDeltasCoalesce, config.ToolCallDeltasFinal:
+		return mode
+	default:
+		return ""
+	}
+}
+
+// pendingToolArgs holds the argument fragments of one tool call that have not
+// been sent yet.
+type pendingToolArgs struct {
+	key string
+	// choice and index locate a chat completion tool call.
+	choice, index int64
+	// template is the Claude or Responses delta payload the fragments are
+	// merged back into.
+	template string
+	args     strings.Builder
+}
+
+// toolCallDeltaAggregator merges the tool-call argument fragments of one
+// outbound stream in the client's format. Fragments are held until another
+// event arrives, the call's buffer reaches toolCallCoalesceBytes in coalesce
+// mode, or the stream ends, and are then sent as a single delta, so clients
+// only ever see fragments in the order they were produced. Gemini streams
+// carry whole function calls and pass through unchanged.
+type toolCallDeltaAggregator struct {
+	handlerType string
+	mode        string
+	pending     []*pendingToolArgs
+	// envelope is the last chat completion chunk that carried fragments.
+	envelope []byte
+	// trailer is the frame terminator of the last SSE chunk.
+	trailer []byte
+	// seq renumbers Responses events, which must stay consecutive once
+	// fragments are merged.
+	seq int64
+}
+
+// newToolCallDeltaAggregator returns an aggregator for handlerType, or nil
+// when mode is off or the format needs none.
+func newToolCallDeltaAggregator(handlerType, mode string) *toolCallDeltaAggregator {
+	if mode == "" {
+		return nil
+	}
+	switch handlerType {
+	case "openai", "openai-response", "claude":
+		return &toolCallDeltaAggregator{handlerType: handlerType, mode: mode}
+	default:
+		return nil
+	}
+}
+
+// Process returns the chunks to send in place of chunk, possibly none.
+func (a *toolCallDeltaAggregator) Process(chunk []byte) [][]byte {
+	if a.handlerType == "openai" {
+		return a.processChat(chunk)
+	}
+	return a.processSSE(chunk)
+}
+
+// Flush returns the fragments still held, for the end of the stream.
+func (a *toolCallDeltaAggregator) Flush() [][]byte {
+	if len(a.pending) == 0 {
+		return nil
+	}
+	if a.handlerType == "openai" {
+		return [][]byte{a.flushChat(a.pending)}
+	}
+	frames := a.flushFrames(a.pending)
+	return [][]byte{append([]byte(strings.Join(frames, "\n\n")), a.trailer...)}
+}
+
+func (a *toolCallDeltaAggregator) find(key string) *pendingToolArgs {
+	for _, p := range a.pending {
+		if p.key == key {
+			return p
+		}
+	}
+	return nil
+}
+
+func (a *toolCallDeltaAggregator) hold(key string) *pendingToolArgs {
+	if p := a.find(key); p != nil {
+		return p
+	}
+	p := &pendingToolArgs{key: key}
+	a.pending = append(a.pending, p)
+	return p
+}
+
+// takeFull removes and returns the calls whose buffers reached the coalesce
+// threshold.
+func (a *toolCallDeltaAggregator) takeFull() []*pendingToolArgs {
+	if a.mode != config.ToolCallDeltasCoalesce {
+		return nil
+	}
+	var full []*pendingToolArgs
+	kept := a.pending[:0]
+	for _, p := range a.pending {
+		if p.args.Len() >= toolCallCoalesceBytes {
+			full = append(full, p)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	a.pending = kept
+	return full
+}
+
+func (a *toolCallDeltaAggregator) takeAll() []*pendingToolArgs {
+	all := a.pending
+	a.pending = nil
+	return all
+}
+
+// processChat handles a bare chat completion chunk. Chunks carrying nothing
+// but argument fragments are held; arguments on a call's first chunk are
+// moved into its buffer so each call's arguments leave in as few deltas as
+// possible.
+func (a *toolCallDeltaAggregator) processChat(chunk []byte) [][]byte {
+	data := bytes.TrimSpace(chunk)
+	if !gjson.ValidBytes(data) || !gjson.GetBytes(data, "choices").IsArray() {
+		return a.prependChat(a.takeAll(), chunk)
+	}
+	root := gjson.ParseBytes(data)
+	if isChatFragmentChunk(root) {
+		a.envelope = bytes.Clone(data)
+		root.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+			choice.Get("delta.tool_calls").ForEach(func(_, tc gjson.Result) bool {
+				p := a.hold(chatToolCallKey(choice, tc))
+				p.choice, p.index = choice.Get("index").Int(), tc.Get("index").Int()
+				p.args.WriteString(tc.Get("function.arguments").String())
+				return true
+			})
+			return true
+		})
+		if full := a.takeFull(); len(full) > 0 {
+			return [][]byte{a.flushChat(full)}
+		}
+		return nil
+	}
+
+	out := a.prependChat(a.takeAll(), nil)
+	updated := data
+	root.Get("choices").ForEach(func(ci, choice gjson.Result) bool {
+		if fr := choice.Get("finish_reason"); fr.Exists() && fr.Type != gjson.Null {
+			return true
+		}
+		choice.Get("delta.tool_calls").ForEach(func(ti, tc gjson.Result) bool {
+			args := tc.Get("function.arguments").String()
+			if args == "" {
+				return true
+			}
+			path := "choices." + ci.String() + ".delta.tool_calls." + ti.String() + ".function.arguments"
+			if next, err := sjson.SetBytes(updated, path, ""); err == nil {
+				updated = next
+				p := a.hold(chatToolCallKey(choice, tc))
+				p.choice, p.index = choice.Get("index").Int(), tc.Get("index").Int()
+				p.args.WriteString(args)
+				a.envelope = bytes.Clone(data)
+			}
+			return true
+		})
+		return true
+	})
+	if bytes.Equal(updated, data) {
+		return append(out, chunk)
+	}
+	return append(out, updated)
+}
+
+// isChatFragmentChunk reports whether every tool call delta of the chunk only
+// continues the arguments of a call already announced.
+func isChatFragmentChunk(root gjson.Result) bool {
+	if root.Get("usage").Exists() && root.Get("usage").Type != gjson.Null {
+		return false
+	}
+	choices := root.Get("choices").Array()
+	if len(choices) == 0 {
+		return false
+	}
+	for _, choice := range choices {
+		if fr := choice.Get("finish_reason"); fr.Exists() && fr.Type != gjson.Null {
+			return false
+		}
+		if c := choice.Get("delta.content"); c.Exists() && c.String() != "" {
+			return false
+		}
+		calls := choice.Get("delta.tool_calls").Array()
+		if len(calls) == 0 {
+			return false
+		}
+		for _, tc := range calls {
+			if tc.Get("id").String() != "" || tc.Get("function.name").String() != "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func chatToolCallKey(choice, tc gjson.Result) string {
+	return choice.Get("index").String() + ":" + tc.Get("index").String()
+}
+
+// prependChat returns the merged fragments of pending followed by chunk.
+func (a *toolCallDeltaAggregator) prependChat(pending []*pendingToolArgs, chunk []byte) [][]byte {
+	var out [][]byte
+	if len(pending) > 0 {
+		out = append(out, a.flushChat(pending))
+	}
+	if chunk != nil {
+		out = append(out, chunk)
+	}
+	return out
+}
+
+// flushChat builds one chat completion chunk carrying the arguments of
+// pending, grouped by choice.
+func (a *toolCallDeltaAggregator) flushChat(pending []*pendingToolArgs) []byte {
+	envelope := a.envelope
+	if len(envelope) == 0 {
+		envelope = []byte(`{"object":"chat.completion.chunk"}`)
+	}
+	out, _ := sjson.DeleteBytes(envelope, "usage")
+	out, _ = sjson.SetRawBytes(out, "choices", []byte("[]"))
+	choiceSlot := map[int64]int{}
+	for _, p := range pending {
+		slot, ok := choiceSlot[p.choice]
+		if !ok {
+			slot = len(choiceSlot)
+			choiceSlot[p.choice] = slot
+			choice, _ := sjson.Set(`{"index":0,"delta":{"tool_calls":[]}}`, "index", p.choice)
+			out, _ = sjson.SetRawBytes(out, "choices.-1", []byte(choice))
+		}
+		call, _ := sjson.Set(`{"index":0,"function":{"arguments":""}}`, "index", p.index)
+		call, _ = sjson.Set(call, "function.arguments", p.args.String())
+		out, _ = sjson.SetRawBytes(out, "choices."+strconv.Itoa(slot)+".delta.tool_calls.-1", []byte(call))
+	}
+	return out
+}
+
+// processSSE handles a Claude or Responses chunk of one or more SSE frames.
+func (a *toolCallDeltaAggregator) processSSE(chunk []byte) [][]byte {
+	trailer := chunk[len(bytes.TrimRight(chunk, "\r\n")):]
+	a.trailer = bytes.Clone(trailer)
+	var kept []string
+	changed := false
+	for _, frame := range parseSSEFrames(chunk) {
+		data := frame.data()
+		if len(frame.dataIdx) == 0 || gjson.Get(data, "type").String() == "ping" {
+			// Comments and pings say nothing about the calls being built.
+			kept = append(kept, frame.String())
+			continue
+		}
+		key, fragment, ok := a.sseFragment(data)
+		if !ok {
+			if len(a.pending) > 0 {
+				kept = append(kept, a.flushFrames(a.takeAll())...)
+				changed = true
+			}
+			if a.renumber(frame) {
+				changed = true
+			}
+			kept = append(kept, frame.String())
+			continue
+		}
+		changed = true
+		p := a.hold(key)
+		if p.template == "" {
+			p.template = data
+		}
+		p.args.WriteString(fragment)
+		if full := a.takeFull(); len(full) > 0 {
+			kept = append(kept, a.flushFrames(full)...)
+		}
+	}
+	if !changed {
+		return [][]byte{chunk}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return [][]byte{append([]byte(strings.Join(kept, "\n\n")), trailer...)}
+}
+
+// sseFragment reports whether data is an argument fragment and returns the
+// key of its call and the fragment.
+func (a *toolCallDeltaAggregator) sseFragment(data string) (string, string, bool) {
+	if !gjson.Valid(data) {
+		return "", "", false
+	}
+	root := gjson.Parse(data)
+	switch a.handlerType {
+	case "claude":
+		if root.Get("type").String() == "content_block_delta" && root.Get("delta.type").String() == "input_json_delta" {
+			return root.Get("index").String(), root.Get("delta.partial_json").String(), true
+		}
+	case "openai-response":
+		if root.Get("type").String() == "response.function_call_arguments.delta" {
+			return root.Get("item_id").String() + ":" + root.Get("output_index").String(), root.Get("delta").String(), true
+		}
+	}
+	return "", "", false
+}
+
+// flushFrames rebuilds one delta frame per pending call.
+func (a *toolCallDeltaAggregator) flushFrames(pending []*pendingToolArgs) []string {
+	frames := make([]string, 0, len(pending))
+	for _, p := range pending {
+		var data, event string
+		if a.handlerType == "claude" {
+			event = "content_block_delta"
+			data, _ = sjson.Set(p.template, "delta.partial_json", p.args.String())
+		} else {
+			event = "response.function_call_arguments.delta"
+			data, _ = sjson.Set(p.template, "delta", p.args.String())
+			a.seq++
+			data, _ = sjson.Set(data, "sequence_number", a.seq)
+		}
+		frames = append(frames, "event: "+event+"\ndata: "+data)
+	}
+	return frames
+}
+
+// renumber rewrites the sequence number of a Responses frame, reporting
+// whether it changed.
+func (a *toolCallDeltaAggregator) renumber(frame *sseFrame) bool {
+	if a.handlerType != "openai-response" || len(frame.dataIdx) == 0 {
+		return false
+	}
+	data := frame.data()
+	seq := gjson.Get(data, "sequence_number")
+	if !seq.Exists() {
+		return false
+	}
+	a.seq++
+	if seq.Int() == a.seq {
+		return false
+	}
+	updated, err := sjson.Set(data, "sequence_number", a.seq)
+	if err != nil {
+		return false
+	}
+	frame.setData(updated)
+	return true
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func feedAggregator(a *toolCallDeltaAggregator, chunks ...string) []string {
+	var out []string
+	for _, chunk := range chunks {
+		for _, payload := range a.Process([]byte(chunk)) {
+			out = append(out, string(payload))
+		}
+	}
+	for _, payload := range a.Flush() {
+		out = append(out, string(payload))
+	}
+	return out
+}
+
+func TestToolCallDeltaAggregatorChatFinal(t *testing.T) {
+	a := newToolCallDeltaAggregator("openai", sdkconfig.ToolCallDeltasFinal)
+	out := feedAggregator(a,
+		`{"id":"c","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"ls","arguments":"{\"pa"}}]}}]}`,
+		`{"id":"c","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"th\":"}}]}}]}`,
+		`{"id":"c","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\".\"}"}}]}}]}`,
+		`{"id":"c","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	)
+	if len(out) != 3 {
+		t.Fatalf("chunks = %d: %q", len(out), out)
+	}
+	if got := gjson.Get(out[0], "choices.0.delta.tool_calls.0.function.arguments").String(); got != "" {
+		t.Fatalf("header arguments = %q", got)
+	}
+	if gjson.Get(out[0], "choices.0.delta.tool_calls.0.function.name").String() != "ls" {
+		t.Fatalf("header = %s", out[0])
+	}
+	merged := gjson.Get(out[1], "choices.0.delta.tool_calls.0")
+	if merged.Get("index").Int() != 0 || merged.Get("function.arguments").String() != `{"path":"."}` {
+		t.Fatalf("merged delta = %s", out[1])
+	}
+	if gjson.Get(out[1], "id").String() != "c" {
+		t.Fatalf("merged chunk lost its envelope: %s", out[1])
+	}
+	if gjson.Get(out[2], "choices.0.finish_reason").String() != "tool_calls" {
+		t.Fatalf("last chunk = %s", out[2])
+	}
+}
+
+func TestToolCallDeltaAggregatorChatCoalesce(t *testing.T) {
+	a := newToolCallDeltaAggregator("openai", sdkconfig.ToolCallDeltasCoalesce)
+	fragment := `{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"` + strings.Repeat("x", 200) + `"}}]}}]}`
+	chunks := []string{`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"w","arguments":""}}]}}]}`}
+	for range 5 {
+		chunks = append(chunks, fragment)
+	}
+	out := feedAggregator(a, chunks...)
+	// The header, one delta once 600 bytes are held, and the remaining 400 at the end.
+	if len(out) != 3 {
+		t.Fatalf("chunks = %d: %q", len(out), out)
+	}
+	if got := len(gjson.Get(out[1], "choices.0.delta.tool_calls.0.function.arguments").String()); got != 600 {
+		t.Fatalf("coalesced delta has %d bytes", got)
+	}
+	if got := len(gjson.Get(out[2], "choices.0.delta.tool_calls.0.function.arguments").String()); got != 400 {
+		t.Fatalf("flushed delta has %d bytes", got)
+	}
+}
+
+func TestToolCallDeltaAggregatorClaude(t *testing.T) {
+	a := newToolCallDeltaAggregator("claude", sdkconfig.ToolCallDeltasFinal)
+	out := feedAggregator(a,
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"t\",\"name\":\"ls\",\"input\":{}}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"a\\\":\"}}\n\n",
+		"event: ping\ndata: {\"type\":\"ping\"}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"1}\"}}\n\n",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":1}\n\n",
+	)
+	joined := strings.Join(out, "")
+	if strings.Count(joined, "input_json_delta") != 1 {
+		t.Fatalf("expected one merged delta: %q", joined)
+	}
+	want := "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"a\\\":1}\"}}\n\nevent: content_block_stop"
+	if !strings.Contains(joined, want) {
+		t.Fatalf("merged delta should precede the block stop: %q", joined)
+	}
+}
+
+func TestToolCallDeltaAggregatorResponsesRenumbers(t *testing.T) {
+	a := newToolCallDeltaAggregator("openai-response", sdkconfig.ToolCallDeltasFinal)
+	out := feedAggregator(a,
+		"event: response.output_item.added\ndata: {\"type\":\"response.output_item.added\",\"sequence_number\":1,\"output_index\":0,\"item\":{\"id\":\"fc_1\",\"type\":\"function_call\"}}",
+		"event: response.function_call_arguments.delta\ndata: {\"type\":\"response.function_call_arguments.delta\",\"sequence_number\":2,\"item_id\":\"fc_1\",\"output_index\":0,\"delta\":\"{\\\"a\\\"\"}",
+		"event: response.function_call_arguments.delta\ndata: {\"type\":\"response.function_call_arguments.delta\",\"sequence_number\":3,\"item_id\":\"fc_1\",\"output_index\":0,\"delta\":\":1}\"}",
+		"event: response.function_call_arguments.done\ndata: {\"type\":\"response.function_call_arguments.done\",\"sequence_number\":4,\"item_id\":\"fc_1\",\"output_index\":0,\"arguments\":\"{\\\"a\\\":1}\"}",
+	)
+	var seqs []int64
+	var deltas []string
+	for _, chunk := range out {
+		for _, frame := range parseSSEFrames([]byte(chunk)) {
+			data := gjson.Parse(frame.data())
+			seqs = append(seqs, data.Get("sequence_number").Int())
+			if data.Get("type").String() == "response.function_call_arguments.delta" {
+				deltas = append(deltas, data.Get("delta").String())
+			}
+		}
+	}
+	if len(deltas) != 1 || deltas[0] != `{"a":1}` {
+		t.Fatalf("deltas = %q", deltas)
+	}
+	for i, seq := range seqs {
+		if seq != int64(i+1) {
+			t.Fatalf("sequence numbers = %v", seqs)
+		}
+	}
+}
+
+func TestToolCallDeltaAggregatorDisabled(t *testing.T) {
+	if newToolCallDeltaAggregator("openai", "") != nil || newToolCallDeltaAggregator("gemini", sdkconfig.ToolCallDeltasFinal) != nil {
+		t.Fatal("aggregator should only run when enabled for formats with argument fragments")
+	}
+	cfg := &sdkconfig.SDKConfig{Streaming: sdkconfig.StreamingConfig{ToolCallDeltas: " Final "}}
+	if got := toolCallDeltasMode(cfg); got != sdkconfig.ToolCallDeltasFinal {
+		t.Fatalf("mode = %q", got)
+	}
+}
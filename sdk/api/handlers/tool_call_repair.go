@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// repairToolCallArguments fixes malformed JSON in the tool-call arguments of
+// a response in the client's format when tool-call-json-repair is enabled.
+// Only formats that carry arguments as complete JSON strings are covered:
+// OpenAI chat completions and the Responses API, including the Responses
+// stream events that repeat the finished arguments. Chat completion streams
+// send arguments in fragments and pass through unchanged.
+func (h *BaseAPIHandler) repairToolCallArguments(handlerType string, payload []byte, chunk bool) []byte {
+	if h == nil || h.Cfg == nil || !h.Cfg.ToolCallJSONRepair || len(payload) == 0 {
+		return payload
+	}
+	switch handlerType {
+	case "openai":
+		if chunk {
+			return payload
+		}
+		return repairArgumentPaths(payload, chatToolCallArgumentPaths(payload))
+	case "openai-response":
+		if !chunk {
+			return repairArgumentPaths(payload, responsesArgumentPaths(payload, ""))
+		}
+		return repairResponsesStreamChunk(payload)
+	default:
+		return payload
+	}
+}
+
+func chatToolCallArgumentPaths(payload []byte) []string {
+	var paths []string
+	gjson.GetBytes(payload, "choices").ForEach(func(ci, choice gjson.Result) bool {
+		choice.Get("message.tool_calls").ForEach(func(ti, _ gjson.Result) bool {
+			paths = append(paths, "choices."+ci.String()+".message.tool_calls."+ti.String()+".function.arguments")
+			return true
+		})
+		return true
+	})
+	return paths
+}
+
+// responsesArgumentPaths lists the arguments of function_call items in the
+// output array found under prefix.
+func responsesArgumentPaths(payload []byte, prefix string) []string {
+	var paths []string
+	gjson.GetBytes(payload, prefix+"output").ForEach(func(i, item gjson.Result) bool {
+		if item.Get("type").String() == "function_call" {
+			paths = append(paths, prefix+"output."+i.String()+".arguments")
+		}
+		return true
+	})
+	return paths
+}
+
+func repairArgumentPaths(payload []byte, paths []string) []byte {
+	for _, path := range paths {
+		args := gjson.GetBytes(payload, path)
+		if args.Type != gjson.String {
+			continue
+		}
+		fixed, repaired := util.RepairJSON(args.String())
+		if !repaired {
+			continue
+		}
+		if updated, err := sjson.SetBytes(payload, path, fixed); err == nil {
+			log.Debugf("repaired malformed tool-call arguments at %s", path)
+			payload = updated
+		}
+	}
+	return payload
+}
+
+// repairResponsesStreamChunk rewrites the data lines of Responses stream
+// events that carry complete arguments.
+func repairResponsesStreamChunk(chunk []byte) []byte {
+	if !bytes.Contains(chunk, []byte("arguments")) {
+		return chunk
+	}
+	lines := bytes.Split(chunk, []byte("\n"))
+	changed := false
+	for i, line := range lines {
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(line[5:])
+		var paths []string
+		switch gjson.GetBytes(data, "type").String() {
+		case "response.function_call_arguments.done":
+			paths = []string{"arguments"}
+		case "response.output_item.done":
+			if gjson.GetBytes(data, "item.type").String() == "function_call" {
+				paths = []string{"item.arguments"}
+			}
+		case "response.completed":
+			paths = responsesArgumentPaths(data, "response.")
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		fixed := repairArgumentPaths(bytes.Clone(data), paths)
+		if !bytes.Equal(fixed, data) {
+			lines[i] = append([]byte("data: "), fixed...)
+			changed = true
+		}
+	}
+	if !changed {
+		return chunk
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
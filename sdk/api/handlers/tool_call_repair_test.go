@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestRepairToolCallArguments(t *testing.T) {
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{ToolCallJSONRepair: true}, nil)
+
+	chat := []byte(`{"choices":[{"message":{"tool_calls":[{"function":{"name":"f","arguments":"{\"a\":1,}"}},{"function":{"name":"g","arguments":"{\"b\":[1"}}]}}]}`)
+	out := h.repairToolCallArguments("openai", chat, false)
+	if got := gjson.GetBytes(out, "choices.0.message.tool_calls.0.function.arguments").String(); got != `{"a":1}` {
+		t.Fatalf("chat arguments 0 = %s", got)
+	}
+	if got := gjson.GetBytes(out, "choices.0.message.tool_calls.1.function.arguments").String(); got != `{"b":[1]}` {
+		t.Fatalf("chat arguments 1 = %s", got)
+	}
+
+	responses := []byte(`{"output":[{"type":"message","content":[]},{"type":"function_call","arguments":"{\"path\":\"/x\""}]}`)
+	out = h.repairToolCallArguments("openai-response", responses, false)
+	if got := gjson.GetBytes(out, "output.1.arguments").String(); got != `{"path":"/x"}` {
+		t.Fatalf("responses arguments = %s", got)
+	}
+
+	event := []byte("event: response.function_call_arguments.done\ndata: {\"type\":\"response.function_call_arguments.done\",\"arguments\":\"{\\\"a\\\":1,}\"}\n")
+	out = h.repairToolCallArguments("openai-response", event, true)
+	if !strings.HasPrefix(string(out), "event: response.function_call_arguments.done\n") {
+		t.Fatalf("event line lost: %q", out)
+	}
+	data := strings.TrimPrefix(strings.Split(string(out), "\n")[1], "data: ")
+	if got := gjson.Get(data, "arguments").String(); got != `{"a":1}` {
+		t.Fatalf("stream arguments = %s", got)
+	}
+
+	disabled := NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil)
+	if out = disabled.repairToolCallArguments("openai", chat, false); string(out) != string(chat) {
+		t.Fatalf("repair ran while disabled: %s", out)
+	}
+}
@@ -5,6 +5,7 @@
 package api
 
 import (
+	"net"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -44,3 +45,8 @@ func WithKeepAliveEndpoint(timeout time.Duration, onTimeout func()) ServerOption
 func WithRequestLoggerFactory(factory func(*config.Config, string) logging.RequestLogger) ServerOption {
 	return internalapi.WithRequestLoggerFactory(factory)
 }
+
+// WithListener serves on an existing listener instead of binding host:port from the config.
+func WithListener(listener net.Listener) ServerOption {
+	return internalapi.WithListener(listener)
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+)
+
+func (m *Manager) antigravityAutoTierEnabled() bool {
+	if m == nil {
+		return false
+	}
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	return cfg != nil && cfg.Routing.AntigravityAutoTier
+}
+
+// resolveAntigravityTierPool expands a tierless Antigravity model into its
+// tier models, ordered by preference. The preferred tier follows the requested
+// thinking level; a tier whose quota is currently exhausted on auth is moved
+// behind the other one. Returns nil when requestedModel is not a tier family.
+func (m *Manager) resolveAntigravityTierPool(auth *Auth, requestedModel string, payload []byte) []string {
+	if auth == nil || !strings.EqualFold(strings.TrimSpace(auth.Provider), "antigravity") || !m.antigravityAutoTierEnabled() {
+		return nil
+	}
+	suffix := thinking.ParseSuffix(strings.TrimSpace(requestedModel))
+	family := strings.TrimSpace(suffix.ModelName)
+	if family == "" {
+		return nil
+	}
+	high := registry.AntigravityTierModelID(family, registry.AntigravityTierHigh)
+	low := registry.AntigravityTierModelID(family, registry.AntigravityTierLow)
+	if !antigravityAuthSupportsModel(auth, high) || !antigravityAuthSupportsModel(auth, low) {
+		return nil
+	}
+
+	preferred, fallback := high, low
+	if antigravityTierForLevel(thinking.RequestedLevel(suffix, payload)) == registry.AntigravityTierLow {
+		preferred, fallback = low, high
+	}
+	now := time.Now()
+	if antigravityTierQuotaExhausted(auth, preferred, now) && !antigravityTierQuotaExhausted(auth, fallback, now) {
+		preferred, fallback = fallback, preferred
+	}
+	return []string{
+		preserveResolvedModelSuffix(preferred, suffix),
+		preserveResolvedModelSuffix(fallback, suffix),
+	}
+}
+
+// markAntigravityTierResult mirrors an execution result onto the tier model
+// state when a tierless route was served by a specific tier, so later tier
+// selection can see per-tier quota.
+func (m *Manager) markAntigravityTierResult(ctx context.Context, result Result, routeModel, upstreamModel string) {
+	if m == nil || !m.antigravityAutoTierEnabled() || !strings.EqualFold(strings.TrimSpace(result.Provider), "antigravity") {
+		return
+	}
+	route := strings.TrimSpace(thinking.ParseSuffix(routeModel).ModelName)
+	if idx := strings.LastIndex(route, "/"); idx >= 0 {
+		route = route[idx+1:]
+	}
+	tier := strings.TrimSpace(thinking.ParseSuffix(upstreamModel).ModelName)
+	if route == "" || strings.EqualFold(route, tier) {
+		return
+	}
+	if !strings.EqualFold(tier, registry.AntigravityTierModelID(route, registry.AntigravityTierHigh)) &&
+		!strings.EqualFold(tier, registry.AntigravityTierModelID(route, registry.AntigravityTierLow)) {
+		return
+	}
+	result.Model = tier
+	m.MarkResult(ctx, result)
+}
+
+func antigravityAuthSupportsModel(auth *Auth, modelID string) bool {
+	reg := registry.GetGlobalRegistry()
+	if reg.ClientSupportsModel(auth.ID, modelID) {
+		return true
+	}
+	for _, prefix := range auth.ModelPrefixes() {
+		if reg.ClientSupportsModel(auth.ID, prefix+"/"+modelID) {
+			return true
+		}
+	}
+	return false
+}
+
+func antigravityTierQuotaExhausted(auth *Auth, modelID string, now time.Time) bool {
+	if auth == nil || len(auth.ModelStates) == 0 {
+		return false
+	}
+	state := auth.ModelStates[modelID]
+	if state == nil || !state.Quota.Exceeded {
+		return false
+	}
+	return state.Quota.NextRecoverAt.IsZero() || now.Before(state.Quota.NextRecoverAt)
+}
+
+// antigravityTierForLevel maps a thinking level to a tier. Medium and above,
+// as well as unset or dynamic levels, use the high tier.
+func antigravityTierForLevel(level string) string {
+	switch thinking.ThinkingLevel(strings.ToLower(strings.TrimSpace(level))) {
+	case thinking.LevelNone, thinking.LevelMinimal, thinking.LevelLow:
+		return registry.AntigravityTierLow
+	default:
+		return registry.AntigravityTierHigh
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func newAntigravityTierTestManager(t *testing.T, executor *openAICompatPoolExecutor) (*Manager, *Auth) {
+	t.Helper()
+	cfg := &internalconfig.Config{}
+	cfg.Routing.AntigravityAutoTier = true
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(cfg)
+	m.RegisterExecutor(executor)
+
+	auth := &Auth{ID: "antigravity-tier-" + t.Name(), Provider: "antigravity", Status: StatusActive}
+	if _, err := m.Register(context.Background(), auth); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(auth.ID, "antigravity", []*registry.ModelInfo{
+		{ID: "gemini-3.1-pro"},
+		{ID: "gemini-3.1-pro-high"},
+		{ID: "gemini-3.1-pro-low"},
+	})
+	t.Cleanup(func() {
+		reg.UnregisterClient(auth.ID)
+	})
+	return m, auth
+}
+
+func TestResolveAntigravityTierPool_FollowsThinkingLevel(t *testing.T) {
+	m, auth := newAntigravityTierTestManager(t, &openAICompatPoolExecutor{id: "antigravity"})
+
+	tests := []struct {
+		name    string
+		model   string
+		payload string
+		want    []string
+	}{
+		{name: "default high", model: "gemini-3.1-pro", want: []string{"gemini-3.1-pro-high", "gemini-3.1-pro-low"}},
+		{name: "suffix low", model: "gemini-3.1-pro(low)", want: []string{"gemini-3.1-pro-low(low)", "gemini-3.1-pro-high(low)"}},
+		{name: "budget suffix", model: "gemini-3.1-pro(512)", want: []string{"gemini-3.1-pro-low(512)", "gemini-3.1-pro-high(512)"}},
+		{name: "openai effort", model: "gemini-3.1-pro", payload: `{"reasoning_effort":"minimal"}`, want: []string{"gemini-3.1-pro-low", "gemini-3.1-pro-high"}},
+		{name: "gemini level", model: "gemini-3.1-pro", payload: `{"generationConfig":{"thinkingConfig":{"thinkingLevel":"high"}}}`, want: []string{"gemini-3.1-pro-high", "gemini-3.1-pro-low"}},
+		{name: "claude budget", model: "gemini-3.1-pro", payload: `{"thinking":{"type":"enabled","budget_tokens":1024}}`, want: []string{"gemini-3.1-pro-low", "gemini-3.1-pro-high"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := m.prepareExecutionModels(auth, tt.model, []byte(tt.payload))
+			if len(got) != len(tt.want) || got[0] != tt.want[0] || got[1] != tt.want[1] {
+				t.Fatalf("pool = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := m.prepareExecutionModels(auth, "gemini-3.1-pro-high", nil); len(got) != 1 || got[0] != "gemini-3.1-pro-high" {
+		t.Fatalf("explicit tier pool = %v, want passthrough", got)
+	}
+}
+
+func TestManagerExecute_AntigravityTierFallsBackOnExhaustedQuota(t *testing.T) {
+	quotaErr := &Error{HTTPStatus: http.StatusTooManyRequests, Message: "quota exhausted"}
+	executor := &openAICompatPoolExecutor{
+		id:            "antigravity",
+		executeErrors: map[string]error{"gemini-3.1-pro-high": quotaErr},
+	}
+	m, auth := newAntigravityTierTestManager(t, executor)
+
+	resp, err := m.Execute(context.Background(), []string{"antigravity"}, cliproxyexecutor.Request{Model: "gemini-3.1-pro"}, cliproxyexecutor.Options{})
+	if err != nil {
+		t.Fatalf("execute error = %v", err)
+	}
+	if string(resp.Payload) != "gemini-3.1-pro-low" {
+		t.Fatalf("payload = %q, want gemini-3.1-pro-low", string(resp.Payload))
+	}
+
+	current, ok := m.GetByID(auth.ID)
+	if !ok {
+		t.Fatal("auth not found after execute")
+	}
+	if state := current.ModelStates["gemini-3.1-pro-high"]; state == nil || !state.Quota.Exceeded {
+		t.Fatalf("high tier state = %+v, want quota exceeded", state)
+	}
+
+	// With the high tier exhausted, the next request prefers the low tier directly.
+	if got := m.prepareExecutionModels(current, "gemini-3.1-pro", nil); got[0] != "gemini-3.1-pro-low" {
+		t.Fatalf("pool after quota = %v, want low tier first", got)
+	}
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// claudeCodeExecutor adapts Claude Code requests for providers other than
+// Anthropic, whose translators drop or reject parts of the payload that
+// Claude Code relies on.
+type claudeCodeExecutor struct {
+	ProviderExecutor
+}
+
+func (e *claudeCodeExecutor) Execute(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	req.Payload = normalizeClaudeCodePayload(req.Payload)
+	return e.ProviderExecutor.Execute(ctx, auth, req, opts)
+}
+
+func (e *claudeCodeExecutor) ExecuteStream(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	req.Payload = normalizeClaudeCodePayload(req.Payload)
+	return e.ProviderExecutor.ExecuteStream(ctx, auth, req, opts)
+}
+
+func (e *claudeCodeExecutor) CountTokens(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	req.Payload = normalizeClaudeCodePayload(req.Payload)
+	return e.ProviderExecutor.CountTokens(ctx, auth, req, opts)
+}
+
+// normalizeClaudeCodePayload rewrites a Claude Messages payload so that
+// translators for other providers keep its meaning:
+//   - cache_control markers are removed wherever Claude Code places them;
+//   - images inside tool_result blocks move to the end of the user turn, since
+//     most providers only accept text in tool results;
+//   - thinking blocks interleaved with tool calls move to the start of their
+//     assistant turn, where translators expect reasoning.
+func normalizeClaudeCodePayload(payload []byte) []byte {
+	if len(payload) == 0 || !gjson.ValidBytes(payload) {
+		return payload
+	}
+	out := payload
+	out = deleteCacheControl(out, "system")
+	out = deleteCacheControl(out, "tools")
+	messages := gjson.GetBytes(out, "messages")
+	if !messages.IsArray() {
+		return out
+	}
+	for i, message := range messages.Array() {
+		content := message.Get("content")
+		if !content.IsArray() {
+			continue
+		}
+		blocks := make([]string, 0, len(content.Array()))
+		for _, block := range content.Array() {
+			blocks = append(blocks, stripCacheControl(block))
+		}
+		switch message.Get("role").String() {
+		case "user":
+			blocks = moveToolResultImages(blocks)
+		case "assistant":
+			blocks = hoistThinkingBlocks(blocks)
+		}
+		if updated, errSet := sjson.SetRawBytes(out, "messages."+strconv.Itoa(i)+".content", []byte("["+strings.Join(blocks, ",")+"]")); errSet == nil {
+			out = updated
+		}
+	}
+	return out
+}
+
+// deleteCacheControl removes cache_control from each element of the array at
+// path.
+func deleteCacheControl(payload []byte, path string) []byte {
+	items := gjson.GetBytes(payload, path)
+	if !items.IsArray() {
+		return payload
+	}
+	out := payload
+	for i, item := range items.Array() {
+		if !item.Get("cache_control").Exists() {
+			continue
+		}
+		if updated, errDel := sjson.DeleteBytes(out, path+"."+strconv.Itoa(i)+".cache_control"); errDel == nil {
+			out = updated
+		}
+	}
+	return out
+}
+
+// stripCacheControl returns block without cache_control, including on the
+// blocks nested in a tool_result.
+func stripCacheControl(block gjson.Result) string {
+	raw := block.Raw
+	if block.Get("cache_control").Exists() {
+		raw, _ = sjson.Delete(raw, "cache_control")
+	}
+	nested := gjson.Get(raw, "content")
+	if !nested.IsArray() {
+		return raw
+	}
+	for i, item := range nested.Array() {
+		if item.Get("cache_control").Exists() {
+			raw, _ = sjson.Delete(raw, "content."+strconv.Itoa(i)+".cache_control")
+		}
+	}
+	return raw
+}
+
+func moveToolResultImages(blocks []string) []string {
+	var images []string
+	for i, block := range blocks {
+		if gjson.Get(block, "type").String() != "tool_result" {
+			continue
+		}
+		nested := gjson.Get(block, "content")
+		if !nested.IsArray() {
+			continue
+		}
+		kept := make([]string, 0, len(nested.Array()))
+		moved := 0
+		for _, item := range nested.Array() {
+			if item.Get("type").String() == "image" {
+				images = append(images, item.Raw)
+				moved++
+				continue
+			}
+			kept = append(kept, item.Raw)
+		}
+		if moved == 0 {
+			continue
+		}
+		if len(kept) == 0 {
+			kept = append(kept, `{"type":"text","text":"(image attached below)"}`)
+		}
+		if updated, errSet := sjson.SetRaw(block, "content", "["+strings.Join(kept, ",")+"]"); errSet == nil {
+			blocks[i] = updated
+		}
+	}
+	return append(blocks, images...)
+}
+
+func hoistThinkingBlocks(blocks []string) []string {
+	thinking := make([]string, 0, len(blocks))
+	rest := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		switch gjson.Get(block, "type").String() {
+		case "thinking", "redacted_thinking":
+			thinking = append(thinking, block)
+		default:
+			rest = append(rest, block)
+		}
+	}
+	return append(thinking, rest...)
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestNormalizeClaudeCodePayload(t *testing.T) {
+	payload := []byte(`{
+		"model":"claude-sonnet-4-5",
+		"system":[{"type":"text","text":"sys","cache_control":{"type":"ephemeral"}}],
+		"tools":[{"name":"Read","input_schema":{"type":"object"},"cache_control":{"type":"ephemeral"}}],
+		"messages":[
+			{"role":"user","content":"plain"},
+			{"role":"assistant","content":[
+				{"type":"text","text":"looking"},
+				{"type":"tool_use","id":"t1","name":"Read","input":{}},
+				{"type":"thinking","thinking":"hmm","signature":"s"}
+			]},
+			{"role":"user","content":[
+				{"type":"tool_result","tool_use_id":"t1","content":[
+					{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAA"}},
+					{"type":"text","text":"shot","cache_control":{"type":"ephemeral"}}
+				]},
+				{"type":"tool_result","tool_use_id":"t2","content":[
+					{"type":"image","source":{"type":"base64","media_type":"image/png","data":"BBB"}}
+				]},
+				{"type":"text","text":"next","cache_control":{"type":"ephemeral"}}
+			]}
+		]
+	}`)
+	out := normalizeClaudeCodePayload(payload)
+
+	for _, path := range []string{"system.0.cache_control", "tools.0.cache_control", "messages.2.content.0.content.0.cache_control", "messages.2.content.2.cache_control"} {
+		if gjson.GetBytes(out, path).Exists() {
+			t.Errorf("%s should be removed: %s", path, out)
+		}
+	}
+	if got := gjson.GetBytes(out, "messages.0.content").String(); got != "plain" {
+		t.Errorf("string content changed: %q", got)
+	}
+	if got := gjson.GetBytes(out, "messages.1.content.#.type").String(); got != `["thinking","text","tool_use"]` {
+		t.Errorf("assistant block order = %s", got)
+	}
+
+	user := gjson.GetBytes(out, "messages.2.content")
+	if got := user.Get("#.type").String(); got != `["tool_result","tool_result","text","image","image"]` {
+		t.Fatalf("user block order = %s", got)
+	}
+	if got := user.Get("0.content.#.type").String(); got != `["text"]` {
+		t.Errorf("first tool_result content = %s", got)
+	}
+	if got := user.Get("1.content.0.text").String(); got == "" {
+		t.Errorf("emptied tool_result should keep a text note: %s", user.Raw)
+	}
+	if got := user.Get("3.source.data").String() + user.Get("4.source.data").String(); got != "AAABBB" {
+		t.Errorf("moved images = %s", got)
+	}
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// withClientProfile wraps executor when the request comes from a client whose
+// API key carries a compatibility profile that applies to auth. Other
+// executors are returned unchanged.
+func withClientProfile(executor ProviderExecutor, auth *Auth, opts cliproxyexecutor.Options) ProviderExecutor {
+	if executor == nil || auth == nil {
+		return executor
+	}
+	profile, _ := opts.Metadata[cliproxyexecutor.ClientProfileMetadataKey].(string)
+	switch profile {
+	case config.ClientProfileClaudeCode:
+		if opts.SourceFormat == sdktranslator.FormatClaude && !strings.EqualFold(auth.Provider, "claude") {
+			return &claudeCodeExecutor{ProviderExecutor: executor}
+		}
+	case config.ClientProfileCodexCLI:
+		if opts.SourceFormat == sdktranslator.FormatOpenAIResponse && !strings.EqualFold(auth.Provider, "codex") && opts.Alt == "" {
+			return &codexCLIExecutor{ProviderExecutor: executor}
+		}
+	}
+	return executor
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestWithClientProfile(t *testing.T) {
+	base := &streamStyleExecutor{}
+	opts := cliproxyexecutor.Options{
+		SourceFormat: sdktranslator.FormatClaude,
+		Metadata:     map[string]any{cliproxyexecutor.ClientProfileMetadataKey: config.ClientProfileClaudeCode},
+	}
+	if _, ok := withClientProfile(base, &Auth{Provider: "gemini"}, opts).(*claudeCodeExecutor); !ok {
+		t.Fatal("expected the claude-code profile for a gemini credential")
+	}
+	if got := withClientProfile(base, &Auth{Provider: "claude"}, opts); got != base {
+		t.Fatal("claude credentials should receive the request unchanged")
+	}
+	opts.SourceFormat = sdktranslator.FormatOpenAIResponse
+	if got := withClientProfile(base, &Auth{Provider: "gemini"}, opts); got != base {
+		t.Fatal("the claude-code profile only applies to Claude Messages requests")
+	}
+	opts.Metadata[cliproxyexecutor.ClientProfileMetadataKey] = config.ClientProfileCodexCLI
+	if _, ok := withClientProfile(base, &Auth{Provider: "openai-compatibility"}, opts).(*codexCLIExecutor); !ok {
+		t.Fatal("expected the codex-cli profile for a chat completions credential")
+	}
+	if got := withClientProfile(base, &Auth{Provider: "codex"}, opts); got != base {
+		t.Fatal("codex credentials should receive the request unchanged")
+	}
+	opts.SourceFormat = sdktranslator.FormatClaude
+	opts.Metadata = nil
+	if got := withClientProfile(base, &Auth{Provider: "gemini"}, opts); got != base {
+		t.Fatal("keys without a profile should receive the request unchanged")
+	}
+}
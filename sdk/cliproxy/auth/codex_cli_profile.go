@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	// codexRolloutKeyPrefix prefixes the shared-state keys holding the
+	// transcript behind a response ID.
+	codexRolloutKeyPrefix = "codex-rollout:"
+	// codexTurnKeyPrefix prefixes the shared-state keys holding what is
+	// known of the response behind a transcript, for transcript exports.
+	codexTurnKeyPrefix  = "codex-rollout-turn:"
+	codexRolloutTTL     = time.Hour
+	codexRolloutTimeout = 2 * time.Second
+)
+
+// codexShellTool is the function tool that stands in for Codex CLI's
+// local_shell built-in. Codex CLI runs function calls named "shell" with the
+// same arguments, so responses need no rewriting.
+const codexShellTool = `{"type":"function","name":"shell","description":"Runs a shell command and returns its output.","parameters":{"type":"object","properties":{"command":{"type":"array","items":{"type":"string"},"description":"The command and its arguments."},"workdir":{"type":"string","description":"The working directory to run the command in."},"timeout_ms":{"type":"number","description":"The timeout for the command in milliseconds."}},"required":["command"]}}`
+
+// codexCLIExecutor serves Codex CLI's Responses sessions through providers
+// without a native Responses API. Prior turns referenced by
+// previous_response_id are rebuilt from the transcripts recorded in shared
+// state, and local shell tools and calls become "shell" function tools.
+type codexCLIExecutor struct {
+	ProviderExecutor
+}
+
+func (e *codexCLIExecutor) Execute(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	scope := codexRolloutScope(opts)
+	previousID := strings.TrimSpace(gjson.GetBytes(req.Payload, "previous_response_id").String())
+	var transcript []byte
+	req.Payload, transcript = restoreCodexRollout(ctx, scope, req.Payload)
+	req.Payload = normalizeCodexCLIPayload(req.Payload)
+	resp, err := e.ProviderExecutor.Execute(ctx, auth, req, opts)
+	if err == nil {
+		recordCodexRollout(scope, previousID, transcript, resp.Payload)
+	}
+	return resp, err
+}
+
+func (e *codexCLIExecutor) ExecuteStream(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	scope := codexRolloutScope(opts)
+	previousID := strings.TrimSpace(gjson.GetBytes(req.Payload, "previous_response_id").String())
+	var transcript []byte
+	req.Payload, transcript = restoreCodexRollout(ctx, scope, req.Payload)
+	req.Payload = normalizeCodexCLIPayload(req.Payload)
+	result, err := e.ProviderExecutor.ExecuteStream(ctx, auth, req, opts)
+	if err != nil || result == nil {
+		return result, err
+	}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	upstream := result.Chunks
+	go func() {
+		defer close(out)
+		for chunk := range upstream {
+			if chunk.Err == nil {
+				if completed := codexCompletedResponse(chunk.Payload); completed != nil {
+					recordCodexRollout(scope, previousID, transcript, completed)
+				}
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				discardStreamChunks(upstream)
+				return
+			}
+		}
+	}()
+	return &cliproxyexecutor.StreamResult{Headers: result.Headers, Chunks: out}, nil
+}
+
+func (e *codexCLIExecutor) CountTokens(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	req.Payload, _ = restoreCodexRollout(ctx, codexRolloutScope(opts), req.Payload)
+	req.Payload = normalizeCodexCLIPayload(req.Payload)
+	return e.ProviderExecutor.CountTokens(ctx, auth, req, opts)
+}
+
+// codexRolloutScope keeps the transcripts of different API keys apart.
+func codexRolloutScope(opts cliproxyexecutor.Options) string {
+	scope, _ := opts.Metadata[cliproxyexecutor.ClientScopeMetadataKey].(string)
+	return scope
+}
+
+func codexRolloutKey(scope, responseID string) string {
+	return codexRolloutKeyPrefix + scope + ":" + responseID
+}
+
+func codexTurnKey(scope, responseID string) string {
+	return codexTurnKeyPrefix + scope + ":" + responseID
+}
+
+// restoreCodexRollout replaces previous_response_id with the transcript
+// recorded for it, prepended to the request input. It returns the payload and
+// the full input transcript of this turn.
+func restoreCodexRollout(ctx context.Context, scope string, payload []byte) ([]byte, []byte) {
+	input := codexInputItems(gjson.GetBytes(payload, "input"))
+	previousID := strings.TrimSpace(gjson.GetBytes(payload, "previous_response_id").String())
+	if previousID == "" {
+		return payload, input
+	}
+	lookupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), codexRolloutTimeout)
+	defer cancel()
+	previous, found, errGet := sharedstate.Current().Get(lookupCtx, codexRolloutKey(scope, previousID))
+	if errGet != nil {
+		log.Debugf("codex-cli profile: transcript lookup failed: %v", errGet)
+	}
+	if !found {
+		log.Debugf("codex-cli profile: no transcript for response %s", previousID)
+		return payload, input
+	}
+	transcript := joinJSONArrays(previous, input)
+	out, errSet := sjson.SetRawBytes(payload, "input", transcript)
+	if errSet != nil {
+		return payload, input
+	}
+	out, _ = sjson.DeleteBytes(out, "previous_response_id")
+	return out, transcript
+}
+
+// recordCodexRollout stores the transcript of a completed response, the
+// turn's input followed by the response output, under the response ID, and
+// next to it the turn the response adds to the transcript.
+func recordCodexRollout(scope, previousID string, transcript, response []byte) {
+	responseID := gjson.GetBytes(response, "id").String()
+	output := gjson.GetBytes(response, "output")
+	if responseID == "" || !output.IsArray() {
+		return
+	}
+	value := joinJSONArrays(transcript, []byte(output.Raw))
+	setCtx, cancel := context.WithTimeout(context.Background(), codexRolloutTimeout)
+	defer cancel()
+	if errSet := sharedstate.Current().Set(setCtx, codexRolloutKey(scope, responseID), value, codexRolloutTTL); errSet != nil {
+		log.Debugf("codex-cli profile: transcript store failed: %v", errSet)
+		return
+	}
+	turn := codexRolloutTurn{
+		ResponseID:         responseID,
+		PreviousResponseID: previousID,
+		Model:              gjson.GetBytes(response, "model").String(),
+		CreatedAt:          gjson.GetBytes(response, "created_at").Int(),
+		OutputStart:        len(gjson.ParseBytes(transcript).Array()),
+		OutputCount:        len(output.Array()),
+	}
+	if usage := gjson.GetBytes(response, "usage"); usage.IsObject() {
+		turn.Usage = json.RawMessage(usage.Raw)
+	}
+	data, errMarshal := json.Marshal(turn)
+	if errMarshal != nil {
+		return
+	}
+	if errSet := sharedstate.Current().Set(setCtx, codexTurnKey(scope, responseID), data, codexRolloutTTL); errSet != nil {
+		log.Debugf("codex-cli profile: transcript turn store failed: %v", errSet)
+	}
+}
+
+// codexCompletedResponse returns the response of a response.completed event
+// in a Responses stream chunk.
+func codexCompletedResponse(chunk []byte) []byte {
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if gjson.GetBytes(data, "type").String() != "response.completed" {
+			continue
+		}
+		if response := gjson.GetBytes(data, "response"); response.IsObject() {
+			return []byte(response.Raw)
+		}
+	}
+	return nil
+}
+
+// codexInputItems returns input as a JSON array; string input becomes a user
+// message.
+func codexInputItems(input gjson.Result) []byte {
+	switch {
+	case input.IsArray():
+		return []byte(input.Raw)
+	case input.Type == gjson.String:
+		item, _ := sjson.Set(`{"type":"message","role":"user"}`, "content", input.String())
+		return []byte("[" + item + "]")
+	default:
+		return []byte("[]")
+	}
+}
+
+func joinJSONArrays(arrays ...[]byte) []byte {
+	var items []string
+	for _, array := range arrays {
+		for _, item := range gjson.ParseBytes(array).Array() {
+			items = append(items, item.Raw)
+		}
+	}
+	return []byte("[" + strings.Join(items, ",") + "]")
+}
+
+// normalizeCodexCLIPayload turns Codex CLI's local shell tool and the
+// local_shell_call items of prior turns into the "shell" function tool and
+// function calls, which translators for other providers understand.
+func normalizeCodexCLIPayload(payload []byte) []byte {
+	if len(payload) == 0 || !gjson.ValidBytes(payload) {
+		return payload
+	}
+	out := payload
+	if tools := gjson.GetBytes(out, "tools"); tools.IsArray() {
+		hasShell := false
+		items := make([]string, 0, len(tools.Array()))
+		for _, tool := range tools.Array() {
+			if tool.Get("type").String() == "function" && tool.Get("name").String() == "shell" {
+				hasShell = true
+			}
+		}
+		for _, tool := range tools.Array() {
+			if tool.Get("type").String() != "local_shell" {
+				items = append(items, tool.Raw)
+				continue
+			}
+			if !hasShell {
+				items = append(items, codexShellTool)
+				hasShell = true
+			}
+		}
+		if updated, errSet := sjson.SetRawBytes(out, "tools", []byte("["+strings.Join(items, ",")+"]")); errSet == nil {
+			out = updated
+		}
+	}
+	if input := gjson.GetBytes(out, "input"); input.IsArray() {
+		items := make([]string, 0, len(input.Array()))
+		for _, item := range input.Array() {
+			switch item.Get("type").String() {
+			case "local_shell_call":
+				items = append(items, codexShellFunctionCall(item))
+			case "local_shell_call_output":
+				output, _ := sjson.Set(`{"type":"function_call_output"}`, "call_id", codexCallID(item))
+				output, _ = sjson.Set(output, "output", item.Get("output").String())
+				items = append(items, output)
+			default:
+				items = append(items, item.Raw)
+			}
+		}
+		if updated, errSet := sjson.SetRawBytes(out, "input", []byte("["+strings.Join(items, ",")+"]")); errSet == nil {
+			out = updated
+		}
+	}
+	return out
+}
+
+func codexShellFunctionCall(item gjson.Result) string {
+	action := item.Get("action")
+	args := map[string]any{"command": action.Get("command").Value()}
+	if workdir := action.Get("working_directory").String(); workdir != "" {
+		args["workdir"] = workdir
+	}
+	if timeout := action.Get("timeout_ms"); timeout.Exists() && timeout.Type == gjson.Number {
+		args["timeout_ms"] = timeout.Int()
+	}
+	arguments, _ := json.Marshal(args)
+	call, _ := sjson.Set(`{"type":"function_call","name":"shell"}`, "call_id", codexCallID(item))
+	call, _ = sjson.Set(call, "arguments", string(arguments))
+	return call
+}
+
+func codexCallID(item gjson.Result) string {
+	if callID := item.Get("call_id").String(); callID != "" {
+		return callID
+	}
+	return item.Get("id").String()
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+// rolloutExecutor records the payloads it receives and answers with one
+// assistant message per call.
+type rolloutExecutor struct {
+	streamStyleExecutor
+	payloads [][]byte
+	calls    int
+}
+
+func (e *rolloutExecutor) response() []byte {
+	e.calls++
+	if e.calls == 1 {
+		return []byte(`{"id":"resp_a","object":"response","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"first"}]}]}`)
+	}
+	return []byte(`{"id":"resp_b","object":"response","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"second"}]}]}`)
+}
+
+func (e *rolloutExecutor) Execute(_ context.Context, _ *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.payloads = append(e.payloads, req.Payload)
+	return cliproxyexecutor.Response{Payload: e.response()}, nil
+}
+
+func (e *rolloutExecutor) ExecuteStream(_ context.Context, _ *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	e.payloads = append(e.payloads, req.Payload)
+	ch := make(chan cliproxyexecutor.StreamChunk, 2)
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte("event: response.created\ndata: {\"type\":\"response.created\"}")}
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte("event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":" + string(e.response()) + "}")}
+	close(ch)
+	return &cliproxyexecutor.StreamResult{Chunks: ch}, nil
+}
+
+func TestCodexCLIExecutor_RebuildsPreviousTurns(t *testing.T) {
+	upstream := &rolloutExecutor{}
+	executor := &codexCLIExecutor{ProviderExecutor: upstream}
+	scope := uuid.NewString()
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.ClientScopeMetadataKey: scope}}
+	ctx := context.Background()
+
+	result, err := executor.ExecuteStream(ctx, nil, cliproxyexecutor.Request{Payload: []byte(`{"model":"m","input":"hello"}`)}, opts)
+	if err != nil {
+		t.Fatalf("first turn: %v", err)
+	}
+	for range result.Chunks {
+	}
+
+	second := []byte(`{"model":"m","previous_response_id":"resp_a","input":[{"type":"message","role":"user","content":[{"type":"input_text","text":"again"}]}]}`)
+	if _, err = executor.Execute(ctx, nil, cliproxyexecutor.Request{Payload: second}, opts); err != nil {
+		t.Fatalf("second turn: %v", err)
+	}
+	sent := upstream.payloads[1]
+	if gjson.GetBytes(sent, "previous_response_id").Exists() {
+		t.Fatalf("previous_response_id should be resolved: %s", sent)
+	}
+	if got := gjson.GetBytes(sent, "input.#.role").String(); got != `["user","assistant","user"]` {
+		t.Fatalf("rebuilt input roles = %s (%s)", got, sent)
+	}
+	if got := gjson.GetBytes(sent, "input.0.content").String(); got != "hello" {
+		t.Fatalf("first input = %q", got)
+	}
+
+	third := []byte(`{"model":"m","previous_response_id":"resp_b","input":[]}`)
+	if _, err = executor.Execute(ctx, nil, cliproxyexecutor.Request{Payload: third}, opts); err != nil {
+		t.Fatalf("third turn: %v", err)
+	}
+	if got := gjson.GetBytes(upstream.payloads[2], "input.#").Int(); got != 4 {
+		t.Fatalf("third turn input has %d items: %s", got, upstream.payloads[2])
+	}
+
+	other := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.ClientScopeMetadataKey: uuid.NewString()}}
+	if _, err = executor.Execute(ctx, nil, cliproxyexecutor.Request{Payload: third}, other); err != nil {
+		t.Fatalf("other key: %v", err)
+	}
+	if got := gjson.GetBytes(upstream.payloads[3], "input.#").Int(); got != 0 {
+		t.Fatalf("another key must not see the transcript: %s", upstream.payloads[3])
+	}
+}
+
+func TestNormalizeCodexCLIPayload(t *testing.T) {
+	payload := []byte(`{
+		"tools":[{"type":"local_shell"},{"type":"function","name":"apply_patch","parameters":{}}],
+		"input":[
+			{"type":"local_shell_call","id":"ls_1","call_id":"call_1","status":"completed","action":{"type":"exec","command":["ls","-la"],"working_directory":"/tmp","timeout_ms":5000}},
+			{"type":"local_shell_call_output","call_id":"call_1","output":"total 0"},
+			{"type":"reasoning","summary":[{"type":"summary_text","text":"thought"}]}
+		]
+	}`)
+	out := normalizeCodexCLIPayload(payload)
+
+	if got := gjson.GetBytes(out, "tools.#.name").String(); got != `["shell","apply_patch"]` {
+		t.Fatalf("tools = %s", gjson.GetBytes(out, "tools").Raw)
+	}
+	call := gjson.GetBytes(out, "input.0")
+	if call.Get("type").String() != "function_call" || call.Get("name").String() != "shell" || call.Get("call_id").String() != "call_1" {
+		t.Fatalf("shell call = %s", call.Raw)
+	}
+	args := gjson.Parse(call.Get("arguments").String())
+	if args.Get("command.1").String() != "-la" || args.Get("workdir").String() != "/tmp" || args.Get("timeout_ms").Int() != 5000 {
+		t.Fatalf("shell arguments = %s", args.Raw)
+	}
+	if got := gjson.GetBytes(out, "input.1").Raw; got != `{"type":"function_call_output","call_id":"call_1","output":"total 0"}` {
+		t.Fatalf("shell output = %s", got)
+	}
+	if gjson.GetBytes(out, "input.2.type").String() != "reasoning" {
+		t.Fatalf("other items should be kept: %s", out)
+	}
+
+	withShell := normalizeCodexCLIPayload([]byte(`{"tools":[{"type":"function","name":"shell"},{"type":"local_shell"}]}`))
+	if got := gjson.GetBytes(withShell, "tools.#").Int(); got != 1 {
+		t.Fatalf("an existing shell function should not be duplicated: %s", withShell)
+	}
+}
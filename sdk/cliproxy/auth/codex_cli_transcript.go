@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+	"github.com/tidwall/gjson"
+)
+
+// codexRolloutTurn is what is recorded of the response behind a transcript:
+// its output is the OutputCount items of the transcript from OutputStart.
+type codexRolloutTurn struct {
+	ResponseID         string          `json:"response_id"`
+	PreviousResponseID string          `json:"previous_response_id,omitempty"`
+	Model              string          `json:"model,omitempty"`
+	CreatedAt          int64           `json:"created_at,omitempty"`
+	Usage              json.RawMessage `json:"usage,omitempty"`
+	OutputStart        int             `json:"output_start"`
+	OutputCount        int             `json:"output_count"`
+}
+
+// CodexTranscript is a conversation recorded by the codex-cli client profile,
+// up to and including one response, split into the turns that produced it.
+type CodexTranscript struct {
+	ResponseID string                `json:"response_id"`
+	Turns      []CodexTranscriptTurn `json:"turns"`
+	Usage      CodexTranscriptUsage  `json:"usage"`
+}
+
+// CodexTranscriptTurn is one request of a transcript: the input items it
+// added and the output of its response. Earlier turns whose records expired
+// are folded into the input of the first turn known.
+type CodexTranscriptTurn struct {
+	ResponseID         string            `json:"response_id"`
+	PreviousResponseID string            `json:"previous_response_id,omitempty"`
+	Model              string            `json:"model,omitempty"`
+	CreatedAt          int64             `json:"created_at,omitempty"`
+	Usage              json.RawMessage   `json:"usage,omitempty"`
+	Input              []json.RawMessage `json:"input"`
+	Output             []json.RawMessage `json:"output"`
+}
+
+// CodexTranscriptUsage totals the token usage reported by the turns.
+type CodexTranscriptUsage struct {
+	InputTokens     int64 `json:"input_tokens"`
+	OutputTokens    int64 `json:"output_tokens"`
+	ReasoningTokens int64 `json:"reasoning_tokens,omitempty"`
+	TotalTokens     int64 `json:"total_tokens"`
+}
+
+// LoadCodexTranscript returns the conversation ending with responseID that
+// the codex-cli profile recorded for the client key scope, a
+// ClientScope value. It reports false when no transcript is recorded, or it
+// expired.
+func LoadCodexTranscript(ctx context.Context, scope, responseID string) (*CodexTranscript, bool, error) {
+	responseID = strings.TrimSpace(responseID)
+	if responseID == "" {
+		return nil, false, nil
+	}
+	store := sharedstate.Current()
+	raw, found, err := store.Get(ctx, codexRolloutKey(scope, responseID))
+	if err != nil || !found {
+		return nil, false, err
+	}
+	var items []json.RawMessage
+	if err = json.Unmarshal(raw, &items); err != nil {
+		return nil, false, fmt.Errorf("decode transcript: %w", err)
+	}
+
+	// Walk back through the recorded turns while their offsets still line up
+	// with this transcript; a turn whose predecessor had expired restarted it.
+	var turns []codexRolloutTurn
+	seen := make(map[string]bool)
+	limit := len(items)
+	for id := responseID; id != "" && !seen[id]; {
+		seen[id] = true
+		data, ok, errGet := store.Get(ctx, codexTurnKey(scope, id))
+		if errGet != nil {
+			return nil, false, errGet
+		}
+		if !ok {
+			break
+		}
+		var turn codexRolloutTurn
+		if json.Unmarshal(data, &turn) != nil || turn.OutputStart < 0 || turn.OutputStart+turn.OutputCount > limit {
+			break
+		}
+		turns = append(turns, turn)
+		limit = turn.OutputStart
+		id = turn.PreviousResponseID
+	}
+
+	transcript := &CodexTranscript{ResponseID: responseID}
+	if len(turns) == 0 {
+		transcript.Turns = []CodexTranscriptTurn{{ResponseID: responseID, Input: items, Output: []json.RawMessage{}}}
+		return transcript, true, nil
+	}
+	cursor := 0
+	for i := len(turns) - 1; i >= 0; i-- {
+		turn := turns[i]
+		end := turn.OutputStart + turn.OutputCount
+		transcript.Turns = append(transcript.Turns, CodexTranscriptTurn{
+			ResponseID:         turn.ResponseID,
+			PreviousResponseID: turn.PreviousResponseID,
+			Model:              turn.Model,
+			CreatedAt:          turn.CreatedAt,
+			Usage:              turn.Usage,
+			Input:              append([]json.RawMessage{}, items[cursor:turn.OutputStart]...),
+			Output:             append([]json.RawMessage{}, items[turn.OutputStart:end]...),
+		})
+		cursor = end
+		usage := gjson.ParseBytes(turn.Usage)
+		transcript.Usage.InputTokens += usage.Get("input_tokens").Int()
+		transcript.Usage.OutputTokens += usage.Get("output_tokens").Int()
+		transcript.Usage.ReasoningTokens += usage.Get("output_tokens_details.reasoning_tokens").Int()
+		transcript.Usage.TotalTokens += usage.Get("total_tokens").Int()
+	}
+	return transcript, true, nil
+}
+
+// Markdown renders the transcript for reading: messages, reasoning
+// summaries, tool calls with their arguments and outputs, and the usage of
+// every turn.
+func (t *CodexTranscript) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Conversation %s\n", t.ResponseID)
+	for i, turn := range t.Turns {
+		heading := fmt.Sprintf("Turn %d · %s", i+1, turn.ResponseID)
+		if turn.Model != "" {
+			heading += " · " + turn.Model
+		}
+		if turn.CreatedAt > 0 {
+			heading += " · " + time.Unix(turn.CreatedAt, 0).UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(&b, "\n## %s\n", heading)
+		for _, item := range turn.Input {
+			writeTranscriptItem(&b, gjson.ParseBytes(item))
+		}
+		for _, item := range turn.Output {
+			writeTranscriptItem(&b, gjson.ParseBytes(item))
+		}
+		if usage := gjson.ParseBytes(turn.Usage); usage.IsObject() {
+			fmt.Fprintf(&b, "\n_Usage: %s_\n", transcriptUsageLine(usage.Get("input_tokens").Int(), usage.Get("output_tokens").Int(),
+				usage.Get("output_tokens_details.reasoning_tokens").Int(), usage.Get("total_tokens").Int()))
+		}
+	}
+	u := t.Usage
+	if u.TotalTokens > 0 || u.InputTokens > 0 || u.OutputTokens > 0 {
+		fmt.Fprintf(&b, "\n---\n\n**Total usage:** %s\n", transcriptUsageLine(u.InputTokens, u.OutputTokens, u.ReasoningTokens, u.TotalTokens))
+	}
+	return b.String()
+}
+
+func transcriptUsageLine(input, output, reasoning, total int64) string {
+	line := fmt.Sprintf("%d input · %d output", input, output)
+	if reasoning > 0 {
+		line += fmt.Sprintf(" (%d reasoning)", reasoning)
+	}
+	return line + fmt.Sprintf(" · %d total tokens", total)
+}
+
+// writeTranscriptItem renders one Responses input or output item.
+func writeTranscriptItem(b *strings.Builder, item gjson.Result) {
+	switch kind := item.Get("type").String(); kind {
+	case "message", "":
+		role := item.Get("role").String()
+		if role == "" {
+			role = "user"
+		}
+		fmt.Fprintf(b, "\n**%s**\n\n%s\n", strings.ToUpper(role[:1])+role[1:], transcriptMessageText(item.Get("content")))
+	case "reasoning":
+		var parts []string
+		item.Get("summary").ForEach(func(_, part gjson.Result) bool {
+			if text := strings.TrimSpace(part.Get("text").String()); text != "" {
+				parts = append(parts, text)
+			}
+			return true
+		})
+		if len(parts) > 0 {
+			fmt.Fprintf(b, "\n**Reasoning**\n\n%s\n", quoteMarkdown(strings.Join(parts, "\n\n")))
+		}
+	case "function_call", "custom_tool_call":
+		args := item.Get("arguments").String()
+		if kind == "custom_tool_call" {
+			args = item.Get("input").String()
+		}
+		fmt.Fprintf(b, "\n**Tool call** `%s` (%s)\n\n%s", item.Get("name").String(), codexCallID(item), fenceMarkdown(prettyJSON(args), "json"))
+	case "local_shell_call":
+		fmt.Fprintf(b, "\n**Tool call** `local_shell` (%s)\n\n%s", codexCallID(item), fenceMarkdown(prettyJSON(item.Get("action").Raw), "json"))
+	case "function_call_output", "custom_tool_call_output", "local_shell_call_output":
+		output := item.Get("output")
+		text := output.String()
+		if output.IsArray() {
+			text = transcriptMessageText(output)
+		}
+		fmt.Fprintf(b, "\n**Tool output** (%s)\n\n%s", codexCallID(item), fenceMarkdown(text, ""))
+	default:
+		fmt.Fprintf(b, "\n**%s**\n\n%s", kind, fenceMarkdown(prettyJSON(item.Raw), "json"))
+	}
+}
+
+// transcriptMessageText joins the text parts of message content, marking
+// the parts that are not text.
+func transcriptMessageText(content gjson.Result) string {
+	if content.Type == gjson.String {
+		return content.String()
+	}
+	var parts []string
+	content.ForEach(func(_, part gjson.Result) bool {
+		switch kind := part.Get("type").String(); kind {
+		case "input_text", "output_text", "text":
+			parts = append(parts, part.Get("text").String())
+		case "refusal":
+			parts = append(parts, "_Refusal:_ "+part.Get("refusal").String())
+		default:
+			parts = append(parts, "_["+kind+"]_")
+		}
+		return true
+	})
+	return strings.Join(parts, "\n\n")
+}
+
+func prettyJSON(raw string) string {
+	if !gjson.Valid(raw) {
+		return raw
+	}
+	var v any
+	if json.Unmarshal([]byte(raw), &v) != nil {
+		return raw
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return raw
+	}
+	return string(out)
+}
+
+// fenceMarkdown wraps text in a code fence longer than any backtick run in it.
+func fenceMarkdown(text, lang string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + fence + "\n"
+}
+
+func quoteMarkdown(text string) string {
+	return "> " + strings.ReplaceAll(text, "\n", "\n> ")
+}
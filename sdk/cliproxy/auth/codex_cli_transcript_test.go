@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// scriptedExecutor answers each call with the next of its responses.
+type scriptedExecutor struct {
+	streamStyleExecutor
+	responses []string
+}
+
+func (e *scriptedExecutor) Execute(_ context.Context, _ *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	next := e.responses[0]
+	e.responses = e.responses[1:]
+	return cliproxyexecutor.Response{Payload: []byte(next)}, nil
+}
+
+func TestLoadCodexTranscript(t *testing.T) {
+	upstream := &scriptedExecutor{responses: []string{
+		`{"id":"resp_1","model":"gpt-5","created_at":1760400000,"output":[{"type":"function_call","call_id":"call_1","name":"shell","arguments":"{\"command\":[\"ls\"]}"}],"usage":{"input_tokens":100,"output_tokens":20,"total_tokens":120}}`,
+		`{"id":"resp_2","model":"gpt-5","output":[{"type":"reasoning","summary":[{"type":"summary_text","text":"One file found."}]},{"type":"message","role":"assistant","content":[{"type":"output_text","text":"There is ` + "```" + `a.txt` + "```" + `."}]}],"usage":{"input_tokens":150,"output_tokens":30,"output_tokens_details":{"reasoning_tokens":10},"total_tokens":180}}`,
+	}}
+	executor := &codexCLIExecutor{ProviderExecutor: upstream}
+	scope := uuid.NewString()
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.ClientScopeMetadataKey: scope}}
+	ctx := context.Background()
+
+	if _, err := executor.Execute(ctx, nil, cliproxyexecutor.Request{Payload: []byte(`{"model":"gpt-5","input":"list files"}`)}, opts); err != nil {
+		t.Fatalf("first turn: %v", err)
+	}
+	second := `{"model":"gpt-5","previous_response_id":"resp_1","input":[{"type":"function_call_output","call_id":"call_1","output":"a.txt"}]}`
+	if _, err := executor.Execute(ctx, nil, cliproxyexecutor.Request{Payload: []byte(second)}, opts); err != nil {
+		t.Fatalf("second turn: %v", err)
+	}
+
+	transcript, found, err := LoadCodexTranscript(ctx, scope, "resp_2")
+	if err != nil || !found {
+		t.Fatalf("LoadCodexTranscript: found=%v err=%v", found, err)
+	}
+	if len(transcript.Turns) != 2 {
+		t.Fatalf("turns = %+v", transcript.Turns)
+	}
+	first, last := transcript.Turns[0], transcript.Turns[1]
+	if first.ResponseID != "resp_1" || len(first.Input) != 1 || len(first.Output) != 1 {
+		t.Fatalf("first turn = %+v", first)
+	}
+	if last.PreviousResponseID != "resp_1" || len(last.Input) != 1 || len(last.Output) != 2 {
+		t.Fatalf("second turn = %+v", last)
+	}
+	if u := transcript.Usage; u.InputTokens != 250 || u.OutputTokens != 50 || u.ReasoningTokens != 10 || u.TotalTokens != 300 {
+		t.Fatalf("usage = %+v", u)
+	}
+
+	md := transcript.Markdown()
+	for _, want := range []string{
+		"## Turn 1 · resp_1 · gpt-5 · 2025-10-14T00:00:00Z",
+		"**User**\n\nlist files",
+		"**Tool call** `shell` (call_1)",
+		"**Tool output** (call_1)\n\n```\na.txt\n```",
+		"> One file found.",
+		"_Usage: 150 input · 30 output (10 reasoning) · 180 total tokens_",
+		"**Total usage:** 250 input · 50 output (10 reasoning) · 300 total tokens",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown lacks %q:\n%s", want, md)
+		}
+	}
+
+	if got := fenceMarkdown("see ```go```", ""); !strings.HasPrefix(got, "````\n") {
+		t.Errorf("fence should outgrow the backticks in the text: %q", got)
+	}
+
+	if _, found, _ = LoadCodexTranscript(ctx, uuid.NewString(), "resp_2"); found {
+		t.Fatal("another key must not see the transcript")
+	}
+}
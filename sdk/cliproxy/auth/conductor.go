@@ -18,7 +18,9 @@ import (
 	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/servicetier"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tracing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	log "github.com/sirupsen/logrus"
@@ -142,6 +144,16 @@ type Manager struct {
 	requestRetry        atomic.Int32
 	maxRetryCredentials atomic.Int32
 	maxRetryInterval    atomic.Int64
+	// rateLimitQueueMaxWait is how long a rate-limited request may wait for a
+	// credential to cool down; 0 disables queueing.
+	rateLimitQueueMaxWait atomic.Int64
+	// rateLimitWaiters orders queued requests by service tier rank and client
+	// key.
+	rateLimitWaiters rateLimitWaiters
+	// qualityMaxRetries and qualitySameRetries limit the retries of
+	// pathological non-streaming responses; see SetQualityGuard.
+	qualityMaxRetries  atomic.Int32
+	qualitySameRetries atomic.Int32
 
 	// oauthModelAlias stores global OAuth model alias mappings (alias -> upstream name) keyed by channel.
 	oauthModelAlias atomic.Value
@@ -160,6 +172,18 @@ type Manager struct {
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
 
+	// Optional broadcaster that shares results with other replicas.
+	resultBroadcaster ResultBroadcaster
+
+	// Optional cluster hooks: refreshGate decides whether this instance runs
+	// auto refresh, refreshBroadcaster shares the credentials it refreshed.
+	refreshGate        func() bool
+	refreshBroadcaster RefreshBroadcaster
+
+	// modelUnavailable remembers (auth, model) pairs the upstream rejected
+	// as unavailable; see isModelUnavailableError.
+	modelUnavailable modelUnavailableCache
+
 	// Auto refresh state
 	refreshCancel    context.CancelFunc
 	refreshSemaphore chan struct{}
@@ -263,6 +287,31 @@ func (m *Manager) SetRoundTripperProvider(p RoundTripperProvider) {
 	m.mu.Unlock()
 }
 
+// SetResultBroadcaster registers a broadcaster that receives credential
+// state changes so other replicas can apply them via ApplyRemoteResult.
+func (m *Manager) SetResultBroadcaster(b ResultBroadcaster) {
+	m.mu.Lock()
+	m.resultBroadcaster = b
+	m.mu.Unlock()
+}
+
+// SetRefreshGate installs a check consulted before every auto-refresh pass;
+// when it returns false the pass is skipped. Replicas sharing an auth store
+// use it so only the elected leader refreshes tokens.
+func (m *Manager) SetRefreshGate(gate func() bool) {
+	m.mu.Lock()
+	m.refreshGate = gate
+	m.mu.Unlock()
+}
+
+// SetRefreshBroadcaster registers a broadcaster that receives every auth the
+// auto-refresh loop refreshed, for other replicas to apply via ApplyRemoteAuth.
+func (m *Manager) SetRefreshBroadcaster(b RefreshBroadcaster) {
+	m.mu.Lock()
+	m.refreshBroadcaster = b
+	m.mu.Unlock()
+}
+
 // SetConfig updates the runtime config snapshot used by request-time helpers.
 // Callers should provide the latest config on reload so per-credential alias mapping stays in sync.
 func (m *Manager) SetConfig(cfg *internalconfig.Config) {
@@ -421,12 +470,15 @@ func preserveRequestedModelSuffix(requestedModel, resolved string) string {
 }
 
 func (m *Manager) executionModelCandidates(auth *Auth, routeModel string) []string {
-	return m.prepareExecutionModels(auth, routeModel)
+	return m.prepareExecutionModels(auth, routeModel, nil)
 }
 
-func (m *Manager) prepareExecutionModels(auth *Auth, routeModel string) []string {
+func (m *Manager) prepareExecutionModels(auth *Auth, routeModel string, payload []byte) []string {
 	requestedModel := rewriteModelForAuth(routeModel, auth)
 	requestedModel = m.applyOAuthModelAlias(auth, requestedModel)
+	if pool := m.resolveAntigravityTierPool(auth, requestedModel, payload); len(pool) > 0 {
+		return pool
+	}
 	if pool := m.resolveOpenAICompatUpstreamModelPool(auth, requestedModel); len(pool) > 0 {
 		if len(pool) == 1 {
 			return pool
@@ -536,12 +588,13 @@ func (m *Manager) executeStreamWithModelPool(ctx context.Context, executor Provi
 	if executor == nil {
 		return nil, &Error{Code: "executor_not_found", Message: "executor not registered"}
 	}
-	execModels := m.prepareExecutionModels(auth, routeModel)
+	execModels := m.prepareExecutionModels(auth, routeModel, req.Payload)
 	var lastErr error
 	for idx, execModel := range execModels {
 		execReq := req
 		execReq.Model = execModel
-		streamResult, errStream := executor.ExecuteStream(ctx, auth, execReq, opts)
+		cliproxyexecutor.NextAttempt(ctx)
+		streamResult, errStream := executor.ExecuteStream(cliproxyexecutor.WithUpstreamModel(ctx, execModel), auth, execReq, opts)
 		if errStream != nil {
 			if errCtx := ctx.Err(); errCtx != nil {
 				return nil, errCtx
@@ -553,6 +606,7 @@ func (m *Manager) executeStreamWithModelPool(ctx context.Context, executor Provi
 			result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: false, Error: rerr}
 			result.RetryAfter = retryAfterFromError(errStream)
 			m.MarkResult(ctx, result)
+			m.markAntigravityTierResult(ctx, result, routeModel, execModel)
 			if isRequestInvalidError(errStream) {
 				return nil, errStream
 			}
@@ -585,6 +639,7 @@ func (m *Manager) executeStreamWithModelPool(ctx context.Context, executor Provi
 				result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: false, Error: rerr}
 				result.RetryAfter = retryAfterFromError(bootstrapErr)
 				m.MarkResult(ctx, result)
+				m.markAntigravityTierResult(ctx, result, routeModel, execModel)
 				discardStreamChunks(streamResult.Chunks)
 				lastErr = bootstrapErr
 				continue
@@ -764,6 +819,18 @@ func (m *Manager) SetRetryConfig(retry int, maxRetryInterval time.Duration, maxR
 	m.maxRetryInterval.Store(maxRetryInterval.Nanoseconds())
 }
 
+// SetRateLimitQueue sets how long requests rejected by every credential with a
+// rate limit wait for one to cool down before failing. Zero disables waiting.
+func (m *Manager) SetRateLimitQueue(maxWait time.Duration) {
+	if m == nil {
+		return
+	}
+	if maxWait < 0 {
+		maxWait = 0
+	}
+	m.rateLimitQueueMaxWait.Store(maxWait.Nanoseconds())
+}
+
 // RegisterExecutor registers a provider executor with the manager.
 func (m *Manager) RegisterExecutor(executor ProviderExecutor) {
 	if executor == nil {
@@ -849,6 +916,39 @@ func (m *Manager) Update(ctx context.Context, auth *Auth) (*Auth, error) {
 	return auth.Clone(), nil
 }
 
+// ApplyRemoteAuth installs credentials refreshed by another replica. Only
+// serialisable fields are taken from auth; runtime data, storage and the
+// file name of the local entry are kept. Nothing is persisted and no hook
+// fires, since the refreshing replica already did both.
+func (m *Manager) ApplyRemoteAuth(ctx context.Context, auth *Auth) {
+	if auth == nil || auth.ID == "" {
+		return
+	}
+	m.mu.Lock()
+	existing, ok := m.auths[auth.ID]
+	if !ok || existing == nil {
+		m.mu.Unlock()
+		return
+	}
+	updated := auth.Clone()
+	updated.Index = existing.Index
+	updated.indexAssigned = existing.indexAssigned
+	updated.FileName = existing.FileName
+	updated.Storage = existing.Storage
+	updated.Runtime = existing.Runtime
+	if len(updated.ModelStates) == 0 && len(existing.ModelStates) > 0 {
+		updated.ModelStates = existing.ModelStates
+	}
+	updated.EnsureIndex()
+	m.auths[auth.ID] = updated
+	m.mu.Unlock()
+	m.rebuildAPIKeyModelAliasFromRuntimeConfig()
+	if m.scheduler != nil {
+		m.scheduler.upsertAuth(updated.Clone())
+	}
+	log.Debugf("applied remote refresh for %s, %s", updated.Provider, updated.ID)
+}
+
 // Load resets manager state from the backing store.
 func (m *Manager) Load(ctx context.Context) error {
 	m.mu.Lock()
@@ -888,21 +988,42 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 	}
 
 	_, maxRetryCredentials, maxWait := m.retrySettings()
+	ctx = cliproxyexecutor.WithAttemptCounter(ctx)
 
 	var lastErr error
+	var ticket *rateLimitTicket
+	rank := servicetier.Rank(serviceTierOf(opts))
+	client, weight := rateLimitQueueClientOf(opts)
+	guard := m.newQualityGuard()
 	for attempt := 0; ; attempt++ {
-		resp, errExec := m.executeMixedOnce(ctx, normalized, req, opts, maxRetryCredentials)
+		resp, errExec := m.executeMixedOnce(ctx, normalized, req, opts, maxRetryCredentials, guard)
 		if errExec == nil {
+			ticket.leave(true)
 			return resp, nil
 		}
 		lastErr = errExec
 		wait, shouldRetry := m.shouldRetryAfterError(errExec, attempt, normalized, req.Model, maxWait)
 		if !shouldRetry {
-			break
+			if !m.rateLimitQueueEligible(errExec) {
+				break
+			}
+			if ticket == nil {
+				ticket = m.rateLimitWaiters.join(req.Model, rank, client, weight)
+				defer ticket.leave(false)
+			}
+			if wait, shouldRetry = m.rateLimitQueueWait(normalized, req.Model, ticket); !shouldRetry {
+				break
+			}
+			notifyQueueWait(opts.Metadata, wait)
 		}
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return cliproxyexecutor.Response{}, errWait
 		}
+		if turn, errTurn := m.awaitRateLimitTurn(ctx, ticket); errTurn != nil {
+			return cliproxyexecutor.Response{}, errTurn
+		} else if !turn {
+			break
+		}
 	}
 	if lastErr != nil {
 		return cliproxyexecutor.Response{}, lastErr
@@ -919,21 +1040,41 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 	}
 
 	_, maxRetryCredentials, maxWait := m.retrySettings()
+	ctx = cliproxyexecutor.WithAttemptCounter(ctx)
 
 	var lastErr error
+	var ticket *rateLimitTicket
+	rank := servicetier.Rank(serviceTierOf(opts))
+	client, weight := rateLimitQueueClientOf(opts)
 	for attempt := 0; ; attempt++ {
 		resp, errExec := m.executeCountMixedOnce(ctx, normalized, req, opts, maxRetryCredentials)
 		if errExec == nil {
+			ticket.leave(true)
 			return resp, nil
 		}
 		lastErr = errExec
 		wait, shouldRetry := m.shouldRetryAfterError(errExec, attempt, normalized, req.Model, maxWait)
 		if !shouldRetry {
-			break
+			if !m.rateLimitQueueEligible(errExec) {
+				break
+			}
+			if ticket == nil {
+				ticket = m.rateLimitWaiters.join(req.Model, rank, client, weight)
+				defer ticket.leave(false)
+			}
+			if wait, shouldRetry = m.rateLimitQueueWait(normalized, req.Model, ticket); !shouldRetry {
+				break
+			}
+			notifyQueueWait(opts.Metadata, wait)
 		}
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return cliproxyexecutor.Response{}, errWait
 		}
+		if turn, errTurn := m.awaitRateLimitTurn(ctx, ticket); errTurn != nil {
+			return cliproxyexecutor.Response{}, errTurn
+		} else if !turn {
+			break
+		}
 	}
 	if lastErr != nil {
 		return cliproxyexecutor.Response{}, lastErr
@@ -950,21 +1091,41 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	}
 
 	_, maxRetryCredentials, maxWait := m.retrySettings()
+	ctx = cliproxyexecutor.WithAttemptCounter(ctx)
 
 	var lastErr error
+	var ticket *rateLimitTicket
+	rank := servicetier.Rank(serviceTierOf(opts))
+	client, weight := rateLimitQueueClientOf(opts)
 	for attempt := 0; ; attempt++ {
 		result, errStream := m.executeStreamMixedOnce(ctx, normalized, req, opts, maxRetryCredentials)
 		if errStream == nil {
+			ticket.leave(true)
 			return result, nil
 		}
 		lastErr = errStream
 		wait, shouldRetry := m.shouldRetryAfterError(errStream, attempt, normalized, req.Model, maxWait)
 		if !shouldRetry {
-			break
+			if !m.rateLimitQueueEligible(errStream) {
+				break
+			}
+			if ticket == nil {
+				ticket = m.rateLimitWaiters.join(req.Model, rank, client, weight)
+				defer ticket.leave(false)
+			}
+			if wait, shouldRetry = m.rateLimitQueueWait(normalized, req.Model, ticket); !shouldRetry {
+				break
+			}
+			notifyQueueWait(opts.Metadata, wait)
 		}
 		if errWait := waitForCooldown(ctx, wait); errWait != nil {
 			return nil, errWait
 		}
+		if turn, errTurn := m.awaitRateLimitTurn(ctx, ticket); errTurn != nil {
+			return nil, errTurn
+		} else if !turn {
+			break
+		}
 	}
 	if lastErr != nil {
 		return nil, lastErr
@@ -972,7 +1133,7 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
 
-func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, maxRetryCredentials int) (cliproxyexecutor.Response, error) {
+func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, maxRetryCredentials int, guard *qualityGuard) (cliproxyexecutor.Response, error) {
 	if len(providers) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
@@ -982,6 +1143,9 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 	var lastErr error
 	for {
 		if maxRetryCredentials > 0 && len(tried) >= maxRetryCredentials {
+			if resp, ok := guard.fallbackResponse(); ok {
+				return resp, nil
+			}
 			if lastErr != nil {
 				return cliproxyexecutor.Response{}, lastErr
 			}
@@ -989,6 +1153,9 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 		}
 		auth, executor, provider, errPick := m.pickNextMixed(ctx, providers, routeModel, opts, tried)
 		if errPick != nil {
+			if resp, ok := guard.fallbackResponse(); ok {
+				return resp, nil
+			}
 			if lastErr != nil {
 				return cliproxyexecutor.Response{}, lastErr
 			}
@@ -1000,39 +1167,67 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 		publishSelectedAuthMetadata(opts.Metadata, auth.ID)
 
 		tried[auth.ID] = struct{}{}
+		executor = withStreamMode(executor, auth, routeModel)
+		executor = withClientProfile(executor, auth, opts)
+		executor = withStopSequences(executor, auth, req, opts)
+		executor = withMultiChoice(executor, auth, req, opts)
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
 
-		models := m.prepareExecutionModels(auth, routeModel)
+		models := m.prepareExecutionModels(auth, routeModel, req.Payload)
 		var authErr error
+		nextCredential := false
+	modelLoop:
 		for _, upstreamModel := range models {
 			execReq := req
 			execReq.Model = upstreamModel
-			resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
-			result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
-			if errExec != nil {
-				if errCtx := execCtx.Err(); errCtx != nil {
-					return cliproxyexecutor.Response{}, errCtx
-				}
-				result.Error = &Error{Message: errExec.Error()}
-				if se, ok := errors.AsType[cliproxyexecutor.StatusError](errExec); ok && se != nil {
-					result.Error.HTTPStatus = se.StatusCode()
-				}
-				if ra := retryAfterFromError(errExec); ra != nil {
-					result.RetryAfter = ra
+			for {
+				cliproxyexecutor.NextAttempt(execCtx)
+				resp, errExec := executor.Execute(guard.tag(cliproxyexecutor.WithUpstreamModel(execCtx, upstreamModel)), auth, execReq, opts)
+				result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
+				if errExec != nil {
+					if errCtx := execCtx.Err(); errCtx != nil {
+						return cliproxyexecutor.Response{}, errCtx
+					}
+					result.Error = &Error{Message: errExec.Error()}
+					if se, ok := errors.AsType[cliproxyexecutor.StatusError](errExec); ok && se != nil {
+						result.Error.HTTPStatus = se.StatusCode()
+					}
+					if ra := retryAfterFromError(errExec); ra != nil {
+						result.RetryAfter = ra
+					}
+					m.MarkResult(execCtx, result)
+					m.markAntigravityTierResult(execCtx, result, routeModel, upstreamModel)
+					if isRequestInvalidError(errExec) {
+						return cliproxyexecutor.Response{}, errExec
+					}
+					authErr = errExec
+					continue modelLoop
 				}
 				m.MarkResult(execCtx, result)
-				if isRequestInvalidError(errExec) {
-					return cliproxyexecutor.Response{}, errExec
+				m.markAntigravityTierResult(execCtx, result, routeModel, upstreamModel)
+				resp.Headers = withSeedReport(resp.Headers, auth, routeModel, req, opts)
+				resp.Headers = withServiceTierReport(resp.Headers, auth, opts, resp.Payload)
+				resp.Headers = withRateLimitReport(resp.Headers, auth, opts, time.Now())
+				resp.Payload = withServiceTierField(resp.Payload, resp.Headers, opts)
+				action, defect := guard.check(opts.SourceFormat, resp)
+				switch action {
+				case RecoveryRetrySameCredential:
+					entry.Debugf("quality guard: %s response from auth %s, retrying on the same credential", defect, auth.ID)
+					continue
+				case RecoveryRetryNextCredential:
+					entry.Debugf("quality guard: %s response from auth %s, retrying on the next credential", defect, auth.ID)
+					nextCredential = true
+					break modelLoop
 				}
-				authErr = errExec
-				continue
+				return resp, nil
 			}
-			m.MarkResult(execCtx, result)
-			return resp, nil
+		}
+		if nextCredential {
+			continue
 		}
 		if authErr != nil {
 			if isRequestInvalidError(authErr) {
@@ -1072,18 +1267,20 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 		publishSelectedAuthMetadata(opts.Metadata, auth.ID)
 
 		tried[auth.ID] = struct{}{}
+		executor = withClientProfile(executor, auth, opts)
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
 
-		models := m.prepareExecutionModels(auth, routeModel)
+		models := m.prepareExecutionModels(auth, routeModel, req.Payload)
 		var authErr error
 		for _, upstreamModel := range models {
 			execReq := req
 			execReq.Model = upstreamModel
-			resp, errExec := executor.CountTokens(execCtx, auth, execReq, opts)
+			cliproxyexecutor.NextAttempt(execCtx)
+			resp, errExec := executor.CountTokens(cliproxyexecutor.WithUpstreamModel(execCtx, upstreamModel), auth, execReq, opts)
 			result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 			if errExec != nil {
 				if errCtx := execCtx.Err(); errCtx != nil {
@@ -1144,6 +1341,10 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 		publishSelectedAuthMetadata(opts.Metadata, auth.ID)
 
 		tried[auth.ID] = struct{}{}
+		executor = withStreamMode(executor, auth, routeModel)
+		executor = withClientProfile(executor, auth, opts)
+		executor = withStopSequences(executor, auth, req, opts)
+		executor = withMultiChoice(executor, auth, req, opts)
 		execCtx := ctx
 		if rt := m.roundTripperFor(auth); rt != nil {
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
@@ -1160,6 +1361,9 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 			lastErr = errStream
 			continue
 		}
+		streamResult.Headers = withSeedReport(streamResult.Headers, auth, routeModel, req, opts)
+		streamResult.Headers = withServiceTierReport(streamResult.Headers, auth, opts, nil)
+		streamResult.Headers = withRateLimitReport(streamResult.Headers, auth, opts, time.Now())
 		return streamResult, nil
 	}
 }
@@ -1239,15 +1443,12 @@ func rewriteModelForAuth(model string, auth *Auth) string {
 	if auth == nil || model == "" {
 		return model
 	}
-	prefix := strings.TrimSpace(auth.Prefix)
-	if prefix == "" {
-		return model
-	}
-	needle := prefix + "/"
-	if !strings.HasPrefix(model, needle) {
-		return model
+	for _, prefix := range auth.ModelPrefixes() {
+		if needle := prefix + "/"; strings.HasPrefix(model, needle) {
+			return strings.TrimPrefix(model, needle)
+		}
 	}
-	return strings.TrimPrefix(model, needle)
+	return model
 }
 
 func (m *Manager) applyAPIKeyModelAlias(auth *Auth, requestedModel string) string {
@@ -1489,6 +1690,9 @@ func (m *Manager) retrySettings() (int, int, time.Duration) {
 	return int(m.requestRetry.Load()), int(m.maxRetryCredentials.Load()), time.Duration(m.maxRetryInterval.Load())
 }
 
+// closestCooldownWait returns the shortest wait until a blocked auth of the
+// given providers becomes usable again for model. Auths whose retry budget is
+// spent by attempt are ignored; a negative attempt considers every auth.
 func (m *Manager) closestCooldownWait(providers []string, model string, attempt int) (time.Duration, bool) {
 	if m == nil || len(providers) == 0 {
 		return 0, false
@@ -1527,7 +1731,7 @@ func (m *Manager) closestCooldownWait(providers []string, model string, attempt
 		if effectiveRetry < 0 {
 			effectiveRetry = 0
 		}
-		if attempt >= effectiveRetry {
+		if attempt >= 0 && attempt >= effectiveRetry {
 			continue
 		}
 		blocked, reason, next := isAuthBlockedForModel(auth, model, now)
@@ -1566,6 +1770,74 @@ func (m *Manager) shouldRetryAfterError(err error, attempt int, providers []stri
 	return wait, true
 }
 
+// rateLimitQueuePoll bounds each wait in the rate-limit queue so credentials
+// that recover early, or were added meanwhile, are picked up promptly.
+const rateLimitQueuePoll = 5 * time.Second
+
+// rateLimitQueueEligible reports whether a request that failed with err after
+// exhausting its retries may enter the rate-limit queue: the queue is enabled
+// and err is a rate limit or overload.
+func (m *Manager) rateLimitQueueEligible(err error) bool {
+	return m.rateLimitQueueMaxWait.Load() > 0 && isRateLimitOrOverload(err)
+}
+
+// rateLimitQueueWait decides whether a request in the rate-limit queue,
+// holding ticket since it was first queued, keeps waiting for a credential to
+// cool down. While requests of a higher service tier rank, or of other client
+// keys ahead in fair order, wait for the same model, the request polls
+// instead of waking when the next credential frees up, and
+// awaitRateLimitTurn then holds it until they have left the queue.
+func (m *Manager) rateLimitQueueWait(providers []string, model string, ticket *rateLimitTicket) (time.Duration, bool) {
+	maxWait := time.Duration(m.rateLimitQueueMaxWait.Load())
+	if maxWait <= 0 {
+		return 0, false
+	}
+	remaining := maxWait - time.Since(ticket.joinedAt)
+	if remaining <= 0 {
+		return 0, false
+	}
+	wait, found := m.closestCooldownWait(providers, model, -1)
+	if found && wait > remaining {
+		// No credential frees up before the deadline.
+		return 0, false
+	}
+	if !found || wait > rateLimitQueuePoll || m.rateLimitWaiters.yields(ticket) {
+		wait = rateLimitQueuePoll
+	}
+	if wait > remaining {
+		wait = remaining
+	}
+	return wait, true
+}
+
+// awaitRateLimitTurn holds a queued request, before it retries, until no
+// request ahead of it in the queue is waiting for the same model. It reports
+// false when the queue's max wait runs out first. Requests that are not queued
+// pass straight through.
+func (m *Manager) awaitRateLimitTurn(ctx context.Context, ticket *rateLimitTicket) (bool, error) {
+	if ticket == nil {
+		return true, nil
+	}
+	deadline := ticket.joinedAt.Add(time.Duration(m.rateLimitQueueMaxWait.Load()))
+	return m.rateLimitWaiters.awaitTurn(ctx, ticket, deadline)
+}
+
+func isRateLimitOrOverload(err error) bool {
+	switch statusCodeFromError(err) {
+	case http.StatusTooManyRequests, 529:
+		return true
+	}
+	return false
+}
+
+// notifyQueueWait tells the caller, through the request metadata, that the
+// request is held in the rate-limit queue for wait.
+func notifyQueueWait(meta map[string]any, wait time.Duration) {
+	if callback, ok := meta[cliproxyexecutor.QueueWaitCallbackMetadataKey].(func(time.Duration)); ok && callback != nil {
+		callback(wait)
+	}
+}
+
 func waitForCooldown(ctx context.Context, wait time.Duration) error {
 	if wait <= 0 {
 		return nil
@@ -1582,6 +1854,17 @@ func waitForCooldown(ctx context.Context, wait time.Duration) error {
 
 // MarkResult records an execution result and notifies hooks.
 func (m *Manager) MarkResult(ctx context.Context, result Result) {
+	m.markResult(ctx, result, false)
+}
+
+// ApplyRemoteResult records a result reported by another replica that serves
+// the same credentials. State is updated in memory only: nothing is persisted,
+// hooks do not fire and the result is not broadcast again.
+func (m *Manager) ApplyRemoteResult(ctx context.Context, result Result) {
+	m.markResult(ctx, result, true)
+}
+
+func (m *Manager) markResult(ctx context.Context, result Result, remote bool) {
 	if result.AuthID == "" {
 		return
 	}
@@ -1592,13 +1875,19 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	clearModelQuota := false
 	setModelQuota := false
 	var authSnapshot *Auth
+	var broadcaster ResultBroadcaster
+	shared := result
 
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
 		now := time.Now()
+		if !remote && m.resultBroadcaster != nil && (!result.Success || authDegradedForModel(auth, result.Model)) {
+			broadcaster = m.resultBroadcaster
+		}
 
 		if result.Success {
 			if result.Model != "" {
+				m.modelUnavailable.remove(auth.ID, result.Model)
 				state := ensureModelState(auth, result.Model)
 				resetModelState(state, now)
 				updateAggregatedAvailability(auth, now)
@@ -1656,6 +1945,11 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 						backoffLevel = nextLevel
 					}
 					state.NextRetryAfter = next
+					if !next.IsZero() {
+						// Replicas adopt this cooldown instead of computing their own backoff.
+						retryAfter := next.Sub(now)
+						shared.RetryAfter = &retryAfter
+					}
 					state.Quota = QuotaState{
 						Exceeded:      true,
 						Reason:        "quota",
@@ -1675,6 +1969,19 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 				default:
 					state.NextRetryAfter = time.Time{}
 				}
+				if ttl := m.modelUnavailableTTL(); ttl > 0 && isModelUnavailableError(result.Error) {
+					state.NextRetryAfter = now.Add(ttl)
+					suspendReason = "model_unavailable"
+					shouldSuspendModel = true
+					m.modelUnavailable.put(ModelUnavailableEntry{
+						AuthID:     auth.ID,
+						Model:      result.Model,
+						StatusCode: statusCode,
+						Message:    result.Error.Message,
+						CachedAt:   now,
+						ExpiresAt:  state.NextRetryAfter,
+					})
+				}
 
 				auth.Status = StatusError
 				auth.UpdatedAt = now
@@ -1684,7 +1991,9 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 			}
 		}
 
-		_ = m.persist(ctx, auth)
+		if !remote {
+			_ = m.persist(ctx, auth)
+		}
 		authSnapshot = auth.Clone()
 	}
 	m.mu.Unlock()
@@ -1704,7 +2013,25 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 		registry.GetGlobalRegistry().SuspendClientModel(result.AuthID, result.Model, suspendReason)
 	}
 
+	if remote {
+		return
+	}
 	m.hook.OnResult(ctx, result)
+	if broadcaster != nil {
+		broadcaster.BroadcastResult(ctx, shared)
+	}
+}
+
+// authDegradedForModel reports whether a success for model would change
+// shared state, i.e. the auth or model is currently cooling down or failed.
+func authDegradedForModel(auth *Auth, model string) bool {
+	if model != "" {
+		if state, ok := auth.ModelStates[model]; ok && state != nil {
+			return state.Unavailable || state.Quota.Exceeded || state.Status == StatusError
+		}
+		return false
+	}
+	return auth.Unavailable || auth.Quota.Exceeded || auth.Status == StatusError
 }
 
 func ensureModelState(auth *Auth, model string) *ModelState {
@@ -1985,6 +2312,22 @@ func (m *Manager) List() []*Auth {
 	return list
 }
 
+// AuthAvailableForModel reports whether the auth with id is registered and
+// not disabled or cooling down for model.
+func (m *Manager) AuthAvailableForModel(id, model string) bool {
+	if id == "" {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	auth, ok := m.auths[id]
+	if !ok {
+		return false
+	}
+	blocked, _, _ := isAuthBlockedForModel(auth, model, time.Now())
+	return !blocked
+}
+
 // GetByID retrieves an auth entry by its ID.
 
 func (m *Manager) GetByID(id string) (*Auth, bool) {
@@ -2244,6 +2587,7 @@ func (m *Manager) pickNextMixedLegacy(ctx context.Context, providers []string, m
 }
 
 func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, string, error) {
+	defer tracing.FromContext(ctx).Start(tracing.StageAuthSelection)()
 	if !m.useSchedulerFastPath() {
 		return m.pickNextMixedLegacy(ctx, providers, model, opts, tried)
 	}
@@ -2351,8 +2695,20 @@ func (m *Manager) StopAutoRefresh() {
 	}
 }
 
+// CheckRefreshes runs one auto-refresh pass now instead of waiting for the
+// next tick, e.g. right after this replica is elected leader.
+func (m *Manager) CheckRefreshes(ctx context.Context) {
+	m.checkRefreshes(ctx)
+}
+
 func (m *Manager) checkRefreshes(ctx context.Context) {
 	// log.Debugf("checking refreshes")
+	m.mu.RLock()
+	gate := m.refreshGate
+	m.mu.RUnlock()
+	if gate != nil && !gate() {
+		return
+	}
 	now := time.Now()
 	snapshot := m.snapshotAuths()
 	for _, a := range snapshot {
@@ -2667,7 +3023,13 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	// If the Authenticator did not set it (zero value), shouldRefresh will use default logic
 	updated.LastError = nil
 	updated.UpdatedAt = now
-	_, _ = m.Update(ctx, updated)
+	refreshed, _ := m.Update(ctx, updated)
+	m.mu.RLock()
+	broadcaster := m.refreshBroadcaster
+	m.mu.RUnlock()
+	if broadcaster != nil && refreshed != nil {
+		broadcaster.BroadcastRefresh(ctx, refreshed)
+	}
 }
 
 func (m *Manager) executorFor(provider string) ProviderExecutor {
@@ -2690,6 +3052,19 @@ func (m *Manager) roundTripperFor(auth *Auth) http.RoundTripper {
 	return p.RoundTripperFor(auth)
 }
 
+// ResultBroadcaster receives execution results that change credential state,
+// letting replicas behind a load balancer cool down and recover credentials
+// in step. BroadcastResult must not block.
+type ResultBroadcaster interface {
+	BroadcastResult(ctx context.Context, result Result)
+}
+
+// RefreshBroadcaster receives auths refreshed by this instance so replicas
+// that do not refresh can pick up the new tokens. BroadcastRefresh must not block.
+type RefreshBroadcaster interface {
+	BroadcastRefresh(ctx context.Context, auth *Auth)
+}
+
 // RoundTripperProvider defines a minimal provider of per-auth HTTP transports.
 type RoundTripperProvider interface {
 	RoundTripperFor(auth *Auth) http.RoundTripper
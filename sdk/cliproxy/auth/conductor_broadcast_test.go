@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+type recordingBroadcaster struct {
+	results []Result
+}
+
+func (b *recordingBroadcaster) BroadcastResult(_ context.Context, result Result) {
+	b.results = append(b.results, result)
+}
+
+func TestManager_MarkResult_BroadcastsStateChanges(t *testing.T) {
+	prev := quotaCooldownDisabled.Load()
+	quotaCooldownDisabled.Store(false)
+	t.Cleanup(func() { quotaCooldownDisabled.Store(prev) })
+
+	ctx := context.Background()
+	local, remote := NewManager(nil, nil, nil), NewManager(nil, nil, nil)
+	for _, m := range []*Manager{local, remote} {
+		if _, errRegister := m.Register(ctx, &Auth{ID: "auth-1", Provider: "claude"}); errRegister != nil {
+			t.Fatalf("register auth: %v", errRegister)
+		}
+	}
+	broadcaster := &recordingBroadcaster{}
+	local.SetResultBroadcaster(broadcaster)
+	model := "test-model"
+
+	// A success on a healthy auth changes nothing worth sharing.
+	local.MarkResult(ctx, Result{AuthID: "auth-1", Provider: "claude", Model: model, Success: true})
+	local.MarkResult(ctx, Result{AuthID: "auth-1", Provider: "claude", Model: model, Error: &Error{HTTPStatus: 429, Message: "quota"}})
+	local.MarkResult(ctx, Result{AuthID: "auth-1", Provider: "claude", Model: model, Success: true})
+	if len(broadcaster.results) != 2 {
+		t.Fatalf("expected the failure and the recovery to be broadcast, got %d results", len(broadcaster.results))
+	}
+	failure := broadcaster.results[0]
+	if failure.RetryAfter == nil || *failure.RetryAfter <= 0 {
+		t.Fatalf("broadcast quota failure should carry the computed cooldown, got %v", failure.RetryAfter)
+	}
+
+	remote.ApplyRemoteResult(ctx, failure)
+	if remote.AuthAvailableForModel("auth-1", model) {
+		t.Fatal("remote failure should cool the auth down on the receiving replica")
+	}
+	remote.ApplyRemoteResult(ctx, broadcaster.results[1])
+	if !remote.AuthAvailableForModel("auth-1", model) {
+		t.Fatal("remote recovery should make the auth available again")
+	}
+}
+
+func TestManager_ApplyRemoteAuth_KeepsLocalRuntime(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, nil, nil)
+	local := &Auth{ID: "auth-1", Provider: "claude", FileName: "claude.json", Runtime: "runtime", Metadata: map[string]any{"access_token": "old"}}
+	if _, errRegister := m.Register(ctx, local); errRegister != nil {
+		t.Fatalf("register auth: %v", errRegister)
+	}
+	m.ApplyRemoteAuth(ctx, &Auth{ID: "auth-1", Provider: "claude", Metadata: map[string]any{"access_token": "new"}})
+	updated, ok := m.GetByID("auth-1")
+	if !ok {
+		t.Fatal("expected auth to be present")
+	}
+	if updated.Metadata["access_token"] != "new" {
+		t.Fatalf("remote token not applied: %v", updated.Metadata)
+	}
+	if updated.Runtime != "runtime" || updated.FileName != "claude.json" {
+		t.Fatalf("local runtime fields lost: runtime=%v file=%q", updated.Runtime, updated.FileName)
+	}
+
+	m.ApplyRemoteAuth(ctx, &Auth{ID: "unknown", Provider: "claude"})
+	if _, ok = m.GetByID("unknown"); ok {
+		t.Fatal("remote refresh must not register auths this replica does not serve")
+	}
+}
@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/servicetier"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type rateLimitQueueExecutor struct{ schedulerTestExecutor }
+
+func (rateLimitQueueExecutor) Identifier() string { return "claude" }
+
+func (rateLimitQueueExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{Payload: []byte("ok")}, nil
+}
+
+func newRateLimitQueueTestManager(t *testing.T, cooldown time.Duration) (*Manager, string) {
+	t.Helper()
+
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(rateLimitQueueExecutor{})
+	model := "queue-model-" + uuid.NewString()
+	auth := &Auth{
+		ID:       uuid.NewString(),
+		Provider: "claude",
+		ModelStates: map[string]*ModelState{
+			model: {
+				Unavailable:    true,
+				Status:         StatusError,
+				NextRetryAfter: time.Now().Add(cooldown),
+				Quota:          QuotaState{Exceeded: true, NextRecoverAt: time.Now().Add(cooldown)},
+			},
+		},
+	}
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(auth.ID, "claude", []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { reg.UnregisterClient(auth.ID) })
+	if _, errRegister := m.Register(context.Background(), auth); errRegister != nil {
+		t.Fatalf("register auth: %v", errRegister)
+	}
+	return m, model
+}
+
+func TestManager_RateLimitQueue_WaitsForCooldown(t *testing.T) {
+	m, model := newRateLimitQueueTestManager(t, 300*time.Millisecond)
+	m.SetRateLimitQueue(5 * time.Second)
+
+	var waits []time.Duration
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{
+		cliproxyexecutor.QueueWaitCallbackMetadataKey: func(wait time.Duration) { waits = append(waits, wait) },
+	}}
+	start := time.Now()
+	resp, errExecute := m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: model}, opts)
+	if errExecute != nil || string(resp.Payload) != "ok" {
+		t.Fatalf("Execute() = %q, %v; want ok after the cooldown", resp.Payload, errExecute)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Fatalf("Execute() returned after %v, before the credential cooled down", elapsed)
+	}
+	if len(waits) == 0 || waits[0] > rateLimitQueuePoll {
+		t.Fatalf("queue wait callbacks = %v", waits)
+	}
+}
+
+func TestManager_RateLimitQueue_FailsWhenCooldownExceedsMaxWait(t *testing.T) {
+	m, model := newRateLimitQueueTestManager(t, time.Hour)
+
+	for _, maxWait := range []time.Duration{0, time.Minute} {
+		m.SetRateLimitQueue(maxWait)
+		start := time.Now()
+		_, errExecute := m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: model}, cliproxyexecutor.Options{})
+		if statusCodeFromError(errExecute) != http.StatusTooManyRequests {
+			t.Fatalf("max wait %v: Execute() error = %v, want 429", maxWait, errExecute)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("max wait %v: Execute() waited %v for a cooldown past the deadline", maxWait, elapsed)
+		}
+	}
+}
+
+func TestManager_RateLimitQueueWait_OnlyQueuesRateLimits(t *testing.T) {
+	m, model := newRateLimitQueueTestManager(t, 2*time.Second)
+	m.SetRateLimitQueue(time.Minute)
+
+	queued := &rateLimitTicket{model: model, rank: 1, joinedAt: time.Now()}
+	if m.rateLimitQueueEligible(&Error{HTTPStatus: http.StatusInternalServerError}) {
+		t.Fatalf("server error was queued")
+	}
+	if !m.rateLimitQueueEligible(&Error{HTTPStatus: 529}) || !m.rateLimitQueueEligible(&Error{HTTPStatus: http.StatusTooManyRequests}) {
+		t.Fatalf("rate limit or overload was not queued")
+	}
+	wait, ok := m.rateLimitQueueWait([]string{"claude"}, model, queued)
+	if !ok || wait <= 0 || wait > 2*time.Second {
+		t.Fatalf("rateLimitQueueWait() = %v, %v", wait, ok)
+	}
+	queued.joinedAt = time.Now().Add(-time.Minute)
+	if _, ok := m.rateLimitQueueWait([]string{"claude"}, model, queued); ok {
+		t.Fatalf("request queued past its max wait")
+	}
+	m.SetRateLimitQueue(0)
+	if m.rateLimitQueueEligible(&Error{HTTPStatus: http.StatusTooManyRequests}) {
+		t.Fatalf("rate limit was queued with the queue disabled")
+	}
+}
+
+type badRequestExecutor struct{ schedulerTestExecutor }
+
+func (badRequestExecutor) Identifier() string { return "claude" }
+
+func (badRequestExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, &Error{HTTPStatus: http.StatusBadRequest, Message: "bad request"}
+}
+
+func TestManager_RateLimitQueue_JoinsOnlyForQueuedErrors(t *testing.T) {
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.QueueClientMetadataKey: "client-key"}}
+
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(badRequestExecutor{})
+	m.SetRateLimitQueue(time.Minute)
+	model := "queue-model-" + uuid.NewString()
+	auth := &Auth{ID: uuid.NewString(), Provider: "claude"}
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(auth.ID, "claude", []*registry.ModelInfo{{ID: model}})
+	t.Cleanup(func() { reg.UnregisterClient(auth.ID) })
+	if _, errRegister := m.Register(context.Background(), auth); errRegister != nil {
+		t.Fatalf("register auth: %v", errRegister)
+	}
+	if _, errExecute := m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: model}, opts); statusCodeFromError(errExecute) != http.StatusBadRequest {
+		t.Fatalf("Execute() error = %v, want 400", errExecute)
+	}
+	if stats := m.RateLimitQueueStats(); len(stats) != 0 {
+		t.Fatalf("a bad request joined the queue: %+v", stats)
+	}
+
+	limited, limitedModel := newRateLimitQueueTestManager(t, time.Hour)
+	limited.SetRateLimitQueue(0)
+	if _, errExecute := limited.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: limitedModel}, opts); statusCodeFromError(errExecute) != http.StatusTooManyRequests {
+		t.Fatalf("Execute() error = %v, want 429", errExecute)
+	}
+	if stats := limited.RateLimitQueueStats(); len(stats) != 0 {
+		t.Fatalf("a rate limit joined the disabled queue: %+v", stats)
+	}
+}
+
+func TestManager_RateLimitQueueWait_LeavesFreedCredentialsToHigherTiers(t *testing.T) {
+	m, model := newRateLimitQueueTestManager(t, time.Second)
+	m.SetRateLimitQueue(time.Minute)
+
+	priority := m.rateLimitWaiters.join(model, servicetier.Rank(servicetier.Priority), "", 0)
+	flex := m.rateLimitWaiters.join(model, servicetier.Rank(servicetier.Flex), "", 0)
+	defer flex.leave(false)
+	if wait, ok := m.rateLimitQueueWait([]string{"claude"}, model, flex); !ok || wait != rateLimitQueuePoll {
+		t.Fatalf("outranked wait = %v, %v; want a full poll", wait, ok)
+	}
+	if wait, ok := m.rateLimitQueueWait([]string{"claude"}, model, priority); !ok || wait > time.Second {
+		t.Fatalf("priority wait = %v, %v; want the cooldown", wait, ok)
+	}
+	priority.leave(true)
+	if wait, _ := m.rateLimitQueueWait([]string{"claude"}, model, flex); wait > time.Second {
+		t.Fatalf("wait after the priority request left = %v", wait)
+	}
+}
+
+func TestServiceTierReport(t *testing.T) {
+	opts := cliproxyexecutor.Options{
+		SourceFormat: "openai",
+		Metadata:     map[string]any{cliproxyexecutor.ServiceTierMetadataKey: servicetier.Priority},
+	}
+	headers := withServiceTierReport(nil, &Auth{Provider: "claude"}, opts, []byte(`{"choices":[]}`))
+	if got := headers.Get(servicetier.ResponseHeader); got != servicetier.Priority {
+		t.Fatalf("claude priority tier = %q", got)
+	}
+	if got := string(withServiceTierField([]byte(`{"choices":[]}`), headers, opts)); got != `{"choices":[],"service_tier":"priority"}` {
+		t.Fatalf("payload = %s", got)
+	}
+	headers = withServiceTierReport(nil, &Auth{Provider: "gemini"}, opts, nil)
+	if got := headers.Get(servicetier.ResponseHeader); got != servicetier.Default {
+		t.Fatalf("gemini tier = %q", got)
+	}
+	headers = withServiceTierReport(nil, &Auth{Provider: "codex"}, opts, []byte(`{"service_tier":"default"}`))
+	if got := headers.Get(servicetier.ResponseHeader); got != servicetier.Default {
+		t.Fatalf("reported tier = %q", got)
+	}
+	if headers = withServiceTierReport(nil, &Auth{Provider: "claude"}, cliproxyexecutor.Options{}, nil); headers != nil {
+		t.Fatalf("requests without a tier got %v", headers)
+	}
+}
+
+func TestManager_RateLimitQueueWait_SharesFreedCredentialsFairlyBetweenKeys(t *testing.T) {
+	m, model := newRateLimitQueueTestManager(t, time.Second)
+	m.SetRateLimitQueue(time.Minute)
+	rank := servicetier.Rank(servicetier.Default)
+	waitsForCooldown := func(ticket *rateLimitTicket) bool {
+		wait, ok := m.rateLimitQueueWait([]string{"claude"}, model, ticket)
+		return ok && wait < rateLimitQueuePoll
+	}
+
+	// A busy key queues three requests before a quiet key queues one.
+	busy := []*rateLimitTicket{
+		m.rateLimitWaiters.join(model, rank, "busy-key", 1),
+		m.rateLimitWaiters.join(model, rank, "busy-key", 1),
+		m.rateLimitWaiters.join(model, rank, "busy-key", 1),
+	}
+	quiet := m.rateLimitWaiters.join(model, rank, "quiet-key", 1)
+	defer quiet.leave(false)
+	if !waitsForCooldown(busy[0]) || waitsForCooldown(quiet) {
+		t.Fatal("the first request queued should get the next credential")
+	}
+	busy[0].leave(true)
+	if !waitsForCooldown(quiet) || waitsForCooldown(busy[1]) {
+		t.Fatal("the quiet key should be served before the busy key's second request")
+	}
+	quiet.leave(true)
+	if !waitsForCooldown(busy[1]) {
+		t.Fatal("the busy key should be served once the quiet key is")
+	}
+	busy[1].leave(true)
+	busy[2].leave(false)
+
+	// A key of weight 2 is served twice per turn of a key of weight 1.
+	heavy := []*rateLimitTicket{
+		m.rateLimitWaiters.join(model, rank, "heavy-key", 2),
+		m.rateLimitWaiters.join(model, rank, "heavy-key", 2),
+		m.rateLimitWaiters.join(model, rank, "heavy-key", 2),
+	}
+	light := m.rateLimitWaiters.join(model, rank, "light-key", 1)
+	for _, ticket := range heavy[:2] {
+		if !waitsForCooldown(ticket) || waitsForCooldown(light) {
+			t.Fatal("the heavy key should be served twice before the light key")
+		}
+		ticket.leave(true)
+	}
+	if !waitsForCooldown(light) || waitsForCooldown(heavy[2]) {
+		t.Fatal("the light key should be served after two heavy requests")
+	}
+	light.leave(true)
+	heavy[2].leave(true)
+
+	stats := m.RateLimitQueueStats()
+	var busyStats RateLimitQueueClientStats
+	for _, entry := range stats {
+		if entry.APIKey == "busy-key" {
+			busyStats = entry
+		}
+	}
+	if busyStats.Queued != 3 || busyStats.Served != 2 || busyStats.Expired != 1 || busyStats.Waiting != 0 {
+		t.Fatalf("busy key stats = %+v", busyStats)
+	}
+}
+
+func TestManager_AwaitRateLimitTurn_DispatchesInWeightedFairOrder(t *testing.T) {
+	m, model := newRateLimitQueueTestManager(t, time.Second)
+	m.SetRateLimitQueue(time.Minute)
+	rank := servicetier.Rank(servicetier.Default)
+
+	// The light key queues all its requests before the heavy key, of weight
+	// 2, queues any; their retries then contend for one credential at a time.
+	var tickets []*rateLimitTicket
+	for range 3 {
+		tickets = append(tickets, m.rateLimitWaiters.join(model, rank, "light", 1))
+	}
+	for range 3 {
+		tickets = append(tickets, m.rateLimitWaiters.join(model, rank, "heavy", 2))
+	}
+	var (
+		credential sync.Mutex
+		order      []string
+		wg         sync.WaitGroup
+	)
+	for _, ticket := range tickets {
+		wg.Add(1)
+		go func(ticket *rateLimitTicket) {
+			defer wg.Done()
+			if turn, errTurn := m.awaitRateLimitTurn(context.Background(), ticket); !turn || errTurn != nil {
+				t.Errorf("awaitRateLimitTurn(%s) = %v, %v", ticket.client, turn, errTurn)
+				return
+			}
+			credential.Lock()
+			defer credential.Unlock()
+			order = append(order, ticket.client)
+			ticket.leave(true)
+		}(ticket)
+	}
+	wg.Wait()
+
+	want := []string{"heavy", "light", "heavy", "heavy", "light", "light"}
+	if len(order) != len(want) {
+		t.Fatalf("dispatch order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("dispatch order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestManager_AwaitRateLimitTurn_GivesUpAtMaxWait(t *testing.T) {
+	m, model := newRateLimitQueueTestManager(t, time.Second)
+	m.SetRateLimitQueue(50 * time.Millisecond)
+	rank := servicetier.Rank(servicetier.Default)
+
+	ahead := m.rateLimitWaiters.join(model, rank, "first", 1)
+	defer ahead.leave(false)
+	behind := m.rateLimitWaiters.join(model, rank, "second", 1)
+	defer behind.leave(false)
+	if turn, errTurn := m.awaitRateLimitTurn(context.Background(), behind); turn || errTurn != nil {
+		t.Fatalf("awaitRateLimitTurn() = %v, %v; want it to give up", turn, errTurn)
+	}
+	if turn, _ := m.awaitRateLimitTurn(context.Background(), ahead); !turn {
+		t.Fatal("the request at the head of the queue was held")
+	}
+}
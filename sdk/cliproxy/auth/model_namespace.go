@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+)
+
+// ModelNamespacesAttr lists, comma separated, the model-namespaces prefixes a
+// credential serves. The synthesizer resolves it from the credential labels.
+const ModelNamespacesAttr = "model_namespaces"
+
+// ModelPrefixes returns every prefix the auth's models are published under:
+// its own prefix and the namespaces it belongs to, longest first so nested
+// namespaces match before their parents.
+func (a *Auth) ModelPrefixes() []string {
+	if a == nil {
+		return nil
+	}
+	var prefixes []string
+	seen := make(map[string]struct{})
+	add := func(prefix string) {
+		prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+		if prefix == "" {
+			return
+		}
+		if _, ok := seen[prefix]; ok {
+			return
+		}
+		seen[prefix] = struct{}{}
+		prefixes = append(prefixes, prefix)
+	}
+	add(a.Prefix)
+	if a.Attributes != nil {
+		for _, prefix := range strings.Split(a.Attributes[ModelNamespacesAttr], ",") {
+			add(prefix)
+		}
+	}
+	sort.SliceStable(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	return prefixes
+}
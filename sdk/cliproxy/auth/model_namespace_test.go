@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAuthModelPrefixes_NestedNamespacesStripLongestFirst(t *testing.T) {
+	auth := &Auth{
+		ID:     "ns-auth",
+		Prefix: "team",
+		Attributes: map[string]string{
+			ModelNamespacesAttr: "acme, acme/research,team",
+		},
+	}
+	if got, want := auth.ModelPrefixes(), []string{"acme/research", "team", "acme"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ModelPrefixes() = %v, want %v", got, want)
+	}
+
+	for model, want := range map[string]string{
+		"acme/research/gemini-2.5-pro": "gemini-2.5-pro",
+		"acme/gemini-2.5-pro":          "gemini-2.5-pro",
+		"team/gemini-2.5-pro":          "gemini-2.5-pro",
+		"other/gemini-2.5-pro":         "other/gemini-2.5-pro",
+		"gemini-2.5-pro":               "gemini-2.5-pro",
+	} {
+		if got := rewriteModelForAuth(model, auth); got != want {
+			t.Errorf("rewriteModelForAuth(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+// modelUnavailableMarkers are fragments of upstream error messages that mean
+// the model will never work with the credential, as opposed to failing for
+// the request at hand.
+var modelUnavailableMarkers = []string{
+	"model_not_found",
+	"model_not_supported",
+	"unsupported_model",
+	"model not found",
+	"model is not supported",
+	"model not supported",
+	"is not supported when using",
+	"does not exist or you do not have access",
+	"model does not exist",
+	"is not found for api version",
+	"not available for your account",
+	"unknown model",
+	"no access to model",
+}
+
+// ModelUnavailableEntry records that an upstream rejected Model for AuthID as
+// unavailable. The credential is skipped for the model until ExpiresAt.
+type ModelUnavailableEntry struct {
+	AuthID     string    `json:"auth_id"`
+	Model      string    `json:"model"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	CachedAt   time.Time `json:"cached_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type modelUnavailableKey struct {
+	authID string
+	model  string
+}
+
+// modelUnavailableCache is the negative cache of (auth, model) pairs. The
+// blocking itself goes through the model state's NextRetryAfter; the cache
+// keeps the reason and lets operators list and clear entries.
+type modelUnavailableCache struct {
+	mu      sync.Mutex
+	entries map[modelUnavailableKey]ModelUnavailableEntry
+}
+
+func (c *modelUnavailableCache) put(entry ModelUnavailableEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[modelUnavailableKey]ModelUnavailableEntry)
+	}
+	c.entries[modelUnavailableKey{authID: entry.AuthID, model: entry.Model}] = entry
+}
+
+func (c *modelUnavailableCache) remove(authID, model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, modelUnavailableKey{authID: authID, model: model})
+}
+
+// isModelUnavailableError reports whether err says the model is not
+// available for the credential at all.
+func isModelUnavailableError(err *Error) bool {
+	if err == nil {
+		return false
+	}
+	switch statusCodeFromResult(err) {
+	case 400, 403, 404, 422:
+	default:
+		return false
+	}
+	message := strings.ToLower(err.Code + " " + err.Message)
+	for _, marker := range modelUnavailableMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) modelUnavailableTTL() time.Duration {
+	seconds := internalconfig.DefaultModelUnavailableCacheSeconds
+	if cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config); cfg != nil && cfg.Routing.ModelUnavailableCacheSeconds != 0 {
+		seconds = cfg.Routing.ModelUnavailableCacheSeconds
+	}
+	if seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// UnavailableModels lists the unexpired negative cache entries, ordered by
+// auth ID and model.
+func (m *Manager) UnavailableModels() []ModelUnavailableEntry {
+	if m == nil {
+		return nil
+	}
+	now := time.Now()
+	c := &m.modelUnavailable
+	c.mu.Lock()
+	out := make([]ModelUnavailableEntry, 0, len(c.entries))
+	for key, entry := range c.entries {
+		if !entry.ExpiresAt.After(now) {
+			delete(c.entries, key)
+			continue
+		}
+		out = append(out, entry)
+	}
+	c.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].AuthID != out[j].AuthID {
+			return out[i].AuthID < out[j].AuthID
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// ClearUnavailableModels drops negative cache entries and makes the affected
+// credentials eligible for their models again. An empty authID or model
+// matches every entry. It returns the number of entries removed.
+func (m *Manager) ClearUnavailableModels(ctx context.Context, authID, model string) int {
+	if m == nil {
+		return 0
+	}
+	var cleared []modelUnavailableKey
+	c := &m.modelUnavailable
+	c.mu.Lock()
+	for key := range c.entries {
+		if (authID == "" || key.authID == authID) && (model == "" || key.model == model) {
+			cleared = append(cleared, key)
+			delete(c.entries, key)
+		}
+	}
+	c.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range cleared {
+		var snapshot *Auth
+		m.mu.Lock()
+		if auth, ok := m.auths[key.authID]; ok && auth != nil {
+			if state, okState := auth.ModelStates[key.model]; okState && state != nil {
+				resetModelState(state, now)
+				updateAggregatedAvailability(auth, now)
+				if !hasModelError(auth, now) {
+					auth.LastError = nil
+					auth.StatusMessage = ""
+					auth.Status = StatusActive
+				}
+				auth.UpdatedAt = now
+				_ = m.persist(ctx, auth)
+				snapshot = auth.Clone()
+			}
+		}
+		m.mu.Unlock()
+		if snapshot == nil {
+			continue
+		}
+		if m.scheduler != nil {
+			m.scheduler.upsertAuth(snapshot)
+		}
+		registry.GetGlobalRegistry().ResumeClientModel(key.authID, key.model)
+	}
+	return len(cleared)
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestManager_MarkResult_CachesUnavailableModels(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(&internalconfig.Config{Routing: internalconfig.RoutingConfig{ModelUnavailableCacheSeconds: 600}})
+	if _, errRegister := m.Register(ctx, &Auth{ID: "auth-1", Provider: "codex"}); errRegister != nil {
+		t.Fatalf("register auth: %v", errRegister)
+	}
+
+	// A plain bad request is not about the model and must not be cached.
+	m.MarkResult(ctx, Result{AuthID: "auth-1", Provider: "codex", Model: "gpt-5", Error: &Error{HTTPStatus: 400, Message: "invalid_request_error: messages is required"}})
+	if len(m.UnavailableModels()) != 0 || !m.AuthAvailableForModel("auth-1", "gpt-5") {
+		t.Fatal("unrelated 400 must not mark the model unavailable")
+	}
+
+	before := time.Now()
+	m.MarkResult(ctx, Result{AuthID: "auth-1", Provider: "codex", Model: "gpt-5", Error: &Error{HTTPStatus: 400, Message: "The 'gpt-5' model is not supported when using Codex with a ChatGPT account."}})
+	entries := m.UnavailableModels()
+	if len(entries) != 1 || entries[0].AuthID != "auth-1" || entries[0].Model != "gpt-5" || entries[0].StatusCode != 400 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if ttl := entries[0].ExpiresAt.Sub(before); ttl < 590*time.Second || ttl > 610*time.Second {
+		t.Fatalf("expires in %v, want the configured 600s", ttl)
+	}
+	if m.AuthAvailableForModel("auth-1", "gpt-5") {
+		t.Fatal("cached model should be skipped for the auth")
+	}
+	if !m.AuthAvailableForModel("auth-1", "gpt-5-mini") {
+		t.Fatal("other models must stay available")
+	}
+
+	if cleared := m.ClearUnavailableModels(ctx, "auth-1", ""); cleared != 1 {
+		t.Fatalf("cleared = %d, want 1", cleared)
+	}
+	if len(m.UnavailableModels()) != 0 || !m.AuthAvailableForModel("auth-1", "gpt-5") {
+		t.Fatal("clearing should make the model available again")
+	}
+
+	m.MarkResult(ctx, Result{AuthID: "auth-1", Provider: "codex", Model: "o3", Error: &Error{HTTPStatus: 404, Code: "model_not_found", Message: "not found"}})
+	m.MarkResult(ctx, Result{AuthID: "auth-1", Provider: "codex", Model: "o3", Success: true})
+	if len(m.UnavailableModels()) != 0 {
+		t.Fatal("a success should drop the cache entry")
+	}
+}
+
+func TestManager_ModelUnavailableCacheDisabled(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(&internalconfig.Config{Routing: internalconfig.RoutingConfig{ModelUnavailableCacheSeconds: -1}})
+	if _, errRegister := m.Register(ctx, &Auth{ID: "auth-1", Provider: "claude"}); errRegister != nil {
+		t.Fatalf("register auth: %v", errRegister)
+	}
+	m.MarkResult(ctx, Result{AuthID: "auth-1", Provider: "claude", Model: "claude-x", Error: &Error{HTTPStatus: 400, Message: "model_not_supported"}})
+	if len(m.UnavailableModels()) != 0 || !m.AuthAvailableForModel("auth-1", "claude-x") {
+		t.Fatal("negative TTL should disable the cache")
+	}
+}
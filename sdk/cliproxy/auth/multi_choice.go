@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// maxEmulatedChoices bounds the parallel upstream calls one request may fan
+// out to.
+const maxEmulatedChoices = 16
+
+// multiChoiceProviders translate a chat completion's n into a native
+// candidate count, so their upstream returns every choice itself.
+var multiChoiceProviders = map[string]bool{
+	"gemini":               true,
+	"vertex":               true,
+	"gemini-cli":           true,
+	"aistudio":             true,
+	"antigravity":          true,
+	"openai-compatibility": true,
+}
+
+// multiChoiceExecutor emulates n > 1 chat completion choices for providers
+// that return a single one: the request is sent n times in parallel without
+// n, and the answers are merged as choices 0 to n-1 with summed usage.
+type multiChoiceExecutor struct {
+	ProviderExecutor
+	n int
+}
+
+// withMultiChoice wraps executor when a chat completion asks auth for more
+// than one choice and its provider cannot produce them. Other executors are
+// returned unchanged.
+func withMultiChoice(executor ProviderExecutor, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) ProviderExecutor {
+	if executor == nil || auth == nil || opts.SourceFormat != sdktranslator.FormatOpenAI || opts.Alt != "" {
+		return executor
+	}
+	n := int(gjson.GetBytes(req.Payload, "n").Int())
+	if n <= 1 || nativeMultiChoice(auth) {
+		return executor
+	}
+	return &multiChoiceExecutor{ProviderExecutor: executor, n: n}
+}
+
+func nativeMultiChoice(auth *Auth) bool {
+	if auth.Attributes["compat_name"] != "" {
+		return true
+	}
+	return multiChoiceProviders[strings.ToLower(strings.TrimSpace(auth.Provider))]
+}
+
+func (e *multiChoiceExecutor) validate() error {
+	if e.n > maxEmulatedChoices {
+		return &Error{Code: "invalid_request", Message: fmt.Sprintf("n must be at most %d for this model", maxEmulatedChoices), HTTPStatus: http.StatusBadRequest}
+	}
+	return nil
+}
+
+func (e *multiChoiceExecutor) Execute(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if err := e.validate(); err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	req, opts = withoutChoiceCount(req, opts)
+	branchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	responses := make([]cliproxyexecutor.Response, e.n)
+	errs := make([]error, e.n)
+	var wg sync.WaitGroup
+	for i := range e.n {
+		wg.Go(func() {
+			responses[i], errs[i] = e.ProviderExecutor.Execute(branchCtx, auth, req, opts)
+			if errs[i] != nil {
+				cancel()
+			}
+		})
+	}
+	wg.Wait()
+	// The first failure cancels the other branches; report it rather than
+	// the cancellations it caused.
+	var firstErr error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if firstErr == nil || errors.Is(firstErr, context.Canceled) && !errors.Is(err, context.Canceled) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return cliproxyexecutor.Response{}, firstErr
+	}
+
+	payloads := make([][]byte, e.n)
+	for i, resp := range responses {
+		payloads[i] = resp.Payload
+	}
+	merged, errMerge := mergeChoiceResponses(payloads)
+	if errMerge != nil {
+		return cliproxyexecutor.Response{}, &Error{Code: "multi_choice_failed", Message: errMerge.Error(), Retryable: true}
+	}
+	return cliproxyexecutor.Response{Payload: merged, Headers: responses[0].Headers}, nil
+}
+
+func (e *multiChoiceExecutor) ExecuteStream(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	req, opts = withoutChoiceCount(req, opts)
+	branchCtx, cancel := context.WithCancel(ctx)
+
+	results := make([]*cliproxyexecutor.StreamResult, 0, e.n)
+	for range e.n {
+		result, err := e.ProviderExecutor.ExecuteStream(branchCtx, auth, req, opts)
+		if err == nil && result == nil {
+			err = &Error{Code: "multi_choice_failed", Message: "empty stream result", Retryable: true}
+		}
+		if err != nil {
+			cancel()
+			for _, started := range results {
+				discardStreamChunks(started.Chunks)
+			}
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	merger := &choiceStreamMerger{}
+	out := make(chan cliproxyexecutor.StreamChunk)
+	var wg sync.WaitGroup
+	for i, result := range results {
+		wg.Go(func() {
+			for chunk := range result.Chunks {
+				if chunk.Err != nil {
+					cancel()
+				} else if chunk.Payload = merger.rewrite(chunk.Payload, i); len(chunk.Payload) == 0 {
+					continue
+				}
+				select {
+				case out <- chunk:
+				case <-ctx.Done():
+					discardStreamChunks(result.Chunks)
+					return
+				}
+			}
+		})
+	}
+	go func() {
+		defer close(out)
+		defer cancel()
+		wg.Wait()
+		if usage := merger.usageChunk(); usage != nil {
+			select {
+			case out <- cliproxyexecutor.StreamChunk{Payload: usage}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return &cliproxyexecutor.StreamResult{Headers: results[0].Headers, Chunks: out}, nil
+}
+
+// withoutChoiceCount drops n from the client request sent on each branch.
+func withoutChoiceCount(req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Request, cliproxyexecutor.Options) {
+	if updated, errDelete := sjson.DeleteBytes(req.Payload, "n"); errDelete == nil {
+		req.Payload = updated
+	}
+	if gjson.GetBytes(opts.OriginalRequest, "n").Exists() {
+		if updated, errDelete := sjson.DeleteBytes(opts.OriginalRequest, "n"); errDelete == nil {
+			opts.OriginalRequest = updated
+		}
+	}
+	return req, opts
+}
+
+// mergeChoiceResponses merges single-choice chat completions into one
+// response whose choices are numbered in branch order.
+func mergeChoiceResponses(payloads [][]byte) ([]byte, error) {
+	var choices []string
+	usage := "{}"
+	usageSeen := false
+	for i, payload := range payloads {
+		if !gjson.ValidBytes(payload) {
+			return nil, fmt.Errorf("choice %d: response is not valid JSON", i)
+		}
+		for _, choice := range gjson.GetBytes(payload, "choices").Array() {
+			renumbered, _ := sjson.Set(choice.Raw, "index", len(choices))
+			choices = append(choices, renumbered)
+		}
+		if u := gjson.GetBytes(payload, "usage"); u.IsObject() {
+			usage = addUsage(usage, u)
+			usageSeen = true
+		}
+	}
+	out, errSet := sjson.SetRawBytes(payloads[0], "choices", []byte("["+strings.Join(choices, ",")+"]"))
+	if errSet != nil {
+		return nil, errSet
+	}
+	if usageSeen {
+		out, _ = sjson.SetRawBytes(out, "usage", []byte(usage))
+	}
+	return out, nil
+}
+
+// addUsage adds every numeric field of u, including nested details, to sum.
+func addUsage(sum string, u gjson.Result) string {
+	var walk func(prefix string, value gjson.Result)
+	walk = func(prefix string, value gjson.Result) {
+		value.ForEach(func(key, field gjson.Result) bool {
+			path := prefix + key.String()
+			switch {
+			case field.IsObject():
+				walk(path+".", field)
+			case field.Type == gjson.Number:
+				sum, _ = sjson.Set(sum, path, gjson.Get(sum, path).Int()+field.Int())
+			}
+			return true
+		})
+	}
+	walk("", u)
+	return sum
+}
+
+// choiceStreamMerger rewrites the chunks of parallel single-choice streams
+// into one multi-choice stream: choices take their branch's index, chunks
+// share the first stream's ID, and usage is held back and reported once all
+// branches have finished.
+type choiceStreamMerger struct {
+	mu        sync.Mutex
+	id        string
+	envelope  string
+	usage     string
+	usageSeen bool
+}
+
+// rewrite returns chunk as part of the merged stream, or nil to drop it.
+func (m *choiceStreamMerger) rewrite(chunk []byte, branch int) []byte {
+	trimmed, prefixed := chatChunkData(chunk)
+	if bytes.Equal(trimmed, []byte("[DONE]")) {
+		return nil
+	}
+	if !gjson.ValidBytes(trimmed) {
+		return chunk
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := string(trimmed)
+	if id := gjson.Get(out, "id").String(); id != "" {
+		if m.id == "" {
+			m.id = id
+			m.envelope = out
+		}
+		out, _ = sjson.Set(out, "id", m.id)
+	}
+	if u := gjson.Get(out, "usage"); u.IsObject() {
+		if !m.usageSeen {
+			m.usage = "{}"
+			m.usageSeen = true
+		}
+		m.usage = addUsage(m.usage, u)
+		out, _ = sjson.Delete(out, "usage")
+	}
+	choices := gjson.Get(out, "choices")
+	if !choices.IsArray() {
+		return chunk
+	}
+	if len(choices.Array()) == 0 {
+		return nil
+	}
+	choices.ForEach(func(i, choice gjson.Result) bool {
+		out, _ = sjson.Set(out, "choices."+i.String()+".index", branch+int(choice.Get("index").Int()))
+		return true
+	})
+	return chatChunkFrame(out, prefixed)
+}
+
+// usageChunk returns the chunk reporting the usage of every branch, or nil
+// when no branch reported usage.
+func (m *choiceStreamMerger) usageChunk() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.usageSeen {
+		return nil
+	}
+	chunk := `{"object":"chat.completion.chunk","choices":[]}`
+	for _, key := range []string{"id", "created", "model", "system_fingerprint"} {
+		if value := gjson.Get(m.envelope, key); value.Exists() {
+			chunk, _ = sjson.SetRaw(chunk, key, value.Raw)
+		}
+	}
+	chunk, _ = sjson.SetRaw(chunk, "usage", m.usage)
+	return []byte(chunk)
+}
+
+// chatChunkData returns the JSON of a chat completion stream chunk, which
+// executors emit either bare or as an SSE data line, and whether it was
+// prefixed.
+func chatChunkData(chunk []byte) ([]byte, bool) {
+	trimmed := bytes.TrimSpace(chunk)
+	if rest, ok := bytes.CutPrefix(trimmed, []byte("data:")); ok {
+		return bytes.TrimSpace(rest), true
+	}
+	return trimmed, false
+}
+
+// chatChunkFrame frames data the way chatChunkData found it.
+func chatChunkFrame(data string, prefixed bool) []byte {
+	if prefixed {
+		return []byte("data: " + data)
+	}
+	return []byte(data)
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// choiceExecutor answers every call with one chat completion choice whose
+// content names the call.
+type choiceExecutor struct {
+	streamStyleExecutor
+	calls atomic.Int32
+	sawN  atomic.Bool
+}
+
+func (e *choiceExecutor) next(payload []byte) string {
+	if gjson.GetBytes(payload, "n").Exists() {
+		e.sawN.Store(true)
+	}
+	return string(rune('a' + e.calls.Add(1) - 1))
+}
+
+func (e *choiceExecutor) Execute(_ context.Context, _ *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	name := e.next(req.Payload)
+	return cliproxyexecutor.Response{Payload: []byte(`{"id":"chatcmpl-` + name + `","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"` + name + `"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`)}, nil
+}
+
+func (e *choiceExecutor) ExecuteStream(_ context.Context, _ *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	name := e.next(req.Payload)
+	ch := make(chan cliproxyexecutor.StreamChunk, 4)
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte(`{"id":"chatcmpl-` + name + `","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"` + name + `"}}]}`)}
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte(`data: {"id":"chatcmpl-` + name + `","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)}
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte(`{"id":"chatcmpl-` + name + `","object":"chat.completion.chunk","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`)}
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte(`data: [DONE]`)}
+	close(ch)
+	return &cliproxyexecutor.StreamResult{Chunks: ch}, nil
+}
+
+func multiChoiceRequest(n string) (cliproxyexecutor.Request, cliproxyexecutor.Options) {
+	payload := []byte(`{"model":"m","n":` + n + `,"messages":[{"role":"user","content":"hi"}]}`)
+	return cliproxyexecutor.Request{Model: "m", Payload: payload}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatOpenAI, OriginalRequest: payload}
+}
+
+func TestWithMultiChoice_PassesThroughNativeProviders(t *testing.T) {
+	req, opts := multiChoiceRequest("3")
+	upstream := &choiceExecutor{}
+	for _, auth := range []*Auth{
+		{Provider: "gemini"},
+		{Provider: "my-compat", Attributes: map[string]string{"compat_name": "my-compat"}},
+	} {
+		if got := withMultiChoice(upstream, auth, req, opts); got != ProviderExecutor(upstream) {
+			t.Fatalf("provider %q should handle n itself", auth.Provider)
+		}
+	}
+	single, singleOpts := multiChoiceRequest("1")
+	if got := withMultiChoice(upstream, &Auth{Provider: "claude"}, single, singleOpts); got != ProviderExecutor(upstream) {
+		t.Fatal("n=1 should not be emulated")
+	}
+}
+
+func TestMultiChoiceExecutor_MergesResponses(t *testing.T) {
+	req, opts := multiChoiceRequest("3")
+	upstream := &choiceExecutor{}
+	executor := withMultiChoice(upstream, &Auth{Provider: "claude"}, req, opts)
+
+	resp, err := executor.Execute(context.Background(), nil, req, opts)
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if upstream.calls.Load() != 3 || upstream.sawN.Load() {
+		t.Fatalf("calls = %d, n forwarded = %v", upstream.calls.Load(), upstream.sawN.Load())
+	}
+	if got := gjson.GetBytes(resp.Payload, "choices.#.index").String(); got != "[0,1,2]" {
+		t.Fatalf("choice indices = %s", got)
+	}
+	contents := gjson.GetBytes(resp.Payload, "choices.#.message.content").String()
+	for _, name := range []string{`"a"`, `"b"`, `"c"`} {
+		if !strings.Contains(contents, name) {
+			t.Fatalf("contents = %s", contents)
+		}
+	}
+	if got := gjson.GetBytes(resp.Payload, "usage.total_tokens").Int(); got != 12 {
+		t.Fatalf("total tokens = %d", got)
+	}
+}
+
+func TestMultiChoiceExecutor_MergesStreams(t *testing.T) {
+	req, opts := multiChoiceRequest("2")
+	executor := withMultiChoice(&choiceExecutor{}, &Auth{Provider: "codex"}, req, opts)
+
+	result, err := executor.ExecuteStream(context.Background(), nil, req, opts)
+	if err != nil {
+		t.Fatalf("ExecuteStream error: %v", err)
+	}
+	var chunks []string
+	for chunk := range result.Chunks {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		chunks = append(chunks, string(chunk.Payload))
+	}
+	if len(chunks) != 5 {
+		t.Fatalf("chunks = %d: %q", len(chunks), chunks)
+	}
+	ids := map[string]bool{}
+	finished := map[int64]bool{}
+	for _, chunk := range chunks[:4] {
+		if strings.Contains(chunk, "[DONE]") || strings.Contains(chunk, "usage") {
+			t.Fatalf("branch chunk leaked: %s", chunk)
+		}
+		data := gjson.Parse(strings.TrimPrefix(chunk, "data: "))
+		ids[data.Get("id").String()] = true
+		if data.Get("choices.0.finish_reason").String() == "stop" {
+			finished[data.Get("choices.0.index").Int()] = true
+		}
+	}
+	if len(ids) != 1 || !finished[0] || !finished[1] {
+		t.Fatalf("ids = %v, finished = %v", ids, finished)
+	}
+	last := gjson.Parse(chunks[4])
+	if last.Get("usage.completion_tokens").Int() != 2 || len(last.Get("choices").Array()) != 0 {
+		t.Fatalf("usage chunk = %s", chunks[4])
+	}
+}
+
+func TestMultiChoiceExecutor_RejectsTooManyChoices(t *testing.T) {
+	req, opts := multiChoiceRequest("17")
+	executor := withMultiChoice(&choiceExecutor{}, &Auth{Provider: "claude"}, req, opts)
+	_, err := executor.Execute(context.Background(), nil, req, opts)
+	if authErr, ok := err.(*Error); !ok || authErr.HTTPStatus != 400 {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// Response defects detected by the quality guard.
+const (
+	DefectEmptyContent     = "empty_content"
+	DefectImmediateStop    = "immediate_stop"
+	DefectMalformedToolArg = "malformed_tool_json"
+)
+
+// Recovery actions recorded on the usage of retried attempts.
+const (
+	RecoveryRetrySameCredential = "retry_same_credential"
+	RecoveryRetryNextCredential = "retry_next_credential"
+)
+
+// SetQualityGuard sets how many times non-streaming requests are retried after
+// a pathological response, and how many of those retries stay on the same
+// credential. maxRetries <= 0 disables the guard.
+func (m *Manager) SetQualityGuard(maxRetries, sameCredentialRetries int) {
+	if m == nil {
+		return
+	}
+	m.qualityMaxRetries.Store(int32(max(maxRetries, 0)))
+	m.qualitySameRetries.Store(int32(max(sameCredentialRetries, 0)))
+}
+
+// qualityGuard tracks the quality retries of one request.
+type qualityGuard struct {
+	maxRetries  int
+	sameRetries int
+
+	retries     int
+	sameUsed    int
+	recovery    string
+	fallback    cliproxyexecutor.Response
+	hasFallback bool
+}
+
+// newQualityGuard returns nil when the guard is disabled.
+func (m *Manager) newQualityGuard() *qualityGuard {
+	maxRetries := int(m.qualityMaxRetries.Load())
+	if maxRetries <= 0 {
+		return nil
+	}
+	return &qualityGuard{maxRetries: maxRetries, sameRetries: int(m.qualitySameRetries.Load())}
+}
+
+// tag marks ctx with the recovery action that led to the coming attempt.
+func (g *qualityGuard) tag(ctx context.Context) context.Context {
+	if g == nil || g.recovery == "" {
+		return ctx
+	}
+	return cliproxyexecutor.WithRecoveryAction(ctx, g.recovery)
+}
+
+// check inspects resp and returns the recovery action to take, or "" to hand
+// the response to the client. A defective response is kept as the fallback
+// returned when no further attempt succeeds.
+func (g *qualityGuard) check(format sdktranslator.Format, resp cliproxyexecutor.Response) (action, defect string) {
+	if g == nil {
+		return "", ""
+	}
+	defect = ResponseDefect(format, resp.Payload)
+	if defect == "" || g.retries >= g.maxRetries {
+		return "", defect
+	}
+	g.fallback, g.hasFallback = resp, true
+	g.retries++
+	action = RecoveryRetryNextCredential
+	if g.sameUsed < g.sameRetries {
+		g.sameUsed++
+		action = RecoveryRetrySameCredential
+	} else {
+		g.sameUsed = 0
+	}
+	g.recovery = action + ":" + defect
+	return action, defect
+}
+
+// fallbackResponse returns the last defective response, if any.
+func (g *qualityGuard) fallbackResponse() (cliproxyexecutor.Response, bool) {
+	if g == nil || !g.hasFallback {
+		return cliproxyexecutor.Response{}, false
+	}
+	return g.fallback, true
+}
+
+// ResponseDefect reports why a non-streaming response in the given client
+// format is pathological: it has no text or tool call, it stopped
+// before producing any, or a tool call's arguments are not valid JSON. It
+// returns "" for sound responses and formats it does not know.
+func ResponseDefect(format sdktranslator.Format, payload []byte) string {
+	if len(payload) == 0 {
+		return DefectEmptyContent
+	}
+	root := gjson.ParseBytes(payload)
+	switch format {
+	case sdktranslator.FormatOpenAI:
+		return openAIChatDefect(root)
+	case sdktranslator.FormatOpenAIResponse:
+		return openAIResponsesDefect(root)
+	case sdktranslator.FormatClaude:
+		return claudeDefect(root)
+	case sdktranslator.FormatGemini, sdktranslator.FormatGeminiCLI:
+		if inner := root.Get("response"); inner.IsObject() {
+			root = inner
+		}
+		return geminiDefect(root)
+	default:
+		return ""
+	}
+}
+
+// emptyDefect distinguishes an upstream that ended the turn normally without
+// output from one that returned nothing usable.
+func emptyDefect(stopReason string, normalStops ...string) string {
+	for _, stop := range normalStops {
+		if strings.EqualFold(stopReason, stop) {
+			return DefectImmediateStop
+		}
+	}
+	return DefectEmptyContent
+}
+
+func validToolArguments(args gjson.Result) bool {
+	if args.Type != gjson.String {
+		return true
+	}
+	raw := strings.TrimSpace(args.String())
+	return raw == "" || gjson.Valid(raw)
+}
+
+func openAIChatDefect(root gjson.Result) string {
+	choice := root.Get("choices.0")
+	if !choice.Exists() {
+		return DefectEmptyContent
+	}
+	toolCalls := choice.Get("message.tool_calls").Array()
+	for _, call := range toolCalls {
+		if !validToolArguments(call.Get("function.arguments")) {
+			return DefectMalformedToolArg
+		}
+	}
+	if len(toolCalls) > 0 || strings.TrimSpace(choice.Get("message.content").String()) != "" || choice.Get("finish_reason").String() == "content_filter" {
+		return ""
+	}
+	return emptyDefect(choice.Get("finish_reason").String(), "stop")
+}
+
+func openAIResponsesDefect(root gjson.Result) string {
+	hasOutput := false
+	for _, item := range root.Get("output").Array() {
+		switch item.Get("type").String() {
+		case "function_call":
+			if !validToolArguments(item.Get("arguments")) {
+				return DefectMalformedToolArg
+			}
+			hasOutput = true
+		case "message":
+			for _, part := range item.Get("content").Array() {
+				if strings.TrimSpace(part.Get("text").String()) != "" || part.Get("refusal").String() != "" {
+					hasOutput = true
+				}
+			}
+		case "reasoning":
+		default:
+			// Built-in tool calls such as web_search count as output.
+			hasOutput = true
+		}
+	}
+	if hasOutput {
+		return ""
+	}
+	return emptyDefect(root.Get("status").String(), "completed")
+}
+
+func claudeDefect(root gjson.Result) string {
+	if root.Get("type").String() == "error" || root.Get("stop_reason").String() == "refusal" {
+		return ""
+	}
+	for _, block := range root.Get("content").Array() {
+		switch block.Get("type").String() {
+		case "text":
+			if strings.TrimSpace(block.Get("text").String()) != "" {
+				return ""
+			}
+		case "thinking", "redacted_thinking":
+		default:
+			return ""
+		}
+	}
+	return emptyDefect(root.Get("stop_reason").String(), "end_turn", "stop_sequence")
+}
+
+func geminiDefect(root gjson.Result) string {
+	candidate := root.Get("candidates.0")
+	if !candidate.Exists() {
+		// Prompts blocked by safety filters carry promptFeedback only.
+		if root.Get("promptFeedback.blockReason").Exists() {
+			return ""
+		}
+		return DefectEmptyContent
+	}
+	for _, part := range candidate.Get("content.parts").Array() {
+		if part.Get("thought").Bool() {
+			continue
+		}
+		if part.Get("functionCall").Exists() || strings.TrimSpace(part.Get("text").String()) != "" || part.Get("inlineData").Exists() {
+			return ""
+		}
+	}
+	if reason := candidate.Get("finishReason").String(); reason != "" && !strings.EqualFold(reason, "STOP") && !strings.EqualFold(reason, "MAX_TOKENS") {
+		// Safety, recitation and similar stops are deliberate, not upstream faults.
+		return ""
+	}
+	return emptyDefect(candidate.Get("finishReason").String(), "STOP")
+}
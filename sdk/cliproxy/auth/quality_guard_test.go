@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestResponseDefect(t *testing.T) {
+	cases := []struct {
+		name    string
+		format  sdktranslator.Format
+		payload string
+		want    string
+	}{
+		{"openai ok", sdktranslator.FormatOpenAI, `{"choices":[{"message":{"content":"hi"},"finish_reason":"stop"}]}`, ""},
+		{"openai tool call", sdktranslator.FormatOpenAI, `{"choices":[{"message":{"content":null,"tool_calls":[{"function":{"name":"f","arguments":"{\"a\":1}"}}]},"finish_reason":"tool_calls"}]}`, ""},
+		{"openai immediate stop", sdktranslator.FormatOpenAI, `{"choices":[{"message":{"content":""},"finish_reason":"stop"}]}`, DefectImmediateStop},
+		{"openai no choices", sdktranslator.FormatOpenAI, `{"choices":[]}`, DefectEmptyContent},
+		{"openai bad tool json", sdktranslator.FormatOpenAI, `{"choices":[{"message":{"tool_calls":[{"function":{"name":"f","arguments":"{\"a\":"}}]}}]}`, DefectMalformedToolArg},
+		{"openai content filter", sdktranslator.FormatOpenAI, `{"choices":[{"message":{"content":""},"finish_reason":"content_filter"}]}`, ""},
+		{"claude ok", sdktranslator.FormatClaude, `{"content":[{"type":"thinking","thinking":"x"},{"type":"text","text":"hi"}],"stop_reason":"end_turn"}`, ""},
+		{"claude thinking only", sdktranslator.FormatClaude, `{"content":[{"type":"thinking","thinking":"x"}],"stop_reason":"end_turn"}`, DefectImmediateStop},
+		{"claude max tokens", sdktranslator.FormatClaude, `{"content":[],"stop_reason":"max_tokens"}`, DefectEmptyContent},
+		{"claude tool use", sdktranslator.FormatClaude, `{"content":[{"type":"tool_use","input":{}}],"stop_reason":"tool_use"}`, ""},
+		{"gemini ok", sdktranslator.FormatGemini, `{"candidates":[{"content":{"parts":[{"text":"hi"}]},"finishReason":"STOP"}]}`, ""},
+		{"gemini-cli empty", sdktranslator.FormatGeminiCLI, `{"response":{"candidates":[{"content":{"parts":[{"text":"t","thought":true}]},"finishReason":"STOP"}]}}`, DefectImmediateStop},
+		{"gemini safety", sdktranslator.FormatGemini, `{"candidates":[{"finishReason":"SAFETY"}]}`, ""},
+		{"responses ok", sdktranslator.FormatOpenAIResponse, `{"status":"completed","output":[{"type":"message","content":[{"type":"output_text","text":"hi"}]}]}`, ""},
+		{"responses reasoning only", sdktranslator.FormatOpenAIResponse, `{"status":"completed","output":[{"type":"reasoning"}]}`, DefectImmediateStop},
+		{"responses bad tool json", sdktranslator.FormatOpenAIResponse, `{"output":[{"type":"function_call","arguments":"{"}]}`, DefectMalformedToolArg},
+		{"unknown format", sdktranslator.FormatCodex, `{}`, ""},
+	}
+	for _, tc := range cases {
+		if got := ResponseDefect(tc.format, []byte(tc.payload)); got != tc.want {
+			t.Errorf("%s: ResponseDefect() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// qualityGuardExecutor returns an empty completion from bad auths and a sound
+// one from every other auth, recording the recovery action of each attempt.
+type qualityGuardExecutor struct {
+	schedulerTestExecutor
+	bad map[string]bool
+
+	mu       sync.Mutex
+	attempts []string
+}
+
+func (e *qualityGuardExecutor) Identifier() string { return "claude" }
+
+func (e *qualityGuardExecutor) Execute(ctx context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.mu.Lock()
+	e.attempts = append(e.attempts, auth.ID+"|"+cliproxyexecutor.RecoveryAction(ctx))
+	e.mu.Unlock()
+	if e.bad[auth.ID] {
+		return cliproxyexecutor.Response{Payload: []byte(`{"choices":[{"message":{"content":""},"finish_reason":"stop"}]}`)}, nil
+	}
+	return cliproxyexecutor.Response{Payload: []byte(`{"choices":[{"message":{"content":"hi"},"finish_reason":"stop"}]}`)}, nil
+}
+
+func newQualityGuardTestManager(t *testing.T, exec *qualityGuardExecutor, ids ...string) (*Manager, string) {
+	t.Helper()
+	m := NewManager(nil, &FillFirstSelector{}, nil)
+	m.RegisterExecutor(exec)
+	model := "quality-model-" + uuid.NewString()
+	reg := registry.GetGlobalRegistry()
+	for _, id := range ids {
+		reg.RegisterClient(id, "claude", []*registry.ModelInfo{{ID: model}})
+		if _, errRegister := m.Register(context.Background(), &Auth{ID: id, Provider: "claude"}); errRegister != nil {
+			t.Fatalf("register auth: %v", errRegister)
+		}
+	}
+	t.Cleanup(func() {
+		for _, id := range ids {
+			reg.UnregisterClient(id)
+		}
+	})
+	return m, model
+}
+
+func TestManager_QualityGuard_RetriesSameThenNextCredential(t *testing.T) {
+	first, second := "qa-"+uuid.NewString(), "qb-"+uuid.NewString()
+	if first > second {
+		first, second = second, first
+	}
+	exec := &qualityGuardExecutor{bad: map[string]bool{first: true}}
+	m, model := newQualityGuardTestManager(t, exec, first, second)
+	m.SetQualityGuard(2, 1)
+
+	opts := cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatOpenAI}
+	resp, err := m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: model}, opts)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if ResponseDefect(sdktranslator.FormatOpenAI, resp.Payload) != "" {
+		t.Fatalf("Execute() returned a defective response: %s", resp.Payload)
+	}
+	want := []string{
+		first + "|",
+		first + "|retry_same_credential:immediate_stop",
+		second + "|retry_next_credential:immediate_stop",
+	}
+	if len(exec.attempts) != len(want) {
+		t.Fatalf("attempts = %v, want %v", exec.attempts, want)
+	}
+	for i := range want {
+		if exec.attempts[i] != want[i] {
+			t.Fatalf("attempt %d = %q, want %q", i, exec.attempts[i], want[i])
+		}
+	}
+}
+
+func TestManager_QualityGuard_ReturnsDefectiveResponseWithoutOtherCredential(t *testing.T) {
+	only := "qc-" + uuid.NewString()
+	exec := &qualityGuardExecutor{bad: map[string]bool{only: true}}
+	m, model := newQualityGuardTestManager(t, exec, only)
+	m.SetQualityGuard(3, 0)
+
+	opts := cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatOpenAI}
+	resp, err := m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: model}, opts)
+	if err != nil {
+		t.Fatalf("Execute() error = %v, want the defective response", err)
+	}
+	if ResponseDefect(sdktranslator.FormatOpenAI, resp.Payload) != DefectImmediateStop {
+		t.Fatalf("Execute() = %s", resp.Payload)
+	}
+	if len(exec.attempts) != 1 {
+		t.Fatalf("attempts = %v, want a single attempt with no other credential", exec.attempts)
+	}
+
+	m.SetQualityGuard(0, 0)
+	exec.attempts = nil
+	if _, err = m.Execute(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: model}, opts); err != nil || len(exec.attempts) != 1 {
+		t.Fatalf("disabled guard: err = %v, attempts = %v", err, exec.attempts)
+	}
+}
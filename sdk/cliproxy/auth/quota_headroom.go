@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ratelimit"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// lowQuotaHeadroom is the remaining share of a quota window below which an
+// auth is passed over while a peer of the same priority has more left.
+const lowQuotaHeadroom = 0.1
+
+// QuotaWindow is one limit an upstream reported for a credential, such as
+// requests per minute or a five-hour usage allowance.
+type QuotaWindow struct {
+	Name      string  `json:"name"`
+	Limit     float64 `json:"limit,omitempty"`
+	Remaining float64 `json:"remaining,omitempty"`
+	// RemainingFraction is the share of the window left, from 0 to 1.
+	RemainingFraction float64   `json:"remaining_fraction"`
+	ResetAt           time.Time `json:"reset_at,omitempty"`
+}
+
+// QuotaHeadroom is the most recent quota state an upstream reported for a
+// credential through its response headers or quota errors.
+type QuotaHeadroom struct {
+	// Source names the report the windows were read from, e.g. "anthropic".
+	Source    string        `json:"source"`
+	Windows   []QuotaWindow `json:"windows"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// RemainingFraction returns the smallest share left across windows that have
+// not reset by now, or 1 when none apply.
+func (h QuotaHeadroom) RemainingFraction(now time.Time) float64 {
+	fraction := 1.0
+	for _, window := range h.Windows {
+		if !window.ResetAt.IsZero() && !window.ResetAt.After(now) {
+			continue
+		}
+		if window.RemainingFraction < fraction {
+			fraction = window.RemainingFraction
+		}
+	}
+	return fraction
+}
+
+var (
+	quotaHeadrooms sync.Map // auth ID -> QuotaHeadroom
+	// quotaHeadroomTracked lets selection skip lookups until any upstream has
+	// reported quota.
+	quotaHeadroomTracked atomic.Bool
+)
+
+// RecordQuotaHeadroom stores the quota an upstream reported for authID,
+// replacing the previous report.
+func RecordQuotaHeadroom(authID string, headroom QuotaHeadroom) {
+	if authID == "" || len(headroom.Windows) == 0 {
+		return
+	}
+	if headroom.UpdatedAt.IsZero() {
+		headroom.UpdatedAt = time.Now()
+	}
+	quotaHeadrooms.Store(authID, headroom)
+	quotaHeadroomTracked.Store(true)
+}
+
+// QuotaHeadroomFor returns the last quota report for authID.
+func QuotaHeadroomFor(authID string) (QuotaHeadroom, bool) {
+	value, ok := quotaHeadrooms.Load(authID)
+	if !ok {
+		return QuotaHeadroom{}, false
+	}
+	return value.(QuotaHeadroom), true
+}
+
+// QuotaHeadrooms returns the last quota report of every credential, keyed by
+// auth ID.
+func QuotaHeadrooms() map[string]QuotaHeadroom {
+	out := make(map[string]QuotaHeadroom)
+	quotaHeadrooms.Range(func(key, value any) bool {
+		out[key.(string)] = value.(QuotaHeadroom)
+		return true
+	})
+	return out
+}
+
+// quotaHeadroomLow reports whether authID last reported less than
+// lowQuotaHeadroom of a quota window that has not reset yet.
+func quotaHeadroomLow(authID string, now time.Time) bool {
+	if !quotaHeadroomTracked.Load() {
+		return false
+	}
+	headroom, ok := QuotaHeadroomFor(authID)
+	return ok && headroom.RemainingFraction(now) < lowQuotaHeadroom
+}
+
+// preferQuotaHeadroom drops auths low on quota when any candidate has more
+// left, so they are only used once every peer is equally constrained.
+func preferQuotaHeadroom(auths []*Auth, now time.Time) []*Auth {
+	if len(auths) < 2 || !quotaHeadroomTracked.Load() {
+		return auths
+	}
+	roomy := make([]*Auth, 0, len(auths))
+	for _, auth := range auths {
+		if !quotaHeadroomLow(auth.ID, now) {
+			roomy = append(roomy, auth)
+		}
+	}
+	if len(roomy) == 0 {
+		return auths
+	}
+	return roomy
+}
+
+// withRateLimitReport records on headers the tightest quota window the
+// upstream last reported for auth, when the request asked for it. Windows
+// reported only as a share, without counts, are not reported.
+func withRateLimitReport(headers http.Header, auth *Auth, opts cliproxyexecutor.Options, now time.Time) http.Header {
+	if wanted, _ := opts.Metadata[cliproxyexecutor.RateLimitHeadersMetadataKey].(bool); !wanted || auth == nil {
+		return headers
+	}
+	headroom, ok := QuotaHeadroomFor(auth.ID)
+	if !ok {
+		return headers
+	}
+	var tightest *QuotaWindow
+	for i := range headroom.Windows {
+		window := &headroom.Windows[i]
+		if !window.ResetAt.IsZero() && !window.ResetAt.After(now) {
+			continue
+		}
+		if tightest == nil || window.RemainingFraction < tightest.RemainingFraction {
+			tightest = window
+		}
+	}
+	if tightest == nil || tightest.Limit <= 0 {
+		return headers
+	}
+	return ratelimit.Set(headers, tightest.Limit, tightest.Remaining, tightest.ResetAt, now)
+}
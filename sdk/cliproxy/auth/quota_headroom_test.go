@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func recordTestHeadroom(t *testing.T, authID string, fraction float64, resetAt time.Time) {
+	t.Helper()
+	RecordQuotaHeadroom(authID, QuotaHeadroom{
+		Source:  "test",
+		Windows: []QuotaWindow{{Name: "requests", RemainingFraction: fraction, ResetAt: resetAt}},
+	})
+	t.Cleanup(func() { quotaHeadrooms.Delete(authID) })
+}
+
+func TestQuotaHeadroom_RemainingFractionIgnoresResetWindows(t *testing.T) {
+	now := time.Now()
+	headroom := QuotaHeadroom{Windows: []QuotaWindow{
+		{Name: "5h", RemainingFraction: 0.4, ResetAt: now.Add(time.Hour)},
+		{Name: "minute", RemainingFraction: 0, ResetAt: now.Add(-time.Second)},
+		{Name: "7d", RemainingFraction: 0.7},
+	}}
+	if got := headroom.RemainingFraction(now); got != 0.4 {
+		t.Fatalf("RemainingFraction() = %v, want 0.4", got)
+	}
+}
+
+func TestSchedulerPick_PrefersAuthsWithQuotaHeadroom(t *testing.T) {
+	recordTestHeadroom(t, "quota-low", 0.02, time.Now().Add(time.Hour))
+	recordTestHeadroom(t, "quota-roomy", 0.8, time.Time{})
+
+	scheduler := newSchedulerForTest(
+		&RoundRobinSelector{},
+		&Auth{ID: "quota-low", Provider: "claude"},
+		&Auth{ID: "quota-roomy", Provider: "claude"},
+	)
+	for index := 0; index < 3; index++ {
+		got, errPick := scheduler.pickSingle(context.Background(), "claude", "", cliproxyexecutor.Options{}, nil)
+		if errPick != nil || got == nil || got.ID != "quota-roomy" {
+			t.Fatalf("pickSingle() #%d = %v, %v; want quota-roomy", index, got, errPick)
+		}
+	}
+
+	// A credential low on quota is still used once its peers are exhausted.
+	tried := map[string]struct{}{"quota-roomy": {}}
+	got, errPick := scheduler.pickSingle(context.Background(), "claude", "", cliproxyexecutor.Options{}, tried)
+	if errPick != nil || got == nil || got.ID != "quota-low" {
+		t.Fatalf("pickSingle() with roomy auth tried = %v, %v; want quota-low", got, errPick)
+	}
+}
+
+func TestPreferQuotaHeadroom_KeepsAllWhenEveryAuthIsLow(t *testing.T) {
+	recordTestHeadroom(t, "quota-low-a", 0.05, time.Time{})
+	recordTestHeadroom(t, "quota-low-b", 0.01, time.Time{})
+
+	auths := []*Auth{{ID: "quota-low-a"}, {ID: "quota-low-b"}, {ID: "quota-unknown"}}
+	if got := preferQuotaHeadroom(auths, time.Now()); len(got) != 1 || got[0].ID != "quota-unknown" {
+		t.Fatalf("preferQuotaHeadroom() = %v, want only quota-unknown", got)
+	}
+	if got := preferQuotaHeadroom(auths[:2], time.Now()); len(got) != 2 {
+		t.Fatalf("preferQuotaHeadroom() dropped auths when all are low: %v", got)
+	}
+}
+
+func TestWithRateLimitReport_ReportsTightestWindow(t *testing.T) {
+	now := time.Now()
+	RecordQuotaHeadroom("quota-report", QuotaHeadroom{Source: "test", Windows: []QuotaWindow{
+		{Name: "tokens", Limit: 100000, Remaining: 80000, RemainingFraction: 0.8, ResetAt: now.Add(time.Minute)},
+		{Name: "requests", Limit: 50, Remaining: 5, RemainingFraction: 0.1, ResetAt: now.Add(30 * time.Second)},
+		{Name: "expired", Limit: 10, RemainingFraction: 0, ResetAt: now.Add(-time.Second)},
+	}})
+	t.Cleanup(func() { quotaHeadrooms.Delete("quota-report") })
+	auth := &Auth{ID: "quota-report"}
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.RateLimitHeadersMetadataKey: true}}
+
+	headers := withRateLimitReport(nil, auth, opts, now)
+	if headers.Get("X-RateLimit-Limit") != "50" || headers.Get("X-RateLimit-Remaining") != "5" || headers.Get("X-RateLimit-Reset") != "30" {
+		t.Fatalf("headers = %v", headers)
+	}
+	if headers = withRateLimitReport(nil, auth, cliproxyexecutor.Options{}, now); headers != nil {
+		t.Fatalf("requests not asking for rate limits got %v", headers)
+	}
+
+	recordTestHeadroom(t, "quota-share", 0.3, time.Time{})
+	if headers = withRateLimitReport(nil, &Auth{ID: "quota-share"}, opts, now); headers != nil {
+		t.Fatalf("windows without counts got %v", headers)
+	}
+}
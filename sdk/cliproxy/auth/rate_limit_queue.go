@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// rateLimitWaiters tracks the requests waiting in the rate-limit queue. Freed
+// credentials go to higher service tier ranks first and, within a rank, to
+// client keys in weighted fair order: each request is tagged with the virtual
+// time its key would finish it, so a key with many queued requests is served
+// in turn with the others rather than ahead of them.
+type rateLimitWaiters struct {
+	mu     sync.Mutex
+	seq    uint64
+	models map[string]*rateLimitModelQueue
+	stats  map[string]*RateLimitQueueClientStats
+	// changed is closed, and replaced, whenever a request leaves the queue,
+	// waking the requests waiting for their turn.
+	changed chan struct{}
+}
+
+// rateLimitModelQueue holds the waiters of one model and the virtual clock
+// of their fair order, which advances as requests are served.
+type rateLimitModelQueue struct {
+	tickets map[*rateLimitTicket]struct{}
+	clock   float64
+	finish  map[string]float64 // client -> tag of its latest request
+}
+
+// rateLimitTicket is a request's place in the rate-limit queue.
+type rateLimitTicket struct {
+	waiters  *rateLimitWaiters
+	model    string
+	rank     int
+	client   string
+	tag      float64
+	seq      uint64
+	joinedAt time.Time
+	once     sync.Once
+}
+
+// RateLimitQueueClientStats reports how the requests of one client API key
+// fared in the rate-limit queue.
+type RateLimitQueueClientStats struct {
+	APIKey string `json:"api_key"`
+	// Waiting is the number of requests of the key queued now.
+	Waiting int `json:"waiting"`
+	// Queued counts the requests that entered the queue, of which Served got
+	// a credential and Expired gave up waiting.
+	Queued  int64 `json:"queued"`
+	Served  int64 `json:"served"`
+	Expired int64 `json:"expired"`
+
+	// The waits cover the requests that left the queue.
+	TotalWaitMs   int64 `json:"total_wait_ms"`
+	AverageWaitMs int64 `json:"average_wait_ms"`
+	MaxWaitMs     int64 `json:"max_wait_ms"`
+
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// rateLimitQueueClientOf returns the client key a request queues as and its
+// weight, as recorded in the request metadata by the handlers.
+func rateLimitQueueClientOf(opts cliproxyexecutor.Options) (string, float64) {
+	client, _ := opts.Metadata[cliproxyexecutor.QueueClientMetadataKey].(string)
+	weight, _ := opts.Metadata[cliproxyexecutor.QueueWeightMetadataKey].(float64)
+	return client, weight
+}
+
+// join queues a request for model and returns its ticket.
+func (w *rateLimitWaiters) join(model string, rank int, client string, weight float64) *rateLimitTicket {
+	if weight <= 0 {
+		weight = 1
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.models == nil {
+		w.models = make(map[string]*rateLimitModelQueue)
+	}
+	queue := w.models[model]
+	if queue == nil {
+		queue = &rateLimitModelQueue{tickets: make(map[*rateLimitTicket]struct{}), finish: make(map[string]float64)}
+		w.models[model] = queue
+	}
+	w.seq++
+	ticket := &rateLimitTicket{
+		waiters:  w,
+		model:    model,
+		rank:     rank,
+		client:   client,
+		tag:      max(queue.clock, queue.finish[client]) + 1/weight,
+		seq:      w.seq,
+		joinedAt: time.Now(),
+	}
+	queue.finish[client] = ticket.tag
+	queue.tickets[ticket] = struct{}{}
+	if stats := w.clientStatsLocked(client); stats != nil {
+		stats.Waiting++
+		stats.Queued++
+	}
+	return ticket
+}
+
+func (w *rateLimitWaiters) clientStatsLocked(client string) *RateLimitQueueClientStats {
+	if client == "" {
+		return nil
+	}
+	if w.stats == nil {
+		w.stats = make(map[string]*RateLimitQueueClientStats)
+	}
+	stats := w.stats[client]
+	if stats == nil {
+		stats = &RateLimitQueueClientStats{APIKey: client}
+		w.stats[client] = stats
+	}
+	return stats
+}
+
+// leave removes the request from the queue, served or not; only the first
+// call counts.
+func (t *rateLimitTicket) leave(served bool) {
+	if t == nil || t.waiters == nil {
+		return
+	}
+	t.once.Do(func() {
+		w := t.waiters
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.changed != nil {
+			close(w.changed)
+			w.changed = nil
+		}
+		if queue := w.models[t.model]; queue != nil {
+			delete(queue.tickets, t)
+			if served {
+				queue.clock = max(queue.clock, t.tag)
+			}
+			if len(queue.tickets) == 0 {
+				delete(w.models, t.model)
+			}
+		}
+		stats := w.clientStatsLocked(t.client)
+		if stats == nil {
+			return
+		}
+		wait := time.Since(t.joinedAt)
+		stats.Waiting--
+		stats.totalWait += wait
+		stats.maxWait = max(stats.maxWait, wait)
+		if served {
+			stats.Served++
+		} else {
+			stats.Expired++
+		}
+	})
+}
+
+// yields reports whether another request should get the next freed
+// credential before t: one of a higher rank, or one of another client key
+// of the same rank that is ahead in fair order.
+func (w *rateLimitWaiters) yields(t *rateLimitTicket) bool {
+	if t == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.yieldsLocked(t)
+}
+
+func (w *rateLimitWaiters) yieldsLocked(t *rateLimitTicket) bool {
+	queue := w.models[t.model]
+	if queue == nil {
+		return false
+	}
+	for other := range queue.tickets {
+		if other == t {
+			continue
+		}
+		if other.rank > t.rank {
+			return true
+		}
+		if other.rank == t.rank && other.client != t.client && (other.tag < t.tag || (other.tag == t.tag && other.seq < t.seq)) {
+			return true
+		}
+	}
+	return false
+}
+
+// awaitTurn blocks while t yields to another queued request, so requests are
+// dispatched in queue order rather than whenever their own wait ends. It
+// reports false when deadline passes first.
+func (w *rateLimitWaiters) awaitTurn(ctx context.Context, t *rateLimitTicket, deadline time.Time) (bool, error) {
+	if t == nil {
+		return true, nil
+	}
+	for {
+		w.mu.Lock()
+		if !w.yieldsLocked(t) {
+			w.mu.Unlock()
+			return true, nil
+		}
+		if w.changed == nil {
+			w.changed = make(chan struct{})
+		}
+		changed := w.changed
+		w.mu.Unlock()
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false, nil
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		case <-timer.C:
+			return false, nil
+		case <-changed:
+			timer.Stop()
+		}
+	}
+}
+
+// snapshot returns the queue statistics of every client key, ordered by key.
+func (w *rateLimitWaiters) snapshot() []RateLimitQueueClientStats {
+	w.mu.Lock()
+	out := make([]RateLimitQueueClientStats, 0, len(w.stats))
+	for _, stats := range w.stats {
+		entry := *stats
+		entry.TotalWaitMs = entry.totalWait.Milliseconds()
+		entry.MaxWaitMs = entry.maxWait.Milliseconds()
+		if left := entry.Served + entry.Expired; left > 0 {
+			entry.AverageWaitMs = entry.TotalWaitMs / left
+		}
+		out = append(out, entry)
+	}
+	w.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].APIKey < out[j].APIKey })
+	return out
+}
+
+// RateLimitQueueStats reports, per client API key, the requests waiting in
+// the rate-limit queue and how long queued requests waited.
+func (m *Manager) RateLimitQueueStats() []RateLimitQueueClientStats {
+	if m == nil {
+		return nil
+	}
+	return m.rateLimitWaiters.snapshot()
+}
@@ -0,0 +1,318 @@
+package auth
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Schedule attributes are written by the synthesizer from the auth-schedules
+// entry a credential selects.
+const (
+	scheduleActiveHoursAttr = "schedule_active_hours"
+	scheduleTimezoneAttr    = "schedule_timezone"
+	scheduleRestAttr        = "schedule_rest_minutes"
+)
+
+// scheduleHorizon bounds how far ahead schedule transitions are searched.
+const scheduleHorizon = 8 * 24 * time.Hour
+
+// authSchedule is the parsed form of a credential's schedule attributes.
+type authSchedule struct {
+	loc     *time.Location
+	windows []scheduleWindow
+	// rest is the number of minutes per hour the credential is idle, starting
+	// restOffset minutes past the hour.
+	rest       int
+	restOffset int
+}
+
+// scheduleWindow is active from start to end minutes past midnight on each of
+// its days; an end at or before start continues into the next day.
+type scheduleWindow struct {
+	days       [7]bool
+	start, end int
+}
+
+var scheduleWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+type scheduleCacheKey struct {
+	activeHours, timezone, rest, authID string
+}
+
+// parsedSchedules caches parsed schedules, including failures as nil, so the
+// selector does not re-parse on every pick and logs each bad schedule once.
+var parsedSchedules sync.Map // scheduleCacheKey -> *authSchedule
+
+// scheduleForAuth returns the schedule a credential runs on, or nil when it is
+// always available.
+func scheduleForAuth(auth *Auth) *authSchedule {
+	if auth == nil || len(auth.Attributes) == 0 {
+		return nil
+	}
+	key := scheduleCacheKey{
+		activeHours: strings.TrimSpace(auth.Attributes[scheduleActiveHoursAttr]),
+		timezone:    strings.TrimSpace(auth.Attributes[scheduleTimezoneAttr]),
+		rest:        strings.TrimSpace(auth.Attributes[scheduleRestAttr]),
+	}
+	if key.activeHours == "" && key.rest == "" {
+		return nil
+	}
+	key.authID = auth.ID
+	if cached, ok := parsedSchedules.Load(key); ok {
+		return cached.(*authSchedule)
+	}
+	schedule, err := parseAuthSchedule(key.activeHours, key.timezone, key.rest, auth.ID)
+	if err != nil {
+		log.Warnf("auth %s: ignoring invalid schedule: %v", auth.ID, err)
+		schedule = nil
+	}
+	parsedSchedules.Store(key, schedule)
+	return schedule
+}
+
+func parseAuthSchedule(activeHours, timezone, rest, authID string) (*authSchedule, error) {
+	schedule := &authSchedule{loc: time.Local}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone %q: %w", timezone, err)
+		}
+		schedule.loc = loc
+	}
+	for _, spec := range strings.Split(activeHours, ";") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		window, err := parseScheduleWindow(spec)
+		if err != nil {
+			return nil, err
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+	if rest != "" {
+		minutes, err := strconv.Atoi(rest)
+		if err != nil || minutes < 0 || minutes >= 60 {
+			return nil, fmt.Errorf("rest-minutes-per-hour %q must be between 0 and 59", rest)
+		}
+		schedule.rest = minutes
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(authID))
+		schedule.restOffset = int(h.Sum32() % 60)
+	}
+	if len(schedule.windows) == 0 && schedule.rest == 0 {
+		return nil, nil
+	}
+	return schedule, nil
+}
+
+// parseScheduleWindow parses "[days] [HH:MM-HH:MM]", where days is a comma
+// list of weekdays or weekday ranges such as "mon-fri".
+func parseScheduleWindow(spec string) (scheduleWindow, error) {
+	window := scheduleWindow{start: 0, end: 24 * 60}
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) > 2 {
+		return window, fmt.Errorf("active-hours %q: expected \"[days] [HH:MM-HH:MM]\"", spec)
+	}
+	daysSet := false
+	for _, field := range fields {
+		if strings.Contains(field, ":") {
+			start, end, ok := strings.Cut(field, "-")
+			if !ok {
+				return window, fmt.Errorf("active-hours %q: time range must be HH:MM-HH:MM", spec)
+			}
+			var err error
+			if window.start, err = parseScheduleClock(start); err != nil {
+				return window, fmt.Errorf("active-hours %q: %w", spec, err)
+			}
+			if window.end, err = parseScheduleClock(end); err != nil {
+				return window, fmt.Errorf("active-hours %q: %w", spec, err)
+			}
+			continue
+		}
+		for _, part := range strings.Split(field, ",") {
+			from, to, isRange := strings.Cut(part, "-")
+			first, okFrom := scheduleWeekdays[from]
+			last := first
+			okTo := true
+			if isRange {
+				last, okTo = scheduleWeekdays[to]
+			}
+			if !okFrom || !okTo {
+				return window, fmt.Errorf("active-hours %q: unknown day %q", spec, part)
+			}
+			for day := first; ; day = (day + 1) % 7 {
+				window.days[day] = true
+				if day == last {
+					break
+				}
+			}
+		}
+		daysSet = true
+	}
+	if !daysSet {
+		for day := range window.days {
+			window.days[day] = true
+		}
+	}
+	return window, nil
+}
+
+func parseScheduleClock(value string) (int, error) {
+	hours, minutes, ok := strings.Cut(value, ":")
+	h, errH := strconv.Atoi(hours)
+	m, errM := strconv.Atoi(minutes)
+	if !ok || errH != nil || errM != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return h*60 + m, nil
+}
+
+// inWindow reports whether t falls in one of the active-hours windows.
+func (s *authSchedule) inWindow(t time.Time) bool {
+	if len(s.windows) == 0 {
+		return true
+	}
+	local := t.In(s.loc)
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday()
+	yesterday := (day + 6) % 7
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if w.days[day] && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+		if (w.days[day] && minute >= w.start) || (w.days[yesterday] && minute < w.end) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *authSchedule) resting(t time.Time) bool {
+	if s.rest == 0 {
+		return false
+	}
+	return s.restElapsed(t) < s.rest
+}
+
+// restElapsed is the number of minutes since the credential's rest period
+// last started.
+func (s *authSchedule) restElapsed(t time.Time) int {
+	return (t.In(s.loc).Minute() - s.restOffset + 60) % 60
+}
+
+func (s *authSchedule) active(t time.Time) bool {
+	return s.inWindow(t) && !s.resting(t)
+}
+
+// windowEdges returns the window start or end times, in order, within the
+// search horizon around now.
+func (s *authSchedule) windowEdges(now time.Time, starts bool) []time.Time {
+	local := now.In(s.loc)
+	var edges []time.Time
+	for offset := -1; offset <= int(scheduleHorizon/(24*time.Hour)); offset++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, s.loc)
+		for _, w := range s.windows {
+			if !w.days[day.Weekday()] {
+				continue
+			}
+			minute := w.start
+			if !starts {
+				minute = w.end
+				if w.end <= w.start {
+					minute += 24 * 60
+				}
+			}
+			edges = append(edges, time.Date(day.Year(), day.Month(), day.Day(), 0, minute, 0, 0, s.loc))
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].Before(edges[j]) })
+	return edges
+}
+
+// nextActive returns the first time at or after now the credential may be
+// used, or the zero time when that is beyond the search horizon.
+func (s *authSchedule) nextActive(now time.Time) time.Time {
+	t := now
+	for i := 0; i < 64 && t.Sub(now) <= scheduleHorizon; i++ {
+		if !s.inWindow(t) {
+			next := time.Time{}
+			for _, edge := range s.windowEdges(t, true) {
+				if edge.After(t) && s.inWindow(edge) {
+					next = edge
+					break
+				}
+			}
+			if next.IsZero() {
+				return time.Time{}
+			}
+			t = next
+			continue
+		}
+		if s.resting(t) {
+			t = t.Truncate(time.Minute).Add(time.Duration(s.rest-s.restElapsed(t)) * time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// activeUntil returns when a credential usable at now stops being usable, or
+// the zero time when it stays usable past the search horizon.
+func (s *authSchedule) activeUntil(now time.Time) time.Time {
+	var until time.Time
+	if len(s.windows) > 0 {
+		for _, edge := range s.windowEdges(now, false) {
+			if edge.After(now) && !s.inWindow(edge) {
+				until = edge
+				break
+			}
+		}
+	}
+	if s.rest > 0 {
+		restStart := now.Truncate(time.Minute).Add(time.Duration(60-s.restElapsed(now)) * time.Minute)
+		if until.IsZero() || restStart.Before(until) {
+			until = restStart
+		}
+	}
+	return until
+}
+
+// scheduleBlocked reports whether auth is outside its schedule at now and, if
+// so, when it becomes available again.
+func scheduleBlocked(auth *Auth, now time.Time) (bool, time.Time) {
+	schedule := scheduleForAuth(auth)
+	if schedule == nil || schedule.active(now) {
+		return false, time.Time{}
+	}
+	next := schedule.nextActive(now)
+	if next.IsZero() {
+		// No opening within the horizon; check again later in case the
+		// schedule has changed by then.
+		next = now.Add(time.Hour)
+	}
+	return true, next
+}
+
+// scheduleActiveUntil returns when a currently usable auth leaves its
+// schedule; zero means not within the search horizon.
+func scheduleActiveUntil(auth *Auth, now time.Time) time.Time {
+	schedule := scheduleForAuth(auth)
+	if schedule == nil {
+		return time.Time{}
+	}
+	return schedule.activeUntil(now)
+}
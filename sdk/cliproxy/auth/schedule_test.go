@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestAuthSchedule_ActiveHoursWindows(t *testing.T) {
+	t.Parallel()
+
+	schedule, err := parseAuthSchedule("mon-fri 18:00-09:00; sat,sun", "UTC", "", "a")
+	if err != nil {
+		t.Fatalf("parseAuthSchedule() error = %v", err)
+	}
+	// 2026-10-14 is a Wednesday.
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC) }
+	cases := []struct {
+		name   string
+		t      time.Time
+		active bool
+	}{
+		{"wednesday noon", at(14, 12, 0), false},
+		{"wednesday evening", at(14, 20, 0), true},
+		{"thursday early morning", at(15, 8, 59), true},
+		{"thursday nine", at(15, 9, 0), false},
+		{"saturday noon", at(17, 12, 0), true},
+		{"monday early morning", at(19, 8, 0), false},
+	}
+	for _, tc := range cases {
+		if got := schedule.active(tc.t); got != tc.active {
+			t.Errorf("%s: active = %v, want %v", tc.name, got, tc.active)
+		}
+	}
+
+	if got, want := schedule.nextActive(at(14, 12, 0)), at(14, 18, 0); !got.Equal(want) {
+		t.Errorf("nextActive(wednesday noon) = %v, want %v", got, want)
+	}
+	if got, want := schedule.activeUntil(at(14, 20, 0)), at(15, 9, 0); !got.Equal(want) {
+		t.Errorf("activeUntil(wednesday evening) = %v, want %v", got, want)
+	}
+	// Friday night runs into the weekend, which lasts until Monday midnight.
+	if got, want := schedule.activeUntil(at(16, 20, 0)), at(19, 0, 0); !got.Equal(want) {
+		t.Errorf("activeUntil(friday evening) = %v, want %v", got, want)
+	}
+}
+
+func TestAuthSchedule_RestMinutesPerHour(t *testing.T) {
+	t.Parallel()
+
+	schedule, err := parseAuthSchedule("", "UTC", "10", "codex-personal")
+	if err != nil || schedule == nil {
+		t.Fatalf("parseAuthSchedule() = %v, %v", schedule, err)
+	}
+	hour := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	resting := 0
+	for minute := 0; minute < 60; minute++ {
+		t0 := hour.Add(time.Duration(minute) * time.Minute)
+		if !schedule.active(t0) {
+			resting++
+			if next := schedule.nextActive(t0); !schedule.active(next) || next.Sub(t0) > 10*time.Minute {
+				t.Fatalf("nextActive(%v) = %v", t0, next)
+			}
+		}
+	}
+	if resting != 10 {
+		t.Fatalf("rested %d minutes per hour, want 10", resting)
+	}
+
+	other, _ := parseAuthSchedule("", "UTC", "10", "codex-work")
+	if other.restOffset == schedule.restOffset {
+		t.Fatalf("credentials sharing a schedule rest at the same offset %d", other.restOffset)
+	}
+}
+
+func TestAuthSchedule_RejectsInvalidSpecs(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct{ hours, tz, rest string }{
+		{"someday 10:00-12:00", "", ""},
+		{"mon 25:00-26:00", "", ""},
+		{"mon 10:00", "", ""},
+		{"", "Mars/Olympus", ""},
+		{"", "", "60"},
+	} {
+		if _, err := parseAuthSchedule(tc.hours, tc.tz, tc.rest, "a"); err == nil {
+			t.Errorf("parseAuthSchedule(%q, %q, %q) succeeded, want error", tc.hours, tc.tz, tc.rest)
+		}
+	}
+}
+
+// windowAround returns an active-hours range in UTC covering the given offsets
+// from now.
+func windowAround(now time.Time, from, to time.Duration) string {
+	return now.Add(from).UTC().Format("15:04") + "-" + now.Add(to).UTC().Format("15:04")
+}
+
+func TestSchedulerPick_HonoursAuthSchedules(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	scheduler := newSchedulerForTest(
+		&RoundRobinSelector{},
+		&Auth{ID: "resting", Provider: "gemini", Attributes: map[string]string{
+			scheduleActiveHoursAttr: windowAround(now, 2*time.Hour, 3*time.Hour),
+			scheduleTimezoneAttr:    "UTC",
+		}},
+		&Auth{ID: "working", Provider: "gemini", Attributes: map[string]string{
+			scheduleActiveHoursAttr: windowAround(now, -time.Hour, time.Hour),
+			scheduleTimezoneAttr:    "UTC",
+		}},
+	)
+
+	for index := 0; index < 2; index++ {
+		got, errPick := scheduler.pickSingle(context.Background(), "gemini", "", cliproxyexecutor.Options{}, nil)
+		if errPick != nil || got == nil || got.ID != "working" {
+			t.Fatalf("pickSingle() #%d = %v, %v; want working", index, got, errPick)
+		}
+	}
+
+	// Once the working window closes, the auth leaves the ready set without
+	// being updated.
+	shard := scheduler.providers["gemini"].ensureModelLocked("", now)
+	shard.promoteExpiredLocked(now.Add(90 * time.Minute))
+	if entry := shard.entries["working"]; entry.state != scheduledStateBlocked || entry.nextRetryAt.IsZero() {
+		t.Fatalf("working auth after its window: state %v, next retry %v", entry.state, entry.nextRetryAt)
+	}
+	if entry := shard.entries["resting"]; entry.state != scheduledStateBlocked {
+		t.Fatalf("resting auth state = %v, want blocked", entry.state)
+	}
+}
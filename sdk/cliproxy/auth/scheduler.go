@@ -63,6 +63,8 @@ type modelScheduler struct {
 	priorityOrder   []int
 	readyByPriority map[int]*readyBucket
 	blocked         cooldownQueue
+	// scheduleEnd is the earliest time a ready auth leaves its schedule.
+	scheduleEnd time.Time
 }
 
 // scheduledAuth stores the runtime scheduling state for a single auth inside a model shard.
@@ -71,6 +73,8 @@ type scheduledAuth struct {
 	auth        *Auth
 	state       scheduledState
 	nextRetryAt time.Time
+	// readyUntil is when a ready auth leaves its schedule, if it has one.
+	readyUntil time.Time
 }
 
 // readyBucket keeps the ready views for one priority level.
@@ -576,10 +580,13 @@ func (m *modelScheduler) upsertEntryLocked(meta *scheduledAuthMeta, now time.Tim
 	entry.meta = meta
 	entry.auth = meta.auth
 	entry.nextRetryAt = time.Time{}
+	previousReadyUntil := entry.readyUntil
+	entry.readyUntil = time.Time{}
 	blocked, reason, next := isAuthBlockedForModel(meta.auth, m.modelKey, now)
 	switch {
 	case !blocked:
 		entry.state = scheduledStateReady
+		entry.readyUntil = scheduleActiveUntil(meta.auth, now)
 	case reason == blockReasonCooldown:
 		entry.state = scheduledStateCooldown
 		entry.nextRetryAt = next
@@ -590,7 +597,7 @@ func (m *modelScheduler) upsertEntryLocked(meta *scheduledAuthMeta, now time.Tim
 		entry.nextRetryAt = next
 	}
 
-	if ok && previousState == entry.state && previousNextRetryAt.Equal(entry.nextRetryAt) && previousPriority == meta.priority && previousParent == meta.virtualParent && previousWebsocketEnabled == meta.websocketEnabled {
+	if ok && previousState == entry.state && previousNextRetryAt.Equal(entry.nextRetryAt) && previousReadyUntil.Equal(entry.readyUntil) && previousPriority == meta.priority && previousParent == meta.virtualParent && previousWebsocketEnabled == meta.websocketEnabled {
 		return
 	}
 	m.rebuildIndexesLocked()
@@ -608,24 +615,45 @@ func (m *modelScheduler) removeEntryLocked(authID string) {
 	m.rebuildIndexesLocked()
 }
 
-// promoteExpiredLocked reevaluates blocked auths whose retry time has elapsed.
+// promoteExpiredLocked reevaluates blocked auths whose retry time has elapsed
+// and ready auths whose schedule has ended.
 func (m *modelScheduler) promoteExpiredLocked(now time.Time) {
-	if m == nil || len(m.blocked) == 0 {
+	if m == nil {
+		return
+	}
+	candidates := m.blocked
+	if !m.scheduleEnd.IsZero() && !m.scheduleEnd.After(now) {
+		candidates = nil
+		for _, entry := range m.entries {
+			if entry == nil || entry.auth == nil {
+				continue
+			}
+			if entry.state == scheduledStateReady && !entry.readyUntil.IsZero() && !entry.readyUntil.After(now) {
+				entry.nextRetryAt = now
+			}
+			if entry.state != scheduledStateDisabled && !entry.nextRetryAt.IsZero() {
+				candidates = append(candidates, entry)
+			}
+		}
+	}
+	if len(candidates) == 0 {
 		return
 	}
 	changed := false
-	for _, entry := range m.blocked {
+	for _, entry := range candidates {
 		if entry == nil || entry.auth == nil {
 			continue
 		}
 		if entry.nextRetryAt.IsZero() || entry.nextRetryAt.After(now) {
 			continue
 		}
+		entry.readyUntil = time.Time{}
 		blocked, reason, next := isAuthBlockedForModel(entry.auth, m.modelKey, now)
 		switch {
 		case !blocked:
 			entry.state = scheduledStateReady
 			entry.nextRetryAt = time.Time{}
+			entry.readyUntil = scheduleActiveUntil(entry.auth, now)
 		case reason == blockReasonCooldown:
 			entry.state = scheduledStateCooldown
 			entry.nextRetryAt = next
@@ -692,11 +720,25 @@ func (m *modelScheduler) pickReadyAtPriorityLocked(preferWebsocket bool, priorit
 	if preferWebsocket && len(bucket.ws.flat) > 0 {
 		view = &bucket.ws
 	}
+	pick := func(match func(*scheduledAuth) bool) *scheduledAuth {
+		if strategy == schedulerStrategyFillFirst {
+			return view.pickFirst(match)
+		}
+		return view.pickRoundRobin(match)
+	}
 	var picked *scheduledAuth
-	if strategy == schedulerStrategyFillFirst {
-		picked = view.pickFirst(predicate)
-	} else {
-		picked = view.pickRoundRobin(predicate)
+	if quotaHeadroomTracked.Load() {
+		// Prefer peers with quota left; fall back once all are running low.
+		now := time.Now()
+		picked = pick(func(entry *scheduledAuth) bool {
+			if predicate != nil && !predicate(entry) {
+				return false
+			}
+			return entry != nil && entry.auth != nil && !quotaHeadroomLow(entry.auth.ID, now)
+		})
+	}
+	if picked == nil {
+		picked = pick(predicate)
 	}
 	if picked == nil || picked.auth == nil {
 		return nil
@@ -757,6 +799,7 @@ func (m *modelScheduler) rebuildIndexesLocked() {
 	m.readyByPriority = make(map[int]*readyBucket)
 	m.priorityOrder = m.priorityOrder[:0]
 	m.blocked = m.blocked[:0]
+	m.scheduleEnd = time.Time{}
 	priorityBuckets := make(map[int][]*scheduledAuth)
 	for _, entry := range m.entries {
 		if entry == nil || entry.auth == nil {
@@ -764,6 +807,9 @@ func (m *modelScheduler) rebuildIndexesLocked() {
 		}
 		switch entry.state {
 		case scheduledStateReady:
+			if !entry.readyUntil.IsZero() && (m.scheduleEnd.IsZero() || entry.readyUntil.Before(m.scheduleEnd)) {
+				m.scheduleEnd = entry.readyUntil
+			}
 			priority := entry.meta.priority
 			priorityBuckets[priority] = append(priorityBuckets[priority], entry)
 		case scheduledStateCooldown, scheduledStateBlocked:
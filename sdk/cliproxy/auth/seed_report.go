@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/seed"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// withSeedReport records on headers whether the seed of a client request
+// reached an upstream that samples deterministically. Requests without a seed
+// leave headers unchanged.
+func withSeedReport(headers http.Header, auth *Auth, model string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) http.Header {
+	if auth == nil {
+		return headers
+	}
+	if _, ok := seed.FromRequest(req.Payload, opts.SourceFormat.String()); !ok {
+		return headers
+	}
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set(seed.HonoredHeader, strconv.FormatBool(seed.ClientSupported(auth.ID, model)))
+	return headers
+}
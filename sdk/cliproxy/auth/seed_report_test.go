@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+func TestWithSeedReport(t *testing.T) {
+	reg := registry.GetGlobalRegistry()
+	seeded := &Auth{ID: uuid.NewString(), Provider: "gemini"}
+	reg.RegisterClient(seeded.ID, "gemini", []*registry.ModelInfo{{ID: "seed-report-model", SupportsSeed: true}})
+	t.Cleanup(func() { reg.UnregisterClient(seeded.ID) })
+	unseeded := &Auth{ID: uuid.NewString(), Provider: "claude"}
+	reg.RegisterClient(unseeded.ID, "claude", []*registry.ModelInfo{{ID: "seed-report-model"}})
+	t.Cleanup(func() { reg.UnregisterClient(unseeded.ID) })
+
+	opts := cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatOpenAI}
+	req := cliproxyexecutor.Request{Model: "seed-report-model", Payload: []byte(`{"seed":42}`)}
+
+	if got := withSeedReport(nil, seeded, req.Model, req, opts).Get("X-Seed-Honored"); got != "true" {
+		t.Fatalf("seed-capable upstream reported %q", got)
+	}
+	if got := withSeedReport(nil, unseeded, req.Model, req, opts).Get("X-Seed-Honored"); got != "false" {
+		t.Fatalf("upstream without seed support reported %q", got)
+	}
+
+	req.Payload = []byte(`{}`)
+	if headers := withSeedReport(nil, seeded, req.Model, req, opts); headers != nil {
+		t.Fatalf("unseeded request stamped headers: %v", headers)
+	}
+}
@@ -241,7 +241,7 @@ func getAvailableAuths(auths []*Auth, provider, model string, now time.Time) ([]
 		}
 	}
 
-	available := availableByPriority[bestPriority]
+	available := preferQuotaHeadroom(availableByPriority[bestPriority], now)
 	if len(available) > 1 {
 		sort.Slice(available, func(i, j int) bool { return available[i].ID < available[j].ID })
 	}
@@ -369,6 +369,9 @@ func isAuthBlockedForModel(auth *Auth, model string, now time.Time) (bool, block
 	if auth.Disabled || auth.Status == StatusDisabled {
 		return true, blockReasonDisabled, time.Time{}
 	}
+	if blocked, next := scheduleBlocked(auth, now); blocked {
+		return true, blockReasonOther, next
+	}
 	if model != "" {
 		if len(auth.ModelStates) > 0 {
 			state, ok := auth.ModelStates[model]
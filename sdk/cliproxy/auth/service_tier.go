@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/servicetier"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+func serviceTierOf(opts cliproxyexecutor.Options) string {
+	tier, _ := opts.Metadata[cliproxyexecutor.ServiceTierMetadataKey].(string)
+	return tier
+}
+
+// withServiceTierReport records on headers the tier that served a request
+// with an explicit tier. Upstreams that report a tier in payload are taken at
+// their word; payload is nil for streams.
+func withServiceTierReport(headers http.Header, auth *Auth, opts cliproxyexecutor.Options, payload []byte) http.Header {
+	tier := serviceTierOf(opts)
+	if auth == nil || tier == "" {
+		return headers
+	}
+	effective := servicetier.FromResponse(payload, opts.SourceFormat.String())
+	if effective == "" {
+		effective = servicetier.Effective(auth.Provider, tier)
+	}
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set(servicetier.ResponseHeader, effective)
+	return headers
+}
+
+// withServiceTierField adds service_tier to a chat completion translated from
+// an upstream that does not report it, as OpenAI does for requests with a
+// tier.
+func withServiceTierField(payload []byte, headers http.Header, opts cliproxyexecutor.Options) []byte {
+	effective := headers.Get(servicetier.ResponseHeader)
+	if effective == "" || opts.SourceFormat.String() != "openai" || !gjson.GetBytes(payload, "choices").IsArray() || gjson.GetBytes(payload, "service_tier").Exists() {
+		return payload
+	}
+	out, err := sjson.SetBytes(payload, "service_tier", effective)
+	if err != nil {
+		return payload
+	}
+	return out
+}
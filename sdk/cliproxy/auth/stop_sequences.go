@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// stopSequenceLimits is how many chat completion stop sequences each
+// provider's upstream honours. Providers whose translators drop stop have a
+// limit of 0; providers that are not listed honour every sequence.
+var stopSequenceLimits = map[string]int{
+	"codex":                0,
+	"gemini":               0,
+	"vertex":               0,
+	"gemini-cli":           0,
+	"aistudio":             0,
+	"antigravity":          0,
+	"kiro":                 0,
+	"openai-compatibility": 4,
+}
+
+// stopSequenceExecutor enforces chat completion stop sequences the upstream
+// would ignore. Output is scanned as it arrives, cut before the first match
+// and finished with finish_reason "stop"; once every choice has stopped the
+// upstream request is cancelled. The first sequences within the provider's
+// limit are still sent so the upstream can stop early by itself.
+type stopSequenceExecutor struct {
+	ProviderExecutor
+	stops []string
+	limit int
+}
+
+// withStopSequences wraps executor when a chat completion carries more stop
+// sequences than auth's provider honours. Other executors are returned
+// unchanged.
+func withStopSequences(executor ProviderExecutor, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) ProviderExecutor {
+	if executor == nil || auth == nil || opts.SourceFormat != sdktranslator.FormatOpenAI || opts.Alt != "" {
+		return executor
+	}
+	stops := chatStopSequences(req.Payload)
+	if len(stops) == 0 {
+		return executor
+	}
+	limit, limited := stopSequenceLimits[strings.ToLower(strings.TrimSpace(auth.Provider))]
+	if auth.Attributes["compat_name"] != "" {
+		limit, limited = stopSequenceLimits["openai-compatibility"]
+	}
+	if !limited || len(stops) <= limit {
+		return executor
+	}
+	return &stopSequenceExecutor{ProviderExecutor: executor, stops: stops, limit: limit}
+}
+
+// chatStopSequences returns the non-empty stop sequences of a chat
+// completion request, given either as a string or an array.
+func chatStopSequences(payload []byte) []string {
+	stop := gjson.GetBytes(payload, "stop")
+	var stops []string
+	switch {
+	case stop.IsArray():
+		stop.ForEach(func(_, value gjson.Result) bool {
+			if s := value.String(); s != "" {
+				stops = append(stops, s)
+			}
+			return true
+		})
+	case stop.Type == gjson.String && stop.String() != "":
+		stops = []string{stop.String()}
+	}
+	return stops
+}
+
+// upstreamRequest keeps only the stop sequences the upstream honours.
+func (e *stopSequenceExecutor) upstreamRequest(req cliproxyexecutor.Request) cliproxyexecutor.Request {
+	var updated []byte
+	var err error
+	if e.limit == 0 {
+		updated, err = sjson.DeleteBytes(req.Payload, "stop")
+	} else {
+		updated, err = sjson.SetBytes(req.Payload, "stop", e.stops[:e.limit])
+	}
+	if err == nil {
+		req.Payload = updated
+	}
+	return req
+}
+
+// firstStop returns the offset of the earliest stop sequence in text, or -1.
+func (e *stopSequenceExecutor) firstStop(text string) int {
+	first := -1
+	for _, stop := range e.stops {
+		if i := strings.Index(text, stop); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	return first
+}
+
+func (e *stopSequenceExecutor) Execute(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	resp, err := e.ProviderExecutor.Execute(ctx, auth, e.upstreamRequest(req), opts)
+	if err != nil || !gjson.ValidBytes(resp.Payload) {
+		return resp, err
+	}
+	payload := resp.Payload
+	gjson.GetBytes(payload, "choices").ForEach(func(i, choice gjson.Result) bool {
+		content := choice.Get("message.content")
+		if content.Type != gjson.String {
+			return true
+		}
+		cut := e.firstStop(content.String())
+		if cut < 0 {
+			return true
+		}
+		path := "choices." + i.String()
+		payload, _ = sjson.SetBytes(payload, path+".message.content", content.String()[:cut])
+		// Anything after the stop sequence, tool calls included, was never
+		// meant to be generated.
+		payload, _ = sjson.DeleteBytes(payload, path+".message.tool_calls")
+		payload, _ = sjson.SetBytes(payload, path+".finish_reason", "stop")
+		return true
+	})
+	resp.Payload = payload
+	return resp, nil
+}
+
+func (e *stopSequenceExecutor) ExecuteStream(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	upstreamCtx, cancel := context.WithCancel(ctx)
+	result, err := e.ProviderExecutor.ExecuteStream(upstreamCtx, auth, e.upstreamRequest(req), opts)
+	if err != nil || result == nil {
+		cancel()
+		return result, err
+	}
+	choices := int(gjson.GetBytes(req.Payload, "n").Int())
+	scanner := newStopScanner(e, max(choices, 1))
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer cancel()
+		send := func(chunk cliproxyexecutor.StreamChunk) bool {
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for chunk := range result.Chunks {
+			if chunk.Err != nil {
+				if !send(chunk) {
+					discardStreamChunks(result.Chunks)
+					return
+				}
+				continue
+			}
+			for _, payload := range scanner.scan(chunk.Payload) {
+				if !send(cliproxyexecutor.StreamChunk{Payload: payload}) {
+					discardStreamChunks(result.Chunks)
+					return
+				}
+			}
+			if scanner.done() {
+				// Every choice has stopped; the rest of the generation is
+				// not needed.
+				cancel()
+				discardStreamChunks(result.Chunks)
+				return
+			}
+		}
+		if payload := scanner.flush(); payload != nil {
+			send(cliproxyexecutor.StreamChunk{Payload: payload})
+		}
+	}()
+	return &cliproxyexecutor.StreamResult{Headers: result.Headers, Chunks: out}, nil
+}
+
+// stopScanner finds stop sequences in the content deltas of a chat
+// completion stream. The tail of each choice's content that could still
+// begin a stop sequence is held back until the next delta shows whether it
+// does.
+type stopScanner struct {
+	e       *stopSequenceExecutor
+	choices int
+	hold    int
+	held    map[int64]string
+	stopped map[int64]bool
+	// envelope and prefixed describe the last chunk, for the chunk that
+	// releases held content at the end of the stream.
+	envelope string
+	prefixed bool
+}
+
+func newStopScanner(e *stopSequenceExecutor, choices int) *stopScanner {
+	longest := 0
+	for _, stop := range e.stops {
+		longest = max(longest, len(stop))
+	}
+	return &stopScanner{e: e, choices: choices, hold: longest - 1, held: map[int64]string{}, stopped: map[int64]bool{}}
+}
+
+// done reports whether every choice has reached a stop sequence.
+func (s *stopScanner) done() bool {
+	return len(s.stopped) >= s.choices
+}
+
+// scan returns the chunks to send in place of chunk, possibly none.
+func (s *stopScanner) scan(chunk []byte) [][]byte {
+	data, prefixed := chatChunkData(chunk)
+	if !gjson.ValidBytes(data) || !gjson.GetBytes(data, "choices").IsArray() {
+		return [][]byte{chunk}
+	}
+	s.envelope, s.prefixed = string(data), prefixed
+	out := string(data)
+	changed := false
+	var kept []string
+	gjson.GetBytes(data, "choices").ForEach(func(_, choice gjson.Result) bool {
+		index := choice.Get("index").Int()
+		if s.stopped[index] {
+			changed = true
+			return true
+		}
+		updated, stopped := s.scanChoice(index, choice)
+		if updated != choice.Raw {
+			changed = true
+		}
+		if stopped {
+			s.stopped[index] = true
+		}
+		kept = append(kept, updated)
+		return true
+	})
+	if !changed {
+		return [][]byte{chunk}
+	}
+	hasUsage := gjson.Get(out, "usage").IsObject()
+	if len(kept) == 0 && !hasUsage {
+		return nil
+	}
+	out, _ = sjson.SetRaw(out, "choices", "["+strings.Join(kept, ",")+"]")
+	return [][]byte{chatChunkFrame(out, prefixed)}
+}
+
+// scanChoice returns choice with its content delta replaced by the text that
+// is safe to send, and whether a stop sequence ended the choice.
+func (s *stopScanner) scanChoice(index int64, choice gjson.Result) (string, bool) {
+	pending := s.held[index] + choice.Get("delta.content").String()
+	finished := choice.Get("finish_reason").Type == gjson.String
+	if pending == "" {
+		return choice.Raw, false
+	}
+	updated := choice.Raw
+	if cut := s.e.firstStop(pending); cut >= 0 {
+		delete(s.held, index)
+		updated, _ = sjson.Set(updated, "delta.content", pending[:cut])
+		updated, _ = sjson.Delete(updated, "delta.tool_calls")
+		updated, _ = sjson.Set(updated, "finish_reason", "stop")
+		return updated, true
+	}
+	release := len(pending)
+	if !finished && len(choice.Get("delta.tool_calls").Array()) == 0 {
+		release = max(len(pending)-s.hold, 0)
+		for release > 0 && !utf8.RuneStart(pending[release]) {
+			release--
+		}
+	}
+	s.held[index] = pending[release:]
+	if s.held[index] == "" {
+		delete(s.held, index)
+	}
+	updated, _ = sjson.Set(updated, "delta.content", pending[:release])
+	return updated, false
+}
+
+// flush returns a chunk releasing the content still held when the upstream
+// stream ended without finishing its choices, or nil.
+func (s *stopScanner) flush() []byte {
+	if len(s.held) == 0 || s.envelope == "" {
+		return nil
+	}
+	out, _ := sjson.Delete(s.envelope, "usage")
+	out, _ = sjson.SetRaw(out, "choices", "[]")
+	for _, index := range slices.Sorted(maps.Keys(s.held)) {
+		choice, _ := sjson.Set(`{"delta":{}}`, "index", index)
+		choice, _ = sjson.Set(choice, "delta.content", s.held[index])
+		out, _ = sjson.SetRaw(out, "choices.-1", choice)
+	}
+	s.held = map[int64]string{}
+	return chatChunkFrame(out, s.prefixed)
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// deltaExecutor streams one content delta per entry and records whether its
+// request was cancelled before the stream was fully read.
+type deltaExecutor struct {
+	streamStyleExecutor
+	deltas    []string
+	payload   []byte
+	cancelled chan struct{}
+}
+
+func (e *deltaExecutor) Execute(_ context.Context, _ *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.payload = req.Payload
+	return cliproxyexecutor.Response{Payload: []byte(`{"id":"c","choices":[{"index":0,"message":{"role":"assistant","content":"` + strings.Join(e.deltas, "") + `","tool_calls":[{"id":"t"}]},"finish_reason":"tool_calls"}]}`)}, nil
+}
+
+func (e *deltaExecutor) ExecuteStream(ctx context.Context, _ *Auth, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	e.payload = req.Payload
+	e.cancelled = make(chan struct{})
+	ch := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(ch)
+		for _, delta := range e.deltas {
+			chunk := cliproxyexecutor.StreamChunk{Payload: []byte(`data: {"id":"c","choices":[{"index":0,"delta":{"content":"` + delta + `"}}]}`)}
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				close(e.cancelled)
+				return
+			}
+		}
+		ch <- cliproxyexecutor.StreamChunk{Payload: []byte(`data: {"id":"c","choices":[{"index":0,"delta":{},"finish_reason":"length"}]}`)}
+	}()
+	return &cliproxyexecutor.StreamResult{Chunks: ch}, nil
+}
+
+func stopRequest(stop string) (cliproxyexecutor.Request, cliproxyexecutor.Options) {
+	payload := []byte(`{"model":"m","stop":` + stop + `,"messages":[{"role":"user","content":"hi"}]}`)
+	return cliproxyexecutor.Request{Model: "m", Payload: payload}, cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatOpenAI}
+}
+
+func TestWithStopSequences_OnlyWrapsWhenUnsupported(t *testing.T) {
+	upstream := &deltaExecutor{}
+	req, opts := stopRequest(`["a","b"]`)
+	if withStopSequences(upstream, &Auth{Provider: "claude"}, req, opts) != ProviderExecutor(upstream) {
+		t.Fatal("claude honours stop sequences")
+	}
+	if withStopSequences(upstream, &Auth{Provider: "my-compat", Attributes: map[string]string{"compat_name": "my-compat"}}, req, opts) != ProviderExecutor(upstream) {
+		t.Fatal("two sequences are within the OpenAI-compatible limit")
+	}
+	many, manyOpts := stopRequest(`["a","b","c","d","e"]`)
+	executor := withStopSequences(upstream, &Auth{Provider: "openai-compatibility"}, many, manyOpts)
+	if _, err := executor.Execute(context.Background(), nil, many, manyOpts); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if got := gjson.GetBytes(upstream.payload, "stop.#").Int(); got != 4 {
+		t.Fatalf("upstream got %d stop sequences", got)
+	}
+}
+
+func TestStopSequenceExecutor_TruncatesResponse(t *testing.T) {
+	upstream := &deltaExecutor{deltas: []string{"Hello ", "END", " more"}}
+	req, opts := stopRequest(`"END"`)
+	executor := withStopSequences(upstream, &Auth{Provider: "codex"}, req, opts)
+
+	resp, err := executor.Execute(context.Background(), nil, req, opts)
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if gjson.GetBytes(upstream.payload, "stop").Exists() {
+		t.Fatalf("stop should not reach codex: %s", upstream.payload)
+	}
+	choice := gjson.GetBytes(resp.Payload, "choices.0")
+	if choice.Get("message.content").String() != "Hello " || choice.Get("finish_reason").String() != "stop" || choice.Get("message.tool_calls").Exists() {
+		t.Fatalf("choice = %s", choice.Raw)
+	}
+}
+
+func TestStopSequenceExecutor_StopsStreamAcrossChunks(t *testing.T) {
+	upstream := &deltaExecutor{deltas: []string{"Hel", "lo E", "ND tail", "never", "sent"}}
+	req, opts := stopRequest(`["END","STOP"]`)
+	executor := withStopSequences(upstream, &Auth{Provider: "gemini"}, req, opts)
+
+	result, err := executor.ExecuteStream(context.Background(), nil, req, opts)
+	if err != nil {
+		t.Fatalf("ExecuteStream error: %v", err)
+	}
+	var content strings.Builder
+	var finish string
+	for chunk := range result.Chunks {
+		data := gjson.Parse(strings.TrimPrefix(string(chunk.Payload), "data: "))
+		content.WriteString(data.Get("choices.0.delta.content").String())
+		if fr := data.Get("choices.0.finish_reason").String(); fr != "" {
+			finish = fr
+		}
+	}
+	if content.String() != "Hello " || finish != "stop" {
+		t.Fatalf("content = %q, finish_reason = %q", content.String(), finish)
+	}
+	<-upstream.cancelled
+}
+
+func TestStopSequenceExecutor_ReleasesHeldTextWithoutMatch(t *testing.T) {
+	upstream := &deltaExecutor{deltas: []string{"ab", "cS", "T"}}
+	req, opts := stopRequest(`"STOP"`)
+	executor := withStopSequences(upstream, &Auth{Provider: "kiro"}, req, opts)
+
+	result, err := executor.ExecuteStream(context.Background(), nil, req, opts)
+	if err != nil {
+		t.Fatalf("ExecuteStream error: %v", err)
+	}
+	var content strings.Builder
+	var finish string
+	for chunk := range result.Chunks {
+		data := gjson.Parse(strings.TrimPrefix(string(chunk.Payload), "data: "))
+		content.WriteString(data.Get("choices.0.delta.content").String())
+		if fr := data.Get("choices.0.finish_reason").String(); fr != "" {
+			finish = fr
+		}
+	}
+	if content.String() != "abcST" || finish != "length" {
+		t.Fatalf("content = %q, finish_reason = %q", content.String(), finish)
+	}
+}
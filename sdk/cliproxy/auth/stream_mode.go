@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/streamadapt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// streamModeExecutor serves requests in the style the client asked for when
+// the model's upstream only supports the other one: streaming requests are
+// sent non-streaming and replayed as chunks, and non-streaming requests are
+// streamed and aggregated.
+type streamModeExecutor struct {
+	ProviderExecutor
+	mode string
+}
+
+// withStreamMode wraps executor when the registry restricts how auth serves
+// model. Other executors are returned unchanged.
+func withStreamMode(executor ProviderExecutor, auth *Auth, model string) ProviderExecutor {
+	if executor == nil || auth == nil {
+		return executor
+	}
+	modelKey := strings.TrimSpace(model)
+	if parsed := thinking.ParseSuffix(modelKey); strings.TrimSpace(parsed.ModelName) != "" {
+		modelKey = strings.TrimSpace(parsed.ModelName)
+	}
+	info := registry.GetGlobalRegistry().ClientModelInfo(auth.ID, modelKey)
+	if info == nil {
+		return executor
+	}
+	switch info.StreamMode {
+	case registry.StreamModeNonStream, registry.StreamModeStream:
+		return &streamModeExecutor{ProviderExecutor: executor, mode: info.StreamMode}
+	default:
+		return executor
+	}
+}
+
+func (e *streamModeExecutor) Execute(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if e.mode != registry.StreamModeStream || !streamadapt.Supported(opts.SourceFormat) {
+		return e.ProviderExecutor.Execute(ctx, auth, req, opts)
+	}
+	req, opts = withStreamFlag(req, opts, true)
+	result, errStream := e.ProviderExecutor.ExecuteStream(ctx, auth, req, opts)
+	if errStream != nil {
+		return cliproxyexecutor.Response{}, errStream
+	}
+	var chunks [][]byte
+	for chunk := range result.Chunks {
+		if chunk.Err != nil {
+			discardStreamChunks(result.Chunks)
+			return cliproxyexecutor.Response{}, chunk.Err
+		}
+		chunks = append(chunks, chunk.Payload)
+	}
+	payload, errAggregate := streamadapt.Aggregate(opts.SourceFormat, chunks)
+	if errAggregate != nil {
+		return cliproxyexecutor.Response{}, &Error{Code: "stream_adaptation_failed", Message: errAggregate.Error(), Retryable: true}
+	}
+	return cliproxyexecutor.Response{Payload: payload, Headers: result.Headers}, nil
+}
+
+func (e *streamModeExecutor) ExecuteStream(ctx context.Context, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	if e.mode != registry.StreamModeNonStream || !streamadapt.Supported(opts.SourceFormat) {
+		return e.ProviderExecutor.ExecuteStream(ctx, auth, req, opts)
+	}
+	req, opts = withStreamFlag(req, opts, false)
+	resp, errExec := e.ProviderExecutor.Execute(ctx, auth, req, opts)
+	if errExec != nil {
+		return nil, errExec
+	}
+	payloads, errSynth := streamadapt.Synthesize(opts.SourceFormat, resp.Payload)
+	if errSynth != nil {
+		return nil, &Error{Code: "stream_adaptation_failed", Message: errSynth.Error(), Retryable: true}
+	}
+	out := make(chan cliproxyexecutor.StreamChunk, len(payloads))
+	for _, payload := range payloads {
+		out <- cliproxyexecutor.StreamChunk{Payload: payload}
+	}
+	close(out)
+	return &cliproxyexecutor.StreamResult{Headers: resp.Headers, Chunks: out}, nil
+}
+
+// withStreamFlag switches the request to the other call style, including the
+// "stream" field of schemas that carry one in the body.
+func withStreamFlag(req cliproxyexecutor.Request, opts cliproxyexecutor.Options, stream bool) (cliproxyexecutor.Request, cliproxyexecutor.Options) {
+	opts.Stream = stream
+	if gjson.GetBytes(req.Payload, "stream").Exists() {
+		if updated, errSet := sjson.SetBytes(req.Payload, "stream", stream); errSet == nil {
+			req.Payload = updated
+		}
+	}
+	if gjson.GetBytes(opts.OriginalRequest, "stream").Exists() {
+		if updated, errSet := sjson.SetBytes(opts.OriginalRequest, "stream", stream); errSet == nil {
+			opts.OriginalRequest = updated
+		}
+	}
+	return req, opts
+}
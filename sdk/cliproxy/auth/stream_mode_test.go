@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// streamStyleExecutor records which call style reached the upstream.
+type streamStyleExecutor struct {
+	streamCalls  int
+	executeCalls int
+	lastStream   bool
+}
+
+func (e *streamStyleExecutor) Identifier() string { return "openai-compatibility" }
+
+func (e *streamStyleExecutor) Execute(_ context.Context, _ *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.executeCalls++
+	e.lastStream = opts.Stream || gjson.GetBytes(req.Payload, "stream").Bool()
+	return cliproxyexecutor.Response{Payload: []byte(`{"id":"c1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"full"},"finish_reason":"stop"}]}`)}, nil
+}
+
+func (e *streamStyleExecutor) ExecuteStream(_ context.Context, _ *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	e.streamCalls++
+	e.lastStream = opts.Stream && gjson.GetBytes(req.Payload, "stream").Bool()
+	ch := make(chan cliproxyexecutor.StreamChunk, 2)
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte(`{"id":"c1","choices":[{"index":0,"delta":{"content":"stre"}}]}`)}
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte(`{"id":"c1","choices":[{"index":0,"delta":{"content":"amed"},"finish_reason":"stop"}]}`)}
+	close(ch)
+	return &cliproxyexecutor.StreamResult{Chunks: ch}, nil
+}
+
+func (e *streamStyleExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *streamStyleExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *streamStyleExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func newStreamModeTestManager(t *testing.T, mode string) (*Manager, *streamStyleExecutor) {
+	t.Helper()
+	m := NewManager(nil, nil, nil)
+	executor := &streamStyleExecutor{}
+	m.RegisterExecutor(executor)
+
+	auth := &Auth{ID: uuid.NewString(), Provider: "openai-compatibility"}
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient(auth.ID, "openai-compatibility", []*registry.ModelInfo{{ID: "adapted-model", StreamMode: mode}})
+	t.Cleanup(func() { reg.UnregisterClient(auth.ID) })
+	if _, errRegister := m.Register(context.Background(), auth); errRegister != nil {
+		t.Fatalf("register auth: %v", errRegister)
+	}
+	return m, executor
+}
+
+func TestStreamMode_NonStreamOnlyModelSynthesizesStream(t *testing.T) {
+	m, executor := newStreamModeTestManager(t, registry.StreamModeNonStream)
+
+	req := cliproxyexecutor.Request{Model: "adapted-model", Payload: []byte(`{"model":"adapted-model","stream":true}`)}
+	opts := cliproxyexecutor.Options{Stream: true, SourceFormat: sdktranslator.FormatOpenAI}
+	result, err := m.ExecuteStream(context.Background(), []string{"openai-compatibility"}, req, opts)
+	if err != nil {
+		t.Fatalf("ExecuteStream: %v", err)
+	}
+	var chunks [][]byte
+	for chunk := range result.Chunks {
+		if chunk.Err != nil {
+			t.Fatalf("chunk error: %v", chunk.Err)
+		}
+		chunks = append(chunks, chunk.Payload)
+	}
+	if executor.executeCalls != 1 || executor.streamCalls != 0 || executor.lastStream {
+		t.Fatalf("expected a single non-streaming upstream call, got execute=%d stream=%d streamFlag=%t", executor.executeCalls, executor.streamCalls, executor.lastStream)
+	}
+	if len(chunks) != 1 || gjson.GetBytes(chunks[0], "choices.0.delta.content").String() != "full" {
+		t.Fatalf("unexpected chunks %q", chunks)
+	}
+}
+
+func TestStreamMode_StreamOnlyModelAggregatesResponse(t *testing.T) {
+	m, executor := newStreamModeTestManager(t, registry.StreamModeStream)
+
+	req := cliproxyexecutor.Request{Model: "adapted-model", Payload: []byte(`{"model":"adapted-model","stream":false}`)}
+	opts := cliproxyexecutor.Options{SourceFormat: sdktranslator.FormatOpenAI}
+	resp, err := m.Execute(context.Background(), []string{"openai-compatibility"}, req, opts)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if executor.streamCalls != 1 || executor.executeCalls != 0 || !executor.lastStream {
+		t.Fatalf("expected a single streaming upstream call, got execute=%d stream=%d streamFlag=%t", executor.executeCalls, executor.streamCalls, executor.lastStream)
+	}
+	if got := gjson.GetBytes(resp.Payload, "choices.0.message.content").String(); got != "streamed" {
+		t.Fatalf("content = %q", got)
+	}
+}
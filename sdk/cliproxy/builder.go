@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	externalaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/external_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
@@ -164,13 +165,13 @@ func (b *Builder) WithPostAuthHook(hook coreauth.PostAuthHook) *Builder {
 }
 
 // Build validates inputs, applies defaults, and returns a ready-to-run service.
+// The configuration path is optional: without one the service runs from the
+// in-memory configuration, and config hot reload and management API edits to
+// the config are unavailable.
 func (b *Builder) Build() (*Service, error) {
 	if b.cfg == nil {
 		return nil, fmt.Errorf("cliproxy: configuration is required")
 	}
-	if b.configPath == "" {
-		return nil, fmt.Errorf("cliproxy: configuration path is required")
-	}
 
 	tokenProvider := b.tokenProvider
 	if tokenProvider == nil {
@@ -198,6 +199,7 @@ func (b *Builder) Build() (*Service, error) {
 	}
 
 	configaccess.Register(&b.cfg.SDKConfig)
+	externalaccess.Register(&b.cfg.SDKConfig)
 	accessManager.SetProviders(sdkaccess.RegisteredProviders())
 
 	coreManager := b.coreManager
@@ -1,9 +1,24 @@
 package executor
 
-import "context"
+import (
+	"context"
+	"sync/atomic"
+)
 
 type downstreamWebsocketContextKey struct{}
 
+type upstreamModelContextKey struct{}
+
+type attemptCounterContextKey struct{}
+
+type experimentArmContextKey struct{}
+
+type experimentArm struct{ experiment, arm string }
+
+type recoveryActionContextKey struct{}
+
+type usageParentContextKey struct{}
+
 // WithDownstreamWebsocket marks the current request as coming from a downstream websocket connection.
 func WithDownstreamWebsocket(ctx context.Context) context.Context {
 	if ctx == nil {
@@ -21,3 +36,117 @@ func DownstreamWebsocket(ctx context.Context) bool {
 	enabled, ok := raw.(bool)
 	return ok && enabled
 }
+
+// WithUpstreamModel records the upstream model targeted by the current execution
+// attempt so transport-level hooks (such as header templates) can reference it.
+func WithUpstreamModel(ctx context.Context, model string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, upstreamModelContextKey{}, model)
+}
+
+// UpstreamModel returns the model recorded by WithUpstreamModel. The second
+// value is false when ctx does not belong to a model execution.
+func UpstreamModel(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	model, ok := ctx.Value(upstreamModelContextKey{}).(string)
+	return model, ok
+}
+
+// WithExperimentArm records the experiment and arm a request was assigned to
+// so usage records can be attributed to the arm.
+func WithExperimentArm(ctx context.Context, experiment, arm string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, experimentArmContextKey{}, experimentArm{experiment: experiment, arm: arm})
+}
+
+// ExperimentArm returns the experiment and arm recorded by WithExperimentArm,
+// or empty strings when the request is not part of an experiment.
+func ExperimentArm(ctx context.Context) (experiment, arm string) {
+	if ctx == nil {
+		return "", ""
+	}
+	v, _ := ctx.Value(experimentArmContextKey{}).(experimentArm)
+	return v.experiment, v.arm
+}
+
+// WithRecoveryAction records why the coming attempt is a retry of a response
+// that was already received, such as "retry_same_credential:empty_content".
+func WithRecoveryAction(ctx context.Context, action string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, recoveryActionContextKey{}, action)
+}
+
+// RecoveryAction returns the action recorded by WithRecoveryAction.
+func RecoveryAction(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	action, _ := ctx.Value(recoveryActionContextKey{}).(string)
+	return action
+}
+
+// WithUsageParent marks the coming execution as a secondary call made on
+// behalf of the request with ID parentRequestID, such as a smart router
+// classifier call; its usage record carries the ID as ParentRequestID.
+func WithUsageParent(ctx context.Context, parentRequestID string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, usageParentContextKey{}, parentRequestID)
+}
+
+// UsageParent returns the request ID recorded by WithUsageParent.
+func UsageParent(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	parent, _ := ctx.Value(usageParentContextKey{}).(string)
+	return parent
+}
+
+// WithAttemptCounter attaches a counter of upstream attempts made for one
+// client request. It returns ctx unchanged when a counter is already present,
+// so nested executions keep counting against the same request.
+func WithAttemptCounter(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Value(attemptCounterContextKey{}).(*atomic.Int32); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, attemptCounterContextKey{}, new(atomic.Int32))
+}
+
+// NextAttempt records the start of an upstream attempt and returns its
+// 1-based number, or 0 when ctx carries no counter.
+func NextAttempt(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	counter, ok := ctx.Value(attemptCounterContextKey{}).(*atomic.Int32)
+	if !ok {
+		return 0
+	}
+	return int(counter.Add(1))
+}
+
+// Attempt returns the number of the current upstream attempt, or 0 when ctx
+// carries no counter.
+func Attempt(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	counter, ok := ctx.Value(attemptCounterContextKey{}).(*atomic.Int32)
+	if !ok {
+		return 0
+	}
+	return int(counter.Load())
+}
@@ -1,6 +1,8 @@
 package executor
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"net/url"
 
@@ -19,8 +21,36 @@ const (
 	SelectedAuthCallbackMetadataKey = "selected_auth_callback"
 	// ExecutionSessionMetadataKey identifies a long-lived downstream execution session.
 	ExecutionSessionMetadataKey = "execution_session_id"
+	// QueueWaitCallbackMetadataKey carries an optional func(time.Duration) invoked
+	// each time a rate-limited request waits in the queue for a credential.
+	QueueWaitCallbackMetadataKey = "queue_wait_callback"
+	// ClientProfileMetadataKey names the compatibility profile of the client
+	// API key, e.g. "claude-code".
+	ClientProfileMetadataKey = "client_profile"
+	// ClientScopeMetadataKey identifies the client API key, without revealing
+	// it, for state that client profiles keep per key.
+	ClientScopeMetadataKey = "client_scope"
+	// ServiceTierMetadataKey carries the service tier the client asked for,
+	// from service_tier or the X-Request-Priority header.
+	ServiceTierMetadataKey = "service_tier"
+	// RateLimitHeadersMetadataKey asks for the quota left on the serving
+	// credential to be reported in the X-RateLimit response headers.
+	RateLimitHeadersMetadataKey = "rate_limit_headers"
+	// QueueClientMetadataKey names the client API key a request waits as in
+	// the rate-limit queue, which shares freed credentials fairly between keys.
+	QueueClientMetadataKey = "queue_client"
+	// QueueWeightMetadataKey carries the float64 share of that key in the
+	// rate-limit queue; absent means 1.
+	QueueWeightMetadataKey = "queue_weight"
 )
 
+// ClientScope returns the ClientScopeMetadataKey value of the client API key
+// apiKey authenticated by accessProvider.
+func ClientScope(accessProvider, apiKey string) string {
+	sum := sha256.Sum256([]byte(accessProvider + "\x00" + apiKey))
+	return hex.EncodeToString(sum[:16])
+}
+
 // Request encapsulates the translated payload that will be sent to a provider executor.
 type Request struct {
 	// Model is the upstream model identifier after translation.
@@ -0,0 +1,43 @@
+package cliproxy
+
+import (
+	"path/filepath"
+	"plugin"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	executorPluginsMu sync.Mutex
+	executorPlugins   = make(map[string]struct{})
+)
+
+// loadExecutorPlugins opens the Go plugins listed under executor-plugins. A
+// plugin registers its executors from init via RegisterExecutorFactory, so
+// opening it is all that is needed. Go cannot unload plugins: paths already
+// opened are skipped, and removing one from the config takes a restart.
+func loadExecutorPlugins(paths []string) {
+	executorPluginsMu.Lock()
+	defer executorPluginsMu.Unlock()
+
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if abs, errAbs := filepath.Abs(path); errAbs == nil {
+			path = abs
+		}
+		if _, loaded := executorPlugins[path]; loaded {
+			continue
+		}
+		if _, errOpen := plugin.Open(path); errOpen != nil {
+			log.Errorf("failed to load executor plugin %s: %v", path, errOpen)
+			continue
+		}
+		executorPlugins[path] = struct{}{}
+		log.Infof("loaded executor plugin %s", path)
+	}
+}
@@ -0,0 +1,72 @@
+package cliproxy
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// ExecutorFactory builds the executor serving a provider. It is called again
+// after every config reload so the executor can observe the new configuration.
+type ExecutorFactory func(cfg *config.Config) coreauth.ProviderExecutor
+
+// ExecutorModelLister is implemented by out-of-tree executors that want their
+// models listed under /v1/models for each auth of their provider.
+type ExecutorModelLister interface {
+	ListModels(ctx context.Context, auth *coreauth.Auth) []*ModelInfo
+}
+
+var (
+	executorFactoriesMu sync.RWMutex
+	executorFactories   = make(map[string]ExecutorFactory)
+)
+
+// RegisterExecutorFactory makes factory serve auths whose provider equals
+// provider (case-insensitive), taking precedence over any built-in executor.
+// Registering the same provider again replaces the previous factory.
+func RegisterExecutorFactory(provider string, factory ExecutorFactory) {
+	normalized := strings.ToLower(strings.TrimSpace(provider))
+	if normalized == "" || factory == nil {
+		return
+	}
+	executorFactoriesMu.Lock()
+	executorFactories[normalized] = factory
+	executorFactoriesMu.Unlock()
+}
+
+// UnregisterExecutorFactory removes the factory registered for provider.
+func UnregisterExecutorFactory(provider string) {
+	normalized := strings.ToLower(strings.TrimSpace(provider))
+	if normalized == "" {
+		return
+	}
+	executorFactoriesMu.Lock()
+	delete(executorFactories, normalized)
+	executorFactoriesMu.Unlock()
+}
+
+// LookupExecutorFactory returns the factory registered for provider.
+func LookupExecutorFactory(provider string) (ExecutorFactory, bool) {
+	normalized := strings.ToLower(strings.TrimSpace(provider))
+	executorFactoriesMu.RLock()
+	factory, ok := executorFactories[normalized]
+	executorFactoriesMu.RUnlock()
+	return factory, ok
+}
+
+// executorModelLister returns the executor bound to provider when it lists its
+// own models.
+func (s *Service) executorModelLister(provider string) (ExecutorModelLister, bool) {
+	if s == nil || s.coreManager == nil {
+		return nil, false
+	}
+	exec, ok := s.coreManager.Executor(provider)
+	if !ok {
+		return nil, false
+	}
+	lister, ok := exec.(ExecutorModelLister)
+	return lister, ok
+}
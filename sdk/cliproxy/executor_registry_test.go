@@ -0,0 +1,81 @@
+package cliproxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+type pluginTestExecutor struct{ provider string }
+
+func (e pluginTestExecutor) Identifier() string { return e.provider }
+
+func (pluginTestExecutor) Execute(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{Payload: []byte(`{}`)}, nil
+}
+
+func (pluginTestExecutor) ExecuteStream(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (*cliproxyexecutor.StreamResult, error) {
+	return nil, nil
+}
+
+func (pluginTestExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (pluginTestExecutor) CountTokens(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (pluginTestExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, nil
+}
+
+func (e pluginTestExecutor) ListModels(context.Context, *coreauth.Auth) []*ModelInfo {
+	return []*ModelInfo{{ID: e.provider + "-large", Object: "model", Type: e.provider}}
+}
+
+func TestEnsureExecutorsForAuth_UsesRegisteredFactory(t *testing.T) {
+	RegisterExecutorFactory("Acme", func(*config.Config) coreauth.ProviderExecutor {
+		return pluginTestExecutor{provider: "acme"}
+	})
+	t.Cleanup(func() { UnregisterExecutorFactory("acme") })
+
+	service := &Service{cfg: &config.Config{}, coreManager: coreauth.NewManager(nil, nil, nil)}
+	auth := &coreauth.Auth{ID: "acme-auth", Provider: "acme", Status: coreauth.StatusActive}
+	service.ensureExecutorsForAuth(auth)
+
+	exec, ok := service.coreManager.Executor("acme")
+	if !ok {
+		t.Fatal("expected an executor for acme")
+	}
+	if _, isPlugin := exec.(pluginTestExecutor); !isPlugin {
+		t.Fatalf("executor = %T, want the registered factory's executor", exec)
+	}
+
+	reg := registry.GetGlobalRegistry()
+	reg.UnregisterClient(auth.ID)
+	t.Cleanup(func() { reg.UnregisterClient(auth.ID) })
+	service.registerModelsForAuth(auth)
+	models := reg.GetModelsForClient(auth.ID)
+	if len(models) != 1 || models[0].ID != "acme-large" {
+		t.Fatalf("expected the executor's listed model, got %+v", models)
+	}
+}
+
+func TestEnsureExecutorsForAuth_KeepsEmbedderExecutor(t *testing.T) {
+	service := &Service{cfg: &config.Config{}, coreManager: coreauth.NewManager(nil, nil, nil)}
+	service.coreManager.RegisterExecutor(pluginTestExecutor{provider: "inhouse"})
+
+	auth := &coreauth.Auth{ID: "inhouse-auth", Provider: "inhouse", Status: coreauth.StatusActive}
+	service.ensureExecutorsForAuthWithMode(auth, true)
+
+	exec, _ := service.coreManager.Executor("inhouse")
+	if _, isPlugin := exec.(pluginTestExecutor); !isPlugin {
+		t.Fatalf("executor = %T, want the embedder's executor to be kept", exec)
+	}
+}
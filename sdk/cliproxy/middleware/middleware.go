@@ -0,0 +1,287 @@
+// Package middleware exposes ordered hooks around the four points where a
+// proxied request can be inspected or rewritten:
+//
+//   - pre-translation: the client request, in the client's format, before a
+//     credential is selected;
+//   - post-translation: the payload after it has been translated into the
+//     upstream provider's format;
+//   - pre-upstream: the outbound *http.Request, after provider headers and
+//     before request signing;
+//   - post-response: the response returned to the client, once for a
+//     non-streaming body or once per chunk of a stream.
+//
+// Middleware runs in registration order. Features such as moderation,
+// caching or prompt rewriting can be composed from these hooks by embedders
+// without dedicated configuration flags.
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// Request describes a client request as the API handler received it. It is
+// shared, by pointer, with every later stage of the same request.
+type Request struct {
+	// Format is the client-facing schema, e.g. "openai" or "claude".
+	Format sdktranslator.Format
+	// Model is the requested model. Pre-translation middleware may rewrite it
+	// to change routing.
+	Model string
+	// Stream reports whether the client asked for a streaming response.
+	Stream bool
+	// CountTokens is set for token counting requests.
+	CountTokens bool
+	// Payload is the raw request body in Format.
+	Payload []byte
+	// Headers holds the inbound client headers, when available.
+	Headers http.Header
+	// Values carries request-scoped data between stages.
+	Values map[string]any
+	// Reply, when set by pre-translation middleware, is returned to the client
+	// instead of calling upstream. For streaming requests it is delivered as a
+	// single chunk and must already be in the client's stream format.
+	Reply []byte
+}
+
+// TranslatedRequest is the request payload in the upstream provider's format.
+type TranslatedRequest struct {
+	// Request is the originating client request.
+	Request *Request
+	// Auth is the credential selected for this attempt.
+	Auth *cliproxyauth.Auth
+	// Model is the model sent upstream, after aliasing.
+	Model string
+	// Payload is the translated request body.
+	Payload []byte
+}
+
+// UpstreamRequest wraps the outbound HTTP request for one upstream attempt.
+type UpstreamRequest struct {
+	// Request is the originating client request.
+	Request *Request
+	// Auth is the credential selected for this attempt.
+	Auth *cliproxyauth.Auth
+	// Model is the model sent upstream, after aliasing.
+	Model string
+	// HTTP is the request about to be sent. Its body is already final.
+	HTTP *http.Request
+}
+
+// Response is a response body on its way back to the client.
+type Response struct {
+	// Request is the originating client request.
+	Request *Request
+	// Payload is the whole response body, or a single chunk when Chunk is set.
+	Payload []byte
+	// Chunk reports whether Payload is one chunk of a streaming response.
+	Chunk bool
+	// Headers holds the upstream headers forwarded to the client, if any.
+	Headers http.Header
+}
+
+// PreTranslationFunc inspects or rewrites the client request. Returning an
+// error rejects the request; see Reject.
+type PreTranslationFunc func(ctx context.Context, req *Request) error
+
+// PostTranslationFunc inspects or rewrites the translated payload. Returning
+// an error fails the upstream attempt.
+type PostTranslationFunc func(ctx context.Context, req *TranslatedRequest) error
+
+// PreUpstreamFunc inspects or mutates the outbound HTTP request. Returning an
+// error fails the upstream attempt.
+type PreUpstreamFunc func(ctx context.Context, req *UpstreamRequest) error
+
+// PostResponseFunc inspects or rewrites a response body or stream chunk.
+// Returning an error fails the response.
+type PostResponseFunc func(ctx context.Context, resp *Response) error
+
+// Error rejects a request with a client-facing status code.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// StatusCode reports the HTTP status returned to the client.
+func (e *Error) StatusCode() int { return e.Status }
+
+// Reject builds an error that middleware can return to fail a request with
+// status and message.
+func Reject(status int, message string) error {
+	return &Error{Status: status, Message: message}
+}
+
+type entry[T any] struct {
+	name string
+	fn   T
+}
+
+// chain is an ordered, name-keyed list of middleware for one stage.
+type chain[T any] struct {
+	mu      sync.RWMutex
+	entries []entry[T]
+}
+
+func (c *chain[T]) use(name string, fn T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.entries {
+		if c.entries[i].name == name {
+			c.entries[i].fn = fn
+			return
+		}
+	}
+	c.entries = append(c.entries, entry[T]{name: name, fn: fn})
+}
+
+func (c *chain[T]) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.entries {
+		if c.entries[i].name == name {
+			c.entries = append(c.entries[:i:i], c.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *chain[T]) snapshot() []T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]T, len(c.entries))
+	for i := range c.entries {
+		out[i] = c.entries[i].fn
+	}
+	return out
+}
+
+func (c *chain[T]) empty() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries) == 0
+}
+
+var (
+	preTranslation  chain[PreTranslationFunc]
+	postTranslation chain[PostTranslationFunc]
+	preUpstream     chain[PreUpstreamFunc]
+	postResponse    chain[PostResponseFunc]
+)
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// UsePreTranslation appends fn to the pre-translation stage under name.
+// Registering the same name again replaces the previous function in place.
+func UsePreTranslation(name string, fn PreTranslationFunc) {
+	if name = normalizeName(name); name != "" && fn != nil {
+		preTranslation.use(name, fn)
+	}
+}
+
+// UsePostTranslation appends fn to the post-translation stage under name.
+func UsePostTranslation(name string, fn PostTranslationFunc) {
+	if name = normalizeName(name); name != "" && fn != nil {
+		postTranslation.use(name, fn)
+	}
+}
+
+// UsePreUpstream appends fn to the pre-upstream stage under name.
+func UsePreUpstream(name string, fn PreUpstreamFunc) {
+	if name = normalizeName(name); name != "" && fn != nil {
+		preUpstream.use(name, fn)
+	}
+}
+
+// UsePostResponse appends fn to the post-response stage under name.
+func UsePostResponse(name string, fn PostResponseFunc) {
+	if name = normalizeName(name); name != "" && fn != nil {
+		postResponse.use(name, fn)
+	}
+}
+
+// Remove unregisters name from every stage.
+func Remove(name string) {
+	name = normalizeName(name)
+	if name == "" {
+		return
+	}
+	preTranslation.remove(name)
+	postTranslation.remove(name)
+	preUpstream.remove(name)
+	postResponse.remove(name)
+}
+
+// HasUpstreamMiddleware reports whether any post-translation or pre-upstream
+// middleware is registered, letting executors skip the extra transport.
+func HasUpstreamMiddleware() bool {
+	return !postTranslation.empty() || !preUpstream.empty()
+}
+
+// RunPreTranslation runs the pre-translation stage. It stops early once a
+// middleware sets req.Reply.
+func RunPreTranslation(ctx context.Context, req *Request) error {
+	for _, fn := range preTranslation.snapshot() {
+		if errRun := fn(ctx, req); errRun != nil {
+			return errRun
+		}
+		if req.Reply != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// RunPostTranslation runs the post-translation stage.
+func RunPostTranslation(ctx context.Context, req *TranslatedRequest) error {
+	for _, fn := range postTranslation.snapshot() {
+		if errRun := fn(ctx, req); errRun != nil {
+			return errRun
+		}
+	}
+	return nil
+}
+
+// RunPreUpstream runs the pre-upstream stage.
+func RunPreUpstream(ctx context.Context, req *UpstreamRequest) error {
+	for _, fn := range preUpstream.snapshot() {
+		if errRun := fn(ctx, req); errRun != nil {
+			return errRun
+		}
+	}
+	return nil
+}
+
+// RunPostResponse runs the post-response stage.
+func RunPostResponse(ctx context.Context, resp *Response) error {
+	for _, fn := range postResponse.snapshot() {
+		if errRun := fn(ctx, resp); errRun != nil {
+			return errRun
+		}
+	}
+	return nil
+}
+
+type requestKey struct{}
+
+// WithRequest attaches req to ctx so later stages can reach it.
+func WithRequest(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// RequestFromContext returns the request attached by WithRequest, or nil.
+func RequestFromContext(ctx context.Context) *Request {
+	if ctx == nil {
+		return nil
+	}
+	req, _ := ctx.Value(requestKey{}).(*Request)
+	return req
+}
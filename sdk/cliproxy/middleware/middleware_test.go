@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestRunPreTranslation_OrderAndReply(t *testing.T) {
+	var order []string
+	UsePreTranslation("First", func(_ context.Context, req *Request) error {
+		order = append(order, "first")
+		req.Model = "rewritten"
+		return nil
+	})
+	UsePreTranslation("cache", func(_ context.Context, req *Request) error {
+		order = append(order, "cache")
+		req.Reply = []byte(`{"cached":true}`)
+		return nil
+	})
+	UsePreTranslation("last", func(context.Context, *Request) error {
+		order = append(order, "last")
+		return nil
+	})
+	// Re-registering keeps the original position.
+	UsePreTranslation("first", func(_ context.Context, req *Request) error {
+		order = append(order, "first-v2")
+		req.Model = "rewritten"
+		return nil
+	})
+	t.Cleanup(func() {
+		Remove("first")
+		Remove("cache")
+		Remove("last")
+	})
+
+	req := &Request{Model: "original"}
+	if errRun := RunPreTranslation(context.Background(), req); errRun != nil {
+		t.Fatalf("RunPreTranslation: %v", errRun)
+	}
+	if len(order) != 2 || order[0] != "first-v2" || order[1] != "cache" {
+		t.Fatalf("order = %v, want the chain to stop after the reply", order)
+	}
+	if req.Model != "rewritten" || string(req.Reply) != `{"cached":true}` {
+		t.Fatalf("request = %+v", req)
+	}
+}
+
+func TestReject_CarriesStatus(t *testing.T) {
+	UsePreTranslation("moderation", func(context.Context, *Request) error {
+		return Reject(http.StatusForbidden, "flagged")
+	})
+	t.Cleanup(func() { Remove("moderation") })
+
+	errRun := RunPreTranslation(context.Background(), &Request{})
+	se, ok := errRun.(interface{ StatusCode() int })
+	if !ok || se.StatusCode() != http.StatusForbidden || errRun.Error() != "flagged" {
+		t.Fatalf("error = %v", errRun)
+	}
+	if HasUpstreamMiddleware() {
+		t.Fatal("no upstream middleware is registered")
+	}
+}
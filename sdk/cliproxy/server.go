@@ -0,0 +1,229 @@
+package cliproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// Option configures a Server created by New.
+type Option func(*serverSettings)
+
+type serverSettings struct {
+	builder      *Builder
+	configFile   string
+	hasConfig    bool
+	usagePlugins []usage.Plugin
+}
+
+// WithConfig sets the configuration the server runs with.
+func WithConfig(cfg *config.Config) Option {
+	return func(s *serverSettings) {
+		s.builder.WithConfig(cfg)
+		s.hasConfig = cfg != nil
+	}
+}
+
+// WithConfigFile points the server at a config file. The file is loaded when
+// WithConfig is not given, and is watched for hot reload either way.
+func WithConfigFile(path string) Option {
+	return func(s *serverSettings) {
+		s.configFile = path
+		s.builder.WithConfigPath(path)
+	}
+}
+
+// WithListener serves the proxy on an existing listener instead of binding
+// host:port from the configuration.
+func WithListener(listener net.Listener) Option {
+	return func(s *serverSettings) {
+		if listener != nil {
+			s.builder.WithServerOptions(api.WithListener(listener))
+		}
+	}
+}
+
+// WithUsagePlugin registers a usage plugin with the default usage manager.
+func WithUsagePlugin(plugin usage.Plugin) Option {
+	return func(s *serverSettings) {
+		if plugin != nil {
+			s.usagePlugins = append(s.usagePlugins, plugin)
+		}
+	}
+}
+
+// WithHooks registers lifecycle hooks on the underlying service.
+func WithHooks(hooks Hooks) Option {
+	return func(s *serverSettings) {
+		s.builder.WithHooks(hooks)
+	}
+}
+
+// WithServerOptions appends HTTP server options such as middleware or extra routes.
+func WithServerOptions(opts ...api.ServerOption) Option {
+	return func(s *serverSettings) {
+		s.builder.WithServerOptions(opts...)
+	}
+}
+
+// WithCoreAuthManager overrides the runtime auth manager used for selection and execution.
+func WithCoreAuthManager(mgr *coreauth.Manager) Option {
+	return func(s *serverSettings) {
+		s.builder.WithCoreAuthManager(mgr)
+	}
+}
+
+// Server is the proxy packaged for embedding: built by New, started with
+// Start and stopped with Shutdown.
+type Server struct {
+	service *Service
+	ready   chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+}
+
+// New assembles a Server from the given options. Either WithConfig or
+// WithConfigFile is required.
+func New(opts ...Option) (*Server, error) {
+	settings := &serverSettings{builder: NewBuilder()}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(settings)
+		}
+	}
+	if !settings.hasConfig {
+		if settings.configFile == "" {
+			return nil, fmt.Errorf("cliproxy: configuration is required")
+		}
+		cfg, errLoad := config.LoadConfig(settings.configFile)
+		if errLoad != nil {
+			return nil, fmt.Errorf("cliproxy: load config: %w", errLoad)
+		}
+		settings.builder.WithConfig(cfg)
+	}
+
+	srv := &Server{ready: make(chan struct{}), done: make(chan struct{})}
+	hooks := settings.builder.hooks
+	afterStart := hooks.OnAfterStart
+	hooks.OnAfterStart = func(s *Service) {
+		if afterStart != nil {
+			afterStart(s)
+		}
+		close(srv.ready)
+	}
+	settings.builder.WithHooks(hooks)
+
+	service, errBuild := settings.builder.Build()
+	if errBuild != nil {
+		return nil, errBuild
+	}
+	for _, plugin := range settings.usagePlugins {
+		usage.RegisterPlugin(plugin)
+	}
+	srv.service = service
+	return srv, nil
+}
+
+// Service exposes the underlying service.
+func (s *Server) Service() *Service {
+	if s == nil {
+		return nil
+	}
+	return s.service
+}
+
+// Start runs the proxy in the background and returns once the HTTP server is
+// accepting requests. ctx bounds startup only; use Shutdown to stop the server.
+func (s *Server) Start(ctx context.Context) error {
+	if s == nil || s.service == nil {
+		return fmt.Errorf("cliproxy: server is nil")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return fmt.Errorf("cliproxy: server already started")
+	}
+	s.started = true
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	go func() {
+		defer close(s.done)
+		errRun := s.service.Run(runCtx)
+		if errors.Is(errRun, context.Canceled) {
+			errRun = nil
+		}
+		s.mu.Lock()
+		s.err = errRun
+		s.mu.Unlock()
+	}()
+
+	select {
+	case <-s.ready:
+		return nil
+	case <-s.done:
+		if errRun := s.Err(); errRun != nil {
+			return errRun
+		}
+		return fmt.Errorf("cliproxy: server stopped during startup")
+	case <-ctx.Done():
+		cancel()
+		<-s.done
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops the server and waits for it to exit or for ctx to expire.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s == nil || s.service == nil {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.mu.Lock()
+	started, cancel := s.started, s.cancel
+	s.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	errShutdown := s.service.Shutdown(ctx)
+	cancel()
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if errShutdown != nil {
+		return errShutdown
+	}
+	return s.Err()
+}
+
+// Done is closed once the server has stopped.
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error the server stopped with, if any.
+func (s *Server) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
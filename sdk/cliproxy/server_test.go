@@ -0,0 +1,59 @@
+package cliproxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestNew_RequiresConfig(t *testing.T) {
+	if _, err := New(); err == nil {
+		t.Fatal("expected an error without WithConfig or WithConfigFile")
+	}
+}
+
+func TestServer_StartServesOnListenerAndShutsDown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	cfg := &config.Config{AuthDir: t.TempDir()}
+	srv, err := New(WithConfig(cfg), WithListener(listener))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err = srv.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if errAgain := srv.Start(ctx); errAgain == nil {
+		t.Fatal("second Start should fail")
+	}
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/")
+	if err != nil {
+		t.Fatalf("GET /: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET / status = %d, want 200", resp.StatusCode)
+	}
+
+	if err = srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	select {
+	case <-srv.Done():
+	default:
+		t.Fatal("Done should be closed after Shutdown")
+	}
+	if _, err = net.DialTimeout("tcp", listener.Addr().String(), time.Second); err == nil {
+		t.Fatal("listener should be closed after Shutdown")
+	}
+}
@@ -8,11 +8,14 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	externalaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/external_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/apiversion"
 	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
@@ -61,6 +64,9 @@ type Service struct {
 	// pprofServer manages the optional pprof HTTP debug server.
 	pprofServer *pprofServer
 
+	// sharedStateUnsubscribe removes the replica event subscriptions.
+	sharedStateUnsubscribe []func()
+
 	// serverErr channel for server startup/shutdown errors.
 	serverErr chan error
 
@@ -356,6 +362,8 @@ func (s *Service) applyRetryConfig(cfg *config.Config) {
 	}
 	maxInterval := time.Duration(cfg.MaxRetryInterval) * time.Second
 	s.coreManager.SetRetryConfig(cfg.RequestRetry, maxInterval, cfg.MaxRetryCredentials)
+	s.coreManager.SetRateLimitQueue(time.Duration(cfg.RateLimitQueue.MaxWaitSeconds) * time.Second)
+	s.coreManager.SetQualityGuard(cfg.QualityGuard.MaxRetries, cfg.QualityGuard.SameCredentialRetries)
 }
 
 func openAICompatInfoFromAuth(a *coreauth.Auth) (providerKey string, compatName string, ok bool) {
@@ -386,6 +394,14 @@ func (s *Service) ensureExecutorsForAuthWithMode(a *coreauth.Auth, forceReplace
 	if s == nil || s.coreManager == nil || a == nil {
 		return
 	}
+	if factory, ok := LookupExecutorFactory(a.Provider); ok {
+		if !a.Disabled {
+			if exec := factory(s.cfg); exec != nil {
+				s.coreManager.RegisterExecutor(exec)
+			}
+		}
+		return
+	}
 	if strings.EqualFold(strings.TrimSpace(a.Provider), "codex") {
 		if !forceReplace {
 			existingExecutor, hasExecutor := s.coreManager.Executor("codex")
@@ -450,6 +466,12 @@ func (s *Service) ensureExecutorsForAuthWithMode(a *coreauth.Auth, forceReplace
 		if providerKey == "" {
 			providerKey = "openai-compatibility"
 		}
+		// Leave executors an embedder registered on the core manager in place.
+		if existing, ok := s.coreManager.Executor(providerKey); ok {
+			if _, isCompat := existing.(*executor.OpenAICompatExecutor); !isCompat {
+				return
+			}
+		}
 		s.coreManager.RegisterExecutor(executor.NewOpenAICompatExecutor(providerKey, s.cfg))
 	}
 }
@@ -518,6 +540,9 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 
 	s.applyRetryConfig(s.cfg)
+	s.applySharedStateConfig(s.cfg)
+	apiversion.Apply(s.cfg.APIVersions)
+	loadExecutorPlugins(s.cfg.ExecutorPlugins)
 
 	if s.coreManager != nil {
 		if errLoad := s.coreManager.Load(ctx); errLoad != nil {
@@ -623,7 +648,7 @@ func (s *Service) Run(ctx context.Context) error {
 	}()
 
 	time.Sleep(100 * time.Millisecond)
-	fmt.Printf("API server started successfully on: %s:%d\n", s.cfg.Host, s.cfg.Port)
+	fmt.Printf("API server started successfully on: %s\n", s.server.Addr())
 
 	s.applyPprofConfig(s.cfg)
 
@@ -673,6 +698,9 @@ func (s *Service) Run(ctx context.Context) error {
 
 		s.applyRetryConfig(newCfg)
 		s.applyPprofConfig(newCfg)
+		s.applySharedStateConfig(newCfg)
+		apiversion.Apply(newCfg.APIVersions)
+		loadExecutorPlugins(newCfg.ExecutorPlugins)
 		if s.server != nil {
 			s.server.UpdateClients(newCfg)
 		}
@@ -706,7 +734,9 @@ func (s *Service) Run(ctx context.Context) error {
 		}
 		log.Debugf("kiro refresh callback: notifying watcher for token %s", tokenID)
 		watcherWrapper.NotifyTokenRefreshed(tokenID, tokenData.AccessToken, tokenData.RefreshToken, tokenData.ExpiresAt)
+		publishKiroTokenRefresh(tokenID, tokenData)
 	})
+	s.subscribeKiroTokenRefreshes(watcherWrapper)
 	log.Debug("kiro: connected background refresh callback to watcher")
 
 	watcherCtx, watcherCancel := context.WithCancel(context.Background())
@@ -785,6 +815,15 @@ func (s *Service) Shutdown(ctx context.Context) error {
 			}
 		}
 
+		externalaccess.Shutdown()
+
+		if errShutdownShared := s.shutdownSharedState(); errShutdownShared != nil {
+			log.Errorf("failed to close shared state: %v", errShutdownShared)
+			if shutdownErr == nil {
+				shutdownErr = errShutdownShared
+			}
+		}
+
 		// no legacy clients to persist
 
 		if s.server != nil {
@@ -897,6 +936,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 	case "antigravity":
 		models = registry.GetAntigravityModels()
 		models = applyExcludedModelsWithAlias(s.cfg, provider, authKind, models, excluded)
+		models = applyAntigravityAutoTierModels(s.cfg, models)
 	case "claude":
 		models = registry.GetClaudeModels()
 		if entry := s.resolveConfigClaudeKey(a); entry != nil {
@@ -958,6 +998,13 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		models = executor.GitLabModelsFromAuth(a)
 		models = applyExcludedModels(models, excluded)
 	default:
+		if lister, ok := s.executorModelLister(provider); ok && !compatDetected {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			models = lister.ListModels(ctx, a)
+			cancel()
+			models = applyExcludedModelsWithAlias(s.cfg, provider, authKind, models, excluded)
+			break
+		}
 		// Handle OpenAI-compatibility providers by name using config
 		if s.cfg != nil {
 			providerKey := provider
@@ -1016,6 +1063,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 							OwnedBy:     compat.Name,
 							Type:        "openai-compatibility",
 							DisplayName: modelID,
+							StreamMode:  strings.ToLower(strings.TrimSpace(m.StreamMode)),
 							UserDefined: true,
 						})
 					}
@@ -1024,7 +1072,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 						if providerKey == "" {
 							providerKey = "openai-compatibility"
 						}
-						s.registerResolvedModelsForAuth(a, providerKey, applyModelPrefixes(ms, a.Prefix, s.cfg.ForceModelPrefix))
+						s.registerResolvedModelsForAuth(a, providerKey, applyModelPrefixes(ms, a.ModelPrefixes(), s.cfg.ForceModelPrefix))
 					} else {
 						// Ensure stale registrations are cleared when model list becomes empty.
 						GlobalModelRegistry().UnregisterClient(a.ID)
@@ -1045,7 +1093,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		if key == "" {
 			key = strings.ToLower(strings.TrimSpace(a.Provider))
 		}
-		s.registerResolvedModelsForAuth(a, key, applyModelPrefixes(models, a.Prefix, s.cfg != nil && s.cfg.ForceModelPrefix))
+		s.registerResolvedModelsForAuth(a, key, applyModelPrefixes(models, a.ModelPrefixes(), s.cfg != nil && s.cfg.ForceModelPrefix))
 		return
 	}
 
@@ -1277,12 +1325,13 @@ func (s *Service) backfillAntigravityModels(source *coreauth.Auth, primaryModels
 		}
 
 		models := applyExcludedModelsWithAlias(s.cfg, "antigravity", authKind, primaryModels, excluded)
+		models = applyAntigravityAutoTierModels(s.cfg, models)
 		models = applyOAuthModelAlias(s.cfg, "antigravity", authKind, models)
 		if len(models) == 0 {
 			continue
 		}
 
-		reg.RegisterClient(candidateID, "antigravity", applyModelPrefixes(models, candidate.Prefix, s.cfg != nil && s.cfg.ForceModelPrefix))
+		reg.RegisterClient(candidateID, "antigravity", applyModelPrefixes(models, candidate.ModelPrefixes(), s.cfg != nil && s.cfg.ForceModelPrefix))
 		log.Debugf("antigravity models backfilled for auth %s using primary model list", candidateID)
 	}
 }
@@ -1405,9 +1454,8 @@ func applyExcludedModels(models []*ModelInfo, excluded []string) []*ModelInfo {
 	return filtered
 }
 
-func applyModelPrefixes(models []*ModelInfo, prefix string, forceModelPrefix bool) []*ModelInfo {
-	trimmedPrefix := strings.TrimSpace(prefix)
-	if trimmedPrefix == "" || len(models) == 0 {
+func applyModelPrefixes(models []*ModelInfo, prefixes []string, forceModelPrefix bool) []*ModelInfo {
+	if len(prefixes) == 0 || len(models) == 0 {
 		return models
 	}
 
@@ -1437,12 +1485,14 @@ func applyModelPrefixes(models []*ModelInfo, prefix string, forceModelPrefix boo
 		if baseID == "" {
 			continue
 		}
-		if !forceModelPrefix || trimmedPrefix == baseID {
+		if !forceModelPrefix || slices.Contains(prefixes, baseID) {
 			addModel(model)
 		}
-		clone := *model
-		clone.ID = trimmedPrefix + "/" + baseID
-		addModel(&clone)
+		for _, prefix := range prefixes {
+			clone := *model
+			clone.ID = prefix + "/" + baseID
+			addModel(&clone)
+		}
 	}
 	return out
 }
@@ -1595,6 +1645,21 @@ func rewriteModelInfoName(name, oldID, newID string) string {
 	return name
 }
 
+// applyAntigravityAutoTierModels appends tierless family models when
+// routing.antigravity-auto-tier is enabled so clients can omit the tier.
+func applyAntigravityAutoTierModels(cfg *config.Config, models []*ModelInfo) []*ModelInfo {
+	if cfg == nil || !cfg.Routing.AntigravityAutoTier {
+		return models
+	}
+	families := registry.AntigravityTierFamilyModels(models)
+	if len(families) == 0 {
+		return models
+	}
+	out := make([]*ModelInfo, 0, len(models)+len(families))
+	out = append(out, models...)
+	return append(out, families...)
+}
+
 func applyOAuthModelAlias(cfg *config.Config, provider, authKind string, models []*ModelInfo) []*ModelInfo {
 	if cfg == nil || len(models) == 0 {
 		return models
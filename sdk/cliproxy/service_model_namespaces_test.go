@@ -0,0 +1,30 @@
+package cliproxy
+
+import (
+	"reflect"
+	"testing"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestApplyModelPrefixes_PublishesEveryNamespace(t *testing.T) {
+	auth := &coreauth.Auth{
+		Prefix:     "team",
+		Attributes: map[string]string{coreauth.ModelNamespacesAttr: "acme/research"},
+	}
+	models := []*ModelInfo{{ID: "gemini-2.5-pro"}}
+
+	ids := func(list []*ModelInfo) []string {
+		out := make([]string, 0, len(list))
+		for _, model := range list {
+			out = append(out, model.ID)
+		}
+		return out
+	}
+	if got, want := ids(applyModelPrefixes(models, auth.ModelPrefixes(), false)), []string{"gemini-2.5-pro", "acme/research/gemini-2.5-pro", "team/gemini-2.5-pro"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("applyModelPrefixes() = %v, want %v", got, want)
+	}
+	if got, want := ids(applyModelPrefixes(models, auth.ModelPrefixes(), true)), []string{"acme/research/gemini-2.5-pro", "team/gemini-2.5-pro"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("applyModelPrefixes(force) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+package cliproxy
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	kiroauth "github.com/router-for-me/CLIProxyAPI/v6/internal/auth/kiro"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// authResultTopic carries credential state changes between replicas.
+	authResultTopic = "auth-result"
+	// authRefreshTopic carries auths refreshed by the leader to the followers.
+	authRefreshTopic = "auth-refreshed"
+	// kiroTokenRefreshTopic carries tokens renewed by the Kiro background refresher.
+	kiroTokenRefreshTopic = "kiro-token-refreshed"
+
+	sharedStatePublishTimeout = 3 * time.Second
+)
+
+// kiroTokenRefresh is the payload published on kiroTokenRefreshTopic.
+type kiroTokenRefresh struct {
+	TokenID      string `json:"token_id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    string `json:"expires_at"`
+}
+
+// publishShared sends payload to the other replicas without blocking the caller.
+func publishShared(topic, subject string, payload any) {
+	if !sharedstate.Distributed() {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sharedStatePublishTimeout)
+		defer cancel()
+		if errPublish := sharedstate.Publish(ctx, topic, payload); errPublish != nil {
+			log.Debugf("shared state: failed to publish %s for %s: %v", topic, subject, errPublish)
+		}
+	}()
+}
+
+// sharedStateBroadcaster publishes credential results and refreshes so that
+// replicas cool down, recover and rotate tokens in step.
+type sharedStateBroadcaster struct{}
+
+func (sharedStateBroadcaster) BroadcastResult(_ context.Context, result coreauth.Result) {
+	publishShared(authResultTopic, result.AuthID, result)
+}
+
+func (sharedStateBroadcaster) BroadcastRefresh(_ context.Context, auth *coreauth.Auth) {
+	publishShared(authRefreshTopic, auth.ID, auth)
+}
+
+func publishKiroTokenRefresh(tokenID string, tokenData *kiroauth.KiroTokenData) {
+	publishShared(kiroTokenRefreshTopic, tokenID, kiroTokenRefresh{
+		TokenID:      tokenID,
+		AccessToken:  tokenData.AccessToken,
+		RefreshToken: tokenData.RefreshToken,
+		ExpiresAt:    tokenData.ExpiresAt,
+	})
+}
+
+func (s *Service) applySharedStateConfig(cfg *config.Config) {
+	if s == nil || cfg == nil {
+		return
+	}
+	if errApply := sharedstate.Apply(cfg.SharedState); errApply != nil {
+		log.Errorf("shared state: %v; keeping the previous backend", errApply)
+	}
+	if len(s.sharedStateUnsubscribe) > 0 || s.coreManager == nil {
+		return
+	}
+	manager := s.coreManager
+	manager.SetResultBroadcaster(sharedStateBroadcaster{})
+	manager.SetRefreshBroadcaster(sharedStateBroadcaster{})
+	manager.SetRefreshGate(sharedstate.IsLeader)
+	s.sharedStateUnsubscribe = append(s.sharedStateUnsubscribe,
+		sharedstate.Subscribe(authResultTopic, func(payload json.RawMessage) {
+			var result coreauth.Result
+			if errDecode := json.Unmarshal(payload, &result); errDecode != nil {
+				log.Debugf("shared state: dropping malformed result: %v", errDecode)
+				return
+			}
+			manager.ApplyRemoteResult(context.Background(), result)
+		}),
+		sharedstate.Subscribe(authRefreshTopic, func(payload json.RawMessage) {
+			var auth coreauth.Auth
+			if errDecode := json.Unmarshal(payload, &auth); errDecode != nil {
+				log.Debugf("shared state: dropping malformed refresh: %v", errDecode)
+				return
+			}
+			manager.ApplyRemoteAuth(context.Background(), &auth)
+		}),
+		// A newly elected leader checks for due refreshes right away rather
+		// than waiting out the refresh interval.
+		sharedstate.OnLeadershipChange(func(leading bool) {
+			if leading {
+				go manager.CheckRefreshes(context.Background())
+			}
+		}),
+	)
+}
+
+// subscribeKiroTokenRefreshes feeds tokens renewed on the leader into the
+// local watcher, as the Kiro refresh callback does for local refreshes.
+func (s *Service) subscribeKiroTokenRefreshes(watcher *WatcherWrapper) {
+	if s == nil || watcher == nil {
+		return
+	}
+	s.sharedStateUnsubscribe = append(s.sharedStateUnsubscribe,
+		sharedstate.Subscribe(kiroTokenRefreshTopic, func(payload json.RawMessage) {
+			var refresh kiroTokenRefresh
+			if errDecode := json.Unmarshal(payload, &refresh); errDecode != nil || refresh.TokenID == "" {
+				log.Debugf("shared state: dropping malformed kiro refresh: %v", errDecode)
+				return
+			}
+			watcher.NotifyTokenRefreshed(refresh.TokenID, refresh.AccessToken, refresh.RefreshToken, refresh.ExpiresAt)
+		}),
+	)
+}
+
+func (s *Service) shutdownSharedState() error {
+	if s == nil {
+		return nil
+	}
+	for _, unsubscribe := range s.sharedStateUnsubscribe {
+		unsubscribe()
+	}
+	s.sharedStateUnsubscribe = nil
+	return sharedstate.Close()
+}
@@ -0,0 +1,98 @@
+package signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// Default header names used by the HMAC signer.
+const (
+	DefaultHMACSignatureHeader = "X-Signature"
+	DefaultHMACTimestampHeader = "X-Signature-Timestamp"
+	DefaultHMACKeyIDHeader     = "X-Signature-Key-Id"
+)
+
+// HMACOptions configures NewHMACSigner.
+type HMACOptions struct {
+	// Secret is the shared HMAC key.
+	Secret string
+	// KeyID, when set, is sent in KeyIDHeader so the gateway can pick the key.
+	KeyID string
+	// Algorithm is "sha256" (default) or "sha512".
+	Algorithm string
+	// SignatureHeader, TimestampHeader and KeyIDHeader override the default header names.
+	SignatureHeader string
+	TimestampHeader string
+	KeyIDHeader     string
+	// Now overrides the clock; nil uses time.Now.
+	Now func() time.Time
+}
+
+type hmacSigner struct {
+	opts    HMACOptions
+	newHash func() hash.Hash
+}
+
+// NewHMACSigner returns a signer that adds a hex HMAC over
+//
+//	METHOD \n REQUEST-URI \n UNIX-TIMESTAMP \n HEX(SHA256(BODY))
+//
+// together with the timestamp it signed.
+func NewHMACSigner(opts HMACOptions) (Signer, error) {
+	if opts.Secret == "" {
+		return nil, errors.New("hmac signer: secret is required")
+	}
+	signer := &hmacSigner{opts: opts}
+	switch strings.ToLower(strings.TrimSpace(opts.Algorithm)) {
+	case "", "sha256":
+		signer.newHash = sha256.New
+	case "sha512":
+		signer.newHash = sha512.New
+	default:
+		return nil, fmt.Errorf("hmac signer: unsupported algorithm %q", opts.Algorithm)
+	}
+	if signer.opts.SignatureHeader == "" {
+		signer.opts.SignatureHeader = DefaultHMACSignatureHeader
+	}
+	if signer.opts.TimestampHeader == "" {
+		signer.opts.TimestampHeader = DefaultHMACTimestampHeader
+	}
+	if signer.opts.KeyIDHeader == "" {
+		signer.opts.KeyIDHeader = DefaultHMACKeyIDHeader
+	}
+	if signer.opts.Now == nil {
+		signer.opts.Now = time.Now
+	}
+	return signer, nil
+}
+
+func (s *hmacSigner) SignRequest(_ context.Context, req *http.Request, _ *cliproxyauth.Auth) error {
+	body, errBody := RequestBody(req)
+	if errBody != nil {
+		return fmt.Errorf("hmac signer: read body: %w", errBody)
+	}
+	bodyHash := sha256.Sum256(body)
+	timestamp := strconv.FormatInt(s.opts.Now().Unix(), 10)
+
+	mac := hmac.New(s.newHash, []byte(s.opts.Secret))
+	mac.Write([]byte(req.Method + "\n" + req.URL.RequestURI() + "\n" + timestamp + "\n" + hex.EncodeToString(bodyHash[:])))
+
+	req.Header.Set(s.opts.TimestampHeader, timestamp)
+	req.Header.Set(s.opts.SignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	if s.opts.KeyID != "" {
+		req.Header.Set(s.opts.KeyIDHeader, s.opts.KeyID)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Package signing provides the extension point used to sign or otherwise
+// mutate outbound upstream requests just before they reach the transport.
+// Signers are selected per credential through the request-signing config
+// section, either by a built-in type ("hmac", "sigv4") or by the name a
+// signer was registered under.
+package signing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// Signer mutates an outbound request in place, typically by adding signature
+// headers. It runs after all other headers have been applied. Returning an
+// error aborts the request.
+type Signer interface {
+	SignRequest(ctx context.Context, req *http.Request, auth *cliproxyauth.Auth) error
+}
+
+// SignerFunc adapts a function to the Signer interface.
+type SignerFunc func(ctx context.Context, req *http.Request, auth *cliproxyauth.Auth) error
+
+// SignRequest implements Signer.
+func (f SignerFunc) SignRequest(ctx context.Context, req *http.Request, auth *cliproxyauth.Auth) error {
+	return f(ctx, req, auth)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Signer)
+)
+
+// RegisterSigner makes signer available to request-signing entries whose type
+// equals name (case-insensitive). Registering the same name again replaces the
+// previous signer.
+func RegisterSigner(name string, signer Signer) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if normalized == "" || signer == nil {
+		return
+	}
+	registryMu.Lock()
+	registry[normalized] = signer
+	registryMu.Unlock()
+}
+
+// UnregisterSigner removes the signer registered under name.
+func UnregisterSigner(name string) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	if normalized == "" {
+		return
+	}
+	registryMu.Lock()
+	delete(registry, normalized)
+	registryMu.Unlock()
+}
+
+// LookupSigner returns the signer registered under name.
+func LookupSigner(name string) (Signer, bool) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	registryMu.RLock()
+	signer, ok := registry[normalized]
+	registryMu.RUnlock()
+	return signer, ok && signer != nil
+}
+
+// RequestBody returns the body of req without consuming it, so signers can
+// hash the payload. The request body is replaced with an equivalent reader
+// when it cannot be re-obtained through GetBody.
+func RequestBody(req *http.Request) ([]byte, error) {
+	if req == nil || req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, errGet := req.GetBody()
+		if errGet != nil {
+			return nil, errGet
+		}
+		defer func() { _ = body.Close() }()
+		return io.ReadAll(body)
+	}
+	data, errRead := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if errRead != nil {
+		return nil, errRead
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return data, nil
+}
@@ -0,0 +1,118 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestSigV4Signer_MatchesReferenceVector(t *testing.T) {
+	// get-vanilla from the AWS SigV4 test suite.
+	signer, errNew := NewSigV4Signer(SigV4Options{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+		Now:             func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	})
+	if errNew != nil {
+		t.Fatalf("NewSigV4Signer: %v", errNew)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if errSign := signer.SignRequest(context.Background(), req, nil); errSign != nil {
+		t.Fatalf("SignRequest: %v", errSign)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("Authorization = %q\nwant %q", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Fatalf("X-Amz-Date = %q", got)
+	}
+}
+
+func TestHMACSigner_SignsMethodURITimestampAndBody(t *testing.T) {
+	signer, errNew := NewHMACSigner(HMACOptions{
+		Secret: "s3cret",
+		KeyID:  "egress-1",
+		Now:    func() time.Time { return time.Unix(1700000000, 0) },
+	})
+	if errNew != nil {
+		t.Fatalf("NewHMACSigner: %v", errNew)
+	}
+	body := []byte(`{"model":"gpt-5"}`)
+	req, _ := http.NewRequest(http.MethodPost, "https://gateway.example.com/v1/responses?beta=1", bytes.NewReader(body))
+	if errSign := signer.SignRequest(context.Background(), req, &cliproxyauth.Auth{ID: "a"}); errSign != nil {
+		t.Fatalf("SignRequest: %v", errSign)
+	}
+
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("POST\n/v1/responses?beta=1\n1700000000\n" + hex.EncodeToString(bodyHash[:])))
+	if got, want := req.Header.Get(DefaultHMACSignatureHeader), hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Fatalf("signature = %q, want %q", got, want)
+	}
+	if got := req.Header.Get(DefaultHMACTimestampHeader); got != "1700000000" {
+		t.Fatalf("timestamp = %q", got)
+	}
+	if got := req.Header.Get(DefaultHMACKeyIDHeader); got != "egress-1" {
+		t.Fatalf("key id = %q", got)
+	}
+	sent, _ := io.ReadAll(req.Body)
+	if !bytes.Equal(sent, body) {
+		t.Fatalf("body consumed by signer: %q", sent)
+	}
+}
+
+func TestNewHMACSigner_RejectsInvalidOptions(t *testing.T) {
+	if _, errNew := NewHMACSigner(HMACOptions{}); errNew == nil {
+		t.Fatal("expected error for missing secret")
+	}
+	if _, errNew := NewHMACSigner(HMACOptions{Secret: "x", Algorithm: "md5"}); errNew == nil {
+		t.Fatal("expected error for unsupported algorithm")
+	}
+}
+
+func TestRequestBody_RestoresBodyWithoutGetBody(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com", nil)
+	req.Body = io.NopCloser(strings.NewReader("payload"))
+	data, errBody := RequestBody(req)
+	if errBody != nil || string(data) != "payload" {
+		t.Fatalf("RequestBody = %q, %v", data, errBody)
+	}
+	again, _ := io.ReadAll(req.Body)
+	if string(again) != "payload" {
+		t.Fatalf("body not restored: %q", again)
+	}
+}
+
+func TestRegisterSigner_LookupIsCaseInsensitive(t *testing.T) {
+	called := false
+	RegisterSigner("Corp-Gateway", SignerFunc(func(context.Context, *http.Request, *cliproxyauth.Auth) error {
+		called = true
+		return nil
+	}))
+	defer UnregisterSigner("corp-gateway")
+
+	signer, ok := LookupSigner(" corp-gateway ")
+	if !ok {
+		t.Fatal("expected registered signer")
+	}
+	_ = signer.SignRequest(context.Background(), nil, nil)
+	if !called {
+		t.Fatal("expected registered signer to be invoked")
+	}
+	UnregisterSigner("CORP-GATEWAY")
+	if _, ok = LookupSigner("corp-gateway"); ok {
+		t.Fatal("expected signer to be unregistered")
+	}
+}
@@ -9,27 +9,51 @@ import (
 )
 
 // Record contains the usage statistics captured for a single provider request.
+// Secondary upstream calls made on behalf of a request (for example Kiro
+// web_search follow-ups) publish their own record with ParentRequestID set to
+// the originating RequestID.
+//
+// Terminal is set on the final record of a request. Streams may additionally
+// publish non-terminal records with the running output estimate, which are
+// delivered only to StreamingUsagePlugin implementations.
+//
+// Estimated marks token counts computed locally with the model's tokenizer
+// because the upstream response carried no usage.
 type Record struct {
-	Provider      string
-	Model         string
-	VariantOrigin string
-	Variant       string
-	APIKey        string
-	AuthID        string
-	AuthIndex     string
-	Source        string
-	RequestedAt   time.Time
-	Failed        bool
-	Detail        Detail
-}
-
-// Detail holds the token usage breakdown.
+	Provider        string
+	Model           string
+	VariantOrigin   string
+	Variant         string
+	APIKey          string
+	AuthID          string
+	AuthIndex       string
+	Source          string
+	RequestID       string
+	ParentRequestID string
+	Experiment      string
+	Arm             string
+	Recovery        string
+	RequestedAt     time.Time
+	Failed          bool
+	Terminal        bool
+	Estimated       bool
+	Detail          Detail
+}
+
+// Detail holds the token usage breakdown and the timing of the attempt that
+// produced it. TimeToFirstByte is only measured for streams; Retries counts
+// the upstream attempts made for the request before this one.
 type Detail struct {
 	InputTokens     int64
 	OutputTokens    int64
 	ReasoningTokens int64
 	CachedTokens    int64
 	TotalTokens     int64
+
+	TimeToFirstByte       time.Duration
+	Duration              time.Duration
+	OutputTokensPerSecond float64
+	Retries               int
 }
 
 // Plugin consumes usage records emitted by the proxy runtime.
@@ -37,9 +61,17 @@ type Plugin interface {
 	HandleUsage(ctx context.Context, record Record)
 }
 
+// StreamingUsagePlugin is implemented by plugins that also want the partial
+// records published while a stream is still running.
+type StreamingUsagePlugin interface {
+	Plugin
+	HandleStreamingUsage(ctx context.Context, record Record)
+}
+
 type queueItem struct {
-	ctx    context.Context
-	record Record
+	ctx     context.Context
+	record  Record
+	partial bool
 }
 
 // Manager maintains a queue of usage records and delivers them to registered plugins.
@@ -108,6 +140,32 @@ func (m *Manager) Register(plugin Plugin) {
 // Publish enqueues a usage record for processing. If no plugin is registered
 // the record will be discarded downstream.
 func (m *Manager) Publish(ctx context.Context, record Record) {
+	m.enqueue(queueItem{ctx: ctx, record: record})
+}
+
+// PublishPartial enqueues a non-terminal record for streaming plugins.
+func (m *Manager) PublishPartial(ctx context.Context, record Record) {
+	record.Terminal = false
+	m.enqueue(queueItem{ctx: ctx, record: record, partial: true})
+}
+
+// HasStreamingPlugins reports whether any registered plugin implements
+// StreamingUsagePlugin.
+func (m *Manager) HasStreamingPlugins() bool {
+	if m == nil {
+		return false
+	}
+	m.pluginsMu.RLock()
+	defer m.pluginsMu.RUnlock()
+	for _, plugin := range m.plugins {
+		if _, ok := plugin.(StreamingUsagePlugin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) enqueue(item queueItem) {
 	if m == nil {
 		return
 	}
@@ -118,7 +176,7 @@ func (m *Manager) Publish(ctx context.Context, record Record) {
 		m.mu.Unlock()
 		return
 	}
-	m.queue = append(m.queue, queueItem{ctx: ctx, record: record})
+	m.queue = append(m.queue, item)
 	m.mu.Unlock()
 	m.cond.Signal()
 }
@@ -152,6 +210,12 @@ func (m *Manager) dispatch(item queueItem) {
 		if plugin == nil {
 			continue
 		}
+		if item.partial {
+			if streaming, ok := plugin.(StreamingUsagePlugin); ok {
+				safeInvokeStreaming(streaming, item.ctx, item.record)
+			}
+			continue
+		}
 		safeInvoke(plugin, item.ctx, item.record)
 	}
 }
@@ -165,6 +229,15 @@ func safeInvoke(plugin Plugin, ctx context.Context, record Record) {
 	plugin.HandleUsage(ctx, record)
 }
 
+func safeInvokeStreaming(plugin StreamingUsagePlugin, ctx context.Context, record Record) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("usage: streaming plugin panic recovered: %v", r)
+		}
+	}()
+	plugin.HandleStreamingUsage(ctx, record)
+}
+
 var defaultManager = NewManager(512)
 
 // DefaultManager returns the global usage manager instance.
@@ -176,6 +249,14 @@ func RegisterPlugin(plugin Plugin) { DefaultManager().Register(plugin) }
 // PublishRecord publishes a record using the default manager.
 func PublishRecord(ctx context.Context, record Record) { DefaultManager().Publish(ctx, record) }
 
+// PublishPartialRecord publishes a non-terminal record using the default manager.
+func PublishPartialRecord(ctx context.Context, record Record) {
+	DefaultManager().PublishPartial(ctx, record)
+}
+
+// HasStreamingPlugins reports whether the default manager has a streaming plugin.
+func HasStreamingPlugins() bool { return DefaultManager().HasStreamingPlugins() }
+
 // StartDefault starts the default manager's dispatcher.
 func StartDefault(ctx context.Context) { DefaultManager().Start(ctx) }
 
@@ -0,0 +1,68 @@
+package usage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingPlugin struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func (p *recordingPlugin) HandleUsage(_ context.Context, record Record) {
+	p.mu.Lock()
+	p.records = append(p.records, record)
+	p.mu.Unlock()
+}
+
+type recordingStreamingPlugin struct {
+	recordingPlugin
+	partials []Record
+}
+
+func (p *recordingStreamingPlugin) HandleStreamingUsage(_ context.Context, record Record) {
+	p.mu.Lock()
+	p.partials = append(p.partials, record)
+	p.mu.Unlock()
+}
+
+func TestManager_DeliversPartialRecordsOnlyToStreamingPlugins(t *testing.T) {
+	m := NewManager(0)
+	defer m.Stop()
+	plain := &recordingPlugin{}
+	streaming := &recordingStreamingPlugin{}
+	m.Register(plain)
+	if m.HasStreamingPlugins() {
+		t.Fatal("no streaming plugin registered yet")
+	}
+	m.Register(streaming)
+	if !m.HasStreamingPlugins() {
+		t.Fatal("expected a streaming plugin")
+	}
+
+	m.PublishPartial(context.Background(), Record{RequestID: "r1", Terminal: true})
+	m.Publish(context.Background(), Record{RequestID: "r1", Terminal: true})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		streaming.mu.Lock()
+		partials, finals := len(streaming.partials), len(streaming.records)
+		streaming.mu.Unlock()
+		plain.mu.Lock()
+		plainFinals := len(plain.records)
+		plain.mu.Unlock()
+		if partials == 1 && finals == 1 && plainFinals == 1 {
+			if streaming.partials[0].Terminal {
+				t.Fatal("partial records must not be terminal")
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("partials=%d finals=%d plain=%d", partials, finals, plainFinals)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -12,6 +12,42 @@ type Config = internalconfig.Config
 
 type StreamingConfig = internalconfig.StreamingConfig
 type UpstreamTimeouts = internalconfig.UpstreamTimeouts
+type SharedStateConfig = internalconfig.SharedStateConfig
+type DisconnectConfig = internalconfig.DisconnectConfig
+type ImagePreprocessConfig = internalconfig.ImagePreprocessConfig
+type EmbeddingCacheConfig = internalconfig.EmbeddingCacheConfig
+type RequestDedupConfig = internalconfig.RequestDedupConfig
+type ArtifactsConfig = internalconfig.ArtifactsConfig
+type JobsConfig = internalconfig.JobsConfig
+type CompressionConfig = internalconfig.CompressionConfig
+type ResponseSpillConfig = internalconfig.ResponseSpillConfig
+type ResourceLimitsConfig = internalconfig.ResourceLimitsConfig
+type LogRetentionConfig = internalconfig.LogRetentionConfig
+type LogRetentionPolicy = internalconfig.LogRetentionPolicy
+type MaintenanceConfig = internalconfig.MaintenanceConfig
+type RateLimitQueueConfig = internalconfig.RateLimitQueueConfig
+type RateLimitQueueKeyWeight = internalconfig.RateLimitQueueKeyWeight
+type QualityGuardConfig = internalconfig.QualityGuardConfig
+type ShadowTrafficConfig = internalconfig.ShadowTrafficConfig
+type ShadowTrafficRule = internalconfig.ShadowTrafficRule
+type Experiment = internalconfig.Experiment
+type ExperimentArm = internalconfig.ExperimentArm
+type SmartRouter = internalconfig.SmartRouter
+type SmartRouterRule = internalconfig.SmartRouterRule
+type SmartRouterClassifier = internalconfig.SmartRouterClassifier
+type SmartRouterClass = internalconfig.SmartRouterClass
+type PromptTemplate = internalconfig.PromptTemplate
+type PromptTemplateMessage = internalconfig.PromptTemplateMessage
+type APIKeyLimit = internalconfig.APIKeyLimit
+type ModelPrice = internalconfig.ModelPrice
+type LoopDetection = internalconfig.LoopDetection
+type APIKeyReasoning = internalconfig.APIKeyReasoning
+type ModelNamespace = internalconfig.ModelNamespace
+type APIKeyModels = internalconfig.APIKeyModels
+type APIKeyProfile = internalconfig.APIKeyProfile
+type AccessConfig = internalconfig.AccessConfig
+type AccessProvider = internalconfig.AccessProvider
+type ExternalAccessProvider = internalconfig.ExternalAccessProvider
 type InvalidTimeoutError = internalconfig.InvalidTimeoutError
 type TLSConfig = internalconfig.TLSConfig
 type RemoteManagement = internalconfig.RemoteManagement
@@ -21,6 +57,8 @@ type PayloadConfig = internalconfig.PayloadConfig
 type PayloadRule = internalconfig.PayloadRule
 type PayloadFilterRule = internalconfig.PayloadFilterRule
 type PayloadModelRule = internalconfig.PayloadModelRule
+type PayloadCalibrationRule = internalconfig.PayloadCalibrationRule
+type CalibrationPoint = internalconfig.CalibrationPoint
 
 type GeminiKey = internalconfig.GeminiKey
 type CodexKey = internalconfig.CodexKey
@@ -30,13 +68,42 @@ type VertexCompatModel = internalconfig.VertexCompatModel
 type OpenAICompatibility = internalconfig.OpenAICompatibility
 type OpenAICompatibilityAPIKey = internalconfig.OpenAICompatibilityAPIKey
 type OpenAICompatibilityModel = internalconfig.OpenAICompatibilityModel
+type ClientProfile = internalconfig.ClientProfile
+type AuthSchedule = internalconfig.AuthSchedule
 
 type TLS = internalconfig.TLSConfig
 
 const (
-	DefaultPanelGitHubRepository        = internalconfig.DefaultPanelGitHubRepository
-	DefaultConnectTimeoutSeconds        = internalconfig.DefaultConnectTimeoutSeconds
-	DefaultResponseHeaderTimeoutSeconds = internalconfig.DefaultResponseHeaderTimeoutSeconds
+	DefaultPanelGitHubRepository          = internalconfig.DefaultPanelGitHubRepository
+	DefaultConnectTimeoutSeconds          = internalconfig.DefaultConnectTimeoutSeconds
+	DefaultResponseHeaderTimeoutSeconds   = internalconfig.DefaultResponseHeaderTimeoutSeconds
+	DisconnectPolicyCancel                = internalconfig.DisconnectPolicyCancel
+	DisconnectPolicyComplete              = internalconfig.DisconnectPolicyComplete
+	DefaultArtifactMinBytes               = internalconfig.DefaultArtifactMinBytes
+	DefaultArtifactMaxAgeHours            = internalconfig.DefaultArtifactMaxAgeHours
+	DefaultJobsMaxConcurrent              = internalconfig.DefaultJobsMaxConcurrent
+	DefaultCompressionMinBytes            = internalconfig.DefaultCompressionMinBytes
+	DefaultMemoryLimitRatio               = internalconfig.DefaultMemoryLimitRatio
+	DefaultMemoryPressureRetryAfter       = internalconfig.DefaultMemoryPressureRetryAfter
+	DefaultMaintenanceMessage             = internalconfig.DefaultMaintenanceMessage
+	DefaultRateLimitQueueKeepAliveSeconds = internalconfig.DefaultRateLimitQueueKeepAliveSeconds
+	DefaultShadowTrafficMaxConcurrent     = internalconfig.DefaultShadowTrafficMaxConcurrent
+	DefaultEmbeddingCacheMaxEntries       = internalconfig.DefaultEmbeddingCacheMaxEntries
+	DefaultSmartRouterLongContextChars    = internalconfig.DefaultSmartRouterLongContextChars
+	DefaultSmartRouterCacheTTLSeconds     = internalconfig.DefaultSmartRouterCacheTTLSeconds
+	DefaultLoopThreshold                  = internalconfig.DefaultLoopThreshold
+	DefaultLoopWindowSeconds              = internalconfig.DefaultLoopWindowSeconds
+	LoopActionError                       = internalconfig.LoopActionError
+	LoopActionInject                      = internalconfig.LoopActionInject
+	ReasoningVerbatim                     = internalconfig.ReasoningVerbatim
+	ReasoningSummarized                   = internalconfig.ReasoningSummarized
+	ReasoningStripped                     = internalconfig.ReasoningStripped
+	StreamSanitizeRepair                  = internalconfig.StreamSanitizeRepair
+	StreamSanitizeStrict                  = internalconfig.StreamSanitizeStrict
+	ToolCallDeltasCoalesce                = internalconfig.ToolCallDeltasCoalesce
+	ToolCallDeltasFinal                   = internalconfig.ToolCallDeltasFinal
+	ClientProfileClaudeCode               = internalconfig.ClientProfileClaudeCode
+	ClientProfileCodexCLI                 = internalconfig.ClientProfileCodexCLI
 )
 
 func LoadConfig(configFile string) (*Config, error) { return internalconfig.LoadConfig(configFile) }
@@ -0,0 +1,127 @@
+package proxyutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// DialFunc has the signature of http.Transport.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// ConnStats counts the outbound connections dialed through tracked dialers.
+type ConnStats struct {
+	// Open is the number of connections dialed and not yet closed.
+	Open int64 `json:"open"`
+	// Dialed is the number of connections established since startup.
+	Dialed int64 `json:"dialed"`
+	// Failed is the number of dials that returned an error other than
+	// cancellation.
+	Failed int64 `json:"failed"`
+	// Canceled is the number of dials abandoned because their context ended.
+	Canceled int64 `json:"canceled"`
+}
+
+var connCounters struct {
+	open, dialed, failed, canceled atomic.Int64
+}
+
+// Connections returns the counters of the tracked dialers.
+func Connections() ConnStats {
+	return ConnStats{
+		Open:     connCounters.open.Load(),
+		Dialed:   connCounters.dialed.Load(),
+		Failed:   connCounters.failed.Load(),
+		Canceled: connCounters.canceled.Load(),
+	}
+}
+
+// trackedConn decrements the open counter the first time it is closed.
+type trackedConn struct {
+	net.Conn
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() { connCounters.open.Add(-1) })
+	return c.Conn.Close()
+}
+
+// Track wraps dial so the connections it returns are counted by Connections.
+func Track(dial DialFunc) DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			if dialCanceled(ctx, err) {
+				connCounters.canceled.Add(1)
+			} else {
+				connCounters.failed.Add(1)
+			}
+			return nil, err
+		}
+		connCounters.dialed.Add(1)
+		connCounters.open.Add(1)
+		return &trackedConn{Conn: conn}, nil
+	}
+}
+
+// dialCanceled reports whether a dial failed because ctx ended. Dialers
+// report it in several ways: the SOCKS5 dialer turns the context deadline
+// into a connection deadline and fails with an i/o timeout that may precede
+// ctx.Err.
+func dialCanceled(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// DialContext dials addr through dialer and returns when ctx ends, even if
+// the dialer cannot be canceled. Dialers implementing proxy.ContextDialer,
+// such as the SOCKS5 dialer, are canceled directly; for the others the dial
+// runs in a goroutine that closes the connection should it arrive after ctx
+// ended instead of leaking it.
+func DialContext(ctx context.Context, dialer proxy.Dialer, network, addr string) (net.Conn, error) {
+	if contextDialer, ok := dialer.(proxy.ContextDialer); ok {
+		return contextDialer.DialContext(ctx, network, addr)
+	}
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	// resultCh is unbuffered so a result is either received here or, once
+	// the dial is abandoned, discarded by the goroutine.
+	resultCh := make(chan dialResult)
+	abandoned := make(chan struct{})
+	go func() {
+		conn, err := dialer.Dial(network, addr)
+		select {
+		case resultCh <- dialResult{conn: conn, err: err}:
+		case <-abandoned:
+			if conn != nil {
+				_ = conn.Close()
+			}
+		}
+	}()
+	select {
+	case result := <-resultCh:
+		return result.conn, result.err
+	case <-ctx.Done():
+		close(abandoned)
+		return nil, ctx.Err()
+	}
+}
+
+// ContextDialFunc returns a DialFunc that dials through dialer with
+// DialContext.
+func ContextDialFunc(dialer proxy.Dialer) DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return DialContext(ctx, dialer, network, addr)
+	}
+}
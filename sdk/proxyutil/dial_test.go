@@ -0,0 +1,138 @@
+package proxyutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// blockingDialer dials a pipe once release is closed, ignoring contexts the
+// way a plain proxy.Dialer does.
+type blockingDialer struct {
+	release chan struct{}
+	dialed  chan net.Conn
+}
+
+func (d *blockingDialer) Dial(_, _ string) (net.Conn, error) {
+	<-d.release
+	client, server := net.Pipe()
+	d.dialed <- server
+	return client, nil
+}
+
+// waitForGoroutines fails the test unless the goroutine count drops back to
+// baseline.
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("goroutines leaked: %d > %d\n%s", runtime.NumGoroutine(), baseline, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDialContext_ClosesConnectionsArrivingAfterCancel(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+	dialer := &blockingDialer{release: make(chan struct{}), dialed: make(chan net.Conn, 1)}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := DialContext(ctx, dialer, "tcp", "example.com:443")
+	if !errors.Is(err, context.DeadlineExceeded) || conn != nil {
+		t.Fatalf("DialContext = %v, %v; want deadline exceeded", conn, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("DialContext returned after %v", elapsed)
+	}
+
+	close(dialer.release)
+	server := <-dialer.dialed
+	if _, errRead := server.Read(make([]byte, 1)); !errors.Is(errRead, io.EOF) {
+		t.Fatalf("late connection was not closed: %v", errRead)
+	}
+	waitForGoroutines(t, baseline)
+}
+
+func TestBuildHTTPTransport_SOCKS5DialStopsWithContext(t *testing.T) {
+	// The proxy accepts connections but never answers the SOCKS5 greeting.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, errAccept := listener.Accept()
+		if errAccept == nil {
+			accepted <- conn
+		}
+	}()
+
+	transport, _, err := BuildHTTPTransport("socks5://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("BuildHTTPTransport: %v", err)
+	}
+	baseline := runtime.NumGoroutine()
+	before := Connections()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err = transport.DialContext(ctx, "tcp", "example.com:443"); err == nil {
+		t.Fatal("expected dial to fail once the context ended")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("DialContext returned after %v", elapsed)
+	}
+
+	server := <-accepted
+	defer func() { _ = server.Close() }()
+	_ = server.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, errRead := io.ReadAll(server); errRead != nil {
+		t.Fatalf("proxy connection was not closed: %v", errRead)
+	}
+	if after := Connections(); after.Canceled != before.Canceled+1 || after.Open != before.Open {
+		t.Fatalf("connection stats = %+v, before %+v", after, before)
+	}
+	waitForGoroutines(t, baseline)
+}
+
+func TestTrack_CountsOpenConnections(t *testing.T) {
+	var server net.Conn
+	dial := Track(func(context.Context, string, string) (net.Conn, error) {
+		var client net.Conn
+		client, server = net.Pipe()
+		return client, nil
+	})
+	before := Connections()
+	conn, err := dial(context.Background(), "tcp", "example.com:443")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+	if got := Connections(); got.Open != before.Open+1 || got.Dialed != before.Dialed+1 {
+		t.Fatalf("after dial: %+v, before %+v", got, before)
+	}
+	_ = conn.Close()
+	_ = conn.Close()
+	if got := Connections(); got.Open != before.Open {
+		t.Fatalf("after close: %+v, before %+v", got, before)
+	}
+
+	failing := Track(func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("refused")
+	})
+	if _, err = failing(context.Background(), "tcp", "example.com:443"); err == nil {
+		t.Fatal("expected dial error")
+	}
+	if got := Connections(); got.Failed != before.Failed+1 {
+		t.Fatalf("after failure: %+v, before %+v", got, before)
+	}
+}
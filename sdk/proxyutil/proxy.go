@@ -1,9 +1,7 @@
 package proxyutil
 
 import (
-	"context"
 	"fmt"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -103,10 +101,8 @@ func BuildHTTPTransport(raw string) (*http.Transport, Mode, error) {
 				return nil, setting.Mode, fmt.Errorf("create SOCKS5 dialer failed: %w", errSOCKS5)
 			}
 			return &http.Transport{
-				Proxy: nil,
-				DialContext: func(_ context.Context, network, addr string) (net.Conn, error) {
-					return dialer.Dial(network, addr)
-				},
+				Proxy:       nil,
+				DialContext: Track(ContextDialFunc(dialer)),
 			}, setting.Mode, nil
 		}
 		return &http.Transport{Proxy: http.ProxyURL(setting.URL)}, setting.Mode, nil
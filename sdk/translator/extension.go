@@ -0,0 +1,149 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StreamDoneChunk is sent by some executors once the upstream stream has
+// ended, giving converters a chance to flush buffered output.
+const StreamDoneChunk = "[DONE]"
+
+// RequestTranslator converts a request payload from the inbound format to the provider format.
+type RequestTranslator interface {
+	TranslateRequest(model string, rawJSON []byte, stream bool) []byte
+}
+
+// StreamConverter converts the chunks of a single streaming response. A new
+// converter is created for every stream, so it may keep state in its fields.
+type StreamConverter interface {
+	ConvertChunk(ctx context.Context, chunk []byte) []string
+}
+
+// StreamTranslator converts streaming responses from the provider format back
+// to the inbound format.
+type StreamTranslator interface {
+	NewStreamConverter(model string, originalRequestRawJSON, requestRawJSON []byte) StreamConverter
+}
+
+// NonStreamTranslator converts a complete response from the provider format
+// back to the inbound format.
+type NonStreamTranslator interface {
+	TranslateResponse(ctx context.Context, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte) string
+}
+
+// TokenCountTranslator renders a token count in the inbound format.
+type TokenCountTranslator interface {
+	TranslateTokenCount(ctx context.Context, count int64) string
+}
+
+// RegisterTranslator registers t for requests arriving in format from and
+// served by a provider speaking format to. Besides RequestTranslator, t may
+// implement StreamTranslator, NonStreamTranslator and TokenCountTranslator;
+// responses are passed through unchanged for the parts it does not implement.
+// A later registration for the same pair replaces the earlier one.
+func (r *Registry) RegisterTranslator(from, to Format, t RequestTranslator) error {
+	if strings.TrimSpace(from.String()) == "" || strings.TrimSpace(to.String()) == "" {
+		return fmt.Errorf("translator: source and target formats are required")
+	}
+	if t == nil {
+		return fmt.Errorf("translator: nil translator for %s -> %s", from, to)
+	}
+	var response ResponseTransform
+	if st, ok := t.(StreamTranslator); ok {
+		response.Stream = StreamTransform(st)
+	}
+	if nt, ok := t.(NonStreamTranslator); ok {
+		response.NonStream = func(ctx context.Context, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, _ *any) string {
+			return nt.TranslateResponse(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON)
+		}
+	}
+	if tt, ok := t.(TokenCountTranslator); ok {
+		response.TokenCount = tt.TranslateTokenCount
+	}
+	r.Register(from, to, t.TranslateRequest, response)
+	return nil
+}
+
+// Unregister removes the transforms registered between two formats.
+func (r *Registry) Unregister(from, to Format) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.requests[from], to)
+	if len(r.requests[from]) == 0 {
+		delete(r.requests, from)
+	}
+	delete(r.responses[from], to)
+	if len(r.responses[from]) == 0 {
+		delete(r.responses, from)
+	}
+}
+
+// HasRequestTransformer indicates whether a request translator exists.
+func (r *Registry) HasRequestTransformer(from, to Format) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.requests[from][to]
+	return ok
+}
+
+// Formats lists every format that appears in a registration, sorted.
+func (r *Registry) Formats() []Format {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[Format]struct{})
+	for from, byTarget := range r.responses {
+		seen[from] = struct{}{}
+		for to := range byTarget {
+			seen[to] = struct{}{}
+		}
+	}
+	formats := make([]Format, 0, len(seen))
+	for f := range seen {
+		formats = append(formats, f)
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i] < formats[j] })
+	return formats
+}
+
+// StreamTransform adapts a StreamTranslator to a ResponseStreamTransform. The
+// converter for a stream lives in the per-stream param slot, so it is created
+// on the first chunk and reused for the rest.
+func StreamTransform(t StreamTranslator) ResponseStreamTransform {
+	return func(ctx context.Context, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) []string {
+		var converter StreamConverter
+		if param != nil {
+			converter, _ = (*param).(StreamConverter)
+		}
+		if converter == nil {
+			converter = t.NewStreamConverter(model, originalRequestRawJSON, requestRawJSON)
+			if converter == nil {
+				return []string{string(rawJSON)}
+			}
+			if param != nil {
+				*param = converter
+			}
+		}
+		return converter.ConvertChunk(ctx, rawJSON)
+	}
+}
+
+// RegisterTranslator registers t on the default registry.
+func RegisterTranslator(from, to Format, t RequestTranslator) error {
+	return defaultRegistry.RegisterTranslator(from, to, t)
+}
+
+// Unregister removes transforms from the default registry.
+func Unregister(from, to Format) {
+	defaultRegistry.Unregister(from, to)
+}
+
+// Formats lists the formats known to the default registry.
+func Formats() []Format {
+	return defaultRegistry.Formats()
+}
@@ -0,0 +1,75 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type upperTranslator struct{}
+
+type countingConverter struct{ seen int }
+
+func (upperTranslator) TranslateRequest(_ string, rawJSON []byte, _ bool) []byte {
+	return []byte(strings.ToUpper(string(rawJSON)))
+}
+
+func (upperTranslator) NewStreamConverter(string, []byte, []byte) StreamConverter {
+	return &countingConverter{}
+}
+
+func (upperTranslator) TranslateResponse(_ context.Context, _ string, _, _, rawJSON []byte) string {
+	return "resp:" + string(rawJSON)
+}
+
+func (c *countingConverter) ConvertChunk(_ context.Context, chunk []byte) []string {
+	c.seen++
+	if string(chunk) == StreamDoneChunk {
+		return []string{fmt.Sprintf("done after %d", c.seen-1)}
+	}
+	return []string{fmt.Sprintf("%d:%s", c.seen, chunk)}
+}
+
+func TestRegistry_RegisterTranslator(t *testing.T) {
+	r := NewRegistry()
+	from, to := Format("cohere"), Format("myprov")
+	if err := r.RegisterTranslator(from, to, upperTranslator{}); err != nil {
+		t.Fatalf("RegisterTranslator: %v", err)
+	}
+
+	if got := string(r.TranslateRequest(from, to, "m", []byte("hi"), false)); got != "HI" {
+		t.Fatalf("request = %q, want HI", got)
+	}
+	ctx := context.Background()
+	if got := r.TranslateNonStream(ctx, to, from, "m", nil, nil, []byte("x"), nil); got != "resp:x" {
+		t.Fatalf("non-stream = %q", got)
+	}
+
+	var param any
+	var chunks []string
+	for _, chunk := range []string{"a", "b", StreamDoneChunk} {
+		chunks = append(chunks, r.TranslateStream(ctx, to, from, "m", nil, nil, []byte(chunk), &param)...)
+	}
+	if strings.Join(chunks, ",") != "1:a,2:b,done after 2" {
+		t.Fatalf("stream chunks = %v, want one converter per stream", chunks)
+	}
+
+	if formats := r.Formats(); len(formats) != 2 || formats[0] != from || formats[1] != to {
+		t.Fatalf("formats = %v", formats)
+	}
+	r.Unregister(from, to)
+	if r.HasRequestTransformer(from, to) || r.HasResponseTransformer(from, to) {
+		t.Fatal("translator still registered after Unregister")
+	}
+}
+
+func TestRegistry_RegisterTranslatorRejectsInvalidInput(t *testing.T) {
+	r := NewRegistry()
+	if err := r.RegisterTranslator("", "myprov", upperTranslator{}); err == nil {
+		t.Fatal("expected an error for an empty source format")
+	}
+	if err := r.RegisterTranslator("cohere", "myprov", nil); err == nil {
+		t.Fatal("expected an error for a nil translator")
+	}
+}